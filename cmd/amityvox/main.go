@@ -7,36 +7,52 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
+	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/alexedwards/argon2id"
-
 	"github.com/go-chi/chi/v5"
 
+	"github.com/amityvox/amityvox/internal/adminops"
 	"github.com/amityvox/amityvox/internal/api"
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/automod"
+	"github.com/amityvox/amityvox/internal/cdn"
 	"github.com/amityvox/amityvox/internal/config"
 	"github.com/amityvox/amityvox/internal/database"
+	"github.com/amityvox/amityvox/internal/emailgateway"
 	"github.com/amityvox/amityvox/internal/encryption"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/featureflags"
 	"github.com/amityvox/amityvox/internal/federation"
 	"github.com/amityvox/amityvox/internal/gateway"
+	"github.com/amityvox/amityvox/internal/importer"
+	"github.com/amityvox/amityvox/internal/instancemove"
+	"github.com/amityvox/amityvox/internal/linksafety"
+	"github.com/amityvox/amityvox/internal/loadtest"
+	"github.com/amityvox/amityvox/internal/matrix"
 	"github.com/amityvox/amityvox/internal/media"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/notifications"
+	"github.com/amityvox/amityvox/internal/payments"
 	"github.com/amityvox/amityvox/internal/presence"
 	"github.com/amityvox/amityvox/internal/search"
+	"github.com/amityvox/amityvox/internal/slo"
 	"github.com/amityvox/amityvox/internal/voice"
 	"github.com/amityvox/amityvox/internal/workers"
 )
@@ -70,6 +86,21 @@ func main() {
 			fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			os.Exit(1)
 		}
+	case "loadtest":
+		if err := runLoadtest(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "migrate-instance":
+		if err := runMigrateInstance(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	case "errors":
+		if err := runErrors(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
 	case "version":
 		runVersion()
 	case "help", "--help", "-h":
@@ -89,11 +120,14 @@ func printUsage() {
 	fmt.Println("  amityvox <command> [options]")
 	fmt.Println()
 	fmt.Println("Commands:")
-	fmt.Println("  serve     Start the AmityVox server")
-	fmt.Println("  migrate   Run database migrations")
-	fmt.Println("  admin     Manage users and instance settings")
-	fmt.Println("  version   Print version information")
-	fmt.Println("  help      Show this help message")
+	fmt.Println("  serve             Start the AmityVox server")
+	fmt.Println("  migrate           Run database migrations")
+	fmt.Println("  migrate-instance  Move this instance to a new domain and notify peers")
+	fmt.Println("  admin             Manage users and instance settings")
+	fmt.Println("  loadtest          Measure message delivery latency against an instance")
+	fmt.Println("  errors            Print the API error codes as Markdown (--json for JSON)")
+	fmt.Println("  version           Print version information")
+	fmt.Println("  help              Show this help message")
 	fmt.Println()
 	fmt.Println("Configuration:")
 	fmt.Println("  Config file:  amityvox.toml (or set AMITYVOX_CONFIG_PATH)")
@@ -131,6 +165,8 @@ func runServe() error {
 		return fmt.Errorf("connecting to database: %w", err)
 	}
 	defer db.Close()
+	slowQuery, _ := cfg.Database.SlowQueryThresholdParsed() // validated at load
+	db.SetSlowQueryThreshold(slowQuery)
 
 	// Run migrations.
 	if err := database.MigrateUp(cfg.Database.URL, logger); err != nil {
@@ -145,6 +181,15 @@ func runServe() error {
 	logger.Info("local instance ready", slog.String("instance_id", instanceID),
 		slog.Bool("federation_key_loaded", len(federationKey) > 0))
 
+	// Payloads carry ready-to-load media URLs unless the instance turns them off.
+	if cfg.Media.ResolveURLs {
+		base := cfg.Media.CDNURL
+		if base == "" {
+			base = "https://" + cfg.Instance.Domain
+		}
+		cdn.Configure(cdn.New(base, instanceID))
+	}
+
 	// Connect to NATS event bus.
 	bus, err := events.New(cfg.NATS.URL, logger)
 	if err != nil {
@@ -171,38 +216,59 @@ func runServe() error {
 	}
 
 	// Create auth service.
+	var ldapCfg *auth.LDAPConfig
+	if cfg.Auth.LDAP.Enabled {
+		l := cfg.Auth.LDAP
+		ldapCfg = &auth.LDAPConfig{
+			URL:                  l.URL,
+			StartTLS:             l.StartTLS,
+			InsecureSkipVerify:   l.InsecureSkipVerify,
+			BindDN:               l.BindDN,
+			BindPassword:         l.BindPassword,
+			BaseDN:               l.BaseDN,
+			UserFilter:           l.UserFilter,
+			UsernameAttribute:    l.UsernameAttribute,
+			DisplayNameAttribute: l.DisplayNameAttribute,
+			EmailAttribute:       l.EmailAttribute,
+			GroupAttribute:       l.GroupAttribute,
+		}
+		for _, gr := range l.GroupRoles {
+			ldapCfg.GroupRoles = append(ldapCfg.GroupRoles, auth.LDAPGroupRole{
+				Group: gr.Group, GuildID: gr.GuildID, RoleID: gr.RoleID,
+			})
+		}
+		logger.Info("LDAP authentication enabled", slog.String("url", l.URL))
+	}
+
 	authSvc := auth.NewService(auth.Config{
 		Pool:            db.Pool,
 		Cache:           cache,
+		Bus:             bus,
 		InstanceID:      instanceID,
 		SessionDuration: sessionDuration,
 		RegEnabled:      cfg.Auth.RegistrationEnabled,
 		InviteOnly:      cfg.Auth.InviteOnly,
 		RequireEmail:    cfg.Auth.RequireEmail,
-		Logger:          logger,
+		LDAP:            ldapCfg,
+		Abuse: auth.AbuseConfig{
+			RegistrationsPerIPHour: cfg.Auth.Abuse.RegistrationsPerIPHour,
+			RegistrationsPerIPDay:  cfg.Auth.Abuse.RegistrationsPerIPDay,
+			BlockDisposableEmail:   cfg.Auth.Abuse.BlockDisposableEmail,
+			DisposableDomains:      cfg.Auth.Abuse.DisposableDomains,
+			SignalKey:              signalKey(cfg.Auth.Abuse.SignalSecret, federationKey),
+		},
+		Logger: logger,
 	})
 
+	// Regional endpoints are probed on this interval; validated at load.
+	regionCheckInterval, _ := cfg.Regions.HealthCheckIntervalParsed()
+
 	// Create media/S3 storage service.
 	var mediaSvc *media.Service
 	if cfg.Storage.Endpoint != "" {
-		maxBytes, _ := cfg.Media.MaxUploadSizeBytes()
-		if maxBytes <= 0 {
-			maxBytes = 100 * 1024 * 1024
-		}
-		logger.Info("media upload limit configured", slog.Int64("max_bytes", maxBytes), slog.String("max_upload_size", cfg.Media.MaxUploadSize))
-		svc, err := media.New(media.Config{
-			Endpoint:       cfg.Storage.Endpoint,
-			Bucket:         cfg.Storage.Bucket,
-			AccessKey:      cfg.Storage.AccessKey,
-			SecretKey:      cfg.Storage.SecretKey,
-			Region:         cfg.Storage.Region,
-			UseSSL:         cfg.Storage.UseSSL,
-			MaxUploadMB:    maxBytes / (1024 * 1024),
-			ThumbnailSizes: cfg.Media.ImageThumbnailSizes,
-			StripExif:      cfg.Media.StripExif,
-			Pool:           db.Pool,
-			Logger:         logger,
-		})
+		mediaCfg := mediaConfig(cfg, db, logger)
+		logger.Info("media upload limit configured", slog.Int64("max_bytes", mediaCfg.MaxUploadMB*1024*1024), slog.String("max_upload_size", cfg.Media.MaxUploadSize))
+		svc, err := media.New(mediaCfg)
 		if err != nil {
 			logger.Warn("media service unavailable, file uploads disabled", slog.String("error", err.Error()))
 		} else {
@@ -210,7 +276,9 @@ func runServe() error {
 				logger.Warn("could not ensure S3 bucket", slog.String("error", err.Error()))
 			}
 			mediaSvc = svc
-			logger.Info("media service ready", slog.String("endpoint", cfg.Storage.Endpoint))
+			mediaSvc.StartHealthChecks(ctx, regionCheckInterval)
+			logger.Info("media service ready", slog.String("endpoint", cfg.Storage.Endpoint),
+				slog.Int("regions", 1+len(mediaCfg.Regions)))
 		}
 	}
 
@@ -235,13 +303,17 @@ func runServe() error {
 	}
 
 	// Create federation service.
+	maxTimestampAge, _ := cfg.Federation.MaxTimestampAgeParsed() // validated at load
+	clockSkew, _ := cfg.Federation.ClockSkewToleranceParsed()    // validated at load
 	fedSvc := federation.New(federation.Config{
-		Pool:           db.Pool,
-		InstanceID:     instanceID,
-		Domain:         cfg.Instance.Domain,
-		PrivateKey:     federationKey,
-		EnforceIPCheck: cfg.Federation.EnforceIPCheck,
-		Logger:         logger,
+		Pool:            db.Pool,
+		InstanceID:      instanceID,
+		Domain:          cfg.Instance.Domain,
+		PrivateKey:      federationKey,
+		EnforceIPCheck:  cfg.Federation.EnforceIPCheck,
+		Logger:          logger,
+		MaxTimestampAge: maxTimestampAge,
+		ClockSkew:       clockSkew,
 	})
 
 	// Refresh federation peer public keys on startup to handle key rotations.
@@ -256,17 +328,40 @@ func runServe() error {
 
 	// Create notification service (always — handles preferences; push is optional).
 	notifSvc := notifications.NewService(notifications.Config{
-		Pool:              db.Pool,
-		Logger:            logger,
-		VAPIDPublicKey:    cfg.Push.VAPIDPublicKey,
-		VAPIDPrivateKey:   cfg.Push.VAPIDPrivateKey,
-		VAPIDContactEmail: cfg.Push.VAPIDContactEmail,
-		Bus:               bus,
+		Pool:                    db.Pool,
+		Logger:                  logger,
+		VAPIDPublicKey:          cfg.Push.VAPIDPublicKey,
+		VAPIDPrivateKey:         cfg.Push.VAPIDPrivateKey,
+		VAPIDContactEmail:       cfg.Push.VAPIDContactEmail,
+		VAPIDPreviousPublicKey:  cfg.Push.VAPIDPreviousPublicKey,
+		VAPIDPreviousPrivateKey: cfg.Push.VAPIDPreviousPrivateKey,
+		Bus:                     bus,
 	})
 	if cfg.Push.VAPIDPublicKey != "" && cfg.Push.VAPIDPrivateKey != "" {
 		logger.Info("push notifications enabled")
 	}
 
+	// Create guild import service (jobs run in the background workers).
+	importSvc := importer.NewService(importer.Config{
+		Pool:   db.Pool,
+		Bus:    bus,
+		Media:  mediaSvc,
+		Logger: logger,
+	})
+
+	// Create link safety checker (optional — blocklist, plus Safe Browsing with a key).
+	var linkSafety *linksafety.Checker
+	if cfg.LinkSafety.Enabled {
+		ttl, _ := cfg.LinkSafety.CacheTTLParsed()
+		linkSafety = linksafety.New(linksafety.Config{
+			Pool:               db.Pool,
+			SafeBrowsingAPIKey: cfg.LinkSafety.SafeBrowsingAPIKey,
+			CacheTTL:           ttl,
+			InterstitialURL:    cfg.LinkSafety.InterstitialURL,
+		})
+		logger.Info("link safety enabled", slog.Bool("safe_browsing", cfg.LinkSafety.SafeBrowsingAPIKey != ""))
+	}
+
 	// Start background workers.
 	workerMgr := workers.New(workers.Config{
 		Pool:               db.Pool,
@@ -275,7 +370,10 @@ func runServe() error {
 		Media:              mediaSvc,
 		AutoMod:            automodSvc,
 		Notifications:      notifSvc,
+		Importer:           importSvc,
+		LinkSafety:         linkSafety,
 		BackfillWindowDays: cfg.Federation.BackfillWindowDays,
+		InstanceID:         instanceID,
 		Logger:             logger,
 	})
 	workerMgr.Start(ctx)
@@ -283,18 +381,39 @@ func runServe() error {
 	// Create voice service (optional — only when LiveKit is configured).
 	var voiceSvc *voice.Service
 	if cfg.LiveKit.URL != "" && cfg.LiveKit.APIKey != "" && cfg.LiveKit.APISecret != "" {
+		liveKitRegions := make([]voice.RegionConfig, 0, len(cfg.LiveKit.Regions))
+		for _, lr := range cfg.LiveKit.Regions {
+			liveKitRegions = append(liveKitRegions, voice.RegionConfig{
+				Name:      lr.Name,
+				URL:       lr.URL,
+				PublicURL: lr.PublicURL,
+				APIKey:    lr.APIKey,
+				APISecret: lr.APISecret,
+			})
+		}
 		svc, err := voice.New(voice.Config{
-			URL:       cfg.LiveKit.URL,
-			APIKey:    cfg.LiveKit.APIKey,
-			APISecret: cfg.LiveKit.APISecret,
-			Pool:      db.Pool,
-			Logger:    logger,
+			URL:           cfg.LiveKit.URL,
+			PublicURL:     cfg.LiveKit.PublicURL,
+			APIKey:        cfg.LiveKit.APIKey,
+			APISecret:     cfg.LiveKit.APISecret,
+			Pool:          db.Pool,
+			Bus:           bus,
+			Logger:        logger,
+			Regions:       liveKitRegions,
+			PrimaryRegion: cfg.Regions.Primary,
+			LocalRegion:   cfg.Regions.LocalRegion(),
+			Strategy:      cfg.Regions.Strategy,
 		})
 		if err != nil {
 			logger.Warn("voice service unavailable", slog.String("error", err.Error()))
 		} else {
 			voiceSvc = svc
-			logger.Info("voice service ready", slog.String("url", cfg.LiveKit.URL))
+			voiceSvc.StartAFKSweep(ctx)
+			voiceSvc.StartReconcile(ctx)
+			voiceSvc.StartCallSweep(ctx)
+			voiceSvc.StartHealthChecks(ctx, regionCheckInterval)
+			logger.Info("voice service ready", slog.String("url", cfg.LiveKit.URL),
+				slog.Int("regions", 1+len(liveKitRegions)))
 		}
 	}
 
@@ -306,12 +425,31 @@ func runServe() error {
 
 	// Create federation sync service (message routing between instances).
 	// Created before the API server so handlers can use it for write-routing.
+	// A zero quota in the config disables it; SyncConfig uses negative for that.
+	peerEventsPerMinute, peerBytesPerMinute := cfg.Federation.PeerEventsPerMinute, cfg.Federation.PeerBytesPerMinute
+	if peerEventsPerMinute == 0 {
+		peerEventsPerMinute = -1
+	}
+	if peerBytesPerMinute == 0 {
+		peerBytesPerMinute = -1
+	}
+	peerThrottle, _ := cfg.Federation.PeerThrottleParsed() // validated at load
 	syncSvc := federation.NewSyncService(fedSvc, bus, logger, federation.SyncConfig{
 		PeerInboxLimit:      cfg.Federation.PeerInboxLimit,
 		DeliveryConcurrency: cfg.Federation.DeliveryConcurrency,
 		BackfillWindowDays:  cfg.Federation.BackfillWindowDays,
+		PeerEventsPerMinute: peerEventsPerMinute,
+		PeerBytesPerMinute:  peerBytesPerMinute,
+		PeerThrottle:        peerThrottle,
 	})
 
+	// Feature flags are cached per node and reloaded when an operator
+	// changes them.
+	flagStore := featureflags.NewStore(db.Pool, logger)
+	if err := flagStore.Watch(bus); err != nil {
+		logger.Warn("failed to watch feature flag changes", slog.String("error", err.Error()))
+	}
+
 	// Create and start HTTP API server.
 	srv := api.NewServer(db, cfg, authSvc, bus, cache, mediaSvc, searchSvc, voiceSvc, instanceID, logger)
 	srv.Encryption = encryptionSvc
@@ -320,18 +458,34 @@ func runServe() error {
 	srv.FedSvc = fedSvc
 	srv.FedProxy = syncSvc
 	srv.Version = version
+	srv.Jobs = workerMgr
+	// Callbacks are signed with their own key so that a signature handed to
+	// an integrator can never pass as a federation message.
+	srv.SigningKey = ed25519.NewKeyFromSeed(deriveKey(federationKey, "amityvox callback signing v1"))
+	srv.FeatureFlags = flagStore
 
 	// Register API routes after all optional services are set.
 	srv.RegisterRoutes()
 
 	// Public federation discovery and handshake (rate limited — no signature verification).
+	// Instance move notices are signed with the key being moved and verified by the handler.
 	fedRL := srv.RateLimitGlobal()
 	srv.Router.With(fedRL).Get("/.well-known/amityvox", fedSvc.HandleDiscovery)
 	srv.Router.With(fedRL).Post("/federation/v1/handshake", fedSvc.HandleHandshake)
+	srv.Router.With(fedRL).Post("/federation/v1/instance-move", fedSvc.HandleInstanceMove)
+	srv.Router.With(fedRL).Get("/federation/v1/directory", fedSvc.HandleDirectory)
 
 	// Wire voice service into federation sync for federated voice token generation.
 	if voiceSvc != nil {
-		syncSvc.SetVoiceService(voiceSvc, srv.Config.LiveKit.PublicURL)
+		syncSvc.SetVoiceService(voiceSvc)
+	}
+
+	// Operators can pause outbound federation with the admin subsystem toggles.
+	syncSvc.SetOutboundPause(func() bool { return srv.SubsystemDisabled(models.SubsystemFederation) })
+
+	// Wire media storage into federation sync for the signed media endpoint.
+	if mediaSvc != nil {
+		syncSvc.SetMediaSource(mediaSvc)
 	}
 
 	// Wire backfill trigger: when a peer recovers to healthy, request missed events.
@@ -356,17 +510,26 @@ func runServe() error {
 	srv.Router.Post("/federation/v1/sync", syncSvc.HandleSync)
 	srv.Router.Get("/federation/v1/users/lookup", fedSvc.HandleUserLookup)
 	srv.Router.Post("/federation/v1/users/{userID}/profile", syncSvc.HandleUserProfile)
+	srv.Router.Post("/federation/v1/media/{fileID}", syncSvc.HandleFederatedMedia)
+	srv.Router.Post("/federation/v1/emoji", syncSvc.HandleFederatedInstanceEmoji)
 
 	// Wire federation DM notifier into the users handler.
 	if cfg.Instance.FederationMode != "closed" && srv.UserHandler != nil {
 		srv.UserHandler.NotifyFederatedDM = syncSvc.NotifyFederatedDM
 	}
+	// Negotiate MLS sessions with DM participants on other instances.
+	if cfg.Instance.FederationMode != "closed" {
+		encryptionSvc.FederatedDMs = syncSvc
+	}
 
 	// Federation DM endpoints (signed, no rate limit).
 	srv.Router.Post("/federation/v1/dm/create", syncSvc.HandleFederatedDMCreate)
 	srv.Router.Post("/federation/v1/dm/message", syncSvc.HandleFederatedDMMessage)
 	srv.Router.Post("/federation/v1/dm/recipient-add", syncSvc.HandleFederatedDMRecipientAdd)
 	srv.Router.Post("/federation/v1/dm/recipient-remove", syncSvc.HandleFederatedDMRecipientRemove)
+	srv.Router.Post("/federation/v1/dm/group/state", syncSvc.HandleFederatedGroupDMState)
+	srv.Router.Post("/federation/v1/dm/group/recipients", syncSvc.HandleFederatedGroupDMRecipients)
+	srv.Router.Post("/federation/v1/dm/mls/key-packages/claim", syncSvc.HandleFederatedDMClaimKeyPackage)
 
 	// Federation guild endpoints (signed, no rate limit).
 	srv.Router.Get("/federation/v1/guilds/{guildID}/preview", syncSvc.HandleFederatedGuildPreview)
@@ -376,6 +539,8 @@ func runServe() error {
 	srv.Router.Post("/federation/v1/guilds/{guildID}/channels/{channelID}/messages", syncSvc.HandleFederatedGuildMessages)
 	srv.Router.Post("/federation/v1/guilds/{guildID}/channels/{channelID}/messages/create", syncSvc.HandleFederatedGuildPostMessage)
 	srv.Router.Post("/federation/v1/guilds/{guildID}/members", syncSvc.HandleFederatedGuildMembers)
+	srv.Router.Post("/federation/v1/guilds/{guildID}/permissions", syncSvc.HandleFederatedGuildPermissions)
+	srv.Router.Post("/federation/v1/guilds/{guildID}/replica", syncSvc.HandleGuildReplica)
 	srv.Router.Post("/federation/v1/guilds/{guildID}/channels/{channelID}/messages/{messageID}/reactions", syncSvc.HandleFederatedGuildReactionAdd)
 	srv.Router.Post("/federation/v1/guilds/{guildID}/channels/{channelID}/messages/{messageID}/reactions/remove", syncSvc.HandleFederatedGuildReactionRemove)
 	srv.Router.Post("/federation/v1/guilds/{guildID}/channels/{channelID}/typing", syncSvc.HandleFederatedGuildTyping)
@@ -386,23 +551,29 @@ func runServe() error {
 	srv.Router.Post("/federation/v1/invites/{code}/accept", syncSvc.HandleInviteAccept)
 
 	// Federation guild proxy endpoints (authenticated, for local users accessing remote guilds).
+	// They have their own rate limit class rather than the global one, and
+	// each remote guild gets a cap on requests in flight.
 	srv.Router.Route("/api/v1/federation/guilds", func(r chi.Router) {
 		r.Use(auth.RequireAuth(authSvc))
-		r.Use(srv.RateLimitGlobal())
-		r.Post("/join", syncSvc.HandleProxyJoinFederatedGuild)
-		r.Post("/{guildID}/leave", syncSvc.HandleProxyLeaveFederatedGuild)
-		r.Get("/{guildID}/channels/{channelID}/messages", syncSvc.HandleProxyGetFederatedGuildMessages)
-		r.Post("/{guildID}/channels/{channelID}/messages", syncSvc.HandleProxyPostFederatedGuildMessage)
-		r.Get("/{guildID}/members", syncSvc.HandleProxyGetFederatedGuildMembers)
-		r.Put("/{guildID}/channels/{channelID}/messages/{messageID}/reactions/{emoji}", syncSvc.HandleProxyAddFederatedReaction)
-		r.Delete("/{guildID}/channels/{channelID}/messages/{messageID}/reactions/{emoji}", syncSvc.HandleProxyRemoveFederatedReaction)
-		r.Post("/{guildID}/channels/{channelID}/typing", syncSvc.HandleProxyFederatedTyping)
+		r.Use(srv.RateLimitFederationProxy)
+		r.With(srv.RateLimitFederationJoin).Post("/join", syncSvc.HandleProxyJoinFederatedGuild)
+		r.Route("/{guildID}", func(r chi.Router) {
+			r.Use(srv.LimitFederatedGuildConcurrency)
+			r.Post("/leave", syncSvc.HandleProxyLeaveFederatedGuild)
+			r.Get("/channels/{channelID}/messages", syncSvc.HandleProxyGetFederatedGuildMessages)
+			r.With(srv.RateLimitFederationMessages).Post("/channels/{channelID}/messages", syncSvc.HandleProxyPostFederatedGuildMessage)
+			r.Get("/members", syncSvc.HandleProxyGetFederatedGuildMembers)
+			r.Get("/permissions", syncSvc.HandleProxyGetFederatedGuildPermissions)
+			r.With(srv.RateLimitFederationMessages).Put("/channels/{channelID}/messages/{messageID}/reactions/{emoji}", syncSvc.HandleProxyAddFederatedReaction)
+			r.With(srv.RateLimitFederationMessages).Delete("/channels/{channelID}/messages/{messageID}/reactions/{emoji}", syncSvc.HandleProxyRemoveFederatedReaction)
+			r.With(srv.RateLimitFederationMessages).Post("/channels/{channelID}/typing", syncSvc.HandleProxyFederatedTyping)
+		})
 	})
 
 	// Federation user proxy endpoints (authenticated, for local users managing remote user stubs).
 	srv.Router.Route("/api/v1/federation/users", func(r chi.Router) {
 		r.Use(auth.RequireAuth(authSvc))
-		r.Use(srv.RateLimitGlobal())
+		r.Use(srv.RateLimitFederationProxy)
 		r.Post("/ensure", syncSvc.HandleProxyEnsureFederatedUser)
 		r.Get("/{instanceID}/{userID}/profile", syncSvc.HandleProxyUserProfile)
 	})
@@ -410,16 +581,23 @@ func runServe() error {
 	// Federation peers and discovery proxy endpoints (authenticated, for local users).
 	srv.Router.Route("/api/v1/federation/peers", func(r chi.Router) {
 		r.Use(auth.RequireAuth(authSvc))
-		r.Use(srv.RateLimitGlobal())
+		r.Use(srv.RateLimitFederationProxy)
 		r.Get("/public", syncSvc.HandleGetPublicFederationPeers)
-		r.Get("/{peerID}/guilds", syncSvc.HandleProxyDiscoverRemoteGuilds)
+		r.With(srv.RateLimitFederationDiscovery).Get("/{peerID}/guilds", syncSvc.HandleProxyDiscoverRemoteGuilds)
+		r.With(srv.RateLimitFederationDiscovery).Get("/{peerID}/emoji", syncSvc.HandleProxyRemoteEmoji)
 	})
 
 	// Aggregated federation guild discovery (authenticated, fans out to all peers).
-	srv.Router.With(auth.RequireAuth(authSvc), srv.RateLimitGlobal()).Get("/api/v1/federation/discover", syncSvc.HandleAggregatedDiscover)
+	srv.Router.With(auth.RequireAuth(authSvc), srv.RateLimitFederationProxy, srv.RateLimitFederationDiscovery).Get("/api/v1/federation/discover", syncSvc.HandleAggregatedDiscover)
+	srv.Router.With(auth.RequireAuth(authSvc), srv.RateLimitFederationProxy, srv.RateLimitFederationDiscovery).Get("/api/v1/federation/discover/recommended", syncSvc.HandleRecommendedGuilds)
+
+	// Pending DMs from users on restricted peers (authenticated, for local recipients).
+	srv.Router.With(auth.RequireAuth(authSvc), srv.RateLimitFederationProxy).Get("/api/v1/federation/dm-requests", syncSvc.HandleListDMRequests)
+	srv.Router.With(auth.RequireAuth(authSvc), srv.RateLimitFederationProxy).Post("/api/v1/federation/dm-requests/{requestID}/accept", syncSvc.HandleAcceptDMRequest)
+	srv.Router.With(auth.RequireAuth(authSvc), srv.RateLimitFederationProxy).Delete("/api/v1/federation/dm-requests/{requestID}", syncSvc.HandleDeclineDMRequest)
 
 	// Federation invite proxy (authenticated, rate limited — for local users resolving cross-instance invites).
-	srv.Router.With(auth.RequireAuth(authSvc), srv.RateLimitGlobal()).Post("/api/v1/federation/invites/resolve", syncSvc.HandleProxyResolveInvite)
+	srv.Router.With(auth.RequireAuth(authSvc), srv.RateLimitFederationProxy, srv.RateLimitFederationJoin).Post("/api/v1/federation/invites/resolve", syncSvc.HandleProxyResolveInvite)
 
 	// Federation guild discovery (signed, no rate limit).
 	srv.Router.Post("/federation/v1/guilds/discover", syncSvc.HandleFederatedGuildDiscover)
@@ -439,18 +617,86 @@ func runServe() error {
 	// Federation voice proxy endpoints (authenticated, for local users joining remote voice channels).
 	srv.Router.Route("/api/v1/federation/voice", func(r chi.Router) {
 		r.Use(auth.RequireAuth(authSvc))
-		r.Use(srv.RateLimitGlobal())
+		r.Use(srv.RateLimitFederationProxy)
 		r.Post("/join", syncSvc.HandleProxyFederatedVoiceJoin)
 		r.Post("/guild-join", syncSvc.HandleProxyFederatedVoiceJoinByGuild)
 	})
 
+	// Embedded Matrix appservice (optional; replaces the standalone bridge for
+	// deployments that enable it).
+	if mc := cfg.Federation.Matrix; mc.Enabled {
+		matrixSvc := matrix.New(matrix.Config{
+			HomeserverURL: mc.HomeserverURL,
+			ServerName:    mc.ServerName,
+			ASToken:       mc.ASToken,
+			HSToken:       mc.HSToken,
+			UserPrefix:    mc.UserPrefix,
+			AliasPrefix:   mc.AliasPrefix,
+			InstanceID:    instanceID,
+		}, db.Pool, bus, logger)
+		srv.Router.Mount("/_matrix/app/v1", matrixSvc.Routes())
+		if err := matrixSvc.Start(ctx); err != nil {
+			return fmt.Errorf("starting matrix appservice: %w", err)
+		}
+		importSvc.SetPuppetProvider(importer.SourceMatrix, matrixSvc)
+	}
+
+	// Channel email gateway (optional).
+	if ec := cfg.Email; ec.Enabled {
+		pollInterval, _ := ec.PollIntervalParsed() // validated at load
+		emailSvc := emailgateway.New(emailgateway.Config{
+			Domain:         ec.Domain,
+			JMAPSessionURL: ec.JMAPSessionURL,
+			JMAPToken:      ec.JMAPToken,
+			PollInterval:   pollInterval,
+			MaxAttachment:  int64(ec.MaxAttachmentMB) << 20,
+			SMTPHost:       ec.SMTPHost,
+			SMTPPort:       ec.SMTPPort,
+			SMTPUsername:   ec.SMTPUsername,
+			SMTPPassword:   ec.SMTPPassword,
+			InstanceID:     instanceID,
+		}, db.Pool, bus, mediaSvc, logger)
+		if err := emailSvc.Start(ctx); err != nil {
+			return fmt.Errorf("starting email gateway: %w", err)
+		}
+	}
+
+	// Payment webhooks and supporter reconciliation. The reconciliation job
+	// also expires manual grants, so it runs even with no provider configured.
+	gracePeriod, _ := cfg.Payments.GracePeriodParsed() // validated at load
+	paymentsSvc := payments.New(payments.Config{
+		StripeWebhookSecret:   cfg.Payments.StripeWebhookSecret,
+		StripeAPIKey:          cfg.Payments.StripeAPIKey,
+		KofiVerificationToken: cfg.Payments.KofiVerificationToken,
+		SlotsPerUnit:          cfg.Payments.SlotsPerUnit,
+		GracePeriod:           gracePeriod,
+	}, db.Pool, bus, logger)
+	srv.Router.Mount("/api/v1/payments", paymentsSvc.Routes())
+	paymentsSvc.Start(ctx)
+
 	if cfg.Instance.FederationMode != "closed" {
 		syncSvc.StartRouter(ctx)
 		fedSvc.StartCounterFlusher(ctx)
+		fedSvc.StartDirectoryRefresher(ctx)
 		syncSvc.StartTimestampFlusher(ctx)
 		logger.Info("federation sync router started", slog.String("mode", cfg.Instance.FederationMode))
 	}
 
+	// Trace a sample of message sends for the delivery SLO metrics.
+	sloRate := cfg.Metrics.SLOSampleRate
+	if !cfg.Metrics.Enabled {
+		sloRate = 0
+	}
+	gatewaySLO, _ := cfg.Metrics.SLOGatewayThresholdParsed()       // validated at load
+	federationSLO, _ := cfg.Metrics.SLOFederationThresholdParsed() // validated at load
+	slo.Configure(slo.Config{
+		SampleRate: sloRate,
+		Objectives: []slo.Objective{
+			{SLI: slo.GatewayDelivery, Threshold: gatewaySLO, Target: cfg.Metrics.SLOGatewayTarget},
+			{SLI: slo.FederationDelivery, Threshold: federationSLO, Target: cfg.Metrics.SLOFederationTarget},
+		},
+	})
+
 	// Parse WebSocket settings.
 	heartbeatInterval, err := cfg.WebSocket.HeartbeatIntervalParsed()
 	if err != nil {
@@ -473,9 +719,18 @@ func runServe() error {
 		ListenAddr:        cfg.WebSocket.Listen,
 		BuildVersion:      version + "-" + commit + "-" + buildDate,
 		LocalInstanceID:   instanceID,
+		Clients:           cfg.Clients,
 		Logger:            logger,
 		CORSOrigins:       cfg.HTTP.CORSOrigins,
+		DispatchShards:    cfg.WebSocket.DispatchShards,
+		MaxDispatchShards: cfg.WebSocket.MaxDispatchShards,
+		Region:            cfg.WebSocket.Region,
+		FeatureFlags:      flagStore,
 	})
+	srv.GatewayShards = gw.ShardStats
+	srv.GatewayConnections = gw.ConnectionStats
+	srv.GatewayTraffic = gw.GuildTraffic
+	srv.GatewayLatency = gw.LatencyStats
 
 	// Graceful shutdown handler.
 	shutdownCh := make(chan os.Signal, 1)
@@ -526,6 +781,68 @@ func runServe() error {
 	return nil
 }
 
+// mediaConfig builds the media service configuration from the instance
+// config.
+func mediaConfig(cfg *config.Config, db *database.DB, logger *slog.Logger) media.Config {
+	storageRegions := make([]media.RegionConfig, 0, len(cfg.Storage.Regions))
+	for _, sr := range cfg.Storage.Regions {
+		storageRegions = append(storageRegions, media.RegionConfig{
+			Name:      sr.Name,
+			Endpoint:  sr.Endpoint,
+			Bucket:    sr.Bucket,
+			AccessKey: sr.AccessKey,
+			SecretKey: sr.SecretKey,
+			Region:    sr.Region,
+			UseSSL:    sr.UseSSL,
+		})
+	}
+	maxBytes, _ := cfg.Media.MaxUploadSizeBytes()
+	if maxBytes <= 0 {
+		maxBytes = 100 * 1024 * 1024
+	}
+	embedImageMax, _ := cfg.Media.EmbedImageMaxSizeBytes()
+	embedImageTTL, _ := cfg.Media.EmbedImageTTLParsed()
+	return media.Config{
+		Endpoint:           cfg.Storage.Endpoint,
+		Bucket:             cfg.Storage.Bucket,
+		AccessKey:          cfg.Storage.AccessKey,
+		SecretKey:          cfg.Storage.SecretKey,
+		Region:             cfg.Storage.Region,
+		UseSSL:             cfg.Storage.UseSSL,
+		MaxUploadMB:        maxBytes / (1024 * 1024),
+		ThumbnailSizes:     cfg.Media.ImageThumbnailSizes,
+		StripExif:          cfg.Media.StripExif,
+		Pool:               db.Pool,
+		Logger:             logger,
+		Regions:            storageRegions,
+		PrimaryRegion:      cfg.Regions.Primary,
+		LocalRegion:        cfg.Regions.LocalRegion(),
+		Strategy:           cfg.Regions.Strategy,
+		RegionHeader:       cfg.Regions.ClientRegionHeader,
+		EmbedImageCache:    cfg.Media.EmbedImageCache,
+		EmbedImageMaxBytes: embedImageMax,
+		EmbedImageTTL:      embedImageTTL,
+	}
+}
+
+// signalKey returns the HMAC key for stored account signals: the configured
+// secret, or one derived from the federation key so that it survives
+// restarts without extra setup.
+func signalKey(secret string, federationKey ed25519.PrivateKey) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	return deriveKey(federationKey, "amityvox account signals v1")
+}
+
+// deriveKey derives an independent 32-byte key for one purpose from the
+// federation private key. Different labels give unrelated keys.
+func deriveKey(federationKey ed25519.PrivateKey, label string) []byte {
+	mac := hmac.New(sha256.New, federationKey.Seed())
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
 // ensureLocalInstance checks if the local instance record exists in the database
 // (matched by domain). If not, it creates one with a generated Ed25519 key pair
 // for federation signing. Returns the instance ID and the Ed25519 private key.
@@ -686,20 +1003,33 @@ func runMigrate() error {
 	}
 }
 
-// runAdmin handles admin subcommands for user and instance management.
+// runAdmin handles admin subcommands for user and instance management. With
+// AMITYVOX_ADMIN_URL set it runs the action on that instance over the API,
+// using the admin action token in AMITYVOX_ADMIN_TOKEN, instead of connecting
+// to the database.
 func runAdmin() error {
 	if len(os.Args) < 3 {
 		fmt.Println("Usage: amityvox admin <action>")
 		fmt.Println()
 		fmt.Println("Actions:")
-		fmt.Println("  create-user  Create a new user account")
-		fmt.Println("  suspend      Suspend a user account")
-		fmt.Println("  unsuspend    Unsuspend a user account")
-		fmt.Println("  set-admin    Grant admin flag to a user")
-		fmt.Println("  unset-admin  Remove admin flag from a user")
-		fmt.Println("  list-users   List all user accounts")
+		for _, a := range adminops.Actions {
+			fmt.Printf("  %-12s %s\n", a.Name, a.Description)
+		}
+		fmt.Printf("  %-12s %s\n", "mint-token", "Mint a token for running actions remotely (mint-token [-uses n] <ttl> <action>...)")
+		fmt.Println()
+		fmt.Println("Remote use, when the database is not reachable from this host:")
+		fmt.Println("  AMITYVOX_ADMIN_URL    Base URL of the instance, e.g. https://chat.example.com")
+		fmt.Println("  AMITYVOX_ADMIN_TOKEN  Admin action token from 'mint-token' or the admin API")
 		return nil
 	}
+	action, args := os.Args[2], os.Args[3:]
+
+	if baseURL := os.Getenv("AMITYVOX_ADMIN_URL"); baseURL != "" {
+		if action == "mint-token" {
+			return fmt.Errorf("mint-token needs direct database access; unset AMITYVOX_ADMIN_URL")
+		}
+		return runRemoteAdmin(baseURL, os.Getenv("AMITYVOX_ADMIN_TOKEN"), action, args)
+	}
 
 	logger := setupLogger("info", "text")
 
@@ -716,130 +1046,271 @@ func runAdmin() error {
 	}
 	defer db.Close()
 
-	switch os.Args[2] {
-	case "create-user":
-		if len(os.Args) < 5 {
-			return fmt.Errorf("usage: amityvox admin create-user <username> <password>")
+	if action == "mint-token" {
+		fs := flag.NewFlagSet("mint-token", flag.ExitOnError)
+		uses := fs.Int("uses", adminops.DefaultTokenUses, "number of requests the token is good for")
+		fs.Usage = func() {
+			fmt.Fprintln(os.Stderr, "Usage: amityvox admin mint-token [-uses n] <ttl> <action>...")
+			fmt.Fprintln(os.Stderr)
+			fs.PrintDefaults()
 		}
-		username, password := os.Args[3], os.Args[4]
-
-		// Get local instance ID.
-		var instanceID string
-		if err := db.Pool.QueryRow(ctx, `SELECT id FROM instances WHERE domain = $1`, cfg.Instance.Domain).Scan(&instanceID); err != nil {
-			return fmt.Errorf("instance not found — run 'amityvox serve' first to bootstrap")
+		fs.Parse(args)
+		if fs.NArg() < 2 {
+			fs.Usage()
+			return fmt.Errorf("a ttl and at least one action are required")
 		}
-
-		// Hash password.
-		hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+		ttl, err := time.ParseDuration(fs.Arg(0))
 		if err != nil {
-			return fmt.Errorf("hashing password: %w", err)
+			return fmt.Errorf("parsing ttl %q: %w", fs.Arg(0), err)
 		}
-
-		userID := models.NewULID().String()
-		_, err = db.Pool.Exec(ctx,
-			`INSERT INTO users (id, instance_id, username, password_hash, created_at) VALUES ($1, $2, $3, $4, now())`,
-			userID, instanceID, username, hash)
+		tok, err := adminops.MintToken(ctx, db.Pool, nil, fs.Args()[1:], ttl, *uses)
 		if err != nil {
-			return fmt.Errorf("creating user: %w", err)
+			return err
 		}
-		fmt.Printf("Created user %s (ID: %s)\n", username, userID)
+		fmt.Printf("Token for %s, valid for %d use(s) until %s:\n%s\n",
+			strings.Join(tok.Actions, ", "), tok.MaxUses, tok.ExpiresAt.Format(time.RFC3339), tok.Token)
+		return nil
+	}
 
-	case "suspend":
-		if len(os.Args) < 4 {
-			return fmt.Errorf("usage: amityvox admin suspend <username>")
-		}
-		tag, err := db.Pool.Exec(ctx,
-			`UPDATE users SET flags = flags | $1 WHERE username = $2`,
-			models.UserFlagSuspended, os.Args[3])
+	env := adminops.Env{Pool: db.Pool, Domain: cfg.Instance.Domain}
+	if action == "run-job" {
+		bus, err := events.New(cfg.NATS.URL, logger)
 		if err != nil {
-			return fmt.Errorf("suspending user: %w", err)
+			return fmt.Errorf("connecting to NATS: %w", err)
 		}
-		if tag.RowsAffected() == 0 {
-			return fmt.Errorf("user %q not found", os.Args[3])
-		}
-		fmt.Printf("Suspended user %s\n", os.Args[3])
-
-	case "unsuspend":
-		if len(os.Args) < 4 {
-			return fmt.Errorf("usage: amityvox admin unsuspend <username>")
-		}
-		tag, err := db.Pool.Exec(ctx,
-			`UPDATE users SET flags = flags & ~$1 WHERE username = $2`,
-			models.UserFlagSuspended, os.Args[3])
+		defer bus.Close()
+		env.Bus = bus
+	}
+	if action == "fsck" && cfg.Storage.Endpoint != "" {
+		svc, err := media.New(mediaConfig(cfg, db, logger))
 		if err != nil {
-			return fmt.Errorf("unsuspending user: %w", err)
-		}
-		if tag.RowsAffected() == 0 {
-			return fmt.Errorf("user %q not found", os.Args[3])
+			return fmt.Errorf("connecting to storage: %w", err)
 		}
-		fmt.Printf("Unsuspended user %s\n", os.Args[3])
+		env.Media = svc
+	}
+	return adminops.Run(ctx, env, action, args, os.Stdout)
+}
 
-	case "set-admin":
-		if len(os.Args) < 4 {
-			return fmt.Errorf("usage: amityvox admin set-admin <username>")
-		}
-		tag, err := db.Pool.Exec(ctx,
-			`UPDATE users SET flags = flags | $1 WHERE username = $2`,
-			models.UserFlagAdmin, os.Args[3])
-		if err != nil {
-			return fmt.Errorf("setting admin: %w", err)
-		}
-		if tag.RowsAffected() == 0 {
-			return fmt.Errorf("user %q not found", os.Args[3])
-		}
-		fmt.Printf("Granted admin to %s\n", os.Args[3])
+// runRemoteAdmin runs an admin action on a remote instance and prints its
+// output.
+func runRemoteAdmin(baseURL, token, action string, args []string) error {
+	if token == "" {
+		return fmt.Errorf("AMITYVOX_ADMIN_TOKEN must be set when AMITYVOX_ADMIN_URL is")
+	}
+	if args == nil {
+		args = []string{}
+	}
+	body, _ := json.Marshal(map[string]interface{}{"action": action, "args": args})
+	req, err := http.NewRequest(http.MethodPost,
+		strings.TrimRight(baseURL, "/")+"/api/v1/admin-cli/run", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
 
-	case "unset-admin":
-		if len(os.Args) < 4 {
-			return fmt.Errorf("usage: amityvox admin unset-admin <username>")
-		}
-		tag, err := db.Pool.Exec(ctx,
-			`UPDATE users SET flags = flags & ~$1 WHERE username = $2`,
-			models.UserFlagAdmin, os.Args[3])
-		if err != nil {
-			return fmt.Errorf("unsetting admin: %w", err)
-		}
-		if tag.RowsAffected() == 0 {
-			return fmt.Errorf("user %q not found", os.Args[3])
-		}
-		fmt.Printf("Removed admin from %s\n", os.Args[3])
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("contacting %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			Output string `json:"output"`
+		} `json:"data"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("reading response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", result.Error.Message)
+	}
+	fmt.Print(result.Data.Output)
+	return nil
+}
+
+// runLoadtest runs synthetic gateway clients and REST senders against a
+// target instance and prints delivery latency percentiles. It needs no
+// config file since the target is usually another instance.
+func runLoadtest() error {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	target := fs.String("target", "", "instance root URL, e.g. https://staging.example.com (required)")
+	gatewayURL := fs.String("gateway", "", "gateway WebSocket URL (default: target with /ws)")
+	clients := fs.Int("clients", 100, "synthetic gateway clients listening in the channel")
+	senders := fs.Int("senders", 5, "REST clients posting messages")
+	rate := fs.Float64("rate", 1, "messages per second per sender")
+	duration := fs.Duration("duration", time.Minute, "how long to send messages for")
+	rampUp := fs.Duration("ramp-up", 10*time.Second, "spread gateway connections over this long")
+	drain := fs.Duration("drain", 5*time.Second, "wait this long for late deliveries after sending stops")
+	channelID := fs.String("channel", "", "existing channel to use (requires -tokens)")
+	tokensFile := fs.String("tokens", "", "file of session tokens, one per line (default: register throwaway users)")
+	verbose := fs.Bool("v", false, "log connection and send failures")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: amityvox loadtest -target <url> [options]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Run against a staging instance with rate limits relaxed; by default it")
+		fmt.Fprintln(os.Stderr, "registers throwaway users and creates a guild for the run.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[2:])
+	if *target == "" {
+		fs.Usage()
+		return fmt.Errorf("-target is required")
+	}
 
-	case "list-users":
-		rows, err := db.Pool.Query(ctx,
-			`SELECT id, username, display_name, email, flags, created_at FROM users ORDER BY created_at`)
+	var tokens []string
+	if *tokensFile != "" {
+		data, err := os.ReadFile(*tokensFile)
 		if err != nil {
-			return fmt.Errorf("listing users: %w", err)
-		}
-		defer rows.Close()
-
-		fmt.Printf("%-28s %-20s %-20s %-30s %6s %s\n", "ID", "Username", "DisplayName", "Email", "Flags", "Created")
-		fmt.Println(strings.Repeat("-", 130))
-		for rows.Next() {
-			var id, username string
-			var displayName, email *string
-			var flags int
-			var createdAt time.Time
-			if err := rows.Scan(&id, &username, &displayName, &email, &flags, &createdAt); err != nil {
-				return fmt.Errorf("scanning user: %w", err)
-			}
-			dn := ""
-			if displayName != nil {
-				dn = *displayName
-			}
-			em := ""
-			if email != nil {
-				em = *email
+			return fmt.Errorf("reading tokens: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				tokens = append(tokens, line)
 			}
-			fmt.Printf("%-28s %-20s %-20s %-30s %6d %s\n", id, username, dn, em, flags, createdAt.Format(time.RFC3339))
 		}
+		if len(tokens) == 0 {
+			return fmt.Errorf("no tokens in %s", *tokensFile)
+		}
+	}
 
-	default:
-		return fmt.Errorf("unknown admin action: %s", os.Args[2])
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := loadtest.Run(ctx, loadtest.Config{
+		BaseURL:    *target,
+		GatewayURL: *gatewayURL,
+		Clients:    *clients,
+		Senders:    *senders,
+		Rate:       *rate,
+		Duration:   *duration,
+		RampUp:     *rampUp,
+		Drain:      *drain,
+		ChannelID:  *channelID,
+		Tokens:     tokens,
+		Progress:   os.Stdout,
+		Logger:     logger,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	report.Print(os.Stdout)
+	return nil
+}
+
+// runMigrateInstance moves the instance to a new domain: it updates the local
+// instance record, optionally replaces the federation key, sends a signed move
+// notice to peers and prints the steps left to the operator.
+func runMigrateInstance() error {
+	fs := flag.NewFlagSet("migrate-instance", flag.ExitOnError)
+	newDomain := fs.String("new-domain", "", "domain to move the instance to (required unless -resend)")
+	newKey := fs.Bool("new-key", false, "also replace the federation key")
+	dryRun := fs.Bool("dry-run", false, "show what would happen without changing anything")
+	skipNotify := fs.Bool("skip-notify", false, "update local records without notifying peers")
+	resend := fs.Bool("resend", false, "send the last recorded move to peers again")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: amityvox migrate-instance -new-domain <domain> [options]")
+		fmt.Fprintln(os.Stderr, "       amityvox migrate-instance -resend")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Stop the server first and point the new domain's DNS at this host. The")
+		fmt.Fprintln(os.Stderr, "instance keeps its ID, users and guilds; peers are told of the move with")
+		fmt.Fprintln(os.Stderr, "a notice signed by the current federation key.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[2:])
+	if *newDomain == "" && !*resend {
+		fs.Usage()
+		return fmt.Errorf("-new-domain is required")
+	}
+
+	logger := setupLogger("warn", "text")
+	cfg, err := config.Load(configPath())
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	ctx := context.Background()
+	db, err := database.New(ctx, cfg.Database.URL, cfg.Database.MaxConnections, logger)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
 	}
+	defer db.Close()
 
+	report, err := instancemove.Run(ctx, instancemove.Config{
+		Pool:          db.Pool,
+		OldDomain:     cfg.Instance.Domain,
+		NewDomain:     *newDomain,
+		NewKey:        *newKey,
+		DryRun:        *dryRun,
+		SkipNotify:    *skipNotify,
+		Resend:        *resend,
+		StaleSettings: staleDomainSettings(cfg),
+	})
+	if err != nil {
+		return err
+	}
+	report.Print(os.Stdout)
 	return nil
 }
 
+// staleDomainSettings lists the config settings besides instance.domain that
+// name the instance's domain and need changing when it moves.
+func staleDomainSettings(cfg *config.Config) []string {
+	settings := []struct {
+		name   string
+		values []string
+	}{
+		{"auth.webauthn.rp_id", []string{cfg.Auth.WebAuthn.RPID}},
+		{"auth.webauthn.rp_origins", cfg.Auth.WebAuthn.RPOrigins},
+		{"http.cors_origins", cfg.HTTP.CORSOrigins},
+		{"livekit.public_url", []string{cfg.LiveKit.PublicURL}},
+		{"media.cdn_url", []string{cfg.Media.CDNURL}},
+		{"link_safety.interstitial_url", []string{cfg.LinkSafety.InterstitialURL}},
+		{"clients.upgrade_url", []string{cfg.Clients.UpgradeURL}},
+	}
+	if cfg.Instance.Domain == "" {
+		return nil
+	}
+	var stale []string
+	for _, s := range settings {
+		for _, v := range s.values {
+			if strings.Contains(strings.ToLower(v), strings.ToLower(cfg.Instance.Domain)) {
+				stale = append(stale, fmt.Sprintf("%s = %q", s.name, v))
+			}
+		}
+	}
+	return stale
+}
+
+// runErrors prints the API error catalog, as Markdown for the API
+// documentation or, with --json, as JSON.
+func runErrors() error {
+	fs := flag.NewFlagSet("errors", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print JSON instead of Markdown")
+	fs.Parse(os.Args[2:])
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(apierrors.All())
+	}
+	return apierrors.WriteMarkdown(os.Stdout)
+}
+
 // runVersion prints version information and exits.
 func runVersion() {
 	fmt.Printf("AmityVox %s\n", version)
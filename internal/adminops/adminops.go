@@ -0,0 +1,303 @@
+// Package adminops implements the actions behind `amityvox admin`. The CLI
+// runs them against the database when it can reach it; when it cannot, it
+// sends them to a running instance with a short-lived admin action token and
+// the server runs the same code. Actions write plain text meant for a
+// terminal.
+package adminops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/media"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+	"github.com/amityvox/amityvox/internal/workers"
+)
+
+// Env is what an action runs against.
+type Env struct {
+	Pool   *pgxpool.Pool
+	Domain string         // local instance domain
+	Bus    *events.Bus    // needed only by run-job
+	Media  *media.Service // optional; fsck checks attachment objects with it
+}
+
+// Action is one `amityvox admin` subcommand.
+type Action struct {
+	Name        string
+	Args        string // argument synopsis shown in usage errors
+	Description string
+	MinArgs     int
+	run         func(ctx context.Context, env Env, args []string, out io.Writer) error
+}
+
+// Usage returns the action's usage line.
+func (a Action) Usage() string {
+	if a.Args == "" {
+		return "usage: amityvox admin " + a.Name
+	}
+	return "usage: amityvox admin " + a.Name + " " + a.Args
+}
+
+// Actions lists every admin action in the order they are shown in help.
+var Actions = []Action{
+	{Name: "create-user", Args: "<username> <password>", Description: "Create a new user account", MinArgs: 2, run: createUser},
+	{Name: "suspend", Args: "<username>", Description: "Suspend a user account", MinArgs: 1, run: setUserFlag(models.UserFlagSuspended, true, "suspending user", "Suspended user %s\n")},
+	{Name: "unsuspend", Args: "<username>", Description: "Unsuspend a user account", MinArgs: 1, run: setUserFlag(models.UserFlagSuspended, false, "unsuspending user", "Unsuspended user %s\n")},
+	{Name: "set-admin", Args: "<username>", Description: "Grant admin flag to a user", MinArgs: 1, run: setUserFlag(models.UserFlagAdmin, true, "setting admin", "Granted admin to %s\n")},
+	{Name: "unset-admin", Args: "<username>", Description: "Remove admin flag from a user", MinArgs: 1, run: setUserFlag(models.UserFlagAdmin, false, "unsetting admin", "Removed admin from %s\n")},
+	{Name: "list-users", Description: "List all user accounts", run: listUsers},
+	{Name: "list-roles", Description: "List instance staff roles and their permissions", run: listRoles},
+	{Name: "grant-role", Args: "<username> <role name>", Description: "Grant an instance staff role to a user", MinArgs: 2, run: changeRole(true)},
+	{Name: "revoke-role", Args: "<username> <role name>", Description: "Remove an instance staff role from a user", MinArgs: 2, run: changeRole(false)},
+	{Name: "jobs", Description: "Show the latest run of each background job", run: listJobs},
+	{Name: "job-runs", Args: "[job] [--failed]", Description: "List recent job runs (job-runs [job] [--failed])", run: listJobRuns},
+	{Name: "run-job", Args: "<job>", Description: "Ask a running server to run a job now", MinArgs: 1, run: runJob},
+	{Name: "fsck", Args: "[--fix] [--skip-objects]", Description: "Find (and with --fix repair) orphaned data and missing attachment files", run: fsck},
+}
+
+// Lookup returns the action with the given name.
+func Lookup(name string) (Action, bool) {
+	for _, a := range Actions {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Action{}, false
+}
+
+// Run runs the named action, writing its output to out.
+func Run(ctx context.Context, env Env, name string, args []string, out io.Writer) error {
+	a, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown admin action: %s", name)
+	}
+	if len(args) < a.MinArgs {
+		return fmt.Errorf("%s", a.Usage())
+	}
+	return a.run(ctx, env, args, out)
+}
+
+func createUser(ctx context.Context, env Env, args []string, out io.Writer) error {
+	username, password := args[0], args[1]
+
+	// Get local instance ID.
+	var instanceID string
+	if err := env.Pool.QueryRow(ctx, `SELECT id FROM instances WHERE domain = $1`, env.Domain).Scan(&instanceID); err != nil {
+		return fmt.Errorf("instance not found — run 'amityvox serve' first to bootstrap")
+	}
+
+	// Hash password.
+	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	if err != nil {
+		return fmt.Errorf("hashing password: %w", err)
+	}
+
+	userID := models.NewULID().String()
+	_, err = env.Pool.Exec(ctx,
+		`INSERT INTO users (id, instance_id, username, password_hash, created_at) VALUES ($1, $2, $3, $4, now())`,
+		userID, instanceID, username, hash)
+	if err != nil {
+		return fmt.Errorf("creating user: %w", err)
+	}
+	fmt.Fprintf(out, "Created user %s (ID: %s)\n", username, userID)
+	return nil
+}
+
+// setUserFlag returns an action that sets or clears a user flag by username.
+func setUserFlag(flag int, set bool, verb, done string) func(context.Context, Env, []string, io.Writer) error {
+	query := `UPDATE users SET flags = flags | $1 WHERE username = $2`
+	if !set {
+		query = `UPDATE users SET flags = flags & ~$1 WHERE username = $2`
+	}
+	return func(ctx context.Context, env Env, args []string, out io.Writer) error {
+		tag, err := env.Pool.Exec(ctx, query, flag, args[0])
+		if err != nil {
+			return fmt.Errorf("%s: %w", verb, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("user %q not found", args[0])
+		}
+		fmt.Fprintf(out, done, args[0])
+		return nil
+	}
+}
+
+func listRoles(ctx context.Context, env Env, args []string, out io.Writer) error {
+	rows, err := env.Pool.Query(ctx,
+		`SELECT ir.name, ir.permissions,
+		        COALESCE(string_agg(u.username, ', ' ORDER BY u.username), '')
+		 FROM instance_roles ir
+		 LEFT JOIN user_instance_roles uir ON uir.role_id = ir.id
+		 LEFT JOIN users u ON u.id = uir.user_id
+		 GROUP BY ir.id, ir.name, ir.permissions
+		 ORDER BY ir.name`)
+	if err != nil {
+		return fmt.Errorf("listing instance roles: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Fprintf(out, "%-24s %-40s %s\n", "Role", "Permissions", "Members")
+	fmt.Fprintln(out, strings.Repeat("-", 100))
+	for rows.Next() {
+		var name, members string
+		var perms int64
+		if err := rows.Scan(&name, &perms, &members); err != nil {
+			return fmt.Errorf("scanning instance role: %w", err)
+		}
+		fmt.Fprintf(out, "%-24s %-40s %s\n", name,
+			strings.Join(permissions.InstanceNames(uint64(perms)), ","), members)
+	}
+	return rows.Err()
+}
+
+// changeRole returns the grant-role or revoke-role action.
+func changeRole(grant bool) func(context.Context, Env, []string, io.Writer) error {
+	return func(ctx context.Context, env Env, args []string, out io.Writer) error {
+		username, roleName := args[0], strings.Join(args[1:], " ")
+
+		var userID, roleID string
+		if err := env.Pool.QueryRow(ctx, `SELECT id FROM users WHERE username = $1`, username).Scan(&userID); err != nil {
+			return fmt.Errorf("user %q not found", username)
+		}
+		if err := env.Pool.QueryRow(ctx,
+			`SELECT id FROM instance_roles WHERE LOWER(name) = LOWER($1)`, roleName).Scan(&roleID); err != nil {
+			return fmt.Errorf("instance role %q not found (see 'amityvox admin list-roles')", roleName)
+		}
+
+		if grant {
+			if _, err := env.Pool.Exec(ctx,
+				`INSERT INTO user_instance_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+				userID, roleID); err != nil {
+				return fmt.Errorf("granting instance role: %w", err)
+			}
+			fmt.Fprintf(out, "Granted %s to %s\n", roleName, username)
+		} else {
+			if _, err := env.Pool.Exec(ctx,
+				`DELETE FROM user_instance_roles WHERE user_id = $1 AND role_id = $2`,
+				userID, roleID); err != nil {
+				return fmt.Errorf("revoking instance role: %w", err)
+			}
+			fmt.Fprintf(out, "Removed %s from %s\n", roleName, username)
+		}
+		return nil
+	}
+}
+
+func listUsers(ctx context.Context, env Env, args []string, out io.Writer) error {
+	rows, err := env.Pool.Query(ctx,
+		`SELECT id, username, display_name, email, flags, created_at FROM users ORDER BY created_at`)
+	if err != nil {
+		return fmt.Errorf("listing users: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Fprintf(out, "%-28s %-20s %-20s %-30s %6s %s\n", "ID", "Username", "DisplayName", "Email", "Flags", "Created")
+	fmt.Fprintln(out, strings.Repeat("-", 130))
+	for rows.Next() {
+		var id, username string
+		var displayName, email *string
+		var flags int
+		var createdAt time.Time
+		if err := rows.Scan(&id, &username, &displayName, &email, &flags, &createdAt); err != nil {
+			return fmt.Errorf("scanning user: %w", err)
+		}
+		fmt.Fprintf(out, "%-28s %-20s %-20s %-30s %6d %s\n", id, username, derefOr(displayName, ""),
+			derefOr(email, ""), flags, createdAt.Format(time.RFC3339))
+	}
+	return rows.Err()
+}
+
+func listJobs(ctx context.Context, env Env, args []string, out io.Writer) error {
+	rows, err := env.Pool.Query(ctx,
+		`SELECT DISTINCT ON (job) job, status, started_at, finished_at, error,
+		        (SELECT count(*) FROM job_runs f WHERE f.job = r.job AND f.status = 'failed')
+		 FROM job_runs r ORDER BY job, started_at DESC, id DESC`)
+	if err != nil {
+		return fmt.Errorf("listing jobs: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Fprintf(out, "%-28s %-10s %-26s %10s %8s %s\n", "Job", "Status", "Last Run", "Duration", "Failures", "Last Error")
+	fmt.Fprintln(out, strings.Repeat("-", 120))
+	for rows.Next() {
+		var job, status string
+		var startedAt time.Time
+		var finishedAt *time.Time
+		var lastErr *string
+		var failures int
+		if err := rows.Scan(&job, &status, &startedAt, &finishedAt, &lastErr, &failures); err != nil {
+			return fmt.Errorf("scanning job: %w", err)
+		}
+		fmt.Fprintf(out, "%-28s %-10s %-26s %10s %8d %s\n", job, status, startedAt.Format(time.RFC3339),
+			runDuration(startedAt, finishedAt), failures, derefOr(lastErr, ""))
+	}
+	return rows.Err()
+}
+
+func listJobRuns(ctx context.Context, env Env, args []string, out io.Writer) error {
+	query := `SELECT ` + workers.JobRunColumns + ` FROM job_runs WHERE true`
+	var qargs []interface{}
+	for _, arg := range args {
+		if arg == "--failed" {
+			query += ` AND status = 'failed'`
+			continue
+		}
+		qargs = append(qargs, arg)
+		query += fmt.Sprintf(" AND job = $%d", len(qargs))
+	}
+	rows, err := env.Pool.Query(ctx, query+` ORDER BY started_at DESC, id DESC LIMIT 50`, qargs...)
+	if err != nil {
+		return fmt.Errorf("listing job runs: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Fprintf(out, "%-26s %-28s %-8s %-10s %8s %-26s %10s %s\n", "ID", "Job", "Trigger", "Status", "Attempts", "Started", "Duration", "Error")
+	fmt.Fprintln(out, strings.Repeat("-", 150))
+	for rows.Next() {
+		run, err := workers.ScanJobRun(rows)
+		if err != nil {
+			return fmt.Errorf("scanning job run: %w", err)
+		}
+		fmt.Fprintf(out, "%-26s %-28s %-8s %-10s %8d %-26s %10s %s\n", run.ID, run.Job, run.Trigger, run.Status,
+			run.Attempts, run.StartedAt.Format(time.RFC3339), runDuration(run.StartedAt, run.FinishedAt), derefOr(run.Error, ""))
+	}
+	return rows.Err()
+}
+
+func runJob(ctx context.Context, env Env, args []string, out io.Writer) error {
+	if env.Bus == nil {
+		return fmt.Errorf("requesting job run: no connection to NATS")
+	}
+	data, _ := json.Marshal(map[string]string{"job": args[0]})
+	if err := env.Bus.Publish(ctx, workers.SubjectJobRun, events.Event{Type: "JOB_RUN", Data: data}); err != nil {
+		return fmt.Errorf("requesting job run: %w", err)
+	}
+	fmt.Fprintf(out, "Requested a run of %s; see 'amityvox admin job-runs %s' for the result\n", args[0], args[0])
+	return nil
+}
+
+// runDuration formats how long a job run took, or "-" while it is running.
+func runDuration(startedAt time.Time, finishedAt *time.Time) string {
+	if finishedAt == nil {
+		return "-"
+	}
+	return finishedAt.Sub(startedAt).Round(time.Millisecond).String()
+}
+
+// derefOr returns *s, or def when s is nil.
+func derefOr(s *string, def string) string {
+	if s == nil {
+		return def
+	}
+	return *s
+}
@@ -0,0 +1,53 @@
+package adminops
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRun_RejectsUnknownAndShortArgs(t *testing.T) {
+	if err := Run(context.Background(), Env{}, "drop-database", nil, nil); err == nil ||
+		!strings.Contains(err.Error(), "unknown admin action") {
+		t.Errorf("Run(unknown) error = %v, want unknown admin action", err)
+	}
+	err := Run(context.Background(), Env{}, "suspend", nil, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "usage: amityvox admin suspend") {
+		t.Errorf("Run(suspend) error = %v, want usage", err)
+	}
+}
+
+func TestValidateTokenActions(t *testing.T) {
+	if err := ValidateTokenActions(nil); err == nil {
+		t.Error("expected error for no actions")
+	}
+	if err := ValidateTokenActions([]string{"list-users", "mint-token"}); err == nil {
+		t.Error("expected error for mint-token, which only runs locally")
+	}
+	if err := ValidateTokenActions([]string{"list-users", "suspend"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	a, b := HashToken(TokenPrefix+"one"), HashToken(TokenPrefix+"two")
+	if a == b || len(a) != 64 {
+		t.Errorf("HashToken gave %q and %q", a, b)
+	}
+	if HashToken(TokenPrefix+"one") != a {
+		t.Error("HashToken is not deterministic")
+	}
+}
+
+func TestParseFsckArgs(t *testing.T) {
+	opts, err := parseFsckArgs([]string{"--fix", "--skip-objects"})
+	if err != nil || !opts.fix || !opts.skipObjects {
+		t.Errorf("parseFsckArgs = %+v, %v", opts, err)
+	}
+	if opts, err := parseFsckArgs(nil); err != nil || opts.fix || opts.skipObjects {
+		t.Errorf("parseFsckArgs(nil) = %+v, %v; want a dry run", opts, err)
+	}
+	if _, err := parseFsckArgs([]string{"--force"}); err == nil {
+		t.Error("expected error for unknown option")
+	}
+}
@@ -0,0 +1,196 @@
+package adminops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fsckCheck is one referential check run by fsck. Rows of table (aliased t)
+// matching where are anomalies; --fix deletes them, or applies set instead
+// when it is given.
+type fsckCheck struct {
+	name  string
+	table string
+	where string
+	set   string
+}
+
+// fsckChecks are the database checks, in the order they are reported.
+// Foreign keys prevent most of these, but rows restored from a backup,
+// imported with triggers disabled or left by old releases can still slip
+// through.
+var fsckChecks = []fsckCheck{
+	{
+		name:  "messages-missing-channel",
+		table: "messages",
+		where: `NOT EXISTS (SELECT 1 FROM channels c WHERE c.id = t.channel_id)`,
+	},
+	{
+		name:  "messages-missing-thread",
+		table: "messages",
+		where: `t.thread_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM channels c WHERE c.id = t.thread_id)`,
+		set:   `thread_id = NULL`,
+	},
+	{
+		name:  "member-roles-missing-role",
+		table: "member_roles",
+		where: `NOT EXISTS (SELECT 1 FROM roles r WHERE r.id = t.role_id AND r.guild_id = t.guild_id)`,
+	},
+	{
+		name:  "overrides-missing-role",
+		table: "channel_permission_overrides",
+		where: `t.target_type = 'role' AND NOT EXISTS (
+		            SELECT 1 FROM roles r JOIN channels c ON c.guild_id = r.guild_id
+		            WHERE r.id = t.target_id AND c.id = t.channel_id)`,
+	},
+	{
+		name:  "read-state-departed-member",
+		table: "read_state",
+		where: `EXISTS (
+		            SELECT 1 FROM channels c
+		            WHERE c.id = t.channel_id AND c.guild_id IS NOT NULL
+		              AND NOT EXISTS (SELECT 1 FROM guild_members gm
+		                              WHERE gm.guild_id = c.guild_id AND gm.user_id = t.user_id))`,
+	},
+}
+
+// fsckObjectCheck names the check for attachments whose file is gone from
+// storage. It needs env.Media.
+const fsckObjectCheck = "attachments-missing-object"
+
+// fsckObjectBatch is how many attachments are read from the database at a
+// time while their objects are checked.
+const fsckObjectBatch = 500
+
+type fsckOptions struct {
+	fix         bool
+	skipObjects bool
+}
+
+func parseFsckArgs(args []string) (fsckOptions, error) {
+	var opts fsckOptions
+	for _, arg := range args {
+		switch arg {
+		case "--fix":
+			opts.fix = true
+		case "--skip-objects":
+			opts.skipObjects = true
+		default:
+			return opts, fmt.Errorf("unknown fsck option %q", arg)
+		}
+	}
+	return opts, nil
+}
+
+// fsck reports data that refers to rows or files that no longer exist and,
+// with --fix, removes it. Each repair is a single statement, so an
+// interrupted run leaves every check either done or untouched.
+func fsck(ctx context.Context, env Env, args []string, out io.Writer) error {
+	opts, err := parseFsckArgs(args)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%-28s %10s %10s\n", "Check", "Found", "Repaired")
+	fmt.Fprintln(out, strings.Repeat("-", 50))
+	total := 0
+	for _, c := range fsckChecks {
+		var found int
+		if err := env.Pool.QueryRow(ctx,
+			`SELECT COUNT(*) FROM `+c.table+` t WHERE `+c.where).Scan(&found); err != nil {
+			return fmt.Errorf("checking %s: %w", c.name, err)
+		}
+		repaired := "-"
+		if opts.fix && found > 0 {
+			stmt := `DELETE FROM ` + c.table + ` t WHERE ` + c.where
+			if c.set != "" {
+				stmt = `UPDATE ` + c.table + ` t SET ` + c.set + ` WHERE ` + c.where
+			}
+			tag, err := env.Pool.Exec(ctx, stmt)
+			if err != nil {
+				return fmt.Errorf("repairing %s: %w", c.name, err)
+			}
+			repaired = fmt.Sprint(tag.RowsAffected())
+		}
+		fmt.Fprintf(out, "%-28s %10d %10s\n", c.name, found, repaired)
+		total += found
+	}
+
+	switch {
+	case opts.skipObjects:
+		fmt.Fprintf(out, "%-28s %10s %10s\n", fsckObjectCheck, "skipped", "-")
+	case env.Media == nil:
+		fmt.Fprintf(out, "%-28s %10s %10s  (storage is not configured)\n", fsckObjectCheck, "skipped", "-")
+	default:
+		found, err := fsckObjects(ctx, env, opts.fix, out)
+		if err != nil {
+			return err
+		}
+		total += found
+	}
+
+	switch {
+	case total == 0:
+		fmt.Fprintln(out, "\nNo anomalies found")
+	case !opts.fix:
+		fmt.Fprintf(out, "\n%d anomalies found; run 'amityvox admin fsck --fix' to repair them\n", total)
+	default:
+		fmt.Fprintf(out, "\n%d anomalies found and repaired\n", total)
+	}
+	return nil
+}
+
+// fsckObjects checks that every attachment on a message still has its file
+// in storage. With fix, attachments whose file is gone are deleted so
+// clients stop offering broken downloads. Uploads not yet on a message are
+// left to the unattached-file cleanup job.
+func fsckObjects(ctx context.Context, env Env, fix bool, out io.Writer) (int, error) {
+	var missing []string
+	after := ""
+	for {
+		rows, err := env.Pool.Query(ctx,
+			`SELECT id, s3_bucket, s3_key FROM attachments
+			 WHERE message_id IS NOT NULL AND id > $1
+			 ORDER BY id LIMIT $2`, after, fsckObjectBatch)
+		if err != nil {
+			return 0, fmt.Errorf("listing attachments: %w", err)
+		}
+		type object struct{ id, bucket, key string }
+		batch, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (object, error) {
+			var o object
+			err := row.Scan(&o.id, &o.bucket, &o.key)
+			return o, err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("listing attachments: %w", err)
+		}
+		for _, o := range batch {
+			ok, err := env.Media.ObjectExists(ctx, o.bucket, o.key)
+			if err != nil {
+				return 0, fmt.Errorf("checking object %s/%s: %w", o.bucket, o.key, err)
+			}
+			if !ok {
+				missing = append(missing, o.id)
+			}
+		}
+		if len(batch) < fsckObjectBatch {
+			break
+		}
+		after = batch[len(batch)-1].id
+	}
+
+	repaired := "-"
+	if fix && len(missing) > 0 {
+		tag, err := env.Pool.Exec(ctx, `DELETE FROM attachments WHERE id = ANY($1)`, missing)
+		if err != nil {
+			return 0, fmt.Errorf("repairing %s: %w", fsckObjectCheck, err)
+		}
+		repaired = fmt.Sprint(tag.RowsAffected())
+	}
+	fmt.Fprintf(out, "%-28s %10d %10s\n", fsckObjectCheck, len(missing), repaired)
+	return len(missing), nil
+}
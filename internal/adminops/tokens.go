@@ -0,0 +1,141 @@
+package adminops
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// TokenPrefix starts every admin action token so it is recognisable in logs
+// and secret scanners.
+const TokenPrefix = "avadm_"
+
+// Admin action tokens are short-lived: they are minted for a task, not kept.
+// Each one is also good for a fixed number of requests, one by default, so a
+// leaked token cannot be replayed.
+const (
+	DefaultTokenTTL  = 15 * time.Minute
+	MaxTokenTTL      = 24 * time.Hour
+	DefaultTokenUses = 1
+	MaxTokenUses     = 100
+)
+
+// Errors returned by Authorize.
+var (
+	ErrInvalidToken     = errors.New("invalid, expired or used up admin token")
+	ErrActionNotAllowed = errors.New("admin token is not valid for this action")
+)
+
+// Token is a newly minted admin action token. The raw Token is only
+// available at mint time; the database keeps its hash.
+type Token struct {
+	ID        string    `json:"id"`
+	Token     string    `json:"token"`
+	Actions   []string  `json:"actions"`
+	MaxUses   int       `json:"max_uses"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ValidateTokenActions checks that every action a token is to be bound to
+// exists.
+func ValidateTokenActions(actions []string) error {
+	if len(actions) == 0 {
+		return fmt.Errorf("at least one action is required")
+	}
+	for _, name := range actions {
+		if _, ok := Lookup(name); !ok {
+			return fmt.Errorf("unknown admin action: %s", name)
+		}
+	}
+	return nil
+}
+
+// MintToken creates an admin action token valid for ttl, for at most uses
+// requests and only for the given actions. createdBy is the admin who asked
+// for it, or nil when minted from the CLI.
+func MintToken(ctx context.Context, pool *pgxpool.Pool, createdBy *string, actions []string, ttl time.Duration, uses int) (*Token, error) {
+	if err := ValidateTokenActions(actions); err != nil {
+		return nil, err
+	}
+	if ttl <= 0 || ttl > MaxTokenTTL {
+		return nil, fmt.Errorf("ttl must be between 1s and %s", MaxTokenTTL)
+	}
+	if uses < 1 || uses > MaxTokenUses {
+		return nil, fmt.Errorf("uses must be between 1 and %d", MaxTokenUses)
+	}
+	actions = slices.Compact(slices.Sorted(slices.Values(actions)))
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generating random bytes: %w", err)
+	}
+	tok := &Token{
+		ID:      models.NewULID().String(),
+		Token:   TokenPrefix + hex.EncodeToString(b),
+		Actions: actions,
+		MaxUses: uses,
+	}
+	if err := pool.QueryRow(ctx,
+		`INSERT INTO admin_action_tokens (id, token_hash, actions, created_by, expires_at, max_uses, created_at)
+		 VALUES ($1, $2, $3, $4, now() + $5 * interval '1 second', $6, now())
+		 RETURNING expires_at`,
+		tok.ID, HashToken(tok.Token), tok.Actions, createdBy, int64(ttl.Seconds()), tok.MaxUses,
+	).Scan(&tok.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("creating admin token: %w", err)
+	}
+	return tok, nil
+}
+
+// Authorize checks that raw is an unexpired admin action token bound to
+// action with uses left, and consumes one use. The check and the use are one
+// statement, so concurrent requests cannot spend the same use twice. A token
+// minted by an admin stops working once they are no longer one. It returns
+// the token's ID and creator.
+func Authorize(ctx context.Context, pool *pgxpool.Pool, raw, action string) (tokenID string, createdBy *string, err error) {
+	const usable = `t.token_hash = $1 AND t.expires_at > now() AND t.uses < t.max_uses
+		   AND (t.created_by IS NULL OR EXISTS (
+		       SELECT 1 FROM users u WHERE u.id = t.created_by AND u.flags & $2 <> 0
+		   ))`
+	err = pool.QueryRow(ctx,
+		`UPDATE admin_action_tokens t SET uses = t.uses + 1, last_used_at = now()
+		 WHERE `+usable+` AND $3 = ANY(t.actions)
+		 RETURNING t.id, t.created_by`,
+		HashToken(raw), models.UserFlagAdmin, action,
+	).Scan(&tokenID, &createdBy)
+	if err == nil {
+		return tokenID, createdBy, nil
+	}
+	if err != pgx.ErrNoRows {
+		return "", nil, err
+	}
+
+	// Nothing was consumed; tell a token bound to other actions apart from
+	// one that is unknown, expired or used up.
+	var usableForOther bool
+	if err := pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM admin_action_tokens t WHERE `+usable+`)`,
+		HashToken(raw), models.UserFlagAdmin,
+	).Scan(&usableForOther); err != nil {
+		return "", nil, err
+	}
+	if usableForOther {
+		return "", nil, ErrActionNotAllowed
+	}
+	return "", nil, ErrInvalidToken
+}
+
+// HashToken returns the stored form of a raw admin action token.
+func HashToken(raw string) string {
+	h := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(h[:])
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -155,11 +156,11 @@ func (h *Handler) HandleGetActivity(w http.ResponseWriter, r *http.Request) {
 		&a.ConfigSchema, &a.PermissionsRequired, &a.InstallCount,
 		&a.RatingSum, &a.RatingCount, &a.CreatedAt, &a.UpdatedAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Activity not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Activity not found")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get activity")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get activity")
 		return
 	}
 
@@ -263,7 +264,7 @@ func (h *Handler) HandleRateActivity(w http.ResponseWriter, r *http.Request) {
 		 ON CONFLICT (activity_id, user_id) DO UPDATE SET rating = $3, review = $4, created_at = NOW()`,
 		activityID, userID, req.Rating, req.Review)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to rate activity")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to rate activity")
 		return
 	}
 
@@ -314,7 +315,7 @@ func (h *Handler) HandleStartActivitySession(w http.ResponseWriter, r *http.Requ
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to verify activity")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to verify activity")
 		return
 	}
 
@@ -395,7 +396,7 @@ func (h *Handler) HandleJoinActivitySession(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get session")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get session")
 		return
 	}
 
@@ -416,7 +417,7 @@ func (h *Handler) HandleJoinActivitySession(w http.ResponseWriter, r *http.Reque
 		 ON CONFLICT (session_id, user_id) DO NOTHING`,
 		sessionID, userID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to join session")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to join session")
 		return
 	}
 
@@ -465,7 +466,7 @@ func (h *Handler) HandleEndActivitySession(w http.ResponseWriter, r *http.Reques
 		 WHERE id = $1 AND host_user_id = $2 AND status = 'active'`,
 		sessionID, userID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to end session")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to end session")
 		return
 	}
 	if tag.RowsAffected() == 0 {
@@ -523,7 +524,7 @@ func (h *Handler) HandleGetActiveSession(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get session")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get session")
 		return
 	}
 
@@ -579,11 +580,11 @@ func (h *Handler) HandleUpdateActivityState(w http.ResponseWriter, r *http.Reque
 		`UPDATE activity_sessions SET state = $1 WHERE id = $2 AND status = 'active'`,
 		req.State, sessionID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update state")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update state")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Active session not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Active session not found")
 		return
 	}
 
@@ -793,11 +794,11 @@ func (h *Handler) HandleJoinGame(w http.ResponseWriter, r *http.Request) {
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT game_type, status, channel_id FROM game_sessions WHERE id = $1`, gameID).Scan(&gameType, &status, &channelID)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Game not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Game not found")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get game")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get game")
 		return
 	}
 	if status != "waiting" {
@@ -825,7 +826,7 @@ func (h *Handler) HandleJoinGame(w http.ResponseWriter, r *http.Request) {
 		 ON CONFLICT (session_id, user_id) DO NOTHING`,
 		gameID, userID, playerCount)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to join game")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to join game")
 		return
 	}
 
@@ -872,11 +873,11 @@ func (h *Handler) HandleGameMove(w http.ResponseWriter, r *http.Request) {
 		`SELECT game_type, status, state, turn_user_id, channel_id
 		 FROM game_sessions WHERE id = $1`, gameID).Scan(&gameType, &status, &state, &turnUserID, &channelID)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Game not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Game not found")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get game")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get game")
 		return
 	}
 	if status != "playing" {
@@ -1026,11 +1027,11 @@ func (h *Handler) HandleGetGame(w http.ResponseWriter, r *http.Request) {
 		&g.ID, &g.ChannelID, &g.GameType, &g.State, &g.Config,
 		&g.Status, &g.WinnerUserID, &g.TurnUserID, &g.StartedAt, &g.EndedAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Game not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Game not found")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get game")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get game")
 		return
 	}
 
@@ -1091,7 +1092,7 @@ func (h *Handler) HandleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
 		 ORDER BY gl.wins DESC, gl.total_score DESC
 		 LIMIT 50`, gameType)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get leaderboard")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get leaderboard")
 		return
 	}
 	defer rows.Close()
@@ -1235,11 +1236,11 @@ func (h *Handler) HandleSyncWatchTogether(w http.ResponseWriter, r *http.Request
 		 WHERE activity_session_id = $4`,
 		req.CurrentTimeMs, playing, req.PlaybackRate, sessionID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to sync")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to sync")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Watch Together session not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Watch Together session not found")
 		return
 	}
 
@@ -1358,11 +1359,11 @@ func (h *Handler) HandleAddToMusicQueue(w http.ResponseWriter, r *http.Request)
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT queue FROM music_party_sessions WHERE activity_session_id = $1`, sessionID).Scan(&queue)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Music party not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Music party not found")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get queue")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get queue")
 		return
 	}
 
@@ -20,11 +20,13 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/federation"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
 	"github.com/amityvox/amityvox/internal/presence"
 )
 
@@ -42,6 +44,9 @@ type Handler struct {
 	EventBus   *events.Bus          // optional — enables real-time announcement events
 	Cache      *presence.Cache      // optional — enables accurate online user count
 	FedSvc     *federation.Service  // optional — enables federation handshake from admin
+
+	MinClientVersion string // advertised by GET /api/v1/instance
+	SigningKey       string // hex Ed25519 public key, advertised by GET /api/v1/instance
 }
 
 type updateInstanceRequest struct {
@@ -71,10 +76,58 @@ func (h *Handler) isAdmin(r *http.Request) bool {
 	return flags&models.UserFlagAdmin != 0
 }
 
+// logStaffAction records an instance staff action in the instance audit log.
+// Failures are logged rather than surfaced so the action itself still succeeds.
+func (h *Handler) logStaffAction(r *http.Request, action, targetType, targetID string, before, after interface{}, reason *string) {
+	err := apiutil.LogStaffAction(r.Context(), h.Pool, apiutil.StaffAction{
+		ActorID:    auth.UserIDFromContext(r.Context()),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+		Reason:     reason,
+	})
+	if err != nil {
+		h.Logger.Warn("failed to write instance audit log",
+			slog.String("action", action), slog.String("error", err.Error()))
+	}
+}
+
+// instanceSettings is the audited subset of the instances row.
+type instanceSettings struct {
+	Name           *string `json:"name"`
+	Description    *string `json:"description"`
+	FederationMode string  `json:"federation_mode"`
+	Shorthand      *string `json:"shorthand"`
+	VoiceMode      string  `json:"voice_mode"`
+}
+
+// userFlagState reads a user's flags ahead of a change so the audit log can
+// record the prior state. Missing users report zero.
+func (h *Handler) userFlagState(r *http.Request, userID string) int {
+	var flags int
+	h.Pool.QueryRow(r.Context(), `SELECT flags FROM users WHERE id = $1`, userID).Scan(&flags)
+	return flags
+}
+
+// requireInstancePermission checks that the requesting user holds the given
+// instance permission, either through the admin flag or an instance role.
+// Writes a 403 and returns false if not.
+func (h *Handler) requireInstancePermission(w http.ResponseWriter, r *http.Request, perm uint64) bool {
+	userID := auth.UserIDFromContext(r.Context())
+	if !apiutil.HasInstancePermission(r.Context(), h.Pool, userID, perm) {
+		apiutil.WriteError(w, http.StatusForbidden, "missing_instance_permission",
+			"Missing instance permission: "+strings.Join(permissions.InstanceNames(perm), ", "))
+		return false
+	}
+	return true
+}
+
 // HandleGetInstance handles GET /api/v1/admin/instance.
 func (h *Handler) HandleGetInstance(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -101,7 +154,7 @@ func (h *Handler) HandleGetInstance(w http.ResponseWriter, r *http.Request) {
 // HandleUpdateInstance handles PATCH /api/v1/admin/instance.
 func (h *Handler) HandleUpdateInstance(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -134,6 +187,11 @@ func (h *Handler) HandleUpdateInstance(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var before instanceSettings
+	h.Pool.QueryRow(r.Context(),
+		`SELECT name, description, federation_mode, shorthand, voice_mode FROM instances WHERE id = $1`,
+		h.InstanceID).Scan(&before.Name, &before.Description, &before.FederationMode, &before.Shorthand, &before.VoiceMode)
+
 	var inst models.Instance
 	err := h.Pool.QueryRow(r.Context(),
 		`UPDATE instances
@@ -164,18 +222,22 @@ func (h *Handler) HandleUpdateInstance(w http.ResponseWriter, r *http.Request) {
 		h.FedSvc.InvalidateFedModeCache()
 	}
 
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance", h.InstanceID, before, instanceSettings{
+		Name: inst.Name, Description: inst.Description, FederationMode: inst.FederationMode,
+		Shorthand: inst.Shorthand, VoiceMode: inst.VoiceMode,
+	}, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, inst)
 }
 
 // HandleGetFederationPeers handles GET /api/v1/admin/federation/peers.
 func (h *Handler) HandleGetFederationPeers(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
 	rows, err := h.Pool.Query(r.Context(),
-		`SELECT fp.peer_id, fp.status, fp.established_at, fp.last_synced_at,
+		`SELECT fp.peer_id, fp.status, fp.trust_level, fp.sandbox, fp.established_at, fp.last_synced_at,
 		        i.domain, i.name, i.software, i.software_version
 		 FROM federation_peers fp
 		 JOIN instances i ON i.id = fp.peer_id
@@ -194,6 +256,8 @@ func (h *Handler) HandleGetFederationPeers(w http.ResponseWriter, r *http.Reques
 		Software        string     `json:"software"`
 		SoftwareVersion *string    `json:"software_version,omitempty"`
 		Status          string     `json:"status"`
+		TrustLevel      string     `json:"trust_level"`
+		Sandbox         bool       `json:"sandbox"`
 		LastSeenAt      *time.Time `json:"last_seen_at,omitempty"`
 		CreatedAt       time.Time  `json:"created_at"`
 	}
@@ -202,7 +266,7 @@ func (h *Handler) HandleGetFederationPeers(w http.ResponseWriter, r *http.Reques
 	for rows.Next() {
 		var p peerResponse
 		if err := rows.Scan(
-			&p.ID, &p.Status, &p.CreatedAt, &p.LastSeenAt,
+			&p.ID, &p.Status, &p.TrustLevel, &p.Sandbox, &p.CreatedAt, &p.LastSeenAt,
 			&p.Domain, &p.Name, &p.Software, &p.SoftwareVersion,
 		); err != nil {
 			apiutil.InternalError(w, h.Logger, "Failed to read federation peers", err)
@@ -219,8 +283,7 @@ func (h *Handler) HandleGetFederationPeers(w http.ResponseWriter, r *http.Reques
 // resolves domain IPs, computes key fingerprint, creates a pending peer,
 // and sends a handshake request to the remote instance.
 func (h *Handler) HandleAddFederationPeer(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -369,29 +432,34 @@ func (h *Handler) HandleAddFederationPeer(w http.ResponseWriter, r *http.Request
 		resp["handshake_info"] = hsInfo
 	}
 
+	h.logStaffAction(r, models.StaffActionFederationPeerAdd, "instance", peerID,
+		nil, map[string]string{"domain": req.Domain, "status": peerStatus}, nil)
+
 	apiutil.WriteJSON(w, http.StatusCreated, resp)
 }
 
 // HandleRemoveFederationPeer handles DELETE /api/v1/admin/federation/peers/{peerID}.
 func (h *Handler) HandleRemoveFederationPeer(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
 	peerID := chi.URLParam(r, "peerID")
 
-	tag, err := h.Pool.Exec(r.Context(),
-		`DELETE FROM federation_peers WHERE instance_id = $1 AND peer_id = $2`,
-		h.InstanceID, peerID)
-	if err != nil {
-		apiutil.InternalError(w, h.Logger, "Failed to remove federation peer", err)
+	var status string
+	err := h.Pool.QueryRow(r.Context(),
+		`DELETE FROM federation_peers WHERE instance_id = $1 AND peer_id = $2 RETURNING status`,
+		h.InstanceID, peerID).Scan(&status)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "peer_not_found", "Federation peer not found")
 		return
 	}
-	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "peer_not_found", "Federation peer not found")
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to remove federation peer", err)
 		return
 	}
+	h.logStaffAction(r, models.StaffActionFederationPeerRemove, "instance", peerID,
+		map[string]string{"status": status}, nil, nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -399,7 +467,7 @@ func (h *Handler) HandleRemoveFederationPeer(w http.ResponseWriter, r *http.Requ
 // HandleGetStats handles GET /api/v1/admin/stats.
 func (h *Handler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -422,6 +490,7 @@ func (h *Handler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
 		MemSysMB     uint64 `json:"mem_sys_mb"`
 		NumCPU       int    `json:"num_cpu"`
 		Uptime       string `json:"uptime"`
+		Cleanup      []cleanupStat `json:"cleanup"`
 	}
 
 	var s stats
@@ -495,13 +564,48 @@ func (h *Handler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
 		s.Uptime = time.Since(createdAt).Truncate(time.Second).String()
 	}
 
+	s.Cleanup = h.loadCleanupStats(r.Context())
+
 	apiutil.WriteJSON(w, http.StatusOK, s)
 }
 
+// cleanupStat is what the dead-link cleanup worker has reclaimed of one kind:
+// invites, channel_followers or files.
+type cleanupStat struct {
+	Kind           string    `json:"kind"`
+	LastRemoved    int64     `json:"last_removed"`
+	TotalRemoved   int64     `json:"total_removed"`
+	BytesReclaimed int64     `json:"bytes_reclaimed"`
+	LastRunAt      time.Time `json:"last_run_at"`
+}
+
+// loadCleanupStats returns the cleanup worker's totals, or an empty list if
+// it has not run yet or the lookup fails.
+func (h *Handler) loadCleanupStats(ctx context.Context) []cleanupStat {
+	out := []cleanupStat{}
+	rows, err := h.Pool.Query(ctx,
+		`SELECT kind, last_removed, total_removed, bytes_reclaimed, last_run_at
+		 FROM cleanup_stats ORDER BY kind`)
+	if err != nil {
+		h.Logger.Warn("stats query failed",
+			slog.String("sql", "cleanup_stats"),
+			slog.String("error", err.Error()),
+		)
+		return out
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c cleanupStat
+		if rows.Scan(&c.Kind, &c.LastRemoved, &c.TotalRemoved, &c.BytesReclaimed, &c.LastRunAt) == nil {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
 // HandleListUsers handles GET /api/v1/admin/users.
 func (h *Handler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 
@@ -542,7 +646,7 @@ func (h *Handler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
 			 LIMIT $1 OFFSET $2`, limit, offset)
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to list users")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to list users")
 		return
 	}
 	defer rows.Close()
@@ -574,45 +678,98 @@ func (h *Handler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
 	apiutil.WriteJSON(w, http.StatusOK, users)
 }
 
-// HandleSuspendUser handles POST /api/v1/admin/users/{userID}/suspend.
+// suspendUserRequest is the optional body of a suspension. A missing or
+// non-positive duration suspends indefinitely; the reason is shown to the user
+// when they try to log in.
+type suspendUserRequest struct {
+	Reason          *string `json:"reason"`
+	DurationSeconds *int64  `json:"duration_seconds"`
+}
+
+// revokeUserSessions signs a user out everywhere, logging rather than
+// failing the surrounding action on error.
+func (h *Handler) revokeUserSessions(r *http.Request, userID string) {
+	if err := apiutil.RevokeUserSessions(r.Context(), h.Pool, h.Cache, h.EventBus, userID, "suspended"); err != nil {
+		h.Logger.Warn("failed to revoke user sessions",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+}
+
+// HandleSuspendUser suspends a user, optionally for a limited time, and
+// revokes their sessions. Timed suspensions are lifted by the suspension
+// expiry worker.
+// POST /api/v1/admin/users/{userID}/suspend
 func (h *Handler) HandleSuspendUser(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 	userID := chi.URLParam(r, "userID")
 
-	_, err := h.Pool.Exec(r.Context(),
-		`UPDATE users SET flags = flags | $1 WHERE id = $2`, models.UserFlagSuspended, userID)
+	var req suspendUserRequest
+	if r.ContentLength != 0 {
+		if !apiutil.DecodeJSON(w, r, &req) {
+			return
+		}
+	}
+	if req.Reason != nil && !apiutil.ValidateStringLength(w, "reason", *req.Reason, 0, 1000) {
+		return
+	}
+	var until *time.Time
+	if req.DurationSeconds != nil && *req.DurationSeconds > 0 {
+		t := time.Now().UTC().Add(time.Duration(*req.DurationSeconds) * time.Second)
+		until = &t
+	}
+
+	prev := h.userFlagState(r, userID)
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE users SET flags = flags | $1, suspended_until = $3, suspension_reason = $4, scim_suspended = false
+		 WHERE id = $2`, models.UserFlagSuspended, userID, until, req.Reason)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to suspend user")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to suspend user")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteCode(w, apierrors.NotFound, "User not found")
 		return
 	}
-	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "suspended"})
+	h.revokeUserSessions(r, userID)
+
+	h.logStaffAction(r, models.StaffActionUserSuspend, "user", userID,
+		map[string]bool{"suspended": prev&models.UserFlagSuspended != 0},
+		map[string]interface{}{"suspended": true, "suspended_until": until}, req.Reason)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"status":          "suspended",
+		"suspended_until": until,
+	})
 }
 
 // HandleUnsuspendUser handles POST /api/v1/admin/users/{userID}/unsuspend.
 func (h *Handler) HandleUnsuspendUser(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 	userID := chi.URLParam(r, "userID")
+	prev := h.userFlagState(r, userID)
 
 	mask := ^models.UserFlagSuspended
 	_, err := h.Pool.Exec(r.Context(),
-		`UPDATE users SET flags = flags & $1 WHERE id = $2`, mask, userID)
+		`UPDATE users SET flags = flags & $1, suspended_until = NULL, suspension_reason = NULL
+		 WHERE id = $2`, mask, userID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to unsuspend user")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to unsuspend user")
 		return
 	}
+	h.logStaffAction(r, models.StaffActionUserUnsuspend, "user", userID,
+		map[string]bool{"suspended": prev&models.UserFlagSuspended != 0},
+		map[string]bool{"suspended": false}, nil)
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "unsuspended"})
 }
 
 // HandleSetAdmin handles POST /api/v1/admin/users/{userID}/set-admin.
 func (h *Handler) HandleSetAdmin(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 	userID := chi.URLParam(r, "userID")
@@ -624,6 +781,7 @@ func (h *Handler) HandleSetAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	prev := h.userFlagState(r, userID)
 	var err error
 	if req.Admin {
 		_, err = h.Pool.Exec(r.Context(),
@@ -634,16 +792,19 @@ func (h *Handler) HandleSetAdmin(w http.ResponseWriter, r *http.Request) {
 			`UPDATE users SET flags = flags & $1 WHERE id = $2`, mask, userID)
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update admin status")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update admin status")
 		return
 	}
+	h.logStaffAction(r, models.StaffActionUserSetAdmin, "user", userID,
+		map[string]bool{"admin": prev&models.UserFlagAdmin != 0},
+		map[string]bool{"admin": req.Admin}, nil)
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"admin": req.Admin})
 }
 
 // HandleSetGlobalMod handles POST /api/v1/admin/users/{userID}/set-globalmod.
 func (h *Handler) HandleSetGlobalMod(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 	userID := chi.URLParam(r, "userID")
@@ -655,6 +816,7 @@ func (h *Handler) HandleSetGlobalMod(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	prev := h.userFlagState(r, userID)
 	var tag pgconn.CommandTag
 	var err error
 	if req.GlobalMod {
@@ -666,21 +828,23 @@ func (h *Handler) HandleSetGlobalMod(w http.ResponseWriter, r *http.Request) {
 			`UPDATE users SET flags = flags & $1 WHERE id = $2`, mask, userID)
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update global mod status")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update global mod status")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "User not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "User not found")
 		return
 	}
+	h.logStaffAction(r, models.StaffActionUserSetGlobalMod, "user", userID,
+		map[string]bool{"global_mod": prev&models.UserFlagGlobalMod != 0},
+		map[string]bool{"global_mod": req.GlobalMod}, nil)
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"global_mod": req.GlobalMod})
 }
 
 // HandleInstanceBanUser bans a user at the instance level (suspends + records reason).
 // POST /api/v1/admin/users/{userID}/instance-ban
 func (h *Handler) HandleInstanceBanUser(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 	targetID := chi.URLParam(r, "userID")
@@ -690,11 +854,13 @@ func (h *Handler) HandleInstanceBanUser(w http.ResponseWriter, r *http.Request)
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	// Suspend the user.
+	prev := h.userFlagState(r, targetID)
+
+	// Suspend the user. Instance bans never expire on their own.
 	_, err := h.Pool.Exec(r.Context(),
-		`UPDATE users SET flags = flags | $1 WHERE id = $2`, models.UserFlagSuspended, targetID)
+		`UPDATE users SET flags = flags | $1, suspended_until = NULL, scim_suspended = false WHERE id = $2`, models.UserFlagSuspended, targetID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to ban user")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to ban user")
 		return
 	}
 
@@ -707,7 +873,15 @@ func (h *Handler) HandleInstanceBanUser(w http.ResponseWriter, r *http.Request)
 		targetID, adminID, req.Reason)
 
 	// Invalidate all sessions for the banned user.
-	h.Pool.Exec(r.Context(), `DELETE FROM user_sessions WHERE user_id = $1`, targetID)
+	h.revokeUserSessions(r, targetID)
+
+	var reason *string
+	if req.Reason != "" {
+		reason = &req.Reason
+	}
+	h.logStaffAction(r, models.StaffActionUserInstanceBan, "user", targetID,
+		map[string]bool{"suspended": prev&models.UserFlagSuspended != 0},
+		map[string]bool{"suspended": true}, reason)
 
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "banned"})
 }
@@ -715,21 +889,27 @@ func (h *Handler) HandleInstanceBanUser(w http.ResponseWriter, r *http.Request)
 // HandleInstanceUnbanUser unbans a user at the instance level.
 // POST /api/v1/admin/users/{userID}/instance-unban
 func (h *Handler) HandleInstanceUnbanUser(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 	targetID := chi.URLParam(r, "userID")
 
 	mask := ^models.UserFlagSuspended
 	_, err := h.Pool.Exec(r.Context(),
-		`UPDATE users SET flags = flags & $1 WHERE id = $2`, mask, targetID)
+		`UPDATE users SET flags = flags & $1, suspended_until = NULL, suspension_reason = NULL
+		 WHERE id = $2`, mask, targetID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to unban user")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to unban user")
 		return
 	}
 
-	h.Pool.Exec(r.Context(), `DELETE FROM instance_bans WHERE user_id = $1`, targetID)
+	var banReason *string
+	h.Pool.QueryRow(r.Context(),
+		`DELETE FROM instance_bans WHERE user_id = $1 RETURNING reason`, targetID).Scan(&banReason)
+
+	h.logStaffAction(r, models.StaffActionUserInstanceUnban, "user", targetID,
+		map[string]interface{}{"suspended": true, "ban_reason": banReason},
+		map[string]bool{"suspended": false}, nil)
 
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "unbanned"})
 }
@@ -737,8 +917,7 @@ func (h *Handler) HandleInstanceUnbanUser(w http.ResponseWriter, r *http.Request
 // HandleGetInstanceBans lists all instance-level banned users.
 // GET /api/v1/admin/instance-bans
 func (h *Handler) HandleGetInstanceBans(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 
@@ -749,7 +928,7 @@ func (h *Handler) HandleGetInstanceBans(w http.ResponseWriter, r *http.Request)
 		 JOIN users u ON u.id = ib.user_id
 		 ORDER BY ib.created_at DESC`)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get bans")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get bans")
 		return
 	}
 	defer rows.Close()
@@ -782,8 +961,7 @@ func (h *Handler) HandleGetInstanceBans(w http.ResponseWriter, r *http.Request)
 // HandleGetRegistrationConfig returns the instance registration settings.
 // GET /api/v1/admin/registration
 func (h *Handler) HandleGetRegistrationConfig(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 
@@ -809,8 +987,7 @@ func (h *Handler) HandleGetRegistrationConfig(w http.ResponseWriter, r *http.Req
 // HandleUpdateRegistrationConfig updates registration settings.
 // PATCH /api/v1/admin/registration
 func (h *Handler) HandleUpdateRegistrationConfig(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 
@@ -822,6 +999,8 @@ func (h *Handler) HandleUpdateRegistrationConfig(w http.ResponseWriter, r *http.
 		return
 	}
 
+	before := h.registrationSettings(r)
+
 	if req.Mode != nil {
 		switch *req.Mode {
 		case "open", "invite_only", "closed":
@@ -839,16 +1018,38 @@ func (h *Handler) HandleUpdateRegistrationConfig(w http.ResponseWriter, r *http.
 			 ON CONFLICT (key) DO UPDATE SET value = $1`, *req.Message)
 	}
 
+	h.logStaffAction(r, models.StaffActionRegistrationUpdate, "instance", h.InstanceID,
+		before, h.registrationSettings(r), nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
+// registrationSettings returns the current registration mode and message for
+// audit logging.
+func (h *Handler) registrationSettings(r *http.Request) map[string]string {
+	settings := map[string]string{"mode": "open", "message": ""}
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT key, value FROM instance_settings
+		 WHERE key IN ('registration_mode', 'registration_message')`)
+	if err != nil {
+		return settings
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if rows.Scan(&key, &value) == nil {
+			settings[strings.TrimPrefix(key, "registration_")] = value
+		}
+	}
+	return settings
+}
+
 // --- Registration Token Handlers ---
 
 // HandleCreateRegistrationToken creates a new registration token for invite-only mode.
 // POST /api/v1/admin/registration/tokens
 func (h *Handler) HandleCreateRegistrationToken(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 
@@ -883,21 +1084,23 @@ func (h *Handler) HandleCreateRegistrationToken(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	apiutil.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+	token := map[string]interface{}{
 		"id":         tokenID,
 		"max_uses":   req.MaxUses,
 		"uses":       0,
 		"note":       req.Note,
 		"expires_at": expiresAt,
 		"created_by": adminID,
-	})
+	}
+	h.logStaffAction(r, models.StaffActionRegTokenCreate, "registration_token", tokenID, nil, token, nil)
+
+	apiutil.WriteJSON(w, http.StatusCreated, token)
 }
 
 // HandleListRegistrationTokens lists all registration tokens.
 // GET /api/v1/admin/registration/tokens
 func (h *Handler) HandleListRegistrationTokens(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 
@@ -908,7 +1111,7 @@ func (h *Handler) HandleListRegistrationTokens(w http.ResponseWriter, r *http.Re
 		 JOIN users u ON u.id = rt.created_by
 		 ORDER BY rt.created_at DESC`)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to list tokens")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to list tokens")
 		return
 	}
 	defer rows.Close()
@@ -944,8 +1147,7 @@ func (h *Handler) HandleListRegistrationTokens(w http.ResponseWriter, r *http.Re
 // HandleDeleteRegistrationToken deletes a registration token.
 // DELETE /api/v1/admin/registration/tokens/{tokenID}
 func (h *Handler) HandleDeleteRegistrationToken(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 
@@ -953,14 +1155,16 @@ func (h *Handler) HandleDeleteRegistrationToken(w http.ResponseWriter, r *http.R
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM registration_tokens WHERE id = $1`, tokenID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete token")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete token")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Token not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Token not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionRegTokenDelete, "registration_token", tokenID, nil, nil, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -970,7 +1174,7 @@ func (h *Handler) HandleDeleteRegistrationToken(w http.ResponseWriter, r *http.R
 // POST /api/v1/admin/announcements
 func (h *Handler) HandleCreateAnnouncement(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -985,7 +1189,7 @@ func (h *Handler) HandleCreateAnnouncement(w http.ResponseWriter, r *http.Reques
 	}
 
 	if req.Title == "" || req.Content == "" {
-		apiutil.WriteError(w, http.StatusBadRequest, "missing_fields", "Title and content are required")
+		apiutil.WriteCode(w, apierrors.MissingFields, "Title and content are required")
 		return
 	}
 
@@ -1025,6 +1229,7 @@ func (h *Handler) HandleCreateAnnouncement(w http.ResponseWriter, r *http.Reques
 		"active":     true,
 		"expires_at": expiresAt,
 	}
+	h.logStaffAction(r, models.StaffActionAnnouncementCreate, "announcement", announcementID, nil, announcement, nil)
 
 	// Publish real-time event so all connected clients see the announcement immediately.
 	if h.EventBus != nil {
@@ -1046,7 +1251,7 @@ func (h *Handler) HandleGetAnnouncements(w http.ResponseWriter, r *http.Request)
 		 ORDER BY ia.created_at DESC
 		 LIMIT 10`)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get announcements")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get announcements")
 		return
 	}
 	defer rows.Close()
@@ -1080,7 +1285,7 @@ func (h *Handler) HandleGetAnnouncements(w http.ResponseWriter, r *http.Request)
 // GET /api/v1/admin/announcements
 func (h *Handler) HandleListAllAnnouncements(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1092,7 +1297,7 @@ func (h *Handler) HandleListAllAnnouncements(w http.ResponseWriter, r *http.Requ
 		 ORDER BY ia.created_at DESC
 		 LIMIT 50`)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get announcements")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get announcements")
 		return
 	}
 	defer rows.Close()
@@ -1126,7 +1331,7 @@ func (h *Handler) HandleListAllAnnouncements(w http.ResponseWriter, r *http.Requ
 // PATCH /api/v1/admin/announcements/{announcementID}
 func (h *Handler) HandleUpdateAnnouncement(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1149,14 +1354,16 @@ func (h *Handler) HandleUpdateAnnouncement(w http.ResponseWriter, r *http.Reques
 		 WHERE id = $4`,
 		req.Active, req.Title, req.Content, announcementID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update announcement")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update announcement")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Announcement not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Announcement not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionAnnouncementUpdate, "announcement", announcementID, nil, req, nil)
+
 	// Publish real-time update event.
 	if h.EventBus != nil {
 		h.EventBus.PublishBroadcastEvent(r.Context(), events.SubjectAnnouncementUpdate, "ANNOUNCEMENT_UPDATE", map[string]interface{}{
@@ -1174,7 +1381,7 @@ func (h *Handler) HandleUpdateAnnouncement(w http.ResponseWriter, r *http.Reques
 // DELETE /api/v1/admin/announcements/{announcementID}
 func (h *Handler) HandleDeleteAnnouncement(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1182,14 +1389,16 @@ func (h *Handler) HandleDeleteAnnouncement(w http.ResponseWriter, r *http.Reques
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM instance_announcements WHERE id = $1`, announcementID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete announcement")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete announcement")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Announcement not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Announcement not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionAnnouncementDelete, "announcement", announcementID, nil, nil, nil)
+
 	// Publish real-time delete event.
 	if h.EventBus != nil {
 		h.EventBus.PublishBroadcastEvent(r.Context(), events.SubjectAnnouncementDelete, "ANNOUNCEMENT_DELETE", map[string]string{
@@ -1204,11 +1413,28 @@ func (h *Handler) HandleDeleteAnnouncement(w http.ResponseWriter, r *http.Reques
 // Guild Admin Handlers
 // =============================================================================
 
+// guildRow is a guild as listed for admins.
+type guildRow struct {
+	models.Guild
+	guildRowStats
+}
+
+type guildRowStats struct {
+	OwnerName    string `json:"owner_name"`
+	ChannelCount int    `json:"channel_count"`
+	RoleCount    int    `json:"role_count"`
+}
+
+// MarshalJSON writes the guild with its stats added.
+func (g guildRow) MarshalJSON() ([]byte, error) {
+	return models.MergeJSON(g.Guild, g.guildRowStats)
+}
+
 // HandleListGuilds returns all guilds with owner info and stats.
 // GET /api/v1/admin/guilds
 func (h *Handler) HandleListGuilds(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1241,18 +1467,11 @@ func (h *Handler) HandleListGuilds(w http.ResponseWriter, r *http.Request) {
 		orderBy = "g.created_at DESC"
 	}
 
-	type guildRow struct {
-		models.Guild
-		OwnerName    string `json:"owner_name"`
-		ChannelCount int    `json:"channel_count"`
-		RoleCount    int    `json:"role_count"`
-	}
-
 	var baseQuery string
 	if q != "" {
 		baseQuery = fmt.Sprintf(`SELECT g.id, g.instance_id, g.owner_id, g.name, g.description,
 		        g.icon_id, g.banner_id, g.default_permissions, g.flags, g.nsfw, g.discoverable,
-		        g.preferred_locale, g.max_members, g.vanity_url, g.verification_level, g.tags,
+		        g.preferred_locale, g.timezone, g.max_members, g.vanity_url, g.verification_level, g.tags,
 		        g.created_at,
 		        COALESCE(u.username, 'unknown') AS owner_name,
 		        (SELECT COUNT(*) FROM guild_members gm WHERE gm.guild_id = g.id) AS member_count,
@@ -1266,7 +1485,7 @@ func (h *Handler) HandleListGuilds(w http.ResponseWriter, r *http.Request) {
 	} else {
 		baseQuery = fmt.Sprintf(`SELECT g.id, g.instance_id, g.owner_id, g.name, g.description,
 		        g.icon_id, g.banner_id, g.default_permissions, g.flags, g.nsfw, g.discoverable,
-		        g.preferred_locale, g.max_members, g.vanity_url, g.verification_level, g.tags,
+		        g.preferred_locale, g.timezone, g.max_members, g.vanity_url, g.verification_level, g.tags,
 		        g.created_at,
 		        COALESCE(u.username, 'unknown') AS owner_name,
 		        (SELECT COUNT(*) FROM guild_members gm WHERE gm.guild_id = g.id) AS member_count,
@@ -1297,7 +1516,7 @@ func (h *Handler) HandleListGuilds(w http.ResponseWriter, r *http.Request) {
 		if err := rows.Scan(
 			&g.ID, &g.InstanceID, &g.OwnerID, &g.Name, &g.Description,
 			&g.IconID, &g.BannerID, &g.DefaultPermissions, &g.Flags, &g.NSFW, &g.Discoverable,
-			&g.PreferredLocale, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel, &g.Tags,
+			&g.PreferredLocale, &g.Timezone, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel, &g.Tags,
 			&g.CreatedAt,
 			&g.OwnerName, &g.MemberCount, &g.ChannelCount, &g.RoleCount,
 		); err != nil {
@@ -1309,34 +1528,44 @@ func (h *Handler) HandleListGuilds(w http.ResponseWriter, r *http.Request) {
 	apiutil.WriteJSON(w, http.StatusOK, guilds)
 }
 
+// guildDetail is a guild with the stats shown on its admin page.
+type guildDetail struct {
+	models.Guild
+	guildDetailStats
+}
+
+type guildDetailStats struct {
+	OwnerName     string `json:"owner_name"`
+	ChannelCount  int    `json:"channel_count"`
+	RoleCount     int    `json:"role_count"`
+	EmojiCount    int    `json:"emoji_count"`
+	InviteCount   int    `json:"invite_count"`
+	MessageCount  int64  `json:"message_count"`
+	MessagesToday int64  `json:"messages_today"`
+	BanCount      int    `json:"ban_count"`
+}
+
+// MarshalJSON writes the guild with its stats added.
+func (g guildDetail) MarshalJSON() ([]byte, error) {
+	return models.MergeJSON(g.Guild, g.guildDetailStats)
+}
+
 // HandleGetGuildDetails returns detailed info for a single guild (admin view).
 // GET /api/v1/admin/guilds/{guildID}
 func (h *Handler) HandleGetGuildDetails(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
 	guildID := chi.URLParam(r, "guildID")
 
-	type guildDetail struct {
-		models.Guild
-		OwnerName      string `json:"owner_name"`
-		ChannelCount   int    `json:"channel_count"`
-		RoleCount      int    `json:"role_count"`
-		EmojiCount     int    `json:"emoji_count"`
-		InviteCount    int    `json:"invite_count"`
-		MessageCount   int64  `json:"message_count"`
-		MessagesToday  int64  `json:"messages_today"`
-		BanCount       int    `json:"ban_count"`
-	}
-
 	var g guildDetail
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT g.id, g.instance_id, g.owner_id, g.name, g.description,
 		        g.icon_id, g.banner_id, g.default_permissions, g.flags, g.nsfw, g.discoverable,
 		        g.system_channel_join, g.system_channel_leave, g.system_channel_kick, g.system_channel_ban,
-		        g.preferred_locale, g.max_members, g.vanity_url, g.verification_level,
+		        g.preferred_locale, g.timezone, g.max_members, g.vanity_url, g.verification_level,
 		        g.afk_channel_id, g.afk_timeout, g.tags, g.created_at,
 		        COALESCE(u.username, 'unknown') AS owner_name,
 		        (SELECT COUNT(*) FROM guild_members gm WHERE gm.guild_id = g.id) AS member_count,
@@ -1354,13 +1583,13 @@ func (h *Handler) HandleGetGuildDetails(w http.ResponseWriter, r *http.Request)
 		&g.ID, &g.InstanceID, &g.OwnerID, &g.Name, &g.Description,
 		&g.IconID, &g.BannerID, &g.DefaultPermissions, &g.Flags, &g.NSFW, &g.Discoverable,
 		&g.SystemChannelJoin, &g.SystemChannelLeave, &g.SystemChannelKick, &g.SystemChannelBan,
-		&g.PreferredLocale, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel,
+		&g.PreferredLocale, &g.Timezone, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel,
 		&g.AFKChannelID, &g.AFKTimeout, &g.Tags, &g.CreatedAt,
 		&g.OwnerName, &g.MemberCount, &g.ChannelCount, &g.RoleCount,
 		&g.EmojiCount, &g.InviteCount, &g.MessageCount, &g.MessagesToday, &g.BanCount,
 	)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Guild not found")
 		return
 	}
 	if err != nil {
@@ -1375,21 +1604,25 @@ func (h *Handler) HandleGetGuildDetails(w http.ResponseWriter, r *http.Request)
 // DELETE /api/v1/admin/guilds/{guildID}
 func (h *Handler) HandleAdminDeleteGuild(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
 	guildID := chi.URLParam(r, "guildID")
 
-	tag, err := h.Pool.Exec(r.Context(), `DELETE FROM guilds WHERE id = $1`, guildID)
-	if err != nil {
-		apiutil.InternalError(w, h.Logger, "Failed to delete guild", err)
+	var name, ownerID string
+	err := h.Pool.QueryRow(r.Context(),
+		`DELETE FROM guilds WHERE id = $1 RETURNING name, owner_id`, guildID).Scan(&name, &ownerID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.NotFound, "Guild not found")
 		return
 	}
-	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Guild not found")
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete guild", err)
 		return
 	}
+	h.logStaffAction(r, models.StaffActionGuildDelete, "guild", guildID,
+		map[string]string{"name": name, "owner_id": ownerID}, nil, nil)
 
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
@@ -1397,8 +1630,7 @@ func (h *Handler) HandleAdminDeleteGuild(w http.ResponseWriter, r *http.Request)
 // HandleGetUserGuilds returns all guilds a specific user is a member of (admin view).
 // GET /api/v1/admin/users/{userID}/guilds
 func (h *Handler) HandleGetUserGuilds(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
 		return
 	}
 
@@ -1460,7 +1692,7 @@ func parseInt(s string) (int, error) {
 // GET /api/v1/admin/rate-limits/stats
 func (h *Handler) HandleGetRateLimitStats(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1527,7 +1759,7 @@ func (h *Handler) HandleGetRateLimitStats(w http.ResponseWriter, r *http.Request
 // GET /api/v1/admin/rate-limits/log
 func (h *Handler) HandleGetRateLimitLog(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1614,7 +1846,7 @@ func (h *Handler) HandleGetRateLimitLog(w http.ResponseWriter, r *http.Request)
 // PATCH /api/v1/admin/rate-limits/config
 func (h *Handler) HandleUpdateRateLimitConfig(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1646,6 +1878,8 @@ func (h *Handler) HandleUpdateRateLimitConfig(w http.ResponseWriter, r *http.Req
 			 ON CONFLICT (key) DO UPDATE SET value = $1, updated_at = now()`, *req.WindowSeconds)
 	}
 
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", "rate_limit", nil, req, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
@@ -1656,8 +1890,7 @@ func (h *Handler) HandleUpdateRateLimitConfig(w http.ResponseWriter, r *http.Req
 // HandleGetContentScanRules returns all content scan rules.
 // GET /api/v1/admin/content-scan/rules
 func (h *Handler) HandleGetContentScanRules(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceModerateMedia) {
 		return
 	}
 
@@ -1696,8 +1929,7 @@ func (h *Handler) HandleGetContentScanRules(w http.ResponseWriter, r *http.Reque
 // HandleCreateContentScanRule creates a new content scan rule.
 // POST /api/v1/admin/content-scan/rules
 func (h *Handler) HandleCreateContentScanRule(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceModerateMedia) {
 		return
 	}
 
@@ -1761,7 +1993,7 @@ func (h *Handler) HandleCreateContentScanRule(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	apiutil.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+	rule := map[string]interface{}{
 		"id":         ruleID,
 		"name":       req.Name,
 		"pattern":    req.Pattern,
@@ -1769,14 +2001,16 @@ func (h *Handler) HandleCreateContentScanRule(w http.ResponseWriter, r *http.Req
 		"target":     req.Target,
 		"enabled":    enabled,
 		"created_at": time.Now().UTC(),
-	})
+	}
+	h.logStaffAction(r, models.StaffActionContentScanRuleCreate, "content_scan_rule", ruleID, nil, rule, nil)
+
+	apiutil.WriteJSON(w, http.StatusCreated, rule)
 }
 
 // HandleUpdateContentScanRule updates an existing content scan rule.
 // PATCH /api/v1/admin/content-scan/rules/{ruleID}
 func (h *Handler) HandleUpdateContentScanRule(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceModerateMedia) {
 		return
 	}
 
@@ -1837,18 +2071,19 @@ func (h *Handler) HandleUpdateContentScanRule(w http.ResponseWriter, r *http.Req
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Content scan rule not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Content scan rule not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionContentScanRuleUpdate, "content_scan_rule", ruleID, nil, req, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
 // HandleDeleteContentScanRule deletes a content scan rule.
 // DELETE /api/v1/admin/content-scan/rules/{ruleID}
 func (h *Handler) HandleDeleteContentScanRule(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceModerateMedia) {
 		return
 	}
 
@@ -1860,18 +2095,19 @@ func (h *Handler) HandleDeleteContentScanRule(w http.ResponseWriter, r *http.Req
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Content scan rule not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Content scan rule not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionContentScanRuleDelete, "content_scan_rule", ruleID, nil, nil, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // HandleGetContentScanLog returns paginated content scan log entries.
 // GET /api/v1/admin/content-scan/log
 func (h *Handler) HandleGetContentScanLog(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceModerateMedia) {
 		return
 	}
 
@@ -1954,7 +2190,7 @@ func (h *Handler) HandleGetContentScanLog(w http.ResponseWriter, r *http.Request
 // GET /api/v1/admin/captcha
 func (h *Handler) HandleGetCaptchaConfig(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1985,7 +2221,7 @@ func (h *Handler) HandleGetCaptchaConfig(w http.ResponseWriter, r *http.Request)
 // PATCH /api/v1/admin/captcha
 func (h *Handler) HandleUpdateCaptchaConfig(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -2022,5 +2258,13 @@ func (h *Handler) HandleUpdateCaptchaConfig(w http.ResponseWriter, r *http.Reque
 			 ON CONFLICT (key) DO UPDATE SET value = $1, updated_at = now()`, *req.SecretKey)
 	}
 
+	// Never write the secret key itself into the audit log.
+	after := map[string]interface{}{
+		"provider":           req.Provider,
+		"site_key":           req.SiteKey,
+		"secret_key_changed": req.SecretKey != nil,
+	}
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", "captcha", nil, after, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
 )
 
 func TestWriteJSON(t *testing.T) {
@@ -173,3 +177,146 @@ func TestWriteJSON_StatusCodes(t *testing.T) {
 		}
 	}
 }
+
+func TestAuditLogFilter(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantWhere string
+		wantArgs  int
+	}{
+		{"", "", 0},
+		{"?action=user_suspend", " WHERE action = $1", 1},
+		{"?target_type=user&target_id=u1&before=01H", " WHERE target_type = $1 AND target_id = $2 AND id < $3", 3},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/admin/audit-log"+tt.query, nil)
+		where, args := auditLogFilter(r)
+		if where != tt.wantWhere || len(args) != tt.wantArgs {
+			t.Errorf("auditLogFilter(%q) = (%q, %d args), want (%q, %d args)",
+				tt.query, where, len(args), tt.wantWhere, tt.wantArgs)
+		}
+	}
+}
+
+func TestValidateGuildDefaults(t *testing.T) {
+	perms := func(p uint64) *int64 { v := int64(p); return &v }
+	color := "#12ab34"
+	badColor := "red"
+	tests := []struct {
+		name  string
+		d     models.GuildDefaults
+		valid bool
+	}{
+		{"empty keeps built-ins", models.GuildDefaults{}, true},
+		{"full template", models.GuildDefaults{
+			DefaultPermissions: perms(permissions.ViewChannel | permissions.SendMessages),
+			Channels:           []models.GuildDefaultChannel{{Name: "welcome", ChannelType: "announcement"}, {Name: "Lounge", ChannelType: "voice"}},
+			Roles:              []models.GuildDefaultRole{{Name: "Moderator", Color: &color, PermissionsAllow: int64(permissions.KickMembers)}},
+		}, true},
+		{"administrator for everyone", models.GuildDefaults{DefaultPermissions: perms(permissions.Administrator)}, false},
+		{"unknown bits", models.GuildDefaults{DefaultPermissions: perms(1 << 50)}, false},
+		{"empty channel list", models.GuildDefaults{Channels: []models.GuildDefaultChannel{}}, false},
+		{"bad channel type", models.GuildDefaults{Channels: []models.GuildDefaultChannel{{Name: "x", ChannelType: "dm"}}}, false},
+		{"unnamed channel", models.GuildDefaults{Channels: []models.GuildDefaultChannel{{ChannelType: "text"}}}, false},
+		{"everyone role", models.GuildDefaults{Roles: []models.GuildDefaultRole{{Name: "@everyone"}}}, false},
+		{"bad role color", models.GuildDefaults{Roles: []models.GuildDefaultRole{{Name: "Member", Color: &badColor}}}, false},
+		{"followed channels", models.GuildDefaults{FollowChannelIDs: []string{"news", "status"}}, true},
+		{"duplicate followed channel", models.GuildDefaults{FollowChannelIDs: []string{"news", "news"}}, false},
+		{"too many followed channels", models.GuildDefaults{FollowChannelIDs: make([]string, maxGuildDefaultFollows+1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := validateGuildDefaults(tt.d)
+			if (msg == "") != tt.valid {
+				t.Errorf("validateGuildDefaults() = %q, want valid=%v", msg, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateBranding(t *testing.T) {
+	tests := []struct {
+		name  string
+		b     models.InstanceBranding
+		valid bool
+	}{
+		{"empty keeps client defaults", models.InstanceBranding{}, true},
+		{"full branding", models.InstanceBranding{
+			ProductName:  "Acme Chat",
+			LogoURL:      "https://cdn.example.com/logo.svg",
+			IconURL:      "/static/icon.png",
+			Colors:       models.BrandingColors{Primary: "#112233", Accent: "#abcdef"},
+			LoginTitle:   "Welcome to Acme",
+			LoginMessage: "Sign in with your company account.",
+			Links:        []models.BrandingLink{{Label: "Terms", URL: "https://example.com/terms"}},
+		}, true},
+		{"javascript logo", models.InstanceBranding{LogoURL: "javascript:alert(1)"}, false},
+		{"protocol-relative logo", models.InstanceBranding{LogoURL: "//evil.example/logo.png"}, false},
+		{"named color", models.InstanceBranding{Colors: models.BrandingColors{Primary: "blue"}}, false},
+		{"unlabeled link", models.InstanceBranding{Links: []models.BrandingLink{{URL: "https://example.com"}}}, false},
+		{"data link", models.InstanceBranding{Links: []models.BrandingLink{{Label: "x", URL: "data:text/html,hi"}}}, false},
+		{"long login message", models.InstanceBranding{LoginMessage: strings.Repeat("a", maxLoginMessageLength+1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := validateBranding(tt.b)
+			if (msg == "") != tt.valid {
+				t.Errorf("validateBranding() = %q, want valid=%v", msg, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateMaintenanceWindow(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	window := func(title string, start, end time.Duration) models.MaintenanceWindow {
+		return models.MaintenanceWindow{Title: title, StartsAt: now.Add(start), EndsAt: now.Add(end)}
+	}
+	tests := []struct {
+		name  string
+		mw    models.MaintenanceWindow
+		valid bool
+	}{
+		{"starts now", window("Database upgrade", 0, time.Hour), true},
+		{"scheduled", window("Database upgrade", 24*time.Hour, 26*time.Hour), true},
+		{"already started", window("Database upgrade", -time.Hour, time.Hour), true},
+		{"untitled", window("", 0, time.Hour), false},
+		{"ends before start", window("x", time.Hour, 30*time.Minute), false},
+		{"already over", window("x", -2*time.Hour, -time.Hour), false},
+		{"too long", window("x", 0, 8*24*time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := validateMaintenanceWindow(tt.mw, now)
+			if (msg == "") != tt.valid {
+				t.Errorf("validateMaintenanceWindow() = %q, want valid=%v", msg, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateBroadcastLimits(t *testing.T) {
+	ok := models.ParseBroadcastLimits("")
+	if msg := validateBroadcastLimits(ok); msg != "" {
+		t.Fatalf("default limits rejected: %s", msg)
+	}
+	tests := []struct {
+		name   string
+		modify func(*models.BroadcastLimits)
+	}{
+		{"no broadcasts per day", func(l *models.BroadcastLimits) { l.PerGuildPerDay = 0 }},
+		{"too many per day", func(l *models.BroadcastLimits) { l.PerGuildPerDay = maxBroadcastsPerDay + 1 }},
+		{"no recipients", func(l *models.BroadcastLimits) { l.MaxRecipients = 0 }},
+		{"no delivery rate", func(l *models.BroadcastLimits) { l.DeliveriesPerMinute = 0 }},
+		{"delivery rate too high", func(l *models.BroadcastLimits) { l.DeliveriesPerMinute = maxBroadcastDeliveriesPerMin + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := ok
+			tt.modify(&l)
+			if validateBroadcastLimits(l) == "" {
+				t.Errorf("validateBroadcastLimits(%+v) accepted invalid limits", l)
+			}
+		})
+	}
+}
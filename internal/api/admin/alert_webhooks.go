@@ -0,0 +1,296 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/opsalerts"
+)
+
+// maxAlertWebhooks bounds how many alert webhooks an instance may configure.
+const maxAlertWebhooks = 10
+
+const alertWebhookColumns = `id, name, url, format, min_severity, kinds, signing_secret, enabled,
+	created_by, created_at, last_delivered_at, last_status, last_error`
+
+type alertWebhookRequest struct {
+	Name         *string   `json:"name"`
+	URL          *string   `json:"url"`
+	Format       *string   `json:"format"`
+	MinSeverity  *string   `json:"min_severity"`
+	Kinds        *[]string `json:"kinds"`
+	Enabled      *bool     `json:"enabled"`
+	RotateSecret bool      `json:"rotate_secret"`
+}
+
+// validate checks the fields that are set and writes an error for the first
+// bad one.
+func (req *alertWebhookRequest) validate(w http.ResponseWriter) bool {
+	if req.Name != nil {
+		*req.Name = strings.TrimSpace(*req.Name)
+		if *req.Name == "" || utf8.RuneCountInString(*req.Name) > 100 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "name must be 1-100 characters")
+			return false
+		}
+	}
+	if req.URL != nil {
+		if u, err := url.Parse(*req.URL); err != nil || u.Scheme != "https" || u.Host == "" || len(*req.URL) > 2048 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_url", "url must be an https URL")
+			return false
+		}
+	}
+	if req.Format != nil && !opsalerts.ValidFormat(*req.Format) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_format", "format must be json, slack or matrix")
+		return false
+	}
+	if req.MinSeverity != nil && !opsalerts.ValidSeverity(*req.MinSeverity) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_severity", "min_severity must be info, warning or critical")
+		return false
+	}
+	if req.Kinds != nil {
+		for _, k := range *req.Kinds {
+			if !slices.Contains(opsalerts.Kinds, k) {
+				apiutil.WriteError(w, http.StatusBadRequest, "invalid_kind", "Unknown alert kind: "+k)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func scanAlertWebhook(row pgx.Row) (models.OperatorAlertWebhook, error) {
+	var h models.OperatorAlertWebhook
+	err := row.Scan(&h.ID, &h.Name, &h.URL, &h.Format, &h.MinSeverity, &h.Kinds, &h.SigningSecret, &h.Enabled,
+		&h.CreatedBy, &h.CreatedAt, &h.LastDeliveredAt, &h.LastStatus, &h.LastError)
+	return h, err
+}
+
+// HandleListAlertWebhooks lists the webhooks that receive operational alerts.
+// Signing secrets are only returned when created or rotated.
+// GET /api/v1/admin/alert-webhooks
+func (h *Handler) HandleListAlertWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+alertWebhookColumns+` FROM operator_alert_webhooks ORDER BY created_at`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list alert webhooks", err)
+		return
+	}
+	hooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OperatorAlertWebhook, error) {
+		hook, err := scanAlertWebhook(row)
+		hook.SigningSecret = ""
+		return hook, err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read alert webhooks", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, hooks)
+}
+
+// HandleCreateAlertWebhook adds a webhook for operational alerts. It
+// defaults to the JSON format and warning severity, for every kind.
+// POST /api/v1/admin/alert-webhooks
+func (h *Handler) HandleCreateAlertWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req alertWebhookRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name == nil || req.URL == nil {
+		apiutil.WriteCode(w, apierrors.MissingFields, "name and url are required")
+		return
+	}
+	if !req.validate(w) {
+		return
+	}
+	format, severity, kinds, enabled := opsalerts.FormatJSON, string(opsalerts.Warning), []string{}, true
+	if req.Format != nil {
+		format = *req.Format
+	}
+	if req.MinSeverity != nil {
+		severity = *req.MinSeverity
+	}
+	if req.Kinds != nil {
+		kinds = *req.Kinds
+	}
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	var count int
+	if err := h.Pool.QueryRow(r.Context(), `SELECT COUNT(*) FROM operator_alert_webhooks`).Scan(&count); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to count alert webhooks", err)
+		return
+	}
+	if count >= maxAlertWebhooks {
+		apiutil.WriteError(w, http.StatusBadRequest, "max_alert_webhooks", "An instance can have at most 10 alert webhooks")
+		return
+	}
+
+	secret, err := opsalerts.NewSigningSecret()
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to generate signing secret", err)
+		return
+	}
+	hook, err := scanAlertWebhook(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO operator_alert_webhooks (id, name, url, format, min_severity, kinds, signing_secret, enabled, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())
+		 RETURNING `+alertWebhookColumns,
+		models.NewULID().String(), *req.Name, *req.URL, format, severity, kinds, secret, enabled,
+		auth.UserIDFromContext(r.Context())))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create alert webhook", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionAlertWebhookCreate, "alert_webhook", hook.ID, nil, redactAlertWebhook(hook), nil)
+	apiutil.WriteJSON(w, http.StatusCreated, hook)
+}
+
+// HandleUpdateAlertWebhook changes an alert webhook. Omitted fields keep
+// their value; rotate_secret issues a new signing secret, returned once.
+// PATCH /api/v1/admin/alert-webhooks/{webhookID}
+func (h *Handler) HandleUpdateAlertWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	webhookID := chi.URLParam(r, "webhookID")
+
+	var req alertWebhookRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if !req.validate(w) {
+		return
+	}
+	var secret *string
+	if req.RotateSecret {
+		s, err := opsalerts.NewSigningSecret()
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to generate signing secret", err)
+			return
+		}
+		secret = &s
+	}
+
+	var before, after models.OperatorAlertWebhook
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		var err error
+		before, err = scanAlertWebhook(tx.QueryRow(r.Context(),
+			`SELECT `+alertWebhookColumns+` FROM operator_alert_webhooks WHERE id = $1 FOR UPDATE`, webhookID))
+		if err != nil {
+			return err
+		}
+		after, err = scanAlertWebhook(tx.QueryRow(r.Context(),
+			`UPDATE operator_alert_webhooks SET
+			     name = COALESCE($2, name),
+			     url = COALESCE($3, url),
+			     format = COALESCE($4, format),
+			     min_severity = COALESCE($5, min_severity),
+			     kinds = COALESCE($6, kinds),
+			     enabled = COALESCE($7, enabled),
+			     signing_secret = COALESCE($8, signing_secret)
+			 WHERE id = $1
+			 RETURNING `+alertWebhookColumns,
+			webhookID, req.Name, req.URL, req.Format, req.MinSeverity, req.Kinds, req.Enabled, secret))
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		apiutil.WriteError(w, http.StatusNotFound, "alert_webhook_not_found", "Alert webhook not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update alert webhook", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionAlertWebhookUpdate, "alert_webhook", webhookID,
+		redactAlertWebhook(before), redactAlertWebhook(after), nil)
+	if !req.RotateSecret {
+		after.SigningSecret = ""
+	}
+	apiutil.WriteJSON(w, http.StatusOK, after)
+}
+
+// HandleDeleteAlertWebhook removes an alert webhook.
+// DELETE /api/v1/admin/alert-webhooks/{webhookID}
+func (h *Handler) HandleDeleteAlertWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	webhookID := chi.URLParam(r, "webhookID")
+
+	hook, err := scanAlertWebhook(h.Pool.QueryRow(r.Context(),
+		`DELETE FROM operator_alert_webhooks WHERE id = $1 RETURNING `+alertWebhookColumns, webhookID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		apiutil.WriteError(w, http.StatusNotFound, "alert_webhook_not_found", "Alert webhook not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete alert webhook", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionAlertWebhookDelete, "alert_webhook", webhookID, redactAlertWebhook(hook), nil, nil)
+	apiutil.WriteNoContent(w)
+}
+
+// HandleTestAlertWebhook sends a test alert to a webhook, whatever its
+// filters and whether or not it is enabled, and returns the outcome.
+// POST /api/v1/admin/alert-webhooks/{webhookID}/test
+func (h *Handler) HandleTestAlertWebhook(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	hook, err := scanAlertWebhook(h.Pool.QueryRow(r.Context(),
+		`SELECT `+alertWebhookColumns+` FROM operator_alert_webhooks WHERE id = $1`, chi.URLParam(r, "webhookID")))
+	if errors.Is(err, pgx.ErrNoRows) {
+		apiutil.WriteError(w, http.StatusNotFound, "alert_webhook_not_found", "Alert webhook not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load alert webhook", err)
+		return
+	}
+
+	d := &opsalerts.Dispatcher{Pool: h.Pool, InstanceID: h.InstanceID, Logger: h.Logger}
+	res := d.Deliver(r.Context(), hook, opsalerts.Alert{
+		Kind:     opsalerts.KindTest,
+		Severity: opsalerts.Info,
+		Summary:  "Test alert sent from the admin panel",
+		Details:  map[string]interface{}{"webhook": hook.Name},
+	})
+	apiutil.WriteJSON(w, http.StatusOK, res)
+}
+
+// redactAlertWebhook drops the signing secret before a webhook is recorded
+// in the instance audit log.
+func redactAlertWebhook(hook models.OperatorAlertWebhook) models.OperatorAlertWebhook {
+	hook.SigningSecret = ""
+	return hook
+}
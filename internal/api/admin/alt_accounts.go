@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// correlatedAccount is another account sharing at least one signal with the
+// user being inspected.
+type correlatedAccount struct {
+	UserID     string    `json:"user_id"`
+	Username   string    `json:"username"`
+	Flags      int       `json:"flags"`
+	CreatedAt  time.Time `json:"created_at"`
+	Shared     []string  `json:"shared_signals"` // Any of "ip", "email", "device".
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// HandleGetCorrelatedAccounts lists accounts that share a hashed IP address,
+// normalized email, or device fingerprint with the given user, most recently
+// seen first.
+// GET /api/v1/admin/users/{userID}/correlated-accounts
+func (h *Handler) HandleGetCorrelatedAccounts(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
+		return
+	}
+	userID := chi.URLParam(r, "userID")
+
+	var exists bool
+	h.Pool.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+	if !exists {
+		apiutil.WriteCode(w, apierrors.NotFound, "User not found")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT u.id, u.username, u.flags, u.created_at,
+		        array_agg(DISTINCT o.kind ORDER BY o.kind), MAX(o.last_seen_at)
+		 FROM account_signals s
+		 JOIN account_signals o ON o.kind = s.kind AND o.value_hash = s.value_hash AND o.user_id <> s.user_id
+		 JOIN users u ON u.id = o.user_id
+		 WHERE s.user_id = $1
+		 GROUP BY u.id, u.username, u.flags, u.created_at
+		 ORDER BY MAX(o.last_seen_at) DESC
+		 LIMIT 100`, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get correlated accounts", err)
+		return
+	}
+	defer rows.Close()
+
+	accounts := make([]correlatedAccount, 0)
+	for rows.Next() {
+		var a correlatedAccount
+		if err := rows.Scan(&a.UserID, &a.Username, &a.Flags, &a.CreatedAt, &a.Shared, &a.LastSeenAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read correlated accounts", err)
+			return
+		}
+		accounts = append(accounts, a)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, accounts)
+}
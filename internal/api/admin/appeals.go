@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// HandleListSuspensionAppeals lists suspension appeals, pending ones by
+// default. Pass ?status=accepted, rejected, or all to see reviewed appeals.
+// GET /api/v1/admin/suspension-appeals
+func (h *Handler) HandleListSuspensionAppeals(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.AppealStatusPending
+	}
+	if !apiutil.ValidateEnum(w, "status", status, []string{
+		models.AppealStatusPending, models.AppealStatusAccepted, models.AppealStatusRejected, "all",
+	}) {
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT a.id, a.user_id, a.message, a.status, a.response, a.reviewed_by, a.reviewed_at, a.created_at,
+		        u.username, u.suspension_reason, u.suspended_until
+		 FROM suspension_appeals a
+		 JOIN users u ON u.id = a.user_id
+		 WHERE $1 = 'all' OR a.status = $1
+		 ORDER BY a.created_at
+		 LIMIT 200`, status)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list suspension appeals", err)
+		return
+	}
+	defer rows.Close()
+
+	type appealEntry struct {
+		models.SuspensionAppeal
+		Username         string     `json:"username"`
+		SuspensionReason *string    `json:"suspension_reason,omitempty"`
+		SuspendedUntil   *time.Time `json:"suspended_until,omitempty"`
+	}
+
+	appeals := make([]appealEntry, 0)
+	for rows.Next() {
+		var a appealEntry
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Message, &a.Status, &a.Response,
+			&a.ReviewedBy, &a.ReviewedAt, &a.CreatedAt,
+			&a.Username, &a.SuspensionReason, &a.SuspendedUntil); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read suspension appeals", err)
+			return
+		}
+		appeals = append(appeals, a)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, appeals)
+}
+
+// HandleResolveSuspensionAppeal accepts or rejects a pending appeal. Accepting
+// lifts the user's suspension, including an instance ban.
+// PATCH /api/v1/admin/suspension-appeals/{appealID}
+func (h *Handler) HandleResolveSuspensionAppeal(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageUsers) {
+		return
+	}
+	appealID := chi.URLParam(r, "appealID")
+	reviewerID := auth.UserIDFromContext(r.Context())
+
+	var req struct {
+		Status   string  `json:"status"`
+		Response *string `json:"response"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if !apiutil.ValidateEnum(w, "status", req.Status, []string{models.AppealStatusAccepted, models.AppealStatusRejected}) {
+		return
+	}
+	if req.Response != nil && !apiutil.ValidateStringLength(w, "response", *req.Response, 0, 2000) {
+		return
+	}
+
+	var appeal models.SuspensionAppeal
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(r.Context(),
+			`UPDATE suspension_appeals
+			 SET status = $2, response = $3, reviewed_by = $4, reviewed_at = now()
+			 WHERE id = $1 AND status = 'pending'
+			 RETURNING id, user_id, message, status, response, reviewed_by, reviewed_at, created_at`,
+			appealID, req.Status, req.Response, reviewerID).Scan(
+			&appeal.ID, &appeal.UserID, &appeal.Message, &appeal.Status, &appeal.Response,
+			&appeal.ReviewedBy, &appeal.ReviewedAt, &appeal.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if req.Status != models.AppealStatusAccepted {
+			return nil
+		}
+		_, err = tx.Exec(r.Context(),
+			`UPDATE users SET flags = flags & $1, suspended_until = NULL, suspension_reason = NULL
+			 WHERE id = $2`, ^models.UserFlagSuspended, appeal.UserID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(r.Context(), `DELETE FROM instance_bans WHERE user_id = $1`, appeal.UserID)
+		return err
+	})
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "appeal_not_found", "No pending appeal found with that ID")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to resolve suspension appeal", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionAppealResolve, "user", appeal.UserID,
+		map[string]string{"appeal_id": appeal.ID, "status": models.AppealStatusPending},
+		map[string]string{"appeal_id": appeal.ID, "status": appeal.Status}, req.Response)
+
+	apiutil.WriteJSON(w, http.StatusOK, appeal)
+}
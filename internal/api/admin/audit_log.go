@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// auditLogFilter builds the WHERE clause for instance audit log queries from
+// the request's query parameters. Unset filters are omitted.
+func auditLogFilter(r *http.Request) (string, []interface{}) {
+	q := r.URL.Query()
+	var conds []string
+	var args []interface{}
+	for _, f := range []struct{ param, column string }{
+		{"action", "action"},
+		{"actor_id", "actor_id"},
+		{"target_type", "target_type"},
+		{"target_id", "target_id"},
+	} {
+		if v := q.Get(f.param); v != "" {
+			args = append(args, v)
+			conds = append(conds, fmt.Sprintf("%s = $%d", f.column, len(args)))
+		}
+	}
+	// "before" is an entry ID cursor; IDs are ULIDs and sort by time.
+	if v := q.Get("before"); v != "" {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf("id < $%d", len(args)))
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// HandleGetInstanceAuditLog returns instance staff actions, newest first.
+// Supports filtering by action, actor_id, target_type, and target_id, and
+// paging with a before cursor or limit/offset.
+// GET /api/v1/admin/audit-log
+func (h *Handler) HandleGetInstanceAuditLog(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	limit := 50
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if v, err := parseInt(l); err == nil && v > 0 && v <= 200 {
+			limit = v
+		}
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if v, err := parseInt(o); err == nil && v >= 0 {
+			offset = v
+		}
+	}
+
+	where, args := auditLogFilter(r)
+	args = append(args, limit, offset)
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT id, actor_id, action, target_type, target_id, before_value, after_value, reason, created_at
+		 FROM instance_audit_log`+where+
+			fmt.Sprintf(` ORDER BY id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args)),
+		args...)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get instance audit log", err)
+		return
+	}
+	defer rows.Close()
+
+	entries := make([]models.InstanceAuditEntry, 0)
+	for rows.Next() {
+		var e models.InstanceAuditEntry
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID,
+			&e.Before, &e.After, &e.Reason, &e.CreatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read instance audit log", err)
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,236 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/automod"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// instanceRuleTypes are the rule types the instance policy accepts. Content
+// warning rules name guild channels, so they stay guild-only.
+var instanceRuleTypes = map[string]bool{
+	automod.RuleWordFilter: true, automod.RuleRegexFilter: true, automod.RuleInviteFilter: true,
+	automod.RuleMentionSpam: true, automod.RuleCapsFilter: true, automod.RuleSpamFilter: true,
+	automod.RuleLinkFilter: true, automod.RuleEmojiHash: true, automod.RuleAttachmentHash: true,
+	automod.RuleNameFilter: true,
+}
+
+var instanceRuleActions = map[string]bool{
+	automod.ActionDelete: true, automod.ActionWarn: true, automod.ActionTimeout: true,
+	automod.ActionLog: true, automod.ActionQuarantine: true,
+}
+
+const instanceRuleColumns = `id, name, enabled, rule_type, config, action,
+	timeout_duration_seconds, COALESCE(created_by, ''), created_at, updated_at`
+
+func scanInstanceRule(row pgx.Row) (automod.Rule, error) {
+	rule := automod.Rule{Instance: true}
+	var configJSON []byte
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Enabled, &rule.RuleType, &configJSON,
+		&rule.Action, &rule.TimeoutDurationSeconds, &rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return rule, err
+	}
+	return rule, json.Unmarshal(configJSON, &rule.Config)
+}
+
+// validInstanceRuleAction reports whether action suits the rule type. Emoji
+// uploads can only be rejected or flagged, and names renamed, warned about
+// or flagged.
+func validInstanceRuleAction(ruleType, action string) bool {
+	switch ruleType {
+	case automod.RuleEmojiHash:
+		return action == automod.ActionDelete || action == automod.ActionLog
+	case automod.RuleNameFilter:
+		return action == automod.ActionDelete || action == automod.ActionWarn || action == automod.ActionLog
+	}
+	return instanceRuleActions[action]
+}
+
+// HandleListInstanceAutomodRules lists the instance AutoMod policy, the
+// rules every guild is held to beneath its own.
+// GET /api/v1/admin/automod/rules
+func (h *Handler) HandleListInstanceAutomodRules(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+instanceRuleColumns+` FROM instance_automod_rules ORDER BY created_at ASC`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list AutoMod rules", err)
+		return
+	}
+	rules, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (automod.Rule, error) {
+		return scanInstanceRule(row)
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read AutoMod rules", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, rules)
+}
+
+// HandleCreateInstanceAutomodRule adds a rule to the instance policy. It is
+// evaluated in every guild ahead of the guild's own rules.
+// POST /api/v1/admin/automod/rules
+func (h *Handler) HandleCreateInstanceAutomodRule(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		Name                   string             `json:"name"`
+		RuleType               string             `json:"rule_type"`
+		Config                 automod.RuleConfig `json:"config"`
+		Action                 string             `json:"action"`
+		Enabled                *bool              `json:"enabled"`
+		TimeoutDurationSeconds *int               `json:"timeout_duration_seconds"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name == "" || req.RuleType == "" {
+		apiutil.WriteCode(w, apierrors.MissingFields, "name and rule_type are required")
+		return
+	}
+	if !instanceRuleTypes[req.RuleType] {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_type", "Invalid rule_type")
+		return
+	}
+	if req.Action == "" {
+		req.Action = automod.ActionDelete
+	}
+	if !validInstanceRuleAction(req.RuleType, req.Action) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_action", "Invalid action for this rule_type")
+		return
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	timeout := 60
+	if req.TimeoutDurationSeconds != nil {
+		timeout = *req.TimeoutDurationSeconds
+	}
+	configJSON, _ := json.Marshal(req.Config)
+
+	rule, err := scanInstanceRule(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO instance_automod_rules (id, name, enabled, rule_type, config, action,
+		     timeout_duration_seconds, created_by, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now(), now())
+		 RETURNING `+instanceRuleColumns,
+		models.NewULID().String(), req.Name, enabled, req.RuleType, configJSON, req.Action,
+		timeout, auth.UserIDFromContext(r.Context())))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create AutoMod rule", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionAutomodRuleCreate, "instance_automod_rule", rule.ID, nil, rule, nil)
+	apiutil.WriteJSON(w, http.StatusCreated, rule)
+}
+
+// HandleUpdateInstanceAutomodRule changes a rule of the instance policy.
+// Omitted fields are left as they are; the rule type cannot change.
+// PATCH /api/v1/admin/automod/rules/{ruleID}
+func (h *Handler) HandleUpdateInstanceAutomodRule(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	ruleID := chi.URLParam(r, "ruleID")
+
+	var req struct {
+		Name                   *string             `json:"name"`
+		Enabled                *bool               `json:"enabled"`
+		Config                 *automod.RuleConfig `json:"config"`
+		Action                 *string             `json:"action"`
+		TimeoutDurationSeconds *int                `json:"timeout_duration_seconds"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name != nil && *req.Name == "" {
+		apiutil.WriteCode(w, apierrors.MissingFields, "name cannot be empty")
+		return
+	}
+
+	before, err := scanInstanceRule(h.Pool.QueryRow(r.Context(),
+		`SELECT `+instanceRuleColumns+` FROM instance_automod_rules WHERE id = $1`, ruleID))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "rule_not_found", "AutoMod rule not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get AutoMod rule", err)
+		return
+	}
+	if req.Action != nil && !validInstanceRuleAction(before.RuleType, *req.Action) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_action", "Invalid action for this rule_type")
+		return
+	}
+	var configJSON []byte
+	if req.Config != nil {
+		configJSON, _ = json.Marshal(req.Config)
+	}
+
+	rule, err := scanInstanceRule(h.Pool.QueryRow(r.Context(),
+		`UPDATE instance_automod_rules SET
+		     name = COALESCE($2, name),
+		     enabled = COALESCE($3, enabled),
+		     config = COALESCE($4, config),
+		     action = COALESCE($5, action),
+		     timeout_duration_seconds = COALESCE($6, timeout_duration_seconds),
+		     updated_at = now()
+		 WHERE id = $1
+		 RETURNING `+instanceRuleColumns,
+		ruleID, req.Name, req.Enabled, configJSON, req.Action, req.TimeoutDurationSeconds))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "rule_not_found", "AutoMod rule not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update AutoMod rule", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionAutomodRuleUpdate, "instance_automod_rule", rule.ID, before, rule, nil)
+	apiutil.WriteJSON(w, http.StatusOK, rule)
+}
+
+// HandleDeleteInstanceAutomodRule removes a rule from the instance policy,
+// along with the guild action log entries it produced.
+// DELETE /api/v1/admin/automod/rules/{ruleID}
+func (h *Handler) HandleDeleteInstanceAutomodRule(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	ruleID := chi.URLParam(r, "ruleID")
+
+	before, err := scanInstanceRule(h.Pool.QueryRow(r.Context(),
+		`DELETE FROM instance_automod_rules WHERE id = $1 RETURNING `+instanceRuleColumns, ruleID))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "rule_not_found", "AutoMod rule not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete AutoMod rule", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionAutomodRuleDelete, "instance_automod_rule", ruleID, before, nil, nil)
+	apiutil.WriteNoContent(w)
+}
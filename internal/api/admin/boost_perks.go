@@ -0,0 +1,364 @@
+package admin
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// HandleListBoostTiers handles GET /api/v1/admin/boost-tiers.
+func (h *Handler) HandleListBoostTiers(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT boost_tier, max_upload_bytes, max_bitrate, updated_at
+		 FROM boost_tier_perks ORDER BY boost_tier`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list boost tiers", err)
+		return
+	}
+	defer rows.Close()
+
+	tiers := []models.BoostTierPerks{}
+	for rows.Next() {
+		var t models.BoostTierPerks
+		if err := rows.Scan(&t.BoostTier, &t.MaxUploadBytes, &t.MaxBitrate, &t.UpdatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read boost tiers", err)
+			return
+		}
+		tiers = append(tiers, t)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, tiers)
+}
+
+// HandleSetBoostTier creates or replaces the perks of a boost tier. A null
+// limit leaves the instance maximum in place for that tier.
+// PUT /api/v1/admin/boost-tiers/{tier}
+func (h *Handler) HandleSetBoostTier(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	boostTier, err := strconv.Atoi(chi.URLParam(r, "tier"))
+	if err != nil || boostTier < 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_tier", "tier must be a non-negative integer")
+		return
+	}
+
+	var req struct {
+		MaxUploadBytes *int64 `json:"max_upload_bytes"`
+		MaxBitrate     *int   `json:"max_bitrate"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if (req.MaxUploadBytes != nil && *req.MaxUploadBytes <= 0) || (req.MaxBitrate != nil && *req.MaxBitrate <= 0) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_limit", "Limits must be positive")
+		return
+	}
+
+	t := models.BoostTierPerks{BoostTier: boostTier}
+	err = h.Pool.QueryRow(r.Context(),
+		`INSERT INTO boost_tier_perks (boost_tier, max_upload_bytes, max_bitrate, updated_at)
+		 VALUES ($1, $2, $3, now())
+		 ON CONFLICT (boost_tier) DO UPDATE SET
+		     max_upload_bytes = EXCLUDED.max_upload_bytes, max_bitrate = EXCLUDED.max_bitrate, updated_at = now()
+		 RETURNING max_upload_bytes, max_bitrate, updated_at`,
+		boostTier, req.MaxUploadBytes, req.MaxBitrate,
+	).Scan(&t.MaxUploadBytes, &t.MaxBitrate, &t.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to set boost tier", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionBoostTierUpdate, "boost_tier", strconv.Itoa(boostTier), nil, t, nil)
+	apiutil.WriteJSON(w, http.StatusOK, t)
+}
+
+// HandleDeleteBoostTier removes a boost tier's perks; guilds at that tier fall
+// back to the next lower one. Tier 0 is the floor and cannot be removed.
+// DELETE /api/v1/admin/boost-tiers/{tier}
+func (h *Handler) HandleDeleteBoostTier(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	boostTier, err := strconv.Atoi(chi.URLParam(r, "tier"))
+	if err != nil || boostTier < 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_tier", "tier must be a non-negative integer")
+		return
+	}
+	if boostTier == 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "base_tier", "Tier 0 holds the default perks and cannot be removed")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(), `DELETE FROM boost_tier_perks WHERE boost_tier = $1`, boostTier)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete boost tier", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "tier_not_found", "No perks for that tier")
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionBoostTierDelete, "boost_tier", strconv.Itoa(boostTier), nil, nil, nil)
+	apiutil.WriteNoContent(w)
+}
+
+// HandleGetBoostSettings handles GET /api/v1/admin/boost-settings.
+func (h *Handler) HandleGetBoostSettings(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, map[string]bool{
+		"require_supporter": apiutil.BoostsRequireSupporter(r.Context(), h.Pool),
+	})
+}
+
+// HandleUpdateBoostSettings turns the supporter requirement for boosting on or
+// off. Boosts that already exist are kept when it is turned on; they are only
+// trimmed once the booster's entitlement changes.
+// PATCH /api/v1/admin/boost-settings
+func (h *Handler) HandleUpdateBoostSettings(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		RequireSupporter bool `json:"require_supporter"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_settings (key, value, updated_at) VALUES ('boosts_require_supporter', $1, now())
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+		strconv.FormatBool(req.RequireSupporter)); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update boost settings", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", "boosts_require_supporter", nil, req, nil)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]bool{"require_supporter": req.RequireSupporter})
+}
+
+const supporterColumns = `e.user_id, e.boost_slots,
+	(SELECT COUNT(*) FROM guild_boosts b WHERE b.user_id = e.user_id AND b.active = true),
+	e.source, e.expires_at, e.granted_by, e.note, e.external_id, e.admin_override, e.created_at, e.updated_at`
+
+func scanSupporter(row pgx.Row) (models.SupporterEntitlement, error) {
+	var s models.SupporterEntitlement
+	err := row.Scan(&s.UserID, &s.BoostSlots, &s.SlotsUsed, &s.Source, &s.ExpiresAt,
+		&s.GrantedBy, &s.Note, &s.ExternalID, &s.AdminOverride, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+// HandleListSupporters lists supporter entitlements, newest first. Pass
+// ?source= to only list grants from one source.
+// GET /api/v1/admin/supporters
+func (h *Handler) HandleListSupporters(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var source *string
+	if v := r.URL.Query().Get("source"); v != "" {
+		source = &v
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+supporterColumns+` FROM supporter_entitlements e
+		 WHERE $1::text IS NULL OR e.source = $1
+		 ORDER BY e.updated_at DESC`, source)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list supporters", err)
+		return
+	}
+	defer rows.Close()
+
+	supporters := []models.SupporterEntitlement{}
+	for rows.Next() {
+		s, err := scanSupporter(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read supporters", err)
+			return
+		}
+		supporters = append(supporters, s)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, supporters)
+}
+
+// HandleSetSupporter grants or changes a user's supporter entitlement by
+// hand. If the user now has fewer slots than boosts, their latest boosts end.
+// Set override to stop payment webhooks and reconciliation from changing the
+// entitlement afterwards; leaving it out keeps the current setting.
+// PUT /api/v1/admin/supporters/{userID}
+func (h *Handler) HandleSetSupporter(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	targetID := chi.URLParam(r, "userID")
+
+	var req struct {
+		BoostSlots int        `json:"boost_slots"`
+		ExpiresAt  *time.Time `json:"expires_at"`
+		Note       *string    `json:"note"`
+		Override   *bool      `json:"override"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.BoostSlots < 0 || req.BoostSlots > 100 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_slots", "boost_slots must be between 0 and 100")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_expiry", "expires_at must be in the future")
+		return
+	}
+
+	var exists bool
+	h.Pool.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, targetID).Scan(&exists)
+	if !exists {
+		apiutil.WriteCode(w, apierrors.UserNotFound, "")
+		return
+	}
+
+	_, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO supporter_entitlements (user_id, boost_slots, source, expires_at, granted_by, note,
+		                                     admin_override, created_at, updated_at)
+		 VALUES ($1, $2, 'manual', $3, $4, $5, COALESCE($6, false), now(), now())
+		 ON CONFLICT (user_id) DO UPDATE SET
+		     boost_slots = EXCLUDED.boost_slots, source = 'manual', expires_at = EXCLUDED.expires_at,
+		     granted_by = EXCLUDED.granted_by, note = EXCLUDED.note, external_id = NULL,
+		     admin_override = COALESCE($6, supporter_entitlements.admin_override), updated_at = now()`,
+		targetID, req.BoostSlots, req.ExpiresAt, auth.UserIDFromContext(r.Context()), req.Note, req.Override)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to set supporter", err)
+		return
+	}
+	h.syncSupporter(r, targetID)
+
+	s, err := scanSupporter(h.Pool.QueryRow(r.Context(),
+		`SELECT `+supporterColumns+` FROM supporter_entitlements e WHERE e.user_id = $1`, targetID))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load supporter", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionSupporterGrant, "user", targetID, nil, s, req.Note)
+	apiutil.WriteJSON(w, http.StatusOK, s)
+}
+
+// HandleRevokeSupporter removes a user's supporter entitlement, whatever its
+// source. While the instance requires supporters, the user's boosts end.
+// DELETE /api/v1/admin/supporters/{userID}
+func (h *Handler) HandleRevokeSupporter(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	targetID := chi.URLParam(r, "userID")
+
+	tag, err := h.Pool.Exec(r.Context(), `DELETE FROM supporter_entitlements WHERE user_id = $1`, targetID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to revoke supporter", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "not_supporter", "User is not a supporter")
+		return
+	}
+	h.syncSupporter(r, targetID)
+
+	h.logStaffAction(r, models.StaffActionSupporterRevoke, "user", targetID, nil, nil, nil)
+	apiutil.WriteNoContent(w)
+}
+
+// syncSupporter updates a user's supporter flag after their entitlement
+// changed, ends the boosts they no longer have slots for and tells the
+// affected guilds.
+func (h *Handler) syncSupporter(r *http.Request, userID string) {
+	if err := apiutil.SyncSupporterFlag(r.Context(), h.Pool, userID); err != nil {
+		h.Logger.Error("failed to sync supporter flag",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+	guildIDs, err := apiutil.TrimSupporterBoosts(r.Context(), h.Pool, userID)
+	if err != nil {
+		h.Logger.Error("failed to trim supporter boosts",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+	if h.EventBus == nil {
+		return
+	}
+	for _, guildID := range guildIDs {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildUpdate, "GUILD_UPDATE", guildID, map[string]interface{}{
+			"id":      guildID,
+			"boosted": false,
+			"booster": userID,
+		})
+	}
+}
+
+// HandleListPaymentEvents lists the most recent payment webhook deliveries
+// and what each did. Pass ?provider= to only list one provider's.
+// GET /api/v1/admin/payment-events
+func (h *Handler) HandleListPaymentEvents(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var provider *string
+	if v := r.URL.Query().Get("provider"); v != "" {
+		provider = &v
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT provider, event_id, event_type, user_id, outcome, received_at
+		 FROM payment_events
+		 WHERE $1::text IS NULL OR provider = $1
+		 ORDER BY received_at DESC LIMIT 200`, provider)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list payment events", err)
+		return
+	}
+	defer rows.Close()
+
+	paymentEvents := []models.PaymentEvent{}
+	for rows.Next() {
+		var e models.PaymentEvent
+		if err := rows.Scan(&e.Provider, &e.EventID, &e.EventType, &e.UserID, &e.Outcome, &e.ReceivedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read payment events", err)
+			return
+		}
+		paymentEvents = append(paymentEvents, e)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, paymentEvents)
+}
@@ -0,0 +1,180 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// brandingSetting is the instance_settings key holding the instance's
+// branding as JSON.
+const brandingSetting = "branding"
+
+const (
+	maxBrandingLinks       = 10
+	maxBrandingTextLength  = 100
+	maxLoginMessageLength  = 2000
+	maxBrandingURLLength   = 2048
+	maxBrandingLabelLength = 50
+)
+
+// validBrandingURL reports whether u may be used for a logo or link: an
+// absolute http(s) URL or a path on this instance.
+func validBrandingURL(u string) bool {
+	if len(u) > maxBrandingURLLength {
+		return false
+	}
+	if strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//") {
+		return true
+	}
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "https" || parsed.Scheme == "http") && parsed.Host != ""
+}
+
+// validateBranding checks branding and returns a message safe to return to
+// the caller, or "".
+func validateBranding(b models.InstanceBranding) string {
+	if len(b.ProductName) > maxBrandingTextLength || len(b.LoginTitle) > maxBrandingTextLength {
+		return fmt.Sprintf("product_name and login_title must be at most %d characters", maxBrandingTextLength)
+	}
+	if len(b.LoginMessage) > maxLoginMessageLength {
+		return fmt.Sprintf("login_message must be at most %d characters", maxLoginMessageLength)
+	}
+	for _, u := range []string{b.LogoURL, b.IconURL} {
+		if u != "" && !validBrandingURL(u) {
+			return "logo_url and icon_url must be http(s) URLs or paths on this instance"
+		}
+	}
+	for _, c := range []string{b.Colors.Primary, b.Colors.Accent, b.Colors.Background, b.Colors.Text} {
+		if c != "" && !guildDefaultColorPattern.MatchString(c) {
+			return "Colors must look like #rrggbb"
+		}
+	}
+	if len(b.Links) > maxBrandingLinks {
+		return fmt.Sprintf("At most %d links are allowed", maxBrandingLinks)
+	}
+	for _, l := range b.Links {
+		if l.Label == "" || len(l.Label) > maxBrandingLabelLength {
+			return fmt.Sprintf("Link labels must be 1-%d characters", maxBrandingLabelLength)
+		}
+		if !validBrandingURL(l.URL) {
+			return "Link URLs must be http(s) URLs or paths on this instance"
+		}
+	}
+	return ""
+}
+
+// loadBranding returns the stored branding, or empty branding that leaves
+// every client default in place.
+func (h *Handler) loadBranding(r *http.Request) (models.InstanceBranding, error) {
+	b := models.InstanceBranding{Links: []models.BrandingLink{}}
+	var raw string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = $1`, brandingSetting).Scan(&raw)
+	if err == pgx.ErrNoRows {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	if err := json.Unmarshal([]byte(raw), &b); err != nil {
+		return b, err
+	}
+	if b.Links == nil {
+		b.Links = []models.BrandingLink{}
+	}
+	return b, nil
+}
+
+// HandleGetPublicInstance describes the instance to clients that have not
+// logged in yet, so white-labeled clients can style the login page.
+// GET /api/v1/instance
+func (h *Handler) HandleGetPublicInstance(w http.ResponseWriter, r *http.Request) {
+	inst := models.PublicInstance{MinClientVersion: h.MinClientVersion, SigningKey: h.SigningKey, RegistrationMode: "open"}
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT domain, name, description, COALESCE(software_version, ''),
+		        COALESCE((SELECT value FROM instance_settings WHERE key = 'registration_mode'), 'open'),
+		        COALESCE((SELECT value FROM instance_settings WHERE key = 'registration_message'), ''),
+		        COALESCE((SELECT value FROM instance_settings WHERE key = 'motd'), '')
+		 FROM instances WHERE id = $1`, h.InstanceID).Scan(
+		&inst.Domain, &inst.Name, &inst.Description, &inst.SoftwareVersion,
+		&inst.RegistrationMode, &inst.RegistrationMessage, &inst.MOTD,
+	)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get instance", err)
+		return
+	}
+
+	inst.Branding, err = h.loadBranding(r)
+	if err != nil {
+		// Unstyled is better than no login page.
+		h.Logger.Warn("stored branding is unreadable", slog.String("error", err.Error()))
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	apiutil.WriteJSON(w, http.StatusOK, inst)
+}
+
+// HandleGetBranding returns the instance's branding.
+// GET /api/v1/admin/branding
+func (h *Handler) HandleGetBranding(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	b, err := h.loadBranding(r)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get branding", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, b)
+}
+
+// HandleUpdateBranding replaces the instance's branding. Clients pick it up
+// the next time they load GET /api/v1/instance.
+// PUT /api/v1/admin/branding
+func (h *Handler) HandleUpdateBranding(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req models.InstanceBranding
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Links == nil {
+		req.Links = []models.BrandingLink{}
+	}
+	if msg := validateBranding(req); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_branding", msg)
+		return
+	}
+
+	before, err := h.loadBranding(r)
+	if err != nil {
+		h.Logger.Warn("stored branding is unreadable, replacing it", slog.String("error", err.Error()))
+	}
+
+	value, _ := json.Marshal(req)
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_settings (key, value, updated_at) VALUES ($1, $2, now())
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+		brandingSetting, string(value)); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update branding", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", brandingSetting, before, req, nil)
+	apiutil.WriteJSON(w, http.StatusOK, req)
+}
@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// broadcastLimitsSetting is the instance_settings key holding the guild
+// broadcast caps as JSON.
+const broadcastLimitsSetting = "broadcast_limits"
+
+// Upper bounds on the broadcast caps an admin can set.
+const (
+	maxBroadcastsPerDay          = 24
+	maxBroadcastRecipients       = 100000
+	maxBroadcastDeliveriesPerMin = 1000
+)
+
+// validateBroadcastLimits checks broadcast caps and returns a message safe
+// to return to the caller, or "".
+func validateBroadcastLimits(l models.BroadcastLimits) string {
+	if l.PerGuildPerDay < 1 || l.PerGuildPerDay > maxBroadcastsPerDay {
+		return "per_guild_per_day must be between 1 and 24"
+	}
+	if l.MaxRecipients < 1 || l.MaxRecipients > maxBroadcastRecipients {
+		return "max_recipients must be between 1 and 100000"
+	}
+	if l.DeliveriesPerMinute < 1 || l.DeliveriesPerMinute > maxBroadcastDeliveriesPerMin {
+		return "deliveries_per_minute must be between 1 and 1000"
+	}
+	return ""
+}
+
+// loadBroadcastLimits returns the instance's broadcast caps.
+func (h *Handler) loadBroadcastLimits(r *http.Request) models.BroadcastLimits {
+	var raw string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = $1`, broadcastLimitsSetting).Scan(&raw)
+	return models.ParseBroadcastLimits(raw)
+}
+
+// HandleGetBroadcastLimits handles GET /api/v1/admin/broadcast-limits.
+func (h *Handler) HandleGetBroadcastLimits(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, h.loadBroadcastLimits(r))
+}
+
+// HandleUpdateBroadcastLimits replaces the caps on guild broadcast DMs:
+// whether guilds may send them at all, how many each guild may send a day,
+// how many members one may reach and how fast the worker delivers them
+// across the instance. Lowering the delivery rate slows broadcasts already
+// being sent; the other caps apply to new broadcasts.
+// PUT /api/v1/admin/broadcast-limits
+func (h *Handler) HandleUpdateBroadcastLimits(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req models.BroadcastLimits
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if msg := validateBroadcastLimits(req); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_broadcast_limits", msg)
+		return
+	}
+
+	before := h.loadBroadcastLimits(r)
+	value, _ := json.Marshal(req)
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_settings (key, value, updated_at) VALUES ($1, $2, now())
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+		broadcastLimitsSetting, string(value)); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update broadcast limits", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", broadcastLimitsSetting, before, req, nil)
+	apiutil.WriteJSON(w, http.StatusOK, req)
+}
@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/federation"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// =============================================================================
+// Instance Directory
+// =============================================================================
+
+// HandleGetDirectoryListing reports whether this instance is listed in the
+// federated instance directory.
+// GET /api/v1/admin/federation/directory-listing
+func (h *Handler) HandleGetDirectoryListing(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	var value string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = $1`, federation.DirectoryListedSetting).Scan(&value)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]bool{"listed": value == "true"})
+}
+
+// HandleUpdateDirectoryListing opts the instance in to or out of the
+// directory. A listed instance publishes its name, description, user count,
+// federation mode and largest discoverable guilds at
+// /federation/v1/directory.
+// PUT /api/v1/admin/federation/directory-listing
+func (h *Handler) HandleUpdateDirectoryListing(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	var req struct {
+		Listed *bool `json:"listed"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Listed == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "missing_listed", "listed is required")
+		return
+	}
+
+	var before string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = $1`, federation.DirectoryListedSetting).Scan(&before)
+	value := strconv.FormatBool(*req.Listed)
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_settings (key, value, updated_at) VALUES ($1, $2, now())
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+		federation.DirectoryListedSetting, value); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update directory listing", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", federation.DirectoryListedSetting,
+		map[string]bool{"listed": before == "true"}, map[string]bool{"listed": *req.Listed}, nil)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]bool{"listed": *req.Listed})
+}
+
+// HandleGetDirectory lists the instances in the federated directory, with
+// this instance's peering status for each so admins can peer with them via
+// POST /admin/federation/peers. Optional ?q= filters by domain or name.
+// GET /api/v1/admin/federation/directory
+func (h *Handler) HandleGetDirectory(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	q := r.URL.Query().Get("q")
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT d.instance_id, d.domain, d.name, d.description, d.user_count, d.federation_mode,
+		        d.software_version, d.featured_guilds, d.fetched_at, fp.status
+		 FROM instance_directory d
+		 LEFT JOIN instances i ON i.domain = d.domain
+		 LEFT JOIN federation_peers fp ON fp.instance_id = $1 AND fp.peer_id = i.id
+		 WHERE $2 = '' OR d.domain ILIKE '%' || $2 || '%' OR d.name ILIKE '%' || $2 || '%'
+		 ORDER BY d.user_count DESC, d.domain
+		 LIMIT 200`, h.InstanceID, q)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get instance directory", err)
+		return
+	}
+	defer rows.Close()
+
+	entries := make([]models.DirectoryEntry, 0)
+	for rows.Next() {
+		var e models.DirectoryEntry
+		if err := rows.Scan(&e.InstanceID, &e.Domain, &e.Name, &e.Description, &e.UserCount,
+			&e.FederationMode, &e.SoftwareVersion, &e.FeaturedGuilds, &e.FetchedAt, &e.PeerStatus); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read instance directory", err)
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, entries)
+}
+
+// HandleRefreshDirectory starts a directory refresh in the background
+// instead of waiting for the periodic one.
+// POST /api/v1/admin/federation/directory/refresh
+func (h *Handler) HandleRefreshDirectory(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+	if h.FedSvc == nil {
+		apiutil.WriteError(w, http.StatusServiceUnavailable, "federation_disabled", "Federation is not enabled")
+		return
+	}
+
+	go func() {
+		if _, err := h.FedSvc.RefreshDirectory(context.Background()); err != nil {
+			h.Logger.Warn("manual directory refresh failed", slog.String("error", err.Error()))
+		}
+	}()
+	h.logStaffAction(r, models.StaffActionDirectoryRefresh, "instance", h.InstanceID, nil, nil, nil)
+	apiutil.WriteJSON(w, http.StatusAccepted, map[string]string{"status": "refreshing"})
+}
@@ -0,0 +1,171 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// guildEmojiLimits is a guild's emoji quota override. Nil limits fall back to
+// the guild's boost tier.
+type guildEmojiLimits struct {
+	GuildID     string `json:"guild_id"`
+	MaxStatic   *int   `json:"max_static"`
+	MaxAnimated *int   `json:"max_animated"`
+}
+
+// HandleListEmojiTiers handles GET /api/v1/admin/emoji-tiers.
+func (h *Handler) HandleListEmojiTiers(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT boost_tier, max_static, max_animated, updated_at
+		 FROM emoji_quota_tiers ORDER BY boost_tier`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list emoji tiers", err)
+		return
+	}
+	defer rows.Close()
+
+	tiers := []models.EmojiQuotaTier{}
+	for rows.Next() {
+		var t models.EmojiQuotaTier
+		if err := rows.Scan(&t.BoostTier, &t.MaxStatic, &t.MaxAnimated, &t.UpdatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read emoji tiers", err)
+			return
+		}
+		tiers = append(tiers, t)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, tiers)
+}
+
+// HandleSetEmojiTier creates or replaces the emoji quota for a boost tier.
+// PUT /api/v1/admin/emoji-tiers/{tier}
+func (h *Handler) HandleSetEmojiTier(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	boostTier, err := strconv.Atoi(chi.URLParam(r, "tier"))
+	if err != nil || boostTier < 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_tier", "tier must be a non-negative integer")
+		return
+	}
+
+	var req struct {
+		MaxStatic   int `json:"max_static"`
+		MaxAnimated int `json:"max_animated"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.MaxStatic < 0 || req.MaxAnimated < 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_limit", "Limits must not be negative")
+		return
+	}
+
+	t := models.EmojiQuotaTier{BoostTier: boostTier}
+	err = h.Pool.QueryRow(r.Context(),
+		`INSERT INTO emoji_quota_tiers (boost_tier, max_static, max_animated, updated_at)
+		 VALUES ($1, $2, $3, now())
+		 ON CONFLICT (boost_tier) DO UPDATE SET
+		     max_static = EXCLUDED.max_static, max_animated = EXCLUDED.max_animated, updated_at = now()
+		 RETURNING max_static, max_animated, updated_at`,
+		boostTier, req.MaxStatic, req.MaxAnimated,
+	).Scan(&t.MaxStatic, &t.MaxAnimated, &t.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to set emoji tier", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionEmojiTierUpdate, "emoji_tier", strconv.Itoa(boostTier), nil, t, nil)
+	apiutil.WriteJSON(w, http.StatusOK, t)
+}
+
+// HandleDeleteEmojiTier removes a boost tier's quota; guilds at that tier fall
+// back to the next lower one. Tier 0 is the floor and cannot be removed.
+// DELETE /api/v1/admin/emoji-tiers/{tier}
+func (h *Handler) HandleDeleteEmojiTier(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	boostTier, err := strconv.Atoi(chi.URLParam(r, "tier"))
+	if err != nil || boostTier < 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_tier", "tier must be a non-negative integer")
+		return
+	}
+	if boostTier == 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "base_tier", "Tier 0 is the default quota and cannot be removed")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(), `DELETE FROM emoji_quota_tiers WHERE boost_tier = $1`, boostTier)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete emoji tier", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "tier_not_found", "No emoji quota for that tier")
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionEmojiTierDelete, "emoji_tier", strconv.Itoa(boostTier), nil, nil, nil)
+	apiutil.WriteNoContent(w)
+}
+
+// HandleSetGuildEmojiQuota sets or clears a guild's own emoji limits,
+// overriding its tier. Send null for a limit to use the tier's again.
+// PUT /api/v1/admin/guilds/{guildID}/emoji-quota
+func (h *Handler) HandleSetGuildEmojiQuota(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	guildID := chi.URLParam(r, "guildID")
+
+	var req struct {
+		MaxStatic   *int `json:"max_static"`
+		MaxAnimated *int `json:"max_animated"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if (req.MaxStatic != nil && *req.MaxStatic < 0) || (req.MaxAnimated != nil && *req.MaxAnimated < 0) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_limit", "Limits must not be negative")
+		return
+	}
+
+	before := guildEmojiLimits{GuildID: guildID}
+	after := guildEmojiLimits{GuildID: guildID}
+	err := h.Pool.QueryRow(r.Context(),
+		`UPDATE guilds g SET emoji_max_static = $2, emoji_max_animated = $3
+		 FROM (SELECT emoji_max_static, emoji_max_animated FROM guilds WHERE id = $1 FOR UPDATE) old
+		 WHERE g.id = $1
+		 RETURNING old.emoji_max_static, old.emoji_max_animated, g.emoji_max_static, g.emoji_max_animated`,
+		guildID, req.MaxStatic, req.MaxAnimated,
+	).Scan(&before.MaxStatic, &before.MaxAnimated, &after.MaxStatic, &after.MaxAnimated)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to set guild emoji quota", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionGuildEmojiQuota, "guild", guildID, before, after, nil)
+	apiutil.WriteJSON(w, http.StatusOK, after)
+}
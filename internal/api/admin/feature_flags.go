@@ -0,0 +1,258 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/featureflags"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// featureFlagKeyRe is the shape of a flag key: the name code checks it by.
+var featureFlagKeyRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+type updateFeatureFlagRequest struct {
+	Description     *string `json:"description"`
+	Enabled         *bool   `json:"enabled"`
+	UserPercent     *int    `json:"user_percent"`
+	GuildPercent    *int    `json:"guild_percent"`
+	InstancePercent *int    `json:"instance_percent"`
+}
+
+// HandleListFeatureFlags returns every feature flag with its overrides.
+// GET /api/v1/admin/feature-flags
+func (h *Handler) HandleListFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	flags, err := featureflags.Load(r.Context(), h.Pool)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list feature flags", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, flags)
+}
+
+// HandleUpdateFeatureFlag creates a feature flag or changes its rollout.
+// New flags start enabled with nothing rolled out; omitted fields keep their
+// current value. Every node applies the change within a few seconds.
+// PUT /api/v1/admin/feature-flags/{flagKey}
+func (h *Handler) HandleUpdateFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	key := chi.URLParam(r, "flagKey")
+	if !featureFlagKeyRe.MatchString(key) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_flag_key",
+			"Flag keys are 1-64 lowercase letters, digits, '_', '.' or '-'")
+		return
+	}
+
+	var req updateFeatureFlagRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Description != nil && len(*req.Description) > 500 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_description", "description must be at most 500 characters")
+		return
+	}
+	for _, p := range []*int{req.UserPercent, req.GuildPercent, req.InstancePercent} {
+		if p != nil && (*p < 0 || *p > 100) {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_percent", "Rollout percentages must be between 0 and 100")
+			return
+		}
+	}
+
+	before, _ := h.loadFeatureFlag(r.Context(), key)
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO feature_flags (key, description, enabled, user_percent, guild_percent, instance_percent)
+		 VALUES ($1, COALESCE($2, ''), COALESCE($3, true), COALESCE($4, 0), COALESCE($5, 0), COALESCE($6, 0))
+		 ON CONFLICT (key) DO UPDATE SET
+		     description = COALESCE($2, feature_flags.description),
+		     enabled = COALESCE($3, feature_flags.enabled),
+		     user_percent = COALESCE($4, feature_flags.user_percent),
+		     guild_percent = COALESCE($5, feature_flags.guild_percent),
+		     instance_percent = COALESCE($6, feature_flags.instance_percent),
+		     updated_at = now()`,
+		key, req.Description, req.Enabled, req.UserPercent, req.GuildPercent, req.InstancePercent); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update feature flag", err)
+		return
+	}
+
+	flag, err := h.loadFeatureFlag(r.Context(), key)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load feature flag", err)
+		return
+	}
+	h.logStaffAction(r, models.StaffActionFeatureFlagUpdate, "feature_flag", key, before, flag, nil)
+	h.publishFeatureFlagsUpdate(r.Context(), key)
+	apiutil.WriteJSON(w, http.StatusOK, flag)
+}
+
+// HandleDeleteFeatureFlag deletes a feature flag and its overrides. Code
+// checking it sees it as off.
+// DELETE /api/v1/admin/feature-flags/{flagKey}
+func (h *Handler) HandleDeleteFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	key := chi.URLParam(r, "flagKey")
+
+	before, err := h.loadFeatureFlag(r.Context(), key)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "flag_not_found", "Feature flag not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load feature flag", err)
+		return
+	}
+	if _, err := h.Pool.Exec(r.Context(), `DELETE FROM feature_flags WHERE key = $1`, key); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete feature flag", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionFeatureFlagDelete, "feature_flag", key, before, nil, nil)
+	h.publishFeatureFlagsUpdate(r.Context(), key)
+	apiutil.WriteNoContent(w)
+}
+
+// HandleSetFeatureFlagOverride forces a flag on or off for one user, guild
+// or peer instance.
+// PUT /api/v1/admin/feature-flags/{flagKey}/overrides/{targetType}/{targetID}
+func (h *Handler) HandleSetFeatureFlagOverride(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	key := chi.URLParam(r, "flagKey")
+	targetType := chi.URLParam(r, "targetType")
+	targetID := chi.URLParam(r, "targetID")
+	if !validFeatureFlagTarget(targetType) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_target_type",
+			"Target type must be 'user', 'guild' or 'instance'")
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Enabled == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "missing_enabled", "enabled is required")
+		return
+	}
+
+	before, err := h.loadFeatureFlag(r.Context(), key)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "flag_not_found", "Feature flag not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load feature flag", err)
+		return
+	}
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO feature_flag_overrides (flag_key, target_type, target_id, enabled)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (flag_key, target_type, target_id) DO UPDATE SET enabled = EXCLUDED.enabled`,
+		key, targetType, targetID, *req.Enabled); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to set feature flag override", err)
+		return
+	}
+	h.Pool.Exec(r.Context(), `UPDATE feature_flags SET updated_at = now() WHERE key = $1`, key)
+
+	h.writeFeatureFlagChange(w, r, models.StaffActionFeatureOverrideSet, key, before)
+}
+
+// HandleDeleteFeatureFlagOverride removes an override, returning the target
+// to the rollout percentages.
+// DELETE /api/v1/admin/feature-flags/{flagKey}/overrides/{targetType}/{targetID}
+func (h *Handler) HandleDeleteFeatureFlagOverride(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	key := chi.URLParam(r, "flagKey")
+
+	before, err := h.loadFeatureFlag(r.Context(), key)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "flag_not_found", "Feature flag not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load feature flag", err)
+		return
+	}
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM feature_flag_overrides WHERE flag_key = $1 AND target_type = $2 AND target_id = $3`,
+		key, chi.URLParam(r, "targetType"), chi.URLParam(r, "targetID"))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete feature flag override", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "override_not_found", "Override not found")
+		return
+	}
+	h.Pool.Exec(r.Context(), `UPDATE feature_flags SET updated_at = now() WHERE key = $1`, key)
+
+	h.writeFeatureFlagChange(w, r, models.StaffActionFeatureOverrideDelete, key, before)
+}
+
+// writeFeatureFlagChange logs and announces a change to a flag's overrides
+// under the given audit action and responds with the flag.
+func (h *Handler) writeFeatureFlagChange(w http.ResponseWriter, r *http.Request, action, key string, before models.FeatureFlag) {
+	flag, err := h.loadFeatureFlag(r.Context(), key)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load feature flag", err)
+		return
+	}
+	h.logStaffAction(r, action, "feature_flag", key, before, flag, nil)
+	h.publishFeatureFlagsUpdate(r.Context(), key)
+	apiutil.WriteJSON(w, http.StatusOK, flag)
+}
+
+// loadFeatureFlag returns one flag with its overrides, or pgx.ErrNoRows.
+func (h *Handler) loadFeatureFlag(ctx context.Context, key string) (models.FeatureFlag, error) {
+	flags, err := featureflags.Load(ctx, h.Pool)
+	if err != nil {
+		return models.FeatureFlag{}, err
+	}
+	for _, f := range flags {
+		if f.Key == key {
+			return f, nil
+		}
+	}
+	return models.FeatureFlag{}, pgx.ErrNoRows
+}
+
+// publishFeatureFlagsUpdate tells every node to reload its flags and every
+// client to fetch its own again.
+func (h *Handler) publishFeatureFlagsUpdate(ctx context.Context, key string) {
+	if h.EventBus != nil {
+		h.EventBus.PublishBroadcastEvent(ctx, events.SubjectFeatureFlagsUpdate, "FEATURE_FLAGS_UPDATE",
+			map[string]string{"key": key})
+	}
+}
+
+func validFeatureFlagTarget(targetType string) bool {
+	switch targetType {
+	case models.FeatureFlagTargetUser, models.FeatureFlagTargetGuild, models.FeatureFlagTargetInstance:
+		return true
+	}
+	return false
+}
@@ -10,10 +10,13 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
 )
 
 // =============================================================================
@@ -24,8 +27,7 @@ import (
 // event lag, and aggregate statistics for the admin dashboard.
 // GET /api/v1/admin/federation/dashboard
 func (h *Handler) HandleGetFederationDashboard(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -46,6 +48,9 @@ func (h *Handler) HandleGetFederationDashboard(w http.ResponseWriter, r *http.Re
 		Version        *string    `json:"version,omitempty"`
 		Capabilities   json.RawMessage `json:"capabilities"`
 		EstablishedAt  time.Time  `json:"established_at"`
+		Reputation      int        `json:"reputation"`       // 0-100, lowered by quota and validation violations
+		QuotaViolations int64      `json:"quota_violations"`
+		ThrottledUntil  *time.Time `json:"throttled_until,omitempty"`
 	}
 
 	rows, err := h.Pool.Query(r.Context(),
@@ -54,7 +59,9 @@ func (h *Handler) HandleGetFederationDashboard(w http.ResponseWriter, r *http.Re
 		        fps.last_sync_at, fps.last_event_at,
 		        COALESCE(fps.event_lag_ms, 0), COALESCE(fps.events_sent, 0),
 		        COALESCE(fps.events_received, 0), COALESCE(fps.errors_24h, 0),
-		        fps.version, COALESCE(fps.capabilities, '[]'::jsonb)
+		        fps.version, COALESCE(fps.capabilities, '[]'::jsonb),
+		        COALESCE(fps.reputation, 100), COALESCE(fps.quota_violations, 0),
+		        CASE WHEN fps.throttled_until > now() THEN fps.throttled_until END
 		 FROM federation_peers fp
 		 JOIN instances i ON i.id = fp.peer_id
 		 LEFT JOIN federation_peer_status fps ON fps.peer_id = fp.peer_id
@@ -75,6 +82,7 @@ func (h *Handler) HandleGetFederationDashboard(w http.ResponseWriter, r *http.Re
 			&p.HealthStatus, &p.LastSyncAt, &p.LastEventAt,
 			&p.EventLagMs, &p.EventsSent, &p.EventsReceived,
 			&p.Errors24h, &p.Version, &p.Capabilities,
+			&p.Reputation, &p.QuotaViolations, &p.ThrottledUntil,
 		); err != nil {
 			h.Logger.Error("failed to scan federation peer", slog.String("error", err.Error()))
 			continue
@@ -84,7 +92,7 @@ func (h *Handler) HandleGetFederationDashboard(w http.ResponseWriter, r *http.Re
 	}
 
 	// Aggregate stats.
-	var totalPeers, activePeers, blockedPeers, degradedPeers int64
+	var totalPeers, activePeers, blockedPeers, degradedPeers, throttledPeers int64
 	for _, p := range peers {
 		totalPeers++
 		switch p.FederationStatus {
@@ -96,6 +104,9 @@ func (h *Handler) HandleGetFederationDashboard(w http.ResponseWriter, r *http.Re
 		if p.HealthStatus == "degraded" || p.HealthStatus == "unreachable" {
 			degradedPeers++
 		}
+		if p.ThrottledUntil != nil {
+			throttledPeers++
+		}
 	}
 
 	// Get total delivery stats.
@@ -122,6 +133,7 @@ func (h *Handler) HandleGetFederationDashboard(w http.ResponseWriter, r *http.Re
 		"active_peers":       activePeers,
 		"blocked_peers":      blockedPeers,
 		"degraded_peers":     degradedPeers,
+		"throttled_peers":    throttledPeers,
 		"pending_deliveries": pendingDeliveries,
 		"failed_deliveries":  failedDeliveries,
 		"total_deliveries":   totalDeliveries,
@@ -135,8 +147,7 @@ func (h *Handler) HandleGetFederationDashboard(w http.ResponseWriter, r *http.Re
 // HandleUpdatePeerControl sets the allow/block/mute action for a specific peer.
 // PUT /api/v1/admin/federation/peers/{peerID}/control
 func (h *Handler) HandleUpdatePeerControl(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -161,6 +172,11 @@ func (h *Handler) HandleUpdatePeerControl(w http.ResponseWriter, r *http.Request
 	adminID := auth.UserIDFromContext(r.Context())
 	controlID := models.NewULID().String()
 
+	var prevAction *string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT action FROM federation_peer_controls WHERE instance_id = $1 AND peer_id = $2`,
+		h.InstanceID, peerID).Scan(&prevAction)
+
 	_, err := h.Pool.Exec(r.Context(),
 		`INSERT INTO federation_peer_controls (id, instance_id, peer_id, action, reason, created_by)
 		 VALUES ($1, $2, $3, $4, $5, $6)
@@ -188,6 +204,9 @@ func (h *Handler) HandleUpdatePeerControl(w http.ResponseWriter, r *http.Request
 		h.FedSvc.InvalidateAllowedCache(peerID)
 	}
 
+	h.logStaffAction(r, models.StaffActionFederationPeerControl, "instance", peerID,
+		map[string]*string{"action": prevAction}, map[string]string{"action": req.Action}, req.Reason)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"peer_id": peerID,
 		"action":  req.Action,
@@ -196,11 +215,72 @@ func (h *Handler) HandleUpdatePeerControl(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// HandleUpdatePeerTrust sets a peer's trust level: trusted, normal or
+// restricted. Restricted peers have their media proxied without caching,
+// their users cannot create invites or DM local users without approval, and
+// their messages get stricter AutoMod.
+// PUT /api/v1/admin/federation/peers/{peerID}/trust
+func (h *Handler) HandleUpdatePeerTrust(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	peerID := chi.URLParam(r, "peerID")
+
+	var req struct {
+		TrustLevel string  `json:"trust_level"`
+		Reason     *string `json:"reason,omitempty"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	switch req.TrustLevel {
+	case models.PeerTrustTrusted, models.PeerTrustNormal, models.PeerTrustRestricted:
+		// valid
+	default:
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_trust_level", "Trust level must be 'trusted', 'normal', or 'restricted'")
+		return
+	}
+
+	var prevLevel string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT trust_level FROM federation_peers WHERE instance_id = $1 AND peer_id = $2`,
+		h.InstanceID, peerID).Scan(&prevLevel)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "peer_not_found", "Federation peer not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get peer trust level", err)
+		return
+	}
+
+	if _, err := h.Pool.Exec(r.Context(),
+		`UPDATE federation_peers SET trust_level = $3 WHERE instance_id = $1 AND peer_id = $2`,
+		h.InstanceID, peerID, req.TrustLevel); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update peer trust level", err)
+		return
+	}
+
+	if h.FedSvc != nil {
+		h.FedSvc.InvalidateTrustCache(peerID)
+	}
+
+	h.logStaffAction(r, models.StaffActionFederationPeerTrust, "instance", peerID,
+		map[string]string{"trust_level": prevLevel}, map[string]string{"trust_level": req.TrustLevel}, req.Reason)
+
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"peer_id":     peerID,
+		"trust_level": req.TrustLevel,
+		"status":      "updated",
+	})
+}
+
 // HandleGetPeerControls returns the current allow/block/mute list.
 // GET /api/v1/admin/federation/peers/controls
 func (h *Handler) HandleGetPeerControls(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -248,8 +328,7 @@ func (h *Handler) HandleGetPeerControls(w http.ResponseWriter, r *http.Request)
 // HandleGetDeliveryReceipts returns paginated delivery receipt logs.
 // GET /api/v1/admin/federation/delivery-receipts
 func (h *Handler) HandleGetDeliveryReceipts(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -325,8 +404,7 @@ func (h *Handler) HandleGetDeliveryReceipts(w http.ResponseWriter, r *http.Reque
 // HandleRetryDelivery retries a failed delivery.
 // POST /api/v1/admin/federation/delivery-receipts/{receiptID}/retry
 func (h *Handler) HandleRetryDelivery(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -342,10 +420,12 @@ func (h *Handler) HandleRetryDelivery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Delivery receipt not found or already delivered")
+		apiutil.WriteCode(w, apierrors.NotFound, "Delivery receipt not found or already delivered")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionFederationRetry, "delivery_receipt", receiptID, nil, nil, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "retrying"})
 }
 
@@ -356,8 +436,7 @@ func (h *Handler) HandleRetryDelivery(w http.ResponseWriter, r *http.Request) {
 // HandleGetFederatedSearchConfig returns the federated search settings.
 // GET /api/v1/admin/federation/search-config
 func (h *Handler) HandleGetFederatedSearchConfig(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -389,8 +468,7 @@ func (h *Handler) HandleGetFederatedSearchConfig(w http.ResponseWriter, r *http.
 // HandleUpdateFederatedSearchConfig updates federated search settings.
 // PATCH /api/v1/admin/federation/search-config
 func (h *Handler) HandleUpdateFederatedSearchConfig(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -425,6 +503,8 @@ func (h *Handler) HandleUpdateFederatedSearchConfig(w http.ResponseWriter, r *ht
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", "federated_search", nil, req, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
@@ -435,8 +515,7 @@ func (h *Handler) HandleUpdateFederatedSearchConfig(w http.ResponseWriter, r *ht
 // HandleGetBridges returns all configured bridges for the instance.
 // GET /api/v1/admin/bridges
 func (h *Handler) HandleGetBridges(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -487,8 +566,7 @@ func (h *Handler) HandleGetBridges(w http.ResponseWriter, r *http.Request) {
 // HandleCreateBridge creates a new bridge configuration.
 // POST /api/v1/admin/bridges
 func (h *Handler) HandleCreateBridge(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -530,19 +608,22 @@ func (h *Handler) HandleCreateBridge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	apiutil.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+	bridge := map[string]interface{}{
 		"id":           bridgeID,
 		"bridge_type":  req.BridgeType,
 		"display_name": req.DisplayName,
 		"status":       "disconnected",
-	})
+	}
+	// Bridge config carries remote credentials, so only the summary is audited.
+	h.logStaffAction(r, models.StaffActionBridgeCreate, "bridge", bridgeID, nil, bridge, nil)
+
+	apiutil.WriteJSON(w, http.StatusCreated, bridge)
 }
 
 // HandleUpdateBridge updates a bridge configuration.
 // PATCH /api/v1/admin/bridges/{bridgeID}
 func (h *Handler) HandleUpdateBridge(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -570,18 +651,23 @@ func (h *Handler) HandleUpdateBridge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Bridge not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Bridge not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionBridgeUpdate, "bridge", bridgeID, nil, map[string]interface{}{
+		"enabled":        req.Enabled,
+		"display_name":   req.DisplayName,
+		"config_changed": req.Config != nil,
+	}, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
 // HandleDeleteBridge removes a bridge configuration and all its mappings.
 // DELETE /api/v1/admin/bridges/{bridgeID}
 func (h *Handler) HandleDeleteBridge(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -594,18 +680,19 @@ func (h *Handler) HandleDeleteBridge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Bridge not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Bridge not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionBridgeDelete, "bridge", bridgeID, nil, nil, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // HandleGetBridgeChannelMappings returns channel mappings for a bridge.
 // GET /api/v1/admin/bridges/{bridgeID}/mappings
 func (h *Handler) HandleGetBridgeChannelMappings(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -656,8 +743,7 @@ func (h *Handler) HandleGetBridgeChannelMappings(w http.ResponseWriter, r *http.
 // HandleCreateBridgeChannelMapping creates a new channel mapping for a bridge.
 // POST /api/v1/admin/bridges/{bridgeID}/mappings
 func (h *Handler) HandleCreateBridgeChannelMapping(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -674,7 +760,7 @@ func (h *Handler) HandleCreateBridgeChannelMapping(w http.ResponseWriter, r *htt
 	}
 
 	if req.LocalChannelID == "" || req.RemoteChannelID == "" {
-		apiutil.WriteError(w, http.StatusBadRequest, "missing_fields", "local_channel_id and remote_channel_id are required")
+		apiutil.WriteCode(w, apierrors.MissingFields, "local_channel_id and remote_channel_id are required")
 		return
 	}
 
@@ -699,19 +785,22 @@ func (h *Handler) HandleCreateBridgeChannelMapping(w http.ResponseWriter, r *htt
 		return
 	}
 
-	apiutil.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+	mapping := map[string]interface{}{
 		"id":                mappingID,
+		"bridge_id":         bridgeID,
 		"local_channel_id":  req.LocalChannelID,
 		"remote_channel_id": req.RemoteChannelID,
 		"direction":         req.Direction,
-	})
+	}
+	h.logStaffAction(r, models.StaffActionBridgeMappingCreate, "bridge_mapping", mappingID, nil, mapping, nil)
+
+	apiutil.WriteJSON(w, http.StatusCreated, mapping)
 }
 
 // HandleDeleteBridgeChannelMapping removes a channel mapping.
 // DELETE /api/v1/admin/bridges/{bridgeID}/mappings/{mappingID}
 func (h *Handler) HandleDeleteBridgeChannelMapping(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -724,18 +813,19 @@ func (h *Handler) HandleDeleteBridgeChannelMapping(w http.ResponseWriter, r *htt
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Channel mapping not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Channel mapping not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionBridgeMappingDelete, "bridge_mapping", mappingID, nil, nil, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // HandleGetBridgeVirtualUsers returns virtual users for a bridge.
 // GET /api/v1/admin/bridges/{bridgeID}/virtual-users
 func (h *Handler) HandleGetBridgeVirtualUsers(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -776,6 +866,131 @@ func (h *Handler) HandleGetBridgeVirtualUsers(w http.ResponseWriter, r *http.Req
 	apiutil.WriteJSON(w, http.StatusOK, users)
 }
 
+// =============================================================================
+// Embedded Matrix Appservice
+// =============================================================================
+
+// HandleGetMatrixGuilds returns the guilds exposed to Matrix through the
+// embedded appservice.
+// GET /api/v1/admin/matrix/guilds
+func (h *Handler) HandleGetMatrixGuilds(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT b.guild_id, g.name, b.created_by, b.created_at,
+		        (SELECT COUNT(*) FROM matrix_rooms mr
+		         JOIN channels c ON c.id = mr.channel_id
+		         WHERE c.guild_id = b.guild_id)
+		 FROM matrix_bridged_guilds b
+		 JOIN guilds g ON g.id = b.guild_id
+		 ORDER BY b.created_at DESC`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get Matrix guilds", err)
+		return
+	}
+	defer rows.Close()
+
+	type bridgedGuild struct {
+		GuildID   string    `json:"guild_id"`
+		GuildName string    `json:"guild_name"`
+		CreatedBy *string   `json:"created_by,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+		RoomCount int64     `json:"room_count"`
+	}
+
+	guilds := make([]bridgedGuild, 0)
+	for rows.Next() {
+		var g bridgedGuild
+		if err := rows.Scan(&g.GuildID, &g.GuildName, &g.CreatedBy, &g.CreatedAt, &g.RoomCount); err != nil {
+			continue
+		}
+		guilds = append(guilds, g)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, guilds)
+}
+
+// HandleAddMatrixGuild exposes a local guild to Matrix. Its readable text
+// channels become joinable by alias once the embedded appservice is enabled.
+// PUT /api/v1/admin/matrix/guilds/{guildID}
+func (h *Handler) HandleAddMatrixGuild(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	guildID := chi.URLParam(r, "guildID")
+	userID := auth.UserIDFromContext(r.Context())
+
+	var local bool
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT instance_id = $2 FROM guilds WHERE id = $1`, guildID, h.InstanceID).Scan(&local)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get guild", err)
+		return
+	}
+	if !local {
+		apiutil.WriteError(w, http.StatusBadRequest, "remote_guild", "Only guilds hosted on this instance can be bridged to Matrix")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO matrix_bridged_guilds (guild_id, created_by) VALUES ($1, $2)
+		 ON CONFLICT (guild_id) DO NOTHING`, guildID, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to bridge guild to Matrix", err)
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		h.logStaffAction(r, models.StaffActionMatrixGuildAdd, "guild", guildID, nil, nil, nil)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRemoveMatrixGuild stops exposing a guild to Matrix and forgets its
+// room mappings. The rooms themselves remain on the homeserver.
+// DELETE /api/v1/admin/matrix/guilds/{guildID}
+func (h *Handler) HandleRemoveMatrixGuild(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	guildID := chi.URLParam(r, "guildID")
+
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(),
+			`DELETE FROM matrix_bridged_guilds WHERE guild_id = $1`, guildID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		_, err = tx.Exec(r.Context(),
+			`DELETE FROM matrix_rooms
+			 WHERE channel_id IN (SELECT id FROM channels WHERE guild_id = $1)`, guildID)
+		return err
+	})
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.NotFound, "Guild is not bridged to Matrix")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to unbridge guild", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionMatrixGuildRemove, "guild", guildID, nil, nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // =============================================================================
 // Instance Connection Profiles (Multi-Instance)
 // =============================================================================
@@ -884,7 +1099,7 @@ func (h *Handler) HandleRemoveInstanceProfile(w http.ResponseWriter, r *http.Req
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Instance profile not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Instance profile not found")
 		return
 	}
 
@@ -898,8 +1113,7 @@ func (h *Handler) HandleRemoveInstanceProfile(w http.ResponseWriter, r *http.Req
 // HandleGetFederatedUserProfile retrieves a user profile from a remote instance.
 // GET /api/v1/admin/federation/users/{instanceDomain}/{username}
 func (h *Handler) HandleGetFederatedUserProfile(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -929,7 +1143,7 @@ func (h *Handler) HandleGetFederatedUserProfile(w http.ResponseWriter, r *http.R
 		&u.ID, &u.InstanceID, &u.Username, &u.DisplayName, &u.AvatarID,
 		&u.Bio, &u.StatusPresence, &u.CreatedAt, &u.InstanceDomain)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "user_not_found",
+		apiutil.WriteCode(w, apierrors.UserNotFound,
 			"User not found. The remote instance may need to be discovered first.")
 		return
 	}
@@ -944,8 +1158,7 @@ func (h *Handler) HandleGetFederatedUserProfile(w http.ResponseWriter, r *http.R
 // HandleGetInstanceBlocklist returns the instance blocklist (blocked peers).
 // GET /api/v1/admin/federation/blocklist
 func (h *Handler) HandleGetInstanceBlocklist(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -985,8 +1198,7 @@ func (h *Handler) HandleGetInstanceBlocklist(w http.ResponseWriter, r *http.Requ
 // HandleGetInstanceAllowlist returns the instance allowlist (allowed peers).
 // GET /api/v1/admin/federation/allowlist
 func (h *Handler) HandleGetInstanceAllowlist(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -1030,8 +1242,7 @@ func (h *Handler) HandleGetInstanceAllowlist(w http.ResponseWriter, r *http.Requ
 // HandleGetProtocolInfo returns protocol version and capabilities for this instance.
 // GET /api/v1/admin/federation/protocol
 func (h *Handler) HandleGetProtocolInfo(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -1064,8 +1275,7 @@ func (h *Handler) HandleGetProtocolInfo(w http.ResponseWriter, r *http.Request)
 // HandleUpdateProtocolConfig updates protocol version and capabilities.
 // PATCH /api/v1/admin/federation/protocol
 func (h *Handler) HandleUpdateProtocolConfig(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -1097,6 +1307,8 @@ func (h *Handler) HandleUpdateProtocolConfig(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance", h.InstanceID, nil, req, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
@@ -1107,8 +1319,7 @@ func (h *Handler) HandleUpdateProtocolConfig(w http.ResponseWriter, r *http.Requ
 // HandleApproveFederationPeer approves a pending federation peer.
 // POST /api/v1/admin/federation/peers/{peerID}/approve
 func (h *Handler) HandleApproveFederationPeer(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -1124,18 +1335,21 @@ func (h *Handler) HandleApproveFederationPeer(w http.ResponseWriter, r *http.Req
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "No pending peer found with that ID")
+		apiutil.WriteCode(w, apierrors.NotFound, "No pending peer found with that ID")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionFederationPeerApprove, "instance", peerID,
+		map[string]string{"status": models.FederationPeerPending},
+		map[string]string{"status": models.FederationPeerActive}, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "approved"})
 }
 
 // HandleRejectFederationPeer rejects a pending federation peer.
 // POST /api/v1/admin/federation/peers/{peerID}/reject
 func (h *Handler) HandleRejectFederationPeer(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -1151,18 +1365,21 @@ func (h *Handler) HandleRejectFederationPeer(w http.ResponseWriter, r *http.Requ
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "No pending peer found with that ID")
+		apiutil.WriteCode(w, apierrors.NotFound, "No pending peer found with that ID")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionFederationPeerReject, "instance", peerID,
+		map[string]string{"status": models.FederationPeerPending},
+		map[string]string{"status": models.FederationPeerBlocked}, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "rejected"})
 }
 
 // HandleGetKeyAudit returns unacknowledged key change audit entries.
 // GET /api/v1/admin/federation/key-audit
 func (h *Handler) HandleGetKeyAudit(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
@@ -1209,27 +1426,31 @@ func (h *Handler) HandleGetKeyAudit(w http.ResponseWriter, r *http.Request) {
 // HandleAcknowledgeKeyChange acknowledges a key change audit entry.
 // POST /api/v1/admin/federation/key-audit/{auditID}/acknowledge
 func (h *Handler) HandleAcknowledgeKeyChange(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
 		return
 	}
 
 	auditID := chi.URLParam(r, "auditID")
 	adminID := auth.UserIDFromContext(r.Context())
 
-	tag, err := h.Pool.Exec(r.Context(),
+	var peerID, newFingerprint string
+	err := h.Pool.QueryRow(r.Context(),
 		`UPDATE federation_key_audit
 		 SET acknowledged_by = $1, acknowledged_at = now()
-		 WHERE id = $2 AND acknowledged_at IS NULL`,
-		adminID, auditID)
-	if err != nil {
-		apiutil.InternalError(w, h.Logger, "Failed to acknowledge key change", err)
+		 WHERE id = $2 AND acknowledged_at IS NULL
+		 RETURNING instance_id, new_fingerprint`,
+		adminID, auditID).Scan(&peerID, &newFingerprint)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.NotFound, "Key audit entry not found or already acknowledged")
 		return
 	}
-	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Key audit entry not found or already acknowledged")
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to acknowledge key change", err)
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionFederationKeyAck, "instance", peerID, nil,
+		map[string]string{"audit_id": auditID, "new_fingerprint": newFingerprint}, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "acknowledged"})
 }
@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/federation"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// =============================================================================
+// Federation Rejections
+// =============================================================================
+
+// HandleGetFederationRejections lists inbound federation requests refused for
+// a bad signature, an unknown sender or clock skew, newest first. Filters:
+// sender_id, reason, endpoint (exact path), before (a rejection ID to page
+// from) and limit (1-200, default 50).
+// GET /api/v1/admin/federation/rejections
+func (h *Handler) HandleGetFederationRejections(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	q := r.URL.Query()
+	reason := q.Get("reason")
+	switch reason {
+	case "", federation.RejectBadSignature, federation.RejectUnknownPeer, federation.RejectClockSkew:
+	default:
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_reason",
+			"reason must be bad_signature, unknown_peer or clock_skew")
+		return
+	}
+	limit := 50
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT fr.id, fr.sender_id, i.domain, fr.remote_addr, fr.endpoint, fr.reason, fr.detail, fr.created_at
+		 FROM federation_rejections fr
+		 LEFT JOIN instances i ON i.id = fr.sender_id
+		 WHERE ($1 = '' OR fr.sender_id = $1) AND ($2 = '' OR fr.reason = $2)
+		   AND ($3 = '' OR fr.endpoint = $3) AND ($4 = '' OR fr.id < $4)
+		 ORDER BY fr.id DESC
+		 LIMIT $5`,
+		q.Get("sender_id"), reason, q.Get("endpoint"), q.Get("before"), limit)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get federation rejections", err)
+		return
+	}
+	defer rows.Close()
+
+	rejections := make([]models.FederationRejection, 0)
+	for rows.Next() {
+		var rej models.FederationRejection
+		if err := rows.Scan(&rej.ID, &rej.SenderID, &rej.Domain, &rej.RemoteAddr, &rej.Endpoint,
+			&rej.Reason, &rej.Detail, &rej.CreatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read federation rejections", err)
+			return
+		}
+		rejections = append(rejections, rej)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, rejections)
+}
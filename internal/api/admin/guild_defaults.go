@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// guildDefaultsSetting is the instance_settings key holding the new guild
+// template as JSON.
+const guildDefaultsSetting = "guild_defaults"
+
+const (
+	maxGuildDefaultChannels = 50
+	maxGuildDefaultRoles    = 25
+	maxGuildDefaultFollows  = 10
+)
+
+var guildDefaultColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// guildDefaultChannelTypes are the channel types a new guild may start with.
+var guildDefaultChannelTypes = map[string]bool{
+	"text": true, "voice": true, "announcement": true, "forum": true, "gallery": true, "stage": true,
+}
+
+// validateGuildDefaults checks a new guild template and returns a message
+// safe to return to the caller, or "".
+func validateGuildDefaults(d models.GuildDefaults) string {
+	if d.DefaultPermissions != nil {
+		perms := uint64(*d.DefaultPermissions)
+		if perms&^permissions.AllPermissions != 0 {
+			return "default_permissions contains unknown permission bits"
+		}
+		if perms&permissions.Administrator != 0 {
+			return "default_permissions cannot include ADMINISTRATOR"
+		}
+	}
+
+	if d.Channels != nil && len(d.Channels) == 0 {
+		return "channels must list at least one channel, or be null for the built-in default"
+	}
+	if len(d.Channels) > maxGuildDefaultChannels {
+		return fmt.Sprintf("At most %d default channels are allowed", maxGuildDefaultChannels)
+	}
+	for _, ch := range d.Channels {
+		if ch.Name == "" || len(ch.Name) > 100 {
+			return "Channel names must be 1-100 characters"
+		}
+		if !guildDefaultChannelTypes[ch.ChannelType] {
+			return fmt.Sprintf("Invalid channel type %q", ch.ChannelType)
+		}
+		if ch.Topic != nil && len(*ch.Topic) > 1024 {
+			return "Channel topics must be at most 1024 characters"
+		}
+	}
+
+	if len(d.Roles) > maxGuildDefaultRoles {
+		return fmt.Sprintf("At most %d default roles are allowed", maxGuildDefaultRoles)
+	}
+	for _, role := range d.Roles {
+		if role.Name == "" || len(role.Name) > 100 {
+			return "Role names must be 1-100 characters"
+		}
+		if strings.EqualFold(role.Name, "@everyone") {
+			return "Set @everyone through default_permissions"
+		}
+		if role.Color != nil && !guildDefaultColorPattern.MatchString(*role.Color) {
+			return "Role colors must look like #rrggbb"
+		}
+		if uint64(role.PermissionsAllow)&^permissions.AllPermissions != 0 ||
+			uint64(role.PermissionsDeny)&^permissions.AllPermissions != 0 {
+			return fmt.Sprintf("Role %q contains unknown permission bits", role.Name)
+		}
+	}
+
+	if len(d.FollowChannelIDs) > maxGuildDefaultFollows {
+		return fmt.Sprintf("At most %d announcement channels can be followed", maxGuildDefaultFollows)
+	}
+	seen := make(map[string]bool, len(d.FollowChannelIDs))
+	for _, id := range d.FollowChannelIDs {
+		if id == "" || seen[id] {
+			return "follow_channel_ids must be distinct channel IDs"
+		}
+		seen[id] = true
+	}
+	return ""
+}
+
+// checkFollowChannels returns a message naming the first of ids that is not
+// an announcement channel in a guild on this instance, or "".
+func (h *Handler) checkFollowChannels(r *http.Request, ids []string) (string, error) {
+	for _, id := range ids {
+		var ok bool
+		err := h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM channels c JOIN guilds g ON g.id = c.guild_id
+			               WHERE c.id = $1 AND c.channel_type = $2 AND g.instance_id = $3)`,
+			id, models.ChannelTypeAnnouncement, h.InstanceID).Scan(&ok)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return fmt.Sprintf("Channel %s is not an announcement channel on this instance", id), nil
+		}
+	}
+	return "", nil
+}
+
+// loadGuildDefaults returns the stored template, or an empty one that keeps
+// every built-in default.
+func (h *Handler) loadGuildDefaults(r *http.Request) (models.GuildDefaults, error) {
+	var d models.GuildDefaults
+	var raw string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = $1`, guildDefaultsSetting).Scan(&raw)
+	if err == pgx.ErrNoRows {
+		return d, nil
+	}
+	if err != nil {
+		return d, err
+	}
+	return d, json.Unmarshal([]byte(raw), &d)
+}
+
+// HandleGetGuildDefaults returns the template applied to new guilds. Null
+// fields mean the built-in default is used.
+// GET /api/v1/admin/guild-defaults
+func (h *Handler) HandleGetGuildDefaults(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	d, err := h.loadGuildDefaults(r)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get guild defaults", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, d)
+}
+
+// HandleUpdateGuildDefaults replaces the template applied to new guilds:
+// the @everyone permissions, the starting channels, any extra roles and the
+// announcement channels they follow. Existing guilds are not changed.
+// PUT /api/v1/admin/guild-defaults
+func (h *Handler) HandleUpdateGuildDefaults(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req models.GuildDefaults
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if msg := validateGuildDefaults(req); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_guild_defaults", msg)
+		return
+	}
+	msg, err := h.checkFollowChannels(r, req.FollowChannelIDs)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to check announcement channels", err)
+		return
+	}
+	if msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_guild_defaults", msg)
+		return
+	}
+
+	before, err := h.loadGuildDefaults(r)
+	if err != nil {
+		h.Logger.Warn("stored guild defaults are unreadable, replacing them", slog.String("error", err.Error()))
+	}
+
+	value, _ := json.Marshal(req)
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_settings (key, value, updated_at) VALUES ($1, $2, now())
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+		guildDefaultsSetting, string(value)); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update guild defaults", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", guildDefaultsSetting, before, req, nil)
+	apiutil.WriteJSON(w, http.StatusOK, req)
+}
@@ -0,0 +1,225 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// maxInstanceEmojiName matches the guild emoji name limit.
+const maxInstanceEmojiName = 32
+
+// instanceEmojiColumns is the column list scanned by scanInstanceEmoji. The
+// image hash is the uploaded file's, as for guild emoji.
+const instanceEmojiColumns = `ie.id, ie.name, ie.creator_id, ie.animated, a.s3_key, a.sha256, ie.file_id, ie.created_at`
+
+func scanInstanceEmoji(row pgx.Row) (models.CustomEmoji, error) {
+	e := models.CustomEmoji{Origin: models.EmojiOriginInstance}
+	err := row.Scan(&e.ID, &e.Name, &e.CreatorID, &e.Animated, &e.S3Key, &e.ImageHash, &e.FileID, &e.CreatedAt)
+	e.URL = "/api/v1/emoji/" + e.ID
+	return e, err
+}
+
+// getInstanceEmoji reads one instance emoji.
+func (h *Handler) getInstanceEmoji(ctx context.Context, emojiID string) (models.CustomEmoji, error) {
+	return scanInstanceEmoji(h.Pool.QueryRow(ctx,
+		`SELECT `+instanceEmojiColumns+`
+		 FROM instance_emoji ie JOIN attachments a ON a.id = ie.file_id
+		 WHERE ie.id = $1`, emojiID))
+}
+
+// publishInstanceEmoji tells connected clients to refetch the instance emoji.
+func (h *Handler) publishInstanceEmoji(ctx context.Context, action string, emoji models.CustomEmoji) {
+	if h.EventBus == nil {
+		return
+	}
+	h.EventBus.PublishBroadcastEvent(ctx, events.SubjectInstanceEmoji, "INSTANCE_EMOJI_UPDATE",
+		map[string]interface{}{"action": action, "emoji": emoji})
+}
+
+// HandleListInstanceEmoji returns the instance's emoji, which are usable in
+// every guild and DM, by name.
+// GET /api/v1/emoji (any logged-in user)
+func (h *Handler) HandleListInstanceEmoji(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+instanceEmojiColumns+`
+		 FROM instance_emoji ie JOIN attachments a ON a.id = ie.file_id
+		 ORDER BY lower(ie.name)`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list instance emoji", err)
+		return
+	}
+	defer rows.Close()
+
+	emoji := []models.CustomEmoji{}
+	for rows.Next() {
+		e, err := scanInstanceEmoji(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read instance emoji", err)
+			return
+		}
+		emoji = append(emoji, e)
+	}
+	if err := rows.Err(); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read instance emoji", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, emoji)
+}
+
+// HandleCreateInstanceEmoji adds an instance emoji from an uploaded image.
+// POST /api/v1/admin/emoji
+func (h *Handler) HandleCreateInstanceEmoji(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		Name     string `json:"name"`
+		FileID   string `json:"file_id"`
+		Animated bool   `json:"animated"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxInstanceEmojiName {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Emoji name must be 1-32 characters")
+		return
+	}
+	if !apiutil.RequireNonEmpty(w, "file_id", req.FileID) {
+		return
+	}
+
+	var contentType string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT content_type FROM attachments WHERE id = $1`, req.FileID).Scan(&contentType)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_file", "File not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create instance emoji", err)
+		return
+	}
+	if !strings.HasPrefix(contentType, "image/") || contentType == "image/svg+xml" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_file", "Emoji must be a raster image")
+		return
+	}
+
+	emojiID := models.NewULID().String()
+	_, err = h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_emoji (id, name, file_id, animated, creator_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, now())`,
+		emojiID, req.Name, req.FileID, req.Animated, auth.UserIDFromContext(r.Context()))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		apiutil.WriteError(w, http.StatusConflict, "emoji_name_taken", "An instance emoji with that name already exists")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create instance emoji", err)
+		return
+	}
+	emoji, err := h.getInstanceEmoji(r.Context(), emojiID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read instance emoji", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceEmojiCreate, "instance_emoji", emojiID, nil, emoji, nil)
+	h.publishInstanceEmoji(r.Context(), "create", emoji)
+	apiutil.WriteJSON(w, http.StatusCreated, emoji)
+}
+
+// HandleUpdateInstanceEmoji renames an instance emoji.
+// PATCH /api/v1/admin/emoji/{emojiID}
+func (h *Handler) HandleUpdateInstanceEmoji(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	emojiID := chi.URLParam(r, "emojiID")
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxInstanceEmojiName {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Emoji name must be 1-32 characters")
+		return
+	}
+
+	before, err := h.getInstanceEmoji(r.Context(), emojiID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "emoji_not_found", "Emoji not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update instance emoji", err)
+		return
+	}
+
+	_, err = h.Pool.Exec(r.Context(),
+		`UPDATE instance_emoji SET name = $2 WHERE id = $1`, emojiID, req.Name)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		apiutil.WriteError(w, http.StatusConflict, "emoji_name_taken", "An instance emoji with that name already exists")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update instance emoji", err)
+		return
+	}
+	after := before
+	after.Name = req.Name
+
+	h.logStaffAction(r, models.StaffActionInstanceEmojiUpdate, "instance_emoji", emojiID, before, after, nil)
+	h.publishInstanceEmoji(r.Context(), "update", after)
+	apiutil.WriteJSON(w, http.StatusOK, after)
+}
+
+// HandleDeleteInstanceEmoji removes an instance emoji. Its image stays until
+// the unattached file cleanup collects it.
+// DELETE /api/v1/admin/emoji/{emojiID}
+func (h *Handler) HandleDeleteInstanceEmoji(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	emojiID := chi.URLParam(r, "emojiID")
+
+	before, err := h.getInstanceEmoji(r.Context(), emojiID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "emoji_not_found", "Emoji not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete instance emoji", err)
+		return
+	}
+	if _, err := h.Pool.Exec(r.Context(), `DELETE FROM instance_emoji WHERE id = $1`, emojiID); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete instance emoji", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceEmojiDelete, "instance_emoji", emojiID, before, nil, nil)
+	h.publishInstanceEmoji(r.Context(), "delete", before)
+	apiutil.WriteNoContent(w)
+}
@@ -0,0 +1,337 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// instanceRole is the API representation of an instance staff role.
+type instanceRole struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description,omitempty"`
+	Permissions []string  `json:"permissions"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type instanceRoleRequest struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Permissions *[]string `json:"permissions"`
+}
+
+// parseInstancePermissionNames converts API permission names to a bitfield,
+// returning the first unknown name on failure.
+func parseInstancePermissionNames(names []string) (uint64, string) {
+	var perms uint64
+	for _, n := range names {
+		bit, ok := permissions.ParseInstancePermission(n)
+		if !ok {
+			return 0, n
+		}
+		perms |= bit
+	}
+	return perms, ""
+}
+
+// HandleGetMyInstancePermissions returns the caller's instance staff
+// permissions so clients can decide which admin panels to show.
+// GET /api/v1/admin/permissions/@me
+func (h *Handler) HandleGetMyInstancePermissions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	perms, err := apiutil.InstancePermissions(r.Context(), h.Pool, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load instance permissions", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"admin":       h.isAdmin(r),
+		"permissions": permissions.InstanceNames(perms),
+	})
+}
+
+// HandleListInstanceRoles handles GET /api/v1/admin/instance-roles.
+func (h *Handler) HandleListInstanceRoles(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT ir.id, ir.name, ir.description, ir.permissions, ir.created_at,
+		        (SELECT COUNT(*) FROM user_instance_roles uir WHERE uir.role_id = ir.id)
+		 FROM instance_roles ir ORDER BY ir.name`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list instance roles", err)
+		return
+	}
+	defer rows.Close()
+
+	roles := make([]instanceRole, 0)
+	for rows.Next() {
+		var role instanceRole
+		var perms int64
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &perms, &role.CreatedAt, &role.MemberCount); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read instance roles", err)
+			return
+		}
+		role.Permissions = permissions.InstanceNames(uint64(perms))
+		roles = append(roles, role)
+	}
+	apiutil.WriteJSON(w, http.StatusOK, roles)
+}
+
+// HandleCreateInstanceRole creates a new instance staff role.
+// POST /api/v1/admin/instance-roles
+func (h *Handler) HandleCreateInstanceRole(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req instanceRoleRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "missing_name", "name is required")
+		return
+	}
+	name := strings.TrimSpace(*req.Name)
+	if !apiutil.ValidateStringLength(w, "name", name, 1, 64) {
+		return
+	}
+	var perms uint64
+	if req.Permissions != nil {
+		var unknown string
+		if perms, unknown = parseInstancePermissionNames(*req.Permissions); unknown != "" {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_permission", "Unknown instance permission: "+unknown)
+			return
+		}
+	}
+
+	role := instanceRole{
+		ID:          models.NewULID().String(),
+		Name:        name,
+		Description: req.Description,
+		Permissions: permissions.InstanceNames(perms),
+	}
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO instance_roles (id, name, description, permissions)
+		 VALUES ($1, $2, $3, $4) RETURNING created_at`,
+		role.ID, role.Name, role.Description, int64(perms)).Scan(&role.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			apiutil.WriteError(w, http.StatusConflict, "role_exists", "An instance role with that name already exists")
+			return
+		}
+		apiutil.InternalError(w, h.Logger, "Failed to create instance role", err)
+		return
+	}
+	h.logStaffAction(r, models.StaffActionInstanceRoleCreate, "instance_role", role.ID, nil, role, nil)
+	apiutil.WriteJSON(w, http.StatusCreated, role)
+}
+
+// HandleUpdateInstanceRole updates an instance role's name, description, or
+// permissions.
+// PATCH /api/v1/admin/instance-roles/{roleID}
+func (h *Handler) HandleUpdateInstanceRole(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	roleID := chi.URLParam(r, "roleID")
+
+	var req instanceRoleRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	var name *string
+	if req.Name != nil {
+		n := strings.TrimSpace(*req.Name)
+		if !apiutil.ValidateStringLength(w, "name", n, 1, 64) {
+			return
+		}
+		name = &n
+	}
+	var perms *int64
+	if req.Permissions != nil {
+		p, unknown := parseInstancePermissionNames(*req.Permissions)
+		if unknown != "" {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_permission", "Unknown instance permission: "+unknown)
+			return
+		}
+		v := int64(p)
+		perms = &v
+	}
+
+	var before instanceRole
+	var beforePerms int64
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT id, name, description, permissions, created_at FROM instance_roles WHERE id = $1`,
+		roleID).Scan(&before.ID, &before.Name, &before.Description, &beforePerms, &before.CreatedAt)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.RoleNotFound, "Instance role not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get instance role", err)
+		return
+	}
+	before.Permissions = permissions.InstanceNames(uint64(beforePerms))
+
+	var role instanceRole
+	var rolePerms int64
+	err = h.Pool.QueryRow(r.Context(),
+		`UPDATE instance_roles SET
+			name = COALESCE($2, name),
+			description = COALESCE($3, description),
+			permissions = COALESCE($4, permissions)
+		 WHERE id = $1
+		 RETURNING id, name, description, permissions, created_at`,
+		roleID, name, req.Description, perms).Scan(
+		&role.ID, &role.Name, &role.Description, &rolePerms, &role.CreatedAt)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.RoleNotFound, "Instance role not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update instance role", err)
+		return
+	}
+	role.Permissions = permissions.InstanceNames(uint64(rolePerms))
+	h.logStaffAction(r, models.StaffActionInstanceRoleUpdate, "instance_role", role.ID, before, role, nil)
+	apiutil.WriteJSON(w, http.StatusOK, role)
+}
+
+// HandleDeleteInstanceRole deletes an instance role and removes it from every
+// user holding it.
+// DELETE /api/v1/admin/instance-roles/{roleID}
+func (h *Handler) HandleDeleteInstanceRole(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	roleID := chi.URLParam(r, "roleID")
+
+	var role instanceRole
+	var perms int64
+	err := h.Pool.QueryRow(r.Context(),
+		`DELETE FROM instance_roles WHERE id = $1
+		 RETURNING id, name, description, permissions, created_at`, roleID).Scan(
+		&role.ID, &role.Name, &role.Description, &perms, &role.CreatedAt)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.RoleNotFound, "Instance role not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete instance role", err)
+		return
+	}
+	role.Permissions = permissions.InstanceNames(uint64(perms))
+	h.logStaffAction(r, models.StaffActionInstanceRoleDelete, "instance_role", roleID, role, nil, nil)
+	apiutil.WriteNoContent(w)
+}
+
+// HandleGetUserInstanceRoles handles GET /api/v1/admin/users/{userID}/instance-roles.
+func (h *Handler) HandleGetUserInstanceRoles(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	userID := chi.URLParam(r, "userID")
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT ir.id, ir.name, ir.description, ir.permissions, ir.created_at
+		 FROM user_instance_roles uir
+		 JOIN instance_roles ir ON ir.id = uir.role_id
+		 WHERE uir.user_id = $1 ORDER BY ir.name`, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list user instance roles", err)
+		return
+	}
+	defer rows.Close()
+
+	roles := make([]instanceRole, 0)
+	for rows.Next() {
+		var role instanceRole
+		var perms int64
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &perms, &role.CreatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read user instance roles", err)
+			return
+		}
+		role.Permissions = permissions.InstanceNames(uint64(perms))
+		roles = append(roles, role)
+	}
+	apiutil.WriteJSON(w, http.StatusOK, roles)
+}
+
+// HandleAssignInstanceRole grants an instance role to a user.
+// PUT /api/v1/admin/users/{userID}/instance-roles/{roleID}
+func (h *Handler) HandleAssignInstanceRole(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	adminID := auth.UserIDFromContext(r.Context())
+	userID := chi.URLParam(r, "userID")
+	roleID := chi.URLParam(r, "roleID")
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO user_instance_roles (user_id, role_id, assigned_by)
+		 SELECT u.id, ir.id, $3 FROM users u, instance_roles ir
+		 WHERE u.id = $1 AND ir.id = $2
+		 ON CONFLICT DO NOTHING`, userID, roleID, adminID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to assign instance role", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM user_instance_roles WHERE user_id = $1 AND role_id = $2)`,
+			userID, roleID).Scan(&exists)
+		if !exists {
+			apiutil.WriteCode(w, apierrors.NotFound, "User or instance role not found")
+			return
+		}
+	} else {
+		h.logStaffAction(r, models.StaffActionInstanceRoleAssign, "user", userID,
+			nil, map[string]string{"role_id": roleID}, nil)
+	}
+	apiutil.WriteNoContent(w)
+}
+
+// HandleUnassignInstanceRole removes an instance role from a user.
+// DELETE /api/v1/admin/users/{userID}/instance-roles/{roleID}
+func (h *Handler) HandleUnassignInstanceRole(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	userID := chi.URLParam(r, "userID")
+	roleID := chi.URLParam(r, "roleID")
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM user_instance_roles WHERE user_id = $1 AND role_id = $2`, userID, roleID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to remove instance role", err)
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		h.logStaffAction(r, models.StaffActionInstanceRoleRevoke, "user", userID,
+			map[string]string{"role_id": roleID}, nil, nil)
+	}
+	apiutil.WriteNoContent(w)
+}
@@ -0,0 +1,245 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// =============================================================================
+// Instance Retention
+// =============================================================================
+
+// maxInstanceRetentionDays matches the cap on retention policies.
+const maxInstanceRetentionDays = 36500
+
+// HandleGetInstanceRetention returns the instance-wide retention windows, in
+// days. Zero keeps data forever.
+// GET /api/v1/admin/instance-retention
+func (h *Handler) HandleGetInstanceRetention(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	ret, err := apiutil.GetInstanceRetention(r.Context(), h.Pool)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get instance retention", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, ret)
+}
+
+// HandleUpdateInstanceRetention sets how long DMs and the data of deleted
+// accounts are kept. Omitted fields are left unchanged; zero turns a window
+// off. Users and guilds under legal hold are never purged.
+// PATCH /api/v1/admin/instance-retention
+func (h *Handler) HandleUpdateInstanceRetention(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		DMDays             *int `json:"dm_retention_days"`
+		DeletedAccountDays *int `json:"deleted_account_retention_days"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	for _, days := range []*int{req.DMDays, req.DeletedAccountDays} {
+		if days != nil && (*days < 0 || *days > maxInstanceRetentionDays) {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_retention",
+				"Retention must be between 0 (keep forever) and 36500 days")
+			return
+		}
+	}
+
+	before, err := apiutil.GetInstanceRetention(r.Context(), h.Pool)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get instance retention", err)
+		return
+	}
+
+	after := before
+	updates := map[string]int{}
+	if req.DMDays != nil {
+		after.DMDays = *req.DMDays
+		updates[apiutil.DMRetentionSetting] = *req.DMDays
+	}
+	if req.DeletedAccountDays != nil {
+		after.DeletedAccountDays = *req.DeletedAccountDays
+		updates[apiutil.DeletedAccountRetentionSetting] = *req.DeletedAccountDays
+	}
+
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		for key, days := range updates {
+			if _, err := tx.Exec(r.Context(),
+				`INSERT INTO instance_settings (key, value, updated_at) VALUES ($1, $2, now())
+				 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+				key, strconv.Itoa(days)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update instance retention", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionRetentionUpdate, "instance_setting", "retention", before, after, nil)
+	apiutil.WriteJSON(w, http.StatusOK, after)
+}
+
+// =============================================================================
+// Legal Holds
+// =============================================================================
+
+const legalHoldColumns = `lh.id, lh.target_type, lh.target_id,
+	CASE lh.target_type WHEN 'user' THEN (SELECT username FROM users WHERE id = lh.target_id)
+	                    ELSE (SELECT name FROM guilds WHERE id = lh.target_id) END,
+	lh.reason, lh.created_by, lh.created_at, lh.released_by, lh.released_at`
+
+func scanLegalHold(row pgx.Row) (models.LegalHold, error) {
+	var hold models.LegalHold
+	err := row.Scan(&hold.ID, &hold.TargetType, &hold.TargetID, &hold.TargetName,
+		&hold.Reason, &hold.CreatedBy, &hold.CreatedAt, &hold.ReleasedBy, &hold.ReleasedAt)
+	return hold, err
+}
+
+// HandleListLegalHolds lists active legal holds, newest first. Pass
+// ?include_released=true to include released ones.
+// GET /api/v1/admin/legal-holds
+func (h *Handler) HandleListLegalHolds(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	includeReleased := r.URL.Query().Get("include_released") == "true"
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+legalHoldColumns+` FROM legal_holds lh
+		 WHERE $1 OR lh.released_at IS NULL
+		 ORDER BY lh.id DESC`, includeReleased)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list legal holds", err)
+		return
+	}
+	defer rows.Close()
+
+	holds := make([]models.LegalHold, 0)
+	for rows.Next() {
+		hold, err := scanLegalHold(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read legal holds", err)
+			return
+		}
+		holds = append(holds, hold)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, holds)
+}
+
+// HandleCreateLegalHold places a user or guild under legal hold. Their
+// messages are exempt from every purge and their exports are frozen until the
+// hold is released.
+// POST /api/v1/admin/legal-holds
+func (h *Handler) HandleCreateLegalHold(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		TargetType string `json:"target_type"`
+		TargetID   string `json:"target_id"`
+		Reason     string `json:"reason"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || len(req.Reason) > 1000 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_reason", "A reason of at most 1000 characters is required")
+		return
+	}
+
+	var exists bool
+	switch req.TargetType {
+	case "user":
+		h.Pool.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, req.TargetID).Scan(&exists)
+	case "guild":
+		h.Pool.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM guilds WHERE id = $1)`, req.TargetID).Scan(&exists)
+	default:
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_target_type", "target_type must be user or guild")
+		return
+	}
+	if !exists {
+		apiutil.WriteError(w, http.StatusNotFound, "target_not_found", "No such "+req.TargetType)
+		return
+	}
+
+	holdID := models.NewULID().String()
+	_, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO legal_holds (id, target_type, target_id, reason, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, now())`,
+		holdID, req.TargetType, req.TargetID, req.Reason, auth.UserIDFromContext(r.Context()))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			apiutil.WriteError(w, http.StatusConflict, "already_held", "This "+req.TargetType+" is already under legal hold")
+			return
+		}
+		apiutil.InternalError(w, h.Logger, "Failed to create legal hold", err)
+		return
+	}
+
+	hold, err := scanLegalHold(h.Pool.QueryRow(r.Context(),
+		`SELECT `+legalHoldColumns+` FROM legal_holds lh WHERE lh.id = $1`, holdID))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read legal hold", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionLegalHoldCreate, req.TargetType, req.TargetID, nil, hold, &req.Reason)
+	apiutil.WriteJSON(w, http.StatusCreated, hold)
+}
+
+// HandleReleaseLegalHold releases a legal hold. The record is kept; the
+// target's data becomes subject to purges and exports again.
+// DELETE /api/v1/admin/legal-holds/{holdID}
+func (h *Handler) HandleReleaseLegalHold(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	holdID := chi.URLParam(r, "holdID")
+	hold, err := scanLegalHold(h.Pool.QueryRow(r.Context(),
+		`UPDATE legal_holds lh SET released_by = $2, released_at = now()
+		 WHERE lh.id = $1 AND lh.released_at IS NULL
+		 RETURNING `+legalHoldColumns,
+		holdID, auth.UserIDFromContext(r.Context())))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.NotFound, "No active legal hold with that ID")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to release legal hold", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionLegalHoldRelease, hold.TargetType, hold.TargetID, nil, hold, nil)
+	apiutil.WriteJSON(w, http.StatusOK, hold)
+}
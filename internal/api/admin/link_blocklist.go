@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/linksafety"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// HandleGetLinkBlocklist lists the domains whose links are rewritten to the
+// warning interstitial, newest first.
+// GET /api/v1/admin/link-blocklist
+func (h *Handler) HandleGetLinkBlocklist(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT domain, reason, created_by, created_at FROM link_blocklist ORDER BY created_at DESC`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list link blocklist", err)
+		return
+	}
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.LinkBlocklistEntry, error) {
+		var e models.LinkBlocklistEntry
+		err := row.Scan(&e.Domain, &e.Reason, &e.CreatedBy, &e.CreatedAt)
+		return e, err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read link blocklist", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, entries)
+}
+
+// HandleAddLinkBlocklistDomain blocks a domain and its subdomains. The
+// domain may be given as a link; only its host is kept. Messages already
+// posted are not rechecked.
+// POST /api/v1/admin/link-blocklist
+func (h *Handler) HandleAddLinkBlocklistDomain(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		Domain string  `json:"domain"`
+		Reason *string `json:"reason"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	domain, ok := linksafety.NormalizeDomain(req.Domain)
+	if !ok {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_domain", "domain must be a domain name such as scam.example")
+		return
+	}
+
+	e := models.LinkBlocklistEntry{Domain: domain, Reason: req.Reason}
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO link_blocklist (domain, reason, created_by, created_at)
+		 VALUES ($1, $2, $3, now())
+		 RETURNING created_by, created_at`,
+		domain, req.Reason, auth.UserIDFromContext(r.Context()),
+	).Scan(&e.CreatedBy, &e.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		apiutil.WriteError(w, http.StatusConflict, "already_blocked", "That domain is already on the blocklist")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to add blocklist domain", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionLinkBlocklistAdd, "link_domain", domain, nil, e, req.Reason)
+	apiutil.WriteJSON(w, http.StatusCreated, e)
+}
+
+// HandleRemoveLinkBlocklistDomain takes a domain off the blocklist. Links
+// already rewritten stay rewritten.
+// DELETE /api/v1/admin/link-blocklist/{domain}
+func (h *Handler) HandleRemoveLinkBlocklistDomain(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	domain, ok := linksafety.NormalizeDomain(chi.URLParam(r, "domain"))
+	if !ok {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_domain", "Invalid domain")
+		return
+	}
+	tag, err := h.Pool.Exec(r.Context(), `DELETE FROM link_blocklist WHERE domain = $1`, domain)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to remove blocklist domain", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "domain_not_found", "That domain is not on the blocklist")
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionLinkBlocklistRemove, "link_domain", domain, nil, nil, nil)
+	apiutil.WriteNoContent(w)
+}
@@ -0,0 +1,348 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// motdSetting is the instance_settings key holding the message of the day.
+const motdSetting = "motd"
+
+const (
+	maxMaintenanceTitleLength   = 100
+	maxMaintenanceMessageLength = 2000
+	maxMaintenanceDuration      = 7 * 24 * time.Hour
+	maxMOTDLength               = 500
+)
+
+const maintenanceColumns = `id, title, message, starts_at, ends_at, read_only, created_by, created_at, cancelled_at`
+
+func scanMaintenanceWindow(row pgx.Row) (models.MaintenanceWindow, error) {
+	var mw models.MaintenanceWindow
+	err := row.Scan(&mw.ID, &mw.Title, &mw.Message, &mw.StartsAt, &mw.EndsAt,
+		&mw.ReadOnly, &mw.CreatedBy, &mw.CreatedAt, &mw.CancelledAt)
+	return mw, err
+}
+
+// validateMaintenanceWindow checks a window and returns a message safe to
+// return to the caller, or "".
+func validateMaintenanceWindow(mw models.MaintenanceWindow, now time.Time) string {
+	if mw.Title == "" || len(mw.Title) > maxMaintenanceTitleLength {
+		return fmt.Sprintf("title must be 1-%d characters", maxMaintenanceTitleLength)
+	}
+	if len(mw.Message) > maxMaintenanceMessageLength {
+		return fmt.Sprintf("message must be at most %d characters", maxMaintenanceMessageLength)
+	}
+	if !mw.EndsAt.After(mw.StartsAt) {
+		return "ends_at must be after starts_at"
+	}
+	if !mw.EndsAt.After(now) {
+		return "ends_at must be in the future"
+	}
+	if mw.EndsAt.Sub(mw.StartsAt) > maxMaintenanceDuration {
+		return "Maintenance windows can last at most 7 days"
+	}
+	return ""
+}
+
+// publishMaintenance tells connected clients a window was scheduled, changed
+// or cancelled.
+func (h *Handler) publishMaintenance(ctx context.Context, mw models.MaintenanceWindow) {
+	if h.EventBus != nil {
+		h.EventBus.PublishBroadcastEvent(ctx, events.SubjectMaintenance, "MAINTENANCE_SCHEDULE", mw)
+	}
+}
+
+// HandleGetInstanceStatus reports whether the instance is in maintenance,
+// the windows scheduled next, switched-off subsystems and the message of the
+// day. It needs no login so clients can show it on the login page and while
+// the API is read-only.
+// GET /api/v1/instance/status
+func (h *Handler) HandleGetInstanceStatus(w http.ResponseWriter, r *http.Request) {
+	st := models.InstanceStatus{Status: "ok", Upcoming: []models.MaintenanceWindow{}}
+	h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = $1`, motdSetting).Scan(&st.MOTD)
+	disabled, _ := apiutil.LoadDisabledSubsystems(r.Context(), h.Pool)
+	st.DisabledSubsystems = disabled.Names()
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+maintenanceColumns+` FROM maintenance_windows
+		 WHERE cancelled_at IS NULL AND ends_at > now()
+		 ORDER BY starts_at, id LIMIT 10`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get instance status", err)
+		return
+	}
+	defer rows.Close()
+	now := time.Now()
+	for rows.Next() {
+		mw, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			continue
+		}
+		if mw.Active(now) {
+			// Overlapping windows: report the read-only one.
+			if st.Maintenance == nil || (mw.ReadOnly && !st.Maintenance.ReadOnly) {
+				st.Maintenance = &mw
+			}
+			continue
+		}
+		st.Upcoming = append(st.Upcoming, mw)
+	}
+	if st.Maintenance != nil {
+		st.Status = "maintenance"
+		if st.Maintenance.ReadOnly {
+			st.Status = "read_only"
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	apiutil.WriteJSON(w, http.StatusOK, st)
+}
+
+// HandleListMaintenance returns recent and scheduled maintenance windows,
+// including cancelled ones.
+// GET /api/v1/admin/maintenance
+func (h *Handler) HandleListMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+maintenanceColumns+` FROM maintenance_windows
+		 ORDER BY starts_at DESC, id DESC LIMIT 50`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list maintenance windows", err)
+		return
+	}
+	defer rows.Close()
+
+	windows := make([]models.MaintenanceWindow, 0)
+	for rows.Next() {
+		mw, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			continue
+		}
+		windows = append(windows, mw)
+	}
+	apiutil.WriteJSON(w, http.StatusOK, windows)
+}
+
+// HandleCreateMaintenance schedules a maintenance window. starts_at defaults
+// to now, starting maintenance immediately.
+// POST /api/v1/admin/maintenance
+func (h *Handler) HandleCreateMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		Title    string     `json:"title"`
+		Message  string     `json:"message"`
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   time.Time  `json:"ends_at"`
+		ReadOnly bool       `json:"read_only"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	now := time.Now()
+	mw := models.MaintenanceWindow{
+		Title:    req.Title,
+		Message:  req.Message,
+		StartsAt: now,
+		EndsAt:   req.EndsAt,
+		ReadOnly: req.ReadOnly,
+	}
+	if req.StartsAt != nil {
+		mw.StartsAt = *req.StartsAt
+	}
+	if msg := validateMaintenanceWindow(mw, now); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_maintenance_window", msg)
+		return
+	}
+
+	adminID := auth.UserIDFromContext(r.Context())
+	mw, err := scanMaintenanceWindow(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO maintenance_windows (id, title, message, starts_at, ends_at, read_only, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING `+maintenanceColumns,
+		models.NewULID().String(), mw.Title, mw.Message, mw.StartsAt, mw.EndsAt, mw.ReadOnly, adminID))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to schedule maintenance", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "maintenance_window", mw.ID, nil, mw, nil)
+	h.publishMaintenance(r.Context(), mw)
+	apiutil.WriteJSON(w, http.StatusCreated, mw)
+}
+
+// HandleUpdateMaintenance changes a window that has not ended, for example
+// to extend it while a migration overruns.
+// PATCH /api/v1/admin/maintenance/{windowID}
+func (h *Handler) HandleUpdateMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	windowID := chi.URLParam(r, "windowID")
+	var req struct {
+		Title    *string    `json:"title"`
+		Message  *string    `json:"message"`
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   *time.Time `json:"ends_at"`
+		ReadOnly *bool      `json:"read_only"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	before, err := scanMaintenanceWindow(h.Pool.QueryRow(r.Context(),
+		`SELECT `+maintenanceColumns+` FROM maintenance_windows WHERE id = $1`, windowID))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.NotFound, "Maintenance window not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get maintenance window", err)
+		return
+	}
+	now := time.Now()
+	if before.CancelledAt != nil || !before.EndsAt.After(now) {
+		apiutil.WriteError(w, http.StatusConflict, "maintenance_over", "This maintenance window has already ended or been cancelled")
+		return
+	}
+
+	mw := before
+	if req.Title != nil {
+		mw.Title = *req.Title
+	}
+	if req.Message != nil {
+		mw.Message = *req.Message
+	}
+	if req.StartsAt != nil {
+		mw.StartsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil {
+		mw.EndsAt = *req.EndsAt
+	}
+	if req.ReadOnly != nil {
+		mw.ReadOnly = *req.ReadOnly
+	}
+	if msg := validateMaintenanceWindow(mw, now); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_maintenance_window", msg)
+		return
+	}
+
+	mw, err = scanMaintenanceWindow(h.Pool.QueryRow(r.Context(),
+		`UPDATE maintenance_windows
+		 SET title = $2, message = $3, starts_at = $4, ends_at = $5, read_only = $6
+		 WHERE id = $1
+		 RETURNING `+maintenanceColumns,
+		windowID, mw.Title, mw.Message, mw.StartsAt, mw.EndsAt, mw.ReadOnly))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update maintenance window", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "maintenance_window", mw.ID, before, mw, nil)
+	h.publishMaintenance(r.Context(), mw)
+	apiutil.WriteJSON(w, http.StatusOK, mw)
+}
+
+// HandleCancelMaintenance cancels a window. Cancelling an active window ends
+// maintenance, and read-only mode with it, immediately.
+// DELETE /api/v1/admin/maintenance/{windowID}
+func (h *Handler) HandleCancelMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	windowID := chi.URLParam(r, "windowID")
+	mw, err := scanMaintenanceWindow(h.Pool.QueryRow(r.Context(),
+		`UPDATE maintenance_windows SET cancelled_at = now()
+		 WHERE id = $1 AND cancelled_at IS NULL AND ends_at > now()
+		 RETURNING `+maintenanceColumns, windowID))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.NotFound, "No pending or active maintenance window with this ID")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to cancel maintenance window", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "maintenance_window", mw.ID, nil, mw, nil)
+	h.publishMaintenance(r.Context(), mw)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetMOTD returns the instance message of the day.
+// GET /api/v1/admin/motd
+func (h *Handler) HandleGetMOTD(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var motd string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = $1`, motdSetting).Scan(&motd)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"motd": motd})
+}
+
+// HandleUpdateMOTD sets the message of the day; an empty string clears it.
+// PUT /api/v1/admin/motd
+func (h *Handler) HandleUpdateMOTD(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		MOTD string `json:"motd"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.MOTD) > maxMOTDLength {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_motd",
+			fmt.Sprintf("motd must be at most %d characters", maxMOTDLength))
+		return
+	}
+
+	var before string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = $1`, motdSetting).Scan(&before)
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_settings (key, value, updated_at) VALUES ($1, $2, now())
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+		motdSetting, req.MOTD); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update motd", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", motdSetting, before, req.MOTD, nil)
+	if h.EventBus != nil {
+		h.EventBus.PublishBroadcastEvent(r.Context(), events.SubjectMOTDUpdate, "MOTD_UPDATE",
+			map[string]string{"motd": req.MOTD})
+	}
+	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"motd": req.MOTD})
+}
@@ -0,0 +1,212 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// adminPolicy is a policy version with how many users acknowledged it.
+type adminPolicy struct {
+	models.InstancePolicy
+	Acknowledgments int `json:"acknowledgments"`
+}
+
+// HandleListPolicies lists every published policy version, newest first.
+// Pass ?kind=terms or ?kind=rules to list one kind.
+// GET /api/v1/admin/policies
+func (h *Handler) HandleListPolicies(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var kind *string
+	if v := r.URL.Query().Get("kind"); v != "" {
+		kind = &v
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT p.id, p.kind, p.version, p.title, p.content, p.requires_acknowledgment,
+		        p.published_by, p.published_at,
+		        (SELECT COUNT(*) FROM instance_policy_acknowledgments a WHERE a.policy_id = p.id)
+		 FROM instance_policies p
+		 WHERE $1::text IS NULL OR p.kind = $1
+		 ORDER BY p.kind, p.version DESC`, kind)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list policies", err)
+		return
+	}
+	defer rows.Close()
+
+	policies := []adminPolicy{}
+	for rows.Next() {
+		var p adminPolicy
+		if err := rows.Scan(&p.ID, &p.Kind, &p.Version, &p.Title, &p.Content, &p.RequiresAcknowledgment,
+			&p.PublishedBy, &p.PublishedAt, &p.Acknowledgments); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read policies", err)
+			return
+		}
+		policies = append(policies, p)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, policies)
+}
+
+// HandlePublishPolicy publishes a new version of the terms or rules. Unless
+// requires_acknowledgment is false, every user has to acknowledge it before
+// they can use the API again.
+// POST /api/v1/admin/policies
+func (h *Handler) HandlePublishPolicy(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		Kind                   string `json:"kind"`
+		Title                  string `json:"title"`
+		Content                string `json:"content"`
+		RequiresAcknowledgment *bool  `json:"requires_acknowledgment"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Kind != models.PolicyKindTerms && req.Kind != models.PolicyKindRules {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_kind", "kind must be terms or rules")
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" || len(req.Title) > 200 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_title", "Title must be 1-200 characters")
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" || len(req.Content) > 100000 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_content", "Content must be 1-100000 characters")
+		return
+	}
+	requiresAck := req.RequiresAcknowledgment == nil || *req.RequiresAcknowledgment
+
+	p := models.InstancePolicy{
+		ID:                     models.NewULID().String(),
+		Kind:                   req.Kind,
+		Title:                  req.Title,
+		Content:                req.Content,
+		RequiresAcknowledgment: requiresAck,
+	}
+	publisher := auth.UserIDFromContext(r.Context())
+	p.PublishedBy = &publisher
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO instance_policies (id, kind, version, title, content, requires_acknowledgment, published_by, published_at)
+		 SELECT $1, $2, COALESCE(MAX(version), 0) + 1, $3, $4, $5, $6, now()
+		 FROM instance_policies WHERE kind = $2
+		 RETURNING version, published_at`,
+		p.ID, p.Kind, p.Title, p.Content, p.RequiresAcknowledgment, publisher,
+	).Scan(&p.Version, &p.PublishedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			apiutil.WriteError(w, http.StatusConflict, "version_conflict", "Another version was published at the same time")
+			return
+		}
+		apiutil.InternalError(w, h.Logger, "Failed to publish policy", err)
+		return
+	}
+
+	apiutil.InvalidateRequiredPolicies(r.Context(), h.Cache, h.Logger)
+	h.logStaffAction(r, models.StaffActionPolicyPublish, "policy", p.ID, nil, map[string]interface{}{
+		"kind": p.Kind, "version": p.Version, "requires_acknowledgment": p.RequiresAcknowledgment,
+	}, nil)
+	if h.EventBus != nil {
+		h.EventBus.PublishBroadcastEvent(r.Context(), events.SubjectPolicyPublish, "POLICY_PUBLISH", map[string]interface{}{
+			"id":                      p.ID,
+			"kind":                    p.Kind,
+			"version":                 p.Version,
+			"requires_acknowledgment": p.RequiresAcknowledgment,
+		})
+	}
+
+	apiutil.WriteJSON(w, http.StatusCreated, p)
+}
+
+// HandleGetPolicyAcknowledgments lists who acknowledged a policy version and
+// when, newest first. Page with ?before=<RFC 3339 time> and ?limit=.
+// GET /api/v1/admin/policies/{policyID}/acknowledgments
+func (h *Handler) HandleGetPolicyAcknowledgments(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+	policyID := chi.URLParam(r, "policyID")
+
+	limit := 100
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 500 {
+		limit = v
+	}
+	before := time.Now().Add(time.Minute)
+	if v := r.URL.Query().Get("before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_before", "before must be an RFC 3339 time")
+			return
+		}
+		before = t
+	}
+
+	h.writeAcknowledgments(w, r,
+		`WHERE a.policy_id = $1 AND a.acknowledged_at < $2
+		 ORDER BY a.acknowledged_at DESC LIMIT $3`, policyID, before, limit)
+}
+
+// HandleGetUserPolicyAcknowledgments lists every policy version a user has
+// acknowledged.
+// GET /api/v1/admin/users/{userID}/policy-acknowledgments
+func (h *Handler) HandleGetUserPolicyAcknowledgments(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	h.writeAcknowledgments(w, r,
+		`WHERE a.user_id = $1 ORDER BY a.acknowledged_at DESC`, chi.URLParam(r, "userID"))
+}
+
+// writeAcknowledgments writes the acknowledgments selected by the given
+// WHERE/ORDER clause.
+func (h *Handler) writeAcknowledgments(w http.ResponseWriter, r *http.Request, clause string, args ...interface{}) {
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT a.user_id, u.username, a.policy_id, p.kind, p.version, a.acknowledged_at, a.ip_address
+		 FROM instance_policy_acknowledgments a
+		 JOIN instance_policies p ON p.id = a.policy_id
+		 JOIN users u ON u.id = a.user_id
+		 `+clause, args...)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list policy acknowledgments", err)
+		return
+	}
+	defer rows.Close()
+
+	acks := []models.PolicyAcknowledgment{}
+	for rows.Next() {
+		var a models.PolicyAcknowledgment
+		if err := rows.Scan(&a.UserID, &a.Username, &a.PolicyID, &a.Kind, &a.Version,
+			&a.AcknowledgedAt, &a.IPAddress); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read policy acknowledgments", err)
+			return
+		}
+		acks = append(acks, a)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, acks)
+}
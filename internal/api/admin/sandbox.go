@@ -0,0 +1,227 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/federation"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// =============================================================================
+// Federation Sandbox
+// =============================================================================
+
+// HandleGetFederationSandbox reports whether outbound federation is in
+// dry-run mode, which peers are sandbox peers, and how many events the
+// sandbox holds.
+// GET /api/v1/admin/federation/sandbox
+func (h *Handler) HandleGetFederationSandbox(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+	ctx := r.Context()
+
+	var value string
+	h.Pool.QueryRow(ctx,
+		`SELECT value FROM instance_settings WHERE key = $1`, federation.DryRunSetting).Scan(&value)
+
+	var outbound, inbound int64
+	if err := h.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FILTER (WHERE direction = 'outbound'), COUNT(*) FILTER (WHERE direction = 'inbound')
+		 FROM federation_sandbox_events`).Scan(&outbound, &inbound); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to count sandbox events", err)
+		return
+	}
+
+	rows, err := h.Pool.Query(ctx,
+		`SELECT fp.peer_id, i.domain FROM federation_peers fp
+		 JOIN instances i ON i.id = fp.peer_id
+		 WHERE fp.instance_id = $1 AND fp.sandbox
+		 ORDER BY i.domain`, h.InstanceID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get sandbox peers", err)
+		return
+	}
+	defer rows.Close()
+
+	peers := make([]map[string]string, 0)
+	for rows.Next() {
+		var peerID, domain string
+		if err := rows.Scan(&peerID, &domain); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read sandbox peers", err)
+			return
+		}
+		peers = append(peers, map[string]string{"peer_id": peerID, "domain": domain})
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"dry_run":         value == "true",
+		"sandbox_peers":   peers,
+		"outbound_events": outbound,
+		"inbound_events":  inbound,
+	})
+}
+
+// HandleUpdateFederationSandbox turns outbound dry-run mode on or off.
+// While on, events for peers are signed and recorded in the sandbox instead
+// of being delivered.
+// PUT /api/v1/admin/federation/sandbox
+func (h *Handler) HandleUpdateFederationSandbox(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	var req struct {
+		DryRun *bool   `json:"dry_run"`
+		Reason *string `json:"reason,omitempty"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.DryRun == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "missing_dry_run", "dry_run is required")
+		return
+	}
+
+	var before string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = $1`, federation.DryRunSetting).Scan(&before)
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_settings (key, value, updated_at) VALUES ($1, $2, now())
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+		federation.DryRunSetting, strconv.FormatBool(*req.DryRun)); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update federation dry run", err)
+		return
+	}
+	if h.FedSvc != nil {
+		h.FedSvc.InvalidateSandboxCache(federation.DryRunSetting)
+	}
+
+	h.logStaffAction(r, models.StaffActionFederationSandbox, "instance_setting", federation.DryRunSetting,
+		map[string]bool{"dry_run": before == "true"}, map[string]bool{"dry_run": *req.DryRun}, req.Reason)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]bool{"dry_run": *req.DryRun})
+}
+
+// HandleUpdatePeerSandbox marks a peer as a sandbox peer or back as a live
+// one. Events from a sandbox peer are verified as usual but only recorded
+// in the sandbox.
+// PUT /api/v1/admin/federation/peers/{peerID}/sandbox
+func (h *Handler) HandleUpdatePeerSandbox(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	peerID := chi.URLParam(r, "peerID")
+
+	var req struct {
+		Sandbox *bool   `json:"sandbox"`
+		Reason  *string `json:"reason,omitempty"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Sandbox == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "missing_sandbox", "sandbox is required")
+		return
+	}
+
+	var before bool
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT sandbox FROM federation_peers WHERE instance_id = $1 AND peer_id = $2`,
+		h.InstanceID, peerID).Scan(&before)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "peer_not_found", "Federation peer not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get peer sandbox flag", err)
+		return
+	}
+
+	if _, err := h.Pool.Exec(r.Context(),
+		`UPDATE federation_peers SET sandbox = $3 WHERE instance_id = $1 AND peer_id = $2`,
+		h.InstanceID, peerID, *req.Sandbox); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update peer sandbox flag", err)
+		return
+	}
+	if h.FedSvc != nil {
+		h.FedSvc.InvalidateSandboxCache(peerID)
+	}
+
+	h.logStaffAction(r, models.StaffActionFederationSandbox, "instance", peerID,
+		map[string]bool{"sandbox": before}, map[string]bool{"sandbox": *req.Sandbox}, req.Reason)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"peer_id": peerID,
+		"sandbox": *req.Sandbox,
+	})
+}
+
+// HandleGetSandboxEvents lists sandbox events, newest first. Optional
+// ?direction=outbound|inbound and ?peer_id= filter them; ?limit= defaults to
+// 50 and is capped at 200.
+// GET /api/v1/admin/federation/sandbox/events
+func (h *Handler) HandleGetSandboxEvents(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	direction := r.URL.Query().Get("direction")
+	if direction != "" && direction != "outbound" && direction != "inbound" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_direction", "direction must be outbound or inbound")
+		return
+	}
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT e.id, e.direction, e.peer_id, COALESCE(NULLIF(e.domain, ''), i.domain, ''),
+		        e.event_type, e.guild_id, e.channel_id, e.payload, e.created_at
+		 FROM federation_sandbox_events e
+		 LEFT JOIN instances i ON i.id = e.peer_id
+		 WHERE ($1 = '' OR e.direction = $1) AND ($2 = '' OR e.peer_id = $2)
+		 ORDER BY e.created_at DESC
+		 LIMIT $3`, direction, r.URL.Query().Get("peer_id"), limit)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get sandbox events", err)
+		return
+	}
+	defer rows.Close()
+
+	sandboxEvents := make([]models.FederationSandboxEvent, 0)
+	for rows.Next() {
+		var e models.FederationSandboxEvent
+		if err := rows.Scan(&e.ID, &e.Direction, &e.PeerID, &e.Domain,
+			&e.EventType, &e.GuildID, &e.ChannelID, &e.Payload, &e.CreatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read sandbox events", err)
+			return
+		}
+		sandboxEvents = append(sandboxEvents, e)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, sandboxEvents)
+}
+
+// HandleClearSandboxEvents deletes every event in the sandbox.
+// DELETE /api/v1/admin/federation/sandbox/events
+func (h *Handler) HandleClearSandboxEvents(w http.ResponseWriter, r *http.Request) {
+	if !h.requireInstancePermission(w, r, permissions.InstanceManageFederation) {
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(), `DELETE FROM federation_sandbox_events`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to clear sandbox events", err)
+		return
+	}
+	h.logStaffAction(r, models.StaffActionSandboxClear, "instance", h.InstanceID, nil,
+		map[string]int64{"events_deleted": tag.RowsAffected()}, nil)
+	apiutil.WriteNoContent(w)
+}
@@ -16,9 +16,11 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
 )
 
 // =============================================================================
@@ -61,7 +63,7 @@ func (h *Handler) HandleCompleteSetup(w http.ResponseWriter, r *http.Request) {
 	if completed == "true" {
 		// Already completed — require admin.
 		if !h.isAdmin(r) {
-			apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Setup already completed. Admin access required to reconfigure.")
+			apiutil.WriteCode(w, apierrors.Forbidden, "Setup already completed. Admin access required to reconfigure.")
 			return
 		}
 	}
@@ -121,6 +123,14 @@ func (h *Handler) HandleCompleteSetup(w http.ResponseWriter, r *http.Request) {
 		 ON CONFLICT (key) DO UPDATE SET value = $1, updated_at = now()`,
 		time.Now().UTC().Format(time.RFC3339))
 
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance", h.InstanceID, nil, map[string]string{
+		"instance_name":     req.InstanceName,
+		"description":       req.Description,
+		"federation_mode":   req.FederationMode,
+		"registration_mode": req.RegistrationMode,
+		"domain":            req.Domain,
+	}, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"status":  "completed",
 		"message": "Instance setup completed successfully",
@@ -135,7 +145,7 @@ func (h *Handler) HandleCompleteSetup(w http.ResponseWriter, r *http.Request) {
 // GET /api/v1/admin/updates/check
 func (h *Handler) HandleCheckUpdates(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -182,7 +192,7 @@ func (h *Handler) HandleCheckUpdates(w http.ResponseWriter, r *http.Request) {
 // POST /api/v1/admin/updates/set-latest
 func (h *Handler) HandleSetLatestVersion(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -212,6 +222,8 @@ func (h *Handler) HandleSetLatestVersion(w http.ResponseWriter, r *http.Request)
 			 ON CONFLICT (key) DO UPDATE SET value = $2, updated_at = now()`, k, v)
 	}
 
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", "update_latest_version", nil, req, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
@@ -219,7 +231,7 @@ func (h *Handler) HandleSetLatestVersion(w http.ResponseWriter, r *http.Request)
 // POST /api/v1/admin/updates/dismiss
 func (h *Handler) HandleDismissUpdate(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -231,6 +243,7 @@ func (h *Handler) HandleDismissUpdate(w http.ResponseWriter, r *http.Request) {
 		h.Pool.Exec(r.Context(),
 			`INSERT INTO instance_settings (key, value, updated_at) VALUES ('update_dismissed_version', $1, now())
 			 ON CONFLICT (key) DO UPDATE SET value = $1, updated_at = now()`, latestVersion)
+		h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", "update_dismissed_version", nil, latestVersion, nil)
 	}
 
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "dismissed"})
@@ -240,7 +253,7 @@ func (h *Handler) HandleDismissUpdate(w http.ResponseWriter, r *http.Request) {
 // GET /api/v1/admin/updates/config
 func (h *Handler) HandleGetUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -263,7 +276,7 @@ func (h *Handler) HandleGetUpdateConfig(w http.ResponseWriter, r *http.Request)
 // PATCH /api/v1/admin/updates/config
 func (h *Handler) HandleUpdateUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -308,6 +321,8 @@ func (h *Handler) HandleUpdateUpdateConfig(w http.ResponseWriter, r *http.Reques
 			 ON CONFLICT (key) DO UPDATE SET value = $1, updated_at = now()`, v)
 	}
 
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", "update_config", nil, req, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
@@ -319,7 +334,7 @@ func (h *Handler) HandleUpdateUpdateConfig(w http.ResponseWriter, r *http.Reques
 // GET /api/v1/admin/health
 func (h *Handler) HandleGetHealthDashboard(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -458,7 +473,7 @@ func (h *Handler) HandleGetHealthDashboard(w http.ResponseWriter, r *http.Reques
 // GET /api/v1/admin/health/history
 func (h *Handler) HandleGetHealthHistory(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -516,7 +531,7 @@ func (h *Handler) HandleGetHealthHistory(w http.ResponseWriter, r *http.Request)
 // GET /api/v1/admin/storage
 func (h *Handler) HandleGetStorageDashboard(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -680,7 +695,7 @@ func (h *Handler) HandleGetStorageDashboard(w http.ResponseWriter, r *http.Reque
 // GET /api/v1/admin/retention
 func (h *Handler) HandleGetRetentionPolicies(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -742,7 +757,7 @@ func (h *Handler) HandleGetRetentionPolicies(w http.ResponseWriter, r *http.Requ
 // POST /api/v1/admin/retention
 func (h *Handler) HandleCreateRetentionPolicy(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -800,7 +815,7 @@ func (h *Handler) HandleCreateRetentionPolicy(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	apiutil.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+	policy := map[string]interface{}{
 		"id":                 policyID,
 		"channel_id":         req.ChannelID,
 		"guild_id":           req.GuildID,
@@ -809,14 +824,17 @@ func (h *Handler) HandleCreateRetentionPolicy(w http.ResponseWriter, r *http.Req
 		"delete_pins":        deletePins,
 		"enabled":            enabled,
 		"next_run_at":        nextRun,
-	})
+	}
+	h.logStaffAction(r, models.StaffActionRetentionPolicyCreate, "retention_policy", policyID, nil, policy, nil)
+
+	apiutil.WriteJSON(w, http.StatusCreated, policy)
 }
 
 // HandleUpdateRetentionPolicy updates an existing retention policy.
 // PATCH /api/v1/admin/retention/{policyID}
 func (h *Handler) HandleUpdateRetentionPolicy(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -851,10 +869,12 @@ func (h *Handler) HandleUpdateRetentionPolicy(w http.ResponseWriter, r *http.Req
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Retention policy not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Retention policy not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionRetentionPolicyUpdate, "retention_policy", policyID, nil, req, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
@@ -862,7 +882,7 @@ func (h *Handler) HandleUpdateRetentionPolicy(w http.ResponseWriter, r *http.Req
 // DELETE /api/v1/admin/retention/{policyID}
 func (h *Handler) HandleDeleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -875,18 +895,21 @@ func (h *Handler) HandleDeleteRetentionPolicy(w http.ResponseWriter, r *http.Req
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Retention policy not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Retention policy not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionRetentionPolicyDelete, "retention_policy", policyID, nil, nil, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // HandleRunRetentionPolicy manually triggers a retention policy execution.
+// Messages under legal hold are kept.
 // POST /api/v1/admin/retention/{policyID}/run
 func (h *Handler) HandleRunRetentionPolicy(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -900,11 +923,11 @@ func (h *Handler) HandleRunRetentionPolicy(w http.ResponseWriter, r *http.Reques
 		 FROM data_retention_policies WHERE id = $1 AND enabled = true`, policyID).Scan(
 		&maxAgeDays, &channelID, &guildID, &deleteAttachments, &deletePins)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Retention policy not found or disabled")
+		apiutil.WriteCode(w, apierrors.NotFound, "Retention policy not found or disabled")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read retention policy")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to read retention policy")
 		return
 	}
 
@@ -914,9 +937,9 @@ func (h *Handler) HandleRunRetentionPolicy(w http.ResponseWriter, r *http.Reques
 
 	if channelID != nil {
 		// Channel-scoped.
-		baseQuery := `DELETE FROM messages WHERE channel_id = $1 AND created_at < $2`
+		baseQuery := `DELETE FROM messages m WHERE m.channel_id = $1 AND m.created_at < $2 AND ` + apiutil.NotOnLegalHoldSQL
 		if !deletePins {
-			baseQuery += ` AND id NOT IN (SELECT message_id FROM pins WHERE channel_id = $1)`
+			baseQuery += ` AND m.id NOT IN (SELECT message_id FROM pins WHERE channel_id = $1)`
 		}
 		tag, err := h.Pool.Exec(r.Context(), baseQuery, *channelID, cutoff)
 		if err == nil {
@@ -924,9 +947,9 @@ func (h *Handler) HandleRunRetentionPolicy(w http.ResponseWriter, r *http.Reques
 		}
 	} else if guildID != nil {
 		// Guild-scoped.
-		baseQuery := `DELETE FROM messages WHERE channel_id IN (SELECT id FROM channels WHERE guild_id = $1) AND created_at < $2`
+		baseQuery := `DELETE FROM messages m WHERE m.channel_id IN (SELECT id FROM channels WHERE guild_id = $1) AND m.created_at < $2 AND ` + apiutil.NotOnLegalHoldSQL
 		if !deletePins {
-			baseQuery += ` AND id NOT IN (SELECT message_id FROM pins)`
+			baseQuery += ` AND m.id NOT IN (SELECT message_id FROM pins)`
 		}
 		tag, err := h.Pool.Exec(r.Context(), baseQuery, *guildID, cutoff)
 		if err == nil {
@@ -943,11 +966,14 @@ func (h *Handler) HandleRunRetentionPolicy(w http.ResponseWriter, r *http.Reques
 		     updated_at = now()
 		 WHERE id = $2`, deleted, policyID)
 
-	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+	result := map[string]interface{}{
 		"policy_id":        policyID,
 		"messages_deleted": deleted,
 		"cutoff_date":      cutoff.Format(time.RFC3339),
-	})
+	}
+	h.logStaffAction(r, models.StaffActionRetentionPurge, "retention_policy", policyID, nil, result, nil)
+
+	apiutil.WriteJSON(w, http.StatusOK, result)
 }
 
 // =============================================================================
@@ -958,7 +984,7 @@ func (h *Handler) HandleRunRetentionPolicy(w http.ResponseWriter, r *http.Reques
 // GET /api/v1/admin/domains
 func (h *Handler) HandleGetCustomDomains(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1005,7 +1031,7 @@ func (h *Handler) HandleGetCustomDomains(w http.ResponseWriter, r *http.Request)
 // POST /api/v1/admin/domains
 func (h *Handler) HandleCreateCustomDomain(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1018,7 +1044,7 @@ func (h *Handler) HandleCreateCustomDomain(w http.ResponseWriter, r *http.Reques
 	}
 
 	if req.GuildID == "" || req.Domain == "" {
-		apiutil.WriteError(w, http.StatusBadRequest, "missing_fields", "guild_id and domain are required")
+		apiutil.WriteCode(w, apierrors.MissingFields, "guild_id and domain are required")
 		return
 	}
 
@@ -1034,14 +1060,14 @@ func (h *Handler) HandleCreateCustomDomain(w http.ResponseWriter, r *http.Reques
 	h.Pool.QueryRow(r.Context(),
 		`SELECT EXISTS(SELECT 1 FROM guilds WHERE id = $1)`, req.GuildID).Scan(&guildExists)
 	if !guildExists {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 
 	// Generate verification token.
 	tokenBytes := make([]byte, 32)
 	if _, err := rand.Read(tokenBytes); err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to generate verification token")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to generate verification token")
 		return
 	}
 	verificationToken := fmt.Sprintf("amityvox-verify-%s", hex.EncodeToString(tokenBytes[:16]))
@@ -1061,6 +1087,9 @@ func (h *Handler) HandleCreateCustomDomain(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionCustomDomainCreate, "custom_domain", domainID, nil,
+		map[string]string{"guild_id": req.GuildID, "domain": req.Domain}, nil)
+
 	apiutil.WriteJSON(w, http.StatusCreated, map[string]interface{}{
 		"id":                 domainID,
 		"guild_id":           req.GuildID,
@@ -1075,7 +1104,7 @@ func (h *Handler) HandleCreateCustomDomain(w http.ResponseWriter, r *http.Reques
 // POST /api/v1/admin/domains/{domainID}/verify
 func (h *Handler) HandleVerifyCustomDomain(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1093,10 +1122,13 @@ func (h *Handler) HandleVerifyCustomDomain(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Custom domain not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Custom domain not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionCustomDomainVerify, "custom_domain", domainID,
+		map[string]bool{"verified": false}, map[string]bool{"verified": true}, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"verified": true,
 		"message":  "Domain verified successfully. Configure your DNS CNAME to point to this instance.",
@@ -1107,7 +1139,7 @@ func (h *Handler) HandleVerifyCustomDomain(w http.ResponseWriter, r *http.Reques
 // DELETE /api/v1/admin/domains/{domainID}
 func (h *Handler) HandleDeleteCustomDomain(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1120,10 +1152,12 @@ func (h *Handler) HandleDeleteCustomDomain(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Custom domain not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Custom domain not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionCustomDomainDelete, "custom_domain", domainID, nil, nil, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -1135,7 +1169,7 @@ func (h *Handler) HandleDeleteCustomDomain(w http.ResponseWriter, r *http.Reques
 // GET /api/v1/admin/backups
 func (h *Handler) HandleGetBackupSchedules(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1195,7 +1229,7 @@ func (h *Handler) HandleGetBackupSchedules(w http.ResponseWriter, r *http.Reques
 // POST /api/v1/admin/backups
 func (h *Handler) HandleCreateBackupSchedule(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1268,7 +1302,7 @@ func (h *Handler) HandleCreateBackupSchedule(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	apiutil.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+	schedule := map[string]interface{}{
 		"id":               scheduleID,
 		"name":             req.Name,
 		"frequency":        req.Frequency,
@@ -1278,14 +1312,17 @@ func (h *Handler) HandleCreateBackupSchedule(w http.ResponseWriter, r *http.Requ
 		"storage_path":     req.StoragePath,
 		"enabled":          enabled,
 		"next_run_at":      nextRun,
-	})
+	}
+	h.logStaffAction(r, models.StaffActionBackupScheduleCreate, "backup_schedule", scheduleID, nil, schedule, nil)
+
+	apiutil.WriteJSON(w, http.StatusCreated, schedule)
 }
 
 // HandleUpdateBackupSchedule updates an existing backup schedule.
 // PATCH /api/v1/admin/backups/{scheduleID}
 func (h *Handler) HandleUpdateBackupSchedule(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1337,7 +1374,7 @@ func (h *Handler) HandleUpdateBackupSchedule(w http.ResponseWriter, r *http.Requ
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Backup schedule not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Backup schedule not found")
 		return
 	}
 
@@ -1348,6 +1385,8 @@ func (h *Handler) HandleUpdateBackupSchedule(w http.ResponseWriter, r *http.Requ
 			`UPDATE backup_schedules SET next_run_at = $1 WHERE id = $2`, nextRun, scheduleID)
 	}
 
+	h.logStaffAction(r, models.StaffActionBackupScheduleUpdate, "backup_schedule", scheduleID, nil, req, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
@@ -1355,7 +1394,7 @@ func (h *Handler) HandleUpdateBackupSchedule(w http.ResponseWriter, r *http.Requ
 // DELETE /api/v1/admin/backups/{scheduleID}
 func (h *Handler) HandleDeleteBackupSchedule(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1368,10 +1407,12 @@ func (h *Handler) HandleDeleteBackupSchedule(w http.ResponseWriter, r *http.Requ
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Backup schedule not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Backup schedule not found")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionBackupScheduleDelete, "backup_schedule", scheduleID, nil, nil, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -1379,7 +1420,7 @@ func (h *Handler) HandleDeleteBackupSchedule(w http.ResponseWriter, r *http.Requ
 // GET /api/v1/admin/backups/{scheduleID}/history
 func (h *Handler) HandleGetBackupHistory(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1429,7 +1470,7 @@ func (h *Handler) HandleGetBackupHistory(w http.ResponseWriter, r *http.Request)
 // POST /api/v1/admin/backups/{scheduleID}/run
 func (h *Handler) HandleTriggerBackup(w http.ResponseWriter, r *http.Request) {
 	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -1440,11 +1481,11 @@ func (h *Handler) HandleTriggerBackup(w http.ResponseWriter, r *http.Request) {
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT name, frequency FROM backup_schedules WHERE id = $1`, scheduleID).Scan(&name, &frequency)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Backup schedule not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Backup schedule not found")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read backup schedule")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to read backup schedule")
 		return
 	}
 
@@ -1454,7 +1495,7 @@ func (h *Handler) HandleTriggerBackup(w http.ResponseWriter, r *http.Request) {
 		`INSERT INTO backup_history (id, schedule_id, status, started_at, created_at)
 		 VALUES ($1, $2, 'running', now(), now())`, historyID, scheduleID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to create backup entry")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to create backup entry")
 		return
 	}
 
@@ -1471,6 +1512,9 @@ func (h *Handler) HandleTriggerBackup(w http.ResponseWriter, r *http.Request) {
 	h.Pool.Exec(r.Context(),
 		`UPDATE backup_schedules SET last_run_status = 'completed' WHERE id = $1`, scheduleID)
 
+	h.logStaffAction(r, models.StaffActionBackupTrigger, "backup_schedule", scheduleID, nil,
+		map[string]string{"backup_id": historyID, "name": name}, nil)
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"backup_id":   historyID,
 		"schedule_id": scheduleID,
@@ -1500,8 +1544,7 @@ func formatBytes(b int64) string {
 // HandleAdminGetMedia returns all attachments, paginated, for admin review.
 // GET /api/v1/admin/media
 func (h *Handler) HandleAdminGetMedia(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceModerateMedia) {
 		return
 	}
 
@@ -1523,7 +1566,7 @@ func (h *Handler) HandleAdminGetMedia(w http.ResponseWriter, r *http.Request) {
 
 	rows, err := h.Pool.Query(r.Context(), baseSQL, args...)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to query media")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to query media")
 		return
 	}
 	defer rows.Close()
@@ -1536,7 +1579,7 @@ func (h *Handler) HandleAdminGetMedia(w http.ResponseWriter, r *http.Request) {
 			&a.Width, &a.Height, &a.DurationSeconds, &a.S3Bucket, &a.S3Key, &a.Blurhash,
 			&a.AltText, &a.NSFW, &a.Description, &a.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read media data")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read media data")
 			return
 		}
 		attachments = append(attachments, a)
@@ -1548,8 +1591,7 @@ func (h *Handler) HandleAdminGetMedia(w http.ResponseWriter, r *http.Request) {
 // HandleAdminDeleteMedia deletes an attachment from DB and S3 as admin.
 // DELETE /api/v1/admin/media/{fileID}
 func (h *Handler) HandleAdminDeleteMedia(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	if !h.requireInstancePermission(w, r, permissions.InstanceModerateMedia) {
 		return
 	}
 
@@ -1564,7 +1606,7 @@ func (h *Handler) HandleAdminDeleteMedia(w http.ResponseWriter, r *http.Request)
 		if err == pgx.ErrNoRows {
 			apiutil.WriteError(w, http.StatusNotFound, "file_not_found", "Attachment not found")
 		} else {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up attachment")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up attachment")
 		}
 		return
 	}
@@ -1580,10 +1622,13 @@ func (h *Handler) HandleAdminDeleteMedia(w http.ResponseWriter, r *http.Request)
 	// Delete from database.
 	_, err = h.Pool.Exec(r.Context(), `DELETE FROM attachments WHERE id = $1`, fileID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete attachment")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete attachment")
 		return
 	}
 
+	h.logStaffAction(r, models.StaffActionMediaDelete, "attachment", fileID,
+		map[string]string{"s3_key": s3Key}, nil, nil)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// HandleGetSubsystems returns which subsystems are switched off.
+// GET /api/v1/admin/subsystems
+func (h *Handler) HandleGetSubsystems(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	d, err := apiutil.LoadDisabledSubsystems(r.Context(), h.Pool)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get subsystem toggles", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, d)
+}
+
+// HandleUpdateSubsystems switches subsystems off or back on. Omitted fields
+// keep their current value. Every API node applies the change within a few
+// seconds.
+// PATCH /api/v1/admin/subsystems
+func (h *Handler) HandleUpdateSubsystems(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
+		return
+	}
+
+	var req struct {
+		Registration *bool   `json:"registration"`
+		Messaging    *bool   `json:"messaging"`
+		Uploads      *bool   `json:"uploads"`
+		Federation   *bool   `json:"federation"`
+		Reason       *string `json:"reason"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Reason != nil && len(*req.Reason) > 200 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_reason", "reason must be at most 200 characters")
+		return
+	}
+
+	before, err := apiutil.LoadDisabledSubsystems(r.Context(), h.Pool)
+	if err != nil {
+		// A corrupt value is replaced rather than leaving the toggles stuck.
+		h.Logger.Warn("stored subsystem toggles are unreadable, replacing them", slog.String("error", err.Error()))
+	}
+	d := before
+	for _, f := range []struct {
+		set *bool
+		dst *bool
+	}{
+		{req.Registration, &d.Registration},
+		{req.Messaging, &d.Messaging},
+		{req.Uploads, &d.Uploads},
+		{req.Federation, &d.Federation},
+	} {
+		if f.set != nil {
+			*f.dst = *f.set
+		}
+	}
+	if req.Reason != nil {
+		d.Reason = *req.Reason
+	}
+	if len(d.Names()) == 0 {
+		d.Reason = ""
+	}
+
+	value, _ := json.Marshal(d)
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_settings (key, value, updated_at) VALUES ($1, $2, now())
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+		apiutil.DisabledSubsystemsSetting, string(value)); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update subsystem toggles", err)
+		return
+	}
+
+	h.logStaffAction(r, models.StaffActionInstanceUpdate, "instance_setting", apiutil.DisabledSubsystemsSetting, before, d, nil)
+	h.Logger.Warn("subsystem toggles changed",
+		slog.Any("disabled", d.Names()), slog.String("reason", d.Reason))
+	if h.EventBus != nil {
+		h.EventBus.PublishBroadcastEvent(r.Context(), events.SubjectSubsystemsUpdate, "SUBSYSTEMS_UPDATE", map[string]interface{}{
+			"disabled_subsystems": d.Names(),
+			"reason":              d.Reason,
+		})
+	}
+	apiutil.WriteJSON(w, http.StatusOK, d)
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/adminops"
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+)
+
+type createAdminTokenRequest struct {
+	Actions    []string `json:"actions"`
+	TTLSeconds int      `json:"ttl_seconds"`
+	Uses       int      `json:"uses"`
+}
+
+type runAdminActionRequest struct {
+	Action string   `json:"action"`
+	Args   []string `json:"args"`
+}
+
+type runAdminActionResponse struct {
+	Output string `json:"output"`
+}
+
+// handleCreateAdminToken mints a short-lived token that lets `amityvox admin`
+// run the given actions against this instance over the API, once unless more
+// uses are asked for. The raw token is returned only here.
+// POST /api/v1/admin/action-tokens
+func (s *Server) handleCreateAdminToken(w http.ResponseWriter, r *http.Request) {
+	var req createAdminTokenRequest
+	if !DecodeJSON(w, r, &req) {
+		return
+	}
+	if err := adminops.ValidateTokenActions(req.Actions); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid_actions", err.Error())
+		return
+	}
+	ttl := adminops.DefaultTokenTTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > adminops.MaxTokenTTL {
+		WriteError(w, http.StatusBadRequest, "invalid_ttl", "ttl_seconds must be between 1 and 86400")
+		return
+	}
+	uses := adminops.DefaultTokenUses
+	if req.Uses != 0 {
+		uses = req.Uses
+	}
+	if uses < 1 || uses > adminops.MaxTokenUses {
+		WriteError(w, http.StatusBadRequest, "invalid_uses", fmt.Sprintf("uses must be between 1 and %d", adminops.MaxTokenUses))
+		return
+	}
+
+	userID := auth.UserIDFromContext(r.Context())
+	tok, err := adminops.MintToken(r.Context(), s.DB.Pool, &userID, req.Actions, ttl, uses)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to create admin token", err)
+		return
+	}
+
+	if err := apiutil.LogStaffAction(r.Context(), s.DB.Pool, apiutil.StaffAction{
+		ActorID:    userID,
+		Action:     "admin_token_create",
+		TargetType: "admin_token",
+		TargetID:   tok.ID,
+		After:      map[string]interface{}{"actions": tok.Actions, "max_uses": tok.MaxUses, "expires_at": tok.ExpiresAt},
+	}); err != nil {
+		s.Logger.Warn("failed to log staff action",
+			slog.String("action", "admin_token_create"), slog.String("error", err.Error()))
+	}
+	WriteJSON(w, http.StatusCreated, tok)
+}
+
+// handleRunAdminAction runs one `amityvox admin` action for a CLI holding an
+// admin action token bound to it, and returns what the action printed.
+// POST /api/v1/admin-cli/run
+func (s *Server) handleRunAdminAction(w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 8 || !strings.EqualFold(header[:7], "Bearer ") {
+		WriteCode(w, apierrors.Unauthorized, "Missing admin token")
+		return
+	}
+
+	var req runAdminActionRequest
+	if !DecodeJSON(w, r, &req) {
+		return
+	}
+	if _, ok := adminops.Lookup(req.Action); !ok {
+		WriteError(w, http.StatusBadRequest, "unknown_action", "Unknown admin action: "+req.Action)
+		return
+	}
+
+	tokenID, createdBy, err := adminops.Authorize(r.Context(), s.DB.Pool, strings.TrimSpace(header[7:]), req.Action)
+	switch {
+	case errors.Is(err, adminops.ErrInvalidToken):
+		WriteError(w, http.StatusUnauthorized, "invalid_token", "Invalid, expired or used up admin token")
+		return
+	case errors.Is(err, adminops.ErrActionNotAllowed):
+		WriteError(w, http.StatusForbidden, "action_not_allowed", "This admin token was not minted for "+req.Action)
+		return
+	case err != nil:
+		InternalError(w, s.Logger, "Failed to check admin token", err)
+		return
+	}
+
+	// Arguments are left out of the audit entry since create-user takes a
+	// password.
+	actorID := "admin_token:" + tokenID
+	if createdBy != nil {
+		actorID = *createdBy
+	}
+	if err := apiutil.LogStaffAction(r.Context(), s.DB.Pool, apiutil.StaffAction{
+		ActorID:    actorID,
+		Action:     "admin_cli_action",
+		TargetType: "admin_token",
+		TargetID:   tokenID,
+		After:      map[string]string{"action": req.Action},
+	}); err != nil {
+		s.Logger.Warn("failed to log staff action",
+			slog.String("action", "admin_cli_action"), slog.String("error", err.Error()))
+	}
+
+	var out bytes.Buffer
+	env := adminops.Env{Pool: s.DB.Pool, Domain: s.Config.Instance.Domain, Bus: s.EventBus, Media: s.Media}
+	if err := adminops.Run(r.Context(), env, req.Action, req.Args, &out); err != nil {
+		WriteError(w, http.StatusBadRequest, "action_failed", err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, runAdminActionResponse{Output: out.String()})
+}
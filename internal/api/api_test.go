@@ -2,9 +2,21 @@ package api
 
 import (
 	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/api/channels"
+	"github.com/amityvox/amityvox/internal/api/guilds"
+	"github.com/amityvox/amityvox/internal/api/invites"
+	"github.com/amityvox/amityvox/internal/api/openapi"
+	"github.com/amityvox/amityvox/internal/api/users"
+	"github.com/amityvox/amityvox/internal/config"
+	"github.com/amityvox/amityvox/internal/database"
 )
 
 func TestWriteJSON(t *testing.T) {
@@ -226,3 +238,284 @@ func TestParsePagination(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMentionsLimit(t *testing.T) {
+	tests := map[string]int{
+		"":    defaultMentionsLimit,
+		"10":  10,
+		"100": 100,
+		"101": defaultMentionsLimit,
+		"0":   defaultMentionsLimit,
+		"-3":  defaultMentionsLimit,
+		"abc": defaultMentionsLimit,
+	}
+	for raw, want := range tests {
+		if got := parseMentionsLimit(raw); got != want {
+			t.Errorf("parseMentionsLimit(%q) = %d, want %d", raw, got, want)
+		}
+	}
+}
+
+func TestParseSearchOperators(t *testing.T) {
+	tests := []struct {
+		q        string
+		wantText string
+		wantFile bool
+	}{
+		{"cat pictures", "cat pictures", false},
+		{"has:file", "", true},
+		{"invoice has:file 2024", "invoice 2024", true},
+		{"HAS:FILE report", "report", true},
+		{"has:files", "has:files", false},
+	}
+	for _, tc := range tests {
+		text, hasFile := parseSearchOperators(tc.q)
+		if text != tc.wantText || hasFile != tc.wantFile {
+			t.Errorf("parseSearchOperators(%q) = (%q, %v), want (%q, %v)",
+				tc.q, text, hasFile, tc.wantText, tc.wantFile)
+		}
+	}
+}
+
+func TestMergeSearchIDs(t *testing.T) {
+	got := mergeSearchIDs([]string{"a", "b"}, []string{"b", "c", "d"}, 3)
+	want := []string{"a", "b", "c"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("mergeSearchIDs = %v, want %v", got, want)
+	}
+}
+
+func TestFedMediaTypeAllowed(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"image/png", true},
+		{"video/mp4", true},
+		{"audio/ogg; codecs=opus", true},
+		{"application/pdf", true},
+		{"text/plain; charset=utf-8", true},
+		{"image/svg+xml", false},
+		{"text/html", false},
+		{"application/javascript", false},
+		{"", false},
+	}
+	for _, tc := range tests {
+		if got := fedMediaTypeAllowed(tc.contentType); got != tc.want {
+			t.Errorf("fedMediaTypeAllowed(%q) = %v, want %v", tc.contentType, got, tc.want)
+		}
+	}
+}
+
+func TestFedMediaContentMatches(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		want        bool
+	}{
+		{"png as png", "image/png", png, true},
+		{"html as png", "image/png", []byte("<!DOCTYPE html><script>alert(1)</script>"), false},
+		{"png as jpeg", "image/jpeg", png, true},
+		{"text as image", "image/webp", []byte("just some text"), false},
+		{"unknown bytes", "video/mp4", []byte{0x00, 0x01, 0x02, 0x03}, true},
+		{"pdf", "application/pdf", []byte("%PDF-1.7"), true},
+	}
+	for _, tc := range tests {
+		if got := fedMediaContentMatches(tc.contentType, tc.data); got != tc.want {
+			t.Errorf("%s: fedMediaContentMatches = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCappedReader(t *testing.T) {
+	r := &cappedReader{r: strings.NewReader("0123456789"), remaining: 4}
+	data, err := io.ReadAll(r)
+	if err != errFedMediaTooLarge {
+		t.Errorf("err = %v, want %v", err, errFedMediaTooLarge)
+	}
+	if string(data) != "0123" {
+		t.Errorf("data = %q, want %q", data, "0123")
+	}
+
+	for _, remaining := range []int64{4, 10} {
+		r = &cappedReader{r: strings.NewReader("0123"), remaining: remaining}
+		if data, err := io.ReadAll(r); err != nil || string(data) != "0123" {
+			t.Errorf("cap %d: data = %q, err = %v", remaining, data, err)
+		}
+	}
+}
+
+func TestPolicyExempt(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/api/v1/users/@me", true},
+		{"/api/v1/users/@me/policies", true},
+		{"/api/v1/users/@me/policies/acknowledge", true},
+		{"/api/v1/users/@me/export", true},
+		{"/api/v1/users/@me/guilds", false},
+		{"/api/v1/channels/123/messages", false},
+		{"/api/v1/users/someone", false},
+	}
+	for _, tt := range tests {
+		if got := policyExempt(tt.path); got != tt.want {
+			t.Errorf("policyExempt(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestClientVersionHeaders(t *testing.T) {
+	handler := clientVersionHeaders(config.ClientsConfig{MinVersion: "1.4.0", UpgradeURL: "https://example.com/download"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-Client-Version", "1.3.0")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Min-Client-Version"); got != "1.4.0" {
+		t.Errorf("X-Min-Client-Version = %q, want 1.4.0", got)
+	}
+	if got := w.Header().Get("X-Client-Upgrade-URL"); got != "https://example.com/download" {
+		t.Errorf("X-Client-Upgrade-URL = %q", got)
+	}
+	if w.Code != http.StatusOK || w.Header().Get("X-Client-Upgrade-Required") != "true" {
+		t.Errorf("outdated client: status %d, upgrade required %q; want 200 and true",
+			w.Code, w.Header().Get("X-Client-Upgrade-Required"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-Client-Version", "1.4.0")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("X-Client-Upgrade-Required") != "" {
+		t.Error("current client was flagged for upgrade")
+	}
+}
+
+func TestReadOnlyExempt(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{http.MethodGet, "/api/v1/channels/123/messages", true},
+		{http.MethodPost, "/api/v1/channels/123/messages", false},
+		{http.MethodDelete, "/api/v1/guilds/1", false},
+		{http.MethodPatch, "/api/v1/admin/maintenance/01H", true},
+		{http.MethodPost, "/api/v1/admin-cli/run", true},
+		{http.MethodPost, "/api/v1/auth/login", true},
+		{http.MethodPost, "/api/v1/auth/register", false},
+		{http.MethodPost, "/api/v1/token-leaks", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := readOnlyExempt(r); got != tt.want {
+			t.Errorf("readOnlyExempt(%s %s) = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTokenLeakHookAuth(t *testing.T) {
+	s := &Server{Config: &config.Config{}, Logger: slog.Default()}
+	post := func(auth string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/token-leaks", strings.NewReader(`{"content":"x"}`))
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		s.handleTokenLeakHook(w, r)
+		return w.Code
+	}
+
+	if got := post("Bearer anything"); got != http.StatusNotFound {
+		t.Errorf("without a secret configured: status = %d, want %d", got, http.StatusNotFound)
+	}
+
+	s.Config.TokenLeaks.ReportSecret = "s3cret"
+	for _, auth := range []string{"", "Bearer wrong", "Basic s3cret", "Bearer s3cre"} {
+		if got := post(auth); got != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status = %d, want %d", auth, got, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	now := time.Now()
+	if got := retryAfterSeconds(now.Add(90*time.Second+time.Millisecond), now); got != 91 {
+		t.Errorf("retryAfterSeconds(90.001s) = %d, want 91", got)
+	}
+	if got := retryAfterSeconds(now.Add(-time.Second), now); got != 1 {
+		t.Errorf("retryAfterSeconds(past) = %d, want 1", got)
+	}
+}
+
+func TestIsFederationPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/federation/v1/inbox", true},
+		{"/api/v1/federation/media/inst/file", true},
+		{"/.well-known/amityvox", false},
+		{"/api/v1/admin/federation/peers", false},
+		{"/api/v1/channels/1/messages", false},
+	}
+	for _, tt := range tests {
+		if got := isFederationPath(tt.path); got != tt.want {
+			t.Errorf("isFederationPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	cfg, err := config.Load(t.TempDir() + "/amityvox.toml")
+	if err != nil {
+		t.Fatalf("loading default config: %v", err)
+	}
+	s := NewServer(&database.DB{}, cfg, nil, nil, nil, nil, nil, nil, "instance", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.RegisterRoutes()
+
+	w := httptest.NewRecorder()
+	s.handleOpenAPISpec(w, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decoding document: %v", err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+
+	// Every described operation must match a registered route, so renamed
+	// routes cannot leave stale descriptions behind.
+	for _, ops := range [][]openapi.Operation{serverOperations, users.Operations, guilds.Operations, channels.Operations, invites.Operations} {
+		for _, op := range ops {
+			item := doc.Paths[op.Path]
+			if item == nil || (*item)[strings.ToLower(op.Method)] == nil {
+				t.Errorf("%s %s is described but not routed", op.Method, op.Path)
+				continue
+			}
+			if got := (*item)[strings.ToLower(op.Method)].Summary; got != op.Summary {
+				t.Errorf("%s %s summary = %q, want %q", op.Method, op.Path, got, op.Summary)
+			}
+		}
+	}
+
+	// Authentication comes from the route tree.
+	if op := (*doc.Paths["/auth/login"])["post"]; len(op.Security) != 0 {
+		t.Error("login should not require a token")
+	}
+	if op := (*doc.Paths["/users/@me"])["get"]; len(op.Security) == 0 {
+		t.Error("GET /users/@me should require a token")
+	}
+	if _, ok := doc.Components.Schemas["CreateMessageRequest"]; !ok {
+		t.Error("missing CreateMessageRequest schema")
+	}
+}
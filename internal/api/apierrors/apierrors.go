@@ -0,0 +1,138 @@
+// Package apierrors is the catalog of error codes returned by the REST API.
+// Each code has a stable machine-readable ID, the HTTP status it is always
+// sent with, a default English message and, optionally, translations of that
+// message. Clients branch on the ID; the message is for people and may
+// change. The catalog is served at /api/v1/errors and can be printed as
+// Markdown with "amityvox errors" for the API documentation.
+//
+// Codes used across packages are declared here. Codes specific to one
+// handler may still be written directly with apiutil.WriteError until they
+// are registered.
+package apierrors
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// Code is one registered error code.
+type Code struct {
+	ID          string `json:"code"`
+	Status      int    `json:"status"`
+	Message     string `json:"message"`
+	Description string `json:"description"`
+}
+
+var (
+	mu           sync.RWMutex
+	codes        = map[string]Code{}
+	translations = map[language.Tag]map[string]string{}
+)
+
+// Register adds a code to the catalog and returns it. It is meant to be
+// called from package-level variable declarations and panics if the ID is
+// already registered or the code is incomplete, so mistakes fail at startup.
+func Register(id string, status int, message, description string) Code {
+	if id == "" || message == "" || http.StatusText(status) == "" {
+		panic(fmt.Sprintf("apierrors: incomplete error code %q", id))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := codes[id]; ok {
+		panic(fmt.Sprintf("apierrors: error code %q registered twice", id))
+	}
+	c := Code{ID: id, Status: status, Message: message, Description: description}
+	codes[id] = c
+	return c
+}
+
+// Translate registers the default message of the code with the given ID in
+// another language, given as a BCP 47 tag such as "de" or "pt-BR".
+func Translate(lang, id, message string) {
+	tag := language.MustParse(lang)
+	mu.Lock()
+	defer mu.Unlock()
+	if translations[tag] == nil {
+		translations[tag] = map[string]string{}
+	}
+	translations[tag][id] = message
+}
+
+// Lookup returns the registered code with the given ID.
+func Lookup(id string) (Code, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := codes[id]
+	return c, ok
+}
+
+// All returns every registered code, ordered by ID.
+func All() []Code {
+	mu.RLock()
+	all := make([]Code, 0, len(codes))
+	for _, c := range codes {
+		all = append(all, c)
+	}
+	mu.RUnlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all
+}
+
+// Localize returns the code with its message in the language best matching
+// an Accept-Language header. Without a translation the English message is
+// kept.
+func (c Code) Localize(acceptLanguage string) Code {
+	if acceptLanguage == "" {
+		return c
+	}
+	prefs, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(prefs) == 0 {
+		return c
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	supported := []language.Tag{language.English}
+	for tag := range translations {
+		supported = append(supported, tag)
+	}
+	// Sort so the matcher breaks ties the same way on every call.
+	sort.Slice(supported[1:], func(i, j int) bool {
+		return supported[i+1].String() < supported[j+1].String()
+	})
+	_, index, confidence := language.NewMatcher(supported).Match(prefs...)
+	if confidence == language.No || index == 0 {
+		return c
+	}
+	if msg, ok := translations[supported[index]][c.ID]; ok {
+		c.Message = msg
+	}
+	return c
+}
+
+// WriteMarkdown writes the catalog as a Markdown table for the API
+// documentation.
+func WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# API error codes\n\n")
+	b.WriteString("Errors are returned as `{\"error\": {\"code\": ..., \"message\": ...}}`. ")
+	b.WriteString("Handle errors by `code`; messages are for display and may change.\n\n")
+	b.WriteString("| Code | Status | Message | Description |\n")
+	b.WriteString("|------|--------|---------|-------------|\n")
+	for _, c := range All() {
+		fmt.Fprintf(&b, "| `%s` | %d %s | %s | %s |\n",
+			c.ID, c.Status, http.StatusText(c.Status), escapeCell(c.Message), escapeCell(c.Description))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,87 @@
+package apierrors
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCatalogIsWellFormed(t *testing.T) {
+	idRe := regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	all := All()
+	if len(all) == 0 {
+		t.Fatal("catalog is empty")
+	}
+	for i, c := range all {
+		if !idRe.MatchString(c.ID) {
+			t.Errorf("code %q is not snake_case", c.ID)
+		}
+		if http.StatusText(c.Status) == "" || c.Status < 400 {
+			t.Errorf("code %q has status %d, want an error status", c.ID, c.Status)
+		}
+		if c.Message == "" || c.Description == "" {
+			t.Errorf("code %q needs a message and a description", c.ID)
+		}
+		if i > 0 && all[i-1].ID >= c.ID {
+			t.Errorf("All() is not ordered by ID at %q", c.ID)
+		}
+	}
+}
+
+func TestTranslationsNameRegisteredCodes(t *testing.T) {
+	for tag, messages := range translations {
+		for id := range messages {
+			if _, ok := Lookup(id); !ok {
+				t.Errorf("%s translation for unregistered code %q", tag, id)
+			}
+		}
+	}
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering an existing code did not panic")
+		}
+	}()
+	Register(MissingPermission.ID, http.StatusForbidden, "again", "again")
+}
+
+func TestLocalize(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{"no header", "", "Channel not found"},
+		{"english", "en-US,en;q=0.9", "Channel not found"},
+		{"german", "de-DE,de;q=0.9,en;q=0.8", "Kanal nicht gefunden"},
+		{"preferred first", "fr;q=0.9,es", "Canal no encontrado"},
+		{"untranslated language", "ja", "Channel not found"},
+		{"malformed", ";;;", "Channel not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ChannelNotFound.Localize(tt.acceptLanguage)
+			if got.Message != tt.want {
+				t.Errorf("Localize(%q).Message = %q, want %q", tt.acceptLanguage, got.Message, tt.want)
+			}
+			if got.ID != ChannelNotFound.ID || got.Status != ChannelNotFound.Status {
+				t.Errorf("Localize changed the code or status: %+v", got)
+			}
+		})
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var b strings.Builder
+	if err := WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range All() {
+		if !strings.Contains(b.String(), "| `"+c.ID+"` |") {
+			t.Errorf("Markdown is missing %q", c.ID)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package apierrors
+
+import "net/http"
+
+// Request errors.
+var (
+	InvalidBody = Register("invalid_body", http.StatusBadRequest,
+		"Invalid request body",
+		"The body is not valid JSON, does not match the expected shape, or a field is out of range.")
+	MissingFields = Register("missing_fields", http.StatusBadRequest,
+		"Required fields are missing",
+		"One or more required fields were omitted or empty.")
+)
+
+// Authentication and authorization errors.
+var (
+	Unauthorized = Register("unauthorized", http.StatusUnauthorized,
+		"Authentication required",
+		"The request has no valid session or bot token.")
+	Forbidden = Register("forbidden", http.StatusForbidden,
+		"You are not allowed to do this",
+		"The caller may not perform this action for a reason other than a missing guild permission, such as not being an instance admin or the resource's owner.")
+	MissingPermission = Register("missing_permission", http.StatusForbidden,
+		"You do not have permission to do this",
+		"The caller lacks a guild or channel permission the action requires. The message names the permission.")
+	NotMember = Register("not_member", http.StatusForbidden,
+		"You are not a member of this guild",
+		"The caller is not a member of the guild or a recipient of the channel.")
+)
+
+// Missing resources.
+var (
+	NotFound = Register("not_found", http.StatusNotFound,
+		"Not found",
+		"The requested resource does not exist or is not visible to the caller.")
+	GuildNotFound = Register("guild_not_found", http.StatusNotFound,
+		"Guild not found",
+		"The guild does not exist or the caller cannot see it.")
+	ChannelNotFound = Register("channel_not_found", http.StatusNotFound,
+		"Channel not found",
+		"The channel does not exist or the caller cannot see it.")
+	MessageNotFound = Register("message_not_found", http.StatusNotFound,
+		"Message not found",
+		"The message does not exist in this channel or has been deleted.")
+	UserNotFound = Register("user_not_found", http.StatusNotFound,
+		"User not found",
+		"No user has this ID.")
+	RoleNotFound = Register("role_not_found", http.StatusNotFound,
+		"Role not found",
+		"The role does not exist in this guild.")
+	MemberNotFound = Register("member_not_found", http.StatusNotFound,
+		"Member not found",
+		"The user is not a member of this guild.")
+)
+
+// Limits and availability.
+var (
+	RateLimited = Register("rate_limited", http.StatusTooManyRequests,
+		"You are being rate limited. Please try again later.",
+		"Too many requests. Wait for the number of seconds in the Retry-After header.")
+	VoiceDisabled = Register("voice_disabled", http.StatusServiceUnavailable,
+		"Voice is not enabled on this instance",
+		"The instance runs without a voice server.")
+	InternalError = Register("internal_error", http.StatusInternalServerError,
+		"Something went wrong",
+		"An unexpected server error. It has been logged; retrying may succeed.")
+)
+
+func init() {
+	for lang, messages := range map[string]map[string]string{
+		"de": {
+			"invalid_body":       "Ungültiger Anfrageinhalt",
+			"missing_fields":     "Pflichtfelder fehlen",
+			"unauthorized":       "Anmeldung erforderlich",
+			"forbidden":          "Das ist dir nicht erlaubt",
+			"missing_permission": "Dir fehlt die Berechtigung dafür",
+			"not_member":         "Du bist kein Mitglied dieser Gilde",
+			"not_found":          "Nicht gefunden",
+			"guild_not_found":    "Gilde nicht gefunden",
+			"channel_not_found":  "Kanal nicht gefunden",
+			"message_not_found":  "Nachricht nicht gefunden",
+			"user_not_found":     "Benutzer nicht gefunden",
+			"role_not_found":     "Rolle nicht gefunden",
+			"member_not_found":   "Mitglied nicht gefunden",
+			"rate_limited":       "Zu viele Anfragen. Bitte versuche es später erneut.",
+			"voice_disabled":     "Sprachchat ist auf dieser Instanz nicht aktiviert",
+			"internal_error":     "Etwas ist schiefgelaufen",
+		},
+		"es": {
+			"invalid_body":       "Cuerpo de la solicitud no válido",
+			"missing_fields":     "Faltan campos obligatorios",
+			"unauthorized":       "Se requiere iniciar sesión",
+			"forbidden":          "No tienes permitido hacer esto",
+			"missing_permission": "No tienes permiso para hacer esto",
+			"not_member":         "No eres miembro de este gremio",
+			"not_found":          "No encontrado",
+			"guild_not_found":    "Gremio no encontrado",
+			"channel_not_found":  "Canal no encontrado",
+			"message_not_found":  "Mensaje no encontrado",
+			"user_not_found":     "Usuario no encontrado",
+			"role_not_found":     "Rol no encontrado",
+			"member_not_found":   "Miembro no encontrado",
+			"rate_limited":       "Demasiadas solicitudes. Inténtalo de nuevo más tarde.",
+			"voice_disabled":     "La voz no está habilitada en esta instancia",
+			"internal_error":     "Algo salió mal",
+		},
+		"fr": {
+			"invalid_body":       "Corps de requête invalide",
+			"missing_fields":     "Des champs obligatoires sont manquants",
+			"unauthorized":       "Authentification requise",
+			"forbidden":          "Vous n'êtes pas autorisé à faire cela",
+			"missing_permission": "Vous n'avez pas la permission de faire cela",
+			"not_member":         "Vous n'êtes pas membre de cette guilde",
+			"not_found":          "Introuvable",
+			"guild_not_found":    "Guilde introuvable",
+			"channel_not_found":  "Salon introuvable",
+			"message_not_found":  "Message introuvable",
+			"user_not_found":     "Utilisateur introuvable",
+			"role_not_found":     "Rôle introuvable",
+			"member_not_found":   "Membre introuvable",
+			"rate_limited":       "Trop de requêtes. Veuillez réessayer plus tard.",
+			"voice_disabled":     "La voix n'est pas activée sur cette instance",
+			"internal_error":     "Une erreur est survenue",
+		},
+	} {
+		for id, message := range messages {
+			Translate(lang, id, message)
+		}
+	}
+}
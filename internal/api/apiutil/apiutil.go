@@ -11,6 +11,8 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 )
 
 // ErrorResponse is the standard error envelope returned by the API.
@@ -60,6 +62,23 @@ func WriteError(w http.ResponseWriter, status int, code, message string) {
 	})
 }
 
+// WriteCode writes a JSON error response for a code from the error catalog,
+// with the code's status. An empty message uses the code's default message.
+func WriteCode(w http.ResponseWriter, code apierrors.Code, message string) {
+	if message == "" {
+		message = code.Message
+	}
+	WriteError(w, code.Status, code.ID, message)
+}
+
+// WriteLocalizedCode writes a JSON error response for a code from the error
+// catalog with its default message translated for the request's
+// Accept-Language, where a translation exists.
+func WriteLocalizedCode(w http.ResponseWriter, r *http.Request, code apierrors.Code) {
+	code = code.Localize(r.Header.Get("Accept-Language"))
+	WriteError(w, code.Status, code.ID, code.Message)
+}
+
 // WriteNoContent writes a 204 No Content response with no body.
 func WriteNoContent(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusNoContent)
@@ -69,7 +88,7 @@ func WriteNoContent(w http.ResponseWriter) {
 // 400 error response and returns false so the caller can return early.
 func DecodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
 	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
-		WriteError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		WriteLocalizedCode(w, r, apierrors.InvalidBody)
 		return false
 	}
 	return true
@@ -79,7 +98,7 @@ func DecodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
 // parameter is used both as the log message and the user-facing message.
 func InternalError(w http.ResponseWriter, logger *slog.Logger, msg string, err error) {
 	logger.Error(msg, slog.String("error", err.Error()))
-	WriteError(w, http.StatusInternalServerError, "internal_error", msg)
+	WriteCode(w, apierrors.InternalError, msg)
 }
 
 // WithTx runs fn inside a database transaction. It begins a transaction, calls
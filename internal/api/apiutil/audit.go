@@ -0,0 +1,51 @@
+package apiutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// StaffAction describes an instance-level staff action for the instance audit
+// log. Before and After are marshalled to JSON; either may be nil.
+type StaffAction struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+	Reason     *string
+}
+
+// LogStaffAction appends an entry to the instance audit log.
+func LogStaffAction(ctx context.Context, pool *pgxpool.Pool, a StaffAction) error {
+	before, err := marshalAuditValue(a.Before)
+	if err != nil {
+		return fmt.Errorf("encoding audit before value: %w", err)
+	}
+	after, err := marshalAuditValue(a.After)
+	if err != nil {
+		return fmt.Errorf("encoding audit after value: %w", err)
+	}
+
+	_, err = pool.Exec(ctx,
+		`INSERT INTO instance_audit_log (id, actor_id, action, target_type, target_id, before_value, after_value, reason, created_at)
+		 VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8, now())`,
+		models.NewULID().String(), a.ActorID, a.Action, a.TargetType, a.TargetID, before, after, a.Reason)
+	if err != nil {
+		return fmt.Errorf("inserting instance audit entry: %w", err)
+	}
+	return nil
+}
+
+func marshalAuditValue(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,37 @@
+package apiutil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Autocomplete query bounds.
+const (
+	MaxPrefixQuery           = 100
+	DefaultAutocompleteLimit = 10
+	MaxAutocompleteLimit     = 25
+)
+
+// PrefixPattern turns a name query into a LIKE pattern matching names that
+// start with it, case-insensitively. Compare it against lower(column), which
+// the prefix indexes cover. LIKE wildcards in q match literally.
+func PrefixPattern(q string) string {
+	q = strings.TrimSpace(q)
+	if r := []rune(q); len(r) > MaxPrefixQuery {
+		q = string(r[:MaxPrefixQuery])
+	}
+	q = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(q))
+	return q + "%"
+}
+
+// AutocompleteLimit reads the limit query parameter of an autocomplete
+// request, falling back to DefaultAutocompleteLimit for missing or invalid
+// values and capping at MaxAutocompleteLimit.
+func AutocompleteLimit(r *http.Request) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || n < 1 {
+		return DefaultAutocompleteLimit
+	}
+	return min(n, MaxAutocompleteLimit)
+}
@@ -0,0 +1,43 @@
+package apiutil
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrefixPattern(t *testing.T) {
+	tests := map[string]string{
+		"Ali":     "ali%",
+		"  bob ":  "bob%",
+		"50%_off": `50\%\_off%`,
+		`back\sl`: `back\\sl%`,
+		"ÉMILE":   "émile%",
+	}
+	for in, want := range tests {
+		if got := PrefixPattern(in); got != want {
+			t.Errorf("PrefixPattern(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	long := strings.Repeat("é", MaxPrefixQuery+5)
+	if got := PrefixPattern(long); got != strings.Repeat("é", MaxPrefixQuery)+"%" {
+		t.Errorf("PrefixPattern did not truncate to %d runes", MaxPrefixQuery)
+	}
+}
+
+func TestAutocompleteLimit(t *testing.T) {
+	tests := map[string]int{
+		"":         DefaultAutocompleteLimit,
+		"limit=5":  5,
+		"limit=0":  DefaultAutocompleteLimit,
+		"limit=x":  DefaultAutocompleteLimit,
+		"limit=99": MaxAutocompleteLimit,
+	}
+	for query, want := range tests {
+		r := httptest.NewRequest("GET", "/?"+query, nil)
+		if got := AutocompleteLimit(r); got != want {
+			t.Errorf("AutocompleteLimit(%q) = %d, want %d", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package apiutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// BoostTier returns the boost tier a guild reaches with count active boosts.
+func BoostTier(count int) int {
+	switch {
+	case count >= 14:
+		return 3
+	case count >= 7:
+		return 2
+	case count >= 2:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// RecountGuildBoosts refreshes the boost count and tier cached on a guild.
+func RecountGuildBoosts(ctx context.Context, pool *pgxpool.Pool, guildID string) error {
+	var count int
+	if err := pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM guild_boosts WHERE guild_id = $1 AND active = true`, guildID,
+	).Scan(&count); err != nil {
+		return fmt.Errorf("counting guild boosts: %w", err)
+	}
+	if _, err := pool.Exec(ctx,
+		`UPDATE guilds SET boost_count = $2, boost_tier = $3 WHERE id = $1`,
+		guildID, count, BoostTier(count)); err != nil {
+		return fmt.Errorf("updating guild boost tier: %w", err)
+	}
+	return nil
+}
+
+// GuildBoostPerks returns the perks of the highest configured tier at or below
+// the guild's boost tier. A guild with no matching tier gets no limits beyond
+// the instance's own.
+func GuildBoostPerks(ctx context.Context, pool *pgxpool.Pool, guildID string) (models.BoostTierPerks, error) {
+	var p models.BoostTierPerks
+	err := pool.QueryRow(ctx,
+		`SELECT g.boost_tier, t.max_upload_bytes, t.max_bitrate
+		 FROM guilds g
+		 LEFT JOIN LATERAL (
+		     SELECT max_upload_bytes, max_bitrate FROM boost_tier_perks
+		     WHERE boost_tier <= g.boost_tier ORDER BY boost_tier DESC LIMIT 1
+		 ) t ON true
+		 WHERE g.id = $1`, guildID,
+	).Scan(&p.BoostTier, &p.MaxUploadBytes, &p.MaxBitrate)
+	if err != nil {
+		return p, fmt.Errorf("loading guild boost perks: %w", err)
+	}
+	return p, nil
+}
+
+// BoostsRequireSupporter reports whether the instance only lets supporters
+// boost guilds.
+func BoostsRequireSupporter(ctx context.Context, pool *pgxpool.Pool) bool {
+	var value string
+	pool.QueryRow(ctx,
+		`SELECT COALESCE((SELECT value FROM instance_settings WHERE key = 'boosts_require_supporter'), 'false')`,
+	).Scan(&value)
+	return value == "true"
+}
+
+// SupporterBoostSlots returns how many boost slots a user's entitlement
+// grants and how many of them are in use. Users without a current
+// entitlement have no slots.
+func SupporterBoostSlots(ctx context.Context, pool *pgxpool.Pool, userID string) (slots, used int, err error) {
+	err = pool.QueryRow(ctx,
+		`SELECT COALESCE((SELECT boost_slots FROM supporter_entitlements
+		                  WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > now())), 0),
+		        (SELECT COUNT(*) FROM guild_boosts WHERE user_id = $1 AND active = true)`,
+		userID).Scan(&slots, &used)
+	if err != nil {
+		return 0, 0, fmt.Errorf("loading supporter boost slots: %w", err)
+	}
+	return slots, used, nil
+}
+
+// TrimSupporterBoosts ends a user's most recent boosts until they fit in the
+// user's boost slots, for when an entitlement shrinks, expires or is revoked.
+// Nothing is trimmed while the instance lets everyone boost. It returns the
+// guilds that lost a boost, with their boost tiers already recounted.
+func TrimSupporterBoosts(ctx context.Context, pool *pgxpool.Pool, userID string) ([]string, error) {
+	if !BoostsRequireSupporter(ctx, pool) {
+		return nil, nil
+	}
+	slots, _, err := SupporterBoostSlots(ctx, pool, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx,
+		`UPDATE guild_boosts SET active = false
+		 WHERE id IN (
+		     SELECT id FROM guild_boosts WHERE user_id = $1 AND active = true
+		     ORDER BY started_at OFFSET $2
+		 )
+		 RETURNING guild_id`, userID, slots)
+	if err != nil {
+		return nil, fmt.Errorf("trimming supporter boosts: %w", err)
+	}
+	var guildIDs []string
+	for rows.Next() {
+		var guildID string
+		if err := rows.Scan(&guildID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("reading trimmed boosts: %w", err)
+		}
+		guildIDs = append(guildIDs, guildID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("trimming supporter boosts: %w", err)
+	}
+
+	for _, guildID := range guildIDs {
+		if err := RecountGuildBoosts(ctx, pool, guildID); err != nil {
+			return guildIDs, err
+		}
+	}
+	return guildIDs, nil
+}
+
+// SyncSupporterFlag sets or clears a user's supporter flag to match whether
+// they hold a current entitlement.
+func SyncSupporterFlag(ctx context.Context, pool *pgxpool.Pool, userID string) error {
+	_, err := pool.Exec(ctx,
+		`UPDATE users SET flags = CASE
+		     WHEN EXISTS(SELECT 1 FROM supporter_entitlements
+		                 WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > now()))
+		     THEN flags | $2 ELSE flags & ~$2 END
+		 WHERE id = $1`, userID, models.UserFlagSupporter)
+	if err != nil {
+		return fmt.Errorf("syncing supporter flag: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package apiutil
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// MemberChannelPermissions computes a guild member's permissions in a
+// channel: roles, an installed app's grant, the channel's overrides, any
+// timeout, and the instance admin bypass. It returns pgx.ErrNoRows when
+// userID is not a member of the guild.
+func MemberChannelPermissions(ctx context.Context, pool *pgxpool.Pool, guildID, channelID, userID string) (uint64, error) {
+	member, guild, roles, admin, err := memberPermissionBase(ctx, pool, guildID, userID)
+	if err != nil {
+		return 0, err
+	}
+	var everyone *int64
+	if err := pool.QueryRow(ctx,
+		`SELECT default_permissions FROM channels WHERE id = $1 AND guild_id = $2`,
+		channelID, guildID).Scan(&everyone); err != nil {
+		return 0, err
+	}
+	if admin {
+		return permissions.AllPermissions, nil
+	}
+	channel := permissions.ChannelInfo{}
+	if everyone != nil {
+		allow := uint64(*everyone)
+		channel.DefaultPermissionsAllow = &allow
+	}
+	rows, err := pool.Query(ctx,
+		`SELECT target_type, target_id, permissions_allow, permissions_deny
+		 FROM channel_permission_overrides WHERE channel_id = $1`, channelID)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var o permissions.ChannelOverride
+		var allow, deny int64
+		if err := rows.Scan(&o.TargetType, &o.TargetID, &allow, &deny); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		o.PermissionsAllow, o.PermissionsDeny = uint64(allow), uint64(deny)
+		channel.Overrides = append(channel.Overrides, o)
+	}
+	rows.Close()
+
+	return permissions.CalculatePermissions(member, guild, roles, &channel), nil
+}
+
+// ChannelAccess is a member's permissions in one channel, and whether the
+// channel is only listed to them as a locked preview.
+type ChannelAccess struct {
+	Permissions uint64
+	Preview     bool
+}
+
+// MemberGuildChannelAccess computes a guild member's access to every channel
+// of the guild in one pass, for filtering channel lists. It returns
+// pgx.ErrNoRows when userID is not a member of the guild.
+func MemberGuildChannelAccess(ctx context.Context, pool *pgxpool.Pool, guildID, userID string) (map[string]ChannelAccess, error) {
+	member, guild, roles, admin, err := memberPermissionBase(ctx, pool, guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := map[string]*permissions.ChannelInfo{}
+	rows, err := pool.Query(ctx,
+		`SELECT id, default_permissions, preview_enabled FROM channels WHERE guild_id = $1`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		var everyone *int64
+		c := &permissions.ChannelInfo{}
+		if err := rows.Scan(&id, &everyone, &c.Preview); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if everyone != nil {
+			allow := uint64(*everyone)
+			c.DefaultPermissionsAllow = &allow
+		}
+		channels[id] = c
+	}
+	rows.Close()
+
+	access := make(map[string]ChannelAccess, len(channels))
+	if admin {
+		for id := range channels {
+			access[id] = ChannelAccess{Permissions: permissions.AllPermissions}
+		}
+		return access, nil
+	}
+
+	rows, err = pool.Query(ctx,
+		`SELECT o.channel_id, o.target_type, o.target_id, o.permissions_allow, o.permissions_deny
+		 FROM channel_permission_overrides o JOIN channels c ON c.id = o.channel_id
+		 WHERE c.guild_id = $1`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var channelID string
+		var o permissions.ChannelOverride
+		var allow, deny int64
+		if err := rows.Scan(&channelID, &o.TargetType, &o.TargetID, &allow, &deny); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		o.PermissionsAllow, o.PermissionsDeny = uint64(allow), uint64(deny)
+		if c := channels[channelID]; c != nil {
+			c.Overrides = append(c.Overrides, o)
+		}
+	}
+	rows.Close()
+
+	for id, c := range channels {
+		e := permissions.Explain(member, guild, roles, c)
+		access[id] = ChannelAccess{Permissions: e.Permissions, Preview: e.Preview}
+	}
+	return access, nil
+}
+
+// memberPermissionBase loads what a guild member's permissions are built
+// from. admin is set for instance admins, who bypass permission checks.
+func memberPermissionBase(ctx context.Context, pool *pgxpool.Pool, guildID, userID string) (
+	member permissions.MemberInfo, guild permissions.GuildInfo, roles []permissions.RoleInfo, admin bool, err error) {
+	var (
+		defaultPerms int64
+		userFlags    int
+		appCap       *int64
+	)
+	member.UserID = userID
+	err = pool.QueryRow(ctx,
+		`SELECT g.owner_id, COALESCE(g.default_permissions, 0), gm.timeout_until, u.flags, bi.permissions
+		 FROM guild_members gm
+		 JOIN guilds g ON g.id = gm.guild_id
+		 JOIN users u ON u.id = gm.user_id
+		 LEFT JOIN bot_installs bi ON bi.guild_id = gm.guild_id AND bi.bot_id = gm.user_id
+		 WHERE gm.guild_id = $1 AND gm.user_id = $2`,
+		guildID, userID,
+	).Scan(&guild.OwnerID, &defaultPerms, &member.TimeoutUntil, &userFlags, &appCap)
+	if err != nil {
+		return member, guild, nil, false, err
+	}
+	if userFlags&models.UserFlagAdmin != 0 {
+		return member, guild, nil, true, nil
+	}
+	guild.DefaultPermissions = uint64(defaultPerms)
+	if appCap != nil {
+		grant := uint64(*appCap)
+		member.Cap = &grant
+	}
+
+	rows, err := pool.Query(ctx,
+		`SELECT r.id, r.position, r.permissions_allow, r.permissions_deny
+		 FROM roles r JOIN member_roles mr ON mr.role_id = r.id
+		 WHERE mr.guild_id = $1 AND mr.user_id = $2
+		 ORDER BY r.position DESC`, guildID, userID)
+	if err != nil {
+		return member, guild, nil, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ri permissions.RoleInfo
+		var allow, deny int64
+		if err = rows.Scan(&ri.ID, &ri.Position, &allow, &deny); err != nil {
+			return member, guild, nil, false, err
+		}
+		ri.PermissionsAllow, ri.PermissionsDeny = uint64(allow), uint64(deny)
+		roles = append(roles, ri)
+	}
+	return member, guild, roles, false, rows.Err()
+}
@@ -0,0 +1,22 @@
+package apiutil
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// ContentSettings returns the user's NSFW filters. Users who never changed
+// them, and lookups that fail, get the defaults, which hide NSFW content.
+func ContentSettings(ctx context.Context, pool *pgxpool.Pool, userID string) models.UserContentSettings {
+	s := models.DefaultUserContentSettings()
+	if err := pool.QueryRow(ctx,
+		`SELECT show_nsfw, nsfw_media, safe_search, age_confirmed_at, updated_at
+		 FROM user_content_settings WHERE user_id = $1`, userID,
+	).Scan(&s.ShowNSFW, &s.NSFWMedia, &s.SafeSearch, &s.AgeConfirmedAt, &s.UpdatedAt); err != nil {
+		return models.DefaultUserContentSettings()
+	}
+	return s
+}
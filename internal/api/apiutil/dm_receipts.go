@@ -0,0 +1,85 @@
+package apiutil
+
+import (
+	"context"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DMReceipt is the payload of a DM_RECEIPT event: a recipient of a DM or
+// group DM has received or read the channel's messages up to MessageID.
+type DMReceipt struct {
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id"`
+	MessageID string `json:"message_id"`
+	Status    string `json:"status"` // models.MessageStatusDelivered or models.MessageStatusRead
+}
+
+// RecordDMDelivery records that userID received messageID in a DM or group
+// DM, by a gateway session or a push, and tells the message's author. Other
+// channels and messages already counted as delivered are ignored.
+func RecordDMDelivery(ctx context.Context, pool *pgxpool.Pool, bus *events.Bus, channelID, userID, messageID string) error {
+	var authorID string
+	err := pool.QueryRow(ctx,
+		`WITH advanced AS (
+		     INSERT INTO dm_delivery_state (channel_id, user_id, last_delivered_id)
+		     SELECT c.id, $2, $3 FROM channels c
+		     WHERE c.id = $1 AND c.channel_type IN ('dm', 'group')
+		       AND EXISTS(SELECT 1 FROM channel_recipients r WHERE r.channel_id = c.id AND r.user_id = $2)
+		     ON CONFLICT (channel_id, user_id) DO UPDATE SET last_delivered_id = EXCLUDED.last_delivered_id
+		     WHERE dm_delivery_state.last_delivered_id < EXCLUDED.last_delivered_id
+		     RETURNING 1
+		 )
+		 SELECT m.author_id FROM messages m, advanced
+		 WHERE m.id = $3 AND m.channel_id = $1 AND m.author_id <> $2`,
+		channelID, userID, messageID).Scan(&authorID)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bus != nil {
+		bus.PublishUserEvent(ctx, events.SubjectDMReceipt, "DM_RECEIPT", authorID, DMReceipt{
+			ChannelID: channelID, UserID: userID, MessageID: messageID, Status: models.MessageStatusDelivered,
+		})
+	}
+	return nil
+}
+
+// PublishDMRead tells the other participants of a DM or group DM that
+// userID has read up to messageID. Nothing is sent for other channels, when
+// the reader has turned read receipts off, or to participants who have.
+func PublishDMRead(ctx context.Context, pool *pgxpool.Pool, bus *events.Bus, channelID, userID, messageID string) error {
+	rows, err := pool.Query(ctx,
+		`SELECT r.user_id FROM channel_recipients r
+		 JOIN channels c ON c.id = r.channel_id
+		 JOIN users u ON u.id = r.user_id
+		 WHERE r.channel_id = $1 AND r.user_id <> $2
+		   AND c.channel_type IN ('dm', 'group') AND u.read_receipts
+		   AND (SELECT read_receipts FROM users WHERE id = $2)`,
+		channelID, userID)
+	if err != nil {
+		return err
+	}
+	var recipients []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			recipients = append(recipients, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	receipt := DMReceipt{ChannelID: channelID, UserID: userID, MessageID: messageID, Status: models.MessageStatusRead}
+	for _, id := range recipients {
+		bus.PublishUserEvent(ctx, events.SubjectDMReceipt, "DM_RECEIPT", id, receipt)
+	}
+	return nil
+}
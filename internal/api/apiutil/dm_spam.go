@@ -0,0 +1,159 @@
+package apiutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/presence"
+)
+
+// DM abuse classes. Opening DM channels and messaging in them are counted
+// separately, so chatting in existing DMs never eats into the allowance for
+// reaching out to new people, and the reverse.
+const (
+	// DMClassOpen counts new DM channels opened with users the sender shares
+	// no guild or friendship with.
+	DMClassOpen = "dm_open"
+	// DMClassMessage counts messages sent into DM and group DM channels.
+	DMClassMessage = "dm_msg"
+)
+
+type dmRateClass struct {
+	limit  int
+	window time.Duration
+}
+
+var dmRateClasses = map[string]dmRateClass{
+	DMClassOpen:    {limit: 10, window: time.Hour},
+	DMClassMessage: {limit: 30, window: 10 * time.Second},
+}
+
+// DMRateLimited counts an action of class by userID and returns how long the
+// user should wait if the class's limit is reached, or zero. Like
+// SourceRateLimited, nothing is enforced without a cache, and lookup errors
+// let the action through.
+func DMRateLimited(ctx context.Context, cache *presence.Cache, class, userID string) time.Duration {
+	rc, ok := dmRateClasses[class]
+	if cache == nil || !ok {
+		return 0
+	}
+	key := class + ":" + userID
+	n, err := cache.WindowCount(ctx, key, rc.window)
+	if err != nil {
+		return 0
+	}
+	if n >= float64(rc.limit) {
+		return rc.window
+	}
+	cache.IncrWindowCount(ctx, key, rc.window)
+	return 0
+}
+
+// --- DM Spam Detection ---
+
+// dmSpamTracker tracks recent DM activity per user to detect spam patterns.
+// A user is flagged when they send the same content to 5+ different DM
+// recipients, or are refused opening DMs with 5+ different strangers, within
+// a 10-minute sliding window.
+var dmSpamTracker = &dmTracker{
+	sends: make(map[string][]dmSendEntry),
+}
+
+const (
+	dmSpamRecipientThreshold = 5 // same content to this many different recipients = flagged
+	dmSpamRefusedThreshold   = 5 // refused DM opens to this many different users = flagged
+	dmSpamWindow             = 10 * time.Minute
+)
+
+type dmTracker struct {
+	mu    sync.Mutex
+	sends map[string][]dmSendEntry // key: "userID:contentHash" or "userID:open"
+}
+
+type dmSendEntry struct {
+	recipientID string
+	ts          time.Time
+}
+
+// contentHash returns a hex-encoded SHA-256 hash of the content for compact storage.
+func contentHash(content string) string {
+	h := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(content))))
+	return hex.EncodeToString(h[:])
+}
+
+// TrackDMSend records a DM send and reports whether the user is flagged as a
+// spammer.
+func TrackDMSend(userID, recipientID, content string) bool {
+	return dmSpamTracker.track(userID+":"+contentHash(content), recipientID, dmSpamRecipientThreshold)
+}
+
+// TrackDMOpenRefused records a refused attempt to open a DM with targetID
+// and reports whether the user is flagged as a spammer.
+func TrackDMOpenRefused(userID, targetID string) bool {
+	return dmSpamTracker.track(userID+":open", targetID, dmSpamRefusedThreshold)
+}
+
+// track records an entry for recipientID under key and reports whether the
+// key has reached threshold unique recipients within the window.
+func (dt *dmTracker) track(key, recipientID string, threshold int) bool {
+	now := time.Now()
+	cutoff := now.Add(-dmSpamWindow)
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	// Prune old entries.
+	entries := dt.sends[key]
+	pruned := entries[:0]
+	for _, e := range entries {
+		if e.ts.After(cutoff) {
+			pruned = append(pruned, e)
+		}
+	}
+
+	// Add the current entry.
+	pruned = append(pruned, dmSendEntry{recipientID: recipientID, ts: now})
+	dt.sends[key] = pruned
+
+	// Count unique recipients.
+	uniqueRecipients := make(map[string]struct{})
+	for _, e := range pruned {
+		uniqueRecipients[e.recipientID] = struct{}{}
+	}
+
+	return len(uniqueRecipients) >= threshold
+}
+
+// cleanup removes stale entries older than the window.
+func (dt *dmTracker) cleanup() {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+	cutoff := time.Now().Add(-dmSpamWindow)
+	for key, entries := range dt.sends {
+		pruned := entries[:0]
+		for _, e := range entries {
+			if e.ts.After(cutoff) {
+				pruned = append(pruned, e)
+			}
+		}
+		if len(pruned) == 0 {
+			delete(dt.sends, key)
+		} else {
+			dt.sends[key] = pruned
+		}
+	}
+}
+
+func init() {
+	// Periodically clean up the DM spam tracker in the background.
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		for range ticker.C {
+			dmSpamTracker.cleanup()
+		}
+	}()
+}
@@ -0,0 +1,30 @@
+package apiutil
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTrackDMOpenRefused(t *testing.T) {
+	const user = "test-open-refused"
+	for i := 0; i < dmSpamRefusedThreshold-1; i++ {
+		// Repeats of the same target never count twice.
+		TrackDMOpenRefused(user, "target-0")
+		if TrackDMOpenRefused(user, fmt.Sprintf("target-%d", i)) {
+			t.Fatalf("flagged after %d distinct targets", i+1)
+		}
+	}
+	if !TrackDMOpenRefused(user, "target-last") {
+		t.Errorf("not flagged after %d distinct targets", dmSpamRefusedThreshold)
+	}
+}
+
+func TestTrackDMSendSeparateFromOpens(t *testing.T) {
+	const user = "test-send-separate"
+	for i := 0; i < dmSpamRefusedThreshold; i++ {
+		TrackDMOpenRefused(user, fmt.Sprintf("target-%d", i))
+	}
+	if TrackDMSend(user, "target-0", "hello") {
+		t.Error("refused opens counted towards identical-content sends")
+	}
+}
@@ -33,4 +33,21 @@ type FederationProxy interface {
 	// guild and, if so, forwards message creation to the home instance.
 	// Returns true if proxied (handler should return), false if local.
 	ProxyCreateChannelMessage(w http.ResponseWriter, r *http.Request, channelID string, userID string, content string, opts map[string]interface{}) bool
+
+	// ProxyGroupDMRecipientChange checks if the channel is a federated group
+	// DM hosted by another instance and, if so, forwards the recipient add or
+	// remove to the host and applies the resulting membership locally.
+	// proxied is false if the group is hosted here (handler continues). When
+	// proxied, ok reports whether the host accepted the change; if it did not,
+	// the error response has already been written.
+	ProxyGroupDMRecipientChange(w http.ResponseWriter, r *http.Request, channelID, userID, targetUserID string, add bool) (proxied, ok bool)
+
+	// ProxyResolveRemoteInvite writes the guild preview for an invite code
+	// hosted on the instance at domain, with the invite's uses and expiry as
+	// the home instance reports them.
+	ProxyResolveRemoteInvite(w http.ResponseWriter, r *http.Request, domain, code string)
+
+	// ProxyAcceptRemoteInvite redeems an invite code hosted on the instance
+	// at domain for userID and mirrors the joined guild locally.
+	ProxyAcceptRemoteInvite(w http.ResponseWriter, r *http.Request, userID, domain, code string)
 }
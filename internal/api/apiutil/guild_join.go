@@ -0,0 +1,72 @@
+package apiutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// Errors returned by AddGuildMember.
+var (
+	ErrJoinBanned    = errors.New("user is banned from this guild")
+	ErrJoinGuildFull = errors.New("guild has reached its maximum member count")
+)
+
+// AddGuildMember adds a user to a local guild inside tx, refusing a banned
+// user or a full guild. The guild row is locked first so concurrent joins
+// cannot push it past max_members; the member_count trigger counts the new
+// row. It reports whether the user was added and when; a user who is already
+// a member is not added again and is not an error. Once tx commits, callers
+// publish the join with PublishMemberAdd.
+func AddGuildMember(ctx context.Context, tx pgx.Tx, guildID, userID string) (joinedAt time.Time, added bool, err error) {
+	var maxMembers, memberCount int
+	if err := tx.QueryRow(ctx,
+		`SELECT max_members, member_count FROM guilds WHERE id = $1 FOR UPDATE`, guildID,
+	).Scan(&maxMembers, &memberCount); err != nil {
+		return time.Time{}, false, fmt.Errorf("locking guild: %w", err)
+	}
+
+	var member, banned bool
+	if err := tx.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2),
+		        EXISTS(SELECT 1 FROM guild_bans WHERE guild_id = $1 AND user_id = $2)`,
+		guildID, userID,
+	).Scan(&member, &banned); err != nil {
+		return time.Time{}, false, fmt.Errorf("checking membership: %w", err)
+	}
+	switch {
+	case member:
+		return time.Time{}, false, nil
+	case banned:
+		return time.Time{}, false, ErrJoinBanned
+	case maxMembers > 0 && memberCount >= maxMembers:
+		return time.Time{}, false, ErrJoinGuildFull
+	}
+
+	joinedAt = time.Now().UTC()
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO guild_members (guild_id, user_id, joined_at) VALUES ($1, $2, $3)`,
+		guildID, userID, joinedAt); err != nil {
+		return time.Time{}, false, fmt.Errorf("adding guild member: %w", err)
+	}
+	return joinedAt, true, nil
+}
+
+// PublishMemberAdd publishes GUILD_MEMBER_ADD for a user who joined a guild.
+// bus may be nil.
+func PublishMemberAdd(ctx context.Context, bus *events.Bus, guildID, userID string, joinedAt time.Time) {
+	if bus == nil {
+		return
+	}
+	bus.PublishGuildEvent(ctx, events.SubjectGuildMemberAdd, "GUILD_MEMBER_ADD", guildID,
+		map[string]interface{}{
+			"guild_id":  guildID,
+			"user_id":   userID,
+			"joined_at": joinedAt,
+		})
+}
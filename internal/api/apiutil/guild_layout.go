@@ -0,0 +1,100 @@
+package apiutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// LoadGuildLayout returns a user's guild positions, guild folders and
+// favorite channels, each in the user's order.
+func LoadGuildLayout(ctx context.Context, pool *pgxpool.Pool, userID string) (models.GuildLayout, error) {
+	layout := models.GuildLayout{
+		GuildPositions: []models.GuildPosition{},
+		Folders:        []models.GuildFolder{},
+		Favorites:      []models.ChannelFavorite{},
+	}
+
+	rows, err := pool.Query(ctx,
+		`SELECT id, name, position, color, created_at FROM guild_folders
+		 WHERE user_id = $1 ORDER BY position, created_at`, userID)
+	if err != nil {
+		return layout, fmt.Errorf("loading guild folders: %w", err)
+	}
+	folders := make(map[string]int)
+	for rows.Next() {
+		f := models.GuildFolder{GuildIDs: []string{}}
+		if err := rows.Scan(&f.ID, &f.Name, &f.Position, &f.Color, &f.CreatedAt); err != nil {
+			rows.Close()
+			return layout, fmt.Errorf("reading guild folder: %w", err)
+		}
+		folders[f.ID] = len(layout.Folders)
+		layout.Folders = append(layout.Folders, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return layout, fmt.Errorf("loading guild folders: %w", err)
+	}
+
+	// Positions of guilds the user has left are kept until the next
+	// reorder; they are not part of the layout.
+	rows, err = pool.Query(ctx,
+		`SELECT p.guild_id, p.position, p.folder_id, p.folder_position
+		 FROM user_guild_positions p
+		 JOIN guild_members gm ON gm.guild_id = p.guild_id AND gm.user_id = p.user_id
+		 WHERE p.user_id = $1
+		 ORDER BY p.folder_position, p.position`, userID)
+	if err != nil {
+		return layout, fmt.Errorf("loading guild positions: %w", err)
+	}
+	for rows.Next() {
+		var p models.GuildPosition
+		if err := rows.Scan(&p.GuildID, &p.Position, &p.FolderID, &p.FolderPosition); err != nil {
+			rows.Close()
+			return layout, fmt.Errorf("reading guild position: %w", err)
+		}
+		layout.GuildPositions = append(layout.GuildPositions, p)
+		if p.FolderID != nil {
+			if i, ok := folders[*p.FolderID]; ok {
+				layout.Folders[i].GuildIDs = append(layout.Folders[i].GuildIDs, p.GuildID)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return layout, fmt.Errorf("loading guild positions: %w", err)
+	}
+
+	rows, err = pool.Query(ctx,
+		`SELECT f.channel_id, c.guild_id, f.position, f.created_at
+		 FROM user_channel_favorites f
+		 JOIN channels c ON c.id = f.channel_id
+		 WHERE f.user_id = $1 ORDER BY f.position, f.created_at`, userID)
+	if err != nil {
+		return layout, fmt.Errorf("loading channel favorites: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f models.ChannelFavorite
+		if err := rows.Scan(&f.ChannelID, &f.GuildID, &f.Position, &f.CreatedAt); err != nil {
+			return layout, fmt.Errorf("reading channel favorite: %w", err)
+		}
+		layout.Favorites = append(layout.Favorites, f)
+	}
+	return layout, rows.Err()
+}
+
+// PublishGuildLayout sends the user's current layout to their other
+// sessions as GUILD_LAYOUT_UPDATE and returns it.
+func PublishGuildLayout(ctx context.Context, pool *pgxpool.Pool, bus *events.Bus, userID string) (models.GuildLayout, error) {
+	layout, err := LoadGuildLayout(ctx, pool, userID)
+	if err != nil {
+		return layout, err
+	}
+	bus.PublishUserEvent(ctx, events.SubjectGuildLayoutUpdate, "GUILD_LAYOUT_UPDATE", userID, layout)
+	return layout, nil
+}
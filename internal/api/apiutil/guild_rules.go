@@ -0,0 +1,27 @@
+package apiutil
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// GuildRulesPending reports whether a member still has to accept the guild's
+// current rules before posting, reacting or joining voice. The guild owner
+// and bots never do. Lookup errors are treated as not pending.
+func GuildRulesPending(ctx context.Context, pool *pgxpool.Pool, guildID, userID string) bool {
+	var pending bool
+	pool.QueryRow(ctx,
+		`SELECT EXISTS(
+		     SELECT 1 FROM guild_onboarding o
+		     JOIN guild_members gm ON gm.guild_id = o.guild_id AND gm.user_id = $2
+		     JOIN guilds g ON g.id = o.guild_id
+		     JOIN users u ON u.id = gm.user_id
+		     WHERE o.guild_id = $1 AND o.require_rules_acceptance
+		       AND g.owner_id <> gm.user_id AND u.flags & $3 = 0
+		       AND COALESCE(gm.rules_accepted_version, 0) < o.rules_version)`,
+		guildID, userID, models.UserFlagBot).Scan(&pending)
+	return pending
+}
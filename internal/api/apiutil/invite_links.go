@@ -0,0 +1,53 @@
+package apiutil
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// inviteLinkRegex matches Discord invites and the /invite/<code> links of any
+// AmityVox instance, this one or a federated peer, with or without a scheme.
+var inviteLinkRegex = regexp.MustCompile(`(?i)\b(?:https?://)?(?:www\.)?` +
+	`(?:discord\.gg|discord(?:app)?\.com/invite|[a-z0-9-]+(?:\.[a-z0-9-]+)+(?::\d+)?/invite)` +
+	`/[A-Za-z0-9_-]+`)
+
+// FindInviteLinks returns the guild invite links in content.
+func FindInviteLinks(content string) []string {
+	return inviteLinkRegex.FindAllString(content, -1)
+}
+
+// StripInviteLinks removes the guild invite links from content.
+func StripInviteLinks(content string) string {
+	stripped := inviteLinkRegex.ReplaceAllString(content, "")
+	return strings.TrimSpace(stripped)
+}
+
+// ValidInviteLinkPolicy reports whether policy is one of the
+// InviteLinkPolicy constants.
+func ValidInviteLinkPolicy(policy string) bool {
+	switch policy {
+	case models.InviteLinkPolicyAllow, models.InviteLinkPolicyStrip, models.InviteLinkPolicyRequireManageGuild:
+		return true
+	}
+	return false
+}
+
+// ApplyInvitePolicy applies a guild's invite link policy to a message. It
+// returns the content to store, which has the links removed under the strip
+// policy, and whether the message must be refused because the author lacks
+// MANAGE_GUILD under require_manage_guild. Exempt channels and messages
+// without invite links pass unchanged.
+func ApplyInvitePolicy(policy string, exempt, canManageGuild bool, content string) (string, bool) {
+	if exempt || policy == "" || policy == models.InviteLinkPolicyAllow || len(FindInviteLinks(content)) == 0 {
+		return content, false
+	}
+	switch policy {
+	case models.InviteLinkPolicyStrip:
+		return StripInviteLinks(content), false
+	case models.InviteLinkPolicyRequireManageGuild:
+		return content, !canManageGuild
+	}
+	return content, false
+}
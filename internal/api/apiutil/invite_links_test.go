@@ -0,0 +1,43 @@
+package apiutil
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+func TestFindInviteLinks(t *testing.T) {
+	content := "join https://discord.gg/abc123, discord.com/invite/xyz or " +
+		"https://chat.peer.example/invite/Q9z_-k but not https://example.com/invites/no or discord.gg alone"
+	want := []string{"https://discord.gg/abc123", "discord.com/invite/xyz", "https://chat.peer.example/invite/Q9z_-k"}
+	if got := FindInviteLinks(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindInviteLinks() = %v, want %v", got, want)
+	}
+}
+
+func TestApplyInvitePolicy(t *testing.T) {
+	const msg = "come hang out https://discord.gg/abc123"
+	tests := []struct {
+		name       string
+		policy     string
+		exempt     bool
+		canManage  bool
+		content    string
+		want       string
+		wantRefuse bool
+	}{
+		{"allow", models.InviteLinkPolicyAllow, false, false, msg, msg, false},
+		{"strip", models.InviteLinkPolicyStrip, false, false, msg, "come hang out", false},
+		{"strip exempt channel", models.InviteLinkPolicyStrip, true, false, msg, msg, false},
+		{"require without permission", models.InviteLinkPolicyRequireManageGuild, false, false, msg, msg, true},
+		{"require with permission", models.InviteLinkPolicyRequireManageGuild, false, true, msg, msg, false},
+		{"no invite", models.InviteLinkPolicyRequireManageGuild, false, false, "hello", "hello", false},
+	}
+	for _, tt := range tests {
+		got, refuse := ApplyInvitePolicy(tt.policy, tt.exempt, tt.canManage, tt.content)
+		if got != tt.want || refuse != tt.wantRefuse {
+			t.Errorf("%s: ApplyInvitePolicy() = %q, %v; want %q, %v", tt.name, got, refuse, tt.want, tt.wantRefuse)
+		}
+	}
+}
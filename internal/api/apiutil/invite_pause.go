@@ -0,0 +1,27 @@
+package apiutil
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// InvitesPausedUntil returns when the guild's invite pause ends, or nil if its
+// invites are not paused. Lookup errors are treated as not paused.
+func InvitesPausedUntil(ctx context.Context, pool *pgxpool.Pool, guildID string) *time.Time {
+	var until *time.Time
+	pool.QueryRow(ctx,
+		`SELECT invites_paused_until FROM guilds
+		 WHERE id = $1 AND invites_paused_until > now()`, guildID,
+	).Scan(&until)
+	return until
+}
+
+// WriteInvitesPaused writes the error returned when an invite is redeemed
+// while the guild's invites are paused.
+func WriteInvitesPaused(w http.ResponseWriter, until time.Time) {
+	WriteError(w, http.StatusForbidden, "invites_paused",
+		"Invites to this guild are paused until "+until.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,102 @@
+package apiutil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// JoinOriginRefusal is why a guild's join origin policy refuses a user.
+type JoinOriginRefusal struct {
+	Code    string
+	Message string
+}
+
+// Join origin refusal codes, returned to local and remote users alike.
+const (
+	JoinOriginCodeLocalOnly  = "origin_local_only"
+	JoinOriginCodeNotAllowed = "origin_not_allowed"
+	JoinOriginCodeBlocked    = "origin_blocked"
+)
+
+// ValidJoinOriginMode reports whether mode is a JoinOrigin constant.
+func ValidJoinOriginMode(mode string) bool {
+	switch mode {
+	case models.JoinOriginOpen, models.JoinOriginLocalOnly, models.JoinOriginAllowlist:
+		return true
+	}
+	return false
+}
+
+// JoinOriginAllows checks a user from domain against a join origin policy.
+// An empty domain is a user of the guild's own instance, which every mode
+// accepts. Domains compare case-insensitively.
+func JoinOriginAllows(policy models.GuildJoinOriginPolicy, domain string) *JoinOriginRefusal {
+	if domain == "" {
+		return nil
+	}
+	domain = strings.ToLower(domain)
+	if slices.Contains(policy.BlockedDomains, domain) {
+		return &JoinOriginRefusal{JoinOriginCodeBlocked,
+			"This guild does not accept members from " + domain}
+	}
+	switch policy.Mode {
+	case models.JoinOriginLocalOnly:
+		return &JoinOriginRefusal{JoinOriginCodeLocalOnly,
+			"This guild only accepts members from its own instance"}
+	case models.JoinOriginAllowlist:
+		if !slices.Contains(policy.AllowedDomains, domain) {
+			return &JoinOriginRefusal{JoinOriginCodeNotAllowed,
+				"This guild only accepts members from approved instances, and " + domain + " is not one"}
+		}
+	}
+	return nil
+}
+
+// CheckJoinOrigin checks a user from domain against the guild's join origin
+// policy; an empty domain is a local user. Callers should fail closed on
+// error.
+func CheckJoinOrigin(ctx context.Context, pool *pgxpool.Pool, guildID, domain string) (*JoinOriginRefusal, error) {
+	if domain == "" {
+		return nil, nil
+	}
+	policy := models.GuildJoinOriginPolicy{GuildID: guildID, Mode: models.JoinOriginOpen}
+	err := pool.QueryRow(ctx,
+		`SELECT mode, allowed_domains, blocked_domains FROM guild_join_origin_policy WHERE guild_id = $1`,
+		guildID,
+	).Scan(&policy.Mode, &policy.AllowedDomains, &policy.BlockedDomains)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return JoinOriginAllows(policy, domain), nil
+}
+
+// CheckUserJoinOrigin checks an existing user against the guild's join
+// origin policy by the instance their account belongs to. localInstanceID is
+// this instance's ID.
+func CheckUserJoinOrigin(ctx context.Context, pool *pgxpool.Pool, localInstanceID, guildID, userID string) (*JoinOriginRefusal, error) {
+	var domain string
+	err := pool.QueryRow(ctx,
+		`SELECT COALESCE(i.domain, '') FROM users u
+		 LEFT JOIN instances i ON i.id = u.instance_id AND i.id <> $2
+		 WHERE u.id = $1`,
+		userID, localInstanceID,
+	).Scan(&domain)
+	if err != nil {
+		return nil, err
+	}
+	return CheckJoinOrigin(ctx, pool, guildID, domain)
+}
+
+// WriteJoinOriginRefusal writes the error returned when a guild's join origin
+// policy refuses a user.
+func WriteJoinOriginRefusal(w http.ResponseWriter, ref *JoinOriginRefusal) {
+	WriteError(w, http.StatusForbidden, ref.Code, ref.Message)
+}
@@ -0,0 +1,39 @@
+package apiutil
+
+import (
+	"testing"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+func TestJoinOriginAllows(t *testing.T) {
+	blocked := []string{"spam.example"}
+	tests := []struct {
+		name   string
+		policy models.GuildJoinOriginPolicy
+		domain string
+		want   string // refusal code, "" if allowed
+	}{
+		{"local user, local only", models.GuildJoinOriginPolicy{Mode: models.JoinOriginLocalOnly}, "", ""},
+		{"local user, blocked list", models.GuildJoinOriginPolicy{Mode: models.JoinOriginOpen, BlockedDomains: blocked}, "", ""},
+		{"open", models.GuildJoinOriginPolicy{Mode: models.JoinOriginOpen}, "peer.example", ""},
+		{"open, blocked", models.GuildJoinOriginPolicy{Mode: models.JoinOriginOpen, BlockedDomains: blocked}, "spam.example", JoinOriginCodeBlocked},
+		{"blocked, any case", models.GuildJoinOriginPolicy{Mode: models.JoinOriginOpen, BlockedDomains: blocked}, "Spam.Example", JoinOriginCodeBlocked},
+		{"local only", models.GuildJoinOriginPolicy{Mode: models.JoinOriginLocalOnly}, "peer.example", JoinOriginCodeLocalOnly},
+		{"allowlisted", models.GuildJoinOriginPolicy{Mode: models.JoinOriginAllowlist, AllowedDomains: []string{"peer.example"}}, "peer.example", ""},
+		{"not allowlisted", models.GuildJoinOriginPolicy{Mode: models.JoinOriginAllowlist, AllowedDomains: []string{"peer.example"}}, "other.example", JoinOriginCodeNotAllowed},
+		{"allowlisted but blocked", models.GuildJoinOriginPolicy{Mode: models.JoinOriginAllowlist,
+			AllowedDomains: []string{"spam.example"}, BlockedDomains: blocked}, "spam.example", JoinOriginCodeBlocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if ref := JoinOriginAllows(tt.policy, tt.domain); ref != nil {
+				got = ref.Code
+			}
+			if got != tt.want {
+				t.Errorf("refusal = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
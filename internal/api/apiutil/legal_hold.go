@@ -0,0 +1,69 @@
+package apiutil
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Instance settings keys for instance-wide retention. A missing or zero value
+// keeps data forever.
+const (
+	DMRetentionSetting             = "dm_retention_days"
+	DeletedAccountRetentionSetting = "deleted_account_retention_days"
+)
+
+// NotOnLegalHoldSQL is a WHERE condition, for a query over messages aliased
+// m, that leaves out messages written by a held user or posted in a held
+// guild. Every purge of message data must include it.
+const NotOnLegalHoldSQL = `NOT EXISTS (
+	SELECT 1 FROM legal_holds lh
+	WHERE lh.released_at IS NULL AND (
+	    (lh.target_type = 'user' AND lh.target_id = m.author_id) OR
+	    (lh.target_type = 'guild' AND lh.target_id = (SELECT hc.guild_id FROM channels hc WHERE hc.id = m.channel_id))))`
+
+// InstanceRetention is the instance-wide retention configuration, in days.
+type InstanceRetention struct {
+	DMDays             int `json:"dm_retention_days"`
+	DeletedAccountDays int `json:"deleted_account_retention_days"`
+}
+
+// GetInstanceRetention reads the instance-wide retention settings.
+func GetInstanceRetention(ctx context.Context, pool *pgxpool.Pool) (InstanceRetention, error) {
+	var dm, deleted string
+	err := pool.QueryRow(ctx,
+		`SELECT COALESCE((SELECT value FROM instance_settings WHERE key = $1), '0'),
+		        COALESCE((SELECT value FROM instance_settings WHERE key = $2), '0')`,
+		DMRetentionSetting, DeletedAccountRetentionSetting,
+	).Scan(&dm, &deleted)
+	if err != nil {
+		return InstanceRetention{}, err
+	}
+	var ret InstanceRetention
+	ret.DMDays, _ = strconv.Atoi(dm)
+	ret.DeletedAccountDays, _ = strconv.Atoi(deleted)
+	return ret, nil
+}
+
+// OnLegalHold reports whether the user or guild has an active legal hold.
+// Lookup errors count as held so data is never released by mistake.
+func OnLegalHold(ctx context.Context, pool *pgxpool.Pool, targetType, targetID string) bool {
+	var held bool
+	err := pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM legal_holds
+		 WHERE target_type = $1 AND target_id = $2 AND released_at IS NULL)`,
+		targetType, targetID).Scan(&held)
+	return err != nil || held
+}
+
+// RejectIfLegalHold writes a 423 and returns true when the user or guild is
+// under legal hold, freezing exports of its data.
+func RejectIfLegalHold(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, targetType, targetID string) bool {
+	if !OnLegalHold(r.Context(), pool, targetType, targetID) {
+		return false
+	}
+	WriteError(w, http.StatusLocked, "legal_hold", "This data is under legal hold and cannot be exported")
+	return true
+}
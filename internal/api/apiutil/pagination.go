@@ -0,0 +1,189 @@
+package apiutil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes for cursor-paginated lists.
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+// KeyColumn is one column of a Keyset: the SQL expression it orders by and
+// the Postgres type a cursor value is cast to when compared against it.
+type KeyColumn struct {
+	Expr string
+	Type string
+}
+
+// Keyset is the order of a cursor-paginated list. Its columns must together
+// identify a row and must not be NULL; an index on them keeps every page as
+// cheap as the first, however deep the client pages.
+type Keyset struct {
+	Columns []KeyColumn
+	Desc    bool
+}
+
+// Page is a parsed page request: at most Limit rows after, or before, the
+// row a cursor points at, in keyset order.
+type Page struct {
+	Limit    int
+	cursor   []string
+	backward bool
+}
+
+// PageResponse is the envelope of a page. NextCursor continues in the
+// direction the page was fetched: pass it as after for pages fetched
+// forwards and as before for pages fetched with before. It is null once
+// there is nothing further.
+type PageResponse struct {
+	Data       interface{} `json:"data"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// ParsePage reads the before, after and limit query parameters of a request
+// for a list ordered by ks. Limit falls back to defaultLimit when missing or
+// invalid and is capped at MaxPageLimit. On a bad cursor it writes a 400
+// response and returns false.
+func ParsePage(w http.ResponseWriter, r *http.Request, ks Keyset, defaultLimit int) (Page, bool) {
+	q := r.URL.Query()
+	p := Page{Limit: defaultLimit}
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		p.Limit = min(n, MaxPageLimit)
+	}
+
+	before, after := q.Get("before"), q.Get("after")
+	if before != "" && after != "" {
+		WriteError(w, http.StatusBadRequest, "invalid_cursor", "Use either before or after, not both")
+		return Page{}, false
+	}
+	raw := after
+	if before != "" {
+		raw, p.backward = before, true
+	}
+	if raw == "" {
+		return p, true
+	}
+
+	values, err := DecodeCursor(raw)
+	if err != nil || len(values) != len(ks.Columns) {
+		WriteError(w, http.StatusBadRequest, "invalid_cursor", "Invalid pagination cursor")
+		return Page{}, false
+	}
+	p.cursor = values
+	return p, true
+}
+
+// HasCursor reports whether the page starts from a cursor rather than at the
+// head of the list.
+func (p Page) HasCursor() bool {
+	return p.cursor != nil
+}
+
+// Where returns a condition selecting the rows past the page's cursor,
+// starting with " AND ", with placeholders numbered from argIdx, and the
+// arguments to bind to them. It returns "" without a cursor.
+func (p Page) Where(ks Keyset, argIdx int) (string, []interface{}) {
+	if p.cursor == nil {
+		return "", nil
+	}
+	cols := make([]string, len(ks.Columns))
+	params := make([]string, len(ks.Columns))
+	args := make([]interface{}, len(ks.Columns))
+	for i, c := range ks.Columns {
+		cols[i] = c.Expr
+		params[i] = fmt.Sprintf("$%d::%s", argIdx+i, c.Type)
+		args[i] = p.cursor[i]
+	}
+	op := ">"
+	if ks.Desc != p.backward {
+		op = "<"
+	}
+	return fmt.Sprintf(" AND (%s) %s (%s)", strings.Join(cols, ", "), op, strings.Join(params, ", ")), args
+}
+
+// OrderBy returns the ORDER BY list to fetch the page with. Pages fetched
+// with before are read in reverse and put back in order by FinishPage.
+func (p Page) OrderBy(ks Keyset) string {
+	dir := " ASC"
+	if ks.Desc != p.backward {
+		dir = " DESC"
+	}
+	terms := make([]string, len(ks.Columns))
+	for i, c := range ks.Columns {
+		terms[i] = c.Expr + dir
+	}
+	return strings.Join(terms, ", ")
+}
+
+// FetchLimit is the LIMIT to query the page with: one row more than the
+// page holds, which tells FinishPage whether anything lies beyond it.
+func (p Page) FetchLimit() int {
+	return p.Limit + 1
+}
+
+// FinishPage trims rows fetched with FetchLimit to the page, puts them in
+// keyset order, and returns them with the cursor continuing past them, or
+// nil if there is nothing further. key returns a row's keyset values.
+func FinishPage[T any](p Page, rows []T, key func(T) []interface{}) ([]T, *string) {
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+	if p.backward {
+		slices.Reverse(rows)
+	}
+	if !more || len(rows) == 0 {
+		return rows, nil
+	}
+	edge := rows[len(rows)-1]
+	if p.backward {
+		edge = rows[0]
+	}
+	next := EncodeCursor(key(edge)...)
+	return rows, &next
+}
+
+// WritePage writes a page with 200 OK.
+func WritePage(w http.ResponseWriter, data interface{}, next *string) {
+	WriteJSONRaw(w, http.StatusOK, PageResponse{Data: data, NextCursor: next})
+}
+
+// EncodeCursor encodes a row's keyset values as an opaque cursor. Times are
+// kept to the nanosecond so the cursor compares equal to the stored row.
+func EncodeCursor(values ...interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case time.Time:
+			parts[i] = v.UTC().Format(time.RFC3339Nano)
+		case string:
+			parts[i] = v
+		default:
+			parts[i] = fmt.Sprint(v)
+		}
+	}
+	b, _ := json.Marshal(parts)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor returns the keyset values encoded in a cursor.
+func DecodeCursor(cursor string) ([]string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var parts []string
+	if err := json.Unmarshal(b, &parts); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
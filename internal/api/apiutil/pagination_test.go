@@ -0,0 +1,127 @@
+package apiutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testKeyset = Keyset{Desc: true, Columns: []KeyColumn{
+	{Expr: "created_at", Type: "timestamptz"},
+	{Expr: "id", Type: "text"},
+}}
+
+func parseTestPage(t *testing.T, query string) (Page, int) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	p, ok := ParsePage(w, httptest.NewRequest(http.MethodGet, "/?"+query, nil), testKeyset, DefaultPageLimit)
+	if !ok {
+		return p, w.Code
+	}
+	return p, 0
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 123456000, time.FixedZone("x", 3600))
+	got, err := DecodeCursor(EncodeCursor(at, "01HXYZ"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2026-03-04T04:06:07.123456Z", "01HXYZ"}
+	if !slices.Equal(got, want) {
+		t.Errorf("DecodeCursor = %q, want %q", got, want)
+	}
+	if _, err := DecodeCursor("not a cursor!"); err == nil {
+		t.Error("DecodeCursor accepted garbage")
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	cursor := EncodeCursor("2026-01-01T00:00:00Z", "a")
+	tests := []struct {
+		query    string
+		limit    int
+		wantCode int
+	}{
+		{"", DefaultPageLimit, 0},
+		{"limit=5", 5, 0},
+		{"limit=0", DefaultPageLimit, 0},
+		{"limit=abc", DefaultPageLimit, 0},
+		{"limit=100000", MaxPageLimit, 0},
+		{"after=" + cursor, DefaultPageLimit, 0},
+		{"before=" + cursor + "&after=" + cursor, 0, http.StatusBadRequest},
+		{"after=01HXYZ", 0, http.StatusBadRequest},
+		{"after=" + EncodeCursor("only-one"), 0, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		p, code := parseTestPage(t, tt.query)
+		if code != tt.wantCode {
+			t.Errorf("ParsePage(%q) status = %d, want %d", tt.query, code, tt.wantCode)
+			continue
+		}
+		if code == 0 && p.Limit != tt.limit {
+			t.Errorf("ParsePage(%q).Limit = %d, want %d", tt.query, p.Limit, tt.limit)
+		}
+	}
+}
+
+func TestPageSQL(t *testing.T) {
+	cursor := EncodeCursor("2026-01-01T00:00:00Z", "a")
+	tests := []struct {
+		query, where, order string
+	}{
+		{"", "", "created_at DESC, id DESC"},
+		{"after=" + cursor, " AND (created_at, id) < ($3::timestamptz, $4::text)", "created_at DESC, id DESC"},
+		{"before=" + cursor, " AND (created_at, id) > ($3::timestamptz, $4::text)", "created_at ASC, id ASC"},
+	}
+	for _, tt := range tests {
+		p, _ := parseTestPage(t, tt.query)
+		where, args := p.Where(testKeyset, 3)
+		if where != tt.where {
+			t.Errorf("%q: Where = %q, want %q", tt.query, where, tt.where)
+		}
+		if where != "" && len(args) != 2 {
+			t.Errorf("%q: Where args = %v", tt.query, args)
+		}
+		if order := p.OrderBy(testKeyset); order != tt.order {
+			t.Errorf("%q: OrderBy = %q, want %q", tt.query, order, tt.order)
+		}
+	}
+}
+
+func TestFinishPage(t *testing.T) {
+	key := func(s string) []interface{} { return []interface{}{s} }
+	oneKey := Keyset{Columns: []KeyColumn{{Expr: "name", Type: "text"}}}
+	page := func(query string) Page {
+		w := httptest.NewRecorder()
+		p, ok := ParsePage(w, httptest.NewRequest(http.MethodGet, "/?"+query, nil), oneKey, 2)
+		if !ok {
+			t.Fatalf("ParsePage(%q) failed: %s", query, w.Body)
+		}
+		return p
+	}
+
+	rows, next := FinishPage(page(""), []string{"a", "b", "c"}, key)
+	if !slices.Equal(rows, []string{"a", "b"}) || next == nil {
+		t.Fatalf("forward page = %v, next %v", rows, next)
+	}
+	if v, _ := DecodeCursor(*next); v[0] != "b" {
+		t.Errorf("forward next cursor points at %q, want b", v[0])
+	}
+
+	rows, next = FinishPage(page(""), []string{"a", "b"}, key)
+	if len(rows) != 2 || next != nil {
+		t.Errorf("last page = %v, next %v; want no cursor", rows, next)
+	}
+
+	// Backward pages arrive in reverse order.
+	rows, next = FinishPage(page("before="+EncodeCursor("z")), []string{"y", "x", "w"}, key)
+	if !slices.Equal(rows, []string{"x", "y"}) || next == nil {
+		t.Fatalf("backward page = %v, next %v", rows, next)
+	}
+	if v, _ := DecodeCursor(*next); v[0] != "x" {
+		t.Errorf("backward next cursor points at %q, want x", v[0])
+	}
+}
@@ -0,0 +1,23 @@
+package apiutil
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// FromRestrictedPeer reports whether userID is a remote user whose home
+// instance this instance has marked restricted. Lookup errors are treated as
+// not restricted.
+func FromRestrictedPeer(ctx context.Context, pool *pgxpool.Pool, instanceID, userID string) bool {
+	var restricted bool
+	pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM users u
+		               JOIN federation_peers fp ON fp.peer_id = u.instance_id
+		               WHERE u.id = $1 AND fp.instance_id = $2 AND fp.trust_level = $3)`,
+		userID, instanceID, models.PeerTrustRestricted,
+	).Scan(&restricted)
+	return restricted
+}
@@ -0,0 +1,182 @@
+package apiutil
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/presence"
+)
+
+// PendingPolicies is checked on every authenticated request, so both halves
+// of the check are cached: the required versions until the next publish, and
+// each user's acknowledgments until they acknowledge again.
+const (
+	requiredPoliciesGenKey = "policies:required"
+	requiredPoliciesTTL    = time.Hour
+	policyAcksTTL          = 10 * time.Minute
+)
+
+// requiredPolicy is the latest version of a policy kind a user must have
+// acknowledged, and the ID of that kind's current version.
+type requiredPolicy struct {
+	Kind      string `json:"kind"`
+	Version   int    `json:"version"`
+	CurrentID string `json:"current_id"`
+}
+
+// policyAcks is a user's highest acknowledged version per policy kind.
+type policyAcks struct {
+	Bot      bool           `json:"bot"`
+	Versions map[string]int `json:"versions"`
+}
+
+// PendingPolicies returns the IDs of the current instance policies a user
+// still has to acknowledge. For each kind, the user must have acknowledged
+// the latest version that required acknowledgment, or any version after it.
+// Bots never have pending policies. cache may be nil.
+func PendingPolicies(ctx context.Context, pool *pgxpool.Pool, cache *presence.Cache, userID string) ([]string, error) {
+	required, err := loadRequiredPolicies(ctx, pool, cache)
+	if err != nil || len(required) == 0 {
+		return nil, err
+	}
+	acks, err := loadPolicyAcks(ctx, pool, cache, userID)
+	if err != nil || acks.Bot {
+		return nil, err
+	}
+
+	var ids []string
+	for _, p := range required {
+		if acks.Versions[p.Kind] < p.Version {
+			ids = append(ids, p.CurrentID)
+		}
+	}
+	return ids, nil
+}
+
+// InvalidateRequiredPolicies drops every cached set of required policy
+// versions. Call it after publishing a policy. cache may be nil.
+func InvalidateRequiredPolicies(ctx context.Context, cache *presence.Cache, logger *slog.Logger) {
+	if cache == nil {
+		return
+	}
+	if err := cache.BumpGeneration(ctx, requiredPoliciesGenKey); err != nil {
+		logger.Warn("failed to invalidate required policies", slog.String("error", err.Error()))
+	}
+}
+
+// InvalidatePolicyAcks drops a user's cached acknowledgments. Call it after
+// the user acknowledges policies. cache may be nil.
+func InvalidatePolicyAcks(ctx context.Context, cache *presence.Cache, logger *slog.Logger, userID string) {
+	if cache == nil {
+		return
+	}
+	if err := cache.BumpGeneration(ctx, policyAcksGenKey(userID)); err != nil {
+		logger.Warn("failed to invalidate policy acknowledgments",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+}
+
+func policyAcksGenKey(userID string) string {
+	return "policies:acks:" + userID
+}
+
+// loadRequiredPolicies returns, per kind, the latest version that required
+// acknowledgment, ordered by kind.
+func loadRequiredPolicies(ctx context.Context, pool *pgxpool.Pool, cache *presence.Cache) ([]requiredPolicy, error) {
+	var key string
+	if cache != nil {
+		if gen, err := cache.Generation(ctx, requiredPoliciesGenKey); err == nil {
+			key = fmt.Sprintf("%s:%d", requiredPoliciesGenKey, gen)
+			var cached []requiredPolicy
+			if ok, err := cache.Get(ctx, key, &cached); err == nil && ok {
+				return cached, nil
+			}
+		}
+	}
+
+	rows, err := pool.Query(ctx,
+		`WITH required AS (
+		     SELECT kind, MAX(version) AS version FROM instance_policies
+		     WHERE requires_acknowledgment GROUP BY kind
+		 )
+		 SELECT r.kind, r.version, cur.id FROM required r
+		 JOIN LATERAL (
+		     SELECT id FROM instance_policies p WHERE p.kind = r.kind ORDER BY version DESC LIMIT 1
+		 ) cur ON true
+		 ORDER BY r.kind`)
+	if err != nil {
+		return nil, fmt.Errorf("loading required policies: %w", err)
+	}
+	defer rows.Close()
+
+	required := []requiredPolicy{}
+	for rows.Next() {
+		var p requiredPolicy
+		if err := rows.Scan(&p.Kind, &p.Version, &p.CurrentID); err != nil {
+			return nil, fmt.Errorf("reading required policies: %w", err)
+		}
+		required = append(required, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading required policies: %w", err)
+	}
+
+	if key != "" {
+		cache.Set(ctx, key, required, requiredPoliciesTTL)
+	}
+	return required, nil
+}
+
+// loadPolicyAcks returns the highest policy version per kind the user has
+// acknowledged, and whether the user is a bot.
+func loadPolicyAcks(ctx context.Context, pool *pgxpool.Pool, cache *presence.Cache, userID string) (policyAcks, error) {
+	acks := policyAcks{Versions: map[string]int{}}
+	var key string
+	if cache != nil {
+		if gen, err := cache.Generation(ctx, policyAcksGenKey(userID)); err == nil {
+			key = fmt.Sprintf("%s:%d", policyAcksGenKey(userID), gen)
+			if ok, err := cache.Get(ctx, key, &acks); err == nil && ok {
+				return acks, nil
+			}
+		}
+	}
+
+	var flags int
+	if err := pool.QueryRow(ctx,
+		`SELECT COALESCE((SELECT flags FROM users WHERE id = $1), 0)`, userID).Scan(&flags); err != nil {
+		return acks, fmt.Errorf("loading user flags: %w", err)
+	}
+	acks.Bot = flags&models.UserFlagBot != 0
+
+	rows, err := pool.Query(ctx,
+		`SELECT p.kind, MAX(p.version)
+		 FROM instance_policy_acknowledgments a
+		 JOIN instance_policies p ON p.id = a.policy_id
+		 WHERE a.user_id = $1
+		 GROUP BY p.kind`, userID)
+	if err != nil {
+		return acks, fmt.Errorf("loading policy acknowledgments: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind string
+		var version int
+		if err := rows.Scan(&kind, &version); err != nil {
+			return acks, fmt.Errorf("reading policy acknowledgments: %w", err)
+		}
+		acks.Versions[kind] = version
+	}
+	if err := rows.Err(); err != nil {
+		return acks, fmt.Errorf("reading policy acknowledgments: %w", err)
+	}
+
+	if key != "" {
+		cache.Set(ctx, key, acks, policyAcksTTL)
+	}
+	return acks, nil
+}
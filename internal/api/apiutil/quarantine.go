@@ -0,0 +1,34 @@
+package apiutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// IsQuarantined reports whether a user is under a pending shadow quarantine.
+// Lookup errors are treated as not quarantined.
+func IsQuarantined(ctx context.Context, pool *pgxpool.Pool, userID string) bool {
+	var quarantined bool
+	pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM user_quarantines WHERE user_id = $1 AND status = 'pending')`,
+		userID).Scan(&quarantined)
+	return quarantined
+}
+
+// QuarantineUser places a user under shadow quarantine. It reports false if
+// the user was already quarantined.
+func QuarantineUser(ctx context.Context, pool *pgxpool.Pool, q models.UserQuarantine) (bool, error) {
+	tag, err := pool.Exec(ctx,
+		`INSERT INTO user_quarantines (id, user_id, source, reason, guild_id, status, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, 'pending', $6, now())
+		 ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING`,
+		models.NewULID().String(), q.UserID, q.Source, q.Reason, q.GuildID, q.CreatedBy)
+	if err != nil {
+		return false, fmt.Errorf("inserting user quarantine: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,74 @@
+package apiutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// ChannelsAck is the payload of CHANNELS_ACK, the one event sent when a
+// user marks a whole guild, or everything, as read. GuildID is nil when
+// every channel was acked.
+type ChannelsAck struct {
+	UserID     string   `json:"user_id"`
+	GuildID    *string  `json:"guild_id"`
+	ChannelIDs []string `json:"channel_ids"`
+}
+
+// ReadableGuildChannels returns the channels of a guild the member can read,
+// leaving out channels only previewed. It returns pgx.ErrNoRows when userID
+// is not a member.
+func ReadableGuildChannels(ctx context.Context, pool *pgxpool.Pool, guildID, userID string) ([]string, error) {
+	access, err := MemberGuildChannelAccess(ctx, pool, guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(access))
+	for id, a := range access {
+		if a.Permissions&permissions.ViewChannel != 0 && !a.Preview {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// AckChannels marks channels read up to their latest message for a user in
+// one statement, clearing their mention counts, and publishes a single
+// CHANNELS_ACK. It returns the latest message of each channel that had
+// messages, by channel ID, for sending read receipts.
+func AckChannels(ctx context.Context, pool *pgxpool.Pool, bus *events.Bus, userID string, guildID *string, channelIDs []string) (map[string]string, error) {
+	acked := make(map[string]string)
+	if len(channelIDs) > 0 {
+		rows, err := pool.Query(ctx,
+			`INSERT INTO read_state (user_id, channel_id, last_read_id, mention_count)
+			 SELECT $1, id, last_message_id, 0 FROM channels
+			 WHERE id = ANY($2) AND last_message_id IS NOT NULL
+			 ON CONFLICT (user_id, channel_id) DO UPDATE
+			     SET last_read_id = EXCLUDED.last_read_id, mention_count = 0
+			 RETURNING channel_id, last_read_id`,
+			userID, channelIDs)
+		if err != nil {
+			return nil, fmt.Errorf("acking channels: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var channelID, lastReadID string
+			if err := rows.Scan(&channelID, &lastReadID); err != nil {
+				return nil, fmt.Errorf("acking channels: %w", err)
+			}
+			acked[channelID] = lastReadID
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("acking channels: %w", err)
+		}
+	}
+
+	bus.PublishUserEvent(ctx, events.SubjectChannelAck, "CHANNELS_ACK", userID, ChannelsAck{
+		UserID: userID, GuildID: guildID, ChannelIDs: channelIDs,
+	})
+	return acked, nil
+}
@@ -0,0 +1,40 @@
+package apiutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/presence"
+)
+
+// RevokeUserSessions deletes every session for a user, evicts them from the
+// session cache, and tells the gateway to drop the user's connections. cache
+// and bus may be nil.
+func RevokeUserSessions(ctx context.Context, pool *pgxpool.Pool, cache *presence.Cache, bus *events.Bus, userID, reason string) error {
+	rows, err := pool.Query(ctx, `DELETE FROM user_sessions WHERE user_id = $1 RETURNING id`, userID)
+	if err != nil {
+		return fmt.Errorf("deleting user sessions: %w", err)
+	}
+	var sessionIDs []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			sessionIDs = append(sessionIDs, id)
+		}
+	}
+	rows.Close()
+
+	if cache != nil {
+		for _, id := range sessionIDs {
+			cache.DeleteSession(ctx, id)
+		}
+	}
+	if bus != nil {
+		bus.PublishUserEvent(ctx, events.SubjectUserSessionsRevoked, "SESSIONS_REVOKED", userID,
+			map[string]string{"user_id": userID, "reason": reason})
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package apiutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/presence"
+)
+
+// SourceRateLimited counts a message from source against the channel's
+// limit for that source and returns how long the sender should wait if the
+// limit is reached, or zero. Messages are counted in the shared cache, so
+// without one, or without a limit, nothing is enforced; lookup errors also
+// let the message through.
+func SourceRateLimited(ctx context.Context, pool *pgxpool.Pool, cache *presence.Cache, channelID, source string) time.Duration {
+	if cache == nil {
+		return 0
+	}
+	var maxMessages, windowSeconds int
+	if err := pool.QueryRow(ctx,
+		`SELECT max_messages, window_seconds FROM channel_source_rate_limits
+		 WHERE channel_id = $1 AND source = $2`, channelID, source,
+	).Scan(&maxMessages, &windowSeconds); err != nil {
+		return 0
+	}
+
+	// The window is part of the key so changing it starts a fresh count.
+	key := fmt.Sprintf("channel_source:%s:%s:%d", channelID, source, windowSeconds)
+	window := time.Duration(windowSeconds) * time.Second
+	n, err := cache.WindowCount(ctx, key, window)
+	if err != nil {
+		return 0
+	}
+	if n >= float64(maxMessages) {
+		return window
+	}
+	cache.IncrWindowCount(ctx, key, window)
+	return 0
+}
+
+// WriteSourceRateLimited writes the error returned when a channel's limit
+// for a message source is reached.
+func WriteSourceRateLimited(w http.ResponseWriter, source string, retryAfter time.Duration) {
+	seconds := int(retryAfter.Seconds())
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	WriteError(w, http.StatusTooManyRequests, "source_rate_limited",
+		fmt.Sprintf("This channel is receiving too many %s messages. Try again in %d seconds", source, seconds))
+}
@@ -0,0 +1,42 @@
+package apiutil
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// InstancePermissions returns the instance-scoped staff permissions held by a
+// user. Instance admins hold every permission; everyone else holds the union
+// of their instance roles.
+func InstancePermissions(ctx context.Context, pool *pgxpool.Pool, userID string) (uint64, error) {
+	var flags int
+	var rolePerms int64
+	err := pool.QueryRow(ctx,
+		`SELECT u.flags, COALESCE(bit_or(ir.permissions), 0)
+		 FROM users u
+		 LEFT JOIN user_instance_roles uir ON uir.user_id = u.id
+		 LEFT JOIN instance_roles ir ON ir.id = uir.role_id
+		 WHERE u.id = $1
+		 GROUP BY u.flags`, userID).Scan(&flags, &rolePerms)
+	if err != nil {
+		return 0, err
+	}
+	if flags&models.UserFlagAdmin != 0 {
+		return permissions.AllInstancePermissions, nil
+	}
+	return uint64(rolePerms), nil
+}
+
+// HasInstancePermission reports whether the user holds the given instance
+// permission. Lookup errors are treated as a denial.
+func HasInstancePermission(ctx context.Context, pool *pgxpool.Pool, userID string, perm uint64) bool {
+	perms, err := InstancePermissions(ctx, pool, userID)
+	if err != nil {
+		return false
+	}
+	return perms&perm == perm
+}
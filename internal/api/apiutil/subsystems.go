@@ -0,0 +1,35 @@
+package apiutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// DisabledSubsystemsSetting is the instance_settings key holding the
+// subsystems switched off by an operator, as JSON.
+const DisabledSubsystemsSetting = "disabled_subsystems"
+
+// LoadDisabledSubsystems returns the subsystems switched off by an operator.
+// Everything is enabled when nothing has been stored.
+func LoadDisabledSubsystems(ctx context.Context, pool *pgxpool.Pool) (models.DisabledSubsystems, error) {
+	var d models.DisabledSubsystems
+	var raw string
+	err := pool.QueryRow(ctx,
+		`SELECT value FROM instance_settings WHERE key = $1`, DisabledSubsystemsSetting).Scan(&raw)
+	if err == pgx.ErrNoRows {
+		return d, nil
+	}
+	if err != nil {
+		return d, fmt.Errorf("loading disabled subsystems: %w", err)
+	}
+	if err := json.Unmarshal([]byte(raw), &d); err != nil {
+		return d, fmt.Errorf("decoding disabled subsystems: %w", err)
+	}
+	return d, nil
+}
@@ -0,0 +1,66 @@
+package apiutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// systemMessageToggles maps each message type the server posts to a guild's
+// system channel to the guild column that turns it off. Security alerts
+// cannot be turned off.
+var systemMessageToggles = map[string]string{
+	models.MessageTypeSystemJoin:          "system_join_messages",
+	models.MessageTypeSystemBoost:         "system_boost_messages",
+	models.MessageTypeSystemEventReminder: "system_event_reminders",
+	models.MessageTypeSystemSecurityAlert: "true",
+}
+
+// PostSystemMessage posts a server-generated message to the guild's system
+// channel, attributed to authorID. Nothing is posted, and nil is returned,
+// when the guild has no system channel or has turned messageType off.
+func PostSystemMessage(ctx context.Context, pool *pgxpool.Pool, bus *events.Bus, guildID, messageType, authorID string, content *string) (*models.Message, error) {
+	toggle, ok := systemMessageToggles[messageType]
+	if !ok {
+		return nil, fmt.Errorf("no system channel toggle for message type %q", messageType)
+	}
+
+	var channelID *string
+	var enabled bool
+	err := pool.QueryRow(ctx,
+		`SELECT system_channel_id, `+toggle+` FROM guilds WHERE id = $1`, guildID,
+	).Scan(&channelID, &enabled)
+	if err == pgx.ErrNoRows || (err == nil && (channelID == nil || !enabled)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading system channel: %w", err)
+	}
+
+	msg := models.Message{
+		ID:          models.NewULID().String(),
+		ChannelID:   *channelID,
+		AuthorID:    authorID,
+		Content:     content,
+		MessageType: messageType,
+	}
+	if err := pool.QueryRow(ctx,
+		`INSERT INTO messages (id, channel_id, author_id, content, message_type, flags, created_at)
+		 VALUES ($1, $2, $3, $4, $5, 0, now())
+		 RETURNING created_at`,
+		msg.ID, msg.ChannelID, msg.AuthorID, msg.Content, msg.MessageType,
+	).Scan(&msg.CreatedAt); err != nil {
+		return nil, fmt.Errorf("posting system message: %w", err)
+	}
+	pool.Exec(ctx, `UPDATE channels SET last_message_id = $1 WHERE id = $2`, msg.ID, msg.ChannelID)
+
+	if bus != nil {
+		bus.PublishChannelEvent(ctx, events.SubjectMessageCreate, "MESSAGE_CREATE", msg.ChannelID, msg)
+	}
+	return &msg, nil
+}
@@ -0,0 +1,17 @@
+package apiutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+func TestPostSystemMessage_UnknownType(t *testing.T) {
+	for _, messageType := range []string{models.MessageTypeDefault, models.MessageTypeSystemLockdown, ""} {
+		msg, err := PostSystemMessage(context.Background(), nil, nil, "guild1", messageType, "user1", nil)
+		if err == nil || msg != nil {
+			t.Errorf("PostSystemMessage(%q) = %v, %v; want an error", messageType, msg, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package apiutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// TimeoutRoleName is the name given to the role that backs timeouts.
+const TimeoutRoleName = "Timed Out"
+
+// TimeoutRoleID returns the guild's timeout role, or "" if the guild does not
+// back timeouts with one. Lookup errors are treated as no role.
+func TimeoutRoleID(ctx context.Context, pool *pgxpool.Pool, guildID string) string {
+	var roleID *string
+	pool.QueryRow(ctx, `SELECT timeout_role_id FROM guilds WHERE id = $1`, guildID).Scan(&roleID)
+	if roleID == nil {
+		return ""
+	}
+	return *roleID
+}
+
+// ApplyTimeoutOverrides makes sure every channel in the guild has an override
+// for the timeout role that denies the timeout permissions and allows none of
+// them, leaving any other bits an admin set alone. It returns the channels
+// whose overrides changed.
+func ApplyTimeoutOverrides(ctx context.Context, pool *pgxpool.Pool, guildID, roleID string) ([]string, error) {
+	mask := int64(permissions.TimeoutActionMask)
+	rows, err := pool.Query(ctx,
+		`INSERT INTO channel_permission_overrides (channel_id, target_type, target_id, permissions_allow, permissions_deny)
+		 SELECT id, 'role', $2, 0, $3::bigint FROM channels WHERE guild_id = $1
+		 ON CONFLICT (channel_id, target_type, target_id) DO UPDATE SET
+		     permissions_allow = channel_permission_overrides.permissions_allow & ~$3::bigint,
+		     permissions_deny = channel_permission_overrides.permissions_deny | $3::bigint
+		 WHERE channel_permission_overrides.permissions_deny & $3::bigint <> $3::bigint
+		    OR channel_permission_overrides.permissions_allow & $3::bigint <> 0
+		 RETURNING channel_id`,
+		guildID, roleID, mask)
+	if err != nil {
+		return nil, fmt.Errorf("applying timeout overrides: %w", err)
+	}
+	return collectIDs(rows)
+}
+
+// SyncTimeoutRole gives the timeout role to members whose timeout is active
+// and takes it from members whose timeout has ended, then publishes a member
+// update for each change. If userID is non-empty only that member is synced.
+// bus may be nil.
+func SyncTimeoutRole(ctx context.Context, pool *pgxpool.Pool, bus *events.Bus, guildID, roleID, userID string) error {
+	rows, err := pool.Query(ctx,
+		`INSERT INTO member_roles (guild_id, user_id, role_id)
+		 SELECT guild_id, user_id, $2 FROM guild_members
+		 WHERE guild_id = $1 AND timeout_until > now() AND ($3 = '' OR user_id = $3)
+		 ON CONFLICT DO NOTHING
+		 RETURNING user_id`,
+		guildID, roleID, userID)
+	if err != nil {
+		return fmt.Errorf("adding timeout role: %w", err)
+	}
+	added, err := collectIDs(rows)
+	if err != nil {
+		return fmt.Errorf("adding timeout role: %w", err)
+	}
+
+	rows, err = pool.Query(ctx,
+		`DELETE FROM member_roles mr
+		 WHERE mr.guild_id = $1 AND mr.role_id = $2 AND ($3 = '' OR mr.user_id = $3)
+		   AND NOT EXISTS (
+		       SELECT 1 FROM guild_members gm
+		       WHERE gm.guild_id = mr.guild_id AND gm.user_id = mr.user_id AND gm.timeout_until > now()
+		   )
+		 RETURNING mr.user_id`,
+		guildID, roleID, userID)
+	if err != nil {
+		return fmt.Errorf("removing timeout role: %w", err)
+	}
+	removed, err := collectIDs(rows)
+	if err != nil {
+		return fmt.Errorf("removing timeout role: %w", err)
+	}
+
+	if bus == nil {
+		return nil
+	}
+	publish := func(ids []string, action string) {
+		for _, id := range ids {
+			var roles []string
+			if r, err := pool.Query(ctx,
+				`SELECT role_id FROM member_roles WHERE guild_id = $1 AND user_id = $2`, guildID, id); err == nil {
+				roles, _ = collectIDs(r)
+			}
+			bus.PublishGuildEvent(ctx, events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", guildID, map[string]interface{}{
+				"guild_id": guildID, "user_id": id, "role_id": roleID, "action": action,
+				"roles": roles,
+			})
+		}
+	}
+	publish(added, "role_add")
+	publish(removed, "role_remove")
+	return nil
+}
+
+// collectIDs reads a single text column from rows and closes them.
+func collectIDs(rows pgx.Rows) ([]string, error) {
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
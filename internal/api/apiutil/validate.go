@@ -4,13 +4,15 @@ import (
 	"fmt"
 	"net/http"
 	"unicode/utf8"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 )
 
 // RequireNonEmpty checks that s is not empty. On failure it writes a 400 error
 // with message "<field> is required" and returns false.
 func RequireNonEmpty(w http.ResponseWriter, field, s string) bool {
 	if s == "" {
-		WriteError(w, http.StatusBadRequest, "invalid_body", field+" is required")
+		WriteCode(w, apierrors.InvalidBody, field+" is required")
 		return false
 	}
 	return true
@@ -22,12 +24,12 @@ func RequireNonEmpty(w http.ResponseWriter, field, s string) bool {
 func ValidateStringLength(w http.ResponseWriter, field, s string, min, max int) bool {
 	n := utf8.RuneCountInString(s)
 	if min > 0 && n < min {
-		WriteError(w, http.StatusBadRequest, "invalid_body",
+		WriteCode(w, apierrors.InvalidBody,
 			fmt.Sprintf("%s must be at least %d characters", field, min))
 		return false
 	}
 	if max > 0 && n > max {
-		WriteError(w, http.StatusBadRequest, "invalid_body",
+		WriteCode(w, apierrors.InvalidBody,
 			fmt.Sprintf("%s must be at most %d characters", field, max))
 		return false
 	}
@@ -42,7 +44,7 @@ func ValidateEnum(w http.ResponseWriter, field, value string, allowed []string)
 			return true
 		}
 	}
-	WriteError(w, http.StatusBadRequest, "invalid_body",
+	WriteCode(w, apierrors.InvalidBody,
 		fmt.Sprintf("Invalid %s (allowed: %v)", field, allowed))
 	return false
 }
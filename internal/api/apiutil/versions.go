@@ -0,0 +1,27 @@
+package apiutil
+
+import (
+	"net/http"
+)
+
+// VersionConflictResponse is the body of a 409 version_conflict: the
+// standard error plus the resource as it now stands, in the same "data"
+// field a successful response would use.
+type VersionConflictResponse struct {
+	Error ErrorBody   `json:"error"`
+	Data  interface{} `json:"data"`
+}
+
+// WriteVersionConflict writes the error returned when a PATCH carries a
+// version that is no longer current because someone else edited the
+// resource first. The client can merge its changes into current and retry
+// with current's version.
+func WriteVersionConflict(w http.ResponseWriter, current interface{}) {
+	WriteJSONRaw(w, http.StatusConflict, VersionConflictResponse{
+		Error: ErrorBody{
+			Code:    "version_conflict",
+			Message: "This was changed by someone else since you loaded it",
+		},
+		Data: current,
+	})
+}
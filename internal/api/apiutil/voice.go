@@ -0,0 +1,28 @@
+package apiutil
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// VoicePresence reports who is connected to which voice channel. The
+// voice.Service in internal/voice satisfies this interface.
+type VoicePresence interface {
+	// InVoiceChannel reports whether the user is connected to channelID.
+	InVoiceChannel(userID, channelID string) bool
+}
+
+// VoiceChatAccess reports whether userID is connected to the voice or stage
+// channel according to its participant log, and whether they are at least a
+// recent participant, having left within the channel's
+// voice_chat_recent_minutes.
+func VoiceChatAccess(ctx context.Context, pool *pgxpool.Pool, channelID, userID string) (connected, recent bool) {
+	pool.QueryRow(ctx,
+		`SELECT p.left_at IS NULL,
+		        p.left_at IS NULL OR p.left_at > now() - make_interval(mins => c.voice_chat_recent_minutes)
+		 FROM voice_chat_participants p JOIN channels c ON c.id = p.channel_id
+		 WHERE p.channel_id = $1 AND p.user_id = $2`,
+		channelID, userID).Scan(&connected, &recent)
+	return connected, recent
+}
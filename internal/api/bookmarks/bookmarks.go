@@ -13,6 +13,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
@@ -77,7 +78,7 @@ func (h *Handler) HandleCreateBookmark(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 		return
 	}
 
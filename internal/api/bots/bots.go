@@ -1,9 +1,12 @@
 // Package bots implements REST API handlers for bot account management,
-// bot token authentication, and slash command registration.
-// Mounted under /api/v1/users/@me/bots and /api/v1/bots/{botID}.
+// bot token authentication, slash command registration, the bot directory,
+// message content access requests, and user-installed apps and their
+// interactions. Mounted under /api/v1/users/@me/bots, /api/v1/users/@me/apps,
+// /api/v1/bots and /api/v1/interactions.
 package bots
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
@@ -19,6 +22,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -33,6 +37,7 @@ type Handler struct {
 	AuthService *auth.Service
 	EventBus    *events.Bus
 	Logger      *slog.Logger
+	SigningKey  ed25519.PrivateKey // optional — enables interaction endpoints
 }
 
 // --- Helpers ---
@@ -342,7 +347,8 @@ func (h *Handler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body struct {
-		Name string `json:"name"`
+		Name  string `json:"name"`
+		Scope string `json:"scope"`
 	}
 	if r.Body != nil && r.ContentLength > 0 {
 		if !apiutil.DecodeJSON(w, r, &body) {
@@ -352,6 +358,13 @@ func (h *Handler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
 	if body.Name == "" {
 		body.Name = "default"
 	}
+	if body.Scope == "" {
+		body.Scope = models.BotTokenScopeBot
+	}
+	if body.Scope != models.BotTokenScopeBot && body.Scope != models.BotTokenScopeUserApp {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_scope", "scope must be bot or user_app")
+		return
+	}
 	body.Name = strings.TrimSpace(body.Name)
 	if len(body.Name) > 64 {
 		apiutil.WriteError(w, http.StatusBadRequest, "name_too_long", "Token name must be 64 characters or fewer")
@@ -380,11 +393,11 @@ func (h *Handler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
 	tokenID := models.NewULID().String()
 	var token models.BotToken
 	err = h.Pool.QueryRow(r.Context(),
-		`INSERT INTO bot_tokens (id, bot_id, token_hash, name, created_at)
-		 VALUES ($1, $2, $3, $4, now())
-		 RETURNING id, bot_id, name, created_at, last_used_at`,
-		tokenID, botID, hash, body.Name,
-	).Scan(&token.ID, &token.BotID, &token.Name, &token.CreatedAt, &token.LastUsedAt)
+		`INSERT INTO bot_tokens (id, bot_id, token_hash, name, scope, created_at)
+		 VALUES ($1, $2, $3, $4, $5, now())
+		 RETURNING id, bot_id, name, scope, created_at, last_used_at`,
+		tokenID, botID, hash, body.Name, body.Scope,
+	).Scan(&token.ID, &token.BotID, &token.Name, &token.Scope, &token.CreatedAt, &token.LastUsedAt)
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to create token", err)
 		return
@@ -412,7 +425,7 @@ func (h *Handler) HandleListTokens(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := h.Pool.Query(r.Context(),
-		`SELECT id, bot_id, name, created_at, last_used_at
+		`SELECT id, bot_id, name, scope, created_at, last_used_at
 		 FROM bot_tokens
 		 WHERE bot_id = $1
 		 ORDER BY created_at DESC`, botID)
@@ -425,7 +438,7 @@ func (h *Handler) HandleListTokens(w http.ResponseWriter, r *http.Request) {
 	tokens := []models.BotToken{}
 	for rows.Next() {
 		var t models.BotToken
-		if err := rows.Scan(&t.ID, &t.BotID, &t.Name, &t.CreatedAt, &t.LastUsedAt); err != nil {
+		if err := rows.Scan(&t.ID, &t.BotID, &t.Name, &t.Scope, &t.CreatedAt, &t.LastUsedAt); err != nil {
 			h.Logger.Error("failed to scan token", slog.String("error", err.Error()))
 			continue
 		}
@@ -482,6 +495,7 @@ func (h *Handler) HandleRegisterCommand(w http.ResponseWriter, r *http.Request)
 		Description string          `json:"description"`
 		GuildID     *string         `json:"guild_id"`
 		Options     json.RawMessage `json:"options"`
+		Context     string          `json:"context"`
 	}
 	if !apiutil.DecodeJSON(w, r, &body) {
 		return
@@ -508,19 +522,32 @@ func (h *Handler) HandleRegisterCommand(w http.ResponseWriter, r *http.Request)
 	if body.Options == nil {
 		body.Options = json.RawMessage("[]")
 	}
+	switch body.Context {
+	case "":
+		body.Context = models.CommandContextGuild
+	case models.CommandContextGuild:
+	case models.CommandContextDM:
+		if body.GuildID != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_context", "DM commands cannot be scoped to a guild")
+			return
+		}
+	default:
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_context", "context must be guild or dm")
+		return
+	}
 
 	commandID := models.NewULID().String()
 	now := time.Now()
 
 	var cmd models.SlashCommand
 	err := h.Pool.QueryRow(r.Context(),
-		`INSERT INTO slash_commands (id, bot_id, guild_id, name, description, options, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
-		 RETURNING id, bot_id, guild_id, name, description, options, created_at, updated_at`,
-		commandID, botID, body.GuildID, body.Name, body.Description, body.Options, now,
+		`INSERT INTO slash_commands (id, bot_id, guild_id, name, description, options, context, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		 RETURNING id, bot_id, guild_id, name, description, options, context, created_at, updated_at`,
+		commandID, botID, body.GuildID, body.Name, body.Description, body.Options, body.Context, now,
 	).Scan(
 		&cmd.ID, &cmd.BotID, &cmd.GuildID, &cmd.Name, &cmd.Description,
-		&cmd.Options, &cmd.CreatedAt, &cmd.UpdatedAt,
+		&cmd.Options, &cmd.Context, &cmd.CreatedAt, &cmd.UpdatedAt,
 	)
 	if err != nil {
 		if strings.Contains(err.Error(), "unique constraint") || strings.Contains(err.Error(), "duplicate key") {
@@ -562,13 +589,13 @@ func (h *Handler) HandleListCommands(w http.ResponseWriter, r *http.Request) {
 	var err error
 	if guildID != "" {
 		rows, err = h.Pool.Query(r.Context(),
-			`SELECT id, bot_id, guild_id, name, description, options, created_at, updated_at
+			`SELECT id, bot_id, guild_id, name, description, options, context, created_at, updated_at
 			 FROM slash_commands
 			 WHERE bot_id = $1 AND (guild_id = $2 OR guild_id IS NULL)
 			 ORDER BY name`, botID, guildID)
 	} else {
 		rows, err = h.Pool.Query(r.Context(),
-			`SELECT id, bot_id, guild_id, name, description, options, created_at, updated_at
+			`SELECT id, bot_id, guild_id, name, description, options, context, created_at, updated_at
 			 FROM slash_commands
 			 WHERE bot_id = $1
 			 ORDER BY name`, botID)
@@ -584,7 +611,7 @@ func (h *Handler) HandleListCommands(w http.ResponseWriter, r *http.Request) {
 		var cmd models.SlashCommand
 		if err := rows.Scan(
 			&cmd.ID, &cmd.BotID, &cmd.GuildID, &cmd.Name, &cmd.Description,
-			&cmd.Options, &cmd.CreatedAt, &cmd.UpdatedAt,
+			&cmd.Options, &cmd.Context, &cmd.CreatedAt, &cmd.UpdatedAt,
 		); err != nil {
 			h.Logger.Error("failed to scan command", slog.String("error", err.Error()))
 			continue
@@ -655,12 +682,12 @@ func (h *Handler) HandleUpdateCommand(w http.ResponseWriter, r *http.Request) {
 	args = append(args, commandID, botID)
 	query := "UPDATE slash_commands SET " + strings.Join(setClauses, ", ") +
 		" WHERE id = $" + itoa(argIdx) + " AND bot_id = $" + itoa(argIdx+1) +
-		" RETURNING id, bot_id, guild_id, name, description, options, created_at, updated_at"
+		" RETURNING id, bot_id, guild_id, name, description, options, context, created_at, updated_at"
 
 	var cmd models.SlashCommand
 	err := h.Pool.QueryRow(r.Context(), query, args...).Scan(
 		&cmd.ID, &cmd.BotID, &cmd.GuildID, &cmd.Name, &cmd.Description,
-		&cmd.Options, &cmd.CreatedAt, &cmd.UpdatedAt,
+		&cmd.Options, &cmd.Context, &cmd.CreatedAt, &cmd.UpdatedAt,
 	)
 	if err == pgx.ErrNoRows {
 		apiutil.WriteError(w, http.StatusNotFound, "command_not_found", "Command not found")
@@ -782,7 +809,7 @@ func (h *Handler) HandleUpdateBotGuildPermissions(w http.ResponseWriter, r *http
 	if err := h.Pool.QueryRow(r.Context(),
 		`SELECT EXISTS(SELECT 1 FROM guilds WHERE id = $1)`, guildID,
 	).Scan(&guildExists); err != nil || !guildExists {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 
@@ -872,6 +899,15 @@ func (h *Handler) HandleComponentInteraction(w http.ResponseWriter, r *http.Requ
 			"bot_id":         msgAuthorID,
 		})
 	}
+	h.deliverInteraction(msgAuthorID, interactionComponent, map[string]interface{}{
+		"component_id":   componentID,
+		"message_id":     messageID,
+		"channel_id":     channelID,
+		"user_id":        userID,
+		"custom_id":      comp.CustomID,
+		"component_type": comp.ComponentType,
+		"values":         body.Values,
+	})
 
 	h.Logger.Info("component interaction",
 		slog.String("component_id", componentID),
@@ -1058,7 +1094,7 @@ func (h *Handler) HandleCreateEventSubscription(w http.ResponseWriter, r *http.R
 	if err := h.Pool.QueryRow(r.Context(),
 		`SELECT EXISTS(SELECT 1 FROM guilds WHERE id = $1)`, body.GuildID,
 	).Scan(&guildExists); err != nil || !guildExists {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 
@@ -1253,6 +1289,25 @@ func (h *Handler) HandleUpdateBotRateLimit(w http.ResponseWriter, r *http.Reques
 
 // --- Admin Bot Management ---
 
+// botWithDetails is a bot account as listed for admins.
+type botWithDetails struct {
+	models.User
+	GuildPermissions   []models.BotGuildPermission
+	EventSubscriptions []models.BotEventSubscription
+	RateLimit          *models.BotRateLimit
+	Presence           *models.BotPresence
+}
+
+// MarshalJSON writes the bot user with its details added.
+func (b botWithDetails) MarshalJSON() ([]byte, error) {
+	return models.MergeJSON(b.User, struct {
+		GuildPermissions   []models.BotGuildPermission   `json:"guild_permissions"`
+		EventSubscriptions []models.BotEventSubscription `json:"event_subscriptions"`
+		RateLimit          *models.BotRateLimit          `json:"rate_limit,omitempty"`
+		Presence           *models.BotPresence           `json:"presence,omitempty"`
+	}{b.GuildPermissions, b.EventSubscriptions, b.RateLimit, b.Presence})
+}
+
 // HandleAdminListAllBots lists all bot accounts on the instance. Admin only.
 // GET /api/v1/admin/bots
 func (h *Handler) HandleAdminListAllBots(w http.ResponseWriter, r *http.Request) {
@@ -1267,15 +1322,7 @@ func (h *Handler) HandleAdminListAllBots(w http.ResponseWriter, r *http.Request)
 	}
 	defer rows.Close()
 
-	type BotWithDetails struct {
-		models.User
-		GuildPermissions   []models.BotGuildPermission    `json:"guild_permissions"`
-		EventSubscriptions []models.BotEventSubscription  `json:"event_subscriptions"`
-		RateLimit          *models.BotRateLimit           `json:"rate_limit,omitempty"`
-		Presence           *models.BotPresence            `json:"presence,omitempty"`
-	}
-
-	bots := []BotWithDetails{}
+	bots := []botWithDetails{}
 	botIDs := []string{}
 	for rows.Next() {
 		var bot models.User
@@ -1288,7 +1335,7 @@ func (h *Handler) HandleAdminListAllBots(w http.ResponseWriter, r *http.Request)
 			h.Logger.Error("failed to scan bot", slog.String("error", err.Error()))
 			continue
 		}
-		bots = append(bots, BotWithDetails{
+		bots = append(bots, botWithDetails{
 			User:               bot,
 			GuildPermissions:   []models.BotGuildPermission{},
 			EventSubscriptions: []models.BotEventSubscription{},
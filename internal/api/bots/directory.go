@@ -0,0 +1,344 @@
+package bots
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// --- Bot Directory ---
+//
+// A bot's owner publishes a listing describing the bot and the permissions it
+// needs. Asking for a public listing queues it for instance-admin review, and
+// any later edit queues it again, so the directory only shows what an admin
+// has seen. Guild admins install from the directory with
+// POST /api/v1/guilds/{guildID}/apps.
+
+var listingTagRegex = regexp.MustCompile(`^[a-z0-9-]{1,24}$`)
+
+type updateListingRequest struct {
+	ShortDescription string   `json:"short_description"`
+	Description      string   `json:"description"`
+	Permissions      int64    `json:"permissions"`
+	Scopes           []string `json:"scopes"`
+	Tags             []string `json:"tags"`
+	WebsiteURL       *string  `json:"website_url"`
+	SupportURL       *string  `json:"support_url"`
+	UserInstallable  bool     `json:"user_installable"`
+	Public           bool     `json:"public"`
+}
+
+// validateListing checks a listing update, normalizing it in place, and
+// returns an error code and message for the first problem found.
+func validateListing(req *updateListingRequest) (string, string) {
+	req.ShortDescription = strings.TrimSpace(req.ShortDescription)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.ShortDescription == "" || len(req.ShortDescription) > 120 {
+		return "invalid_short_description", "short_description must be 1-120 characters"
+	}
+	if len(req.Description) > 4000 {
+		return "invalid_description", "description must be at most 4000 characters"
+	}
+	if req.Permissions < 0 || uint64(req.Permissions)&^permissions.AllPermissions != 0 {
+		return "invalid_permissions", "permissions contains unknown permission bits"
+	}
+	if req.Scopes == nil {
+		req.Scopes = []string{}
+	}
+	for _, s := range req.Scopes {
+		if !models.ValidBotScopes[s] {
+			return "invalid_scope", "Invalid scope: " + s
+		}
+	}
+	if len(req.Tags) > 5 {
+		return "invalid_tags", "At most 5 tags are allowed"
+	}
+	tags := make([]string, 0, len(req.Tags))
+	for _, t := range req.Tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !listingTagRegex.MatchString(t) {
+			return "invalid_tags", "Tags must be 1-24 lowercase letters, digits or hyphens"
+		}
+		tags = append(tags, t)
+	}
+	req.Tags = tags
+	for _, link := range []*string{req.WebsiteURL, req.SupportURL} {
+		if link == nil || *link == "" {
+			continue
+		}
+		if u, err := url.Parse(*link); err != nil || u.Scheme != "https" || u.Host == "" || len(*link) > 512 {
+			return "invalid_url", "website_url and support_url must be https URLs"
+		}
+	}
+	return "", ""
+}
+
+// listingColumns is the SQL column list for scanning a models.BotListing
+// from bot_listings l joined with users u.
+const listingColumns = `l.bot_id, u.username, u.display_name, u.avatar_id, l.short_description,
+        l.description, l.permissions, l.scopes, l.tags, l.website_url, l.support_url,
+        l.review_status, l.review_note, l.reviewed_at, l.install_count, l.user_installable,
+        l.created_at, l.updated_at`
+
+// scanListing scans a models.BotListing and fills in its install link.
+func scanListing(row pgx.Row) (models.BotListing, error) {
+	var l models.BotListing
+	err := row.Scan(
+		&l.BotID, &l.Username, &l.DisplayName, &l.AvatarID, &l.ShortDescription,
+		&l.Description, &l.Permissions, &l.Scopes, &l.Tags, &l.WebsiteURL, &l.SupportURL,
+		&l.ReviewStatus, &l.ReviewNote, &l.ReviewedAt, &l.InstallCount, &l.UserInstallable,
+		&l.CreatedAt, &l.UpdatedAt,
+	)
+	l.InstallURL = fmt.Sprintf("/install/%s?permissions=%d", l.BotID, l.Permissions)
+	return l, err
+}
+
+// HandleBrowseDirectory lists approved listings, most installed first,
+// optionally filtered by a search term and a tag.
+// GET /api/v1/bots/directory?q=&tag=&limit=&offset=
+func (h *Handler) HandleBrowseDirectory(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	tag := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag")))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+listingColumns+`
+		 FROM bot_listings l JOIN users u ON u.id = l.bot_id
+		 WHERE l.review_status = 'approved'
+		   AND ($1 = '' OR u.username ILIKE '%' || $1 || '%' OR l.short_description ILIKE '%' || $1 || '%')
+		   AND ($2 = '' OR $2 = ANY(l.tags))
+		 ORDER BY l.install_count DESC, l.bot_id
+		 LIMIT $3 OFFSET $4`,
+		q, tag, limit, offset)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to browse directory", err)
+		return
+	}
+	defer rows.Close()
+
+	listings := []models.BotListing{}
+	for rows.Next() {
+		l, err := scanListing(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read listings", err)
+			return
+		}
+		listings = append(listings, l)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, listings)
+}
+
+// HandleGetListing returns a bot's listing. Listings that are not approved
+// are only visible to the bot's owner.
+// GET /api/v1/bots/{botID}/listing
+func (h *Handler) HandleGetListing(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	var ownerID *string
+	l, err := scanListing(h.Pool.QueryRow(r.Context(),
+		`SELECT `+listingColumns+`
+		 FROM bot_listings l JOIN users u ON u.id = l.bot_id
+		 WHERE l.bot_id = $1`, botID))
+	if err == nil && l.ReviewStatus != models.BotListingApproved {
+		h.Pool.QueryRow(r.Context(), `SELECT bot_owner_id FROM users WHERE id = $1`, botID).Scan(&ownerID)
+		if ownerID == nil || *ownerID != userID {
+			err = pgx.ErrNoRows
+		}
+	}
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "listing_not_found", "This bot has no directory listing")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get listing", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, l)
+}
+
+// HandleUpdateListing creates or replaces a bot's listing. Only the bot owner
+// can update. A public listing goes back to pending review on every change.
+// PUT /api/v1/bots/{botID}/listing
+func (h *Handler) HandleUpdateListing(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+
+	var req updateListingRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if code, msg := validateListing(&req); code != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, code, msg)
+		return
+	}
+
+	status := models.BotListingUnlisted
+	if req.Public {
+		status = models.BotListingPending
+	}
+
+	_, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO bot_listings (bot_id, short_description, description, permissions, scopes, tags,
+		                           website_url, support_url, review_status, user_installable,
+		                           created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10, now(), now())
+		 ON CONFLICT (bot_id) DO UPDATE SET
+		     short_description = EXCLUDED.short_description,
+		     description = EXCLUDED.description,
+		     permissions = EXCLUDED.permissions,
+		     scopes = EXCLUDED.scopes,
+		     tags = EXCLUDED.tags,
+		     website_url = EXCLUDED.website_url,
+		     support_url = EXCLUDED.support_url,
+		     review_status = EXCLUDED.review_status,
+		     user_installable = EXCLUDED.user_installable,
+		     review_note = NULL,
+		     reviewed_by = NULL,
+		     reviewed_at = NULL,
+		     updated_at = now()`,
+		botID, req.ShortDescription, req.Description, req.Permissions, req.Scopes, req.Tags,
+		req.WebsiteURL, req.SupportURL, status, req.UserInstallable)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update listing", err)
+		return
+	}
+
+	l, err := scanListing(h.Pool.QueryRow(r.Context(),
+		`SELECT `+listingColumns+`
+		 FROM bot_listings l JOIN users u ON u.id = l.bot_id
+		 WHERE l.bot_id = $1`, botID))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get listing", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, l)
+}
+
+// HandleDeleteListing removes a bot's listing from the directory. Guilds that
+// already installed the bot keep it.
+// DELETE /api/v1/bots/{botID}/listing
+func (h *Handler) HandleDeleteListing(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(), `DELETE FROM bot_listings WHERE bot_id = $1`, botID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete listing", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "listing_not_found", "This bot has no directory listing")
+		return
+	}
+
+	apiutil.WriteNoContent(w)
+}
+
+// HandleAdminListListings lists directory listings by review status, oldest
+// change first, defaulting to those awaiting review. Admin only.
+// GET /api/v1/admin/bot-listings?status=pending
+func (h *Handler) HandleAdminListListings(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.BotListingPending
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+listingColumns+`
+		 FROM bot_listings l JOIN users u ON u.id = l.bot_id
+		 WHERE l.review_status = $1
+		 ORDER BY l.updated_at
+		 LIMIT 200`, status)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list listings", err)
+		return
+	}
+	defer rows.Close()
+
+	listings := []models.BotListing{}
+	for rows.Next() {
+		l, err := scanListing(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read listings", err)
+			return
+		}
+		listings = append(listings, l)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, listings)
+}
+
+// HandleAdminReviewListing approves or rejects a pending listing. Admin only.
+// POST /api/v1/admin/bot-listings/{botID}/review
+func (h *Handler) HandleAdminReviewListing(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	var body struct {
+		Approve bool    `json:"approve"`
+		Note    *string `json:"note"`
+	}
+	if !apiutil.DecodeJSON(w, r, &body) {
+		return
+	}
+
+	status := models.BotListingRejected
+	if body.Approve {
+		status = models.BotListingApproved
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE bot_listings
+		 SET review_status = $1, review_note = $2, reviewed_by = $3, reviewed_at = now()
+		 WHERE bot_id = $4 AND review_status = 'pending'`,
+		status, body.Note, userID, botID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to review listing", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "listing_not_found", "No listing awaiting review for this bot")
+		return
+	}
+
+	l, err := scanListing(h.Pool.QueryRow(r.Context(),
+		`SELECT `+listingColumns+`
+		 FROM bot_listings l JOIN users u ON u.id = l.bot_id
+		 WHERE l.bot_id = $1`, botID))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get listing", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, l)
+}
@@ -0,0 +1,38 @@
+package bots
+
+import (
+	"testing"
+
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+func TestValidateListing(t *testing.T) {
+	site := "https://example.com/bot"
+	insecure := "http://example.com"
+	tests := []struct {
+		name string
+		req  updateListingRequest
+		want string
+	}{
+		{"minimal", updateListingRequest{ShortDescription: "Does things"}, ""},
+		{"full", updateListingRequest{ShortDescription: "Does things", Permissions: int64(permissions.ManageMessages),
+			Scopes: []string{"messages.read"}, Tags: []string{" Moderation "}, WebsiteURL: &site}, ""},
+		{"missing short description", updateListingRequest{ShortDescription: "  "}, "invalid_short_description"},
+		{"unknown permission bit", updateListingRequest{ShortDescription: "x", Permissions: -1}, "invalid_permissions"},
+		{"bad scope", updateListingRequest{ShortDescription: "x", Scopes: []string{"root"}}, "invalid_scope"},
+		{"bad tag", updateListingRequest{ShortDescription: "x", Tags: []string{"no spaces"}}, "invalid_tags"},
+		{"too many tags", updateListingRequest{ShortDescription: "x", Tags: []string{"a", "b", "c", "d", "e", "f"}}, "invalid_tags"},
+		{"http link", updateListingRequest{ShortDescription: "x", SupportURL: &insecure}, "invalid_url"},
+	}
+	for _, tt := range tests {
+		if code, _ := validateListing(&tt.req); code != tt.want {
+			t.Errorf("%s: code = %q, want %q", tt.name, code, tt.want)
+		}
+	}
+
+	req := updateListingRequest{ShortDescription: "x", Tags: []string{" Fun "}}
+	validateListing(&req)
+	if len(req.Tags) != 1 || req.Tags[0] != "fun" || req.Scopes == nil {
+		t.Errorf("normalized request = %+v", req)
+	}
+}
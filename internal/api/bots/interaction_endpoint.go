@@ -0,0 +1,207 @@
+package bots
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/callback"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- Interaction Endpoints ---
+//
+// An app can take its interactions over HTTP: each one is POSTed to the
+// app's endpoint, signed with the instance key (see package callback), in
+// addition to the gateway event. Before a URL is saved it must answer a
+// signed PING with 2xx and refuse one with a bad signature, so an endpoint
+// that skips verification is never trusted with real interactions.
+
+// Interaction payload types, named after the matching gateway events.
+const (
+	interactionPing      = "PING"
+	interactionCommand   = "INTERACTION_CREATE"
+	interactionComponent = "COMPONENT_INTERACTION"
+)
+
+// interactionDeliveryTimeout bounds one interaction POST.
+const interactionDeliveryTimeout = 10 * time.Second
+
+// interactionPayload is the body POSTed to an interaction endpoint.
+type interactionPayload struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// checkInteractionEndpoint pings rawURL twice, once correctly signed and
+// once signed with a throwaway key, and returns an error message when the
+// endpoint does not accept the first and refuse the second.
+func (h *Handler) checkInteractionEndpoint(ctx context.Context, rawURL string) string {
+	body, _ := json.Marshal(interactionPayload{Type: interactionPing})
+
+	resp, err := callback.Post(ctx, h.SigningKey, rawURL, body, nil)
+	if err != nil {
+		return "Endpoint could not be reached: " + err.Error()
+	}
+	if !resp.OK() {
+		return "Endpoint answered the signed PING with HTTP " + itoa(resp.StatusCode)
+	}
+
+	_, forged, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return "Failed to generate a test key"
+	}
+	resp, err = callback.Post(ctx, forged, rawURL, body, nil)
+	if err != nil {
+		return "Endpoint could not be reached: " + err.Error()
+	}
+	if resp.OK() {
+		return "Endpoint accepted a PING with an invalid signature; it must verify " + callback.SignatureHeader
+	}
+	return ""
+}
+
+// deliverInteraction POSTs an interaction to the bot's endpoint, if it has
+// one. It runs in the background; failures are logged, and the app still
+// gets the gateway event.
+func (h *Handler) deliverInteraction(botID, eventType string, data interface{}) {
+	if len(h.SigningKey) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), interactionDeliveryTimeout)
+		defer cancel()
+
+		var endpoint string
+		err := h.Pool.QueryRow(ctx,
+			`SELECT url FROM bot_interaction_endpoints WHERE bot_id = $1`, botID,
+		).Scan(&endpoint)
+		if err == pgx.ErrNoRows {
+			return
+		}
+		if err != nil {
+			h.Logger.Warn("failed to look up interaction endpoint",
+				slog.String("bot_id", botID), slog.String("error", err.Error()))
+			return
+		}
+
+		body, _ := json.Marshal(interactionPayload{Type: eventType, Data: data})
+		resp, err := callback.Post(ctx, h.SigningKey, endpoint, body, nil)
+		if err == nil && !resp.OK() {
+			err = fmt.Errorf("endpoint answered HTTP %d", resp.StatusCode)
+		}
+		if err != nil {
+			h.Logger.Warn("interaction delivery failed",
+				slog.String("bot_id", botID), slog.String("type", eventType), slog.String("error", err.Error()))
+		}
+	}()
+}
+
+// HandleGetInteractionEndpoint returns the bot's interaction endpoint.
+// GET /api/v1/bots/{botID}/interactions-endpoint
+func (h *Handler) HandleGetInteractionEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+
+	ep := models.BotInteractionEndpoint{BotID: botID}
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT url, verified_at FROM bot_interaction_endpoints WHERE bot_id = $1`, botID,
+	).Scan(&ep.URL, &ep.VerifiedAt)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "no_endpoint", "This bot has no interaction endpoint")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get interaction endpoint", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, ep)
+}
+
+// HandleSetInteractionEndpoint checks and saves the bot's interaction
+// endpoint. Only the bot owner can set it.
+// PUT /api/v1/bots/{botID}/interactions-endpoint
+func (h *Handler) HandleSetInteractionEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+	if len(h.SigningKey) == 0 {
+		apiutil.WriteError(w, http.StatusServiceUnavailable, "signing_unavailable", "This instance cannot sign interaction requests")
+		return
+	}
+
+	var body struct {
+		URL string `json:"url"`
+	}
+	if !apiutil.DecodeJSON(w, r, &body) {
+		return
+	}
+	body.URL = strings.TrimSpace(body.URL)
+	if u, err := url.Parse(body.URL); err != nil || u.Scheme != "https" || u.Host == "" || len(body.URL) > 2048 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_url", "url must be an https URL")
+		return
+	}
+
+	if msg := h.checkInteractionEndpoint(r.Context(), body.URL); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "endpoint_check_failed", msg)
+		return
+	}
+
+	ep := models.BotInteractionEndpoint{BotID: botID}
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO bot_interaction_endpoints (bot_id, url, verified_at)
+		 VALUES ($1, $2, now())
+		 ON CONFLICT (bot_id) DO UPDATE SET url = EXCLUDED.url, verified_at = now()
+		 RETURNING url, verified_at`,
+		botID, body.URL,
+	).Scan(&ep.URL, &ep.VerifiedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to save interaction endpoint", err)
+		return
+	}
+
+	h.Logger.Info("bot interaction endpoint set", slog.String("bot_id", botID))
+	apiutil.WriteJSON(w, http.StatusOK, ep)
+}
+
+// HandleDeleteInteractionEndpoint stops HTTP delivery of interactions; the
+// bot keeps receiving them over the gateway.
+// DELETE /api/v1/bots/{botID}/interactions-endpoint
+func (h *Handler) HandleDeleteInteractionEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM bot_interaction_endpoints WHERE bot_id = $1`, botID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete interaction endpoint", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "no_endpoint", "This bot has no interaction endpoint")
+		return
+	}
+	apiutil.WriteNoContent(w)
+}
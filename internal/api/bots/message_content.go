@@ -0,0 +1,228 @@
+package bots
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- Message Content Access ---
+//
+// Message content is a privileged capability. Without it the gateway and the
+// message history endpoints give a bot message metadata only, except for
+// messages it sent, messages that mention it, and DMs. A bot's owner asks for
+// the capability with a reason; an instance admin approves, denies or later
+// revokes it.
+
+// maxContentReason is the longest reason an owner may give.
+const maxContentReason = 1000
+
+// getMessageContentAccess loads a bot's message content request. Bots that
+// never asked are reported with status "none".
+func (h *Handler) getMessageContentAccess(r *http.Request, botID string) (models.BotMessageContentAccess, error) {
+	a := models.BotMessageContentAccess{BotID: botID, Status: models.MessageContentNone}
+	var status, reason *string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT u.username, a.status, a.reason, a.review_note, a.reviewed_at, a.requested_at
+		 FROM users u LEFT JOIN bot_message_content_access a ON a.bot_id = u.id
+		 WHERE u.id = $1`, botID,
+	).Scan(&a.Username, &status, &reason, &a.ReviewNote, &a.ReviewedAt, &a.RequestedAt)
+	if status != nil {
+		a.Status = *status
+	}
+	if reason != nil {
+		a.Reason = *reason
+	}
+	return a, err
+}
+
+// publishCapabilities tells the bot's gateway sessions whether they may now
+// receive message content.
+func (h *Handler) publishCapabilities(r *http.Request, botID string, messageContent bool) {
+	h.EventBus.PublishUserEvent(r.Context(), events.SubjectBotCapabilities, "BOT_CAPABILITIES_UPDATE", botID,
+		map[string]interface{}{"bot_id": botID, "message_content": messageContent})
+}
+
+// HandleGetMessageContentAccess returns the bot's message content status.
+// GET /api/v1/bots/{botID}/message-content
+func (h *Handler) HandleGetMessageContentAccess(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+
+	a, err := h.getMessageContentAccess(r, botID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get message content access", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, a)
+}
+
+// HandleRequestMessageContentAccess asks for the message content capability,
+// or asks again after a denial. The request waits for instance-admin review.
+// PUT /api/v1/bots/{botID}/message-content
+func (h *Handler) HandleRequestMessageContentAccess(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if !apiutil.DecodeJSON(w, r, &body) {
+		return
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if !apiutil.RequireNonEmpty(w, "reason", body.Reason) {
+		return
+	}
+	if utf8.RuneCountInString(body.Reason) > maxContentReason {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_reason", "reason must be at most 1000 characters")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO bot_message_content_access (bot_id, status, reason, requested_at)
+		 VALUES ($1, 'pending', $2, now())
+		 ON CONFLICT (bot_id) DO UPDATE SET
+		     status = 'pending', reason = EXCLUDED.reason, review_note = NULL,
+		     reviewed_by = NULL, reviewed_at = NULL, requested_at = now()
+		 WHERE bot_message_content_access.status <> 'approved'`,
+		botID, body.Reason)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to request message content access", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusConflict, "already_approved", "This bot already has message content access")
+		return
+	}
+
+	a, err := h.getMessageContentAccess(r, botID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get message content access", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, a)
+}
+
+// HandleDeleteMessageContentAccess withdraws the request, or gives up the
+// capability if it was approved.
+// DELETE /api/v1/bots/{botID}/message-content
+func (h *Handler) HandleDeleteMessageContentAccess(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+
+	var status string
+	err := h.Pool.QueryRow(r.Context(),
+		`DELETE FROM bot_message_content_access WHERE bot_id = $1 RETURNING status`, botID).Scan(&status)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "not_requested", "This bot has not asked for message content access")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to remove message content access", err)
+		return
+	}
+	if status == models.MessageContentApproved {
+		h.publishCapabilities(r, botID, false)
+	}
+
+	apiutil.WriteNoContent(w)
+}
+
+// HandleAdminListMessageContentRequests lists message content requests by
+// status, oldest first, defaulting to those awaiting review. Admin only.
+// GET /api/v1/admin/bot-message-content?status=pending
+func (h *Handler) HandleAdminListMessageContentRequests(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.MessageContentPending
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT a.bot_id, u.username, a.status, a.reason, a.review_note, a.reviewed_at, a.requested_at
+		 FROM bot_message_content_access a JOIN users u ON u.id = a.bot_id
+		 WHERE a.status = $1
+		 ORDER BY a.requested_at
+		 LIMIT 200`, status)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list message content requests", err)
+		return
+	}
+	defer rows.Close()
+
+	requests := []models.BotMessageContentAccess{}
+	for rows.Next() {
+		var a models.BotMessageContentAccess
+		if err := rows.Scan(&a.BotID, &a.Username, &a.Status, &a.Reason, &a.ReviewNote,
+			&a.ReviewedAt, &a.RequestedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read message content requests", err)
+			return
+		}
+		requests = append(requests, a)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, requests)
+}
+
+// HandleAdminReviewMessageContent approves or denies a pending request.
+// Denying an approved bot revokes the capability. Admin only.
+// POST /api/v1/admin/bot-message-content/{botID}/review
+func (h *Handler) HandleAdminReviewMessageContent(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	var body struct {
+		Approve bool    `json:"approve"`
+		Note    *string `json:"note"`
+	}
+	if !apiutil.DecodeJSON(w, r, &body) {
+		return
+	}
+
+	status, from := models.MessageContentDenied, []string{models.MessageContentPending, models.MessageContentApproved}
+	if body.Approve {
+		status, from = models.MessageContentApproved, []string{models.MessageContentPending}
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE bot_message_content_access
+		 SET status = $1, review_note = $2, reviewed_by = $3, reviewed_at = now()
+		 WHERE bot_id = $4 AND status = ANY($5)`,
+		status, body.Note, userID, botID, from)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to review message content request", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "request_not_found", "No message content request to review for this bot")
+		return
+	}
+	h.publishCapabilities(r, botID, body.Approve)
+
+	a, err := h.getMessageContentAccess(r, botID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get message content access", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, a)
+}
@@ -0,0 +1,239 @@
+package bots
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- Presence Subscriptions ---
+//
+// A bot receives presence for the members of its guilds. For anyone else,
+// e.g. on a status board, its owner names the users instead of the bot
+// getting every presence on the instance. Each named user is asked, and
+// their presence reaches the bot's gateway sessions only once they grant it.
+
+// maxPresenceSubscriptions is how many users one bot may name.
+const maxPresenceSubscriptions = 100
+
+// publishPresenceTargets tells the bot's gateway sessions which users now
+// let it see their presence.
+func (h *Handler) publishPresenceTargets(r *http.Request, botID string) {
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT user_id FROM bot_presence_subscriptions WHERE bot_id = $1 AND status = 'granted'`, botID)
+	if err != nil {
+		return
+	}
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return
+	}
+	h.EventBus.PublishUserEvent(r.Context(), events.SubjectBotCapabilities, "BOT_PRESENCE_TARGETS_UPDATE", botID,
+		map[string]interface{}{"bot_id": botID, "user_ids": userIDs})
+}
+
+// getPresenceSubscription loads one subscription with both users.
+func (h *Handler) getPresenceSubscription(r *http.Request, botID, userID string) (models.BotPresenceSubscription, error) {
+	s := models.BotPresenceSubscription{Bot: &models.User{}, User: &models.User{}}
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT s.bot_id, s.user_id, s.status, s.requested_at, s.granted_at,
+		        b.id, b.instance_id, b.username, b.display_name, b.avatar_id,
+		        u.id, u.instance_id, u.username, u.display_name, u.avatar_id
+		 FROM bot_presence_subscriptions s
+		 JOIN users b ON b.id = s.bot_id
+		 JOIN users u ON u.id = s.user_id
+		 WHERE s.bot_id = $1 AND s.user_id = $2`, botID, userID,
+	).Scan(&s.BotID, &s.UserID, &s.Status, &s.RequestedAt, &s.GrantedAt,
+		&s.Bot.ID, &s.Bot.InstanceID, &s.Bot.Username, &s.Bot.DisplayName, &s.Bot.AvatarID,
+		&s.User.ID, &s.User.InstanceID, &s.User.Username, &s.User.DisplayName, &s.User.AvatarID)
+	return s, err
+}
+
+// listPresenceSubscriptions writes the subscriptions matching column = id,
+// newest first.
+func (h *Handler) listPresenceSubscriptions(w http.ResponseWriter, r *http.Request, column, id string) {
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT s.bot_id, s.user_id, s.status, s.requested_at, s.granted_at,
+		        b.id, b.instance_id, b.username, b.display_name, b.avatar_id,
+		        u.id, u.instance_id, u.username, u.display_name, u.avatar_id
+		 FROM bot_presence_subscriptions s
+		 JOIN users b ON b.id = s.bot_id
+		 JOIN users u ON u.id = s.user_id
+		 WHERE s.`+column+` = $1
+		 ORDER BY s.requested_at DESC`, id)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list presence subscriptions", err)
+		return
+	}
+	defer rows.Close()
+
+	subs := []models.BotPresenceSubscription{}
+	for rows.Next() {
+		s := models.BotPresenceSubscription{Bot: &models.User{}, User: &models.User{}}
+		if err := rows.Scan(&s.BotID, &s.UserID, &s.Status, &s.RequestedAt, &s.GrantedAt,
+			&s.Bot.ID, &s.Bot.InstanceID, &s.Bot.Username, &s.Bot.DisplayName, &s.Bot.AvatarID,
+			&s.User.ID, &s.User.InstanceID, &s.User.Username, &s.User.DisplayName, &s.User.AvatarID); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read presence subscriptions", err)
+			return
+		}
+		subs = append(subs, s)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, subs)
+}
+
+// HandleListPresenceSubscriptions lists the users the bot asked to receive
+// presence for, granted or not.
+// GET /api/v1/bots/{botID}/presence-subscriptions
+func (h *Handler) HandleListPresenceSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+	h.listPresenceSubscriptions(w, r, "bot_id", botID)
+}
+
+// HandleAddPresenceSubscription asks a user to let the bot receive their
+// presence. Asking again leaves the existing subscription as it is.
+// PUT /api/v1/bots/{botID}/presence-subscriptions/{userID}
+func (h *Handler) HandleAddPresenceSubscription(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+	targetID := chi.URLParam(r, "userID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+	if targetID == botID {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_target", "A bot cannot subscribe to its own presence")
+		return
+	}
+
+	var exists bool
+	h.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND flags & $2 = 0)`,
+		targetID, models.UserFlagBot).Scan(&exists)
+	if !exists {
+		apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+
+	var count int
+	h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM bot_presence_subscriptions WHERE bot_id = $1`, botID).Scan(&count)
+	if count >= maxPresenceSubscriptions {
+		apiutil.WriteError(w, http.StatusBadRequest, "limit_reached", "A bot can subscribe to at most 100 users' presence")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO bot_presence_subscriptions (bot_id, user_id, status, requested_at)
+		 VALUES ($1, $2, 'pending', now())
+		 ON CONFLICT (bot_id, user_id) DO NOTHING`,
+		botID, targetID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to add presence subscription", err)
+		return
+	}
+
+	sub, err := h.getPresenceSubscription(r, botID, targetID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get presence subscription", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteJSON(w, http.StatusOK, sub)
+		return
+	}
+
+	h.EventBus.PublishUserEvent(r.Context(), events.SubjectBotPresenceRequest, "BOT_PRESENCE_REQUEST_CREATE", targetID, sub)
+	apiutil.WriteJSON(w, http.StatusCreated, sub)
+}
+
+// HandleRemovePresenceSubscription stops the bot receiving a user's
+// presence, or withdraws the request.
+// DELETE /api/v1/bots/{botID}/presence-subscriptions/{userID}
+func (h *Handler) HandleRemovePresenceSubscription(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	if !h.verifyBotOwnership(w, r, botID, userID) {
+		return
+	}
+	h.removePresenceSubscription(w, r, botID, chi.URLParam(r, "userID"))
+}
+
+// removePresenceSubscription deletes a subscription and, if it was granted,
+// tells the bot's gateway sessions.
+func (h *Handler) removePresenceSubscription(w http.ResponseWriter, r *http.Request, botID, userID string) {
+	var status string
+	err := h.Pool.QueryRow(r.Context(),
+		`DELETE FROM bot_presence_subscriptions WHERE bot_id = $1 AND user_id = $2 RETURNING status`,
+		botID, userID).Scan(&status)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "subscription_not_found", "Presence subscription not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to remove presence subscription", err)
+		return
+	}
+	if status == models.BotPresenceGranted {
+		h.publishPresenceTargets(r, botID)
+	}
+
+	apiutil.WriteNoContent(w)
+}
+
+// HandleListBotPresenceRequests lists the bots that asked for, or were
+// granted, the caller's presence.
+// GET /api/v1/users/@me/bot-presence
+func (h *Handler) HandleListBotPresenceRequests(w http.ResponseWriter, r *http.Request) {
+	h.listPresenceSubscriptions(w, r, "user_id", auth.UserIDFromContext(r.Context()))
+}
+
+// HandleGrantBotPresence lets a bot that asked receive the caller's presence.
+// PUT /api/v1/users/@me/bot-presence/{botID}
+func (h *Handler) HandleGrantBotPresence(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE bot_presence_subscriptions SET status = 'granted', granted_at = now()
+		 WHERE bot_id = $1 AND user_id = $2 AND status = 'pending'`,
+		botID, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to grant presence", err)
+		return
+	}
+
+	sub, err := h.getPresenceSubscription(r, botID, userID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "subscription_not_found", "This bot has not asked for your presence")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get presence subscription", err)
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		h.publishPresenceTargets(r, botID)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, sub)
+}
+
+// HandleRevokeBotPresence declines a bot's request for the caller's
+// presence, or withdraws a grant.
+// DELETE /api/v1/users/@me/bot-presence/{botID}
+func (h *Handler) HandleRevokeBotPresence(w http.ResponseWriter, r *http.Request) {
+	h.removePresenceSubscription(w, r, chi.URLParam(r, "botID"), auth.UserIDFromContext(r.Context()))
+}
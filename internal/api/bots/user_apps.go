@@ -0,0 +1,378 @@
+package bots
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- User Apps and Interactions ---
+//
+// An app can be installed to a user's account instead of a guild. Its "dm"
+// commands can then be run by that user in their DMs and group DMs, without
+// the app joining those channels. Running a command, in either context,
+// creates an interaction that the app receives as INTERACTION_CREATE and
+// answers once through the callback endpoint, usually with a user_app token.
+
+// interactionTTL is how long an app has to answer an interaction.
+const interactionTTL = 15 * time.Minute
+
+// commandScope is where a channel's commands come from: the guild's member
+// bots for guild channels, the caller's installed apps for DMs.
+type commandScope struct {
+	GuildID *string
+	Context string
+}
+
+// resolveCommandScope checks that userID can run commands in the channel and
+// returns the scope those commands come from. ok is false when the channel
+// does not exist or the user cannot see it.
+func (h *Handler) resolveCommandScope(ctx context.Context, channelID, userID string) (scope commandScope, ok bool, err error) {
+	var channelType string
+	var guildID *string
+	err = h.Pool.QueryRow(ctx,
+		`SELECT channel_type, guild_id FROM channels WHERE id = $1`, channelID,
+	).Scan(&channelType, &guildID)
+	if err == pgx.ErrNoRows {
+		return scope, false, nil
+	}
+	if err != nil {
+		return scope, false, err
+	}
+
+	var allowed bool
+	switch {
+	case channelType == models.ChannelTypeDM || channelType == models.ChannelTypeGroup:
+		scope.Context = models.CommandContextDM
+		err = h.Pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2)`,
+			channelID, userID).Scan(&allowed)
+	case guildID != nil:
+		scope.Context, scope.GuildID = models.CommandContextGuild, guildID
+		err = h.Pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
+			*guildID, userID).Scan(&allowed)
+	}
+	return scope, allowed, err
+}
+
+// availableCommandsSQL selects the commands userID ($1) can run in a scope:
+// dm commands of apps they installed, or guild commands of bots in guild $2.
+// Callers append further conditions on c.
+const availableCommandsSQL = `SELECT c.id, c.bot_id, c.guild_id, c.name, c.description, c.options, c.context, c.created_at, c.updated_at
+	 FROM slash_commands c
+	 WHERE CASE WHEN $2::text IS NULL
+	     THEN c.context = 'dm' AND EXISTS(SELECT 1 FROM user_app_installs i WHERE i.user_id = $1 AND i.bot_id = c.bot_id)
+	     ELSE c.context = 'guild' AND (c.guild_id IS NULL OR c.guild_id = $2)
+	          AND EXISTS(SELECT 1 FROM guild_members gm WHERE gm.guild_id = $2 AND gm.user_id = c.bot_id)
+	 END`
+
+func scanSlashCommand(row pgx.Row) (models.SlashCommand, error) {
+	var cmd models.SlashCommand
+	err := row.Scan(&cmd.ID, &cmd.BotID, &cmd.GuildID, &cmd.Name, &cmd.Description,
+		&cmd.Options, &cmd.Context, &cmd.CreatedAt, &cmd.UpdatedAt)
+	return cmd, err
+}
+
+// HandleListUserApps lists the apps installed to the current user.
+// GET /api/v1/users/@me/apps
+func (h *Handler) HandleListUserApps(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT i.bot_id, u.username, u.display_name, u.avatar_id, i.installed_at
+		 FROM user_app_installs i JOIN users u ON u.id = i.bot_id
+		 WHERE i.user_id = $1
+		 ORDER BY i.installed_at`, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list apps", err)
+		return
+	}
+	defer rows.Close()
+
+	apps := []models.UserAppInstall{}
+	for rows.Next() {
+		var a models.UserAppInstall
+		if err := rows.Scan(&a.BotID, &a.Username, &a.DisplayName, &a.AvatarID, &a.InstalledAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read apps", err)
+			return
+		}
+		apps = append(apps, a)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, apps)
+}
+
+// HandleInstallUserApp installs an app to the current user. The app's
+// listing must be approved and user-installable, unless the user owns it.
+// PUT /api/v1/users/@me/apps/{botID}
+func (h *Handler) HandleInstallUserApp(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	var a models.UserAppInstall
+	var ownerID *string
+	var installable bool
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT u.id, u.username, u.display_name, u.avatar_id, u.bot_owner_id,
+		        COALESCE(l.user_installable AND l.review_status = 'approved', false)
+		 FROM users u LEFT JOIN bot_listings l ON l.bot_id = u.id
+		 WHERE u.id = $1 AND u.flags & $2 != 0`,
+		botID, models.UserFlagBot,
+	).Scan(&a.BotID, &a.Username, &a.DisplayName, &a.AvatarID, &ownerID, &installable)
+	if err == nil && !installable && (ownerID == nil || *ownerID != userID) {
+		err = pgx.ErrNoRows
+	}
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "app_not_found", "No user-installable app with that ID")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up app", err)
+		return
+	}
+
+	err = h.Pool.QueryRow(r.Context(),
+		`INSERT INTO user_app_installs (user_id, bot_id, installed_at) VALUES ($1, $2, now())
+		 ON CONFLICT (user_id, bot_id) DO UPDATE SET installed_at = user_app_installs.installed_at
+		 RETURNING installed_at`,
+		userID, botID).Scan(&a.InstalledAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to install app", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, a)
+}
+
+// HandleUninstallUserApp removes an app from the current user.
+// DELETE /api/v1/users/@me/apps/{botID}
+func (h *Handler) HandleUninstallUserApp(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM user_app_installs WHERE user_id = $1 AND bot_id = $2`, userID, botID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to uninstall app", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "app_not_installed", "This app is not installed")
+		return
+	}
+
+	apiutil.WriteNoContent(w)
+}
+
+// HandleListChannelCommands lists the commands the current user can run in
+// a channel.
+// GET /api/v1/channels/{channelID}/commands
+func (h *Handler) HandleListChannelCommands(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	scope, ok, err := h.resolveCommandScope(r.Context(), channelID, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up channel", err)
+		return
+	}
+	if !ok {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(), availableCommandsSQL+` ORDER BY c.name, c.bot_id`, userID, scope.GuildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list commands", err)
+		return
+	}
+	defer rows.Close()
+
+	commands := []models.SlashCommand{}
+	for rows.Next() {
+		cmd, err := scanSlashCommand(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read commands", err)
+			return
+		}
+		commands = append(commands, cmd)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, commands)
+}
+
+// HandleCreateInteraction runs a command in a channel. The command's app
+// receives an INTERACTION_CREATE event and has interactionTTL to answer.
+// POST /api/v1/channels/{channelID}/interactions
+func (h *Handler) HandleCreateInteraction(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	var body struct {
+		CommandID string          `json:"command_id"`
+		Options   json.RawMessage `json:"options"`
+	}
+	if !apiutil.DecodeJSON(w, r, &body) {
+		return
+	}
+	if !apiutil.RequireNonEmpty(w, "command_id", body.CommandID) {
+		return
+	}
+	if len(body.Options) == 0 || string(body.Options) == "null" {
+		body.Options = json.RawMessage("{}")
+	}
+
+	scope, ok, err := h.resolveCommandScope(r.Context(), channelID, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up channel", err)
+		return
+	}
+	if !ok {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+		return
+	}
+
+	cmd, err := scanSlashCommand(h.Pool.QueryRow(r.Context(),
+		availableCommandsSQL+` AND c.id = $3`, userID, scope.GuildID, body.CommandID))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "command_not_found", "That command is not available in this channel")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up command", err)
+		return
+	}
+
+	in := models.Interaction{
+		ID:          models.NewULID().String(),
+		BotID:       cmd.BotID,
+		UserID:      userID,
+		ChannelID:   channelID,
+		GuildID:     scope.GuildID,
+		CommandID:   cmd.ID,
+		CommandName: cmd.Name,
+		Context:     scope.Context,
+		Options:     body.Options,
+	}
+	err = h.Pool.QueryRow(r.Context(),
+		`INSERT INTO interactions (id, bot_id, user_id, channel_id, guild_id, command_id, context, options, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+		 RETURNING created_at`,
+		in.ID, in.BotID, in.UserID, in.ChannelID, in.GuildID, in.CommandID, in.Context, in.Options,
+	).Scan(&in.CreatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create interaction", err)
+		return
+	}
+
+	h.EventBus.PublishUserEvent(r.Context(), events.SubjectInteractionCreate, "INTERACTION_CREATE", in.BotID, in)
+	h.deliverInteraction(in.BotID, interactionCommand, in)
+
+	apiutil.WriteJSON(w, http.StatusAccepted, in)
+}
+
+// errBotPostsDisallowed is returned when an interaction is answered in a
+// channel that does not allow bot posts.
+var errBotPostsDisallowed = errors.New("bot posts disallowed in channel")
+
+// HandleInteractionCallback posts the app's answer to an interaction as a
+// message from the app in the interaction's channel. Each interaction can be
+// answered once.
+// POST /api/v1/interactions/{interactionID}/callback
+func (h *Handler) HandleInteractionCallback(w http.ResponseWriter, r *http.Request) {
+	botID := auth.UserIDFromContext(r.Context())
+	interactionID := chi.URLParam(r, "interactionID")
+
+	var body struct {
+		Content string `json:"content"`
+	}
+	if !apiutil.DecodeJSON(w, r, &body) {
+		return
+	}
+	if !apiutil.RequireNonEmpty(w, "content", strings.TrimSpace(body.Content)) {
+		return
+	}
+	if len(body.Content) > 4000 {
+		apiutil.WriteError(w, http.StatusBadRequest, "content_too_long", "Message content must be at most 4000 characters")
+		return
+	}
+
+	var msg models.Message
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		var channelID string
+		if err := tx.QueryRow(r.Context(),
+			`UPDATE interactions SET responded_at = now()
+			 WHERE id = $1 AND bot_id = $2 AND responded_at IS NULL AND created_at > now() - make_interval(mins => $3)
+			 RETURNING channel_id`,
+			interactionID, botID, int(interactionTTL.Minutes()),
+		).Scan(&channelID); err != nil {
+			return err
+		}
+
+		var disallowed bool
+		if err := tx.QueryRow(r.Context(),
+			`SELECT disallow_bot_posts FROM channels WHERE id = $1`, channelID,
+		).Scan(&disallowed); err != nil {
+			return err
+		}
+		if disallowed {
+			return errBotPostsDisallowed
+		}
+
+		if err := tx.QueryRow(r.Context(),
+			`INSERT INTO messages (id, channel_id, author_id, content, message_type, created_at)
+			 VALUES ($1, $2, $3, $4, $5, now())
+			 RETURNING id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
+			           reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
+			           thread_id, masquerade_name, masquerade_avatar, masquerade_color,
+			           encrypted, encryption_session_id, created_at`,
+			models.NewULID().String(), channelID, botID, body.Content, models.MessageTypeDefault,
+		).Scan(
+			&msg.ID, &msg.ChannelID, &msg.AuthorID, &msg.Content, &msg.Nonce, &msg.MessageType,
+			&msg.EditedAt, &msg.Flags, &msg.ReplyToIDs, &msg.MentionUserIDs, &msg.MentionRoleIDs,
+			&msg.MentionHere, &msg.ThreadID, &msg.MasqueradeName, &msg.MasqueradeAvatar,
+			&msg.MasqueradeColor, &msg.Encrypted, &msg.EncryptionSessionID, &msg.CreatedAt,
+		); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(r.Context(),
+			`UPDATE channels SET last_message_id = $1 WHERE id = $2`, msg.ID, channelID)
+		return err
+	})
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "interaction_not_found", "No open interaction with that ID for this app")
+		return
+	}
+	if errors.Is(err, errBotPostsDisallowed) {
+		apiutil.WriteError(w, http.StatusForbidden, "bot_posts_disallowed", "Bots may not post in this channel")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to answer interaction", err)
+		return
+	}
+
+	h.EventBus.PublishChannelEvent(r.Context(), events.SubjectMessageCreate, "MESSAGE_CREATE", msg.ChannelID, msg)
+
+	h.Logger.Info("interaction answered",
+		slog.String("interaction_id", interactionID),
+		slog.String("bot_id", botID),
+	)
+
+	apiutil.WriteJSON(w, http.StatusCreated, msg)
+}
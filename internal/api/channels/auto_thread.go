@@ -0,0 +1,84 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// maxThreadName matches the limit HandleCreateThread enforces.
+const maxThreadName = 100
+
+// autoThreadName names a thread after the first line of its starter
+// message, falling back to a generic name when there is no readable text.
+func autoThreadName(content *string, encrypted bool) string {
+	if content == nil || encrypted {
+		return "Thread"
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(*content), "\n")
+	line = strings.Join(strings.Fields(line), " ")
+	if line == "" {
+		return "Thread"
+	}
+	if utf8.RuneCountInString(line) > maxThreadName {
+		line = strings.TrimSpace(string([]rune(line)[:maxThreadName-1])) + "…"
+	}
+	return line
+}
+
+// openAutoThread creates the thread for a top-level message in an
+// auto-thread channel and links the message to it. Unlike a thread opened
+// by hand, no "thread created" system message is posted, since the starter
+// message already marks the thread in the channel.
+func (h *Handler) openAutoThread(ctx context.Context, guildID string, msg *models.Message, announce bool) error {
+	threadID := models.NewULID().String()
+	name := autoThreadName(msg.Content, msg.Encrypted)
+
+	var thread models.Channel
+	err := apiutil.WithTx(ctx, h.Pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx,
+			`INSERT INTO channels (id, guild_id, category_id, channel_type, name, owner_id, position,
+			                       default_auto_archive_duration, encrypted, parent_channel_id, last_activity_at, created_at)
+			 SELECT $1, guild_id, NULL, 'text', $2, $3, 0, default_auto_archive_duration, encrypted, id, now(), now()
+			 FROM channels WHERE id = $4
+			 RETURNING id, guild_id, category_id, channel_type, name, topic, position,
+			           slowmode_seconds, nsfw, encrypted, last_message_id, owner_id,
+			           default_permissions, user_limit, bitrate, locked, locked_by, locked_at,
+			           archived, read_only, read_only_role_ids, default_auto_archive_duration,
+			           parent_channel_id, last_activity_at, created_at`,
+			threadID, name, msg.AuthorID, msg.ChannelID,
+		).Scan(
+			&thread.ID, &thread.GuildID, &thread.CategoryID, &thread.ChannelType, &thread.Name,
+			&thread.Topic, &thread.Position, &thread.SlowmodeSeconds, &thread.NSFW, &thread.Encrypted,
+			&thread.LastMessageID, &thread.OwnerID, &thread.DefaultPermissions,
+			&thread.UserLimit, &thread.Bitrate,
+			&thread.Locked, &thread.LockedBy, &thread.LockedAt,
+			&thread.Archived, &thread.ReadOnly, &thread.ReadOnlyRoleIDs,
+			&thread.DefaultAutoArchiveDuration, &thread.ParentChannelID, &thread.LastActivityAt, &thread.CreatedAt,
+		); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx,
+			`UPDATE messages SET thread_id = $1 WHERE id = $2`, threadID, msg.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	msg.ThreadID = &threadID
+
+	if announce {
+		h.EventBus.Publish(ctx, events.SubjectChannelCreate, events.Event{
+			Type:    "THREAD_CREATE",
+			GuildID: guildID,
+			Data:    mustMarshal(thread),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package channels
+
+import (
+	"context"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// maxBurnMinutes is the longest a burn-after-reading DM may survive once
+// read: one week.
+const maxBurnMinutes = 7 * 24 * 60
+
+// enrichMessagesWithBurns attaches burn-after-reading state to messages so
+// clients can show the countdown.
+func (h *Handler) enrichMessagesWithBurns(ctx context.Context, messages []models.Message) {
+	if len(messages) == 0 {
+		return
+	}
+	msgIDs := make([]string, len(messages))
+	for i, m := range messages {
+		msgIDs[i] = m.ID
+	}
+	rows, err := h.Pool.Query(ctx,
+		`SELECT message_id, burn_after_minutes, burn_at FROM message_burns
+		 WHERE message_id = ANY($1)`, msgIDs)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	byID := make(map[string]*models.MessageBurn)
+	for rows.Next() {
+		var id string
+		var b models.MessageBurn
+		if err := rows.Scan(&id, &b.AfterMinutes, &b.BurnAt); err != nil {
+			continue
+		}
+		byID[id] = &b
+	}
+	for i := range messages {
+		messages[i].Burn = byID[messages[i].ID]
+	}
+}
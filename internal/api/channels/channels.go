@@ -6,27 +6,30 @@ package channels
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/locale"
 	"github.com/amityvox/amityvox/internal/mentions"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/permissions"
+	"github.com/amityvox/amityvox/internal/presence"
+	"github.com/amityvox/amityvox/internal/slo"
 )
 
 // Handler implements channel-related REST API endpoints.
@@ -35,98 +38,11 @@ type Handler struct {
 	EventBus *events.Bus
 	Logger   *slog.Logger
 	FedProxy apiutil.FederationProxy // optional, nil if federation disabled
-}
-
-// --- DM Spam Detection ---
+	Voice    apiutil.VoicePresence   // optional; voice participant exemptions need it
+	Cache    *presence.Cache         // optional; adaptive slowmode needs it to count messages
 
-// dmSpamTracker tracks recent DM sends per user to detect spam patterns.
-// A user is flagged when they send the same content to 5+ different DM
-// recipients within a 10-minute sliding window.
-var dmSpamTracker = &dmTracker{
-	sends: make(map[string][]dmSendEntry),
-}
-
-const (
-	dmSpamRecipientThreshold = 5           // same content to this many different recipients = flagged
-	dmSpamWindow             = 10 * time.Minute
-)
-
-type dmTracker struct {
-	mu    sync.Mutex
-	sends map[string][]dmSendEntry // key: "userID:contentHash"
-}
-
-type dmSendEntry struct {
-	recipientID string
-	ts          time.Time
-}
-
-// contentHash returns a hex-encoded SHA-256 hash of the content for compact storage.
-func contentHash(content string) string {
-	h := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(content))))
-	return hex.EncodeToString(h[:])
-}
-
-// trackDMSend records a DM send and returns true if the user is flagged as a spammer.
-func (dt *dmTracker) trackDMSend(userID, recipientID, content string) bool {
-	hash := contentHash(content)
-	key := userID + ":" + hash
-	now := time.Now()
-	cutoff := now.Add(-dmSpamWindow)
-
-	dt.mu.Lock()
-	defer dt.mu.Unlock()
-
-	// Prune old entries.
-	entries := dt.sends[key]
-	pruned := entries[:0]
-	for _, e := range entries {
-		if e.ts.After(cutoff) {
-			pruned = append(pruned, e)
-		}
-	}
-
-	// Add the current send.
-	pruned = append(pruned, dmSendEntry{recipientID: recipientID, ts: now})
-	dt.sends[key] = pruned
-
-	// Count unique recipients.
-	uniqueRecipients := make(map[string]struct{})
-	for _, e := range pruned {
-		uniqueRecipients[e.recipientID] = struct{}{}
-	}
-
-	return len(uniqueRecipients) >= dmSpamRecipientThreshold
-}
-
-// cleanup removes stale entries older than the window.
-func (dt *dmTracker) cleanup() {
-	dt.mu.Lock()
-	defer dt.mu.Unlock()
-	cutoff := time.Now().Add(-dmSpamWindow)
-	for key, entries := range dt.sends {
-		pruned := entries[:0]
-		for _, e := range entries {
-			if e.ts.After(cutoff) {
-				pruned = append(pruned, e)
-			}
-		}
-		if len(pruned) == 0 {
-			delete(dt.sends, key)
-		} else {
-			dt.sends[key] = pruned
-		}
-	}
-}
-
-func init() {
-	// Periodically clean up the DM spam tracker in the background.
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
-		for range ticker.C {
-			dmSpamTracker.cleanup()
-		}
-	}()
+	EmailDomain    string // email gateway domain, empty if the gateway is disabled
+	NormalizeEmoji bool   // store :shortcode: emoji as unicode
 }
 
 type updateChannelRequest struct {
@@ -148,6 +64,30 @@ type updateChannelRequest struct {
 	GalleryDefaultSort         *string  `json:"gallery_default_sort"`
 	GalleryPostGuidelines      *string  `json:"gallery_post_guidelines"`
 	GalleryRequireTags         *bool    `json:"gallery_require_tags"`
+	AutoThread                 *bool    `json:"auto_thread"`
+	DisallowBotPosts           *bool    `json:"disallow_bot_posts"`
+	DisallowWebhookPosts       *bool    `json:"disallow_webhook_posts"`
+	InviteLinksExempt          *bool    `json:"invite_links_exempt"`
+	NotificationLevel          *string  `json:"notification_level"`
+	// VoiceChannelID is a voice or stage channel of the guild; "" unties it.
+	VoiceChannelID         *string `json:"voice_channel_id"`
+	VoiceParticipantAllow  *int64  `json:"voice_participant_allow"`
+	VoiceChatRecentMinutes *int    `json:"voice_chat_recent_minutes"`
+	VoiceChatHistory       *string `json:"voice_chat_history"`
+	// PreviewRoleID is a role of the guild named on the locked preview; ""
+	// clears it.
+	PreviewEnabled *bool   `json:"preview_enabled"`
+	PreviewRoleID  *string `json:"preview_role_id"`
+	PublicView     *bool   `json:"public_view"`
+	// Language is an ISO 639 language code; "" clears it.
+	Language *string `json:"language"`
+	// Setting one of these to true makes the channel follow its category
+	// again; setting the value itself turns inheritance off.
+	InheritNSFW              *bool `json:"inherit_nsfw"`
+	InheritSlowmode          *bool `json:"inherit_slowmode"`
+	InheritNotificationLevel *bool `json:"inherit_notification_level"`
+	// Version, if set, must be the channel's current version.
+	Version *int64 `json:"version"`
 }
 
 type createMessageRequest struct {
@@ -158,9 +98,15 @@ type createMessageRequest struct {
 	MentionUserIDs      []string `json:"mention_user_ids"`
 	MentionRoleIDs      []string `json:"mention_role_ids"`
 	MentionHere     bool     `json:"mention_here"`
+	MentionEveryone bool     `json:"mention_everyone"`
 	Silent              bool     `json:"silent"`
 	Encrypted           bool     `json:"encrypted"`
 	EncryptionSessionID *string  `json:"encryption_session_id"`
+	BurnAfterMinutes    *int     `json:"burn_after_minutes"`
+	ContentWarning      *string  `json:"content_warning"`
+	// Masquerade is only accepted from bots holding MASQUERADE, which is how
+	// bridges show the remote author.
+	Masquerade *messageMasquerade `json:"masquerade"`
 }
 
 type scheduleMessageRequest struct {
@@ -171,6 +117,8 @@ type scheduleMessageRequest struct {
 
 type updateMessageRequest struct {
 	Content *string `json:"content"`
+	// ContentWarning replaces the message's warning; "" removes it.
+	ContentWarning *string `json:"content_warning"`
 }
 
 type permissionOverrideRequest struct {
@@ -187,19 +135,24 @@ func (h *Handler) HandleGetChannel(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: ViewChannel.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
 		return
 	}
 
 	channel, err := h.getChannel(r.Context(), channelID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get channel")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get channel")
 		return
 	}
+	if channel.PreviewEnabled && h.previewLocked(r.Context(), channelID, userID) {
+		channel.PreviewLocked = true
+		channel.LastMessageID = nil
+		channel.LastActivityAt = nil
+	}
 
 	apiutil.WriteJSON(w, http.StatusOK, channel)
 }
@@ -219,12 +172,12 @@ func (h *Handler) HandleUpdateChannel(w http.ResponseWriter, r *http.Request) {
 	if parentChID != nil {
 		if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageThreads) &&
 			!h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_THREADS permission")
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_THREADS permission")
 			return
 		}
 	} else {
 		if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 			return
 		}
 	}
@@ -243,7 +196,7 @@ func (h *Handler) HandleUpdateChannel(w http.ResponseWriter, r *http.Request) {
 			 LEFT JOIN guilds g ON g.id = c.guild_id
 			 WHERE c.id = $1`, channelID,
 		).Scan(&guildID, &instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up channel")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up channel")
 			return
 		}
 		// Build a flat payload that includes channel_id alongside the update fields
@@ -269,10 +222,10 @@ func (h *Handler) HandleUpdateChannel(w http.ResponseWriter, r *http.Request) {
 		var channelType string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT channel_type FROM channels WHERE id = $1`, channelID).Scan(&channelType); err != nil {
 			if err == pgx.ErrNoRows {
-				apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+				apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 				return
 			}
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get channel type")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to get channel type")
 			return
 		}
 		if channelType != "text" && channelType != "dm" && channelType != "group" {
@@ -282,6 +235,75 @@ func (h *Handler) HandleUpdateChannel(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Voice bitrate is capped by the guild's boost tier.
+	if req.Bitrate != nil {
+		if *req.Bitrate <= 0 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_bitrate", "Bitrate must be positive")
+			return
+		}
+		if limit := h.boostBitrateLimit(r.Context(), channelID); limit != nil && *req.Bitrate > *limit {
+			apiutil.WriteError(w, http.StatusBadRequest, "bitrate_too_high",
+				fmt.Sprintf("This guild's boost tier allows a bitrate of at most %d", *limit))
+			return
+		}
+	}
+
+	// A text channel can only be tied to a voice room of its own guild.
+	if req.VoiceChannelID != nil && *req.VoiceChannelID != "" {
+		var ok bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM channels v JOIN channels c ON c.guild_id = v.guild_id
+			    WHERE c.id = $1 AND v.id = $2 AND v.channel_type IN ('voice', 'stage')
+			      AND c.channel_type IN ('text', 'announcement'))`,
+			channelID, *req.VoiceChannelID).Scan(&ok)
+		if !ok {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_voice_channel",
+				"voice_channel_id must be a voice or stage channel in this guild, set on a text channel")
+			return
+		}
+	}
+	if req.VoiceParticipantAllow != nil && uint64(*req.VoiceParticipantAllow)&permissions.Administrator != 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_voice_participant_allow",
+			"voice_participant_allow cannot grant Administrator")
+		return
+	}
+	if !validateVoiceChatSettings(w, req.VoiceChatRecentMinutes, req.VoiceChatHistory) {
+		return
+	}
+	if req.PreviewRoleID != nil && *req.PreviewRoleID != "" {
+		var ok bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM roles r JOIN channels c ON c.guild_id = r.guild_id
+			    WHERE c.id = $1 AND r.id = $2)`,
+			channelID, *req.PreviewRoleID).Scan(&ok)
+		if !ok {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_role", "Preview role must belong to this guild")
+			return
+		}
+	}
+	if req.Language != nil && *req.Language != "" {
+		lang, err := locale.ParseLanguage(*req.Language)
+		if err != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_language", "language must be a language code such as ja or pt")
+			return
+		}
+		req.Language = &lang
+	}
+	// Only plain text and announcement channels of a guild can be public.
+	if req.PublicView != nil && *req.PublicView {
+		var ok bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT guild_id IS NOT NULL AND channel_type IN ('text', 'announcement')
+			        AND NOT COALESCE($2, nsfw) AND NOT COALESCE($3, encrypted)
+			 FROM channels WHERE id = $1`,
+			channelID, req.NSFW, req.Encrypted).Scan(&ok)
+		if !ok {
+			apiutil.WriteError(w, http.StatusBadRequest, "public_view_not_supported",
+				"Only guild text and announcement channels that are not NSFW or encrypted can be public")
+			return
+		}
+	}
+
 	// Validate auto-archive duration if provided.
 	if req.DefaultAutoArchiveDuration != nil {
 		valid := map[int]bool{0: true, 60: true, 1440: true, 4320: true, 10080: true}
@@ -292,6 +314,34 @@ func (h *Handler) HandleUpdateChannel(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if req.AutoThread != nil && *req.AutoThread {
+		var channelType string
+		if err := h.Pool.QueryRow(r.Context(), `SELECT channel_type FROM channels WHERE id = $1`, channelID).Scan(&channelType); err != nil {
+			if err == pgx.ErrNoRows {
+				apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+				return
+			}
+			apiutil.InternalError(w, h.Logger, "Failed to get channel type", err)
+			return
+		}
+		if parentChID != nil || (channelType != models.ChannelTypeText && channelType != models.ChannelTypeAnnouncement) {
+			apiutil.WriteError(w, http.StatusBadRequest, "auto_thread_not_supported",
+				"Auto-threading is only available in text and announcement channels")
+			return
+		}
+	}
+
+	if req.NotificationLevel != nil && *req.NotificationLevel != "" &&
+		*req.NotificationLevel != "all" && *req.NotificationLevel != "mentions" && *req.NotificationLevel != "none" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_level", "Notification level must be all, mentions, or none")
+		return
+	}
+	// An explicit value overrides the category; "" clears the level.
+	inheritNSFW := inheritFlag(req.InheritNSFW, req.NSFW != nil)
+	inheritSlowmode := inheritFlag(req.InheritSlowmode, req.SlowmodeSeconds != nil)
+	inheritLevel := inheritFlag(req.InheritNotificationLevel, req.NotificationLevel != nil)
+
+	// The channel_inherit_category trigger fills in inherited values.
 	var channel models.Channel
 	err := h.Pool.QueryRow(r.Context(),
 		`UPDATE channels SET
@@ -312,20 +362,44 @@ func (h *Handler) HandleUpdateChannel(w http.ResponseWriter, r *http.Request) {
 			forum_require_tags = COALESCE($16, forum_require_tags),
 			gallery_default_sort = COALESCE($17, gallery_default_sort),
 			gallery_post_guidelines = COALESCE($18, gallery_post_guidelines),
-			gallery_require_tags = COALESCE($19, gallery_require_tags)
-		 WHERE id = $1
+			gallery_require_tags = COALESCE($19, gallery_require_tags),
+			notification_level = CASE WHEN $20::text IS NULL THEN notification_level ELSE NULLIF($20, '') END,
+			nsfw_inherited = COALESCE($21, nsfw_inherited),
+			slowmode_inherited = COALESCE($22, slowmode_inherited),
+			notification_level_inherited = COALESCE($23, notification_level_inherited),
+			auto_thread = COALESCE($24, auto_thread),
+			disallow_bot_posts = COALESCE($25, disallow_bot_posts),
+			disallow_webhook_posts = COALESCE($26, disallow_webhook_posts),
+			invite_links_exempt = COALESCE($28, invite_links_exempt),
+			voice_channel_id = CASE WHEN $29::text IS NULL THEN voice_channel_id ELSE NULLIF($29, '') END,
+			voice_participant_allow = COALESCE($30, voice_participant_allow),
+			voice_chat_recent_minutes = COALESCE($31, voice_chat_recent_minutes),
+			voice_chat_history = COALESCE($32, voice_chat_history),
+			preview_enabled = COALESCE($33, preview_enabled),
+			preview_role_id = CASE WHEN $34::text IS NULL THEN preview_role_id ELSE NULLIF($34, '') END,
+			public_view = COALESCE($35, public_view),
+			language = CASE WHEN $36::text IS NULL THEN language ELSE NULLIF($36, '') END,
+			version = version + 1
+		 WHERE id = $1 AND ($27::bigint IS NULL OR version = $27)
 		 RETURNING id, guild_id, category_id, channel_type, name, topic, position,
 		           slowmode_seconds, nsfw, encrypted, last_message_id, owner_id,
 		           default_permissions, user_limit, bitrate, locked, locked_by, locked_at,
 		           archived, read_only, read_only_role_ids, default_auto_archive_duration,
 		           forum_default_sort, forum_post_guidelines, forum_require_tags,
-		           gallery_default_sort, gallery_post_guidelines, gallery_require_tags,
-		           pinned, reply_count, created_at`,
+		           gallery_default_sort, gallery_post_guidelines, gallery_require_tags, auto_thread,
+		           disallow_bot_posts, disallow_webhook_posts, invite_links_exempt,
+		           voice_channel_id, voice_participant_allow, voice_chat_recent_minutes, voice_chat_history,
+		           preview_enabled, preview_role_id, public_view, language, pinned, reply_count,
+		           nsfw_inherited, slowmode_inherited, notification_level, notification_level_inherited, version, created_at`,
 		channelID, req.Name, req.Topic, req.Position, req.NSFW, req.SlowmodeSeconds,
 		req.UserLimit, req.Bitrate, req.Archived, req.Encrypted, req.ReadOnly, req.ReadOnlyRoleIDs,
 		req.DefaultAutoArchiveDuration,
 		req.ForumDefaultSort, req.ForumPostGuidelines, req.ForumRequireTags,
 		req.GalleryDefaultSort, req.GalleryPostGuidelines, req.GalleryRequireTags,
+		req.NotificationLevel, inheritNSFW, inheritSlowmode, inheritLevel, req.AutoThread,
+		req.DisallowBotPosts, req.DisallowWebhookPosts, req.Version, req.InviteLinksExempt,
+		req.VoiceChannelID, req.VoiceParticipantAllow, req.VoiceChatRecentMinutes, req.VoiceChatHistory,
+		req.PreviewEnabled, req.PreviewRoleID, req.PublicView, req.Language,
 	).Scan(
 		&channel.ID, &channel.GuildID, &channel.CategoryID, &channel.ChannelType, &channel.Name,
 		&channel.Topic, &channel.Position, &channel.SlowmodeSeconds, &channel.NSFW, &channel.Encrypted,
@@ -335,18 +409,34 @@ func (h *Handler) HandleUpdateChannel(w http.ResponseWriter, r *http.Request) {
 		&channel.Archived, &channel.ReadOnly, &channel.ReadOnlyRoleIDs,
 		&channel.DefaultAutoArchiveDuration,
 		&channel.ForumDefaultSort, &channel.ForumPostGuidelines, &channel.ForumRequireTags,
-		&channel.GalleryDefaultSort, &channel.GalleryPostGuidelines, &channel.GalleryRequireTags,
-		&channel.Pinned, &channel.ReplyCount, &channel.CreatedAt,
+		&channel.GalleryDefaultSort, &channel.GalleryPostGuidelines, &channel.GalleryRequireTags, &channel.AutoThread,
+		&channel.DisallowBotPosts, &channel.DisallowWebhookPosts, &channel.InviteLinksExempt,
+		&channel.VoiceChannelID, &channel.VoiceParticipantAllow, &channel.VoiceChatRecentMinutes, &channel.VoiceChatHistory,
+		&channel.PreviewEnabled, &channel.PreviewRoleID, &channel.PublicView, &channel.Language, &channel.Pinned, &channel.ReplyCount,
+		&channel.NSFWInherited, &channel.SlowmodeInherited, &channel.NotificationLevel,
+		&channel.NotificationLevelInherited, &channel.Version, &channel.CreatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+			if req.Version != nil {
+				if current, err := h.getChannel(r.Context(), channelID); err == nil {
+					apiutil.WriteVersionConflict(w, current)
+					return
+				}
+			}
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update channel")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update channel")
 		return
 	}
 
+	// The server cannot read encrypted messages, so auto-translation ends
+	// when a channel turns on encryption.
+	if channel.Encrypted {
+		h.Pool.Exec(r.Context(), `DELETE FROM channel_auto_translate WHERE channel_id = $1`, channelID)
+	}
+
 	guildID := ""
 	if channel.GuildID != nil {
 		guildID = *channel.GuildID
@@ -376,7 +466,7 @@ func (h *Handler) HandleDeleteChannel(w http.ResponseWriter, r *http.Request) {
 			 LEFT JOIN guilds g ON g.id = c.guild_id
 			 WHERE c.id = $1`, channelID,
 		).Scan(&fedGuildID, &instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up channel")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up channel")
 			return
 		}
 		if fedGuildID != nil && h.FedProxy.ProxyToHomeInstance(w, r, *fedGuildID, instanceID, "channel_delete", userID, map[string]string{"channel_id": channelID}) {
@@ -394,12 +484,12 @@ func (h *Handler) HandleDeleteChannel(w http.ResponseWriter, r *http.Request) {
 	if parentChannelID != nil {
 		if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageThreads) &&
 			!h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_THREADS permission")
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_THREADS permission")
 			return
 		}
 	} else {
 		if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 			return
 		}
 	}
@@ -430,11 +520,11 @@ func (h *Handler) HandleDeleteChannel(w http.ResponseWriter, r *http.Request) {
 		return nil
 	}); err != nil {
 		h.Logger.Error("failed to delete channel", slog.String("channel_id", channelID), slog.String("error", err.Error()))
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete channel")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete channel")
 		return
 	}
 	if rowsAffected == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 
@@ -467,7 +557,16 @@ func (h *Handler) HandleGetMessages(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: ViewChannel + ReadHistory.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ReadHistory) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need READ_HISTORY permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need READ_HISTORY permission")
+		return
+	}
+	if h.previewLocked(r.Context(), channelID, userID) {
+		writePreviewLocked(w)
+		return
+	}
+	if !h.canReadVoiceChat(r.Context(), channelID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_in_voice",
+			"Only current and recent participants can read this voice channel's chat")
 		return
 	}
 
@@ -488,42 +587,42 @@ func (h *Handler) HandleGetMessages(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case before != "":
 		query = `SELECT id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
-		                reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
+		                reply_to_ids, mention_user_ids, mention_role_ids, mention_here, mention_everyone,
 		                thread_id, masquerade_name, masquerade_avatar, masquerade_color,
-		                encrypted, encryption_session_id, created_at
+		                encrypted, encryption_session_id, repeat_count, created_at
 		         FROM messages WHERE channel_id = $1 AND id < $2
 		         ORDER BY id DESC LIMIT $3`
 		args = []interface{}{channelID, before, limit}
 	case after != "":
 		query = `SELECT id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
-		                reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
+		                reply_to_ids, mention_user_ids, mention_role_ids, mention_here, mention_everyone,
 		                thread_id, masquerade_name, masquerade_avatar, masquerade_color,
-		                encrypted, encryption_session_id, created_at
+		                encrypted, encryption_session_id, repeat_count, created_at
 		         FROM messages WHERE channel_id = $1 AND id > $2
 		         ORDER BY id ASC LIMIT $3`
 		args = []interface{}{channelID, after, limit}
 	case around != "":
 		halfLimit := limit / 2
 		query = `(SELECT id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
-		                 reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
+		                 reply_to_ids, mention_user_ids, mention_role_ids, mention_here, mention_everyone,
 		                 thread_id, masquerade_name, masquerade_avatar, masquerade_color,
-		                 encrypted, encryption_session_id, created_at
+		                 encrypted, encryption_session_id, repeat_count, created_at
 		          FROM messages WHERE channel_id = $1 AND id <= $2
 		          ORDER BY id DESC LIMIT $3)
 		         UNION ALL
 		         (SELECT id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
-		                 reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
+		                 reply_to_ids, mention_user_ids, mention_role_ids, mention_here, mention_everyone,
 		                 thread_id, masquerade_name, masquerade_avatar, masquerade_color,
-		                 encrypted, encryption_session_id, created_at
+		                 encrypted, encryption_session_id, repeat_count, created_at
 		          FROM messages WHERE channel_id = $1 AND id > $2
 		          ORDER BY id ASC LIMIT $4)
 		         ORDER BY id DESC`
 		args = []interface{}{channelID, around, halfLimit, halfLimit}
 	default:
 		query = `SELECT id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
-		                reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
+		                reply_to_ids, mention_user_ids, mention_role_ids, mention_here, mention_everyone,
 		                thread_id, masquerade_name, masquerade_avatar, masquerade_color,
-		                encrypted, encryption_session_id, created_at
+		                encrypted, encryption_session_id, repeat_count, created_at
 		         FROM messages WHERE channel_id = $1
 		         ORDER BY id DESC LIMIT $2`
 		args = []interface{}{channelID, limit}
@@ -542,8 +641,8 @@ func (h *Handler) HandleGetMessages(w http.ResponseWriter, r *http.Request) {
 		if err := rows.Scan(
 			&m.ID, &m.ChannelID, &m.AuthorID, &m.Content, &m.Nonce, &m.MessageType,
 			&m.EditedAt, &m.Flags, &m.ReplyToIDs, &m.MentionUserIDs, &m.MentionRoleIDs,
-			&m.MentionHere, &m.ThreadID, &m.MasqueradeName, &m.MasqueradeAvatar,
-			&m.MasqueradeColor, &m.Encrypted, &m.EncryptionSessionID, &m.CreatedAt,
+			&m.MentionHere, &m.MentionEveryone, &m.ThreadID, &m.MasqueradeName, &m.MasqueradeAvatar,
+			&m.MasqueradeColor, &m.Encrypted, &m.EncryptionSessionID, &m.RepeatCount, &m.CreatedAt,
 		); err != nil {
 			apiutil.InternalError(w, h.Logger, "Failed to read messages", err)
 			return
@@ -551,16 +650,139 @@ func (h *Handler) HandleGetMessages(w http.ResponseWriter, r *http.Request) {
 		messages = append(messages, m)
 	}
 
+	messages = h.filterHidden(r.Context(), channelID, userID, messages)
+
 	h.enrichMessagesWithAuthors(r.Context(), messages)
 	h.enrichMessagesWithAttachments(r.Context(), messages)
 	h.enrichMessagesWithEmbeds(r.Context(), messages)
+	h.enrichMessagesWithTranslations(r.Context(), channelID, messages)
+	h.enrichMessagesWithBurns(r.Context(), messages)
+	h.enrichMessagesWithContentWarnings(r.Context(), messages)
+	h.enrichMessagesWithStatus(r.Context(), channelID, userID, messages)
+	h.redactForBots(r.Context(), channelID, userID, messages)
+	h.redactBlocked(r.Context(), userID, messages)
 
 	apiutil.WriteJSON(w, http.StatusOK, messages)
 }
 
+// publishMessageEvent publishes a message create or update. Messages from
+// quarantined users are routed to their author alone.
+func (h *Handler) publishMessageEvent(ctx context.Context, subject, eventType string, msg models.Message) {
+	if msg.IsQuarantined() {
+		h.EventBus.PublishUserEvent(ctx, events.SubjectQuarantinedMessage, eventType, msg.AuthorID, msg)
+		return
+	}
+	h.EventBus.Publish(ctx, subject, events.Event{
+		Type:      eventType,
+		ChannelID: msg.ChannelID,
+		Data:      mustMarshal(msg),
+	})
+}
+
+// filterHidden drops quarantined and soft-deleted messages the viewer may
+// not see. Members with ManageMessages see both, so they can review a
+// quarantine or restore a deletion. Quarantined messages are also shown to
+// their authors; soft-deleted ones are not.
+func (h *Handler) filterHidden(ctx context.Context, channelID, userID string, messages []models.Message) []models.Message {
+	hidden := false
+	for _, m := range messages {
+		if hiddenFromMembers(m, userID) {
+			hidden = true
+			break
+		}
+	}
+	if !hidden || h.hasChannelPermission(ctx, channelID, userID, permissions.ManageMessages) {
+		return messages
+	}
+	visible := messages[:0]
+	for _, m := range messages {
+		if !hiddenFromMembers(m, userID) {
+			visible = append(visible, m)
+		}
+	}
+	return visible
+}
+
+// hiddenFromMembers reports whether userID may only see m with
+// ManageMessages.
+func hiddenFromMembers(m models.Message, userID string) bool {
+	return m.IsDeleted() || (m.IsQuarantined() && m.AuthorID != userID)
+}
+
+// botContentHidden reports whether the user is a bot that may not read
+// message content in this channel: it has no approved message content
+// access and the channel belongs to a guild.
+func (h *Handler) botContentHidden(ctx context.Context, channelID, userID string) bool {
+	var hidden bool
+	h.Pool.QueryRow(ctx,
+		`SELECT u.flags & $3 != 0 AND c.guild_id IS NOT NULL
+		        AND NOT EXISTS(SELECT 1 FROM bot_message_content_access a
+		                       WHERE a.bot_id = u.id AND a.status = 'approved')
+		 FROM users u, channels c WHERE u.id = $1 AND c.id = $2`,
+		userID, channelID, models.UserFlagBot).Scan(&hidden)
+	return hidden
+}
+
+// redactForBots clears message content for bots without message content
+// access, leaving their own messages and those that mention them intact.
+func (h *Handler) redactForBots(ctx context.Context, channelID, userID string, messages []models.Message) {
+	if len(messages) == 0 || !h.botContentHidden(ctx, channelID, userID) {
+		return
+	}
+	for i := range messages {
+		if messages[i].AuthorID != userID && !slices.Contains(messages[i].MentionUserIDs, userID) {
+			messages[i].RedactContent()
+		}
+	}
+}
+
+// redactBlocked replaces messages by authors the user has blocked with
+// placeholders. Ignored authors are left alone: the client collapses their
+// messages but can still show them.
+func (h *Handler) redactBlocked(ctx context.Context, userID string, messages []models.Message) {
+	if len(messages) == 0 {
+		return
+	}
+	authorIDs := make([]string, 0, len(messages))
+	for _, m := range messages {
+		if m.AuthorID != userID && !slices.Contains(authorIDs, m.AuthorID) {
+			authorIDs = append(authorIDs, m.AuthorID)
+		}
+	}
+	if len(authorIDs) == 0 {
+		return
+	}
+
+	rows, err := h.Pool.Query(ctx,
+		`SELECT target_id FROM user_blocks
+		 WHERE user_id = $1 AND level = $2 AND target_id = ANY($3)`,
+		userID, models.BlockLevelBlock, authorIDs)
+	if err != nil {
+		h.Logger.Warn("failed to load blocked authors", slog.String("error", err.Error()))
+		return
+	}
+	defer rows.Close()
+	blocked := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			blocked[id] = true
+		}
+	}
+	if len(blocked) == 0 {
+		return
+	}
+	for i := range messages {
+		if blocked[messages[i].AuthorID] {
+			messages[i].RedactBlocked()
+		}
+	}
+}
+
 // HandleCreateMessage sends a new message in a channel.
 // POST /api/v1/channels/{channelID}/messages
 func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	userID := auth.UserIDFromContext(r.Context())
 	channelID := chi.URLParam(r, "channelID")
 
@@ -568,13 +790,13 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 	// (down from ~25 sequential queries in the original implementation).
 	cc, err := h.loadChannelCtx(r.Context(), channelID, userID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 
 	// Permission check: SendMessages.
 	if !cc.hasPerm(permissions.SendMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need SEND_MESSAGES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need SEND_MESSAGES permission")
 		return
 	}
 
@@ -587,25 +809,18 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 		apiutil.WriteError(w, http.StatusForbidden, "channel_locked", "This channel is locked")
 		return
 	}
-	// Read-only check: owners, admins, and Administrator role holders bypass.
-	if cc.ReadOnly {
-		allowed := cc.IsOwner || cc.IsAdmin || cc.hasPerm(permissions.Administrator)
-		if !allowed && len(cc.ReadOnlyRoleIDs) > 0 && cc.GuildID != nil {
-			var matchCount int
-			h.Pool.QueryRow(r.Context(),
-				`SELECT COUNT(*) FROM member_roles
-				 WHERE guild_id = $1 AND user_id = $2 AND role_id = ANY($3)`,
-				*cc.GuildID, userID, cc.ReadOnlyRoleIDs,
-			).Scan(&matchCount)
-			if matchCount > 0 {
-				allowed = true
-			}
-		}
-		if !allowed {
-			apiutil.WriteError(w, http.StatusForbidden, "channel_read_only",
-				"This channel is read-only. Only users with specific roles can post.")
-			return
-		}
+	if !h.canPostReadOnly(r.Context(), cc, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "channel_read_only",
+			"This channel is read-only. Only users with specific roles can post.")
+		return
+	}
+	if cc.DisallowBotPosts && cc.UserFlags&models.UserFlagBot != 0 {
+		apiutil.WriteError(w, http.StatusForbidden, "bot_posts_disallowed", "Bots may not post in this channel")
+		return
+	}
+	if !h.canPostVoiceChat(r.Context(), cc, channelID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_in_voice", "Join the voice channel to chat in it")
+		return
 	}
 
 	var req createMessageRequest
@@ -613,6 +828,20 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A resend with a nonce already used returns the first message.
+	if req.Nonce != nil && *req.Nonce == "" {
+		req.Nonce = nil
+	}
+	if req.Nonce != nil {
+		if len(*req.Nonce) > maxNonceLength {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_nonce", "Nonce must be at most 64 characters")
+			return
+		}
+		if h.writeDeliveredMessage(w, r, channelID, userID, *req.Nonce) {
+			return
+		}
+	}
+
 	hasContent := req.Content != nil && *req.Content != ""
 	hasAttachments := len(req.AttachmentIDs) > 0
 	if !hasContent && !hasAttachments {
@@ -629,7 +858,7 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 	// Only proxy plain text messages — the federation protocol only carries
 	// content/nonce/reply_to_ids. Messages with attachments, encryption, or
 	// silent flags fall through to local handling until protocol parity.
-	canProxy := hasContent && !hasAttachments && !req.Silent && !req.Encrypted
+	canProxy := hasContent && !hasAttachments && !req.Silent && !req.Encrypted && req.Masquerade == nil
 	if h.FedProxy != nil && canProxy {
 		opts := map[string]interface{}{}
 		if req.Nonce != nil && *req.Nonce != "" {
@@ -655,16 +884,80 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Enforce slowmode. Users with ManageMessages or ManageChannels bypass.
-	if cc.SlowmodeSeconds > 0 && !cc.hasPerm(permissions.ManageMessages) && !cc.hasPerm(permissions.ManageChannels) {
+	// Auto-thread channels only take conversation starters; replies belong
+	// in the starter's thread.
+	if cc.AutoThread && len(req.ReplyToIDs) > 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "reply_in_thread",
+			"Replies in this channel must be posted in the message's thread")
+		return
+	}
+
+	var masqName, masqAvatar, masqColor *string
+	if req.Masquerade != nil {
+		if cc.UserFlags&models.UserFlagBot == 0 || cc.GuildID == nil {
+			apiutil.WriteError(w, http.StatusForbidden, "masquerade_not_allowed",
+				"Only bots can post messages with a masquerade in guild channels")
+			return
+		}
+		if !cc.hasPerm(permissions.Masquerade) {
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need MASQUERADE permission")
+			return
+		}
+		var mErr error
+		masqName, masqAvatar, masqColor, mErr = req.Masquerade.normalize()
+		if mErr != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_masquerade", mErr.Error())
+			return
+		}
+	}
+
+	// Burn-after-reading is only offered in one-to-one DMs, where there is a
+	// single recipient whose read state starts the timer.
+	if req.BurnAfterMinutes != nil {
+		if cc.ChannelType != "dm" {
+			apiutil.WriteError(w, http.StatusBadRequest, "burn_not_allowed",
+				"Burn-after-reading is only available in direct messages")
+			return
+		}
+		if *req.BurnAfterMinutes < 1 || *req.BurnAfterMinutes > maxBurnMinutes {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_burn",
+				fmt.Sprintf("burn_after_minutes must be between 1 and %d", maxBurnMinutes))
+			return
+		}
+	}
+
+	var contentWarning string
+	if req.ContentWarning != nil {
+		var cwErr error
+		if contentWarning, cwErr = normalizeContentWarning(*req.ContentWarning); cwErr != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_content_warning", cwErr.Error())
+			return
+		}
+	}
+
+	// Enforce slowmode. Users with ManageMessages or ManageChannels bypass, as
+	// do members connected to the channel's voice room. Adaptive slowmode can
+	// raise the cooldown above the fixed one while the channel is busy.
+	bypassSlowmode := cc.hasPerm(permissions.ManageMessages) || cc.hasPerm(permissions.ManageChannels) || cc.InVoice
+	slowmode := cc.SlowmodeSeconds
+	var adaptive *models.ChannelAdaptiveSlowmode
+	if cc.AdaptiveSlowmode {
+		if cfg, err := h.loadAdaptiveSlowmode(r.Context(), channelID); err == nil && cfg.Enabled {
+			adaptive = &cfg
+			if !bypassSlowmode {
+				slowmode = max(slowmode, adaptiveCooldown(cfg, h.channelVelocity(r.Context(), cfg)))
+			}
+		}
+	}
+	if slowmode > 0 && !bypassSlowmode {
 		var lastSent *time.Time
 		h.Pool.QueryRow(r.Context(),
 			`SELECT MAX(created_at) FROM messages WHERE channel_id = $1 AND author_id = $2`,
 			channelID, userID).Scan(&lastSent)
 		if lastSent != nil {
 			elapsed := time.Since(*lastSent)
-			if elapsed < time.Duration(cc.SlowmodeSeconds)*time.Second {
-				remaining := time.Duration(cc.SlowmodeSeconds)*time.Second - elapsed
+			if elapsed < time.Duration(slowmode)*time.Second {
+				remaining := time.Duration(slowmode)*time.Second - elapsed
 				apiutil.WriteError(w, http.StatusTooManyRequests, "slowmode",
 					fmt.Sprintf("Slowmode active. Try again in %.0f seconds", remaining.Seconds()))
 				return
@@ -672,11 +965,52 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Per-source limits throttle noisy integrations regardless of who the
+	// individual author is.
+	source := models.MessageSourceOf(cc.UserFlags, masqName != nil)
+	if retryAfter := apiutil.SourceRateLimited(r.Context(), h.Pool, h.Cache, channelID, source); retryAfter > 0 {
+		apiutil.WriteSourceRateLimited(w, source, retryAfter)
+		return
+	}
+
+	// Attachments posted in a guild are held to its boost tier's upload limit.
+	if hasAttachments && cc.GuildID != nil {
+		if limit := h.oversizedAttachment(r.Context(), *cc.GuildID, userID, req.AttachmentIDs); limit > 0 {
+			apiutil.WriteError(w, http.StatusRequestEntityTooLarge, "attachment_too_large",
+				fmt.Sprintf("Attachments in this guild can be at most %d MB", limit/(1<<20)))
+			return
+		}
+	}
+
 	// Check if the user is timed out in this guild.
 	if cc.TimeoutUntil != nil && cc.TimeoutUntil.After(time.Now()) {
 		apiutil.WriteError(w, http.StatusForbidden, "timed_out", "You are timed out and cannot send messages")
 		return
 	}
+	if cc.GuildID != nil && apiutil.GuildRulesPending(r.Context(), h.Pool, *cc.GuildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "rules_not_accepted", "You must accept the guild rules before posting")
+		return
+	}
+	if hasContent && !req.Encrypted && !h.applyInvitePolicy(w, cc, req.Content, hasAttachments) {
+		return
+	}
+
+	// A plain text repeat of the sender's last message is collapsed or
+	// refused when the channel's duplicate policy asks for it.
+	if hasContent && !hasAttachments && !req.Encrypted && len(req.ReplyToIDs) == 0 &&
+		h.handleRepeat(w, r, cc, channelID, userID, *req.Content) {
+		return
+	}
+
+	// DMs have their own message rate class, tighter than the guild one.
+	if cc.GuildID == nil {
+		if retryAfter := apiutil.DMRateLimited(r.Context(), h.Cache, apiutil.DMClassMessage, userID); retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+			apiutil.WriteError(w, http.StatusTooManyRequests, "dm_rate_limited",
+				"You are sending direct messages too quickly. Please slow down.")
+			return
+		}
+	}
 
 	// DM spam detection.
 	if cc.GuildID == nil && hasContent {
@@ -686,14 +1020,28 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 			channelID, userID,
 		).Scan(&recipientID)
 		if err == nil && recipientID != "" {
-			if dmSpamTracker.trackDMSend(userID, recipientID, *req.Content) {
-				h.Logger.Warn("DM spam detected: user sending identical content to multiple recipients",
-					slog.String("user_id", userID),
-					slog.String("channel_id", channelID),
-				)
-				apiutil.WriteError(w, http.StatusTooManyRequests, "dm_spam_detected",
-					"You are sending similar messages to too many users. Please slow down.")
-				return
+			if apiutil.TrackDMSend(userID, recipientID, *req.Content) {
+				// Shadow-quarantine rather than reject, so the sender keeps
+				// posting into the void until a moderator reviews them.
+				reason := "Identical content sent to many DM recipients"
+				created, qErr := apiutil.QuarantineUser(r.Context(), h.Pool, models.UserQuarantine{
+					UserID: userID,
+					Source: models.QuarantineSourceDMSpam,
+					Reason: &reason,
+				})
+				if qErr != nil {
+					h.Logger.Error("failed to quarantine DM spammer",
+						slog.String("user_id", userID), slog.String("error", qErr.Error()))
+					apiutil.WriteError(w, http.StatusTooManyRequests, "dm_spam_detected",
+						"You are sending similar messages to too many users. Please slow down.")
+					return
+				}
+				if created {
+					h.Logger.Warn("DM spam detected: user quarantined for sending identical content to multiple recipients",
+						slog.String("user_id", userID),
+						slog.String("channel_id", channelID),
+					)
+				}
 			}
 		}
 	}
@@ -703,25 +1051,35 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 	if req.Silent {
 		flags |= models.MessageFlagSilent
 	}
+	quarantined := apiutil.IsQuarantined(r.Context(), h.Pool, userID)
+	if quarantined {
+		flags |= models.MessageFlagQuarantined
+	}
 	if req.Content != nil && strings.HasPrefix(*req.Content, "@silent ") {
 		flags |= models.MessageFlagSilent
 		trimmed := strings.TrimPrefix(*req.Content, "@silent ")
 		req.Content = &trimmed
 	}
 
+	if !req.Encrypted && hasContent {
+		h.normalizeEmojiShortcodes(r.Context(), cc.GuildID, channelID, userID, req.Content)
+	}
+
 	// Extract and validate mentions from content.
 	var mentionUserIDs []string
 	var mentionRoleIDs []string
-	var mentionHere bool
+	var mentionHere, mentionEveryone bool
 
 	if req.Encrypted {
 		// Encrypted messages: trust client-supplied mention fields (server can't parse ciphertext).
 		mentionUserIDs = req.MentionUserIDs
 		mentionRoleIDs = req.MentionRoleIDs
 		mentionHere = req.MentionHere
+		mentionEveryone = req.MentionEveryone
 	} else if hasContent {
 		parsed := mentions.Parse(*req.Content)
 		mentionHere = parsed.MentionHere
+		mentionEveryone = parsed.MentionEveryone
 		mentionUserIDs = parsed.UserIDs
 		mentionRoleIDs = parsed.RoleIDs
 	}
@@ -730,9 +1088,13 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 	if mentionHere && cc.GuildID != nil && !cc.hasPerm(permissions.MentionHere) {
 		mentionHere = false
 	}
-	// No @here in DMs.
-	if mentionHere && cc.GuildID == nil {
-		mentionHere = false
+	// Same for @everyone, which needs its own MentionEveryone permission.
+	if mentionEveryone && cc.GuildID != nil && !cc.hasPerm(permissions.MentionEveryone) {
+		mentionEveryone = false
+	}
+	// No @here or @everyone in DMs.
+	if cc.GuildID == nil {
+		mentionHere, mentionEveryone = false, false
 	}
 
 	// Validate user mentions: only store IDs of actual guild members (or DM recipients).
@@ -821,6 +1183,9 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 		mentionRoleIDs = nil
 	}
 
+	// Mass mentions past the reach or hourly cap are dropped, not rejected.
+	h.limitMassMentions(r.Context(), cc, userID, &mentionEveryone, &mentionHere, &mentionRoleIDs, len(mentionUserIDs))
+
 	msgID := models.NewULID().String()
 	msgType := models.MessageTypeDefault
 	if len(req.ReplyToIDs) > 0 {
@@ -831,21 +1196,27 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 	err = h.Pool.QueryRow(r.Context(),
 		`INSERT INTO messages (id, channel_id, author_id, content, nonce, message_type, flags,
 		                       reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
-		                       encrypted, encryption_session_id, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, now())
+		                       encrypted, encryption_session_id,
+		                       masquerade_name, masquerade_avatar, masquerade_color, mention_everyone, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, now())
 		 RETURNING id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
-		           reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
+		           reply_to_ids, mention_user_ids, mention_role_ids, mention_here, mention_everyone,
 		           thread_id, masquerade_name, masquerade_avatar, masquerade_color,
 		           encrypted, encryption_session_id, created_at`,
 		msgID, channelID, userID, req.Content, req.Nonce, msgType, flags,
 		req.ReplyToIDs, mentionUserIDs, mentionRoleIDs, mentionHere,
 		req.Encrypted, req.EncryptionSessionID,
+		masqName, masqAvatar, masqColor, mentionEveryone,
 	).Scan(
 		&msg.ID, &msg.ChannelID, &msg.AuthorID, &msg.Content, &msg.Nonce, &msg.MessageType,
 		&msg.EditedAt, &msg.Flags, &msg.ReplyToIDs, &msg.MentionUserIDs, &msg.MentionRoleIDs,
-		&msg.MentionHere, &msg.ThreadID, &msg.MasqueradeName, &msg.MasqueradeAvatar,
+		&msg.MentionHere, &msg.MentionEveryone, &msg.ThreadID, &msg.MasqueradeName, &msg.MasqueradeAvatar,
 		&msg.MasqueradeColor, &msg.Encrypted, &msg.EncryptionSessionID, &msg.CreatedAt,
 	)
+	if err != nil && isNonceConflict(err) && h.writeDeliveredMessage(w, r, channelID, userID, *req.Nonce) {
+		// A concurrent resend got there first.
+		return
+	}
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to send message", err)
 		return
@@ -859,24 +1230,67 @@ func (h *Handler) HandleCreateMessage(w http.ResponseWriter, r *http.Request) {
 		msg.Attachments = h.loadAttachments(r.Context(), msgID)
 	}
 
-	// Update last_message_id on the channel.
-	h.Pool.Exec(r.Context(),
-		`UPDATE channels SET last_message_id = $1 WHERE id = $2`, msgID, channelID)
+	if contentWarning != "" {
+		if err := h.setContentWarning(r.Context(), msgID, contentWarning); err != nil {
+			// Never post a message without the warning its sender asked for.
+			h.Pool.Exec(r.Context(), `DELETE FROM messages WHERE id = $1`, msgID)
+			apiutil.InternalError(w, h.Logger, "Failed to send message", err)
+			return
+		}
+		msg.ContentWarning = &contentWarning
+		if len(msg.Attachments) > 0 {
+			msg.Attachments = h.loadAttachments(r.Context(), msgID)
+		}
+	}
 
-	// Update last_activity_at and reply_count for thread channels (fire-and-forget).
-	h.Pool.Exec(r.Context(),
-		`UPDATE channels SET last_activity_at = now(), reply_count = reply_count + 1
-		 WHERE id = $1 AND parent_channel_id IS NOT NULL`,
-		channelID)
+	if req.BurnAfterMinutes != nil {
+		if _, err := h.Pool.Exec(r.Context(),
+			`INSERT INTO message_burns (message_id, channel_id, author_id, burn_after_minutes)
+			 VALUES ($1, $2, $3, $4)`,
+			msgID, channelID, userID, *req.BurnAfterMinutes); err != nil {
+			// Never leave behind a message its sender expected to disappear.
+			h.Pool.Exec(r.Context(), `DELETE FROM messages WHERE id = $1`, msgID)
+			apiutil.InternalError(w, h.Logger, "Failed to send message", err)
+			return
+		}
+		msg.Burn = &models.MessageBurn{AfterMinutes: *req.BurnAfterMinutes}
+	}
+
+	if cc.AutoThread && cc.GuildID != nil {
+		if err := h.openAutoThread(r.Context(), *cc.GuildID, &msg, !quarantined); err != nil {
+			h.Pool.Exec(r.Context(), `DELETE FROM messages WHERE id = $1`, msgID)
+			apiutil.InternalError(w, h.Logger, "Failed to create thread", err)
+			return
+		}
+	}
+
+	if adaptive != nil && !quarantined {
+		h.recordChannelMessage(r.Context(), *adaptive)
+	}
+
+	// Quarantined messages must not surface as channel activity to others.
+	if !quarantined {
+		// Update last_message_id on the channel.
+		h.Pool.Exec(r.Context(),
+			`UPDATE channels SET last_message_id = $1 WHERE id = $2`, msgID, channelID)
+
+		// Update last_activity_at and reply_count for thread channels (fire-and-forget).
+		h.Pool.Exec(r.Context(),
+			`UPDATE channels SET last_activity_at = now(), reply_count = reply_count + 1
+			 WHERE id = $1 AND parent_channel_id IS NOT NULL`,
+			channelID)
+	}
 
 	// Populate author user data for the response and event.
 	h.enrichMessageWithAuthor(r.Context(), &msg)
 
-	h.EventBus.Publish(r.Context(), events.SubjectMessageCreate, events.Event{
-		Type:      "MESSAGE_CREATE",
-		ChannelID: channelID,
-		Data:      mustMarshal(msg),
-	})
+	// A sample of sends is traced to measure delivery latency from here.
+	h.publishMessageEvent(slo.Trace(r.Context(), start), events.SubjectMessageCreate, "MESSAGE_CREATE", msg)
+
+	// Only the author's copy carries a status; recipients get the event.
+	if cc.ChannelType == "dm" || cc.ChannelType == "group" {
+		msg.Status = models.MessageStatusSent
+	}
 
 	apiutil.WriteJSON(w, http.StatusCreated, msg)
 }
@@ -890,24 +1304,40 @@ func (h *Handler) HandleGetMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: ViewChannel.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
+		return
+	}
+	if h.previewLocked(r.Context(), channelID, userID) {
+		writePreviewLocked(w)
 		return
 	}
 
 	msg, err := h.getMessage(r.Context(), channelID, messageID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+			apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get message")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get message")
+		return
+	}
+
+	if len(h.filterHidden(r.Context(), channelID, userID, []models.Message{*msg})) == 0 {
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 		return
 	}
 
 	msg.Attachments = h.loadAttachments(r.Context(), messageID)
 	msg.Embeds = h.loadEmbeds(r.Context(), messageID)
-
-	apiutil.WriteJSON(w, http.StatusOK, msg)
+	visible := []models.Message{*msg}
+	h.enrichMessagesWithTranslations(r.Context(), channelID, visible)
+	h.enrichMessagesWithBurns(r.Context(), visible)
+	h.enrichMessagesWithContentWarnings(r.Context(), visible)
+	h.enrichMessagesWithStatus(r.Context(), channelID, userID, visible)
+	h.redactForBots(r.Context(), channelID, userID, visible)
+	h.redactBlocked(r.Context(), userID, visible)
+
+	apiutil.WriteJSON(w, http.StatusOK, visible[0])
 }
 
 // HandleUpdateMessage edits a message's content. Only the author can edit.
@@ -922,26 +1352,73 @@ func (h *Handler) HandleUpdateMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Content == nil {
-		apiutil.WriteError(w, http.StatusBadRequest, "missing_content", "Content is required")
+	if req.Content == nil && req.ContentWarning == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "missing_content", "Content or content_warning is required")
 		return
 	}
+	var contentWarning string
+	if req.ContentWarning != nil {
+		var cwErr error
+		if contentWarning, cwErr = normalizeContentWarning(*req.ContentWarning); cwErr != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_content_warning", cwErr.Error())
+			return
+		}
+	}
 
 	// Verify ownership and get current content for edit history.
 	var authorID string
 	var currentContent *string
 	err := h.Pool.QueryRow(r.Context(),
-		`SELECT author_id, content FROM messages WHERE id = $1 AND channel_id = $2`,
-		messageID, channelID,
+		`SELECT author_id, content FROM messages WHERE id = $1 AND channel_id = $2 AND flags & $3 = 0`,
+		messageID, channelID, models.MessageFlagDeleted,
 	).Scan(&authorID, &currentContent)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 		return
 	}
 	if authorID != userID {
 		apiutil.WriteError(w, http.StatusForbidden, "not_author", "You can only edit your own messages")
 		return
 	}
+	// The guild's edit policy covers content edits; a content warning can
+	// always be added.
+	var editPolicy *messageEditPolicy
+	if req.Content != nil {
+		var ok bool
+		if editPolicy, ok = h.checkEditPolicy(w, r, messageID); !ok {
+			return
+		}
+	}
+	if req.Content != nil && *req.Content != "" {
+		if cc, err := h.loadChannelCtx(r.Context(), channelID, userID); err == nil && !cc.Encrypted {
+			if !h.applyInvitePolicy(w, cc, req.Content, false) {
+				return
+			}
+			h.normalizeEmojiShortcodes(r.Context(), cc.GuildID, channelID, userID, req.Content)
+		}
+	}
+
+	if req.ContentWarning != nil {
+		if err := h.setContentWarning(r.Context(), messageID, contentWarning); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to update content warning", err)
+			return
+		}
+	}
+	if req.Content == nil {
+		// Only the content warning changed; the content is not re-edited.
+		msg, err := h.getMessage(r.Context(), channelID, messageID)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to update message", err)
+			return
+		}
+		msg.Attachments = h.loadAttachments(r.Context(), messageID)
+		h.enrichMessageWithAuthor(r.Context(), msg)
+		updated := []models.Message{*msg}
+		h.enrichMessagesWithContentWarnings(r.Context(), updated)
+		h.publishMessageEvent(r.Context(), events.SubjectMessageUpdate, "MESSAGE_UPDATE", updated[0])
+		apiutil.WriteJSON(w, http.StatusOK, updated[0])
+		return
+	}
 
 	// Save previous content to edit history.
 	if currentContent != nil {
@@ -954,7 +1431,7 @@ func (h *Handler) HandleUpdateMessage(w http.ResponseWriter, r *http.Request) {
 	// Re-parse mentions from the edited content.
 	var editMentionUserIDs []string
 	var editMentionRoleIDs []string
-	var editMentionHere bool
+	var editMentionHere, editMentionEveryone bool
 
 	// Check if message is encrypted.
 	var encrypted bool
@@ -968,10 +1445,11 @@ func (h *Handler) HandleUpdateMessage(w http.ResponseWriter, r *http.Request) {
 		editMentionUserIDs = parsed.UserIDs
 		editMentionRoleIDs = parsed.RoleIDs
 		editMentionHere = parsed.MentionHere
+		editMentionEveryone = parsed.MentionEveryone
 
-		// Strip @here if in DMs.
+		// Strip @here and @everyone if in DMs.
 		if guildID == nil {
-			editMentionHere = false
+			editMentionHere, editMentionEveryone = false, false
 			editMentionRoleIDs = nil
 		}
 
@@ -982,14 +1460,17 @@ func (h *Handler) HandleUpdateMessage(w http.ResponseWriter, r *http.Request) {
 			if ccErr != nil {
 				h.Logger.Warn("failed to load channel context for edit mention validation", slog.String("error", ccErr.Error()))
 				// Fail closed: strip all special mentions.
-				editMentionHere = false
+				editMentionHere, editMentionEveryone = false, false
 				editMentionUserIDs = nil
 				editMentionRoleIDs = nil
 			} else {
-				// Validate @here permission.
+				// Validate @here and @everyone permissions.
 				if editMentionHere && !cc.hasPerm(permissions.MentionHere) {
 					editMentionHere = false
 				}
+				if editMentionEveryone && !cc.hasPerm(permissions.MentionEveryone) {
+					editMentionEveryone = false
+				}
 
 				// Validate user mentions: only actual guild members.
 				if len(editMentionUserIDs) > 0 {
@@ -1080,31 +1561,32 @@ func (h *Handler) HandleUpdateMessage(w http.ResponseWriter, r *http.Request) {
 	var msg models.Message
 	err = h.Pool.QueryRow(r.Context(),
 		`UPDATE messages SET content = $3, edited_at = now(),
-		        mention_user_ids = $4, mention_role_ids = $5, mention_here = $6
+		        mention_user_ids = $4, mention_role_ids = $5, mention_here = $6, mention_everyone = $7
 		 WHERE id = $1 AND channel_id = $2
 		 RETURNING id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
-		           reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
+		           reply_to_ids, mention_user_ids, mention_role_ids, mention_here, mention_everyone,
 		           thread_id, masquerade_name, masquerade_avatar, masquerade_color,
 		           encrypted, encryption_session_id, created_at`,
-		messageID, channelID, req.Content, editMentionUserIDs, editMentionRoleIDs, editMentionHere,
+		messageID, channelID, req.Content, editMentionUserIDs, editMentionRoleIDs, editMentionHere, editMentionEveryone,
 	).Scan(
 		&msg.ID, &msg.ChannelID, &msg.AuthorID, &msg.Content, &msg.Nonce, &msg.MessageType,
 		&msg.EditedAt, &msg.Flags, &msg.ReplyToIDs, &msg.MentionUserIDs, &msg.MentionRoleIDs,
-		&msg.MentionHere, &msg.ThreadID, &msg.MasqueradeName, &msg.MasqueradeAvatar,
+		&msg.MentionHere, &msg.MentionEveryone, &msg.ThreadID, &msg.MasqueradeName, &msg.MasqueradeAvatar,
 		&msg.MasqueradeColor, &msg.Encrypted, &msg.EncryptionSessionID, &msg.CreatedAt,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update message")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update message")
 		return
 	}
 
+	h.snapshotEdit(r.Context(), editPolicy, msg, currentContent)
+
 	h.enrichMessageWithAuthor(r.Context(), &msg)
+	updated := []models.Message{msg}
+	h.enrichMessagesWithContentWarnings(r.Context(), updated)
+	msg = updated[0]
 
-	h.EventBus.Publish(r.Context(), events.SubjectMessageUpdate, events.Event{
-		Type:      "MESSAGE_UPDATE",
-		ChannelID: channelID,
-		Data:      mustMarshal(msg),
-	})
+	h.publishMessageEvent(r.Context(), events.SubjectMessageUpdate, "MESSAGE_UPDATE", msg)
 
 	apiutil.WriteJSON(w, http.StatusOK, msg)
 }
@@ -1118,19 +1600,21 @@ func (h *Handler) HandleGetMessageEdits(w http.ResponseWriter, r *http.Request)
 
 	// Permission check: ReadHistory.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ReadHistory) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need READ_HISTORY permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need READ_HISTORY permission")
 		return
 	}
 
 	// Verify the message exists in this channel.
-	var exists bool
-	h.Pool.QueryRow(r.Context(),
-		`SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND channel_id = $2)`,
-		messageID, channelID).Scan(&exists)
-	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+	var authorID string
+	var mentioned []string
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT author_id, mention_user_ids FROM messages WHERE id = $1 AND channel_id = $2`,
+		messageID, channelID).Scan(&authorID, &mentioned); err != nil {
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 		return
 	}
+	hidden := authorID != userID && !slices.Contains(mentioned, userID) &&
+		h.botContentHidden(r.Context(), channelID, userID)
 
 	rows, err := h.Pool.Query(r.Context(),
 		`SELECT id, message_id, content, edited_at
@@ -1140,7 +1624,7 @@ func (h *Handler) HandleGetMessageEdits(w http.ResponseWriter, r *http.Request)
 		messageID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get edit history")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get edit history")
 		return
 	}
 	defer rows.Close()
@@ -1156,9 +1640,12 @@ func (h *Handler) HandleGetMessageEdits(w http.ResponseWriter, r *http.Request)
 	for rows.Next() {
 		var e editEntry
 		if err := rows.Scan(&e.ID, &e.MessageID, &e.Content, &e.EditedAt); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read edit history")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read edit history")
 			return
 		}
+		if hidden {
+			e.Content = ""
+		}
 		edits = append(edits, e)
 	}
 
@@ -1181,7 +1668,7 @@ func (h *Handler) HandleDeleteMessage(w http.ResponseWriter, r *http.Request) {
 			 LEFT JOIN guilds g ON g.id = c.guild_id
 			 WHERE c.id = $1`, channelID,
 		).Scan(&guildID, &instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up channel")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up channel")
 			return
 		}
 		if guildID != nil && h.FedProxy.ProxyToHomeInstance(w, r, *guildID, instanceID, "message_delete", userID, map[string]string{"message_id": messageID}) {
@@ -1191,35 +1678,52 @@ func (h *Handler) HandleDeleteMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Check authorship (permission-based deletion requires guild context, simplified here).
 	var authorID string
+	var flags int
 	err := h.Pool.QueryRow(r.Context(),
-		`SELECT author_id FROM messages WHERE id = $1 AND channel_id = $2`,
+		`SELECT author_id, flags FROM messages WHERE id = $1 AND channel_id = $2`,
 		messageID, channelID,
-	).Scan(&authorID)
+	).Scan(&authorID, &flags)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 		return
 	}
+	alreadyDeleted := flags&models.MessageFlagDeleted != 0
 
-	if authorID != userID {
-		// Non-authors need MANAGE_MESSAGES permission in the guild.
+	if authorID != userID || alreadyDeleted {
+		// Non-authors need MANAGE_MESSAGES permission in the guild. So does
+		// purging a soft-deleted message, which only moderators can see.
 		if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageMessages) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_MESSAGES permission to delete others' messages")
+			if alreadyDeleted {
+				apiutil.WriteCode(w, apierrors.MessageNotFound, "")
+				return
+			}
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission to delete others' messages")
 			return
 		}
 	}
 
-	_, err = h.Pool.Exec(r.Context(),
-		`DELETE FROM messages WHERE id = $1 AND channel_id = $2`, messageID, channelID)
+	if !alreadyDeleted && h.restoreWindowDays(r.Context(), channelID) > 0 {
+		_, err = h.Pool.Exec(r.Context(),
+			`UPDATE messages SET flags = flags | $3, deleted_at = now(), deleted_by = $4
+			 WHERE id = $1 AND channel_id = $2`,
+			messageID, channelID, models.MessageFlagDeleted, userID)
+	} else {
+		_, err = h.Pool.Exec(r.Context(),
+			`DELETE FROM messages WHERE id = $1 AND channel_id = $2`, messageID, channelID)
+	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete message")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete message")
 		return
 	}
 
-	// Decrement reply_count for thread channels (fire-and-forget).
-	h.Pool.Exec(r.Context(),
-		`UPDATE channels SET reply_count = GREATEST(reply_count - 1, 0)
-		 WHERE id = $1 AND parent_channel_id IS NOT NULL`,
-		channelID)
+	// Decrement reply_count for thread channels (fire-and-forget). A
+	// soft-deleted message was already uncounted when it was hidden.
+	if !alreadyDeleted {
+		h.Pool.Exec(r.Context(),
+			`UPDATE channels SET reply_count = GREATEST(reply_count - 1, 0)
+			 WHERE id = $1 AND parent_channel_id IS NOT NULL`,
+			channelID)
+	}
 
 	h.EventBus.Publish(r.Context(), events.SubjectMessageDelete, events.Event{
 		Type:      "MESSAGE_DELETE",
@@ -1237,7 +1741,7 @@ func (h *Handler) HandleBulkDeleteMessages(w http.ResponseWriter, r *http.Reques
 	channelID := chi.URLParam(r, "channelID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_MESSAGES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
 		return
 	}
 
@@ -1257,10 +1761,20 @@ func (h *Handler) HandleBulkDeleteMessages(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	tag, err := h.Pool.Exec(r.Context(),
-		`DELETE FROM messages WHERE channel_id = $1 AND id = ANY($2)`,
-		channelID, req.MessageIDs,
-	)
+	var tag pgconn.CommandTag
+	var err error
+	if h.restoreWindowDays(r.Context(), channelID) > 0 {
+		tag, err = h.Pool.Exec(r.Context(),
+			`UPDATE messages SET flags = flags | $3, deleted_at = now(), deleted_by = $4
+			 WHERE channel_id = $1 AND id = ANY($2) AND flags & $3 = 0`,
+			channelID, req.MessageIDs, models.MessageFlagDeleted, userID,
+		)
+	} else {
+		tag, err = h.Pool.Exec(r.Context(),
+			`DELETE FROM messages WHERE channel_id = $1 AND id = ANY($2)`,
+			channelID, req.MessageIDs,
+		)
+	}
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to delete messages", err)
 		return
@@ -1288,7 +1802,11 @@ func (h *Handler) HandleGetReactions(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: ViewChannel.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
+		return
+	}
+	if h.previewLocked(r.Context(), channelID, userID) {
+		writePreviewLocked(w)
 		return
 	}
 
@@ -1300,7 +1818,7 @@ func (h *Handler) HandleGetReactions(w http.ResponseWriter, r *http.Request) {
 		messageID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get reactions")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get reactions")
 		return
 	}
 	defer rows.Close()
@@ -1315,7 +1833,7 @@ func (h *Handler) HandleGetReactions(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var rg reactionGroup
 		if err := rows.Scan(&rg.Emoji, &rg.Count, &rg.Users); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read reactions")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read reactions")
 			return
 		}
 		reactions = append(reactions, rg)
@@ -1334,7 +1852,13 @@ func (h *Handler) HandleAddReaction(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: AddReactions.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.AddReactions) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need ADD_REACTIONS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need ADD_REACTIONS permission")
+		return
+	}
+	var guildID *string
+	h.Pool.QueryRow(r.Context(), `SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	if guildID != nil && apiutil.GuildRulesPending(r.Context(), h.Pool, *guildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "rules_not_accepted", "You must accept the guild rules before reacting")
 		return
 	}
 
@@ -1345,7 +1869,7 @@ func (h *Handler) HandleAddReaction(w http.ResponseWriter, r *http.Request) {
 		messageID, channelID,
 	).Scan(&exists)
 	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 		return
 	}
 
@@ -1356,7 +1880,7 @@ func (h *Handler) HandleAddReaction(w http.ResponseWriter, r *http.Request) {
 		messageID, userID, emoji,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to add reaction")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to add reaction")
 		return
 	}
 
@@ -1380,7 +1904,7 @@ func (h *Handler) HandleRemoveReaction(w http.ResponseWriter, r *http.Request) {
 		messageID, userID, emoji,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to remove reaction")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to remove reaction")
 		return
 	}
 
@@ -1402,7 +1926,7 @@ func (h *Handler) HandleRemoveUserReaction(w http.ResponseWriter, r *http.Reques
 
 	// Permission check: ManageMessages required to remove others' reactions.
 	if !h.hasChannelPermission(r.Context(), channelID, actorID, permissions.ManageMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_MESSAGES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
 		return
 	}
 
@@ -1411,7 +1935,7 @@ func (h *Handler) HandleRemoveUserReaction(w http.ResponseWriter, r *http.Reques
 		messageID, targetUserID, emoji,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to remove reaction")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to remove reaction")
 		return
 	}
 	if result.RowsAffected() == 0 {
@@ -1434,7 +1958,11 @@ func (h *Handler) HandleGetPins(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: ViewChannel.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
+		return
+	}
+	if h.previewLocked(r.Context(), channelID, userID) {
+		writePreviewLocked(w)
 		return
 	}
 
@@ -1450,7 +1978,7 @@ func (h *Handler) HandleGetPins(w http.ResponseWriter, r *http.Request) {
 		channelID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get pins")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get pins")
 		return
 	}
 	defer rows.Close()
@@ -1464,11 +1992,14 @@ func (h *Handler) HandleGetPins(w http.ResponseWriter, r *http.Request) {
 			&m.MentionHere, &m.ThreadID, &m.MasqueradeName, &m.MasqueradeAvatar,
 			&m.MasqueradeColor, &m.Encrypted, &m.EncryptionSessionID, &m.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read pins")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read pins")
 			return
 		}
 		messages = append(messages, m)
 	}
+	messages = h.filterHidden(r.Context(), channelID, userID, messages)
+	h.redactForBots(r.Context(), channelID, userID, messages)
+	h.redactBlocked(r.Context(), userID, messages)
 
 	apiutil.WriteJSON(w, http.StatusOK, messages)
 }
@@ -1489,7 +2020,7 @@ func (h *Handler) HandlePinMessage(w http.ResponseWriter, r *http.Request) {
 			 LEFT JOIN guilds g ON g.id = c.guild_id
 			 WHERE c.id = $1`, channelID,
 		).Scan(&guildID, &instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up channel")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up channel")
 			return
 		}
 		if guildID != nil && h.FedProxy.ProxyToHomeInstance(w, r, *guildID, instanceID, "message_pin", userID, map[string]string{"message_id": messageID}) {
@@ -1498,18 +2029,18 @@ func (h *Handler) HandlePinMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_MESSAGES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
 		return
 	}
 
-	// Verify message exists.
+	// Verify message exists and is not waiting out a restore window.
 	var exists bool
 	h.Pool.QueryRow(r.Context(),
-		`SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND channel_id = $2)`,
-		messageID, channelID,
+		`SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND channel_id = $2 AND flags & $3 = 0)`,
+		messageID, channelID, models.MessageFlagDeleted,
 	).Scan(&exists)
 	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 		return
 	}
 
@@ -1529,7 +2060,7 @@ func (h *Handler) HandlePinMessage(w http.ResponseWriter, r *http.Request) {
 		channelID, messageID, userID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to pin message")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to pin message")
 		return
 	}
 
@@ -1556,7 +2087,7 @@ func (h *Handler) HandleUnpinMessage(w http.ResponseWriter, r *http.Request) {
 			 LEFT JOIN guilds g ON g.id = c.guild_id
 			 WHERE c.id = $1`, channelID,
 		).Scan(&guildID, &instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up channel")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up channel")
 			return
 		}
 		if guildID != nil && h.FedProxy.ProxyToHomeInstance(w, r, *guildID, instanceID, "message_unpin", userID, map[string]string{"message_id": messageID}) {
@@ -1566,14 +2097,14 @@ func (h *Handler) HandleUnpinMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: ManageMessages.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_MESSAGES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
 		return
 	}
 
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM pins WHERE channel_id = $1 AND message_id = $2`, channelID, messageID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to unpin message")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to unpin message")
 		return
 	}
 	if tag.RowsAffected() == 0 {
@@ -1596,7 +2127,7 @@ func (h *Handler) HandleTriggerTyping(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: SendMessages (typing implies intent to send).
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.SendMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need SEND_MESSAGES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need SEND_MESSAGES permission")
 		return
 	}
 
@@ -1616,7 +2147,7 @@ func (h *Handler) HandleAckChannel(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: ViewChannel.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
 		return
 	}
 
@@ -1638,6 +2169,11 @@ func (h *Handler) HandleAckChannel(w http.ResponseWriter, r *http.Request) {
 	h.EventBus.PublishUserEvent(r.Context(), events.SubjectChannelAck, "CHANNEL_ACK", userID, map[string]string{
 		"channel_id": channelID, "user_id": userID,
 	})
+	if lastMessageID != nil {
+		if err := apiutil.PublishDMRead(r.Context(), h.Pool, h.EventBus, channelID, userID, *lastMessageID); err != nil {
+			h.Logger.Warn("failed to publish DM read receipt", slog.String("error", err.Error()))
+		}
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -1651,7 +2187,7 @@ func (h *Handler) HandleSetChannelPermission(w http.ResponseWriter, r *http.Requ
 
 	// Permission check: ManageChannels.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -1673,7 +2209,7 @@ func (h *Handler) HandleSetChannelPermission(w http.ResponseWriter, r *http.Requ
 		channelID, req.TargetType, overrideID, req.PermissionsAllow, req.PermissionsDeny,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to set permission override")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to set permission override")
 		return
 	}
 
@@ -1699,7 +2235,7 @@ func (h *Handler) HandleDeleteChannelPermission(w http.ResponseWriter, r *http.R
 
 	// Permission check: ManageChannels.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -1708,7 +2244,7 @@ func (h *Handler) HandleDeleteChannelPermission(w http.ResponseWriter, r *http.R
 		channelID, overrideID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete permission override")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete permission override")
 		return
 	}
 
@@ -1724,7 +2260,7 @@ func (h *Handler) HandleCreateThread(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: SendMessages (thread creation requires ability to send).
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.SendMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need SEND_MESSAGES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need SEND_MESSAGES permission")
 		return
 	}
 
@@ -1739,13 +2275,17 @@ func (h *Handler) HandleCreateThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify the parent message exists.
-	var exists bool
-	h.Pool.QueryRow(r.Context(),
-		`SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND channel_id = $2)`,
-		messageID, channelID).Scan(&exists)
-	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+	// Verify the parent message exists and has no thread yet; messages in
+	// auto-thread channels get theirs when posted.
+	var existingThread *string
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT thread_id FROM messages WHERE id = $1 AND channel_id = $2 AND flags & $3 = 0`,
+		messageID, channelID, models.MessageFlagDeleted).Scan(&existingThread); err != nil {
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
+		return
+	}
+	if existingThread != nil {
+		apiutil.WriteError(w, http.StatusConflict, "thread_exists", "This message already has a thread")
 		return
 	}
 
@@ -1757,7 +2297,7 @@ func (h *Handler) HandleCreateThread(w http.ResponseWriter, r *http.Request) {
 		`SELECT guild_id, default_auto_archive_duration, encrypted FROM channels WHERE id = $1`, channelID,
 	).Scan(&guildID, &parentAutoArchive, &parentEncrypted); err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to query channel", err)
@@ -1838,7 +2378,11 @@ func (h *Handler) HandleGetThreads(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: ViewChannel.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
+		return
+	}
+	if h.previewLocked(r.Context(), channelID, userID) {
+		writePreviewLocked(w)
 		return
 	}
 
@@ -1847,7 +2391,7 @@ func (h *Handler) HandleGetThreads(w http.ResponseWriter, r *http.Request) {
 	h.Pool.QueryRow(r.Context(),
 		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
 	if guildID == nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found or is not a guild channel")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "Channel not found or is not a guild channel")
 		return
 	}
 
@@ -1876,7 +2420,7 @@ func (h *Handler) HandleGetThreads(w http.ResponseWriter, r *http.Request) {
 		channelID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get threads")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get threads")
 		return
 	}
 	defer rows.Close()
@@ -1892,7 +2436,7 @@ func (h *Handler) HandleGetThreads(w http.ResponseWriter, r *http.Request) {
 			&c.Archived, &c.ReadOnly, &c.ReadOnlyRoleIDs,
 			&c.DefaultAutoArchiveDuration, &c.ParentChannelID, &c.LastActivityAt, &c.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read threads")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read threads")
 			return
 		}
 		threads = append(threads, c)
@@ -1909,7 +2453,7 @@ func (h *Handler) HandleHideThread(w http.ResponseWriter, r *http.Request) {
 	threadID := chi.URLParam(r, "threadID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
 		return
 	}
 
@@ -1929,7 +2473,7 @@ func (h *Handler) HandleHideThread(w http.ResponseWriter, r *http.Request) {
 		userID, threadID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to hide thread")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to hide thread")
 		return
 	}
 
@@ -1944,7 +2488,7 @@ func (h *Handler) HandleUnhideThread(w http.ResponseWriter, r *http.Request) {
 	threadID := chi.URLParam(r, "threadID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
 		return
 	}
 
@@ -1962,7 +2506,7 @@ func (h *Handler) HandleUnhideThread(w http.ResponseWriter, r *http.Request) {
 		userID, threadID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to unhide thread")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to unhide thread")
 		return
 	}
 
@@ -1979,7 +2523,7 @@ func (h *Handler) HandleGetHiddenThreads(w http.ResponseWriter, r *http.Request)
 		userID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get hidden threads")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get hidden threads")
 		return
 	}
 	defer rows.Close()
@@ -1988,7 +2532,7 @@ func (h *Handler) HandleGetHiddenThreads(w http.ResponseWriter, r *http.Request)
 	for rows.Next() {
 		var id string
 		if err := rows.Scan(&id); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read hidden threads")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read hidden threads")
 			return
 		}
 		ids = append(ids, id)
@@ -2007,7 +2551,7 @@ func (h *Handler) HandleScheduleMessage(w http.ResponseWriter, r *http.Request)
 
 	// Permission check: SendMessages.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.SendMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need SEND_MESSAGES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need SEND_MESSAGES permission")
 		return
 	}
 
@@ -2066,7 +2610,7 @@ func (h *Handler) HandleGetScheduledMessages(w http.ResponseWriter, r *http.Requ
 
 	// Permission check: ViewChannel.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
 		return
 	}
 
@@ -2116,7 +2660,7 @@ func (h *Handler) HandleDeleteScheduledMessage(w http.ResponseWriter, r *http.Re
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Scheduled message not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Scheduled message not found")
 		return
 	}
 
@@ -2133,7 +2677,7 @@ func (h *Handler) HandleGetChannelWebhooks(w http.ResponseWriter, r *http.Reques
 
 	// Permission check: ManageWebhooks.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageWebhooks) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_WEBHOOKS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
 		return
 	}
 
@@ -2145,7 +2689,7 @@ func (h *Handler) HandleGetChannelWebhooks(w http.ResponseWriter, r *http.Reques
 		channelID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get webhooks")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get webhooks")
 		return
 	}
 	defer rows.Close()
@@ -2157,7 +2701,7 @@ func (h *Handler) HandleGetChannelWebhooks(w http.ResponseWriter, r *http.Reques
 			&wh.ID, &wh.GuildID, &wh.ChannelID, &wh.CreatorID, &wh.Name,
 			&wh.AvatarID, &wh.Token, &wh.WebhookType, &wh.OutgoingURL, &wh.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read webhooks")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read webhooks")
 			return
 		}
 		webhooks = append(webhooks, wh)
@@ -2166,6 +2710,20 @@ func (h *Handler) HandleGetChannelWebhooks(w http.ResponseWriter, r *http.Reques
 	apiutil.WriteJSON(w, http.StatusOK, webhooks)
 }
 
+// inheritFlag resolves the new value of a channel's *_inherited flag: an
+// explicit inherit request wins, setting the value itself turns inheritance
+// off, and otherwise the flag is left alone (nil).
+func inheritFlag(inherit *bool, valueSet bool) *bool {
+	if inherit != nil {
+		return inherit
+	}
+	if valueSet {
+		off := false
+		return &off
+	}
+	return nil
+}
+
 func (h *Handler) getChannel(ctx context.Context, channelID string) (*models.Channel, error) {
 	var c models.Channel
 	err := h.Pool.QueryRow(ctx,
@@ -2173,7 +2731,10 @@ func (h *Handler) getChannel(ctx context.Context, channelID string) (*models.Cha
 		        slowmode_seconds, nsfw, encrypted, last_message_id, owner_id,
 		        default_permissions, user_limit, bitrate, locked, locked_by, locked_at,
 		        archived, read_only, read_only_role_ids, default_auto_archive_duration,
-		        parent_channel_id, last_activity_at, created_at
+		        parent_channel_id, last_activity_at, auto_thread, disallow_bot_posts, disallow_webhook_posts,
+		        invite_links_exempt, voice_channel_id, voice_participant_allow,
+		        voice_chat_recent_minutes, voice_chat_history, preview_enabled, preview_role_id, public_view, language,
+		        nsfw_inherited, slowmode_inherited, notification_level, notification_level_inherited, version, created_at
 		 FROM channels WHERE id = $1`,
 		channelID,
 	).Scan(
@@ -2182,7 +2743,10 @@ func (h *Handler) getChannel(ctx context.Context, channelID string) (*models.Cha
 		&c.OwnerID, &c.DefaultPermissions, &c.UserLimit, &c.Bitrate,
 		&c.Locked, &c.LockedBy, &c.LockedAt,
 		&c.Archived, &c.ReadOnly, &c.ReadOnlyRoleIDs,
-		&c.DefaultAutoArchiveDuration, &c.ParentChannelID, &c.LastActivityAt, &c.CreatedAt,
+		&c.DefaultAutoArchiveDuration, &c.ParentChannelID, &c.LastActivityAt, &c.AutoThread,
+		&c.DisallowBotPosts, &c.DisallowWebhookPosts, &c.InviteLinksExempt, &c.VoiceChannelID, &c.VoiceParticipantAllow,
+		&c.VoiceChatRecentMinutes, &c.VoiceChatHistory, &c.PreviewEnabled, &c.PreviewRoleID, &c.PublicView, &c.Language,
+		&c.NSFWInherited, &c.SlowmodeInherited, &c.NotificationLevel, &c.NotificationLevelInherited, &c.Version, &c.CreatedAt,
 	)
 	return &c, err
 }
@@ -2193,14 +2757,14 @@ func (h *Handler) getMessage(ctx context.Context, channelID, messageID string) (
 		`SELECT id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
 		        reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
 		        thread_id, masquerade_name, masquerade_avatar, masquerade_color,
-		        encrypted, encryption_session_id, created_at
+		        encrypted, encryption_session_id, repeat_count, created_at
 		 FROM messages WHERE id = $1 AND channel_id = $2`,
 		messageID, channelID,
 	).Scan(
 		&m.ID, &m.ChannelID, &m.AuthorID, &m.Content, &m.Nonce, &m.MessageType,
 		&m.EditedAt, &m.Flags, &m.ReplyToIDs, &m.MentionUserIDs, &m.MentionRoleIDs,
 		&m.MentionHere, &m.ThreadID, &m.MasqueradeName, &m.MasqueradeAvatar,
-		&m.MasqueradeColor, &m.Encrypted, &m.EncryptionSessionID, &m.CreatedAt,
+		&m.MasqueradeColor, &m.Encrypted, &m.EncryptionSessionID, &m.RepeatCount, &m.CreatedAt,
 	)
 	return &m, err
 }
@@ -2208,7 +2772,7 @@ func (h *Handler) getMessage(ctx context.Context, channelID, messageID string) (
 func (h *Handler) loadAttachments(ctx context.Context, messageID string) []models.Attachment {
 	rows, err := h.Pool.Query(ctx,
 		`SELECT id, message_id, uploader_id, filename, content_type, size_bytes,
-		        width, height, duration_seconds, s3_bucket, s3_key, blurhash, alt_text, created_at
+		        width, height, duration_seconds, s3_bucket, s3_key, blurhash, thumbhash, variants, alt_text, spoiler, created_at
 		 FROM attachments WHERE message_id = $1
 		 ORDER BY created_at`,
 		messageID,
@@ -2223,7 +2787,7 @@ func (h *Handler) loadAttachments(ctx context.Context, messageID string) []model
 		var a models.Attachment
 		if err := rows.Scan(
 			&a.ID, &a.MessageID, &a.UploaderID, &a.Filename, &a.ContentType, &a.SizeBytes,
-			&a.Width, &a.Height, &a.DurationSeconds, &a.S3Bucket, &a.S3Key, &a.Blurhash, &a.AltText, &a.CreatedAt,
+			&a.Width, &a.Height, &a.DurationSeconds, &a.S3Bucket, &a.S3Key, &a.Blurhash, &a.Thumbhash, &a.Variants, &a.AltText, &a.Spoiler, &a.CreatedAt,
 		); err != nil {
 			return nil
 		}
@@ -2332,7 +2896,7 @@ func (h *Handler) enrichMessagesWithAttachments(ctx context.Context, messages []
 
 	rows, err := h.Pool.Query(ctx,
 		`SELECT id, message_id, uploader_id, filename, content_type, size_bytes,
-		        width, height, duration_seconds, s3_bucket, s3_key, blurhash, alt_text, created_at
+		        width, height, duration_seconds, s3_bucket, s3_key, blurhash, thumbhash, variants, alt_text, spoiler, created_at
 		 FROM attachments WHERE message_id = ANY($1)
 		 ORDER BY created_at`, msgIDs)
 	if err != nil {
@@ -2345,7 +2909,7 @@ func (h *Handler) enrichMessagesWithAttachments(ctx context.Context, messages []
 		var a models.Attachment
 		if err := rows.Scan(
 			&a.ID, &a.MessageID, &a.UploaderID, &a.Filename, &a.ContentType, &a.SizeBytes,
-			&a.Width, &a.Height, &a.DurationSeconds, &a.S3Bucket, &a.S3Key, &a.Blurhash, &a.AltText, &a.CreatedAt,
+			&a.Width, &a.Height, &a.DurationSeconds, &a.S3Bucket, &a.S3Key, &a.Blurhash, &a.Thumbhash, &a.Variants, &a.AltText, &a.Spoiler, &a.CreatedAt,
 		); err != nil {
 			continue
 		}
@@ -2439,7 +3003,7 @@ func (h *Handler) HandleCrosspostMessage(w http.ResponseWriter, r *http.Request)
 		TargetChannelID string `json:"target_channel_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TargetChannelID == "" {
-		apiutil.WriteError(w, http.StatusBadRequest, "invalid_body", "target_channel_id is required")
+		apiutil.WriteCode(w, apierrors.InvalidBody, "target_channel_id is required")
 		return
 	}
 
@@ -2450,7 +3014,7 @@ func (h *Handler) HandleCrosspostMessage(w http.ResponseWriter, r *http.Request)
 
 	// Check permission in target channel's guild.
 	if !h.hasChannelPermission(r.Context(), req.TargetChannelID, userID, permissions.SendMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need SEND_MESSAGES permission in the target channel")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need SEND_MESSAGES permission in the target channel")
 		return
 	}
 
@@ -2458,11 +3022,11 @@ func (h *Handler) HandleCrosspostMessage(w http.ResponseWriter, r *http.Request)
 	var content *string
 	var authorID string
 	err := h.Pool.QueryRow(r.Context(),
-		`SELECT author_id, content FROM messages WHERE id = $1 AND channel_id = $2`,
-		messageID, sourceChannelID,
+		`SELECT author_id, content FROM messages WHERE id = $1 AND channel_id = $2 AND flags & $3 = 0`,
+		messageID, sourceChannelID, models.MessageFlagDeleted|models.MessageFlagQuarantined,
 	).Scan(&authorID, &content)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Source message not found")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "Source message not found")
 		return
 	}
 
@@ -2507,7 +3071,7 @@ func (h *Handler) HandleFollowChannel(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: ManageWebhooks in the source channel's guild.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageWebhooks) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_WEBHOOKS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
 		return
 	}
 
@@ -2517,7 +3081,7 @@ func (h *Handler) HandleFollowChannel(w http.ResponseWriter, r *http.Request) {
 		`SELECT channel_type FROM channels WHERE id = $1`, channelID,
 	).Scan(&channelType)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if channelType != models.ChannelTypeAnnouncement {
@@ -2533,7 +3097,7 @@ func (h *Handler) HandleFollowChannel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if req.WebhookID == "" || req.GuildID == "" {
-		apiutil.WriteError(w, http.StatusBadRequest, "missing_fields", "webhook_id and guild_id are required")
+		apiutil.WriteCode(w, apierrors.MissingFields, "webhook_id and guild_id are required")
 		return
 	}
 
@@ -2572,7 +3136,7 @@ func (h *Handler) HandleGetChannelFollowers(w http.ResponseWriter, r *http.Reque
 
 	// Permission check: ManageWebhooks in the source channel's guild.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageWebhooks) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_WEBHOOKS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
 		return
 	}
 
@@ -2582,7 +3146,7 @@ func (h *Handler) HandleGetChannelFollowers(w http.ResponseWriter, r *http.Reque
 		`SELECT channel_type FROM channels WHERE id = $1`, channelID,
 	).Scan(&channelType)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if channelType != models.ChannelTypeAnnouncement {
@@ -2624,7 +3188,7 @@ func (h *Handler) HandleUnfollowChannel(w http.ResponseWriter, r *http.Request)
 
 	// Permission check: ManageWebhooks in the source channel's guild.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageWebhooks) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_WEBHOOKS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
 		return
 	}
 
@@ -2654,7 +3218,7 @@ func (h *Handler) HandlePublishMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Permission check: SendMessages in the announcement channel.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.SendMessages) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need SEND_MESSAGES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need SEND_MESSAGES permission")
 		return
 	}
 
@@ -2664,7 +3228,7 @@ func (h *Handler) HandlePublishMessage(w http.ResponseWriter, r *http.Request) {
 		`SELECT channel_type FROM channels WHERE id = $1`, channelID,
 	).Scan(&channelType)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if channelType != models.ChannelTypeAnnouncement {
@@ -2680,8 +3244,8 @@ func (h *Handler) HandlePublishMessage(w http.ResponseWriter, r *http.Request) {
 		`SELECT author_id, content, flags FROM messages WHERE id = $1 AND channel_id = $2`,
 		messageID, channelID,
 	).Scan(&authorID, &content, &flags)
-	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+	if err != nil || flags&(models.MessageFlagDeleted|models.MessageFlagQuarantined) != 0 {
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 		return
 	}
 
@@ -2779,7 +3343,7 @@ func (h *Handler) HandleCreateChannelTemplate(w http.ResponseWriter, r *http.Req
 
 	// Permission check: ManageChannels required to create templates.
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -2851,7 +3415,7 @@ func (h *Handler) HandleGetChannelTemplates(w http.ResponseWriter, r *http.Reque
 		guildID, userID,
 	).Scan(&isMember)
 	if !isMember {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -2862,7 +3426,7 @@ func (h *Handler) HandleGetChannelTemplates(w http.ResponseWriter, r *http.Reque
 		guildID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get templates")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get templates")
 		return
 	}
 	defer rows.Close()
@@ -2892,7 +3456,7 @@ func (h *Handler) HandleDeleteChannelTemplate(w http.ResponseWriter, r *http.Req
 	templateID := chi.URLParam(r, "templateID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -2901,7 +3465,7 @@ func (h *Handler) HandleDeleteChannelTemplate(w http.ResponseWriter, r *http.Req
 		templateID, guildID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete template")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete template")
 		return
 	}
 	if tag.RowsAffected() == 0 {
@@ -2920,7 +3484,7 @@ func (h *Handler) HandleApplyChannelTemplate(w http.ResponseWriter, r *http.Requ
 	templateID := chi.URLParam(r, "templateID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -2953,7 +3517,7 @@ func (h *Handler) HandleApplyChannelTemplate(w http.ResponseWriter, r *http.Requ
 			apiutil.WriteError(w, http.StatusNotFound, "template_not_found", "Template not found")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get template")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get template")
 		return
 	}
 
@@ -3010,7 +3574,7 @@ func (h *Handler) HandleApplyChannelTemplate(w http.ResponseWriter, r *http.Requ
 		return nil
 	})
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to create channel")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to create channel")
 		return
 	}
 
@@ -3042,9 +3606,13 @@ func (h *Handler) hasGuildPermission(ctx context.Context, guildID, userID string
 		return true
 	}
 
-	// Default permissions + role permissions.
+	// Default permissions + role permissions, capped for installed apps.
 	var defaultPerms int64
-	h.Pool.QueryRow(ctx, `SELECT default_permissions FROM guilds WHERE id = $1`, guildID).Scan(&defaultPerms)
+	var appCap *int64
+	h.Pool.QueryRow(ctx,
+		`SELECT g.default_permissions, bi.permissions
+		 FROM guilds g LEFT JOIN bot_installs bi ON bi.guild_id = g.id AND bi.bot_id = $2
+		 WHERE g.id = $1`, guildID, userID).Scan(&defaultPerms, &appCap)
 	computed := uint64(defaultPerms)
 
 	rows, _ := h.Pool.Query(ctx,
@@ -3064,6 +3632,9 @@ func (h *Handler) hasGuildPermission(ctx context.Context, guildID, userID string
 			computed &^= uint64(deny)
 		}
 	}
+	if appCap != nil {
+		computed &= uint64(*appCap)
+	}
 
 	if computed&permissions.Administrator != 0 {
 		return true
@@ -3110,9 +3681,13 @@ func (h *Handler) hasChannelPermission(ctx context.Context, channelID, userID st
 		return true
 	}
 
-	// Default permissions + role permissions.
+	// Default permissions + role permissions, capped for installed apps.
 	var defaultPerms int64
-	h.Pool.QueryRow(ctx, `SELECT default_permissions FROM guilds WHERE id = $1`, *guildID).Scan(&defaultPerms)
+	var appCap *int64
+	h.Pool.QueryRow(ctx,
+		`SELECT g.default_permissions, bi.permissions
+		 FROM guilds g LEFT JOIN bot_installs bi ON bi.guild_id = g.id AND bi.bot_id = $2
+		 WHERE g.id = $1`, *guildID, userID).Scan(&defaultPerms, &appCap)
 	computed := uint64(defaultPerms)
 
 	rows, _ := h.Pool.Query(ctx,
@@ -3132,6 +3707,9 @@ func (h *Handler) hasChannelPermission(ctx context.Context, channelID, userID st
 			computed &^= uint64(deny)
 		}
 	}
+	if appCap != nil {
+		computed &= uint64(*appCap)
+	}
 
 	if computed&permissions.Administrator != 0 {
 		return true
@@ -3157,6 +3735,94 @@ type channelCtx struct {
 	IsAdmin          bool
 	IsDMRecipient    bool
 	TimeoutUntil     *time.Time
+	AutoThread       bool
+	DisallowBotPosts bool
+	AdaptiveSlowmode bool
+	AppCap           *int64 // install grant when the user is an app
+	DuplicateMode    string
+	DuplicateWindow  int // seconds
+	InvitePolicy     string
+	InviteExempt     bool
+	VoiceChannelID   *string
+	VoiceAllow       int64
+	InVoice          bool // connected to the channel's voice room
+	Preview          bool // listed locked to members who cannot view it
+}
+
+// canPostReadOnly reports whether the user may post in the channel given its
+// read-only setting. Owners, admins, Administrator role holders and members
+// with one of the channel's read-only roles bypass it.
+func (h *Handler) canPostReadOnly(ctx context.Context, cc *channelCtx, userID string) bool {
+	if !cc.ReadOnly || cc.IsOwner || cc.IsAdmin || cc.hasPerm(permissions.Administrator) {
+		return true
+	}
+	if len(cc.ReadOnlyRoleIDs) == 0 || cc.GuildID == nil {
+		return false
+	}
+	var matchCount int
+	h.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM member_roles
+		 WHERE guild_id = $1 AND user_id = $2 AND role_id = ANY($3)`,
+		*cc.GuildID, userID, cc.ReadOnlyRoleIDs,
+	).Scan(&matchCount)
+	return matchCount > 0
+}
+
+// boostBitrateLimit returns the highest voice bitrate the channel's guild may
+// use at its boost tier, or nil when the tier sets no limit.
+func (h *Handler) boostBitrateLimit(ctx context.Context, channelID string) *int {
+	var guildID *string
+	h.Pool.QueryRow(ctx, `SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	if guildID == nil {
+		return nil
+	}
+	perks, err := apiutil.GuildBoostPerks(ctx, h.Pool, *guildID)
+	if err != nil {
+		return nil
+	}
+	return perks.MaxBitrate
+}
+
+// oversizedAttachment returns the guild's upload limit if any of the user's
+// attachments is larger than its boost tier allows, and 0 otherwise.
+func (h *Handler) oversizedAttachment(ctx context.Context, guildID, userID string, attachmentIDs []string) int64 {
+	perks, err := apiutil.GuildBoostPerks(ctx, h.Pool, guildID)
+	if err != nil || perks.MaxUploadBytes == nil {
+		return 0
+	}
+	var oversized bool
+	h.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM attachments
+		               WHERE id = ANY($1) AND uploader_id = $2 AND size_bytes > $3)`,
+		attachmentIDs, userID, *perks.MaxUploadBytes).Scan(&oversized)
+	if !oversized {
+		return 0
+	}
+	return *perks.MaxUploadBytes
+}
+
+// applyInvitePolicy applies the guild's invite link policy to content in
+// place. It returns false if the message was refused and a response has been
+// written. Content left empty by stripping is refused unless the message has
+// attachments to stand on.
+func (h *Handler) applyInvitePolicy(w http.ResponseWriter, cc *channelCtx, content *string, hasAttachments bool) bool {
+	if cc.GuildID == nil {
+		return true
+	}
+	stripped, refuse := apiutil.ApplyInvitePolicy(cc.InvitePolicy, cc.InviteExempt,
+		cc.hasPerm(permissions.ManageGuild), *content)
+	if refuse {
+		apiutil.WriteError(w, http.StatusForbidden, "invite_links_not_allowed",
+			"You need MANAGE_GUILD permission to post invite links in this guild")
+		return false
+	}
+	if stripped == "" && !hasAttachments {
+		apiutil.WriteError(w, http.StatusBadRequest, "invite_links_not_allowed",
+			"Invite links are removed in this guild and the message has nothing else")
+		return false
+	}
+	*content = stripped
+	return true
 }
 
 // loadChannelCtx fetches all channel state, guild ownership, and user
@@ -3170,17 +3836,28 @@ func (h *Handler) loadChannelCtx(ctx context.Context, channelID, userID string)
 		`SELECT c.guild_id, c.channel_type, c.locked, c.archived, c.read_only,
 		        c.read_only_role_ids, c.encrypted, COALESCE(c.slowmode_seconds, 0),
 		        COALESCE(g.owner_id, ''), COALESCE(g.default_permissions, 0),
-		        COALESCE(u.flags, 0), gm.timeout_until
+		        COALESCE(u.flags, 0), gm.timeout_until, c.auto_thread, c.disallow_bot_posts,
+		        COALESCE(sa.enabled, false), bi.permissions,
+		        COALESCE(dp.mode, 'off'), COALESCE(dp.window_seconds, 0),
+		        COALESCE(g.invite_link_policy, 'allow'), c.invite_links_exempt,
+		        c.voice_channel_id, c.voice_participant_allow, c.preview_enabled
 		 FROM channels c
 		 LEFT JOIN guilds g ON g.id = c.guild_id
 		 LEFT JOIN users u ON u.id = $2
 		 LEFT JOIN guild_members gm ON gm.guild_id = c.guild_id AND gm.user_id = $2
+		 LEFT JOIN bot_installs bi ON bi.guild_id = c.guild_id AND bi.bot_id = $2
+		 LEFT JOIN channel_adaptive_slowmode sa ON sa.channel_id = c.id
+		 LEFT JOIN channel_duplicate_policy dp ON dp.channel_id = c.id
 		 WHERE c.id = $1`,
 		channelID, userID,
 	).Scan(
 		&c.GuildID, &c.ChannelType, &c.Locked, &c.Archived, &c.ReadOnly,
 		&c.ReadOnlyRoleIDs, &c.Encrypted, &c.SlowmodeSeconds,
-		&c.OwnerID, &c.ComputedPerms, &c.UserFlags, &c.TimeoutUntil,
+		&c.OwnerID, &c.ComputedPerms, &c.UserFlags, &c.TimeoutUntil, &c.AutoThread, &c.DisallowBotPosts,
+		&c.AdaptiveSlowmode, &c.AppCap,
+		&c.DuplicateMode, &c.DuplicateWindow,
+		&c.InvitePolicy, &c.InviteExempt,
+		&c.VoiceChannelID, &c.VoiceAllow, &c.Preview,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("loading channel context: %w", err)
@@ -3188,6 +3865,10 @@ func (h *Handler) loadChannelCtx(ctx context.Context, channelID, userID string)
 
 	c.IsOwner = c.GuildID != nil && userID == c.OwnerID
 	c.IsAdmin = c.UserFlags&models.UserFlagAdmin != 0
+	if isVoiceRoom(c.ChannelType) {
+		c.VoiceChannelID = &channelID
+	}
+	c.InVoice = h.Voice != nil && c.VoiceChannelID != nil && h.Voice.InVoiceChannel(userID, *c.VoiceChannelID)
 
 	// Short-circuit: owners and admins have all permissions.
 	if c.IsOwner || c.IsAdmin {
@@ -3229,12 +3910,24 @@ func (h *Handler) loadChannelCtx(ctx context.Context, channelID, userID string)
 			c.ComputedPerms &^= uint64(deny)
 		}
 	}
+	if c.AppCap != nil {
+		c.ComputedPerms &= uint64(*c.AppCap)
+	}
+	// Members connected to the voice room get its participant grant.
+	if c.InVoice {
+		c.ComputedPerms |= uint64(c.VoiceAllow)
+	}
 
 	// Administrator bit grants all permissions.
 	if c.ComputedPerms&permissions.Administrator != 0 {
 		c.ComputedPerms = ^uint64(0)
 	}
 
+	// A preview channel grants nothing to members it is locked for.
+	if c.Preview && c.ComputedPerms != ^uint64(0) && h.previewLocked(ctx, channelID, userID) {
+		c.ComputedPerms = 0
+	}
+
 	return c, nil
 }
 
@@ -3256,7 +3949,7 @@ func (h *Handler) HandleBatchDecryptMessages(w http.ResponseWriter, r *http.Requ
 	channelID := chi.URLParam(r, "channelID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -3304,7 +3997,8 @@ func (h *Handler) HandleBatchDecryptMessages(w http.ResponseWriter, r *http.Requ
 	apiutil.WriteJSON(w, http.StatusOK, map[string]int{"updated": updated})
 }
 
-// HandleAddGroupDMRecipient adds a user to a group DM channel.
+// HandleAddGroupDMRecipient adds a user to a group DM channel, or invites
+// them if their group DM privacy setting does not let the caller add them.
 // PUT /api/v1/channels/{channelID}/recipients/{userID}
 func (h *Handler) HandleAddGroupDMRecipient(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
@@ -3321,7 +4015,7 @@ func (h *Handler) HandleAddGroupDMRecipient(w http.ResponseWriter, r *http.Reque
 		`SELECT channel_type FROM channels WHERE id = $1`, channelID,
 	).Scan(&channelType)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if channelType != "group" {
@@ -3336,7 +4030,7 @@ func (h *Handler) HandleAddGroupDMRecipient(w http.ResponseWriter, r *http.Reque
 		channelID, userID,
 	).Scan(&isMember)
 	if !isMember {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this group DM")
+		apiutil.WriteCode(w, apierrors.NotMember, "You are not a member of this group DM")
 		return
 	}
 
@@ -3346,7 +4040,7 @@ func (h *Handler) HandleAddGroupDMRecipient(w http.ResponseWriter, r *http.Reque
 		`SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, targetUserID,
 	).Scan(&targetExists)
 	if !targetExists {
-		apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "User not found")
+		apiutil.WriteCode(w, apierrors.UserNotFound, "")
 		return
 	}
 
@@ -3371,23 +4065,43 @@ func (h *Handler) HandleAddGroupDMRecipient(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Add the recipient.
-	_, err = h.Pool.Exec(r.Context(),
-		`INSERT INTO channel_recipients (channel_id, user_id, joined_at) VALUES ($1, $2, now())`,
-		channelID, targetUserID,
-	)
-	if err != nil {
-		apiutil.InternalError(w, h.Logger, "Failed to add recipient", err)
+	// Users who only accept group DMs from some people get an invite instead.
+	if h.groupDMInviteRequired(r.Context(), userID, targetUserID) {
+		h.inviteToGroupDM(w, r, channelID, userID, targetUserID)
 		return
 	}
 
+	// Group DMs hosted on another instance are changed by the host, which
+	// pushes the new membership back to this instance.
+	proxied := false
+	if h.FedProxy != nil {
+		var ok bool
+		if proxied, ok = h.FedProxy.ProxyGroupDMRecipientChange(w, r, channelID, userID, targetUserID, true); proxied && !ok {
+			return
+		}
+	}
+
+	// Add the recipient.
+	if !proxied {
+		_, err = h.Pool.Exec(r.Context(),
+			`INSERT INTO channel_recipients (channel_id, user_id, joined_at) VALUES ($1, $2, now())`,
+			channelID, targetUserID,
+		)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to add recipient", err)
+			return
+		}
+	}
+
 	channel, err := h.getChannel(r.Context(), channelID)
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to get updated channel", err)
 		return
 	}
 
-	h.EventBus.PublishChannelEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_UPDATE", channelID, channel)
+	if !proxied {
+		h.EventBus.PublishChannelEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_UPDATE", channelID, channel)
+	}
 
 	apiutil.WriteJSON(w, http.StatusOK, channel)
 }
@@ -3411,7 +4125,7 @@ func (h *Handler) HandleRemoveGroupDMRecipient(w http.ResponseWriter, r *http.Re
 		`SELECT channel_type, owner_id FROM channels WHERE id = $1`, channelID,
 	).Scan(&channelType, &ownerID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if channelType != "group" {
@@ -3426,7 +4140,7 @@ func (h *Handler) HandleRemoveGroupDMRecipient(w http.ResponseWriter, r *http.Re
 		channelID, userID,
 	).Scan(&isMember)
 	if !isMember {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this group DM")
+		apiutil.WriteCode(w, apierrors.NotMember, "You are not a member of this group DM")
 		return
 	}
 
@@ -3449,11 +4163,36 @@ func (h *Handler) HandleRemoveGroupDMRecipient(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Remove the recipient.
-	_, err = h.Pool.Exec(r.Context(),
-		`DELETE FROM channel_recipients WHERE channel_id = $1 AND user_id = $2`,
-		channelID, targetUserID,
-	)
+	if h.FedProxy != nil {
+		if proxied, ok := h.FedProxy.ProxyGroupDMRecipientChange(w, r, channelID, userID, targetUserID, false); proxied {
+			if ok {
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+	}
+
+	// Remove the recipient. If the owner leaves, ownership passes to the
+	// longest-standing remaining member.
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`DELETE FROM channel_recipients WHERE channel_id = $1 AND user_id = $2`,
+			channelID, targetUserID,
+		); err != nil {
+			return err
+		}
+		if ownerID == nil || *ownerID != targetUserID {
+			return nil
+		}
+		_, err := tx.Exec(r.Context(),
+			`UPDATE channels SET owner_id = (
+			   SELECT user_id FROM channel_recipients WHERE channel_id = $1
+			   ORDER BY joined_at, user_id LIMIT 1)
+			 WHERE id = $1`,
+			channelID,
+		)
+		return err
+	})
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to remove recipient", err)
 		return
@@ -3481,7 +4220,7 @@ func (h *Handler) HandleGetChannelGallery(w http.ResponseWriter, r *http.Request
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if guildID != nil {
@@ -3490,7 +4229,7 @@ func (h *Handler) HandleGetChannelGallery(w http.ResponseWriter, r *http.Request
 			`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
 			*guildID, userID).Scan(&isMember)
 		if !isMember {
-			apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+			apiutil.WriteCode(w, apierrors.NotMember, "")
 			return
 		}
 	} else {
@@ -3508,7 +4247,7 @@ func (h *Handler) HandleGetChannelGallery(w http.ResponseWriter, r *http.Request
 	// Build query with optional filters.
 	baseSQL := `SELECT a.id, a.message_id, a.uploader_id, a.filename, a.content_type, a.size_bytes,
 	            a.width, a.height, a.duration_seconds, a.s3_bucket, a.s3_key, a.blurhash,
-	            a.alt_text, a.nsfw, a.description, a.created_at
+	            a.thumbhash, a.variants, a.alt_text, a.nsfw, a.description, a.created_at
 	     FROM attachments a
 	     JOIN messages m ON m.id = a.message_id
 	     WHERE m.channel_id = $1`
@@ -3538,7 +4277,7 @@ func (h *Handler) HandleGetChannelGallery(w http.ResponseWriter, r *http.Request
 
 	rows, err := h.Pool.Query(r.Context(), baseSQL, args...)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to query gallery")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to query gallery")
 		return
 	}
 	defer rows.Close()
@@ -3549,9 +4288,9 @@ func (h *Handler) HandleGetChannelGallery(w http.ResponseWriter, r *http.Request
 		if err := rows.Scan(
 			&a.ID, &a.MessageID, &a.UploaderID, &a.Filename, &a.ContentType, &a.SizeBytes,
 			&a.Width, &a.Height, &a.DurationSeconds, &a.S3Bucket, &a.S3Key, &a.Blurhash,
-			&a.AltText, &a.NSFW, &a.Description, &a.CreatedAt,
+			&a.Thumbhash, &a.Variants, &a.AltText, &a.NSFW, &a.Description, &a.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read gallery data")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read gallery data")
 			return
 		}
 		attachments = append(attachments, a)
@@ -1,12 +1,20 @@
 package channels
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
 )
 
 func TestWriteJSON(t *testing.T) {
@@ -166,3 +174,485 @@ func TestPermissionOverrideRequest(t *testing.T) {
 		t.Errorf("permissions_deny = %d, want 2048", req.PermissionsDeny)
 	}
 }
+
+func TestFilterHidden_AuthorSeesOwn(t *testing.T) {
+	h := &Handler{}
+	messages := []models.Message{
+		{ID: "1", AuthorID: "alice"},
+		{ID: "2", AuthorID: "bob", Flags: models.MessageFlagQuarantined},
+	}
+	// Bob's own quarantined message is visible to him without a permission lookup.
+	got := h.filterHidden(context.Background(), "chan", "bob", messages)
+	if len(got) != 2 {
+		t.Errorf("len(filterHidden) = %d, want 2", len(got))
+	}
+}
+
+func TestHiddenFromMembers(t *testing.T) {
+	tests := []struct {
+		flags  int
+		author string
+		want   bool
+	}{
+		{0, "bob", false},
+		{models.MessageFlagQuarantined, "bob", false},
+		{models.MessageFlagQuarantined, "alice", true},
+		{models.MessageFlagDeleted, "bob", true},
+		{models.MessageFlagDeleted, "alice", true},
+	}
+	for _, tt := range tests {
+		m := models.Message{AuthorID: tt.author, Flags: tt.flags}
+		if got := hiddenFromMembers(m, "bob"); got != tt.want {
+			t.Errorf("hiddenFromMembers(flags=%d, author=%s) = %v, want %v", tt.flags, tt.author, got, tt.want)
+		}
+	}
+}
+
+func TestMessageIsDeleted(t *testing.T) {
+	if (models.Message{Flags: models.MessageFlagQuarantined}).IsDeleted() {
+		t.Error("quarantined message reported as deleted")
+	}
+	if !(models.Message{Flags: models.MessageFlagPinned | models.MessageFlagDeleted}).IsDeleted() {
+		t.Error("deleted message not reported as deleted")
+	}
+}
+
+func TestMessageIsQuarantined(t *testing.T) {
+	if (models.Message{Flags: models.MessageFlagSilent}).IsQuarantined() {
+		t.Error("silent message reported as quarantined")
+	}
+	if !(models.Message{Flags: models.MessageFlagSilent | models.MessageFlagQuarantined}).IsQuarantined() {
+		t.Error("quarantined message not reported as quarantined")
+	}
+}
+
+func TestGenerateEmailLocalpart(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+	}{
+		{"General Chat", "general-chat-"},
+		{"support_desk", "support-desk-"},
+		{"  ~~weird~~ name!! ", "weird-name-"},
+		{"ünïcode", "ncode-"},
+		{"", "channel-"},
+		{"a-very-long-channel-name-that-keeps-going", "a-very-long-channel-name-"},
+	}
+	for _, tc := range tests {
+		got, err := generateEmailLocalpart(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(got, tc.prefix) || len(got) != len(tc.prefix)+10 {
+			t.Errorf("generateEmailLocalpart(%q) = %q, want %q + 10 hex chars", tc.name, got, tc.prefix)
+		}
+	}
+
+	a, _ := generateEmailLocalpart("general")
+	b, _ := generateEmailLocalpart("general")
+	if a == b {
+		t.Errorf("two localparts for the same name are equal: %q", a)
+	}
+}
+
+func TestValidateImportBatch(t *testing.T) {
+	now := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	valid := importMessage{
+		RemoteID:   "1",
+		Content:    "hello",
+		CreatedAt:  now.Add(-24 * time.Hour),
+		AuthorName: "alice",
+	}
+	withMessages := func(msgs ...importMessage) importMessagesRequest {
+		return importMessagesRequest{Source: "discord", Messages: msgs}
+	}
+
+	future := valid
+	future.CreatedAt = now.Add(time.Hour)
+	noAuthor := valid
+	noAuthor.AuthorName = ""
+	second := valid
+	second.RemoteID = "2"
+
+	tests := []struct {
+		name string
+		req  importMessagesRequest
+		want string
+	}{
+		{"valid", withMessages(valid, second), ""},
+		{"missing source", importMessagesRequest{Messages: []importMessage{valid}}, "invalid_source"},
+		{"empty batch", withMessages(), "invalid_batch_size"},
+		{"duplicate remote id", withMessages(valid, valid), "duplicate_remote_id"},
+		{"future timestamp", withMessages(future), "invalid_timestamp"},
+		{"missing author", withMessages(noAuthor), "invalid_author_name"},
+	}
+	for _, tc := range tests {
+		if code, _ := validateImportBatch(tc.req, now); code != tc.want {
+			t.Errorf("%s: code = %q, want %q", tc.name, code, tc.want)
+		}
+	}
+
+	big := make([]importMessage, maxImportBatch+1)
+	if code, _ := validateImportBatch(withMessages(big...), now); code != "invalid_batch_size" {
+		t.Errorf("oversized batch: code = %q", code)
+	}
+}
+
+func TestRenderTag(t *testing.T) {
+	v := tagVars{UserID: "u1", ChannelID: "c1", GuildName: "Guild"}
+	got := renderTag("Hi {user}, welcome to {guild}! See {channel}. {user} {unknown}", v)
+	want := "Hi <@u1>, welcome to Guild! See <#c1>. <@u1> {unknown}"
+	if got != want {
+		t.Errorf("renderTag = %q, want %q", got, want)
+	}
+	if got := renderTag("no placeholders", v); got != "no placeholders" {
+		t.Errorf("renderTag changed plain text: %q", got)
+	}
+}
+
+func TestInheritFlag(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		name     string
+		inherit  *bool
+		valueSet bool
+		want     *bool
+	}{
+		{"untouched", nil, false, nil},
+		{"value overrides category", nil, true, &off},
+		{"explicit inherit wins", &on, true, &on},
+		{"explicit override", &off, false, &off},
+	}
+	for _, tt := range tests {
+		got := inheritFlag(tt.inherit, tt.valueSet)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("%s: inheritFlag = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMessageMasquerade_Normalize(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name    string
+		m       messageMasquerade
+		wantErr bool
+		want    [3]string
+	}{
+		{"bridge name, empty avatar", messageMasquerade{Name: str("alice (IRC)"), Avatar: str("")}, false, [3]string{"alice (IRC)", "", ""}},
+		{"all fields", messageMasquerade{Name: str(" bob​  smith "), Avatar: str("https://cdn.example.com/a.png"), Color: str("#5865F2")}, false, [3]string{"bob smith", "https://cdn.example.com/a.png", "#5865f2"}},
+		{"http avatar", messageMasquerade{Avatar: str("http://example.com/a.png")}, true, [3]string{}},
+		{"bad color", messageMasquerade{Name: str("x"), Color: str("red")}, true, [3]string{}},
+		{"name too long", messageMasquerade{Name: str(strings.Repeat("a", maxMasqueradeName+1))}, true, [3]string{}},
+		{"nothing set", messageMasquerade{Name: str("​"), Avatar: str(" ")}, true, [3]string{}},
+	}
+	deref := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return *p
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, avatar, color, err := tt.m.normalize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := [3]string{deref(name), deref(avatar), deref(color)}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoThreadName(t *testing.T) {
+	str := func(s string) *string { return &s }
+	long := strings.Repeat("é", maxThreadName+20)
+	tests := []struct {
+		name      string
+		content   *string
+		encrypted bool
+		want      string
+	}{
+		{"first line", str("  Release notes\nmore details"), false, "Release notes"},
+		{"collapses whitespace", str("a \t b"), false, "a b"},
+		{"no content", nil, false, "Thread"},
+		{"blank content", str(" \n "), false, "Thread"},
+		{"encrypted", str("ciphertext"), true, "Thread"},
+		{"truncated", str(long), false, strings.Repeat("é", maxThreadName-1) + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := autoThreadName(tt.content, tt.encrypted); got != tt.want {
+				t.Errorf("autoThreadName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveCooldown(t *testing.T) {
+	cfg := models.DefaultChannelAdaptiveSlowmode("ch")
+	cfg.LowThreshold, cfg.HighThreshold = 20, 120
+	cfg.MinSeconds, cfg.MaxSeconds = 2, 30
+
+	tests := []struct {
+		curve    string
+		velocity float64
+		want     int
+	}{
+		{models.AdaptiveCurveLinear, 19.9, 0},
+		{models.AdaptiveCurveLinear, 20, 2},
+		{models.AdaptiveCurveLinear, 70, 16},
+		{models.AdaptiveCurveLinear, 500, 30},
+		{models.AdaptiveCurveEaseIn, 70, 9},
+		{models.AdaptiveCurveEaseOut, 70, 22},
+		{models.AdaptiveCurveEaseIn, 120, 30},
+	}
+	for _, tt := range tests {
+		cfg.Curve = tt.curve
+		if got := adaptiveCooldown(cfg, tt.velocity); got != tt.want {
+			t.Errorf("adaptiveCooldown(%s, %v) = %d, want %d", tt.curve, tt.velocity, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAdaptiveSlowmode(t *testing.T) {
+	valid := models.DefaultChannelAdaptiveSlowmode("ch")
+	if msg := validateAdaptiveSlowmode(valid); msg != "" {
+		t.Fatalf("defaults rejected: %s", msg)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*models.ChannelAdaptiveSlowmode)
+	}{
+		{"window too short", func(c *models.ChannelAdaptiveSlowmode) { c.WindowSeconds = 5 }},
+		{"thresholds inverted", func(c *models.ChannelAdaptiveSlowmode) { c.LowThreshold = c.HighThreshold }},
+		{"zero low threshold", func(c *models.ChannelAdaptiveSlowmode) { c.LowThreshold = 0 }},
+		{"min above max", func(c *models.ChannelAdaptiveSlowmode) { c.MinSeconds = c.MaxSeconds + 1 }},
+		{"max too long", func(c *models.ChannelAdaptiveSlowmode) { c.MaxSeconds = maxAdaptiveCooldown + 1 }},
+		{"unknown curve", func(c *models.ChannelAdaptiveSlowmode) { c.Curve = "cubic" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			if validateAdaptiveSlowmode(cfg) == "" {
+				t.Error("expected configuration to be rejected")
+			}
+		})
+	}
+}
+
+func TestValidateDuplicatePolicy(t *testing.T) {
+	valid := models.DefaultChannelDuplicatePolicy("ch")
+	if msg := validateDuplicatePolicy(valid); msg != "" {
+		t.Fatalf("defaults rejected: %s", msg)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*models.ChannelDuplicatePolicy)
+	}{
+		{"unknown mode", func(p *models.ChannelDuplicatePolicy) { p.Mode = "merge" }},
+		{"window too short", func(p *models.ChannelDuplicatePolicy) { p.WindowSeconds = minDuplicateWindow - 1 }},
+		{"window too long", func(p *models.ChannelDuplicatePolicy) { p.WindowSeconds = maxDuplicateWindow + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.modify(&p)
+			if validateDuplicatePolicy(p) == "" {
+				t.Error("expected policy to be rejected")
+			}
+		})
+	}
+}
+
+func TestIsRepeat(t *testing.T) {
+	now := time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC)
+	hello := "hello"
+	other := "hello there"
+	window := 5 * time.Minute
+	base := lastMessage{ID: "1", AuthorID: "alice", Content: &hello, CreatedAt: now.Add(-time.Minute)}
+
+	tests := []struct {
+		name    string
+		modify  func(*lastMessage)
+		author  string
+		content string
+		want    bool
+	}{
+		{"same text", nil, "alice", "hello", true},
+		{"surrounding whitespace", nil, "alice", "  hello\n", true},
+		{"other author", nil, "bob", "hello", false},
+		{"different text", nil, "alice", "Hello", false},
+		{"earlier had attachments", func(m *lastMessage) { m.HasAttachments = true }, "alice", "hello", false},
+		{"earlier had no text", func(m *lastMessage) { m.Content = nil }, "alice", "hello", false},
+		{"outside window", func(m *lastMessage) { m.CreatedAt = now.Add(-window - time.Second) }, "alice", "hello", false},
+		{"longer text", func(m *lastMessage) { m.Content = &other }, "alice", "hello", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last := base
+			if tt.modify != nil {
+				tt.modify(&last)
+			}
+			if got := isRepeat(last, tt.author, tt.content, now, window); got != tt.want {
+				t.Errorf("isRepeat = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentionReach(t *testing.T) {
+	tests := []struct {
+		name        string
+		guildWide   bool
+		memberCount int
+		roleMembers int
+		users       int
+		want        int
+	}{
+		{"guild wide counts members only", true, 5000, 40, 3, 5000},
+		{"roles and users add up", false, 5000, 40, 3, 43},
+		{"users only", false, 0, 0, 7, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mentionReach(tt.guildWide, tt.memberCount, tt.roleMembers, tt.users); got != tt.want {
+				t.Errorf("mentionReach() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOverrideSet(t *testing.T) {
+	role := channelOverride{TargetType: "role", TargetID: "r1", PermissionsAllow: 1024}
+	user := channelOverride{TargetType: "user", TargetID: "r1", PermissionsDeny: 2048}
+
+	tests := []struct {
+		name      string
+		overrides []channelOverride
+		want      string
+	}{
+		{"empty set clears overrides", nil, ""},
+		{"role and user with same id", []channelOverride{role, user}, ""},
+		{"bad target type", []channelOverride{{TargetType: "everyone", TargetID: "x"}}, "invalid_target_type"},
+		{"missing target id", []channelOverride{{TargetType: "role"}}, "invalid_target_id"},
+		{"duplicate target", []channelOverride{role, role}, "duplicate_override"},
+	}
+	for _, tt := range tests {
+		if code, _ := validateOverrideSet(tt.overrides); code != tt.want {
+			t.Errorf("%s: code = %q, want %q", tt.name, code, tt.want)
+		}
+	}
+
+	big := make([]channelOverride, maxChannelOverrides+1)
+	if code, _ := validateOverrideSet(big); code != "too_many_overrides" {
+		t.Errorf("oversized set: code = %q", code)
+	}
+}
+
+func TestIsNonceConflict(t *testing.T) {
+	nonce := &pgconn.PgError{Code: "23505", ConstraintName: "messages_channel_id_nonce_key"}
+	if !isNonceConflict(fmt.Errorf("insert: %w", nonce)) {
+		t.Error("expected the nonce constraint to be recognized")
+	}
+	other := &pgconn.PgError{Code: "23505", ConstraintName: "messages_pkey"}
+	if isNonceConflict(other) {
+		t.Error("other unique violations are not nonce conflicts")
+	}
+	if isNonceConflict(errors.New("boom")) {
+		t.Error("plain errors are not nonce conflicts")
+	}
+}
+
+func TestValidateVoiceChatSettings(t *testing.T) {
+	intp := func(v int) *int { return &v }
+	strp := func(v string) *string { return &v }
+	tests := []struct {
+		name    string
+		minutes *int
+		history *string
+		ok      bool
+	}{
+		{"unset", nil, nil, true},
+		{"current participants only", intp(0), strp(models.VoiceChatHistorySession), true},
+		{"a week", intp(maxVoiceChatRecentMinutes), strp(models.VoiceChatHistoryKeep), true},
+		{"negative window", intp(-1), nil, false},
+		{"window too long", intp(maxVoiceChatRecentMinutes + 1), nil, false},
+		{"unknown history", nil, strp("forever"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if got := validateVoiceChatSettings(w, tt.minutes, tt.history); got != tt.ok {
+				t.Errorf("validateVoiceChatSettings() = %v, want %v (status %d)", got, tt.ok, w.Code)
+			}
+		})
+	}
+}
+
+func TestDMMessageStatus(t *testing.T) {
+	const id = "01HZZZZZZZZZZZZZZZZZZZZZ50"
+	const older = "01HZZZZZZZZZZZZZZZZZZZZZ40"
+	const newer = "01HZZZZZZZZZZZZZZZZZZZZZ60"
+	tests := []struct {
+		name       string
+		recipients []dmRecipientState
+		showReads  bool
+		want       string
+	}{
+		{"no recipients", nil, true, models.MessageStatusSent},
+		{"not yet delivered", []dmRecipientState{{lastDeliveredID: older, readReceipts: true}}, true, models.MessageStatusSent},
+		{"delivered", []dmRecipientState{{lastDeliveredID: id, readReceipts: true}}, true, models.MessageStatusDelivered},
+		{"read", []dmRecipientState{{lastDeliveredID: id, lastReadID: newer, readReceipts: true}}, true, models.MessageStatusRead},
+		{"read without a recorded delivery", []dmRecipientState{{lastReadID: id, readReceipts: true}}, true, models.MessageStatusRead},
+		{"recipient hides reads", []dmRecipientState{{lastReadID: id}}, true, models.MessageStatusDelivered},
+		{"author hides reads", []dmRecipientState{{lastReadID: id, readReceipts: true}}, false, models.MessageStatusDelivered},
+		{"group waits for everyone", []dmRecipientState{
+			{lastReadID: id, readReceipts: true},
+			{lastDeliveredID: id, readReceipts: true},
+		}, true, models.MessageStatusDelivered},
+		{"group member offline", []dmRecipientState{
+			{lastReadID: id, readReceipts: true},
+			{},
+		}, true, models.MessageStatusSent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dmMessageStatus(id, tt.recipients, tt.showReads); got != tt.want {
+				t.Errorf("dmMessageStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditPolicyRefusal(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	window := func(minutes int, sentAgo time.Duration) *messageEditPolicy {
+		return &messageEditPolicy{
+			GuildEditPolicy: models.GuildEditPolicy{EditWindowMinutes: minutes},
+			SentAt:          now.Add(-sentAgo),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		policy *messageEditPolicy
+		want   string
+	}{
+		{"no policy", nil, ""},
+		{"no window", window(0, 30*24*time.Hour), ""},
+		{"inside window", window(15, 10*time.Minute), ""},
+		{"window passed", window(15, 16*time.Minute), "edit_window_expired"},
+		{"pending report", &messageEditPolicy{SentAt: now, PendingReport: true}, "message_edit_locked"},
+	}
+	for _, tt := range tests {
+		if code, _ := tt.policy.refusal(now); code != tt.want {
+			t.Errorf("%s: refusal() = %q, want %q", tt.name, code, tt.want)
+		}
+	}
+}
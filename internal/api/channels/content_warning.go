@@ -0,0 +1,75 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// maxContentWarningLength is the longest content warning reason, in
+// characters. A warning is a short label, not a summary.
+const maxContentWarningLength = 200
+
+// normalizeContentWarning trims a content warning and checks its length. An
+// empty result means no warning.
+func normalizeContentWarning(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxContentWarningLength {
+		return "", fmt.Errorf("content_warning must be at most %d characters", maxContentWarningLength)
+	}
+	return reason, nil
+}
+
+// setContentWarning sets or, for an empty reason, clears a message's content
+// warning. A warning spoilers every attachment of the message; clearing it
+// leaves them spoilered, since the author may have marked them individually.
+func (h *Handler) setContentWarning(ctx context.Context, messageID, reason string) error {
+	if reason == "" {
+		_, err := h.Pool.Exec(ctx,
+			`DELETE FROM message_content_warnings WHERE message_id = $1`, messageID)
+		return err
+	}
+	if _, err := h.Pool.Exec(ctx,
+		`INSERT INTO message_content_warnings (message_id, reason) VALUES ($1, $2)
+		 ON CONFLICT (message_id) DO UPDATE SET reason = EXCLUDED.reason`,
+		messageID, reason); err != nil {
+		return err
+	}
+	_, err := h.Pool.Exec(ctx,
+		`UPDATE attachments SET spoiler = true WHERE message_id = $1 AND NOT spoiler`, messageID)
+	return err
+}
+
+// enrichMessagesWithContentWarnings attaches content warnings to messages so
+// clients can hide their content until revealed.
+func (h *Handler) enrichMessagesWithContentWarnings(ctx context.Context, messages []models.Message) {
+	if len(messages) == 0 {
+		return
+	}
+	msgIDs := make([]string, len(messages))
+	for i, m := range messages {
+		msgIDs[i] = m.ID
+	}
+	rows, err := h.Pool.Query(ctx,
+		`SELECT message_id, reason FROM message_content_warnings
+		 WHERE message_id = ANY($1)`, msgIDs)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	byID := make(map[string]*string)
+	for rows.Next() {
+		var id, reason string
+		if err := rows.Scan(&id, &reason); err != nil {
+			continue
+		}
+		byID[id] = &reason
+	}
+	for i := range messages {
+		messages[i].ContentWarning = byID[messages[i].ID]
+	}
+}
@@ -0,0 +1,165 @@
+package channels
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// --- Soft-deleted Messages ---
+//
+// In a guild with a message restore window, deleting a message only flags
+// it. Members get MESSAGE_DELETE as usual and no longer see it; moderators
+// can list and restore it until the window passes and the purge worker
+// removes it for good. Deleting an already soft-deleted message purges it
+// at once.
+
+// deletedMessage is a soft-deleted message as listed for moderators.
+type deletedMessage struct {
+	models.Message
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy *string   `json:"deleted_by"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// restoreWindowDays returns how many days the channel's guild keeps deleted
+// messages, or 0 for DMs and guilds that delete immediately.
+func (h *Handler) restoreWindowDays(ctx context.Context, channelID string) int {
+	var days int
+	h.Pool.QueryRow(ctx,
+		`SELECT g.message_restore_days FROM channels c JOIN guilds g ON g.id = c.guild_id
+		 WHERE c.id = $1`, channelID).Scan(&days)
+	return days
+}
+
+// HandleGetDeletedMessages lists a channel's soft-deleted messages, most
+// recently deleted first.
+// GET /api/v1/channels/{channelID}/messages/deleted?limit=
+func (h *Handler) HandleGetDeletedMessages(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageMessages) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 100 {
+			limit = n
+		}
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT m.id, m.channel_id, m.author_id, m.content, m.nonce, m.message_type, m.edited_at, m.flags,
+		        m.reply_to_ids, m.mention_user_ids, m.mention_role_ids, m.mention_here, m.mention_everyone,
+		        m.thread_id, m.masquerade_name, m.masquerade_avatar, m.masquerade_color,
+		        m.encrypted, m.encryption_session_id, m.created_at,
+		        m.deleted_at, m.deleted_by, m.deleted_at + make_interval(days => g.message_restore_days)
+		 FROM messages m
+		 JOIN channels c ON c.id = m.channel_id
+		 JOIN guilds g ON g.id = c.guild_id
+		 WHERE m.channel_id = $1 AND m.flags & $2 != 0
+		 ORDER BY m.deleted_at DESC
+		 LIMIT $3`, channelID, models.MessageFlagDeleted, limit)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get deleted messages", err)
+		return
+	}
+	defer rows.Close()
+
+	deleted := make([]deletedMessage, 0)
+	for rows.Next() {
+		var d deletedMessage
+		m := &d.Message
+		if err := rows.Scan(
+			&m.ID, &m.ChannelID, &m.AuthorID, &m.Content, &m.Nonce, &m.MessageType,
+			&m.EditedAt, &m.Flags, &m.ReplyToIDs, &m.MentionUserIDs, &m.MentionRoleIDs,
+			&m.MentionHere, &m.MentionEveryone, &m.ThreadID, &m.MasqueradeName, &m.MasqueradeAvatar,
+			&m.MasqueradeColor, &m.Encrypted, &m.EncryptionSessionID, &m.CreatedAt,
+			&d.DeletedAt, &d.DeletedBy, &d.PurgeAt,
+		); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read deleted messages", err)
+			return
+		}
+		deleted = append(deleted, d)
+	}
+	if err := rows.Err(); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read deleted messages", err)
+		return
+	}
+
+	for i := range deleted {
+		m := &deleted[i].Message
+		m.Attachments = h.loadAttachments(r.Context(), m.ID)
+		h.enrichMessageWithAuthor(r.Context(), m)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, deleted)
+}
+
+// HandleRestoreMessage brings back a soft-deleted message. Members receive
+// it again as a MESSAGE_CREATE in its original place in the history.
+// POST /api/v1/channels/{channelID}/messages/{messageID}/restore
+func (h *Handler) HandleRestoreMessage(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	messageID := chi.URLParam(r, "messageID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageMessages) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE messages SET flags = flags & ~$3, deleted_at = NULL, deleted_by = NULL
+		 WHERE id = $1 AND channel_id = $2 AND flags & $3 != 0`,
+		messageID, channelID, models.MessageFlagDeleted)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to restore message", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "deleted_message_not_found", "No deleted message with this ID in the channel")
+		return
+	}
+
+	h.Pool.Exec(r.Context(),
+		`UPDATE channels SET reply_count = reply_count + 1
+		 WHERE id = $1 AND parent_channel_id IS NOT NULL`,
+		channelID)
+
+	msg, err := h.getMessage(r.Context(), channelID, messageID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "deleted_message_not_found", "No deleted message with this ID in the channel")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get message", err)
+		return
+	}
+	msg.Attachments = h.loadAttachments(r.Context(), messageID)
+	msg.Embeds = h.loadEmbeds(r.Context(), messageID)
+	h.enrichMessageWithAuthor(r.Context(), msg)
+
+	h.publishMessageEvent(r.Context(), events.SubjectMessageCreate, "MESSAGE_CREATE", *msg)
+	h.Logger.Info("message restored",
+		slog.String("channel_id", channelID),
+		slog.String("message_id", messageID),
+		slog.String("restored_by", userID))
+
+	apiutil.WriteJSON(w, http.StatusOK, msg)
+}
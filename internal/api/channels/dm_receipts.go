@@ -0,0 +1,88 @@
+package channels
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// dmRecipientState is how far one recipient of a DM or group DM has
+// received and read its messages.
+type dmRecipientState struct {
+	lastDeliveredID string
+	lastReadID      string
+	readReceipts    bool
+}
+
+// dmMessageStatus returns the status of a message as its author sees it:
+// the least advanced of its recipients. Reads only count when both the
+// author (showReads) and the recipient share read receipts; otherwise a
+// read message shows as delivered.
+func dmMessageStatus(messageID string, recipients []dmRecipientState, showReads bool) string {
+	if len(recipients) == 0 {
+		return models.MessageStatusSent
+	}
+	status := models.MessageStatusRead
+	for _, r := range recipients {
+		switch {
+		case showReads && r.readReceipts && r.lastReadID >= messageID:
+		case r.lastDeliveredID >= messageID || r.lastReadID >= messageID:
+			status = models.MessageStatusDelivered
+		default:
+			return models.MessageStatusSent
+		}
+	}
+	return status
+}
+
+// enrichMessagesWithStatus sets the delivery status of the user's own
+// messages in a DM or group DM. Messages in other channels are left alone.
+func (h *Handler) enrichMessagesWithStatus(ctx context.Context, channelID, userID string, messages []models.Message) {
+	own := false
+	for _, m := range messages {
+		if m.AuthorID == userID {
+			own = true
+			break
+		}
+	}
+	if !own {
+		return
+	}
+
+	var isDM, showReads bool
+	h.Pool.QueryRow(ctx,
+		`SELECT c.channel_type IN ('dm', 'group'), u.read_receipts
+		 FROM channels c, users u WHERE c.id = $1 AND u.id = $2`,
+		channelID, userID).Scan(&isDM, &showReads)
+	if !isDM {
+		return
+	}
+
+	rows, err := h.Pool.Query(ctx,
+		`SELECT COALESCE(d.last_delivered_id, ''), COALESCE(rs.last_read_id, ''), u.read_receipts
+		 FROM channel_recipients r
+		 JOIN users u ON u.id = r.user_id
+		 LEFT JOIN dm_delivery_state d ON d.channel_id = r.channel_id AND d.user_id = r.user_id
+		 LEFT JOIN read_state rs ON rs.channel_id = r.channel_id AND rs.user_id = r.user_id
+		 WHERE r.channel_id = $1 AND r.user_id <> $2`,
+		channelID, userID)
+	if err != nil {
+		h.Logger.Warn("failed to load DM receipts", slog.String("error", err.Error()))
+		return
+	}
+	defer rows.Close()
+	var recipients []dmRecipientState
+	for rows.Next() {
+		var r dmRecipientState
+		if rows.Scan(&r.lastDeliveredID, &r.lastReadID, &r.readReceipts) == nil {
+			recipients = append(recipients, r)
+		}
+	}
+
+	for i := range messages {
+		if messages[i].AuthorID == userID {
+			messages[i].Status = dmMessageStatus(messages[i].ID, recipients, showReads)
+		}
+	}
+}
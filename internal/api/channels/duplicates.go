@@ -0,0 +1,201 @@
+// Package channels — duplicates.go catches a member sending the same text
+// twice in a row. Depending on the channel's policy the repeat is folded
+// into the earlier message as a counter or refused, which keeps accidental
+// double sends and copy-paste flooding out of the history.
+package channels
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// Bounds on the duplicate window: from a double-click to a day.
+const (
+	minDuplicateWindow = 10
+	maxDuplicateWindow = 86400
+)
+
+type updateDuplicatePolicyRequest struct {
+	Mode          *string `json:"mode"`
+	WindowSeconds *int    `json:"window_seconds"`
+}
+
+// lastMessage is the newest visible message of a channel, as compared
+// against a new send.
+type lastMessage struct {
+	ID             string
+	AuthorID       string
+	Content        *string
+	HasAttachments bool
+	CreatedAt      time.Time
+}
+
+// validateDuplicatePolicy checks a policy and returns a message safe to
+// return to the caller, or "".
+func validateDuplicatePolicy(p models.ChannelDuplicatePolicy) string {
+	switch p.Mode {
+	case models.DuplicateModeOff, models.DuplicateModeCollapse, models.DuplicateModeReject:
+	default:
+		return "mode must be off, collapse or reject"
+	}
+	if p.WindowSeconds < minDuplicateWindow || p.WindowSeconds > maxDuplicateWindow {
+		return fmt.Sprintf("window_seconds must be between %d and %d", minDuplicateWindow, maxDuplicateWindow)
+	}
+	return ""
+}
+
+// isRepeat reports whether sending content as userID at now repeats last:
+// same author, same text ignoring surrounding whitespace, no attachments on
+// the earlier message, and within window of it.
+func isRepeat(last lastMessage, userID, content string, now time.Time, window time.Duration) bool {
+	if last.AuthorID != userID || last.Content == nil || last.HasAttachments {
+		return false
+	}
+	if now.Sub(last.CreatedAt) > window {
+		return false
+	}
+	text := strings.TrimSpace(content)
+	return text != "" && strings.TrimSpace(*last.Content) == text
+}
+
+// handleRepeat applies the channel's duplicate policy to a plain text send.
+// It returns true if the send was a repeat and a response has been written:
+// the earlier message with its counter raised, or a refusal.
+func (h *Handler) handleRepeat(w http.ResponseWriter, r *http.Request, cc *channelCtx, channelID, userID, content string) bool {
+	if cc.DuplicateMode != models.DuplicateModeCollapse && cc.DuplicateMode != models.DuplicateModeReject {
+		return false
+	}
+
+	var last lastMessage
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT m.id, m.author_id, m.content, m.created_at,
+		        EXISTS(SELECT 1 FROM attachments a WHERE a.message_id = m.id)
+		 FROM messages m
+		 WHERE m.channel_id = $1 AND m.flags & $2 = 0
+		 ORDER BY m.id DESC LIMIT 1`,
+		channelID, models.MessageFlagDeleted,
+	).Scan(&last.ID, &last.AuthorID, &last.Content, &last.CreatedAt, &last.HasAttachments)
+	if err != nil {
+		// No earlier message, or none we can read: post normally.
+		return false
+	}
+	if !isRepeat(last, userID, content, time.Now(), time.Duration(cc.DuplicateWindow)*time.Second) {
+		return false
+	}
+
+	if cc.DuplicateMode == models.DuplicateModeReject {
+		apiutil.WriteError(w, http.StatusConflict, "duplicate_message",
+			"You just sent that message. Try saying something new.")
+		return true
+	}
+
+	if _, err := h.Pool.Exec(r.Context(),
+		`UPDATE messages SET repeat_count = repeat_count + 1 WHERE id = $1`, last.ID); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to collapse repeated message", err)
+		return true
+	}
+	msg, err := h.getMessage(r.Context(), channelID, last.ID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get message", err)
+		return true
+	}
+	h.enrichMessageWithAuthor(r.Context(), msg)
+	h.publishMessageEvent(r.Context(), events.SubjectMessageUpdate, "MESSAGE_UPDATE", *msg)
+
+	apiutil.WriteJSON(w, http.StatusOK, msg)
+	return true
+}
+
+// loadDuplicatePolicy returns the channel's policy, or the default.
+func (h *Handler) loadDuplicatePolicy(ctx context.Context, channelID string) (models.ChannelDuplicatePolicy, error) {
+	p := models.DefaultChannelDuplicatePolicy(channelID)
+	err := h.Pool.QueryRow(ctx,
+		`SELECT mode, window_seconds, updated_at FROM channel_duplicate_policy WHERE channel_id = $1`, channelID,
+	).Scan(&p.Mode, &p.WindowSeconds, &p.UpdatedAt)
+	if err != nil && err != pgx.ErrNoRows {
+		return p, err
+	}
+	return p, nil
+}
+
+// HandleGetDuplicatePolicy returns the channel's duplicate message policy,
+// so clients can explain a collapsed or refused send.
+// GET /api/v1/channels/{channelID}/duplicate-policy
+func (h *Handler) HandleGetDuplicatePolicy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
+		return
+	}
+
+	p, err := h.loadDuplicatePolicy(r.Context(), channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get duplicate policy", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, p)
+}
+
+// HandleUpdateDuplicatePolicy changes the channel's duplicate message
+// policy. Omitted fields keep their current value.
+// PATCH /api/v1/channels/{channelID}/duplicate-policy
+func (h *Handler) HandleUpdateDuplicatePolicy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	var req updateDuplicatePolicyRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	p, err := h.loadDuplicatePolicy(r.Context(), channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get duplicate policy", err)
+		return
+	}
+	if req.Mode != nil {
+		p.Mode = *req.Mode
+	}
+	if req.WindowSeconds != nil {
+		p.WindowSeconds = *req.WindowSeconds
+	}
+	if msg := validateDuplicatePolicy(p); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_duplicate_policy", msg)
+		return
+	}
+
+	err = h.Pool.QueryRow(r.Context(),
+		`INSERT INTO channel_duplicate_policy (channel_id, mode, window_seconds, updated_at)
+		 VALUES ($1, $2, $3, now())
+		 ON CONFLICT (channel_id) DO UPDATE SET
+		     mode = $2, window_seconds = $3, updated_at = now()
+		 RETURNING updated_at`,
+		channelID, p.Mode, p.WindowSeconds,
+	).Scan(&p.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update duplicate policy", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, p)
+}
@@ -0,0 +1,96 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// messageEditPolicy is a guild's edit policy together with the state of the
+// message being edited.
+type messageEditPolicy struct {
+	models.GuildEditPolicy
+	SentAt        time.Time
+	PendingReport bool // only looked up when the policy locks reported messages
+}
+
+// loadEditPolicy returns the edit policy that applies to a message, or nil
+// when the message is not in a guild that has one.
+func (h *Handler) loadEditPolicy(ctx context.Context, messageID string) (*messageEditPolicy, error) {
+	var p messageEditPolicy
+	err := h.Pool.QueryRow(ctx,
+		`SELECT p.guild_id, p.edit_window_minutes, p.snapshot_edits, p.lock_reported, m.created_at,
+		        p.lock_reported AND EXISTS (
+		            SELECT 1 FROM message_reports r
+		            WHERE r.message_id = m.id AND r.status IN ('open', 'admin_pending'))
+		 FROM messages m
+		 JOIN channels c ON c.id = m.channel_id
+		 JOIN guild_edit_policy p ON p.guild_id = c.guild_id
+		 WHERE m.id = $1`, messageID,
+	).Scan(&p.GuildID, &p.EditWindowMinutes, &p.SnapshotEdits, &p.LockReported, &p.SentAt, &p.PendingReport)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading edit policy: %w", err)
+	}
+	return &p, nil
+}
+
+// refusal returns the error code and message that refuse an edit made at
+// now, or "" if the policy allows it.
+func (p *messageEditPolicy) refusal(now time.Time) (code, msg string) {
+	if p == nil {
+		return "", ""
+	}
+	if p.PendingReport {
+		return "message_edit_locked", "This message has been reported and cannot be edited until moderators review it"
+	}
+	if p.EditWindowMinutes > 0 && now.Sub(p.SentAt) > time.Duration(p.EditWindowMinutes)*time.Minute {
+		return "edit_window_expired", fmt.Sprintf("Messages can only be edited for %d minutes after sending", p.EditWindowMinutes)
+	}
+	return "", ""
+}
+
+// checkEditPolicy writes an error and returns false if the guild's policy
+// refuses an edit of the message's content.
+func (h *Handler) checkEditPolicy(w http.ResponseWriter, r *http.Request, messageID string) (*messageEditPolicy, bool) {
+	policy, err := h.loadEditPolicy(r.Context(), messageID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to check edit policy", err)
+		return nil, false
+	}
+	if code, msg := policy.refusal(time.Now()); code != "" {
+		apiutil.WriteError(w, http.StatusForbidden, code, msg)
+		return nil, false
+	}
+	return policy, true
+}
+
+// snapshotEdit records an edit's before and after content in the guild
+// audit log, for guilds whose policy asks for it. Failures are logged.
+func (h *Handler) snapshotEdit(ctx context.Context, policy *messageEditPolicy, msg models.Message, before *string) {
+	if policy == nil || !policy.SnapshotEdits || msg.Encrypted {
+		return
+	}
+	changes := map[string]interface{}{
+		"channel_id": msg.ChannelID,
+		"before":     before,
+		"after":      msg.Content,
+	}
+	if _, err := h.Pool.Exec(ctx,
+		`INSERT INTO audit_log (id, guild_id, actor_id, action, target_type, target_id, changes, created_at)
+		 VALUES ($1, $2, $3, 'MESSAGE_EDIT', 'message', $4, $5, now())`,
+		models.NewULID().String(), policy.GuildID, msg.AuthorID, msg.ID, changes); err != nil {
+		h.Logger.Warn("failed to record message edit snapshot",
+			slog.String("message_id", msg.ID), slog.String("error", err.Error()))
+	}
+}
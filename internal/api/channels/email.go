@@ -0,0 +1,185 @@
+// Package channels — email.go implements management of a channel's inbound
+// email address for the email gateway.
+package channels
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// channelEmailAddress is the API representation of a channel's email address.
+type channelEmailAddress struct {
+	ChannelID    string    `json:"channel_id"`
+	Address      string    `json:"address"`
+	ReplyEnabled bool      `json:"reply_enabled"`
+	CreatedBy    *string   `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// HandleGetChannelEmail returns the channel's inbound email address.
+// GET /api/v1/channels/{channelID}/email
+func (h *Handler) HandleGetChannelEmail(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	addr, err := h.getChannelEmail(r, channelID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "email_not_enabled", "This channel has no email address")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel email address", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, addr)
+}
+
+// HandleSetChannelEmail gives the channel an inbound email address, or updates
+// whether replies are emailed back to senders. The address is generated once
+// and kept until the address is removed.
+// PUT /api/v1/channels/{channelID}/email
+func (h *Handler) HandleSetChannelEmail(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if h.EmailDomain == "" {
+		apiutil.WriteError(w, http.StatusNotImplemented, "email_gateway_disabled", "The email gateway is not enabled on this instance")
+		return
+	}
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	var req struct {
+		ReplyEnabled bool `json:"reply_enabled"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	var name, channelType string
+	var guildID *string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT COALESCE(name, ''), channel_type, guild_id FROM channels WHERE id = $1`, channelID,
+	).Scan(&name, &channelType, &guildID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel", err)
+		return
+	}
+	if guildID == nil || (channelType != "text" && channelType != "announcement") {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_channel_type", "Only guild text and announcement channels can receive email")
+		return
+	}
+
+	localpart, err := generateEmailLocalpart(name)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to generate email address", err)
+		return
+	}
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO channel_email_addresses (channel_id, localpart, reply_enabled, created_by)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (channel_id) DO UPDATE SET reply_enabled = EXCLUDED.reply_enabled`,
+		channelID, localpart, req.ReplyEnabled, userID); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to set channel email address", err)
+		return
+	}
+
+	addr, err := h.getChannelEmail(r, channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel email address", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, addr)
+}
+
+// HandleDeleteChannelEmail removes the channel's email address. Mail sent to
+// it afterwards is dropped.
+// DELETE /api/v1/channels/{channelID}/email
+func (h *Handler) HandleDeleteChannelEmail(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM channel_email_addresses WHERE channel_id = $1`, channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete channel email address", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "email_not_enabled", "This channel has no email address")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) getChannelEmail(r *http.Request, channelID string) (*channelEmailAddress, error) {
+	var addr channelEmailAddress
+	var localpart string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT channel_id, localpart, reply_enabled, created_by, created_at
+		 FROM channel_email_addresses WHERE channel_id = $1`, channelID,
+	).Scan(&addr.ChannelID, &localpart, &addr.ReplyEnabled, &addr.CreatedBy, &addr.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	addr.Address = localpart + "@" + h.EmailDomain
+	return &addr, nil
+}
+
+// generateEmailLocalpart derives an address localpart from the channel name
+// plus a random suffix, so addresses are readable but not guessable.
+func generateEmailLocalpart(channelName string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToLower(channelName) {
+		if b.Len() >= 24 {
+			break
+		}
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-' || r == '_' || r == ' ' || r == '.':
+			if s := b.String(); s != "" && !strings.HasSuffix(s, "-") {
+				b.WriteByte('-')
+			}
+		}
+	}
+	slug := strings.Trim(b.String(), "-")
+	if slug == "" {
+		slug = "channel"
+	}
+
+	suffix := make([]byte, 5)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return slug + "-" + hex.EncodeToString(suffix), nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -59,11 +60,11 @@ func (h *EmojiHandler) HandleGetChannelEmoji(w http.ResponseWriter, r *http.Requ
 	// Verify the user has access to this channel (is a member of the guild).
 	guildID, err := h.getChannelGuild(r, channelID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if !h.isMember(r, guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -125,11 +126,11 @@ func (h *EmojiHandler) HandleCreateChannelEmoji(w http.ResponseWriter, r *http.R
 	// Verify channel access and get guild ID.
 	guildID, err := h.getChannelGuild(r, channelID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if !h.isMember(r, guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -194,11 +195,11 @@ func (h *EmojiHandler) HandleDeleteChannelEmoji(w http.ResponseWriter, r *http.R
 	// Verify channel access.
 	guildID, err := h.getChannelGuild(r, channelID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if !h.isMember(r, guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -224,7 +225,7 @@ func (h *EmojiHandler) HandleDeleteChannelEmoji(w http.ResponseWriter, r *http.R
 			`SELECT owner_id FROM guilds WHERE id = $1`, guildID,
 		).Scan(&ownerID)
 		if ownerID != userID {
-			apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You can only delete emoji you created")
+			apiutil.WriteCode(w, apierrors.Forbidden, "You can only delete emoji you created")
 			return
 		}
 	}
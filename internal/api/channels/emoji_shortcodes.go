@@ -0,0 +1,50 @@
+package channels
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/amityvox/amityvox/internal/emojicodes"
+)
+
+// normalizeEmojiShortcodes rewrites :shortcode: emoji in content to unicode
+// when the instance has normalization turned on. The guild's aliases apply on
+// top of the standard shortcodes, and names of custom emoji the sender could
+// be using (guild, channel, instance and their own) are left as written.
+func (h *Handler) normalizeEmojiShortcodes(ctx context.Context, guildID *string, channelID, userID string, content *string) {
+	if !h.NormalizeEmoji || content == nil || !strings.Contains(*content, ":") {
+		return
+	}
+
+	rows, err := h.Pool.Query(ctx,
+		`SELECT alias, emoji FROM guild_emoji_aliases WHERE guild_id = $1
+		 UNION ALL SELECT lower(name), '' FROM custom_emoji WHERE guild_id = $1
+		 UNION ALL SELECT lower(name), '' FROM channel_emoji WHERE channel_id = $2
+		 UNION ALL SELECT lower(name), '' FROM instance_emoji
+		 UNION ALL SELECT lower(name), '' FROM user_emoji WHERE user_id = $3`,
+		guildID, channelID, userID)
+	if err != nil {
+		h.Logger.Warn("failed to load emoji aliases", slog.String("error", err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	aliases := make(map[string]string)
+	for rows.Next() {
+		var name, emoji string
+		if err := rows.Scan(&name, &emoji); err != nil {
+			h.Logger.Warn("failed to read emoji aliases", slog.String("error", err.Error()))
+			return
+		}
+		// A custom emoji wins over an alias of the same name.
+		if _, ok := aliases[name]; !ok || emoji == "" {
+			aliases[name] = emoji
+		}
+	}
+	if rows.Err() != nil {
+		return
+	}
+
+	*content = emojicodes.Normalize(*content, aliases)
+}
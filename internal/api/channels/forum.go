@@ -10,6 +10,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/oklog/ulid/v2"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -28,7 +29,7 @@ func (h *Handler) HandleGetForumTags(w http.ResponseWriter, r *http.Request) {
 	channelID := chi.URLParam(r, "channelID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
 		return
 	}
 
@@ -36,7 +37,7 @@ func (h *Handler) HandleGetForumTags(w http.ResponseWriter, r *http.Request) {
 		`SELECT id, channel_id, name, emoji, color, position, created_at
 		 FROM forum_tags WHERE channel_id = $1 ORDER BY position`, channelID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to list tags")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to list tags")
 		return
 	}
 	defer rows.Close()
@@ -63,14 +64,14 @@ func (h *Handler) HandleCreateForumTag(w http.ResponseWriter, r *http.Request) {
 	channelID := chi.URLParam(r, "channelID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
 	// Verify it's a forum channel.
 	var channelType string
 	if err := h.Pool.QueryRow(r.Context(), `SELECT channel_type FROM channels WHERE id = $1`, channelID).Scan(&channelType); err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if channelType != models.ChannelTypeForum {
@@ -119,7 +120,7 @@ func (h *Handler) HandleUpdateForumTag(w http.ResponseWriter, r *http.Request) {
 	tagID := chi.URLParam(r, "tagID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -162,11 +163,11 @@ func (h *Handler) HandleUpdateForumTag(w http.ResponseWriter, r *http.Request) {
 	err := h.Pool.QueryRow(r.Context(), query, args...).Scan(
 		&tag.ID, &tag.ChannelID, &tag.Name, &tag.Emoji, &tag.Color, &tag.Position, &tag.CreatedAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Tag not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Tag not found")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update tag")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update tag")
 		return
 	}
 
@@ -181,18 +182,18 @@ func (h *Handler) HandleDeleteForumTag(w http.ResponseWriter, r *http.Request) {
 	tagID := chi.URLParam(r, "tagID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM forum_tags WHERE id = $1 AND channel_id = $2`, tagID, channelID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete tag")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete tag")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Tag not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Tag not found")
 		return
 	}
 
@@ -210,14 +211,14 @@ func (h *Handler) HandleGetForumPosts(w http.ResponseWriter, r *http.Request) {
 	channelID := chi.URLParam(r, "channelID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
 		return
 	}
 
 	// Verify it's a forum channel.
 	var channelType string
 	if err := h.Pool.QueryRow(r.Context(), `SELECT channel_type FROM channels WHERE id = $1`, channelID).Scan(&channelType); err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if channelType != models.ChannelTypeForum {
@@ -392,7 +393,7 @@ func (h *Handler) HandleCreateForumPost(w http.ResponseWriter, r *http.Request)
 	channelID := chi.URLParam(r, "channelID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.CreateThreads) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need CREATE_THREADS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need CREATE_THREADS permission")
 		return
 	}
 
@@ -404,7 +405,7 @@ func (h *Handler) HandleCreateForumPost(w http.ResponseWriter, r *http.Request)
 		`SELECT channel_type, COALESCE(forum_require_tags, false), guild_id
 		 FROM channels WHERE id = $1`, channelID).Scan(&channelType, &requireTags, &guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if channelType != models.ChannelTypeForum {
@@ -548,7 +549,7 @@ func (h *Handler) HandlePinForumPost(w http.ResponseWriter, r *http.Request) {
 	postID := chi.URLParam(r, "postID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageThreads) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_THREADS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_THREADS permission")
 		return
 	}
 
@@ -557,14 +558,14 @@ func (h *Handler) HandlePinForumPost(w http.ResponseWriter, r *http.Request) {
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT parent_channel_id FROM channels WHERE id = $1`, postID).Scan(&parentID)
 	if err != nil || parentID == nil || *parentID != channelID {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Post not found in this forum")
+		apiutil.WriteCode(w, apierrors.NotFound, "Post not found in this forum")
 		return
 	}
 
 	_, err = h.Pool.Exec(r.Context(),
 		`UPDATE channels SET pinned = NOT pinned WHERE id = $1`, postID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to toggle pin")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to toggle pin")
 		return
 	}
 
@@ -579,7 +580,7 @@ func (h *Handler) HandleCloseForumPost(w http.ResponseWriter, r *http.Request) {
 	postID := chi.URLParam(r, "postID")
 
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageThreads) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_THREADS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_THREADS permission")
 		return
 	}
 
@@ -588,14 +589,14 @@ func (h *Handler) HandleCloseForumPost(w http.ResponseWriter, r *http.Request) {
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT parent_channel_id FROM channels WHERE id = $1`, postID).Scan(&parentID)
 	if err != nil || parentID == nil || *parentID != channelID {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Post not found in this forum")
+		apiutil.WriteCode(w, apierrors.NotFound, "Post not found in this forum")
 		return
 	}
 
 	_, err = h.Pool.Exec(r.Context(),
 		`UPDATE channels SET locked = NOT locked WHERE id = $1`, postID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to toggle post lock")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to toggle post lock")
 		return
 	}
 
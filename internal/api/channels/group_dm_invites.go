@@ -0,0 +1,66 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// groupDMInviteRequired reports whether targetID's group DM privacy keeps
+// inviterID from adding them to a group DM without an invite. Users without
+// the setting, including remote users, can be added by anyone.
+func (h *Handler) groupDMInviteRequired(ctx context.Context, inviterID, targetID string) bool {
+	var privacy string
+	var friends bool
+	h.Pool.QueryRow(ctx,
+		`SELECT COALESCE((SELECT settings->>'group_dm_privacy' FROM user_settings WHERE user_id = $1), ''),
+		        EXISTS(SELECT 1 FROM user_relationships
+		               WHERE user_id = $1 AND target_id = $2 AND status = 'friend')`,
+		targetID, inviterID,
+	).Scan(&privacy, &friends)
+
+	switch privacy {
+	case models.GroupDMPrivacyNobody:
+		return true
+	case models.GroupDMPrivacyFriends:
+		return !friends
+	default:
+		return false
+	}
+}
+
+// inviteToGroupDM records a pending invite for targetID to join the group
+// DM, tells them about it, and writes it with 202 Accepted. Inviting a user
+// again returns the existing invite without telling them twice.
+func (h *Handler) inviteToGroupDM(w http.ResponseWriter, r *http.Request, channelID, inviterID, targetID string) {
+	tag, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO group_dm_invites (id, channel_id, inviter_id, target_id, created_at)
+		 VALUES ($1, $2, $3, $4, now())
+		 ON CONFLICT (channel_id, target_id) DO NOTHING`,
+		models.NewULID().String(), channelID, inviterID, targetID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to invite recipient", err)
+		return
+	}
+
+	var invite models.GroupDMInvite
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT i.id, i.channel_id, c.name, i.inviter_id, i.target_id, i.created_at
+		 FROM group_dm_invites i
+		 JOIN channels c ON c.id = i.channel_id
+		 WHERE i.channel_id = $1 AND i.target_id = $2`,
+		channelID, targetID,
+	).Scan(&invite.ID, &invite.ChannelID, &invite.ChannelName, &invite.InviterID,
+		&invite.TargetID, &invite.CreatedAt); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get invite", err)
+		return
+	}
+
+	if tag.RowsAffected() > 0 {
+		h.EventBus.PublishUserEvent(r.Context(), events.SubjectGroupDMInvite, "GROUP_DM_INVITE_CREATE", targetID, invite)
+	}
+	apiutil.WriteJSON(w, http.StatusAccepted, invite)
+}
@@ -0,0 +1,227 @@
+// Package channels — import.go implements bulk message import for bridges and
+// history importers. Imported messages keep their original timestamps and
+// author names and are written in one transaction without per-message events.
+package channels
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxImportBatch is the most messages one import request may carry.
+const maxImportBatch = 500
+
+// importMessage is one message in an import batch.
+type importMessage struct {
+	RemoteID        string     `json:"remote_id"`
+	Content         string     `json:"content"`
+	CreatedAt       time.Time  `json:"created_at"`
+	EditedAt        *time.Time `json:"edited_at"`
+	AuthorName      string     `json:"author_name"`
+	AuthorAvatar    *string    `json:"author_avatar"`
+	AuthorColor     *string    `json:"author_color"`
+	ReplyToRemoteID string     `json:"reply_to_remote_id"`
+}
+
+type importMessagesRequest struct {
+	Source   string          `json:"source"`
+	Messages []importMessage `json:"messages"`
+}
+
+// importedMessage maps a remote message ID to the local one.
+type importedMessage struct {
+	RemoteID  string `json:"remote_id"`
+	MessageID string `json:"message_id"`
+}
+
+// validateImportBatch checks an import request, returning an error code and
+// message for the first problem found.
+func validateImportBatch(req importMessagesRequest, now time.Time) (string, string) {
+	if req.Source == "" || len(req.Source) > 32 {
+		return "invalid_source", "source is required and must be at most 32 characters"
+	}
+	if len(req.Messages) == 0 || len(req.Messages) > maxImportBatch {
+		return "invalid_batch_size", "Between 1 and 500 messages may be imported per request"
+	}
+	seen := make(map[string]bool, len(req.Messages))
+	for _, m := range req.Messages {
+		if m.RemoteID == "" {
+			return "missing_remote_id", "Every message needs a remote_id"
+		}
+		if seen[m.RemoteID] {
+			return "duplicate_remote_id", "remote_id " + m.RemoteID + " appears more than once"
+		}
+		seen[m.RemoteID] = true
+		if strings.TrimSpace(m.Content) == "" || len(m.Content) > 4000 {
+			return "invalid_content", "Message content must be 1-4000 characters"
+		}
+		if m.CreatedAt.IsZero() || m.CreatedAt.After(now.Add(time.Minute)) {
+			return "invalid_timestamp", "created_at is required and may not be in the future"
+		}
+		if m.AuthorName == "" || len(m.AuthorName) > 80 {
+			return "invalid_author_name", "author_name is required and must be at most 80 characters"
+		}
+	}
+	return "", ""
+}
+
+// HandleImportMessages inserts a batch of historical messages with their
+// original timestamps, shown under the original author's name. Messages whose
+// remote_id was already imported into the channel are skipped, so a failed
+// batch can be retried as-is. Only bots granted the messages.import scope in
+// the guild may import.
+// POST /api/v1/channels/{channelID}/messages/import
+func (h *Handler) HandleImportMessages(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	cc, err := h.loadChannelCtx(r.Context(), channelID, userID)
+	if err != nil {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+		return
+	}
+	if cc.GuildID == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_channel_type", "Messages can only be imported into guild channels")
+		return
+	}
+	if cc.UserFlags&models.UserFlagBot == 0 || !h.botHasScope(r.Context(), userID, *cc.GuildID, models.BotScopeMessagesImport) {
+		apiutil.WriteError(w, http.StatusForbidden, "missing_scope", "Importing requires a bot with the messages.import scope in this guild")
+		return
+	}
+	if !cc.hasPerm(permissions.SendMessages) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need SEND_MESSAGES permission")
+		return
+	}
+
+	var req importMessagesRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Source = strings.ToLower(strings.TrimSpace(req.Source))
+	if code, msg := validateImportBatch(req, time.Now()); code != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, code, msg)
+		return
+	}
+
+	// Insert oldest first so replies can point at messages earlier in the batch.
+	sort.SliceStable(req.Messages, func(i, j int) bool {
+		return req.Messages[i].CreatedAt.Before(req.Messages[j].CreatedAt)
+	})
+
+	imported := make([]importedMessage, 0, len(req.Messages))
+	skipped := 0
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		remoteIDs := make([]string, 0, len(req.Messages))
+		for _, m := range req.Messages {
+			remoteIDs = append(remoteIDs, m.RemoteID)
+			if m.ReplyToRemoteID != "" {
+				remoteIDs = append(remoteIDs, m.ReplyToRemoteID)
+			}
+		}
+
+		// Local IDs of remote messages already in the channel, for duplicate
+		// detection and reply resolution.
+		local := make(map[string]string)
+		rows, err := tx.Query(r.Context(),
+			`SELECT bridge_remote_id, id FROM messages
+			 WHERE channel_id = $1 AND bridge_source = $2 AND bridge_remote_id = ANY($3)`,
+			channelID, req.Source, remoteIDs)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var remoteID, id string
+			if err := rows.Scan(&remoteID, &id); err != nil {
+				rows.Close()
+				return err
+			}
+			local[remoteID] = id
+		}
+		rows.Close()
+
+		batch := &pgx.Batch{}
+		for _, m := range req.Messages {
+			if _, exists := local[m.RemoteID]; exists {
+				skipped++
+				continue
+			}
+			id := models.NewULIDWithTime(m.CreatedAt).String()
+			local[m.RemoteID] = id
+
+			msgType := models.MessageTypeDefault
+			var replyTo []string
+			if parent, ok := local[m.ReplyToRemoteID]; ok && m.ReplyToRemoteID != "" {
+				msgType = models.MessageTypeReply
+				replyTo = []string{parent}
+			}
+
+			batch.Queue(
+				`INSERT INTO messages (id, channel_id, author_id, content, message_type, reply_to_ids,
+				                       masquerade_name, masquerade_avatar, masquerade_color,
+				                       bridge_source, bridge_remote_id, bridge_author_name,
+				                       edited_at, created_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $7, $12, $13)`,
+				id, channelID, userID, m.Content, msgType, replyTo,
+				m.AuthorName, m.AuthorAvatar, m.AuthorColor,
+				req.Source, m.RemoteID, m.EditedAt, m.CreatedAt)
+			imported = append(imported, importedMessage{RemoteID: m.RemoteID, MessageID: id})
+		}
+		if len(imported) == 0 {
+			return nil
+		}
+		if err := tx.SendBatch(r.Context(), batch).Close(); err != nil {
+			return err
+		}
+
+		// Historical messages only move last_message_id forward.
+		newest := imported[len(imported)-1].MessageID
+		_, err = tx.Exec(r.Context(),
+			`UPDATE channels SET last_message_id = $1
+			 WHERE id = $2 AND (last_message_id IS NULL OR last_message_id < $1)`,
+			newest, channelID)
+		return err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to import messages", err)
+		return
+	}
+
+	if len(imported) > 0 {
+		h.EventBus.PublishChannelEvent(r.Context(), events.SubjectMessageImport, "MESSAGE_IMPORT", channelID, map[string]interface{}{
+			"channel_id":       channelID,
+			"guild_id":         *cc.GuildID,
+			"source":           req.Source,
+			"count":            len(imported),
+			"first_message_id": imported[0].MessageID,
+			"last_message_id":  imported[len(imported)-1].MessageID,
+		})
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"imported": len(imported),
+		"skipped":  skipped,
+		"messages": imported,
+	})
+}
+
+// botHasScope reports whether a bot has been granted scope in a guild.
+func (h *Handler) botHasScope(ctx context.Context, botID, guildID, scope string) bool {
+	var ok bool
+	h.Pool.QueryRow(ctx,
+		`SELECT $3 = ANY(scopes) FROM bot_guild_permissions WHERE bot_id = $1 AND guild_id = $2`,
+		botID, guildID, scope).Scan(&ok)
+	return ok
+}
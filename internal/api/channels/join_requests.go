@@ -0,0 +1,457 @@
+// Package channels — join requests for private channels. A channel opened to
+// requests shows up in its guild's requestable list; members who cannot see
+// it ask to join, and holders of the approver role decide. Approval adds a
+// user permission override so the member can see the channel.
+package channels
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// joinGrant is what an approved request allows in the channel.
+const joinGrant = permissions.ViewChannel | permissions.ReadHistory
+
+const joinRequestColumns = `id, channel_id, guild_id, user_id, message, status, reviewer_id, reviewed_at, created_at`
+
+func scanJoinRequest(row pgx.Row) (models.ChannelJoinRequest, error) {
+	var jr models.ChannelJoinRequest
+	err := row.Scan(&jr.ID, &jr.ChannelID, &jr.GuildID, &jr.UserID, &jr.Message, &jr.Status,
+		&jr.ReviewerID, &jr.ReviewedAt, &jr.CreatedAt)
+	return jr, err
+}
+
+// RequestableChannel is a private channel listed for members to ask to join.
+type RequestableChannel struct {
+	ID             string  `json:"id"`
+	Name           *string `json:"name,omitempty"`
+	Topic          *string `json:"topic,omitempty"`
+	Description    *string `json:"description,omitempty"`
+	ApproverRoleID *string `json:"approver_role_id,omitempty"`
+	Pending        bool    `json:"pending"`
+}
+
+// joinSettings loads a channel's join request settings. ok is false if the
+// channel does not accept requests.
+func (h *Handler) joinSettings(ctx context.Context, channelID string) (guildID string, approverRoleID *string, ok bool) {
+	err := h.Pool.QueryRow(ctx,
+		`SELECT c.guild_id, s.approver_role_id
+		 FROM channel_join_settings s JOIN channels c ON c.id = s.channel_id
+		 WHERE s.channel_id = $1 AND c.guild_id IS NOT NULL`, channelID,
+	).Scan(&guildID, &approverRoleID)
+	return guildID, approverRoleID, err == nil
+}
+
+// canReviewJoinRequests reports whether the user may approve or deny requests
+// for the channel: MANAGE_CHANNELS holders and members with the approver role.
+func (h *Handler) canReviewJoinRequests(ctx context.Context, channelID, guildID, userID string, approverRoleID *string) bool {
+	if h.hasChannelPermission(ctx, channelID, userID, permissions.ManageChannels) {
+		return true
+	}
+	if approverRoleID == nil {
+		return false
+	}
+	var ok bool
+	h.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM member_roles WHERE guild_id = $1 AND user_id = $2 AND role_id = $3)`,
+		guildID, userID, *approverRoleID).Scan(&ok)
+	return ok
+}
+
+// canViewChannel computes whether a guild member can see the channel,
+// channel overrides included.
+func (h *Handler) canViewChannel(ctx context.Context, guildID, channelID, userID string) bool {
+	var guild permissions.GuildInfo
+	var defaultPerms int64
+	var everyone *int64
+	err := h.Pool.QueryRow(ctx,
+		`SELECT g.owner_id, g.default_permissions, c.default_permissions
+		 FROM guilds g JOIN channels c ON c.guild_id = g.id
+		 WHERE g.id = $1 AND c.id = $2`, guildID, channelID,
+	).Scan(&guild.OwnerID, &defaultPerms, &everyone)
+	if err != nil {
+		return false
+	}
+	guild.DefaultPermissions = uint64(defaultPerms)
+
+	var roles []permissions.RoleInfo
+	rows, err := h.Pool.Query(ctx,
+		`SELECT r.id, r.position, r.permissions_allow, r.permissions_deny
+		 FROM roles r JOIN member_roles mr ON mr.role_id = r.id
+		 WHERE mr.guild_id = $1 AND mr.user_id = $2
+		 ORDER BY r.position DESC`, guildID, userID)
+	if err != nil {
+		return false
+	}
+	for rows.Next() {
+		var ri permissions.RoleInfo
+		var allow, deny int64
+		if rows.Scan(&ri.ID, &ri.Position, &allow, &deny) == nil {
+			ri.PermissionsAllow, ri.PermissionsDeny = uint64(allow), uint64(deny)
+			roles = append(roles, ri)
+		}
+	}
+	rows.Close()
+
+	channel := permissions.ChannelInfo{}
+	if everyone != nil {
+		allow := uint64(*everyone)
+		channel.DefaultPermissionsAllow = &allow
+	}
+	rows, err = h.Pool.Query(ctx,
+		`SELECT target_type, target_id, permissions_allow, permissions_deny
+		 FROM channel_permission_overrides WHERE channel_id = $1`, channelID)
+	if err != nil {
+		return false
+	}
+	for rows.Next() {
+		var o permissions.ChannelOverride
+		var allow, deny int64
+		if rows.Scan(&o.TargetType, &o.TargetID, &allow, &deny) == nil {
+			o.PermissionsAllow, o.PermissionsDeny = uint64(allow), uint64(deny)
+			channel.Overrides = append(channel.Overrides, o)
+		}
+	}
+	rows.Close()
+
+	perms := permissions.CalculatePermissions(permissions.MemberInfo{UserID: userID}, guild, roles, &channel)
+	return perms&permissions.ViewChannel != 0
+}
+
+// logJoinAudit records a join request decision in the guild audit log.
+func (h *Handler) logJoinAudit(ctx context.Context, guildID, actorID, action, requestID string) {
+	h.Pool.Exec(ctx,
+		`INSERT INTO audit_log (id, guild_id, actor_id, action, target_type, target_id, created_at)
+		 VALUES ($1, $2, $3, $4, 'channel_join_request', $5, now())`,
+		models.NewULID().String(), guildID, actorID, action, requestID)
+}
+
+// HandleGetRequestableChannels lists the guild's channels that accept join
+// requests, marking the ones the caller has a pending request for.
+// GET /api/v1/guilds/{guildID}/channels/requestable
+func (h *Handler) HandleGetRequestableChannels(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	var isMember bool
+	h.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
+		guildID, userID).Scan(&isMember)
+	if !isMember {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT c.id, c.name, c.topic, s.description, s.approver_role_id,
+		        EXISTS(SELECT 1 FROM channel_join_requests jr
+		               WHERE jr.channel_id = c.id AND jr.user_id = $2 AND jr.status = 'pending')
+		 FROM channel_join_settings s JOIN channels c ON c.id = s.channel_id
+		 WHERE c.guild_id = $1
+		 ORDER BY c.position, c.id`, guildID, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get requestable channels", err)
+		return
+	}
+	defer rows.Close()
+
+	channels := []RequestableChannel{}
+	for rows.Next() {
+		var c RequestableChannel
+		if err := rows.Scan(&c.ID, &c.Name, &c.Topic, &c.Description, &c.ApproverRoleID, &c.Pending); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read requestable channels", err)
+			return
+		}
+		channels = append(channels, c)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, channels)
+}
+
+// HandleSetJoinSettings opens a channel to join requests or changes its
+// approver role.
+// PUT /api/v1/channels/{channelID}/join-settings
+//
+// Request body: {"approver_role_id": "...", "description": "..."}
+func (h *Handler) HandleSetJoinSettings(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	var req struct {
+		ApproverRoleID *string `json:"approver_role_id"`
+		Description    *string `json:"description"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Description != nil && len(*req.Description) > 500 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_description", "Description must be at most 500 characters")
+		return
+	}
+
+	var guildID *string
+	h.Pool.QueryRow(r.Context(), `SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	if guildID == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "not_guild_channel", "Only guild channels can accept join requests")
+		return
+	}
+	if req.ApproverRoleID != nil {
+		var ok bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM roles WHERE id = $1 AND guild_id = $2)`,
+			*req.ApproverRoleID, *guildID).Scan(&ok)
+		if !ok {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_role", "Approver role must belong to this guild")
+			return
+		}
+	}
+
+	var s RequestableChannel
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO channel_join_settings (channel_id, approver_role_id, description)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (channel_id) DO UPDATE SET approver_role_id = $2, description = $3
+		 RETURNING channel_id, approver_role_id, description`,
+		channelID, req.ApproverRoleID, req.Description,
+	).Scan(&s.ID, &s.ApproverRoleID, &s.Description)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to save join settings", err)
+		return
+	}
+
+	h.EventBus.PublishChannelEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_UPDATE", channelID, map[string]string{
+		"channel_id": channelID,
+	})
+
+	apiutil.WriteJSON(w, http.StatusOK, s)
+}
+
+// HandleDeleteJoinSettings stops a channel from accepting join requests.
+// Pending requests are denied.
+// DELETE /api/v1/channels/{channelID}/join-settings
+func (h *Handler) HandleDeleteJoinSettings(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`DELETE FROM channel_join_settings WHERE channel_id = $1`, channelID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(r.Context(),
+			`UPDATE channel_join_requests SET status = 'denied', reviewer_id = $2, reviewed_at = now()
+			 WHERE channel_id = $1 AND status = 'pending'`, channelID, userID)
+		return err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to remove join settings", err)
+		return
+	}
+
+	apiutil.WriteNoContent(w)
+}
+
+// HandleCreateJoinRequest asks to join a channel.
+// POST /api/v1/channels/{channelID}/join-requests
+//
+// Request body: {"message": "optional note for the approvers"}
+func (h *Handler) HandleCreateJoinRequest(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	var req struct {
+		Message *string `json:"message"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Message != nil {
+		msg := strings.TrimSpace(*req.Message)
+		if len(msg) > 500 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_message", "Message must be at most 500 characters")
+			return
+		}
+		req.Message = &msg
+		if msg == "" {
+			req.Message = nil
+		}
+	}
+
+	guildID, _, ok := h.joinSettings(r.Context(), channelID)
+	if !ok {
+		apiutil.WriteError(w, http.StatusNotFound, "not_requestable", "This channel does not accept join requests")
+		return
+	}
+	var isMember bool
+	h.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
+		guildID, userID).Scan(&isMember)
+	if !isMember {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+	if h.canViewChannel(r.Context(), guildID, channelID, userID) {
+		apiutil.WriteError(w, http.StatusConflict, "already_has_access", "You can already see this channel")
+		return
+	}
+
+	jr, err := scanJoinRequest(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO channel_join_requests (id, channel_id, guild_id, user_id, message)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+joinRequestColumns,
+		models.NewULID().String(), channelID, guildID, userID, req.Message))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			apiutil.WriteError(w, http.StatusConflict, "request_pending", "You already have a pending request for this channel")
+			return
+		}
+		apiutil.InternalError(w, h.Logger, "Failed to create join request", err)
+		return
+	}
+
+	h.logJoinAudit(r.Context(), guildID, userID, "channel_join_request_create", jr.ID)
+
+	apiutil.WriteJSON(w, http.StatusCreated, jr)
+}
+
+// HandleGetJoinRequests lists a channel's join requests, pending ones by
+// default. Pass ?status=approved or ?status=denied for the history.
+// GET /api/v1/channels/{channelID}/join-requests
+func (h *Handler) HandleGetJoinRequests(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.JoinRequestPending
+	}
+	if status != models.JoinRequestPending && status != models.JoinRequestApproved && status != models.JoinRequestDenied {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_status", "Status must be pending, approved or denied")
+		return
+	}
+
+	guildID, approverRoleID, ok := h.joinSettings(r.Context(), channelID)
+	if !ok {
+		apiutil.WriteError(w, http.StatusNotFound, "not_requestable", "This channel does not accept join requests")
+		return
+	}
+	if !h.canReviewJoinRequests(r.Context(), channelID, guildID, userID, approverRoleID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_approver", "You cannot review join requests for this channel")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+joinRequestColumns+` FROM channel_join_requests
+		 WHERE channel_id = $1 AND status = $2
+		 ORDER BY created_at LIMIT 100`, channelID, status)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get join requests", err)
+		return
+	}
+	defer rows.Close()
+
+	requests := []models.ChannelJoinRequest{}
+	for rows.Next() {
+		jr, err := scanJoinRequest(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read join requests", err)
+			return
+		}
+		requests = append(requests, jr)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, requests)
+}
+
+// HandleApproveJoinRequest approves a pending request and grants the member
+// access through a user permission override.
+// POST /api/v1/channels/{channelID}/join-requests/{requestID}/approve
+func (h *Handler) HandleApproveJoinRequest(w http.ResponseWriter, r *http.Request) {
+	h.reviewJoinRequest(w, r, models.JoinRequestApproved)
+}
+
+// HandleDenyJoinRequest denies a pending request.
+// POST /api/v1/channels/{channelID}/join-requests/{requestID}/deny
+func (h *Handler) HandleDenyJoinRequest(w http.ResponseWriter, r *http.Request) {
+	h.reviewJoinRequest(w, r, models.JoinRequestDenied)
+}
+
+func (h *Handler) reviewJoinRequest(w http.ResponseWriter, r *http.Request, decision string) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	requestID := chi.URLParam(r, "requestID")
+
+	guildID, approverRoleID, ok := h.joinSettings(r.Context(), channelID)
+	if !ok {
+		apiutil.WriteError(w, http.StatusNotFound, "not_requestable", "This channel does not accept join requests")
+		return
+	}
+	if !h.canReviewJoinRequests(r.Context(), channelID, guildID, userID, approverRoleID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_approver", "You cannot review join requests for this channel")
+		return
+	}
+
+	var jr models.ChannelJoinRequest
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		var err error
+		jr, err = scanJoinRequest(tx.QueryRow(r.Context(),
+			`UPDATE channel_join_requests SET status = $3, reviewer_id = $4, reviewed_at = now()
+			 WHERE id = $1 AND channel_id = $2 AND status = 'pending'
+			 RETURNING `+joinRequestColumns,
+			requestID, channelID, decision, userID))
+		if err != nil || decision != models.JoinRequestApproved {
+			return err
+		}
+		// Keep any other permissions the member's override already holds.
+		_, err = tx.Exec(r.Context(),
+			`INSERT INTO channel_permission_overrides (channel_id, target_type, target_id, permissions_allow, permissions_deny)
+			 VALUES ($1, 'user', $2, $3, 0)
+			 ON CONFLICT (channel_id, target_type, target_id) DO UPDATE
+			 SET permissions_allow = channel_permission_overrides.permissions_allow | $3,
+			     permissions_deny = channel_permission_overrides.permissions_deny & ~$3::bigint`,
+			channelID, jr.UserID, int64(joinGrant))
+		return err
+	})
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "request_not_found", "No pending join request with that ID")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to review join request", err)
+		return
+	}
+
+	h.logJoinAudit(r.Context(), guildID, userID, "channel_join_request_"+decision, jr.ID)
+	h.EventBus.PublishUserEvent(r.Context(), events.SubjectUserUpdate, "CHANNEL_JOIN_REQUEST_UPDATE", jr.UserID, jr)
+	if decision == models.JoinRequestApproved {
+		h.EventBus.PublishChannelEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_UPDATE", channelID, map[string]string{
+			"channel_id": channelID,
+		})
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, jr)
+}
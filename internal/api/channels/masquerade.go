@@ -0,0 +1,69 @@
+package channels
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on the masquerade a bot may post a message under.
+const (
+	maxMasqueradeName   = 80
+	maxMasqueradeAvatar = 2048
+)
+
+var masqueradeColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// messageMasquerade is the name, avatar and color a bridged message is shown
+// with in place of the bot's own profile. An empty avatar or color is the
+// same as leaving it out.
+type messageMasquerade struct {
+	Name   *string `json:"name"`
+	Avatar *string `json:"avatar"`
+	Color  *string `json:"color"`
+}
+
+// normalize cleans the masquerade and returns the values to store, or an
+// error message safe to return to the caller.
+func (m messageMasquerade) normalize() (name, avatar, color *string, err error) {
+	if m.Name != nil {
+		n := strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+				return -1
+			}
+			return r
+		}, *m.Name)
+		n = strings.Join(strings.Fields(n), " ")
+		if utf8.RuneCountInString(n) > maxMasqueradeName {
+			return nil, nil, nil, fmt.Errorf("masquerade.name must be at most %d characters", maxMasqueradeName)
+		}
+		if n != "" {
+			name = &n
+		}
+	}
+	if m.Avatar != nil {
+		if a := strings.TrimSpace(*m.Avatar); a != "" {
+			u, perr := url.Parse(a)
+			if perr != nil || u.Scheme != "https" || u.Host == "" || len(a) > maxMasqueradeAvatar {
+				return nil, nil, nil, fmt.Errorf("masquerade.avatar must be an https URL")
+			}
+			avatar = &a
+		}
+	}
+	if m.Color != nil {
+		if c := strings.TrimSpace(*m.Color); c != "" {
+			if !masqueradeColorPattern.MatchString(c) {
+				return nil, nil, nil, fmt.Errorf("masquerade.color must be a hex color like #5865f2")
+			}
+			c = strings.ToLower(c)
+			color = &c
+		}
+	}
+	if name == nil && avatar == nil && color == nil {
+		return nil, nil, nil, fmt.Errorf("masquerade must set a name, avatar or color")
+	}
+	return name, avatar, color, nil
+}
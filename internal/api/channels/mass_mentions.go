@@ -0,0 +1,72 @@
+package channels
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// Limits on mass mentions (@everyone, @here and role mentions) in new
+// messages. A message over either limit is still posted, but without its
+// mass mentions, so nobody gets pinged.
+const (
+	// maxMentionReach is the most users one message may notify.
+	maxMentionReach = 2500
+	// massMentionHourlyLimit is how many messages with mass mentions a user
+	// may post per hour.
+	massMentionHourlyLimit = 10
+)
+
+// mentionReach estimates how many users a message notifies. A guild-wide
+// mention reaches every member, so role and user mentions add nothing to it.
+func mentionReach(guildWide bool, memberCount, roleMembers, users int) int {
+	if guildWide {
+		return memberCount
+	}
+	return roleMembers + users
+}
+
+// limitMassMentions clears the mass mentions of a new message when it would
+// notify more than maxMentionReach users or the author is over the hourly
+// limit. Guild owners, instance admins and Administrators are exempt.
+func (h *Handler) limitMassMentions(ctx context.Context, cc *channelCtx, userID string,
+	everyone, here *bool, roleIDs *[]string, userMentions int) {
+	if cc.GuildID == nil || (!*everyone && !*here && len(*roleIDs) == 0) {
+		return
+	}
+	if cc.hasPerm(permissions.Administrator) {
+		return
+	}
+	downgrade := func(reason string) {
+		h.Logger.Debug("mass mention downgraded",
+			slog.String("user_id", userID), slog.String("reason", reason))
+		*everyone, *here, *roleIDs = false, false, nil
+	}
+
+	var memberCount, roleMembers int
+	if *everyone || *here {
+		h.Pool.QueryRow(ctx, `SELECT member_count FROM guilds WHERE id = $1`, *cc.GuildID).Scan(&memberCount)
+	} else {
+		h.Pool.QueryRow(ctx,
+			`SELECT COUNT(DISTINCT user_id) FROM member_roles WHERE guild_id = $1 AND role_id = ANY($2)`,
+			*cc.GuildID, *roleIDs).Scan(&roleMembers)
+	}
+	if mentionReach(*everyone || *here, memberCount, roleMembers, userMentions) > maxMentionReach {
+		downgrade("reach")
+		return
+	}
+
+	if h.Cache == nil {
+		return
+	}
+	n, err := h.Cache.IncrWindowCount(ctx, "mass_mentions:"+userID, time.Hour)
+	if err != nil {
+		h.Logger.Warn("failed to count mass mentions", slog.String("error", err.Error()))
+		return
+	}
+	if n > massMentionHourlyLimit {
+		downgrade("hourly_limit")
+	}
+}
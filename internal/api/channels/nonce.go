@@ -0,0 +1,108 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// --- Message Nonces ---
+//
+// A client may tag a send with a nonce. The nonce is stored with the message
+// and comes back in the response and in MESSAGE_CREATE, so the client can
+// swap its optimistic echo for the real message. Nonces are unique per
+// channel: resending with the same nonce returns the message already created
+// instead of posting it twice, and a client that lost the response can look
+// the message up by its nonce.
+
+// maxNonceLength bounds a client nonce. Snowflakes, ULIDs and UUIDs all fit.
+const maxNonceLength = 64
+
+// errNonceTaken is returned by messageByNonce when another user's message
+// already carries the nonce.
+var errNonceTaken = errors.New("nonce used by another author")
+
+// isNonceConflict reports whether err is the messages (channel_id, nonce)
+// unique constraint failing.
+func isNonceConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "messages_channel_id_nonce_key"
+}
+
+// messageByNonce loads userID's message in channelID carrying nonce, with its
+// attachments and author. It returns pgx.ErrNoRows when no message has the
+// nonce and errNonceTaken when another user's does.
+func (h *Handler) messageByNonce(ctx context.Context, channelID, userID, nonce string) (*models.Message, error) {
+	var messageID, authorID string
+	err := h.Pool.QueryRow(ctx,
+		`SELECT id, author_id FROM messages WHERE channel_id = $1 AND nonce = $2`,
+		channelID, nonce).Scan(&messageID, &authorID)
+	if err != nil {
+		return nil, err
+	}
+	if authorID != userID {
+		return nil, errNonceTaken
+	}
+	msg, err := h.getMessage(ctx, channelID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	msg.Attachments = h.loadAttachments(ctx, messageID)
+	h.enrichMessageWithAuthor(ctx, msg)
+	return msg, nil
+}
+
+// writeDeliveredMessage answers a send whose nonce was already used. The
+// sender's own message is returned with 200 rather than 201, so a retry
+// reads as an acknowledgment of the first attempt. It returns false when no
+// message has the nonce and the send should go ahead.
+func (h *Handler) writeDeliveredMessage(w http.ResponseWriter, r *http.Request, channelID, userID, nonce string) bool {
+	msg, err := h.messageByNonce(r.Context(), channelID, userID, nonce)
+	switch {
+	case err == pgx.ErrNoRows:
+		return false
+	case errors.Is(err, errNonceTaken):
+		apiutil.WriteError(w, http.StatusConflict, "nonce_conflict", "This nonce is already used in this channel")
+	case err != nil:
+		apiutil.InternalError(w, h.Logger, "Failed to look up message nonce", err)
+	default:
+		apiutil.WriteJSON(w, http.StatusOK, msg)
+	}
+	return true
+}
+
+// HandleGetMessageByNonce returns the caller's message sent with a nonce,
+// for clients that lost the response to a send and missed its
+// MESSAGE_CREATE.
+// GET /api/v1/channels/{channelID}/messages/nonce/{nonce}
+func (h *Handler) HandleGetMessageByNonce(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	nonce := chi.URLParam(r, "nonce")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
+		return
+	}
+
+	msg, err := h.messageByNonce(r.Context(), channelID, userID, nonce)
+	if err == pgx.ErrNoRows || errors.Is(err, errNonceTaken) {
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "No message of yours has this nonce")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up message nonce", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, msg)
+}
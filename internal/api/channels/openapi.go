@@ -0,0 +1,25 @@
+package channels
+
+import (
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/openapi"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Operations describes the request and response bodies of the main channel
+// and message routes for the OpenAPI document.
+var Operations = []openapi.Operation{
+	{Method: "GET", Path: "/channels/{channelID}", Summary: "Get a channel", Response: models.Channel{}},
+	{Method: "PATCH", Path: "/channels/{channelID}", Summary: "Update a channel", Request: updateChannelRequest{}, Response: models.Channel{}},
+	{Method: "DELETE", Path: "/channels/{channelID}", Summary: "Delete a channel", Status: http.StatusNoContent},
+	{Method: "PATCH", Path: "/channels/{channelID}/permissions", Summary: "Replace all permission overrides", Request: replaceChannelPermissionsRequest{}, Response: channelPermissionsResponse{}},
+	{Method: "GET", Path: "/channels/{channelID}/messages", Summary: "List messages", Response: []models.Message{}},
+	{Method: "POST", Path: "/channels/{channelID}/messages", Summary: "Send a message", Request: createMessageRequest{}, Response: models.Message{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/channels/{channelID}/messages/nonce/{nonce}", Summary: "Get your message by nonce", Response: models.Message{}},
+	{Method: "GET", Path: "/channels/{channelID}/messages/deleted", Summary: "List soft-deleted messages", Response: []deletedMessage{}},
+	{Method: "POST", Path: "/channels/{channelID}/messages/{messageID}/restore", Summary: "Restore a soft-deleted message", Response: models.Message{}},
+	{Method: "GET", Path: "/channels/{channelID}/messages/{messageID}", Summary: "Get a message", Response: models.Message{}},
+	{Method: "PATCH", Path: "/channels/{channelID}/messages/{messageID}", Summary: "Edit a message", Request: updateMessageRequest{}, Response: models.Message{}},
+	{Method: "DELETE", Path: "/channels/{channelID}/messages/{messageID}", Summary: "Delete a message", Status: http.StatusNoContent},
+}
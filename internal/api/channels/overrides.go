@@ -0,0 +1,196 @@
+package channels
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxChannelOverrides caps how many overrides one replace may set.
+const maxChannelOverrides = 250
+
+// channelOverride is one entry of a full override set.
+type channelOverride struct {
+	TargetType       string `json:"target_type"`
+	TargetID         string `json:"target_id"`
+	PermissionsAllow int64  `json:"permissions_allow"`
+	PermissionsDeny  int64  `json:"permissions_deny"`
+}
+
+// replaceChannelPermissionsRequest is the complete override set of a
+// channel. SampleUserID picks the member whose effective permissions are
+// returned; it defaults to the caller.
+type replaceChannelPermissionsRequest struct {
+	Overrides    []channelOverride `json:"overrides"`
+	SampleUserID *string           `json:"sample_user_id"`
+}
+
+// effectivePermissions is a member's computed permissions in a channel.
+type effectivePermissions struct {
+	UserID          string   `json:"user_id"`
+	Permissions     int64    `json:"permissions"`
+	PermissionNames []string `json:"permission_names"`
+}
+
+// channelPermissionsResponse is the saved override set and the effective
+// permissions of the sample member under it.
+type channelPermissionsResponse struct {
+	Overrides []models.ChannelPermissionOverride `json:"overrides"`
+	Effective effectivePermissions               `json:"effective"`
+}
+
+// validateOverrideSet checks the shape of an override set and returns an
+// error code and message, or "" when it is valid. Whether the targets exist
+// is checked against the guild separately.
+func validateOverrideSet(overrides []channelOverride) (code, msg string) {
+	if len(overrides) > maxChannelOverrides {
+		return "too_many_overrides", "A channel can have at most 250 permission overrides"
+	}
+	seen := make(map[string]bool, len(overrides))
+	for _, o := range overrides {
+		if o.TargetType != models.OverrideTargetRole && o.TargetType != models.OverrideTargetUser {
+			return "invalid_target_type", "Target type must be 'role' or 'user'"
+		}
+		if o.TargetID == "" {
+			return "invalid_target_id", "Every override needs a target_id"
+		}
+		key := o.TargetType + ":" + o.TargetID
+		if seen[key] {
+			return "duplicate_override", "Target " + o.TargetID + " appears more than once"
+		}
+		seen[key] = true
+	}
+	return "", ""
+}
+
+// HandleReplaceChannelPermissions replaces every permission override of a
+// guild channel in one transaction, so a permission editor can save what it
+// shows without racing other edits half-applied. Role targets must be roles
+// of the guild and user targets its members. The response carries the saved
+// set and the sample member's effective permissions under it.
+// PATCH /api/v1/channels/{channelID}/permissions
+func (h *Handler) HandleReplaceChannelPermissions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	var req replaceChannelPermissionsRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if code, msg := validateOverrideSet(req.Overrides); code != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, code, msg)
+		return
+	}
+
+	var guildID *string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel", err)
+		return
+	}
+	if guildID == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "not_guild_channel", "Only guild channels have permission overrides")
+		return
+	}
+
+	sampleID := userID
+	if req.SampleUserID != nil && *req.SampleUserID != "" {
+		sampleID = *req.SampleUserID
+	}
+
+	errUnknownTarget := errors.New("unknown override target")
+	var unknown string
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		for _, o := range req.Overrides {
+			var exists bool
+			query := `SELECT EXISTS(SELECT 1 FROM roles WHERE guild_id = $1 AND id = $2)`
+			if o.TargetType == models.OverrideTargetUser {
+				query = `SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`
+			}
+			if err := tx.QueryRow(r.Context(), query, *guildID, o.TargetID).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				unknown = o.TargetType + " " + o.TargetID
+				return errUnknownTarget
+			}
+		}
+
+		if _, err := tx.Exec(r.Context(),
+			`DELETE FROM channel_permission_overrides WHERE channel_id = $1`, channelID); err != nil {
+			return err
+		}
+		for _, o := range req.Overrides {
+			if _, err := tx.Exec(r.Context(),
+				`INSERT INTO channel_permission_overrides (channel_id, target_type, target_id, permissions_allow, permissions_deny)
+				 VALUES ($1, $2, $3, $4, $5)`,
+				channelID, o.TargetType, o.TargetID, o.PermissionsAllow, o.PermissionsDeny); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errUnknownTarget) {
+		apiutil.WriteError(w, http.StatusBadRequest, "unknown_target", "No "+unknown+" in this guild")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to replace permission overrides", err)
+		return
+	}
+
+	h.EventBus.PublishChannelEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_UPDATE", channelID, map[string]string{
+		"channel_id": channelID,
+	})
+	h.Logger.Info("channel permission overrides replaced",
+		slog.String("channel_id", channelID), slog.Int("overrides", len(req.Overrides)))
+
+	resp := channelPermissionsResponse{
+		Overrides: make([]models.ChannelPermissionOverride, 0, len(req.Overrides)),
+		Effective: effectivePermissions{UserID: sampleID},
+	}
+	for _, o := range req.Overrides {
+		resp.Overrides = append(resp.Overrides, models.ChannelPermissionOverride{
+			ChannelID:        channelID,
+			TargetType:       o.TargetType,
+			TargetID:         o.TargetID,
+			PermissionsAllow: o.PermissionsAllow,
+			PermissionsDeny:  o.PermissionsDeny,
+		})
+	}
+
+	perms, err := apiutil.MemberChannelPermissions(r.Context(), h.Pool, *guildID, channelID, sampleID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.MemberNotFound,
+			"Overrides were saved, but the sample user is not a member of this guild")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to compute effective permissions", err)
+		return
+	}
+	resp.Effective.Permissions = int64(perms)
+	resp.Effective.PermissionNames = permissions.Names(perms)
+
+	apiutil.WriteJSON(w, http.StatusOK, resp)
+}
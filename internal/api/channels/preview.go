@@ -0,0 +1,30 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// previewLocked reports whether the channel is a preview channel the user
+// cannot view: it is listed to them with its name and topic, but its history
+// stays closed. Channel overrides are taken into account.
+func (h *Handler) previewLocked(ctx context.Context, channelID, userID string) bool {
+	var guildID *string
+	var preview bool
+	h.Pool.QueryRow(ctx,
+		`SELECT guild_id, preview_enabled FROM channels WHERE id = $1`, channelID,
+	).Scan(&guildID, &preview)
+	if !preview || guildID == nil {
+		return false
+	}
+	perms, err := apiutil.MemberChannelPermissions(ctx, h.Pool, *guildID, channelID, userID)
+	return err == nil && perms&permissions.ViewChannel == 0
+}
+
+func writePreviewLocked(w http.ResponseWriter) {
+	apiutil.WriteError(w, http.StatusForbidden, "channel_locked",
+		"This channel is locked; you need its role to read it")
+}
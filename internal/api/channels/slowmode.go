@@ -0,0 +1,230 @@
+// Package channels — slowmode.go implements adaptive slowmode, which raises
+// a channel's cooldown while it is busy and lets it fall back as the channel
+// calms down. Message velocity is counted in the shared cache so every API
+// instance sees the same rate.
+package channels
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// Bounds on adaptive slowmode settings. The cooldown cap matches the fixed
+// slowmode limit.
+const (
+	minAdaptiveWindow    = 10
+	maxAdaptiveWindow    = 3600
+	maxAdaptiveThreshold = 100000
+	maxAdaptiveCooldown  = 21600
+)
+
+type updateAdaptiveSlowmodeRequest struct {
+	Enabled       *bool   `json:"enabled"`
+	WindowSeconds *int    `json:"window_seconds"`
+	LowThreshold  *int    `json:"low_threshold"`
+	HighThreshold *int    `json:"high_threshold"`
+	MinSeconds    *int    `json:"min_seconds"`
+	MaxSeconds    *int    `json:"max_seconds"`
+	Curve         *string `json:"curve"`
+}
+
+// adaptiveSlowmodeStatus is a channel's configuration along with the
+// velocity it currently sees and the cooldown that follows from it.
+type adaptiveSlowmodeStatus struct {
+	models.ChannelAdaptiveSlowmode
+	Velocity        float64 `json:"velocity"`
+	CooldownSeconds int     `json:"cooldown_seconds"`
+}
+
+// validateAdaptiveSlowmode checks a configuration and returns a message safe
+// to return to the caller, or "".
+func validateAdaptiveSlowmode(cfg models.ChannelAdaptiveSlowmode) string {
+	switch {
+	case cfg.WindowSeconds < minAdaptiveWindow || cfg.WindowSeconds > maxAdaptiveWindow:
+		return fmt.Sprintf("window_seconds must be between %d and %d", minAdaptiveWindow, maxAdaptiveWindow)
+	case cfg.LowThreshold < 1 || cfg.HighThreshold > maxAdaptiveThreshold || cfg.LowThreshold >= cfg.HighThreshold:
+		return fmt.Sprintf("Thresholds must satisfy 1 <= low_threshold < high_threshold <= %d", maxAdaptiveThreshold)
+	case cfg.MinSeconds < 0 || cfg.MaxSeconds > maxAdaptiveCooldown || cfg.MinSeconds > cfg.MaxSeconds:
+		return fmt.Sprintf("Cooldowns must satisfy 0 <= min_seconds <= max_seconds <= %d", maxAdaptiveCooldown)
+	}
+	switch cfg.Curve {
+	case models.AdaptiveCurveLinear, models.AdaptiveCurveEaseIn, models.AdaptiveCurveEaseOut:
+		return ""
+	}
+	return "curve must be linear, ease_in or ease_out"
+}
+
+// adaptiveCooldown maps a message velocity to a cooldown in seconds. Below
+// the low threshold there is none; from there it runs from min_seconds to
+// max_seconds along the curve, and stays at max_seconds past the high
+// threshold.
+func adaptiveCooldown(cfg models.ChannelAdaptiveSlowmode, velocity float64) int {
+	if velocity < float64(cfg.LowThreshold) {
+		return 0
+	}
+	t := (velocity - float64(cfg.LowThreshold)) / float64(cfg.HighThreshold-cfg.LowThreshold)
+	t = math.Min(t, 1)
+	switch cfg.Curve {
+	case models.AdaptiveCurveEaseIn:
+		t = t * t
+	case models.AdaptiveCurveEaseOut:
+		t = math.Sqrt(t)
+	}
+	return cfg.MinSeconds + int(math.Round(t*float64(cfg.MaxSeconds-cfg.MinSeconds)))
+}
+
+// velocityKey is the cache counter for a channel's messages. The window is
+// part of the key so changing it starts a fresh count.
+func velocityKey(cfg models.ChannelAdaptiveSlowmode) string {
+	return fmt.Sprintf("channel_velocity:%s:%d", cfg.ChannelID, cfg.WindowSeconds)
+}
+
+// loadAdaptiveSlowmode returns the channel's configuration, or the defaults.
+func (h *Handler) loadAdaptiveSlowmode(ctx context.Context, channelID string) (models.ChannelAdaptiveSlowmode, error) {
+	cfg := models.DefaultChannelAdaptiveSlowmode(channelID)
+	err := h.Pool.QueryRow(ctx,
+		`SELECT enabled, window_seconds, low_threshold, high_threshold, min_seconds, max_seconds, curve, updated_at
+		 FROM channel_adaptive_slowmode WHERE channel_id = $1`, channelID,
+	).Scan(&cfg.Enabled, &cfg.WindowSeconds, &cfg.LowThreshold, &cfg.HighThreshold,
+		&cfg.MinSeconds, &cfg.MaxSeconds, &cfg.Curve, &cfg.UpdatedAt)
+	if err != nil && err != pgx.ErrNoRows {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// channelVelocity returns the channel's current message velocity. Without a
+// cache there is nothing to count with, and adaptive slowmode stays idle.
+func (h *Handler) channelVelocity(ctx context.Context, cfg models.ChannelAdaptiveSlowmode) float64 {
+	if h.Cache == nil {
+		return 0
+	}
+	v, err := h.Cache.WindowCount(ctx, velocityKey(cfg), time.Duration(cfg.WindowSeconds)*time.Second)
+	if err != nil {
+		h.Logger.Warn("failed to read channel velocity", slog.String("channel_id", cfg.ChannelID), slog.String("error", err.Error()))
+		return 0
+	}
+	return v
+}
+
+// recordChannelMessage counts a posted message towards the channel's velocity.
+func (h *Handler) recordChannelMessage(ctx context.Context, cfg models.ChannelAdaptiveSlowmode) {
+	if h.Cache == nil {
+		return
+	}
+	if _, err := h.Cache.IncrWindowCount(ctx, velocityKey(cfg), time.Duration(cfg.WindowSeconds)*time.Second); err != nil {
+		h.Logger.Warn("failed to count channel message", slog.String("channel_id", cfg.ChannelID), slog.String("error", err.Error()))
+	}
+}
+
+// HandleGetAdaptiveSlowmode returns the channel's adaptive slowmode settings
+// with its current velocity and cooldown, so clients can show the wait.
+// GET /api/v1/channels/{channelID}/slowmode/adaptive
+func (h *Handler) HandleGetAdaptiveSlowmode(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
+		return
+	}
+
+	cfg, err := h.loadAdaptiveSlowmode(r.Context(), channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get adaptive slowmode", err)
+		return
+	}
+
+	status := adaptiveSlowmodeStatus{ChannelAdaptiveSlowmode: cfg}
+	if cfg.Enabled {
+		status.Velocity = h.channelVelocity(r.Context(), cfg)
+		status.CooldownSeconds = adaptiveCooldown(cfg, status.Velocity)
+	}
+	apiutil.WriteJSON(w, http.StatusOK, status)
+}
+
+// HandleUpdateAdaptiveSlowmode changes the channel's adaptive slowmode
+// settings. Omitted fields keep their current value.
+// PATCH /api/v1/channels/{channelID}/slowmode/adaptive
+func (h *Handler) HandleUpdateAdaptiveSlowmode(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	var guildID *string
+	err := h.Pool.QueryRow(r.Context(), `SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	if err == pgx.ErrNoRows || (err == nil && guildID == nil) {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "Guild channel not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel", err)
+		return
+	}
+
+	var req updateAdaptiveSlowmodeRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	cfg, err := h.loadAdaptiveSlowmode(r.Context(), channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get adaptive slowmode", err)
+		return
+	}
+	setIf := func(dst *int, v *int) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	if req.Enabled != nil {
+		cfg.Enabled = *req.Enabled
+	}
+	setIf(&cfg.WindowSeconds, req.WindowSeconds)
+	setIf(&cfg.LowThreshold, req.LowThreshold)
+	setIf(&cfg.HighThreshold, req.HighThreshold)
+	setIf(&cfg.MinSeconds, req.MinSeconds)
+	setIf(&cfg.MaxSeconds, req.MaxSeconds)
+	if req.Curve != nil {
+		cfg.Curve = *req.Curve
+	}
+	if msg := validateAdaptiveSlowmode(cfg); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_adaptive_slowmode", msg)
+		return
+	}
+
+	err = h.Pool.QueryRow(r.Context(),
+		`INSERT INTO channel_adaptive_slowmode (channel_id, enabled, window_seconds, low_threshold, high_threshold,
+		                                        min_seconds, max_seconds, curve, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+		 ON CONFLICT (channel_id) DO UPDATE SET
+		     enabled = $2, window_seconds = $3, low_threshold = $4, high_threshold = $5,
+		     min_seconds = $6, max_seconds = $7, curve = $8, updated_at = now()
+		 RETURNING updated_at`,
+		channelID, cfg.Enabled, cfg.WindowSeconds, cfg.LowThreshold, cfg.HighThreshold,
+		cfg.MinSeconds, cfg.MaxSeconds, cfg.Curve,
+	).Scan(&cfg.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update adaptive slowmode", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, adaptiveSlowmodeStatus{ChannelAdaptiveSlowmode: cfg})
+}
@@ -0,0 +1,157 @@
+// Package channels — source_limits.go implements per-source rate limits,
+// which cap how many messages humans, bots, webhooks or bridges may post in
+// a channel within a sliding window. They let admins quiet a noisy
+// integration without slowing the members around it. Limits are enforced
+// by apiutil.SourceRateLimited.
+package channels
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// Bounds on per-source rate limits.
+const (
+	minSourceLimitWindow   = 10
+	maxSourceLimitWindow   = 86400
+	maxSourceLimitMessages = 100000
+)
+
+type setSourceRateLimitRequest struct {
+	MaxMessages   int `json:"max_messages"`
+	WindowSeconds int `json:"window_seconds"`
+}
+
+// HandleGetSourceRateLimits lists the channel's per-source rate limits.
+// GET /api/v1/channels/{channelID}/source-limits
+func (h *Handler) HandleGetSourceRateLimits(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT channel_id, source, max_messages, window_seconds, updated_at
+		 FROM channel_source_rate_limits WHERE channel_id = $1 ORDER BY source`, channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get source limits", err)
+		return
+	}
+	defer rows.Close()
+
+	limits := []models.ChannelSourceRateLimit{}
+	for rows.Next() {
+		var l models.ChannelSourceRateLimit
+		if err := rows.Scan(&l.ChannelID, &l.Source, &l.MaxMessages, &l.WindowSeconds, &l.UpdatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read source limits", err)
+			return
+		}
+		limits = append(limits, l)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, limits)
+}
+
+// HandleSetSourceRateLimit sets the channel's rate limit for one source.
+// PUT /api/v1/channels/{channelID}/source-limits/{source}
+func (h *Handler) HandleSetSourceRateLimit(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	source := chi.URLParam(r, "source")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+	if !models.ValidMessageSource(source) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_source", "source must be human, bot, webhook or bridge")
+		return
+	}
+
+	var guildID *string
+	err := h.Pool.QueryRow(r.Context(), `SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	if err == pgx.ErrNoRows || (err == nil && guildID == nil) {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "Guild channel not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel", err)
+		return
+	}
+
+	var req setSourceRateLimitRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.MaxMessages < 1 || req.MaxMessages > maxSourceLimitMessages {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_source_limit",
+			fmt.Sprintf("max_messages must be between 1 and %d", maxSourceLimitMessages))
+		return
+	}
+	if req.WindowSeconds < minSourceLimitWindow || req.WindowSeconds > maxSourceLimitWindow {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_source_limit",
+			fmt.Sprintf("window_seconds must be between %d and %d", minSourceLimitWindow, maxSourceLimitWindow))
+		return
+	}
+
+	limit := models.ChannelSourceRateLimit{
+		ChannelID:     channelID,
+		Source:        source,
+		MaxMessages:   req.MaxMessages,
+		WindowSeconds: req.WindowSeconds,
+	}
+	err = h.Pool.QueryRow(r.Context(),
+		`INSERT INTO channel_source_rate_limits (channel_id, source, max_messages, window_seconds, updated_at)
+		 VALUES ($1, $2, $3, $4, now())
+		 ON CONFLICT (channel_id, source) DO UPDATE SET
+		     max_messages = $3, window_seconds = $4, updated_at = now()
+		 RETURNING updated_at`,
+		channelID, source, req.MaxMessages, req.WindowSeconds,
+	).Scan(&limit.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to set source limit", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, limit)
+}
+
+// HandleDeleteSourceRateLimit removes the channel's rate limit for one
+// source.
+// DELETE /api/v1/channels/{channelID}/source-limits/{source}
+func (h *Handler) HandleDeleteSourceRateLimit(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	source := chi.URLParam(r, "source")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM channel_source_rate_limits WHERE channel_id = $1 AND source = $2`,
+		channelID, source)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete source limit", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "source_limit_not_found", "No limit is set for that source")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
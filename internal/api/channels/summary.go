@@ -0,0 +1,233 @@
+// Package channels — summary.go implements activity summaries that help a
+// member catch up on a channel: counts and top participants since their
+// read marker, unread threads, and optionally a recap written by the
+// language model configured through the summarize package.
+package channels
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+	"github.com/amityvox/amityvox/internal/spoilers"
+	"github.com/amityvox/amityvox/internal/summarize"
+)
+
+const (
+	// How many of the most active authors and threads a summary lists.
+	summaryTopParticipants = 5
+	summaryActiveThreads   = 5
+
+	// recapMaxMessages is how many of the newest unread messages a recap
+	// is written from.
+	recapMaxMessages = 200
+)
+
+// activityParticipant is an author counted in an activity summary.
+type activityParticipant struct {
+	UserID       string  `json:"user_id"`
+	Username     string  `json:"username"`
+	DisplayName  *string `json:"display_name"`
+	MessageCount int     `json:"message_count"`
+}
+
+// activeThread is a thread of the channel with unread messages.
+type activeThread struct {
+	ThreadID     string  `json:"thread_id"`
+	Name         *string `json:"name"`
+	MessageCount int     `json:"message_count"`
+}
+
+// activitySummary is the response of HandleGetActivitySummary.
+type activitySummary struct {
+	ChannelID        string                `json:"channel_id"`
+	SinceMessageID   *string               `json:"since_message_id"`
+	MessageCount     int                   `json:"message_count"`
+	ParticipantCount int                   `json:"participant_count"`
+	MentionCount     int                   `json:"mention_count"`
+	FirstMessageID   *string               `json:"first_message_id"`
+	LastMessageID    *string               `json:"last_message_id"`
+	TopParticipants  []activityParticipant `json:"top_participants"`
+	ActiveThreads    []activeThread        `json:"active_threads"`
+	RecapAvailable   bool                  `json:"recap_available"`
+	Recap            *string               `json:"recap,omitempty"`
+}
+
+// HandleGetActivitySummary summarizes a channel's activity since the
+// caller's read marker, or since the message given by ?since=. With
+// ?recap=true and a model configured, the summary includes a written
+// recap; encrypted messages and messages behind a content warning are left
+// out of it and spoilers are masked.
+// GET /api/v1/channels/{channelID}/summary
+func (h *Handler) HandleGetActivitySummary(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ReadHistory) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need READ_HISTORY permission")
+		return
+	}
+
+	modelCfg, recapAvailable := summarize.FromEnv()
+	wantRecap := r.URL.Query().Get("recap") == "true"
+	if wantRecap && !recapAvailable {
+		apiutil.WriteError(w, http.StatusBadRequest, "recap_disabled", "Activity recaps are not enabled on this instance")
+		return
+	}
+
+	summary := activitySummary{
+		ChannelID:       channelID,
+		TopParticipants: []activityParticipant{},
+		ActiveThreads:   []activeThread{},
+		RecapAvailable:  recapAvailable,
+	}
+
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT last_read_id, mention_count FROM read_state
+		 WHERE user_id = $1 AND channel_id = $2`,
+		userID, channelID,
+	).Scan(&summary.SinceMessageID, &summary.MentionCount)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to get read state", err)
+		return
+	}
+	if since := r.URL.Query().Get("since"); since != "" {
+		summary.SinceMessageID = &since
+	}
+
+	err = h.Pool.QueryRow(r.Context(),
+		`SELECT count(*), count(DISTINCT author_id), min(id), max(id) FROM messages
+		 WHERE channel_id = $1 AND ($2::text IS NULL OR id > $2) AND flags & $3 = 0`,
+		channelID, summary.SinceMessageID, models.MessageFlagDeleted,
+	).Scan(&summary.MessageCount, &summary.ParticipantCount, &summary.FirstMessageID, &summary.LastMessageID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to count messages", err)
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT m.author_id, u.username, u.display_name, count(*) AS n
+		 FROM messages m
+		 JOIN users u ON u.id = m.author_id
+		 WHERE m.channel_id = $1 AND ($2::text IS NULL OR m.id > $2) AND m.flags & $3 = 0
+		 GROUP BY m.author_id, u.username, u.display_name
+		 ORDER BY n DESC, m.author_id
+		 LIMIT $4`,
+		channelID, summary.SinceMessageID, models.MessageFlagDeleted, summaryTopParticipants)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get participants", err)
+		return
+	}
+	for rows.Next() {
+		var p activityParticipant
+		if err := rows.Scan(&p.UserID, &p.Username, &p.DisplayName, &p.MessageCount); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read participants", err)
+			return
+		}
+		summary.TopParticipants = append(summary.TopParticipants, p)
+	}
+	rows.Close()
+
+	// A thread the caller has never opened counts from the channel's own
+	// read marker, so threads started while they were away show up.
+	rows, err = h.Pool.Query(r.Context(),
+		`SELECT t.id, t.name, count(m.id) AS n
+		 FROM channels t
+		 LEFT JOIN read_state rs ON rs.channel_id = t.id AND rs.user_id = $2
+		 JOIN messages m ON m.channel_id = t.id AND m.flags & $4 = 0
+		      AND m.id > COALESCE(rs.last_read_id, $3::text, '')
+		 WHERE t.parent_channel_id = $1
+		   AND NOT EXISTS (SELECT 1 FROM user_hidden_threads uh WHERE uh.user_id = $2 AND uh.thread_id = t.id)
+		 GROUP BY t.id, t.name
+		 ORDER BY n DESC, t.id
+		 LIMIT $5`,
+		channelID, userID, summary.SinceMessageID, models.MessageFlagDeleted, summaryActiveThreads)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get threads", err)
+		return
+	}
+	for rows.Next() {
+		var t activeThread
+		if err := rows.Scan(&t.ThreadID, &t.Name, &t.MessageCount); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read threads", err)
+			return
+		}
+		summary.ActiveThreads = append(summary.ActiveThreads, t)
+	}
+	rows.Close()
+
+	if wantRecap && summary.MessageCount > 0 {
+		lines, err := h.recapLines(r, channelID, summary.SinceMessageID)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to get messages", err)
+			return
+		}
+		if len(lines) > 0 {
+			recap, err := summarize.Recap(r.Context(), modelCfg, lines)
+			if err != nil {
+				h.Logger.Error("activity recap failed", slog.String("channel_id", channelID), slog.String("error", err.Error()))
+				var statusErr *summarize.StatusError
+				switch {
+				case errors.As(err, &statusErr):
+					apiutil.WriteError(w, http.StatusBadGateway, "recap_error",
+						fmt.Sprintf("Summary service returned status %d", statusErr.Status))
+				case errors.Is(err, summarize.ErrBadResponse):
+					apiutil.WriteError(w, http.StatusBadGateway, "recap_error", "Failed to parse summary response")
+				default:
+					apiutil.WriteError(w, http.StatusBadGateway, "recap_error", "Summary service is unavailable")
+				}
+				return
+			}
+			summary.Recap = &recap
+		}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, summary)
+}
+
+// recapLines loads the newest unread messages a recap may be written from,
+// oldest first.
+func (h *Handler) recapLines(r *http.Request, channelID string, since *string) ([]summarize.Line, error) {
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT COALESCE(m.masquerade_name, u.display_name, u.username), m.content
+		 FROM messages m
+		 JOIN users u ON u.id = m.author_id
+		 LEFT JOIN message_content_warnings cw ON cw.message_id = m.id
+		 WHERE m.channel_id = $1 AND ($2::text IS NULL OR m.id > $2) AND m.flags & $3 = 0
+		   AND NOT m.encrypted AND m.content IS NOT NULL AND cw.message_id IS NULL
+		 ORDER BY m.id DESC
+		 LIMIT $4`,
+		channelID, since, models.MessageFlagDeleted, recapMaxMessages)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []summarize.Line
+	for rows.Next() {
+		var l summarize.Line
+		if err := rows.Scan(&l.Author, &l.Content); err != nil {
+			return nil, err
+		}
+		l.Content = spoilers.Mask(l.Content)
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines, nil
+}
@@ -0,0 +1,176 @@
+// Package channels — tags.go posts guild tags, the moderator-defined canned
+// responses managed in the guilds package. A tag is posted as a normal
+// message from the member who ran /tag, so no bot is involved.
+package channels
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// tagVars are the values substituted into a tag's placeholders.
+type tagVars struct {
+	UserID    string
+	ChannelID string
+	GuildName string
+}
+
+// renderTag fills in a tag's {user}, {channel} and {guild} placeholders.
+// {user} and {channel} become mentions. Unknown placeholders are left as is.
+func renderTag(content string, v tagVars) string {
+	return strings.NewReplacer(
+		"{user}", "<@"+v.UserID+">",
+		"{channel}", "<#"+v.ChannelID+">",
+		"{guild}", v.GuildName,
+	).Replace(content)
+}
+
+// HandleInvokeTag posts a guild tag in the channel as the caller.
+// Moderators with MANAGE_MESSAGES are not held to the tag's cooldown.
+// POST /api/v1/channels/{channelID}/commands/tag
+//
+// Request body: {"name": "rules"}
+func (h *Handler) HandleInvokeTag(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if !apiutil.RequireNonEmpty(w, "name", req.Name) {
+		return
+	}
+
+	cc, err := h.loadChannelCtx(r.Context(), channelID, userID)
+	if err != nil {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+		return
+	}
+	if cc.GuildID == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "not_guild_channel", "Tags can only be used in guild channels")
+		return
+	}
+	if !cc.hasPerm(permissions.SendMessages) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need SEND_MESSAGES permission")
+		return
+	}
+	if cc.Archived {
+		apiutil.WriteError(w, http.StatusForbidden, "channel_archived", "This channel is archived and read-only")
+		return
+	}
+	if cc.Locked {
+		apiutil.WriteError(w, http.StatusForbidden, "channel_locked", "This channel is locked")
+		return
+	}
+	if !h.canPostReadOnly(r.Context(), cc, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "channel_read_only",
+			"This channel is read-only. Only users with specific roles can post.")
+		return
+	}
+	if cc.Encrypted {
+		apiutil.WriteError(w, http.StatusBadRequest, "channel_encrypted", "Tags cannot be posted in encrypted channels")
+		return
+	}
+	if cc.TimeoutUntil != nil && cc.TimeoutUntil.After(time.Now()) {
+		apiutil.WriteError(w, http.StatusForbidden, "timed_out", "You are timed out and cannot send messages")
+		return
+	}
+	if cc.GuildID != nil && apiutil.GuildRulesPending(r.Context(), h.Pool, *cc.GuildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "rules_not_accepted", "You must accept the guild rules before posting")
+		return
+	}
+
+	var (
+		tagID, content, guildName string
+		cooldown                  int
+		lastUsed                  *time.Time
+	)
+	err = h.Pool.QueryRow(r.Context(),
+		`SELECT t.id, t.content, t.cooldown_seconds, t.last_used_at, g.name
+		 FROM guild_tags t JOIN guilds g ON g.id = t.guild_id
+		 WHERE t.guild_id = $1 AND lower(t.name) = lower($2)`,
+		*cc.GuildID, strings.TrimSpace(req.Name),
+	).Scan(&tagID, &content, &cooldown, &lastUsed, &guildName)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "tag_not_found", "No tag with that name in this guild")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up tag", err)
+		return
+	}
+
+	// The cooldown is claimed in the same statement that counts the use, so
+	// two members running the tag at once cannot both get through.
+	bypass := cc.hasPerm(permissions.ManageMessages)
+	result, err := h.Pool.Exec(r.Context(),
+		`UPDATE guild_tags SET uses = uses + 1, last_used_at = now()
+		 WHERE id = $1 AND ($2 OR last_used_at IS NULL
+		                   OR last_used_at <= now() - make_interval(secs => cooldown_seconds))`,
+		tagID, bypass)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to record tag use", err)
+		return
+	}
+	if result.RowsAffected() == 0 {
+		remaining := time.Duration(cooldown) * time.Second
+		if lastUsed != nil {
+			remaining -= time.Since(*lastUsed)
+		}
+		apiutil.WriteError(w, http.StatusTooManyRequests, "tag_cooldown",
+			fmt.Sprintf("This tag is on cooldown. Try again in %.0f seconds", max(remaining.Seconds(), 1)))
+		return
+	}
+
+	var flags int
+	quarantined := apiutil.IsQuarantined(r.Context(), h.Pool, userID)
+	if quarantined {
+		flags |= models.MessageFlagQuarantined
+	}
+
+	rendered := renderTag(content, tagVars{UserID: userID, ChannelID: channelID, GuildName: guildName})
+	var msg models.Message
+	err = h.Pool.QueryRow(r.Context(),
+		`INSERT INTO messages (id, channel_id, author_id, content, message_type, flags, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, now())
+		 RETURNING id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
+		           reply_to_ids, mention_user_ids, mention_role_ids, mention_here,
+		           thread_id, masquerade_name, masquerade_avatar, masquerade_color,
+		           encrypted, encryption_session_id, created_at`,
+		models.NewULID().String(), channelID, userID, rendered, models.MessageTypeDefault, flags,
+	).Scan(
+		&msg.ID, &msg.ChannelID, &msg.AuthorID, &msg.Content, &msg.Nonce, &msg.MessageType,
+		&msg.EditedAt, &msg.Flags, &msg.ReplyToIDs, &msg.MentionUserIDs, &msg.MentionRoleIDs,
+		&msg.MentionHere, &msg.ThreadID, &msg.MasqueradeName, &msg.MasqueradeAvatar,
+		&msg.MasqueradeColor, &msg.Encrypted, &msg.EncryptionSessionID, &msg.CreatedAt,
+	)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to post tag", err)
+		return
+	}
+
+	if !quarantined {
+		h.Pool.Exec(r.Context(),
+			`UPDATE channels SET last_message_id = $1 WHERE id = $2`, msg.ID, channelID)
+	}
+
+	h.enrichMessageWithAuthor(r.Context(), &msg)
+	h.publishMessageEvent(r.Context(), events.SubjectMessageCreate, "MESSAGE_CREATE", msg)
+
+	apiutil.WriteJSON(w, http.StatusCreated, msg)
+}
@@ -1,25 +1,25 @@
-// Package channels — translation.go implements the message translation endpoint.
-// Uses LibreTranslate (self-hosted) for translation with a PostgreSQL-backed cache.
+// Package channels — translation.go implements the message translation endpoint
+// and per-channel auto-translation. Uses LibreTranslate (self-hosted) through
+// the translate package, with a PostgreSQL-backed cache.
 package channels
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
-	"os"
-	"strings"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/permissions"
+	"github.com/amityvox/amityvox/internal/translate"
 )
 
 // translateRequest is the body for HandleTranslateMessage.
@@ -28,39 +28,9 @@ type translateRequest struct {
 	Force      bool   `json:"force,omitempty"`
 }
 
-// libreTranslateRequest is the request body sent to the LibreTranslate API.
-type libreTranslateRequest struct {
-	Q      string `json:"q"`
-	Source string `json:"source"`
-	Target string `json:"target"`
-	Format string `json:"format"`
-}
-
-// libreTranslateResponse is the response from the LibreTranslate API.
-type libreTranslateResponse struct {
-	TranslatedText string `json:"translatedText"`
-	DetectedLanguage struct {
-		Confidence float64 `json:"confidence"`
-		Language   string  `json:"language"`
-	} `json:"detectedLanguage"`
-}
-
-// getTranslationConfig reads translation config from environment variables.
-// Returns (enabled, apiURL, defaultTargetLang).
-func getTranslationConfig() (bool, string, string) {
-	enabled := os.Getenv("AMITYVOX_TRANSLATION_ENABLED")
-	if enabled != "true" && enabled != "1" {
-		return false, "", ""
-	}
-	apiURL := os.Getenv("AMITYVOX_TRANSLATION_API_URL")
-	if apiURL == "" {
-		apiURL = "http://localhost:5000"
-	}
-	defaultLang := os.Getenv("AMITYVOX_TRANSLATION_DEFAULT_LANG")
-	if defaultLang == "" {
-		defaultLang = "en"
-	}
-	return true, apiURL, defaultLang
+// validLangCode is the basic check applied to language codes: 2-5 characters.
+func validLangCode(lang string) bool {
+	return len(lang) >= 2 && len(lang) <= 5
 }
 
 // HandleTranslateMessage translates a message's content to the requested target language.
@@ -75,12 +45,12 @@ func (h *Handler) HandleTranslateMessage(w http.ResponseWriter, r *http.Request)
 
 	// Check that the user can view this channel.
 	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_CHANNEL permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
 		return
 	}
 
 	// Check translation config.
-	enabled, apiURL, defaultLang := getTranslationConfig()
+	cfg, enabled := translate.FromEnv()
 	if !enabled {
 		apiutil.WriteError(w, http.StatusBadRequest, "translation_disabled", "Translation is not enabled on this instance")
 		return
@@ -92,24 +62,27 @@ func (h *Handler) HandleTranslateMessage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if req.TargetLang == "" {
-		req.TargetLang = defaultLang
+		req.TargetLang = cfg.DefaultLang
 	}
 
-	// Validate target language (basic check: 2-5 chars, lowercase).
-	if len(req.TargetLang) < 2 || len(req.TargetLang) > 5 {
+	if !validLangCode(req.TargetLang) {
 		apiutil.WriteError(w, http.StatusBadRequest, "invalid_lang", "Target language must be a 2-5 character language code")
 		return
 	}
 
-	// Fetch the message content from the database.
+	// Fetch the message content from the database, with the channel's
+	// language as the source.
 	var content *string
+	var channelLang string
 	err := h.Pool.QueryRow(r.Context(),
-		`SELECT content FROM messages WHERE id = $1 AND channel_id = $2`,
-		messageID, channelID,
-	).Scan(&content)
+		`SELECT m.content, COALESCE(c.language, '') FROM messages m
+		 JOIN channels c ON c.id = m.channel_id
+		 WHERE m.id = $1 AND m.channel_id = $2 AND m.flags & $3 = 0`,
+		messageID, channelID, models.MessageFlagDeleted,
+	).Scan(&content, &channelLang)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+			apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to fetch message", err)
@@ -122,140 +95,242 @@ func (h *Handler) HandleTranslateMessage(w http.ResponseWriter, r *http.Request)
 
 	// Check the translation cache first (skip if force=true for retry).
 	if !req.Force {
-		var cachedText string
-		var cachedSourceLang string
-		err = h.Pool.QueryRow(r.Context(),
-			`SELECT translated_text, source_lang FROM translation_cache
-			 WHERE message_id = $1 AND target_lang = $2`,
-			messageID, req.TargetLang,
-		).Scan(&cachedText, &cachedSourceLang)
-		if err == nil {
-			// Cache hit — return cached translation.
+		if cached, ok, _ := translate.Cached(r.Context(), h.Pool, messageID, req.TargetLang); ok {
 			apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
 				"message_id":      messageID,
-				"source_lang":     cachedSourceLang,
+				"source_lang":     cached.SourceLang,
 				"target_lang":     req.TargetLang,
-				"translated_text": cachedText,
+				"translated_text": cached.Text,
 				"cached":          true,
 			})
 			return
 		}
 	}
 
-	// Call LibreTranslate API.
-	ltReq := libreTranslateRequest{
-		Q:      *content,
-		Source: "auto",
-		Target: req.TargetLang,
-		Format: "text",
-	}
-	body, err := json.Marshal(ltReq)
+	result, err := translate.TranslateFrom(r.Context(), cfg, *content, translate.SourceFor(channelLang, req.TargetLang), req.TargetLang)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to prepare translation request")
+		h.Logger.Error("translation failed", slog.String("error", err.Error()))
+		var statusErr *translate.StatusError
+		switch {
+		case errors.As(err, &statusErr):
+			apiutil.WriteError(w, http.StatusBadGateway, "translation_error",
+				fmt.Sprintf("Translation service returned status %d", statusErr.Status))
+		case errors.Is(err, translate.ErrGarbage):
+			apiutil.WriteError(w, http.StatusBadGateway, "translation_error",
+				"Translation service returned invalid output — check LibreTranslate configuration")
+		case errors.Is(err, translate.ErrBadResponse):
+			apiutil.WriteError(w, http.StatusBadGateway, "translation_error", "Failed to parse translation response")
+		default:
+			apiutil.WriteError(w, http.StatusBadGateway, "translation_error", "Translation service is unavailable")
+		}
+		return
+	}
+
+	if err := translate.Store(r.Context(), h.Pool, messageID, result); err != nil {
+		// Log but do not fail — translation still succeeded.
+		h.Logger.Warn("failed to cache translation", slog.String("error", err.Error()))
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message_id":      messageID,
+		"source_lang":     result.SourceLang,
+		"target_lang":     req.TargetLang,
+		"translated_text": result.Text,
+		"cached":          false,
+	})
+}
+
+// --- Auto-translation ---
+//
+// A guild channel can translate every new message into one language, the
+// guild's preferred locale unless the channel names another. Translations
+// are produced in the background, stored in translation_cache and attached
+// to messages as "translation"; clients decide whether to show them.
+// Encrypted channels cannot use it, since the server cannot read them.
+
+// autoTranslateSetting is a channel's auto-translation configuration.
+type autoTranslateSetting struct {
+	ChannelID  string  `json:"channel_id"`
+	Enabled    bool    `json:"enabled"`
+	TargetLang *string `json:"target_lang"`
+	// EffectiveLang is the language messages are translated into.
+	EffectiveLang string `json:"effective_lang"`
+}
+
+// autoTranslateLang returns the language a channel auto-translates into, or
+// "" if auto-translation is off for it.
+func (h *Handler) autoTranslateLang(ctx context.Context, channelID string) string {
+	var lang string
+	h.Pool.QueryRow(ctx,
+		`SELECT COALESCE(a.target_lang, g.preferred_locale, 'en')
+		 FROM channel_auto_translate a
+		 JOIN channels c ON c.id = a.channel_id
+		 JOIN guilds g ON g.id = c.guild_id
+		 WHERE a.channel_id = $1 AND NOT c.encrypted`, channelID).Scan(&lang)
+	return lang
+}
+
+// enrichMessagesWithTranslations attaches stored translations to messages
+// in an auto-translated channel.
+func (h *Handler) enrichMessagesWithTranslations(ctx context.Context, channelID string, messages []models.Message) {
+	if len(messages) == 0 {
+		return
+	}
+	lang := h.autoTranslateLang(ctx, channelID)
+	if lang == "" {
 		return
 	}
 
-	httpClient := &http.Client{Timeout: 15 * time.Second}
-	resp, err := httpClient.Post(apiURL+"/translate", "application/json", bytes.NewReader(body))
+	msgIDs := make([]string, len(messages))
+	for i, m := range messages {
+		msgIDs[i] = m.ID
+	}
+	rows, err := h.Pool.Query(ctx,
+		`SELECT message_id, source_lang, translated_text FROM translation_cache
+		 WHERE message_id = ANY($1) AND target_lang = $2`, msgIDs, lang)
 	if err != nil {
-		h.Logger.Error("failed to call LibreTranslate", slog.String("error", err.Error()))
-		apiutil.WriteError(w, http.StatusBadGateway, "translation_error", "Translation service is unavailable")
 		return
 	}
-	defer resp.Body.Close()
+	defer rows.Close()
+
+	byID := make(map[string]*models.MessageTranslation)
+	for rows.Next() {
+		var id string
+		t := models.MessageTranslation{TargetLang: lang}
+		if err := rows.Scan(&id, &t.SourceLang, &t.Text); err != nil {
+			continue
+		}
+		byID[id] = &t
+	}
+	for i := range messages {
+		if !messages[i].Encrypted {
+			messages[i].Translation = byID[messages[i].ID]
+		}
+	}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		h.Logger.Error("LibreTranslate returned error",
-			slog.Int("status", resp.StatusCode),
-			slog.String("body", string(respBody)),
-		)
-		apiutil.WriteError(w, http.StatusBadGateway, "translation_error",
-			fmt.Sprintf("Translation service returned status %d", resp.StatusCode))
+// getAutoTranslateSetting loads a guild channel's auto-translation setting.
+func (h *Handler) getAutoTranslateSetting(ctx context.Context, channelID string) (autoTranslateSetting, error) {
+	s := autoTranslateSetting{ChannelID: channelID}
+	err := h.Pool.QueryRow(ctx,
+		`SELECT a.channel_id IS NOT NULL, a.target_lang, COALESCE(a.target_lang, g.preferred_locale, 'en')
+		 FROM channels c
+		 JOIN guilds g ON g.id = c.guild_id
+		 LEFT JOIN channel_auto_translate a ON a.channel_id = c.id
+		 WHERE c.id = $1`, channelID,
+	).Scan(&s.Enabled, &s.TargetLang, &s.EffectiveLang)
+	return s, err
+}
+
+// HandleGetAutoTranslate returns a channel's auto-translation setting.
+// GET /api/v1/channels/{channelID}/auto-translate
+func (h *Handler) HandleGetAutoTranslate(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
 		return
 	}
 
-	var ltResp libreTranslateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ltResp); err != nil {
-		h.Logger.Error("failed to decode LibreTranslate response", slog.String("error", err.Error()))
-		apiutil.WriteError(w, http.StatusBadGateway, "translation_error", "Failed to parse translation response")
+	s, err := h.getAutoTranslateSetting(r.Context(), channelID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "Guild channel not found")
 		return
 	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get auto-translate setting", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, s)
+}
 
-	// Validate translation output: detect garbage responses where a single word
-	// is repeated many times (e.g. "MAINSTREAM MAINSTREAM MAINSTREAM...").
-	// This happens when LibreTranslate language models fail to load or are corrupted.
-	if isRepeatedWordGarbage(ltResp.TranslatedText) {
-		h.Logger.Warn("LibreTranslate returned repeated-word garbage output",
-			slog.String("output", ltResp.TranslatedText[:min(len(ltResp.TranslatedText), 100)]),
-		)
-		apiutil.WriteError(w, http.StatusBadGateway, "translation_error",
-			"Translation service returned invalid output — check LibreTranslate configuration")
+// HandleSetAutoTranslate turns on auto-translation for a guild channel.
+// target_lang may be omitted to follow the guild's preferred locale.
+// PUT /api/v1/channels/{channelID}/auto-translate
+func (h *Handler) HandleSetAutoTranslate(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+	if _, enabled := translate.FromEnv(); !enabled {
+		apiutil.WriteError(w, http.StatusBadRequest, "translation_disabled", "Translation is not enabled on this instance")
 		return
 	}
 
-	sourceLang := ltResp.DetectedLanguage.Language
-	if sourceLang == "" {
-		sourceLang = "auto"
+	var req struct {
+		TargetLang *string `json:"target_lang"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.TargetLang != nil && !validLangCode(*req.TargetLang) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_lang", "Target language must be a 2-5 character language code")
+		return
 	}
 
-	// Cache the translation.
-	cacheID := models.NewULID().String()
-	_, err = h.Pool.Exec(r.Context(),
-		`INSERT INTO translation_cache (id, message_id, source_lang, target_lang, translated_text, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6)
-		 ON CONFLICT (message_id, target_lang) DO UPDATE SET
-		   translated_text = EXCLUDED.translated_text,
-		   source_lang = EXCLUDED.source_lang`,
-		cacheID, messageID, sourceLang, req.TargetLang, ltResp.TranslatedText, time.Now(),
-	)
+	var guildID *string
+	var encrypted bool
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT guild_id, encrypted FROM channels WHERE id = $1`, channelID).Scan(&guildID, &encrypted)
+	if err == pgx.ErrNoRows || (err == nil && guildID == nil) {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "Guild channel not found")
+		return
+	}
 	if err != nil {
-		// Log but do not fail — translation still succeeded.
-		h.Logger.Warn("failed to cache translation", slog.String("error", err.Error()))
+		apiutil.InternalError(w, h.Logger, "Failed to look up channel", err)
+		return
+	}
+	if encrypted {
+		apiutil.WriteError(w, http.StatusBadRequest, "channel_encrypted",
+			"Encrypted channels cannot be auto-translated")
+		return
 	}
 
-	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
-		"message_id":      messageID,
-		"source_lang":     sourceLang,
-		"target_lang":     req.TargetLang,
-		"translated_text": ltResp.TranslatedText,
-		"cached":          false,
-	})
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO channel_auto_translate (channel_id, target_lang, enabled_by, updated_at)
+		 VALUES ($1, $2, $3, now())
+		 ON CONFLICT (channel_id) DO UPDATE SET
+		     target_lang = EXCLUDED.target_lang, enabled_by = EXCLUDED.enabled_by, updated_at = now()`,
+		channelID, req.TargetLang, userID); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to enable auto-translate", err)
+		return
+	}
+
+	s, err := h.getAutoTranslateSetting(r.Context(), channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get auto-translate setting", err)
+		return
+	}
+	h.EventBus.PublishChannelEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_AUTO_TRANSLATE_UPDATE", channelID, s)
+	apiutil.WriteJSON(w, http.StatusOK, s)
 }
 
-// isRepeatedWordGarbage detects translation output that indicates a LibreTranslate
-// model failure. Catches two patterns:
-// 1. Space-separated: "MAINSTREAM MAINSTREAM MAINSTREAM"
-// 2. Concatenated: "MAINSTREMAINSTREMAINSTRE..." (single long token with repeated substring)
-func isRepeatedWordGarbage(text string) bool {
-	// Pattern 1: all space-separated words are the same.
-	words := strings.Fields(text)
-	if len(words) >= 3 {
-		first := strings.ToLower(words[0])
-		allSame := true
-		for _, w := range words[1:] {
-			if strings.ToLower(w) != first {
-				allSame = false
-				break
-			}
-		}
-		if allSame {
-			return true
-		}
+// HandleDeleteAutoTranslate turns off auto-translation for a channel.
+// Translations already made are kept.
+// DELETE /api/v1/channels/{channelID}/auto-translate
+func (h *Handler) HandleDeleteAutoTranslate(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
 	}
 
-	// Pattern 2: a short substring (3-20 chars) repeats 5+ times in the text.
-	lower := strings.ToLower(text)
-	if len(lower) < 30 {
-		return false
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM channel_auto_translate WHERE channel_id = $1`, channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to disable auto-translate", err)
+		return
 	}
-	for subLen := 3; subLen <= 20 && subLen <= len(lower)/5; subLen++ {
-		sub := lower[:subLen]
-		count := strings.Count(lower, sub)
-		if count >= 5 && len(sub)*count >= len(lower)/2 {
-			return true
-		}
+	if tag.RowsAffected() > 0 {
+		h.EventBus.PublishChannelEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_AUTO_TRANSLATE_UPDATE", channelID,
+			autoTranslateSetting{ChannelID: channelID})
 	}
 
-	return false
+	apiutil.WriteNoContent(w)
 }
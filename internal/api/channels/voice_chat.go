@@ -0,0 +1,62 @@
+package channels
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxVoiceChatRecentMinutes caps how long a member who left a voice room can
+// still read its chat.
+const maxVoiceChatRecentMinutes = 7 * 24 * 60
+
+func isVoiceRoom(channelType string) bool {
+	return channelType == models.ChannelTypeVoice || channelType == models.ChannelTypeStage
+}
+
+// canPostVoiceChat reports whether the user may post in the chat of a voice
+// or stage channel: members connected to the room, and those with
+// MANAGE_MESSAGES. Other channels are not restricted.
+func (h *Handler) canPostVoiceChat(ctx context.Context, cc *channelCtx, channelID, userID string) bool {
+	if !isVoiceRoom(cc.ChannelType) || cc.InVoice || cc.hasPerm(permissions.ManageMessages) {
+		return true
+	}
+	connected, _ := apiutil.VoiceChatAccess(ctx, h.Pool, channelID, userID)
+	return connected
+}
+
+// canReadVoiceChat reports whether the user may read the chat of a voice or
+// stage channel: current and recent participants, and those with
+// MANAGE_MESSAGES. Other channels are not restricted.
+func (h *Handler) canReadVoiceChat(ctx context.Context, channelID, userID string) bool {
+	var channelType string
+	h.Pool.QueryRow(ctx, `SELECT channel_type FROM channels WHERE id = $1`, channelID).Scan(&channelType)
+	if !isVoiceRoom(channelType) {
+		return true
+	}
+	if h.Voice != nil && h.Voice.InVoiceChannel(userID, channelID) {
+		return true
+	}
+	if _, recent := apiutil.VoiceChatAccess(ctx, h.Pool, channelID, userID); recent {
+		return true
+	}
+	return h.hasChannelPermission(ctx, channelID, userID, permissions.ManageMessages)
+}
+
+// validateVoiceChatSettings checks the voice chat fields of a channel update.
+func validateVoiceChatSettings(w http.ResponseWriter, recentMinutes *int, history *string) bool {
+	if recentMinutes != nil && (*recentMinutes < 0 || *recentMinutes > maxVoiceChatRecentMinutes) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_voice_chat_recent_minutes",
+			"voice_chat_recent_minutes must be between 0 and 10080")
+		return false
+	}
+	if history != nil && *history != models.VoiceChatHistoryKeep && *history != models.VoiceChatHistorySession {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_voice_chat_history",
+			"voice_chat_history must be keep or session")
+		return false
+	}
+	return true
+}
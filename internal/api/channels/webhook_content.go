@@ -0,0 +1,103 @@
+// Package channels — webhook_content.go lets channel managers limit what
+// incoming webhooks may post in a channel. The webhook execute endpoint
+// enforces the rules.
+package channels
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+type updateWebhookContentRequest struct {
+	AllowAttachments *bool `json:"allow_attachments"`
+	AllowMentions    *bool `json:"allow_mentions"`
+	EmbedsOnly       *bool `json:"embeds_only"`
+}
+
+// HandleGetWebhookContent returns the channel's webhook content rules, or the
+// defaults if they have never been changed.
+// GET /api/v1/channels/{channelID}/webhook-content
+func (h *Handler) HandleGetWebhookContent(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ViewChannel) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_CHANNEL permission")
+		return
+	}
+
+	rules := models.DefaultChannelWebhookContent(channelID)
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT allow_attachments, allow_mentions, embeds_only, updated_at
+		 FROM channel_webhook_content WHERE channel_id = $1`, channelID,
+	).Scan(&rules.AllowAttachments, &rules.AllowMentions, &rules.EmbedsOnly, &rules.UpdatedAt)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to get webhook content rules", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, rules)
+}
+
+// HandleUpdateWebhookContent changes the channel's webhook content rules.
+// Threads follow their parent channel, so the rules can only be set on a
+// top-level guild channel.
+// PATCH /api/v1/channels/{channelID}/webhook-content
+func (h *Handler) HandleUpdateWebhookContent(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasChannelPermission(r.Context(), channelID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	var guildID, parentID *string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT guild_id, parent_channel_id FROM channels WHERE id = $1`, channelID,
+	).Scan(&guildID, &parentID)
+	if err == pgx.ErrNoRows || (err == nil && guildID == nil) {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "Guild channel not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel", err)
+		return
+	}
+	if parentID != nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "thread_channel", "Threads use their parent channel's webhook content rules")
+		return
+	}
+
+	var req updateWebhookContentRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	rules := models.ChannelWebhookContent{ChannelID: channelID}
+	err = h.Pool.QueryRow(r.Context(),
+		`INSERT INTO channel_webhook_content (channel_id, allow_attachments, allow_mentions, embeds_only, updated_at)
+		 VALUES ($1, COALESCE($2, true), COALESCE($3, true), COALESCE($4, false), now())
+		 ON CONFLICT (channel_id) DO UPDATE SET
+		     allow_attachments = COALESCE($2, channel_webhook_content.allow_attachments),
+		     allow_mentions = COALESCE($3, channel_webhook_content.allow_mentions),
+		     embeds_only = COALESCE($4, channel_webhook_content.embeds_only),
+		     updated_at = now()
+		 RETURNING allow_attachments, allow_mentions, embeds_only, updated_at`,
+		channelID, req.AllowAttachments, req.AllowMentions, req.EmbedsOnly,
+	).Scan(&rules.AllowAttachments, &rules.AllowMentions, &rules.EmbedsOnly, &rules.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update webhook content rules", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, rules)
+}
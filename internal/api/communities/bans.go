@@ -0,0 +1,219 @@
+package communities
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// --- Community Bans ---
+//
+// A community ban is copied into the bans of every member guild, removing
+// the user from any they are in, and lifted from all of them together. The
+// community owner and holders of a community role with BAN_MEMBERS manage
+// the list. A guild cannot lift a community ban on its own.
+
+// HandleListCommunityBans lists the community's bans, newest first.
+// GET /api/v1/communities/{communityID}/bans
+func (h *Handler) HandleListCommunityBans(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+
+	if _, ok := h.communityOwner(w, r, communityID); !ok {
+		return
+	}
+	if !h.hasCommunityPermission(r.Context(), communityID, userID, permissions.BanMembers) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS in this community")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT b.community_id, b.user_id, b.reason, b.banned_by, b.created_at,
+		        u.id, u.instance_id, u.username, u.display_name, u.avatar_id
+		 FROM community_bans b
+		 JOIN users u ON u.id = b.user_id
+		 WHERE b.community_id = $1
+		 ORDER BY b.created_at DESC
+		 LIMIT 1000`, communityID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list community bans", err)
+		return
+	}
+	bans, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.CommunityBan, error) {
+		b := models.CommunityBan{User: &models.User{}}
+		err := row.Scan(&b.CommunityID, &b.UserID, &b.Reason, &b.BannedBy, &b.CreatedAt,
+			&b.User.ID, &b.User.InstanceID, &b.User.Username, &b.User.DisplayName, &b.User.AvatarID)
+		return b, err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read community bans", err)
+		return
+	}
+	if bans == nil {
+		bans = []models.CommunityBan{}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, bans)
+}
+
+// HandleCreateCommunityBan bans a user from every guild in the community.
+// Owners of the community or of one of its guilds cannot be banned. A guild
+// that already banned the user keeps its own ban.
+// PUT /api/v1/communities/{communityID}/bans/{userID}
+func (h *Handler) HandleCreateCommunityBan(w http.ResponseWriter, r *http.Request) {
+	actorID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+	targetID := chi.URLParam(r, "userID")
+
+	if _, ok := h.communityOwner(w, r, communityID); !ok {
+		return
+	}
+	if !h.hasCommunityPermission(r.Context(), communityID, actorID, permissions.BanMembers) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS in this community")
+		return
+	}
+
+	var req struct {
+		Reason *string `json:"reason"`
+	}
+	if r.ContentLength > 0 && !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	var exists, isOwner bool
+	h.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM users WHERE id = $2),
+		        EXISTS(SELECT 1 FROM communities WHERE id = $1 AND owner_id = $2)
+		     OR EXISTS(SELECT 1 FROM community_guilds cg JOIN guilds g ON g.id = cg.guild_id
+		               WHERE cg.community_id = $1 AND cg.status = 'active' AND g.owner_id = $2)`,
+		communityID, targetID).Scan(&exists, &isOwner)
+	if !exists {
+		apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+	if isOwner {
+		apiutil.WriteError(w, http.StatusForbidden, "cannot_ban_owner", "Cannot ban the owner of the community or one of its guilds")
+		return
+	}
+
+	var bannedIn, removedFrom []string
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO community_bans (community_id, user_id, reason, banned_by, created_at)
+			 VALUES ($1, $2, $3, $4, now())
+			 ON CONFLICT (community_id, user_id) DO UPDATE SET reason = EXCLUDED.reason, banned_by = EXCLUDED.banned_by`,
+			communityID, targetID, req.Reason, actorID); err != nil {
+			return err
+		}
+		rows, err := tx.Query(r.Context(),
+			`INSERT INTO guild_bans (guild_id, user_id, reason, banned_by, community_id, created_at)
+			 SELECT cg.guild_id, $2, $3, $4, $1, now()
+			 FROM community_guilds cg
+			 WHERE cg.community_id = $1 AND cg.status = 'active'
+			 ON CONFLICT (guild_id, user_id) DO NOTHING
+			 RETURNING guild_id`,
+			communityID, targetID, req.Reason, actorID)
+		if err != nil {
+			return err
+		}
+		if bannedIn, err = pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+			return err
+		}
+		rows, err = tx.Query(r.Context(),
+			`DELETE FROM guild_members
+			 WHERE user_id = $2 AND guild_id IN (SELECT guild_id FROM community_guilds WHERE community_id = $1 AND status = 'active')
+			 RETURNING guild_id`,
+			communityID, targetID)
+		if err != nil {
+			return err
+		}
+		removedFrom, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		return err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to ban user from community", err)
+		return
+	}
+
+	for _, guildID := range removedFrom {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildMemberRemove, "GUILD_MEMBER_REMOVE", guildID, map[string]string{
+			"guild_id": guildID, "user_id": targetID,
+		})
+	}
+	for _, guildID := range bannedIn {
+		h.Pool.Exec(r.Context(),
+			`INSERT INTO audit_log (id, guild_id, actor_id, action, target_type, target_id, reason, created_at)
+			 VALUES ($1, $2, $3, 'community_ban', 'user', $4, $5, now())`,
+			models.NewULID().String(), guildID, actorID, targetID, req.Reason)
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildBanAdd, "GUILD_BAN_ADD", guildID, map[string]string{
+			"guild_id": guildID, "user_id": targetID,
+		})
+	}
+
+	apiutil.WriteNoContent(w)
+}
+
+// HandleRemoveCommunityBan lifts a community ban from every member guild.
+// Bans the guilds issued themselves stay.
+// DELETE /api/v1/communities/{communityID}/bans/{userID}
+func (h *Handler) HandleRemoveCommunityBan(w http.ResponseWriter, r *http.Request) {
+	actorID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+	targetID := chi.URLParam(r, "userID")
+
+	if _, ok := h.communityOwner(w, r, communityID); !ok {
+		return
+	}
+	if !h.hasCommunityPermission(r.Context(), communityID, actorID, permissions.BanMembers) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS in this community")
+		return
+	}
+
+	var liftedIn []string
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(),
+			`DELETE FROM community_bans WHERE community_id = $1 AND user_id = $2`, communityID, targetID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		rows, err := tx.Query(r.Context(),
+			`DELETE FROM guild_bans WHERE community_id = $1 AND user_id = $2 RETURNING guild_id`,
+			communityID, targetID)
+		if err != nil {
+			return err
+		}
+		liftedIn, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		return err
+	})
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "ban_not_found", "User is not banned from this community")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to lift community ban", err)
+		return
+	}
+
+	for _, guildID := range liftedIn {
+		h.Pool.Exec(r.Context(),
+			`INSERT INTO audit_log (id, guild_id, actor_id, action, target_type, target_id, created_at)
+			 VALUES ($1, $2, $3, 'community_unban', 'user', $4, now())`,
+			models.NewULID().String(), guildID, actorID, targetID)
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildBanRemove, "GUILD_BAN_REMOVE", guildID, map[string]string{
+			"guild_id": guildID, "user_id": targetID,
+		})
+	}
+
+	apiutil.WriteNoContent(w)
+}
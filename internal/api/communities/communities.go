@@ -0,0 +1,447 @@
+// Package communities implements REST API handlers for communities: groups
+// of guilds sharing a name and branding, a ban list and roles. A community's
+// owner invites guilds, and each guild joins once its owner accepts.
+//
+// Community roles and bans are mirrored into every member guild as ordinary
+// guild roles and bans, so permission checks and join checks across the
+// codebase apply them without knowing about communities. Mounted under
+// /api/v1/communities and /api/v1/guilds/{guildID}/community.
+package communities
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+const (
+	// maxOwnedCommunities is how many communities one user may own.
+	maxOwnedCommunities = 10
+	// maxCommunityGuilds bounds the guilds in, or invited to, a community.
+	maxCommunityGuilds = 50
+)
+
+// Handler implements community REST API endpoints.
+type Handler struct {
+	Pool       *pgxpool.Pool
+	EventBus   *events.Bus
+	InstanceID string
+	Logger     *slog.Logger
+}
+
+const communityColumns = `id, owner_id, name, description, icon_id, banner_id, accent_color, discoverable, created_at`
+
+func scanCommunity(row pgx.Row) (models.Community, error) {
+	var c models.Community
+	err := row.Scan(&c.ID, &c.OwnerID, &c.Name, &c.Description, &c.IconID, &c.BannerID,
+		&c.AccentColor, &c.Discoverable, &c.CreatedAt)
+	return c, err
+}
+
+type createCommunityRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	IconID      *string `json:"icon_id"`
+	BannerID    *string `json:"banner_id"`
+	AccentColor *string `json:"accent_color"`
+}
+
+type updateCommunityRequest struct {
+	Name         *string `json:"name"`
+	Description  *string `json:"description"`
+	IconID       *string `json:"icon_id"`
+	BannerID     *string `json:"banner_id"`
+	AccentColor  *string `json:"accent_color"`
+	Discoverable *bool   `json:"discoverable"`
+}
+
+// --- Access helpers ---
+
+// communityOwner returns the community's owner, writing a 404 if it does
+// not exist.
+func (h *Handler) communityOwner(w http.ResponseWriter, r *http.Request, communityID string) (string, bool) {
+	var ownerID string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT owner_id FROM communities WHERE id = $1`, communityID).Scan(&ownerID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "community_not_found", "Community not found")
+		return "", false
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get community", err)
+		return "", false
+	}
+	return ownerID, true
+}
+
+// requireOwner writes an error unless userID owns the community.
+func (h *Handler) requireOwner(w http.ResponseWriter, r *http.Request, communityID, userID string) bool {
+	ownerID, ok := h.communityOwner(w, r, communityID)
+	if !ok {
+		return false
+	}
+	if ownerID != userID {
+		apiutil.WriteError(w, http.StatusForbidden, "not_community_owner", "Only the community owner can do that")
+		return false
+	}
+	return true
+}
+
+// checkBrandingUploads writes an error unless the icon and banner, when set,
+// are images userID uploaded, or already the community's branding.
+// communityID is empty for a new community.
+func (h *Handler) checkBrandingUploads(w http.ResponseWriter, r *http.Request, communityID, userID string, iconID, bannerID *string) bool {
+	for _, f := range []struct {
+		id   *string
+		code string
+	}{{iconID, "invalid_icon"}, {bannerID, "invalid_banner"}} {
+		if f.id == nil {
+			continue
+		}
+		var ok bool
+		if err := h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM attachments a
+			               WHERE a.id = $1 AND a.message_id IS NULL AND a.content_type LIKE 'image/%'
+			                 AND (a.uploader_id = $2 OR a.id IN (
+			                     SELECT icon_id FROM communities WHERE id = $3
+			                     UNION ALL SELECT banner_id FROM communities WHERE id = $3)))`,
+			*f.id, userID, communityID,
+		).Scan(&ok); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to check upload", err)
+			return false
+		}
+		if !ok {
+			apiutil.WriteError(w, http.StatusBadRequest, f.code, "Upload the image first and use its file ID")
+			return false
+		}
+	}
+	return true
+}
+
+// hasCommunityPermission reports whether userID owns the community or holds
+// a community role granting perm.
+func (h *Handler) hasCommunityPermission(ctx context.Context, communityID, userID string, perm uint64) bool {
+	var ok bool
+	h.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM communities WHERE id = $1 AND owner_id = $2)
+		     OR EXISTS(SELECT 1 FROM community_roles cr
+		               JOIN community_role_members m ON m.role_id = cr.id
+		               WHERE cr.community_id = $1 AND m.user_id = $2
+		                 AND cr.permissions_allow & $3 <> 0)`,
+		communityID, userID, int64(perm)).Scan(&ok)
+	return ok
+}
+
+// canView reports whether userID may see a community that is not
+// discoverable: its owner and members of its guilds can.
+func (h *Handler) canView(ctx context.Context, communityID, userID string) bool {
+	var ok bool
+	h.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM communities WHERE id = $1 AND (discoverable OR owner_id = $2))
+		     OR EXISTS(SELECT 1 FROM community_guilds cg
+		               JOIN guild_members gm ON gm.guild_id = cg.guild_id
+		               WHERE cg.community_id = $1 AND cg.status = 'active' AND gm.user_id = $2)`,
+		communityID, userID).Scan(&ok)
+	return ok
+}
+
+// loadGuilds returns the community's guilds, with pending invitations too
+// when withPending is set.
+func (h *Handler) loadGuilds(ctx context.Context, communityID string, withPending bool) ([]models.CommunityGuild, error) {
+	rows, err := h.Pool.Query(ctx,
+		`SELECT cg.guild_id, cg.community_id, cg.status, g.name, g.icon_id, g.member_count, cg.added_at
+		 FROM community_guilds cg
+		 JOIN guilds g ON g.id = cg.guild_id
+		 WHERE cg.community_id = $1 AND (cg.status = 'active' OR $2)
+		 ORDER BY g.member_count DESC`, communityID, withPending)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.CommunityGuild, error) {
+		var g models.CommunityGuild
+		err := row.Scan(&g.GuildID, &g.CommunityID, &g.Status, &g.Name, &g.IconID, &g.MemberCount, &g.AddedAt)
+		return g, err
+	})
+}
+
+// getCommunity loads a community with its guilds.
+func (h *Handler) getCommunity(ctx context.Context, communityID string, withPending bool) (models.Community, error) {
+	c, err := scanCommunity(h.Pool.QueryRow(ctx,
+		`SELECT `+communityColumns+` FROM communities WHERE id = $1`, communityID))
+	if err != nil {
+		return c, err
+	}
+	c.Guilds, err = h.loadGuilds(ctx, communityID, withPending)
+	return c, err
+}
+
+// publishCommunityUpdate tells a guild's members its community changed, so
+// clients refresh the community's branding, roles and bans.
+func (h *Handler) publishCommunityUpdate(ctx context.Context, guildID, communityID string) {
+	h.EventBus.PublishGuildEvent(ctx, events.SubjectGuildCommunity, "GUILD_COMMUNITY_UPDATE", guildID, map[string]interface{}{
+		"guild_id": guildID, "community_id": communityID,
+	})
+}
+
+// activeGuildIDs returns the IDs of the community's member guilds.
+func (h *Handler) activeGuildIDs(ctx context.Context, communityID string) []string {
+	rows, err := h.Pool.Query(ctx,
+		`SELECT guild_id FROM community_guilds WHERE community_id = $1 AND status = 'active'`, communityID)
+	if err != nil {
+		return nil
+	}
+	ids, _ := pgx.CollectRows(rows, pgx.RowTo[string])
+	return ids
+}
+
+// --- Communities ---
+
+// HandleCreateCommunity creates a community owned by the caller.
+// POST /api/v1/communities
+func (h *Handler) HandleCreateCommunity(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	var req createCommunityRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if !apiutil.RequireNonEmpty(w, "name", req.Name) {
+		return
+	}
+	if len(req.Name) > 100 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Name must be at most 100 characters")
+		return
+	}
+
+	if !h.checkBrandingUploads(w, r, "", userID, req.IconID, req.BannerID) {
+		return
+	}
+
+	var owned int
+	h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM communities WHERE owner_id = $1`, userID).Scan(&owned)
+	if owned >= maxOwnedCommunities {
+		apiutil.WriteError(w, http.StatusBadRequest, "limit_reached", "You can own at most 10 communities")
+		return
+	}
+
+	c, err := scanCommunity(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO communities (id, owner_id, name, description, icon_id, banner_id, accent_color, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+		 RETURNING `+communityColumns,
+		models.NewULID().String(), userID, req.Name, req.Description, req.IconID, req.BannerID, req.AccentColor))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create community", err)
+		return
+	}
+	c.Guilds = []models.CommunityGuild{}
+
+	apiutil.WriteJSON(w, http.StatusCreated, c)
+}
+
+// HandleListOwnedCommunities lists the communities the caller owns, with
+// their guilds and pending invitations.
+// GET /api/v1/communities
+func (h *Handler) HandleListOwnedCommunities(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+communityColumns+` FROM communities WHERE owner_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list communities", err)
+		return
+	}
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Community, error) {
+		return scanCommunity(row)
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read communities", err)
+		return
+	}
+	for i := range list {
+		if list[i].Guilds, err = h.loadGuilds(r.Context(), list[i].ID, true); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read community guilds", err)
+			return
+		}
+	}
+	if list == nil {
+		list = []models.Community{}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, list)
+}
+
+// HandleDiscoverCommunities is the combined discovery page: discoverable
+// communities, each listing its discoverable guilds, largest first.
+// GET /api/v1/communities/discover
+func (h *Handler) HandleDiscoverCommunities(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT c.id, c.owner_id, c.name, c.description, c.icon_id, c.banner_id, c.accent_color, c.discoverable, c.created_at
+		 FROM communities c
+		 WHERE c.discoverable AND ($1 = '' OR c.name ILIKE '%' || $1 || '%')
+		 ORDER BY (SELECT COALESCE(SUM(g.member_count), 0) FROM community_guilds cg
+		           JOIN guilds g ON g.id = cg.guild_id
+		           WHERE cg.community_id = c.id AND cg.status = 'active') DESC
+		 LIMIT 50`, query)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to discover communities", err)
+		return
+	}
+	list, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Community, error) {
+		return scanCommunity(row)
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read communities", err)
+		return
+	}
+
+	result := make([]models.Community, 0, len(list))
+	for _, c := range list {
+		guildRows, err := h.Pool.Query(r.Context(),
+			`SELECT cg.guild_id, cg.community_id, cg.status, g.name, g.icon_id, g.member_count, cg.added_at
+			 FROM community_guilds cg
+			 JOIN guilds g ON g.id = cg.guild_id
+			 WHERE cg.community_id = $1 AND cg.status = 'active' AND g.discoverable = true
+			 ORDER BY g.member_count DESC`, c.ID)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read community guilds", err)
+			return
+		}
+		c.Guilds, err = pgx.CollectRows(guildRows, func(row pgx.CollectableRow) (models.CommunityGuild, error) {
+			var g models.CommunityGuild
+			err := row.Scan(&g.GuildID, &g.CommunityID, &g.Status, &g.Name, &g.IconID, &g.MemberCount, &g.AddedAt)
+			return g, err
+		})
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read community guilds", err)
+			return
+		}
+		// A community with nothing to join is left off the page.
+		if len(c.Guilds) > 0 {
+			result = append(result, c)
+		}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, result)
+}
+
+// HandleGetCommunity returns a community with its guilds. Discoverable
+// communities are public; others are visible to their owner and members of
+// their guilds. The owner also sees pending invitations.
+// GET /api/v1/communities/{communityID}
+func (h *Handler) HandleGetCommunity(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+
+	ownerID, ok := h.communityOwner(w, r, communityID)
+	if !ok {
+		return
+	}
+	if !h.canView(r.Context(), communityID, userID) {
+		apiutil.WriteError(w, http.StatusNotFound, "community_not_found", "Community not found")
+		return
+	}
+
+	c, err := h.getCommunity(r.Context(), communityID, ownerID == userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get community", err)
+		return
+	}
+	if c.Guilds == nil {
+		c.Guilds = []models.CommunityGuild{}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, c)
+}
+
+// HandleUpdateCommunity updates a community's name, branding or
+// discoverability. Member guilds are told so clients refresh the branding.
+// PATCH /api/v1/communities/{communityID}
+func (h *Handler) HandleUpdateCommunity(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+
+	if !h.requireOwner(w, r, communityID, userID) {
+		return
+	}
+
+	var req updateCommunityRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" || len(trimmed) > 100 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Name must be 1-100 characters")
+			return
+		}
+		req.Name = &trimmed
+	}
+	if !h.checkBrandingUploads(w, r, communityID, userID, req.IconID, req.BannerID) {
+		return
+	}
+
+	if _, err := h.Pool.Exec(r.Context(),
+		`UPDATE communities SET
+			name = COALESCE($2, name),
+			description = COALESCE($3, description),
+			icon_id = COALESCE($4, icon_id),
+			banner_id = COALESCE($5, banner_id),
+			accent_color = COALESCE($6, accent_color),
+			discoverable = COALESCE($7, discoverable)
+		 WHERE id = $1`,
+		communityID, req.Name, req.Description, req.IconID, req.BannerID, req.AccentColor, req.Discoverable); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update community", err)
+		return
+	}
+
+	c, err := h.getCommunity(r.Context(), communityID, true)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get community", err)
+		return
+	}
+	for _, g := range c.Guilds {
+		if g.Status == models.CommunityGuildActive {
+			h.publishCommunityUpdate(r.Context(), g.GuildID, communityID)
+		}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, c)
+}
+
+// HandleDeleteCommunity deletes a community. Its guilds stay as they are,
+// less the community's roles and bans.
+// DELETE /api/v1/communities/{communityID}
+func (h *Handler) HandleDeleteCommunity(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+
+	if !h.requireOwner(w, r, communityID, userID) {
+		return
+	}
+
+	guildIDs := h.activeGuildIDs(r.Context(), communityID)
+	// Mirrored roles and bans cascade from the community's rows.
+	if _, err := h.Pool.Exec(r.Context(), `DELETE FROM communities WHERE id = $1`, communityID); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete community", err)
+		return
+	}
+	for _, guildID := range guildIDs {
+		h.publishCommunityUpdate(r.Context(), guildID, communityID)
+	}
+
+	apiutil.WriteNoContent(w)
+}
@@ -0,0 +1,30 @@
+package communities
+
+import (
+	"testing"
+
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+func TestValidateRolePermissions(t *testing.T) {
+	bits := func(v uint64) *int64 { i := int64(v); return &i }
+
+	tests := []struct {
+		name    string
+		bits    *int64
+		wantErr bool
+	}{
+		{"unset", nil, false},
+		{"none", bits(0), false},
+		{"ban members", bits(permissions.BanMembers), false},
+		{"all but administrator", bits(permissions.AllPermissions &^ permissions.Administrator), false},
+		{"administrator", bits(permissions.Administrator), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateRolePermissions(tt.bits) != ""; got != tt.wantErr {
+				t.Errorf("validateRolePermissions() error = %v, want %v", got, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package communities
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- Community Guilds ---
+//
+// The community owner invites a guild, and it joins once the guild's owner
+// accepts; an owner of both skips the invitation. Joining mirrors the
+// community's roles and bans into the guild. Leaving, by either side,
+// removes them again.
+
+// errNoInvite is returned when a guild accepts an invitation it does not have.
+var errNoInvite = errors.New("no pending community invitation")
+
+// isGuildOwner reports whether userID owns the guild.
+func (h *Handler) isGuildOwner(ctx context.Context, guildID, userID string) bool {
+	var ok bool
+	h.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM guilds WHERE id = $1 AND owner_id = $2)`, guildID, userID).Scan(&ok)
+	return ok
+}
+
+// attachGuild mirrors the community's roles and bans into the guild, giving
+// members the roles they hold in the community. Bans skip the guild owner.
+func attachGuild(ctx context.Context, tx pgx.Tx, communityID, guildID string) error {
+	rows, err := tx.Query(ctx,
+		`SELECT id, community_id, name, color, permissions_allow, permissions_deny, 0, created_at
+		 FROM community_roles WHERE community_id = $1 ORDER BY created_at`, communityID)
+	if err != nil {
+		return err
+	}
+	roles, err := pgx.CollectRows(rows, scanCommunityRole)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if _, err := mirrorRole(ctx, tx, guildID, role); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO member_roles (guild_id, user_id, role_id)
+		 SELECT $1, m.user_id, r.id
+		 FROM roles r
+		 JOIN community_role_members m ON m.role_id = r.community_role_id
+		 JOIN guild_members gm ON gm.guild_id = $1 AND gm.user_id = m.user_id
+		 WHERE r.guild_id = $1
+		 ON CONFLICT DO NOTHING`, guildID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO guild_bans (guild_id, user_id, reason, banned_by, community_id, created_at)
+		 SELECT $1, b.user_id, b.reason, b.banned_by, b.community_id, now()
+		 FROM community_bans b
+		 WHERE b.community_id = $2 AND b.user_id <> (SELECT owner_id FROM guilds WHERE id = $1)
+		 ON CONFLICT (guild_id, user_id) DO NOTHING`,
+		guildID, communityID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		`DELETE FROM guild_members WHERE guild_id = $1
+		 AND user_id IN (SELECT user_id FROM guild_bans WHERE guild_id = $1 AND community_id = $2)`,
+		guildID, communityID)
+	return err
+}
+
+// detachGuild removes a guild from its community along with the roles and
+// bans the community mirrored into it.
+func detachGuild(ctx context.Context, tx pgx.Tx, communityID, guildID string) error {
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM roles WHERE guild_id = $1
+		 AND community_role_id IN (SELECT id FROM community_roles WHERE community_id = $2)`,
+		guildID, communityID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM guild_bans WHERE guild_id = $1 AND community_id = $2`, guildID, communityID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx,
+		`DELETE FROM community_guilds WHERE guild_id = $1 AND community_id = $2`, guildID, communityID)
+	return err
+}
+
+// HandleAddCommunityGuild invites a guild into the community. If the caller
+// also owns the guild it joins at once.
+// POST /api/v1/communities/{communityID}/guilds
+func (h *Handler) HandleAddCommunityGuild(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+
+	if !h.requireOwner(w, r, communityID, userID) {
+		return
+	}
+
+	var req struct {
+		GuildID string `json:"guild_id"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if !apiutil.RequireNonEmpty(w, "guild_id", req.GuildID) {
+		return
+	}
+
+	var instanceID string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT instance_id FROM guilds WHERE id = $1`, req.GuildID).Scan(&instanceID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up guild", err)
+		return
+	}
+	if instanceID != h.InstanceID {
+		apiutil.WriteError(w, http.StatusBadRequest, "remote_guild", "Only guilds on this instance can join a community")
+		return
+	}
+
+	var existing string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT community_id FROM community_guilds WHERE guild_id = $1`, req.GuildID).Scan(&existing)
+	if existing != "" {
+		apiutil.WriteError(w, http.StatusConflict, "already_in_community", "That guild already belongs to, or is invited to, a community")
+		return
+	}
+
+	var count int
+	h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM community_guilds WHERE community_id = $1`, communityID).Scan(&count)
+	if count >= maxCommunityGuilds {
+		apiutil.WriteError(w, http.StatusBadRequest, "limit_reached", "A community can have at most 50 guilds")
+		return
+	}
+
+	status := models.CommunityGuildPending
+	if h.isGuildOwner(r.Context(), req.GuildID, userID) {
+		status = models.CommunityGuildActive
+	}
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO community_guilds (guild_id, community_id, status, added_by, added_at)
+			 VALUES ($1, $2, $3, $4, now())`,
+			req.GuildID, communityID, status, userID); err != nil {
+			return err
+		}
+		if status == models.CommunityGuildActive {
+			return attachGuild(r.Context(), tx, communityID, req.GuildID)
+		}
+		return nil
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to add guild to community", err)
+		return
+	}
+
+	if status == models.CommunityGuildActive {
+		h.publishCommunityUpdate(r.Context(), req.GuildID, communityID)
+	} else {
+		var guildOwner string
+		h.Pool.QueryRow(r.Context(), `SELECT owner_id FROM guilds WHERE id = $1`, req.GuildID).Scan(&guildOwner)
+		h.EventBus.PublishUserEvent(r.Context(), events.SubjectCommunityInvite, "COMMUNITY_INVITE_CREATE", guildOwner,
+			map[string]string{"guild_id": req.GuildID, "community_id": communityID})
+	}
+
+	c, err := h.getCommunity(r.Context(), communityID, true)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get community", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, c)
+}
+
+// HandleRemoveCommunityGuild removes a guild from the community, or
+// withdraws its invitation. The community owner or the guild owner may do it.
+// DELETE /api/v1/communities/{communityID}/guilds/{guildID}
+func (h *Handler) HandleRemoveCommunityGuild(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+	guildID := chi.URLParam(r, "guildID")
+
+	ownerID, ok := h.communityOwner(w, r, communityID)
+	if !ok {
+		return
+	}
+	if ownerID != userID && !h.isGuildOwner(r.Context(), guildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_community_owner", "Only the community owner or the guild owner can do that")
+		return
+	}
+	h.removeGuild(w, r, communityID, guildID)
+}
+
+// removeGuild detaches the guild and tells its members.
+func (h *Handler) removeGuild(w http.ResponseWriter, r *http.Request, communityID, guildID string) {
+	var status string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT status FROM community_guilds WHERE guild_id = $1 AND community_id = $2`,
+		guildID, communityID).Scan(&status)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "guild_not_in_community", "That guild is not in this community")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up community guild", err)
+		return
+	}
+
+	if err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		return detachGuild(r.Context(), tx, communityID, guildID)
+	}); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to remove guild from community", err)
+		return
+	}
+	if status == models.CommunityGuildActive {
+		h.publishCommunityUpdate(r.Context(), guildID, communityID)
+	}
+
+	apiutil.WriteNoContent(w)
+}
+
+// HandleGetGuildCommunity returns the community the guild belongs to. The
+// guild owner also sees a pending invitation, listed among its guilds.
+// GET /api/v1/guilds/{guildID}/community
+func (h *Handler) HandleGetGuildCommunity(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	var communityID, status string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT cg.community_id, cg.status FROM community_guilds cg
+		 JOIN guild_members gm ON gm.guild_id = cg.guild_id AND gm.user_id = $2
+		 WHERE cg.guild_id = $1`, guildID, userID).Scan(&communityID, &status)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "no_community", "This guild is not in a community")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get guild community", err)
+		return
+	}
+	// Members only learn of an invitation once it is accepted; the guild
+	// owner sees it so they can accept.
+	if status == models.CommunityGuildPending && !h.isGuildOwner(r.Context(), guildID, userID) {
+		apiutil.WriteError(w, http.StatusNotFound, "no_community", "This guild is not in a community")
+		return
+	}
+
+	c, err := h.getCommunity(r.Context(), communityID, false)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get community", err)
+		return
+	}
+	if status == models.CommunityGuildPending {
+		c.Guilds = append(c.Guilds, models.CommunityGuild{GuildID: guildID, CommunityID: communityID, Status: status})
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, c)
+}
+
+// HandleAcceptCommunityInvite joins the community that invited the guild.
+// Only the guild owner can accept, since community roles apply in the guild.
+// POST /api/v1/guilds/{guildID}/community/accept
+func (h *Handler) HandleAcceptCommunityInvite(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isGuildOwner(r.Context(), guildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_guild_owner", "Only the guild owner can join a community")
+		return
+	}
+
+	var communityID string
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(r.Context(),
+			`UPDATE community_guilds SET status = 'active', added_at = now()
+			 WHERE guild_id = $1 AND status = 'pending'
+			 RETURNING community_id`, guildID).Scan(&communityID)
+		if err == pgx.ErrNoRows {
+			return errNoInvite
+		}
+		if err != nil {
+			return err
+		}
+		return attachGuild(r.Context(), tx, communityID, guildID)
+	})
+	if errors.Is(err, errNoInvite) {
+		apiutil.WriteError(w, http.StatusNotFound, "invite_not_found", "This guild has no pending community invitation")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to join community", err)
+		return
+	}
+	h.publishCommunityUpdate(r.Context(), guildID, communityID)
+
+	c, err := h.getCommunity(r.Context(), communityID, false)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get community", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, c)
+}
+
+// HandleLeaveCommunity takes the guild out of its community, or declines
+// the invitation.
+// DELETE /api/v1/guilds/{guildID}/community
+func (h *Handler) HandleLeaveCommunity(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isGuildOwner(r.Context(), guildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_guild_owner", "Only the guild owner can leave a community")
+		return
+	}
+
+	var communityID string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT community_id FROM community_guilds WHERE guild_id = $1`, guildID).Scan(&communityID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "no_community", "This guild is not in a community")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get guild community", err)
+		return
+	}
+	h.removeGuild(w, r, communityID, guildID)
+}
@@ -0,0 +1,499 @@
+package communities
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// --- Community Roles ---
+//
+// A community role is defined once and mirrored into each member guild as a
+// guild role linked by community_role_id. Guilds may move the mirrored role
+// but not edit, assign or delete it. Granting the community role to a user
+// gives them the mirrored role in every community guild they belong to.
+
+// maxCommunityRoles bounds the roles a community may define.
+const maxCommunityRoles = 25
+
+// communityRoleColumns selects a models.CommunityRole for scanCommunityRole.
+const communityRoleColumns = `cr.id, cr.community_id, cr.name, cr.color, cr.permissions_allow, cr.permissions_deny,
+	(SELECT COUNT(*) FROM community_role_members m WHERE m.role_id = cr.id), cr.created_at`
+
+func scanCommunityRole(row pgx.CollectableRow) (models.CommunityRole, error) {
+	var cr models.CommunityRole
+	err := row.Scan(&cr.ID, &cr.CommunityID, &cr.Name, &cr.Color, &cr.PermissionsAllow, &cr.PermissionsDeny,
+		&cr.MemberCount, &cr.CreatedAt)
+	return cr, err
+}
+
+type communityRoleRequest struct {
+	Name             *string `json:"name"`
+	Color            *string `json:"color"`
+	PermissionsAllow *int64  `json:"permissions_allow"`
+	PermissionsDeny  *int64  `json:"permissions_deny"`
+}
+
+// validateRolePermissions returns an error message, or "" if the bits can
+// be granted by a community role. Communities cannot make anyone a guild
+// administrator.
+func validateRolePermissions(bits *int64) string {
+	if bits == nil {
+		return ""
+	}
+	if uint64(*bits)&^(permissions.AllPermissions&^permissions.Administrator) != 0 {
+		return "Permissions must be guild permission bits; ADMINISTRATOR cannot be granted"
+	}
+	return ""
+}
+
+// mirrorRole creates the guild's copy of a community role above its other
+// roles.
+func mirrorRole(ctx context.Context, tx pgx.Tx, guildID string, cr models.CommunityRole) (models.Role, error) {
+	var role models.Role
+	err := tx.QueryRow(ctx,
+		`INSERT INTO roles (id, guild_id, name, color, position, permissions_allow, permissions_deny, community_role_id, created_at)
+		 VALUES ($1, $2, $3, $4, (SELECT COALESCE(MAX(position), 0) + 1 FROM roles WHERE guild_id = $2), $5, $6, $7, now())
+		 ON CONFLICT (guild_id, community_role_id) WHERE community_role_id IS NOT NULL DO UPDATE SET name = EXCLUDED.name
+		 RETURNING id, guild_id, name, color, hoist, mentionable, position, permissions_allow, permissions_deny,
+		           community_role_id, created_at`,
+		models.NewULID().String(), guildID, cr.Name, cr.Color, cr.PermissionsAllow, cr.PermissionsDeny, cr.ID,
+	).Scan(&role.ID, &role.GuildID, &role.Name, &role.Color, &role.Hoist, &role.Mentionable, &role.Position,
+		&role.PermissionsAllow, &role.PermissionsDeny, &role.CommunityRoleID, &role.CreatedAt)
+	return role, err
+}
+
+// mirroredRoles returns each guild's copy of a community role, keyed by
+// guild ID.
+func (h *Handler) mirroredRoles(ctx context.Context, communityRoleID string) map[string]string {
+	rows, err := h.Pool.Query(ctx,
+		`SELECT guild_id, id FROM roles WHERE community_role_id = $1`, communityRoleID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	mirrors := make(map[string]string)
+	for rows.Next() {
+		var guildID, roleID string
+		if rows.Scan(&guildID, &roleID) == nil {
+			mirrors[guildID] = roleID
+		}
+	}
+	return mirrors
+}
+
+// getCommunityRole loads a role of the community, writing a 404 if there
+// is none.
+func (h *Handler) getCommunityRole(w http.ResponseWriter, r *http.Request, communityID, roleID string) (models.CommunityRole, bool) {
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+communityRoleColumns+` FROM community_roles cr WHERE cr.id = $1 AND cr.community_id = $2`,
+		roleID, communityID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get community role", err)
+		return models.CommunityRole{}, false
+	}
+	cr, err := pgx.CollectExactlyOneRow(rows, scanCommunityRole)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "role_not_found", "Community role not found")
+		return cr, false
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read community role", err)
+		return cr, false
+	}
+	return cr, true
+}
+
+// HandleListCommunityRoles lists the community's roles.
+// GET /api/v1/communities/{communityID}/roles
+func (h *Handler) HandleListCommunityRoles(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+
+	if _, ok := h.communityOwner(w, r, communityID); !ok {
+		return
+	}
+	if !h.canView(r.Context(), communityID, userID) {
+		apiutil.WriteError(w, http.StatusNotFound, "community_not_found", "Community not found")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+communityRoleColumns+` FROM community_roles cr
+		 WHERE cr.community_id = $1 ORDER BY cr.created_at`, communityID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list community roles", err)
+		return
+	}
+	roles, err := pgx.CollectRows(rows, scanCommunityRole)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read community roles", err)
+		return
+	}
+	if roles == nil {
+		roles = []models.CommunityRole{}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, roles)
+}
+
+// HandleCreateCommunityRole creates a community role and mirrors it into
+// every member guild.
+// POST /api/v1/communities/{communityID}/roles
+func (h *Handler) HandleCreateCommunityRole(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+
+	if !h.requireOwner(w, r, communityID, userID) {
+		return
+	}
+
+	var req communityRoleRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" || len(*req.Name) > 100 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Name must be 1-100 characters")
+		return
+	}
+	if msg := validateRolePermissions(req.PermissionsAllow); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_permissions", msg)
+		return
+	}
+	if msg := validateRolePermissions(req.PermissionsDeny); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_permissions", msg)
+		return
+	}
+
+	var count int
+	h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM community_roles WHERE community_id = $1`, communityID).Scan(&count)
+	if count >= maxCommunityRoles {
+		apiutil.WriteError(w, http.StatusBadRequest, "limit_reached", "A community can have at most 25 roles")
+		return
+	}
+
+	cr := models.CommunityRole{
+		ID:          models.NewULID().String(),
+		CommunityID: communityID,
+		Name:        strings.TrimSpace(*req.Name),
+		Color:       req.Color,
+	}
+	if req.PermissionsAllow != nil {
+		cr.PermissionsAllow = *req.PermissionsAllow
+	}
+	if req.PermissionsDeny != nil {
+		cr.PermissionsDeny = *req.PermissionsDeny
+	}
+
+	var mirrors []models.Role
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(r.Context(),
+			`INSERT INTO community_roles (id, community_id, name, color, permissions_allow, permissions_deny, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, now())
+			 RETURNING created_at`,
+			cr.ID, communityID, cr.Name, cr.Color, cr.PermissionsAllow, cr.PermissionsDeny,
+		).Scan(&cr.CreatedAt); err != nil {
+			return err
+		}
+		for _, guildID := range h.activeGuildIDs(r.Context(), communityID) {
+			role, err := mirrorRole(r.Context(), tx, guildID, cr)
+			if err != nil {
+				return err
+			}
+			mirrors = append(mirrors, role)
+		}
+		return nil
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create community role", err)
+		return
+	}
+	for _, role := range mirrors {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildRoleCreate, "GUILD_ROLE_CREATE", role.GuildID, role)
+	}
+
+	apiutil.WriteJSON(w, http.StatusCreated, cr)
+}
+
+// HandleUpdateCommunityRole edits a community role and its copy in every
+// member guild. Positions in each guild are left alone.
+// PATCH /api/v1/communities/{communityID}/roles/{roleID}
+func (h *Handler) HandleUpdateCommunityRole(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+	roleID := chi.URLParam(r, "roleID")
+
+	if !h.requireOwner(w, r, communityID, userID) {
+		return
+	}
+
+	var req communityRoleRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" || len(trimmed) > 100 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Name must be 1-100 characters")
+			return
+		}
+		req.Name = &trimmed
+	}
+	if msg := validateRolePermissions(req.PermissionsAllow); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_permissions", msg)
+		return
+	}
+	if msg := validateRolePermissions(req.PermissionsDeny); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_permissions", msg)
+		return
+	}
+
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(),
+			`UPDATE community_roles SET
+				name = COALESCE($3, name),
+				color = COALESCE($4, color),
+				permissions_allow = COALESCE($5, permissions_allow),
+				permissions_deny = COALESCE($6, permissions_deny)
+			 WHERE id = $1 AND community_id = $2`,
+			roleID, communityID, req.Name, req.Color, req.PermissionsAllow, req.PermissionsDeny)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		_, err = tx.Exec(r.Context(),
+			`UPDATE roles r SET name = cr.name, color = cr.color,
+			        permissions_allow = cr.permissions_allow, permissions_deny = cr.permissions_deny
+			 FROM community_roles cr
+			 WHERE cr.id = $1 AND r.community_role_id = cr.id`, roleID)
+		return err
+	})
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "role_not_found", "Community role not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update community role", err)
+		return
+	}
+
+	cr, ok := h.getCommunityRole(w, r, communityID, roleID)
+	if !ok {
+		return
+	}
+	for guildID, mirrorID := range h.mirroredRoles(r.Context(), roleID) {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildRoleUpdate, "GUILD_ROLE_UPDATE", guildID, map[string]interface{}{
+			"id": mirrorID, "guild_id": guildID, "name": cr.Name, "color": cr.Color,
+			"permissions_allow": cr.PermissionsAllow, "permissions_deny": cr.PermissionsDeny,
+			"community_role_id": cr.ID,
+		})
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, cr)
+}
+
+// HandleDeleteCommunityRole deletes a community role and its copies.
+// DELETE /api/v1/communities/{communityID}/roles/{roleID}
+func (h *Handler) HandleDeleteCommunityRole(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+	roleID := chi.URLParam(r, "roleID")
+
+	if !h.requireOwner(w, r, communityID, userID) {
+		return
+	}
+
+	mirrors := h.mirroredRoles(r.Context(), roleID)
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM community_roles WHERE id = $1 AND community_id = $2`, roleID, communityID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete community role", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "role_not_found", "Community role not found")
+		return
+	}
+	for guildID, mirrorID := range mirrors {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildRoleDelete, "GUILD_ROLE_DELETE", guildID, map[string]string{
+			"guild_id": guildID, "role_id": mirrorID,
+		})
+	}
+
+	apiutil.WriteNoContent(w)
+}
+
+// HandleListCommunityRoleMembers lists the users holding a community role.
+// GET /api/v1/communities/{communityID}/roles/{roleID}/members
+func (h *Handler) HandleListCommunityRoleMembers(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+	roleID := chi.URLParam(r, "roleID")
+
+	if _, ok := h.communityOwner(w, r, communityID); !ok {
+		return
+	}
+	if !h.canView(r.Context(), communityID, userID) {
+		apiutil.WriteError(w, http.StatusNotFound, "community_not_found", "Community not found")
+		return
+	}
+	if _, ok := h.getCommunityRole(w, r, communityID, roleID); !ok {
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT u.id, u.instance_id, u.username, u.display_name, u.avatar_id
+		 FROM community_role_members m
+		 JOIN users u ON u.id = m.user_id
+		 WHERE m.role_id = $1
+		 ORDER BY m.granted_at`, roleID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list community role members", err)
+		return
+	}
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.User, error) {
+		var u models.User
+		err := row.Scan(&u.ID, &u.InstanceID, &u.Username, &u.DisplayName, &u.AvatarID)
+		return u, err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read community role members", err)
+		return
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, users)
+}
+
+// HandleGrantCommunityRole gives a user a community role, and its copy in
+// each community guild they are a member of.
+// PUT /api/v1/communities/{communityID}/roles/{roleID}/members/{userID}
+func (h *Handler) HandleGrantCommunityRole(w http.ResponseWriter, r *http.Request) {
+	actorID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+	roleID := chi.URLParam(r, "roleID")
+	targetID := chi.URLParam(r, "userID")
+
+	if !h.requireOwner(w, r, communityID, actorID) {
+		return
+	}
+	if _, ok := h.getCommunityRole(w, r, communityID, roleID); !ok {
+		return
+	}
+	var exists bool
+	h.Pool.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, targetID).Scan(&exists)
+	if !exists {
+		apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+
+	var guildIDs []string
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO community_role_members (role_id, user_id, granted_by, granted_at)
+			 VALUES ($1, $2, $3, now()) ON CONFLICT DO NOTHING`,
+			roleID, targetID, actorID); err != nil {
+			return err
+		}
+		rows, err := tx.Query(r.Context(),
+			`INSERT INTO member_roles (guild_id, user_id, role_id)
+			 SELECT r.guild_id, gm.user_id, r.id
+			 FROM roles r
+			 JOIN guild_members gm ON gm.guild_id = r.guild_id AND gm.user_id = $2
+			 WHERE r.community_role_id = $1
+			 ON CONFLICT DO NOTHING
+			 RETURNING guild_id`, roleID, targetID)
+		if err != nil {
+			return err
+		}
+		guildIDs, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		return err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to grant community role", err)
+		return
+	}
+	h.publishMemberRoles(r.Context(), guildIDs, targetID, roleID, "role_add")
+
+	apiutil.WriteNoContent(w)
+}
+
+// HandleRevokeCommunityRole takes a community role, and its copies, away
+// from a user.
+// DELETE /api/v1/communities/{communityID}/roles/{roleID}/members/{userID}
+func (h *Handler) HandleRevokeCommunityRole(w http.ResponseWriter, r *http.Request) {
+	actorID := auth.UserIDFromContext(r.Context())
+	communityID := chi.URLParam(r, "communityID")
+	roleID := chi.URLParam(r, "roleID")
+	targetID := chi.URLParam(r, "userID")
+
+	if !h.requireOwner(w, r, communityID, actorID) {
+		return
+	}
+	if _, ok := h.getCommunityRole(w, r, communityID, roleID); !ok {
+		return
+	}
+
+	var guildIDs []string
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(),
+			`DELETE FROM community_role_members WHERE role_id = $1 AND user_id = $2`, roleID, targetID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		rows, err := tx.Query(r.Context(),
+			`DELETE FROM member_roles mr USING roles r
+			 WHERE r.id = mr.role_id AND r.community_role_id = $1 AND mr.user_id = $2
+			 RETURNING mr.guild_id`, roleID, targetID)
+		if err != nil {
+			return err
+		}
+		guildIDs, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		return err
+	})
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "not_granted", "That user does not hold this role")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to revoke community role", err)
+		return
+	}
+	h.publishMemberRoles(r.Context(), guildIDs, targetID, roleID, "role_remove")
+
+	apiutil.WriteNoContent(w)
+}
+
+// publishMemberRoles sends GUILD_MEMBER_UPDATE to each guild where the
+// user's copy of a community role was added or removed.
+func (h *Handler) publishMemberRoles(ctx context.Context, guildIDs []string, userID, communityRoleID, action string) {
+	mirrors := h.mirroredRoles(ctx, communityRoleID)
+	for _, guildID := range guildIDs {
+		var roles []string
+		if rows, err := h.Pool.Query(ctx,
+			`SELECT role_id FROM member_roles WHERE guild_id = $1 AND user_id = $2`, guildID, userID); err == nil {
+			roles, _ = pgx.CollectRows(rows, pgx.RowTo[string])
+		}
+		h.EventBus.PublishGuildEvent(ctx, events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", guildID, map[string]interface{}{
+			"guild_id": guildID, "user_id": userID, "role_id": mirrors[guildID], "action": action,
+			"roles": roles,
+		})
+	}
+}
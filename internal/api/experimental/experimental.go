@@ -19,9 +19,11 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
 )
 
 // Handler implements experimental feature REST API endpoints.
@@ -169,7 +171,7 @@ func (h *Handler) HandleUpdateLiveLocation(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Active live location share not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Active live location share not found")
 		return
 	}
 
@@ -207,7 +209,7 @@ func (h *Handler) HandleStopLiveLocation(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Active live location share not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Active live location share not found")
 		return
 	}
 
@@ -313,7 +315,7 @@ func (h *Handler) HandleCreateMessageEffect(w http.ResponseWriter, r *http.Reque
 		`SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND channel_id = $2)`,
 		messageID, channelID).Scan(&msgExists)
 	if err != nil || !msgExists {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found in channel")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "Message not found in channel")
 		return
 	}
 
@@ -388,7 +390,7 @@ func (h *Handler) HandleAddSuperReaction(w http.ResponseWriter, r *http.Request)
 		`SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1 AND channel_id = $2)`,
 		messageID, channelID).Scan(&msgExists)
 	if err != nil || !msgExists {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "")
 		return
 	}
 
@@ -491,15 +493,15 @@ func (h *Handler) HandleSummarizeMessages(w http.ResponseWriter, r *http.Request
 	if req.FromID != "" {
 		rows, err = h.Pool.Query(r.Context(),
 			`SELECT id, content, author_id FROM messages
-			 WHERE channel_id = $1 AND id >= $2 AND content IS NOT NULL AND content != ''
+			 WHERE channel_id = $1 AND id >= $2 AND content IS NOT NULL AND content != '' AND flags & $4 = 0
 			 ORDER BY id ASC LIMIT $3`,
-			channelID, req.FromID, req.MessageCount)
+			channelID, req.FromID, req.MessageCount, models.MessageFlagDeleted|models.MessageFlagQuarantined)
 	} else {
 		rows, err = h.Pool.Query(r.Context(),
 			`SELECT id, content, author_id FROM messages
-			 WHERE channel_id = $1 AND content IS NOT NULL AND content != ''
+			 WHERE channel_id = $1 AND content IS NOT NULL AND content != '' AND flags & $3 = 0
 			 ORDER BY id DESC LIMIT $2`,
-			channelID, req.MessageCount)
+			channelID, req.MessageCount, models.MessageFlagDeleted|models.MessageFlagQuarantined)
 	}
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to fetch messages", err)
@@ -924,7 +926,7 @@ func (h *Handler) HandleUpdateWhiteboard(w http.ResponseWriter, r *http.Request)
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT locked, creator_id FROM whiteboards WHERE id = $1`, whiteboardID).Scan(&locked, &creatorID)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Whiteboard not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Whiteboard not found")
 		return
 	}
 	if err != nil {
@@ -997,7 +999,7 @@ func (h *Handler) HandleGetWhiteboardState(w http.ResponseWriter, r *http.Reques
 		&whiteboardID, &channelID, &guildID, &name, &creatorID, &state,
 		&width, &height, &bgColor, &locked, &maxCollab, &createdAt, &updatedAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Whiteboard not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Whiteboard not found")
 		return
 	}
 	if err != nil {
@@ -1165,7 +1167,7 @@ func (h *Handler) HandleGetCodeSnippet(w http.ResponseWriter, r *http.Request) {
 		&s.Code, &s.Stdin, &s.Output, &s.OutputError, &s.ExitCode, &s.RuntimeMs,
 		&s.Runnable, &s.Public, &s.CreatedAt, &s.UpdatedAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Code snippet not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Code snippet not found")
 		return
 	}
 	if err != nil {
@@ -1187,7 +1189,7 @@ func (h *Handler) HandleRunCodeSnippet(w http.ResponseWriter, r *http.Request) {
 		`SELECT language, code, stdin FROM code_snippets WHERE id = $1 AND runnable = true`,
 		snippetID).Scan(&language, &code, &stdin)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Runnable code snippet not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Runnable code snippet not found")
 		return
 	}
 	if err != nil {
@@ -1430,7 +1432,7 @@ func (h *Handler) HandleGetKanbanBoard(w http.ResponseWriter, r *http.Request) {
 		 FROM kanban_boards WHERE id = $1`, boardID).Scan(
 		&boardID, &channelID, &guildID, &name, &description, &creatorID, &createdAt, &updatedAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Kanban board not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Kanban board not found")
 		return
 	}
 	if err != nil {
@@ -1686,7 +1688,7 @@ func (h *Handler) HandleMoveKanbanCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Card not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Card not found")
 		return
 	}
 
@@ -1713,7 +1715,7 @@ func (h *Handler) HandleDeleteKanbanCard(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Card not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Card not found")
 		return
 	}
 
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/featureflags"
+)
+
+// FeatureEnabled reports whether the feature flag named key is on for
+// target. Flags that do not exist are off.
+func (s *Server) FeatureEnabled(ctx context.Context, key string, target featureflags.Target) bool {
+	return s.FeatureFlags.Enabled(ctx, key, target)
+}
+
+// requireFeature returns middleware that answers 404 unless the feature
+// flag named key is on for the caller, and for the guild when the route has
+// a {guildID}. Routes behind it look absent to those outside the rollout.
+func (s *Server) requireFeature(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target := featureflags.Target{
+				UserID:  auth.UserIDFromContext(r.Context()),
+				GuildID: chi.URLParam(r, "guildID"),
+			}
+			if !s.FeatureFlags.Enabled(r.Context(), key, target) {
+				WriteCode(w, apierrors.NotFound, "")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -3,9 +3,11 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -17,6 +19,10 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/redis/go-redis/v9"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/federation"
+	"github.com/amityvox/amityvox/internal/models"
 )
 
 // federationMediaClient is a shared HTTP client with a 30-second timeout for
@@ -30,6 +36,24 @@ const fedMediaCacheTTL = 1 * time.Hour
 // fedMediaMaxCacheableSize is the maximum file size cached in DragonflyDB (1MB).
 const fedMediaMaxCacheableSize = 1 << 20
 
+// defaultFedMediaMaxSize is the size cap for proxied federation media when
+// federation.media_max_size_mb is unset (100MB).
+const defaultFedMediaMaxSize = 100 << 20
+
+// errFedMediaTooLarge aborts a proxied transfer that exceeds the size cap.
+var errFedMediaTooLarge = errors.New("federation media exceeds size limit")
+
+// fedMediaAllowedTypes lists the non-image/video/audio types that may be
+// proxied from remote instances.
+var fedMediaAllowedTypes = map[string]bool{
+	"application/pdf":  true,
+	"application/zip":  true,
+	"application/json": true,
+	"text/plain":       true,
+	"text/markdown":    true,
+	"text/csv":         true,
+}
+
 // fedMediaMeta stores content-type metadata alongside cached media.
 type fedMediaMeta struct {
 	ContentType   string `json:"ct"`
@@ -37,9 +61,12 @@ type fedMediaMeta struct {
 }
 
 // handleFederationMediaProxy proxies media from federated instances so browsers
-// don't hit CORS errors when loading remote avatars, attachments, and embeds.
+// don't hit CORS errors when loading remote avatars, attachments, and embeds,
+// and never contact remote instances directly (which would leak their IPs).
 // The instanceID is validated against the instances table before any external
-// request is made, preventing SSRF via arbitrary host access.
+// request is made, preventing SSRF via arbitrary host access. Files are
+// fetched with a signed federation request, capped at
+// federation.media_max_size_mb, and limited to inert media types.
 //
 // Caching: small files (≤1MB) are cached in DragonflyDB with 1h TTL.
 // Large files (>1MB) are cached on disk with LRU eviction.
@@ -71,15 +98,20 @@ func (s *Server) handleFederationMediaProxy(w http.ResponseWriter, r *http.Reque
 	isRangeRequest := r.Header.Get("Range") != ""
 	cacheKey := fmt.Sprintf("fed:media:%s:%s", instanceID, fileID)
 
+	// Media from restricted peers is proxied but never cached, here or by
+	// clients, so nothing they serve outlives the request.
+	restricted := s.FedSvc != nil &&
+		s.FedSvc.PeerTrustLevel(r.Context(), instanceID) == models.PeerTrustRestricted
+
 	// Try DragonflyDB cache first (only for non-range requests).
-	if !isRangeRequest {
+	if !isRangeRequest && !restricted {
 		if served := s.serveFedMediaFromCache(w, r.Context(), cacheKey); served {
 			return
 		}
 	}
 
 	// Try disk cache (only for non-range requests).
-	if !isRangeRequest {
+	if !isRangeRequest && !restricted {
 		cacheDir := s.Config.Federation.MediaCacheDir
 		if cacheDir != "" {
 			if served := s.serveFedMediaFromDisk(w, cacheDir, instanceID, fileID); served {
@@ -95,37 +127,21 @@ func (s *Server) handleFederationMediaProxy(w http.ResponseWriter, r *http.Reque
 		`SELECT domain FROM instances WHERE id = $1`, instanceID).Scan(&domain)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			WriteError(w, http.StatusNotFound, "not_found", "Unknown instance")
+			WriteCode(w, apierrors.NotFound, "Unknown instance")
 		} else {
 			s.Logger.Error("federation media proxy: failed to look up instance",
 				slog.String("instance_id", instanceID),
 				slog.String("error", err.Error()))
-			WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up instance")
+			WriteCode(w, apierrors.InternalError, "Failed to look up instance")
 		}
 		return
 	}
 
-	// Fetch the file from the remote instance's public file endpoint.
-	remoteURL := fmt.Sprintf("https://%s/api/v1/files/%s", domain, fileID)
-	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, remoteURL, nil)
-	if err != nil {
-		s.Logger.Error("federation media proxy: failed to create request",
-			slog.String("url", remoteURL),
-			slog.String("error", err.Error()))
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to create remote request")
-		return
-	}
-	req.Header.Set("User-Agent", "AmityVox/1.0 (+federation-media-proxy)")
-
-	// Forward Range header from the client for partial content requests.
-	if isRangeRequest {
-		req.Header.Set("Range", r.Header.Get("Range"))
-	}
-
-	resp, err := federationMediaClient.Do(req)
+	resp, err := s.fetchFederationMedia(r.Context(), domain, fileID, r.Header.Get("Range"))
 	if err != nil {
 		s.Logger.Error("federation media proxy: fetch failed",
-			slog.String("url", remoteURL),
+			slog.String("domain", domain),
+			slog.String("file_id", fileID),
 			slog.String("error", err.Error()))
 		WriteError(w, http.StatusBadGateway, "bad_gateway", "Failed to fetch remote media")
 		return
@@ -134,10 +150,11 @@ func (s *Server) handleFederationMediaProxy(w http.ResponseWriter, r *http.Reque
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		if resp.StatusCode == http.StatusNotFound {
-			WriteError(w, http.StatusNotFound, "not_found", "Remote media not found")
+			WriteCode(w, apierrors.NotFound, "Remote media not found")
 		} else {
 			s.Logger.Warn("federation media proxy: remote returned non-200",
-				slog.String("url", remoteURL),
+				slog.String("domain", domain),
+				slog.String("file_id", fileID),
 				slog.Int("status", resp.StatusCode))
 			WriteError(w, http.StatusBadGateway, "bad_gateway", "Remote instance returned an error")
 		}
@@ -147,10 +164,27 @@ func (s *Server) handleFederationMediaProxy(w http.ResponseWriter, r *http.Reque
 	contentType := resp.Header.Get("Content-Type")
 	contentLength, _ := strconv.Atoi(resp.Header.Get("Content-Length"))
 
-	// Set response headers.
-	if contentType != "" {
-		w.Header().Set("Content-Type", contentType)
+	// Refuse types a browser could execute (HTML, SVG, scripts) and anything
+	// over the configured size cap before sending a single byte to the client.
+	if !fedMediaTypeAllowed(contentType) {
+		s.Logger.Warn("federation media proxy: rejected content type",
+			slog.String("domain", domain),
+			slog.String("file_id", fileID),
+			slog.String("content_type", contentType))
+		WriteError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "Remote media type is not allowed")
+		return
+	}
+	maxBytes := s.fedMediaMaxBytes()
+	if int64(contentLength) > maxBytes {
+		WriteError(w, http.StatusRequestEntityTooLarge, "media_too_large", "Remote media exceeds the size limit")
+		return
 	}
+	body := &cappedReader{r: resp.Body, remaining: maxBytes}
+
+	// Set response headers.
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
 	if contentLength > 0 {
 		w.Header().Set("Content-Length", strconv.Itoa(contentLength))
 	}
@@ -160,44 +194,165 @@ func (s *Server) handleFederationMediaProxy(w http.ResponseWriter, r *http.Reque
 	if ar := resp.Header.Get("Accept-Ranges"); ar != "" {
 		w.Header().Set("Accept-Ranges", ar)
 	}
-	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	if restricted {
+		w.Header().Set("Cache-Control", "private, no-store")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	}
 	w.Header().Set("X-Federation-Instance", domain)
 
 	// Range requests or unknown-size responses: stream directly, no caching.
 	if isRangeRequest || resp.StatusCode == http.StatusPartialContent {
 		w.WriteHeader(resp.StatusCode)
-		io.Copy(w, resp.Body)
+		io.Copy(w, body)
 		return
 	}
 
 	// Cacheable: read body and cache based on size.
 	if contentLength > 0 && contentLength <= fedMediaMaxCacheableSize {
 		// Small file — cache in DragonflyDB.
-		body, err := io.ReadAll(io.LimitReader(resp.Body, fedMediaMaxCacheableSize+1))
+		data, err := io.ReadAll(io.LimitReader(body, fedMediaMaxCacheableSize+1))
 		if err != nil {
 			s.Logger.Warn("federation media proxy: failed to read body for caching",
 				slog.String("error", err.Error()))
 			WriteError(w, http.StatusBadGateway, "bad_gateway", "Failed to read remote media")
 			return
 		}
+		// Small files are buffered anyway, so check the declared type
+		// against the actual bytes.
+		if !fedMediaContentMatches(contentType, data) {
+			s.Logger.Warn("federation media proxy: content does not match declared type",
+				slog.String("domain", domain),
+				slog.String("file_id", fileID),
+				slog.String("content_type", contentType))
+			WriteError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "Remote media content does not match its type")
+			return
+		}
 		w.WriteHeader(http.StatusOK)
-		w.Write(body)
+		w.Write(data)
 		// Cache asynchronously to not block response.
-		go s.cacheFedMediaInRedis(cacheKey, contentType, body)
+		if !restricted {
+			go s.cacheFedMediaInRedis(cacheKey, contentType, data)
+		}
 		return
 	}
 
 	// Large file or unknown size — stream to client + cache on disk.
 	cacheDir := s.Config.Federation.MediaCacheDir
-	if cacheDir != "" && contentLength > fedMediaMaxCacheableSize {
+	if cacheDir != "" && contentLength > fedMediaMaxCacheableSize && !restricted {
 		w.WriteHeader(http.StatusOK)
-		s.streamAndCacheFedMediaToDisk(w, resp.Body, cacheDir, instanceID, fileID, contentType, contentLength)
+		s.streamAndCacheFedMediaToDisk(w, body, cacheDir, instanceID, fileID, contentType, contentLength)
 		return
 	}
 
 	// Fallback: stream directly.
 	w.WriteHeader(http.StatusOK)
-	io.Copy(w, resp.Body)
+	io.Copy(w, body)
+}
+
+// fetchFederationMedia requests a file from a peer through its signed
+// federation media endpoint. Peers that predate the endpoint answer 404
+// without the media header; for those the public file route is used.
+func (s *Server) fetchFederationMedia(ctx context.Context, domain, fileID, rangeHeader string) (*http.Response, error) {
+	if s.FedSvc != nil {
+		req, err := s.FedSvc.NewSignedMediaRequest(ctx, domain, fileID)
+		if err != nil {
+			return nil, err
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		resp, err := federationMediaClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusNotFound || resp.Header.Get(federation.MediaHeader) != "" {
+			return resp, nil
+		}
+		resp.Body.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("https://%s/api/v1/files/%s", domain, fileID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "AmityVox/1.0 (+federation-media-proxy)")
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	return federationMediaClient.Do(req)
+}
+
+// fedMediaMaxBytes returns the configured size cap for proxied federation media.
+func (s *Server) fedMediaMaxBytes() int64 {
+	if mb := s.Config.Federation.MediaMaxSizeMB; mb > 0 {
+		return int64(mb) << 20
+	}
+	return defaultFedMediaMaxSize
+}
+
+// fedMediaTypeAllowed reports whether a remote content type may be proxied to
+// clients. Only inert media types are allowed; SVG is excluded because it can
+// carry script.
+func fedMediaTypeAllowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"):
+		return true
+	}
+	return fedMediaAllowedTypes[mediaType]
+}
+
+// fedMediaContentMatches sniffs the first bytes of a file and rejects image,
+// video, and audio files whose content is clearly something else, such as an
+// HTML page labelled as a PNG. Content the sniffer cannot identify passes.
+func fedMediaContentMatches(contentType string, data []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	sniffed := http.DetectContentType(data)
+	if strings.HasPrefix(sniffed, "text/html") || strings.HasPrefix(sniffed, "text/xml") {
+		return false
+	}
+	declared, _, _ := strings.Cut(mediaType, "/")
+	switch declared {
+	case "image", "video", "audio":
+		kind, _, _ := strings.Cut(sniffed, "/")
+		return kind == declared || strings.HasPrefix(sniffed, "application/octet-stream") ||
+			// Several audio/video containers sniff as each other.
+			(declared != "image" && (kind == "video" || kind == "audio"))
+	}
+	return true
+}
+
+// cappedReader reads at most remaining bytes and then fails, so a peer that
+// lies about Content-Length cannot stream past the size cap.
+type cappedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		// A file exactly at the cap is fine; only fail if more data follows.
+		var probe [1]byte
+		if n, err := c.r.Read(probe[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, errFedMediaTooLarge
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	return n, err
 }
 
 // serveFedMediaFromCache serves a federation media file from DragonflyDB if cached.
@@ -228,6 +383,8 @@ func (s *Server) serveFedMediaFromCache(w http.ResponseWriter, ctx context.Conte
 	}
 	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
 	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
 	w.Header().Set("X-Federation-Cache", "hit-redis")
 	w.WriteHeader(http.StatusOK)
 	w.Write(body)
@@ -283,6 +440,8 @@ func (s *Server) serveFedMediaFromDisk(w http.ResponseWriter, cacheDir, instance
 		w.Header().Set("Content-Length", strconv.Itoa(meta.ContentLength))
 	}
 	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
 	w.Header().Set("X-Federation-Cache", "hit-disk")
 	w.WriteHeader(http.StatusOK)
 	io.Copy(w, f)
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/gateway"
+)
+
+// guildTrafficView is a guild's gateway load with the guild and producer
+// names filled in for operators.
+type guildTrafficView struct {
+	gateway.GuildTraffic
+	Name         string         `json:"name,omitempty"`
+	TopProducers []producerView `json:"top_producers"`
+}
+
+type producerView struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username,omitempty"`
+	Events   int64  `json:"events"`
+}
+
+// handleGetGatewayStats returns this node's gateway connections and the
+// guilds driving the most dispatch load over the traffic window.
+// GET /api/v1/admin/gateway?limit=
+func (s *Server) handleGetGatewayStats(w http.ResponseWriter, r *http.Request) {
+	if s.GatewayConnections == nil || s.GatewayTraffic == nil {
+		WriteError(w, http.StatusServiceUnavailable, "gateway_unavailable", "Gateway statistics are not available on this node")
+		return
+	}
+	traffic := s.GatewayTraffic()
+	limit, _ := parsePagination(r)
+	if int(limit) < len(traffic) {
+		traffic = traffic[:limit]
+	}
+	resp := map[string]interface{}{
+		"connections": s.GatewayConnections(),
+		"guilds":      s.describeGuildTraffic(r.Context(), traffic),
+	}
+	if s.GatewayLatency != nil {
+		resp["latency"] = s.GatewayLatency()
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// handleGetGuildGatewayStats returns one guild's gateway load on this node.
+// GET /api/v1/admin/gateway/guilds/{guildID}
+func (s *Server) handleGetGuildGatewayStats(w http.ResponseWriter, r *http.Request) {
+	if s.GatewayTraffic == nil {
+		WriteError(w, http.StatusServiceUnavailable, "gateway_unavailable", "Gateway statistics are not available on this node")
+		return
+	}
+	guildID := chi.URLParam(r, "guildID")
+	for _, gt := range s.GatewayTraffic() {
+		if gt.GuildID == guildID {
+			WriteJSON(w, http.StatusOK, s.describeGuildTraffic(r.Context(), []gateway.GuildTraffic{gt})[0])
+			return
+		}
+	}
+	// No sessions and no recent events: the guild is idle on this node.
+	WriteJSON(w, http.StatusOK, s.describeGuildTraffic(r.Context(), []gateway.GuildTraffic{{
+		GuildID:       guildID,
+		TopEventTypes: []gateway.TrafficCount{},
+	}})[0])
+}
+
+// describeGuildTraffic looks up the names of the guilds and producers in
+// traffic. Names that cannot be found are left blank.
+func (s *Server) describeGuildTraffic(ctx context.Context, traffic []gateway.GuildTraffic) []guildTrafficView {
+	guildIDs := make([]string, 0, len(traffic))
+	var userIDs []string
+	for _, gt := range traffic {
+		guildIDs = append(guildIDs, gt.GuildID)
+		for _, p := range gt.TopProducers {
+			userIDs = append(userIDs, p.Key)
+		}
+	}
+	guildNames := s.lookupNames(ctx, `SELECT id, name FROM guilds WHERE id = ANY($1)`, guildIDs)
+	usernames := s.lookupNames(ctx, `SELECT id, username FROM users WHERE id = ANY($1)`, userIDs)
+
+	out := make([]guildTrafficView, len(traffic))
+	for i, gt := range traffic {
+		producers := make([]producerView, len(gt.TopProducers))
+		for j, p := range gt.TopProducers {
+			producers[j] = producerView{UserID: p.Key, Username: usernames[p.Key], Events: p.Count}
+		}
+		out[i] = guildTrafficView{GuildTraffic: gt, Name: guildNames[gt.GuildID], TopProducers: producers}
+	}
+	return out
+}
+
+// lookupNames runs query, which selects (id, name) pairs for the IDs bound
+// to $1, and returns them as a map.
+func (s *Server) lookupNames(ctx context.Context, query string, ids []string) map[string]string {
+	names := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return names
+	}
+	rows, err := s.DB.Pool.Query(ctx, query, ids)
+	if err != nil {
+		s.Logger.Debug("looking up names for gateway stats failed", slog.String("error", err.Error()))
+		return names
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name string
+		if rows.Scan(&id, &name) == nil {
+			names[id] = name
+		}
+	}
+	return names
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 )
@@ -35,7 +36,7 @@ func (s *Server) handleGiphySearch(w http.ResponseWriter, r *http.Request) {
 
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -125,7 +126,7 @@ func (s *Server) handleGiphyCategories(w http.ResponseWriter, r *http.Request) {
 
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -188,7 +189,7 @@ func (s *Server) handleGiphyTrending(w http.ResponseWriter, r *http.Request) {
 
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
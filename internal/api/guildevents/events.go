@@ -14,6 +14,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -128,7 +129,7 @@ func (h *Handler) HandleCreateEvent(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -261,7 +262,7 @@ func (h *Handler) HandleListEvents(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -356,7 +357,7 @@ func (h *Handler) HandleGetEvent(w http.ResponseWriter, r *http.Request) {
 	h.autoCancelOverdueEvents(r.Context(), guildID)
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -416,7 +417,7 @@ func (h *Handler) HandleUpdateEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !h.isCreatorOrAdmin(r.Context(), guildID, userID, creatorID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to update this event")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to update this event")
 		return
 	}
 
@@ -505,7 +506,7 @@ func (h *Handler) HandleDeleteEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !h.isCreatorOrAdmin(r.Context(), guildID, userID, creatorID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to delete this event")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to delete this event")
 		return
 	}
 
@@ -538,7 +539,7 @@ func (h *Handler) HandleRSVP(w http.ResponseWriter, r *http.Request) {
 	eventID := chi.URLParam(r, "eventID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -607,7 +608,7 @@ func (h *Handler) HandleDeleteRSVP(w http.ResponseWriter, r *http.Request) {
 	eventID := chi.URLParam(r, "eventID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -658,7 +659,7 @@ func (h *Handler) HandleListRSVPs(w http.ResponseWriter, r *http.Request) {
 	eventID := chi.URLParam(r, "eventID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
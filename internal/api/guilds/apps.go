@@ -0,0 +1,456 @@
+// Guild app install handlers.
+// Installing a bot from the directory adds it as a guild member with a role
+// of its own carrying the permissions it was granted, which may be fewer
+// than its listing asks for. Uninstalling removes the member and the role.
+//
+// The grant is also a sandbox: permission checks cap an installed app at
+// it whatever other roles or overrides it is given, its managed role cannot
+// be reassigned or widened, and the app cannot hand out permissions it was
+// not granted. Mounted under /api/v1/guilds/{guildID}/apps.
+package guilds
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// holdsPermissions reports whether the user has every permission in perms,
+// so installers cannot hand a bot more than they hold themselves.
+func (h *Handler) holdsPermissions(ctx context.Context, guildID, userID string, perms uint64) bool {
+	if h.hasGuildPermission(ctx, guildID, userID, permissions.Administrator) {
+		return true
+	}
+	for bit := uint64(1); bit != 0 && bit <= perms; bit <<= 1 {
+		if perms&bit != 0 && !h.hasGuildPermission(ctx, guildID, userID, bit) {
+			return false
+		}
+	}
+	return true
+}
+
+// appGrant returns the permissions the user was granted when installed into
+// the guild as an app. ok is false for users that are not installed apps.
+func (h *Handler) appGrant(ctx context.Context, guildID, userID string) (grant uint64, ok bool) {
+	var perms int64
+	if err := h.Pool.QueryRow(ctx,
+		`SELECT permissions FROM bot_installs WHERE guild_id = $1 AND bot_id = $2`,
+		guildID, userID).Scan(&perms); err != nil {
+		return 0, false
+	}
+	return uint64(perms), true
+}
+
+// roleManager returns the bot that manages the role, or nil if the role is
+// an ordinary one.
+func (h *Handler) roleManager(ctx context.Context, guildID, roleID string) *string {
+	var botID *string
+	h.Pool.QueryRow(ctx,
+		`SELECT managed_by FROM roles WHERE id = $1 AND guild_id = $2`, roleID, guildID).Scan(&botID)
+	return botID
+}
+
+// HandleInstallApp installs a bot into the guild. The bot must have an
+// approved directory listing unless the installer owns it. permissions, if
+// given, must be a subset of what the listing requests.
+// POST /api/v1/guilds/{guildID}/apps
+func (h *Handler) HandleInstallApp(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var req struct {
+		BotID       string `json:"bot_id"`
+		Permissions *int64 `json:"permissions"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if !apiutil.RequireNonEmpty(w, "bot_id", req.BotID) {
+		return
+	}
+
+	var (
+		username  string
+		ownerID   *string
+		status    string
+		requested int64
+		scopes    []string
+	)
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT u.username, u.bot_owner_id, l.review_status, l.permissions, l.scopes
+		 FROM bot_listings l JOIN users u ON u.id = l.bot_id
+		 WHERE l.bot_id = $1 AND u.flags & $2 != 0`,
+		req.BotID, models.UserFlagBot,
+	).Scan(&username, &ownerID, &status, &requested, &scopes)
+	if err == nil && status != models.BotListingApproved && (ownerID == nil || *ownerID != userID) {
+		err = pgx.ErrNoRows
+	}
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "app_not_found", "No installable app with that ID")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up app", err)
+		return
+	}
+
+	granted := requested
+	if req.Permissions != nil {
+		if *req.Permissions < 0 || *req.Permissions&^requested != 0 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_permissions", "permissions must be a subset of what the app requests")
+			return
+		}
+		granted = *req.Permissions
+	}
+	if !h.holdsPermissions(r.Context(), guildID, userID, uint64(granted)) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You cannot grant permissions you do not have")
+		return
+	}
+
+	if h.isMember(r.Context(), guildID, req.BotID) {
+		apiutil.WriteError(w, http.StatusConflict, "already_installed", "This app is already in the guild")
+		return
+	}
+
+	now := time.Now().UTC()
+	var role models.Role
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		// The bot's role sits at the bottom of the hierarchy; admins can
+		// move it up if the bot manages other roles.
+		if err := tx.QueryRow(r.Context(),
+			`INSERT INTO roles (id, guild_id, name, position, permissions_allow, permissions_deny, managed_by, created_at)
+			 VALUES ($1, $2, $3, 0, $4, 0, $5, $6)
+			 RETURNING id, guild_id, name, color, hoist, mentionable, position, permissions_allow, permissions_deny, managed_by, created_at`,
+			models.NewULID().String(), guildID, username, granted, req.BotID, now,
+		).Scan(
+			&role.ID, &role.GuildID, &role.Name, &role.Color, &role.Hoist, &role.Mentionable,
+			&role.Position, &role.PermissionsAllow, &role.PermissionsDeny, &role.ManagedBy, &role.CreatedAt,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO guild_members (guild_id, user_id, joined_at) VALUES ($1, $2, $3)`,
+			guildID, req.BotID, now); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO member_roles (guild_id, user_id, role_id) VALUES ($1, $2, $3)`,
+			guildID, req.BotID, role.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO bot_guild_permissions (bot_id, guild_id, scopes, max_role_position, created_at, updated_at)
+			 VALUES ($1, $2, $3, 0, now(), now())
+			 ON CONFLICT (bot_id, guild_id) DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = now()`,
+			req.BotID, guildID, scopes); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO bot_installs (bot_id, guild_id, installed_by, role_id, permissions, installed_at)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (bot_id, guild_id) DO UPDATE SET
+			     installed_by = EXCLUDED.installed_by, role_id = EXCLUDED.role_id,
+			     permissions = EXCLUDED.permissions, installed_at = EXCLUDED.installed_at`,
+			req.BotID, guildID, userID, role.ID, granted, now); err != nil {
+			return err
+		}
+		_, err := tx.Exec(r.Context(),
+			`UPDATE bot_listings SET install_count = install_count + 1 WHERE bot_id = $1`, req.BotID)
+		return err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to install app", err)
+		return
+	}
+
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildRoleCreate, "GUILD_ROLE_CREATE", guildID, role)
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildMemberAdd, "GUILD_MEMBER_ADD", guildID,
+		map[string]interface{}{
+			"guild_id":  guildID,
+			"user_id":   req.BotID,
+			"joined_at": now,
+			"roles":     []string{role.ID},
+		})
+	h.logAudit(r.Context(), guildID, userID, "app_install", "user", req.BotID, nil)
+
+	apiutil.WriteJSON(w, http.StatusCreated, models.BotInstall{
+		BotID:       req.BotID,
+		GuildID:     guildID,
+		Username:    username,
+		InstalledBy: &userID,
+		RoleID:      &role.ID,
+		Permissions: granted,
+		InstalledAt: now,
+	})
+}
+
+// HandleGetGuildApps lists the apps installed in the guild.
+// GET /api/v1/guilds/{guildID}/apps
+func (h *Handler) HandleGetGuildApps(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT bi.bot_id, bi.guild_id, u.username, bi.installed_by, bi.role_id, bi.permissions, bi.installed_at
+		 FROM bot_installs bi JOIN users u ON u.id = bi.bot_id
+		 WHERE bi.guild_id = $1
+		 ORDER BY bi.installed_at`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get apps", err)
+		return
+	}
+	defer rows.Close()
+
+	apps := []models.BotInstall{}
+	for rows.Next() {
+		var a models.BotInstall
+		if err := rows.Scan(&a.BotID, &a.GuildID, &a.Username, &a.InstalledBy, &a.RoleID,
+			&a.Permissions, &a.InstalledAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read apps", err)
+			return
+		}
+		apps = append(apps, a)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, apps)
+}
+
+// HandleUninstallApp removes an installed app, its membership and its role.
+// DELETE /api/v1/guilds/{guildID}/apps/{botID}
+func (h *Handler) HandleUninstallApp(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	botID := chi.URLParam(r, "botID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var roleID *string
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(r.Context(),
+			`DELETE FROM bot_installs WHERE bot_id = $1 AND guild_id = $2 RETURNING role_id`,
+			botID, guildID).Scan(&roleID); err != nil {
+			return err
+		}
+		if roleID != nil {
+			if _, err := tx.Exec(r.Context(),
+				`DELETE FROM roles WHERE id = $1 AND guild_id = $2`, *roleID, guildID); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(r.Context(),
+			`DELETE FROM bot_guild_permissions WHERE bot_id = $1 AND guild_id = $2`, botID, guildID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(r.Context(),
+			`DELETE FROM guild_members WHERE guild_id = $1 AND user_id = $2`, guildID, botID)
+		return err
+	})
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "app_not_found", "That app is not installed in this guild")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to uninstall app", err)
+		return
+	}
+
+	if roleID != nil {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildRoleDelete, "GUILD_ROLE_DELETE", guildID,
+			map[string]string{"guild_id": guildID, "role_id": *roleID})
+	}
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildMemberRemove, "GUILD_MEMBER_REMOVE", guildID,
+		map[string]string{"guild_id": guildID, "user_id": botID})
+	h.logAudit(r.Context(), guildID, userID, "app_uninstall", "user", botID, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// appChannelAccess is a channel an installed app can see, with what it can
+// do there.
+type appChannelAccess struct {
+	ChannelID       string   `json:"channel_id"`
+	Name            *string  `json:"name"`
+	ChannelType     string   `json:"channel_type"`
+	Permissions     int64    `json:"permissions"`
+	PermissionNames []string `json:"permission_names"`
+	ReadHistory     bool     `json:"read_history"`
+}
+
+// appPermissionAudit describes what an installed app can do in a guild.
+type appPermissionAudit struct {
+	BotID          string             `json:"bot_id"`
+	Username       string             `json:"username"`
+	Scopes         []string           `json:"scopes"`
+	ManagedRoleID  *string            `json:"managed_role_id"`
+	RoleIDs        []string           `json:"role_ids"`
+	Granted        int64              `json:"granted"`
+	GrantedNames   []string           `json:"granted_names"`
+	Effective      int64              `json:"effective"`
+	EffectiveNames []string           `json:"effective_names"`
+	Channels       []appChannelAccess `json:"channels"`
+}
+
+// HandleAuditAppPermissions reports what an installed app is able to do: the
+// permissions it was granted, the roles it holds, its effective guild
+// permissions after the sandbox cap, and every channel it can view.
+// GET /api/v1/guilds/{guildID}/apps/{botID}/permissions
+func (h *Handler) HandleAuditAppPermissions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	botID := chi.URLParam(r, "botID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	audit := appPermissionAudit{BotID: botID, RoleIDs: []string{}, Channels: []appChannelAccess{}}
+	var (
+		guild   permissions.GuildInfo
+		member  = permissions.MemberInfo{UserID: botID}
+		granted int64
+	)
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT u.username, COALESCE(bgp.scopes, '{}'), bi.role_id, bi.permissions,
+		        g.owner_id, COALESCE(g.default_permissions, 0), gm.timeout_until
+		 FROM bot_installs bi
+		 JOIN users u ON u.id = bi.bot_id
+		 JOIN guilds g ON g.id = bi.guild_id
+		 LEFT JOIN guild_members gm ON gm.guild_id = bi.guild_id AND gm.user_id = bi.bot_id
+		 LEFT JOIN bot_guild_permissions bgp ON bgp.guild_id = bi.guild_id AND bgp.bot_id = bi.bot_id
+		 WHERE bi.guild_id = $1 AND bi.bot_id = $2`,
+		guildID, botID,
+	).Scan(&audit.Username, &audit.Scopes, &audit.ManagedRoleID, &granted,
+		&guild.OwnerID, &guild.DefaultPermissions, &member.TimeoutUntil)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "app_not_found", "That app is not installed in this guild")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up app", err)
+		return
+	}
+	grant := uint64(granted)
+	member.Cap = &grant
+	audit.Granted = granted
+	audit.GrantedNames = permissions.Names(grant)
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT r.id, r.position, r.permissions_allow, r.permissions_deny
+		 FROM roles r JOIN member_roles mr ON mr.role_id = r.id
+		 WHERE mr.guild_id = $1 AND mr.user_id = $2
+		 ORDER BY r.position DESC`, guildID, botID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get app roles", err)
+		return
+	}
+	var roles []permissions.RoleInfo
+	for rows.Next() {
+		var role permissions.RoleInfo
+		var allow, deny int64
+		if err := rows.Scan(&role.ID, &role.Position, &allow, &deny); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read app roles", err)
+			return
+		}
+		role.PermissionsAllow, role.PermissionsDeny = uint64(allow), uint64(deny)
+		roles = append(roles, role)
+		audit.RoleIDs = append(audit.RoleIDs, role.ID)
+	}
+	rows.Close()
+
+	effective := permissions.CalculatePermissions(member, guild, roles, nil)
+	audit.Effective = int64(effective)
+	audit.EffectiveNames = permissions.Names(effective)
+
+	type channelRow struct {
+		access  appChannelAccess
+		channel permissions.ChannelInfo
+	}
+	var channels []*channelRow
+	byID := map[string]*channelRow{}
+	rows, err = h.Pool.Query(r.Context(),
+		`SELECT id, name, channel_type, default_permissions
+		 FROM channels WHERE guild_id = $1
+		 ORDER BY position, id`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channels", err)
+		return
+	}
+	for rows.Next() {
+		c := &channelRow{}
+		var everyone *int64
+		if err := rows.Scan(&c.access.ChannelID, &c.access.Name, &c.access.ChannelType, &everyone); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read channels", err)
+			return
+		}
+		if everyone != nil {
+			allow := uint64(*everyone)
+			c.channel.DefaultPermissionsAllow = &allow
+		}
+		channels = append(channels, c)
+		byID[c.access.ChannelID] = c
+	}
+	rows.Close()
+
+	rows, err = h.Pool.Query(r.Context(),
+		`SELECT o.channel_id, o.target_type, o.target_id, o.permissions_allow, o.permissions_deny
+		 FROM channel_permission_overrides o JOIN channels c ON c.id = o.channel_id
+		 WHERE c.guild_id = $1`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel overrides", err)
+		return
+	}
+	for rows.Next() {
+		var channelID string
+		var o permissions.ChannelOverride
+		var allow, deny int64
+		if err := rows.Scan(&channelID, &o.TargetType, &o.TargetID, &allow, &deny); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read channel overrides", err)
+			return
+		}
+		o.PermissionsAllow, o.PermissionsDeny = uint64(allow), uint64(deny)
+		if c := byID[channelID]; c != nil {
+			c.channel.Overrides = append(c.channel.Overrides, o)
+		}
+	}
+	rows.Close()
+
+	for _, c := range channels {
+		perms := permissions.CalculatePermissions(member, guild, roles, &c.channel)
+		if perms&permissions.ViewChannel == 0 {
+			continue
+		}
+		c.access.Permissions = int64(perms)
+		c.access.PermissionNames = permissions.Names(perms)
+		c.access.ReadHistory = perms&permissions.ReadHistory != 0
+		audit.Channels = append(audit.Channels, c.access)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, audit)
+}
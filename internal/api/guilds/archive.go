@@ -0,0 +1,174 @@
+// Guild archive browser handlers.
+// Archived channels and threads are left out of the sidebar payloads; these
+// endpoints let members find them again and let moderators bring them back.
+// Mounted under /api/v1/guilds/{guildID}/archive.
+package guilds
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// archiveKeyset orders archived channels by their last activity, newest
+// first.
+var archiveKeyset = apiutil.Keyset{Desc: true, Columns: []apiutil.KeyColumn{
+	{Expr: "COALESCE(c.last_activity_at, c.created_at)", Type: "timestamptz"},
+	{Expr: "c.id", Type: "text"},
+}}
+
+// archivedChannelColumns matches the channel list sent to the sidebar.
+const archivedChannelColumns = `c.id, c.guild_id, c.category_id, c.channel_type, c.name, c.topic, c.position,
+	c.slowmode_seconds, c.nsfw, c.encrypted, c.last_message_id, c.owner_id,
+	c.default_permissions, c.user_limit, c.bitrate, c.locked, c.locked_by, c.locked_at,
+	c.archived, c.parent_channel_id, c.last_activity_at,
+	c.nsfw_inherited, c.slowmode_inherited, c.notification_level, c.notification_level_inherited, c.version, c.created_at`
+
+func scanArchivedChannel(row pgx.Row) (models.Channel, error) {
+	var c models.Channel
+	err := row.Scan(
+		&c.ID, &c.GuildID, &c.CategoryID, &c.ChannelType, &c.Name, &c.Topic,
+		&c.Position, &c.SlowmodeSeconds, &c.NSFW, &c.Encrypted, &c.LastMessageID,
+		&c.OwnerID, &c.DefaultPermissions, &c.UserLimit, &c.Bitrate,
+		&c.Locked, &c.LockedBy, &c.LockedAt, &c.Archived,
+		&c.ParentChannelID, &c.LastActivityAt,
+		&c.NSFWInherited, &c.SlowmodeInherited, &c.NotificationLevel, &c.NotificationLevelInherited, &c.Version, &c.CreatedAt,
+	)
+	return c, err
+}
+
+// HandleGetArchivedChannels lists the guild's archived channels and threads
+// the caller can view, most recently active first, a page at a time. A page
+// may hold fewer than limit entries when some are hidden from the caller.
+// ?kind=channels|threads narrows the list, ?parent_id= keeps the threads of
+// one channel, and ?q= matches the name or topic.
+// GET /api/v1/guilds/{guildID}/archive?kind=&parent_id=&q=&before=&after=&limit=
+func (h *Handler) HandleGetArchivedChannels(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	q := r.URL.Query()
+	kind := q.Get("kind")
+	if kind != "" && kind != "channels" && kind != "threads" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_kind", "kind must be channels or threads")
+		return
+	}
+	page, ok := apiutil.ParsePage(w, r, archiveKeyset, apiutil.DefaultPageLimit)
+	if !ok {
+		return
+	}
+	cursorSQL, cursorArgs := page.Where(archiveKeyset, 7)
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+archivedChannelColumns+`
+		 FROM channels c
+		 WHERE c.guild_id = $1 AND c.archived AND (NOT c.nsfw OR $3)
+		   AND ($4 = '' OR ($4 = 'threads') = (c.parent_channel_id IS NOT NULL))
+		   AND ($5 = '' OR c.parent_channel_id = $5)
+		   AND ($6 = '' OR c.name ILIKE '%' || $6 || '%' OR c.topic ILIKE '%' || $6 || '%')`+cursorSQL+`
+		 ORDER BY `+page.OrderBy(archiveKeyset)+`
+		 LIMIT $2`,
+		append([]interface{}{guildID, page.FetchLimit(),
+			apiutil.ContentSettings(r.Context(), h.Pool, userID).ShowNSFW,
+			kind, q.Get("parent_id"), q.Get("q")}, cursorArgs...)...,
+	)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get archived channels", err)
+		return
+	}
+	channels, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Channel, error) {
+		return scanArchivedChannel(row)
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read archived channels", err)
+		return
+	}
+	channels, next := apiutil.FinishPage(page, channels, func(c models.Channel) []interface{} {
+		activity := c.CreatedAt
+		if c.LastActivityAt != nil {
+			activity = *c.LastActivityAt
+		}
+		return []interface{}{activity, c.ID}
+	})
+
+	visible := make([]models.Channel, 0, len(channels))
+	for _, c := range channels {
+		perms, err := apiutil.MemberChannelPermissions(r.Context(), h.Pool, guildID, c.ID, userID)
+		if err != nil || perms&permissions.ViewChannel == 0 {
+			continue
+		}
+		visible = append(visible, c)
+	}
+
+	apiutil.WritePage(w, visible, next)
+}
+
+// HandleRestoreArchivedChannel unarchives a channel or thread. Threads need
+// MANAGE_THREADS or MANAGE_CHANNELS and get a fresh activity time so they are
+// not auto-archived again straight away; channels need MANAGE_CHANNELS.
+// POST /api/v1/guilds/{guildID}/archive/{channelID}/restore
+func (h *Handler) HandleRestoreArchivedChannel(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	channelID := chi.URLParam(r, "channelID")
+
+	var parentID *string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT parent_channel_id FROM channels WHERE id = $1 AND guild_id = $2 AND archived`,
+		channelID, guildID).Scan(&parentID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "No archived channel with that ID in this guild")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel", err)
+		return
+	}
+
+	perms, err := apiutil.MemberChannelPermissions(r.Context(), h.Pool, guildID, channelID, userID)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to check permissions", err)
+		return
+	}
+	if parentID != nil {
+		if perms&(permissions.ManageThreads|permissions.ManageChannels) == 0 {
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_THREADS permission")
+			return
+		}
+	} else if perms&permissions.ManageChannels == 0 {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	channel, err := scanArchivedChannel(h.Pool.QueryRow(r.Context(),
+		`UPDATE channels c SET archived = false,
+		        last_activity_at = CASE WHEN c.parent_channel_id IS NOT NULL THEN now() ELSE c.last_activity_at END
+		 WHERE c.id = $1 AND c.archived
+		 RETURNING `+archivedChannelColumns, channelID))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "No archived channel with that ID in this guild")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to restore channel", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "channel_unarchive", "channel", channelID, nil)
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_UPDATE", guildID, channel)
+
+	apiutil.WriteJSON(w, http.StatusOK, channel)
+}
@@ -0,0 +1,131 @@
+package guilds
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+)
+
+// MemberSuggestion is a guild member offered by autocomplete, carrying just
+// what a mention picker shows.
+type MemberSuggestion struct {
+	UserID      string  `json:"user_id"`
+	Username    string  `json:"username"`
+	DisplayName *string `json:"display_name,omitempty"`
+	Nickname    *string `json:"nickname,omitempty"`
+	AvatarID    *string `json:"avatar_id,omitempty"`
+}
+
+// GuildSuggestion is a discoverable guild offered by autocomplete.
+type GuildSuggestion struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	IconID      *string `json:"icon_id,omitempty"`
+	MemberCount int     `json:"member_count"`
+}
+
+// HandleAutocompleteGuildMembers suggests guild members whose nickname,
+// display name or username starts with the query. Members blocking or blocked
+// by the requester are left out. Exact matches come first, then shorter names.
+// GET /api/v1/guilds/{guildID}/members/autocomplete?q=<prefix>&limit=
+func (h *Handler) HandleAutocompleteGuildMembers(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if !apiutil.RequireNonEmpty(w, "Query parameter 'q'", query) {
+		return
+	}
+
+	// Each branch of the union is served by one prefix index.
+	rows, err := h.Pool.Query(r.Context(),
+		`WITH matches AS (
+		     SELECT gm.user_id FROM guild_members gm
+		     WHERE gm.guild_id = $1 AND lower(gm.nickname) LIKE $2
+		     UNION
+		     SELECT u.id FROM users u
+		     JOIN guild_members gm ON gm.guild_id = $1 AND gm.user_id = u.id
+		     WHERE lower(u.username) LIKE $2
+		     UNION
+		     SELECT u.id FROM users u
+		     JOIN guild_members gm ON gm.guild_id = $1 AND gm.user_id = u.id
+		     WHERE lower(u.display_name) LIKE $2
+		 )
+		 SELECT u.id, u.username, u.display_name, gm.nickname, u.avatar_id
+		 FROM matches
+		 JOIN users u ON u.id = matches.user_id
+		 JOIN guild_members gm ON gm.guild_id = $1 AND gm.user_id = u.id
+		 WHERE NOT EXISTS (
+		     SELECT 1 FROM user_relationships ur
+		     WHERE ur.status = 'blocked'
+		       AND ((ur.user_id = $3 AND ur.target_id = u.id) OR (ur.user_id = u.id AND ur.target_id = $3)))
+		 ORDER BY lower(COALESCE(gm.nickname, u.display_name, u.username)) = $4 DESC,
+		          length(COALESCE(gm.nickname, u.display_name, u.username)),
+		          lower(u.username)
+		 LIMIT $5`,
+		guildID, apiutil.PrefixPattern(query), userID, strings.ToLower(query), apiutil.AutocompleteLimit(r),
+	)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to autocomplete members", err)
+		return
+	}
+	defer rows.Close()
+
+	suggestions := make([]MemberSuggestion, 0)
+	for rows.Next() {
+		var s MemberSuggestion
+		if err := rows.Scan(&s.UserID, &s.Username, &s.DisplayName, &s.Nickname, &s.AvatarID); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read member suggestions", err)
+			return
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, suggestions)
+}
+
+// HandleAutocompleteDiscoverGuilds suggests discoverable guilds whose name
+// starts with the query, largest first.
+// GET /api/v1/guilds/discover/autocomplete?q=<prefix>&limit=
+func (h *Handler) HandleAutocompleteDiscoverGuilds(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if !apiutil.RequireNonEmpty(w, "Query parameter 'q'", query) {
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT id, name, icon_id, member_count
+		 FROM guilds
+		 WHERE discoverable = true AND lower(name) LIKE $1
+		 ORDER BY member_count DESC, lower(name)
+		 LIMIT $2`,
+		apiutil.PrefixPattern(query), apiutil.AutocompleteLimit(r),
+	)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to autocomplete guilds", err)
+		return
+	}
+	defer rows.Close()
+
+	suggestions := make([]GuildSuggestion, 0)
+	for rows.Next() {
+		var s GuildSuggestion
+		if err := rows.Scan(&s.ID, &s.Name, &s.IconID, &s.MemberCount); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read guild suggestions", err)
+			return
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, suggestions)
+}
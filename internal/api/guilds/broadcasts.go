@@ -0,0 +1,286 @@
+// Guild broadcast DM handlers.
+// A guild owner can send an announcement to the DMs of members who opted in
+// to the guild's broadcasts. Recipients are fixed when the broadcast is
+// created and the broadcast worker delivers it under the instance's
+// broadcast_limits. Mounted under /api/v1/guilds/{guildID}/broadcasts.
+package guilds
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// maxBroadcastLength bounds a broadcast's text. It is shorter than a
+// message so the worker has room to say which guild it came from.
+const maxBroadcastLength = 2000
+
+// broadcastColumns selects a models.GuildBroadcast with its delivery counts.
+const broadcastColumns = `b.id, b.guild_id, b.author_id, b.content, b.status, b.recipient_count,
+	(SELECT COUNT(*) FROM guild_broadcast_deliveries d WHERE d.broadcast_id = b.id AND d.status = 'delivered'),
+	(SELECT COUNT(*) FROM guild_broadcast_deliveries d WHERE d.broadcast_id = b.id AND d.status = 'skipped'),
+	(SELECT COUNT(*) FROM guild_broadcast_deliveries d WHERE d.broadcast_id = b.id AND d.status = 'failed'),
+	b.created_at, b.started_at, b.completed_at`
+
+func scanBroadcast(row pgx.Row) (models.GuildBroadcast, error) {
+	var b models.GuildBroadcast
+	err := row.Scan(&b.ID, &b.GuildID, &b.AuthorID, &b.Content, &b.Status, &b.RecipientCount,
+		&b.DeliveredCount, &b.SkippedCount, &b.FailedCount, &b.CreatedAt, &b.StartedAt, &b.CompletedAt)
+	return b, err
+}
+
+// errNoBroadcastRecipients and errTooManyBroadcastRecipients abort the
+// create transaction once the recipients have been counted.
+var (
+	errNoBroadcastRecipients      = errors.New("no subscribed members")
+	errTooManyBroadcastRecipients = errors.New("too many subscribed members")
+)
+
+// validateBroadcastContent returns an error message, or "" if content can
+// be broadcast.
+func validateBroadcastContent(content string) string {
+	if strings.TrimSpace(content) == "" {
+		return "content must not be empty"
+	}
+	if len(content) > maxBroadcastLength {
+		return fmt.Sprintf("content must be at most %d characters", maxBroadcastLength)
+	}
+	return ""
+}
+
+// HandleGetGuildBroadcasts lists the guild's 50 most recent broadcasts with
+// their delivery progress. Only the owner can see them.
+// GET /api/v1/guilds/{guildID}/broadcasts
+func (h *Handler) HandleGetGuildBroadcasts(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isGuildOwner(r.Context(), guildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_owner", "Only the guild owner can send broadcasts")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+broadcastColumns+` FROM guild_broadcasts b
+		 WHERE b.guild_id = $1 ORDER BY b.created_at DESC LIMIT 50`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get broadcasts", err)
+		return
+	}
+	broadcasts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.GuildBroadcast, error) {
+		return scanBroadcast(row)
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read broadcasts", err)
+		return
+	}
+	if broadcasts == nil {
+		broadcasts = []models.GuildBroadcast{}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, broadcasts)
+}
+
+// HandleCreateGuildBroadcast queues a broadcast to every member subscribed
+// to the guild's broadcasts. The instance caps how many a guild may send a
+// day, counting cancelled ones, and how many members one may reach.
+// POST /api/v1/guilds/{guildID}/broadcasts
+func (h *Handler) HandleCreateGuildBroadcast(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isGuildOwner(r.Context(), guildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_owner", "Only the guild owner can send broadcasts")
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if msg := validateBroadcastContent(req.Content); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_broadcast", msg)
+		return
+	}
+
+	var raw string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT value FROM instance_settings WHERE key = 'broadcast_limits'`).Scan(&raw)
+	limits := models.ParseBroadcastLimits(raw)
+	if !limits.Enabled {
+		apiutil.WriteError(w, http.StatusForbidden, "broadcasts_disabled", "Broadcasts are disabled on this instance")
+		return
+	}
+
+	var sentToday int
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM guild_broadcasts WHERE guild_id = $1 AND created_at > now() - interval '1 day'`,
+		guildID).Scan(&sentToday); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to count broadcasts", err)
+		return
+	}
+	if sentToday >= limits.PerGuildPerDay {
+		apiutil.WriteError(w, http.StatusTooManyRequests, "broadcast_limit",
+			fmt.Sprintf("A guild may send at most %d broadcasts a day", limits.PerGuildPerDay))
+		return
+	}
+
+	broadcastID := models.NewULID().String()
+	now := time.Now()
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO guild_broadcasts (id, guild_id, author_id, content, status, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			broadcastID, guildID, userID, req.Content, models.BroadcastStatusPending, now); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(r.Context(),
+			`INSERT INTO guild_broadcast_deliveries (broadcast_id, user_id)
+			 SELECT $1, s.user_id FROM guild_broadcast_subscriptions s
+			 JOIN guild_members gm ON gm.guild_id = s.guild_id AND gm.user_id = s.user_id
+			 WHERE s.guild_id = $2 AND s.subscribed AND s.user_id <> $3`,
+			broadcastID, guildID, userID)
+		if err != nil {
+			return err
+		}
+		switch n := tag.RowsAffected(); {
+		case n == 0:
+			return errNoBroadcastRecipients
+		case n > int64(limits.MaxRecipients):
+			return errTooManyBroadcastRecipients
+		}
+		_, err = tx.Exec(r.Context(),
+			`UPDATE guild_broadcasts SET recipient_count = $2 WHERE id = $1`, broadcastID, tag.RowsAffected())
+		return err
+	})
+	switch {
+	case errors.Is(err, errNoBroadcastRecipients):
+		apiutil.WriteError(w, http.StatusBadRequest, "no_recipients", "No members are subscribed to this guild's broadcasts")
+		return
+	case errors.Is(err, errTooManyBroadcastRecipients):
+		apiutil.WriteError(w, http.StatusBadRequest, "too_many_recipients",
+			fmt.Sprintf("A broadcast may reach at most %d members", limits.MaxRecipients))
+		return
+	case err != nil:
+		apiutil.InternalError(w, h.Logger, "Failed to create broadcast", err)
+		return
+	}
+
+	b, err := scanBroadcast(h.Pool.QueryRow(r.Context(),
+		`SELECT `+broadcastColumns+` FROM guild_broadcasts b WHERE b.id = $1`, broadcastID))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get broadcast", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "broadcast_create", "broadcast", broadcastID, nil)
+	apiutil.WriteJSON(w, http.StatusCreated, b)
+}
+
+// HandleCancelGuildBroadcast stops a broadcast that has not finished.
+// Members it already reached keep their DM; the rest are skipped.
+// DELETE /api/v1/guilds/{guildID}/broadcasts/{broadcastID}
+func (h *Handler) HandleCancelGuildBroadcast(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	broadcastID := chi.URLParam(r, "broadcastID")
+
+	if !h.isGuildOwner(r.Context(), guildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "not_owner", "Only the guild owner can send broadcasts")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE guild_broadcasts SET status = $3, completed_at = now()
+		 WHERE id = $1 AND guild_id = $2 AND status IN ($4, $5)`,
+		broadcastID, guildID, models.BroadcastStatusCancelled,
+		models.BroadcastStatusPending, models.BroadcastStatusSending)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to cancel broadcast", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "broadcast_not_found", "No unfinished broadcast with this ID in the guild")
+		return
+	}
+	h.Pool.Exec(r.Context(),
+		`UPDATE guild_broadcast_deliveries SET status = 'skipped', updated_at = now()
+		 WHERE broadcast_id = $1 AND status = 'pending'`, broadcastID)
+
+	h.logAudit(r.Context(), guildID, userID, "broadcast_cancel", "broadcast", broadcastID, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetBroadcastSubscription returns whether the caller receives the
+// guild's broadcasts.
+// GET /api/v1/guilds/{guildID}/broadcasts/subscription
+func (h *Handler) HandleGetBroadcastSubscription(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	var subscribed bool
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT subscribed FROM guild_broadcast_subscriptions WHERE guild_id = $1 AND user_id = $2`,
+		guildID, userID).Scan(&subscribed)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to get broadcast subscription", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, map[string]bool{"subscribed": subscribed})
+}
+
+// HandleSetBroadcastSubscription opts the caller in to or out of the guild's
+// broadcasts. Opting out also skips any broadcast still on its way to them.
+// PUT /api/v1/guilds/{guildID}/broadcasts/subscription
+func (h *Handler) HandleSetBroadcastSubscription(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	var req struct {
+		Subscribed bool `json:"subscribed"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO guild_broadcast_subscriptions (guild_id, user_id, subscribed, updated_at)
+		 VALUES ($1, $2, $3, now())
+		 ON CONFLICT (guild_id, user_id) DO UPDATE SET subscribed = $3, updated_at = now()`,
+		guildID, userID, req.Subscribed); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update broadcast subscription", err)
+		return
+	}
+	if !req.Subscribed {
+		h.Pool.Exec(r.Context(),
+			`UPDATE guild_broadcast_deliveries d SET status = 'skipped', updated_at = now()
+			 FROM guild_broadcasts b
+			 WHERE b.id = d.broadcast_id AND b.guild_id = $1 AND d.user_id = $2 AND d.status = 'pending'`,
+			guildID, userID)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, map[string]bool{"subscribed": req.Subscribed})
+}
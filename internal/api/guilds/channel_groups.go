@@ -12,6 +12,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -97,7 +98,7 @@ func (h *Handler) HandleCreateChannelGroup(w http.ResponseWriter, r *http.Reques
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You need ManageChannels permission")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You need ManageChannels permission")
 		return
 	}
 
@@ -171,7 +172,7 @@ func (h *Handler) HandleUpdateChannelGroup(w http.ResponseWriter, r *http.Reques
 	groupID := chi.URLParam(r, "groupID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You need ManageChannels permission")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You need ManageChannels permission")
 		return
 	}
 
@@ -257,7 +258,7 @@ func (h *Handler) HandleDeleteChannelGroup(w http.ResponseWriter, r *http.Reques
 	groupID := chi.URLParam(r, "groupID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You need ManageChannels permission")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You need ManageChannels permission")
 		return
 	}
 
@@ -308,7 +309,7 @@ func (h *Handler) HandleSetGroupChannels(w http.ResponseWriter, r *http.Request)
 	groupID := chi.URLParam(r, "groupID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You need ManageChannels permission")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You need ManageChannels permission")
 		return
 	}
 
@@ -381,7 +382,7 @@ func (h *Handler) HandleRemoveChannelFromGroup(w http.ResponseWriter, r *http.Re
 	channelID := chi.URLParam(r, "channelID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You need ManageChannels permission")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You need ManageChannels permission")
 		return
 	}
 
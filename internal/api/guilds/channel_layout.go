@@ -0,0 +1,306 @@
+package guilds
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// --- Channel Layout ---
+//
+// Moving a channel or category renumbers its siblings server-side so that
+// positions stay 0..n-1 within each category (and among categories), with
+// no duplicates or gaps for clients to reconcile. The new layout goes out as
+// one CHANNEL_POSITIONS_UPDATE event instead of a CHANNEL_UPDATE per
+// renumbered channel.
+
+// channelPosition is where a top-level channel sits in the sidebar.
+// CategoryID is nil for channels outside any category.
+type channelPosition struct {
+	ID         string  `json:"id"`
+	CategoryID *string `json:"category_id"`
+	Position   int     `json:"position"`
+}
+
+// categoryPosition is where a category sits among the guild's categories.
+type categoryPosition struct {
+	ID       string `json:"id"`
+	Position int    `json:"position"`
+}
+
+// channelPositionsUpdate is the CHANNEL_POSITIONS_UPDATE payload and the
+// response of the move endpoints. It lists every top-level channel or every
+// category of the guild with its normalized position.
+type channelPositionsUpdate struct {
+	GuildID    string             `json:"guild_id"`
+	Channels   []channelPosition  `json:"channels,omitempty"`
+	Categories []categoryPosition `json:"categories,omitempty"`
+}
+
+// moveChannelRequest names the destination of a channel. A null or missing
+// category_id moves the channel out of any category. Position is the index
+// among the destination's channels and is clamped to the valid range.
+type moveChannelRequest struct {
+	CategoryID *string `json:"category_id"`
+	Position   int     `json:"position"`
+}
+
+// moveCategoryRequest is the new index of a category, clamped to the valid
+// range.
+type moveCategoryRequest struct {
+	Position int `json:"position"`
+}
+
+// errLayoutNotFound is returned inside the move transactions when the item
+// being moved is not part of the guild.
+var errLayoutNotFound = errors.New("layout item not found")
+
+// moveID removes id from ids and inserts it at index, clamped to the bounds
+// of the remaining slice.
+func moveID(ids []string, id string, index int) []string {
+	out := make([]string, 0, len(ids)+1)
+	for _, other := range ids {
+		if other != id {
+			out = append(out, other)
+		}
+	}
+	if index < 0 {
+		index = 0
+	}
+	if index > len(out) {
+		index = len(out)
+	}
+	out = append(out, "")
+	copy(out[index+1:], out[index:])
+	out[index] = id
+	return out
+}
+
+// layoutKey groups channels by category; "" is the uncategorized group.
+func layoutKey(categoryID *string) string {
+	if categoryID == nil {
+		return ""
+	}
+	return *categoryID
+}
+
+// moveChannelInLayout moves channel id into categoryID at index and
+// renumbers every category from 0, keeping the existing order of the
+// channels that did not move. layout must be sorted by position; the result
+// keeps the same order, with the moved channel in its new place.
+func moveChannelInLayout(layout []channelPosition, id string, categoryID *string, index int) []channelPosition {
+	groups := map[string][]string{}
+	var order []string
+	for _, c := range layout {
+		key := layoutKey(c.CategoryID)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		if c.ID != id {
+			groups[key] = append(groups[key], c.ID)
+		}
+	}
+	dest := layoutKey(categoryID)
+	if _, ok := groups[dest]; !ok {
+		order = append(order, dest)
+	}
+	groups[dest] = moveID(groups[dest], id, index)
+
+	out := make([]channelPosition, 0, len(layout))
+	for _, key := range order {
+		var cat *string
+		if key != "" {
+			k := key
+			cat = &k
+		}
+		for i, chID := range groups[key] {
+			out = append(out, channelPosition{ID: chID, CategoryID: cat, Position: i})
+		}
+	}
+	return out
+}
+
+// HandleMoveChannel moves a channel to a position within a category, or out
+// of categories, and renumbers the channels of the old and new category.
+// POST /api/v1/guilds/{guildID}/channels/{channelID}/move
+func (h *Handler) HandleMoveChannel(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	channelID := chi.URLParam(r, "channelID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	var req moveChannelRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.CategoryID != nil && *req.CategoryID == "" {
+		req.CategoryID = nil
+	}
+
+	if req.CategoryID != nil {
+		var exists bool
+		if err := h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM guild_categories WHERE id = $1 AND guild_id = $2)`,
+			*req.CategoryID, guildID).Scan(&exists); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to look up category", err)
+			return
+		}
+		if !exists {
+			apiutil.WriteError(w, http.StatusNotFound, "category_not_found", "Category not found")
+			return
+		}
+	}
+
+	var layout []channelPosition
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		// Lock the guild's top-level channels so concurrent moves renumber
+		// one after the other.
+		rows, err := tx.Query(r.Context(),
+			`SELECT id, category_id, position FROM channels
+			 WHERE guild_id = $1 AND parent_channel_id IS NULL
+			 ORDER BY position, id
+			 FOR UPDATE`, guildID)
+		if err != nil {
+			return err
+		}
+		var current []channelPosition
+		found := false
+		for rows.Next() {
+			var c channelPosition
+			if err := rows.Scan(&c.ID, &c.CategoryID, &c.Position); err != nil {
+				rows.Close()
+				return err
+			}
+			found = found || c.ID == channelID
+			current = append(current, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if !found {
+			return errLayoutNotFound
+		}
+
+		before := make(map[string]channelPosition, len(current))
+		for _, c := range current {
+			before[c.ID] = c
+		}
+		layout = moveChannelInLayout(current, channelID, req.CategoryID, req.Position)
+		for _, c := range layout {
+			old := before[c.ID]
+			if layoutKey(old.CategoryID) != layoutKey(c.CategoryID) {
+				// Changing category_id fires channel_inherit_category, so
+				// inherited settings follow the new category.
+				if _, err := tx.Exec(r.Context(),
+					`UPDATE channels SET category_id = $2, position = $3 WHERE id = $1`,
+					c.ID, c.CategoryID, c.Position); err != nil {
+					return err
+				}
+			} else if old.Position != c.Position {
+				if _, err := tx.Exec(r.Context(),
+					`UPDATE channels SET position = $2 WHERE id = $1`, c.ID, c.Position); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errLayoutNotFound) {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "Channel not found in this guild")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to move channel", err)
+		return
+	}
+
+	update := channelPositionsUpdate{GuildID: guildID, Channels: layout}
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectChannelPositionsUpdate, "CHANNEL_POSITIONS_UPDATE", guildID, update)
+	apiutil.WriteJSON(w, http.StatusOK, update)
+}
+
+// HandleMoveCategory moves a category to a new index and renumbers the
+// guild's categories.
+// POST /api/v1/guilds/{guildID}/categories/{categoryID}/move
+func (h *Handler) HandleMoveCategory(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	categoryID := chi.URLParam(r, "categoryID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	var req moveCategoryRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	var layout []categoryPosition
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(),
+			`SELECT id, position FROM guild_categories
+			 WHERE guild_id = $1
+			 ORDER BY position, id
+			 FOR UPDATE`, guildID)
+		if err != nil {
+			return err
+		}
+		var ids []string
+		before := map[string]int{}
+		for rows.Next() {
+			var c categoryPosition
+			if err := rows.Scan(&c.ID, &c.Position); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, c.ID)
+			before[c.ID] = c.Position
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if _, ok := before[categoryID]; !ok {
+			return errLayoutNotFound
+		}
+
+		for i, id := range moveID(ids, categoryID, req.Position) {
+			layout = append(layout, categoryPosition{ID: id, Position: i})
+			if before[id] == i {
+				continue
+			}
+			if _, err := tx.Exec(r.Context(),
+				`UPDATE guild_categories SET position = $2 WHERE id = $1`, id, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errLayoutNotFound) {
+		apiutil.WriteError(w, http.StatusNotFound, "category_not_found", "Category not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to move category", err)
+		return
+	}
+
+	update := channelPositionsUpdate{GuildID: guildID, Categories: layout}
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectChannelPositionsUpdate, "CHANNEL_POSITIONS_UPDATE", guildID, update)
+	apiutil.WriteJSON(w, http.StatusOK, update)
+}
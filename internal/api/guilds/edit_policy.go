@@ -0,0 +1,98 @@
+// Guild message edit policy handlers.
+// The policy sets how long after sending members may edit a message, whether
+// each edit is recorded in the audit log for moderators, and whether
+// messages with a pending report are locked against edits. It is enforced by
+// the message edit endpoint. Mounted under /api/v1/guilds/{guildID}/edit-policy.
+package guilds
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxEditWindowMinutes is the longest edit window a guild may set, one week.
+const maxEditWindowMinutes = 7 * 24 * 60
+
+type updateEditPolicyRequest struct {
+	EditWindowMinutes *int  `json:"edit_window_minutes"`
+	SnapshotEdits     *bool `json:"snapshot_edits"`
+	LockReported      *bool `json:"lock_reported"`
+}
+
+// HandleGetEditPolicy returns the guild's message edit policy, or no limits
+// if it has never been set. Members can read it so clients know when
+// editing closes.
+// GET /api/v1/guilds/{guildID}/edit-policy
+func (h *Handler) HandleGetEditPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	policy := models.GuildEditPolicy{GuildID: guildID}
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT edit_window_minutes, snapshot_edits, lock_reported, updated_at
+		 FROM guild_edit_policy WHERE guild_id = $1`, guildID,
+	).Scan(&policy.EditWindowMinutes, &policy.SnapshotEdits, &policy.LockReported, &policy.UpdatedAt)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to get edit policy", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, policy)
+}
+
+// HandleUpdateEditPolicy changes the guild's message edit policy. A shorter
+// window applies to messages already sent.
+// PATCH /api/v1/guilds/{guildID}/edit-policy
+func (h *Handler) HandleUpdateEditPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var req updateEditPolicyRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.EditWindowMinutes != nil && (*req.EditWindowMinutes < 0 || *req.EditWindowMinutes > maxEditWindowMinutes) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_window",
+			"edit_window_minutes must be between 0 (no limit) and 10080")
+		return
+	}
+
+	var policy models.GuildEditPolicy
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO guild_edit_policy (guild_id, edit_window_minutes, snapshot_edits, lock_reported, updated_at)
+		 VALUES ($1, COALESCE($2, 0), COALESCE($3, false), COALESCE($4, false), now())
+		 ON CONFLICT (guild_id) DO UPDATE SET
+		     edit_window_minutes = COALESCE($2, guild_edit_policy.edit_window_minutes),
+		     snapshot_edits = COALESCE($3, guild_edit_policy.snapshot_edits),
+		     lock_reported = COALESCE($4, guild_edit_policy.lock_reported),
+		     updated_at = now()
+		 RETURNING guild_id, edit_window_minutes, snapshot_edits, lock_reported, updated_at`,
+		guildID, req.EditWindowMinutes, req.SnapshotEdits, req.LockReported,
+	).Scan(&policy.GuildID, &policy.EditWindowMinutes, &policy.SnapshotEdits,
+		&policy.LockReported, &policy.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update edit policy", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "EDIT_POLICY_UPDATE", "guild", guildID, nil)
+	apiutil.WriteJSON(w, http.StatusOK, policy)
+}
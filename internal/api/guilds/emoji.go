@@ -0,0 +1,200 @@
+// Guild emoji quota and moderation helpers.
+// A guild's emoji slots come from the instance's quota tiers for its boost
+// tier, unless an instance admin has set limits for the guild. New emoji are
+// checked against the guild's AutoMod emoji_hash rules; a blocked image is
+// rejected or, for log-only rules, kept flagged and hidden from members
+// until a moderator approves it.
+package guilds
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/automod"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxEmojiName is the longest emoji name.
+const maxEmojiName = 32
+
+// emojiColumns is the column list scanned by scanEmoji.
+const emojiColumns = `id, guild_id, name, creator_id, animated, s3_key, image_hash, flagged, flag_reason, created_at`
+
+func scanEmoji(row pgx.Row) (models.CustomEmoji, error) {
+	e := models.CustomEmoji{Origin: models.EmojiOriginGuild}
+	err := row.Scan(&e.ID, &e.GuildID, &e.Name, &e.CreatorID, &e.Animated, &e.S3Key,
+		&e.ImageHash, &e.Flagged, &e.FlagReason, &e.CreatedAt)
+	return e, err
+}
+
+// listedEmojiSQL is a guild's emoji followed by the instance emoji, which
+// are usable in every guild, as emojiColumns plus origin and file_id.
+// $1 is the guild and $2 whether AutoMod-flagged emoji are included.
+const listedEmojiSQL = `SELECT ` + emojiColumns + `, 'guild' AS origin, NULL::text AS file_id
+	 FROM custom_emoji WHERE guild_id = $1 AND (NOT flagged OR $2)
+	 UNION ALL
+	 SELECT ie.id, '', ie.name, ie.creator_id, ie.animated, a.s3_key, a.sha256, false, NULL, ie.created_at,
+	        'instance', ie.file_id
+	 FROM instance_emoji ie JOIN attachments a ON a.id = ie.file_id`
+
+func scanListedEmoji(row pgx.Row) (models.CustomEmoji, error) {
+	var e models.CustomEmoji
+	err := row.Scan(&e.ID, &e.GuildID, &e.Name, &e.CreatorID, &e.Animated, &e.S3Key,
+		&e.ImageHash, &e.Flagged, &e.FlagReason, &e.CreatedAt, &e.Origin, &e.FileID)
+	if e.Origin == models.EmojiOriginInstance {
+		e.URL = "/api/v1/emoji/" + e.ID
+	}
+	return e, err
+}
+
+// emojiQuotaSQL reads a guild's emoji limits and usage. Flagged emoji count
+// against the quota.
+const emojiQuotaSQL = `SELECT g.boost_tier,
+	        COALESCE(g.emoji_max_static, t.max_static, 0),
+	        COALESCE(g.emoji_max_animated, t.max_animated, 0),
+	        g.emoji_max_static IS NOT NULL OR g.emoji_max_animated IS NOT NULL,
+	        (SELECT COUNT(*) FROM custom_emoji e WHERE e.guild_id = g.id AND NOT e.animated),
+	        (SELECT COUNT(*) FROM custom_emoji e WHERE e.guild_id = g.id AND e.animated)
+	 FROM guilds g
+	 LEFT JOIN LATERAL (
+	     SELECT max_static, max_animated FROM emoji_quota_tiers
+	     WHERE boost_tier <= g.boost_tier ORDER BY boost_tier DESC LIMIT 1
+	 ) t ON true
+	 WHERE g.id = $1`
+
+func scanEmojiQuota(row pgx.Row, guildID string) (models.EmojiQuota, error) {
+	q := models.EmojiQuota{GuildID: guildID}
+	err := row.Scan(&q.BoostTier, &q.MaxStatic, &q.MaxAnimated, &q.Override, &q.StaticUsed, &q.AnimatedUsed)
+	return q, err
+}
+
+// emojiSlotFree reports whether the quota has room for another emoji of the
+// given kind.
+func emojiSlotFree(q models.EmojiQuota, animated bool) bool {
+	if animated {
+		return q.AnimatedUsed < q.MaxAnimated
+	}
+	return q.StaticUsed < q.MaxStatic
+}
+
+// emojiNameTaken reports whether name collides with an existing emoji.
+// Names collide regardless of case, since they are typed as :name:.
+func emojiNameTaken(ctx context.Context, tx pgx.Tx, guildID, name, exceptID string) (bool, error) {
+	var taken bool
+	err := tx.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM custom_emoji WHERE guild_id = $1 AND lower(name) = lower($2) AND id <> $3)`,
+		guildID, name, exceptID).Scan(&taken)
+	return taken, err
+}
+
+// guildEmojiNames returns the lowercased names of a guild's emoji.
+func guildEmojiNames(ctx context.Context, tx pgx.Tx, guildID string) (map[string]bool, error) {
+	rows, err := tx.Query(ctx, `SELECT lower(name) FROM custom_emoji WHERE guild_id = $1`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := map[string]bool{}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		names[n] = true
+	}
+	return names, rows.Err()
+}
+
+// uniqueEmojiName returns name, or name with the lowest free numeric suffix
+// ("party_2", "party_3", ...) if it is taken, shortening the base so the
+// result stays within maxEmojiName. taken holds lowercased names.
+func uniqueEmojiName(name string, taken map[string]bool) string {
+	if !taken[strings.ToLower(name)] {
+		return name
+	}
+	for n := 2; ; n++ {
+		suffix := "_" + strconv.Itoa(n)
+		base := name
+		if len(base)+len(suffix) > maxEmojiName {
+			base = base[:maxEmojiName-len(suffix)]
+		}
+		if candidate := base + suffix; !taken[strings.ToLower(candidate)] {
+			return candidate
+		}
+	}
+}
+
+// screenEmoji runs the guild's emoji_hash rules over an upload. It returns
+// the rule that matched, if any, and why.
+func (h *Handler) screenEmoji(ctx context.Context, guildID string, imageHash *string) (*automod.Rule, string, error) {
+	if h.AutoMod == nil || imageHash == nil {
+		return nil, "", nil
+	}
+	return h.AutoMod.EvaluateEmoji(ctx, guildID, *imageHash)
+}
+
+// HandleGetEmojiQuota returns the guild's emoji limits and usage.
+// GET /api/v1/guilds/{guildID}/emoji/quota
+func (h *Handler) HandleGetEmojiQuota(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	q, err := scanEmojiQuota(h.Pool.QueryRow(r.Context(), emojiQuotaSQL, guildID), guildID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get emoji quota", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, q)
+}
+
+// HandleApproveGuildEmoji clears an emoji flagged by AutoMod, making it
+// usable.
+// POST /api/v1/guilds/{guildID}/emoji/{emojiID}/approve
+func (h *Handler) HandleApproveGuildEmoji(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	emojiID := chi.URLParam(r, "emojiID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageEmoji) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_EMOJI permission")
+		return
+	}
+
+	emoji, err := scanEmoji(h.Pool.QueryRow(r.Context(),
+		`UPDATE custom_emoji SET flagged = false, flag_reason = NULL
+		 WHERE id = $1 AND guild_id = $2 AND flagged
+		 RETURNING `+emojiColumns, emojiID, guildID))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "emoji_not_found", "No flagged emoji with that ID")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to approve emoji", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "emoji_approve", "emoji", emojiID, nil)
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildEmojiUpdate, "GUILD_EMOJI_UPDATE", guildID, emoji)
+
+	apiutil.WriteJSON(w, http.StatusOK, emoji)
+}
@@ -0,0 +1,173 @@
+// Guild emoji alias handlers.
+// A guild adds its own :shortcode: names for unicode emoji, on top of the
+// standard set. When the instance normalizes shortcodes, messages sent in
+// the guild's channels use them. Mounted under
+// /api/v1/guilds/{guildID}/emoji-aliases.
+package guilds
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/emojicodes"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxEmojiAliases bounds the aliases a guild may define.
+const maxEmojiAliases = 200
+
+// resolveAliasEmoji returns the unicode emoji an alias should map to, given
+// either the emoji itself or a standard shortcode such as ":thumbsup:".
+func resolveAliasEmoji(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if name, ok := strings.CutPrefix(value, ":"); ok {
+		return emojicodes.Lookup(strings.TrimSuffix(name, ":"))
+	}
+	if value == "" || len(value) > 64 || !utf8.ValidString(value) {
+		return "", false
+	}
+	// Emoji are never plain ASCII; this keeps aliases from inserting text.
+	for _, r := range value {
+		if r < utf8.RuneSelf {
+			return "", false
+		}
+	}
+	return value, true
+}
+
+// HandleGetEmojiAliases lists the guild's emoji aliases. Any member can see
+// them, so clients can offer them in autocomplete.
+// GET /api/v1/guilds/{guildID}/emoji-aliases
+func (h *Handler) HandleGetEmojiAliases(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT guild_id, alias, emoji, created_by, created_at
+		 FROM guild_emoji_aliases WHERE guild_id = $1 ORDER BY alias`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get emoji aliases", err)
+		return
+	}
+	aliases, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.GuildEmojiAlias, error) {
+		var a models.GuildEmojiAlias
+		err := row.Scan(&a.GuildID, &a.Alias, &a.Emoji, &a.CreatedBy, &a.CreatedAt)
+		return a, err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read emoji aliases", err)
+		return
+	}
+	if aliases == nil {
+		aliases = []models.GuildEmojiAlias{}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, aliases)
+}
+
+// HandleSetEmojiAlias creates or replaces an emoji alias. The emoji may be
+// given as the character itself or as a standard shortcode. An alias cannot
+// share a name with one of the guild's custom emoji, which keep their name.
+// PUT /api/v1/guilds/{guildID}/emoji-aliases/{alias}
+func (h *Handler) HandleSetEmojiAlias(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	alias := strings.ToLower(chi.URLParam(r, "alias"))
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageEmoji) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_EMOJI permission")
+		return
+	}
+
+	var req struct {
+		Emoji string `json:"emoji"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if !emojicodes.ValidName(alias) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_alias",
+			"Alias must be 1-32 lowercase letters, digits, '_', '+' or '-'")
+		return
+	}
+	emoji, ok := resolveAliasEmoji(req.Emoji)
+	if !ok {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_emoji", "emoji must be a unicode emoji or a standard shortcode")
+		return
+	}
+
+	var taken bool
+	h.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM custom_emoji WHERE guild_id = $1 AND name = $2)`,
+		guildID, alias).Scan(&taken)
+	if taken {
+		apiutil.WriteError(w, http.StatusConflict, "alias_taken", "A custom emoji in this guild already uses that name")
+		return
+	}
+
+	var count int
+	h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM guild_emoji_aliases WHERE guild_id = $1 AND alias != $2`,
+		guildID, alias).Scan(&count)
+	if count >= maxEmojiAliases {
+		apiutil.WriteError(w, http.StatusBadRequest, "limit_reached", "A guild can have at most 200 emoji aliases")
+		return
+	}
+
+	var a models.GuildEmojiAlias
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO guild_emoji_aliases (guild_id, alias, emoji, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, now())
+		 ON CONFLICT (guild_id, alias) DO UPDATE SET emoji = EXCLUDED.emoji
+		 RETURNING guild_id, alias, emoji, created_by, created_at`,
+		guildID, alias, emoji, userID,
+	).Scan(&a.GuildID, &a.Alias, &a.Emoji, &a.CreatedBy, &a.CreatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to set emoji alias", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "emoji_alias_set", "emoji_alias", alias, nil)
+	apiutil.WriteJSON(w, http.StatusOK, a)
+}
+
+// HandleDeleteEmojiAlias removes an emoji alias. Messages already stored
+// with it keep their emoji.
+// DELETE /api/v1/guilds/{guildID}/emoji-aliases/{alias}
+func (h *Handler) HandleDeleteEmojiAlias(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	alias := strings.ToLower(chi.URLParam(r, "alias"))
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageEmoji) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_EMOJI permission")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM guild_emoji_aliases WHERE guild_id = $1 AND alias = $2`, guildID, alias)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete emoji alias", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "alias_not_found", "Emoji alias not found")
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "emoji_alias_delete", "emoji_alias", alias, nil)
+	apiutil.WriteNoContent(w)
+}
@@ -0,0 +1,186 @@
+// Guild emoji reaction statistics.
+// Reactions are rolled up weekly per emoji by the emoji-reaction-rollup job.
+// The statistics feed the insights dashboard and the emoji picker's
+// "frequently used in this server" section, so every member can read them;
+// the most-reacted messages only come from channels the member can read.
+// Mounted under /api/v1/guilds/{guildID}/emoji-stats.
+package guilds
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+)
+
+const (
+	defaultEmojiStatsWeeks = 4
+	maxEmojiStatsWeeks     = 52
+
+	// How many emoji and messages each list of the statistics holds.
+	emojiStatsTopEmoji    = 30
+	emojiStatsTrending    = 10
+	emojiStatsTopMessages = 10
+)
+
+// emojiUsage is one emoji and how often it was used. Name and Animated are
+// set for the guild's custom emoji.
+type emojiUsage struct {
+	Emoji     string  `json:"emoji"`
+	Name      *string `json:"name,omitempty"`
+	Animated  bool    `json:"animated,omitempty"`
+	Reactions int64   `json:"reactions"`
+}
+
+// trendingEmoji is an emoji used more this week than last.
+type trendingEmoji struct {
+	Emoji    string  `json:"emoji"`
+	Name     *string `json:"name,omitempty"`
+	Animated bool    `json:"animated,omitempty"`
+	ThisWeek int64   `json:"this_week"`
+	LastWeek int64   `json:"last_week"`
+}
+
+// reactedMessage is a message sent in the period and how many reactions it
+// drew.
+type reactedMessage struct {
+	MessageID string    `json:"message_id"`
+	ChannelID string    `json:"channel_id"`
+	AuthorID  *string   `json:"author_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Reactions int64     `json:"reactions"`
+}
+
+type emojiStats struct {
+	Weeks       int              `json:"weeks"`
+	TopEmoji    []emojiUsage     `json:"top_emoji"`
+	Trending    []trendingEmoji  `json:"trending"`
+	TopMessages []reactedMessage `json:"top_messages"`
+}
+
+// HandleGetEmojiStats returns the guild's most used reaction emoji over the
+// last ?weeks= weeks (default 4, at most 52) including this one, the emoji
+// used more this week than last, and the most-reacted messages sent in the
+// period.
+// GET /api/v1/guilds/{guildID}/emoji-stats
+func (h *Handler) HandleGetEmojiStats(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	weeks := defaultEmojiStatsWeeks
+	if v := r.URL.Query().Get("weeks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxEmojiStatsWeeks {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_weeks", "weeks must be between 1 and 52")
+			return
+		}
+		weeks = n
+	}
+
+	stats := emojiStats{
+		Weeks:       weeks,
+		TopEmoji:    []emojiUsage{},
+		Trending:    []trendingEmoji{},
+		TopMessages: []reactedMessage{},
+	}
+
+	// Weeks start on Monday, as date_trunc('week') has it.
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT s.emoji, ce.name, COALESCE(ce.animated, false), sum(s.reactions)::bigint AS n
+		 FROM emoji_reaction_weekly s
+		 LEFT JOIN custom_emoji ce ON ce.id = s.emoji AND ce.guild_id = s.guild_id
+		 WHERE s.guild_id = $1
+		   AND s.week > date_trunc('week', now() AT TIME ZONE 'UTC')::date - 7 * $2::int
+		 GROUP BY s.emoji, ce.name, ce.animated
+		 ORDER BY n DESC, s.emoji
+		 LIMIT $3`, guildID, weeks, emojiStatsTopEmoji)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get emoji stats", err)
+		return
+	}
+	for rows.Next() {
+		var e emojiUsage
+		if err := rows.Scan(&e.Emoji, &e.Name, &e.Animated, &e.Reactions); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read emoji stats", err)
+			return
+		}
+		stats.TopEmoji = append(stats.TopEmoji, e)
+	}
+	rows.Close()
+
+	rows, err = h.Pool.Query(r.Context(),
+		`WITH weeks AS (
+		     SELECT s.emoji,
+		            sum(s.reactions) FILTER (WHERE s.week = date_trunc('week', now() AT TIME ZONE 'UTC')::date) AS this_week,
+		            sum(s.reactions) FILTER (WHERE s.week = date_trunc('week', now() AT TIME ZONE 'UTC')::date - 7) AS last_week
+		     FROM emoji_reaction_weekly s
+		     WHERE s.guild_id = $1
+		       AND s.week >= date_trunc('week', now() AT TIME ZONE 'UTC')::date - 7
+		     GROUP BY s.emoji)
+		 SELECT w.emoji, ce.name, COALESCE(ce.animated, false),
+		        COALESCE(w.this_week, 0)::bigint, COALESCE(w.last_week, 0)::bigint
+		 FROM weeks w
+		 LEFT JOIN custom_emoji ce ON ce.id = w.emoji AND ce.guild_id = $1
+		 WHERE COALESCE(w.this_week, 0) > COALESCE(w.last_week, 0)
+		 ORDER BY COALESCE(w.this_week, 0) - COALESCE(w.last_week, 0) DESC, w.emoji
+		 LIMIT $2`, guildID, emojiStatsTrending)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get trending emoji", err)
+		return
+	}
+	for rows.Next() {
+		var e trendingEmoji
+		if err := rows.Scan(&e.Emoji, &e.Name, &e.Animated, &e.ThisWeek, &e.LastWeek); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read trending emoji", err)
+			return
+		}
+		stats.Trending = append(stats.Trending, e)
+	}
+	rows.Close()
+
+	channelIDs, err := apiutil.ReadableGuildChannels(r.Context(), h.Pool, guildID, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to check channel access", err)
+		return
+	}
+	rows, err = h.Pool.Query(r.Context(),
+		`SELECT m.id, m.channel_id, m.author_id, m.created_at, count(*) AS n
+		 FROM messages m
+		 JOIN reactions r ON r.message_id = m.id
+		 WHERE m.channel_id = ANY($1)
+		   AND m.created_at >= date_trunc('week', now() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' - make_interval(weeks => $2 - 1)
+		 GROUP BY m.id, m.channel_id, m.author_id, m.created_at
+		 ORDER BY n DESC, m.id DESC
+		 LIMIT $3`, channelIDs, weeks, emojiStatsTopMessages)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get most-reacted messages", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m reactedMessage
+		if err := rows.Scan(&m.MessageID, &m.ChannelID, &m.AuthorID, &m.CreatedAt, &m.Reactions); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read most-reacted messages", err)
+			return
+		}
+		stats.TopMessages = append(stats.TopMessages, m)
+	}
+	if err := rows.Err(); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read most-reacted messages", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, stats)
+}
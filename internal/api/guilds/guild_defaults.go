@@ -0,0 +1,95 @@
+package guilds
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// newGuildDefaults returns the instance's template for new guilds, with the
+// built-in @everyone permissions and #general channel standing in for
+// anything an admin left unset. An unreadable setting is logged and ignored.
+func (h *Handler) newGuildDefaults(ctx context.Context) models.GuildDefaults {
+	var d models.GuildDefaults
+	var raw string
+	if err := h.Pool.QueryRow(ctx,
+		`SELECT value FROM instance_settings WHERE key = 'guild_defaults'`).Scan(&raw); err == nil {
+		if err := json.Unmarshal([]byte(raw), &d); err != nil {
+			h.Logger.Warn("ignoring unreadable guild defaults", slog.String("error", err.Error()))
+			d = models.GuildDefaults{}
+		}
+	}
+
+	if d.DefaultPermissions == nil {
+		perms := int64(permissions.DefaultGuildPermissions)
+		d.DefaultPermissions = &perms
+	}
+	if len(d.Channels) == 0 {
+		d.Channels = []models.GuildDefaultChannel{{Name: "general", ChannelType: "text"}}
+	}
+	return d
+}
+
+// followInstanceAnnouncements makes a new guild follow the announcement
+// channels the instance admins chose. Each follow posts into the guild's
+// first text channel through a webhook, exactly as if the owner had followed
+// the channel by hand, so the owner can later move or remove it. Channels
+// that were deleted or are no longer announcement channels are skipped.
+func (h *Handler) followInstanceAnnouncements(ctx context.Context, tx pgx.Tx, guildID string, channelIDs []string) error {
+	if len(channelIDs) == 0 {
+		return nil
+	}
+
+	var targetID string
+	err := tx.QueryRow(ctx,
+		`SELECT id FROM channels
+		 WHERE guild_id = $1 AND channel_type IN ('text', 'announcement')
+		 ORDER BY position, id LIMIT 1`, guildID).Scan(&targetID)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, sourceID := range channelIDs {
+		var sourceGuild string
+		err := tx.QueryRow(ctx,
+			`SELECT g.name FROM channels c JOIN guilds g ON g.id = c.guild_id
+			 WHERE c.id = $1 AND c.channel_type = $2`,
+			sourceID, models.ChannelTypeAnnouncement).Scan(&sourceGuild)
+		if err == pgx.ErrNoRows {
+			h.Logger.Warn("skipping missing instance announcement channel", slog.String("channel_id", sourceID))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if name := []rune(sourceGuild); len(name) > 80 {
+			sourceGuild = string(name[:80])
+		}
+
+		webhookID := models.NewULID().String()
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO webhooks (id, guild_id, channel_id, name, token, webhook_type, created_at)
+			 VALUES ($1, $2, $3, $4, $5, 'incoming', now())`,
+			webhookID, guildID, targetID, sourceGuild,
+			generateInviteCode()+generateInviteCode()+generateInviteCode(),
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO channel_followers (id, channel_id, webhook_id, guild_id, created_at)
+			 VALUES ($1, $2, $3, $4, now())`,
+			models.NewULID().String(), sourceID, webhookID, guildID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -20,11 +21,15 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/automod"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/locale"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/permissions"
+	"github.com/amityvox/amityvox/internal/presence"
 )
 
 // Handler implements guild-related REST API endpoints.
@@ -34,11 +39,18 @@ type Handler struct {
 	InstanceID string
 	Logger     *slog.Logger
 	FedProxy   apiutil.FederationProxy // optional, nil if federation disabled
+	AutoMod    *automod.Service        // optional, screens new emoji when set
+	Cache      *presence.Cache         // optional, caches the first page of member lists
 }
 
 type createGuildRequest struct {
 	Name        string  `json:"name"`
 	Description *string `json:"description"`
+	// At most one of these replaces the instance's default channels and
+	// roles: a saved template of a guild the caller belongs to, or a
+	// template given inline.
+	TemplateID *string       `json:"template_id"`
+	Template   *templateData `json:"template"`
 }
 
 type updateGuildRequest struct {
@@ -52,6 +64,17 @@ type updateGuildRequest struct {
 	AFKChannelID      *string  `json:"afk_channel_id"`
 	AFKTimeout        *int     `json:"afk_timeout"`
 	Tags              []string `json:"tags"`
+	PreferredLocale   *string  `json:"preferred_locale"`
+	Timezone          *string  `json:"timezone"`
+	// SystemChannelID is a text or announcement channel of the guild; ""
+	// clears it.
+	SystemChannelID      *string `json:"system_channel_id"`
+	SystemJoinMessages   *bool   `json:"system_join_messages"`
+	SystemBoostMessages  *bool   `json:"system_boost_messages"`
+	SystemEventReminders *bool   `json:"system_event_reminders"`
+	InviteLinkPolicy     *string `json:"invite_link_policy"`
+	// Version, if set, must be the guild's current version.
+	Version *int64 `json:"version"`
 }
 
 type createChannelRequest struct {
@@ -125,6 +148,9 @@ type createRoleRequest struct {
 	Position         *int      `json:"position"`
 	PermissionsAllow flexInt64 `json:"permissions_allow"`
 	PermissionsDeny  flexInt64 `json:"permissions_deny"`
+	// PresetID names a permission preset supplying whichever of
+	// PermissionsAllow and PermissionsDeny are not given.
+	PresetID *string `json:"preset_id"`
 }
 
 type updateRoleRequest struct {
@@ -135,6 +161,8 @@ type updateRoleRequest struct {
 	Position         *int      `json:"position"`
 	PermissionsAllow flexInt64 `json:"permissions_allow"`
 	PermissionsDeny  flexInt64 `json:"permissions_deny"`
+	// Version, if set, must be the role's current version.
+	Version *int64 `json:"version"`
 }
 
 type banRequest struct {
@@ -147,7 +175,11 @@ type kickRequest struct {
 	Reason *string `json:"reason"`
 }
 
-// HandleCreateGuild creates a new guild owned by the authenticated user.
+// HandleCreateGuild creates a new guild owned by the authenticated user. The
+// guild starts from the instance's guild defaults: @everyone permissions,
+// channels and extra roles. A template_id or inline template sets up the
+// channels, categories and roles instead. Either way the guild follows the
+// instance's designated announcement channels.
 // POST /api/v1/guilds
 func (h *Handler) HandleCreateGuild(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
@@ -162,11 +194,14 @@ func (h *Handler) HandleCreateGuild(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.TemplateID != nil || req.Template != nil {
+		h.createGuildWithTemplate(w, r, userID, req)
+		return
+	}
+
 	guildID := models.NewULID().String()
-	defaultPerms := int64(permissions.ViewChannel | permissions.ReadHistory |
-		permissions.SendMessages | permissions.AddReactions |
-		permissions.Connect | permissions.Speak |
-		permissions.ChangeNickname | permissions.CreateInvites)
+	defaults := h.newGuildDefaults(r.Context())
+	defaultPerms := *defaults.DefaultPermissions
 
 	var guild models.Guild
 	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
@@ -175,13 +210,13 @@ func (h *Handler) HandleCreateGuild(w http.ResponseWriter, r *http.Request) {
 			`INSERT INTO guilds (id, instance_id, owner_id, name, description, default_permissions, created_at)
 			 VALUES ($1, $2, $3, $4, $5, $6, now())
 			 RETURNING id, instance_id, owner_id, name, description, icon_id, banner_id,
-			           default_permissions, flags, nsfw, discoverable, preferred_locale, max_members,
+			           default_permissions, flags, nsfw, discoverable, preferred_locale, timezone, max_members,
 			           verification_level, afk_channel_id, afk_timeout, created_at`,
 			guildID, h.InstanceID, userID, req.Name, req.Description, defaultPerms,
 		).Scan(
 			&guild.ID, &guild.InstanceID, &guild.OwnerID, &guild.Name, &guild.Description,
 			&guild.IconID, &guild.BannerID, &guild.DefaultPermissions, &guild.Flags,
-			&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.MaxMembers,
+			&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.Timezone, &guild.MaxMembers,
 			&guild.VerificationLevel, &guild.AFKChannelID, &guild.AFKTimeout, &guild.CreatedAt,
 		); err != nil {
 			return err
@@ -195,14 +230,15 @@ func (h *Handler) HandleCreateGuild(w http.ResponseWriter, r *http.Request) {
 			return err
 		}
 
-		// Create default "general" text channel.
-		channelID := models.NewULID().String()
-		if _, err := tx.Exec(r.Context(),
-			`INSERT INTO channels (id, guild_id, channel_type, name, position, created_at)
-			 VALUES ($1, $2, 'text', 'general', 0, now())`,
-			channelID, guildID,
-		); err != nil {
-			return err
+		// Create the instance's default channels.
+		for i, ch := range defaults.Channels {
+			if _, err := tx.Exec(r.Context(),
+				`INSERT INTO channels (id, guild_id, channel_type, name, topic, position, created_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, now())`,
+				models.NewULID().String(), guildID, ch.ChannelType, ch.Name, ch.Topic, i,
+			); err != nil {
+				return err
+			}
 		}
 
 		// Create @everyone role at position 0 with default permissions.
@@ -215,7 +251,19 @@ func (h *Handler) HandleCreateGuild(w http.ResponseWriter, r *http.Request) {
 			return err
 		}
 
-		return nil
+		// Stack the instance's default roles above @everyone.
+		for i, role := range defaults.Roles {
+			if _, err := tx.Exec(r.Context(),
+				`INSERT INTO roles (id, guild_id, name, color, hoist, mentionable, position, permissions_allow, permissions_deny, created_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())`,
+				models.NewULID().String(), guildID, role.Name, role.Color, role.Hoist, role.Mentionable,
+				i+1, role.PermissionsAllow, role.PermissionsDeny,
+			); err != nil {
+				return err
+			}
+		}
+
+		return h.followInstanceAnnouncements(r.Context(), tx, guildID, defaults.FollowChannelIDs)
 	})
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to create guild", err)
@@ -234,17 +282,17 @@ func (h *Handler) HandleGetGuild(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
 	guild, err := h.getGuild(r.Context(), guildID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+			apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get guild")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get guild")
 		return
 	}
 
@@ -258,7 +306,7 @@ func (h *Handler) HandleUpdateGuild(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
 		return
 	}
 
@@ -271,7 +319,7 @@ func (h *Handler) HandleUpdateGuild(w http.ResponseWriter, r *http.Request) {
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "guild_update", userID, req) {
@@ -279,6 +327,42 @@ func (h *Handler) HandleUpdateGuild(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// The locale and timezone decide how server-written text for the guild
+	// shows times, so only values that will format are accepted.
+	if req.PreferredLocale != nil {
+		loc, err := locale.ParseLocale(*req.PreferredLocale)
+		if err != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_locale", "preferred_locale must be a language tag such as en-US")
+			return
+		}
+		req.PreferredLocale = &loc
+	}
+	if req.Timezone != nil {
+		tz, err := locale.ParseTimezone(*req.Timezone)
+		if err != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_timezone", "timezone must be an IANA timezone such as Europe/Berlin")
+			return
+		}
+		req.Timezone = &tz
+	}
+	if req.InviteLinkPolicy != nil && !apiutil.ValidInviteLinkPolicy(*req.InviteLinkPolicy) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_invite_link_policy",
+			"invite_link_policy must be allow, strip or require_manage_guild")
+		return
+	}
+	if req.SystemChannelID != nil && *req.SystemChannelID != "" {
+		var ok bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM channels WHERE id = $1 AND guild_id = $2
+			    AND channel_type IN ('text', 'announcement') AND parent_channel_id IS NULL)`,
+			*req.SystemChannelID, guildID).Scan(&ok)
+		if !ok {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_system_channel",
+				"system_channel_id must be a text or announcement channel in this guild")
+			return
+		}
+	}
+
 	// If tags were provided, update them; otherwise keep existing.
 	var tagsArg interface{} = nil
 	if req.Tags != nil {
@@ -297,22 +381,44 @@ func (h *Handler) HandleUpdateGuild(w http.ResponseWriter, r *http.Request) {
 			verification_level = COALESCE($8, verification_level),
 			afk_channel_id = COALESCE($9, afk_channel_id),
 			afk_timeout = COALESCE($10, afk_timeout),
-			tags = COALESCE($11, tags)
-		 WHERE id = $1
+			tags = COALESCE($11, tags),
+			preferred_locale = COALESCE($12, preferred_locale),
+			timezone = COALESCE($13, timezone),
+			system_channel_id = CASE WHEN $15::text IS NULL THEN system_channel_id ELSE NULLIF($15, '') END,
+			system_join_messages = COALESCE($16, system_join_messages),
+			system_boost_messages = COALESCE($17, system_boost_messages),
+			system_event_reminders = COALESCE($18, system_event_reminders),
+			invite_link_policy = COALESCE($19, invite_link_policy),
+			version = version + 1
+		 WHERE id = $1 AND ($14::bigint IS NULL OR version = $14)
 		 RETURNING id, instance_id, owner_id, name, description, icon_id, banner_id,
-		           default_permissions, flags, nsfw, discoverable, preferred_locale, max_members,
+		           default_permissions, flags, nsfw, discoverable, preferred_locale, timezone, max_members,
 		           vanity_url, verification_level, afk_channel_id, afk_timeout,
-		           tags, member_count, created_at`,
+		           system_channel_id, system_join_messages, system_boost_messages, system_event_reminders,
+		           invite_link_policy, tags, member_count, version, created_at`,
 		guildID, req.Name, req.Description, req.IconID, req.BannerID, req.NSFW, req.Discoverable, req.VerificationLevel, req.AFKChannelID, req.AFKTimeout, tagsArg,
+		req.PreferredLocale, req.Timezone, req.Version,
+		req.SystemChannelID, req.SystemJoinMessages, req.SystemBoostMessages, req.SystemEventReminders,
+		req.InviteLinkPolicy,
 	).Scan(
 		&guild.ID, &guild.InstanceID, &guild.OwnerID, &guild.Name, &guild.Description,
 		&guild.IconID, &guild.BannerID, &guild.DefaultPermissions, &guild.Flags,
-		&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.MaxMembers,
+		&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.Timezone, &guild.MaxMembers,
 		&guild.VanityURL, &guild.VerificationLevel, &guild.AFKChannelID, &guild.AFKTimeout,
-		&guild.Tags, &guild.MemberCount, &guild.CreatedAt,
+		&guild.SystemChannelID, &guild.SystemJoinMessages, &guild.SystemBoostMessages, &guild.SystemEventReminders,
+		&guild.InviteLinkPolicy, &guild.Tags, &guild.MemberCount, &guild.Version, &guild.CreatedAt,
 	)
+	if err == pgx.ErrNoRows {
+		current, err := h.getGuild(r.Context(), guildID)
+		if err != nil {
+			apiutil.WriteCode(w, apierrors.GuildNotFound, "")
+			return
+		}
+		apiutil.WriteVersionConflict(w, current)
+		return
+	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update guild")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update guild")
 		return
 	}
 
@@ -332,7 +438,7 @@ func (h *Handler) HandleDeleteGuild(w http.ResponseWriter, r *http.Request) {
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "guild_delete", userID, nil) {
@@ -343,7 +449,7 @@ func (h *Handler) HandleDeleteGuild(w http.ResponseWriter, r *http.Request) {
 	var ownerID string
 	err := h.Pool.QueryRow(r.Context(), `SELECT owner_id FROM guilds WHERE id = $1`, guildID).Scan(&ownerID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 	if ownerID != userID {
@@ -353,7 +459,7 @@ func (h *Handler) HandleDeleteGuild(w http.ResponseWriter, r *http.Request) {
 
 	_, err = h.Pool.Exec(r.Context(), `DELETE FROM guilds WHERE id = $1`, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete guild")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete guild")
 		return
 	}
 
@@ -371,7 +477,7 @@ func (h *Handler) HandleLeaveGuild(w http.ResponseWriter, r *http.Request) {
 	// Check if user is the owner — owners cannot leave (must transfer or delete).
 	var ownerID string
 	if err := h.Pool.QueryRow(r.Context(), `SELECT owner_id FROM guilds WHERE id = $1`, guildID).Scan(&ownerID); err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 	if ownerID == userID {
@@ -382,7 +488,7 @@ func (h *Handler) HandleLeaveGuild(w http.ResponseWriter, r *http.Request) {
 	result, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM guild_members WHERE guild_id = $1 AND user_id = $2`, guildID, userID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to leave guild")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to leave guild")
 		return
 	}
 	if result.RowsAffected() == 0 {
@@ -409,7 +515,7 @@ func (h *Handler) HandleTransferGuildOwnership(w http.ResponseWriter, r *http.Re
 	// Only the owner can transfer ownership.
 	var ownerID string
 	if err := h.Pool.QueryRow(r.Context(), `SELECT owner_id FROM guilds WHERE id = $1`, guildID).Scan(&ownerID); err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 	if ownerID != userID {
@@ -442,17 +548,17 @@ func (h *Handler) HandleTransferGuildOwnership(w http.ResponseWriter, r *http.Re
 		`UPDATE guilds SET owner_id = $2
 		 WHERE id = $1
 		 RETURNING id, instance_id, owner_id, name, description, icon_id, banner_id,
-		           default_permissions, flags, nsfw, discoverable, preferred_locale, max_members,
+		           default_permissions, flags, nsfw, discoverable, preferred_locale, timezone, max_members,
 		           verification_level, created_at`,
 		guildID, req.NewOwnerID,
 	).Scan(
 		&guild.ID, &guild.InstanceID, &guild.OwnerID, &guild.Name, &guild.Description,
 		&guild.IconID, &guild.BannerID, &guild.DefaultPermissions, &guild.Flags,
-		&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.MaxMembers,
+		&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.Timezone, &guild.MaxMembers,
 		&guild.VerificationLevel, &guild.CreatedAt,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to transfer ownership")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to transfer ownership")
 		return
 	}
 
@@ -462,14 +568,16 @@ func (h *Handler) HandleTransferGuildOwnership(w http.ResponseWriter, r *http.Re
 	apiutil.WriteJSON(w, http.StatusOK, guild)
 }
 
-// HandleGetGuildChannels lists all channels in a guild.
+// HandleGetGuildChannels lists all channels in a guild. NSFW channels are
+// left out unless the caller opted in to NSFW content, and archived threads
+// are left to the archive browser.
 // GET /api/v1/guilds/{guildID}/channels
 func (h *Handler) HandleGetGuildChannels(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -477,13 +585,16 @@ func (h *Handler) HandleGetGuildChannels(w http.ResponseWriter, r *http.Request)
 		`SELECT id, guild_id, category_id, channel_type, name, topic, position,
 		        slowmode_seconds, nsfw, encrypted, last_message_id, owner_id,
 		        default_permissions, user_limit, bitrate, locked, locked_by, locked_at,
-		        archived, parent_channel_id, last_activity_at, created_at
-		 FROM channels WHERE guild_id = $1
+		        archived, parent_channel_id, last_activity_at,
+		        nsfw_inherited, slowmode_inherited, notification_level, notification_level_inherited,
+		        preview_enabled, preview_role_id, version, created_at
+		 FROM channels WHERE guild_id = $1 AND (NOT nsfw OR $2)
+		   AND NOT (archived AND parent_channel_id IS NOT NULL)
 		 ORDER BY position, created_at`,
-		guildID,
+		guildID, apiutil.ContentSettings(r.Context(), h.Pool, userID).ShowNSFW,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get channels")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get channels")
 		return
 	}
 	defer rows.Close()
@@ -496,15 +607,39 @@ func (h *Handler) HandleGetGuildChannels(w http.ResponseWriter, r *http.Request)
 			&c.Position, &c.SlowmodeSeconds, &c.NSFW, &c.Encrypted, &c.LastMessageID,
 			&c.OwnerID, &c.DefaultPermissions, &c.UserLimit, &c.Bitrate,
 			&c.Locked, &c.LockedBy, &c.LockedAt, &c.Archived,
-			&c.ParentChannelID, &c.LastActivityAt, &c.CreatedAt,
+			&c.ParentChannelID, &c.LastActivityAt,
+			&c.NSFWInherited, &c.SlowmodeInherited, &c.NotificationLevel, &c.NotificationLevelInherited,
+			&c.PreviewEnabled, &c.PreviewRoleID, &c.Version, &c.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read channels")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read channels")
 			return
 		}
 		channels = append(channels, c)
 	}
+	rows.Close()
+
+	// Leave out channels the caller cannot view, except preview channels,
+	// which are listed locked without their activity.
+	access, err := apiutil.MemberGuildChannelAccess(r.Context(), h.Pool, guildID, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to compute channel access", err)
+		return
+	}
+	visible := channels[:0]
+	for _, c := range channels {
+		a := access[c.ID]
+		if a.Permissions&permissions.ViewChannel == 0 {
+			if !a.Preview {
+				continue
+			}
+			c.PreviewLocked = true
+			c.LastMessageID = nil
+			c.LastActivityAt = nil
+		}
+		visible = append(visible, c)
+	}
 
-	apiutil.WriteJSON(w, http.StatusOK, channels)
+	apiutil.WriteJSON(w, http.StatusOK, visible)
 }
 
 // HandleCreateGuildChannel creates a new channel in a guild.
@@ -514,7 +649,7 @@ func (h *Handler) HandleCreateGuildChannel(w http.ResponseWriter, r *http.Reques
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -527,7 +662,7 @@ func (h *Handler) HandleCreateGuildChannel(w http.ResponseWriter, r *http.Reques
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "channel_create", userID, req) {
@@ -549,6 +684,7 @@ func (h *Handler) HandleCreateGuildChannel(w http.ResponseWriter, r *http.Reques
 	}
 
 	channelID := models.NewULID().String()
+	// Settings not given explicitly follow the category, if there is one.
 	nsfw := false
 	if req.NSFW != nil {
 		nsfw = *req.NSFW
@@ -560,26 +696,37 @@ func (h *Handler) HandleCreateGuildChannel(w http.ResponseWriter, r *http.Reques
 
 	var channel models.Channel
 	err := h.Pool.QueryRow(r.Context(),
-		`INSERT INTO channels (id, guild_id, category_id, channel_type, name, topic, position, nsfw, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+		`INSERT INTO channels (id, guild_id, category_id, channel_type, name, topic, position, nsfw,
+		                       nsfw_inherited, slowmode_inherited, notification_level_inherited, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, true, true, now())
 		 RETURNING id, guild_id, category_id, channel_type, name, topic, position,
 		           slowmode_seconds, nsfw, encrypted, last_message_id, owner_id,
 		           default_permissions, user_limit, bitrate, locked, locked_by, locked_at,
-		           archived, parent_channel_id, last_activity_at, created_at`,
-		channelID, guildID, req.CategoryID, req.ChannelType, req.Name, req.Topic, position, nsfw,
+		           archived, parent_channel_id, last_activity_at,
+		           nsfw_inherited, slowmode_inherited, notification_level, notification_level_inherited, created_at`,
+		channelID, guildID, req.CategoryID, req.ChannelType, req.Name, req.Topic, position, nsfw, req.NSFW == nil,
 	).Scan(
 		&channel.ID, &channel.GuildID, &channel.CategoryID, &channel.ChannelType, &channel.Name,
 		&channel.Topic, &channel.Position, &channel.SlowmodeSeconds, &channel.NSFW, &channel.Encrypted,
 		&channel.LastMessageID, &channel.OwnerID, &channel.DefaultPermissions,
 		&channel.UserLimit, &channel.Bitrate,
 		&channel.Locked, &channel.LockedBy, &channel.LockedAt, &channel.Archived,
-		&channel.ParentChannelID, &channel.LastActivityAt, &channel.CreatedAt,
+		&channel.ParentChannelID, &channel.LastActivityAt,
+		&channel.NSFWInherited, &channel.SlowmodeInherited, &channel.NotificationLevel,
+		&channel.NotificationLevelInherited, &channel.CreatedAt,
 	)
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to create channel", err)
 		return
 	}
 
+	if roleID := apiutil.TimeoutRoleID(r.Context(), h.Pool, guildID); roleID != "" {
+		if _, err := apiutil.ApplyTimeoutOverrides(r.Context(), h.Pool, guildID, roleID); err != nil {
+			h.Logger.Warn("failed to apply timeout role overrides",
+				slog.String("channel_id", channelID), slog.String("error", err.Error()))
+		}
+	}
+
 	h.logAudit(r.Context(), guildID, userID, "channel_create", "channel", channelID, nil)
 	channelData, err := json.Marshal(channel)
 	if err != nil {
@@ -595,8 +742,15 @@ func (h *Handler) HandleCreateGuildChannel(w http.ResponseWriter, r *http.Reques
 	apiutil.WriteJSON(w, http.StatusCreated, channel)
 }
 
-// HandleGetGuildMembers lists members of a guild.
-// GET /api/v1/guilds/{guildID}/members
+// memberKeyset orders guild members by when they joined.
+var memberKeyset = apiutil.Keyset{Columns: []apiutil.KeyColumn{
+	{Expr: "gm.joined_at", Type: "timestamptz"},
+	{Expr: "gm.user_id", Type: "text"},
+}}
+
+// HandleGetGuildMembers lists members of a guild in the order they joined,
+// a page at a time.
+// GET /api/v1/guilds/{guildID}/members?before=&after=&limit= (default and max 1000)
 func (h *Handler) HandleGetGuildMembers(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
@@ -609,10 +763,27 @@ func (h *Handler) HandleGetGuildMembers(w http.ResponseWriter, r *http.Request)
 	}
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
+	page, ok := apiutil.ParsePage(w, r, memberKeyset, apiutil.MaxPageLimit)
+	if !ok {
+		return
+	}
+	cursorSQL, cursorArgs := page.Where(memberKeyset, 3)
+
+	// Clients load the first full page whenever they open a guild; serve it
+	// from the cache while nothing has changed.
+	var cacheKey string
+	if !page.HasCursor() && page.Limit == apiutil.MaxPageLimit {
+		cacheKey = h.memberListKey(r.Context(), guildID)
+		if cached, ok := h.cachedMemberList(r.Context(), cacheKey); ok {
+			apiutil.WritePage(w, cached.Members, cached.NextCursor)
+			return
+		}
+	}
+
 	rows, err := h.Pool.Query(r.Context(),
 		`SELECT gm.guild_id, gm.user_id, gm.nickname, gm.avatar_id, gm.joined_at,
 		        gm.timeout_until, gm.deaf, gm.mute,
@@ -623,13 +794,13 @@ func (h *Handler) HandleGetGuildMembers(w http.ResponseWriter, r *http.Request)
 		 FROM guild_members gm
 		 JOIN users u ON u.id = gm.user_id
 		 LEFT JOIN instances i ON i.id = u.instance_id
-		 WHERE gm.guild_id = $1
-		 ORDER BY gm.joined_at
-		 LIMIT 1000`,
-		guildID,
+		 WHERE gm.guild_id = $1`+cursorSQL+`
+		 ORDER BY `+page.OrderBy(memberKeyset)+`
+		 LIMIT $2`,
+		append([]interface{}{guildID, page.FetchLimit()}, cursorArgs...)...,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get members")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get members")
 		return
 	}
 	defer rows.Close()
@@ -647,7 +818,7 @@ func (h *Handler) HandleGetGuildMembers(w http.ResponseWriter, r *http.Request)
 			&u.Bio, &u.BannerID, &u.AccentColor, &u.Pronouns, &u.Flags, &u.CreatedAt,
 			&instanceDomain,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read members")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read members")
 			return
 		}
 		if instanceDomain != "" {
@@ -656,11 +827,18 @@ func (h *Handler) HandleGetGuildMembers(w http.ResponseWriter, r *http.Request)
 		m.User = &u
 		members = append(members, m)
 	}
+	members, next := apiutil.FinishPage(page, members, func(m models.GuildMember) []interface{} {
+		return []interface{}{m.JoinedAt, m.UserID}
+	})
 
-	// Batch-load role IDs for all members so the frontend can do hoist grouping.
+	// Batch-load role IDs for the page's members so the frontend can do hoist grouping.
 	if len(members) > 0 {
+		userIDs := make([]string, len(members))
+		for i := range members {
+			userIDs[i] = members[i].UserID
+		}
 		roleRows, roleErr := h.Pool.Query(r.Context(),
-			`SELECT user_id, role_id FROM member_roles WHERE guild_id = $1`, guildID)
+			`SELECT user_id, role_id FROM member_roles WHERE guild_id = $1 AND user_id = ANY($2)`, guildID, userIDs)
 		if roleErr == nil {
 			defer roleRows.Close()
 			memberRoleMap := make(map[string][]string)
@@ -677,7 +855,8 @@ func (h *Handler) HandleGetGuildMembers(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	apiutil.WriteJSON(w, http.StatusOK, members)
+	h.storeMemberList(r.Context(), cacheKey, cachedMemberPage{Members: members, NextCursor: next})
+	apiutil.WritePage(w, members, next)
 }
 
 // HandleGetGuildMember returns a single guild member.
@@ -688,7 +867,7 @@ func (h *Handler) HandleGetGuildMember(w http.ResponseWriter, r *http.Request) {
 	memberID := chi.URLParam(r, "memberID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -700,24 +879,25 @@ func (h *Handler) HandleGetGuildMember(w http.ResponseWriter, r *http.Request) {
 	).Scan(&m.GuildID, &m.UserID, &m.Nickname, &m.AvatarID, &m.JoinedAt, &m.TimeoutUntil, &m.Deaf, &m.Mute)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "member_not_found", "Member not found")
+			apiutil.WriteCode(w, apierrors.MemberNotFound, "")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get member")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get member")
 		return
 	}
 
 	apiutil.WriteJSON(w, http.StatusOK, m)
 }
 
-// HandleSearchGuildMembers searches for guild members by username or nickname.
+// HandleSearchGuildMembers searches for guild members whose username or
+// nickname starts with the query.
 // GET /api/v1/guilds/{guildID}/members/search?q=<query>
 func (h *Handler) HandleSearchGuildMembers(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -725,22 +905,19 @@ func (h *Handler) HandleSearchGuildMembers(w http.ResponseWriter, r *http.Reques
 	if !apiutil.RequireNonEmpty(w, "Query parameter 'q'", query) {
 		return
 	}
-	if len(query) > 100 {
-		query = query[:100]
-	}
 
 	rows, err := h.Pool.Query(r.Context(),
 		`SELECT gm.guild_id, gm.user_id, gm.nickname, gm.avatar_id, gm.joined_at, gm.timeout_until, gm.deaf, gm.mute
 		 FROM guild_members gm
 		 JOIN users u ON u.id = gm.user_id
 		 WHERE gm.guild_id = $1
-		   AND (u.username ILIKE '%' || $2 || '%' OR gm.nickname ILIKE '%' || $2 || '%')
+		   AND (lower(u.username) LIKE $2 OR lower(gm.nickname) LIKE $2)
 		 ORDER BY u.username
 		 LIMIT 25`,
-		guildID, query,
+		guildID, apiutil.PrefixPattern(query),
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to search members")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to search members")
 		return
 	}
 	defer rows.Close()
@@ -749,7 +926,7 @@ func (h *Handler) HandleSearchGuildMembers(w http.ResponseWriter, r *http.Reques
 	for rows.Next() {
 		var m models.GuildMember
 		if err := rows.Scan(&m.GuildID, &m.UserID, &m.Nickname, &m.AvatarID, &m.JoinedAt, &m.TimeoutUntil, &m.Deaf, &m.Mute); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read members")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read members")
 			return
 		}
 		members = append(members, m)
@@ -774,7 +951,7 @@ func (h *Handler) HandleUpdateGuildMember(w http.ResponseWriter, r *http.Request
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "member_update", userID, req) {
@@ -785,13 +962,13 @@ func (h *Handler) HandleUpdateGuildMember(w http.ResponseWriter, r *http.Request
 	// Permission check: manage nicknames for nickname, manage roles for roles, etc.
 	if req.Nickname != nil && userID != memberID {
 		if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageNicknames) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_NICKNAMES permission")
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_NICKNAMES permission")
 			return
 		}
 	}
 	if req.Deaf != nil || req.Mute != nil || req.TimeoutUntil != nil {
 		if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.TimeoutMembers) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need TIMEOUT_MEMBERS permission")
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need TIMEOUT_MEMBERS permission")
 			return
 		}
 	}
@@ -809,20 +986,43 @@ func (h *Handler) HandleUpdateGuildMember(w http.ResponseWriter, r *http.Request
 	).Scan(&m.GuildID, &m.UserID, &m.Nickname, &m.AvatarID, &m.JoinedAt, &m.TimeoutUntil, &m.Deaf, &m.Mute)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "member_not_found", "Member not found")
+			apiutil.WriteCode(w, apierrors.MemberNotFound, "")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update member")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update member")
 		return
 	}
 
 	// Handle role assignment.
 	if req.Roles != nil {
 		if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.AssignRoles) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need ASSIGN_ROLES permission")
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need ASSIGN_ROLES permission")
 			return
 		}
-		h.Pool.Exec(r.Context(), `DELETE FROM member_roles WHERE guild_id = $1 AND user_id = $2`, guildID, memberID)
+		if _, ok := h.appGrant(r.Context(), guildID, userID); ok && memberID == userID {
+			apiutil.WriteError(w, http.StatusForbidden, "app_sandbox", "Apps cannot change their own roles")
+			return
+		}
+		var foreign, community bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM roles WHERE guild_id = $1 AND id = ANY($2) AND managed_by IS NOT NULL AND managed_by <> $3),
+			        EXISTS(SELECT 1 FROM roles WHERE guild_id = $1 AND id = ANY($2) AND community_role_id IS NOT NULL)`,
+			guildID, req.Roles, memberID).Scan(&foreign, &community)
+		if foreign {
+			apiutil.WriteError(w, http.StatusForbidden, "managed_role", "Managed roles can only be held by their app")
+			return
+		}
+		if community {
+			apiutil.WriteError(w, http.StatusForbidden, "community_role", "Community roles are assigned by the community")
+			return
+		}
+		// Managed and community roles stay put; they are only removed by
+		// uninstalling the app or by the community.
+		h.Pool.Exec(r.Context(),
+			`DELETE FROM member_roles WHERE guild_id = $1 AND user_id = $2
+			 AND role_id NOT IN (SELECT id FROM roles WHERE guild_id = $1
+			                     AND (managed_by IS NOT NULL OR community_role_id IS NOT NULL))`,
+			guildID, memberID)
 		for _, roleID := range req.Roles {
 			h.Pool.Exec(r.Context(),
 				`INSERT INTO member_roles (guild_id, user_id, role_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
@@ -830,6 +1030,12 @@ func (h *Handler) HandleUpdateGuildMember(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	// Run after role assignment so a replaced role list cannot strip the
+	// timeout role from a member who is still timed out.
+	if req.TimeoutUntil != nil || req.Roles != nil {
+		h.syncMemberTimeoutRole(r.Context(), guildID, memberID)
+	}
+
 	h.logAudit(r.Context(), guildID, userID, "member_update", "user", memberID, req.Reason)
 	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", guildID, m)
 
@@ -847,7 +1053,7 @@ func (h *Handler) HandleRemoveGuildMember(w http.ResponseWriter, r *http.Request
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "member_remove", userID, map[string]string{"member_id": memberID}) {
@@ -856,7 +1062,7 @@ func (h *Handler) HandleRemoveGuildMember(w http.ResponseWriter, r *http.Request
 	}
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.KickMembers) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need KICK_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need KICK_MEMBERS permission")
 		return
 	}
 
@@ -885,7 +1091,7 @@ func (h *Handler) HandleRemoveGuildMember(w http.ResponseWriter, r *http.Request
 	_, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM guild_members WHERE guild_id = $1 AND user_id = $2`, guildID, memberID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to remove member")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to remove member")
 		return
 	}
 
@@ -897,25 +1103,38 @@ func (h *Handler) HandleRemoveGuildMember(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// HandleGetGuildBans lists all bans in a guild.
-// GET /api/v1/guilds/{guildID}/bans
+// banKeyset orders bans newest first.
+var banKeyset = apiutil.Keyset{Desc: true, Columns: []apiutil.KeyColumn{
+	{Expr: "created_at", Type: "timestamptz"},
+	{Expr: "user_id", Type: "text"},
+}}
+
+// HandleGetGuildBans lists the bans in a guild, newest first, a page at a time.
+// GET /api/v1/guilds/{guildID}/bans?before=&after=&limit=
 func (h *Handler) HandleGetGuildBans(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.BanMembers) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
+	page, ok := apiutil.ParsePage(w, r, banKeyset, apiutil.DefaultPageLimit)
+	if !ok {
+		return
+	}
+	cursorSQL, cursorArgs := page.Where(banKeyset, 3)
+
 	rows, err := h.Pool.Query(r.Context(),
 		`SELECT guild_id, user_id, reason, banned_by, expires_at, created_at
-		 FROM guild_bans WHERE guild_id = $1
-		 ORDER BY created_at DESC`,
-		guildID,
+		 FROM guild_bans WHERE guild_id = $1`+cursorSQL+`
+		 ORDER BY `+page.OrderBy(banKeyset)+`
+		 LIMIT $2`,
+		append([]interface{}{guildID, page.FetchLimit()}, cursorArgs...)...,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get bans")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get bans")
 		return
 	}
 	defer rows.Close()
@@ -924,13 +1143,16 @@ func (h *Handler) HandleGetGuildBans(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var b models.GuildBan
 		if err := rows.Scan(&b.GuildID, &b.UserID, &b.Reason, &b.BannedBy, &b.ExpiresAt, &b.CreatedAt); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read bans")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read bans")
 			return
 		}
 		bans = append(bans, b)
 	}
+	bans, next := apiutil.FinishPage(page, bans, func(b models.GuildBan) []interface{} {
+		return []interface{}{b.CreatedAt, b.UserID}
+	})
 
-	apiutil.WriteJSON(w, http.StatusOK, bans)
+	apiutil.WritePage(w, bans, next)
 }
 
 // HandleCreateGuildBan bans a user from the guild.
@@ -945,7 +1167,7 @@ func (h *Handler) HandleCreateGuildBan(w http.ResponseWriter, r *http.Request) {
 	// error means the JSON is malformed and should be rejected with 400.
 	var req banRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
-		apiutil.WriteError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		apiutil.WriteCode(w, apierrors.InvalidBody, "")
 		return
 	}
 
@@ -953,7 +1175,7 @@ func (h *Handler) HandleCreateGuildBan(w http.ResponseWriter, r *http.Request) {
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "member_ban", actorID, map[string]interface{}{
@@ -967,7 +1189,7 @@ func (h *Handler) HandleCreateGuildBan(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !h.hasGuildPermission(r.Context(), guildID, actorID, permissions.BanMembers) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -1021,7 +1243,7 @@ func (h *Handler) HandleCreateGuildBan(w http.ResponseWriter, r *http.Request) {
 		return nil
 	})
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to ban user")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to ban user")
 		return
 	}
 
@@ -1044,7 +1266,7 @@ func (h *Handler) HandleRemoveGuildBan(w http.ResponseWriter, r *http.Request) {
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "member_unban", actorID, map[string]string{"user_id": targetID}) {
@@ -1053,14 +1275,23 @@ func (h *Handler) HandleRemoveGuildBan(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !h.hasGuildPermission(r.Context(), guildID, actorID, permissions.BanMembers) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
+		return
+	}
+
+	var communityBan bool
+	h.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM guild_bans WHERE guild_id = $1 AND user_id = $2 AND community_id IS NOT NULL)`,
+		guildID, targetID).Scan(&communityBan)
+	if communityBan {
+		apiutil.WriteError(w, http.StatusForbidden, "community_ban", "This ban comes from the guild's community; lift it there")
 		return
 	}
 
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM guild_bans WHERE guild_id = $1 AND user_id = $2`, guildID, targetID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to unban user")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to unban user")
 		return
 	}
 	if tag.RowsAffected() == 0 {
@@ -1083,19 +1314,19 @@ func (h *Handler) HandleGetGuildRoles(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
 	rows, err := h.Pool.Query(r.Context(),
 		`SELECT id, guild_id, name, color, hoist, mentionable, position,
-		        permissions_allow, permissions_deny, created_at
+		        permissions_allow, permissions_deny, managed_by, community_role_id, version, created_at
 		 FROM roles WHERE guild_id = $1
 		 ORDER BY position`,
 		guildID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get roles")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get roles")
 		return
 	}
 	defer rows.Close()
@@ -1105,9 +1336,9 @@ func (h *Handler) HandleGetGuildRoles(w http.ResponseWriter, r *http.Request) {
 		var r models.Role
 		if err := rows.Scan(
 			&r.ID, &r.GuildID, &r.Name, &r.Color, &r.Hoist, &r.Mentionable,
-			&r.Position, &r.PermissionsAllow, &r.PermissionsDeny, &r.CreatedAt,
+			&r.Position, &r.PermissionsAllow, &r.PermissionsDeny, &r.ManagedBy, &r.CommunityRoleID, &r.Version, &r.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read roles")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read roles")
 			return
 		}
 		roles = append(roles, r)
@@ -1123,7 +1354,7 @@ func (h *Handler) HandleCreateGuildRole(w http.ResponseWriter, r *http.Request)
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageRoles) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_ROLES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_ROLES permission")
 		return
 	}
 
@@ -1136,7 +1367,7 @@ func (h *Handler) HandleCreateGuildRole(w http.ResponseWriter, r *http.Request)
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "role_create", userID, req) {
@@ -1148,6 +1379,9 @@ func (h *Handler) HandleCreateGuildRole(w http.ResponseWriter, r *http.Request)
 		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Role name must be 1-100 characters")
 		return
 	}
+	if !h.applyPermissionPreset(w, r, guildID, &req) {
+		return
+	}
 
 	roleID := models.NewULID().String()
 	hoist := false
@@ -1177,6 +1411,10 @@ func (h *Handler) HandleCreateGuildRole(w http.ResponseWriter, r *http.Request)
 	if req.PermissionsDeny.Set {
 		permDeny = req.PermissionsDeny.Value
 	}
+	if grant, ok := h.appGrant(r.Context(), guildID, userID); ok && uint64(permAllow)&^grant != 0 {
+		apiutil.WriteError(w, http.StatusForbidden, "app_sandbox", "Apps cannot grant permissions outside their own grant")
+		return
+	}
 
 	var role models.Role
 	err := h.Pool.QueryRow(r.Context(),
@@ -1189,7 +1427,7 @@ func (h *Handler) HandleCreateGuildRole(w http.ResponseWriter, r *http.Request)
 		&role.Position, &role.PermissionsAllow, &role.PermissionsDeny, &role.CreatedAt,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to create role")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to create role")
 		return
 	}
 
@@ -1207,7 +1445,7 @@ func (h *Handler) HandleUpdateGuildRole(w http.ResponseWriter, r *http.Request)
 	roleID := chi.URLParam(r, "roleID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageRoles) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_ROLES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_ROLES permission")
 		return
 	}
 
@@ -1220,7 +1458,7 @@ func (h *Handler) HandleUpdateGuildRole(w http.ResponseWriter, r *http.Request)
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "role_update", userID, req) {
@@ -1228,6 +1466,25 @@ func (h *Handler) HandleUpdateGuildRole(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	if h.isCommunityRole(r.Context(), guildID, roleID) && (req.Name != nil || req.Color != nil || req.Hoist != nil ||
+		req.Mentionable != nil || req.PermissionsAllow.Int64Ptr() != nil || req.PermissionsDeny.Int64Ptr() != nil) {
+		apiutil.WriteError(w, http.StatusForbidden, "community_role", "Community roles are edited by the community; only their position can change")
+		return
+	}
+
+	if allow := req.PermissionsAllow.Int64Ptr(); allow != nil {
+		if grant, ok := h.appGrant(r.Context(), guildID, userID); ok && uint64(*allow)&^grant != 0 {
+			apiutil.WriteError(w, http.StatusForbidden, "app_sandbox", "Apps cannot grant permissions outside their own grant")
+			return
+		}
+		if botID := h.roleManager(r.Context(), guildID, roleID); botID != nil {
+			if grant, _ := h.appGrant(r.Context(), guildID, *botID); uint64(*allow)&^grant != 0 {
+				apiutil.WriteError(w, http.StatusForbidden, "managed_role", "A managed role cannot exceed the permissions granted to its app")
+				return
+			}
+		}
+	}
+
 	var role models.Role
 	err := h.Pool.QueryRow(r.Context(),
 		`UPDATE roles SET
@@ -1237,21 +1494,28 @@ func (h *Handler) HandleUpdateGuildRole(w http.ResponseWriter, r *http.Request)
 			mentionable = COALESCE($6, mentionable),
 			position = COALESCE($7, position),
 			permissions_allow = COALESCE($8, permissions_allow),
-			permissions_deny = COALESCE($9, permissions_deny)
-		 WHERE id = $1 AND guild_id = $2
-		 RETURNING id, guild_id, name, color, hoist, mentionable, position, permissions_allow, permissions_deny, created_at`,
+			permissions_deny = COALESCE($9, permissions_deny),
+			version = version + 1
+		 WHERE id = $1 AND guild_id = $2 AND ($10::bigint IS NULL OR version = $10)
+		 RETURNING id, guild_id, name, color, hoist, mentionable, position, permissions_allow, permissions_deny, version, created_at`,
 		roleID, guildID, req.Name, req.Color, req.Hoist, req.Mentionable, req.Position,
-		req.PermissionsAllow.Int64Ptr(), req.PermissionsDeny.Int64Ptr(),
+		req.PermissionsAllow.Int64Ptr(), req.PermissionsDeny.Int64Ptr(), req.Version,
 	).Scan(
 		&role.ID, &role.GuildID, &role.Name, &role.Color, &role.Hoist, &role.Mentionable,
-		&role.Position, &role.PermissionsAllow, &role.PermissionsDeny, &role.CreatedAt,
+		&role.Position, &role.PermissionsAllow, &role.PermissionsDeny, &role.Version, &role.CreatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "role_not_found", "Role not found")
+			if req.Version != nil {
+				if current, err := h.getRole(r.Context(), guildID, roleID); err == nil {
+					apiutil.WriteVersionConflict(w, current)
+					return
+				}
+			}
+			apiutil.WriteCode(w, apierrors.RoleNotFound, "")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update role")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update role")
 		return
 	}
 
@@ -1282,7 +1546,7 @@ func (h *Handler) HandleDeleteGuildRole(w http.ResponseWriter, r *http.Request)
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "role_delete", userID, map[string]string{"role_id": roleID}) {
@@ -1291,7 +1555,7 @@ func (h *Handler) HandleDeleteGuildRole(w http.ResponseWriter, r *http.Request)
 	}
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageRoles) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_ROLES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_ROLES permission")
 		return
 	}
 
@@ -1301,21 +1565,29 @@ func (h *Handler) HandleDeleteGuildRole(w http.ResponseWriter, r *http.Request)
 	if err := h.Pool.QueryRow(r.Context(),
 		`SELECT name, position FROM roles WHERE id = $1 AND guild_id = $2`, roleID, guildID,
 	).Scan(&roleName, &rolePos); err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "role_not_found", "Role not found")
+		apiutil.WriteCode(w, apierrors.RoleNotFound, "")
 		return
 	}
 	if roleName == "@everyone" && rolePos == 0 {
 		apiutil.WriteError(w, http.StatusForbidden, "cannot_delete_everyone", "The @everyone role cannot be deleted")
 		return
 	}
+	if h.roleManager(r.Context(), guildID, roleID) != nil {
+		apiutil.WriteError(w, http.StatusForbidden, "managed_role", "This role belongs to an installed app; uninstall the app to remove it")
+		return
+	}
+	if h.isCommunityRole(r.Context(), guildID, roleID) {
+		apiutil.WriteError(w, http.StatusForbidden, "community_role", "This role belongs to the guild's community; remove it there")
+		return
+	}
 
 	tag, err := h.Pool.Exec(r.Context(), `DELETE FROM roles WHERE id = $1 AND guild_id = $2`, roleID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete role")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete role")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "role_not_found", "Role not found")
+		apiutil.WriteCode(w, apierrors.RoleNotFound, "")
 		return
 	}
 
@@ -1334,7 +1606,7 @@ func (h *Handler) HandleReorderGuildRoles(w http.ResponseWriter, r *http.Request
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageRoles) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_ROLES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_ROLES permission")
 		return
 	}
 
@@ -1343,7 +1615,7 @@ func (h *Handler) HandleReorderGuildRoles(w http.ResponseWriter, r *http.Request
 		Position int    `json:"position"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		apiutil.WriteError(w, http.StatusBadRequest, "invalid_body", "Expected array of {id, position} objects")
+		apiutil.WriteCode(w, apierrors.InvalidBody, "Expected array of {id, position} objects")
 		return
 	}
 
@@ -1377,7 +1649,7 @@ func (h *Handler) HandleReorderGuildRoles(w http.ResponseWriter, r *http.Request
 		return nil
 	})
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to reorder roles")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to reorder roles")
 		return
 	}
 
@@ -1392,7 +1664,7 @@ func (h *Handler) HandleReorderGuildChannels(w http.ResponseWriter, r *http.Requ
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -1401,7 +1673,7 @@ func (h *Handler) HandleReorderGuildChannels(w http.ResponseWriter, r *http.Requ
 		Position int    `json:"position"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		apiutil.WriteError(w, http.StatusBadRequest, "invalid_body", "Expected array of {id, position} objects")
+		apiutil.WriteCode(w, apierrors.InvalidBody, "Expected array of {id, position} objects")
 		return
 	}
 
@@ -1421,7 +1693,7 @@ func (h *Handler) HandleReorderGuildChannels(w http.ResponseWriter, r *http.Requ
 		return nil
 	})
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to reorder channels")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to reorder channels")
 		return
 	}
 
@@ -1429,26 +1701,40 @@ func (h *Handler) HandleReorderGuildChannels(w http.ResponseWriter, r *http.Requ
 	h.HandleGetGuildChannels(w, r)
 }
 
-// HandleGetGuildInvites lists all invites for a guild.
-// GET /api/v1/guilds/{guildID}/invites
+// inviteKeyset orders invites newest first.
+var inviteKeyset = apiutil.Keyset{Desc: true, Columns: []apiutil.KeyColumn{
+	{Expr: "created_at", Type: "timestamptz"},
+	{Expr: "code", Type: "text"},
+}}
+
+// HandleGetGuildInvites lists the invites for a guild, newest first, a page at
+// a time.
+// GET /api/v1/guilds/{guildID}/invites?before=&after=&limit=
 func (h *Handler) HandleGetGuildInvites(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	page, ok := apiutil.ParsePage(w, r, inviteKeyset, apiutil.DefaultPageLimit)
+	if !ok {
 		return
 	}
+	cursorSQL, cursorArgs := page.Where(inviteKeyset, 3)
 
 	rows, err := h.Pool.Query(r.Context(),
 		`SELECT code, guild_id, channel_id, creator_id, max_uses, uses,
 		        max_age_seconds, temporary, created_at, expires_at
-		 FROM invites WHERE guild_id = $1
-		 ORDER BY created_at DESC`,
-		guildID,
+		 FROM invites WHERE guild_id = $1`+cursorSQL+`
+		 ORDER BY `+page.OrderBy(inviteKeyset)+`
+		 LIMIT $2`,
+		append([]interface{}{guildID, page.FetchLimit()}, cursorArgs...)...,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get invites")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get invites")
 		return
 	}
 	defer rows.Close()
@@ -1460,13 +1746,16 @@ func (h *Handler) HandleGetGuildInvites(w http.ResponseWriter, r *http.Request)
 			&inv.Code, &inv.GuildID, &inv.ChannelID, &inv.CreatorID, &inv.MaxUses,
 			&inv.Uses, &inv.MaxAgeSeconds, &inv.Temporary, &inv.CreatedAt, &inv.ExpiresAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read invites")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read invites")
 			return
 		}
 		invites = append(invites, inv)
 	}
+	invites, next := apiutil.FinishPage(page, invites, func(inv models.Invite) []interface{} {
+		return []interface{}{inv.CreatedAt, inv.Code}
+	})
 
-	apiutil.WriteJSON(w, http.StatusOK, invites)
+	apiutil.WritePage(w, invites, next)
 }
 
 // HandleCreateGuildInvite creates a new invite for a guild.
@@ -1476,7 +1765,11 @@ func (h *Handler) HandleCreateGuildInvite(w http.ResponseWriter, r *http.Request
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.CreateInvites) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need CREATE_INVITES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need CREATE_INVITES permission")
+		return
+	}
+	if apiutil.FromRestrictedPeer(r.Context(), h.Pool, h.InstanceID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "restricted_instance", "Users from your instance cannot create invites here")
 		return
 	}
 
@@ -1528,14 +1821,26 @@ func (h *Handler) HandleCreateGuildInvite(w http.ResponseWriter, r *http.Request
 	apiutil.WriteJSON(w, http.StatusCreated, inv)
 }
 
-// HandleGetGuildAuditLog returns the audit log for a guild.
-// GET /api/v1/guilds/{guildID}/audit-log
+// auditLogKeyset orders audit log entries newest first.
+var auditLogKeyset = apiutil.Keyset{Desc: true, Columns: []apiutil.KeyColumn{
+	{Expr: "created_at", Type: "timestamptz"},
+	{Expr: "id", Type: "text"},
+}}
+
+// HandleGetGuildAuditLog returns the audit log for a guild, newest first, a
+// page at a time, optionally filtered by action and actor.
+// GET /api/v1/guilds/{guildID}/audit-log?action=&actor_id=&before=&after=&limit=
 func (h *Handler) HandleGetGuildAuditLog(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ViewAuditLog) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need VIEW_AUDIT_LOG permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need VIEW_AUDIT_LOG permission")
+		return
+	}
+
+	page, ok := apiutil.ParsePage(w, r, auditLogKeyset, apiutil.DefaultPageLimit)
+	if !ok {
 		return
 	}
 
@@ -1555,17 +1860,17 @@ func (h *Handler) HandleGetGuildAuditLog(w http.ResponseWriter, r *http.Request)
 		args = append(args, actorID)
 		argIdx++
 	}
-	if before := r.URL.Query().Get("before"); before != "" {
-		baseSQL += fmt.Sprintf(` AND id < $%d`, argIdx)
-		args = append(args, before)
-		argIdx++
-	}
+	cursorSQL, cursorArgs := page.Where(auditLogKeyset, argIdx)
+	baseSQL += cursorSQL
+	args = append(args, cursorArgs...)
+	argIdx += len(cursorArgs)
 
-	baseSQL += ` ORDER BY created_at DESC LIMIT 100`
+	baseSQL += fmt.Sprintf(` ORDER BY %s LIMIT $%d`, page.OrderBy(auditLogKeyset), argIdx)
+	args = append(args, page.FetchLimit())
 
 	rows, err := h.Pool.Query(r.Context(), baseSQL, args...)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get audit log")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get audit log")
 		return
 	}
 	defer rows.Close()
@@ -1577,90 +1882,178 @@ func (h *Handler) HandleGetGuildAuditLog(w http.ResponseWriter, r *http.Request)
 			&e.ID, &e.GuildID, &e.ActorID, &e.Action, &e.TargetType,
 			&e.TargetID, &e.Reason, &e.Changes, &e.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read audit log")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read audit log")
 			return
 		}
 		entries = append(entries, e)
 	}
+	entries, next := apiutil.FinishPage(page, entries, func(e models.AuditLogEntry) []interface{} {
+		return []interface{}{e.CreatedAt, e.ID}
+	})
 
-	apiutil.WriteJSON(w, http.StatusOK, entries)
+	apiutil.WritePage(w, entries, next)
 }
 
-// HandleGetGuildEmoji lists custom emoji for a guild.
-// GET /api/v1/guilds/{guildID}/emoji
+// emojiKeyset orders a guild's emoji, and the instance emoji listed with
+// them, by name; names are unique within each origin.
+var emojiKeyset = apiutil.Keyset{Columns: []apiutil.KeyColumn{
+	{Expr: "name", Type: "text"},
+	{Expr: "origin", Type: "text"},
+}}
+
+// HandleGetGuildEmoji lists custom emoji for a guild by name, a page at a
+// time, along with the instance emoji usable in every guild; origin tells
+// them apart. Emoji flagged by AutoMod are listed only for members with
+// MANAGE_EMOJI.
+// GET /api/v1/guilds/{guildID}/emoji?before=&after=&limit=
 func (h *Handler) HandleGetGuildEmoji(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+	withFlagged := h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageEmoji)
+
+	page, ok := apiutil.ParsePage(w, r, emojiKeyset, apiutil.DefaultPageLimit)
+	if !ok {
 		return
 	}
+	cursorSQL, cursorArgs := page.Where(emojiKeyset, 4)
 
 	rows, err := h.Pool.Query(r.Context(),
-		`SELECT id, guild_id, name, creator_id, animated, s3_key, created_at
-		 FROM custom_emoji WHERE guild_id = $1
-		 ORDER BY name`,
-		guildID,
+		`SELECT * FROM (`+listedEmojiSQL+`) e
+		 WHERE true`+cursorSQL+`
+		 ORDER BY `+page.OrderBy(emojiKeyset)+`
+		 LIMIT $3`,
+		append([]interface{}{guildID, withFlagged, page.FetchLimit()}, cursorArgs...)...,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get emoji")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get emoji")
 		return
 	}
 	defer rows.Close()
 
 	emoji := make([]models.CustomEmoji, 0)
 	for rows.Next() {
-		var e models.CustomEmoji
-		if err := rows.Scan(&e.ID, &e.GuildID, &e.Name, &e.CreatorID, &e.Animated, &e.S3Key, &e.CreatedAt); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read emoji")
+		e, err := scanListedEmoji(rows)
+		if err != nil {
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read emoji")
 			return
 		}
 		emoji = append(emoji, e)
 	}
+	emoji, next := apiutil.FinishPage(page, emoji, func(e models.CustomEmoji) []interface{} {
+		return []interface{}{e.Name, e.Origin}
+	})
 
-	apiutil.WriteJSON(w, http.StatusOK, emoji)
+	apiutil.WritePage(w, emoji, next)
 }
 
 // HandleCreateGuildEmoji creates a custom emoji (metadata only; file upload is separate).
+// The guild's quota must have room for it. A name already in use is refused
+// unless on_conflict is "rename", which picks the next free suffixed name.
 // POST /api/v1/guilds/{guildID}/emoji
 func (h *Handler) HandleCreateGuildEmoji(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageEmoji) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_EMOJI permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_EMOJI permission")
 		return
 	}
 
 	var req struct {
-		Name     string `json:"name"`
-		S3Key    string `json:"s3_key"`
-		Animated bool   `json:"animated"`
+		Name       string `json:"name"`
+		S3Key      string `json:"s3_key"`
+		Animated   bool   `json:"animated"`
+		OnConflict string `json:"on_conflict"`
 	}
 	if !apiutil.DecodeJSON(w, r, &req) {
 		return
 	}
 
-	if req.Name == "" || len(req.Name) > 32 {
+	if req.Name == "" || len(req.Name) > maxEmojiName {
 		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Emoji name must be 1-32 characters")
 		return
 	}
+	if req.OnConflict != "" && req.OnConflict != "reject" && req.OnConflict != "rename" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_on_conflict", "on_conflict must be reject or rename")
+		return
+	}
+
+	// The image hash was recorded when the file was uploaded.
+	var imageHash *string
+	h.Pool.QueryRow(r.Context(),
+		`SELECT sha256 FROM attachments WHERE s3_key = $1`, req.S3Key).Scan(&imageHash)
+
+	rule, reason, err := h.screenEmoji(r.Context(), guildID, imageHash)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to screen emoji", err)
+		return
+	}
+	var flagReason *string
+	if rule != nil {
+		h.AutoMod.ReportEmoji(r.Context(), rule, guildID, userID, req.Name, reason)
+		if rule.Action == automod.ActionDelete {
+			apiutil.WriteError(w, http.StatusForbidden, "emoji_blocked", "This image is blocked by the guild's AutoMod rules")
+			return
+		}
+		flagReason = &reason
+	}
 
 	emojiID := models.NewULID().String()
 	var emoji models.CustomEmoji
-	err := h.Pool.QueryRow(r.Context(),
-		`INSERT INTO custom_emoji (id, guild_id, name, creator_id, animated, s3_key, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, now())
-		 RETURNING id, guild_id, name, creator_id, animated, s3_key, created_at`,
-		emojiID, guildID, req.Name, userID, req.Animated, req.S3Key,
-	).Scan(&emoji.ID, &emoji.GuildID, &emoji.Name, &emoji.CreatorID, &emoji.Animated, &emoji.S3Key, &emoji.CreatedAt)
+	var quotaFull, nameTaken bool
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		// Locking the guild row serializes concurrent uploads against the quota.
+		q, err := scanEmojiQuota(tx.QueryRow(r.Context(), emojiQuotaSQL+` FOR UPDATE OF g`, guildID), guildID)
+		if err != nil {
+			return err
+		}
+		if !emojiSlotFree(q, req.Animated) {
+			quotaFull = true
+			return nil
+		}
+
+		names, err := guildEmojiNames(r.Context(), tx, guildID)
+		if err != nil {
+			return err
+		}
+		name := req.Name
+		if names[strings.ToLower(name)] {
+			if req.OnConflict != "rename" {
+				nameTaken = true
+				return nil
+			}
+			name = uniqueEmojiName(name, names)
+		}
+
+		emoji, err = scanEmoji(tx.QueryRow(r.Context(),
+			`INSERT INTO custom_emoji (id, guild_id, name, creator_id, animated, s3_key, image_hash, flagged, flag_reason, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())
+			 RETURNING `+emojiColumns,
+			emojiID, guildID, name, userID, req.Animated, req.S3Key, imageHash, flagReason != nil, flagReason,
+		))
+		return err
+	})
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to create emoji")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to create emoji")
+		return
+	}
+	if quotaFull {
+		apiutil.WriteError(w, http.StatusBadRequest, "emoji_quota_exceeded", "This guild has no emoji slots of that kind left")
+		return
+	}
+	if nameTaken {
+		apiutil.WriteError(w, http.StatusConflict, "emoji_name_taken", "An emoji with that name already exists")
 		return
 	}
 
-	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildEmojiUpdate, "GUILD_EMOJI_UPDATE", guildID, emoji)
+	if !emoji.Flagged {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildEmojiUpdate, "GUILD_EMOJI_UPDATE", guildID, emoji)
+	}
 
 	apiutil.WriteJSON(w, http.StatusCreated, emoji)
 }
@@ -1673,7 +2066,7 @@ func (h *Handler) HandleUpdateGuildEmoji(w http.ResponseWriter, r *http.Request)
 	emojiID := chi.URLParam(r, "emojiID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageEmoji) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_EMOJI permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_EMOJI permission")
 		return
 	}
 
@@ -1683,28 +2076,43 @@ func (h *Handler) HandleUpdateGuildEmoji(w http.ResponseWriter, r *http.Request)
 	if !apiutil.DecodeJSON(w, r, &req) {
 		return
 	}
-	if req.Name == "" || len(req.Name) > 32 {
+	if req.Name == "" || len(req.Name) > maxEmojiName {
 		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Emoji name must be 1-32 characters")
 		return
 	}
 
 	var emoji models.CustomEmoji
-	err := h.Pool.QueryRow(r.Context(),
-		`UPDATE custom_emoji SET name = $1
-		 WHERE id = $2 AND guild_id = $3
-		 RETURNING id, guild_id, name, creator_id, animated, s3_key, created_at`,
-		req.Name, emojiID, guildID,
-	).Scan(&emoji.ID, &emoji.GuildID, &emoji.Name, &emoji.CreatorID, &emoji.Animated, &emoji.S3Key, &emoji.CreatedAt)
+	var nameTaken bool
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		taken, err := emojiNameTaken(r.Context(), tx, guildID, req.Name, emojiID)
+		if err != nil || taken {
+			nameTaken = taken
+			return err
+		}
+		emoji, err = scanEmoji(tx.QueryRow(r.Context(),
+			`UPDATE custom_emoji SET name = $1
+			 WHERE id = $2 AND guild_id = $3
+			 RETURNING `+emojiColumns,
+			req.Name, emojiID, guildID,
+		))
+		return err
+	})
 	if err == pgx.ErrNoRows {
 		apiutil.WriteError(w, http.StatusNotFound, "emoji_not_found", "Emoji not found")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update emoji")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update emoji")
+		return
+	}
+	if nameTaken {
+		apiutil.WriteError(w, http.StatusConflict, "emoji_name_taken", "An emoji with that name already exists")
 		return
 	}
 
-	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildEmojiUpdate, "GUILD_EMOJI_UPDATE", guildID, emoji)
+	if !emoji.Flagged {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildEmojiUpdate, "GUILD_EMOJI_UPDATE", guildID, emoji)
+	}
 
 	apiutil.WriteJSON(w, http.StatusOK, emoji)
 }
@@ -1717,14 +2125,14 @@ func (h *Handler) HandleDeleteGuildEmoji(w http.ResponseWriter, r *http.Request)
 	emojiID := chi.URLParam(r, "emojiID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageEmoji) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_EMOJI permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_EMOJI permission")
 		return
 	}
 
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM custom_emoji WHERE id = $1 AND guild_id = $2`, emojiID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete emoji")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete emoji")
 		return
 	}
 	if tag.RowsAffected() == 0 {
@@ -1738,6 +2146,29 @@ func (h *Handler) HandleDeleteGuildEmoji(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+const categoryColumns = `id, guild_id, name, position, nsfw, slowmode_seconds, notification_level, created_at`
+
+func scanCategory(row pgx.Row) (models.GuildCategory, error) {
+	var c models.GuildCategory
+	err := row.Scan(&c.ID, &c.GuildID, &c.Name, &c.Position, &c.NSFW, &c.SlowmodeSeconds,
+		&c.NotificationLevel, &c.CreatedAt)
+	return c, err
+}
+
+// validateCategoryDefaults checks the channel defaults of a category create
+// or update. Nil fields are left unchecked.
+func validateCategoryDefaults(w http.ResponseWriter, slowmode *int, level *string) bool {
+	if slowmode != nil && (*slowmode < 0 || *slowmode > 21600) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_slowmode", "Slowmode must be between 0 and 21600 seconds")
+		return false
+	}
+	if level != nil && *level != "all" && *level != "mentions" && *level != "none" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_level", "Notification level must be all, mentions, or none")
+		return false
+	}
+	return true
+}
+
 // HandleGetGuildCategories lists channel categories for a guild.
 // GET /api/v1/guilds/{guildID}/categories
 func (h *Handler) HandleGetGuildCategories(w http.ResponseWriter, r *http.Request) {
@@ -1745,27 +2176,27 @@ func (h *Handler) HandleGetGuildCategories(w http.ResponseWriter, r *http.Reques
 	userID := auth.UserIDFromContext(r.Context())
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
 	rows, err := h.Pool.Query(r.Context(),
-		`SELECT id, guild_id, name, position, created_at
+		`SELECT `+categoryColumns+`
 		 FROM guild_categories WHERE guild_id = $1
 		 ORDER BY position`,
 		guildID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get categories")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get categories")
 		return
 	}
 	defer rows.Close()
 
 	categories := make([]models.GuildCategory, 0)
 	for rows.Next() {
-		var c models.GuildCategory
-		if err := rows.Scan(&c.ID, &c.GuildID, &c.Name, &c.Position, &c.CreatedAt); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read categories")
+		c, err := scanCategory(rows)
+		if err != nil {
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read categories")
 			return
 		}
 		categories = append(categories, c)
@@ -1781,13 +2212,16 @@ func (h *Handler) HandleCreateGuildCategory(w http.ResponseWriter, r *http.Reque
 	userID := auth.UserIDFromContext(r.Context())
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
 	var req struct {
-		Name     string `json:"name"`
-		Position *int   `json:"position"`
+		Name              string  `json:"name"`
+		Position          *int    `json:"position"`
+		NSFW              bool    `json:"nsfw"`
+		SlowmodeSeconds   int     `json:"slowmode_seconds"`
+		NotificationLevel *string `json:"notification_level"`
 	}
 	if !apiutil.DecodeJSON(w, r, &req) {
 		return
@@ -1797,7 +2231,7 @@ func (h *Handler) HandleCreateGuildCategory(w http.ResponseWriter, r *http.Reque
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "category_create", userID, req) {
@@ -1809,6 +2243,9 @@ func (h *Handler) HandleCreateGuildCategory(w http.ResponseWriter, r *http.Reque
 		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Category name must be 1-100 characters")
 		return
 	}
+	if !validateCategoryDefaults(w, &req.SlowmodeSeconds, req.NotificationLevel) {
+		return
+	}
 
 	catID := models.NewULID().String()
 	position := 0
@@ -1816,13 +2253,12 @@ func (h *Handler) HandleCreateGuildCategory(w http.ResponseWriter, r *http.Reque
 		position = *req.Position
 	}
 
-	var cat models.GuildCategory
-	err := h.Pool.QueryRow(r.Context(),
-		`INSERT INTO guild_categories (id, guild_id, name, position, created_at)
-		 VALUES ($1, $2, $3, $4, now())
-		 RETURNING id, guild_id, name, position, created_at`,
-		catID, guildID, req.Name, position,
-	).Scan(&cat.ID, &cat.GuildID, &cat.Name, &cat.Position, &cat.CreatedAt)
+	cat, err := scanCategory(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO guild_categories (id, guild_id, name, position, nsfw, slowmode_seconds, notification_level, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+		 RETURNING `+categoryColumns,
+		catID, guildID, req.Name, position, req.NSFW, req.SlowmodeSeconds, req.NotificationLevel,
+	))
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to create category", err)
 		return
@@ -1831,7 +2267,9 @@ func (h *Handler) HandleCreateGuildCategory(w http.ResponseWriter, r *http.Reque
 	apiutil.WriteJSON(w, http.StatusCreated, cat)
 }
 
-// HandleUpdateGuildCategory updates a channel category's name or position.
+// HandleUpdateGuildCategory updates a channel category's name, position or
+// channel defaults. Changed defaults reach every channel that inherits them;
+// send "notification_level": "" to clear the category's level.
 // PATCH /api/v1/guilds/{guildID}/categories/{categoryID}
 func (h *Handler) HandleUpdateGuildCategory(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
@@ -1839,13 +2277,16 @@ func (h *Handler) HandleUpdateGuildCategory(w http.ResponseWriter, r *http.Reque
 	userID := auth.UserIDFromContext(r.Context())
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
 	var req struct {
-		Name     *string `json:"name"`
-		Position *int    `json:"position"`
+		Name              *string `json:"name"`
+		Position          *int    `json:"position"`
+		NSFW              *bool   `json:"nsfw"`
+		SlowmodeSeconds   *int    `json:"slowmode_seconds"`
+		NotificationLevel *string `json:"notification_level"`
 	}
 	if !apiutil.DecodeJSON(w, r, &req) {
 		return
@@ -1855,7 +2296,7 @@ func (h *Handler) HandleUpdateGuildCategory(w http.ResponseWriter, r *http.Reque
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "category_update", userID, req) {
@@ -1863,24 +2304,42 @@ func (h *Handler) HandleUpdateGuildCategory(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	var cat models.GuildCategory
-	err := h.Pool.QueryRow(r.Context(),
+	clearLevel := req.NotificationLevel != nil && *req.NotificationLevel == ""
+	if clearLevel {
+		req.NotificationLevel = nil
+	}
+	if !validateCategoryDefaults(w, req.SlowmodeSeconds, req.NotificationLevel) {
+		return
+	}
+
+	// Inheriting channels are updated by the category_propagate_defaults
+	// trigger in the same statement.
+	cat, err := scanCategory(h.Pool.QueryRow(r.Context(),
 		`UPDATE guild_categories SET
 			name = COALESCE($3, name),
-			position = COALESCE($4, position)
+			position = COALESCE($4, position),
+			nsfw = COALESCE($5, nsfw),
+			slowmode_seconds = COALESCE($6, slowmode_seconds),
+			notification_level = CASE WHEN $8 THEN NULL ELSE COALESCE($7, notification_level) END
 		 WHERE id = $1 AND guild_id = $2
-		 RETURNING id, guild_id, name, position, created_at`,
-		categoryID, guildID, req.Name, req.Position,
-	).Scan(&cat.ID, &cat.GuildID, &cat.Name, &cat.Position, &cat.CreatedAt)
+		 RETURNING `+categoryColumns,
+		categoryID, guildID, req.Name, req.Position, req.NSFW, req.SlowmodeSeconds,
+		req.NotificationLevel, clearLevel,
+	))
 	if err == pgx.ErrNoRows {
 		apiutil.WriteError(w, http.StatusNotFound, "category_not_found", "Category not found")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update category")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update category")
 		return
 	}
 
+	// Channels following the category changed too; clients refetch them.
+	if req.NSFW != nil || req.SlowmodeSeconds != nil || req.NotificationLevel != nil || clearLevel {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildUpdate, "GUILD_CATEGORY_UPDATE", guildID, cat)
+	}
+
 	apiutil.WriteJSON(w, http.StatusOK, cat)
 }
 
@@ -1896,7 +2355,7 @@ func (h *Handler) HandleDeleteGuildCategory(w http.ResponseWriter, r *http.Reque
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "category_delete", userID, map[string]string{"category_id": categoryID}) {
@@ -1905,14 +2364,14 @@ func (h *Handler) HandleDeleteGuildCategory(w http.ResponseWriter, r *http.Reque
 	}
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM guild_categories WHERE id = $1 AND guild_id = $2`, categoryID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete category")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete category")
 		return
 	}
 	if tag.RowsAffected() == 0 {
@@ -1925,26 +2384,40 @@ func (h *Handler) HandleDeleteGuildCategory(w http.ResponseWriter, r *http.Reque
 
 // --- Internal helpers ---
 
-// HandleGetGuildWebhooks lists all webhooks for a guild.
-// GET /api/v1/guilds/{guildID}/webhooks
+// webhookKeyset orders webhooks newest first.
+var webhookKeyset = apiutil.Keyset{Desc: true, Columns: []apiutil.KeyColumn{
+	{Expr: "created_at", Type: "timestamptz"},
+	{Expr: "id", Type: "text"},
+}}
+
+// HandleGetGuildWebhooks lists the webhooks for a guild, newest first, a page
+// at a time.
+// GET /api/v1/guilds/{guildID}/webhooks?before=&after=&limit=
 func (h *Handler) HandleGetGuildWebhooks(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageWebhooks) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_WEBHOOKS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
 		return
 	}
 
+	page, ok := apiutil.ParsePage(w, r, webhookKeyset, apiutil.DefaultPageLimit)
+	if !ok {
+		return
+	}
+	cursorSQL, cursorArgs := page.Where(webhookKeyset, 3)
+
 	rows, err := h.Pool.Query(r.Context(),
 		`SELECT id, guild_id, channel_id, creator_id, name, avatar_id, token,
 		        webhook_type, outgoing_url, created_at
-		 FROM webhooks WHERE guild_id = $1
-		 ORDER BY created_at DESC`,
-		guildID,
+		 FROM webhooks WHERE guild_id = $1`+cursorSQL+`
+		 ORDER BY `+page.OrderBy(webhookKeyset)+`
+		 LIMIT $2`,
+		append([]interface{}{guildID, page.FetchLimit()}, cursorArgs...)...,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get webhooks")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get webhooks")
 		return
 	}
 	defer rows.Close()
@@ -1956,13 +2429,16 @@ func (h *Handler) HandleGetGuildWebhooks(w http.ResponseWriter, r *http.Request)
 			&wh.ID, &wh.GuildID, &wh.ChannelID, &wh.CreatorID, &wh.Name,
 			&wh.AvatarID, &wh.Token, &wh.WebhookType, &wh.OutgoingURL, &wh.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read webhooks")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read webhooks")
 			return
 		}
 		webhooks = append(webhooks, wh)
 	}
+	webhooks, next := apiutil.FinishPage(page, webhooks, func(wh models.Webhook) []interface{} {
+		return []interface{}{wh.CreatedAt, wh.ID}
+	})
 
-	apiutil.WriteJSON(w, http.StatusOK, webhooks)
+	apiutil.WritePage(w, webhooks, next)
 }
 
 // HandleCreateGuildWebhook creates a new webhook for a guild channel.
@@ -1972,7 +2448,7 @@ func (h *Handler) HandleCreateGuildWebhook(w http.ResponseWriter, r *http.Reques
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageWebhooks) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_WEBHOOKS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
 		return
 	}
 
@@ -1986,7 +2462,7 @@ func (h *Handler) HandleCreateGuildWebhook(w http.ResponseWriter, r *http.Reques
 	}
 
 	if req.Name == "" || len(req.Name) > 80 || req.ChannelID == "" {
-		apiutil.WriteError(w, http.StatusBadRequest, "missing_fields", "Name (1-80 chars) and channel_id are required")
+		apiutil.WriteCode(w, apierrors.MissingFields, "Name (1-80 chars) and channel_id are required")
 		return
 	}
 
@@ -2029,7 +2505,7 @@ func (h *Handler) HandleUpdateGuildWebhook(w http.ResponseWriter, r *http.Reques
 	webhookID := chi.URLParam(r, "webhookID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageWebhooks) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_WEBHOOKS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
 		return
 	}
 
@@ -2060,7 +2536,7 @@ func (h *Handler) HandleUpdateGuildWebhook(w http.ResponseWriter, r *http.Reques
 			apiutil.WriteError(w, http.StatusNotFound, "webhook_not_found", "Webhook not found")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update webhook")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update webhook")
 		return
 	}
 
@@ -2076,14 +2552,14 @@ func (h *Handler) HandleDeleteGuildWebhook(w http.ResponseWriter, r *http.Reques
 	webhookID := chi.URLParam(r, "webhookID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageWebhooks) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_WEBHOOKS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
 		return
 	}
 
 	result, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM webhooks WHERE id = $1 AND guild_id = $2`, webhookID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete webhook")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete webhook")
 		return
 	}
 
@@ -2100,9 +2576,10 @@ func (h *Handler) getGuild(ctx context.Context, guildID string) (*models.Guild,
 	var g models.Guild
 	err := h.Pool.QueryRow(ctx,
 		`SELECT g.id, g.instance_id, COALESCE(i.domain, ''), g.owner_id, g.name, g.description, g.icon_id, g.banner_id,
-		        g.default_permissions, g.flags, g.nsfw, g.discoverable, g.preferred_locale,
+		        g.default_permissions, g.flags, g.nsfw, g.discoverable, g.preferred_locale, g.timezone,
 		        g.max_members, g.vanity_url, g.verification_level, g.afk_channel_id, g.afk_timeout,
-		        g.tags, g.member_count, g.created_at
+		        g.system_channel_id, g.system_join_messages, g.system_boost_messages, g.system_event_reminders,
+		        g.invite_link_policy, g.tags, g.member_count, g.version, g.created_at
 		 FROM guilds g
 		 LEFT JOIN instances i ON i.id = g.instance_id
 		 WHERE g.id = $1`,
@@ -2110,12 +2587,37 @@ func (h *Handler) getGuild(ctx context.Context, guildID string) (*models.Guild,
 	).Scan(
 		&g.ID, &g.InstanceID, &g.InstanceDomain, &g.OwnerID, &g.Name, &g.Description, &g.IconID,
 		&g.BannerID, &g.DefaultPermissions, &g.Flags, &g.NSFW, &g.Discoverable,
-		&g.PreferredLocale, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel, &g.AFKChannelID, &g.AFKTimeout,
-		&g.Tags, &g.MemberCount, &g.CreatedAt,
+		&g.PreferredLocale, &g.Timezone, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel, &g.AFKChannelID, &g.AFKTimeout,
+		&g.SystemChannelID, &g.SystemJoinMessages, &g.SystemBoostMessages, &g.SystemEventReminders,
+		&g.InviteLinkPolicy, &g.Tags, &g.MemberCount, &g.Version, &g.CreatedAt,
 	)
 	return &g, err
 }
 
+func (h *Handler) getRole(ctx context.Context, guildID, roleID string) (*models.Role, error) {
+	var role models.Role
+	err := h.Pool.QueryRow(ctx,
+		`SELECT id, guild_id, name, color, hoist, mentionable, position,
+		        permissions_allow, permissions_deny, managed_by, community_role_id, version, created_at
+		 FROM roles WHERE id = $1 AND guild_id = $2`,
+		roleID, guildID,
+	).Scan(
+		&role.ID, &role.GuildID, &role.Name, &role.Color, &role.Hoist, &role.Mentionable,
+		&role.Position, &role.PermissionsAllow, &role.PermissionsDeny, &role.ManagedBy, &role.CommunityRoleID, &role.Version, &role.CreatedAt,
+	)
+	return &role, err
+}
+
+// isCommunityRole reports whether the role mirrors one of the guild's
+// community roles. Those are assigned and edited by the community.
+func (h *Handler) isCommunityRole(ctx context.Context, guildID, roleID string) bool {
+	var mirrored bool
+	h.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM roles WHERE id = $1 AND guild_id = $2 AND community_role_id IS NOT NULL)`,
+		roleID, guildID).Scan(&mirrored)
+	return mirrored
+}
+
 // HandleGetGuildPreview returns a limited preview of a guild for non-members.
 // Includes basic info, approximate member count, emoji count, and top channels.
 // GET /api/v1/guilds/{guildID}/preview
@@ -2125,23 +2627,23 @@ func (h *Handler) HandleGetGuildPreview(w http.ResponseWriter, r *http.Request)
 	var g models.Guild
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT g.id, g.instance_id, g.owner_id, g.name, g.description, g.icon_id, g.banner_id,
-		        g.flags, g.nsfw, g.discoverable, g.preferred_locale,
+		        g.flags, g.nsfw, g.discoverable, g.preferred_locale, g.timezone,
 		        g.verification_level, g.afk_channel_id, g.afk_timeout,
 		        g.tags, g.member_count, g.created_at
 		 FROM guilds g WHERE g.id = $1`,
 		guildID,
 	).Scan(
 		&g.ID, &g.InstanceID, &g.OwnerID, &g.Name, &g.Description, &g.IconID,
-		&g.BannerID, &g.Flags, &g.NSFW, &g.Discoverable, &g.PreferredLocale,
+		&g.BannerID, &g.Flags, &g.NSFW, &g.Discoverable, &g.PreferredLocale, &g.Timezone,
 		&g.VerificationLevel, &g.AFKChannelID, &g.AFKTimeout,
 		&g.Tags, &g.MemberCount, &g.CreatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+			apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 			return
 		}
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get guild preview")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get guild preview")
 		return
 	}
 
@@ -2196,11 +2698,11 @@ func (h *Handler) HandleGetGuildVanityURL(w http.ResponseWriter, r *http.Request
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT vanity_url FROM guilds WHERE id = $1`, guildID).Scan(&vanityURL)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get vanity URL")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get vanity URL")
 		return
 	}
 
@@ -2226,11 +2728,11 @@ func (h *Handler) HandleSetGuildVanityURL(w http.ResponseWriter, r *http.Request
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT owner_id FROM guilds WHERE id = $1`, guildID).Scan(&ownerID)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get guild")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get guild")
 		return
 	}
 	if ownerID != userID {
@@ -2284,7 +2786,7 @@ func (h *Handler) HandleResolveVanityURL(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to resolve vanity URL")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to resolve vanity URL")
 		return
 	}
 
@@ -2308,7 +2810,7 @@ func (h *Handler) HandleDiscoverGuilds(w http.ResponseWriter, r *http.Request) {
 	// The bump_score subquery counts bumps in the last 24 hours.
 	baseSQL := `SELECT g.id, g.instance_id, g.owner_id, g.name, g.description, g.icon_id,
 	            g.banner_id, g.default_permissions, g.flags, g.nsfw, g.discoverable,
-	            g.preferred_locale, g.max_members, g.vanity_url, g.verification_level,
+	            g.preferred_locale, g.timezone, g.max_members, g.vanity_url, g.verification_level,
 	            g.afk_channel_id, g.afk_timeout, g.tags,
 	            g.member_count, g.created_at
 	     FROM guilds g
@@ -2348,7 +2850,7 @@ func (h *Handler) HandleDiscoverGuilds(w http.ResponseWriter, r *http.Request) {
 		if err := rows.Scan(
 			&g.ID, &g.InstanceID, &g.OwnerID, &g.Name, &g.Description, &g.IconID,
 			&g.BannerID, &g.DefaultPermissions, &g.Flags, &g.NSFW, &g.Discoverable,
-			&g.PreferredLocale, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel,
+			&g.PreferredLocale, &g.Timezone, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel,
 			&g.AFKChannelID, &g.AFKTimeout, &g.Tags,
 			&g.MemberCount, &g.CreatedAt,
 		); err != nil {
@@ -2374,7 +2876,7 @@ func (h *Handler) HandleJoinDiscoverableGuild(w http.ResponseWriter, r *http.Req
 		`SELECT discoverable, name FROM guilds WHERE id = $1`, guildID,
 	).Scan(&discoverable, &guildName)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Guild not found")
 		return
 	}
 	if !discoverable {
@@ -2399,27 +2901,42 @@ func (h *Handler) HandleJoinDiscoverableGuild(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Add member.
-	_, err = h.Pool.Exec(r.Context(),
-		`INSERT INTO guild_members (guild_id, user_id, joined_at) VALUES ($1, $2, now())`,
-		guildID, userID,
-	)
+	// Check the guild accepts members from the user's instance.
+	ref, err := apiutil.CheckUserJoinOrigin(r.Context(), h.Pool, h.InstanceID, guildID, userID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to join guild")
+		apiutil.InternalError(w, h.Logger, "Failed to check join origin policy", err)
+		return
+	}
+	if ref != nil {
+		apiutil.WriteJoinOriginRefusal(w, ref)
 		return
 	}
 
-	// Increment member count.
-	h.Pool.Exec(r.Context(), `UPDATE guilds SET member_count = member_count + 1 WHERE id = $1`, guildID)
+	// Add member.
+	var joinedAt time.Time
+	var added bool
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		var err error
+		joinedAt, added, err = apiutil.AddGuildMember(r.Context(), tx, guildID, userID)
+		return err
+	})
+	switch {
+	case errors.Is(err, apiutil.ErrJoinBanned):
+		apiutil.WriteError(w, http.StatusForbidden, "banned", "You are banned from this guild")
+		return
+	case errors.Is(err, apiutil.ErrJoinGuildFull):
+		apiutil.WriteError(w, http.StatusForbidden, "guild_full", "This guild has reached its maximum member count")
+		return
+	case err != nil:
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to join guild")
+		return
+	case !added:
+		apiutil.WriteError(w, http.StatusConflict, "already_member", "You are already a member of this guild")
+		return
+	}
 
 	// Publish guild join event.
-	if h.EventBus != nil {
-		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildMemberAdd, "GUILD_MEMBER_ADD", guildID,
-			map[string]interface{}{
-				"guild_id": guildID,
-				"user_id":  userID,
-			})
-	}
+	apiutil.PublishMemberAdd(r.Context(), h.EventBus, guildID, userID, joinedAt)
 
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"guild_id": guildID,
@@ -2475,9 +2992,13 @@ func (h *Handler) hasGuildPermission(ctx context.Context, guildID, userID string
 		return true
 	}
 
-	// Get guild default permissions.
+	// Get guild default permissions, and the grant if the user is an installed app.
 	var defaultPerms int64
-	h.Pool.QueryRow(ctx, `SELECT default_permissions FROM guilds WHERE id = $1`, guildID).Scan(&defaultPerms)
+	var appCap *int64
+	h.Pool.QueryRow(ctx,
+		`SELECT g.default_permissions, bi.permissions
+		 FROM guilds g LEFT JOIN bot_installs bi ON bi.guild_id = g.id AND bi.bot_id = $2
+		 WHERE g.id = $1`, guildID, userID).Scan(&defaultPerms, &appCap)
 	computedPerms := uint64(defaultPerms)
 
 	// Apply member's role permissions.
@@ -2499,6 +3020,11 @@ func (h *Handler) hasGuildPermission(ctx context.Context, guildID, userID string
 		}
 	}
 
+	// Apps never act beyond what they were granted at install.
+	if appCap != nil {
+		computedPerms &= uint64(*appCap)
+	}
+
 	if computedPerms&permissions.Administrator != 0 {
 		return true
 	}
@@ -2515,16 +3041,30 @@ func (h *Handler) HandleAddMemberRole(w http.ResponseWriter, r *http.Request) {
 	roleID := chi.URLParam(r, "roleID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.AssignRoles) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need ASSIGN_ROLES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need ASSIGN_ROLES permission")
 		return
 	}
 
 	// Verify the role belongs to this guild and get its position.
 	var targetPos int
+	var managedBy, communityRoleID *string
 	err := h.Pool.QueryRow(r.Context(),
-		`SELECT position FROM roles WHERE id = $1 AND guild_id = $2`, roleID, guildID).Scan(&targetPos)
+		`SELECT position, managed_by, community_role_id FROM roles WHERE id = $1 AND guild_id = $2`, roleID, guildID,
+	).Scan(&targetPos, &managedBy, &communityRoleID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "role_not_found", "Role not found in this guild")
+		apiutil.WriteCode(w, apierrors.RoleNotFound, "Role not found in this guild")
+		return
+	}
+	if managedBy != nil {
+		apiutil.WriteError(w, http.StatusForbidden, "managed_role", "Managed roles can only be held by their app")
+		return
+	}
+	if communityRoleID != nil {
+		apiutil.WriteError(w, http.StatusForbidden, "community_role", "Community roles are assigned by the community")
+		return
+	}
+	if _, ok := h.appGrant(r.Context(), guildID, userID); ok && memberID == userID {
+		apiutil.WriteError(w, http.StatusForbidden, "app_sandbox", "Apps cannot change their own roles")
 		return
 	}
 
@@ -2542,7 +3082,7 @@ func (h *Handler) HandleAddMemberRole(w http.ResponseWriter, r *http.Request) {
 	err = h.Pool.QueryRow(r.Context(),
 		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`, guildID, memberID).Scan(&exists)
 	if err != nil || !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "member_not_found", "Member not found in this guild")
+		apiutil.WriteCode(w, apierrors.MemberNotFound, "Member not found in this guild")
 		return
 	}
 
@@ -2550,7 +3090,7 @@ func (h *Handler) HandleAddMemberRole(w http.ResponseWriter, r *http.Request) {
 		`INSERT INTO member_roles (guild_id, user_id, role_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
 		guildID, memberID, roleID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to add role")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to add role")
 		return
 	}
 
@@ -2578,7 +3118,7 @@ func (h *Handler) HandleRemoveMemberRole(w http.ResponseWriter, r *http.Request)
 	if h.FedProxy != nil {
 		var instanceID *string
 		if err := h.Pool.QueryRow(r.Context(), `SELECT instance_id FROM guilds WHERE id = $1`, guildID).Scan(&instanceID); err != nil && err != pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up guild")
 			return
 		}
 		if h.FedProxy.ProxyToHomeInstance(w, r, guildID, instanceID, "member_role_remove", userID, map[string]string{"member_id": memberID, "role_id": roleID}) {
@@ -2587,7 +3127,15 @@ func (h *Handler) HandleRemoveMemberRole(w http.ResponseWriter, r *http.Request)
 	}
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.AssignRoles) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need ASSIGN_ROLES permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need ASSIGN_ROLES permission")
+		return
+	}
+	if h.roleManager(r.Context(), guildID, roleID) != nil {
+		apiutil.WriteError(w, http.StatusForbidden, "managed_role", "This role belongs to an installed app; uninstall the app to remove it")
+		return
+	}
+	if h.isCommunityRole(r.Context(), guildID, roleID) {
+		apiutil.WriteError(w, http.StatusForbidden, "community_role", "Community roles are assigned by the community")
 		return
 	}
 
@@ -2609,7 +3157,7 @@ func (h *Handler) HandleRemoveMemberRole(w http.ResponseWriter, r *http.Request)
 		`DELETE FROM member_roles WHERE guild_id = $1 AND user_id = $2 AND role_id = $3`,
 		guildID, memberID, roleID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to remove role")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to remove role")
 		return
 	}
 	if tag.RowsAffected() == 0 {
@@ -2644,7 +3192,7 @@ func (h *Handler) HandleGetMemberRoles(w http.ResponseWriter, r *http.Request) {
 		 ORDER BY r.position DESC`,
 		guildID, memberID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get member roles")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get member roles")
 		return
 	}
 	defer rows.Close()
@@ -2656,7 +3204,7 @@ func (h *Handler) HandleGetMemberRoles(w http.ResponseWriter, r *http.Request) {
 			&role.ID, &role.GuildID, &role.Name, &role.Color, &role.Hoist, &role.Mentionable,
 			&role.Position, &role.PermissionsAllow, &role.PermissionsDeny, &role.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read roles")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read roles")
 			return
 		}
 		roles = append(roles, role)
@@ -2705,7 +3253,7 @@ func (h *Handler) HandleGetGuildPruneCount(w http.ResponseWriter, r *http.Reques
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.KickMembers) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need KICK_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need KICK_MEMBERS permission")
 		return
 	}
 
@@ -2741,7 +3289,7 @@ func (h *Handler) HandleGuildPrune(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.KickMembers) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need KICK_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need KICK_MEMBERS permission")
 		return
 	}
 
@@ -2788,7 +3336,7 @@ func (h *Handler) HandleCloneChannel(w http.ResponseWriter, r *http.Request) {
 	channelID := chi.URLParam(r, "channelID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
 		return
 	}
 
@@ -2811,7 +3359,7 @@ func (h *Handler) HandleCloneChannel(w http.ResponseWriter, r *http.Request) {
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found in this guild")
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "Channel not found in this guild")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to fetch channel", err)
@@ -2936,7 +3484,7 @@ func (h *Handler) HandleGetServerGuide(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -2978,7 +3526,7 @@ func (h *Handler) HandleUpdateServerGuide(w http.ResponseWriter, r *http.Request
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
 		return
 	}
 
@@ -3062,7 +3610,7 @@ func (h *Handler) HandleBumpGuild(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -3072,7 +3620,7 @@ func (h *Handler) HandleBumpGuild(w http.ResponseWriter, r *http.Request) {
 		`SELECT discoverable FROM guilds WHERE id = $1`, guildID,
 	).Scan(&discoverable)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 	if !discoverable {
@@ -3129,7 +3677,7 @@ func (h *Handler) HandleGetBumpStatus(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -3194,7 +3742,7 @@ func (h *Handler) HandleGetMyPermissions(w http.ResponseWriter, r *http.Request)
 	if err := h.Pool.QueryRow(r.Context(),
 		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
 		guildID, userID).Scan(&exists); err != nil || !exists {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -3202,7 +3750,7 @@ func (h *Handler) HandleGetMyPermissions(w http.ResponseWriter, r *http.Request)
 	var ownerID string
 	if err := h.Pool.QueryRow(r.Context(),
 		`SELECT owner_id FROM guilds WHERE id = $1`, guildID).Scan(&ownerID); err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 	if userID == ownerID {
@@ -3222,9 +3770,13 @@ func (h *Handler) HandleGetMyPermissions(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get guild default permissions.
+	// Get guild default permissions, and the grant if the user is an installed app.
 	var defaultPerms int64
-	h.Pool.QueryRow(r.Context(), `SELECT default_permissions FROM guilds WHERE id = $1`, guildID).Scan(&defaultPerms)
+	var appCap *int64
+	h.Pool.QueryRow(r.Context(),
+		`SELECT g.default_permissions, bi.permissions
+		 FROM guilds g LEFT JOIN bot_installs bi ON bi.guild_id = g.id AND bi.bot_id = $2
+		 WHERE g.id = $1`, guildID, userID).Scan(&defaultPerms, &appCap)
 	computedPerms := uint64(defaultPerms)
 
 	// Apply member's role permissions.
@@ -3246,6 +3798,10 @@ func (h *Handler) HandleGetMyPermissions(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	if appCap != nil {
+		computedPerms &= uint64(*appCap)
+	}
+
 	if computedPerms&permissions.Administrator != 0 {
 		computedPerms = permissions.AllPermissions
 	}
@@ -3262,7 +3818,7 @@ func (h *Handler) HandleGetGuildGallery(w http.ResponseWriter, r *http.Request)
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -3300,7 +3856,7 @@ func (h *Handler) HandleGetGuildGallery(w http.ResponseWriter, r *http.Request)
 
 	rows, err := h.Pool.Query(r.Context(), baseSQL, args...)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to query gallery")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to query gallery")
 		return
 	}
 	defer rows.Close()
@@ -3313,7 +3869,7 @@ func (h *Handler) HandleGetGuildGallery(w http.ResponseWriter, r *http.Request)
 			&a.Width, &a.Height, &a.DurationSeconds, &a.S3Bucket, &a.S3Key, &a.Blurhash,
 			&a.AltText, &a.NSFW, &a.Description, &a.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read gallery data")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read gallery data")
 			return
 		}
 		attachments = append(attachments, a)
@@ -3329,7 +3885,7 @@ func (h *Handler) HandleGetMediaTags(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -3338,7 +3894,7 @@ func (h *Handler) HandleGetMediaTags(w http.ResponseWriter, r *http.Request) {
 		guildID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get media tags")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get media tags")
 		return
 	}
 	defer rows.Close()
@@ -3347,7 +3903,7 @@ func (h *Handler) HandleGetMediaTags(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var t models.MediaTag
 		if err := rows.Scan(&t.ID, &t.Name, &t.GuildID, &t.CreatedBy, &t.CreatedAt); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read media tags")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read media tags")
 			return
 		}
 		tags = append(tags, t)
@@ -3363,7 +3919,7 @@ func (h *Handler) HandleCreateMediaTag(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -3393,7 +3949,7 @@ func (h *Handler) HandleCreateMediaTag(w http.ResponseWriter, r *http.Request) {
 		tag.ID, tag.Name, tag.GuildID, tag.CreatedBy, tag.CreatedAt,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to create media tag")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to create media tag")
 		return
 	}
 
@@ -3408,7 +3964,7 @@ func (h *Handler) HandleDeleteMediaTag(w http.ResponseWriter, r *http.Request) {
 	tagID := chi.URLParam(r, "tagID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -3416,7 +3972,7 @@ func (h *Handler) HandleDeleteMediaTag(w http.ResponseWriter, r *http.Request) {
 		`DELETE FROM media_tags WHERE id = $1 AND guild_id = $2`, tagID, guildID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete media tag")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete media tag")
 		return
 	}
 	if result.RowsAffected() == 0 {
@@ -2,11 +2,18 @@ package guilds
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/importer"
+	"github.com/amityvox/amityvox/internal/models"
 )
 
 func TestWriteJSON(t *testing.T) {
@@ -231,3 +238,330 @@ func TestWriteJSON_NilData(t *testing.T) {
 		t.Error("expected 'data' key even with nil data")
 	}
 }
+
+func TestValidateImportRequest(t *testing.T) {
+	archive := "01HARCHIVE"
+	token := "bot-token"
+	empty := ""
+
+	tests := []struct {
+		name string
+		req  createImportRequest
+		want string
+	}{
+		{"archive", createImportRequest{Source: "discord", ArchiveID: &archive}, ""},
+		{"bot token", createImportRequest{Source: "discord", BotToken: &token,
+			Options: importer.Options{DiscordGuildID: "123"}}, ""},
+		{"unknown source", createImportRequest{Source: "irc", ArchiveID: &archive}, "invalid_source"},
+		{"neither", createImportRequest{Source: "discord", ArchiveID: &empty}, "invalid_import"},
+		{"both", createImportRequest{Source: "discord", ArchiveID: &archive, BotToken: &token,
+			Options: importer.Options{DiscordGuildID: "123"}}, "invalid_import"},
+		{"token without server", createImportRequest{Source: "discord", BotToken: &token}, "invalid_import"},
+		{"matrix export", createImportRequest{Source: "matrix", ArchiveID: &archive,
+			Options: importer.Options{TargetChannelID: "01HCHAN", AuthorMode: "stub"}}, ""},
+		{"matrix without target", createImportRequest{Source: "matrix", ArchiveID: &archive}, "invalid_import"},
+		{"matrix api over http", createImportRequest{Source: "matrix", AccessToken: &token,
+			Options: importer.Options{TargetChannelID: "01HCHAN", MatrixRoomID: "!r:example.org",
+				MatrixHomeserver: "http://example.org"}}, "invalid_import"},
+		{"slack dry run", createImportRequest{Source: "slack", ArchiveID: &archive,
+			Options: importer.Options{DryRun: true}}, ""},
+		{"slack with token", createImportRequest{Source: "slack", ArchiveID: &archive, BotToken: &token}, "invalid_import"},
+		{"mbox without archive", createImportRequest{Source: "mbox"}, "invalid_import"},
+		{"bad author mode", createImportRequest{Source: "discord", ArchiveID: &archive,
+			Options: importer.Options{AuthorMode: "ghost"}}, "invalid_author_mode"},
+	}
+	for _, tt := range tests {
+		if code, _ := validateImportRequest(tt.req); code != tt.want {
+			t.Errorf("%s: code = %q, want %q", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestUniqueEmojiName(t *testing.T) {
+	taken := map[string]bool{"party": true, "party_2": true}
+
+	if got := uniqueEmojiName("wave", taken); got != "wave" {
+		t.Errorf("free name = %q, want wave", got)
+	}
+	if got := uniqueEmojiName("Party", taken); got != "Party_3" {
+		t.Errorf("taken name = %q, want Party_3", got)
+	}
+
+	long := strings.Repeat("a", maxEmojiName)
+	got := uniqueEmojiName(long, map[string]bool{long: true})
+	if len(got) != maxEmojiName || !strings.HasSuffix(got, "_2") {
+		t.Errorf("long name = %q, want %d characters ending _2", got, maxEmojiName)
+	}
+}
+
+func TestEmojiSlotFree(t *testing.T) {
+	q := models.EmojiQuota{MaxStatic: 2, MaxAnimated: 1, StaticUsed: 1, AnimatedUsed: 1}
+	if !emojiSlotFree(q, false) {
+		t.Error("expected a free static slot")
+	}
+	if emojiSlotFree(q, true) {
+		t.Error("expected animated slots to be full")
+	}
+}
+
+func TestCachedMemberPage_RoundTrip(t *testing.T) {
+	domain := "remote.example"
+	next := apiutil.EncodeCursor("2026-01-01T00:00:00Z", "u2")
+	page := cachedMemberPage{
+		Members: []models.GuildMember{{
+			GuildID: "g1", UserID: "u1", Roles: []string{"r1"},
+			User: &models.User{ID: "u1", Username: "alice", InstanceDomain: &domain},
+		}},
+		NextCursor: &next,
+	}
+
+	raw, err := json.Marshal(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got cachedMemberPage
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cached page must serve the same response as a fresh one.
+	fresh, cached := httptest.NewRecorder(), httptest.NewRecorder()
+	apiutil.WritePage(fresh, page.Members, page.NextCursor)
+	apiutil.WritePage(cached, got.Members, got.NextCursor)
+	if fresh.Body.String() != cached.Body.String() {
+		t.Errorf("cached page response differs:\n fresh  %s\n cached %s", fresh.Body, cached.Body)
+	}
+}
+
+func TestMoveID(t *testing.T) {
+	ids := []string{"a", "b", "c"}
+	tests := []struct {
+		id    string
+		index int
+		want  string
+	}{
+		{"c", 0, "cab"},
+		{"a", 2, "bca"},
+		{"b", 99, "acb"},
+		{"a", -3, "abc"},
+		{"d", 1, "adbc"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(moveID(ids, tt.id, tt.index), ""); got != tt.want {
+			t.Errorf("moveID(%q, %d) = %q, want %q", tt.id, tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestMoveChannelInLayout(t *testing.T) {
+	cat := "cat1"
+	// Duplicate and negative positions, as older clients could leave them.
+	layout := []channelPosition{
+		{ID: "general", Position: -1},
+		{ID: "rules", Position: -1},
+		{ID: "voice", CategoryID: &cat, Position: 3},
+		{ID: "music", CategoryID: &cat, Position: 3},
+	}
+
+	got := moveChannelInLayout(layout, "rules", &cat, 1)
+	want := []string{"general:0", "cat1/voice:0", "cat1/rules:1", "cat1/music:2"}
+	if len(got) != len(want) {
+		t.Fatalf("layout has %d channels, want %d", len(got), len(want))
+	}
+	for i, c := range got {
+		s := fmt.Sprintf("%s:%d", c.ID, c.Position)
+		if c.CategoryID != nil {
+			s = *c.CategoryID + "/" + s
+		}
+		if s != want[i] {
+			t.Errorf("layout[%d] = %s, want %s", i, s, want[i])
+		}
+	}
+
+	// Moving out of every category lands among the uncategorized channels.
+	got = moveChannelInLayout(layout, "music", nil, 0)
+	if got[0].ID != "music" || got[0].CategoryID != nil || got[0].Position != 0 {
+		t.Errorf("first channel = %+v, want music at 0 without category", got[0])
+	}
+}
+
+func TestValidateTemplateData(t *testing.T) {
+	valid := templateData{
+		Roles:      []templateRole{{Name: "Moderator", Position: 1}},
+		Categories: []templateCategory{{Name: "Info"}},
+		Channels: []templateChannel{
+			{Name: "rules", ChannelType: "announcement", CategoryName: "Info"},
+			{Name: "Lounge", ChannelType: "voice"},
+		},
+	}
+	if msg := validateTemplateData(valid); msg != "" {
+		t.Fatalf("valid template rejected: %s", msg)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*templateData)
+	}{
+		{"dm channel", func(d *templateData) { d.Channels[0].ChannelType = "dm" }},
+		{"unnamed channel", func(d *templateData) { d.Channels[1].Name = "" }},
+		{"unknown category", func(d *templateData) { d.Channels[0].CategoryName = "Missing" }},
+		{"unknown permission bits", func(d *templateData) { d.Roles[0].PermissionsAllow = 1 << 60 }},
+		{"negative slowmode", func(d *templateData) { d.Channels[0].SlowmodeSeconds = -1 }},
+		{"too many roles", func(d *templateData) { d.Roles = make([]templateRole, maxTemplateRoles+1) }},
+	}
+	for _, tt := range tests {
+		d := valid
+		d.Roles = append([]templateRole(nil), valid.Roles...)
+		d.Channels = append([]templateChannel(nil), valid.Channels...)
+		tt.modify(&d)
+		if validateTemplateData(d) == "" {
+			t.Errorf("%s: expected template to be rejected", tt.name)
+		}
+	}
+}
+
+func TestWithEveryoneRole(t *testing.T) {
+	roles := withEveryoneRole([]templateRole{{Name: "Admin", Position: 0}}, 42)
+	if len(roles) != 2 || roles[0].Name != "@everyone" || roles[0].PermissionsAllow != 42 || roles[0].Position != 0 {
+		t.Fatalf("expected @everyone first, got %+v", roles)
+	}
+	if roles[1].Position != 1 {
+		t.Errorf("Admin position = %d, want 1", roles[1].Position)
+	}
+
+	existing := []templateRole{{Name: "@everyone", PermissionsAllow: 7}}
+	if got := withEveryoneRole(existing, 42); len(got) != 1 || got[0].PermissionsAllow != 7 {
+		t.Errorf("existing @everyone should be kept, got %+v", got)
+	}
+}
+
+func TestValidateRestoreWindow(t *testing.T) {
+	for _, days := range []int{0, 1, 7, 30} {
+		if msg := validateRestoreWindow(days); msg != "" {
+			t.Errorf("validateRestoreWindow(%d) = %q, want valid", days, msg)
+		}
+	}
+	for _, days := range []int{-1, 31, 365} {
+		if validateRestoreWindow(days) == "" {
+			t.Errorf("validateRestoreWindow(%d) accepted", days)
+		}
+	}
+}
+
+func TestValidateBroadcastContent(t *testing.T) {
+	for _, content := range []string{"Server maintenance tonight", strings.Repeat("a", maxBroadcastLength)} {
+		if msg := validateBroadcastContent(content); msg != "" {
+			t.Errorf("validateBroadcastContent(%d chars) = %q, want valid", len(content), msg)
+		}
+	}
+	for _, content := range []string{"", "  \n", strings.Repeat("a", maxBroadcastLength+1)} {
+		if validateBroadcastContent(content) == "" {
+			t.Errorf("validateBroadcastContent(%q) accepted", content)
+		}
+	}
+}
+
+func TestPublicChannelTemplate(t *testing.T) {
+	warning := "spoilers"
+	page := publicChannelPage{
+		Guild:   publicGuild{ID: "g1", Ref: "cool-guild", Name: "Cool Guild"},
+		Channel: publicChannel{ID: "c1", Name: "announcements"},
+		Messages: []publicMessage{
+			{ID: "m2", Author: "alice", Content: "<script>alert(1)</script>", CreatedAt: time.Now()},
+			{ID: "m1", Author: "bob", Content: "hidden plot", ContentWarning: &warning, Attachments: 2, CreatedAt: time.Now()},
+		},
+		Older: "m1",
+	}
+
+	var buf strings.Builder
+	if err := publicChannelTemplate.Execute(&buf, page); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "<script>") {
+		t.Error("message content was not escaped")
+	}
+	if strings.Contains(out, "hidden plot") || !strings.Contains(out, "Content warning: spoilers") {
+		t.Error("content behind a warning was shown")
+	}
+	if !strings.Contains(out, `href="/api/v1/public/guilds/cool-guild"`) || !strings.Contains(out, "?before=m1") {
+		t.Error("expected links to the guild page and to older messages")
+	}
+}
+
+func TestResolvePublicMentions(t *testing.T) {
+	const (
+		user    = "01HZZZZZZZZZZZZZZZZZZZZZU1"
+		role    = "01HZZZZZZZZZZZZZZZZZZZZZR1"
+		channel = "01HZZZZZZZZZZZZZZZZZZZZZC1"
+		other   = "01HZZZZZZZZZZZZZZZZZZZZZX1"
+	)
+	names := map[string]string{"@" + user: "@alice", "@&" + role: "@mods", "#" + channel: "#news"}
+	tests := []struct {
+		content, want string
+	}{
+		{"hi <@" + user + ">", "hi @alice"},
+		{"ping <@&" + role + "> in <#" + channel + ">", "ping @mods in #news"},
+		{"<@" + other + "> <@&" + other + "> <#" + other + ">", "@unknown-user @unknown-role #unknown-channel"},
+		{"`<@" + user + ">`", "`<@" + user + ">`"},
+		{"no mentions", "no mentions"},
+	}
+	for _, tt := range tests {
+		if got := resolvePublicMentions(tt.content, names); got != tt.want {
+			t.Errorf("resolvePublicMentions(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestNicknameReset(t *testing.T) {
+	str := func(s string) *string { return &s }
+	pattern := regexp.MustCompile(`(?i)discord\.gg`)
+
+	tests := []struct {
+		name     string
+		nickname *string
+		display  string
+		pattern  *regexp.Regexp
+		dehoist  bool
+		changed  bool
+		to       *string
+	}{
+		{"matching nickname cleared", str("join discord.gg/x"), "sam", pattern, false, true, nil},
+		{"other nickname kept", str("Sammy"), "sam", pattern, false, false, nil},
+		{"no nickname kept", nil, "discord.gg", pattern, false, false, nil},
+		{"hoisted nickname dehoisted", str("!Sammy"), "sam", nil, true, true, str("Sammy")},
+		{"hoisted display name nicknamed", nil, "...sam", nil, true, true, str("sam")},
+		{"cleared onto hoisted name", str("discord.gg"), "!sam", pattern, true, true, str("sam")},
+		{"clean name kept", nil, "sam", nil, true, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, changed := nicknameReset("u1", tt.nickname, tt.display, tt.pattern, tt.dehoist)
+			if changed != tt.changed {
+				t.Fatalf("changed = %v, want %v", changed, tt.changed)
+			}
+			if !changed {
+				return
+			}
+			if (c.To == nil) != (tt.to == nil) || (c.To != nil && *c.To != *tt.to) {
+				t.Errorf("to = %v, want %v", c.To, tt.to)
+			}
+		})
+	}
+}
+
+func TestNormalizeJoinOriginDomains(t *testing.T) {
+	got, err := normalizeJoinOriginDomains([]string{" Peer.Example ", "https://chat.other.example/", "peer.example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"peer.example", "chat.other.example"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := normalizeJoinOriginDomains([]string{"not a domain"}); err == nil {
+		t.Error("expected an error for an invalid domain")
+	}
+	if _, err := normalizeJoinOriginDomains(make([]string, maxJoinOriginDomains+1)); err == nil {
+		t.Error("expected an error for too many domains")
+	}
+}
@@ -0,0 +1,257 @@
+// Guild import handlers.
+// An import recreates a guild exported from another platform — channels,
+// roles, emoji, pins, threads and message history — inside an existing
+// guild, or backfills one existing channel with a room's history. With
+// options.dry_run the job only reports what it would create, listing every
+// channel so the requester can choose which to import. The request only
+// queues a job; a background worker runs it and reports progress through
+// GUILD_IMPORT_PROGRESS events and the job record.
+// Mounted under /api/v1/guilds/{guildID}/imports.
+package guilds
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/importer"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+type createImportRequest struct {
+	Source      string           `json:"source"`
+	ArchiveID   *string          `json:"archive_id"`
+	BotToken    *string          `json:"bot_token"`    // Discord
+	AccessToken *string          `json:"access_token"` // Matrix
+	Options     importer.Options `json:"options"`
+}
+
+func nonEmpty(s *string) bool { return s != nil && *s != "" }
+
+// validateImportRequest checks a new import, returning an error code and
+// message for the first problem found.
+func validateImportRequest(req createImportRequest) (string, string) {
+	if !importer.ValidSources[req.Source] {
+		return "invalid_source", "Unknown import source"
+	}
+	hasArchive := nonEmpty(req.ArchiveID)
+	switch req.Source {
+	case importer.SourceDiscord:
+		if hasArchive == nonEmpty(req.BotToken) || req.AccessToken != nil {
+			return "invalid_import", "Provide either archive_id or bot_token"
+		}
+		if nonEmpty(req.BotToken) && req.Options.DiscordGuildID == "" {
+			return "invalid_import", "options.discord_guild_id is required when importing with a bot token"
+		}
+	case importer.SourceMatrix:
+		if hasArchive == nonEmpty(req.AccessToken) || req.BotToken != nil {
+			return "invalid_import", "Provide either archive_id or access_token"
+		}
+		if req.Options.TargetChannelID == "" {
+			return "invalid_import", "options.target_channel_id is required for Matrix imports"
+		}
+		if nonEmpty(req.AccessToken) && (req.Options.MatrixRoomID == "" ||
+			!strings.HasPrefix(req.Options.MatrixHomeserver, "https://")) {
+			return "invalid_import", "options.matrix_room_id and an https options.matrix_homeserver are required when importing with an access token"
+		}
+	case importer.SourceSlack, importer.SourceMbox:
+		if !hasArchive || req.BotToken != nil || req.AccessToken != nil {
+			return "invalid_import", "archive_id is required and no token is accepted for this source"
+		}
+	}
+	switch req.Options.AuthorMode {
+	case "", importer.AuthorMasquerade, importer.AuthorStub:
+	default:
+		return "invalid_author_mode", "author_mode must be masquerade or stub"
+	}
+	if len(req.Options.Channels) > 500 {
+		return "invalid_import", "At most 500 channels may be selected"
+	}
+	return "", ""
+}
+
+const guildImportColumns = `id, guild_id, requested_by, source, status, options, archive_id,
+	progress, error, created_at, started_at, completed_at`
+
+func scanGuildImport(row pgx.Row) (models.GuildImport, error) {
+	var imp models.GuildImport
+	err := row.Scan(&imp.ID, &imp.GuildID, &imp.RequestedBy, &imp.Source, &imp.Status,
+		&imp.Options, &imp.ArchiveID, &imp.Progress, &imp.Error,
+		&imp.CreatedAt, &imp.StartedAt, &imp.CompletedAt)
+	return imp, err
+}
+
+// HandleCreateGuildImport queues an import into the guild. The export is
+// either a previously uploaded archive or read live with the source
+// platform's token; the token is kept only until the job finishes. Only one
+// import may be pending or running per guild.
+// POST /api/v1/guilds/{guildID}/imports
+func (h *Handler) HandleCreateGuildImport(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.Administrator) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need ADMINISTRATOR permission")
+		return
+	}
+
+	var req createImportRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Source = strings.ToLower(strings.TrimSpace(req.Source))
+	if code, msg := validateImportRequest(req); code != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, code, msg)
+		return
+	}
+
+	if target := req.Options.TargetChannelID; target != "" {
+		var channelType string
+		err := h.Pool.QueryRow(r.Context(),
+			`SELECT channel_type FROM channels WHERE id = $1 AND guild_id = $2`,
+			target, guildID).Scan(&channelType)
+		if err != nil || (channelType != "text" && channelType != "announcement") {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_target_channel", "target_channel_id must be a text or announcement channel in this guild")
+			return
+		}
+	}
+
+	if nonEmpty(req.ArchiveID) {
+		var uploaderID *string
+		err := h.Pool.QueryRow(r.Context(),
+			`SELECT uploader_id FROM attachments WHERE id = $1 AND message_id IS NULL`,
+			*req.ArchiveID).Scan(&uploaderID)
+		if err != nil || uploaderID == nil || *uploaderID != userID {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_archive", "archive_id must be an unattached file you uploaded")
+			return
+		}
+	} else {
+		req.ArchiveID = nil
+	}
+
+	credentials := req.BotToken
+	if req.Source == importer.SourceMatrix {
+		credentials = req.AccessToken
+	}
+
+	options, _ := json.Marshal(req.Options)
+	imp, err := scanGuildImport(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO guild_imports (id, guild_id, requested_by, source, options, archive_id, credentials, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+		 RETURNING `+guildImportColumns,
+		models.NewULID().String(), guildID, userID, req.Source, options, req.ArchiveID, credentials))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			apiutil.WriteError(w, http.StatusConflict, "import_in_progress", "This guild already has an import pending or running")
+			return
+		}
+		apiutil.InternalError(w, h.Logger, "Failed to create import", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "guild_import_create", "guild_import", imp.ID, nil)
+
+	apiutil.WriteJSON(w, http.StatusAccepted, imp)
+}
+
+// HandleGetGuildImports lists the guild's imports, newest first.
+// GET /api/v1/guilds/{guildID}/imports
+func (h *Handler) HandleGetGuildImports(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.Administrator) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need ADMINISTRATOR permission")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+guildImportColumns+` FROM guild_imports
+		 WHERE guild_id = $1 ORDER BY created_at DESC LIMIT 50`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get imports", err)
+		return
+	}
+	defer rows.Close()
+
+	imports := make([]models.GuildImport, 0)
+	for rows.Next() {
+		imp, err := scanGuildImport(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read imports", err)
+			return
+		}
+		imports = append(imports, imp)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, imports)
+}
+
+// HandleGetGuildImport returns one import with its current progress.
+// GET /api/v1/guilds/{guildID}/imports/{importID}
+func (h *Handler) HandleGetGuildImport(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	importID := chi.URLParam(r, "importID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.Administrator) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need ADMINISTRATOR permission")
+		return
+	}
+
+	imp, err := scanGuildImport(h.Pool.QueryRow(r.Context(),
+		`SELECT `+guildImportColumns+` FROM guild_imports WHERE id = $1 AND guild_id = $2`,
+		importID, guildID))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "import_not_found", "Import not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get import", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, imp)
+}
+
+// HandleCancelGuildImport cancels a pending or running import. A running job
+// stops at its next progress checkpoint; anything already imported stays.
+// DELETE /api/v1/guilds/{guildID}/imports/{importID}
+func (h *Handler) HandleCancelGuildImport(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	importID := chi.URLParam(r, "importID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.Administrator) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need ADMINISTRATOR permission")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE guild_imports SET status = 'cancelled', credentials = NULL,
+		        completed_at = now(), updated_at = now()
+		 WHERE id = $1 AND guild_id = $2 AND status IN ('pending', 'running')`,
+		importID, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to cancel import", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "import_not_found", "No pending or running import with that ID")
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "guild_import_cancel", "guild_import", importID, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,155 @@
+// Guild invite pause handlers.
+// Pausing stops every invite to the guild from being redeemed until the pause
+// ends, without deleting the invites. Mounted under
+// /api/v1/guilds/{guildID}/invites/pause.
+package guilds
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxInvitePause is the longest a pause may be set for. Every pause expires
+// so a forgotten one cannot close the guild for good.
+const maxInvitePause = 7 * 24 * time.Hour
+
+// invitePause is the invite pause state of a guild.
+type invitePause struct {
+	Paused      bool       `json:"paused"`
+	PausedUntil *time.Time `json:"paused_until"`
+	PausedBy    *string    `json:"paused_by"`
+}
+
+type pauseInvitesRequest struct {
+	DurationSeconds int     `json:"duration_seconds"`
+	Reason          *string `json:"reason"`
+}
+
+// HandleGetInvitePause returns whether the guild's invites are paused.
+// GET /api/v1/guilds/{guildID}/invites/pause
+func (h *Handler) HandleGetInvitePause(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var pause invitePause
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT invites_paused_until, invites_paused_by FROM guilds
+		 WHERE id = $1 AND (invites_paused_until IS NULL OR invites_paused_until > now())`, guildID,
+	).Scan(&pause.PausedUntil, &pause.PausedBy)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to get invite pause", err)
+		return
+	}
+	pause.Paused = pause.PausedUntil != nil
+	if !pause.Paused {
+		pause.PausedBy = nil
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, pause)
+}
+
+// HandlePauseInvites pauses every invite to the guild for duration_seconds,
+// up to seven days. Pausing again replaces the end time.
+// PUT /api/v1/guilds/{guildID}/invites/pause
+func (h *Handler) HandlePauseInvites(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var req pauseInvitesRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	duration := time.Duration(req.DurationSeconds) * time.Second
+	if duration < time.Minute || duration > maxInvitePause {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_duration",
+			"duration_seconds must be between 60 and 604800")
+		return
+	}
+	if req.Reason != nil {
+		trimmed := strings.TrimSpace(*req.Reason)
+		if len(trimmed) > 512 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_reason", "Reason must be at most 512 characters")
+			return
+		}
+		if trimmed == "" {
+			req.Reason = nil
+		} else {
+			req.Reason = &trimmed
+		}
+	}
+
+	pause := invitePause{Paused: true, PausedBy: &userID}
+	err := h.Pool.QueryRow(r.Context(),
+		`UPDATE guilds SET invites_paused_until = $2, invites_paused_by = $3
+		 WHERE id = $1 RETURNING invites_paused_until`,
+		guildID, time.Now().Add(duration), userID,
+	).Scan(&pause.PausedUntil)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to pause invites", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "invites_pause", "guild", guildID, req.Reason)
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildUpdate, "GUILD_UPDATE", guildID, map[string]interface{}{
+		"id":                   guildID,
+		"invites_paused_until": pause.PausedUntil,
+	})
+
+	apiutil.WriteJSON(w, http.StatusOK, pause)
+}
+
+// HandleResumeInvites lifts the guild's invite pause early.
+// DELETE /api/v1/guilds/{guildID}/invites/pause
+func (h *Handler) HandleResumeInvites(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE guilds SET invites_paused_until = NULL, invites_paused_by = NULL
+		 WHERE id = $1 AND invites_paused_until IS NOT NULL`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to resume invites", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "invites_not_paused", "Invites to this guild are not paused")
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "invites_resume", "guild", guildID, nil)
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildUpdate, "GUILD_UPDATE", guildID, map[string]interface{}{
+		"id":                   guildID,
+		"invites_paused_until": nil,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,134 @@
+// Guild join origin policy handlers.
+// The policy limits which instances new members may come from: any, only
+// this one, or an allowlist of peer domains, with blocked domains refused in
+// every mode. It is enforced when local users join and on the federation join
+// endpoints; existing members are unaffected. Mounted under
+// /api/v1/guilds/{guildID}/join-origin-policy.
+package guilds
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/linksafety"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxJoinOriginDomains bounds each domain list of a join origin policy.
+const maxJoinOriginDomains = 100
+
+type updateJoinOriginPolicyRequest struct {
+	Mode           *string   `json:"mode"`
+	AllowedDomains *[]string `json:"allowed_domains"`
+	BlockedDomains *[]string `json:"blocked_domains"`
+}
+
+// HandleGetJoinOriginPolicy returns the guild's join origin policy, or an
+// open one if it has never been set.
+// GET /api/v1/guilds/{guildID}/join-origin-policy
+func (h *Handler) HandleGetJoinOriginPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	policy := models.GuildJoinOriginPolicy{
+		GuildID: guildID, Mode: models.JoinOriginOpen,
+		AllowedDomains: []string{}, BlockedDomains: []string{},
+	}
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT mode, allowed_domains, blocked_domains, updated_at
+		 FROM guild_join_origin_policy WHERE guild_id = $1`, guildID,
+	).Scan(&policy.Mode, &policy.AllowedDomains, &policy.BlockedDomains, &policy.UpdatedAt)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to get join origin policy", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, policy)
+}
+
+// HandleUpdateJoinOriginPolicy changes the guild's join origin policy.
+// PATCH /api/v1/guilds/{guildID}/join-origin-policy
+func (h *Handler) HandleUpdateJoinOriginPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var req updateJoinOriginPolicyRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Mode != nil && !apiutil.ValidJoinOriginMode(*req.Mode) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_mode",
+			"mode must be open, local_only or allowlist")
+		return
+	}
+	for _, list := range []*[]string{req.AllowedDomains, req.BlockedDomains} {
+		if list == nil {
+			continue
+		}
+		domains, err := normalizeJoinOriginDomains(*list)
+		if err != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_domain", err.Error())
+			return
+		}
+		*list = domains
+	}
+
+	policy := models.GuildJoinOriginPolicy{GuildID: guildID}
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO guild_join_origin_policy (guild_id, mode, allowed_domains, blocked_domains, updated_at)
+		 VALUES ($1, COALESCE($2, 'open'), COALESCE($3, '{}'::text[]), COALESCE($4, '{}'::text[]), now())
+		 ON CONFLICT (guild_id) DO UPDATE SET
+		     mode = COALESCE($2, guild_join_origin_policy.mode),
+		     allowed_domains = COALESCE($3, guild_join_origin_policy.allowed_domains),
+		     blocked_domains = COALESCE($4, guild_join_origin_policy.blocked_domains),
+		     updated_at = now()
+		 RETURNING mode, allowed_domains, blocked_domains, updated_at`,
+		guildID, req.Mode, req.AllowedDomains, req.BlockedDomains,
+	).Scan(&policy.Mode, &policy.AllowedDomains, &policy.BlockedDomains, &policy.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update join origin policy", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "JOIN_ORIGIN_POLICY_UPDATE", "guild", guildID, nil)
+	apiutil.WriteJSON(w, http.StatusOK, policy)
+}
+
+// normalizeJoinOriginDomains lowercases and validates a domain list,
+// dropping duplicates.
+func normalizeJoinOriginDomains(domains []string) ([]string, error) {
+	if len(domains) > maxJoinOriginDomains {
+		return nil, fmt.Errorf("at most %d domains may be listed", maxJoinOriginDomains)
+	}
+	out := make([]string, 0, len(domains))
+	seen := make(map[string]bool, len(domains))
+	for _, entry := range domains {
+		d, ok := linksafety.NormalizeDomain(entry)
+		if !ok {
+			return nil, fmt.Errorf("%q is not an instance domain such as chat.example", strings.TrimSpace(entry))
+		}
+		if !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package guilds
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// memberListTTL bounds how stale a cached member list can get through
+// changes that publish no event, such as profile edits synced by SCIM.
+const memberListTTL = 2 * time.Minute
+
+// cachedMemberPage is the first page of a guild's member list as cached.
+type cachedMemberPage struct {
+	Members    []models.GuildMember `json:"members"`
+	NextCursor *string              `json:"next_cursor"`
+}
+
+func memberListGenKey(guildID string) string {
+	return "guild_members:" + guildID
+}
+
+// memberListKey returns the cache key for the first page of a guild's member
+// list, or "" when the cache is unavailable.
+func (h *Handler) memberListKey(ctx context.Context, guildID string) string {
+	if h.Cache == nil {
+		return ""
+	}
+	gen, err := h.Cache.Generation(ctx, memberListGenKey(guildID))
+	if err != nil {
+		h.Logger.Debug("member list cache unavailable", slog.String("error", err.Error()))
+		return ""
+	}
+	return fmt.Sprintf("guild_members:%s:%d", guildID, gen)
+}
+
+// cachedMemberList returns the cached page under key, if there is one.
+func (h *Handler) cachedMemberList(ctx context.Context, key string) (cachedMemberPage, bool) {
+	var page cachedMemberPage
+	if key == "" {
+		return page, false
+	}
+	found, err := h.Cache.Get(ctx, key, &page)
+	if err != nil {
+		h.Logger.Debug("reading cached member list failed", slog.String("error", err.Error()))
+		return page, false
+	}
+	return page, found
+}
+
+func (h *Handler) storeMemberList(ctx context.Context, key string, page cachedMemberPage) {
+	if key == "" {
+		return
+	}
+	if err := h.Cache.Set(ctx, key, page, memberListTTL); err != nil {
+		h.Logger.Debug("caching member list failed", slog.String("error", err.Error()))
+	}
+}
+
+// invalidateMemberLists drops the cached member lists of the given guilds.
+func (h *Handler) invalidateMemberLists(ctx context.Context, guildIDs ...string) {
+	for _, guildID := range guildIDs {
+		if err := h.Cache.BumpGeneration(ctx, memberListGenKey(guildID)); err != nil {
+			h.Logger.Warn("invalidating member list cache failed",
+				slog.String("guild_id", guildID), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// memberListSubjects are the events that change what a guild's member list
+// shows.
+var memberListSubjects = []string{
+	events.SubjectGuildMemberAdd,
+	events.SubjectGuildMemberUpdate,
+	events.SubjectGuildMemberRemove,
+	events.SubjectGuildRoleDelete,
+	events.SubjectGuildBanAdd,
+	events.SubjectGuildDelete,
+}
+
+// StartMemberListInvalidation drops cached member lists as members join,
+// leave, change, or change their profiles. One API node handles each event.
+// Call this once during server startup.
+func (h *Handler) StartMemberListInvalidation() {
+	if h.EventBus == nil || h.Cache == nil {
+		return
+	}
+
+	for _, subject := range memberListSubjects {
+		if _, err := h.EventBus.QueueSubscribe(subject, "member-list-cache", func(event events.Event) {
+			if event.GuildID == "" {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			h.invalidateMemberLists(ctx, event.GuildID)
+		}); err != nil {
+			h.Logger.Error("failed to subscribe for member list invalidation",
+				slog.String("subject", subject), slog.String("error", err.Error()))
+		}
+	}
+
+	// Profile changes show in the member list of every guild the user is in.
+	if _, err := h.EventBus.QueueSubscribe(events.SubjectUserUpdate, "member-list-cache", func(event events.Event) {
+		if event.Type != "USER_UPDATE" || event.UserID == "" {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+
+		rows, err := h.Pool.Query(ctx,
+			`SELECT guild_id FROM guild_members WHERE user_id = $1`, event.UserID)
+		if err != nil {
+			h.Logger.Warn("listing guilds for member list invalidation failed", slog.String("error", err.Error()))
+			return
+		}
+		var guildIDs []string
+		for rows.Next() {
+			var id string
+			if rows.Scan(&id) == nil {
+				guildIDs = append(guildIDs, id)
+			}
+		}
+		rows.Close()
+		h.invalidateMemberLists(ctx, guildIDs...)
+	}); err != nil {
+		h.Logger.Error("failed to subscribe for member list invalidation",
+			slog.String("subject", events.SubjectUserUpdate), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,102 @@
+// Guild message restore window handlers.
+// While the window is set, deleted messages are soft-deleted: hidden from
+// members but kept for moderators to restore until it passes. Mounted under
+// /api/v1/guilds/{guildID}/message-restore.
+package guilds
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxMessageRestoreDays is the longest a guild may keep deleted messages.
+const maxMessageRestoreDays = 30
+
+// messageRestoreSettings is the guild's restore window. Zero deletes
+// messages immediately.
+type messageRestoreSettings struct {
+	GuildID    string `json:"guild_id"`
+	WindowDays int    `json:"window_days"`
+}
+
+// validateRestoreWindow returns an error message, or "" if days is usable.
+func validateRestoreWindow(days int) string {
+	if days < 0 || days > maxMessageRestoreDays {
+		return "window_days must be between 0 and 30"
+	}
+	return ""
+}
+
+// HandleGetMessageRestore returns the guild's message restore window.
+// GET /api/v1/guilds/{guildID}/message-restore
+func (h *Handler) HandleGetMessageRestore(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageMessages) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
+		return
+	}
+
+	settings := messageRestoreSettings{GuildID: guildID}
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT message_restore_days FROM guilds WHERE id = $1`, guildID).Scan(&settings.WindowDays)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get message restore window", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, settings)
+}
+
+// HandleUpdateMessageRestore sets the guild's message restore window.
+// Shortening it lets the purge worker remove messages that fall outside the
+// new window on its next run.
+// PATCH /api/v1/guilds/{guildID}/message-restore
+func (h *Handler) HandleUpdateMessageRestore(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var req struct {
+		WindowDays int `json:"window_days"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if msg := validateRestoreWindow(req.WindowDays); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_window", msg)
+		return
+	}
+
+	settings := messageRestoreSettings{GuildID: guildID}
+	err := h.Pool.QueryRow(r.Context(),
+		`UPDATE guilds SET message_restore_days = $2 WHERE id = $1 RETURNING message_restore_days`,
+		guildID, req.WindowDays).Scan(&settings.WindowDays)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update message restore window", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "MESSAGE_RESTORE_UPDATE", "guild", guildID, nil)
+	apiutil.WriteJSON(w, http.StatusOK, settings)
+}
@@ -0,0 +1,177 @@
+// Guild message source statistics.
+// Message volume is rolled up daily per channel and source (humans, bots,
+// webhooks and bridges) by the message-source-rollup job, so admins can
+// spot noisy integrations and cap them with per-source channel limits.
+// Mounted under /api/v1/guilds/{guildID}/message-sources.
+package guilds
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+const (
+	defaultMessageSourceDays = 30
+	maxMessageSourceDays     = 90
+
+	// How many of the busiest integrations the statistics list.
+	messageSourceTopIntegrations = 20
+)
+
+// messageSourceCounts are one day's, or a period's, messages per source.
+type messageSourceCounts struct {
+	Human   int64 `json:"human"`
+	Bot     int64 `json:"bot"`
+	Webhook int64 `json:"webhook"`
+	Bridge  int64 `json:"bridge"`
+}
+
+func (c *messageSourceCounts) add(source string, n int64) {
+	switch source {
+	case models.MessageSourceHuman:
+		c.Human += n
+	case models.MessageSourceBot:
+		c.Bot += n
+	case models.MessageSourceWebhook:
+		c.Webhook += n
+	case models.MessageSourceBridge:
+		c.Bridge += n
+	}
+}
+
+type messageSourceDay struct {
+	Day string `json:"day"`
+	messageSourceCounts
+}
+
+// messageSourceIntegration is one webhook, bot or bridge and how much it
+// posted over the period.
+type messageSourceIntegration struct {
+	Source   string `json:"source"`
+	SourceID string `json:"source_id"`
+	Name     string `json:"name"`
+	Messages int64  `json:"messages"`
+	Channels int    `json:"channels"`
+}
+
+type messageSourceStats struct {
+	Days         []messageSourceDay              `json:"days"`
+	Totals       messageSourceCounts             `json:"totals"`
+	Integrations []messageSourceIntegration      `json:"integrations"`
+	Limits       []models.ChannelSourceRateLimit `json:"limits"`
+}
+
+// HandleGetMessageSources returns the guild's daily message volume per
+// source over the last ?days= days (default 30, at most 90), its busiest
+// integrations and the per-source limits set on its channels.
+// GET /api/v1/guilds/{guildID}/message-sources
+func (h *Handler) HandleGetMessageSources(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	days := defaultMessageSourceDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxMessageSourceDays {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_days", "days must be between 1 and 90")
+			return
+		}
+		days = n
+	}
+
+	stats := messageSourceStats{
+		Days:         []messageSourceDay{},
+		Integrations: []messageSourceIntegration{},
+		Limits:       []models.ChannelSourceRateLimit{},
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT to_char(day, 'YYYY-MM-DD'), source, sum(messages)::bigint
+		 FROM message_source_daily
+		 WHERE guild_id = $1 AND day > (now() AT TIME ZONE 'UTC')::date - $2::int
+		 GROUP BY day, source
+		 ORDER BY day`, guildID, days)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get message sources", err)
+		return
+	}
+	for rows.Next() {
+		var day, source string
+		var n int64
+		if err := rows.Scan(&day, &source, &n); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read message sources", err)
+			return
+		}
+		if len(stats.Days) == 0 || stats.Days[len(stats.Days)-1].Day != day {
+			stats.Days = append(stats.Days, messageSourceDay{Day: day})
+		}
+		stats.Days[len(stats.Days)-1].add(source, n)
+		stats.Totals.add(source, n)
+	}
+	rows.Close()
+
+	// Webhooks are named by their webhook, bots and relaying bots by their
+	// user; built-in bridges record only their name.
+	rows, err = h.Pool.Query(r.Context(),
+		`SELECT d.source, d.source_id,
+		        COALESCE(wh.name, u.display_name, u.username, d.source_id),
+		        sum(d.messages)::bigint AS n, count(DISTINCT d.channel_id)
+		 FROM message_source_daily d
+		 LEFT JOIN webhooks wh ON d.source = 'webhook' AND wh.id = d.source_id
+		 LEFT JOIN users u ON d.source IN ('bot', 'bridge') AND u.id = d.source_id
+		 WHERE d.guild_id = $1 AND d.source <> 'human' AND d.source_id <> ''
+		   AND d.day > (now() AT TIME ZONE 'UTC')::date - $2::int
+		 GROUP BY d.source, d.source_id, wh.name, u.display_name, u.username
+		 ORDER BY n DESC, d.source_id
+		 LIMIT $3`, guildID, days, messageSourceTopIntegrations)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get integrations", err)
+		return
+	}
+	for rows.Next() {
+		var i messageSourceIntegration
+		if err := rows.Scan(&i.Source, &i.SourceID, &i.Name, &i.Messages, &i.Channels); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read integrations", err)
+			return
+		}
+		stats.Integrations = append(stats.Integrations, i)
+	}
+	rows.Close()
+
+	rows, err = h.Pool.Query(r.Context(),
+		`SELECT l.channel_id, l.source, l.max_messages, l.window_seconds, l.updated_at
+		 FROM channel_source_rate_limits l
+		 JOIN channels c ON c.id = l.channel_id
+		 WHERE c.guild_id = $1
+		 ORDER BY l.channel_id, l.source`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get source limits", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l models.ChannelSourceRateLimit
+		if err := rows.Scan(&l.ChannelID, &l.Source, &l.MaxMessages, &l.WindowSeconds, &l.UpdatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read source limits", err)
+			return
+		}
+		stats.Limits = append(stats.Limits, l)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, stats)
+}
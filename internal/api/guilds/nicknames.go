@@ -0,0 +1,207 @@
+package guilds
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/automod"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+const (
+	// maxNicknamePatternLength bounds the regular expression of a bulk
+	// nickname reset.
+	maxNicknamePatternLength = 256
+	// maxNicknameChangesListed caps the changes a bulk reset lists back.
+	maxNicknameChangesListed = 100
+)
+
+type resetNicknamesRequest struct {
+	Pattern string  `json:"pattern"`
+	Dehoist bool    `json:"dehoist"`
+	DryRun  bool    `json:"dry_run"`
+	Reason  *string `json:"reason"`
+}
+
+// nicknameChange is one member a bulk reset renames. To is nil when the
+// nickname is cleared.
+type nicknameChange struct {
+	UserID string  `json:"user_id"`
+	From   string  `json:"from"`
+	To     *string `json:"to"`
+}
+
+type resetNicknamesResult struct {
+	Count   int              `json:"count"`
+	DryRun  bool             `json:"dry_run"`
+	Changes []nicknameChange `json:"changes"`
+}
+
+// HandleResetNicknames renames members in bulk. Nicknames matching pattern, a
+// regular expression, are cleared; with dehoist, members whose shown name
+// starts with symbols get a nickname without them. The guild owner and
+// members at or above the caller's highest role are left alone. A dry run
+// reports what would change.
+// POST /api/v1/guilds/{guildID}/members/nicknames/reset
+func (h *Handler) HandleResetNicknames(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageNicknames) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_NICKNAMES permission")
+		return
+	}
+
+	var req resetNicknamesRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Pattern == "" && !req.Dehoist {
+		apiutil.WriteCode(w, apierrors.MissingFields, "pattern or dehoist is required")
+		return
+	}
+	var pattern *regexp.Regexp
+	if req.Pattern != "" {
+		if len(req.Pattern) > maxNicknamePatternLength {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_pattern",
+				fmt.Sprintf("pattern must be at most %d characters", maxNicknamePatternLength))
+			return
+		}
+		var err error
+		if pattern, err = regexp.Compile(req.Pattern); err != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_pattern", "pattern is not a valid regular expression")
+			return
+		}
+	}
+
+	owner := h.isGuildOwner(r.Context(), guildID, userID)
+	callerPos := h.getHighestRolePosition(r.Context(), guildID, userID)
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT gm.user_id, gm.nickname, COALESCE(u.display_name, u.username),
+		        COALESCE((SELECT MAX(ro.position) FROM member_roles mr JOIN roles ro ON ro.id = mr.role_id
+		                  WHERE mr.guild_id = gm.guild_id AND mr.user_id = gm.user_id), 0)
+		 FROM guild_members gm
+		 JOIN users u ON u.id = gm.user_id
+		 JOIN guilds g ON g.id = gm.guild_id
+		 WHERE gm.guild_id = $1 AND gm.user_id <> g.owner_id`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list members", err)
+		return
+	}
+	var changes []nicknameChange
+	for rows.Next() {
+		var (
+			memberID string
+			nickname *string
+			name     string
+			pos      int
+		)
+		if err := rows.Scan(&memberID, &nickname, &name, &pos); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read members", err)
+			return
+		}
+		if !owner && pos >= callerPos {
+			continue
+		}
+		if c, ok := nicknameReset(memberID, nickname, name, pattern, req.Dehoist); ok {
+			changes = append(changes, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read members", err)
+		return
+	}
+
+	result := resetNicknamesResult{Count: len(changes), DryRun: req.DryRun, Changes: []nicknameChange{}}
+	if len(changes) > maxNicknameChangesListed {
+		result.Changes = append(result.Changes, changes[:maxNicknameChangesListed]...)
+	} else {
+		result.Changes = append(result.Changes, changes...)
+	}
+	if req.DryRun || len(changes) == 0 {
+		apiutil.WriteJSON(w, http.StatusOK, result)
+		return
+	}
+
+	var updated []models.GuildMember
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		for _, c := range changes {
+			var m models.GuildMember
+			err := tx.QueryRow(r.Context(),
+				`UPDATE guild_members SET nickname = $3 WHERE guild_id = $1 AND user_id = $2
+				 RETURNING guild_id, user_id, nickname, avatar_id, joined_at, timeout_until, deaf, mute`,
+				guildID, c.UserID, c.To,
+			).Scan(&m.GuildID, &m.UserID, &m.Nickname, &m.AvatarID, &m.JoinedAt, &m.TimeoutUntil, &m.Deaf, &m.Mute)
+			if err == pgx.ErrNoRows {
+				continue // Left the guild meanwhile.
+			}
+			if err != nil {
+				return err
+			}
+			updated = append(updated, m)
+		}
+		return nil
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to reset nicknames", err)
+		return
+	}
+
+	reason := fmt.Sprintf("Bulk nickname reset: %d members", len(updated))
+	if req.Reason != nil && *req.Reason != "" {
+		reason += " — " + *req.Reason
+	}
+	h.logAudit(r.Context(), guildID, userID, "member_nickname_bulk_reset", "guild", guildID, &reason)
+	for _, m := range updated {
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", guildID, m)
+	}
+
+	result.Count = len(updated)
+	apiutil.WriteJSON(w, http.StatusOK, result)
+}
+
+// nicknameReset works out what a bulk reset does to one member: a nickname
+// matching pattern is cleared, and with dehoist a hoisted name left showing
+// — the nickname, or the display name once it is cleared — is dehoisted.
+func nicknameReset(userID string, nickname *string, name string, pattern *regexp.Regexp, dehoist bool) (nicknameChange, bool) {
+	c := nicknameChange{UserID: userID}
+	current := ""
+	if nickname != nil {
+		current = *nickname
+	}
+	c.From = current
+
+	next := current
+	if current != "" && pattern != nil && pattern.MatchString(current) {
+		next = ""
+	}
+	if dehoist {
+		shown := next
+		if shown == "" {
+			shown = name
+		}
+		if automod.IsHoisted(shown) {
+			next = automod.Dehoist(shown)
+		}
+	}
+
+	if next == current {
+		return c, false
+	}
+	if next != "" {
+		c.To = &next
+	}
+	return c, true
+}
@@ -0,0 +1,39 @@
+package guilds
+
+import (
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/openapi"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// OpenAPISchema documents that permission bitfields are accepted as numbers
+// or numeric strings.
+func (flexInt64) OpenAPISchema() *openapi.Schema {
+	return &openapi.Schema{Type: []string{"integer", "string"}}
+}
+
+// Operations describes the request and response bodies of the main guild
+// routes for the OpenAPI document.
+var Operations = []openapi.Operation{
+	{Method: "POST", Path: "/guilds", Summary: "Create a guild", Request: createGuildRequest{}, Response: models.Guild{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/guilds/{guildID}", Summary: "Get a guild", Response: models.Guild{}},
+	{Method: "PATCH", Path: "/guilds/{guildID}", Summary: "Update a guild", Request: updateGuildRequest{}, Response: models.Guild{}},
+	{Method: "DELETE", Path: "/guilds/{guildID}", Summary: "Delete a guild", Status: http.StatusNoContent},
+	{Method: "POST", Path: "/guilds/{guildID}/ack", Summary: "Mark every readable channel of a guild as read", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/guilds/{guildID}/channels", Summary: "List a guild's channels", Response: []models.Channel{}},
+	{Method: "POST", Path: "/guilds/{guildID}/channels", Summary: "Create a channel", Request: createChannelRequest{}, Response: models.Channel{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/guilds/{guildID}/channels/{channelID}/move", Summary: "Move a channel", Request: moveChannelRequest{}, Response: channelPositionsUpdate{}},
+	{Method: "POST", Path: "/guilds/{guildID}/categories/{categoryID}/move", Summary: "Move a category", Request: moveCategoryRequest{}, Response: channelPositionsUpdate{}},
+	{Method: "GET", Path: "/guilds/{guildID}/roles", Summary: "List a guild's roles", Response: []models.Role{}},
+	{Method: "POST", Path: "/guilds/{guildID}/roles", Summary: "Create a role", Request: createRoleRequest{}, Response: models.Role{}, Status: http.StatusCreated},
+	{Method: "PATCH", Path: "/guilds/{guildID}/roles/{roleID}", Summary: "Update a role", Request: updateRoleRequest{}, Response: models.Role{}},
+	{Method: "GET", Path: "/guilds/{guildID}/permission-presets", Summary: "List permission presets for new roles", Response: []models.PermissionPreset{}},
+	{Method: "POST", Path: "/guilds/{guildID}/permission-presets", Summary: "Save a permission preset", Request: createPermissionPresetRequest{}, Response: models.PermissionPreset{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/guilds/{guildID}/edit-policy", Summary: "Get the guild's message edit policy", Response: models.GuildEditPolicy{}},
+	{Method: "PATCH", Path: "/guilds/{guildID}/edit-policy", Summary: "Update the guild's message edit policy", Request: updateEditPolicyRequest{}, Response: models.GuildEditPolicy{}},
+	{Method: "GET", Path: "/guilds/{guildID}/emoji-stats", Summary: "Get the guild's top, trending and most-reacted emoji statistics", Response: emojiStats{}},
+	{Method: "PUT", Path: "/guilds/{guildID}/timeout-role", Summary: "Back timeouts with a managed role", Response: timeoutRoleState{}},
+	{Method: "PUT", Path: "/guilds/{guildID}/bans/{userID}", Summary: "Ban a member", Request: banRequest{}, Status: http.StatusNoContent},
+	{Method: "POST", Path: "/guilds/{guildID}/invites", Summary: "Create an invite", Response: models.Invite{}, Status: http.StatusCreated},
+}
@@ -0,0 +1,195 @@
+package guilds
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// sourceInstanceAdmin marks the instance admin flag, which the API honors
+// ahead of any guild or channel setting.
+const sourceInstanceAdmin = "instance_admin"
+
+// permissionSource is one contributor to a member's permissions and the
+// permission set once it was applied.
+type permissionSource struct {
+	Source      string   `json:"source"`
+	TargetID    string   `json:"target_id,omitempty"`
+	Name        *string  `json:"name,omitempty"`
+	Allow       int64    `json:"allow"`
+	AllowNames  []string `json:"allow_names"`
+	Deny        int64    `json:"deny"`
+	DenyNames   []string `json:"deny_names"`
+	Result      int64    `json:"result"`
+	ResultNames []string `json:"result_names"`
+}
+
+// memberPermissionAudit explains a member's effective permissions in a
+// guild or one of its channels.
+type memberPermissionAudit struct {
+	GuildID         string             `json:"guild_id"`
+	UserID          string             `json:"user_id"`
+	ChannelID       *string            `json:"channel_id"`
+	Permissions     int64              `json:"permissions"`
+	PermissionNames []string           `json:"permission_names"`
+	Bypass          *string            `json:"bypass"`
+	Sources         []permissionSource `json:"sources"`
+}
+
+// HandleAuditMemberPermissions reports a member's computed permissions and
+// every source that contributed to them: the @everyone defaults, each role,
+// an installed app's grant, channel overrides, timeouts, and the owner,
+// Administrator and instance admin bypasses. Members may audit themselves;
+// auditing others needs MANAGE_ROLES.
+// GET /api/v1/guilds/{guildID}/members/{memberID}/permissions?channel_id=
+func (h *Handler) HandleAuditMemberPermissions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	memberID := chi.URLParam(r, "memberID")
+
+	if memberID != userID && !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageRoles) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_ROLES permission")
+		return
+	}
+
+	var (
+		guild     permissions.GuildInfo
+		member    = permissions.MemberInfo{UserID: memberID}
+		userFlags int
+		appCap    *int64
+	)
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT g.owner_id, COALESCE(g.default_permissions, 0), gm.timeout_until, u.flags, bi.permissions
+		 FROM guild_members gm
+		 JOIN guilds g ON g.id = gm.guild_id
+		 JOIN users u ON u.id = gm.user_id
+		 LEFT JOIN bot_installs bi ON bi.guild_id = gm.guild_id AND bi.bot_id = gm.user_id
+		 WHERE gm.guild_id = $1 AND gm.user_id = $2`,
+		guildID, memberID,
+	).Scan(&guild.OwnerID, &guild.DefaultPermissions, &member.TimeoutUntil, &userFlags, &appCap)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.MemberNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up member", err)
+		return
+	}
+	if appCap != nil {
+		grant := uint64(*appCap)
+		member.Cap = &grant
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT r.id, r.name, r.position, r.permissions_allow, r.permissions_deny
+		 FROM roles r JOIN member_roles mr ON mr.role_id = r.id
+		 WHERE mr.guild_id = $1 AND mr.user_id = $2
+		 ORDER BY r.position DESC`, guildID, memberID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get member roles", err)
+		return
+	}
+	var roles []permissions.RoleInfo
+	roleNames := map[string]string{}
+	for rows.Next() {
+		var role permissions.RoleInfo
+		var name string
+		var allow, deny int64
+		if err := rows.Scan(&role.ID, &name, &role.Position, &allow, &deny); err != nil {
+			rows.Close()
+			apiutil.InternalError(w, h.Logger, "Failed to read member roles", err)
+			return
+		}
+		role.PermissionsAllow, role.PermissionsDeny = uint64(allow), uint64(deny)
+		roles = append(roles, role)
+		roleNames[role.ID] = name
+	}
+	rows.Close()
+
+	audit := memberPermissionAudit{GuildID: guildID, UserID: memberID}
+	var channel *permissions.ChannelInfo
+	if channelID := r.URL.Query().Get("channel_id"); channelID != "" {
+		channel = &permissions.ChannelInfo{}
+		var everyone *int64
+		err := h.Pool.QueryRow(r.Context(),
+			`SELECT default_permissions, preview_enabled FROM channels WHERE id = $1 AND guild_id = $2`,
+			channelID, guildID).Scan(&everyone, &channel.Preview)
+		if err == pgx.ErrNoRows {
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "Channel not found in this guild")
+			return
+		}
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to get channel", err)
+			return
+		}
+		if everyone != nil {
+			allow := uint64(*everyone)
+			channel.DefaultPermissionsAllow = &allow
+		}
+
+		rows, err := h.Pool.Query(r.Context(),
+			`SELECT target_type, target_id, permissions_allow, permissions_deny
+			 FROM channel_permission_overrides WHERE channel_id = $1`, channelID)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to get channel overrides", err)
+			return
+		}
+		for rows.Next() {
+			var o permissions.ChannelOverride
+			var allow, deny int64
+			if err := rows.Scan(&o.TargetType, &o.TargetID, &allow, &deny); err != nil {
+				rows.Close()
+				apiutil.InternalError(w, h.Logger, "Failed to read channel overrides", err)
+				return
+			}
+			o.PermissionsAllow, o.PermissionsDeny = uint64(allow), uint64(deny)
+			channel.Overrides = append(channel.Overrides, o)
+		}
+		rows.Close()
+		audit.ChannelID = &channelID
+	}
+
+	explained := permissions.Explain(member, guild, roles, channel)
+	steps := explained.Steps
+	perms := explained.Permissions
+	if userFlags&models.UserFlagAdmin != 0 {
+		steps = append(steps, permissions.Step{
+			Source: sourceInstanceAdmin, Allow: permissions.AllPermissions, Result: permissions.AllPermissions,
+		})
+		perms = permissions.AllPermissions
+	}
+
+	audit.Permissions = int64(perms)
+	audit.PermissionNames = permissions.Names(perms)
+	audit.Sources = make([]permissionSource, 0, len(steps))
+	for _, s := range steps {
+		src := permissionSource{
+			Source:      s.Source,
+			TargetID:    s.TargetID,
+			Allow:       int64(s.Allow),
+			AllowNames:  permissions.Names(s.Allow),
+			Deny:        int64(s.Deny),
+			DenyNames:   permissions.Names(s.Deny),
+			Result:      int64(s.Result),
+			ResultNames: permissions.Names(s.Result),
+		}
+		if name, ok := roleNames[s.TargetID]; ok && (s.Source == permissions.SourceRole || s.Source == permissions.SourceChannelRole) {
+			src.Name = &name
+		}
+		switch s.Source {
+		case permissions.SourceOwner, permissions.SourceAdministrator, sourceInstanceAdmin:
+			bypass := s.Source
+			audit.Bypass = &bypass
+		}
+		audit.Sources = append(audit.Sources, src)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, audit)
+}
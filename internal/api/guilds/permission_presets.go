@@ -0,0 +1,235 @@
+// Guild permission preset handlers.
+// Presets are named allow/deny pairs a role can be created from instead of
+// editing raw bitmasks: the built-in presets from the permissions package
+// plus any the guild saved. Mounted under
+// /api/v1/guilds/{guildID}/permission-presets.
+package guilds
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxPermissionPresets is how many custom presets a guild may save.
+const maxPermissionPresets = 50
+
+type createPermissionPresetRequest struct {
+	Name             string    `json:"name"`
+	Description      *string   `json:"description"`
+	PermissionsAllow flexInt64 `json:"permissions_allow"`
+	PermissionsDeny  flexInt64 `json:"permissions_deny"`
+}
+
+// builtinPermissionPresets returns the built-in presets in API form.
+func builtinPermissionPresets() []models.PermissionPreset {
+	presets := make([]models.PermissionPreset, 0, len(permissions.BuiltinPresets))
+	for _, p := range permissions.BuiltinPresets {
+		presets = append(presets, builtinPermissionPreset(p))
+	}
+	return presets
+}
+
+func builtinPermissionPreset(p permissions.Preset) models.PermissionPreset {
+	desc := p.Description
+	return models.PermissionPreset{
+		ID:               p.ID,
+		Name:             p.Name,
+		Description:      &desc,
+		PermissionsAllow: int64(p.Allow),
+		PermissionsDeny:  int64(p.Deny),
+		Builtin:          true,
+	}
+}
+
+// getPermissionPreset returns a built-in preset or one of the guild's own.
+func (h *Handler) getPermissionPreset(ctx context.Context, guildID, presetID string) (*models.PermissionPreset, error) {
+	if p, ok := permissions.BuiltinPreset(presetID); ok {
+		preset := builtinPermissionPreset(p)
+		return &preset, nil
+	}
+	var p models.PermissionPreset
+	err := h.Pool.QueryRow(ctx,
+		`SELECT id, guild_id, name, description, permissions_allow, permissions_deny, created_by, created_at
+		 FROM guild_permission_presets WHERE id = $1 AND guild_id = $2`,
+		presetID, guildID,
+	).Scan(&p.ID, &p.GuildID, &p.Name, &p.Description, &p.PermissionsAllow, &p.PermissionsDeny,
+		&p.CreatedBy, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// HandleGetPermissionPresets lists the built-in presets followed by the
+// guild's own, by name.
+// GET /api/v1/guilds/{guildID}/permission-presets
+func (h *Handler) HandleGetPermissionPresets(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageRoles) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_ROLES permission")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT id, guild_id, name, description, permissions_allow, permissions_deny, created_by, created_at
+		 FROM guild_permission_presets WHERE guild_id = $1
+		 ORDER BY lower(name)`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get permission presets", err)
+		return
+	}
+	defer rows.Close()
+
+	presets := builtinPermissionPresets()
+	for rows.Next() {
+		var p models.PermissionPreset
+		if err := rows.Scan(&p.ID, &p.GuildID, &p.Name, &p.Description, &p.PermissionsAllow,
+			&p.PermissionsDeny, &p.CreatedBy, &p.CreatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read permission presets", err)
+			return
+		}
+		presets = append(presets, p)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, presets)
+}
+
+// HandleCreatePermissionPreset saves a custom preset for the guild.
+// POST /api/v1/guilds/{guildID}/permission-presets
+func (h *Handler) HandleCreatePermissionPreset(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageRoles) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_ROLES permission")
+		return
+	}
+
+	var req createPermissionPresetRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Preset name must be 1-100 characters")
+		return
+	}
+	if _, ok := permissions.BuiltinPreset(strings.ToLower(req.Name)); ok {
+		apiutil.WriteError(w, http.StatusConflict, "preset_exists", "A built-in preset already has this name")
+		return
+	}
+	if req.Description != nil && len(*req.Description) > 256 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_description", "Description must be at most 256 characters")
+		return
+	}
+	allow, deny := uint64(req.PermissionsAllow.Value), uint64(req.PermissionsDeny.Value)
+	if allow&^permissions.AllPermissions != 0 || deny&^permissions.AllPermissions != 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_permissions", "Permissions contain unknown bits")
+		return
+	}
+	if allow == 0 && deny == 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "empty_preset", "A preset must allow or deny at least one permission")
+		return
+	}
+
+	var count int
+	h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM guild_permission_presets WHERE guild_id = $1`, guildID).Scan(&count)
+	if count >= maxPermissionPresets {
+		apiutil.WriteError(w, http.StatusBadRequest, "too_many_presets", "A guild can save at most 50 permission presets")
+		return
+	}
+
+	var p models.PermissionPreset
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO guild_permission_presets (id, guild_id, name, description, permissions_allow, permissions_deny, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+		 RETURNING id, guild_id, name, description, permissions_allow, permissions_deny, created_by, created_at`,
+		models.NewULID().String(), guildID, req.Name, req.Description,
+		req.PermissionsAllow.Value, req.PermissionsDeny.Value, userID,
+	).Scan(&p.ID, &p.GuildID, &p.Name, &p.Description, &p.PermissionsAllow, &p.PermissionsDeny,
+		&p.CreatedBy, &p.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			apiutil.WriteError(w, http.StatusConflict, "preset_exists", "A preset with this name already exists")
+			return
+		}
+		apiutil.InternalError(w, h.Logger, "Failed to create permission preset", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "permission_preset_create", "permission_preset", p.ID, nil)
+	apiutil.WriteJSON(w, http.StatusCreated, p)
+}
+
+// HandleDeletePermissionPreset deletes one of the guild's custom presets.
+// Roles created from it keep their permissions.
+// DELETE /api/v1/guilds/{guildID}/permission-presets/{presetID}
+func (h *Handler) HandleDeletePermissionPreset(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	presetID := chi.URLParam(r, "presetID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageRoles) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_ROLES permission")
+		return
+	}
+	if _, ok := permissions.BuiltinPreset(presetID); ok {
+		apiutil.WriteError(w, http.StatusBadRequest, "builtin_preset", "Built-in presets cannot be deleted")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM guild_permission_presets WHERE id = $1 AND guild_id = $2`, presetID, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete permission preset", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "preset_not_found", "Permission preset not found")
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "permission_preset_delete", "permission_preset", presetID, nil)
+	apiutil.WriteNoContent(w)
+}
+
+// applyPermissionPreset fills in a new role's permissions from its preset.
+// Permissions given explicitly in the request win over the preset's. It
+// writes the error response and returns false if the preset does not exist.
+func (h *Handler) applyPermissionPreset(w http.ResponseWriter, r *http.Request, guildID string, req *createRoleRequest) bool {
+	if req.PresetID == nil {
+		return true
+	}
+	preset, err := h.getPermissionPreset(r.Context(), guildID, *req.PresetID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "preset_not_found", "Permission preset not found")
+		return false
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get permission preset", err)
+		return false
+	}
+	if !req.PermissionsAllow.Set {
+		req.PermissionsAllow = flexInt64{Value: preset.PermissionsAllow, Set: true}
+	}
+	if !req.PermissionsDeny.Set {
+		req.PermissionsDeny = flexInt64{Value: preset.PermissionsDeny, Set: true}
+	}
+	return true
+}
@@ -0,0 +1,392 @@
+// Package guilds — public read-only web view. Channels opted in with
+// public_view are served as plain HTML to anyone, signed in or not, so a
+// community can link to and have search engines index its announcement
+// channels. The guild page, addressed by vanity URL or ID, lists them.
+package guilds
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/codespans"
+	"github.com/amityvox/amityvox/internal/spoilers"
+)
+
+const (
+	// publicViewTTL is how long a rendered page is cached, by the API and by
+	// browsers and proxies.
+	publicViewTTL = time.Minute
+
+	publicViewPageSize = 50
+
+	ulidLength = 26
+
+	publicViewCSP = "default-src 'none'; style-src 'unsafe-inline'; img-src 'self'; frame-ancestors 'none'"
+)
+
+// publicMentionRe matches user (<@ID>), role (<@&ID>) and channel (<#ID>)
+// mention tokens. Group 1 is the sigil and group 2 the ID.
+var publicMentionRe = regexp.MustCompile(`<(@&|@|#)([0-9A-Z]{26})>`)
+
+type publicGuild struct {
+	ID          string
+	Ref         string // vanity URL or ID, as linked
+	Name        string
+	Description *string
+	IconID      *string
+	Channels    []publicChannel
+}
+
+type publicChannel struct {
+	ID    string
+	Name  string
+	Topic *string
+}
+
+type publicMessage struct {
+	ID             string
+	Author         string
+	Content        string
+	ContentWarning *string
+	Attachments    int
+	Edited         bool
+	CreatedAt      time.Time
+}
+
+type publicChannelPage struct {
+	Guild    publicGuild
+	Channel  publicChannel
+	Messages []publicMessage
+	Older    string // message ID to page back from, "" on the last page
+}
+
+var publicViewFuncs = template.FuncMap{
+	"time": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 UTC") },
+	"iso":  func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+}
+
+const publicViewLayout = `{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<style>
+body{margin:0;font-family:system-ui,sans-serif;background:#1e1f22;color:#dbdee1;line-height:1.45}
+main{max-width:760px;margin:0 auto;padding:24px 16px}
+a{color:#8ea1e1}
+h1{margin:0 0 4px;font-size:1.5rem;color:#f2f3f5}
+.muted{color:#949ba4;font-size:.875rem}
+.icon{width:64px;height:64px;border-radius:16px;float:left;margin-right:16px}
+ul.channels{list-style:none;padding:0;clear:both}
+ul.channels li{padding:8px 0;border-bottom:1px solid #2b2d31}
+article{padding:10px 0;border-bottom:1px solid #2b2d31}
+article header{font-weight:600;color:#f2f3f5}
+article p{margin:4px 0 0;white-space:pre-wrap;word-wrap:break-word}
+</style>
+{{end}}`
+
+var publicGuildTemplate = template.Must(template.New("guild").Funcs(publicViewFuncs).Parse(publicViewLayout + `{{template "head"}}
+<title>{{.Name}}</title>
+{{with .Description}}<meta name="description" content="{{.}}">{{end}}
+</head>
+<body>
+<main>
+{{with .IconID}}<img class="icon" src="/api/v1/files/{{.}}" alt="">{{end}}
+<h1>{{.Name}}</h1>
+{{with .Description}}<p class="muted">{{.}}</p>{{end}}
+<ul class="channels">
+{{$ref := .Ref}}{{range .Channels}}<li><a href="/api/v1/public/guilds/{{$ref}}/channels/{{.ID}}">#{{.Name}}</a>{{with .Topic}}<div class="muted">{{.}}</div>{{end}}</li>
+{{end}}</ul>
+</main>
+</body>
+</html>
+`))
+
+var publicChannelTemplate = template.Must(template.New("channel").Funcs(publicViewFuncs).Parse(publicViewLayout + `{{template "head"}}
+<title>#{{.Channel.Name}} · {{.Guild.Name}}</title>
+{{with .Channel.Topic}}<meta name="description" content="{{.}}">{{end}}
+</head>
+<body>
+<main>
+<p class="muted"><a href="/api/v1/public/guilds/{{.Guild.Ref}}">{{.Guild.Name}}</a></p>
+<h1>#{{.Channel.Name}}</h1>
+{{with .Channel.Topic}}<p class="muted">{{.}}</p>{{end}}
+{{range .Messages}}<article id="m-{{.ID}}">
+<header>{{.Author}} <time class="muted" datetime="{{iso .CreatedAt}}">{{time .CreatedAt}}</time>{{if .Edited}} <span class="muted">(edited)</span>{{end}}</header>
+{{if .ContentWarning}}<p class="muted">Content warning: {{.ContentWarning}}</p>{{else}}{{if .Content}}<p>{{.Content}}</p>{{end}}{{if .Attachments}}<p class="muted">{{.Attachments}} attachment{{if ne .Attachments 1}}s{{end}}</p>{{end}}{{end}}
+</article>
+{{else}}<p class="muted">No messages yet.</p>
+{{end}}{{with .Older}}<p><a href="?before={{.}}" rel="next">Older messages</a></p>{{end}}
+</main>
+</body>
+</html>
+`))
+
+// HandlePublicGuildView serves the public page of a guild that has at least
+// one channel in the public view, listing those channels.
+// GET /api/v1/public/guilds/{guildRef}
+func (h *Handler) HandlePublicGuildView(w http.ResponseWriter, r *http.Request) {
+	ref := chi.URLParam(r, "guildRef")
+	h.servePublicView(w, r, "public_view:"+ref, func(ctx context.Context) ([]byte, error) {
+		guild, err := h.loadPublicGuild(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		err = publicGuildTemplate.Execute(&buf, guild)
+		return buf.Bytes(), err
+	})
+}
+
+// HandlePublicChannelView serves the latest messages of a public channel as
+// read-only HTML. Pass ?before={messageID} for older ones.
+// GET /api/v1/public/guilds/{guildRef}/channels/{channelID}
+func (h *Handler) HandlePublicChannelView(w http.ResponseWriter, r *http.Request) {
+	ref := chi.URLParam(r, "guildRef")
+	channelID := chi.URLParam(r, "channelID")
+	before := r.URL.Query().Get("before")
+	if before != "" && len(before) != ulidLength {
+		http.NotFound(w, r)
+		return
+	}
+	key := fmt.Sprintf("public_view:%s:%s:%s", ref, channelID, before)
+	h.servePublicView(w, r, key, func(ctx context.Context) ([]byte, error) {
+		guild, err := h.loadPublicGuild(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		page := publicChannelPage{Guild: guild}
+		found := false
+		for _, c := range guild.Channels {
+			if c.ID == channelID {
+				page.Channel, found = c, true
+			}
+		}
+		if !found {
+			return nil, pgx.ErrNoRows
+		}
+		if page.Messages, err = h.loadPublicMessages(ctx, guild, channelID, before); err != nil {
+			return nil, err
+		}
+		if len(page.Messages) == publicViewPageSize {
+			page.Older = page.Messages[len(page.Messages)-1].ID
+		}
+		var buf bytes.Buffer
+		err = publicChannelTemplate.Execute(&buf, page)
+		return buf.Bytes(), err
+	})
+}
+
+// servePublicView writes a public page, rendering it with render unless a
+// copy is cached under key. render returns pgx.ErrNoRows for pages that do
+// not exist.
+func (h *Handler) servePublicView(w http.ResponseWriter, r *http.Request, key string, render func(context.Context) ([]byte, error)) {
+	var page []byte
+	if h.Cache != nil {
+		if found, err := h.Cache.Get(r.Context(), key, &page); err != nil {
+			h.Logger.Debug("reading cached public view failed", slog.String("error", err.Error()))
+		} else if !found {
+			page = nil
+		}
+	}
+	if page == nil {
+		var err error
+		page, err = render(r.Context())
+		if err == pgx.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			h.Logger.Error("failed to render public view", slog.String("path", r.URL.Path), slog.String("error", err.Error()))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		if h.Cache != nil {
+			if err := h.Cache.Set(r.Context(), key, page, publicViewTTL); err != nil {
+				h.Logger.Debug("caching public view failed", slog.String("error", err.Error()))
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", publicViewCSP)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(publicViewTTL.Seconds())))
+	w.Write(page)
+}
+
+// loadPublicGuild loads a guild by vanity URL or ID with its public
+// channels. Guilds without any are not public and yield pgx.ErrNoRows.
+func (h *Handler) loadPublicGuild(ctx context.Context, ref string) (publicGuild, error) {
+	g := publicGuild{Ref: ref}
+	err := h.Pool.QueryRow(ctx,
+		`SELECT id, name, description, icon_id FROM guilds
+		 WHERE (vanity_url = $1 OR id = $1) AND NOT nsfw
+		 ORDER BY COALESCE(vanity_url = $1, false) DESC LIMIT 1`, ref,
+	).Scan(&g.ID, &g.Name, &g.Description, &g.IconID)
+	if err != nil {
+		return g, err
+	}
+
+	rows, err := h.Pool.Query(ctx,
+		`SELECT id, COALESCE(name, ''), topic FROM channels
+		 WHERE guild_id = $1 AND public_view AND NOT nsfw AND NOT encrypted
+		 ORDER BY position, created_at`, g.ID)
+	if err != nil {
+		return g, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c publicChannel
+		if err := rows.Scan(&c.ID, &c.Name, &c.Topic); err != nil {
+			return g, err
+		}
+		g.Channels = append(g.Channels, c)
+	}
+	if err := rows.Err(); err != nil {
+		return g, err
+	}
+	if len(g.Channels) == 0 {
+		return g, pgx.ErrNoRows
+	}
+	return g, nil
+}
+
+// loadPublicMessages returns a page of a public channel's messages, newest
+// first, ready to show to anyone: deleted and encrypted messages are left
+// out, spoilers are masked and mention tokens are replaced with names.
+func (h *Handler) loadPublicMessages(ctx context.Context, guild publicGuild, channelID, before string) ([]publicMessage, error) {
+	rows, err := h.Pool.Query(ctx,
+		`SELECT m.id, COALESCE(m.masquerade_name, m.bridge_author_name, u.display_name, u.username, 'Unknown'),
+		        COALESCE(m.content, ''), cw.reason,
+		        (SELECT COUNT(*) FROM attachments a WHERE a.message_id = m.id),
+		        m.edited_at IS NOT NULL, m.created_at
+		 FROM messages m
+		 LEFT JOIN users u ON u.id = m.author_id
+		 LEFT JOIN message_content_warnings cw ON cw.message_id = m.id
+		 WHERE m.channel_id = $1 AND ($2 = '' OR m.id < $2)
+		   AND m.deleted_at IS NULL AND NOT m.encrypted
+		 ORDER BY m.id DESC LIMIT $3`,
+		channelID, before, publicViewPageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []publicMessage
+	for rows.Next() {
+		var m publicMessage
+		if err := rows.Scan(&m.ID, &m.Author, &m.Content, &m.ContentWarning,
+			&m.Attachments, &m.Edited, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		m.Content = spoilers.Mask(m.Content)
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	names, err := h.loadPublicMentionNames(ctx, guild, messages)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		messages[i].Content = resolvePublicMentions(messages[i].Content, names)
+	}
+	return messages, nil
+}
+
+// loadPublicMentionNames maps the mention tokens in messages to the text
+// shown in their place. Only this guild's roles and public channels are
+// named, so a token cannot reveal anything the page does not already show.
+func (h *Handler) loadPublicMentionNames(ctx context.Context, guild publicGuild, messages []publicMessage) (map[string]string, error) {
+	names := make(map[string]string)
+	var userIDs, roleIDs []string
+	for _, m := range messages {
+		codespans.EachOutside(m.Content, func(text string) string {
+			for _, match := range publicMentionRe.FindAllStringSubmatch(text, -1) {
+				switch match[1] {
+				case "@":
+					userIDs = append(userIDs, match[2])
+				case "@&":
+					roleIDs = append(roleIDs, match[2])
+				}
+			}
+			return text
+		})
+	}
+	for _, c := range guild.Channels {
+		names["#"+c.ID] = "#" + c.Name
+	}
+
+	if len(userIDs) > 0 {
+		rows, err := h.Pool.Query(ctx,
+			`SELECT id, COALESCE(display_name, username) FROM users WHERE id = ANY($1)`, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			names["@"+id] = "@" + name
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(roleIDs) > 0 {
+		rows, err := h.Pool.Query(ctx,
+			`SELECT id, name FROM roles WHERE guild_id = $1 AND id = ANY($2)`, guild.ID, roleIDs)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			names["@&"+id] = "@" + name
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// resolvePublicMentions replaces the mention tokens outside code in content
+// with their entry in names, keyed by sigil and ID, or with a generic label
+// for anything unknown.
+func resolvePublicMentions(content string, names map[string]string) string {
+	return codespans.EachOutside(content, func(text string) string {
+		return publicMentionRe.ReplaceAllStringFunc(text, func(token string) string {
+			match := publicMentionRe.FindStringSubmatch(token)
+			if name, ok := names[match[1]+match[2]]; ok {
+				return name
+			}
+			switch match[1] {
+			case "@&":
+				return "@unknown-role"
+			case "#":
+				return "#unknown-channel"
+			default:
+				return "@unknown-user"
+			}
+		})
+	})
+}
@@ -0,0 +1,38 @@
+package guilds
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+)
+
+// HandleAckGuild marks every channel of the guild the caller can read as
+// read, sending one CHANNELS_ACK for the guild.
+// POST /api/v1/guilds/{guildID}/ack
+func (h *Handler) HandleAckGuild(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	channelIDs, err := apiutil.ReadableGuildChannels(r.Context(), h.Pool, guildID, userID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list guild channels", err)
+		return
+	}
+
+	if _, err := apiutil.AckChannels(r.Context(), h.Pool, h.EventBus, userID, &guildID, channelIDs); err != nil {
+		h.Logger.Error("failed to ack guild", slog.String("guild_id", guildID), slog.String("error", err.Error()))
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to mark guild as read")
+		return
+	}
+	apiutil.WriteNoContent(w)
+}
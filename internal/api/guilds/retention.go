@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/oklog/ulid/v2"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/permissions"
@@ -38,7 +39,7 @@ func (h *Handler) HandleGetGuildRetentionPolicies(w http.ResponseWriter, r *http
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
 		return
 	}
 
@@ -78,7 +79,7 @@ func (h *Handler) HandleCreateGuildRetentionPolicy(w http.ResponseWriter, r *htt
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
 		return
 	}
 
@@ -153,7 +154,7 @@ func (h *Handler) HandleUpdateGuildRetentionPolicy(w http.ResponseWriter, r *htt
 	policyID := chi.URLParam(r, "policyID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
 		return
 	}
 
@@ -162,11 +163,11 @@ func (h *Handler) HandleUpdateGuildRetentionPolicy(w http.ResponseWriter, r *htt
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT guild_id FROM data_retention_policies WHERE id = $1`, policyID).Scan(&existingGuildID)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Retention policy not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Retention policy not found")
 		return
 	}
 	if err != nil || existingGuildID == nil || *existingGuildID != guildID {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Retention policy not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Retention policy not found")
 		return
 	}
 
@@ -248,7 +249,7 @@ func (h *Handler) HandleDeleteGuildRetentionPolicy(w http.ResponseWriter, r *htt
 	policyID := chi.URLParam(r, "policyID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
 		return
 	}
 
@@ -259,7 +260,7 @@ func (h *Handler) HandleDeleteGuildRetentionPolicy(w http.ResponseWriter, r *htt
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Retention policy not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Retention policy not found")
 		return
 	}
 
@@ -0,0 +1,276 @@
+package guilds
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+const (
+	defaultRoleMembersLimit = 100
+	maxRoleMembersLimit     = 1000
+	// maxBulkRoleMembers bounds the members one bulk request may change.
+	maxBulkRoleMembers = 1000
+)
+
+type bulkRoleMembersRequest struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+	Reason *string  `json:"reason"`
+}
+
+// bulkRoleMembersResult lists who a bulk request changed. Skipped holds IDs
+// that are not members, already had the role when adding, or did not have
+// it when removing.
+type bulkRoleMembersResult struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Skipped []string `json:"skipped"`
+}
+
+// HandleGetRoleMembers lists the members holding a role, ordered by user ID.
+// Pass the last user ID of a page as after to fetch the next one.
+// GET /api/v1/guilds/{guildID}/roles/{roleID}/members?after=&limit=
+func (h *Handler) HandleGetRoleMembers(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	roleID := chi.URLParam(r, "roleID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	limit := defaultRoleMembersLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxRoleMembersLimit {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_limit",
+				fmt.Sprintf("limit must be between 1 and %d", maxRoleMembersLimit))
+			return
+		}
+		limit = n
+	}
+
+	var exists bool
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM roles WHERE id = $1 AND guild_id = $2)`, roleID, guildID,
+	).Scan(&exists); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up role", err)
+		return
+	}
+	if !exists {
+		apiutil.WriteCode(w, apierrors.RoleNotFound, "Role not found in this guild")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT gm.guild_id, gm.user_id, gm.nickname, gm.avatar_id, gm.joined_at,
+		        gm.timeout_until, gm.deaf, gm.mute,
+		        u.id, u.instance_id, u.username, u.display_name, u.avatar_id,
+		        u.status_text, u.status_emoji, u.status_presence, u.status_expires_at,
+		        u.bio, u.banner_id, u.accent_color, u.pronouns, u.flags, u.created_at
+		 FROM member_roles mr
+		 JOIN guild_members gm ON gm.guild_id = mr.guild_id AND gm.user_id = mr.user_id
+		 JOIN users u ON u.id = gm.user_id
+		 WHERE mr.guild_id = $1 AND mr.role_id = $2 AND ($3 = '' OR mr.user_id > $3)
+		 ORDER BY mr.user_id
+		 LIMIT $4`,
+		guildID, roleID, r.URL.Query().Get("after"), limit,
+	)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get role members", err)
+		return
+	}
+	defer rows.Close()
+
+	members := make([]models.GuildMember, 0)
+	for rows.Next() {
+		var m models.GuildMember
+		var u models.User
+		if err := rows.Scan(
+			&m.GuildID, &m.UserID, &m.Nickname, &m.AvatarID, &m.JoinedAt,
+			&m.TimeoutUntil, &m.Deaf, &m.Mute,
+			&u.ID, &u.InstanceID, &u.Username, &u.DisplayName, &u.AvatarID,
+			&u.StatusText, &u.StatusEmoji, &u.StatusPresence, &u.StatusExpiresAt,
+			&u.Bio, &u.BannerID, &u.AccentColor, &u.Pronouns, &u.Flags, &u.CreatedAt,
+		); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read role members", err)
+			return
+		}
+		m.User = &u
+		members = append(members, m)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, members)
+}
+
+// HandleBulkUpdateRoleMembers adds a role to and removes it from many members
+// in one request, under the same rules as assigning it one member at a time.
+// The change is recorded as a single audit log entry.
+// POST /api/v1/guilds/{guildID}/roles/{roleID}/members/bulk
+func (h *Handler) HandleBulkUpdateRoleMembers(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	roleID := chi.URLParam(r, "roleID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.AssignRoles) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need ASSIGN_ROLES permission")
+		return
+	}
+
+	var req bulkRoleMembersRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	all := append(append([]string{}, req.Add...), req.Remove...)
+	if len(all) == 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "empty_request", "add or remove must list at least one member")
+		return
+	}
+	if len(all) > maxBulkRoleMembers {
+		apiutil.WriteError(w, http.StatusBadRequest, "too_many_members",
+			fmt.Sprintf("A bulk request can change at most %d members", maxBulkRoleMembers))
+		return
+	}
+	removing := make(map[string]bool, len(req.Remove))
+	for _, id := range req.Remove {
+		removing[id] = true
+	}
+	for _, id := range req.Add {
+		if removing[id] {
+			apiutil.WriteError(w, http.StatusBadRequest, "conflicting_members", "A member cannot be both added and removed")
+			return
+		}
+	}
+
+	var targetPos int
+	var managedBy, communityRoleID *string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT position, managed_by, community_role_id FROM roles WHERE id = $1 AND guild_id = $2`, roleID, guildID,
+	).Scan(&targetPos, &managedBy, &communityRoleID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.RoleNotFound, "Role not found in this guild")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up role", err)
+		return
+	}
+	if managedBy != nil {
+		apiutil.WriteError(w, http.StatusForbidden, "managed_role", "Managed roles can only be held by their app")
+		return
+	}
+	if communityRoleID != nil {
+		apiutil.WriteError(w, http.StatusForbidden, "community_role", "Community roles are assigned by the community")
+		return
+	}
+	if _, ok := h.appGrant(r.Context(), guildID, userID); ok {
+		for _, id := range all {
+			if id == userID {
+				apiutil.WriteError(w, http.StatusForbidden, "app_sandbox", "Apps cannot change their own roles")
+				return
+			}
+		}
+	}
+	if !h.isGuildOwner(r.Context(), guildID, userID) {
+		if targetPos >= h.getHighestRolePosition(r.Context(), guildID, userID) {
+			apiutil.WriteError(w, http.StatusForbidden, "role_hierarchy", "Cannot assign a role at or above your highest role")
+			return
+		}
+	}
+
+	result := bulkRoleMembersResult{Added: []string{}, Removed: []string{}, Skipped: []string{}}
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if len(req.Add) > 0 {
+			rows, err := tx.Query(r.Context(),
+				`INSERT INTO member_roles (guild_id, user_id, role_id)
+				 SELECT gm.guild_id, gm.user_id, $3 FROM guild_members gm
+				 WHERE gm.guild_id = $1 AND gm.user_id = ANY($2)
+				 ON CONFLICT DO NOTHING
+				 RETURNING user_id`, guildID, req.Add, roleID)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var id string
+				if err := rows.Scan(&id); err != nil {
+					rows.Close()
+					return err
+				}
+				result.Added = append(result.Added, id)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+		}
+		if len(req.Remove) > 0 {
+			rows, err := tx.Query(r.Context(),
+				`DELETE FROM member_roles WHERE guild_id = $1 AND role_id = $3 AND user_id = ANY($2)
+				 RETURNING user_id`, guildID, req.Remove, roleID)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var id string
+				if err := rows.Scan(&id); err != nil {
+					rows.Close()
+					return err
+				}
+				result.Removed = append(result.Removed, id)
+			}
+			rows.Close()
+			return rows.Err()
+		}
+		return nil
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update role members", err)
+		return
+	}
+
+	changed := make(map[string]bool, len(result.Added)+len(result.Removed))
+	for _, id := range result.Added {
+		changed[id] = true
+	}
+	for _, id := range result.Removed {
+		changed[id] = true
+	}
+	for _, id := range all {
+		if !changed[id] {
+			result.Skipped = append(result.Skipped, id)
+		}
+	}
+
+	if len(changed) > 0 {
+		reason := fmt.Sprintf("Bulk role update: %d added, %d removed", len(result.Added), len(result.Removed))
+		if req.Reason != nil && *req.Reason != "" {
+			reason += " — " + *req.Reason
+		}
+		h.logAudit(r.Context(), guildID, userID, "member_role_bulk_update", "role", roleID, &reason)
+	}
+
+	publish := func(ids []string, action string) {
+		for _, id := range ids {
+			h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", guildID, map[string]interface{}{
+				"guild_id": guildID, "user_id": id, "role_id": roleID, "action": action,
+				"roles": h.getMemberRoleIDs(r.Context(), guildID, id),
+			})
+		}
+	}
+	publish(result.Added, "role_add")
+	publish(result.Removed, "role_remove")
+
+	apiutil.WriteJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,219 @@
+// Guild scheduled purge handlers.
+// A guild schedules cleanups ahead of an event: deleting a temporary
+// category with its channels, or removing an event role from every member
+// afterwards. The scheduled purge worker runs them when due and records each
+// run in the audit log. Mounted under /api/v1/guilds/{guildID}/scheduled-purges.
+package guilds
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// maxScheduledPurges bounds the pending purges a guild may hold.
+const maxScheduledPurges = 50
+
+// scheduledPurgeColumns selects a models.GuildScheduledPurge.
+const scheduledPurgeColumns = `id, guild_id, kind, target_id, run_at, repeat_days, status,
+	affected, error, created_by, created_at, last_run_at`
+
+func scanScheduledPurge(row pgx.Row) (models.GuildScheduledPurge, error) {
+	var p models.GuildScheduledPurge
+	err := row.Scan(&p.ID, &p.GuildID, &p.Kind, &p.TargetID, &p.RunAt, &p.RepeatDays, &p.Status,
+		&p.Affected, &p.Error, &p.CreatedBy, &p.CreatedAt, &p.LastRunAt)
+	return p, err
+}
+
+// purgePermission is the permission needed to schedule or cancel a purge of
+// the given kind: the one needed to do it by hand.
+func purgePermission(kind string) (uint64, string) {
+	if kind == models.PurgeKindCategory {
+		return permissions.ManageChannels, "You need MANAGE_CHANNELS permission"
+	}
+	return permissions.AssignRoles, "You need ASSIGN_ROLES permission"
+}
+
+type createScheduledPurgeRequest struct {
+	Kind       string    `json:"kind"`
+	TargetID   string    `json:"target_id"`
+	RunAt      time.Time `json:"run_at"`
+	RepeatDays *int      `json:"repeat_days"`
+}
+
+// HandleGetScheduledPurges lists the guild's scheduled purges, next due
+// first. Members who can manage channels or assign roles can see them.
+// GET /api/v1/guilds/{guildID}/scheduled-purges
+func (h *Handler) HandleGetScheduledPurges(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageChannels) &&
+		!h.hasGuildPermission(r.Context(), guildID, userID, permissions.AssignRoles) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS or ASSIGN_ROLES permission")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+scheduledPurgeColumns+` FROM guild_scheduled_purges
+		 WHERE guild_id = $1 ORDER BY status = 'pending' DESC, run_at LIMIT 100`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get scheduled purges", err)
+		return
+	}
+	purges, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.GuildScheduledPurge, error) {
+		return scanScheduledPurge(row)
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read scheduled purges", err)
+		return
+	}
+	if purges == nil {
+		purges = []models.GuildScheduledPurge{}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, purges)
+}
+
+// HandleCreateScheduledPurge schedules a category deletion or a role
+// removal. Role purges may repeat every repeat_days; category purges run
+// once. The role rules match assigning the role by hand.
+// POST /api/v1/guilds/{guildID}/scheduled-purges
+func (h *Handler) HandleCreateScheduledPurge(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	var req createScheduledPurgeRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Kind != models.PurgeKindCategory && req.Kind != models.PurgeKindRole {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_kind", "kind must be 'category' or 'role'")
+		return
+	}
+	if perm, msg := purgePermission(req.Kind); !h.hasGuildPermission(r.Context(), guildID, userID, perm) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, msg)
+		return
+	}
+	if !apiutil.RequireNonEmpty(w, "target_id", req.TargetID) {
+		return
+	}
+	if !req.RunAt.After(time.Now()) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_run_at", "run_at must be in the future")
+		return
+	}
+	if req.RepeatDays != nil {
+		if req.Kind != models.PurgeKindRole {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_repeat", "Only role purges can repeat")
+			return
+		}
+		if *req.RepeatDays < 1 || *req.RepeatDays > 365 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_repeat", "repeat_days must be between 1 and 365")
+			return
+		}
+	}
+
+	switch req.Kind {
+	case models.PurgeKindCategory:
+		var exists bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM guild_categories WHERE id = $1 AND guild_id = $2)`,
+			req.TargetID, guildID).Scan(&exists)
+		if !exists {
+			apiutil.WriteError(w, http.StatusNotFound, "category_not_found", "Category not found")
+			return
+		}
+	case models.PurgeKindRole:
+		var position int
+		var managedBy, communityRoleID *string
+		err := h.Pool.QueryRow(r.Context(),
+			`SELECT position, managed_by, community_role_id FROM roles WHERE id = $1 AND guild_id = $2`, req.TargetID, guildID,
+		).Scan(&position, &managedBy, &communityRoleID)
+		if err == pgx.ErrNoRows {
+			apiutil.WriteCode(w, apierrors.RoleNotFound, "Role not found in this guild")
+			return
+		}
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to look up role", err)
+			return
+		}
+		if managedBy != nil {
+			apiutil.WriteError(w, http.StatusForbidden, "managed_role", "Managed roles can only be held by their app")
+			return
+		}
+		if communityRoleID != nil {
+			apiutil.WriteError(w, http.StatusForbidden, "community_role", "Community roles are assigned by the community")
+			return
+		}
+		if !h.isGuildOwner(r.Context(), guildID, userID) &&
+			position >= h.getHighestRolePosition(r.Context(), guildID, userID) {
+			apiutil.WriteError(w, http.StatusForbidden, "role_hierarchy", "Cannot purge a role at or above your highest role")
+			return
+		}
+	}
+
+	var pending int
+	h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM guild_scheduled_purges WHERE guild_id = $1 AND status = 'pending'`,
+		guildID).Scan(&pending)
+	if pending >= maxScheduledPurges {
+		apiutil.WriteError(w, http.StatusBadRequest, "limit_reached", "A guild can have at most 50 pending scheduled purges")
+		return
+	}
+
+	purge, err := scanScheduledPurge(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO guild_scheduled_purges (id, guild_id, kind, target_id, run_at, repeat_days, created_by, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+		 RETURNING `+scheduledPurgeColumns,
+		models.NewULID().String(), guildID, req.Kind, req.TargetID, req.RunAt, req.RepeatDays, userID))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to schedule purge", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "scheduled_purge_create", req.Kind, req.TargetID, nil)
+	apiutil.WriteJSON(w, http.StatusCreated, purge)
+}
+
+// HandleDeleteScheduledPurge cancels a scheduled purge, or removes a
+// finished one from the list.
+// DELETE /api/v1/guilds/{guildID}/scheduled-purges/{purgeID}
+func (h *Handler) HandleDeleteScheduledPurge(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	purgeID := chi.URLParam(r, "purgeID")
+
+	var kind, targetID string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT kind, target_id FROM guild_scheduled_purges WHERE id = $1 AND guild_id = $2`,
+		purgeID, guildID).Scan(&kind, &targetID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "purge_not_found", "Scheduled purge not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get scheduled purge", err)
+		return
+	}
+	if perm, msg := purgePermission(kind); !h.hasGuildPermission(r.Context(), guildID, userID, perm) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, msg)
+		return
+	}
+
+	if _, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM guild_scheduled_purges WHERE id = $1 AND guild_id = $2`, purgeID, guildID); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to cancel scheduled purge", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "scheduled_purge_cancel", kind, targetID, nil)
+	apiutil.WriteNoContent(w)
+}
@@ -0,0 +1,225 @@
+// Guild tags: canned responses members post with /tag <name>. Moderators
+// with MANAGE_MESSAGES create and edit them; posting happens in the channels
+// package, which renders placeholders and applies the cooldown.
+package guilds
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+const (
+	maxTagContent  = 2000
+	maxTagCooldown = 86400
+)
+
+// tagNameRe matches valid tag names, which are typed after /tag.
+var tagNameRe = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+const tagColumns = `id, guild_id, name, content, cooldown_seconds, uses, last_used_at, creator_id, created_at, updated_at`
+
+func scanTag(row pgx.Row) (models.GuildTag, error) {
+	var t models.GuildTag
+	err := row.Scan(&t.ID, &t.GuildID, &t.Name, &t.Content, &t.CooldownSeconds, &t.Uses,
+		&t.LastUsedAt, &t.CreatorID, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// validateTag checks the fields of a tag create or update. Nil fields are
+// left unchecked.
+func validateTag(w http.ResponseWriter, name, content *string, cooldown *int) bool {
+	if name != nil && !tagNameRe.MatchString(*name) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_name",
+			"Tag name must be 1-32 lowercase letters, digits, hyphens or underscores")
+		return false
+	}
+	if content != nil && (strings.TrimSpace(*content) == "" || len(*content) > maxTagContent) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_content", "Tag content must be 1-2000 characters")
+		return false
+	}
+	if cooldown != nil && (*cooldown < 0 || *cooldown > maxTagCooldown) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_cooldown", "Cooldown must be between 0 and 86400 seconds")
+		return false
+	}
+	return true
+}
+
+func isTagNameConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// HandleGetTags lists the guild's tags, most used first.
+// GET /api/v1/guilds/{guildID}/tags
+func (h *Handler) HandleGetTags(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+tagColumns+` FROM guild_tags WHERE guild_id = $1 ORDER BY uses DESC, name`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get tags", err)
+		return
+	}
+	defer rows.Close()
+
+	tags := []models.GuildTag{}
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read tags", err)
+			return
+		}
+		tags = append(tags, t)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, tags)
+}
+
+// HandleCreateTag creates a guild tag.
+// POST /api/v1/guilds/{guildID}/tags
+func (h *Handler) HandleCreateTag(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageMessages) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
+		return
+	}
+
+	var req struct {
+		Name            string `json:"name"`
+		Content         string `json:"content"`
+		CooldownSeconds int    `json:"cooldown_seconds"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.ToLower(strings.TrimSpace(req.Name))
+	if !validateTag(w, &req.Name, &req.Content, &req.CooldownSeconds) {
+		return
+	}
+
+	tag, err := scanTag(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO guild_tags (id, guild_id, name, content, cooldown_seconds, creator_id, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, now(), now())
+		 RETURNING `+tagColumns,
+		models.NewULID().String(), guildID, req.Name, req.Content, req.CooldownSeconds, userID))
+	if err != nil {
+		if isTagNameConflict(err) {
+			apiutil.WriteError(w, http.StatusConflict, "tag_exists", "A tag with that name already exists")
+			return
+		}
+		apiutil.InternalError(w, h.Logger, "Failed to create tag", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "tag_create", "tag", tag.ID, nil)
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildUpdate, "GUILD_TAG_CREATE", guildID, tag)
+
+	apiutil.WriteJSON(w, http.StatusCreated, tag)
+}
+
+// HandleUpdateTag edits a guild tag. Usage stats are kept across renames.
+// PATCH /api/v1/guilds/{guildID}/tags/{tagID}
+func (h *Handler) HandleUpdateTag(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	tagID := chi.URLParam(r, "tagID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageMessages) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
+		return
+	}
+
+	var req struct {
+		Name            *string `json:"name"`
+		Content         *string `json:"content"`
+		CooldownSeconds *int    `json:"cooldown_seconds"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name != nil {
+		name := strings.ToLower(strings.TrimSpace(*req.Name))
+		req.Name = &name
+	}
+	if !validateTag(w, req.Name, req.Content, req.CooldownSeconds) {
+		return
+	}
+
+	tag, err := scanTag(h.Pool.QueryRow(r.Context(),
+		`UPDATE guild_tags SET
+			name = COALESCE($3, name),
+			content = COALESCE($4, content),
+			cooldown_seconds = COALESCE($5, cooldown_seconds),
+			updated_at = now()
+		 WHERE id = $1 AND guild_id = $2
+		 RETURNING `+tagColumns,
+		tagID, guildID, req.Name, req.Content, req.CooldownSeconds))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "tag_not_found", "Tag not found")
+		return
+	}
+	if err != nil {
+		if isTagNameConflict(err) {
+			apiutil.WriteError(w, http.StatusConflict, "tag_exists", "A tag with that name already exists")
+			return
+		}
+		apiutil.InternalError(w, h.Logger, "Failed to update tag", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "tag_update", "tag", tagID, nil)
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildUpdate, "GUILD_TAG_UPDATE", guildID, tag)
+
+	apiutil.WriteJSON(w, http.StatusOK, tag)
+}
+
+// HandleDeleteTag deletes a guild tag.
+// DELETE /api/v1/guilds/{guildID}/tags/{tagID}
+func (h *Handler) HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	tagID := chi.URLParam(r, "tagID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageMessages) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
+		return
+	}
+
+	result, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM guild_tags WHERE id = $1 AND guild_id = $2`, tagID, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete tag", err)
+		return
+	}
+	if result.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "tag_not_found", "Tag not found")
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "tag_delete", "tag", tagID, nil)
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildUpdate, "GUILD_TAG_DELETE", guildID,
+		map[string]string{"id": tagID, "guild_id": guildID})
+
+	apiutil.WriteNoContent(w)
+}
@@ -7,14 +7,17 @@ package guilds
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/permissions"
 )
@@ -88,6 +91,88 @@ type applyTemplateRequest struct {
 	GuildName  *string `json:"guild_name"` // Used when creating a new guild from template.
 }
 
+// Limits on a template supplied inline when creating a guild.
+const (
+	maxTemplateRoles      = 100
+	maxTemplateCategories = 50
+	maxTemplateChannels   = 200
+)
+
+// templateChannelTypes are the channel types a template may create.
+var templateChannelTypes = map[string]bool{
+	models.ChannelTypeText: true, models.ChannelTypeVoice: true, models.ChannelTypeAnnouncement: true,
+	models.ChannelTypeForum: true, models.ChannelTypeGallery: true, models.ChannelTypeStage: true,
+}
+
+// validateTemplateData checks a template supplied by a client and returns a
+// message safe to return to the caller, or "". Saved templates were
+// captured from real guilds and are not checked again.
+func validateTemplateData(data templateData) string {
+	if uint64(data.GuildSettings.DefaultPermissions)&^permissions.AllPermissions != 0 {
+		return "guild_settings.default_permissions contains unknown permission bits"
+	}
+	if len(data.Roles) > maxTemplateRoles {
+		return fmt.Sprintf("A template can have at most %d roles", maxTemplateRoles)
+	}
+	for _, role := range data.Roles {
+		if role.Name == "" || len(role.Name) > 100 {
+			return "Role names must be 1-100 characters"
+		}
+		if uint64(role.PermissionsAllow)&^permissions.AllPermissions != 0 ||
+			uint64(role.PermissionsDeny)&^permissions.AllPermissions != 0 {
+			return fmt.Sprintf("Role %q contains unknown permission bits", role.Name)
+		}
+	}
+	if len(data.Categories) > maxTemplateCategories {
+		return fmt.Sprintf("A template can have at most %d categories", maxTemplateCategories)
+	}
+	categories := make(map[string]bool, len(data.Categories))
+	for _, cat := range data.Categories {
+		if cat.Name == "" || len(cat.Name) > 100 {
+			return "Category names must be 1-100 characters"
+		}
+		categories[cat.Name] = true
+	}
+	if len(data.Channels) > maxTemplateChannels {
+		return fmt.Sprintf("A template can have at most %d channels", maxTemplateChannels)
+	}
+	for _, ch := range data.Channels {
+		if ch.Name == "" || len(ch.Name) > 100 {
+			return "Channel names must be 1-100 characters"
+		}
+		if !templateChannelTypes[ch.ChannelType] {
+			return fmt.Sprintf("Invalid channel type %q", ch.ChannelType)
+		}
+		if ch.CategoryName != "" && !categories[ch.CategoryName] {
+			return fmt.Sprintf("Channel %q names unknown category %q", ch.Name, ch.CategoryName)
+		}
+		if len(ch.Topic) > 1024 {
+			return "Channel topics must be at most 1024 characters"
+		}
+		if ch.SlowmodeSeconds < 0 || ch.SlowmodeSeconds > 21600 {
+			return "slowmode_seconds must be between 0 and 21600"
+		}
+	}
+	return ""
+}
+
+// withEveryoneRole returns roles with an @everyone role at position 0,
+// adding one with perms and moving the others up when the template has none.
+func withEveryoneRole(roles []templateRole, perms int64) []templateRole {
+	for _, role := range roles {
+		if role.Name == "@everyone" {
+			return roles
+		}
+	}
+	out := make([]templateRole, 0, len(roles)+1)
+	out = append(out, templateRole{Name: "@everyone", PermissionsAllow: perms})
+	for _, role := range roles {
+		role.Position++
+		out = append(out, role)
+	}
+	return out
+}
+
 // --- Handlers ---
 
 // HandleCreateGuildTemplate captures the current guild's structure as a template.
@@ -99,7 +184,7 @@ func (h *Handler) HandleCreateGuildTemplate(w http.ResponseWriter, r *http.Reque
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
 		return
 	}
 
@@ -178,7 +263,7 @@ func (h *Handler) HandleGetGuildTemplates(w http.ResponseWriter, r *http.Request
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -217,7 +302,7 @@ func (h *Handler) HandleGetGuildTemplate(w http.ResponseWriter, r *http.Request)
 	templateID := chi.URLParam(r, "templateID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -249,7 +334,7 @@ func (h *Handler) HandleDeleteGuildTemplate(w http.ResponseWriter, r *http.Reque
 	templateID := chi.URLParam(r, "templateID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
 		return
 	}
 
@@ -287,14 +372,14 @@ func (h *Handler) HandleApplyGuildTemplate(w http.ResponseWriter, r *http.Reques
 
 	// User must be a member of the source guild to access its templates.
 	if !h.isMember(r.Context(), sourceGuildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
 	var req applyTemplateRequest
 	if r.Body != nil && r.ContentLength > 0 {
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			apiutil.WriteError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+			apiutil.WriteCode(w, apierrors.InvalidBody, "")
 			return
 		}
 	}
@@ -333,7 +418,7 @@ func (h *Handler) HandleApplyGuildTemplate(w http.ResponseWriter, r *http.Reques
 	} else {
 		// Apply template to the source guild (additive).
 		if !h.hasGuildPermission(ctx, sourceGuildID, userID, permissions.ManageGuild) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission to apply templates")
+			apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission to apply templates")
 			return
 		}
 		if err := h.applyTemplateToGuild(ctx, sourceGuildID, data); err != nil {
@@ -344,13 +429,62 @@ func (h *Handler) HandleApplyGuildTemplate(w http.ResponseWriter, r *http.Reques
 
 		guild, err := h.getGuild(ctx, sourceGuildID)
 		if err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get guild")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to get guild")
 			return
 		}
 		apiutil.WriteJSON(w, http.StatusOK, guild)
 	}
 }
 
+// createGuildWithTemplate finishes HandleCreateGuild for a request that
+// names a saved template or carries one inline.
+func (h *Handler) createGuildWithTemplate(w http.ResponseWriter, r *http.Request, userID string, req createGuildRequest) {
+	if req.TemplateID != nil && req.Template != nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "conflicting_template", "Give either template_id or template, not both")
+		return
+	}
+
+	var data templateData
+	if req.Template != nil {
+		data = *req.Template
+		if msg := validateTemplateData(data); msg != "" {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_template", msg)
+			return
+		}
+	} else {
+		var raw json.RawMessage
+		var sourceGuildID string
+		err := h.Pool.QueryRow(r.Context(),
+			`SELECT template_data, guild_id FROM guild_templates WHERE id = $1`, *req.TemplateID,
+		).Scan(&raw, &sourceGuildID)
+		if err != nil && err != pgx.ErrNoRows {
+			apiutil.InternalError(w, h.Logger, "Failed to get template", err)
+			return
+		}
+		// Templates of guilds the caller is not in are reported as missing.
+		if err == pgx.ErrNoRows || !h.isMember(r.Context(), sourceGuildID, userID) {
+			apiutil.WriteError(w, http.StatusNotFound, "template_not_found", "Template not found")
+			return
+		}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			apiutil.InternalError(w, h.Logger, "Invalid template data", err)
+			return
+		}
+	}
+	if req.Description != nil {
+		data.GuildSettings.Description = req.Description
+	}
+
+	guild, err := h.createGuildFromTemplate(r.Context(), userID, req.Name, data)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create guild", err)
+		return
+	}
+
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildCreate, "GUILD_CREATE", guild.ID, guild)
+	apiutil.WriteJSON(w, http.StatusCreated, guild)
+}
+
 // --- Internal helpers ---
 
 // captureGuildStructure takes a snapshot of a guild's roles, channels,
@@ -459,10 +593,7 @@ func (h *Handler) createGuildFromTemplate(ctx context.Context, userID, guildName
 
 	defaultPerms := data.GuildSettings.DefaultPermissions
 	if defaultPerms == 0 {
-		defaultPerms = int64(permissions.ViewChannel | permissions.ReadHistory |
-			permissions.SendMessages | permissions.AddReactions |
-			permissions.Connect | permissions.Speak |
-			permissions.ChangeNickname | permissions.CreateInvites)
+		defaultPerms = int64(permissions.DefaultGuildPermissions)
 	}
 
 	var guild models.Guild
@@ -473,7 +604,7 @@ func (h *Handler) createGuildFromTemplate(ctx context.Context, userID, guildName
 			                     nsfw, verification_level, afk_timeout, created_at)
 			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 			 RETURNING id, instance_id, owner_id, name, description, icon_id, banner_id,
-			           default_permissions, flags, nsfw, discoverable, preferred_locale, max_members,
+			           default_permissions, flags, nsfw, discoverable, preferred_locale, timezone, max_members,
 			           verification_level, afk_channel_id, afk_timeout, created_at`,
 			guildID, h.InstanceID, userID, guildName, data.GuildSettings.Description,
 			defaultPerms, data.GuildSettings.NSFW, data.GuildSettings.VerificationLevel,
@@ -481,7 +612,7 @@ func (h *Handler) createGuildFromTemplate(ctx context.Context, userID, guildName
 		).Scan(
 			&guild.ID, &guild.InstanceID, &guild.OwnerID, &guild.Name, &guild.Description,
 			&guild.IconID, &guild.BannerID, &guild.DefaultPermissions, &guild.Flags,
-			&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.MaxMembers,
+			&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.Timezone, &guild.MaxMembers,
 			&guild.VerificationLevel, &guild.AFKChannelID, &guild.AFKTimeout, &guild.CreatedAt,
 		); err != nil {
 			return err
@@ -496,7 +627,7 @@ func (h *Handler) createGuildFromTemplate(ctx context.Context, userID, guildName
 		}
 
 		// Create roles from template.
-		for _, role := range data.Roles {
+		for _, role := range withEveryoneRole(data.Roles, defaultPerms) {
 			roleID := models.NewULID().String()
 			if _, err := tx.Exec(ctx,
 				`INSERT INTO roles (id, guild_id, name, color, hoist, mentionable, position,
@@ -567,7 +698,7 @@ func (h *Handler) createGuildFromTemplate(ctx context.Context, userID, guildName
 			}
 		}
 
-		return nil
+		return h.followInstanceAnnouncements(ctx, tx, guildID, h.newGuildDefaults(ctx).FollowChannelIDs)
 	})
 	if err != nil {
 		return nil, err
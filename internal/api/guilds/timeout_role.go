@@ -0,0 +1,219 @@
+// Guild timeout role handlers.
+// When enabled, timeouts are also expressed as a server-managed "Timed Out"
+// role with a deny override on every channel, so bots and federated views that
+// only understand roles and overrides still see the restriction. Mounted under
+// /api/v1/guilds/{guildID}/timeout-role.
+package guilds
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// timeoutRoleState is whether a guild backs timeouts with a role.
+type timeoutRoleState struct {
+	Enabled bool    `json:"enabled"`
+	RoleID  *string `json:"role_id"`
+}
+
+// canManageTimeoutRole reports whether the user may turn the timeout role on
+// or off, writing the error response if not.
+func (h *Handler) canManageTimeoutRole(w http.ResponseWriter, ctx context.Context, guildID, userID string) bool {
+	if !h.hasGuildPermission(ctx, guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return false
+	}
+	if !h.hasGuildPermission(ctx, guildID, userID, permissions.ManageRoles) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_ROLES permission")
+		return false
+	}
+	return true
+}
+
+// HandleGetTimeoutRole returns whether timeouts are backed by a role.
+// GET /api/v1/guilds/{guildID}/timeout-role
+func (h *Handler) HandleGetTimeoutRole(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var state timeoutRoleState
+	if roleID := apiutil.TimeoutRoleID(r.Context(), h.Pool, guildID); roleID != "" {
+		state = timeoutRoleState{Enabled: true, RoleID: &roleID}
+	}
+	apiutil.WriteJSON(w, http.StatusOK, state)
+}
+
+// HandleEnableTimeoutRole creates the guild's timeout role, overrides every
+// channel for it and gives it to members already timed out. Enabling an
+// already enabled guild re-applies the overrides.
+// PUT /api/v1/guilds/{guildID}/timeout-role
+func (h *Handler) HandleEnableTimeoutRole(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.canManageTimeoutRole(w, r.Context(), guildID, userID) {
+		return
+	}
+
+	var role *models.Role
+	var roleID string
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		var existing *string
+		if err := tx.QueryRow(r.Context(),
+			`SELECT timeout_role_id FROM guilds WHERE id = $1 FOR UPDATE`, guildID,
+		).Scan(&existing); err != nil {
+			return err
+		}
+		if existing != nil {
+			roleID = *existing
+			return nil
+		}
+
+		var maxPos int
+		if err := tx.QueryRow(r.Context(),
+			`SELECT COALESCE(MAX(position), 0) FROM roles WHERE guild_id = $1`, guildID,
+		).Scan(&maxPos); err != nil {
+			return err
+		}
+		role = &models.Role{}
+		if err := tx.QueryRow(r.Context(),
+			`INSERT INTO roles (id, guild_id, name, color, hoist, mentionable, position, permissions_allow, permissions_deny, created_at)
+			 VALUES ($1, $2, $3, NULL, false, false, $4, 0, 0, now())
+			 RETURNING id, guild_id, name, color, hoist, mentionable, position, permissions_allow, permissions_deny, version, created_at`,
+			models.NewULID().String(), guildID, apiutil.TimeoutRoleName, maxPos+1,
+		).Scan(&role.ID, &role.GuildID, &role.Name, &role.Color, &role.Hoist, &role.Mentionable,
+			&role.Position, &role.PermissionsAllow, &role.PermissionsDeny, &role.Version, &role.CreatedAt); err != nil {
+			return err
+		}
+		roleID = role.ID
+		_, err := tx.Exec(r.Context(),
+			`UPDATE guilds SET timeout_role_id = $2 WHERE id = $1`, guildID, roleID)
+		return err
+	})
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to enable timeout role", err)
+		return
+	}
+
+	if role != nil {
+		h.logAudit(r.Context(), guildID, userID, "timeout_role_enable", "role", roleID, nil)
+		h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildRoleCreate, "GUILD_ROLE_CREATE", guildID, role)
+	}
+	h.applyTimeoutRole(r.Context(), guildID, roleID)
+
+	apiutil.WriteJSON(w, http.StatusOK, timeoutRoleState{Enabled: true, RoleID: &roleID})
+}
+
+// HandleDisableTimeoutRole deletes the guild's timeout role and its channel
+// overrides. Timeouts themselves keep working without it.
+// DELETE /api/v1/guilds/{guildID}/timeout-role
+func (h *Handler) HandleDisableTimeoutRole(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.canManageTimeoutRole(w, r.Context(), guildID, userID) {
+		return
+	}
+
+	roleID := apiutil.TimeoutRoleID(r.Context(), h.Pool, guildID)
+	if roleID == "" {
+		apiutil.WriteNoContent(w)
+		return
+	}
+
+	var channelIDs []string
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(),
+			`DELETE FROM channel_permission_overrides
+			 WHERE target_type = 'role' AND target_id = $1
+			 RETURNING channel_id`, roleID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			channelIDs = append(channelIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// Deleting the role clears guilds.timeout_role_id and member_roles.
+		_, err = tx.Exec(r.Context(),
+			`DELETE FROM roles WHERE id = $1 AND guild_id = $2`, roleID, guildID)
+		return err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to disable timeout role", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "timeout_role_disable", "role", roleID, nil)
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildRoleDelete, "GUILD_ROLE_DELETE", guildID, map[string]string{
+		"guild_id": guildID, "role_id": roleID,
+	})
+	for _, channelID := range channelIDs {
+		h.EventBus.PublishChannelEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_UPDATE", channelID, map[string]string{
+			"channel_id": channelID,
+		})
+	}
+
+	apiutil.WriteNoContent(w)
+}
+
+// applyTimeoutRole brings the timeout role's channel overrides and holders up
+// to date. Failures are logged; the timeout worker retries them.
+func (h *Handler) applyTimeoutRole(ctx context.Context, guildID, roleID string) {
+	channelIDs, err := apiutil.ApplyTimeoutOverrides(ctx, h.Pool, guildID, roleID)
+	if err != nil {
+		h.Logger.Warn("failed to apply timeout role overrides",
+			slog.String("guild_id", guildID), slog.String("error", err.Error()))
+	}
+	for _, channelID := range channelIDs {
+		h.EventBus.PublishChannelEvent(ctx, events.SubjectChannelUpdate, "CHANNEL_UPDATE", channelID, map[string]string{
+			"channel_id": channelID,
+		})
+	}
+	if err := apiutil.SyncTimeoutRole(ctx, h.Pool, h.EventBus, guildID, roleID, ""); err != nil {
+		h.Logger.Warn("failed to sync timeout role",
+			slog.String("guild_id", guildID), slog.String("error", err.Error()))
+	}
+}
+
+// syncMemberTimeoutRole gives or takes the timeout role from one member after
+// their timeout changed, if the guild uses one.
+func (h *Handler) syncMemberTimeoutRole(ctx context.Context, guildID, memberID string) {
+	roleID := apiutil.TimeoutRoleID(ctx, h.Pool, guildID)
+	if roleID == "" {
+		return
+	}
+	if err := apiutil.SyncTimeoutRole(ctx, h.Pool, h.EventBus, guildID, roleID, memberID); err != nil {
+		h.Logger.Warn("failed to sync timeout role",
+			slog.String("guild_id", guildID), slog.String("user_id", memberID),
+			slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,101 @@
+// Guild webhook masquerade policy handlers.
+// The policy restricts the username and avatar_url overrides that incoming
+// webhook executions may set per message. It is enforced by the webhook
+// execute endpoint. Mounted under /api/v1/guilds/{guildID}/webhook-policy.
+package guilds
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+type updateWebhookPolicyRequest struct {
+	AllowNameOverride   *bool   `json:"allow_name_override"`
+	AllowAvatarOverride *bool   `json:"allow_avatar_override"`
+	BlockMemberNames    *bool   `json:"block_member_names"`
+	RequireTag          *bool   `json:"require_tag"`
+	Tag                 *string `json:"tag"`
+}
+
+// HandleGetWebhookPolicy returns the guild's webhook masquerade policy, or
+// the defaults if it has never been changed.
+// GET /api/v1/guilds/{guildID}/webhook-policy
+func (h *Handler) HandleGetWebhookPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageWebhooks) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
+		return
+	}
+
+	policy := models.DefaultWebhookPolicy(guildID)
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT allow_name_override, allow_avatar_override, block_member_names,
+		        require_tag, tag, updated_at
+		 FROM guild_webhook_policy WHERE guild_id = $1`, guildID,
+	).Scan(&policy.AllowNameOverride, &policy.AllowAvatarOverride, &policy.BlockMemberNames,
+		&policy.RequireTag, &policy.Tag, &policy.UpdatedAt)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to get webhook policy", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, policy)
+}
+
+// HandleUpdateWebhookPolicy changes the guild's webhook masquerade policy.
+// PATCH /api/v1/guilds/{guildID}/webhook-policy
+func (h *Handler) HandleUpdateWebhookPolicy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.ManageGuild) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var req updateWebhookPolicyRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Tag != nil && *req.Tag != "[BOT]" && *req.Tag != "[APP]" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_tag", "tag must be [BOT] or [APP]")
+		return
+	}
+
+	var policy models.GuildWebhookPolicy
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO guild_webhook_policy (guild_id, allow_name_override, allow_avatar_override,
+		             block_member_names, require_tag, tag, updated_at)
+		 VALUES ($1, COALESCE($2, true), COALESCE($3, true), COALESCE($4, true),
+		         COALESCE($5, false), COALESCE($6, '[BOT]'), now())
+		 ON CONFLICT (guild_id) DO UPDATE SET
+		     allow_name_override = COALESCE($2, guild_webhook_policy.allow_name_override),
+		     allow_avatar_override = COALESCE($3, guild_webhook_policy.allow_avatar_override),
+		     block_member_names = COALESCE($4, guild_webhook_policy.block_member_names),
+		     require_tag = COALESCE($5, guild_webhook_policy.require_tag),
+		     tag = COALESCE($6, guild_webhook_policy.tag),
+		     updated_at = now()
+		 RETURNING guild_id, allow_name_override, allow_avatar_override, block_member_names,
+		           require_tag, tag, updated_at`,
+		guildID, req.AllowNameOverride, req.AllowAvatarOverride, req.BlockMemberNames,
+		req.RequireTag, req.Tag,
+	).Scan(&policy.GuildID, &policy.AllowNameOverride, &policy.AllowAvatarOverride,
+		&policy.BlockMemberNames, &policy.RequireTag, &policy.Tag, &policy.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update webhook policy", err)
+		return
+	}
+
+	h.logAudit(r.Context(), guildID, userID, "WEBHOOK_POLICY_UPDATE", "guild", guildID, nil)
+	apiutil.WriteJSON(w, http.StatusOK, policy)
+}
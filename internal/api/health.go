@@ -107,6 +107,9 @@ func (s *Server) handleDeepHealthCheck(w http.ResponseWriter, r *http.Request) {
 		s3Health := s.checkServiceHealth("storage", checkTimeout, func(ctx context.Context) error {
 			return s.Media.HealthCheck(ctx)
 		})
+		if statuses := s.Media.RegionStatuses(); len(statuses) > 1 {
+			s3Health.Details = map[string]interface{}{"regions": statuses}
+		}
 		services["storage"] = s3Health
 		if s3Health.Status == "unhealthy" {
 			if overallStatus == "ok" {
@@ -134,7 +137,11 @@ func (s *Server) handleDeepHealthCheck(w http.ResponseWriter, r *http.Request) {
 
 	// --- LiveKit (Voice) ---
 	if s.Voice != nil {
-		services["voice"] = ServiceHealth{Status: "healthy", Details: "connected"}
+		voiceHealth := ServiceHealth{Status: "healthy", Details: "connected"}
+		if statuses := s.Voice.RegionStatuses(); len(statuses) > 1 {
+			voiceHealth.Details = map[string]interface{}{"regions": statuses}
+		}
+		services["voice"] = voiceHealth
 	} else {
 		services["voice"] = ServiceHealth{Status: "disabled"}
 	}
@@ -17,6 +17,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -183,7 +184,7 @@ func (h *Handler) HandleCreateIntegration(w http.ResponseWriter, r *http.Request
 
 	// Verify user is a guild member.
 	if err := h.requireGuildMember(r, guildID, userID); err != nil {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -239,7 +240,7 @@ func (h *Handler) HandleGetIntegration(w http.ResponseWriter, r *http.Request) {
 	).Scan(&i.ID, &i.GuildID, &i.IntegrationType, &i.ChannelID,
 		&i.Name, &i.Enabled, &configJSON, &i.CreatedBy, &i.CreatedAt, &i.UpdatedAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Integration not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Integration not found")
 		return
 	}
 	if err != nil {
@@ -297,7 +298,7 @@ func (h *Handler) HandleUpdateIntegration(w http.ResponseWriter, r *http.Request
 		&i.ID, &i.GuildID, &i.IntegrationType, &i.ChannelID,
 		&i.Name, &i.Enabled, &configJSON, &i.CreatedBy, &i.CreatedAt, &i.UpdatedAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Integration not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Integration not found")
 		return
 	}
 	if err != nil {
@@ -323,7 +324,7 @@ func (h *Handler) HandleDeleteIntegration(w http.ResponseWriter, r *http.Request
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Integration not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Integration not found")
 		return
 	}
 
@@ -391,7 +392,7 @@ func (h *Handler) HandleAddActivityPubFollow(w http.ResponseWriter, r *http.Requ
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT integration_type FROM guild_integrations WHERE id = $1`, integrationID).Scan(&intType)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Integration not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Integration not found")
 		return
 	}
 	if intType != "activitypub" {
@@ -435,7 +436,7 @@ func (h *Handler) HandleRemoveActivityPubFollow(w http.ResponseWriter, r *http.R
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Follow not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Follow not found")
 		return
 	}
 
@@ -595,7 +596,7 @@ func (h *Handler) HandleUpdateBridgeConnection(w http.ResponseWriter, r *http.Re
 		&bc.Enabled, &configJSON, &bc.Status, &bc.LastError, &bc.CreatedBy,
 		&bc.CreatedAt, &bc.UpdatedAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Bridge connection not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Bridge connection not found")
 		return
 	}
 	if err != nil {
@@ -621,7 +622,7 @@ func (h *Handler) HandleDeleteBridgeConnection(w http.ResponseWriter, r *http.Re
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Bridge connection not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Bridge connection not found")
 		return
 	}
 
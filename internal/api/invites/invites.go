@@ -4,6 +4,7 @@
 package invites
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -29,6 +31,9 @@ type Handler struct {
 	FedProxy   apiutil.FederationProxy
 }
 
+// errAlreadyMember rolls back an invite accept that raced another join.
+var errAlreadyMember = errors.New("already a member")
+
 // parseRemoteInvite checks if an invite code contains a remote domain.
 // Supported formats: "CODE@domain.com" or "domain.com/CODE".
 // Returns (localCode, domain, isRemote).
@@ -103,9 +108,10 @@ func (h *Handler) HandleGetInvite(w http.ResponseWriter, r *http.Request) {
 	}
 
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
-		"invite":       inv,
-		"guild_name":   guildName,
-		"member_count": memberCount,
+		"invite":               inv,
+		"guild_name":           guildName,
+		"member_count":         memberCount,
+		"invites_paused_until": apiutil.InvitesPausedUntil(r.Context(), h.Pool, inv.GuildID),
 	})
 }
 
@@ -128,7 +134,14 @@ func (h *Handler) handleGetRemoteInvite(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	// We're federated — return a response indicating this is a remote invite.
+	// Fetch the real preview from the invite's home instance when federation
+	// is running here.
+	if h.FedProxy != nil {
+		h.FedProxy.ProxyResolveRemoteInvite(w, r, domain, code)
+		return
+	}
+
+	// Without the sync service, only report that this is a remote invite.
 	// The frontend will use joinFederatedGuild(domain, null, code) to accept.
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"federated":       true,
@@ -199,6 +212,10 @@ func (h *Handler) HandleAcceptInvite(w http.ResponseWriter, r *http.Request) {
 		apiutil.WriteError(w, http.StatusGone, "invite_exhausted", "This invite has reached its maximum uses")
 		return
 	}
+	if until := apiutil.InvitesPausedUntil(r.Context(), h.Pool, inv.GuildID); until != nil {
+		apiutil.WriteInvitesPaused(w, *until)
+		return
+	}
 
 	// Check if user is banned from this guild.
 	var banned bool
@@ -210,6 +227,17 @@ func (h *Handler) HandleAcceptInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check the guild accepts members from the user's instance.
+	ref, err := apiutil.CheckUserJoinOrigin(r.Context(), h.Pool, h.InstanceID, inv.GuildID, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to check join origin policy", err)
+		return
+	}
+	if ref != nil {
+		apiutil.WriteJoinOriginRefusal(w, ref)
+		return
+	}
+
 	// Check if already a member.
 	var exists bool
 	err = h.Pool.QueryRow(r.Context(),
@@ -234,38 +262,41 @@ func (h *Handler) HandleAcceptInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	now := time.Now().UTC()
+	var joinedAt time.Time
 	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
 		// Add guild member.
-		_, err := tx.Exec(r.Context(),
-			`INSERT INTO guild_members (guild_id, user_id, nickname, joined_at, deaf, mute)
-			 VALUES ($1, $2, NULL, $3, false, false)`,
-			inv.GuildID, userID, now)
+		var added bool
+		var err error
+		joinedAt, added, err = apiutil.AddGuildMember(r.Context(), tx, inv.GuildID, userID)
 		if err != nil {
 			return err
 		}
+		if !added {
+			return errAlreadyMember
+		}
 
 		// Increment invite usage.
 		_, err = tx.Exec(r.Context(),
 			`UPDATE invites SET uses = uses + 1 WHERE code = $1`, code)
-		if err != nil {
-			return err
-		}
-
-		return nil
+		return err
 	})
-	if err != nil {
+	switch {
+	case errors.Is(err, errAlreadyMember):
+		apiutil.WriteError(w, http.StatusConflict, "already_member", "You are already a member of this guild")
+		return
+	case errors.Is(err, apiutil.ErrJoinBanned):
+		apiutil.WriteError(w, http.StatusForbidden, "banned", "You are banned from this guild")
+		return
+	case errors.Is(err, apiutil.ErrJoinGuildFull):
+		apiutil.WriteError(w, http.StatusForbidden, "guild_full", "This guild has reached its maximum member count")
+		return
+	case err != nil:
 		apiutil.InternalError(w, h.Logger, "Failed to join guild", err)
 		return
 	}
 
 	// Publish member add event.
-	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildMemberAdd, "GUILD_MEMBER_ADD", inv.GuildID,
-		map[string]interface{}{
-			"guild_id":  inv.GuildID,
-			"user_id":   userID,
-			"joined_at": now,
-		})
+	apiutil.PublishMemberAdd(r.Context(), h.EventBus, inv.GuildID, userID, joinedAt)
 
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"guild_id": inv.GuildID,
@@ -274,7 +305,9 @@ func (h *Handler) HandleAcceptInvite(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleAcceptRemoteInvite checks federation status for a remote invite and
-// returns instructions for the client to proceed via the federation join endpoint.
+// joins the guild through its home instance, or, when federation is not
+// running, returns instructions for the client to use the federation join
+// endpoint.
 func (h *Handler) handleAcceptRemoteInvite(w http.ResponseWriter, r *http.Request, userID, code, domain string) {
 	ctx := r.Context()
 
@@ -292,6 +325,13 @@ func (h *Handler) handleAcceptRemoteInvite(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Redeem the invite on its home instance, which checks expiry and counts
+	// the use.
+	if h.FedProxy != nil {
+		h.FedProxy.ProxyAcceptRemoteInvite(w, r, userID, domain, code)
+		return
+	}
+
 	// Return redirect to federation join endpoint.
 	// The client should call POST /api/v1/federation/guilds/join with
 	// { instance_domain, invite_code }.
@@ -340,7 +380,7 @@ func (h *Handler) HandleDeleteInvite(w http.ResponseWriter, r *http.Request) {
 					  AND (r.permissions & $3) = $3
 				)`, guildID, userID, permissions.ManageGuild).Scan(&hasManageGuild)
 			if err != nil || !hasManageGuild {
-				apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to delete this invite")
+				apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to delete this invite")
 				return
 			}
 		}
@@ -0,0 +1,29 @@
+package invites
+
+import (
+	"time"
+
+	"github.com/amityvox/amityvox/internal/api/openapi"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// invitePreview is the body of a local invite lookup.
+type invitePreview struct {
+	Invite             models.Invite `json:"invite"`
+	GuildName          string        `json:"guild_name"`
+	MemberCount        int           `json:"member_count"`
+	InvitesPausedUntil *time.Time    `json:"invites_paused_until"`
+}
+
+// inviteAccepted is the body returned after joining through a local invite.
+type inviteAccepted struct {
+	GuildID string `json:"guild_id"`
+	Joined  bool   `json:"joined"`
+}
+
+// Operations describes the request and response bodies of the invite routes
+// for the OpenAPI document.
+var Operations = []openapi.Operation{
+	{Method: "GET", Path: "/invites/{code}", Summary: "Preview an invite", Response: invitePreview{}},
+	{Method: "POST", Path: "/invites/{code}", Summary: "Join a guild through an invite", Response: inviteAccepted{}},
+}
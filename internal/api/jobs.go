@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/workers"
+)
+
+// jobRunKeyset pages job run history newest first.
+var jobRunKeyset = apiutil.Keyset{Desc: true, Columns: []apiutil.KeyColumn{
+	{Expr: "started_at", Type: "timestamptz"},
+	{Expr: "id", Type: "text"},
+}}
+
+// handleListJobs returns the background jobs registered on this node with
+// their schedules and how many runs are in flight.
+// GET /api/v1/admin/jobs
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	if s.Jobs == nil {
+		WriteJSON(w, http.StatusOK, []workers.JobInfo{})
+		return
+	}
+	WriteJSON(w, http.StatusOK, s.Jobs.Jobs())
+}
+
+// handleRunJob starts a run of a job now, outside its schedule. The run
+// proceeds in the background; its outcome shows in the run history.
+// POST /api/v1/admin/jobs/{jobName}/run
+func (s *Server) handleRunJob(w http.ResponseWriter, r *http.Request) {
+	if s.Jobs == nil {
+		WriteError(w, http.StatusNotFound, "unknown_job", "Job not found")
+		return
+	}
+	runID, err := s.Jobs.TriggerJob(chi.URLParam(r, "jobName"))
+	switch {
+	case errors.Is(err, workers.ErrUnknownJob):
+		WriteError(w, http.StatusNotFound, "unknown_job", "Job not found")
+	case errors.Is(err, workers.ErrJobBusy):
+		WriteError(w, http.StatusConflict, "job_busy", "Job is already running")
+	case err != nil:
+		InternalError(w, s.Logger, "Failed to start job", err)
+	default:
+		WriteJSON(w, http.StatusAccepted, map[string]string{"run_id": runID})
+	}
+}
+
+// handleListJobRuns pages recorded job runs, newest first, optionally for one
+// job or with one status.
+// GET /api/v1/admin/jobs/runs?job=&status=&before=&after=&limit=
+func (s *Server) handleListJobRuns(w http.ResponseWriter, r *http.Request) {
+	page, ok := apiutil.ParsePage(w, r, jobRunKeyset, apiutil.DefaultPageLimit)
+	if !ok {
+		return
+	}
+
+	query := `SELECT ` + workers.JobRunColumns + ` FROM job_runs WHERE true`
+	var args []interface{}
+	if job := r.URL.Query().Get("job"); job != "" {
+		args = append(args, job)
+		query += fmt.Sprintf(" AND job = $%d", len(args))
+	}
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case workers.RunRunning, workers.RunSucceeded, workers.RunFailed:
+		args = append(args, status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	default:
+		WriteError(w, http.StatusBadRequest, "invalid_status", "Status must be running, succeeded or failed")
+		return
+	}
+	where, cursorArgs := page.Where(jobRunKeyset, len(args)+1)
+	args = append(args, cursorArgs...)
+	args = append(args, page.FetchLimit())
+	query += where + " ORDER BY " + page.OrderBy(jobRunKeyset) + fmt.Sprintf(" LIMIT $%d", len(args))
+
+	rows, err := s.DB.Pool.Query(r.Context(), query, args...)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to list job runs", err)
+		return
+	}
+	defer rows.Close()
+
+	runs := []workers.JobRun{}
+	for rows.Next() {
+		run, err := workers.ScanJobRun(rows)
+		if err != nil {
+			InternalError(w, s.Logger, "Failed to read job run", err)
+			return
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		InternalError(w, s.Logger, "Failed to list job runs", err)
+		return
+	}
+
+	runs, next := apiutil.FinishPage(page, runs, func(run workers.JobRun) []interface{} {
+		return []interface{}{run.StartedAt, run.ID}
+	})
+	apiutil.WritePage(w, runs, next)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// readOnlyRefresh is how long the active read-only window is cached. Writes
+// may be accepted or refused for this long after a window starts, ends or is
+// cancelled.
+const readOnlyRefresh = 10 * time.Second
+
+// readOnlyGate caches the end of the read-only maintenance window in effect,
+// if any, so writes can be checked without a query per request.
+type readOnlyGate struct {
+	pool   *pgxpool.Pool
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	until   time.Time // zero when no read-only window is active
+	checked time.Time
+}
+
+// activeUntil returns when the current read-only window ends, or the zero
+// time when writes are allowed. Lookup errors allow writes rather than
+// locking the instance.
+func (g *readOnlyGate) activeUntil(ctx context.Context, now time.Time) time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.Sub(g.checked) < readOnlyRefresh {
+		if now.Before(g.until) {
+			return g.until
+		}
+		return time.Time{}
+	}
+
+	var until time.Time
+	err := g.pool.QueryRow(ctx,
+		`SELECT max(ends_at) FROM maintenance_windows
+		 WHERE read_only AND cancelled_at IS NULL AND starts_at <= now() AND ends_at > now()
+		 HAVING count(*) > 0`).Scan(&until)
+	if err != nil && err != pgx.ErrNoRows {
+		g.logger.Warn("checking for read-only maintenance failed", slog.String("error", err.Error()))
+	}
+	g.until, g.checked = until, now
+	if now.Before(until) {
+		return until
+	}
+	return time.Time{}
+}
+
+// readOnlyExempt reports whether a write stays allowed during read-only
+// maintenance: admin routes and remote `amityvox admin` actions, so the
+// window can be extended or cancelled, login, so an admin whose session
+// lapsed can get back in, and token leak reports, so leaked credentials are
+// still revoked.
+func readOnlyExempt(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, "/api/v1/admin/") ||
+		r.URL.Path == "/api/v1/admin-cli/run" ||
+		r.URL.Path == "/api/v1/auth/login" ||
+		r.URL.Path == "/api/v1/token-leaks"
+}
+
+// retryAfterSeconds is the Retry-After value for a window ending at until.
+func retryAfterSeconds(until, now time.Time) int {
+	return max(1, int(math.Ceil(until.Sub(now).Seconds())))
+}
+
+// readOnlyMaintenance returns middleware that answers writes with 503 and a
+// Retry-After while a read-only maintenance window is active.
+func (s *Server) readOnlyMaintenance() func(http.Handler) http.Handler {
+	gate := &readOnlyGate{pool: s.DB.Pool, logger: s.Logger}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if readOnlyExempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			now := time.Now()
+			until := gate.activeUntil(r.Context(), now)
+			if until.IsZero() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(until, now)))
+			WriteError(w, http.StatusServiceUnavailable, "read_only_maintenance",
+				fmt.Sprintf("The instance is read-only for maintenance until %s", until.UTC().Format(time.RFC3339)))
+		})
+	}
+}
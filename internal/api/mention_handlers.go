@@ -0,0 +1,157 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Page sizes for the mentions inbox.
+const (
+	defaultMentionsLimit = 25
+	maxMentionsLimit     = 100
+)
+
+// MentionInboxItem is one message in a user's mentions inbox, with enough
+// context to show where it was posted without loading the channel.
+type MentionInboxItem struct {
+	Message     models.Message `json:"message"`
+	GuildID     *string        `json:"guild_id"`
+	GuildName   *string        `json:"guild_name"`
+	ChannelName *string        `json:"channel_name"`
+	Unread      bool           `json:"unread"`
+}
+
+// parseMentionsLimit reads the limit query parameter, falling back to the
+// default for missing or out of range values.
+func parseMentionsLimit(raw string) int {
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 || limit > maxMentionsLimit {
+		return defaultMentionsLimit
+	}
+	return limit
+}
+
+// handleGetMentions handles GET /api/v1/users/@me/mentions.
+// Lists messages mentioning the user directly or through one of their roles,
+// newest first, across every guild and DM they can still see. Dismissed
+// mentions, the user's own messages and messages from blocked users are left
+// out. A mention is unread until the user's read state in its channel passes
+// it.
+// Query params: before (message ID), limit (default 25, max 100), guild_id,
+// unread (true to list only unread mentions).
+func (s *Server) handleGetMentions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	q := r.URL.Query()
+
+	before := q.Get("before")
+	if before != "" && !validIDPattern.MatchString(before) {
+		WriteError(w, http.StatusBadRequest, "invalid_before", "Invalid before format")
+		return
+	}
+	guildID := q.Get("guild_id")
+	if guildID != "" && !validIDPattern.MatchString(guildID) {
+		WriteError(w, http.StatusBadRequest, "invalid_guild_id", "Invalid guild_id format")
+		return
+	}
+	unreadOnly := q.Get("unread") == "true"
+	limit := parseMentionsLimit(q.Get("limit"))
+
+	rows, err := s.DB.Pool.Query(r.Context(),
+		`SELECT m.id, m.channel_id, m.author_id, m.content, m.nonce, m.message_type, m.edited_at, m.flags,
+		        m.reply_to_ids, m.mention_user_ids, m.mention_role_ids, m.mention_here,
+		        m.thread_id, m.masquerade_name, m.masquerade_avatar, m.masquerade_color,
+		        m.encrypted, m.encryption_session_id, m.created_at,
+		        c.guild_id, g.name, c.name,
+		        (rs.last_read_id IS NULL OR m.id > rs.last_read_id) AS unread
+		 FROM messages m
+		 JOIN channels c ON c.id = m.channel_id
+		 LEFT JOIN guilds g ON g.id = c.guild_id
+		 LEFT JOIN read_state rs ON rs.user_id = $1 AND rs.channel_id = m.channel_id
+		 WHERE (m.mention_user_ids @> ARRAY[$1::text]
+		        OR m.mention_role_ids && ARRAY(SELECT role_id FROM member_roles WHERE user_id = $1 AND guild_id = c.guild_id))
+		   AND m.author_id <> $1
+		   AND m.flags & $2 = 0
+		   AND ($3 = '' OR m.id < $3)
+		   AND ($4 = '' OR c.guild_id = $4)
+		   AND (c.guild_id IS NULL AND EXISTS (SELECT 1 FROM channel_recipients cr WHERE cr.channel_id = c.id AND cr.user_id = $1)
+		        OR EXISTS (SELECT 1 FROM guild_members gm WHERE gm.guild_id = c.guild_id AND gm.user_id = $1))
+		   AND NOT EXISTS (SELECT 1 FROM user_relationships ur
+		                   WHERE ur.user_id = $1 AND ur.target_id = m.author_id AND ur.status = 'blocked')
+		   AND NOT EXISTS (SELECT 1 FROM mention_dismissals md WHERE md.user_id = $1 AND md.message_id = m.id)
+		   AND (NOT $5 OR rs.last_read_id IS NULL OR m.id > rs.last_read_id)
+		 ORDER BY m.id DESC
+		 LIMIT $6`,
+		userID, models.MessageFlagQuarantined|models.MessageFlagDeleted, before, guildID, unreadOnly, limit)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to get mentions", err)
+		return
+	}
+	defer rows.Close()
+
+	items := make([]MentionInboxItem, 0)
+	for rows.Next() {
+		var it MentionInboxItem
+		m := &it.Message
+		if err := rows.Scan(
+			&m.ID, &m.ChannelID, &m.AuthorID, &m.Content, &m.Nonce, &m.MessageType,
+			&m.EditedAt, &m.Flags, &m.ReplyToIDs, &m.MentionUserIDs, &m.MentionRoleIDs,
+			&m.MentionHere, &m.ThreadID, &m.MasqueradeName, &m.MasqueradeAvatar,
+			&m.MasqueradeColor, &m.Encrypted, &m.EncryptionSessionID, &m.CreatedAt,
+			&it.GuildID, &it.GuildName, &it.ChannelName, &it.Unread,
+		); err != nil {
+			InternalError(w, s.Logger, "Failed to read mentions", err)
+			return
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		InternalError(w, s.Logger, "Failed to read mentions", err)
+		return
+	}
+
+	messages := make([]models.Message, len(items))
+	for i := range items {
+		messages[i] = items[i].Message
+	}
+	s.enrichSearchMessagesWithAuthors(r.Context(), messages)
+	s.enrichSearchMessagesWithAttachments(r.Context(), messages)
+	s.enrichSearchMessagesWithEmbeds(r.Context(), messages)
+	for i := range items {
+		items[i].Message = messages[i]
+	}
+
+	WriteJSON(w, http.StatusOK, items)
+}
+
+// handleDismissMention handles DELETE /api/v1/users/@me/mentions/{messageID}.
+// Removes a mention from the user's inbox without marking its channel read.
+func (s *Server) handleDismissMention(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	messageID := chi.URLParam(r, "messageID")
+
+	tag, err := s.DB.Pool.Exec(r.Context(),
+		`INSERT INTO mention_dismissals (user_id, message_id)
+		 SELECT $1, id FROM messages WHERE id = $2
+		 ON CONFLICT DO NOTHING`, userID, messageID)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to dismiss mention", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		s.DB.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM messages WHERE id = $1)`, messageID).Scan(&exists)
+		if !exists {
+			WriteCode(w, apierrors.MessageNotFound, "")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
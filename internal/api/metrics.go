@@ -4,11 +4,18 @@
 package api
 
 import (
+	"cmp"
 	"fmt"
+	"io"
 	"net/http"
 	"runtime"
+	"slices"
 	"sync/atomic"
 	"time"
+
+	"github.com/amityvox/amityvox/internal/database"
+	"github.com/amityvox/amityvox/internal/gateway"
+	"github.com/amityvox/amityvox/internal/slo"
 )
 
 // Metrics tracks lightweight counters for the /metrics endpoint.
@@ -89,8 +96,199 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "# TYPE amityvox_memory_sys_bytes gauge\n")
 	fmt.Fprintf(w, "amityvox_memory_sys_bytes %d\n\n", mem.Sys)
 
+	if s.GatewayConnections != nil {
+		writeGatewayConnectionMetrics(w, s.GatewayConnections())
+	}
+	if s.GatewayLatency != nil {
+		writeGatewayLatencyMetrics(w, s.GatewayLatency())
+	}
+	if s.GatewayShards != nil {
+		s.writeGatewayShardMetrics(w, s.GatewayShards())
+	}
+	writeQueryMetrics(w, s.DB.QueryStats())
+	writeSLOMetrics(w, slo.Snapshot())
+
 	uptime := time.Since(m.StartTime).Seconds()
 	fmt.Fprintf(w, "# HELP amityvox_uptime_seconds Time since server start.\n")
 	fmt.Fprintf(w, "# TYPE amityvox_uptime_seconds gauge\n")
 	fmt.Fprintf(w, "amityvox_uptime_seconds %f\n", uptime)
 }
+
+// writeGatewayConnectionMetrics writes gauges for this node's gateway
+// sessions. Per-guild traffic is left to the admin API: a series per guild
+// would grow without bound.
+func writeGatewayConnectionMetrics(w io.Writer, st gateway.ConnectionStats) {
+	fmt.Fprintf(w, "# HELP amityvox_gateway_sessions Connected gateway sessions, identified or not.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_sessions gauge\n")
+	fmt.Fprintf(w, "amityvox_gateway_sessions %d\n\n", st.Sessions)
+
+	fmt.Fprintf(w, "# HELP amityvox_gateway_sessions_identified Gateway sessions that completed IDENTIFY.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_sessions_identified gauge\n")
+	fmt.Fprintf(w, "amityvox_gateway_sessions_identified %d\n\n", st.Identified)
+
+	fmt.Fprintf(w, "# HELP amityvox_gateway_users Distinct users with a gateway session.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_users gauge\n")
+	fmt.Fprintf(w, "amityvox_gateway_users %d\n\n", st.Users)
+
+	fmt.Fprintf(w, "# HELP amityvox_gateway_bot_sessions Identified gateway sessions of bot accounts.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_bot_sessions gauge\n")
+	fmt.Fprintf(w, "amityvox_gateway_bot_sessions %d\n\n", st.Bots)
+
+	fmt.Fprintf(w, "# HELP amityvox_gateway_sessions_opened_total Gateway sessions opened since start.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_sessions_opened_total counter\n")
+	fmt.Fprintf(w, "amityvox_gateway_sessions_opened_total %d\n\n", st.ConnectedTotal)
+}
+
+// writeGatewayLatencyMetrics writes a histogram of heartbeat round trips
+// labelled with the node's deployment region, so per-region degradation
+// shows up when nodes are aggregated.
+func writeGatewayLatencyMetrics(w io.Writer, st gateway.LatencyStats) {
+	fmt.Fprintf(w, "# HELP amityvox_gateway_heartbeat_rtt_seconds Gateway ping round trip measured on heartbeat.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_heartbeat_rtt_seconds histogram\n")
+	for i, bound := range gateway.RTTBuckets {
+		fmt.Fprintf(w, "amityvox_gateway_heartbeat_rtt_seconds_bucket{region=%q,le=\"%g\"} %d\n", st.Region, bound.Seconds(), st.Buckets[i])
+	}
+	fmt.Fprintf(w, "amityvox_gateway_heartbeat_rtt_seconds_bucket{region=%q,le=\"+Inf\"} %d\n", st.Region, st.Count)
+	fmt.Fprintf(w, "amityvox_gateway_heartbeat_rtt_seconds_sum{region=%q} %f\n", st.Region, st.Total.Seconds())
+	fmt.Fprintf(w, "amityvox_gateway_heartbeat_rtt_seconds_count{region=%q} %d\n\n", st.Region, st.Count)
+}
+
+// writeSLOMetrics writes the sampled message delivery indicators: a latency
+// histogram, good and total counts, the objective, and the error budget burn
+// rate over each window. See deploy/prometheus/alerts.yml for the burn rate
+// alerts built on them.
+func writeSLOMetrics(w io.Writer, stats []slo.Stats) {
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP amityvox_slo_delivery_latency_seconds Latency from message send to delivery, for sampled sends.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_slo_delivery_latency_seconds histogram\n")
+	for _, st := range stats {
+		for i, bound := range slo.LatencyBuckets {
+			fmt.Fprintf(w, "amityvox_slo_delivery_latency_seconds_bucket{sli=%q,le=\"%g\"} %d\n", st.SLI, bound.Seconds(), st.Buckets[i])
+		}
+		fmt.Fprintf(w, "amityvox_slo_delivery_latency_seconds_bucket{sli=%q,le=\"+Inf\"} %d\n", st.SLI, st.Count)
+		fmt.Fprintf(w, "amityvox_slo_delivery_latency_seconds_sum{sli=%q} %f\n", st.SLI, st.Total.Seconds())
+		fmt.Fprintf(w, "amityvox_slo_delivery_latency_seconds_count{sli=%q} %d\n", st.SLI, st.Count)
+	}
+
+	fmt.Fprintf(w, "\n# HELP amityvox_slo_events_total Sampled deliveries measured against the SLO.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_slo_events_total counter\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_slo_events_total{sli=%q} %d\n", st.SLI, st.Count)
+	}
+	fmt.Fprintf(w, "\n# HELP amityvox_slo_good_events_total Sampled deliveries that succeeded within the threshold.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_slo_good_events_total counter\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_slo_good_events_total{sli=%q} %d\n", st.SLI, st.Good)
+	}
+	fmt.Fprintf(w, "\n# HELP amityvox_slo_objective Fraction of deliveries that must be good.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_slo_objective gauge\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_slo_objective{sli=%q} %g\n", st.SLI, st.Target)
+	}
+	fmt.Fprintf(w, "\n# HELP amityvox_slo_threshold_seconds Latency a delivery must complete within to be good.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_slo_threshold_seconds gauge\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_slo_threshold_seconds{sli=%q} %g\n", st.SLI, st.Threshold.Seconds())
+	}
+	fmt.Fprintf(w, "\n# HELP amityvox_slo_burn_rate Error budget burn rate on this node; 1 spends the budget exactly.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_slo_burn_rate gauge\n")
+	for _, st := range stats {
+		for i, window := range slo.Windows {
+			fmt.Fprintf(w, "amityvox_slo_burn_rate{sli=%q,window=%q} %f\n", st.SLI, promDuration(window), st.BurnRate[i])
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// promDuration writes a window the way PromQL does, e.g. "5m" or "6h".
+func promDuration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
+
+// writeGatewayShardMetrics writes per-shard gauges and counters for the
+// gateway's event fan-out workers.
+func (s *Server) writeGatewayShardMetrics(w io.Writer, stats []gateway.ShardStats) {
+	fmt.Fprintf(w, "# HELP amityvox_gateway_shards Current number of gateway dispatch shards.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_shards gauge\n")
+	fmt.Fprintf(w, "amityvox_gateway_shards %d\n\n", len(stats))
+
+	fmt.Fprintf(w, "# HELP amityvox_gateway_shard_queue_depth Events waiting in each dispatch shard.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_shard_queue_depth gauge\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_gateway_shard_queue_depth{shard=\"%d\"} %d\n", st.Shard, st.Queued)
+	}
+	fmt.Fprintf(w, "\n# HELP amityvox_gateway_shard_events_total Events fanned out by each dispatch shard.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_shard_events_total counter\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_gateway_shard_events_total{shard=\"%d\"} %d\n", st.Shard, st.Dispatched)
+	}
+	fmt.Fprintf(w, "\n# HELP amityvox_gateway_shard_stalls_total Enqueues that blocked on a full shard queue.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_shard_stalls_total counter\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_gateway_shard_stalls_total{shard=\"%d\"} %d\n", st.Shard, st.Stalled)
+	}
+	fmt.Fprintf(w, "\n# HELP amityvox_gateway_shard_lag_seconds Queue wait of the shard's most recent event.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_shard_lag_seconds gauge\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_gateway_shard_lag_seconds{shard=\"%d\"} %f\n", st.Shard, st.LastLag.Seconds())
+	}
+	fmt.Fprintf(w, "\n# HELP amityvox_gateway_shard_busy_seconds_total Time each shard spent fanning out events.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_gateway_shard_busy_seconds_total counter\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_gateway_shard_busy_seconds_total{shard=\"%d\"} %f\n", st.Shard, st.Busy.Seconds())
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// writeQueryMetrics writes a duration histogram and error counter for each
+// database query name.
+func writeQueryMetrics(w io.Writer, stats []database.QueryStat) {
+	fmt.Fprintf(w, "# HELP amityvox_db_query_duration_seconds Database query time by query name.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_db_query_duration_seconds histogram\n")
+	for _, st := range stats {
+		for i, bound := range database.QueryBuckets {
+			fmt.Fprintf(w, "amityvox_db_query_duration_seconds_bucket{query=%q,le=\"%g\"} %d\n", st.Name, bound.Seconds(), st.Buckets[i])
+		}
+		fmt.Fprintf(w, "amityvox_db_query_duration_seconds_bucket{query=%q,le=\"+Inf\"} %d\n", st.Name, st.Count)
+		fmt.Fprintf(w, "amityvox_db_query_duration_seconds_sum{query=%q} %f\n", st.Name, st.Total.Seconds())
+		fmt.Fprintf(w, "amityvox_db_query_duration_seconds_count{query=%q} %d\n", st.Name, st.Count)
+	}
+	fmt.Fprintf(w, "\n# HELP amityvox_db_query_errors_total Failed database queries by query name.\n")
+	fmt.Fprintf(w, "# TYPE amityvox_db_query_errors_total counter\n")
+	for _, st := range stats {
+		fmt.Fprintf(w, "amityvox_db_query_errors_total{query=%q} %d\n", st.Name, st.Errors)
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// handleGetQueryStats returns database query timings, the queries taking the
+// most total time first.
+// GET /api/v1/admin/database/queries?limit=
+func (s *Server) handleGetQueryStats(w http.ResponseWriter, r *http.Request) {
+	stats := s.DB.QueryStats()
+	slices.SortFunc(stats, func(a, b database.QueryStat) int {
+		return cmp.Compare(b.Total, a.Total)
+	})
+	limit, _ := parsePagination(r)
+	if int(limit) < len(stats) {
+		stats = stats[:limit]
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"buckets_seconds": bucketSeconds(),
+		"queries":         stats,
+	})
+}
+
+// bucketSeconds returns the query histogram bounds in seconds.
+func bucketSeconds() []float64 {
+	out := make([]float64, len(database.QueryBuckets))
+	for i, b := range database.QueryBuckets {
+		out[i] = b.Seconds()
+	}
+	return out
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
@@ -51,7 +52,7 @@ type TOTPDisableRequest struct {
 func (s *Server) handleTOTPEnable(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -69,7 +70,7 @@ func (s *Server) handleTOTPEnable(w http.ResponseWriter, r *http.Request) {
 	err := s.DB.Pool.QueryRow(r.Context(),
 		`SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&passwordHash)
 	if err != nil || passwordHash == nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to verify credentials")
+		WriteCode(w, apierrors.InternalError, "Failed to verify credentials")
 		return
 	}
 
@@ -91,7 +92,7 @@ func (s *Server) handleTOTPEnable(w http.ResponseWriter, r *http.Request) {
 	// Generate a 20-byte TOTP secret.
 	secretBytes := make([]byte, 20)
 	if _, err := rand.Read(secretBytes); err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to generate TOTP secret")
+		WriteCode(w, apierrors.InternalError, "Failed to generate TOTP secret")
 		return
 	}
 	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secretBytes)
@@ -101,7 +102,7 @@ func (s *Server) handleTOTPEnable(w http.ResponseWriter, r *http.Request) {
 	_, err = s.DB.Pool.Exec(r.Context(),
 		`UPDATE users SET totp_secret = $1 WHERE id = $2`, secret, userID)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to save TOTP secret")
+		WriteCode(w, apierrors.InternalError, "Failed to save TOTP secret")
 		return
 	}
 
@@ -129,7 +130,7 @@ func (s *Server) handleTOTPEnable(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleTOTPVerify(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -168,7 +169,7 @@ func (s *Server) handleTOTPVerify(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -178,7 +179,7 @@ func (s *Server) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.Password == "" || req.Code == "" {
-		WriteError(w, http.StatusBadRequest, "missing_fields", "Password and TOTP code are required to disable TOTP")
+		WriteCode(w, apierrors.MissingFields, "Password and TOTP code are required to disable TOTP")
 		return
 	}
 
@@ -187,7 +188,7 @@ func (s *Server) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
 	err := s.DB.Pool.QueryRow(r.Context(),
 		`SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&passwordHash)
 	if err != nil || passwordHash == nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to verify credentials")
+		WriteCode(w, apierrors.InternalError, "Failed to verify credentials")
 		return
 	}
 
@@ -215,7 +216,7 @@ func (s *Server) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
 	_, err = s.DB.Pool.Exec(r.Context(),
 		`UPDATE users SET totp_secret = NULL WHERE id = $1`, userID)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to disable TOTP")
+		WriteCode(w, apierrors.InternalError, "Failed to disable TOTP")
 		return
 	}
 
@@ -229,7 +230,7 @@ func (s *Server) handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGenerateBackupCodes(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -237,7 +238,7 @@ func (s *Server) handleGenerateBackupCodes(w http.ResponseWriter, r *http.Reques
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" {
-		WriteError(w, http.StatusBadRequest, "invalid_body", "Password is required")
+		WriteCode(w, apierrors.InvalidBody, "Password is required")
 		return
 	}
 
@@ -270,7 +271,7 @@ func (s *Server) handleGenerateBackupCodes(w http.ResponseWriter, r *http.Reques
 	for i := range codes {
 		buf := make([]byte, 4)
 		if _, err := rand.Read(buf); err != nil {
-			WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to generate codes")
+			WriteCode(w, apierrors.InternalError, "Failed to generate codes")
 			return
 		}
 		codes[i] = fmt.Sprintf("%08x", buf)
@@ -295,7 +296,7 @@ func (s *Server) handleGenerateBackupCodes(w http.ResponseWriter, r *http.Reques
 		return nil
 	})
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to generate codes")
+		WriteCode(w, apierrors.InternalError, "Failed to generate codes")
 		return
 	}
 
@@ -309,7 +310,7 @@ func (s *Server) handleGenerateBackupCodes(w http.ResponseWriter, r *http.Reques
 func (s *Server) handleConsumeBackupCode(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -317,7 +318,7 @@ func (s *Server) handleConsumeBackupCode(w http.ResponseWriter, r *http.Request)
 		Code string `json:"code"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
-		WriteError(w, http.StatusBadRequest, "invalid_body", "Code is required")
+		WriteCode(w, apierrors.InvalidBody, "Code is required")
 		return
 	}
 
@@ -326,7 +327,7 @@ func (s *Server) handleConsumeBackupCode(w http.ResponseWriter, r *http.Request)
 		`SELECT id, code_hash FROM backup_codes WHERE user_id = $1 AND used = false`,
 		userID)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to verify code")
+		WriteCode(w, apierrors.InternalError, "Failed to verify code")
 		return
 	}
 	defer rows.Close()
@@ -481,7 +482,7 @@ func (s *Server) handleWebAuthnRegisterBegin(w http.ResponseWriter, r *http.Requ
 
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -519,13 +520,13 @@ func (s *Server) handleWebAuthnRegisterFinish(w http.ResponseWriter, r *http.Req
 
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
 	user, err := s.loadWebAuthnUser(r, userID)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to load user data")
+		WriteCode(w, apierrors.InternalError, "Failed to load user data")
 		return
 	}
 
@@ -579,13 +580,13 @@ func (s *Server) handleWebAuthnLoginBegin(w http.ResponseWriter, r *http.Request
 
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
 	user, err := s.loadWebAuthnUser(r, userID)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to load user data")
+		WriteCode(w, apierrors.InternalError, "Failed to load user data")
 		return
 	}
 
@@ -622,13 +623,13 @@ func (s *Server) handleWebAuthnLoginFinish(w http.ResponseWriter, r *http.Reques
 
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
 	user, err := s.loadWebAuthnUser(r, userID)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to load user data")
+		WriteCode(w, apierrors.InternalError, "Failed to load user data")
 		return
 	}
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
@@ -38,7 +39,7 @@ func (h *Handler) HandleCreateBanList(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -79,7 +80,7 @@ func (h *Handler) HandleGetBanLists(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -91,7 +92,7 @@ func (h *Handler) HandleGetBanLists(w http.ResponseWriter, r *http.Request) {
 		 WHERE bl.guild_id = $1
 		 ORDER BY bl.created_at DESC`, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch ban lists")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch ban lists")
 		return
 	}
 	defer rows.Close()
@@ -133,18 +134,18 @@ func (h *Handler) HandleDeleteBanList(w http.ResponseWriter, r *http.Request) {
 	listID := chi.URLParam(r, "listID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM ban_lists WHERE id = $1 AND guild_id = $2`, listID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete ban list")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete ban list")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Ban list not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Ban list not found")
 		return
 	}
 
@@ -159,7 +160,7 @@ func (h *Handler) HandleAddBanListEntry(w http.ResponseWriter, r *http.Request)
 	listID := chi.URLParam(r, "listID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -176,7 +177,7 @@ func (h *Handler) HandleAddBanListEntry(w http.ResponseWriter, r *http.Request)
 	h.Pool.QueryRow(r.Context(),
 		`SELECT EXISTS(SELECT 1 FROM ban_lists WHERE id = $1 AND guild_id = $2)`, listID, guildID).Scan(&exists)
 	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Ban list not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Ban list not found")
 		return
 	}
 
@@ -208,7 +209,7 @@ func (h *Handler) HandleGetBanListEntries(w http.ResponseWriter, r *http.Request
 	listID := chi.URLParam(r, "listID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -219,7 +220,7 @@ func (h *Handler) HandleGetBanListEntries(w http.ResponseWriter, r *http.Request
 		 WHERE e.ban_list_id = $1 AND bl.guild_id = $2
 		 ORDER BY e.added_at DESC`, listID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch entries")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch entries")
 		return
 	}
 	defer rows.Close()
@@ -257,7 +258,7 @@ func (h *Handler) HandleRemoveBanListEntry(w http.ResponseWriter, r *http.Reques
 	entryID := chi.URLParam(r, "entryID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -267,11 +268,11 @@ func (h *Handler) HandleRemoveBanListEntry(w http.ResponseWriter, r *http.Reques
 		 WHERE e.id = $1 AND e.ban_list_id = $2 AND bl.id = e.ban_list_id AND bl.guild_id = $3`,
 		entryID, listID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to remove entry")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to remove entry")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Entry not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Entry not found")
 		return
 	}
 
@@ -286,7 +287,7 @@ func (h *Handler) HandleExportBanList(w http.ResponseWriter, r *http.Request) {
 	listID := chi.URLParam(r, "listID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -296,7 +297,7 @@ func (h *Handler) HandleExportBanList(w http.ResponseWriter, r *http.Request) {
 		`SELECT name, COALESCE(description, '') FROM ban_lists WHERE id = $1 AND guild_id = $2`,
 		listID, guildID).Scan(&name, &desc)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Ban list not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Ban list not found")
 		return
 	}
 
@@ -305,7 +306,7 @@ func (h *Handler) HandleExportBanList(w http.ResponseWriter, r *http.Request) {
 		`SELECT user_id, username, reason, added_at FROM ban_list_entries WHERE ban_list_id = $1 ORDER BY added_at`,
 		listID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to export")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to export")
 		return
 	}
 	defer rows.Close()
@@ -354,7 +355,7 @@ func (h *Handler) HandleImportBanList(w http.ResponseWriter, r *http.Request) {
 	listID := chi.URLParam(r, "listID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -363,7 +364,7 @@ func (h *Handler) HandleImportBanList(w http.ResponseWriter, r *http.Request) {
 	h.Pool.QueryRow(r.Context(),
 		`SELECT EXISTS(SELECT 1 FROM ban_lists WHERE id = $1 AND guild_id = $2)`, listID, guildID).Scan(&exists)
 	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Ban list not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Ban list not found")
 		return
 	}
 
@@ -414,7 +415,7 @@ func (h *Handler) HandleSubscribeBanList(w http.ResponseWriter, r *http.Request)
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -429,7 +430,7 @@ func (h *Handler) HandleSubscribeBanList(w http.ResponseWriter, r *http.Request)
 	err := h.Pool.QueryRow(r.Context(),
 		`SELECT guild_id, public FROM ban_lists WHERE id = $1`, req.BanListID).Scan(&listGuildID, &isPublic)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Ban list not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Ban list not found")
 		return
 	}
 	if !isPublic && listGuildID != guildID {
@@ -444,7 +445,7 @@ func (h *Handler) HandleSubscribeBanList(w http.ResponseWriter, r *http.Request)
 		 ON CONFLICT (guild_id, ban_list_id) DO UPDATE SET auto_ban = EXCLUDED.auto_ban`,
 		id, guildID, req.BanListID, req.AutoBan, userID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to subscribe")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to subscribe")
 		return
 	}
 
@@ -463,7 +464,7 @@ func (h *Handler) HandleGetBanListSubscriptions(w http.ResponseWriter, r *http.R
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
@@ -476,7 +477,7 @@ func (h *Handler) HandleGetBanListSubscriptions(w http.ResponseWriter, r *http.R
 		 WHERE s.guild_id = $1
 		 ORDER BY s.subscribed_at DESC`, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch subscriptions")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch subscriptions")
 		return
 	}
 	defer rows.Close()
@@ -515,18 +516,18 @@ func (h *Handler) HandleUnsubscribeBanList(w http.ResponseWriter, r *http.Reques
 	subID := chi.URLParam(r, "subID")
 
 	if !h.hasGuildPermission(r.Context(), guildID, userID, "ban_members") {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need BAN_MEMBERS permission")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need BAN_MEMBERS permission")
 		return
 	}
 
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM ban_list_subscriptions WHERE id = $1 AND guild_id = $2`, subID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to unsubscribe")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to unsubscribe")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Subscription not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Subscription not found")
 		return
 	}
 
@@ -547,7 +548,7 @@ func (h *Handler) HandleGetPublicBanLists(w http.ResponseWriter, r *http.Request
 		 ORDER BY subscriber_count DESC, bl.created_at DESC
 		 LIMIT 50`)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch public ban lists")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch public ban lists")
 		return
 	}
 	defer rows.Close()
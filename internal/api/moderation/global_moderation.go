@@ -1,18 +1,22 @@
 package moderation
 
 import (
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
 )
 
-// isGlobalModOrAdmin checks if the requesting user has the global moderator or admin flag.
+// isGlobalModOrAdmin checks if the requesting user has the global moderator or admin flag,
+// or holds the handle_reports instance permission through an instance role.
 // Returns (authorized, error) so callers can distinguish permission failures from DB errors.
 func (h *Handler) isGlobalModOrAdmin(r *http.Request) (bool, error) {
 	userID := auth.UserIDFromContext(r.Context())
@@ -22,7 +26,28 @@ func (h *Handler) isGlobalModOrAdmin(r *http.Request) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return flags&(models.UserFlagAdmin|models.UserFlagGlobalMod) != 0, nil
+	if flags&(models.UserFlagAdmin|models.UserFlagGlobalMod) != 0 {
+		return true, nil
+	}
+	return apiutil.HasInstancePermission(r.Context(), h.Pool, userID, permissions.InstanceHandleReports), nil
+}
+
+// logReportResolution records a report or issue status change in the
+// instance audit log.
+func (h *Handler) logReportResolution(r *http.Request, targetType, targetID, prevStatus, status string, notes *string) {
+	err := apiutil.LogStaffAction(r.Context(), h.Pool, apiutil.StaffAction{
+		ActorID:    auth.UserIDFromContext(r.Context()),
+		Action:     models.StaffActionReportResolve,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     map[string]string{"status": prevStatus},
+		After:      map[string]string{"status": status},
+		Reason:     notes,
+	})
+	if err != nil {
+		h.Logger.Warn("failed to write instance audit log",
+			slog.String("target_id", targetID), slog.String("error", err.Error()))
+	}
 }
 
 // HandleReportUser handles POST /api/v1/users/{userID}/report.
@@ -65,7 +90,7 @@ func (h *Handler) HandleReportUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "User not found")
+		apiutil.WriteCode(w, apierrors.UserNotFound, "")
 		return
 	}
 
@@ -91,7 +116,7 @@ func (h *Handler) HandleGetUserReports(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !ok {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Global moderator or admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Global moderator or admin access required")
 		return
 	}
 
@@ -116,7 +141,7 @@ func (h *Handler) HandleGetUserReports(w http.ResponseWriter, r *http.Request) {
 		rows, qerr = h.Pool.Query(r.Context(), query)
 	}
 	if qerr != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch reports")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch reports")
 		return
 	}
 	defer rows.Close()
@@ -126,13 +151,13 @@ func (h *Handler) HandleGetUserReports(w http.ResponseWriter, r *http.Request) {
 			&rpt.ContextGuildID, &rpt.ContextChannelID, &rpt.Status,
 			&rpt.ResolvedBy, &rpt.ResolvedAt, &rpt.Notes, &rpt.CreatedAt,
 			&rpt.ReporterName, &rpt.ReportedUserName); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read reports")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read reports")
 			return
 		}
 		reports = append(reports, rpt)
 	}
 	if err := rows.Err(); err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read reports")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to read reports")
 		return
 	}
 
@@ -150,7 +175,7 @@ func (h *Handler) HandleResolveUserReport(w http.ResponseWriter, r *http.Request
 		return
 	}
 	if !ok {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Global moderator or admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Global moderator or admin access required")
 		return
 	}
 
@@ -170,19 +195,23 @@ func (h *Handler) HandleResolveUserReport(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	var prevStatus string
+	h.Pool.QueryRow(r.Context(), `SELECT status FROM user_reports WHERE id = $1`, reportID).Scan(&prevStatus)
+
 	now := time.Now()
 	tag, err := h.Pool.Exec(r.Context(),
 		`UPDATE user_reports SET status = $1, resolved_by = $2, resolved_at = $3, notes = $4 WHERE id = $5`,
 		req.Status, modID, now, req.Notes, reportID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to resolve report")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to resolve report")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Report not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Report not found")
 		return
 	}
 
+	h.logReportResolution(r, "user_report", reportID, prevStatus, req.Status, req.Notes)
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": req.Status})
 }
 
@@ -200,7 +229,7 @@ func (h *Handler) HandleCreateIssue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if req.Title == "" || req.Description == "" {
-		apiutil.WriteError(w, http.StatusBadRequest, "missing_fields", "Title and description are required")
+		apiutil.WriteCode(w, apierrors.MissingFields, "Title and description are required")
 		return
 	}
 	if len(req.Title) > 200 {
@@ -240,7 +269,7 @@ func (h *Handler) HandleGetIssues(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !ok {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Global moderator or admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Global moderator or admin access required")
 		return
 	}
 
@@ -268,7 +297,7 @@ func (h *Handler) HandleGetIssues(w http.ResponseWriter, r *http.Request) {
 		irows, ierr = h.Pool.Query(r.Context(), query, status)
 	}
 	if ierr != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch issues")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch issues")
 		return
 	}
 	defer irows.Close()
@@ -277,13 +306,13 @@ func (h *Handler) HandleGetIssues(w http.ResponseWriter, r *http.Request) {
 		if err := irows.Scan(&issue.ID, &issue.ReporterID, &issue.Title, &issue.Description,
 			&issue.Category, &issue.Status, &issue.ResolvedBy, &issue.ResolvedAt,
 			&issue.Notes, &issue.CreatedAt, &issue.ReporterName); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read issues")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read issues")
 			return
 		}
 		issues = append(issues, issue)
 	}
 	if err := irows.Err(); err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read issues")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to read issues")
 		return
 	}
 
@@ -301,7 +330,7 @@ func (h *Handler) HandleResolveIssue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !ok {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Global moderator or admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Global moderator or admin access required")
 		return
 	}
 
@@ -330,18 +359,22 @@ func (h *Handler) HandleResolveIssue(w http.ResponseWriter, r *http.Request) {
 		resolvedBy = &modID
 	}
 
+	var prevStatus string
+	h.Pool.QueryRow(r.Context(), `SELECT status FROM reported_issues WHERE id = $1`, issueID).Scan(&prevStatus)
+
 	tag, err := h.Pool.Exec(r.Context(),
 		`UPDATE reported_issues SET status = $1, resolved_by = $2, resolved_at = $3, notes = $4 WHERE id = $5`,
 		req.Status, resolvedBy, resolvedAt, req.Notes, issueID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to update issue")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to update issue")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Issue not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Issue not found")
 		return
 	}
 
+	h.logReportResolution(r, "issue", issueID, prevStatus, req.Status, req.Notes)
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": req.Status})
 }
 
@@ -353,7 +386,7 @@ func (h *Handler) HandleGetModerationStats(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	if !ok {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Global moderator or admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Global moderator or admin access required")
 		return
 	}
 
@@ -361,17 +394,17 @@ func (h *Handler) HandleGetModerationStats(w http.ResponseWriter, r *http.Reques
 
 	if err := h.Pool.QueryRow(r.Context(),
 		`SELECT COUNT(*) FROM message_reports WHERE status = 'admin_pending'`).Scan(&stats.OpenMessageReports); err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to load moderation stats")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to load moderation stats")
 		return
 	}
 	if err := h.Pool.QueryRow(r.Context(),
 		`SELECT COUNT(*) FROM user_reports WHERE status = 'open'`).Scan(&stats.OpenUserReports); err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to load moderation stats")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to load moderation stats")
 		return
 	}
 	if err := h.Pool.QueryRow(r.Context(),
 		`SELECT COUNT(*) FROM reported_issues WHERE status IN ('open', 'in_progress')`).Scan(&stats.OpenIssues); err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to load moderation stats")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to load moderation stats")
 		return
 	}
 
@@ -387,7 +420,7 @@ func (h *Handler) HandleGetAllMessageReports(w http.ResponseWriter, r *http.Requ
 		return
 	}
 	if !ok {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Global moderator or admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Global moderator or admin access required")
 		return
 	}
 
@@ -405,7 +438,7 @@ func (h *Handler) HandleGetAllMessageReports(w http.ResponseWriter, r *http.Requ
 		WHERE mr.status = $1
 		ORDER BY mr.created_at DESC LIMIT 100`, status)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch message reports")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch message reports")
 		return
 	}
 	defer rows.Close()
@@ -421,13 +454,13 @@ func (h *Handler) HandleGetAllMessageReports(w http.ResponseWriter, r *http.Requ
 		if err := rows.Scan(&rpt.ID, &rpt.GuildID, &rpt.ChannelID, &rpt.MessageID,
 			&rpt.ReporterID, &rpt.Reason, &rpt.Status, &rpt.ResolvedBy,
 			&rpt.ResolvedAt, &rpt.CreatedAt, &rpt.ReporterName); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read message reports")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read message reports")
 			return
 		}
 		reports = append(reports, rpt)
 	}
 	if err := rows.Err(); err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read message reports")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to read message reports")
 		return
 	}
 
@@ -449,7 +482,7 @@ func (h *Handler) HandleGetMyIssues(w http.ResponseWriter, r *http.Request) {
 		WHERE reporter_id = $1
 		ORDER BY created_at DESC LIMIT 50`, userID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch issues")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch issues")
 		return
 	}
 	defer rows.Close()
@@ -460,13 +493,13 @@ func (h *Handler) HandleGetMyIssues(w http.ResponseWriter, r *http.Request) {
 		if err := rows.Scan(&issue.ID, &issue.ReporterID, &issue.Title, &issue.Description,
 			&issue.Category, &issue.Status, &issue.ResolvedBy, &issue.ResolvedAt,
 			&issue.Notes, &issue.CreatedAt); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read issues")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read issues")
 			return
 		}
 		issues = append(issues, issue)
 	}
 	if err := rows.Err(); err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read issues")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to read issues")
 		return
 	}
 
@@ -484,7 +517,7 @@ func (h *Handler) HandleResolveMessageReport(w http.ResponseWriter, r *http.Requ
 		return
 	}
 	if !ok {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Global moderator or admin access required")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Global moderator or admin access required")
 		return
 	}
 
@@ -503,18 +536,22 @@ func (h *Handler) HandleResolveMessageReport(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	var prevStatus string
+	h.Pool.QueryRow(r.Context(), `SELECT status FROM message_reports WHERE id = $1`, reportID).Scan(&prevStatus)
+
 	now := time.Now()
 	tag, err := h.Pool.Exec(r.Context(),
 		`UPDATE message_reports SET status = $1, resolved_by = $2, resolved_at = $3 WHERE id = $4`,
 		req.Status, modID, now, reportID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to resolve message report")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to resolve message report")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Report not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Report not found")
 		return
 	}
 
+	h.logReportResolution(r, "message_report", reportID, prevStatus, req.Status, nil)
 	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": req.Status})
 }
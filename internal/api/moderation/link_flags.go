@@ -0,0 +1,147 @@
+package moderation
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// linkFlagKeyset orders link flags newest first.
+var linkFlagKeyset = apiutil.Keyset{Desc: true, Columns: []apiutil.KeyColumn{
+	{Expr: "created_at", Type: "timestamptz"},
+	{Expr: "id", Type: "text"},
+}}
+
+const linkFlagColumns = `id, guild_id, channel_id, message_id, author_id, verdicts, status,
+	resolved_by, resolved_at, created_at`
+
+func scanLinkFlag(row pgx.Row) (models.MessageLinkFlag, error) {
+	var f models.MessageLinkFlag
+	err := row.Scan(&f.ID, &f.GuildID, &f.ChannelID, &f.MessageID, &f.AuthorID, &f.Verdicts,
+		&f.Status, &f.ResolvedBy, &f.ResolvedAt, &f.CreatedAt)
+	return f, err
+}
+
+// HandleGetLinkFlags lists messages whose links the link safety check found
+// malicious, newest first, for channels where the caller has MANAGE_MESSAGES.
+// A page may hold fewer than limit entries when some channels are out of
+// the caller's reach.
+// GET /api/v1/guilds/{guildID}/link-flags?status=open&before=&after=&limit=
+func (h *Handler) HandleGetLinkFlags(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "open" && status != "resolved" && status != "dismissed" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_status", "Status must be 'open', 'resolved' or 'dismissed'")
+		return
+	}
+	page, ok := apiutil.ParsePage(w, r, linkFlagKeyset, apiutil.DefaultPageLimit)
+	if !ok {
+		return
+	}
+	cursorSQL, cursorArgs := page.Where(linkFlagKeyset, 4)
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+linkFlagColumns+`
+		 FROM message_link_flags
+		 WHERE guild_id = $1 AND ($3 = '' OR status = $3)`+cursorSQL+`
+		 ORDER BY `+page.OrderBy(linkFlagKeyset)+`
+		 LIMIT $2`,
+		append([]interface{}{guildID, page.FetchLimit(), status}, cursorArgs...)...,
+	)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list link flags", err)
+		return
+	}
+	flags, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.MessageLinkFlag, error) {
+		return scanLinkFlag(row)
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read link flags", err)
+		return
+	}
+	flags, next := apiutil.FinishPage(page, flags, func(f models.MessageLinkFlag) []interface{} {
+		return []interface{}{f.CreatedAt, f.ID}
+	})
+
+	canManage := make(map[string]bool)
+	visible := make([]models.MessageLinkFlag, 0, len(flags))
+	for _, f := range flags {
+		allowed, seen := canManage[f.ChannelID]
+		if !seen {
+			perms, err := apiutil.MemberChannelPermissions(r.Context(), h.Pool, guildID, f.ChannelID, userID)
+			allowed = err == nil && perms&permissions.ManageMessages != 0
+			canManage[f.ChannelID] = allowed
+		}
+		if allowed {
+			visible = append(visible, f)
+		}
+	}
+
+	apiutil.WritePage(w, visible, next)
+}
+
+// HandleResolveLinkFlag marks a link flag resolved or dismissed. Needs
+// MANAGE_MESSAGES in the flagged message's channel.
+// PATCH /api/v1/guilds/{guildID}/link-flags/{flagID}
+func (h *Handler) HandleResolveLinkFlag(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	flagID := chi.URLParam(r, "flagID")
+
+	var req resolveReportRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Status != "resolved" && req.Status != "dismissed" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_status", "Status must be 'resolved' or 'dismissed'")
+		return
+	}
+
+	var channelID string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT channel_id FROM message_link_flags WHERE id = $1 AND guild_id = $2`,
+		flagID, guildID).Scan(&channelID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "flag_not_found", "Link flag not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get link flag", err)
+		return
+	}
+	perms, err := apiutil.MemberChannelPermissions(r.Context(), h.Pool, guildID, channelID, userID)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to check permissions", err)
+		return
+	}
+	if perms&permissions.ManageMessages == 0 {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_MESSAGES permission")
+		return
+	}
+
+	flag, err := scanLinkFlag(h.Pool.QueryRow(r.Context(),
+		`UPDATE message_link_flags
+		 SET status = $3, resolved_by = $4, resolved_at = now()
+		 WHERE id = $1 AND guild_id = $2
+		 RETURNING `+linkFlagColumns,
+		flagID, guildID, req.Status, userID))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "flag_not_found", "Link flag not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to resolve link flag", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, flag)
+}
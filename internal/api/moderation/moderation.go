@@ -15,10 +15,13 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/locale"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
 )
 
 // Handler implements moderation-related REST API endpoints.
@@ -114,7 +117,7 @@ func (h *Handler) HandleWarnMember(w http.ResponseWriter, r *http.Request) {
 	memberID := chi.URLParam(r, "memberID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -134,7 +137,7 @@ func (h *Handler) HandleWarnMember(w http.ResponseWriter, r *http.Request) {
 		guildID, memberID,
 	).Scan(&exists)
 	if err != nil || !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "member_not_found", "Member not found in this guild")
+		apiutil.WriteCode(w, apierrors.MemberNotFound, "Member not found in this guild")
 		return
 	}
 
@@ -165,7 +168,7 @@ func (h *Handler) HandleGetWarnings(w http.ResponseWriter, r *http.Request) {
 	memberID := chi.URLParam(r, "memberID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -214,7 +217,7 @@ func (h *Handler) HandleDeleteWarning(w http.ResponseWriter, r *http.Request) {
 	warningID := chi.URLParam(r, "warningID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -254,7 +257,7 @@ func (h *Handler) HandleReportMessage(w http.ResponseWriter, r *http.Request) {
 	guildID, err := h.getGuildIDForChannel(r.Context(), channelID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found or not in a guild")
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "Channel not found or not in a guild")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to report message", err)
@@ -268,7 +271,7 @@ func (h *Handler) HandleReportMessage(w http.ResponseWriter, r *http.Request) {
 		messageID, channelID,
 	).Scan(&msgExists)
 	if err != nil || !msgExists {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found in this channel")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "Message not found in this channel")
 		return
 	}
 
@@ -299,7 +302,7 @@ func (h *Handler) HandleGetReports(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -363,7 +366,7 @@ func (h *Handler) HandleResolveReport(w http.ResponseWriter, r *http.Request) {
 	reportID := chi.URLParam(r, "reportID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -411,7 +414,7 @@ func (h *Handler) HandleLockChannel(w http.ResponseWriter, r *http.Request) {
 	guildID, err := h.getGuildIDForChannel(r.Context(), channelID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found or not in a guild")
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "Channel not found or not in a guild")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to lock channel", err)
@@ -419,7 +422,7 @@ func (h *Handler) HandleLockChannel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -433,7 +436,7 @@ func (h *Handler) HandleLockChannel(w http.ResponseWriter, r *http.Request) {
 	).Scan(&resp.ID, &resp.Locked, &resp.LockedBy, &resp.LockedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to lock channel", err)
@@ -454,7 +457,7 @@ func (h *Handler) HandleUnlockChannel(w http.ResponseWriter, r *http.Request) {
 	guildID, err := h.getGuildIDForChannel(r.Context(), channelID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found or not in a guild")
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "Channel not found or not in a guild")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to unlock channel", err)
@@ -462,7 +465,7 @@ func (h *Handler) HandleUnlockChannel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -476,7 +479,7 @@ func (h *Handler) HandleUnlockChannel(w http.ResponseWriter, r *http.Request) {
 	).Scan(&resp.ID, &resp.Locked, &resp.LockedBy, &resp.LockedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to unlock channel", err)
@@ -495,7 +498,7 @@ func (h *Handler) HandleGetRaidConfig(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -545,7 +548,7 @@ func (h *Handler) HandleUpdateRaidConfig(w http.ResponseWriter, r *http.Request)
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -621,9 +624,10 @@ func (h *Handler) publishLockdownAlert(ctx context.Context, guildID string, conf
 		return
 	}
 
-	// Build the alert message content.
+	// Build the alert message content, timed in the guild's own timezone.
 	alertContent := fmt.Sprintf(
-		"Raid protection activated: lockdown engaged (rate limit: %d joins in %d seconds)",
+		"Raid protection activated at %s: lockdown engaged (rate limit: %d joins in %d seconds)",
+		locale.ForGuild(ctx, h.Pool, guildID).DateTime(time.Now()),
 		config.JoinRateLimit, config.JoinRateWindow,
 	)
 
@@ -699,7 +703,7 @@ func (h *Handler) HandleReportToAdmin(w http.ResponseWriter, r *http.Request) {
 		messageID, channelID,
 	).Scan(&msgAuthorID, &msgContent)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "message_not_found", "Message not found in this channel")
+		apiutil.WriteCode(w, apierrors.MessageNotFound, "Message not found in this channel")
 		return
 	}
 
@@ -732,12 +736,9 @@ func (h *Handler) HandleReportToAdmin(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) HandleGetAdminReports(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 
-	// Check if user is instance admin.
-	var flags int
-	err := h.Pool.QueryRow(r.Context(),
-		`SELECT flags FROM users WHERE id = $1`, userID).Scan(&flags)
-	if err != nil || flags&models.UserFlagAdmin == 0 {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+	// Check if user is instance admin or holds the report-handling role.
+	if !apiutil.HasInstancePermission(r.Context(), h.Pool, userID, permissions.InstanceHandleReports) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Admin access required")
 		return
 	}
 
@@ -751,7 +752,7 @@ func (h *Handler) HandleGetAdminReports(w http.ResponseWriter, r *http.Request)
 		 ORDER BY mr.created_at DESC
 		 LIMIT 50`)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to list reports")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to list reports")
 		return
 	}
 	defer rows.Close()
@@ -0,0 +1,316 @@
+package moderation
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// quarantinedMessageColumns is the column list scanned by scanQuarantinedMessages.
+const quarantinedMessageColumns = `id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
+	reply_to_ids, mention_user_ids, mention_role_ids, mention_here, created_at`
+
+func scanQuarantinedMessages(rows pgx.Rows) ([]models.Message, error) {
+	defer rows.Close()
+	messages := make([]models.Message, 0)
+	for rows.Next() {
+		var m models.Message
+		if err := rows.Scan(&m.ID, &m.ChannelID, &m.AuthorID, &m.Content, &m.Nonce, &m.MessageType,
+			&m.EditedAt, &m.Flags, &m.ReplyToIDs, &m.MentionUserIDs, &m.MentionRoleIDs,
+			&m.MentionHere, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// requireGlobalMod writes the standard 403/500 response and returns false if
+// the caller is not a global moderator, admin, or report handler.
+func (h *Handler) requireGlobalMod(w http.ResponseWriter, r *http.Request) bool {
+	ok, err := h.isGlobalModOrAdmin(r)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to verify permissions", err)
+		return false
+	}
+	if !ok {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Global moderator or admin access required")
+		return false
+	}
+	return true
+}
+
+// logQuarantineAction records a quarantine decision in the instance audit log.
+func (h *Handler) logQuarantineAction(ctx context.Context, actorID, action, userID string, after interface{}, reason *string) {
+	err := apiutil.LogStaffAction(ctx, h.Pool, apiutil.StaffAction{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   userID,
+		After:      after,
+		Reason:     reason,
+	})
+	if err != nil {
+		h.Logger.Warn("failed to write instance audit log",
+			slog.String("target_id", userID), slog.String("error", err.Error()))
+	}
+}
+
+// HandleListQuarantines lists shadow quarantines, pending ones by default,
+// with the number of messages each user has waiting for review.
+// GET /api/v1/moderation/quarantines
+func (h *Handler) HandleListQuarantines(w http.ResponseWriter, r *http.Request) {
+	if !h.requireGlobalMod(w, r) {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.QuarantineStatusPending
+	}
+	if !apiutil.ValidateEnum(w, "status", status, []string{
+		models.QuarantineStatusPending, models.QuarantineStatusReleased, models.QuarantineStatusConfirmed,
+	}) {
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT q.id, q.user_id, q.source, q.reason, q.guild_id, q.status, q.created_by,
+		        q.reviewed_by, q.reviewed_at, q.created_at, u.username,
+		        (SELECT COUNT(*) FROM messages m WHERE m.author_id = q.user_id AND m.flags & $2 != 0)
+		 FROM user_quarantines q
+		 JOIN users u ON u.id = q.user_id
+		 WHERE q.status = $1
+		 ORDER BY q.created_at DESC
+		 LIMIT 200`, status, models.MessageFlagQuarantined)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list quarantines", err)
+		return
+	}
+	defer rows.Close()
+
+	type quarantineEntry struct {
+		models.UserQuarantine
+		Username     string `json:"username"`
+		MessageCount int    `json:"message_count"`
+	}
+
+	quarantines := make([]quarantineEntry, 0)
+	for rows.Next() {
+		var q quarantineEntry
+		if err := rows.Scan(&q.ID, &q.UserID, &q.Source, &q.Reason, &q.GuildID, &q.Status, &q.CreatedBy,
+			&q.ReviewedBy, &q.ReviewedAt, &q.CreatedAt, &q.Username, &q.MessageCount); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read quarantines", err)
+			return
+		}
+		quarantines = append(quarantines, q)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, quarantines)
+}
+
+// HandleGetQuarantinedMessages returns a user's messages held by quarantine.
+// GET /api/v1/moderation/quarantines/{userID}/messages
+func (h *Handler) HandleGetQuarantinedMessages(w http.ResponseWriter, r *http.Request) {
+	if !h.requireGlobalMod(w, r) {
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+quarantinedMessageColumns+`
+		 FROM messages WHERE author_id = $1 AND flags & $2 != 0
+		 ORDER BY id DESC LIMIT 100`,
+		chi.URLParam(r, "userID"), models.MessageFlagQuarantined)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get quarantined messages", err)
+		return
+	}
+	messages, err := scanQuarantinedMessages(rows)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read quarantined messages", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, messages)
+}
+
+// HandleQuarantineUser manually places a user under shadow quarantine.
+// POST /api/v1/moderation/users/{userID}/quarantine
+func (h *Handler) HandleQuarantineUser(w http.ResponseWriter, r *http.Request) {
+	if !h.requireGlobalMod(w, r) {
+		return
+	}
+	userID := chi.URLParam(r, "userID")
+	modID := auth.UserIDFromContext(r.Context())
+
+	var req struct {
+		Reason *string `json:"reason"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	var exists bool
+	h.Pool.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+	if !exists {
+		apiutil.WriteCode(w, apierrors.NotFound, "User not found")
+		return
+	}
+
+	created, err := apiutil.QuarantineUser(r.Context(), h.Pool, models.UserQuarantine{
+		UserID:    userID,
+		Source:    models.QuarantineSourceManual,
+		Reason:    req.Reason,
+		CreatedBy: &modID,
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to quarantine user", err)
+		return
+	}
+	if !created {
+		apiutil.WriteError(w, http.StatusConflict, "already_quarantined", "User is already quarantined")
+		return
+	}
+
+	h.logQuarantineAction(r.Context(), modID, models.StaffActionQuarantine, userID,
+		map[string]string{"status": models.QuarantineStatusPending}, req.Reason)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"status": models.QuarantineStatusPending})
+}
+
+// resolveQuarantine marks the user's pending quarantine as released or
+// confirmed, returning false if there was none.
+func (h *Handler) resolveQuarantine(ctx context.Context, tx pgx.Tx, userID, modID, status string) (bool, error) {
+	tag, err := tx.Exec(ctx,
+		`UPDATE user_quarantines SET status = $2, reviewed_by = $3, reviewed_at = now()
+		 WHERE user_id = $1 AND status = 'pending'`, userID, status, modID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// HandleReleaseQuarantine lifts a quarantine after review. The user's held
+// messages become visible to everyone and are delivered as new messages.
+// POST /api/v1/moderation/quarantines/{userID}/release
+func (h *Handler) HandleReleaseQuarantine(w http.ResponseWriter, r *http.Request) {
+	if !h.requireGlobalMod(w, r) {
+		return
+	}
+	userID := chi.URLParam(r, "userID")
+	modID := auth.UserIDFromContext(r.Context())
+
+	var found bool
+	var released []models.Message
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		var err error
+		if found, err = h.resolveQuarantine(r.Context(), tx, userID, modID, models.QuarantineStatusReleased); err != nil || !found {
+			return err
+		}
+		rows, err := tx.Query(r.Context(),
+			`UPDATE messages SET flags = flags & ~$2::int
+			 WHERE author_id = $1 AND flags & $2 != 0
+			 RETURNING `+quarantinedMessageColumns,
+			userID, models.MessageFlagQuarantined)
+		if err != nil {
+			return err
+		}
+		released, err = scanQuarantinedMessages(rows)
+		return err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to release quarantine", err)
+		return
+	}
+	if !found {
+		apiutil.WriteCode(w, apierrors.NotFound, "User is not quarantined")
+		return
+	}
+
+	for _, m := range released {
+		h.EventBus.PublishChannelEvent(r.Context(), events.SubjectMessageCreate, "MESSAGE_CREATE", m.ChannelID, m)
+	}
+
+	h.logQuarantineAction(r.Context(), modID, models.StaffActionQuarantineRelease, userID,
+		map[string]interface{}{"status": models.QuarantineStatusReleased, "released_messages": len(released)}, nil)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"status":            models.QuarantineStatusReleased,
+		"released_messages": len(released),
+	})
+}
+
+// HandleConfirmQuarantine confirms a quarantined user as a spammer: their
+// held messages are deleted and, if requested, the account is suspended.
+// POST /api/v1/moderation/quarantines/{userID}/confirm
+func (h *Handler) HandleConfirmQuarantine(w http.ResponseWriter, r *http.Request) {
+	if !h.requireGlobalMod(w, r) {
+		return
+	}
+	userID := chi.URLParam(r, "userID")
+	modID := auth.UserIDFromContext(r.Context())
+
+	var req struct {
+		Suspend bool    `json:"suspend"`
+		Reason  *string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if !apiutil.DecodeJSON(w, r, &req) {
+			return
+		}
+	}
+
+	var found bool
+	var deleted int64
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		var err error
+		if found, err = h.resolveQuarantine(r.Context(), tx, userID, modID, models.QuarantineStatusConfirmed); err != nil || !found {
+			return err
+		}
+		tag, err := tx.Exec(r.Context(),
+			`DELETE FROM messages WHERE author_id = $1 AND flags & $2 != 0`,
+			userID, models.MessageFlagQuarantined)
+		if err != nil {
+			return err
+		}
+		deleted = tag.RowsAffected()
+		if !req.Suspend {
+			return nil
+		}
+		_, err = tx.Exec(r.Context(),
+			`UPDATE users SET flags = flags | $2, suspended_until = NULL, suspension_reason = $3, scim_suspended = false
+			 WHERE id = $1`, userID, models.UserFlagSuspended, req.Reason)
+		return err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to confirm quarantine", err)
+		return
+	}
+	if !found {
+		apiutil.WriteCode(w, apierrors.NotFound, "User is not quarantined")
+		return
+	}
+
+	if req.Suspend {
+		if err := apiutil.RevokeUserSessions(r.Context(), h.Pool, nil, h.EventBus, userID, "suspended"); err != nil {
+			h.Logger.Warn("failed to revoke user sessions",
+				slog.String("user_id", userID), slog.String("error", err.Error()))
+		}
+	}
+
+	h.logQuarantineAction(r.Context(), modID, models.StaffActionQuarantineConfirm, userID,
+		map[string]interface{}{"status": models.QuarantineStatusConfirmed, "deleted_messages": deleted, "suspended": req.Suspend},
+		req.Reason)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"status":           models.QuarantineStatusConfirmed,
+		"deleted_messages": deleted,
+		"suspended":        req.Suspend,
+	})
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -36,6 +37,13 @@ type updateOnboardingRequest struct {
 	WelcomeMessage    *string  `json:"welcome_message"`
 	Rules             any      `json:"rules"`
 	DefaultChannelIDs []string `json:"default_channel_ids"`
+
+	// RulesChannelID sets the channel showing the rules; "" clears it.
+	RulesChannelID         *string `json:"rules_channel_id"`
+	RequireRulesAcceptance *bool   `json:"require_rules_acceptance"`
+	// RequireReacknowledgment bumps the rules version when the rules change,
+	// so every member has to accept them again.
+	RequireReacknowledgment bool `json:"require_reacknowledgment"`
 }
 
 type createPromptRequest struct {
@@ -73,6 +81,10 @@ type onboardingResponse struct {
 	DefaultChannelIDs []string         `json:"default_channel_ids"`
 	UpdatedAt         time.Time        `json:"updated_at"`
 	Prompts           []promptResponse `json:"prompts"`
+
+	RulesChannelID         *string `json:"rules_channel_id"`
+	RequireRulesAcceptance bool    `json:"require_rules_acceptance"`
+	RulesVersion           int     `json:"rules_version"`
 }
 
 type promptResponse struct {
@@ -101,6 +113,10 @@ type optionResponse struct {
 type onboardingStatusResponse struct {
 	Completed   bool       `json:"completed"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	RulesPending         bool       `json:"rules_pending"`
+	RulesAcceptedVersion *int       `json:"rules_accepted_version,omitempty"`
+	RulesAcceptedAt      *time.Time `json:"rules_accepted_at,omitempty"`
 }
 
 // --- Permission helpers ---
@@ -138,7 +154,7 @@ func (h *Handler) HandleGetOnboarding(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You are not a member of this guild")
 		return
 	}
 
@@ -146,11 +162,13 @@ func (h *Handler) HandleGetOnboarding(w http.ResponseWriter, r *http.Request) {
 	var resp onboardingResponse
 	var rulesRaw []byte
 	err := h.Pool.QueryRow(r.Context(),
-		`SELECT guild_id, enabled, welcome_message, rules, default_channel_ids, updated_at
+		`SELECT guild_id, enabled, welcome_message, rules, default_channel_ids, updated_at,
+		        rules_channel_id, require_rules_acceptance, rules_version
 		 FROM guild_onboarding
 		 WHERE guild_id = $1`,
 		guildID,
-	).Scan(&resp.GuildID, &resp.Enabled, &resp.WelcomeMessage, &rulesRaw, &resp.DefaultChannelIDs, &resp.UpdatedAt)
+	).Scan(&resp.GuildID, &resp.Enabled, &resp.WelcomeMessage, &rulesRaw, &resp.DefaultChannelIDs, &resp.UpdatedAt,
+		&resp.RulesChannelID, &resp.RequireRulesAcceptance, &resp.RulesVersion)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			// Return default empty onboarding config.
@@ -162,6 +180,7 @@ func (h *Handler) HandleGetOnboarding(w http.ResponseWriter, r *http.Request) {
 				DefaultChannelIDs: []string{},
 				UpdatedAt:         time.Now(),
 				Prompts:           []promptResponse{},
+				RulesVersion:      1,
 			}
 			apiutil.WriteJSON(w, http.StatusOK, resp)
 			return
@@ -262,7 +281,7 @@ func (h *Handler) HandleUpdateOnboarding(w http.ResponseWriter, r *http.Request)
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -287,20 +306,63 @@ func (h *Handler) HandleUpdateOnboarding(w http.ResponseWriter, r *http.Request)
 		req.DefaultChannelIDs = []string{}
 	}
 
+	// The rules channel must belong to this guild.
+	if req.RulesChannelID != nil && *req.RulesChannelID != "" {
+		var inGuild bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM channels WHERE id = $1 AND guild_id = $2)`,
+			*req.RulesChannelID, guildID,
+		).Scan(&inGuild)
+		if !inGuild {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_channel", "Rules channel must be a channel in this guild")
+			return
+		}
+	}
+
 	var resp onboardingResponse
 	var rulesRaw []byte
-	err := h.Pool.QueryRow(r.Context(),
-		`INSERT INTO guild_onboarding (guild_id, enabled, welcome_message, rules, default_channel_ids, updated_at)
-		 VALUES ($1, COALESCE($2, false), $3, COALESCE($4, '[]'::jsonb), $5, now())
-		 ON CONFLICT (guild_id) DO UPDATE SET
-		     enabled = COALESCE($2, guild_onboarding.enabled),
-		     welcome_message = COALESCE($3, guild_onboarding.welcome_message),
-		     rules = COALESCE($4, guild_onboarding.rules),
-		     default_channel_ids = COALESCE($5, guild_onboarding.default_channel_ids),
-		     updated_at = now()
-		 RETURNING guild_id, enabled, welcome_message, rules, default_channel_ids, updated_at`,
-		guildID, req.Enabled, req.WelcomeMessage, rulesJSON, req.DefaultChannelIDs,
-	).Scan(&resp.GuildID, &resp.Enabled, &resp.WelcomeMessage, &rulesRaw, &resp.DefaultChannelIDs, &resp.UpdatedAt)
+	var wasRequired bool
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		tx.QueryRow(r.Context(),
+			`SELECT require_rules_acceptance FROM guild_onboarding WHERE guild_id = $1 FOR UPDATE`,
+			guildID,
+		).Scan(&wasRequired)
+
+		err := tx.QueryRow(r.Context(),
+			`INSERT INTO guild_onboarding (guild_id, enabled, welcome_message, rules, default_channel_ids, updated_at,
+			                               rules_channel_id, require_rules_acceptance)
+			 VALUES ($1, COALESCE($2, false), $3, COALESCE($4, '[]'::jsonb), $5, now(), NULLIF($6, ''), COALESCE($7, false))
+			 ON CONFLICT (guild_id) DO UPDATE SET
+			     enabled = COALESCE($2, guild_onboarding.enabled),
+			     welcome_message = COALESCE($3, guild_onboarding.welcome_message),
+			     rules = COALESCE($4, guild_onboarding.rules),
+			     default_channel_ids = COALESCE($5, guild_onboarding.default_channel_ids),
+			     rules_channel_id = CASE WHEN $6::text IS NULL THEN guild_onboarding.rules_channel_id ELSE NULLIF($6, '') END,
+			     require_rules_acceptance = COALESCE($7, guild_onboarding.require_rules_acceptance),
+			     rules_version = guild_onboarding.rules_version +
+			         CASE WHEN $8 AND $4::jsonb IS NOT NULL AND $4::jsonb <> guild_onboarding.rules THEN 1 ELSE 0 END,
+			     updated_at = now()
+			 RETURNING guild_id, enabled, welcome_message, rules, default_channel_ids, updated_at,
+			           rules_channel_id, require_rules_acceptance, rules_version`,
+			guildID, req.Enabled, req.WelcomeMessage, rulesJSON, req.DefaultChannelIDs,
+			req.RulesChannelID, req.RequireRulesAcceptance, req.RequireReacknowledgment,
+		).Scan(&resp.GuildID, &resp.Enabled, &resp.WelcomeMessage, &rulesRaw, &resp.DefaultChannelIDs, &resp.UpdatedAt,
+			&resp.RulesChannelID, &resp.RequireRulesAcceptance, &resp.RulesVersion)
+		if err != nil {
+			return err
+		}
+
+		// Switching the requirement on only gates members who join from now
+		// on. Everyone already here is recorded at the current version, with
+		// no acceptance time, until an edit asks for re-acknowledgment.
+		if resp.RequireRulesAcceptance && !wasRequired {
+			_, err = tx.Exec(r.Context(),
+				`UPDATE guild_members SET rules_accepted_version = $2
+				 WHERE guild_id = $1 AND rules_accepted_version IS NULL`,
+				guildID, resp.RulesVersion)
+		}
+		return err
+	})
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to update onboarding config", err)
 		return
@@ -323,6 +385,10 @@ func (h *Handler) HandleUpdateOnboarding(w http.ResponseWriter, r *http.Request)
 		"rules":               resp.Rules,
 		"default_channel_ids": resp.DefaultChannelIDs,
 		"updated_at":          resp.UpdatedAt,
+
+		"rules_channel_id":         resp.RulesChannelID,
+		"require_rules_acceptance": resp.RequireRulesAcceptance,
+		"rules_version":            resp.RulesVersion,
 	})
 
 	apiutil.WriteJSON(w, http.StatusOK, resp)
@@ -335,7 +401,7 @@ func (h *Handler) HandleCreatePrompt(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -447,7 +513,7 @@ func (h *Handler) HandleUpdatePrompt(w http.ResponseWriter, r *http.Request) {
 	promptID := chi.URLParam(r, "promptID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -516,7 +582,7 @@ func (h *Handler) HandleDeletePrompt(w http.ResponseWriter, r *http.Request) {
 	promptID := chi.URLParam(r, "promptID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You must be the guild owner or an instance admin")
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You must be the guild owner or an instance admin")
 		return
 	}
 
@@ -545,7 +611,7 @@ func (h *Handler) HandleCompleteOnboarding(w http.ResponseWriter, r *http.Reques
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You are not a member of this guild")
 		return
 	}
 
@@ -565,6 +631,11 @@ func (h *Handler) HandleCompleteOnboarding(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if apiutil.GuildRulesPending(r.Context(), h.Pool, guildID, userID) {
+		apiutil.WriteError(w, http.StatusForbidden, "rules_not_accepted", "You must accept the guild rules first")
+		return
+	}
+
 	// Collect all selected option IDs for batch lookup.
 	allOptionIDs := make([]string, 0)
 	for _, optionIDs := range req.PromptResponses {
@@ -655,7 +726,7 @@ func (h *Handler) HandleGetOnboardingStatus(w http.ResponseWriter, r *http.Reque
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You are not a member of this guild")
 		return
 	}
 
@@ -672,9 +743,15 @@ func (h *Handler) HandleGetOnboardingStatus(w http.ResponseWriter, r *http.Reque
 	}
 
 	resp := onboardingStatusResponse{
-		Completed:   completedAt != nil,
-		CompletedAt: completedAt,
+		Completed:    completedAt != nil,
+		CompletedAt:  completedAt,
+		RulesPending: apiutil.GuildRulesPending(r.Context(), h.Pool, guildID, userID),
 	}
+	h.Pool.QueryRow(r.Context(),
+		`SELECT rules_accepted_version, rules_accepted_at FROM guild_members
+		 WHERE guild_id = $1 AND user_id = $2`,
+		guildID, userID,
+	).Scan(&resp.RulesAcceptedVersion, &resp.RulesAcceptedAt)
 
 	apiutil.WriteJSON(w, http.StatusOK, resp)
 }
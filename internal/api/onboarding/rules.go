@@ -0,0 +1,194 @@
+package onboarding
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+type acceptRulesRequest struct {
+	Version int `json:"version"`
+}
+
+// ruleAcknowledgment is one member's acceptance of the guild rules. A member
+// recorded at a version with no accepted_at was grandfathered in when the
+// requirement was switched on.
+type ruleAcknowledgment struct {
+	UserID          string     `json:"user_id"`
+	Username        string     `json:"username"`
+	JoinedAt        time.Time  `json:"joined_at"`
+	AcceptedVersion *int       `json:"accepted_version"`
+	AcceptedAt      *time.Time `json:"accepted_at"`
+	Pending         bool       `json:"pending"`
+}
+
+// hasGuildPermission checks whether the user holds a guild-level permission
+// through the guild's default permissions or their roles.
+func (h *Handler) hasGuildPermission(ctx context.Context, guildID, userID string, perm uint64) bool {
+	if h.isGuildAdmin(ctx, guildID, userID) {
+		return true
+	}
+
+	var defaultPerms int64
+	if err := h.Pool.QueryRow(ctx,
+		`SELECT g.default_permissions FROM guilds g
+		 JOIN guild_members gm ON gm.guild_id = g.id AND gm.user_id = $2
+		 WHERE g.id = $1`,
+		guildID, userID,
+	).Scan(&defaultPerms); err != nil {
+		return false
+	}
+	computed := uint64(defaultPerms)
+
+	rows, err := h.Pool.Query(ctx,
+		`SELECT r.permissions_allow, r.permissions_deny
+		 FROM roles r
+		 JOIN member_roles mr ON r.id = mr.role_id
+		 WHERE mr.guild_id = $1 AND mr.user_id = $2
+		 ORDER BY r.position DESC`,
+		guildID, userID,
+	)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var allow, deny int64
+			rows.Scan(&allow, &deny)
+			computed |= uint64(allow)
+			computed &^= uint64(deny)
+		}
+	}
+
+	return computed&permissions.Administrator != 0 || computed&perm != 0
+}
+
+// HandleAcceptRules records that the member accepted the current version of
+// the guild rules. The version the client showed must be the current one, so
+// a member cannot accept rules that changed while they were reading.
+// POST /api/v1/guilds/{guildID}/onboarding/rules/accept
+func (h *Handler) HandleAcceptRules(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "You are not a member of this guild")
+		return
+	}
+
+	var req acceptRulesRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	var current int
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT rules_version FROM guild_onboarding WHERE guild_id = $1`, guildID,
+	).Scan(&current); err != nil {
+		apiutil.WriteError(w, http.StatusNotFound, "no_rules", "This guild has no rules to accept")
+		return
+	}
+	if req.Version != current {
+		apiutil.WriteError(w, http.StatusConflict, "rules_outdated", "The guild rules have changed; review them and try again")
+		return
+	}
+
+	var acceptedAt time.Time
+	if err := h.Pool.QueryRow(r.Context(),
+		`UPDATE guild_members SET rules_accepted_version = $3, rules_accepted_at = now()
+		 WHERE guild_id = $1 AND user_id = $2
+		 RETURNING rules_accepted_at`,
+		guildID, userID, current,
+	).Scan(&acceptedAt); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to accept rules", err)
+		return
+	}
+
+	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", guildID, map[string]interface{}{
+		"guild_id":               guildID,
+		"user_id":                userID,
+		"rules_accepted_version": current,
+		"rules_accepted_at":      acceptedAt,
+	})
+
+	apiutil.WriteJSON(w, http.StatusOK, onboardingStatusResponse{
+		RulesAcceptedVersion: &current,
+		RulesAcceptedAt:      &acceptedAt,
+	})
+}
+
+// HandleGetRuleAcknowledgments lists members with the rules version they
+// accepted. Filter with ?status=pending or ?status=accepted, or look up one
+// member with ?user_id=. Page with ?after=<user ID> and ?limit=. Requires
+// KICK_MEMBERS.
+// GET /api/v1/guilds/{guildID}/onboarding/rules/acknowledgments
+func (h *Handler) HandleGetRuleAcknowledgments(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.hasGuildPermission(r.Context(), guildID, userID, permissions.KickMembers) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need KICK_MEMBERS permission")
+		return
+	}
+
+	q := r.URL.Query()
+	status := q.Get("status")
+	if status != "" && status != "pending" && status != "accepted" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_status", "status must be pending or accepted")
+		return
+	}
+	limit := 100
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT m.user_id, m.username, m.joined_at, m.rules_accepted_version, m.rules_accepted_at, m.pending
+		 FROM (
+		     SELECT gm.user_id, u.username, gm.joined_at, gm.rules_accepted_version, gm.rules_accepted_at,
+		            COALESCE(o.require_rules_acceptance, false)
+		              AND g.owner_id <> gm.user_id AND u.flags & $6 = 0
+		              AND COALESCE(gm.rules_accepted_version, 0) < o.rules_version AS pending
+		     FROM guild_members gm
+		     JOIN guilds g ON g.id = gm.guild_id
+		     JOIN users u ON u.id = gm.user_id
+		     LEFT JOIN guild_onboarding o ON o.guild_id = gm.guild_id
+		     WHERE gm.guild_id = $1 AND gm.user_id > $2 AND ($3 = '' OR gm.user_id = $3)
+		 ) m
+		 WHERE $4 = '' OR m.pending = ($4 = 'pending')
+		 ORDER BY m.user_id
+		 LIMIT $5`,
+		guildID, q.Get("after"), q.Get("user_id"), status, limit, models.UserFlagBot,
+	)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list rule acknowledgments", err)
+		return
+	}
+	defer rows.Close()
+
+	acks := make([]ruleAcknowledgment, 0)
+	for rows.Next() {
+		var a ruleAcknowledgment
+		var pending *bool
+		if err := rows.Scan(&a.UserID, &a.Username, &a.JoinedAt, &a.AcceptedVersion, &a.AcceptedAt, &pending); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read rule acknowledgments", err)
+			return
+		}
+		a.Pending = pending != nil && *pending
+		acks = append(acks, a)
+	}
+	if err := rows.Err(); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to read rule acknowledgments", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, acks)
+}
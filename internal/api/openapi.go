@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/channels"
+	"github.com/amityvox/amityvox/internal/api/guilds"
+	"github.com/amityvox/amityvox/internal/api/invites"
+	"github.com/amityvox/amityvox/internal/api/openapi"
+	"github.com/amityvox/amityvox/internal/api/users"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/tokenleak"
+)
+
+// authResponse is the body returned by register and login.
+type authResponse struct {
+	User  models.SelfUser `json:"user"`
+	Token string          `json:"token"`
+}
+
+// serverOperations describes the routes handled directly by Server.
+var serverOperations = []openapi.Operation{
+	{Method: "POST", Path: "/auth/register", Summary: "Create an account", Request: auth.RegisterRequest{}, Response: authResponse{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/auth/login", Summary: "Log in", Request: auth.LoginRequest{}, Response: authResponse{}},
+	{Method: "POST", Path: "/auth/logout", Summary: "End the current session", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/instance", Summary: "Describe this instance", Response: models.PublicInstance{}},
+	{Method: "GET", Path: "/instance/status", Summary: "Maintenance and service status", Response: models.InstanceStatus{}},
+	{Method: "GET", Path: "/errors", Summary: "List the API's error codes", Response: []apierrors.Code{}},
+	{Method: "GET", Path: "/users/@me/usage", Summary: "Daily API usage of the caller", Response: usageReport{}},
+	{Method: "GET", Path: "/bots/{botID}/usage", Summary: "Daily API usage of a bot and its tokens", Response: usageReport{}},
+	{Method: "POST", Path: "/files/upload", Summary: "Upload a file", Upload: true, Response: models.Attachment{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/token-leaks", Summary: "Report leaked webhook URLs and bot tokens (secret scanning hook)", Request: tokenLeakReportRequest{}, Response: tokenleak.Result{}},
+	{Method: "POST", Path: "/admin/token-leaks", Summary: "Report leaked webhook URLs and bot tokens", Request: tokenLeakReportRequest{}, Response: tokenleak.Result{}},
+}
+
+// openAPIDoc caches the generated document. It is built on first request,
+// after main has mounted every route.
+type openAPIDoc struct {
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+// openAPISpec returns the OpenAPI document for /api/v1 as JSON.
+func (s *Server) openAPISpec() ([]byte, error) {
+	s.openAPI.once.Do(func() {
+		b := openapi.New(openapi.Info{
+			Title:       s.Config.Instance.Name + " API",
+			Version:     s.Version,
+			Description: "The AmityVox REST API. Successful responses wrap their payload in {\"data\": ...}; errors use {\"error\": {\"code\", \"message\"}}, with the codes listed at /errors.",
+		}, "/api/v1")
+		b.Secured(auth.RequireAuth(s.AuthService), auth.RequireAppAuth(s.AuthService))
+		b.Describe(serverOperations...)
+		b.Describe(users.Operations...)
+		b.Describe(guilds.Operations...)
+		b.Describe(channels.Operations...)
+		b.Describe(invites.Operations...)
+
+		doc, err := b.Build(s.Router)
+		if err != nil {
+			s.openAPI.err = err
+			return
+		}
+		s.openAPI.spec, s.openAPI.err = json.Marshal(doc)
+	})
+	return s.openAPI.spec, s.openAPI.err
+}
+
+// handleOpenAPISpec serves the OpenAPI document.
+// GET /api/v1/openapi.json
+func (s *Server) handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	spec, err := s.openAPISpec()
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to build API description", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(spec)
+}
+
+// handleListErrorCodes serves the error catalog, with messages translated for
+// the request's Accept-Language where possible.
+// GET /api/v1/errors
+func (s *Server) handleListErrorCodes(w http.ResponseWriter, r *http.Request) {
+	lang := r.Header.Get("Accept-Language")
+	codes := apierrors.All()
+	for i, c := range codes {
+		codes[i] = c.Localize(lang)
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Vary", "Accept-Language")
+	WriteJSON(w, http.StatusOK, codes)
+}
+
+// swaggerUIVersion pins the Swagger UI release loaded by /api/docs.
+const swaggerUIVersion = "5.17.14"
+
+// apiDocsPage renders Swagger UI against the served document.
+const apiDocsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>API reference</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js"></script>
+<script src="/api/docs/init.js"></script>
+</body>
+</html>
+`
+
+// apiDocsInit starts Swagger UI. It is served as a file so the page's CSP
+// needs no inline scripts.
+const apiDocsInit = `window.ui = SwaggerUIBundle({ url: "/api/v1/openapi.json", dom_id: "#swagger-ui", deepLinking: true });
+`
+
+// apiDocsCSP allows the pinned Swagger UI assets and calls back to this API.
+const apiDocsCSP = "default-src 'none'; script-src 'self' https://unpkg.com; style-src https://unpkg.com 'unsafe-inline'; img-src 'self' data: https://unpkg.com; connect-src 'self'; frame-ancestors 'none'"
+
+// handleAPIDocs serves the interactive API reference.
+// GET /api/docs
+func (s *Server) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", apiDocsCSP)
+	w.Write([]byte(apiDocsPage))
+}
+
+// handleAPIDocsInit serves the Swagger UI start-up script.
+// GET /api/docs/init.js
+func (s *Server) handleAPIDocsInit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Write([]byte(apiDocsInit))
+}
@@ -0,0 +1,378 @@
+// Package openapi builds an OpenAPI 3.1 description of the REST API. Paths,
+// methods and authentication come from walking the chi route tree, so every
+// registered route appears; handler packages describe their request and
+// response bodies with Operation entries, which are rendered from the Go
+// types by reflection.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+	Tags       []Tag                `json:"tags,omitempty"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL the API is served from.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag groups operations, one per top-level resource.
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// Components holds reusable schemas and security schemes.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests authenticate.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower-case HTTP methods to operations.
+type PathItem map[string]*OperationObject
+
+// OperationObject is one documented method on a path.
+type OperationObject struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes an operation's request body.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes one response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body in one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Operation describes the bodies of one route for the generated document.
+// Request and Response are zero values of the Go types the handler decodes
+// and writes; Response is the payload inside the {"data": ...} envelope.
+type Operation struct {
+	Method   string
+	Path     string // relative to the API prefix, e.g. /guilds/{guildID}
+	Summary  string
+	Request  interface{}
+	Response interface{}
+	Status   int  // success status; 0 means 200
+	Upload   bool // multipart/form-data with a "file" field
+}
+
+// bearerScheme is the security scheme name for session and bot tokens.
+const bearerScheme = "bearer"
+
+// Builder collects operation descriptions and renders them, together with
+// the route tree, into a Document.
+type Builder struct {
+	info   Info
+	prefix string
+	ops    map[string]Operation
+	secure map[uintptr]bool
+}
+
+// New returns a Builder for routes under prefix, e.g. /api/v1.
+func New(info Info, prefix string) *Builder {
+	return &Builder{
+		info:   info,
+		prefix: strings.TrimRight(prefix, "/"),
+		ops:    make(map[string]Operation),
+		secure: make(map[uintptr]bool),
+	}
+}
+
+// Describe adds operation descriptions.
+func (b *Builder) Describe(ops ...Operation) {
+	for _, op := range ops {
+		b.ops[op.Method+" "+op.Path] = op
+	}
+}
+
+// Secured marks routes behind any of the given middleware as requiring a
+// bearer token. Middleware is matched by its function, so any instance built
+// by the same constructor counts.
+func (b *Builder) Secured(mws ...func(http.Handler) http.Handler) {
+	for _, mw := range mws {
+		b.secure[reflect.ValueOf(mw).Pointer()] = true
+	}
+}
+
+// paramPattern matches chi path parameters, with an optional regexp.
+var paramPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Build walks routes and returns the document for everything under the
+// prefix. Catch-all routes are skipped.
+func (b *Builder) Build(routes chi.Routes) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    b.info,
+		Servers: []Server{{URL: b.prefix}},
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerScheme: {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "A session token from /auth/login or /auth/register, or a bot token.",
+				},
+			},
+		},
+	}
+	schemas := newSchemaSet()
+	schemas.components["Error"] = &Schema{Type: "object", Properties: map[string]*Schema{
+		"error": {Type: "object", Properties: map[string]*Schema{
+			"code":    {Type: "string"},
+			"message": {Type: "string"},
+		}},
+	}}
+	tags := make(map[string]bool)
+	var routeKeys []string
+	objects := make(map[string]*OperationObject)
+
+	err := walk(routes, "", nil, func(method, route string, mws []func(http.Handler) http.Handler) error {
+		path, ok := b.relativePath(route)
+		if !ok {
+			return nil
+		}
+		op := b.ops[method+" "+path]
+		obj := &OperationObject{
+			Summary:     op.Summary,
+			OperationID: operationID(method, path),
+			Parameters:  pathParameters(path),
+			Responses:   make(map[string]*Response),
+		}
+		if tag := firstSegment(path); tag != "" {
+			obj.Tags = []string{tag}
+			tags[tag] = true
+		}
+		if b.isSecured(mws) {
+			obj.Security = []map[string][]string{{bearerScheme: {}}}
+			obj.Responses["401"] = errorResponse("Missing or invalid token")
+		}
+
+		switch {
+		case op.Upload:
+			obj.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+				"multipart/form-data": {Schema: &Schema{Type: "object", Properties: map[string]*Schema{
+					"file": {Type: "string", Format: "binary"},
+				}, Required: []string{"file"}}},
+			}}
+		case op.Request != nil:
+			obj.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+				"application/json": {Schema: schemas.of(op.Request)},
+			}}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := &Response{Description: http.StatusText(status)}
+		if status != http.StatusNoContent {
+			data := schemas.of(op.Response)
+			if data == nil {
+				data = &Schema{}
+			}
+			resp.Content = map[string]MediaType{"application/json": {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"data": data},
+			}}}
+		}
+		obj.Responses[strconv.Itoa(status)] = resp
+		obj.Responses["default"] = errorResponse("Error")
+
+		item := doc.Paths[path]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(method)] = obj
+		key := path + " " + method
+		routeKeys = append(routeKeys, key)
+		objects[key] = obj
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Operation IDs must be unique; number clashes such as /a-b and /a/b in
+	// path order so they stay stable between builds.
+	sort.Strings(routeKeys)
+	seen := make(map[string]int)
+	for _, key := range routeKeys {
+		obj := objects[key]
+		if n := seen[obj.OperationID]; n > 0 {
+			seen[obj.OperationID]++
+			obj.OperationID += strconv.Itoa(n + 1)
+			continue
+		}
+		seen[obj.OperationID] = 1
+	}
+
+	doc.Components.Schemas = schemas.components
+	names := make([]string, 0, len(tags))
+	for t := range tags {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	for _, t := range names {
+		doc.Tags = append(doc.Tags, Tag{Name: t})
+	}
+	return doc, nil
+}
+
+// walk visits every route like chi.Walk, but also passes down the middleware
+// of r.Group blocks to routers mounted inside them: chi applies those by
+// wrapping the mount handler, which chi.Walk does not look through.
+func walk(r chi.Routes, parent string, parentMws []func(http.Handler) http.Handler,
+	fn func(method, route string, mws []func(http.Handler) http.Handler) error) error {
+	for _, route := range r.Routes() {
+		mws := append(append([]func(http.Handler) http.Handler(nil), parentMws...), r.Middlewares()...)
+
+		if route.SubRoutes != nil {
+			for _, h := range route.Handlers {
+				if chain, ok := h.(*chi.ChainHandler); ok {
+					mws = append(mws, chain.Middlewares...)
+					break
+				}
+			}
+			if err := walk(route.SubRoutes, parent+route.Pattern, mws, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for method, h := range route.Handlers {
+			if method == "*" {
+				continue
+			}
+			full := strings.ReplaceAll(parent+route.Pattern, "/*/", "/")
+			handlerMws := mws
+			if chain, ok := h.(*chi.ChainHandler); ok {
+				handlerMws = append(append([]func(http.Handler) http.Handler(nil), mws...), chain.Middlewares...)
+			}
+			if err := fn(method, full, handlerMws); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// relativePath converts a chi route pattern to an OpenAPI path relative to
+// the prefix, reporting false for routes outside it or catch-alls.
+func (b *Builder) relativePath(route string) (string, bool) {
+	if strings.Contains(route, "*") {
+		return "", false
+	}
+	rest, ok := strings.CutPrefix(route, b.prefix)
+	if !ok || (rest != "" && rest[0] != '/') {
+		return "", false
+	}
+	rest = strings.TrimRight(rest, "/")
+	if rest == "" {
+		rest = "/"
+	}
+	return paramPattern.ReplaceAllString(rest, "{$1}"), true
+}
+
+func (b *Builder) isSecured(mws []func(http.Handler) http.Handler) bool {
+	for _, mw := range mws {
+		if b.secure[reflect.ValueOf(mw).Pointer()] {
+			return true
+		}
+	}
+	return false
+}
+
+func errorResponse(description string) *Response {
+	return &Response{Description: description, Content: map[string]MediaType{
+		"application/json": {Schema: &Schema{Ref: "#/components/schemas/Error"}},
+	}}
+}
+
+// pathParameters lists the {name} parameters of path.
+func pathParameters(path string) []Parameter {
+	var params []Parameter
+	for _, m := range paramPattern.FindAllStringSubmatch(path, -1) {
+		params = append(params, Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	return params
+}
+
+// firstSegment returns the resource a path belongs to, e.g. "guilds".
+func firstSegment(path string) string {
+	seg, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if strings.HasPrefix(seg, "{") {
+		return ""
+	}
+	return seg
+}
+
+// operationID derives a stable identifier such as
+// postGuildsGuildIDChannels from the method and path.
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(path, "/") {
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) {
+			sb.WriteString(exportedName(word))
+		}
+	}
+	return sb.String()
+}
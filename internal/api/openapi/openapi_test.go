@@ -0,0 +1,132 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type testAuthor struct {
+	ID string `json:"id"`
+}
+
+type testMessage struct {
+	ID        string         `json:"id"`
+	Content   *string        `json:"content,omitempty"`
+	Author    *testAuthor    `json:"author,omitempty"`
+	Replies   []testMessage  `json:"replies"`
+	Meta      map[string]int `json:"meta"`
+	CreatedAt time.Time      `json:"created_at"`
+	Count     int64          `json:"count,string"`
+	Secret    string         `json:"-"`
+	hidden    string
+	Extra     map[string]string `json:"extra,omitempty"`
+}
+
+type createTestRequest struct {
+	testEmbedded
+	Content string `json:"content"`
+}
+
+type testEmbedded struct {
+	Nonce string `json:"nonce"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	s := newSchemaSet()
+	ref := s.of(testMessage{})
+	if ref.Ref != "#/components/schemas/TestMessage" {
+		t.Fatalf("ref = %q", ref.Ref)
+	}
+	msg := s.components["TestMessage"]
+	if msg == nil {
+		t.Fatal("TestMessage component missing")
+	}
+
+	checks := map[string]func(*Schema) bool{
+		"id":         func(p *Schema) bool { return p.Type == "string" },
+		"content":    func(p *Schema) bool { ty, ok := p.Type.([]string); return ok && ty[0] == "string" && ty[1] == "null" },
+		"author":     func(p *Schema) bool { return p.Ref == "#/components/schemas/TestAuthor" },
+		"replies":    func(p *Schema) bool { return p.Type == "array" && p.Items.Ref == "#/components/schemas/TestMessage" },
+		"meta":       func(p *Schema) bool { return p.Type == "object" && p.AdditionalProperties.Type == "integer" },
+		"created_at": func(p *Schema) bool { return p.Type == "string" && p.Format == "date-time" },
+		"count":      func(p *Schema) bool { return p.Type == "string" },
+	}
+	for name, ok := range checks {
+		p := msg.Properties[name]
+		if p == nil || !ok(p) {
+			t.Errorf("property %s = %+v", name, p)
+		}
+	}
+	for _, name := range []string{"Secret", "hidden"} {
+		if _, ok := msg.Properties[name]; ok {
+			t.Errorf("property %s should be skipped", name)
+		}
+	}
+
+	req := s.components[s.component(reflect.TypeOf(createTestRequest{}))]
+	if req.Properties["nonce"] == nil || req.Properties["content"] == nil {
+		t.Errorf("embedded fields not flattened: %+v", req.Properties)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	requireAuth := func(next http.Handler) http.Handler { return next }
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	r := chi.NewRouter()
+	r.Get("/health", ok)
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Post("/auth/login", ok)
+		r.Group(func(r chi.Router) {
+			r.Use(requireAuth)
+			r.Route("/items", func(r chi.Router) {
+				r.Get("/", ok)
+				r.Post("/", ok)
+				r.Get("/{itemID:[0-9]+}", ok)
+				r.Get("/files/*", ok)
+			})
+		})
+	})
+
+	b := New(Info{Title: "Test", Version: "1"}, "/api/v1")
+	b.Secured(requireAuth)
+	b.Describe(Operation{Method: "POST", Path: "/items", Summary: "Create an item",
+		Request: createTestRequest{}, Response: testMessage{}, Status: http.StatusCreated})
+	doc, err := b.Build(r)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	if _, ok := doc.Paths["/health"]; ok {
+		t.Error("routes outside the prefix should be left out")
+	}
+	if len(doc.Paths) != 3 {
+		t.Errorf("paths = %v", doc.Paths)
+	}
+	if op := (*doc.Paths["/auth/login"])["post"]; op == nil || op.Security != nil {
+		t.Errorf("login operation = %+v, want it public", op)
+	}
+
+	create := (*doc.Paths["/items"])["post"]
+	if create == nil || create.Summary != "Create an item" || create.OperationID != "postItems" {
+		t.Fatalf("create operation = %+v", create)
+	}
+	if create.Security == nil {
+		t.Error("group middleware did not mark /items as secured")
+	}
+	if create.RequestBody == nil || create.Responses["201"] == nil {
+		t.Errorf("create operation bodies = %+v, %+v", create.RequestBody, create.Responses)
+	}
+
+	get := (*doc.Paths["/items/{itemID}"])["get"]
+	if get == nil || len(get.Parameters) != 1 || get.Parameters[0].Name != "itemID" {
+		t.Errorf("item operation = %+v", get)
+	}
+	if get.Responses["200"] == nil {
+		t.Error("undescribed operation should default to a 200 response")
+	}
+}
@@ -0,0 +1,176 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is a JSON Schema object as used by OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"` // a type name, or [name, "null"]
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Describer is implemented by types whose JSON form differs from their Go
+// fields, such as those with custom UnmarshalJSON methods.
+type Describer interface {
+	OpenAPISchema() *Schema
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	rawType         = reflect.TypeOf(json.RawMessage(nil))
+	describerType   = reflect.TypeOf((*Describer)(nil)).Elem()
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// schemaSet turns Go types into schemas, collecting named structs as
+// reusable components.
+type schemaSet struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{components: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// of returns the schema for v's type, or nil for a nil v.
+func (s *schemaSet) of(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return s.schemaFor(reflect.TypeOf(v))
+}
+
+// schemaFor describes how encoding/json renders values of type t.
+func (s *schemaSet) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawType:
+		return &Schema{}
+	}
+
+	if t.Implements(describerType) {
+		return reflect.Zero(t).Interface().(Describer).OpenAPISchema()
+	}
+	if t.Kind() == reflect.Struct && (t.Implements(marshalerType) ||
+		reflect.PointerTo(t).Implements(marshalerType) || reflect.PointerTo(t).Implements(unmarshalerType)) {
+		return &Schema{} // custom encoding we cannot see into
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem := s.schemaFor(t.Elem())
+		if name, ok := elem.Type.(string); ok {
+			elem.Type = []string{name, "null"}
+		}
+		return elem
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.component(t)}
+	default:
+		return &Schema{} // interfaces and anything else: any value
+	}
+}
+
+// component registers a named struct type and returns its component name.
+func (s *schemaSet) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := exportedName(t.Name())
+	if _, taken := s.components[name]; taken {
+		// Same name in another package, e.g. two updateChannelRequests.
+		pkg := t.PkgPath()
+		name = exportedName(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	// Reserve the name before filling it in so self-references terminate.
+	s.names[t] = name
+	s.components[name] = &Schema{}
+	*s.components[name] = *s.structSchema(t)
+	return name
+}
+
+// structSchema describes a struct's JSON object, following encoding/json's
+// rules for tags and embedded structs.
+func (s *schemaSet) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range s.structSchema(ft).Properties {
+					if _, ok := schema.Properties[k]; !ok {
+						schema.Properties[k] = v
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := s.schemaFor(f.Type)
+		if strings.Contains(opts, "string") {
+			prop = &Schema{Type: "string"}
+		}
+		schema.Properties[name] = prop
+	}
+	return schema
+}
+
+// exportedName upper-cases the first letter of a Go type name.
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -385,7 +386,7 @@ func (h *Handler) HandleClosePoll(w http.ResponseWriter, r *http.Request) {
 		var userFlags int
 		h.Pool.QueryRow(r.Context(), `SELECT flags FROM users WHERE id = $1`, userID).Scan(&userFlags)
 		if userFlags&models.UserFlagAdmin == 0 {
-			apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only the poll author or an admin can close this poll")
+			apiutil.WriteCode(w, apierrors.Forbidden, "Only the poll author or an admin can close this poll")
 			return
 		}
 	}
@@ -435,7 +436,7 @@ func (h *Handler) HandleDeletePoll(w http.ResponseWriter, r *http.Request) {
 		var userFlags int
 		h.Pool.QueryRow(r.Context(), `SELECT flags FROM users WHERE id = $1`, userID).Scan(&userFlags)
 		if userFlags&models.UserFlagAdmin == 0 {
-			apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only the poll author or an admin can delete this poll")
+			apiutil.WriteCode(w, apierrors.Forbidden, "Only the poll author or an admin can delete this poll")
 			return
 		}
 	}
@@ -7,8 +7,12 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/presence"
@@ -43,6 +47,52 @@ const (
 	// Webhook execution: 300 calls per minute per webhook.
 	webhookRateLimit  = 300
 	webhookRateWindow = 1 * time.Minute
+
+	// Webhook bursts: 10 calls per 2 seconds per webhook, so a runaway
+	// integration cannot flood a channel with its whole minute's quota.
+	webhookBurstLimit  = 10
+	webhookBurstWindow = 2 * time.Second
+
+	// Bulk role updates: 10 requests per minute per user. Each can touch up
+	// to 1000 members and fan out one event per member.
+	bulkRoleRateLimit  = 10
+	bulkRoleRateWindow = 1 * time.Minute
+
+	// User-app tokens: 600 requests per minute per app. Their own bucket, so
+	// an app answering many users' DMs cannot starve its own bot token.
+	userAppRateLimit  = 600
+	userAppRateWindow = 1 * time.Minute
+
+	// DM creation: 60 requests per hour per user, for 1:1 and group DMs
+	// alike. Opening DMs with strangers is limited further by the handlers.
+	dmCreateRateLimit  = 60
+	dmCreateRateWindow = 1 * time.Hour
+
+	// Federation proxy: 3000 requests per minute per user. Its own bucket so
+	// browsing remote guilds, where every view is a round trip to a peer,
+	// neither eats into nor is starved by the local global limit.
+	fedProxyRateLimit  = 3000
+	fedProxyRateWindow = 1 * time.Minute
+
+	// Federated messages, reactions and typing: 200 per 10 seconds per user.
+	// Higher than local messages, since typing and reactions share it.
+	fedMessageRateLimit  = 200
+	fedMessageRateWindow = 10 * time.Second
+
+	// Federated joins and invite resolution: 10 per minute per user. Each one
+	// makes a peer create a member, so they are kept strict.
+	fedJoinRateLimit  = 10
+	fedJoinRateWindow = 1 * time.Minute
+
+	// Federated discovery: 30 queries per minute per user. An aggregated
+	// query fans out to every peer.
+	fedDiscoveryRateLimit  = 30
+	fedDiscoveryRateWindow = 1 * time.Minute
+
+	// Federated guild concurrency: at most 16 proxy requests in flight per
+	// remote guild on this node, so one slow or misbehaving guild cannot tie
+	// up every worker waiting on its home instance.
+	fedGuildMaxInflight = 16
 )
 
 // RateLimitGlobal returns middleware that enforces rate limits using
@@ -64,7 +114,11 @@ func (s *Server) RateLimitGlobal() func(http.Handler) http.Handler {
 			var limit int
 			var window time.Duration
 
-			if userID != "" {
+			if userID != "" && auth.TokenScopeFromContext(r.Context()) == models.BotTokenScopeUserApp {
+				key = "userapp:" + userID
+				limit = userAppRateLimit
+				window = userAppRateWindow
+			} else if userID != "" {
 				key = "global:" + userID
 				limit = authedRateLimit
 				window = authedRateWindow
@@ -181,7 +235,38 @@ func (s *Server) RateLimitSearch(next http.Handler) http.Handler {
 	})
 }
 
-// RateLimitWebhooks is middleware for webhook execution with per-webhook rate limits.
+// RateLimitBulkRoles is middleware for bulk role assignment.
+func (s *Server) RateLimitBulkRoles(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Cache == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID := auth.UserIDFromContext(r.Context())
+		if userID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := s.Cache.CheckRateLimitInfo(r.Context(), "bulk_roles:"+userID, bulkRoleRateLimit, bulkRoleRateWindow)
+		if err != nil {
+			s.Logger.Debug("bulk role rate limit check failed", slog.String("error", err.Error()))
+			next.ServeHTTP(w, r)
+			return
+		}
+		setRateLimitHeaders(w, result, bulkRoleRateWindow)
+		if !result.Allowed {
+			writeRateLimitResponse(w, bulkRoleRateWindow)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimitWebhooks is middleware for webhook execution with per-webhook rate
+// limits: a short burst bucket and a per-minute bucket.
 func (s *Server) RateLimitWebhooks(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if s.Cache == nil {
@@ -189,9 +274,21 @@ func (s *Server) RateLimitWebhooks(next http.Handler) http.Handler {
 			return
 		}
 
-		// Use the webhook ID from URL path as the rate limit key.
-		key := "webhook:" + r.URL.Path
-		result, err := s.Cache.CheckRateLimitInfo(r.Context(), key, webhookRateLimit, webhookRateWindow)
+		// Key on the webhook ID alone, so the bucket is shared by every
+		// thread the webhook posts to and cannot be reset by varying the path.
+		webhookID := chi.URLParam(r, "webhookID")
+		burst, err := s.Cache.CheckRateLimitInfo(r.Context(), "webhook_burst:"+webhookID, webhookBurstLimit, webhookBurstWindow)
+		if err != nil {
+			s.Logger.Debug("webhook rate limit check failed", slog.String("error", err.Error()))
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !burst.Allowed {
+			setRateLimitHeaders(w, burst, webhookBurstWindow)
+			writeRateLimitResponse(w, webhookBurstWindow)
+			return
+		}
+		result, err := s.Cache.CheckRateLimitInfo(r.Context(), "webhook:"+webhookID, webhookRateLimit, webhookRateWindow)
 		if err != nil {
 			s.Logger.Debug("webhook rate limit check failed", slog.String("error", err.Error()))
 			next.ServeHTTP(w, r)
@@ -207,6 +304,119 @@ func (s *Server) RateLimitWebhooks(next http.Handler) http.Handler {
 	})
 }
 
+// RateLimitDMCreate is middleware for opening DM and group DM channels.
+// Messages sent into DMs are limited separately by the channel handler.
+func (s *Server) RateLimitDMCreate(next http.Handler) http.Handler {
+	return s.rateLimitUserClass("dm_create", dmCreateRateLimit, dmCreateRateWindow, next)
+}
+
+// RateLimitFederationProxy is middleware for the /api/v1/federation proxy
+// endpoints. It takes the place of RateLimitGlobal on those routes.
+func (s *Server) RateLimitFederationProxy(next http.Handler) http.Handler {
+	return s.rateLimitUserClass("fedproxy", fedProxyRateLimit, fedProxyRateWindow, next)
+}
+
+// RateLimitFederationMessages is middleware for sending messages, reactions
+// and typing to remote guilds.
+func (s *Server) RateLimitFederationMessages(next http.Handler) http.Handler {
+	return s.rateLimitUserClass("fedproxy_msg", fedMessageRateLimit, fedMessageRateWindow, next)
+}
+
+// RateLimitFederationJoin is middleware for joining remote guilds and
+// resolving remote invites.
+func (s *Server) RateLimitFederationJoin(next http.Handler) http.Handler {
+	return s.rateLimitUserClass("fedproxy_join", fedJoinRateLimit, fedJoinRateWindow, next)
+}
+
+// RateLimitFederationDiscovery is middleware for remote guild discovery.
+func (s *Server) RateLimitFederationDiscovery(next http.Handler) http.Handler {
+	return s.rateLimitUserClass("fedproxy_discover", fedDiscoveryRateLimit, fedDiscoveryRateWindow, next)
+}
+
+// rateLimitUserClass enforces a per-user bucket keyed by class. Requests
+// without a user pass through, as do requests when the cache is unavailable.
+func (s *Server) rateLimitUserClass(class string, limit int, window time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Cache == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID := auth.UserIDFromContext(r.Context())
+		if userID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := s.Cache.CheckRateLimitInfo(r.Context(), class+":"+userID, limit, window)
+		if err != nil {
+			s.Logger.Debug("rate limit check failed", slog.String("class", class), slog.String("error", err.Error()))
+			next.ServeHTTP(w, r)
+			return
+		}
+		setRateLimitHeaders(w, result, window)
+		if !result.Allowed {
+			writeRateLimitResponse(w, window)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitFederatedGuildConcurrency is middleware that caps the federation proxy
+// requests in flight for one remote guild, taken from the guildID URL
+// parameter. Requests over the cap are refused with 429 rather than queued.
+// The cap is per node: it protects this node's workers, not the remote guild.
+func (s *Server) LimitFederatedGuildConcurrency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		guildID := chi.URLParam(r, "guildID")
+		if guildID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.fedGuildInflight.acquire(guildID, fedGuildMaxInflight) {
+			writeRateLimitResponse(w, time.Second)
+			return
+		}
+		defer s.fedGuildInflight.release(guildID)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// inflightLimiter counts requests in flight per key. The zero value is ready
+// to use.
+type inflightLimiter struct {
+	mu sync.Mutex
+	n  map[string]int
+}
+
+// acquire takes a slot for key, reporting false if max are already taken.
+func (l *inflightLimiter) acquire(key string, max int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.n[key] >= max {
+		return false
+	}
+	if l.n == nil {
+		l.n = make(map[string]int)
+	}
+	l.n[key]++
+	return true
+}
+
+// release frees a slot taken by acquire.
+func (l *inflightLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.n[key] <= 1 {
+		delete(l.n, key)
+		return
+	}
+	l.n[key]--
+}
+
 // setRateLimitHeaders sets X-RateLimit-* headers on every response so clients
 // can track their remaining quota proactively.
 func setRateLimitHeaders(w http.ResponseWriter, result presence.RateLimitResult, window time.Duration) {
@@ -219,7 +429,7 @@ func setRateLimitHeaders(w http.ResponseWriter, result presence.RateLimitResult,
 // standard rate limit headers.
 func writeRateLimitResponse(w http.ResponseWriter, retryAfter time.Duration) {
 	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
-	WriteError(w, http.StatusTooManyRequests, "rate_limited", "You are being rate limited. Please try again later.")
+	WriteCode(w, apierrors.RateLimited, "")
 }
 
 // isAuthEndpoint returns true if the request targets an auth endpoint.
@@ -229,9 +439,9 @@ func isAuthEndpoint(r *http.Request) bool {
 		path == "/api/v1/auth/register"
 }
 
-// clientIP extracts the client IP from the request. Chi's RealIP middleware
-// already sets r.RemoteAddr from trusted proxy headers, so we just strip the
-// port from RemoteAddr. We do NOT re-parse X-Forwarded-For here to avoid
+// clientIP extracts the client IP from the request. The trustedRealIP
+// middleware already sets r.RemoteAddr from trusted proxy headers, so we just
+// strip the port from RemoteAddr. We do NOT re-parse X-Forwarded-For here to avoid
 // trusting arbitrary client-supplied headers.
 func clientIP(r *http.Request) string {
 	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && host != "" {
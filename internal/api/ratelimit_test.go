@@ -1,10 +1,14 @@
 package api
 
 import (
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/config"
 	"github.com/amityvox/amityvox/internal/presence"
 )
 
@@ -31,28 +35,47 @@ func TestIsAuthEndpoint(t *testing.T) {
 }
 
 func TestClientIP(t *testing.T) {
-	// With X-Forwarded-For.
-	req := httptest.NewRequest(http.MethodGet, "/test", nil)
-	req.Header.Set("X-Forwarded-For", "1.2.3.4")
-	req.RemoteAddr = "10.0.0.1:12345"
-	if got := clientIP(req); got != "1.2.3.4" {
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	s := &Server{Config: &config.Config{HTTP: config.HTTPConfig{TrustedProxies: []string{"10.0.0.0/8"}}}}
+	var got string
+	handler := s.trustedRealIP()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientIP(r)
+	}))
+	serve := func(remote, xff string) string {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = remote
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return got
+	}
+
+	// With X-Forwarded-For from a trusted proxy.
+	if got := serve("10.0.0.1:12345", "1.2.3.4"); got != "1.2.3.4" {
 		t.Errorf("clientIP with XFF = %q, want %q", got, "1.2.3.4")
 	}
 
 	// Without X-Forwarded-For — port should be stripped.
-	req2 := httptest.NewRequest(http.MethodGet, "/test", nil)
-	req2.RemoteAddr = "10.0.0.1:12345"
-	if got := clientIP(req2); got != "10.0.0.1" {
+	if got := serve("10.0.0.1:12345", ""); got != "10.0.0.1" {
 		t.Errorf("clientIP without XFF = %q, want %q", got, "10.0.0.1")
 	}
 
-	// X-Forwarded-For with multiple IPs — should take the first.
-	req3 := httptest.NewRequest(http.MethodGet, "/test", nil)
-	req3.Header.Set("X-Forwarded-For", "1.2.3.4, 5.6.7.8, 9.10.11.12")
-	req3.RemoteAddr = "10.0.0.1:12345"
-	if got := clientIP(req3); got != "1.2.3.4" {
+	// X-Forwarded-For with multiple IPs — the nearest untrusted hop is the
+	// client; anything left of it was supplied by the client.
+	if got := serve("10.0.0.1:12345", "9.9.9.9, 1.2.3.4, 10.0.0.7"); got != "1.2.3.4" {
 		t.Errorf("clientIP with multiple XFF = %q, want %q", got, "1.2.3.4")
 	}
+
+	// X-Forwarded-For from an untrusted peer is ignored.
+	if got := serve("5.6.7.8:12345", "1.2.3.4"); got != "5.6.7.8" {
+		t.Errorf("clientIP with spoofed XFF = %q, want %q", got, "5.6.7.8")
+	}
+
+	// Every hop trusted: the leftmost is the client.
+	if got := forwardedClientIP("10.0.0.1", http.Header{"X-Forwarded-For": {"10.1.1.1, 10.2.2.2"}}, []*net.IPNet{private}); got != "10.1.1.1" {
+		t.Errorf("forwardedClientIP with all hops trusted = %q, want %q", got, "10.1.1.1")
+	}
 }
 
 func TestWriteRateLimitResponse(t *testing.T) {
@@ -143,3 +166,71 @@ func TestRateLimitSearch_NoCache(t *testing.T) {
 		t.Error("handler should be called when cache is nil")
 	}
 }
+
+func TestRateLimitFederationProxy_NoCache(t *testing.T) {
+	s := &Server{Cache: nil}
+
+	called := false
+	handler := s.RateLimitFederationProxy(s.RateLimitFederationJoin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/federation/guilds/join", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if !called {
+		t.Error("handler should be called when cache is nil")
+	}
+}
+
+func TestInflightLimiter(t *testing.T) {
+	var l inflightLimiter
+	if !l.acquire("g1", 2) || !l.acquire("g1", 2) {
+		t.Fatal("acquire under the cap should succeed")
+	}
+	if l.acquire("g1", 2) {
+		t.Error("acquire over the cap should fail")
+	}
+	if !l.acquire("g2", 2) {
+		t.Error("another key should have its own slots")
+	}
+	l.release("g1")
+	if !l.acquire("g1", 2) {
+		t.Error("acquire after release should succeed")
+	}
+	l.release("g1")
+	l.release("g1")
+	l.release("g2")
+	if len(l.n) != 0 {
+		t.Errorf("released keys should be dropped, have %v", l.n)
+	}
+}
+
+func TestLimitFederatedGuildConcurrency(t *testing.T) {
+	s := &Server{}
+	for i := 0; i < fedGuildMaxInflight; i++ {
+		s.fedGuildInflight.acquire("g1", fedGuildMaxInflight)
+	}
+
+	r := chi.NewRouter()
+	r.With(s.LimitFederatedGuildConcurrency).Get("/guilds/{guildID}/members", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/guilds/g1/members", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("full guild: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/guilds/g2/members", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("other guild: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := s.fedGuildInflight.n["g2"]; got != 0 {
+		t.Errorf("slot for g2 not released, count = %d", got)
+	}
+}
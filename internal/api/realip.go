@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// forwardedClientIP returns the client address for a request that arrived
+// from remote. Forwarding headers are only believed when remote is a trusted
+// proxy. X-Forwarded-For is read right to left, skipping trusted proxies, so
+// a client cannot pick its own address by sending the header itself; if
+// every hop is trusted the leftmost address is the client.
+func forwardedClientIP(remote string, header http.Header, trusted []*net.IPNet) string {
+	if !ipTrusted(net.ParseIP(remote), trusted) {
+		return remote
+	}
+	if xff := header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := ""
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				// A malformed hop ends the chain we can vouch for.
+				break
+			}
+			client = ip.String()
+			if !ipTrusted(ip, trusted) {
+				break
+			}
+		}
+		if client != "" {
+			return client
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return remote
+}
+
+func ipTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedRealIP returns middleware that sets r.RemoteAddr to the client IP
+// behind the configured trusted proxies, for rate limiting, sessions and
+// request logs. Requests from anywhere else keep their connection address.
+func (s *Server) trustedRealIP() func(http.Handler) http.Handler {
+	// Validated at config load.
+	trusted, _ := s.Config.HTTP.TrustedProxyNets()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			if ip := forwardedClientIP(host, r.Header, trusted); ip != host {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,1370 @@
+// Package scim implements a SCIM 2.0 (RFC 7643/7644) provisioning surface so
+// corporate identity providers can create, update, and deprovision local
+// accounts and manage groups. SCIM groups can be mapped onto guild roles;
+// membership changes are reflected as role grants and revocations.
+// Deprovisioned users are suspended and all of their sessions are revoked.
+// Provider-facing endpoints are mounted under /scim/v2/ and authenticate with
+// admin-issued SCIM bearer tokens; token and mapping management is mounted
+// under /api/v1/admin/scim/.
+package scim
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/presence"
+)
+
+// SCIM schema URNs used in requests and responses.
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaSPConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// maxPageSize caps the count parameter on list requests.
+const maxPageSize = 200
+
+// errStaffSuspended is returned when SCIM tries to reactivate an account it
+// did not suspend itself.
+var errStaffSuspended = errors.New("user was suspended by instance staff and cannot be reactivated through SCIM")
+
+type contextKey string
+
+// contextKeyTokenID carries the ID of the SCIM token a request was made with.
+const contextKeyTokenID contextKey = "scim_token_id"
+
+// Handler implements the SCIM provisioning endpoints.
+type Handler struct {
+	Pool       *pgxpool.Pool
+	EventBus   *events.Bus
+	Cache      *presence.Cache
+	InstanceID string
+	Logger     *slog.Logger
+}
+
+// --- Types ---
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+type scimUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Name        *scimName   `json:"name,omitempty"`
+	Emails      []scimEmail `json:"emails,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Meta        *scimMeta   `json:"meta,omitempty"`
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members,omitempty"`
+	Meta        *scimMeta    `json:"meta,omitempty"`
+}
+
+type scimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+type scimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// --- Response helpers ---
+
+// writeSCIM writes a SCIM resource with the application/scim+json media type.
+func writeSCIM(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeSCIMError writes an error in the SCIM error schema (RFC 7644 §3.12).
+func writeSCIMError(w http.ResponseWriter, status int, scimType, detail string) {
+	writeSCIM(w, status, scimError{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// decodeSCIM decodes a SCIM request body, writing an invalidSyntax error on
+// failure.
+func decodeSCIM(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+		return false
+	}
+	return true
+}
+
+// --- Helpers ---
+
+// generateToken creates a random SCIM bearer token and its SHA-256 hash. The
+// raw token is prefixed with "avscim_" and shown to the admin exactly once.
+func generateToken() (raw string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generating random bytes: %w", err)
+	}
+	raw = "avscim_" + hex.EncodeToString(b)
+	return raw, hashToken(raw), nil
+}
+
+func hashToken(raw string) string {
+	h := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(h[:])
+}
+
+// parseFilter parses the single-clause equality filters identity providers
+// send when looking up existing resources, e.g. `userName eq "alice"`.
+// Only the "eq" operator is supported; the attribute name is returned
+// lower-cased.
+func parseFilter(filter string) (attr, value string, ok bool) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return "", "", false
+	}
+	parts := strings.SplitN(filter, " ", 3)
+	if len(parts) != 3 || !strings.EqualFold(parts[1], "eq") {
+		return "", "", false
+	}
+	value = strings.TrimSpace(parts[2])
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", "", false
+	}
+	return strings.ToLower(parts[0]), value[1 : len(value)-1], true
+}
+
+// parsePaging reads the 1-based startIndex and count query parameters and
+// returns a SQL offset and limit.
+func parsePaging(r *http.Request) (startIndex, count int) {
+	startIndex, count = 1, 100
+	if v, err := strconv.Atoi(r.URL.Query().Get("startIndex")); err == nil && v > 0 {
+		startIndex = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && v >= 0 {
+		count = v
+	}
+	if count > maxPageSize {
+		count = maxPageSize
+	}
+	return startIndex, count
+}
+
+// primaryEmail picks the primary email from a SCIM emails list, falling back
+// to the first entry.
+func primaryEmail(emails []scimEmail) *string {
+	for _, e := range emails {
+		if e.Primary && e.Value != "" {
+			v := e.Value
+			return &v
+		}
+	}
+	if len(emails) > 0 && emails[0].Value != "" {
+		v := emails[0].Value
+		return &v
+	}
+	return nil
+}
+
+// displayNameFor derives the display name from the explicit displayName or
+// the structured name attribute.
+func displayNameFor(u scimUser) *string {
+	if u.DisplayName != "" {
+		v := u.DisplayName
+		return &v
+	}
+	if u.Name != nil {
+		if u.Name.Formatted != "" {
+			v := u.Name.Formatted
+			return &v
+		}
+		full := strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+		if full != "" {
+			return &full
+		}
+	}
+	return nil
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+// --- Token middleware ---
+
+// RequireToken authenticates SCIM requests using an admin-issued bearer token.
+func (h *Handler) RequireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if len(header) < 8 || !strings.EqualFold(header[:7], "Bearer ") {
+			writeSCIMError(w, http.StatusUnauthorized, "", "Missing bearer token")
+			return
+		}
+		hash := hashToken(strings.TrimSpace(header[7:]))
+
+		var tokenID string
+		err := h.Pool.QueryRow(r.Context(),
+			`UPDATE scim_tokens SET last_used_at = now() WHERE token_hash = $1 RETURNING id`,
+			hash).Scan(&tokenID)
+		if err != nil {
+			writeSCIMError(w, http.StatusUnauthorized, "", "Invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyTokenID, tokenID)))
+	})
+}
+
+// --- Discovery ---
+
+// HandleServiceProviderConfig describes the supported SCIM features.
+// GET /scim/v2/ServiceProviderConfig
+func (h *Handler) HandleServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeSCIM(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{SchemaSPConfig},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": maxPageSize},
+		"changePassword": map[string]bool{"supported": false},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "SCIM token issued by an instance administrator",
+			"primary":     true,
+		}},
+	})
+}
+
+// --- Users ---
+
+const userColumns = `id, username, display_name, email, flags, scim_external_id, created_at`
+
+func (h *Handler) scanUser(row pgx.Row) (scimUser, error) {
+	var (
+		id, username       string
+		displayName, email *string
+		externalID         *string
+		flags              int
+		createdAt          time.Time
+	)
+	if err := row.Scan(&id, &username, &displayName, &email, &flags, &externalID, &createdAt); err != nil {
+		return scimUser{}, err
+	}
+	u := scimUser{
+		Schemas:  []string{SchemaUser},
+		ID:       id,
+		UserName: username,
+		Active:   boolPtr(flags&models.UserFlagSuspended == 0),
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Created:      createdAt.UTC().Format(time.RFC3339),
+			Location:     "/scim/v2/Users/" + id,
+		},
+	}
+	if displayName != nil {
+		u.DisplayName = *displayName
+	}
+	if externalID != nil {
+		u.ExternalID = *externalID
+	}
+	if email != nil {
+		u.Emails = []scimEmail{{Value: *email, Primary: true, Type: "work"}}
+	}
+	return u, nil
+}
+
+// HandleListUsers lists local users, optionally filtered by userName or
+// externalId.
+// GET /scim/v2/Users
+func (h *Handler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
+	startIndex, count := parsePaging(r)
+
+	where := `instance_id = $1 AND bot_owner_id IS NULL AND flags & $2 = 0`
+	args := []interface{}{h.InstanceID, models.UserFlagDeleted}
+	if f := r.URL.Query().Get("filter"); f != "" {
+		attr, value, ok := parseFilter(f)
+		if !ok {
+			writeSCIMError(w, http.StatusBadRequest, "invalidFilter", "Unsupported filter expression")
+			return
+		}
+		switch attr {
+		case "username":
+			where += ` AND LOWER(username) = LOWER($3)`
+		case "externalid":
+			where += ` AND scim_external_id = $3`
+		case "id":
+			where += ` AND id = $3`
+		default:
+			writeSCIMError(w, http.StatusBadRequest, "invalidFilter", "Unsupported filter attribute")
+			return
+		}
+		args = append(args, value)
+	}
+
+	var total int
+	if err := h.Pool.QueryRow(r.Context(), `SELECT COUNT(*) FROM users WHERE `+where, args...).Scan(&total); err != nil {
+		h.Logger.Error("scim: counting users", slog.String("error", err.Error()))
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to list users")
+		return
+	}
+
+	n := len(args)
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+userColumns+` FROM users WHERE `+where+
+			fmt.Sprintf(` ORDER BY created_at LIMIT $%d OFFSET $%d`, n+1, n+2),
+		append(args, count, startIndex-1)...)
+	if err != nil {
+		h.Logger.Error("scim: listing users", slog.String("error", err.Error()))
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to list users")
+		return
+	}
+	defer rows.Close()
+
+	resources := make([]scimUser, 0)
+	for rows.Next() {
+		u, err := h.scanUser(rows)
+		if err != nil {
+			h.Logger.Error("scim: scanning user", slog.String("error", err.Error()))
+			writeSCIMError(w, http.StatusInternalServerError, "", "Failed to list users")
+			return
+		}
+		resources = append(resources, u)
+	}
+
+	writeSCIM(w, http.StatusOK, scimListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// HandleGetUser returns a single user.
+// GET /scim/v2/Users/{userID}
+func (h *Handler) HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	u, err := h.getUser(r.Context(), chi.URLParam(r, "userID"))
+	if err == pgx.ErrNoRows {
+		writeSCIMError(w, http.StatusNotFound, "", "User not found")
+		return
+	}
+	if err != nil {
+		h.Logger.Error("scim: getting user", slog.String("error", err.Error()))
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to get user")
+		return
+	}
+	writeSCIM(w, http.StatusOK, u)
+}
+
+func (h *Handler) getUser(ctx context.Context, userID string) (scimUser, error) {
+	return h.scanUser(h.Pool.QueryRow(ctx,
+		`SELECT `+userColumns+` FROM users
+		 WHERE id = $1 AND instance_id = $2 AND flags & $3 = 0`,
+		userID, h.InstanceID, models.UserFlagDeleted))
+}
+
+// HandleCreateUser provisions a new local account. Provisioned accounts have
+// no password; they sign in through the identity provider's SSO path.
+// POST /scim/v2/Users
+func (h *Handler) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req scimUser
+	if !decodeSCIM(w, r, &req) {
+		return
+	}
+	req.UserName = strings.TrimSpace(req.UserName)
+	if !auth.ValidUsername(req.UserName) {
+		writeSCIMError(w, http.StatusBadRequest, "invalidValue",
+			"userName must be 2-32 characters and contain only letters, numbers, underscores, hyphens, and dots")
+		return
+	}
+
+	var externalID *string
+	if req.ExternalID != "" {
+		externalID = &req.ExternalID
+	}
+	flags, suspended := 0, req.Active != nil && !*req.Active
+	if suspended {
+		flags = models.UserFlagSuspended
+	}
+
+	// The name check and the insert are one statement; a concurrent create
+	// of the same name or external ID fails on the unique indexes instead.
+	id := models.NewULID().String()
+	tag, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO users (id, instance_id, username, display_name, email, flags, scim_external_id,
+		                    scim_provisioned, scim_suspended, created_at)
+		 SELECT $1, $2, $3, $4, $5, $6::int, $7, true, $8::boolean, now()
+		 WHERE NOT EXISTS (SELECT 1 FROM users WHERE LOWER(username) = LOWER($3) AND instance_id = $2)`,
+		id, h.InstanceID, req.UserName, displayNameFor(req), primaryEmail(req.Emails), flags, externalID, suspended)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == "23505", err == nil && tag.RowsAffected() == 0:
+		writeSCIMError(w, http.StatusConflict, "uniqueness", "userName or externalId is already taken")
+		return
+	case err != nil:
+		h.Logger.Error("scim: creating user", slog.String("error", err.Error()))
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to create user")
+		return
+	}
+
+	h.Logger.Info("scim: user provisioned", slog.String("user_id", id), slog.String("username", req.UserName))
+
+	u, err := h.getUser(r.Context(), id)
+	if err != nil {
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to load user")
+		return
+	}
+	writeSCIM(w, http.StatusCreated, u)
+}
+
+// HandleReplaceUser replaces a user's provisioned attributes.
+// PUT /scim/v2/Users/{userID}
+func (h *Handler) HandleReplaceUser(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	var req scimUser
+	if !decodeSCIM(w, r, &req) {
+		return
+	}
+
+	var externalID *string
+	if req.ExternalID != "" {
+		externalID = &req.ExternalID
+	}
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE users SET display_name = $3, email = $4, scim_external_id = $5
+		 WHERE id = $1 AND instance_id = $2`,
+		userID, h.InstanceID, displayNameFor(req), primaryEmail(req.Emails), externalID)
+	if err != nil {
+		h.Logger.Error("scim: replacing user", slog.String("error", err.Error()))
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to update user")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		writeSCIMError(w, http.StatusNotFound, "", "User not found")
+		return
+	}
+	if req.Active != nil {
+		err := h.setActive(r.Context(), userID, *req.Active)
+		switch {
+		case errors.Is(err, errStaffSuspended):
+			writeSCIMError(w, http.StatusBadRequest, "mutability", err.Error())
+			return
+		case err != nil:
+			h.Logger.Error("scim: updating active state", slog.String("error", err.Error()))
+			writeSCIMError(w, http.StatusInternalServerError, "", "Failed to update user")
+			return
+		}
+	}
+
+	h.HandleGetUser(w, r)
+}
+
+// HandlePatchUser applies a SCIM PatchOp to a user. The attributes providers
+// commonly patch (active, displayName, externalId, emails) are supported.
+// PATCH /scim/v2/Users/{userID}
+func (h *Handler) HandlePatchUser(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	var req scimPatchRequest
+	if !decodeSCIM(w, r, &req) {
+		return
+	}
+
+	if _, err := h.getUser(r.Context(), userID); err != nil {
+		writeSCIMError(w, http.StatusNotFound, "", "User not found")
+		return
+	}
+
+	for _, op := range req.Operations {
+		attrs, err := patchAttributes(op)
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+		for attr, raw := range attrs {
+			err := h.applyUserAttribute(r.Context(), userID, attr, raw)
+			switch {
+			case errors.Is(err, errStaffSuspended):
+				writeSCIMError(w, http.StatusBadRequest, "mutability", err.Error())
+				return
+			case err != nil:
+				writeSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+				return
+			}
+		}
+	}
+
+	h.HandleGetUser(w, r)
+}
+
+// patchAttributes normalises a patch operation into attribute/value pairs.
+// Operations without a path carry an object of attributes as their value.
+func patchAttributes(op scimPatchOperation) (map[string]json.RawMessage, error) {
+	switch strings.ToLower(op.Op) {
+	case "add", "replace":
+	default:
+		return nil, fmt.Errorf("unsupported patch operation %q", op.Op)
+	}
+	if op.Path != "" {
+		return map[string]json.RawMessage{strings.ToLower(op.Path): op.Value}, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(op.Value, &obj); err != nil {
+		return nil, fmt.Errorf("patch value must be an object when path is omitted")
+	}
+	attrs := make(map[string]json.RawMessage, len(obj))
+	for k, v := range obj {
+		attrs[strings.ToLower(k)] = v
+	}
+	return attrs, nil
+}
+
+func (h *Handler) applyUserAttribute(ctx context.Context, userID, attr string, raw json.RawMessage) error {
+	switch attr {
+	case "active":
+		var active bool
+		if err := json.Unmarshal(raw, &active); err != nil {
+			// Some providers send "True"/"False" as strings.
+			var s string
+			if json.Unmarshal(raw, &s) != nil {
+				return fmt.Errorf("active must be a boolean")
+			}
+			active = strings.EqualFold(s, "true")
+		}
+		return h.setActive(ctx, userID, active)
+	case "displayname":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fmt.Errorf("displayName must be a string")
+		}
+		_, err := h.Pool.Exec(ctx, `UPDATE users SET display_name = $2 WHERE id = $1`, userID, v)
+		return err
+	case "externalid":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fmt.Errorf("externalId must be a string")
+		}
+		_, err := h.Pool.Exec(ctx, `UPDATE users SET scim_external_id = NULLIF($2, '') WHERE id = $1`, userID, v)
+		return err
+	case "emails":
+		var v []scimEmail
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fmt.Errorf("emails must be a list")
+		}
+		_, err := h.Pool.Exec(ctx, `UPDATE users SET email = $2 WHERE id = $1`, userID, primaryEmail(v))
+		return err
+	}
+	// Unknown attributes are ignored so providers that send extension
+	// attributes do not fail the whole request.
+	return nil
+}
+
+// setActive suspends or reinstates a user. Deactivation also revokes every
+// session so the user is signed out immediately. Only accounts SCIM
+// provisioned and suspended itself are reinstated, never instance-banned ones.
+func (h *Handler) setActive(ctx context.Context, userID string, active bool) error {
+	if !active {
+		return h.deprovision(ctx, userID)
+	}
+
+	tag, err := h.Pool.Exec(ctx,
+		`UPDATE users SET flags = flags & $1, scim_suspended = false
+		 WHERE id = $2 AND instance_id = $3 AND scim_provisioned AND scim_suspended
+		   AND NOT EXISTS (SELECT 1 FROM instance_bans ib WHERE ib.user_id = users.id)`,
+		^models.UserFlagSuspended, userID, h.InstanceID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		var flags int
+		if err := h.Pool.QueryRow(ctx,
+			`SELECT flags FROM users WHERE id = $1 AND instance_id = $2`, userID, h.InstanceID,
+		).Scan(&flags); err != nil {
+			return err
+		}
+		if flags&models.UserFlagSuspended != 0 {
+			return errStaffSuspended
+		}
+		return nil
+	}
+	h.logAction(ctx, models.StaffActionSCIMUserActivate, userID, map[string]bool{"suspended": false})
+	h.Logger.Info("scim: user reactivated", slog.String("user_id", userID))
+	return nil
+}
+
+// deprovision suspends a user with no end date and signs them out
+// everywhere. Any timed suspension is cleared so the suspension worker does
+// not lift it later. The suspension counts as SCIM's own only if the user
+// was not already suspended by staff.
+func (h *Handler) deprovision(ctx context.Context, userID string) error {
+	if _, err := h.Pool.Exec(ctx,
+		`UPDATE users SET flags = flags | $1, suspended_until = NULL, suspension_reason = NULL,
+		                  scim_suspended = scim_suspended OR flags & $1 = 0
+		 WHERE id = $2`, models.UserFlagSuspended, userID); err != nil {
+		return err
+	}
+	if err := apiutil.RevokeUserSessions(ctx, h.Pool, h.Cache, h.EventBus, userID, "suspended"); err != nil {
+		return err
+	}
+	h.logAction(ctx, models.StaffActionSCIMUserDeactivate, userID, map[string]bool{"suspended": true})
+	h.Logger.Info("scim: user deprovisioned", slog.String("user_id", userID))
+	return nil
+}
+
+// logAction records a change made through SCIM in the instance audit log,
+// with the SCIM token as the actor.
+func (h *Handler) logAction(ctx context.Context, action, userID string, after interface{}) {
+	tokenID, _ := ctx.Value(contextKeyTokenID).(string)
+	if err := apiutil.LogStaffAction(ctx, h.Pool, apiutil.StaffAction{
+		ActorID:    "scim_token:" + tokenID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   userID,
+		After:      after,
+	}); err != nil {
+		h.Logger.Warn("failed to log staff action",
+			slog.String("action", action), slog.String("error", err.Error()))
+	}
+}
+
+// HandleDeleteUser deprovisions a user. The account is suspended rather than
+// erased so message history and moderation records stay intact.
+// DELETE /scim/v2/Users/{userID}
+func (h *Handler) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if _, err := h.getUser(r.Context(), userID); err != nil {
+		writeSCIMError(w, http.StatusNotFound, "", "User not found")
+		return
+	}
+	if err := h.deprovision(r.Context(), userID); err != nil {
+		h.Logger.Error("scim: deprovisioning user", slog.String("error", err.Error()))
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to deprovision user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Groups ---
+
+func (h *Handler) getGroup(ctx context.Context, groupID string) (scimGroup, error) {
+	var (
+		g          scimGroup
+		externalID *string
+		createdAt  time.Time
+		updatedAt  time.Time
+	)
+	err := h.Pool.QueryRow(ctx,
+		`SELECT id, display_name, external_id, created_at, updated_at FROM scim_groups WHERE id = $1`,
+		groupID).Scan(&g.ID, &g.DisplayName, &externalID, &createdAt, &updatedAt)
+	if err != nil {
+		return g, err
+	}
+	g.Schemas = []string{SchemaGroup}
+	if externalID != nil {
+		g.ExternalID = *externalID
+	}
+	g.Meta = &scimMeta{
+		ResourceType: "Group",
+		Created:      createdAt.UTC().Format(time.RFC3339),
+		LastModified: updatedAt.UTC().Format(time.RFC3339),
+		Location:     "/scim/v2/Groups/" + g.ID,
+	}
+
+	rows, err := h.Pool.Query(ctx,
+		`SELECT u.id, u.username FROM scim_group_members m
+		 JOIN users u ON u.id = m.user_id
+		 WHERE m.group_id = $1 ORDER BY u.username`, groupID)
+	if err != nil {
+		return g, err
+	}
+	defer rows.Close()
+	g.Members = make([]scimMember, 0)
+	for rows.Next() {
+		var m scimMember
+		if err := rows.Scan(&m.Value, &m.Display); err != nil {
+			return g, err
+		}
+		g.Members = append(g.Members, m)
+	}
+	return g, rows.Err()
+}
+
+// HandleListGroups lists SCIM groups, optionally filtered by displayName.
+// GET /scim/v2/Groups
+func (h *Handler) HandleListGroups(w http.ResponseWriter, r *http.Request) {
+	startIndex, count := parsePaging(r)
+
+	where := `TRUE`
+	args := []interface{}{}
+	if f := r.URL.Query().Get("filter"); f != "" {
+		attr, value, ok := parseFilter(f)
+		if !ok {
+			writeSCIMError(w, http.StatusBadRequest, "invalidFilter", "Unsupported filter expression")
+			return
+		}
+		switch attr {
+		case "displayname":
+			where = `display_name = $1`
+		case "externalid":
+			where = `external_id = $1`
+		case "id":
+			where = `id = $1`
+		default:
+			writeSCIMError(w, http.StatusBadRequest, "invalidFilter", "Unsupported filter attribute")
+			return
+		}
+		args = append(args, value)
+	}
+
+	var total int
+	h.Pool.QueryRow(r.Context(), `SELECT COUNT(*) FROM scim_groups WHERE `+where, args...).Scan(&total)
+
+	n := len(args)
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT id FROM scim_groups WHERE `+where+
+			fmt.Sprintf(` ORDER BY created_at LIMIT $%d OFFSET $%d`, n+1, n+2),
+		append(args, count, startIndex-1)...)
+	if err != nil {
+		h.Logger.Error("scim: listing groups", slog.String("error", err.Error()))
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to list groups")
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	resources := make([]scimGroup, 0, len(ids))
+	for _, id := range ids {
+		g, err := h.getGroup(r.Context(), id)
+		if err != nil {
+			continue
+		}
+		resources = append(resources, g)
+	}
+
+	writeSCIM(w, http.StatusOK, scimListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// HandleGetGroup returns a single group with its members.
+// GET /scim/v2/Groups/{groupID}
+func (h *Handler) HandleGetGroup(w http.ResponseWriter, r *http.Request) {
+	g, err := h.getGroup(r.Context(), chi.URLParam(r, "groupID"))
+	if err == pgx.ErrNoRows {
+		writeSCIMError(w, http.StatusNotFound, "", "Group not found")
+		return
+	}
+	if err != nil {
+		h.Logger.Error("scim: getting group", slog.String("error", err.Error()))
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to get group")
+		return
+	}
+	writeSCIM(w, http.StatusOK, g)
+}
+
+// HandleCreateGroup creates a group and adds any listed members.
+// POST /scim/v2/Groups
+func (h *Handler) HandleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req scimGroup
+	if !decodeSCIM(w, r, &req) {
+		return
+	}
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	if req.DisplayName == "" {
+		writeSCIMError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+
+	var externalID *string
+	if req.ExternalID != "" {
+		externalID = &req.ExternalID
+	}
+	id := models.NewULID().String()
+	_, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO scim_groups (id, display_name, external_id) VALUES ($1, $2, $3)`,
+		id, req.DisplayName, externalID)
+	if err != nil {
+		h.Logger.Error("scim: creating group", slog.String("error", err.Error()))
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to create group")
+		return
+	}
+
+	for _, m := range req.Members {
+		if err := h.addGroupMember(r.Context(), id, m.Value); err != nil {
+			h.writeGroupError(w, err)
+			return
+		}
+	}
+
+	g, err := h.getGroup(r.Context(), id)
+	if err != nil {
+		writeSCIMError(w, http.StatusInternalServerError, "", "Failed to load group")
+		return
+	}
+	writeSCIM(w, http.StatusCreated, g)
+}
+
+// HandleReplaceGroup replaces a group's name and full member list.
+// PUT /scim/v2/Groups/{groupID}
+func (h *Handler) HandleReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := chi.URLParam(r, "groupID")
+	var req scimGroup
+	if !decodeSCIM(w, r, &req) {
+		return
+	}
+
+	current, err := h.getGroup(r.Context(), groupID)
+	if err != nil {
+		writeSCIMError(w, http.StatusNotFound, "", "Group not found")
+		return
+	}
+	if name := strings.TrimSpace(req.DisplayName); name != "" {
+		if _, err := h.Pool.Exec(r.Context(),
+			`UPDATE scim_groups SET display_name = $2, updated_at = now() WHERE id = $1`, groupID, name); err != nil {
+			h.writeGroupError(w, err)
+			return
+		}
+	}
+
+	want := make(map[string]bool, len(req.Members))
+	for _, m := range req.Members {
+		want[m.Value] = true
+	}
+	for _, m := range current.Members {
+		if !want[m.Value] {
+			if err := h.removeGroupMember(r.Context(), groupID, m.Value); err != nil {
+				h.writeGroupError(w, err)
+				return
+			}
+		}
+		delete(want, m.Value)
+	}
+	for userID := range want {
+		if err := h.addGroupMember(r.Context(), groupID, userID); err != nil {
+			h.writeGroupError(w, err)
+			return
+		}
+	}
+
+	h.HandleGetGroup(w, r)
+}
+
+// HandlePatchGroup applies member add/remove and displayName operations.
+// PATCH /scim/v2/Groups/{groupID}
+func (h *Handler) HandlePatchGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := chi.URLParam(r, "groupID")
+	var req scimPatchRequest
+	if !decodeSCIM(w, r, &req) {
+		return
+	}
+	if _, err := h.getGroup(r.Context(), groupID); err != nil {
+		writeSCIMError(w, http.StatusNotFound, "", "Group not found")
+		return
+	}
+
+	for _, op := range req.Operations {
+		path := strings.ToLower(op.Path)
+		switch {
+		case strings.EqualFold(op.Op, "remove") && strings.HasPrefix(path, "members"):
+			// Either `members[value eq "id"]` or path "members" with a value list.
+			if _, value, ok := parseFilter(strings.TrimSuffix(strings.TrimPrefix(op.Path[len("members"):], "["), "]")); ok {
+				if err := h.removeGroupMember(r.Context(), groupID, value); err != nil {
+					h.writeGroupError(w, err)
+					return
+				}
+				continue
+			}
+			var members []scimMember
+			json.Unmarshal(op.Value, &members)
+			for _, m := range members {
+				if err := h.removeGroupMember(r.Context(), groupID, m.Value); err != nil {
+					h.writeGroupError(w, err)
+					return
+				}
+			}
+		case (strings.EqualFold(op.Op, "add") || strings.EqualFold(op.Op, "replace")) && path == "members":
+			var members []scimMember
+			if err := json.Unmarshal(op.Value, &members); err != nil {
+				writeSCIMError(w, http.StatusBadRequest, "invalidValue", "members must be a list")
+				return
+			}
+			for _, m := range members {
+				if err := h.addGroupMember(r.Context(), groupID, m.Value); err != nil {
+					h.writeGroupError(w, err)
+					return
+				}
+			}
+		case strings.EqualFold(op.Op, "replace") && (path == "displayname" || path == ""):
+			attrs, err := patchAttributes(op)
+			if err != nil {
+				writeSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+				return
+			}
+			var name string
+			if raw, ok := attrs["displayname"]; ok && json.Unmarshal(raw, &name) == nil && name != "" {
+				if _, err := h.Pool.Exec(r.Context(),
+					`UPDATE scim_groups SET display_name = $2, updated_at = now() WHERE id = $1`, groupID, name); err != nil {
+					h.writeGroupError(w, err)
+					return
+				}
+			}
+		default:
+			writeSCIMError(w, http.StatusBadRequest, "invalidPath", "Unsupported patch operation")
+			return
+		}
+	}
+
+	h.HandleGetGroup(w, r)
+}
+
+// HandleDeleteGroup deletes a group and revokes the roles it granted.
+// DELETE /scim/v2/Groups/{groupID}
+func (h *Handler) HandleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := chi.URLParam(r, "groupID")
+	g, err := h.getGroup(r.Context(), groupID)
+	if err != nil {
+		writeSCIMError(w, http.StatusNotFound, "", "Group not found")
+		return
+	}
+	for _, m := range g.Members {
+		if err := h.removeGroupMember(r.Context(), groupID, m.Value); err != nil {
+			h.writeGroupError(w, err)
+			return
+		}
+	}
+	if _, err := h.Pool.Exec(r.Context(), `DELETE FROM scim_groups WHERE id = $1`, groupID); err != nil {
+		h.writeGroupError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Group → role synchronisation ---
+
+type roleMapping struct {
+	GuildID string
+	RoleID  string
+}
+
+func (h *Handler) groupMappings(ctx context.Context, groupID string) ([]roleMapping, error) {
+	rows, err := h.Pool.Query(ctx,
+		`SELECT guild_id, role_id FROM scim_group_role_mappings WHERE group_id = $1`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []roleMapping
+	for rows.Next() {
+		var m roleMapping
+		if err := rows.Scan(&m.GuildID, &m.RoleID); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// addGroupMember adds a user to a group and grants every mapped guild role.
+// IDs that are not local users are ignored.
+func (h *Handler) addGroupMember(ctx context.Context, groupID, userID string) error {
+	tag, err := h.Pool.Exec(ctx,
+		`INSERT INTO scim_group_members (group_id, user_id)
+		 SELECT $1, id FROM users WHERE id = $2 AND instance_id = $3
+		 ON CONFLICT DO NOTHING`, groupID, userID, h.InstanceID)
+	if err != nil {
+		return fmt.Errorf("adding group member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	mappings, err := h.groupMappings(ctx, groupID)
+	if err != nil {
+		return fmt.Errorf("loading group mappings: %w", err)
+	}
+	return h.grantRoles(ctx, userID, mappings)
+}
+
+// grantRoles grants each mapped role to a user, joining the user to the
+// guild first if necessary. Joins go through the same checks as any other
+// local join, so a banned user or a full guild is skipped.
+func (h *Handler) grantRoles(ctx context.Context, userID string, mappings []roleMapping) error {
+	for _, m := range mappings {
+		var joinedAt time.Time
+		var joined bool
+		err := apiutil.WithTx(ctx, h.Pool, func(tx pgx.Tx) error {
+			var err error
+			joinedAt, joined, err = apiutil.AddGuildMember(ctx, tx, m.GuildID, userID)
+			return err
+		})
+		if errors.Is(err, apiutil.ErrJoinBanned) || errors.Is(err, apiutil.ErrJoinGuildFull) {
+			h.Logger.Warn("scim: skipping role mapping", slog.String("guild_id", m.GuildID), slog.String("error", err.Error()))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("adding guild member: %w", err)
+		}
+		if joined {
+			apiutil.PublishMemberAdd(ctx, h.EventBus, m.GuildID, userID, joinedAt)
+		}
+		if _, err := h.Pool.Exec(ctx,
+			`INSERT INTO member_roles (guild_id, user_id, role_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			m.GuildID, userID, m.RoleID); err != nil {
+			return fmt.Errorf("granting role: %w", err)
+		}
+		h.EventBus.PublishGuildEvent(ctx, events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", m.GuildID,
+			map[string]interface{}{"guild_id": m.GuildID, "user_id": userID, "role_id": m.RoleID, "action": "role_add"})
+	}
+	return nil
+}
+
+// removeGroupMember removes a user from a group and revokes mapped roles that
+// are not still granted through another group the user belongs to.
+func (h *Handler) removeGroupMember(ctx context.Context, groupID, userID string) error {
+	tag, err := h.Pool.Exec(ctx,
+		`DELETE FROM scim_group_members WHERE group_id = $1 AND user_id = $2`, groupID, userID)
+	if err != nil {
+		return fmt.Errorf("removing group member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	mappings, err := h.groupMappings(ctx, groupID)
+	if err != nil {
+		return fmt.Errorf("loading group mappings: %w", err)
+	}
+	for _, m := range mappings {
+		var stillGranted bool
+		if err := h.Pool.QueryRow(ctx,
+			`SELECT EXISTS(
+				SELECT 1 FROM scim_group_role_mappings rm
+				JOIN scim_group_members gm ON gm.group_id = rm.group_id
+				WHERE rm.role_id = $1 AND gm.user_id = $2
+			)`, m.RoleID, userID).Scan(&stillGranted); err != nil {
+			return fmt.Errorf("checking other grants: %w", err)
+		}
+		if stillGranted {
+			continue
+		}
+		if _, err := h.Pool.Exec(ctx,
+			`DELETE FROM member_roles WHERE guild_id = $1 AND user_id = $2 AND role_id = $3`,
+			m.GuildID, userID, m.RoleID); err != nil {
+			return fmt.Errorf("revoking role: %w", err)
+		}
+		h.EventBus.PublishGuildEvent(ctx, events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", m.GuildID,
+			map[string]interface{}{"guild_id": m.GuildID, "user_id": userID, "role_id": m.RoleID, "action": "role_remove"})
+	}
+	return nil
+}
+
+// writeGroupError logs a failed group update and writes a SCIM error.
+func (h *Handler) writeGroupError(w http.ResponseWriter, err error) {
+	h.Logger.Error("scim: updating group", slog.String("error", err.Error()))
+	writeSCIMError(w, http.StatusInternalServerError, "", "Failed to update group")
+}
+
+// --- Admin management ---
+
+// logAdminAction records a SCIM configuration change made by an instance
+// admin in the instance audit log.
+func (h *Handler) logAdminAction(r *http.Request, action, targetType, targetID string, before, after interface{}) {
+	if err := apiutil.LogStaffAction(r.Context(), h.Pool, apiutil.StaffAction{
+		ActorID:    auth.UserIDFromContext(r.Context()),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+	}); err != nil {
+		h.Logger.Warn("failed to log staff action",
+			slog.String("action", action), slog.String("error", err.Error()))
+	}
+}
+
+// HandleListTokens lists SCIM tokens (without secrets).
+// GET /api/v1/admin/scim/tokens
+func (h *Handler) HandleListTokens(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT id, name, created_by, created_at, last_used_at FROM scim_tokens ORDER BY created_at DESC`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list SCIM tokens", err)
+		return
+	}
+	defer rows.Close()
+
+	type tokenEntry struct {
+		ID         string     `json:"id"`
+		Name       string     `json:"name"`
+		CreatedBy  *string    `json:"created_by"`
+		CreatedAt  time.Time  `json:"created_at"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+	}
+	tokens := make([]tokenEntry, 0)
+	for rows.Next() {
+		var t tokenEntry
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedBy, &t.CreatedAt, &t.LastUsedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read SCIM tokens", err)
+			return
+		}
+		tokens = append(tokens, t)
+	}
+	apiutil.WriteJSON(w, http.StatusOK, tokens)
+}
+
+// HandleCreateToken issues a new SCIM token. The raw token is only returned
+// in this response.
+// POST /api/v1/admin/scim/tokens
+func (h *Handler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if !apiutil.ValidateStringLength(w, "name", req.Name, 1, 100) {
+		return
+	}
+
+	raw, hash, err := generateToken()
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to generate token", err)
+		return
+	}
+	id := models.NewULID().String()
+	adminID := auth.UserIDFromContext(r.Context())
+	_, err = h.Pool.Exec(r.Context(),
+		`INSERT INTO scim_tokens (id, name, token_hash, created_by) VALUES ($1, $2, $3, $4)`,
+		id, req.Name, hash, adminID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create SCIM token", err)
+		return
+	}
+	h.logAdminAction(r, models.StaffActionSCIMTokenCreate, "scim_token", id, nil, map[string]string{"name": req.Name})
+
+	apiutil.WriteJSON(w, http.StatusCreated, map[string]string{
+		"id":    id,
+		"name":  req.Name,
+		"token": raw,
+	})
+}
+
+// HandleDeleteToken revokes a SCIM token.
+// DELETE /api/v1/admin/scim/tokens/{tokenID}
+func (h *Handler) HandleDeleteToken(w http.ResponseWriter, r *http.Request) {
+	tokenID := chi.URLParam(r, "tokenID")
+	tag, err := h.Pool.Exec(r.Context(), `DELETE FROM scim_tokens WHERE id = $1`, tokenID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete SCIM token", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteCode(w, apierrors.NotFound, "SCIM token not found")
+		return
+	}
+	h.logAdminAction(r, models.StaffActionSCIMTokenDelete, "scim_token", tokenID, nil, nil)
+	apiutil.WriteNoContent(w)
+}
+
+// HandleListGroupMappings lists group → guild role mappings.
+// GET /api/v1/admin/scim/mappings
+func (h *Handler) HandleListGroupMappings(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT m.group_id, g.display_name, m.guild_id, m.role_id, m.created_at
+		 FROM scim_group_role_mappings m
+		 JOIN scim_groups g ON g.id = m.group_id
+		 ORDER BY g.display_name`)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list SCIM mappings", err)
+		return
+	}
+	defer rows.Close()
+
+	type mappingEntry struct {
+		GroupID   string    `json:"group_id"`
+		GroupName string    `json:"group_name"`
+		GuildID   string    `json:"guild_id"`
+		RoleID    string    `json:"role_id"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	mappings := make([]mappingEntry, 0)
+	for rows.Next() {
+		var m mappingEntry
+		if err := rows.Scan(&m.GroupID, &m.GroupName, &m.GuildID, &m.RoleID, &m.CreatedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read SCIM mappings", err)
+			return
+		}
+		mappings = append(mappings, m)
+	}
+	apiutil.WriteJSON(w, http.StatusOK, mappings)
+}
+
+// HandleCreateGroupMapping maps a SCIM group onto a guild role and grants the
+// role to the group's current members.
+// POST /api/v1/admin/scim/mappings
+func (h *Handler) HandleCreateGroupMapping(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		GroupID string `json:"group_id"`
+		RoleID  string `json:"role_id"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if !apiutil.RequireNonEmpty(w, "group_id", req.GroupID) || !apiutil.RequireNonEmpty(w, "role_id", req.RoleID) {
+		return
+	}
+
+	var guildID string
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT guild_id FROM roles WHERE id = $1`, req.RoleID).Scan(&guildID); err != nil {
+		apiutil.WriteCode(w, apierrors.RoleNotFound, "")
+		return
+	}
+	var groupExists bool
+	h.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM scim_groups WHERE id = $1)`, req.GroupID).Scan(&groupExists)
+	if !groupExists {
+		apiutil.WriteError(w, http.StatusNotFound, "group_not_found", "SCIM group not found")
+		return
+	}
+
+	_, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO scim_group_role_mappings (group_id, guild_id, role_id) VALUES ($1, $2, $3)
+		 ON CONFLICT DO NOTHING`, req.GroupID, guildID, req.RoleID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create SCIM mapping", err)
+		return
+	}
+
+	// Grant the role to the group's existing members.
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT user_id FROM scim_group_members WHERE group_id = $1`, req.GroupID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load SCIM group members", err)
+		return
+	}
+	var members []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			members = append(members, id)
+		}
+	}
+	rows.Close()
+	mapping := []roleMapping{{GuildID: guildID, RoleID: req.RoleID}}
+	for _, id := range members {
+		if err := h.grantRoles(r.Context(), id, mapping); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to grant mapped role", err)
+			return
+		}
+	}
+
+	created := map[string]string{
+		"group_id": req.GroupID,
+		"guild_id": guildID,
+		"role_id":  req.RoleID,
+	}
+	h.logAdminAction(r, models.StaffActionSCIMMappingCreate, "scim_group", req.GroupID, nil, created)
+
+	apiutil.WriteJSON(w, http.StatusCreated, created)
+}
+
+// HandleDeleteGroupMapping removes a group → role mapping. Roles already
+// granted are left in place.
+// DELETE /api/v1/admin/scim/mappings/{groupID}/{roleID}
+func (h *Handler) HandleDeleteGroupMapping(w http.ResponseWriter, r *http.Request) {
+	groupID, roleID := chi.URLParam(r, "groupID"), chi.URLParam(r, "roleID")
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM scim_group_role_mappings WHERE group_id = $1 AND role_id = $2`, groupID, roleID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete SCIM mapping", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteCode(w, apierrors.NotFound, "SCIM mapping not found")
+		return
+	}
+	h.logAdminAction(r, models.StaffActionSCIMMappingDelete, "scim_group", groupID, map[string]string{"role_id": roleID}, nil)
+	apiutil.WriteNoContent(w)
+}
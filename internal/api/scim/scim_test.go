@@ -0,0 +1,101 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		filter    string
+		wantAttr  string
+		wantValue string
+		wantOK    bool
+	}{
+		{`userName eq "alice"`, "username", "alice", true},
+		{`externalId EQ "00u1abc"`, "externalid", "00u1abc", true},
+		{`displayName eq "Engineering Team"`, "displayname", "Engineering Team", true},
+		{`userName co "ali"`, "", "", false},
+		{`userName eq alice`, "", "", false},
+		{`userName`, "", "", false},
+		{``, "", "", false},
+	}
+	for _, tt := range tests {
+		attr, value, ok := parseFilter(tt.filter)
+		if ok != tt.wantOK || attr != tt.wantAttr || value != tt.wantValue {
+			t.Errorf("parseFilter(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.filter, attr, value, ok, tt.wantAttr, tt.wantValue, tt.wantOK)
+		}
+	}
+}
+
+func TestParsePaging(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantStart int
+		wantCount int
+	}{
+		{"", 1, 100},
+		{"?startIndex=11&count=10", 11, 10},
+		{"?startIndex=0&count=-1", 1, 100},
+		{"?count=5000", 1, maxPageSize},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/scim/v2/Users"+tt.query, nil)
+		start, count := parsePaging(r)
+		if start != tt.wantStart || count != tt.wantCount {
+			t.Errorf("parsePaging(%q) = (%d, %d), want (%d, %d)", tt.query, start, count, tt.wantStart, tt.wantCount)
+		}
+	}
+}
+
+func TestPatchAttributes(t *testing.T) {
+	attrs, err := patchAttributes(scimPatchOperation{Op: "Replace", Path: "active", Value: json.RawMessage(`false`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(attrs["active"]) != "false" {
+		t.Errorf("active = %s, want false", attrs["active"])
+	}
+
+	// Azure AD sends path-less replace operations with an attribute object.
+	attrs, err = patchAttributes(scimPatchOperation{Op: "replace", Value: json.RawMessage(`{"active":false,"displayName":"Alice"}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := attrs["displayname"]; !ok {
+		t.Error("expected lower-cased displayname attribute")
+	}
+
+	if _, err := patchAttributes(scimPatchOperation{Op: "remove", Path: "active"}); err == nil {
+		t.Error("expected error for remove operation")
+	}
+}
+
+func TestPrimaryEmail(t *testing.T) {
+	emails := []scimEmail{
+		{Value: "alt@example.com"},
+		{Value: "alice@example.com", Primary: true},
+	}
+	if got := primaryEmail(emails); got == nil || *got != "alice@example.com" {
+		t.Errorf("primaryEmail = %v, want alice@example.com", got)
+	}
+	if got := primaryEmail([]scimEmail{{Value: "only@example.com"}}); got == nil || *got != "only@example.com" {
+		t.Errorf("primaryEmail fallback = %v, want only@example.com", got)
+	}
+	if got := primaryEmail(nil); got != nil {
+		t.Errorf("primaryEmail(nil) = %v, want nil", *got)
+	}
+}
+
+func TestDisplayNameFor(t *testing.T) {
+	u := scimUser{Name: &scimName{GivenName: "Alice", FamilyName: "Smith"}}
+	if got := displayNameFor(u); got == nil || *got != "Alice Smith" {
+		t.Errorf("displayNameFor = %v, want Alice Smith", got)
+	}
+	u.DisplayName = "Ally"
+	if got := displayNameFor(u); got == nil || *got != "Ally" {
+		t.Errorf("displayNameFor = %v, want Ally", got)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
@@ -16,8 +19,29 @@ import (
 // validIDPattern matches ULID/alphanumeric IDs to prevent filter injection.
 var validIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{26}$`)
 
+// parseSearchOperators strips search operators from a query, returning the
+// remaining text and whether has:file was given.
+func parseSearchOperators(q string) (string, bool) {
+	var terms []string
+	hasFile := false
+	for _, term := range strings.Fields(q) {
+		if strings.EqualFold(term, "has:file") {
+			hasFile = true
+			continue
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " "), hasFile
+}
+
 // handleSearchMessages handles GET /api/v1/search/messages.
 // Query params: q (required), channel_id, guild_id, author_id, limit, offset.
+// Attachments whose filename, alt text or recognised text match are returned
+// as their parent messages, after the messages matching by content. The
+// has:file operator in q limits results to messages with attachments. With
+// safe search on, messages from NSFW channels are left out. Bots without
+// message content access only find their own guild messages and those that
+// mention them.
 func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
 	if s.Search == nil {
 		WriteError(w, http.StatusServiceUnavailable, "search_disabled", "Search is not enabled on this instance")
@@ -31,20 +55,27 @@ func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
 
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
 	limit, offset := parsePagination(r)
+	text, hasFile := parseSearchOperators(query)
 
-	// Build filter string for Meilisearch with input validation.
-	var filters []string
+	// Build filter string for Meilisearch with input validation. Attachments
+	// record the author as uploader_id.
+	// A query in one channel is tokenized for the channel's language.
+	var filters, fileFilters []string
+	var lang string
 	if channelID := r.URL.Query().Get("channel_id"); channelID != "" {
 		if !validIDPattern.MatchString(channelID) {
 			WriteError(w, http.StatusBadRequest, "invalid_channel_id", "Invalid channel_id format")
 			return
 		}
 		filters = append(filters, fmt.Sprintf("channel_id = %q", channelID))
+		fileFilters = append(fileFilters, fmt.Sprintf("channel_id = %q", channelID))
+		s.DB.Pool.QueryRow(r.Context(),
+			`SELECT COALESCE(language, '') FROM channels WHERE id = $1`, channelID).Scan(&lang)
 	}
 	if guildID := r.URL.Query().Get("guild_id"); guildID != "" {
 		if !validIDPattern.MatchString(guildID) {
@@ -52,6 +83,7 @@ func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		filters = append(filters, fmt.Sprintf("guild_id = %q", guildID))
+		fileFilters = append(fileFilters, fmt.Sprintf("guild_id = %q", guildID))
 	}
 	if authorID := r.URL.Query().Get("author_id"); authorID != "" {
 		if !validIDPattern.MatchString(authorID) {
@@ -59,22 +91,19 @@ func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		filters = append(filters, fmt.Sprintf("author_id = %q", authorID))
+		fileFilters = append(fileFilters, fmt.Sprintf("uploader_id = %q", authorID))
 	}
-
-	filterStr := ""
-	for i, f := range filters {
-		if i > 0 {
-			filterStr += " AND "
-		}
-		filterStr += f
+	if hasFile {
+		filters = append(filters, "has_file = true")
 	}
 
 	result, err := s.Search.Search(r.Context(), search.SearchRequest{
-		Query:   query,
-		Index:   search.IndexMessages,
-		Filters: filterStr,
-		Limit:   limit,
-		Offset:  offset,
+		Query:    text,
+		Index:    search.IndexMessages,
+		Filters:  strings.Join(filters, " AND "),
+		Limit:    limit,
+		Offset:   offset,
+		Language: lang,
 	})
 	if err != nil {
 		s.Logger.Error("search messages failed", "error", err.Error())
@@ -82,6 +111,18 @@ func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fileMatches, err := s.Search.SearchAttachments(r.Context(), search.SearchRequest{
+		Query:   text,
+		Filters: strings.Join(fileFilters, " AND "),
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		// Content matches are still worth returning.
+		s.Logger.Warn("search attachments failed", "error", err.Error())
+	}
+	result.IDs = mergeSearchIDs(result.IDs, fileMatches, int(limit))
+
 	if len(result.IDs) == 0 {
 		WriteJSON(w, http.StatusOK, []models.Message{})
 		return
@@ -125,7 +166,9 @@ func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// --- Access control: filter out messages from channels the user cannot see ---
-	messages = s.filterAuthorizedMessages(r.Context(), userID, messages)
+	safeSearch := apiutil.ContentSettings(r.Context(), s.DB.Pool, userID).SafeSearch
+	messages = s.filterAuthorizedMessages(r.Context(), userID, messages, safeSearch)
+	messages = s.filterBotHiddenMessages(r.Context(), userID, messages)
 
 	// Enrich with authors, attachments, and embeds.
 	s.enrichSearchMessagesWithAuthors(r.Context(), messages)
@@ -135,6 +178,71 @@ func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, messages)
 }
 
+// filterBotHiddenMessages drops guild messages whose content the user may not
+// read because it is a bot without approved message content access, keeping
+// its own messages and those that mention it. They are dropped rather than
+// redacted, since matching a query already reveals what they say.
+func (s *Server) filterBotHiddenMessages(ctx context.Context, userID string, messages []models.Message) []models.Message {
+	if len(messages) == 0 {
+		return messages
+	}
+	var hidden bool
+	s.DB.Pool.QueryRow(ctx,
+		`SELECT u.flags & $2 != 0
+		        AND NOT EXISTS(SELECT 1 FROM bot_message_content_access a
+		                       WHERE a.bot_id = u.id AND a.status = 'approved')
+		 FROM users u WHERE u.id = $1`,
+		userID, models.UserFlagBot).Scan(&hidden)
+	if !hidden {
+		return messages
+	}
+
+	channelIDs := make([]string, 0, len(messages))
+	for _, m := range messages {
+		channelIDs = append(channelIDs, m.ChannelID)
+	}
+	guildChannels := make(map[string]bool)
+	rows, err := s.DB.Pool.Query(ctx,
+		`SELECT id FROM channels WHERE id = ANY($1) AND guild_id IS NOT NULL`, channelIDs)
+	if err != nil {
+		return []models.Message{}
+	}
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			guildChannels[id] = true
+		}
+	}
+	rows.Close()
+
+	visible := messages[:0]
+	for _, m := range messages {
+		if !guildChannels[m.ChannelID] || m.AuthorID == userID || slices.Contains(m.MentionUserIDs, userID) {
+			visible = append(visible, m)
+		}
+	}
+	return visible
+}
+
+// mergeSearchIDs appends the IDs in extra that are not already in ids, up
+// to limit in total.
+func mergeSearchIDs(ids, extra []string, limit int) []string {
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, id := range extra {
+		if len(ids) >= limit {
+			break
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 // handleSearchUsers handles GET /api/v1/search/users.
 // Query params: q (required), limit, offset.
 func (s *Server) handleSearchUsers(w http.ResponseWriter, r *http.Request) {
@@ -204,7 +312,8 @@ func (s *Server) handleSearchUsers(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleSearchGuilds handles GET /api/v1/search/guilds.
-// Query params: q (required), limit, offset.
+// Query params: q (required), limit, offset. With safe search on, NSFW
+// guilds are left out.
 func (s *Server) handleSearchGuilds(w http.ResponseWriter, r *http.Request) {
 	if s.Search == nil {
 		WriteError(w, http.StatusServiceUnavailable, "search_disabled", "Search is not enabled on this instance")
@@ -239,7 +348,7 @@ func (s *Server) handleSearchGuilds(w http.ResponseWriter, r *http.Request) {
 		`SELECT id, instance_id, owner_id, name, description, icon_id, banner_id,
 		        default_permissions, flags, nsfw, discoverable,
 		        system_channel_join, system_channel_leave, system_channel_kick, system_channel_ban,
-		        preferred_locale, max_members, vanity_url, verification_level,
+		        preferred_locale, timezone, max_members, vanity_url, verification_level,
 		        afk_channel_id, afk_timeout, tags, member_count, created_at
 		 FROM guilds WHERE id = ANY($1)`, result.IDs)
 	if err != nil {
@@ -256,7 +365,7 @@ func (s *Server) handleSearchGuilds(w http.ResponseWriter, r *http.Request) {
 			&g.ID, &g.InstanceID, &g.OwnerID, &g.Name, &g.Description, &g.IconID, &g.BannerID,
 			&g.DefaultPermissions, &g.Flags, &g.NSFW, &g.Discoverable,
 			&g.SystemChannelJoin, &g.SystemChannelLeave, &g.SystemChannelKick, &g.SystemChannelBan,
-			&g.PreferredLocale, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel,
+			&g.PreferredLocale, &g.Timezone, &g.MaxMembers, &g.VanityURL, &g.VerificationLevel,
 			&g.AFKChannelID, &g.AFKTimeout, &g.Tags, &g.MemberCount, &g.CreatedAt,
 		); err != nil {
 			continue
@@ -265,9 +374,10 @@ func (s *Server) handleSearchGuilds(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Preserve Meilisearch relevance ordering.
+	safeSearch := apiutil.ContentSettings(r.Context(), s.DB.Pool, auth.UserIDFromContext(r.Context())).SafeSearch
 	guilds := make([]models.Guild, 0, len(result.IDs))
 	for _, id := range result.IDs {
-		if g, ok := guildMap[id]; ok {
+		if g, ok := guildMap[id]; ok && !(safeSearch && g.NSFW) {
 			guilds = append(guilds, g)
 		}
 	}
@@ -278,7 +388,8 @@ func (s *Server) handleSearchGuilds(w http.ResponseWriter, r *http.Request) {
 // filterAuthorizedMessages removes messages from channels the requesting user
 // does not have access to. For guild channels, the user must be a member of the
 // guild. For DM channels (guild_id IS NULL), the user must be a channel recipient.
-func (s *Server) filterAuthorizedMessages(ctx context.Context, userID string, messages []models.Message) []models.Message {
+// With hideNSFW, messages from NSFW channels are removed as well.
+func (s *Server) filterAuthorizedMessages(ctx context.Context, userID string, messages []models.Message, hideNSFW bool) []models.Message {
 	if len(messages) == 0 {
 		return messages
 	}
@@ -299,7 +410,7 @@ func (s *Server) filterAuthorizedMessages(ctx context.Context, userID string, me
 	}
 	channelMap := make(map[string]channelInfo, len(channelIDs))
 	rows, err := s.DB.Pool.Query(ctx,
-		`SELECT id, guild_id FROM channels WHERE id = ANY($1)`, channelIDs)
+		`SELECT id, guild_id, nsfw FROM channels WHERE id = ANY($1)`, channelIDs)
 	if err != nil {
 		s.Logger.Error("search access control: channel lookup failed", "error", err.Error())
 		return nil // fail closed
@@ -308,9 +419,13 @@ func (s *Server) filterAuthorizedMessages(ctx context.Context, userID string, me
 	for rows.Next() {
 		var cID string
 		var gID *string
-		if err := rows.Scan(&cID, &gID); err != nil {
+		var nsfw bool
+		if err := rows.Scan(&cID, &gID, &nsfw); err != nil {
 			continue
 		}
+		if hideNSFW && nsfw {
+			continue // left out of channelMap, so filtered below
+		}
 		channelMap[cID] = channelInfo{guildID: gID}
 	}
 	rows.Close()
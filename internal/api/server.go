@@ -6,6 +6,7 @@ package api
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -18,12 +19,14 @@ import (
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/api/activities"
 	"github.com/amityvox/amityvox/internal/api/admin"
 	"github.com/amityvox/amityvox/internal/api/bookmarks"
 	"github.com/amityvox/amityvox/internal/api/bots"
 	"github.com/amityvox/amityvox/internal/api/channels"
+	"github.com/amityvox/amityvox/internal/api/communities"
 	"github.com/amityvox/amityvox/internal/api/experimental"
 	"github.com/amityvox/amityvox/internal/api/guildevents"
 	"github.com/amityvox/amityvox/internal/api/guilds"
@@ -32,6 +35,7 @@ import (
 	"github.com/amityvox/amityvox/internal/api/moderation"
 	"github.com/amityvox/amityvox/internal/api/onboarding"
 	"github.com/amityvox/amityvox/internal/api/polls"
+	"github.com/amityvox/amityvox/internal/api/scim"
 	"github.com/amityvox/amityvox/internal/api/social"
 	"github.com/amityvox/amityvox/internal/api/stickers"
 	"github.com/amityvox/amityvox/internal/api/themes"
@@ -39,18 +43,23 @@ import (
 	"github.com/amityvox/amityvox/internal/api/webhooks"
 	"github.com/amityvox/amityvox/internal/api/widgets"
 	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/callback"
 	"github.com/amityvox/amityvox/internal/automod"
 	"github.com/amityvox/amityvox/internal/config"
 	"github.com/amityvox/amityvox/internal/database"
 	"github.com/amityvox/amityvox/internal/encryption"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/featureflags"
 	"github.com/amityvox/amityvox/internal/federation"
+	"github.com/amityvox/amityvox/internal/gateway"
 	"github.com/amityvox/amityvox/internal/media"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/notifications"
+	"github.com/amityvox/amityvox/internal/permissions"
 	"github.com/amityvox/amityvox/internal/presence"
 	"github.com/amityvox/amityvox/internal/search"
 	"github.com/amityvox/amityvox/internal/voice"
+	"github.com/amityvox/amityvox/internal/workers"
 )
 
 // Server is the HTTP API server for AmityVox. It holds the chi router, database
@@ -70,11 +79,23 @@ type Server struct {
 	Notifications *notifications.Service
 	WebAuthn      *webauthn.WebAuthn
 	InstanceID string
+	SigningKey  ed25519.PrivateKey       // optional, signs webhook and interaction callbacks
 	Version     string
 	Logger      *slog.Logger
 	FedSvc      *federation.Service       // exposed for admin federation handlers
 	FedProxy    apiutil.FederationProxy  // optional, set after sync service creation
 	UserHandler *users.Handler           // exposed for federation wiring
+	GatewayShards func() []gateway.ShardStats // optional, gateway dispatch shard metrics
+	GatewayConnections func() gateway.ConnectionStats // optional, gateway session metrics
+	GatewayTraffic     func() []gateway.GuildTraffic  // optional, per-guild gateway load
+	GatewayLatency     func() gateway.LatencyStats    // optional, heartbeat round trips
+	Jobs        *workers.Manager         // optional, background job admin endpoints
+	FeatureFlags *featureflags.Store     // optional, staged feature rollouts; nil has every flag off
+	subsystems  *subsystemGate
+	fedGuildInflight inflightLimiter // federation proxy requests in flight per remote guild
+	usage       *usageRecorder
+	usageStop   chan struct{}
+	openAPI     openAPIDoc
 	server      *http.Server
 }
 
@@ -93,6 +114,8 @@ func NewServer(db *database.DB, cfg *config.Config, authSvc *auth.Service, bus *
 		InstanceID:  instanceID,
 		Logger:      logger,
 	}
+	s.subsystems = &subsystemGate{pool: db.Pool, logger: logger}
+	s.usage = newUsageRecorder()
 
 	// Initialize WebAuthn if configured.
 	if cfg.Auth.WebAuthn.RPID != "" && len(cfg.Auth.WebAuthn.RPOrigins) > 0 {
@@ -132,13 +155,81 @@ func RequireAdmin(pool *pgxpool.Pool) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userID := auth.UserIDFromContext(r.Context())
 			if userID == "" {
-				WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+				WriteCode(w, apierrors.Unauthorized, "")
 				return
 			}
 			var flags int
 			err := pool.QueryRow(r.Context(), `SELECT flags FROM users WHERE id = $1`, userID).Scan(&flags)
 			if err != nil || flags&models.UserFlagAdmin == 0 {
-				WriteError(w, http.StatusForbidden, "forbidden", "Admin access required")
+				WriteCode(w, apierrors.Forbidden, "Admin access required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireStaff returns middleware that admits instance admins and any user
+// holding at least one instance staff permission through an instance role.
+// Handlers behind it check the specific permission they need.
+func RequireStaff(pool *pgxpool.Pool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := auth.UserIDFromContext(r.Context())
+			if userID == "" {
+				WriteCode(w, apierrors.Unauthorized, "")
+				return
+			}
+			perms, err := apiutil.InstancePermissions(r.Context(), pool, userID)
+			if err != nil || perms == 0 {
+				WriteCode(w, apierrors.Forbidden, "Admin access required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePolicyAcknowledgment returns middleware that blocks users who have
+// not acknowledged the current instance terms and rules. Lookup errors let
+// the request through rather than locking every user out.
+func RequirePolicyAcknowledgment(pool *pgxpool.Pool, cache *presence.Cache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if policyExempt(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			pending, err := apiutil.PendingPolicies(r.Context(), pool, cache, auth.UserIDFromContext(r.Context()))
+			if err == nil && len(pending) > 0 {
+				WriteError(w, http.StatusForbidden, "policy_acknowledgment_required",
+					"You must acknowledge the current instance terms and rules")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// policyExempt reports whether a path stays reachable before the user has
+// acknowledged the instance policies: their own account, so they can still
+// export or delete it, and the policy endpoints themselves.
+func policyExempt(path string) bool {
+	return path == "/api/v1/users/@me" ||
+		strings.HasPrefix(path, "/api/v1/users/@me/policies") ||
+		strings.HasPrefix(path, "/api/v1/users/@me/export")
+}
+
+// RequireInstancePermission returns middleware that requires a specific
+// instance staff permission. Used for routes served by handlers outside the
+// admin package that do not check instance permissions themselves.
+func RequireInstancePermission(pool *pgxpool.Pool, perm uint64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := auth.UserIDFromContext(r.Context())
+			if !apiutil.HasInstancePermission(r.Context(), pool, userID, perm) {
+				WriteError(w, http.StatusForbidden, "missing_instance_permission",
+					"Missing instance permission: "+strings.Join(permissions.InstanceNames(perm), ", "))
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -149,13 +240,15 @@ func RequireAdmin(pool *pgxpool.Pool) func(http.Handler) http.Handler {
 // registerMiddleware adds global middleware to the router.
 func (s *Server) registerMiddleware() {
 	s.Router.Use(middleware.RequestID)
-	s.Router.Use(middleware.RealIP)
+	s.Router.Use(s.trustedRealIP())
 	s.Router.Use(slogMiddleware(s.Logger))
 	s.Router.Use(middleware.Recoverer)
 	s.Router.Use(corsMiddleware(s.Config.HTTP.CORSOrigins))
+	s.Router.Use(clientVersionHeaders(s.Config.Clients))
 	s.Router.Use(middleware.Compress(5))
 	s.Router.Use(middleware.Timeout(30 * time.Second))
 	s.Router.Use(maxBodySize(1 << 20)) // 1MB default body limit
+	s.Router.Use(s.federationToggle())
 	// Rate limiting is applied per-route group in registerRoutes so that
 	// authenticated routes run AFTER auth.RequireAuth, allowing the middleware
 	// to key on userID (6000 req/min) instead of falling back to IP (1200 req/min).
@@ -170,6 +263,8 @@ func (s *Server) registerRoutes() {
 		InstanceID:     s.InstanceID,
 		InstanceDomain: s.Config.Instance.Domain,
 		Logger:         s.Logger,
+		FeatureFlags:   s.FeatureFlags,
+		Cache:          s.Cache,
 	}
 	s.UserHandler = userH
 	guildH := &guilds.Handler{
@@ -178,12 +273,28 @@ func (s *Server) registerRoutes() {
 		InstanceID: s.InstanceID,
 		Logger:     s.Logger,
 		FedProxy:   s.FedProxy,
+		AutoMod:    s.AutoMod,
+		Cache:      s.Cache,
 	}
 	channelH := &channels.Handler{
 		Pool:     s.DB.Pool,
 		EventBus: s.EventBus,
 		Logger:   s.Logger,
 		FedProxy: s.FedProxy,
+		Cache:    s.Cache,
+	}
+	if s.Voice != nil {
+		channelH.Voice = s.Voice
+	}
+	if s.Config.Email.Enabled {
+		channelH.EmailDomain = s.Config.Email.Domain
+	}
+	channelH.NormalizeEmoji = s.Config.Messages.NormalizeEmojiShortcodes
+	communityH := &communities.Handler{
+		Pool:       s.DB.Pool,
+		EventBus:   s.EventBus,
+		InstanceID: s.InstanceID,
+		Logger:     s.Logger,
 	}
 	inviteH := &invites.Handler{
 		Pool:       s.DB.Pool,
@@ -200,11 +311,16 @@ func (s *Server) registerRoutes() {
 		EventBus:   s.EventBus,
 		Cache:      s.Cache,
 		FedSvc:     s.FedSvc,
+
+		MinClientVersion: s.Config.Clients.MinVersion,
+		SigningKey:       callback.PublicKey(s.SigningKey),
 	}
 	webhookH := &webhooks.Handler{
-		Pool:     s.DB.Pool,
-		EventBus: s.EventBus,
-		Logger:   s.Logger,
+		Pool:       s.DB.Pool,
+		EventBus:   s.EventBus,
+		Logger:     s.Logger,
+		SigningKey: s.SigningKey,
+		Cache:      s.Cache,
 	}
 	pollH := &polls.Handler{
 		Pool:     s.DB.Pool,
@@ -240,6 +356,7 @@ func (s *Server) registerRoutes() {
 		AuthService: s.AuthService,
 		EventBus:    s.EventBus,
 		Logger:      s.Logger,
+		SigningKey:  s.SigningKey,
 	}
 	themeH := &themes.Handler{
 		Pool:     s.DB.Pool,
@@ -276,28 +393,62 @@ widgetH := &widgets.Handler{
 		EventBus: s.EventBus,
 		Logger:   s.Logger,
 	}
+	scimH := &scim.Handler{
+		Pool:       s.DB.Pool,
+		EventBus:   s.EventBus,
+		Cache:      s.Cache,
+		InstanceID: s.InstanceID,
+		Logger:     s.Logger,
+	}
 
 	// Health check — outside versioned API prefix, no rate limit (used by Docker healthcheck).
 	s.Router.Get("/health", s.handleHealthCheck)
 	s.Router.Get("/health/deep", s.handleDeepHealthCheck)
 
+	// Interactive API reference for the document at /api/v1/openapi.json.
+	s.Router.Get("/api/docs", s.handleAPIDocs)
+	s.Router.Get("/api/docs/init.js", s.handleAPIDocsInit)
+
 	// Prometheus metrics endpoint.
 	s.Router.With(s.RateLimitGlobal()).Get("/metrics", s.handleMetrics)
 
+	// SCIM 2.0 provisioning — authenticated with admin-issued SCIM tokens.
+	s.Router.Route("/scim/v2", func(r chi.Router) {
+		r.Use(s.RateLimitGlobal())
+		r.Use(scimH.RequireToken)
+		r.Get("/ServiceProviderConfig", scimH.HandleServiceProviderConfig)
+		r.Get("/Users", scimH.HandleListUsers)
+		r.Post("/Users", scimH.HandleCreateUser)
+		r.Get("/Users/{userID}", scimH.HandleGetUser)
+		r.Put("/Users/{userID}", scimH.HandleReplaceUser)
+		r.Patch("/Users/{userID}", scimH.HandlePatchUser)
+		r.Delete("/Users/{userID}", scimH.HandleDeleteUser)
+		r.Get("/Groups", scimH.HandleListGroups)
+		r.Post("/Groups", scimH.HandleCreateGroup)
+		r.Get("/Groups/{groupID}", scimH.HandleGetGroup)
+		r.Put("/Groups/{groupID}", scimH.HandleReplaceGroup)
+		r.Patch("/Groups/{groupID}", scimH.HandlePatchGroup)
+		r.Delete("/Groups/{groupID}", scimH.HandleDeleteGroup)
+	})
+
 	// API v1 routes.
 	s.Router.Route("/api/v1", func(r chi.Router) {
+		r.Use(s.readOnlyMaintenance())
+
 		// Auth routes.
 		r.Route("/auth", func(r chi.Router) {
 			// Public auth endpoints (login/register) — IP-based rate limiting.
 			r.Group(func(r chi.Router) {
 				r.Use(s.RateLimitGlobal())
-				r.Post("/register", s.handleRegister)
+				r.With(s.requireSubsystem(models.SubsystemRegistration)).Post("/register", s.handleRegister)
 				r.Post("/login", s.handleLogin)
+				r.Post("/suspension-appeal", s.handleSuspensionAppeal)
 			})
 
 			// Authenticated auth-management endpoints — user-based rate limiting.
 			r.Group(func(r chi.Router) {
 				r.Use(auth.RequireAuth(s.AuthService))
+				r.Use(s.trackAPIUsage())
 				r.Use(s.RateLimitGlobal())
 				r.Post("/logout", s.handleLogout)
 				r.Post("/password", s.handleChangePassword)
@@ -314,10 +465,26 @@ widgetH := &widgets.Handler{
 			})
 		})
 
+		// Instance description and branding, for clients before login.
+		r.With(s.RateLimitGlobal()).Get("/instance", adminH.HandleGetPublicInstance)
+		r.With(s.RateLimitGlobal()).Get("/instance/status", adminH.HandleGetInstanceStatus)
+		r.With(s.RateLimitGlobal()).Get("/openapi.json", s.handleOpenAPISpec)
+		r.With(s.RateLimitGlobal()).Get("/errors", s.handleListErrorCodes)
+
+		// Interaction callbacks — the only routes user-app tokens may call.
+		r.Group(func(r chi.Router) {
+			r.Use(auth.RequireAppAuth(s.AuthService))
+			r.Use(s.trackAPIUsage())
+			r.Use(s.RateLimitGlobal())
+			r.Post("/interactions/{interactionID}/callback", botH.HandleInteractionCallback)
+		})
+
 		// Authenticated routes — require Bearer token.
 		r.Group(func(r chi.Router) {
 			r.Use(auth.RequireAuth(s.AuthService))
+			r.Use(s.trackAPIUsage())   // Counts requests before rate limiting, so 429s show in usage.
 			r.Use(s.RateLimitGlobal()) // Runs after auth: keys on userID (6000 req/min).
+			r.Use(RequirePolicyAcknowledgment(s.DB.Pool, s.Cache))
 
 			// User routes.
 			r.Route("/users", func(r chi.Router) {
@@ -328,20 +495,39 @@ widgetH := &widgets.Handler{
 				r.Get("/@me/dms", userH.HandleGetSelfDMs)
 				r.Get("/@me/relationships", userH.HandleGetRelationships)
 				r.Get("/@me/read-state", userH.HandleGetSelfReadState)
+				r.Post("/@me/ack", userH.HandleAckAll)
 				r.Get("/@me/sessions", userH.HandleGetSelfSessions)
 				r.Delete("/@me/sessions/{sessionID}", userH.HandleDeleteSelfSession)
 				r.Get("/@me/settings", userH.HandleGetUserSettings)
 				r.Patch("/@me/settings", userH.HandleUpdateUserSettings)
+				r.Get("/@me/content-settings", userH.HandleGetContentSettings)
+				r.Patch("/@me/content-settings", userH.HandleUpdateContentSettings)
+				r.Get("/@me/feature-flags", userH.HandleGetFeatureFlags)
+				r.Get("/@me/profile-privacy", userH.HandleGetProfilePrivacy)
+				r.Patch("/@me/profile-privacy", userH.HandleUpdateProfilePrivacy)
 				r.Get("/@me/relationships", userH.HandleGetRelationships)
 				r.Get("/@me/blocked", userH.HandleGetBlockedUsers)
 				r.Get("/@me/bookmarks", bookmarkH.HandleListBookmarks)
+				r.Get("/@me/mentions", s.handleGetMentions)
+				r.Delete("/@me/mentions/{messageID}", s.handleDismissMention)
+				r.Get("/@me/usage", s.handleGetMyAPIUsage)
 				r.Get("/@me/bots", botH.HandleListMyBots)
 				r.Post("/@me/bots", botH.HandleCreateBot)
+				r.Get("/@me/apps", botH.HandleListUserApps)
+				r.Put("/@me/apps/{botID}", botH.HandleInstallUserApp)
+				r.Delete("/@me/apps/{botID}", botH.HandleUninstallUserApp)
+				r.Get("/@me/bot-presence", botH.HandleListBotPresenceRequests)
+				r.Put("/@me/bot-presence/{botID}", botH.HandleGrantBotPresence)
+				r.Delete("/@me/bot-presence/{botID}", botH.HandleRevokeBotPresence)
 				r.Get("/@me/export", userH.HandleExportUserData)
 				r.Get("/@me/export-account", userH.HandleExportAccount)
 				r.Post("/@me/import-account", userH.HandleImportAccount)
 				r.Put("/@me/activity", userH.HandleUpdateActivity)
 				r.Get("/@me/activity", userH.HandleGetActivity)
+				r.Get("/@me/presence-visibility", userH.HandleGetPresenceVisibility)
+				r.Put("/@me/presence-visibility", userH.HandleSetPresenceVisibility)
+				r.Get("/@me/policies", userH.HandleGetSelfPolicies)
+				r.Post("/@me/policies/acknowledge", userH.HandleAcknowledgePolicies)
 				r.Get("/@me/hidden-threads", channelH.HandleGetHiddenThreads)
 				r.Get("/@me/emoji", userH.HandleGetUserEmoji)
 				r.Post("/@me/emoji", userH.HandleCreateUserEmoji)
@@ -357,18 +543,32 @@ widgetH := &widgets.Handler{
 				r.Get("/@me/issues", modH.HandleGetMyIssues)
 
 				// Group DMs.
-				r.Post("/@me/group-dms", userH.HandleCreateGroupDM)
+				r.With(s.RateLimitDMCreate).Post("/@me/group-dms", userH.HandleCreateGroupDM)
+				r.Get("/@me/group-dm-invites", userH.HandleListGroupDMInvites)
+				r.Post("/@me/group-dm-invites/{inviteID}/accept", userH.HandleAcceptGroupDMInvite)
+				r.Delete("/@me/group-dm-invites/{inviteID}", userH.HandleDeclineGroupDMInvite)
 
 				// User guild positions (drag reordering).
 				r.Put("/@me/guild-positions", userH.HandleUpdateGuildPositions)
 
+				// Guild layout: folders and favorite channels, synced across devices.
+				r.Get("/@me/guild-layout", userH.HandleGetGuildLayout)
+				r.Post("/@me/guild-folders", userH.HandleCreateGuildFolder)
+				r.Patch("/@me/guild-folders/{folderID}", userH.HandleUpdateGuildFolder)
+				r.Put("/@me/guild-folders/{folderID}/guilds", userH.HandleSetGuildFolderGuilds)
+				r.Delete("/@me/guild-folders/{folderID}", userH.HandleDeleteGuildFolder)
+				r.Put("/@me/favorites", userH.HandleSetFavorites)
+				r.Put("/@me/favorites/{channelID}", userH.HandleAddFavorite)
+				r.Delete("/@me/favorites/{channelID}", userH.HandleRemoveFavorite)
+
 				// Handle resolution must be before /{userID} to avoid conflicts.
 				r.Get("/resolve", userH.HandleResolveHandle)
+				r.Get("/autocomplete", userH.HandleAutocompleteUsers)
 
 				r.Get("/{userID}", userH.HandleGetUser)
 				r.Get("/{userID}/note", userH.HandleGetUserNote)
 				r.Put("/{userID}/note", userH.HandleSetUserNote)
-				r.Post("/{userID}/dm", userH.HandleCreateDM)
+				r.With(s.RateLimitDMCreate).Post("/{userID}/dm", userH.HandleCreateDM)
 				r.Put("/{userID}/friend", userH.HandleAddFriend)
 				r.Delete("/{userID}/friend", userH.HandleRemoveFriend)
 				r.Put("/{userID}/block", userH.HandleBlockUser)
@@ -382,10 +582,21 @@ widgetH := &widgets.Handler{
 			})
 
 			// Bot management routes.
+			r.Get("/bots/directory", botH.HandleBrowseDirectory)
 			r.Route("/bots/{botID}", func(r chi.Router) {
 				r.Get("/", botH.HandleGetBot)
 				r.Patch("/", botH.HandleUpdateBot)
 				r.Delete("/", botH.HandleDeleteBot)
+				r.Get("/listing", botH.HandleGetListing)
+				r.Put("/listing", botH.HandleUpdateListing)
+				r.Delete("/listing", botH.HandleDeleteListing)
+				r.Get("/message-content", botH.HandleGetMessageContentAccess)
+				r.Put("/message-content", botH.HandleRequestMessageContentAccess)
+				r.Delete("/message-content", botH.HandleDeleteMessageContentAccess)
+				r.Get("/presence-subscriptions", botH.HandleListPresenceSubscriptions)
+				r.Put("/presence-subscriptions/{userID}", botH.HandleAddPresenceSubscription)
+				r.Delete("/presence-subscriptions/{userID}", botH.HandleRemovePresenceSubscription)
+				r.Get("/usage", s.handleGetBotAPIUsage)
 				r.Route("/tokens", func(r chi.Router) {
 					r.Get("/", botH.HandleListTokens)
 					r.Post("/", botH.HandleCreateToken)
@@ -403,6 +614,9 @@ widgetH := &widgets.Handler{
 				r.Put("/presence", botH.HandleUpdateBotPresence)
 				r.Get("/rate-limit", botH.HandleGetBotRateLimit)
 				r.Put("/rate-limit", botH.HandleUpdateBotRateLimit)
+				r.Get("/interactions-endpoint", botH.HandleGetInteractionEndpoint)
+				r.Put("/interactions-endpoint", botH.HandleSetInteractionEndpoint)
+				r.Delete("/interactions-endpoint", botH.HandleDeleteInteractionEndpoint)
 				r.Route("/subscriptions", func(r chi.Router) {
 					r.Post("/", botH.HandleCreateEventSubscription)
 					r.Get("/", botH.HandleListEventSubscriptions)
@@ -415,6 +629,7 @@ widgetH := &widgets.Handler{
 			r.Route("/guilds", func(r chi.Router) {
 				r.Post("/", guildH.HandleCreateGuild)
 				r.Get("/discover", guildH.HandleDiscoverGuilds)
+				r.Get("/discover/autocomplete", guildH.HandleAutocompleteDiscoverGuilds)
 				r.Get("/vanity/{code}", guildH.HandleResolveVanityURL)
 				r.Get("/{guildID}/preview", guildH.HandleGetGuildPreview)
 				r.Post("/{guildID}/join", guildH.HandleJoinDiscoverableGuild)
@@ -422,11 +637,16 @@ widgetH := &widgets.Handler{
 				r.Patch("/{guildID}", guildH.HandleUpdateGuild)
 				r.Delete("/{guildID}", guildH.HandleDeleteGuild)
 				r.Post("/{guildID}/leave", guildH.HandleLeaveGuild)
+				r.Post("/{guildID}/ack", guildH.HandleAckGuild)
 				r.Post("/{guildID}/transfer", guildH.HandleTransferGuildOwnership)
 				r.Get("/{guildID}/channels", guildH.HandleGetGuildChannels)
 				r.Patch("/{guildID}/channels", guildH.HandleReorderGuildChannels)
 				r.Post("/{guildID}/channels", guildH.HandleCreateGuildChannel)
 				r.Post("/{guildID}/channels/{channelID}/clone", guildH.HandleCloneChannel)
+				r.Post("/{guildID}/channels/{channelID}/move", guildH.HandleMoveChannel)
+				r.Get("/{guildID}/archive", guildH.HandleGetArchivedChannels)
+				r.Post("/{guildID}/archive/{channelID}/restore", guildH.HandleRestoreArchivedChannel)
+				r.Get("/{guildID}/channels/requestable", channelH.HandleGetRequestableChannels)
 				r.Get("/{guildID}/guide", guildH.HandleGetServerGuide)
 				r.Put("/{guildID}/guide", guildH.HandleUpdateServerGuide)
 				r.Get("/{guildID}/bump", guildH.HandleGetBumpStatus)
@@ -441,12 +661,15 @@ widgetH := &widgets.Handler{
 				r.Get("/{guildID}/members/@me/permissions", guildH.HandleGetMyPermissions)
 			r.Get("/{guildID}/members", guildH.HandleGetGuildMembers)
 				r.Get("/{guildID}/members/search", guildH.HandleSearchGuildMembers)
+				r.Get("/{guildID}/members/autocomplete", guildH.HandleAutocompleteGuildMembers)
+				r.With(s.RateLimitBulkRoles).Post("/{guildID}/members/nicknames/reset", guildH.HandleResetNicknames)
 				r.Get("/{guildID}/members/{memberID}", guildH.HandleGetGuildMember)
 				r.Patch("/{guildID}/members/{memberID}", guildH.HandleUpdateGuildMember)
 				r.Delete("/{guildID}/members/{memberID}", guildH.HandleRemoveGuildMember)
 				r.Post("/{guildID}/members/{memberID}/warn", modH.HandleWarnMember)
 				r.Get("/{guildID}/members/{memberID}/warnings", modH.HandleGetWarnings)
 				r.Get("/{guildID}/members/{memberID}/roles", guildH.HandleGetMemberRoles)
+				r.Get("/{guildID}/members/{memberID}/permissions", guildH.HandleAuditMemberPermissions)
 				r.Put("/{guildID}/members/{memberID}/roles/{roleID}", guildH.HandleAddMemberRole)
 				r.Delete("/{guildID}/members/{memberID}/roles/{roleID}", guildH.HandleRemoveMemberRole)
 				r.Get("/{guildID}/prune", guildH.HandleGetGuildPruneCount)
@@ -459,27 +682,82 @@ widgetH := &widgets.Handler{
 				r.Post("/{guildID}/roles", guildH.HandleCreateGuildRole)
 				r.Patch("/{guildID}/roles/{roleID}", guildH.HandleUpdateGuildRole)
 				r.Delete("/{guildID}/roles/{roleID}", guildH.HandleDeleteGuildRole)
+				r.Get("/{guildID}/roles/{roleID}/members", guildH.HandleGetRoleMembers)
+				r.With(s.RateLimitBulkRoles).Post("/{guildID}/roles/{roleID}/members/bulk", guildH.HandleBulkUpdateRoleMembers)
 				r.Get("/{guildID}/invites", guildH.HandleGetGuildInvites)
 				r.Post("/{guildID}/invites", guildH.HandleCreateGuildInvite)
+				r.Get("/{guildID}/invites/pause", guildH.HandleGetInvitePause)
+				r.Put("/{guildID}/invites/pause", guildH.HandlePauseInvites)
+				r.Delete("/{guildID}/invites/pause", guildH.HandleResumeInvites)
 				r.Get("/{guildID}/categories", guildH.HandleGetGuildCategories)
 				r.Post("/{guildID}/categories", guildH.HandleCreateGuildCategory)
 				r.Patch("/{guildID}/categories/{categoryID}", guildH.HandleUpdateGuildCategory)
 				r.Delete("/{guildID}/categories/{categoryID}", guildH.HandleDeleteGuildCategory)
+				r.Post("/{guildID}/categories/{categoryID}/move", guildH.HandleMoveCategory)
 				r.Get("/{guildID}/audit-log", guildH.HandleGetGuildAuditLog)
 				r.Get("/{guildID}/emoji", guildH.HandleGetGuildEmoji)
 				r.Post("/{guildID}/emoji", guildH.HandleCreateGuildEmoji)
+				r.Get("/{guildID}/emoji/quota", guildH.HandleGetEmojiQuota)
+				r.Post("/{guildID}/emoji/{emojiID}/approve", guildH.HandleApproveGuildEmoji)
 				r.Patch("/{guildID}/emoji/{emojiID}", guildH.HandleUpdateGuildEmoji)
 				r.Delete("/{guildID}/emoji/{emojiID}", guildH.HandleDeleteGuildEmoji)
+				r.Get("/{guildID}/tags", guildH.HandleGetTags)
+				r.Post("/{guildID}/tags", guildH.HandleCreateTag)
+				r.Patch("/{guildID}/tags/{tagID}", guildH.HandleUpdateTag)
+				r.Delete("/{guildID}/tags/{tagID}", guildH.HandleDeleteTag)
+				r.Get("/{guildID}/imports", guildH.HandleGetGuildImports)
+				r.Post("/{guildID}/imports", guildH.HandleCreateGuildImport)
+				r.Get("/{guildID}/imports/{importID}", guildH.HandleGetGuildImport)
+				r.Delete("/{guildID}/imports/{importID}", guildH.HandleCancelGuildImport)
 				r.Get("/{guildID}/webhooks", guildH.HandleGetGuildWebhooks)
 				r.Post("/{guildID}/webhooks", guildH.HandleCreateGuildWebhook)
 				r.Patch("/{guildID}/webhooks/{webhookID}", guildH.HandleUpdateGuildWebhook)
 				r.Delete("/{guildID}/webhooks/{webhookID}", guildH.HandleDeleteGuildWebhook)
 				r.Get("/{guildID}/webhooks/{webhookID}/logs", webhookH.HandleGetWebhookLogs)
+				r.Put("/{guildID}/webhooks/{webhookID}/outgoing", webhookH.HandleSetOutgoing)
+				r.Post("/{guildID}/webhooks/{webhookID}/outgoing/verify", webhookH.HandleVerifyOutgoing)
+				r.Post("/{guildID}/webhooks/{webhookID}/outgoing/test", webhookH.HandleTestOutgoing)
+				r.Get("/{guildID}/webhook-policy", guildH.HandleGetWebhookPolicy)
+				r.Patch("/{guildID}/webhook-policy", guildH.HandleUpdateWebhookPolicy)
+				r.Get("/{guildID}/edit-policy", guildH.HandleGetEditPolicy)
+				r.Patch("/{guildID}/edit-policy", guildH.HandleUpdateEditPolicy)
+				r.Get("/{guildID}/join-origin-policy", guildH.HandleGetJoinOriginPolicy)
+				r.Patch("/{guildID}/join-origin-policy", guildH.HandleUpdateJoinOriginPolicy)
+				r.Get("/{guildID}/permission-presets", guildH.HandleGetPermissionPresets)
+				r.Post("/{guildID}/permission-presets", guildH.HandleCreatePermissionPreset)
+				r.Delete("/{guildID}/permission-presets/{presetID}", guildH.HandleDeletePermissionPreset)
+				r.Get("/{guildID}/timeout-role", guildH.HandleGetTimeoutRole)
+				r.Put("/{guildID}/timeout-role", guildH.HandleEnableTimeoutRole)
+				r.Delete("/{guildID}/timeout-role", guildH.HandleDisableTimeoutRole)
+				r.Get("/{guildID}/message-sources", guildH.HandleGetMessageSources)
+				r.Get("/{guildID}/emoji-stats", guildH.HandleGetEmojiStats)
+				r.Get("/{guildID}/message-restore", guildH.HandleGetMessageRestore)
+				r.Patch("/{guildID}/message-restore", guildH.HandleUpdateMessageRestore)
+				r.Get("/{guildID}/broadcasts", guildH.HandleGetGuildBroadcasts)
+				r.Post("/{guildID}/broadcasts", guildH.HandleCreateGuildBroadcast)
+				r.Delete("/{guildID}/broadcasts/{broadcastID}", guildH.HandleCancelGuildBroadcast)
+				r.Get("/{guildID}/broadcasts/subscription", guildH.HandleGetBroadcastSubscription)
+				r.Put("/{guildID}/broadcasts/subscription", guildH.HandleSetBroadcastSubscription)
+				r.Get("/{guildID}/scheduled-purges", guildH.HandleGetScheduledPurges)
+				r.Post("/{guildID}/scheduled-purges", guildH.HandleCreateScheduledPurge)
+				r.Delete("/{guildID}/scheduled-purges/{purgeID}", guildH.HandleDeleteScheduledPurge)
+				r.Get("/{guildID}/emoji-aliases", guildH.HandleGetEmojiAliases)
+				r.Put("/{guildID}/emoji-aliases/{alias}", guildH.HandleSetEmojiAlias)
+				r.Delete("/{guildID}/emoji-aliases/{alias}", guildH.HandleDeleteEmojiAlias)
+				r.Get("/{guildID}/community", communityH.HandleGetGuildCommunity)
+				r.Post("/{guildID}/community/accept", communityH.HandleAcceptCommunityInvite)
+				r.Delete("/{guildID}/community", communityH.HandleLeaveCommunity)
+				r.Get("/{guildID}/apps", guildH.HandleGetGuildApps)
+				r.Post("/{guildID}/apps", guildH.HandleInstallApp)
+				r.Delete("/{guildID}/apps/{botID}", guildH.HandleUninstallApp)
+				r.Get("/{guildID}/apps/{botID}/permissions", guildH.HandleAuditAppPermissions)
 				r.Get("/{guildID}/vanity-url", guildH.HandleGetGuildVanityURL)
 				r.Patch("/{guildID}/vanity-url", guildH.HandleSetGuildVanityURL)
 				r.Delete("/{guildID}/warnings/{warningID}", modH.HandleDeleteWarning)
 				r.Get("/{guildID}/reports", modH.HandleGetReports)
 				r.Patch("/{guildID}/reports/{reportID}", modH.HandleResolveReport)
+				r.Get("/{guildID}/link-flags", modH.HandleGetLinkFlags)
+				r.Patch("/{guildID}/link-flags/{flagID}", modH.HandleResolveLinkFlag)
 				r.Get("/{guildID}/raid-config", modH.HandleGetRaidConfig)
 				r.Patch("/{guildID}/raid-config", modH.HandleUpdateRaidConfig)
 
@@ -517,6 +795,8 @@ widgetH := &widgets.Handler{
 					r.Delete("/prompts/{promptID}", onboardH.HandleDeletePrompt)
 					r.Post("/complete", onboardH.HandleCompleteOnboarding)
 					r.Get("/status", onboardH.HandleGetOnboardingStatus)
+					r.Post("/rules/accept", onboardH.HandleAcceptRules)
+					r.Get("/rules/acknowledgments", onboardH.HandleGetRuleAcknowledgments)
 				})
 
 				// Guild event routes.
@@ -575,13 +855,20 @@ widgetH := &widgets.Handler{
 				r.Patch("/{channelID}", channelH.HandleUpdateChannel)
 				r.Delete("/{channelID}", channelH.HandleDeleteChannel)
 				r.Get("/{channelID}/messages", channelH.HandleGetMessages)
-				r.With(s.RateLimitMessages).Post("/{channelID}/messages", channelH.HandleCreateMessage)
+				r.Get("/{channelID}/commands", botH.HandleListChannelCommands)
+				r.Post("/{channelID}/interactions", botH.HandleCreateInteraction)
+				r.With(s.requireSubsystem(models.SubsystemMessaging), s.RateLimitMessages).Post("/{channelID}/commands/tag", channelH.HandleInvokeTag)
+				r.With(s.requireSubsystem(models.SubsystemMessaging), s.RateLimitMessages).Post("/{channelID}/messages", channelH.HandleCreateMessage)
 				r.Post("/{channelID}/messages/bulk-delete", channelH.HandleBulkDeleteMessages)
+				r.With(s.requireSubsystem(models.SubsystemMessaging), s.RateLimitMessages).Post("/{channelID}/messages/import", channelH.HandleImportMessages)
+				r.Get("/{channelID}/messages/nonce/{nonce}", channelH.HandleGetMessageByNonce)
+				r.Get("/{channelID}/messages/deleted", channelH.HandleGetDeletedMessages)
+				r.Post("/{channelID}/messages/{messageID}/restore", channelH.HandleRestoreMessage)
 				r.Get("/{channelID}/messages/{messageID}", channelH.HandleGetMessage)
 				r.Patch("/{channelID}/messages/{messageID}", channelH.HandleUpdateMessage)
 				r.Delete("/{channelID}/messages/{messageID}", channelH.HandleDeleteMessage)
 				r.Get("/{channelID}/messages/{messageID}/edits", channelH.HandleGetMessageEdits)
-				r.Post("/{channelID}/messages/{messageID}/crosspost", channelH.HandleCrosspostMessage)
+				r.With(s.requireSubsystem(models.SubsystemMessaging)).Post("/{channelID}/messages/{messageID}/crosspost", channelH.HandleCrosspostMessage)
 				r.Get("/{channelID}/messages/{messageID}/reactions", channelH.HandleGetReactions)
 				r.Put("/{channelID}/messages/{messageID}/reactions/{emoji}", channelH.HandleAddReaction)
 				r.Delete("/{channelID}/messages/{messageID}/reactions/{emoji}", channelH.HandleRemoveReaction)
@@ -592,21 +879,50 @@ widgetH := &widgets.Handler{
 				r.Post("/{channelID}/typing", channelH.HandleTriggerTyping)
 				r.Post("/{channelID}/decrypt-messages", channelH.HandleBatchDecryptMessages)
 				r.Post("/{channelID}/ack", channelH.HandleAckChannel)
+				r.Get("/{channelID}/summary", channelH.HandleGetActivitySummary)
+				r.Patch("/{channelID}/permissions", channelH.HandleReplaceChannelPermissions)
 				r.Put("/{channelID}/permissions/{overrideID}", channelH.HandleSetChannelPermission)
 				r.Delete("/{channelID}/permissions/{overrideID}", channelH.HandleDeleteChannelPermission)
+				r.Put("/{channelID}/join-settings", channelH.HandleSetJoinSettings)
+				r.Delete("/{channelID}/join-settings", channelH.HandleDeleteJoinSettings)
+				r.Get("/{channelID}/join-requests", channelH.HandleGetJoinRequests)
+				r.Post("/{channelID}/join-requests", channelH.HandleCreateJoinRequest)
+				r.Post("/{channelID}/join-requests/{requestID}/approve", channelH.HandleApproveJoinRequest)
+				r.Post("/{channelID}/join-requests/{requestID}/deny", channelH.HandleDenyJoinRequest)
 				r.Post("/{channelID}/messages/{messageID}/threads", channelH.HandleCreateThread)
 				r.Post("/{channelID}/messages/{messageID}/report", modH.HandleReportMessage)
 				r.Post("/{channelID}/messages/{messageID}/report-admin", modH.HandleReportToAdmin)
 				r.Post("/{channelID}/messages/{messageID}/translate", channelH.HandleTranslateMessage)
+				r.Get("/{channelID}/auto-translate", channelH.HandleGetAutoTranslate)
+				r.Put("/{channelID}/auto-translate", channelH.HandleSetAutoTranslate)
+				r.Delete("/{channelID}/auto-translate", channelH.HandleDeleteAutoTranslate)
 			r.Get("/{channelID}/threads", channelH.HandleGetThreads)
 				r.Post("/{channelID}/threads/{threadID}/hide", channelH.HandleHideThread)
 				r.Delete("/{channelID}/threads/{threadID}/hide", channelH.HandleUnhideThread)
 				r.Post("/{channelID}/lock", modH.HandleLockChannel)
 				r.Post("/{channelID}/unlock", modH.HandleUnlockChannel)
 			r.Get("/{channelID}/webhooks", channelH.HandleGetChannelWebhooks)
+			r.Get("/{channelID}/webhook-content", channelH.HandleGetWebhookContent)
+			r.Patch("/{channelID}/webhook-content", channelH.HandleUpdateWebhookContent)
+			r.Get("/{channelID}/slowmode/adaptive", channelH.HandleGetAdaptiveSlowmode)
+			r.Patch("/{channelID}/slowmode/adaptive", channelH.HandleUpdateAdaptiveSlowmode)
+			r.Get("/{channelID}/source-limits", channelH.HandleGetSourceRateLimits)
+			r.Put("/{channelID}/source-limits/{source}", channelH.HandleSetSourceRateLimit)
+			r.Delete("/{channelID}/source-limits/{source}", channelH.HandleDeleteSourceRateLimit)
+			r.Get("/{channelID}/duplicate-policy", channelH.HandleGetDuplicatePolicy)
+			r.Patch("/{channelID}/duplicate-policy", channelH.HandleUpdateDuplicatePolicy)
 				r.Get("/{channelID}/export", userH.HandleExportChannelMessages)
+				r.Get("/{channelID}/transcripts", userH.HandleGetTranscriptLinks)
+				r.Post("/{channelID}/transcripts", userH.HandleCreateTranscriptLink)
+				r.Delete("/{channelID}/transcripts/{linkID}", userH.HandleRevokeTranscriptLink)
+				r.Get("/{channelID}/transcripts/{linkID}/accesses", userH.HandleGetTranscriptAccesses)
 				r.Get("/{channelID}/gallery", channelH.HandleGetChannelGallery)
 
+				// Inbound email address (email gateway).
+				r.Get("/{channelID}/email", channelH.HandleGetChannelEmail)
+				r.Put("/{channelID}/email", channelH.HandleSetChannelEmail)
+				r.Delete("/{channelID}/email", channelH.HandleDeleteChannelEmail)
+
 				// Forum tag routes.
 				r.Get("/{channelID}/tags", channelH.HandleGetForumTags)
 				r.Post("/{channelID}/tags", channelH.HandleCreateForumTag)
@@ -615,7 +931,7 @@ widgetH := &widgets.Handler{
 
 				// Forum post routes.
 				r.Get("/{channelID}/posts", channelH.HandleGetForumPosts)
-				r.Post("/{channelID}/posts", channelH.HandleCreateForumPost)
+				r.With(s.requireSubsystem(models.SubsystemMessaging)).Post("/{channelID}/posts", channelH.HandleCreateForumPost)
 				r.Post("/{channelID}/posts/{postID}/pin", channelH.HandlePinForumPost)
 				r.Post("/{channelID}/posts/{postID}/close", channelH.HandleCloseForumPost)
 
@@ -682,10 +998,16 @@ widgetH := &widgets.Handler{
 				r.Post("/{channelID}/members/{userID}/deafen", s.handleVoiceServerDeafen)
 				r.Post("/{channelID}/members/{userID}/move", s.handleVoiceMoveUser)
 
+				// DM calls.
+				r.Post("/{channelID}/call/decline", s.handleDeclineCall)
+				r.Get("/{channelID}/calls", s.handleGetCallLog)
+
 				// Voice preferences (PTT/VAD).
 				r.Get("/preferences", s.handleGetVoicePreferences)
 				r.Patch("/preferences", s.handleUpdateVoicePreferences)
 				r.Post("/{channelID}/input-mode", s.handleSetInputMode)
+				r.Get("/{channelID}/input-mode/config", s.handleGetChannelInputMode)
+				r.Patch("/{channelID}/input-mode/config", s.handleUpdateChannelInputMode)
 				r.Post("/{channelID}/priority-speaker", s.handleSetPrioritySpeaker)
 
 				// Soundboard.
@@ -706,6 +1028,8 @@ widgetH := &widgets.Handler{
 				r.Delete("/{channelID}/screen-share", s.handleStopScreenShare)
 				r.Patch("/{channelID}/screen-share", s.handleUpdateScreenShare)
 				r.Get("/{channelID}/screen-shares", s.handleGetScreenShares)
+				r.Get("/{channelID}/screen-share/config", s.handleGetScreenShareConfig)
+				r.Patch("/{channelID}/screen-share/config", s.handleUpdateScreenShareConfig)
 			})
 
 			// Issue reporting (any authenticated user).
@@ -720,6 +1044,11 @@ widgetH := &widgets.Handler{
 				r.Patch("/message-reports/{reportID}", modH.HandleResolveMessageReport)
 				r.Get("/issues", modH.HandleGetIssues)
 				r.Patch("/issues/{issueID}", modH.HandleResolveIssue)
+				r.Get("/quarantines", modH.HandleListQuarantines)
+				r.Get("/quarantines/{userID}/messages", modH.HandleGetQuarantinedMessages)
+				r.Post("/quarantines/{userID}/release", modH.HandleReleaseQuarantine)
+				r.Post("/quarantines/{userID}/confirm", modH.HandleConfirmQuarantine)
+				r.Post("/users/{userID}/quarantine", modH.HandleQuarantineUser)
 			})
 
 			// Public ban lists.
@@ -856,6 +1185,8 @@ widgetH := &widgets.Handler{
 				r.Post("/", socialH.HandleCreateBoost)
 				r.Delete("/", socialH.HandleRemoveBoost)
 			})
+			r.Get("/users/@me/boost-slots", socialH.HandleGetBoostSlots)
+			r.Get("/users/@me/boost-slots/kofi-code", socialH.HandleGetKofiLinkCode)
 			r.Post("/guilds/{guildID}/vanity-claim", socialH.HandleClaimVanityURL)
 			r.Delete("/guilds/{guildID}/vanity-claim", socialH.HandleReleaseVanityURL)
 			r.Get("/guilds/{guildID}/vanity-check", socialH.HandleCheckVanityAvailability)
@@ -875,6 +1206,12 @@ widgetH := &widgets.Handler{
 				r.Patch("/", socialH.HandleUpdateStarboardConfig)
 				r.Get("/entries", socialH.HandleGetStarboardEntries)
 			})
+			r.Route("/guilds/{guildID}/pinboards", func(r chi.Router) {
+				r.Get("/", socialH.HandleGetPinboards)
+				r.Post("/", socialH.HandleCreatePinboard)
+				r.Patch("/{boardID}", socialH.HandleUpdatePinboard)
+				r.Delete("/{boardID}", socialH.HandleDeletePinboard)
+			})
 			r.Route("/guilds/{guildID}/welcome", func(r chi.Router) {
 				r.Get("/", socialH.HandleGetWelcomeConfig)
 				r.Patch("/", socialH.HandleUpdateWelcomeConfig)
@@ -905,6 +1242,28 @@ widgetH := &widgets.Handler{
 				r.Delete("/{connectionID}", integrationH.HandleDeleteBridgeConnection)
 			})
 
+			// Community routes.
+			r.Route("/communities", func(r chi.Router) {
+				r.Get("/", communityH.HandleListOwnedCommunities)
+				r.Post("/", communityH.HandleCreateCommunity)
+				r.Get("/discover", communityH.HandleDiscoverCommunities)
+				r.Get("/{communityID}", communityH.HandleGetCommunity)
+				r.Patch("/{communityID}", communityH.HandleUpdateCommunity)
+				r.Delete("/{communityID}", communityH.HandleDeleteCommunity)
+				r.Post("/{communityID}/guilds", communityH.HandleAddCommunityGuild)
+				r.Delete("/{communityID}/guilds/{guildID}", communityH.HandleRemoveCommunityGuild)
+				r.Get("/{communityID}/roles", communityH.HandleListCommunityRoles)
+				r.Post("/{communityID}/roles", communityH.HandleCreateCommunityRole)
+				r.Patch("/{communityID}/roles/{roleID}", communityH.HandleUpdateCommunityRole)
+				r.Delete("/{communityID}/roles/{roleID}", communityH.HandleDeleteCommunityRole)
+				r.Get("/{communityID}/roles/{roleID}/members", communityH.HandleListCommunityRoleMembers)
+				r.Put("/{communityID}/roles/{roleID}/members/{userID}", communityH.HandleGrantCommunityRole)
+				r.Delete("/{communityID}/roles/{roleID}/members/{userID}", communityH.HandleRevokeCommunityRole)
+				r.Get("/{communityID}/bans", communityH.HandleListCommunityBans)
+				r.Put("/{communityID}/bans/{userID}", communityH.HandleCreateCommunityBan)
+				r.Delete("/{communityID}/bans/{userID}", communityH.HandleRemoveCommunityBan)
+			})
+
 			// Invite routes.
 			r.Route("/invites", func(r chi.Router) {
 				r.Get("/{code}", inviteH.HandleGetInvite)
@@ -914,7 +1273,7 @@ widgetH := &widgets.Handler{
 
 			// File upload and media management.
 			if s.Media != nil {
-				r.Post("/files/upload", s.Media.HandleUpload)
+				r.With(s.requireSubsystem(models.SubsystemUploads)).Post("/files/upload", s.Media.HandleUpload)
 				r.Route("/files/{fileID}", func(r chi.Router) {
 					r.Patch("/", s.Media.HandleUpdateAttachment)
 					r.Delete("/", s.Media.HandleDeleteAttachment)
@@ -1002,9 +1361,22 @@ widgetH := &widgets.Handler{
 			// Instance announcements (visible to all logged-in users).
 			r.Get("/announcements", adminH.HandleGetAnnouncements)
 
-			// Admin routes — protected by RequireAdmin middleware.
+			// Instance emoji, usable in every guild and DM.
+			r.Get("/emoji", adminH.HandleListInstanceEmoji)
+
+			// Admin routes — open to instance staff; each handler checks the
+			// admin flag or the instance permission it needs.
 			r.Route("/admin", func(r chi.Router) {
-				r.Use(RequireAdmin(s.DB.Pool))
+				r.Use(RequireStaff(s.DB.Pool))
+				r.Get("/permissions/@me", adminH.HandleGetMyInstancePermissions)
+				r.Get("/instance-roles", adminH.HandleListInstanceRoles)
+				r.Post("/instance-roles", adminH.HandleCreateInstanceRole)
+				r.Patch("/instance-roles/{roleID}", adminH.HandleUpdateInstanceRole)
+				r.Delete("/instance-roles/{roleID}", adminH.HandleDeleteInstanceRole)
+				r.Get("/users/{userID}/instance-roles", adminH.HandleGetUserInstanceRoles)
+				r.Put("/users/{userID}/instance-roles/{roleID}", adminH.HandleAssignInstanceRole)
+				r.Delete("/users/{userID}/instance-roles/{roleID}", adminH.HandleUnassignInstanceRole)
+				r.Get("/audit-log", adminH.HandleGetInstanceAuditLog)
 				r.Get("/instance", adminH.HandleGetInstance)
 				r.Patch("/instance", adminH.HandleUpdateInstance)
 				r.Get("/federation/peers", adminH.HandleGetFederationPeers)
@@ -1019,10 +1391,65 @@ widgetH := &widgets.Handler{
 				r.Post("/users/{userID}/instance-ban", adminH.HandleInstanceBanUser)
 				r.Post("/users/{userID}/instance-unban", adminH.HandleInstanceUnbanUser)
 				r.Get("/instance-bans", adminH.HandleGetInstanceBans)
+				r.Get("/suspension-appeals", adminH.HandleListSuspensionAppeals)
+				r.Patch("/suspension-appeals/{appealID}", adminH.HandleResolveSuspensionAppeal)
 				r.Get("/guilds", adminH.HandleListGuilds)
 				r.Get("/guilds/{guildID}", adminH.HandleGetGuildDetails)
 				r.Delete("/guilds/{guildID}", adminH.HandleAdminDeleteGuild)
+				r.Put("/guilds/{guildID}/emoji-quota", adminH.HandleSetGuildEmojiQuota)
+				r.Get("/emoji-tiers", adminH.HandleListEmojiTiers)
+				r.Put("/emoji-tiers/{tier}", adminH.HandleSetEmojiTier)
+				r.Delete("/emoji-tiers/{tier}", adminH.HandleDeleteEmojiTier)
+				r.Post("/emoji", adminH.HandleCreateInstanceEmoji)
+				r.Patch("/emoji/{emojiID}", adminH.HandleUpdateInstanceEmoji)
+				r.Delete("/emoji/{emojiID}", adminH.HandleDeleteInstanceEmoji)
+				r.Get("/boost-tiers", adminH.HandleListBoostTiers)
+				r.Put("/boost-tiers/{tier}", adminH.HandleSetBoostTier)
+				r.Delete("/boost-tiers/{tier}", adminH.HandleDeleteBoostTier)
+				r.Get("/boost-settings", adminH.HandleGetBoostSettings)
+				r.Patch("/boost-settings", adminH.HandleUpdateBoostSettings)
+				r.Get("/automod/rules", adminH.HandleListInstanceAutomodRules)
+				r.Post("/automod/rules", adminH.HandleCreateInstanceAutomodRule)
+				r.Patch("/automod/rules/{ruleID}", adminH.HandleUpdateInstanceAutomodRule)
+				r.Delete("/automod/rules/{ruleID}", adminH.HandleDeleteInstanceAutomodRule)
+				r.Get("/link-blocklist", adminH.HandleGetLinkBlocklist)
+				r.Post("/link-blocklist", adminH.HandleAddLinkBlocklistDomain)
+				r.Delete("/link-blocklist/{domain}", adminH.HandleRemoveLinkBlocklistDomain)
+				r.Get("/alert-webhooks", adminH.HandleListAlertWebhooks)
+				r.Post("/alert-webhooks", adminH.HandleCreateAlertWebhook)
+				r.Patch("/alert-webhooks/{webhookID}", adminH.HandleUpdateAlertWebhook)
+				r.Delete("/alert-webhooks/{webhookID}", adminH.HandleDeleteAlertWebhook)
+				r.Post("/alert-webhooks/{webhookID}/test", adminH.HandleTestAlertWebhook)
+				r.With(RequireInstancePermission(s.DB.Pool, permissions.InstanceHandleReports)).Post("/token-leaks", s.handleReportTokenLeak)
+				r.Get("/maintenance", adminH.HandleListMaintenance)
+				r.Post("/maintenance", adminH.HandleCreateMaintenance)
+				r.Patch("/maintenance/{windowID}", adminH.HandleUpdateMaintenance)
+				r.Delete("/maintenance/{windowID}", adminH.HandleCancelMaintenance)
+				r.Get("/motd", adminH.HandleGetMOTD)
+				r.Put("/motd", adminH.HandleUpdateMOTD)
+				r.Get("/subsystems", adminH.HandleGetSubsystems)
+				r.Patch("/subsystems", adminH.HandleUpdateSubsystems)
+				r.Get("/feature-flags", adminH.HandleListFeatureFlags)
+				r.Put("/feature-flags/{flagKey}", adminH.HandleUpdateFeatureFlag)
+				r.Delete("/feature-flags/{flagKey}", adminH.HandleDeleteFeatureFlag)
+				r.Put("/feature-flags/{flagKey}/overrides/{targetType}/{targetID}", adminH.HandleSetFeatureFlagOverride)
+				r.Delete("/feature-flags/{flagKey}/overrides/{targetType}/{targetID}", adminH.HandleDeleteFeatureFlagOverride)
+				r.Get("/branding", adminH.HandleGetBranding)
+				r.Put("/branding", adminH.HandleUpdateBranding)
+				r.Get("/guild-defaults", adminH.HandleGetGuildDefaults)
+				r.Put("/guild-defaults", adminH.HandleUpdateGuildDefaults)
+				r.Get("/broadcast-limits", adminH.HandleGetBroadcastLimits)
+				r.Put("/broadcast-limits", adminH.HandleUpdateBroadcastLimits)
+				r.Get("/supporters", adminH.HandleListSupporters)
+				r.Put("/supporters/{userID}", adminH.HandleSetSupporter)
+				r.Delete("/supporters/{userID}", adminH.HandleRevokeSupporter)
+				r.Get("/payment-events", adminH.HandleListPaymentEvents)
+				r.Get("/policies", adminH.HandleListPolicies)
+				r.Post("/policies", adminH.HandlePublishPolicy)
+				r.Get("/policies/{policyID}/acknowledgments", adminH.HandleGetPolicyAcknowledgments)
+				r.Get("/users/{userID}/policy-acknowledgments", adminH.HandleGetUserPolicyAcknowledgments)
 				r.Get("/users/{userID}/guilds", adminH.HandleGetUserGuilds)
+				r.Get("/users/{userID}/correlated-accounts", adminH.HandleGetCorrelatedAccounts)
 				r.Get("/registration", adminH.HandleGetRegistrationConfig)
 				r.Patch("/registration", adminH.HandleUpdateRegistrationConfig)
 				r.Post("/registration/tokens", adminH.HandleCreateRegistrationToken)
@@ -1033,8 +1460,20 @@ widgetH := &widgets.Handler{
 				r.Patch("/announcements/{announcementID}", adminH.HandleUpdateAnnouncement)
 				r.Delete("/announcements/{announcementID}", adminH.HandleDeleteAnnouncement)
 				r.Get("/reports", modH.HandleGetAdminReports)
-				r.Get("/bots", botH.HandleAdminListAllBots)
+				r.With(RequireInstancePermission(s.DB.Pool, permissions.InstanceManageUsers)).Get("/bots", botH.HandleAdminListAllBots)
+				r.With(RequireInstancePermission(s.DB.Pool, permissions.InstanceManageUsers)).Get("/bot-listings", botH.HandleAdminListListings)
+				r.With(RequireInstancePermission(s.DB.Pool, permissions.InstanceManageUsers)).Post("/bot-listings/{botID}/review", botH.HandleAdminReviewListing)
+				r.With(RequireInstancePermission(s.DB.Pool, permissions.InstanceManageUsers)).Get("/bot-message-content", botH.HandleAdminListMessageContentRequests)
+				r.With(RequireInstancePermission(s.DB.Pool, permissions.InstanceManageUsers)).Post("/bot-message-content/{botID}/review", botH.HandleAdminReviewMessageContent)
 				r.Get("/rate-limits/stats", adminH.HandleGetRateLimitStats)
+				r.With(RequireAdmin(s.DB.Pool)).Get("/database/queries", s.handleGetQueryStats)
+				r.With(RequireAdmin(s.DB.Pool)).Get("/gateway", s.handleGetGatewayStats)
+				r.With(RequireAdmin(s.DB.Pool)).Get("/gateway/guilds/{guildID}", s.handleGetGuildGatewayStats)
+				r.With(RequireAdmin(s.DB.Pool)).Get("/api-usage", s.handleGetAPIUsageStats)
+				r.With(RequireAdmin(s.DB.Pool)).Get("/jobs", s.handleListJobs)
+				r.With(RequireAdmin(s.DB.Pool)).Get("/jobs/runs", s.handleListJobRuns)
+				r.With(RequireAdmin(s.DB.Pool)).Post("/jobs/{jobName}/run", s.handleRunJob)
+				r.With(RequireAdmin(s.DB.Pool)).Post("/action-tokens", s.handleCreateAdminToken)
 				r.Get("/rate-limits/log", adminH.HandleGetRateLimitLog)
 				r.Patch("/rate-limits", adminH.HandleUpdateRateLimitConfig)
 				r.Route("/content-scan", func(r chi.Router) {
@@ -1050,6 +1489,8 @@ widgetH := &widgets.Handler{
 				// Federation dashboard and management.
 				r.Get("/federation/dashboard", adminH.HandleGetFederationDashboard)
 				r.Put("/federation/peers/{peerID}/control", adminH.HandleUpdatePeerControl)
+				r.Put("/federation/peers/{peerID}/trust", adminH.HandleUpdatePeerTrust)
+				r.Put("/federation/peers/{peerID}/sandbox", adminH.HandleUpdatePeerSandbox)
 				r.Post("/federation/peers/{peerID}/approve", adminH.HandleApproveFederationPeer)
 				r.Post("/federation/peers/{peerID}/reject", adminH.HandleRejectFederationPeer)
 				r.Get("/federation/peers/controls", adminH.HandleGetPeerControls)
@@ -1059,6 +1500,15 @@ widgetH := &widgets.Handler{
 				r.Post("/federation/delivery-receipts/{receiptID}/retry", adminH.HandleRetryDelivery)
 				r.Get("/federation/search-config", adminH.HandleGetFederatedSearchConfig)
 				r.Patch("/federation/search-config", adminH.HandleUpdateFederatedSearchConfig)
+				r.Get("/federation/directory-listing", adminH.HandleGetDirectoryListing)
+				r.Put("/federation/directory-listing", adminH.HandleUpdateDirectoryListing)
+				r.Get("/federation/directory", adminH.HandleGetDirectory)
+				r.Post("/federation/directory/refresh", adminH.HandleRefreshDirectory)
+				r.Get("/federation/sandbox", adminH.HandleGetFederationSandbox)
+				r.Put("/federation/sandbox", adminH.HandleUpdateFederationSandbox)
+				r.Get("/federation/sandbox/events", adminH.HandleGetSandboxEvents)
+				r.Delete("/federation/sandbox/events", adminH.HandleClearSandboxEvents)
+				r.Get("/federation/rejections", adminH.HandleGetFederationRejections)
 				r.Get("/federation/protocol", adminH.HandleGetProtocolInfo)
 				r.Patch("/federation/protocol", adminH.HandleUpdateProtocolConfig)
 
@@ -1080,6 +1530,9 @@ widgetH := &widgets.Handler{
 				r.Patch("/updates/config", adminH.HandleUpdateUpdateConfig)
 				r.Get("/health/dashboard", adminH.HandleGetHealthDashboard)
 				r.Get("/health/history", adminH.HandleGetHealthHistory)
+				r.Get("/jetstream/streams", adminH.HandleGetJetStreamStatus)
+				r.Post("/jetstream/streams/{stream}/purge", adminH.HandlePurgeJetStream)
+				r.Post("/jetstream/consumers/{consumer}/replay", adminH.HandleReplayJetStreamConsumer)
 				r.Get("/storage", adminH.HandleGetStorageDashboard)
 				r.Route("/retention", func(r chi.Router) {
 					r.Get("/", adminH.HandleGetRetentionPolicies)
@@ -1088,6 +1541,13 @@ widgetH := &widgets.Handler{
 					r.Delete("/{policyID}", adminH.HandleDeleteRetentionPolicy)
 					r.Post("/{policyID}/run", adminH.HandleRunRetentionPolicy)
 				})
+				r.Get("/instance-retention", adminH.HandleGetInstanceRetention)
+				r.Patch("/instance-retention", adminH.HandleUpdateInstanceRetention)
+				r.Route("/legal-holds", func(r chi.Router) {
+					r.Get("/", adminH.HandleListLegalHolds)
+					r.Post("/", adminH.HandleCreateLegalHold)
+					r.Delete("/{holdID}", adminH.HandleReleaseLegalHold)
+				})
 				r.Route("/domains", func(r chi.Router) {
 					r.Get("/", adminH.HandleGetCustomDomains)
 					r.Post("/", adminH.HandleCreateCustomDomain)
@@ -1115,9 +1575,25 @@ widgetH := &widgets.Handler{
 					r.Get("/{bridgeID}/virtual-users", adminH.HandleGetBridgeVirtualUsers)
 				})
 
+				// Guilds exposed through the embedded Matrix appservice.
+				r.Get("/matrix/guilds", adminH.HandleGetMatrixGuilds)
+				r.Put("/matrix/guilds/{guildID}", adminH.HandleAddMatrixGuild)
+				r.Delete("/matrix/guilds/{guildID}", adminH.HandleRemoveMatrixGuild)
+
 				// Admin media management.
 				r.Get("/media", adminH.HandleAdminGetMedia)
 				r.Delete("/media/{fileID}", adminH.HandleAdminDeleteMedia)
+
+				// SCIM provisioning tokens and group → role mappings.
+				r.Route("/scim", func(r chi.Router) {
+					r.Use(RequireAdmin(s.DB.Pool))
+					r.Get("/tokens", scimH.HandleListTokens)
+					r.Post("/tokens", scimH.HandleCreateToken)
+					r.Delete("/tokens/{tokenID}", scimH.HandleDeleteToken)
+					r.Get("/mappings", scimH.HandleListGroupMappings)
+					r.Post("/mappings", scimH.HandleCreateGroupMapping)
+					r.Delete("/mappings/{groupID}/{roleID}", scimH.HandleDeleteGroupMapping)
+				})
 			})
 		})
 
@@ -1126,15 +1602,35 @@ widgetH := &widgets.Handler{
 			r.Use(s.RateLimitGlobal())
 
 			r.Get("/guilds/{guildID}/widget.json", widgetH.HandleGetGuildWidgetEmbed)
+			r.Get("/policies", userH.HandleGetCurrentPolicies)
+
+			// Read-only HTML view of channels opted in with public_view.
+			r.Get("/public/guilds/{guildRef}", guildH.HandlePublicGuildView)
+			r.Get("/public/guilds/{guildRef}/channels/{channelID}", guildH.HandlePublicChannelView)
 
 			if s.Media != nil {
-				r.Get("/files/{fileID}", s.Media.HandleGetFile)
+				r.With(auth.OptionalAuth(s.AuthService)).Get("/files/{fileID}", s.Media.HandleGetFile)
+				r.With(auth.OptionalAuth(s.AuthService)).Get("/files/{fileID}/thumbnail", s.Media.HandleGetThumbnail)
+				r.Get("/embeds/images/{imageID}", s.Media.HandleGetEmbedImage)
+				r.Get("/emoji/{emojiID}", s.Media.HandleGetEmoji)
 			}
 
+			// Shared channel transcripts; members-only links need a session.
+			r.With(auth.OptionalAuth(s.AuthService)).Get("/transcripts/{code}", userH.HandleGetTranscript)
+
+			// `amityvox admin` actions from a remote CLI, authenticated with an
+			// admin action token instead of a session.
+			r.Post("/admin-cli/run", s.handleRunAdminAction)
+
+			// Leaked webhook URLs and bot tokens reported by a secret scanning
+			// service, authenticated with token_leaks.report_secret.
+			r.Post("/token-leaks", s.handleTokenLeakHook)
+
 			// Federation media proxy — streams remote instance media to avoid CORS issues.
 			r.Get("/federation/media/{instanceId}/{fileId}", s.handleFederationMediaProxy)
 
-			r.With(s.RateLimitWebhooks).Post("/webhooks/{webhookID}/{token}", webhookH.HandleExecute)
+			r.With(s.requireSubsystem(models.SubsystemMessaging), s.RateLimitWebhooks).Post("/webhooks/{webhookID}/{token}", webhookH.HandleExecute)
+			r.Post("/voice/webhook", s.handleLiveKitWebhook)
 		})
 	})
 
@@ -1142,6 +1638,10 @@ widgetH := &widgets.Handler{
 	if s.EventBus != nil {
 		webhookH.StartOutgoingWebhookSubscriber()
 	}
+
+	// Drop cached member lists as guild membership changes.
+	guildH.StartMemberListInvalidation()
+	userH.StartSelfGuildsInvalidation()
 }
 
 // Start begins listening for HTTP requests on the configured address.
@@ -1154,6 +1654,9 @@ func (s *Server) Start() error {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	s.usageStop = make(chan struct{})
+	go s.runUsageFlusher(s.usageStop)
+
 	s.Logger.Info("HTTP server starting", slog.String("listen", s.Config.HTTP.Listen))
 	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("HTTP server error: %w", err)
@@ -1164,7 +1667,12 @@ func (s *Server) Start() error {
 // Shutdown gracefully stops the HTTP server.
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.Logger.Info("HTTP server shutting down")
-	return s.server.Shutdown(ctx)
+	err := s.server.Shutdown(ctx)
+	if s.usageStop != nil {
+		close(s.usageStop)
+		s.flushUsage(ctx)
+	}
+	return err
 }
 
 // --- Auth Handlers ---
@@ -1267,6 +1775,26 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleSuspensionAppeal handles POST /api/v1/auth/suspension-appeal.
+func (s *Server) handleSuspensionAppeal(w http.ResponseWriter, r *http.Request) {
+	var req auth.AppealRequest
+	if !DecodeJSON(w, r, &req) {
+		return
+	}
+
+	appeal, err := s.AuthService.SubmitSuspensionAppeal(r.Context(), req)
+	if err != nil {
+		if authErr, ok := err.(*auth.AuthError); ok {
+			WriteError(w, authErr.Status, authErr.Code, authErr.Message)
+			return
+		}
+		InternalError(w, s.Logger, "Failed to submit appeal", err)
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, appeal)
+}
+
 // handleLogout handles POST /api/v1/auth/logout.
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	sessionID := auth.SessionIDFromContext(r.Context())
@@ -1293,7 +1821,7 @@ func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.CurrentPassword == "" || req.NewPassword == "" {
-		WriteError(w, http.StatusBadRequest, "missing_fields", "Both current_password and new_password are required")
+		WriteCode(w, apierrors.MissingFields, "Both current_password and new_password are required")
 		return
 	}
 
@@ -1319,7 +1847,7 @@ func (s *Server) handleChangeEmail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.Password == "" || req.NewEmail == "" {
-		WriteError(w, http.StatusBadRequest, "missing_fields", "Both password and new_email are required")
+		WriteCode(w, apierrors.MissingFields, "Both password and new_email are required")
 		return
 	}
 
@@ -1412,6 +1940,12 @@ func WriteError(w http.ResponseWriter, status int, code, message string) {
 	apiutil.WriteError(w, status, code, message)
 }
 
+// WriteCode writes a JSON error response for a code from the error catalog.
+// Delegates to apiutil.WriteCode.
+func WriteCode(w http.ResponseWriter, code apierrors.Code, message string) {
+	apiutil.WriteCode(w, code, message)
+}
+
 // WriteNoContent writes a 204 No Content response with no body.
 // Delegates to apiutil.WriteNoContent.
 func WriteNoContent(w http.ResponseWriter) {
@@ -1470,9 +2004,32 @@ func maxBodySize(n int64) func(http.Handler) http.Handler {
 	}
 }
 
+// clientVersionHeaders advertises the minimum supported client version on
+// every response. Requests whose X-Client-Version is older are still served
+// but flagged with X-Client-Upgrade-Required, so the client can prompt the
+// user before the gateway turns it away.
+func clientVersionHeaders(clients config.ClientsConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if clients.MinVersion == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Min-Client-Version", clients.MinVersion)
+			if clients.UpgradeURL != "" {
+				w.Header().Set("X-Client-Upgrade-URL", clients.UpgradeURL)
+			}
+			if clients.Outdated(r.Header.Get("X-Client-Version")) {
+				w.Header().Set("X-Client-Upgrade-Required", "true")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // corsMiddleware returns a chi middleware that sets CORS headers for the given
-// allowed origins.
+// allowed origins and origin patterns.
 func corsMiddleware(origins []string) func(http.Handler) http.Handler {
+	cors := config.HTTPConfig{CORSOrigins: origins}
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			origin := r.Header.Get("Origin")
@@ -1481,21 +2038,13 @@ func corsMiddleware(origins []string) func(http.Handler) http.Handler {
 				return
 			}
 
-			allowed := false
-			for _, o := range origins {
-				if o == "*" || o == origin {
-					allowed = true
-					break
-				}
-			}
-
-			if allowed {
+			if cors.OriginAllowed(origin) {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
 				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-Request-ID")
+				w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-Request-ID, X-Client-Version")
+				w.Header().Set("Access-Control-Expose-Headers", "X-Min-Client-Version, X-Client-Upgrade-URL, X-Client-Upgrade-Required")
 				// Only set Allow-Credentials when using explicit origins, not wildcard.
-				isWildcard := len(origins) == 1 && origins[0] == "*"
-				if !isWildcard {
+				if !cors.AnyOrigin() {
 					w.Header().Set("Access-Control-Allow-Credentials", "true")
 				}
 				w.Header().Set("Access-Control-Max-Age", "86400")
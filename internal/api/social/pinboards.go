@@ -0,0 +1,265 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// maxPinboards is how many pinboards a guild may run at once.
+const maxPinboards = 10
+
+// Pinboard is a channel that collects messages once they get enough of one
+// reaction, like a starboard. A guild can run several, each watching its own
+// set of channels.
+type Pinboard struct {
+	ID                string    `json:"id"`
+	GuildID           string    `json:"guild_id"`
+	Name              string    `json:"name"`
+	ChannelID         string    `json:"channel_id"`
+	Emoji             string    `json:"emoji"`
+	Threshold         int       `json:"threshold"`
+	SelfStar          bool      `json:"self_star"`
+	NSFWAllowed       bool      `json:"nsfw_allowed"`
+	SourceChannelIDs  []string  `json:"source_channel_ids"`
+	IgnoredChannelIDs []string  `json:"ignored_channel_ids"`
+	Enabled           bool      `json:"enabled"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+const pinboardColumns = `id, guild_id, name, channel_id, emoji, threshold, self_star, nsfw_allowed,
+	source_channel_ids, ignored_channel_ids, enabled, created_at, updated_at`
+
+func scanPinboard(row pgx.Row) (Pinboard, error) {
+	var b Pinboard
+	err := row.Scan(&b.ID, &b.GuildID, &b.Name, &b.ChannelID, &b.Emoji, &b.Threshold, &b.SelfStar,
+		&b.NSFWAllowed, &b.SourceChannelIDs, &b.IgnoredChannelIDs, &b.Enabled, &b.CreatedAt, &b.UpdatedAt)
+	return b, err
+}
+
+// isGuildChannel reports whether the channel belongs to the guild.
+func (h *Handler) isGuildChannel(ctx context.Context, guildID, channelID string) bool {
+	var ok bool
+	h.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM channels WHERE id = $1 AND guild_id = $2)`,
+		channelID, guildID).Scan(&ok)
+	return ok
+}
+
+// allGuildChannels reports whether every channel in ids belongs to the guild.
+func (h *Handler) allGuildChannels(ctx context.Context, guildID string, ids []string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	var n int
+	h.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM channels WHERE id = ANY($1) AND guild_id = $2`,
+		ids, guildID).Scan(&n)
+	return n == len(ids)
+}
+
+type pinboardRequest struct {
+	Name              *string   `json:"name"`
+	ChannelID         *string   `json:"channel_id"`
+	Emoji             *string   `json:"emoji"`
+	Threshold         *int      `json:"threshold"`
+	SelfStar          *bool     `json:"self_star"`
+	NSFWAllowed       *bool     `json:"nsfw_allowed"`
+	SourceChannelIDs  *[]string `json:"source_channel_ids"`
+	IgnoredChannelIDs *[]string `json:"ignored_channel_ids"`
+	Enabled           *bool     `json:"enabled"`
+}
+
+// validatePinboard checks the fields present in a create or update request.
+func (h *Handler) validatePinboard(w http.ResponseWriter, r *http.Request, guildID string, req *pinboardRequest) bool {
+	if req.Name != nil {
+		*req.Name = strings.TrimSpace(*req.Name)
+		if *req.Name == "" || len(*req.Name) > 64 {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_name", "Board name must be 1-64 characters")
+			return false
+		}
+	}
+	if req.Emoji != nil && (*req.Emoji == "" || len(*req.Emoji) > 64) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_emoji", "Emoji must be 1-64 characters")
+		return false
+	}
+	if req.Threshold != nil && (*req.Threshold < 1 || *req.Threshold > 100) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_threshold", "Threshold must be between 1 and 100")
+		return false
+	}
+	if req.ChannelID != nil && !h.isGuildChannel(r.Context(), guildID, *req.ChannelID) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_channel", "Board channel must belong to this guild")
+		return false
+	}
+	for _, ids := range []*[]string{req.SourceChannelIDs, req.IgnoredChannelIDs} {
+		if ids != nil && !h.allGuildChannels(r.Context(), guildID, *ids) {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_channel", "Filter channels must belong to this guild")
+			return false
+		}
+	}
+	return true
+}
+
+// HandleGetPinboards lists a guild's pinboards.
+// GET /api/v1/guilds/{guildID}/pinboards
+func (h *Handler) HandleGetPinboards(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isMember(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+pinboardColumns+` FROM pinboards WHERE guild_id = $1 ORDER BY created_at`, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load pinboards", err)
+		return
+	}
+	defer rows.Close()
+
+	boards := make([]Pinboard, 0)
+	for rows.Next() {
+		b, err := scanPinboard(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read pinboards", err)
+			return
+		}
+		boards = append(boards, b)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, boards)
+}
+
+// HandleCreatePinboard creates a pinboard.
+// POST /api/v1/guilds/{guildID}/pinboards
+func (h *Handler) HandleCreatePinboard(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+
+	if !h.isGuildAdmin(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can manage pinboards")
+		return
+	}
+
+	var req pinboardRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name == nil || req.ChannelID == nil {
+		apiutil.WriteCode(w, apierrors.MissingFields, "name and channel_id are required")
+		return
+	}
+	if !h.validatePinboard(w, r, guildID, &req) {
+		return
+	}
+
+	var count int
+	h.Pool.QueryRow(r.Context(), `SELECT COUNT(*) FROM pinboards WHERE guild_id = $1`, guildID).Scan(&count)
+	if count >= maxPinboards {
+		apiutil.WriteError(w, http.StatusBadRequest, "too_many_pinboards", "A guild can have at most 10 pinboards")
+		return
+	}
+
+	board, err := scanPinboard(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO pinboards (id, guild_id, name, channel_id, emoji, threshold, self_star, nsfw_allowed,
+		                        source_channel_ids, ignored_channel_ids, enabled, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, COALESCE($5, 'star'), COALESCE($6, 3), COALESCE($7, false), COALESCE($8, false),
+		         COALESCE($9::text[], '{}'), COALESCE($10::text[], '{}'), COALESCE($11, true), NOW(), NOW())
+		 RETURNING `+pinboardColumns,
+		models.NewULID().String(), guildID, *req.Name, *req.ChannelID, req.Emoji, req.Threshold,
+		req.SelfStar, req.NSFWAllowed, req.SourceChannelIDs, req.IgnoredChannelIDs, req.Enabled))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create pinboard", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusCreated, board)
+}
+
+// HandleUpdatePinboard updates a pinboard. Send an empty source_channel_ids
+// list to watch every channel again.
+// PATCH /api/v1/guilds/{guildID}/pinboards/{boardID}
+func (h *Handler) HandleUpdatePinboard(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	boardID := chi.URLParam(r, "boardID")
+
+	if !h.isGuildAdmin(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can manage pinboards")
+		return
+	}
+
+	var req pinboardRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if !h.validatePinboard(w, r, guildID, &req) {
+		return
+	}
+
+	board, err := scanPinboard(h.Pool.QueryRow(r.Context(),
+		`UPDATE pinboards SET
+		     name = COALESCE($3, name),
+		     channel_id = COALESCE($4, channel_id),
+		     emoji = COALESCE($5, emoji),
+		     threshold = COALESCE($6, threshold),
+		     self_star = COALESCE($7, self_star),
+		     nsfw_allowed = COALESCE($8, nsfw_allowed),
+		     source_channel_ids = COALESCE($9, source_channel_ids),
+		     ignored_channel_ids = COALESCE($10, ignored_channel_ids),
+		     enabled = COALESCE($11, enabled),
+		     updated_at = NOW()
+		 WHERE id = $1 AND guild_id = $2
+		 RETURNING `+pinboardColumns,
+		boardID, guildID, req.Name, req.ChannelID, req.Emoji, req.Threshold, req.SelfStar,
+		req.NSFWAllowed, req.SourceChannelIDs, req.IgnoredChannelIDs, req.Enabled))
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "pinboard_not_found", "Pinboard not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update pinboard", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, board)
+}
+
+// HandleDeletePinboard deletes a pinboard and its entries. Messages it
+// already posted stay in the board channel.
+// DELETE /api/v1/guilds/{guildID}/pinboards/{boardID}
+func (h *Handler) HandleDeletePinboard(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	boardID := chi.URLParam(r, "boardID")
+
+	if !h.isGuildAdmin(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can manage pinboards")
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM pinboards WHERE id = $1 AND guild_id = $2`, boardID, guildID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete pinboard", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "pinboard_not_found", "Pinboard not found")
+		return
+	}
+
+	apiutil.WriteNoContent(w)
+}
@@ -20,10 +20,12 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/payments"
 )
 
 // Handler implements Social & Growth REST API endpoints.
@@ -96,7 +98,7 @@ func (h *Handler) HandleGetInsights(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can view insights")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can view insights")
 		return
 	}
 
@@ -301,7 +303,7 @@ func (h *Handler) HandleGetBoosts(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -341,7 +343,7 @@ func (h *Handler) HandleGetBoosts(w http.ResponseWriter, r *http.Request) {
 	}
 
 	boostCount := len(boosters)
-	boostTier := computeBoostTier(boostCount)
+	boostTier := apiutil.BoostTier(boostCount)
 
 	apiutil.WriteJSON(w, http.StatusOK, BoostSummary{
 		BoostCount:  boostCount,
@@ -351,17 +353,38 @@ func (h *Handler) HandleGetBoosts(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// HandleCreateBoost adds a boost to a guild.
+// HandleCreateBoost adds a boost to a guild. When the instance requires
+// supporters to boost, each boost takes one of the user's boost slots.
 // POST /api/v1/guilds/{guildID}/boosts
 func (h *Handler) HandleCreateBoost(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
+	if apiutil.BoostsRequireSupporter(r.Context(), h.Pool) {
+		var boosting bool
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM guild_boosts WHERE guild_id = $1 AND user_id = $2 AND active = true)`,
+			guildID, userID).Scan(&boosting)
+		slots, used, err := apiutil.SupporterBoostSlots(r.Context(), h.Pool, userID)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to check boost slots", err)
+			return
+		}
+		if !boosting && used >= slots {
+			if slots == 0 {
+				apiutil.WriteError(w, http.StatusForbidden, "not_supporter", "Only supporters can boost guilds")
+			} else {
+				apiutil.WriteError(w, http.StatusForbidden, "no_boost_slots", "All of your boost slots are in use")
+			}
+			return
+		}
+	}
+
 	id := models.NewULID().String()
 
 	var b BoostInfo
@@ -385,6 +408,12 @@ func (h *Handler) HandleCreateBoost(w http.ResponseWriter, r *http.Request) {
 	// Award achievement.
 	h.awardAchievement(r.Context(), userID, "achv_booster")
 
+	if _, err := apiutil.PostSystemMessage(r.Context(), h.Pool, h.EventBus, guildID,
+		models.MessageTypeSystemBoost, userID, nil); err != nil {
+		h.Logger.Warn("failed to post boost message",
+			slog.String("guild_id", guildID), slog.String("error", err.Error()))
+	}
+
 	h.EventBus.PublishGuildEvent(r.Context(), events.SubjectGuildUpdate, "GUILD_UPDATE", guildID, map[string]interface{}{
 		"id":       guildID,
 		"boosted":  true,
@@ -419,28 +448,48 @@ func (h *Handler) HandleRemoveBoost(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) updateGuildBoostCount(ctx context.Context, guildID string) {
-	var count int
-	h.Pool.QueryRow(ctx,
-		`SELECT COUNT(*) FROM guild_boosts WHERE guild_id = $1 AND active = true`, guildID,
-	).Scan(&count)
-	tier := computeBoostTier(count)
-	h.Pool.Exec(ctx,
-		`UPDATE guilds SET boost_count = $2, boost_tier = $3 WHERE id = $1`,
-		guildID, count, tier,
-	)
+	if err := apiutil.RecountGuildBoosts(ctx, h.Pool, guildID); err != nil {
+		h.Logger.Error("failed to update guild boost count",
+			slog.String("guild_id", guildID), slog.String("error", err.Error()))
+	}
 }
 
-func computeBoostTier(count int) int {
-	switch {
-	case count >= 14:
-		return 3
-	case count >= 7:
-		return 2
-	case count >= 2:
-		return 1
-	default:
-		return 0
+// HandleGetKofiLinkCode returns the code the user puts in their Ko-fi message
+// so their Ko-fi subscription payments are credited to this account.
+// GET /api/v1/users/@me/boost-slots/kofi-code
+func (h *Handler) HandleGetKofiLinkCode(w http.ResponseWriter, r *http.Request) {
+	code, err := payments.KofiLinkCode(r.Context(), h.Pool, auth.UserIDFromContext(r.Context()))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load Ko-fi link code", err)
+		return
 	}
+	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"code": code})
+}
+
+// HandleGetBoostSlots returns the user's supporter entitlement and how many of
+// its boost slots are in use. Users who are not supporters get zero slots.
+// GET /api/v1/users/@me/boost-slots
+func (h *Handler) HandleGetBoostSlots(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	ent := models.SupporterEntitlement{UserID: userID, Source: models.SupporterSourceManual}
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT boost_slots, source, expires_at, created_at, updated_at
+		 FROM supporter_entitlements
+		 WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > now())`, userID,
+	).Scan(&ent.BoostSlots, &ent.Source, &ent.ExpiresAt, &ent.CreatedAt, &ent.UpdatedAt)
+	if err != nil && err != pgx.ErrNoRows {
+		apiutil.InternalError(w, h.Logger, "Failed to load boost slots", err)
+		return
+	}
+	h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM guild_boosts WHERE user_id = $1 AND active = true`, userID,
+	).Scan(&ent.SlotsUsed)
+
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"require_supporter": apiutil.BoostsRequireSupporter(r.Context(), h.Pool),
+		"entitlement":       ent,
+	})
 }
 
 // ============================================================
@@ -466,7 +515,7 @@ func (h *Handler) HandleClaimVanityURL(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can claim vanity URLs")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can claim vanity URLs")
 		return
 	}
 
@@ -570,7 +619,7 @@ func (h *Handler) HandleReleaseVanityURL(w http.ResponseWriter, r *http.Request)
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can release vanity URLs")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can release vanity URLs")
 		return
 	}
 
@@ -584,7 +633,7 @@ func (h *Handler) HandleReleaseVanityURL(w http.ResponseWriter, r *http.Request)
 		return nil
 	})
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to release vanity URL")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to release vanity URL")
 		return
 	}
 
@@ -732,7 +781,7 @@ func (h *Handler) HandleCheckAchievements(w http.ResponseWriter, r *http.Request
 		`SELECT id, criteria_type, criteria_threshold FROM achievement_definitions`,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to check achievements")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to check achievements")
 		return
 	}
 	defer rows.Close()
@@ -841,7 +890,7 @@ func (h *Handler) HandleGetLevelingConfig(w http.ResponseWriter, r *http.Request
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -909,7 +958,7 @@ func (h *Handler) HandleUpdateLevelingConfig(w http.ResponseWriter, r *http.Requ
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can update leveling config")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can update leveling config")
 		return
 	}
 
@@ -964,7 +1013,7 @@ func (h *Handler) HandleAddLevelRole(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can manage level roles")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can manage level roles")
 		return
 	}
 
@@ -1009,7 +1058,7 @@ func (h *Handler) HandleDeleteLevelRole(w http.ResponseWriter, r *http.Request)
 	roleMapID := chi.URLParam(r, "roleMapID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can manage level roles")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can manage level roles")
 		return
 	}
 
@@ -1022,7 +1071,7 @@ func (h *Handler) HandleDeleteLevelRole(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Level role not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Level role not found")
 		return
 	}
 
@@ -1036,7 +1085,7 @@ func (h *Handler) HandleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -1088,7 +1137,7 @@ func (h *Handler) HandleGetMemberXP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -1245,7 +1294,8 @@ func (h *Handler) assignLevelRoles(ctx context.Context, guildID, userID string,
 // 6. Starboard
 // ============================================================
 
-// StarboardConfig represents a guild's starboard settings.
+// StarboardConfig represents a guild's starboard settings. It is a view of
+// the guild's first pinboard, kept for clients that predate pinboards.
 type StarboardConfig struct {
 	GuildID    string `json:"guild_id"`
 	Enabled    bool   `json:"enabled"`
@@ -1256,10 +1306,11 @@ type StarboardConfig struct {
 	NSFWAllowed bool  `json:"nsfw_allowed"`
 }
 
-// StarboardEntry represents a starred message.
+// StarboardEntry represents a message that reached a pinboard.
 type StarboardEntry struct {
 	ID                 string  `json:"id"`
 	GuildID            string  `json:"guild_id"`
+	BoardID            string  `json:"board_id"`
 	SourceMessageID    string  `json:"source_message_id"`
 	SourceChannelID    string  `json:"source_channel_id"`
 	StarboardMessageID *string `json:"starboard_message_id,omitempty"`
@@ -1268,24 +1319,26 @@ type StarboardEntry struct {
 	CreatedAt          string  `json:"created_at"`
 }
 
-// HandleGetStarboardConfig returns the starboard config for a guild.
+// HandleGetStarboardConfig returns the guild's first pinboard as a starboard
+// config.
 // GET /api/v1/guilds/{guildID}/starboard
 func (h *Handler) HandleGetStarboardConfig(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
-	var cfg StarboardConfig
+	cfg := StarboardConfig{GuildID: guildID}
 	err := h.Pool.QueryRow(r.Context(),
-		`SELECT guild_id, enabled, channel_id, emoji, threshold, self_star, nsfw_allowed
-		 FROM guild_starboard_config
-		 WHERE guild_id = $1`,
+		`SELECT enabled, channel_id, emoji, threshold, self_star, nsfw_allowed
+		 FROM pinboards
+		 WHERE guild_id = $1
+		 ORDER BY created_at LIMIT 1`,
 		guildID,
-	).Scan(&cfg.GuildID, &cfg.Enabled, &cfg.ChannelID, &cfg.Emoji,
+	).Scan(&cfg.Enabled, &cfg.ChannelID, &cfg.Emoji,
 		&cfg.Threshold, &cfg.SelfStar, &cfg.NSFWAllowed)
 	if err == pgx.ErrNoRows {
 		cfg = StarboardConfig{
@@ -1312,14 +1365,15 @@ type updateStarboardConfigRequest struct {
 	NSFWAllowed *bool   `json:"nsfw_allowed"`
 }
 
-// HandleUpdateStarboardConfig updates the starboard config for a guild.
+// HandleUpdateStarboardConfig updates the guild's first pinboard, creating
+// it if the guild has none.
 // PATCH /api/v1/guilds/{guildID}/starboard
 func (h *Handler) HandleUpdateStarboardConfig(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can update starboard config")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can update starboard config")
 		return
 	}
 
@@ -1332,30 +1386,43 @@ func (h *Handler) HandleUpdateStarboardConfig(w http.ResponseWriter, r *http.Req
 		apiutil.WriteError(w, http.StatusBadRequest, "invalid_threshold", "Threshold must be between 1 and 100")
 		return
 	}
+	if req.ChannelID != nil && !h.isGuildChannel(r.Context(), guildID, *req.ChannelID) {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_channel", "Starboard channel must belong to this guild")
+		return
+	}
 
-	var cfg StarboardConfig
-	err := h.Pool.QueryRow(r.Context(),
-		`INSERT INTO guild_starboard_config (guild_id, enabled, channel_id, emoji, threshold, self_star, nsfw_allowed, updated_at)
-		 VALUES ($1,
-		     COALESCE($2, false),
-		     $3,
-		     COALESCE($4, 'star'),
-		     COALESCE($5, 3),
-		     COALESCE($6, false),
-		     COALESCE($7, false),
-		     NOW())
-		 ON CONFLICT (guild_id) DO UPDATE SET
-		     enabled = COALESCE($2, guild_starboard_config.enabled),
-		     channel_id = COALESCE($3, guild_starboard_config.channel_id),
-		     emoji = COALESCE($4, guild_starboard_config.emoji),
-		     threshold = COALESCE($5, guild_starboard_config.threshold),
-		     self_star = COALESCE($6, guild_starboard_config.self_star),
-		     nsfw_allowed = COALESCE($7, guild_starboard_config.nsfw_allowed),
+	cfg := StarboardConfig{GuildID: guildID}
+	scan := func(row pgx.Row) error {
+		return row.Scan(&cfg.Enabled, &cfg.ChannelID, &cfg.Emoji,
+			&cfg.Threshold, &cfg.SelfStar, &cfg.NSFWAllowed)
+	}
+	err := scan(h.Pool.QueryRow(r.Context(),
+		`UPDATE pinboards SET
+		     enabled = COALESCE($2, enabled),
+		     channel_id = COALESCE($3, channel_id),
+		     emoji = COALESCE($4, emoji),
+		     threshold = COALESCE($5, threshold),
+		     self_star = COALESCE($6, self_star),
+		     nsfw_allowed = COALESCE($7, nsfw_allowed),
 		     updated_at = NOW()
-		 RETURNING guild_id, enabled, channel_id, emoji, threshold, self_star, nsfw_allowed`,
-		guildID, req.Enabled, req.ChannelID, req.Emoji, req.Threshold, req.SelfStar, req.NSFWAllowed,
-	).Scan(&cfg.GuildID, &cfg.Enabled, &cfg.ChannelID, &cfg.Emoji,
-		&cfg.Threshold, &cfg.SelfStar, &cfg.NSFWAllowed)
+		 WHERE id = (SELECT id FROM pinboards WHERE guild_id = $1 ORDER BY created_at LIMIT 1)
+		 RETURNING enabled, channel_id, emoji, threshold, self_star, nsfw_allowed`,
+		guildID, req.Enabled, req.ChannelID, req.Emoji, req.Threshold, req.SelfStar, req.NSFWAllowed))
+	if err == pgx.ErrNoRows {
+		// No board yet: a starboard needs a channel to post in.
+		if req.ChannelID == nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "channel_required", "Choose a starboard channel first")
+			return
+		}
+		err = scan(h.Pool.QueryRow(r.Context(),
+			`INSERT INTO pinboards (id, guild_id, name, channel_id, emoji, threshold, self_star, nsfw_allowed,
+			                        enabled, created_at, updated_at)
+			 VALUES ($1, $2, 'Starboard', $3, COALESCE($4, 'star'), COALESCE($5, 3), COALESCE($6, false),
+			         COALESCE($7, false), COALESCE($8, false), NOW(), NOW())
+			 RETURNING enabled, channel_id, emoji, threshold, self_star, nsfw_allowed`,
+			models.NewULID().String(), guildID, req.ChannelID, req.Emoji, req.Threshold,
+			req.SelfStar, req.NSFWAllowed, req.Enabled))
+	}
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to update starboard config", err)
 		return
@@ -1364,14 +1431,15 @@ func (h *Handler) HandleUpdateStarboardConfig(w http.ResponseWriter, r *http.Req
 	apiutil.WriteJSON(w, http.StatusOK, cfg)
 }
 
-// HandleGetStarboardEntries returns starred messages for a guild.
-// GET /api/v1/guilds/{guildID}/starboard/entries?limit=25
+// HandleGetStarboardEntries returns messages that reached the guild's
+// pinboards, optionally only one board's.
+// GET /api/v1/guilds/{guildID}/starboard/entries?limit=25&board_id=
 func (h *Handler) HandleGetStarboardEntries(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -1381,15 +1449,19 @@ func (h *Handler) HandleGetStarboardEntries(w http.ResponseWriter, r *http.Reque
 			limit = parsed
 		}
 	}
+	var boardID *string
+	if b := r.URL.Query().Get("board_id"); b != "" {
+		boardID = &b
+	}
 
 	rows, err := h.Pool.Query(r.Context(),
-		`SELECT id, guild_id, source_message_id, source_channel_id,
+		`SELECT id, guild_id, board_id, source_message_id, source_channel_id,
 		        starboard_message_id, star_count, author_id, created_at
 		 FROM starboard_entries
-		 WHERE guild_id = $1
+		 WHERE guild_id = $1 AND ($3::text IS NULL OR board_id = $3)
 		 ORDER BY star_count DESC, created_at DESC
 		 LIMIT $2`,
-		guildID, limit,
+		guildID, limit, boardID,
 	)
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to load starboard", err)
@@ -1401,7 +1473,7 @@ func (h *Handler) HandleGetStarboardEntries(w http.ResponseWriter, r *http.Reque
 	for rows.Next() {
 		var e StarboardEntry
 		var createdAt time.Time
-		if err := rows.Scan(&e.ID, &e.GuildID, &e.SourceMessageID, &e.SourceChannelID,
+		if err := rows.Scan(&e.ID, &e.GuildID, &e.BoardID, &e.SourceMessageID, &e.SourceChannelID,
 			&e.StarboardMessageID, &e.StarCount, &e.AuthorID, &createdAt); err != nil {
 			continue
 		}
@@ -1412,133 +1484,6 @@ func (h *Handler) HandleGetStarboardEntries(w http.ResponseWriter, r *http.Reque
 	apiutil.WriteJSON(w, http.StatusOK, entries)
 }
 
-// CheckStarboard evaluates whether a message has reached the starboard threshold
-// and posts/updates it in the starboard channel. Called by the reaction handler.
-func (h *Handler) CheckStarboard(ctx context.Context, guildID, channelID, messageID, emoji string) {
-	var cfg StarboardConfig
-	err := h.Pool.QueryRow(ctx,
-		`SELECT guild_id, enabled, channel_id, emoji, threshold, self_star, nsfw_allowed
-		 FROM guild_starboard_config WHERE guild_id = $1`,
-		guildID,
-	).Scan(&cfg.GuildID, &cfg.Enabled, &cfg.ChannelID, &cfg.Emoji,
-		&cfg.Threshold, &cfg.SelfStar, &cfg.NSFWAllowed)
-	if err != nil || !cfg.Enabled || cfg.ChannelID == nil {
-		return
-	}
-
-	// Only match the configured emoji.
-	if emoji != cfg.Emoji {
-		return
-	}
-
-	// Count matching reactions on the message.
-	var count int
-	h.Pool.QueryRow(ctx,
-		`SELECT COUNT(*) FROM reactions WHERE message_id = $1 AND emoji = $2`,
-		messageID, emoji,
-	).Scan(&count)
-
-	// If self-star is disabled, subtract author's own reaction.
-	if !cfg.SelfStar {
-		var authorID string
-		h.Pool.QueryRow(ctx,
-			`SELECT author_id FROM messages WHERE id = $1`, messageID,
-		).Scan(&authorID)
-		var selfStarred bool
-		h.Pool.QueryRow(ctx,
-			`SELECT EXISTS(SELECT 1 FROM reactions WHERE message_id = $1 AND emoji = $2 AND user_id = $3)`,
-			messageID, emoji, authorID,
-		).Scan(&selfStarred)
-		if selfStarred {
-			count--
-		}
-	}
-
-	if count < cfg.Threshold {
-		// Update existing entry star count if it exists.
-		h.Pool.Exec(ctx,
-			`UPDATE starboard_entries SET star_count = $3
-			 WHERE guild_id = $1 AND source_message_id = $2`,
-			guildID, messageID, count,
-		)
-		return
-	}
-
-	// Check NSFW.
-	if !cfg.NSFWAllowed {
-		var isNSFW bool
-		h.Pool.QueryRow(ctx,
-			`SELECT nsfw FROM channels WHERE id = $1`, channelID,
-		).Scan(&isNSFW)
-		if isNSFW {
-			return
-		}
-	}
-
-	// Check if already on starboard.
-	var existingID string
-	err = h.Pool.QueryRow(ctx,
-		`SELECT id FROM starboard_entries WHERE guild_id = $1 AND source_message_id = $2`,
-		guildID, messageID,
-	).Scan(&existingID)
-
-	if err == nil {
-		// Update star count.
-		h.Pool.Exec(ctx,
-			`UPDATE starboard_entries SET star_count = $2 WHERE id = $1`,
-			existingID, count,
-		)
-		return
-	}
-
-	// Get message author.
-	var authorID string
-	h.Pool.QueryRow(ctx,
-		`SELECT author_id FROM messages WHERE id = $1`, messageID,
-	).Scan(&authorID)
-
-	// Insert new starboard entry.
-	entryID := models.NewULID().String()
-	h.Pool.Exec(ctx,
-		`INSERT INTO starboard_entries (id, guild_id, source_message_id, source_channel_id,
-		     star_count, author_id, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, NOW())
-		 ON CONFLICT (guild_id, source_message_id) DO UPDATE SET star_count = EXCLUDED.star_count`,
-		entryID, guildID, messageID, channelID, count, authorID,
-	)
-
-	// Post notification in starboard channel.
-	var content *string
-	h.Pool.QueryRow(ctx,
-		`SELECT content FROM messages WHERE id = $1`, messageID,
-	).Scan(&content)
-
-	starMsg := fmt.Sprintf("**%d** %s | <#%s>\n", count, cfg.Emoji, channelID)
-	if content != nil {
-		starMsg += *content
-	}
-
-	starMsgID := models.NewULID().String()
-	h.Pool.Exec(ctx,
-		`INSERT INTO messages (id, channel_id, author_id, content, message_type, created_at)
-		 VALUES ($1, $2, $3, $4, 'default', NOW())`,
-		starMsgID, *cfg.ChannelID, authorID, starMsg,
-	)
-
-	// Update the entry with the starboard message ID.
-	h.Pool.Exec(ctx,
-		`UPDATE starboard_entries SET starboard_message_id = $2 WHERE id = $1`,
-		entryID, starMsgID,
-	)
-
-	h.EventBus.PublishChannelEvent(ctx, events.SubjectMessageCreate, "MESSAGE_CREATE", *cfg.ChannelID, map[string]interface{}{
-		"id":         starMsgID,
-		"channel_id": *cfg.ChannelID,
-		"author_id":  authorID,
-		"content":    starMsg,
-	})
-}
-
 // ============================================================
 // 7. Welcome Message Automation
 // ============================================================
@@ -1564,7 +1509,7 @@ func (h *Handler) HandleGetWelcomeConfig(w http.ResponseWriter, r *http.Request)
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -1612,7 +1557,7 @@ func (h *Handler) HandleUpdateWelcomeConfig(w http.ResponseWriter, r *http.Reque
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can update welcome config")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can update welcome config")
 		return
 	}
 
@@ -1664,7 +1609,8 @@ func (h *Handler) HandleUpdateWelcomeConfig(w http.ResponseWriter, r *http.Reque
 }
 
 // SendWelcomeMessage sends a welcome message when a user joins a guild.
-// Called by the guild member add handler or NATS subscriber.
+// Called by the guild member add handler or NATS subscriber. Nothing is sent
+// while the guild has join messages turned off.
 func (h *Handler) SendWelcomeMessage(ctx context.Context, guildID, userID string) {
 	var cfg WelcomeConfig
 	err := h.Pool.QueryRow(ctx,
@@ -1678,6 +1624,11 @@ func (h *Handler) SendWelcomeMessage(ctx context.Context, guildID, userID string
 	if err != nil || !cfg.Enabled {
 		return
 	}
+	var joinMessages bool
+	h.Pool.QueryRow(ctx, `SELECT system_join_messages FROM guilds WHERE id = $1`, guildID).Scan(&joinMessages)
+	if !joinMessages {
+		return
+	}
 
 	var username string
 	var guildName string
@@ -1731,7 +1682,7 @@ func (h *Handler) HandleGetAutoRoles(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -1778,7 +1729,7 @@ func (h *Handler) HandleCreateAutoRole(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can manage auto roles")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can manage auto roles")
 		return
 	}
 
@@ -1839,7 +1790,7 @@ func (h *Handler) HandleUpdateAutoRole(w http.ResponseWriter, r *http.Request) {
 	ruleID := chi.URLParam(r, "ruleID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can manage auto roles")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can manage auto roles")
 		return
 	}
 
@@ -1869,7 +1820,7 @@ func (h *Handler) HandleUpdateAutoRole(w http.ResponseWriter, r *http.Request) {
 	).Scan(&ar.ID, &ar.GuildID, &ar.RoleID, &ar.RuleType,
 		&ar.DelaySeconds, &ar.Enabled, &createdAt)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Auto-role rule not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Auto-role rule not found")
 		return
 	}
 	if err != nil {
@@ -1889,7 +1840,7 @@ func (h *Handler) HandleDeleteAutoRole(w http.ResponseWriter, r *http.Request) {
 	ruleID := chi.URLParam(r, "ruleID")
 
 	if !h.isGuildAdmin(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild admins can manage auto roles")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild admins can manage auto roles")
 		return
 	}
 
@@ -1902,7 +1853,7 @@ func (h *Handler) HandleDeleteAutoRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Auto-role rule not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Auto-role rule not found")
 		return
 	}
 
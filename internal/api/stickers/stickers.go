@@ -12,6 +12,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -91,7 +92,7 @@ func (h *Handler) HandleCreateGuildPack(w http.ResponseWriter, r *http.Request)
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.hasManageEmojis(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to manage stickers")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to manage stickers")
 		return
 	}
 
@@ -132,7 +133,7 @@ func (h *Handler) HandleGetGuildPacks(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isMember(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You are not a member of this guild")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You are not a member of this guild")
 		return
 	}
 
@@ -143,7 +144,7 @@ func (h *Handler) HandleGetGuildPacks(w http.ResponseWriter, r *http.Request) {
 		 WHERE sp.owner_type = 'guild' AND sp.owner_id = $1
 		 ORDER BY sp.created_at DESC`, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch sticker packs")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch sticker packs")
 		return
 	}
 	defer rows.Close()
@@ -183,7 +184,7 @@ func (h *Handler) HandleDeletePack(w http.ResponseWriter, r *http.Request) {
 	packID := chi.URLParam(r, "packID")
 
 	if !h.hasManageEmojis(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to manage stickers")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to manage stickers")
 		return
 	}
 
@@ -191,11 +192,11 @@ func (h *Handler) HandleDeletePack(w http.ResponseWriter, r *http.Request) {
 		`DELETE FROM sticker_packs WHERE id = $1 AND owner_type = 'guild' AND owner_id = $2`,
 		packID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete sticker pack")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete sticker pack")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Sticker pack not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Sticker pack not found")
 		return
 	}
 
@@ -210,7 +211,7 @@ func (h *Handler) HandleAddSticker(w http.ResponseWriter, r *http.Request) {
 	packID := chi.URLParam(r, "packID")
 
 	if !h.hasManageEmojis(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to manage stickers")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to manage stickers")
 		return
 	}
 
@@ -220,7 +221,7 @@ func (h *Handler) HandleAddSticker(w http.ResponseWriter, r *http.Request) {
 		`SELECT EXISTS(SELECT 1 FROM sticker_packs WHERE id = $1 AND owner_type = 'guild' AND owner_id = $2)`,
 		packID, guildID).Scan(&exists)
 	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Sticker pack not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Sticker pack not found")
 		return
 	}
 
@@ -266,7 +267,7 @@ func (h *Handler) HandleGetPackStickers(w http.ResponseWriter, r *http.Request)
 		`SELECT id, pack_id, name, description, tags, file_id, format, created_at
 		 FROM stickers WHERE pack_id = $1 ORDER BY created_at`, packID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch stickers")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch stickers")
 		return
 	}
 	defer rows.Close()
@@ -306,7 +307,7 @@ func (h *Handler) HandleDeleteSticker(w http.ResponseWriter, r *http.Request) {
 	stickerID := chi.URLParam(r, "stickerID")
 
 	if !h.hasManageEmojis(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to manage stickers")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to manage stickers")
 		return
 	}
 
@@ -316,18 +317,18 @@ func (h *Handler) HandleDeleteSticker(w http.ResponseWriter, r *http.Request) {
 		`SELECT EXISTS(SELECT 1 FROM sticker_packs WHERE id = $1 AND owner_type = 'guild' AND owner_id = $2)`,
 		packID, guildID).Scan(&exists)
 	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Sticker pack not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Sticker pack not found")
 		return
 	}
 
 	tag, err := h.Pool.Exec(r.Context(),
 		`DELETE FROM stickers WHERE id = $1 AND pack_id = $2`, stickerID, packID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to delete sticker")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to delete sticker")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Sticker not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Sticker not found")
 		return
 	}
 
@@ -363,7 +364,7 @@ func (h *Handler) HandleCreateUserPack(w http.ResponseWriter, r *http.Request) {
 		 VALUES ($1, $2, $3, 'user', $4, $5)`,
 		id, req.Name, req.Description, userID, now)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to create sticker pack")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to create sticker pack")
 		return
 	}
 
@@ -389,7 +390,7 @@ func (h *Handler) HandleGetUserPacks(w http.ResponseWriter, r *http.Request) {
 		 WHERE sp.owner_type = 'user' AND sp.owner_id = $1
 		 ORDER BY sp.created_at`, userID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch packs")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch packs")
 		return
 	}
 	defer rows.Close()
@@ -428,7 +429,7 @@ func (h *Handler) HandleEnableSharing(w http.ResponseWriter, r *http.Request) {
 	packID := chi.URLParam(r, "packID")
 
 	if !h.hasManageEmojis(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to manage stickers")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to manage stickers")
 		return
 	}
 
@@ -438,7 +439,7 @@ func (h *Handler) HandleEnableSharing(w http.ResponseWriter, r *http.Request) {
 		`SELECT EXISTS(SELECT 1 FROM sticker_packs WHERE id = $1 AND owner_type = 'guild' AND owner_id = $2)`,
 		packID, guildID).Scan(&exists)
 	if !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Sticker pack not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Sticker pack not found")
 		return
 	}
 
@@ -481,7 +482,7 @@ func (h *Handler) HandleDisableSharing(w http.ResponseWriter, r *http.Request) {
 	packID := chi.URLParam(r, "packID")
 
 	if !h.hasManageEmojis(r.Context(), guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to manage stickers")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to manage stickers")
 		return
 	}
 
@@ -490,11 +491,11 @@ func (h *Handler) HandleDisableSharing(w http.ResponseWriter, r *http.Request) {
 		 WHERE id = $1 AND owner_type = 'guild' AND owner_id = $2`,
 		packID, guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to disable sharing")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to disable sharing")
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Sticker pack not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Sticker pack not found")
 		return
 	}
 
@@ -517,7 +518,7 @@ func (h *Handler) HandleGetSharedPack(w http.ResponseWriter, r *http.Request) {
 		shareCode,
 	).Scan(&id, &name, &desc, &ownerType, &ownerID, &createdAt)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Shared sticker pack not found or sharing disabled")
+		apiutil.WriteCode(w, apierrors.NotFound, "Shared sticker pack not found or sharing disabled")
 		return
 	}
 
@@ -526,7 +527,7 @@ func (h *Handler) HandleGetSharedPack(w http.ResponseWriter, r *http.Request) {
 		`SELECT id, name, description, tags, file_id, format, created_at
 		 FROM stickers WHERE pack_id = $1 ORDER BY created_at`, id)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch stickers")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to fetch stickers")
 		return
 	}
 	defer rows.Close()
@@ -579,7 +580,7 @@ func (h *Handler) HandleClonePack(w http.ResponseWriter, r *http.Request) {
 		shareCode,
 	).Scan(&srcPackID, &srcName, &srcDesc)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Shared sticker pack not found or sharing disabled")
+		apiutil.WriteCode(w, apierrors.NotFound, "Shared sticker pack not found or sharing disabled")
 		return
 	}
 
@@ -608,7 +609,7 @@ func (h *Handler) HandleClonePack(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.Pool.Query(r.Context(),
 		`SELECT name, description, tags, file_id, format FROM stickers WHERE pack_id = $1`, srcPackID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read source stickers")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to read source stickers")
 		return
 	}
 	defer rows.Close()
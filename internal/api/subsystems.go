@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// subsystemRefresh is how long subsystem toggles are cached per node.
+const subsystemRefresh = 5 * time.Second
+
+// subsystemErrors are the error code and message returned while a subsystem
+// is switched off.
+var subsystemErrors = map[string][2]string{
+	models.SubsystemRegistration: {"registration_disabled", "Registration is temporarily disabled"},
+	models.SubsystemMessaging:    {"messaging_disabled", "Sending messages is temporarily disabled"},
+	models.SubsystemUploads:      {"uploads_disabled", "File uploads are temporarily disabled"},
+	models.SubsystemFederation:   {"federation_disabled", "Federation is temporarily disabled"},
+}
+
+// subsystemGate caches the operator's subsystem toggles so they can be
+// checked on hot paths.
+type subsystemGate struct {
+	pool   *pgxpool.Pool
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	disabled models.DisabledSubsystems
+	checked  time.Time
+}
+
+// current returns the cached toggles, reloading them when stale. On lookup
+// errors the last known toggles stay in effect.
+func (g *subsystemGate) current(ctx context.Context) models.DisabledSubsystems {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Since(g.checked) < subsystemRefresh {
+		return g.disabled
+	}
+	d, err := apiutil.LoadDisabledSubsystems(ctx, g.pool)
+	if err != nil {
+		g.logger.Warn("checking subsystem toggles failed", slog.String("error", err.Error()))
+	} else {
+		g.disabled = d
+	}
+	g.checked = time.Now()
+	return g.disabled
+}
+
+// SubsystemDisabled reports whether an operator has switched off the named
+// subsystem (one of the models.Subsystem* constants).
+func (s *Server) SubsystemDisabled(subsystem string) bool {
+	return s.subsystems.current(context.Background()).Disabled(subsystem)
+}
+
+// writeSubsystemDisabled answers a request for a switched-off subsystem.
+func writeSubsystemDisabled(w http.ResponseWriter, subsystem string, d models.DisabledSubsystems) {
+	e := subsystemErrors[subsystem]
+	msg := e[1]
+	if d.Reason != "" {
+		msg += ": " + d.Reason
+	}
+	WriteError(w, http.StatusServiceUnavailable, e[0], msg)
+}
+
+// requireSubsystem returns middleware that rejects requests with 503 while
+// the named subsystem is switched off.
+func (s *Server) requireSubsystem(subsystem string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d := s.subsystems.current(r.Context()); d.Disabled(subsystem) {
+				writeSubsystemDisabled(w, subsystem, d)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isFederationPath reports whether a request is federation traffic: peers
+// calling this instance, or the media proxy fetching from peers. Discovery
+// stays up so peers can still see the instance.
+func isFederationPath(path string) bool {
+	return strings.HasPrefix(path, "/federation/") ||
+		strings.HasPrefix(path, "/api/v1/federation/")
+}
+
+// federationToggle returns middleware that turns federation traffic away
+// while federation is switched off. Peers queue 5xx deliveries for retry.
+// It is global because the federation routes are mounted outside
+// registerRoutes.
+func (s *Server) federationToggle() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isFederationPath(r.URL.Path) {
+				if d := s.subsystems.current(r.Context()); d.Federation {
+					writeSubsystemDisabled(w, models.SubsystemFederation, d)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
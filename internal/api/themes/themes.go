@@ -16,6 +16,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -344,7 +345,7 @@ func (h *Handler) HandleDeleteSharedTheme(w http.ResponseWriter, r *http.Request
 		h.Pool.QueryRow(r.Context(), `SELECT flags FROM users WHERE id = $1`, userID).Scan(&flags)
 		const flagAdmin = 1 << 2
 		if flags&flagAdmin == 0 {
-			apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You can only delete your own themes")
+			apiutil.WriteCode(w, apierrors.Forbidden, "You can only delete your own themes")
 			return
 		}
 	}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/tokenleak"
+)
+
+// maxTokenLeakContent bounds the text one report may carry.
+const maxTokenLeakContent = 1 << 20
+
+type tokenLeakReportRequest struct {
+	Content string  `json:"content"` // text the credentials were found in
+	URL     *string `json:"url"`     // where it was found
+}
+
+// handleReportTokenLeak revokes the webhook URLs and bot tokens in text a
+// staff member found leaked, and alerts the guilds and bot owners affected.
+// POST /api/v1/admin/token-leaks
+func (s *Server) handleReportTokenLeak(w http.ResponseWriter, r *http.Request) {
+	s.revokeTokenLeaks(w, r, tokenleak.Report{
+		Source:     tokenleak.SourceStaff,
+		ReporterID: auth.UserIDFromContext(r.Context()),
+	})
+}
+
+// handleTokenLeakHook is handleReportTokenLeak for secret scanning services,
+// authenticated with the configured report secret instead of a session. It
+// is not found while no secret is configured.
+// POST /api/v1/token-leaks
+func (s *Server) handleTokenLeakHook(w http.ResponseWriter, r *http.Request) {
+	secret := s.Config.TokenLeaks.ReportSecret
+	if secret == "" {
+		WriteCode(w, apierrors.NotFound, "")
+		return
+	}
+	header := r.Header.Get("Authorization")
+	if len(header) < 8 || !strings.EqualFold(header[:7], "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimSpace(header[7:])), []byte(secret)) != 1 {
+		WriteCode(w, apierrors.Unauthorized, "Invalid report secret")
+		return
+	}
+	s.revokeTokenLeaks(w, r, tokenleak.Report{Source: tokenleak.SourceScanner})
+}
+
+func (s *Server) revokeTokenLeaks(w http.ResponseWriter, r *http.Request, rep tokenleak.Report) {
+	var req tokenLeakReportRequest
+	if !DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Content == "" {
+		WriteCode(w, apierrors.MissingFields, "content is required")
+		return
+	}
+	if len(req.Content) > maxTokenLeakContent {
+		WriteError(w, http.StatusRequestEntityTooLarge, "content_too_large", "content must be at most 1 MiB")
+		return
+	}
+	rep.URL = req.URL
+
+	responder := &tokenleak.Responder{
+		Pool:          s.DB.Pool,
+		EventBus:      s.EventBus,
+		Notifications: s.Notifications,
+		Logger:        s.Logger,
+	}
+	res, err := responder.Revoke(r.Context(), tokenleak.Scan(req.Content), rep)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to revoke leaked tokens", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,384 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- API Usage ---
+//
+// Authenticated requests are counted in memory per UTC day, user and bot
+// token, and added to api_usage_daily once a minute. Counts are per node;
+// the upsert sums them across nodes. Usage endpoints therefore trail live
+// traffic by up to one flush interval.
+
+const (
+	usageFlushInterval = 1 * time.Minute
+	defaultUsageDays   = 30
+	maxUsageDays       = 90
+)
+
+// usageKey identifies one rollup row. TokenID is empty for session requests.
+type usageKey struct {
+	Day     string
+	UserID  string
+	TokenID string
+}
+
+// usageCounts are the counters of one rollup row.
+type usageCounts struct {
+	Requests    int64 `json:"requests"`
+	Errors      int64 `json:"errors"`
+	RateLimited int64 `json:"rate_limited"`
+}
+
+func (c *usageCounts) add(o usageCounts) {
+	c.Requests += o.Requests
+	c.Errors += o.Errors
+	c.RateLimited += o.RateLimited
+}
+
+// usageRecorder accumulates request counts between flushes.
+type usageRecorder struct {
+	mu     sync.Mutex
+	counts map[usageKey]*usageCounts
+}
+
+func newUsageRecorder() *usageRecorder {
+	return &usageRecorder{counts: make(map[usageKey]*usageCounts)}
+}
+
+// record counts one request that finished with status. Responses of 400
+// and above count as errors; 429s also count as rate limited.
+func (u *usageRecorder) record(at time.Time, userID, tokenID string, status int) {
+	c := usageCounts{Requests: 1}
+	if status >= 400 {
+		c.Errors = 1
+	}
+	if status == http.StatusTooManyRequests {
+		c.RateLimited = 1
+	}
+	key := usageKey{Day: at.UTC().Format("2006-01-02"), UserID: userID, TokenID: tokenID}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if existing, ok := u.counts[key]; ok {
+		existing.add(c)
+		return
+	}
+	u.counts[key] = &c
+}
+
+// drain returns the counts recorded since the last drain and resets them.
+func (u *usageRecorder) drain() map[usageKey]usageCounts {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	out := make(map[usageKey]usageCounts, len(u.counts))
+	for k, c := range u.counts {
+		out[k] = *c
+	}
+	u.counts = make(map[usageKey]*usageCounts)
+	return out
+}
+
+// restore adds drained counts back, so a failed flush is retried with the
+// next one.
+func (u *usageRecorder) restore(counts map[usageKey]usageCounts) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for k, c := range counts {
+		if existing, ok := u.counts[k]; ok {
+			existing.add(c)
+			continue
+		}
+		c := c
+		u.counts[k] = &c
+	}
+}
+
+// trackAPIUsage counts each authenticated request against the caller and,
+// for bots, the token used. It must run after auth.RequireAuth and before
+// RateLimitGlobal, so that rejected requests are counted too.
+func (s *Server) trackAPIUsage() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := auth.UserIDFromContext(r.Context())
+			if userID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			s.usage.record(time.Now(), userID, auth.TokenIDFromContext(r.Context()), status)
+		})
+	}
+}
+
+// runUsageFlusher writes recorded usage every usageFlushInterval until stop
+// is closed.
+func (s *Server) runUsageFlusher(stop <-chan struct{}) {
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			s.flushUsage(ctx)
+			cancel()
+		}
+	}
+}
+
+// flushUsage adds the recorded counts to api_usage_daily. On failure the
+// counts are kept for the next flush.
+func (s *Server) flushUsage(ctx context.Context) {
+	counts := s.usage.drain()
+	if len(counts) == 0 {
+		return
+	}
+	batch := &pgx.Batch{}
+	for k, c := range counts {
+		batch.Queue(
+			`INSERT INTO api_usage_daily (day, user_id, token_id, requests, errors, rate_limited)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (day, user_id, token_id) DO UPDATE SET
+			     requests = api_usage_daily.requests + EXCLUDED.requests,
+			     errors = api_usage_daily.errors + EXCLUDED.errors,
+			     rate_limited = api_usage_daily.rate_limited + EXCLUDED.rate_limited`,
+			k.Day, k.UserID, k.TokenID, c.Requests, c.Errors, c.RateLimited)
+	}
+	if err := s.DB.Pool.SendBatch(ctx, batch).Close(); err != nil {
+		s.usage.restore(counts)
+		s.Logger.Warn("failed to flush API usage",
+			slog.Int("rows", len(counts)), slog.String("error", err.Error()))
+	}
+}
+
+// parseUsageDays reads the ?days= window, defaulting to 30 and capped at 90.
+func parseUsageDays(v string) int {
+	days, err := strconv.Atoi(v)
+	if err != nil || days < 1 {
+		return defaultUsageDays
+	}
+	if days > maxUsageDays {
+		return maxUsageDays
+	}
+	return days
+}
+
+// usageDay is one day of a caller's usage.
+type usageDay struct {
+	Day string `json:"day"`
+	usageCounts
+}
+
+// tokenUsage is one bot token's usage over the window.
+type tokenUsage struct {
+	TokenID    string     `json:"token_id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	usageCounts
+}
+
+// usageReport is the response of the usage endpoints.
+type usageReport struct {
+	UserID string       `json:"user_id"`
+	Days   int          `json:"days"`
+	Totals usageCounts  `json:"totals"`
+	Daily  []usageDay   `json:"daily"`
+	Tokens []tokenUsage `json:"tokens,omitempty"`
+}
+
+// loadUsageReport sums userID's usage per day over the last days days,
+// oldest first.
+func (s *Server) loadUsageReport(ctx context.Context, userID string, days int) (*usageReport, error) {
+	report := &usageReport{UserID: userID, Days: days, Daily: []usageDay{}}
+	rows, err := s.DB.Pool.Query(ctx,
+		`SELECT to_char(day, 'YYYY-MM-DD'), SUM(requests), SUM(errors), SUM(rate_limited)
+		 FROM api_usage_daily
+		 WHERE user_id = $1 AND day > CURRENT_DATE - $2::int
+		 GROUP BY day
+		 ORDER BY day`, userID, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d usageDay
+		if err := rows.Scan(&d.Day, &d.Requests, &d.Errors, &d.RateLimited); err != nil {
+			return nil, err
+		}
+		report.Totals.add(d.usageCounts)
+		report.Daily = append(report.Daily, d)
+	}
+	return report, rows.Err()
+}
+
+// handleGetMyAPIUsage returns the caller's daily API usage.
+// GET /api/v1/users/@me/usage?days=
+func (s *Server) handleGetMyAPIUsage(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	report, err := s.loadUsageReport(r.Context(), userID, parseUsageDays(r.URL.Query().Get("days")))
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to load API usage", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, report)
+}
+
+// handleGetBotAPIUsage returns a bot's daily API usage and the usage of
+// each of its tokens, for the bot's owner.
+// GET /api/v1/bots/{botID}/usage?days=
+func (s *Server) handleGetBotAPIUsage(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	botID := chi.URLParam(r, "botID")
+	days := parseUsageDays(r.URL.Query().Get("days"))
+
+	var ownerID *string
+	err := s.DB.Pool.QueryRow(r.Context(),
+		`SELECT bot_owner_id FROM users WHERE id = $1 AND flags & $2 != 0`,
+		botID, models.UserFlagBot).Scan(&ownerID)
+	if err == pgx.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "bot_not_found", "Bot not found")
+		return
+	}
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to get bot", err)
+		return
+	}
+	if ownerID == nil || *ownerID != userID {
+		WriteError(w, http.StatusForbidden, "not_owner", "You do not own this bot")
+		return
+	}
+
+	report, err := s.loadUsageReport(r.Context(), botID, days)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to load API usage", err)
+		return
+	}
+
+	// Revoked tokens drop out of the breakdown; their requests stay in the
+	// daily totals.
+	rows, err := s.DB.Pool.Query(r.Context(),
+		`SELECT t.id, t.name, t.scope, t.last_used_at,
+		        COALESCE(SUM(u.requests), 0), COALESCE(SUM(u.errors), 0), COALESCE(SUM(u.rate_limited), 0)
+		 FROM bot_tokens t
+		 LEFT JOIN api_usage_daily u
+		        ON u.user_id = t.bot_id AND u.token_id = t.id AND u.day > CURRENT_DATE - $2::int
+		 WHERE t.bot_id = $1
+		 GROUP BY t.id
+		 ORDER BY 5 DESC, t.created_at`, botID, days)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to load token usage", err)
+		return
+	}
+	defer rows.Close()
+	report.Tokens = []tokenUsage{}
+	for rows.Next() {
+		var t tokenUsage
+		if err := rows.Scan(&t.TokenID, &t.Name, &t.Scope, &t.LastUsedAt,
+			&t.Requests, &t.Errors, &t.RateLimited); err != nil {
+			InternalError(w, s.Logger, "Failed to read token usage", err)
+			return
+		}
+		report.Tokens = append(report.Tokens, t)
+	}
+
+	WriteJSON(w, http.StatusOK, report)
+}
+
+// usageSortColumns are the ?sort= values of the admin usage view.
+var usageSortColumns = map[string]string{
+	"requests":     "requests",
+	"errors":       "errors",
+	"rate_limited": "rate_limited",
+}
+
+// apiConsumer is one caller in the admin usage view.
+type apiConsumer struct {
+	UserID     string  `json:"user_id"`
+	Username   string  `json:"username"`
+	Bot        bool    `json:"bot"`
+	BotOwnerID *string `json:"bot_owner_id,omitempty"`
+	Tokens     int     `json:"tokens"`
+	usageCounts
+}
+
+// handleGetAPIUsageStats ranks the instance's API callers over the window,
+// by request count or by errors or rate-limited requests.
+// GET /api/v1/admin/api-usage?days=&sort=&limit=
+func (s *Server) handleGetAPIUsageStats(w http.ResponseWriter, r *http.Request) {
+	days := parseUsageDays(r.URL.Query().Get("days"))
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = "requests"
+	}
+	column, ok := usageSortColumns[sort]
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "invalid_sort", "Sort must be requests, errors or rate_limited")
+		return
+	}
+	limit, _ := parsePagination(r)
+
+	rows, err := s.DB.Pool.Query(r.Context(),
+		`SELECT u.id, u.username, u.flags & $3 != 0, u.bot_owner_id, a.tokens,
+		        a.requests, a.errors, a.rate_limited
+		 FROM (SELECT user_id,
+		              COUNT(DISTINCT NULLIF(token_id, '')) AS tokens,
+		              SUM(requests) AS requests, SUM(errors) AS errors, SUM(rate_limited) AS rate_limited
+		       FROM api_usage_daily
+		       WHERE day > CURRENT_DATE - $1::int
+		       GROUP BY user_id) a
+		 JOIN users u ON u.id = a.user_id
+		 ORDER BY a.`+column+` DESC, u.id
+		 LIMIT $2`, days, limit, models.UserFlagBot)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to load API usage", err)
+		return
+	}
+	defer rows.Close()
+
+	consumers := []apiConsumer{}
+	var totals usageCounts
+	for rows.Next() {
+		var c apiConsumer
+		if err := rows.Scan(&c.UserID, &c.Username, &c.Bot, &c.BotOwnerID, &c.Tokens,
+			&c.Requests, &c.Errors, &c.RateLimited); err != nil {
+			InternalError(w, s.Logger, "Failed to read API usage", err)
+			return
+		}
+		consumers = append(consumers, c)
+	}
+	if err := s.DB.Pool.QueryRow(r.Context(),
+		`SELECT COALESCE(SUM(requests), 0), COALESCE(SUM(errors), 0), COALESCE(SUM(rate_limited), 0)
+		 FROM api_usage_daily WHERE day > CURRENT_DATE - $1::int`, days,
+	).Scan(&totals.Requests, &totals.Errors, &totals.RateLimited); err != nil {
+		InternalError(w, s.Logger, "Failed to load API usage totals", err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"days":      days,
+		"sort":      sort,
+		"totals":    totals,
+		"consumers": consumers,
+	})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUsageRecorder(t *testing.T) {
+	u := newUsageRecorder()
+	day1 := time.Date(2026, 3, 5, 23, 59, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Minute)
+
+	u.record(day1, "bot1", "tok1", http.StatusOK)
+	u.record(day1, "bot1", "tok1", http.StatusNotFound)
+	u.record(day1, "bot1", "tok1", http.StatusTooManyRequests)
+	u.record(day1, "bot1", "tok2", http.StatusOK)
+	u.record(day2, "bot1", "tok1", http.StatusCreated)
+	u.record(day1, "user1", "", http.StatusOK)
+
+	got := u.drain()
+	want := map[usageKey]usageCounts{
+		{"2026-03-05", "bot1", "tok1"}: {Requests: 3, Errors: 2, RateLimited: 1},
+		{"2026-03-05", "bot1", "tok2"}: {Requests: 1},
+		{"2026-03-06", "bot1", "tok1"}: {Requests: 1},
+		{"2026-03-05", "user1", ""}:    {Requests: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("drain returned %d rows, want %d: %v", len(got), len(want), got)
+	}
+	for k, c := range want {
+		if got[k] != c {
+			t.Errorf("%v = %+v, want %+v", k, got[k], c)
+		}
+	}
+	if rest := u.drain(); len(rest) != 0 {
+		t.Errorf("second drain returned %v, want nothing", rest)
+	}
+
+	// A failed flush puts its counts back alongside newer ones.
+	u.record(day1, "user1", "", http.StatusInternalServerError)
+	u.restore(got)
+	key := usageKey{"2026-03-05", "user1", ""}
+	if c := u.drain()[key]; c != (usageCounts{Requests: 2, Errors: 1}) {
+		t.Errorf("after restore %v = %+v, want 2 requests and 1 error", key, c)
+	}
+}
+
+func TestParseUsageDays(t *testing.T) {
+	for in, want := range map[string]int{
+		"":    30,
+		"7":   7,
+		"0":   30,
+		"-3":  30,
+		"abc": 30,
+		"90":  90,
+		"365": 90,
+	} {
+		if got := parseUsageDays(in); got != want {
+			t.Errorf("parseUsageDays(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
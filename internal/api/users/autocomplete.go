@@ -0,0 +1,85 @@
+package users
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+)
+
+// UserSuggestion is a user offered by autocomplete.
+type UserSuggestion struct {
+	ID          string  `json:"id"`
+	Username    string  `json:"username"`
+	DisplayName *string `json:"display_name,omitempty"`
+	AvatarID    *string `json:"avatar_id,omitempty"`
+	InstanceID  string  `json:"instance_id"`
+	Friend      bool    `json:"friend"`
+}
+
+// HandleAutocompleteUsers suggests people the requester knows whose username
+// or display name starts with the query: friends, and members of guilds they
+// share whose dm_privacy setting lets strangers reach them. Users blocking or
+// blocked by the requester are never suggested. Friends come first.
+// GET /api/v1/users/autocomplete?q=<prefix>&limit=
+func (h *Handler) HandleAutocompleteUsers(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if !apiutil.RequireNonEmpty(w, "Query parameter 'q'", query) {
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`WITH named AS (
+		     SELECT id FROM users WHERE lower(username) LIKE $2
+		     UNION
+		     SELECT id FROM users WHERE lower(display_name) LIKE $2
+		 ),
+		 known AS (
+		     SELECT n.id, EXISTS (
+		         SELECT 1 FROM user_relationships f
+		         WHERE f.user_id = $1 AND f.target_id = n.id AND f.status = 'friend') AS friend
+		     FROM named n
+		     WHERE n.id <> $1
+		 )
+		 SELECT u.id, u.username, u.display_name, u.avatar_id, u.instance_id, k.friend
+		 FROM known k
+		 JOIN users u ON u.id = k.id
+		 LEFT JOIN user_settings us ON us.user_id = u.id
+		 WHERE (k.friend OR (
+		         COALESCE(us.settings->>'dm_privacy', 'everyone') = 'everyone'
+		         AND EXISTS (
+		             SELECT 1 FROM guild_members mine
+		             JOIN guild_members theirs ON theirs.guild_id = mine.guild_id
+		             WHERE mine.user_id = $1 AND theirs.user_id = u.id)))
+		   AND NOT EXISTS (
+		     SELECT 1 FROM user_relationships ur
+		     WHERE ur.status = 'blocked'
+		       AND ((ur.user_id = $1 AND ur.target_id = u.id) OR (ur.user_id = u.id AND ur.target_id = $1)))
+		 ORDER BY k.friend DESC,
+		          lower(COALESCE(u.display_name, u.username)) = $3 DESC,
+		          length(COALESCE(u.display_name, u.username)),
+		          lower(u.username)
+		 LIMIT $4`,
+		userID, apiutil.PrefixPattern(query), strings.ToLower(query), apiutil.AutocompleteLimit(r),
+	)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to autocomplete users", err)
+		return
+	}
+	defer rows.Close()
+
+	suggestions := make([]UserSuggestion, 0)
+	for rows.Next() {
+		var s UserSuggestion
+		if err := rows.Scan(&s.ID, &s.Username, &s.DisplayName, &s.AvatarID, &s.InstanceID, &s.Friend); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read user suggestions", err)
+			return
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, suggestions)
+}
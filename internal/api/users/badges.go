@@ -9,6 +9,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 )
 
@@ -21,6 +22,7 @@ const (
 	UserBadgeModerator     = 32  // 1 << 5
 	UserBadgeBot           = 64  // 1 << 6
 	UserBadgeVerified      = 128 // 1 << 7
+	UserBadgeSupporter     = 256 // 1 << 8
 )
 
 // badge represents a displayable badge on a user profile.
@@ -41,6 +43,7 @@ var badgeDefinitions = []struct {
 	{UserBadgeModerator, badge{ID: "moderator", Name: "Moderator", Icon: "hammer"}},
 	{UserBadgeBot, badge{ID: "bot", Name: "Bot", Icon: "robot"}},
 	{UserBadgeVerified, badge{ID: "verified", Name: "Verified", Icon: "check"}},
+	{UserBadgeSupporter, badge{ID: "supporter", Name: "Supporter", Icon: "gem"}},
 }
 
 // HandleGetUserBadges returns the badges for a user based on their flags bitfield.
@@ -58,7 +61,7 @@ func (h *Handler) HandleGetUserBadges(w http.ResponseWriter, r *http.Request) {
 	).Scan(&flags)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "User not found")
+			apiutil.WriteCode(w, apierrors.UserNotFound, "")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to get user badges", err)
@@ -14,6 +14,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
@@ -182,7 +183,7 @@ func (h *ChannelGroupHandler) HandleUpdateChannelGroup(w http.ResponseWriter, r
 		return
 	}
 	if ownerID != userID {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You can only update your own channel groups")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You can only update your own channel groups")
 		return
 	}
 
@@ -250,7 +251,7 @@ func (h *ChannelGroupHandler) HandleDeleteChannelGroup(w http.ResponseWriter, r
 		return
 	}
 	if ownerID != userID {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You can only delete your own channel groups")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You can only delete your own channel groups")
 		return
 	}
 
@@ -302,7 +303,7 @@ func (h *ChannelGroupHandler) HandleAddChannelToGroup(w http.ResponseWriter, r *
 		return
 	}
 	if ownerID != userID {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You can only modify your own channel groups")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You can only modify your own channel groups")
 		return
 	}
 
@@ -356,7 +357,7 @@ func (h *ChannelGroupHandler) HandleRemoveChannelFromGroup(w http.ResponseWriter
 		return
 	}
 	if ownerID != userID {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You can only modify your own channel groups")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You can only modify your own channel groups")
 		return
 	}
 
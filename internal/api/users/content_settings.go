@@ -0,0 +1,92 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- Content Settings ---
+//
+// Unlike the free-form client settings, these are enforced by the server:
+// NSFW channels are left out of the user's channel lists and READY, NSFW
+// media is refused or marked for blurring, and safe search drops NSFW
+// results. Seeing NSFW content at all needs the user to confirm their age
+// once.
+
+type updateContentSettingsRequest struct {
+	ShowNSFW   *bool   `json:"show_nsfw"`
+	NSFWMedia  *string `json:"nsfw_media"`
+	SafeSearch *bool   `json:"safe_search"`
+	ConfirmAge bool    `json:"confirm_age"`
+}
+
+// validateContentSettings checks settings and returns a message safe to
+// return to the caller, or "".
+func validateContentSettings(s models.UserContentSettings) string {
+	switch s.NSFWMedia {
+	case models.NSFWMediaShow, models.NSFWMediaBlur, models.NSFWMediaHide:
+	default:
+		return "nsfw_media must be show, blur or hide"
+	}
+	return ""
+}
+
+// HandleGetContentSettings returns the caller's NSFW filters.
+// GET /api/v1/users/@me/content-settings
+func (h *Handler) HandleGetContentSettings(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	apiutil.WriteJSON(w, http.StatusOK, apiutil.ContentSettings(r.Context(), h.Pool, userID))
+}
+
+// HandleUpdateContentSettings changes the caller's NSFW filters. Omitted
+// fields keep their current value. Turning show_nsfw on requires
+// confirm_age the first time.
+// PATCH /api/v1/users/@me/content-settings
+func (h *Handler) HandleUpdateContentSettings(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	var req updateContentSettingsRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	s := apiutil.ContentSettings(r.Context(), h.Pool, userID)
+	if req.ShowNSFW != nil {
+		s.ShowNSFW = *req.ShowNSFW
+	}
+	if req.NSFWMedia != nil {
+		s.NSFWMedia = *req.NSFWMedia
+	}
+	if req.SafeSearch != nil {
+		s.SafeSearch = *req.SafeSearch
+	}
+	if msg := validateContentSettings(s); msg != "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_content_settings", msg)
+		return
+	}
+	if s.ShowNSFW && s.AgeConfirmedAt == nil && !req.ConfirmAge {
+		apiutil.WriteError(w, http.StatusForbidden, "age_confirmation_required",
+			"Confirm that you are an adult to see NSFW content")
+		return
+	}
+
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO user_content_settings (user_id, show_nsfw, nsfw_media, safe_search, age_confirmed_at, updated_at)
+		 VALUES ($1, $2, $3, $4, CASE WHEN $5 THEN now() END, now())
+		 ON CONFLICT (user_id) DO UPDATE SET
+		     show_nsfw = $2, nsfw_media = $3, safe_search = $4,
+		     age_confirmed_at = COALESCE(user_content_settings.age_confirmed_at, EXCLUDED.age_confirmed_at),
+		     updated_at = now()
+		 RETURNING age_confirmed_at, updated_at`,
+		userID, s.ShowNSFW, s.NSFWMedia, s.SafeSearch, req.ConfirmAge,
+	).Scan(&s.AgeConfirmedAt, &s.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update content settings", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, s)
+}
@@ -0,0 +1,71 @@
+package users
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// allowDMOpen applies the DM open rate class before userID opens a new DM
+// or group DM with targetIDs. Targets who share a guild or a friendship with
+// the sender are always allowed; once the sender has opened too many DMs
+// with strangers this hour, the rest are refused, and refusals to many
+// different strangers escalate to a shadow quarantine. It writes the error
+// and returns false if the open is refused.
+func (h *Handler) allowDMOpen(w http.ResponseWriter, r *http.Request, userID string, targetIDs []string) bool {
+	var strangers []string
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT COALESCE(array_agg(t), '{}') FROM unnest($2::text[]) AS t
+		 WHERE NOT EXISTS (
+			SELECT 1 FROM user_relationships
+			WHERE user_id = $1 AND target_id = t AND status = 'friend'
+		 ) AND NOT EXISTS (
+			SELECT 1 FROM guild_members a
+			JOIN guild_members b ON b.guild_id = a.guild_id
+			WHERE a.user_id = $1 AND b.user_id = t
+		 )`,
+		userID, targetIDs,
+	).Scan(&strangers); err != nil {
+		// Lookup errors let the open through, like the other DM limits.
+		h.Logger.Warn("DM open relationship check failed", slog.String("error", err.Error()))
+		return true
+	}
+	if len(strangers) == 0 {
+		return true
+	}
+
+	retryAfter := apiutil.DMRateLimited(r.Context(), h.Cache, apiutil.DMClassOpen, userID)
+	if retryAfter == 0 {
+		return true
+	}
+
+	flagged := false
+	for _, id := range strangers {
+		if apiutil.TrackDMOpenRefused(userID, id) {
+			flagged = true
+		}
+	}
+	if flagged {
+		reason := "Tried to open DMs with many users sharing no guild or friendship"
+		created, err := apiutil.QuarantineUser(r.Context(), h.Pool, models.UserQuarantine{
+			UserID: userID,
+			Source: models.QuarantineSourceDMSpam,
+			Reason: &reason,
+		})
+		if err != nil {
+			h.Logger.Error("failed to quarantine DM spammer",
+				slog.String("user_id", userID), slog.String("error", err.Error()))
+		} else if created {
+			h.Logger.Warn("DM spam detected: user quarantined for opening DMs with many strangers",
+				slog.String("user_id", userID))
+		}
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	apiutil.WriteError(w, http.StatusTooManyRequests, "dm_open_limited",
+		"You have opened too many DMs with people you share no server or friendship with. Try again later.")
+	return false
+}
@@ -15,8 +15,10 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/permissions"
 )
 
@@ -101,12 +103,13 @@ type userExportRelation struct {
 
 // HandleExportUserData collects all data associated with the authenticated user
 // and returns it as a structured JSON document. This fulfills GDPR data
-// portability requirements. Rate limited to one export per 24 hours.
+// portability requirements. Rate limited to one export per 24 hours, and
+// unavailable while the user is under legal hold.
 // GET /api/v1/users/@me/export
 func (h *Handler) HandleExportUserData(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		apiutil.WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		apiutil.WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -114,12 +117,16 @@ func (h *Handler) HandleExportUserData(w http.ResponseWriter, r *http.Request) {
 	if lastExport, ok := exportCooldowns[userID]; ok {
 		if time.Since(lastExport) < exportCooldownDuration {
 			remaining := exportCooldownDuration - time.Since(lastExport)
-			apiutil.WriteError(w, http.StatusTooManyRequests, "rate_limited",
+			apiutil.WriteCode(w, apierrors.RateLimited,
 				fmt.Sprintf("Data export is limited to once per 24 hours. Try again in %s.", remaining.Round(time.Minute).String()))
 			return
 		}
 	}
 
+	if apiutil.RejectIfLegalHold(w, r, h.Pool, "user", userID) {
+		return
+	}
+
 	ctx := r.Context()
 	export := userDataExport{
 		ExportedAt: time.Now().UTC(),
@@ -238,12 +245,13 @@ type channelExportReaction struct {
 }
 
 // HandleExportChannelMessages exports all messages in a channel as JSON.
-// Requires MANAGE_CHANNELS permission on the channel's guild.
+// Requires EXPORT_MESSAGES and READ_HISTORY in the channel; DM channels need
+// the user to be a participant.
 // GET /api/v1/channels/{channelID}/export
 func (h *Handler) HandleExportChannelMessages(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		apiutil.WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		apiutil.WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -253,58 +261,107 @@ func (h *Handler) HandleExportChannelMessages(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if _, ok := h.exportableChannel(w, r, channelID, userID); !ok {
+		return
+	}
+
+	messages, err := h.collectChannelMessages(r.Context(), channelID, nil)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to export messages", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, channelMessageExport{
+		ChannelID:  channelID,
+		ExportedAt: time.Now().UTC(),
+		ExportedBy: userID,
+		Messages:   messages,
+	})
+}
+
+// exportableChannel checks that the user may export the channel and that its
+// data is not under legal hold, writing the error response when not. It
+// returns the channel's guild, nil for DMs.
+func (h *Handler) exportableChannel(w http.ResponseWriter, r *http.Request, channelID, userID string) (*string, bool) {
 	ctx := r.Context()
 
-	// Get channel to check guild membership and permissions.
 	var guildID *string
 	err := h.Pool.QueryRow(ctx, `SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
 	if err == pgx.ErrNoRows {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
-		return
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+		return nil, false
 	}
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to get channel", err)
-		return
+		return nil, false
 	}
 
-	// Check permissions: guild channels need MANAGE_CHANNELS, DM channels need to be a participant.
+	// Guild channels need EXPORT_MESSAGES and READ_HISTORY, which channel
+	// overrides can grant or deny; DM channels need the user to be a participant.
 	if guildID != nil {
-		if !h.hasChannelPermission(ctx, *guildID, channelID, userID, permissions.ManageChannels) {
-			apiutil.WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_CHANNELS permission to export messages")
-			return
+		perms, err := apiutil.MemberChannelPermissions(ctx, h.Pool, *guildID, channelID, userID)
+		if err != nil && err != pgx.ErrNoRows {
+			apiutil.InternalError(w, h.Logger, "Failed to check permissions", err)
+			return nil, false
 		}
-	} else {
-		// DM channel: check if user is a participant.
-		var isParticipant bool
-		h.Pool.QueryRow(ctx,
-			`SELECT EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2)`,
-			channelID, userID,
-		).Scan(&isParticipant)
-		if !isParticipant {
-			apiutil.WriteError(w, http.StatusForbidden, "not_participant", "You are not a participant in this channel")
-			return
+		if !permissions.HasAllPermissions(perms, permissions.ExportMessages, permissions.ReadHistory) {
+			apiutil.WriteCode(w, apierrors.MissingPermission,
+				"You need EXPORT_MESSAGES permission in this channel to export messages")
+			return nil, false
 		}
+		if apiutil.RejectIfLegalHold(w, r, h.Pool, "guild", *guildID) {
+			return nil, false
+		}
+		return guildID, true
 	}
 
-	export := channelMessageExport{
-		ChannelID:  channelID,
-		ExportedAt: time.Now().UTC(),
-		ExportedBy: userID,
+	var isParticipant bool
+	h.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2)`,
+		channelID, userID,
+	).Scan(&isParticipant)
+	if !isParticipant {
+		apiutil.WriteError(w, http.StatusForbidden, "not_participant", "You are not a participant in this channel")
+		return nil, false
+	}
+	if h.dmOnLegalHold(ctx, channelID) {
+		apiutil.WriteError(w, http.StatusLocked, "legal_hold", "This data is under legal hold and cannot be exported")
+		return nil, false
 	}
+	return nil, true
+}
+
+// dmOnLegalHold reports whether anyone in a DM channel is under legal hold,
+// which freezes the conversation along with their data. Lookup errors count
+// as held.
+func (h *Handler) dmOnLegalHold(ctx context.Context, channelID string) bool {
+	var held bool
+	err := h.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM channel_recipients cr
+		 JOIN legal_holds lh ON lh.target_type = 'user' AND lh.target_id = cr.user_id
+		 WHERE cr.channel_id = $1 AND lh.released_at IS NULL)`,
+		channelID,
+	).Scan(&held)
+	return err != nil || held
+}
 
+// collectChannelMessages loads a channel's messages, oldest first, with their
+// attachments and reactions. A non-nil until leaves out later messages;
+// soft-deleted and quarantined messages are always left out.
+func (h *Handler) collectChannelMessages(ctx context.Context, channelID string, until *time.Time) ([]channelExportMessage, error) {
 	// Fetch all messages with author usernames.
 	rows, err := h.Pool.Query(ctx,
 		`SELECT m.id, m.author_id, COALESCE(u.display_name, u.username), m.content,
 		        m.message_type, m.edited_at, m.created_at
 		 FROM messages m
 		 LEFT JOIN users u ON u.id = m.author_id
-		 WHERE m.channel_id = $1
+		 WHERE m.channel_id = $1 AND ($2::timestamptz IS NULL OR m.created_at <= $2)
+		   AND m.flags & $3 = 0
 		 ORDER BY m.created_at ASC`,
-		channelID,
+		channelID, until, models.MessageFlagDeleted|models.MessageFlagQuarantined,
 	)
 	if err != nil {
-		apiutil.InternalError(w, h.Logger, "Failed to export messages", err)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -316,8 +373,7 @@ func (h *Handler) HandleExportChannelMessages(w http.ResponseWriter, r *http.Req
 		var createdAt time.Time
 		if err := rows.Scan(&msg.ID, &msg.AuthorID, &msg.AuthorName, &msg.Content,
 			&msg.MessageType, &editedAt, &createdAt); err != nil {
-			apiutil.InternalError(w, h.Logger, "Failed to read messages", err)
-			return
+			return nil, err
 		}
 		msg.CreatedAt = createdAt.UTC().Format(time.RFC3339)
 		if editedAt != nil {
@@ -327,6 +383,7 @@ func (h *Handler) HandleExportChannelMessages(w http.ResponseWriter, r *http.Req
 		messageIDs = append(messageIDs, msg.ID)
 		messages = append(messages, msg)
 	}
+	rows.Close()
 
 	// Batch-load attachments for all messages.
 	if len(messageIDs) > 0 {
@@ -387,8 +444,7 @@ func (h *Handler) HandleExportChannelMessages(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	export.Messages = messages
-	apiutil.WriteJSON(w, http.StatusOK, export)
+	return messages, nil
 }
 
 // --- Account Migration Export/Import ---
@@ -419,7 +475,10 @@ type accountExportProfile struct {
 func (h *Handler) HandleExportAccount(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		apiutil.WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		apiutil.WriteCode(w, apierrors.Unauthorized, "")
+		return
+	}
+	if apiutil.RejectIfLegalHold(w, r, h.Pool, "user", userID) {
 		return
 	}
 
@@ -491,7 +550,7 @@ type importAccountProfile struct {
 func (h *Handler) HandleImportAccount(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		apiutil.WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		apiutil.WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -789,53 +848,3 @@ func (h *Handler) collectRelationships(ctx context.Context, userID string) ([]us
 	}
 	return rels, nil
 }
-
-// hasChannelPermission checks if a user has a given permission on a channel's guild.
-// For guild channels, it delegates to the permission computation logic.
-func (h *Handler) hasChannelPermission(ctx context.Context, guildID, channelID, userID string, perm uint64) bool {
-	// Owner has all permissions.
-	var ownerID string
-	if err := h.Pool.QueryRow(ctx, `SELECT owner_id FROM guilds WHERE id = $1`, guildID).Scan(&ownerID); err != nil {
-		return false
-	}
-	if userID == ownerID {
-		return true
-	}
-
-	// Check admin flag on user.
-	var userFlags int
-	h.Pool.QueryRow(ctx, `SELECT flags FROM users WHERE id = $1`, userID).Scan(&userFlags)
-	if userFlags&4 != 0 { // UserFlagAdmin = 1 << 2
-		return true
-	}
-
-	// Get guild default permissions.
-	var defaultPerms int64
-	h.Pool.QueryRow(ctx, `SELECT default_permissions FROM guilds WHERE id = $1`, guildID).Scan(&defaultPerms)
-	computedPerms := uint64(defaultPerms)
-
-	// Apply member's role permissions.
-	rows, _ := h.Pool.Query(ctx,
-		`SELECT r.permissions_allow, r.permissions_deny
-		 FROM roles r
-		 JOIN member_roles mr ON r.id = mr.role_id
-		 WHERE mr.guild_id = $1 AND mr.user_id = $2
-		 ORDER BY r.position DESC`,
-		guildID, userID,
-	)
-	if rows != nil {
-		defer rows.Close()
-		for rows.Next() {
-			var allow, deny int64
-			rows.Scan(&allow, &deny)
-			computedPerms |= uint64(allow)
-			computedPerms &^= uint64(deny)
-		}
-	}
-
-	if computedPerms&permissions.Administrator != 0 {
-		return true
-	}
-
-	return computedPerms&perm != 0
-}
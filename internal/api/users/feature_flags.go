@@ -0,0 +1,32 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+)
+
+// HandleGetFeatureFlags returns the feature flags on for the caller, also
+// sent in READY. Clients fetch it again on FEATURE_FLAGS_UPDATE.
+// GET /api/v1/users/@me/feature-flags
+func (h *Handler) HandleGetFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT guild_id FROM guild_members WHERE user_id = $1`, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get feature flags", err)
+		return
+	}
+	defer rows.Close()
+	var guildIDs []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			guildIDs = append(guildIDs, id)
+		}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, h.FeatureFlags.ForUser(r.Context(), userID, guildIDs))
+}
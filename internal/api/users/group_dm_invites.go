@@ -0,0 +1,130 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// errGroupDMFull is returned when accepting an invite to a full group DM.
+var errGroupDMFull = errors.New("group DM is full")
+
+// HandleListGroupDMInvites returns the caller's pending group DM invites,
+// newest first.
+// GET /api/v1/users/@me/group-dm-invites
+func (h *Handler) HandleListGroupDMInvites(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT i.id, i.channel_id, c.name, i.inviter_id, i.target_id, i.created_at,
+		        u.id, u.instance_id, u.username, u.display_name, u.avatar_id
+		 FROM group_dm_invites i
+		 JOIN channels c ON c.id = i.channel_id
+		 JOIN users u ON u.id = i.inviter_id
+		 WHERE i.target_id = $1
+		 ORDER BY i.created_at DESC
+		 LIMIT 100`, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get group DM invites", err)
+		return
+	}
+	defer rows.Close()
+
+	invites := make([]models.GroupDMInvite, 0)
+	for rows.Next() {
+		var inv models.GroupDMInvite
+		var u models.User
+		if err := rows.Scan(&inv.ID, &inv.ChannelID, &inv.ChannelName, &inv.InviterID, &inv.TargetID, &inv.CreatedAt,
+			&u.ID, &u.InstanceID, &u.Username, &u.DisplayName, &u.AvatarID); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read group DM invites", err)
+			return
+		}
+		inv.Inviter = &u
+		invites = append(invites, inv)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, invites)
+}
+
+// HandleAcceptGroupDMInvite joins the group DM the caller was invited to
+// and drops the invite.
+// POST /api/v1/users/@me/group-dm-invites/{inviteID}/accept
+func (h *Handler) HandleAcceptGroupDMInvite(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	inviteID := chi.URLParam(r, "inviteID")
+
+	var channelID string
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(r.Context(),
+			`DELETE FROM group_dm_invites WHERE id = $1 AND target_id = $2 RETURNING channel_id`,
+			inviteID, userID,
+		).Scan(&channelID); err != nil {
+			return err
+		}
+
+		// Lock the channel so concurrent joins cannot push it past the cap.
+		var count int
+		if err := tx.QueryRow(r.Context(),
+			`SELECT (SELECT COUNT(*) FROM channel_recipients WHERE channel_id = c.id)
+			 FROM channels c WHERE c.id = $1 FOR UPDATE`, channelID,
+		).Scan(&count); err != nil {
+			return err
+		}
+		if count >= 10 {
+			return errGroupDMFull
+		}
+		_, err := tx.Exec(r.Context(),
+			`INSERT INTO channel_recipients (channel_id, user_id, joined_at) VALUES ($1, $2, now())
+			 ON CONFLICT DO NOTHING`,
+			channelID, userID)
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		apiutil.WriteError(w, http.StatusNotFound, "invite_not_found", "Group DM invite not found")
+		return
+	}
+	if errors.Is(err, errGroupDMFull) {
+		apiutil.WriteError(w, http.StatusBadRequest, "group_full", "Group DM cannot have more than 10 members")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to accept group DM invite", err)
+		return
+	}
+
+	channel, err := h.getChannel(r.Context(), channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get group DM", err)
+		return
+	}
+	h.EventBus.PublishChannelEvent(r.Context(), events.SubjectChannelUpdate, "CHANNEL_UPDATE", channelID, channel)
+
+	apiutil.WriteJSON(w, http.StatusOK, channel)
+}
+
+// HandleDeclineGroupDMInvite drops a pending group DM invite. The inviter
+// is not told.
+// DELETE /api/v1/users/@me/group-dm-invites/{inviteID}
+func (h *Handler) HandleDeclineGroupDMInvite(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM group_dm_invites WHERE id = $1 AND target_id = $2`,
+		chi.URLParam(r, "inviteID"), userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to decline group DM invite", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "invite_not_found", "Group DM invite not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
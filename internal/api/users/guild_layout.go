@@ -0,0 +1,449 @@
+// Package users — guild layout handlers.
+// A user's guild layout is their own order of the guild list, the folders
+// they group guilds into and their favorite channels. It is stored on the
+// server so every device shows the same sidebar, and is included in READY.
+// Each change sends GUILD_LAYOUT_UPDATE with the whole layout to the user's
+// sessions.
+// Mounted under /api/v1/users/@me/guild-layout, /guild-folders and /favorites.
+package users
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+const (
+	maxGuildFolders    = 50
+	maxFolderNameRunes = 20 // guild_folders.name CHECK constraint
+	maxFavorites       = 100
+)
+
+type createGuildFolderRequest struct {
+	Name     string   `json:"name"`
+	Color    *string  `json:"color"`
+	GuildIDs []string `json:"guild_ids"`
+}
+
+type updateGuildFolderRequest struct {
+	Name     *string `json:"name"`
+	Color    *string `json:"color"`
+	Position *int    `json:"position"`
+}
+
+type guildIDsRequest struct {
+	GuildIDs []string `json:"guild_ids"`
+}
+
+type channelIDsRequest struct {
+	ChannelIDs []string `json:"channel_ids"`
+}
+
+// HandleGetGuildLayout returns the user's guild positions, guild folders and
+// favorite channels.
+// GET /api/v1/users/@me/guild-layout
+func (h *Handler) HandleGetGuildLayout(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	layout, err := apiutil.LoadGuildLayout(r.Context(), h.Pool, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get guild layout", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, layout)
+}
+
+// HandleCreateGuildFolder creates a guild folder at the end of the user's
+// folders, moving the given guilds into it.
+// POST /api/v1/users/@me/guild-folders
+func (h *Handler) HandleCreateGuildFolder(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	var req createGuildFolderRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if !validFolderName(w, req.Name) || !validFolderColor(w, req.Color) {
+		return
+	}
+	if len(req.GuildIDs) > 200 {
+		apiutil.WriteError(w, http.StatusBadRequest, "too_many", "Too many guilds")
+		return
+	}
+
+	var count int
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM guild_folders WHERE user_id = $1`, userID,
+	).Scan(&count); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create guild folder", err)
+		return
+	}
+	if count >= maxGuildFolders {
+		apiutil.WriteError(w, http.StatusBadRequest, "folder_limit", "You can have at most 50 guild folders")
+		return
+	}
+
+	folderID := models.NewULID().String()
+	if err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO guild_folders (id, user_id, name, position, color)
+			 VALUES ($1, $2, $3, $4, $5)`,
+			folderID, userID, req.Name, count, req.Color); err != nil {
+			return err
+		}
+		return setFolderGuilds(r.Context(), tx, userID, folderID, req.GuildIDs)
+	}); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create guild folder", err)
+		return
+	}
+
+	h.writeFolder(w, r, userID, folderID, http.StatusCreated)
+}
+
+// HandleUpdateGuildFolder renames, recolors or moves a guild folder.
+// PATCH /api/v1/users/@me/guild-folders/{folderID}
+func (h *Handler) HandleUpdateGuildFolder(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	folderID := chi.URLParam(r, "folderID")
+
+	var req updateGuildFolderRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if req.Name != nil && !validFolderName(w, *req.Name) {
+		return
+	}
+	if !validFolderColor(w, req.Color) {
+		return
+	}
+
+	// An empty color clears it.
+	var clearColor bool
+	if req.Color != nil && *req.Color == "" {
+		clearColor = true
+		req.Color = nil
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE guild_folders SET
+		     name = COALESCE($3, name),
+		     color = CASE WHEN $4 THEN NULL ELSE COALESCE($5, color) END,
+		     position = COALESCE($6, position)
+		 WHERE id = $1 AND user_id = $2`,
+		folderID, userID, req.Name, clearColor, req.Color, req.Position)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update guild folder", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "folder_not_found", "Guild folder not found")
+		return
+	}
+
+	h.writeFolder(w, r, userID, folderID, http.StatusOK)
+}
+
+// HandleSetGuildFolderGuilds replaces the guilds in a folder, in the given
+// order. Guilds left out of the list move back to the top level.
+// PUT /api/v1/users/@me/guild-folders/{folderID}/guilds
+func (h *Handler) HandleSetGuildFolderGuilds(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	folderID := chi.URLParam(r, "folderID")
+
+	var req guildIDsRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.GuildIDs) > 200 {
+		apiutil.WriteError(w, http.StatusBadRequest, "too_many", "Too many guilds")
+		return
+	}
+
+	errFolderNotFound := errors.New("folder not found")
+	if err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		var id string
+		err := tx.QueryRow(r.Context(),
+			`SELECT id FROM guild_folders WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+			folderID, userID).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errFolderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return setFolderGuilds(r.Context(), tx, userID, folderID, req.GuildIDs)
+	}); err != nil {
+		if errors.Is(err, errFolderNotFound) {
+			apiutil.WriteError(w, http.StatusNotFound, "folder_not_found", "Guild folder not found")
+			return
+		}
+		apiutil.InternalError(w, h.Logger, "Failed to update guild folder", err)
+		return
+	}
+
+	h.writeFolder(w, r, userID, folderID, http.StatusOK)
+}
+
+// HandleDeleteGuildFolder deletes a guild folder. Its guilds stay in place
+// at the top level.
+// DELETE /api/v1/users/@me/guild-folders/{folderID}
+func (h *Handler) HandleDeleteGuildFolder(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	folderID := chi.URLParam(r, "folderID")
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM guild_folders WHERE id = $1 AND user_id = $2`, folderID, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to delete guild folder", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "folder_not_found", "Guild folder not found")
+		return
+	}
+
+	h.publishGuildLayout(r.Context(), userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSetFavorites replaces the user's favorite channels with the given
+// list, in order.
+// PUT /api/v1/users/@me/favorites
+func (h *Handler) HandleSetFavorites(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	var req channelIDsRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.ChannelIDs) > maxFavorites {
+		apiutil.WriteError(w, http.StatusBadRequest, "favorite_limit", "You can have at most 100 favorite channels")
+		return
+	}
+	seen := make(map[string]bool, len(req.ChannelIDs))
+	channelIDs := make([]string, 0, len(req.ChannelIDs))
+	for _, id := range req.ChannelIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ok, err := h.canViewChannel(r.Context(), userID, id)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to update favorites", err)
+			return
+		}
+		if !ok {
+			apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+			return
+		}
+		channelIDs = append(channelIDs, id)
+	}
+
+	if err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`DELETE FROM user_channel_favorites WHERE user_id = $1 AND NOT (channel_id = ANY($2))`,
+			userID, channelIDs); err != nil {
+			return err
+		}
+		_, err := tx.Exec(r.Context(),
+			`INSERT INTO user_channel_favorites (user_id, channel_id, position)
+			 SELECT $1, id, ord - 1 FROM unnest($2::text[]) WITH ORDINALITY AS t(id, ord)
+			 ON CONFLICT (user_id, channel_id) DO UPDATE SET position = EXCLUDED.position`,
+			userID, channelIDs)
+		return err
+	}); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update favorites", err)
+		return
+	}
+
+	h.writeLayout(w, r, userID)
+}
+
+// HandleAddFavorite adds a channel to the end of the user's favorites. Adding
+// a channel that is already a favorite leaves it where it is.
+// PUT /api/v1/users/@me/favorites/{channelID}
+func (h *Handler) HandleAddFavorite(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	ok, err := h.canViewChannel(r.Context(), userID, channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to add favorite", err)
+		return
+	}
+	if !ok {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+		return
+	}
+
+	var count int
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM user_channel_favorites WHERE user_id = $1`, userID,
+	).Scan(&count); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to add favorite", err)
+		return
+	}
+	if count >= maxFavorites {
+		apiutil.WriteError(w, http.StatusBadRequest, "favorite_limit", "You can have at most 100 favorite channels")
+		return
+	}
+
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO user_channel_favorites (user_id, channel_id, position)
+		 SELECT $1, $2, COALESCE(MAX(position) + 1, 0) FROM user_channel_favorites WHERE user_id = $1
+		 ON CONFLICT (user_id, channel_id) DO NOTHING`,
+		userID, channelID); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to add favorite", err)
+		return
+	}
+
+	h.publishGuildLayout(r.Context(), userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRemoveFavorite removes a channel from the user's favorites.
+// DELETE /api/v1/users/@me/favorites/{channelID}
+func (h *Handler) HandleRemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`DELETE FROM user_channel_favorites WHERE user_id = $1 AND channel_id = $2`,
+		userID, channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to remove favorite", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "favorite_not_found", "Channel is not a favorite")
+		return
+	}
+
+	h.publishGuildLayout(r.Context(), userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setFolderGuilds makes guildIDs the contents of a folder, in order. Guilds
+// the user is not a member of are skipped, and guilds previously in the
+// folder but not listed move back to the top level. A guild can be in one
+// folder only, so listed guilds leave any other folder.
+func setFolderGuilds(ctx context.Context, tx pgx.Tx, userID, folderID string, guildIDs []string) error {
+	if guildIDs == nil {
+		guildIDs = []string{}
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE user_guild_positions SET folder_id = NULL, folder_position = 0
+		 WHERE user_id = $1 AND folder_id = $2 AND NOT (guild_id = ANY($3))`,
+		userID, folderID, guildIDs); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx,
+		`INSERT INTO user_guild_positions (user_id, guild_id, position, folder_id, folder_position)
+		 SELECT $1, t.id, 0, $2, MIN(t.ord) - 1
+		 FROM unnest($3::text[]) WITH ORDINALITY AS t(id, ord)
+		 JOIN guild_members gm ON gm.guild_id = t.id AND gm.user_id = $1
+		 GROUP BY t.id
+		 ON CONFLICT (user_id, guild_id) DO UPDATE
+		     SET folder_id = EXCLUDED.folder_id, folder_position = EXCLUDED.folder_position`,
+		userID, folderID, guildIDs)
+	return err
+}
+
+// canViewChannel reports whether the user can see a channel: a guild channel
+// they have View Channel in, or a DM or group they are a recipient of.
+func (h *Handler) canViewChannel(ctx context.Context, userID, channelID string) (bool, error) {
+	var guildID *string
+	err := h.Pool.QueryRow(ctx,
+		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if guildID == nil {
+		var recipient bool
+		err := h.Pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2)`,
+			channelID, userID).Scan(&recipient)
+		return recipient, err
+	}
+
+	perms, err := apiutil.MemberChannelPermissions(ctx, h.Pool, *guildID, channelID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return perms&permissions.ViewChannel != 0, nil
+}
+
+// publishGuildLayout sends GUILD_LAYOUT_UPDATE after a change that has been
+// committed; a failure is only logged, since the change itself succeeded.
+func (h *Handler) publishGuildLayout(ctx context.Context, userID string) {
+	if _, err := apiutil.PublishGuildLayout(ctx, h.Pool, h.EventBus, userID); err != nil {
+		h.Logger.Warn("failed to publish guild layout",
+			slog.String("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// writeLayout publishes the user's layout and writes it as the response.
+func (h *Handler) writeLayout(w http.ResponseWriter, r *http.Request, userID string) {
+	layout, err := apiutil.PublishGuildLayout(r.Context(), h.Pool, h.EventBus, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get guild layout", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, layout)
+}
+
+// writeFolder publishes the user's layout and writes one of its folders as
+// the response.
+func (h *Handler) writeFolder(w http.ResponseWriter, r *http.Request, userID, folderID string, status int) {
+	layout, err := apiutil.PublishGuildLayout(r.Context(), h.Pool, h.EventBus, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get guild layout", err)
+		return
+	}
+	for _, f := range layout.Folders {
+		if f.ID == folderID {
+			apiutil.WriteJSON(w, status, f)
+			return
+		}
+	}
+	apiutil.WriteError(w, http.StatusNotFound, "folder_not_found", "Guild folder not found")
+}
+
+func validFolderName(w http.ResponseWriter, name string) bool {
+	if !apiutil.RequireNonEmpty(w, "Folder name", name) {
+		return false
+	}
+	if utf8.RuneCountInString(name) > maxFolderNameRunes {
+		apiutil.WriteError(w, http.StatusBadRequest, "name_too_long", "Folder name must be at most 20 characters")
+		return false
+	}
+	return true
+}
+
+func validFolderColor(w http.ResponseWriter, color *string) bool {
+	if color != nil && len(*color) > 7 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_color", "Color must be a valid hex color (e.g. #ff0000)")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/openapi"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// selfGuildPage documents the page envelope of GET /users/@me/guilds.
+type selfGuildPage struct {
+	Data       []models.SelfGuild `json:"data"`
+	NextCursor *string            `json:"next_cursor"`
+}
+
+// Operations describes the request and response bodies of the main user
+// routes for the OpenAPI document.
+var Operations = []openapi.Operation{
+	{Method: "GET", Path: "/users/@me", Summary: "Get the current user", Response: models.SelfUser{}},
+	{Method: "PATCH", Path: "/users/@me", Summary: "Update the current user", Request: updateSelfRequest{}, Response: models.SelfUser{}},
+	{Method: "GET", Path: "/users/@me/guilds", Summary: "List the current user's guilds with unread counts", Response: selfGuildPage{}},
+	{Method: "POST", Path: "/users/@me/ack", Summary: "Mark every channel and DM as read", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/users/@me/guild-layout", Summary: "Get the current user's guild order, folders and favorites", Response: models.GuildLayout{}},
+	{Method: "POST", Path: "/users/@me/guild-folders", Summary: "Create a guild folder", Request: createGuildFolderRequest{}, Response: models.GuildFolder{}, Status: http.StatusCreated},
+	{Method: "PATCH", Path: "/users/@me/guild-folders/{folderID}", Summary: "Update a guild folder", Request: updateGuildFolderRequest{}, Response: models.GuildFolder{}},
+	{Method: "PUT", Path: "/users/@me/guild-folders/{folderID}/guilds", Summary: "Set the guilds in a folder", Request: guildIDsRequest{}, Response: models.GuildFolder{}},
+	{Method: "DELETE", Path: "/users/@me/guild-folders/{folderID}", Summary: "Delete a guild folder", Status: http.StatusNoContent},
+	{Method: "PUT", Path: "/users/@me/favorites", Summary: "Replace the current user's favorite channels", Request: channelIDsRequest{}, Response: models.GuildLayout{}},
+	{Method: "PUT", Path: "/users/@me/favorites/{channelID}", Summary: "Add a favorite channel", Status: http.StatusNoContent},
+	{Method: "DELETE", Path: "/users/@me/favorites/{channelID}", Summary: "Remove a favorite channel", Status: http.StatusNoContent},
+	{Method: "GET", Path: "/users/{userID}", Summary: "Get a user", Response: models.User{}},
+}
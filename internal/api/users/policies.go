@@ -0,0 +1,131 @@
+// Package users — instance terms of service and rules. Users acknowledge the
+// current version of each policy; until they do, the API middleware only lets
+// them read and acknowledge the policies.
+package users
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// currentPoliciesSQL selects the latest version of each policy kind, with the
+// time $1 acknowledged it or a later version that still counts.
+const currentPoliciesSQL = `SELECT DISTINCT ON (p.kind) p.id, p.kind, p.version, p.title, p.content,
+	        p.requires_acknowledgment, p.published_by, p.published_at,
+	        (SELECT MAX(a.acknowledged_at) FROM instance_policy_acknowledgments a
+	         JOIN instance_policies ap ON ap.id = a.policy_id
+	         WHERE a.user_id = $1 AND ap.kind = p.kind
+	           AND ap.version >= COALESCE((SELECT MAX(version) FROM instance_policies
+	                                       WHERE kind = p.kind AND requires_acknowledgment), 0))
+	 FROM instance_policies p
+	 ORDER BY p.kind, p.version DESC`
+
+// loadCurrentPolicies returns the current policies as seen by userID, which
+// may be empty for anonymous callers.
+func (h *Handler) loadCurrentPolicies(r *http.Request, userID string) ([]models.InstancePolicy, error) {
+	rows, err := h.Pool.Query(r.Context(), currentPoliciesSQL, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []models.InstancePolicy{}
+	for rows.Next() {
+		var p models.InstancePolicy
+		if err := rows.Scan(&p.ID, &p.Kind, &p.Version, &p.Title, &p.Content,
+			&p.RequiresAcknowledgment, &p.PublishedBy, &p.PublishedAt, &p.AcknowledgedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// HandleGetCurrentPolicies returns the current terms of service and rules,
+// for showing before sign-up.
+// GET /api/v1/policies
+func (h *Handler) HandleGetCurrentPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.loadCurrentPolicies(r, "")
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get policies", err)
+		return
+	}
+	apiutil.WriteJSON(w, http.StatusOK, policies)
+}
+
+// HandleGetSelfPolicies returns the current policies with the user's
+// acknowledgment of each, and the IDs of those still to be acknowledged.
+// GET /api/v1/users/@me/policies
+func (h *Handler) HandleGetSelfPolicies(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	policies, err := h.loadCurrentPolicies(r, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get policies", err)
+		return
+	}
+	pending, err := apiutil.PendingPolicies(r.Context(), h.Pool, h.Cache, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get pending policies", err)
+		return
+	}
+	if pending == nil {
+		pending = []string{}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"policies": policies,
+		"pending":  pending,
+	})
+}
+
+// HandleAcknowledgePolicies records the user's acknowledgment of policies.
+// Only the current version of a policy can be acknowledged.
+// POST /api/v1/users/@me/policies/acknowledge
+func (h *Handler) HandleAcknowledgePolicies(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	var req struct {
+		PolicyIDs []string `json:"policy_ids"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.PolicyIDs = dedupe(req.PolicyIDs)
+	if len(req.PolicyIDs) == 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "missing_policies", "policy_ids is required")
+		return
+	}
+
+	var current int
+	h.Pool.QueryRow(r.Context(),
+		`SELECT COUNT(*) FROM instance_policies p
+		 WHERE p.id = ANY($1)
+		   AND p.version = (SELECT MAX(version) FROM instance_policies WHERE kind = p.kind)`,
+		req.PolicyIDs).Scan(&current)
+	if current != len(req.PolicyIDs) {
+		apiutil.WriteError(w, http.StatusBadRequest, "outdated_policy",
+			"Only the current version of a policy can be acknowledged")
+		return
+	}
+
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if _, err := h.Pool.Exec(r.Context(),
+		`INSERT INTO instance_policy_acknowledgments (user_id, policy_id, acknowledged_at, ip_address)
+		 SELECT $1, unnest($2::text[]), now(), NULLIF($3, '')
+		 ON CONFLICT (user_id, policy_id) DO NOTHING`,
+		userID, req.PolicyIDs, ip); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to acknowledge policies", err)
+		return
+	}
+	apiutil.InvalidatePolicyAcks(r.Context(), h.Cache, h.Logger, userID)
+
+	h.HandleGetSelfPolicies(w, r)
+}
@@ -0,0 +1,124 @@
+// Package users — exceptions to invisible mode. A user in invisible mode can
+// pick friends and guilds they still appear online to; the gateway applies
+// the lists when it sends out presence.
+package users
+
+import (
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// maxVisibilityExceptions bounds each exception list.
+const maxVisibilityExceptions = 100
+
+// PresenceVisibility lists who sees the user online while they are invisible.
+type PresenceVisibility struct {
+	UserIDs  []string `json:"user_ids"`
+	GuildIDs []string `json:"guild_ids"`
+}
+
+// HandleGetPresenceVisibility returns the user's invisible mode exceptions.
+// GET /api/v1/users/@me/presence-visibility
+func (h *Handler) HandleGetPresenceVisibility(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT target_type, target_id FROM presence_visibility_exceptions
+		 WHERE user_id = $1 ORDER BY created_at`, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get presence visibility", err)
+		return
+	}
+	defer rows.Close()
+
+	vis := PresenceVisibility{UserIDs: []string{}, GuildIDs: []string{}}
+	for rows.Next() {
+		var targetType, targetID string
+		if err := rows.Scan(&targetType, &targetID); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read presence visibility", err)
+			return
+		}
+		if targetType == "guild" {
+			vis.GuildIDs = append(vis.GuildIDs, targetID)
+		} else {
+			vis.UserIDs = append(vis.UserIDs, targetID)
+		}
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, vis)
+}
+
+// HandleSetPresenceVisibility replaces the user's invisible mode exceptions.
+// Users must be friends and guilds must be ones the user is a member of.
+// PUT /api/v1/users/@me/presence-visibility
+func (h *Handler) HandleSetPresenceVisibility(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	var req PresenceVisibility
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.UserIDs = dedupe(req.UserIDs)
+	req.GuildIDs = dedupe(req.GuildIDs)
+	if len(req.UserIDs) > maxVisibilityExceptions || len(req.GuildIDs) > maxVisibilityExceptions {
+		apiutil.WriteError(w, http.StatusBadRequest, "too_many_exceptions",
+			"At most 100 users and 100 guilds can be exceptions")
+		return
+	}
+
+	var friends, guilds int
+	h.Pool.QueryRow(r.Context(),
+		`SELECT (SELECT COUNT(*) FROM user_relationships
+		         WHERE user_id = $1 AND target_id = ANY($2) AND status = 'friend'),
+		        (SELECT COUNT(*) FROM guild_members WHERE user_id = $1 AND guild_id = ANY($3))`,
+		userID, req.UserIDs, req.GuildIDs).Scan(&friends, &guilds)
+	if friends != len(req.UserIDs) {
+		apiutil.WriteError(w, http.StatusBadRequest, "not_friend", "Exceptions can only be made for friends")
+		return
+	}
+	if guilds != len(req.GuildIDs) {
+		apiutil.WriteError(w, http.StatusBadRequest, "not_member", "Exceptions can only be made for guilds you are in")
+		return
+	}
+
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`DELETE FROM presence_visibility_exceptions WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(r.Context(),
+			`INSERT INTO presence_visibility_exceptions (user_id, target_type, target_id)
+			 SELECT $1, 'user', unnest($2::text[])
+			 UNION ALL
+			 SELECT $1, 'guild', unnest($3::text[])`,
+			userID, req.UserIDs, req.GuildIDs)
+		return err
+	})
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update presence visibility", err)
+		return
+	}
+
+	h.EventBus.PublishUserEvent(r.Context(), events.SubjectUserUpdate, "PRESENCE_VISIBILITY_UPDATE", userID, req)
+
+	apiutil.WriteJSON(w, http.StatusOK, req)
+}
+
+// dedupe returns ids without repeats, in their original order. A nil slice
+// comes back empty.
+func dedupe(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/federation"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- Profile Privacy ---
+//
+// Which optional profile fields remote instances receive when they look up
+// or fetch a local user. Members of this instance always see the whole
+// profile.
+
+type updateProfilePrivacyRequest struct {
+	Bio         *string `json:"bio"`
+	Pronouns    *string `json:"pronouns"`
+	Banner      *string `json:"banner"`
+	Connections *string `json:"connections"`
+	Activity    *string `json:"activity"`
+}
+
+// HandleGetProfilePrivacy returns which profile fields the caller shares
+// with remote instances.
+// GET /api/v1/users/@me/profile-privacy
+func (h *Handler) HandleGetProfilePrivacy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	apiutil.WriteJSON(w, http.StatusOK, federation.LoadProfilePrivacy(r.Context(), h.Pool, userID))
+}
+
+// HandleUpdateProfilePrivacy changes which profile fields the caller shares
+// with remote instances. Each field is "everyone" or "local"; omitted
+// fields keep their current value.
+// PATCH /api/v1/users/@me/profile-privacy
+func (h *Handler) HandleUpdateProfilePrivacy(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	var req updateProfilePrivacyRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	p := federation.LoadProfilePrivacy(r.Context(), h.Pool, userID)
+	for _, f := range []struct {
+		req *string
+		dst *string
+	}{
+		{req.Bio, &p.Bio},
+		{req.Pronouns, &p.Pronouns},
+		{req.Banner, &p.Banner},
+		{req.Connections, &p.Connections},
+		{req.Activity, &p.Activity},
+	} {
+		if f.req == nil {
+			continue
+		}
+		if !models.ValidProfileVisibility(*f.req) {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_profile_privacy",
+				"Profile fields must be shared with 'everyone' or kept 'local'")
+			return
+		}
+		*f.dst = *f.req
+	}
+
+	err := h.Pool.QueryRow(r.Context(),
+		`INSERT INTO user_profile_privacy (user_id, bio, pronouns, banner, connections, activity, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, now())
+		 ON CONFLICT (user_id) DO UPDATE SET
+		     bio = $2, pronouns = $3, banner = $4, connections = $5, activity = $6,
+		     updated_at = now()
+		 RETURNING updated_at`,
+		userID, p.Bio, p.Pronouns, p.Banner, p.Connections, p.Activity,
+	).Scan(&p.UpdatedAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update profile privacy", err)
+		return
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, p)
+}
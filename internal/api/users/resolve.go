@@ -11,6 +11,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/federation"
 	"github.com/amityvox/amityvox/internal/models"
@@ -50,7 +51,7 @@ func (h *Handler) HandleResolveHandle(w http.ResponseWriter, r *http.Request) {
 		user, err := h.resolveLocalUser(r, username)
 		if err != nil {
 			if err == pgx.ErrNoRows {
-				apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "No user found with that handle")
+				apiutil.WriteCode(w, apierrors.UserNotFound, "No user found with that handle")
 				return
 			}
 			apiutil.InternalError(w, h.Logger, "Failed to resolve handle", err)
@@ -104,7 +105,7 @@ func (h *Handler) HandleResolveHandle(w http.ResponseWriter, r *http.Request) {
 			slog.String("error", err.Error()),
 			slog.String("domain", domain))
 		if errors.Is(err, errRemoteUserNotFound) {
-			apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "No user found with that handle")
+			apiutil.WriteCode(w, apierrors.UserNotFound, "No user found with that handle")
 		} else {
 			apiutil.WriteError(w, http.StatusBadGateway, "remote_lookup_failed", "Failed to look up user on remote instance")
 		}
@@ -140,6 +141,8 @@ type federatedUserLookupResponse struct {
 	DisplayName *string `json:"display_name"`
 	AvatarID    *string `json:"avatar_id"`
 	Bio         *string `json:"bio"`
+	Pronouns    *string `json:"pronouns"`  // absent unless the user shares it
+	BannerID    *string `json:"banner_id"` // absent unless the user shares it
 	CreatedAt   string  `json:"created_at"`
 }
 
@@ -239,14 +242,17 @@ func (h *Handler) resolveRemoteUser(r *http.Request, username, domain string) (*
 	}
 
 	_, err = h.Pool.Exec(r.Context(),
-		`INSERT INTO users (id, instance_id, username, display_name, avatar_id, bio, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`INSERT INTO users (id, instance_id, username, display_name, avatar_id, bio, pronouns, banner_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		 ON CONFLICT (instance_id, username) DO UPDATE SET
 			display_name = EXCLUDED.display_name,
 			avatar_id = EXCLUDED.avatar_id,
-			bio = EXCLUDED.bio`,
+			bio = EXCLUDED.bio,
+			pronouns = EXCLUDED.pronouns,
+			banner_id = EXCLUDED.banner_id`,
 		remoteUser.ID, disc.InstanceID, remoteUser.Username,
-		remoteUser.DisplayName, remoteUser.AvatarID, remoteUser.Bio, createdAt,
+		remoteUser.DisplayName, remoteUser.AvatarID, remoteUser.Bio,
+		remoteUser.Pronouns, remoteUser.BannerID, createdAt,
 	)
 	if err != nil {
 		h.Logger.Warn("failed to upsert remote user stub", slog.String("error", err.Error()))
@@ -260,6 +266,8 @@ func (h *Handler) resolveRemoteUser(r *http.Request, username, domain string) (*
 		DisplayName:    remoteUser.DisplayName,
 		AvatarID:       remoteUser.AvatarID,
 		Bio:            remoteUser.Bio,
+		Pronouns:       remoteUser.Pronouns,
+		BannerID:       remoteUser.BannerID,
 		StatusPresence: "offline",
 		CreatedAt:      createdAt,
 	}, nil
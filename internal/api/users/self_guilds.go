@@ -0,0 +1,355 @@
+// Package users — the current user's guild list.
+// GET /api/v1/users/@me/guilds lists the user's guilds in their own order,
+// each with the user's roles and permissions in it, unread and mention
+// counts and when it was last active, so clients need not rebuild this from
+// READY. The first page is cached per user until they read, rearrange, join
+// or leave, or the entry expires.
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+const (
+	// maxSelfGuildsPage is the default and largest page of the guild list.
+	maxSelfGuildsPage = 200
+
+	// selfGuildsTTL bounds how stale a cached guild list can get through
+	// changes that publish no event for the user, such as new messages,
+	// mentions, role edits or guild renames.
+	selfGuildsTTL = 30 * time.Second
+)
+
+// selfGuildKeyset orders the guild list by the user's position, unpositioned
+// guilds last, then by name.
+var selfGuildKeyset = apiutil.Keyset{Columns: []apiutil.KeyColumn{
+	{Expr: "COALESCE(ugp.position, 2147483647)", Type: "int"},
+	{Expr: "g.name", Type: "text"},
+	{Expr: "g.id", Type: "text"},
+}}
+
+// cachedSelfGuildPage is the first page of a user's guild list as cached.
+type cachedSelfGuildPage struct {
+	Guilds     []models.SelfGuild `json:"guilds"`
+	NextCursor *string            `json:"next_cursor"`
+}
+
+// HandleGetSelfGuilds returns the guilds the authenticated user is a member
+// of, in the order they arranged them, then by name, a page at a time.
+// GET /api/v1/users/@me/guilds?before=&after=&limit= (default and max 200)
+func (h *Handler) HandleGetSelfGuilds(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	page, ok := apiutil.ParsePage(w, r, selfGuildKeyset, maxSelfGuildsPage)
+	if !ok {
+		return
+	}
+	page.Limit = min(page.Limit, maxSelfGuildsPage)
+
+	var cacheKey string
+	if !page.HasCursor() && page.Limit == maxSelfGuildsPage {
+		cacheKey = h.selfGuildsKey(r.Context(), userID)
+		if cached, ok := h.cachedSelfGuilds(r.Context(), cacheKey); ok {
+			apiutil.WritePage(w, cached.Guilds, cached.NextCursor)
+			return
+		}
+	}
+
+	guilds, next, err := h.loadSelfGuilds(r.Context(), userID, page)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get guilds", err)
+		return
+	}
+	h.storeSelfGuilds(r.Context(), cacheKey, cachedSelfGuildPage{Guilds: guilds, NextCursor: next})
+	apiutil.WritePage(w, guilds, next)
+}
+
+// loadSelfGuilds reads one page of the user's guild list with their roles,
+// permissions and unread counts.
+func (h *Handler) loadSelfGuilds(ctx context.Context, userID string, page apiutil.Page) ([]models.SelfGuild, *string, error) {
+	type row struct {
+		models.SelfGuild
+		position     int
+		timeoutUntil *time.Time
+		appCap       *int64
+	}
+
+	cursorSQL, cursorArgs := page.Where(selfGuildKeyset, 3)
+	rows, err := h.Pool.Query(ctx,
+		`SELECT g.id, g.instance_id, COALESCE(i.domain, ''), g.owner_id, g.name, g.description, g.icon_id,
+		        g.banner_id, g.default_permissions, g.flags, g.nsfw, g.discoverable,
+		        g.preferred_locale, g.timezone, g.max_members, g.vanity_url,
+		        g.verification_level, g.afk_channel_id, g.afk_timeout,
+		        g.tags, g.member_count, g.created_at,
+		        COALESCE(ugp.position, 2147483647), gm.timeout_until, bi.permissions
+		 FROM guilds g
+		 JOIN guild_members gm ON g.id = gm.guild_id
+		 LEFT JOIN instances i ON i.id = g.instance_id
+		 LEFT JOIN user_guild_positions ugp ON ugp.guild_id = g.id AND ugp.user_id = gm.user_id
+		 LEFT JOIN bot_installs bi ON bi.guild_id = g.id AND bi.bot_id = gm.user_id
+		 WHERE gm.user_id = $1`+cursorSQL+`
+		 ORDER BY `+page.OrderBy(selfGuildKeyset)+`
+		 LIMIT $2`,
+		append([]interface{}{userID, page.FetchLimit()}, cursorArgs...)...,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing guilds: %w", err)
+	}
+	defer rows.Close()
+
+	var list []row
+	for rows.Next() {
+		var gr row
+		g := &gr.Guild
+		if err := rows.Scan(
+			&g.ID, &g.InstanceID, &g.InstanceDomain, &g.OwnerID, &g.Name, &g.Description, &g.IconID,
+			&g.BannerID, &g.DefaultPermissions, &g.Flags, &g.NSFW, &g.Discoverable,
+			&g.PreferredLocale, &g.Timezone, &g.MaxMembers, &g.VanityURL,
+			&g.VerificationLevel, &g.AFKChannelID, &g.AFKTimeout,
+			&g.Tags, &g.MemberCount, &g.CreatedAt,
+			&gr.position, &gr.timeoutUntil, &gr.appCap,
+		); err != nil {
+			return nil, nil, fmt.Errorf("reading guilds: %w", err)
+		}
+		list = append(list, gr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading guilds: %w", err)
+	}
+	list, next := apiutil.FinishPage(page, list, func(gr row) []interface{} {
+		return []interface{}{gr.position, gr.Name, gr.ID}
+	})
+
+	guilds := make([]models.SelfGuild, 0, len(list))
+	if len(list) == 0 {
+		return guilds, next, nil
+	}
+	guildIDs := make([]string, len(list))
+	for i, gr := range list {
+		guildIDs[i] = gr.ID
+	}
+
+	roles, err := h.selfGuildRoles(ctx, userID, guildIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	unread, err := h.selfGuildUnreads(ctx, userID, guildIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	var userFlags int
+	if err := h.Pool.QueryRow(ctx, `SELECT flags FROM users WHERE id = $1`, userID).Scan(&userFlags); err != nil {
+		return nil, nil, fmt.Errorf("loading user flags: %w", err)
+	}
+
+	for _, gr := range list {
+		sg := gr.SelfGuild
+		sg.Owner = sg.OwnerID == userID
+		sg.RoleIDs = make([]string, 0, len(roles[sg.ID]))
+		for _, ri := range roles[sg.ID] {
+			sg.RoleIDs = append(sg.RoleIDs, ri.ID)
+		}
+		perms := permissions.AllPermissions
+		if userFlags&models.UserFlagAdmin == 0 {
+			member := permissions.MemberInfo{UserID: userID, TimeoutUntil: gr.timeoutUntil}
+			if gr.appCap != nil {
+				grant := uint64(*gr.appCap)
+				member.Cap = &grant
+			}
+			guild := permissions.GuildInfo{OwnerID: sg.OwnerID, DefaultPermissions: uint64(sg.DefaultPermissions)}
+			perms = permissions.CalculatePermissions(member, guild, roles[sg.ID], nil)
+		}
+		sg.Permissions = int64(perms)
+		if u, ok := unread[sg.ID]; ok {
+			sg.UnreadChannels, sg.MentionCount, sg.LastActivityAt = u.UnreadChannels, u.MentionCount, u.LastActivityAt
+		}
+		guilds = append(guilds, sg)
+	}
+	return guilds, next, nil
+}
+
+// selfGuildRoles returns the user's roles in each of the guilds, highest
+// position first as permission calculation expects.
+func (h *Handler) selfGuildRoles(ctx context.Context, userID string, guildIDs []string) (map[string][]permissions.RoleInfo, error) {
+	rows, err := h.Pool.Query(ctx,
+		`SELECT mr.guild_id, r.id, r.position, r.permissions_allow, r.permissions_deny
+		 FROM member_roles mr JOIN roles r ON r.id = mr.role_id
+		 WHERE mr.user_id = $1 AND mr.guild_id = ANY($2)
+		 ORDER BY mr.guild_id, r.position DESC`, userID, guildIDs)
+	if err != nil {
+		return nil, fmt.Errorf("loading roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := make(map[string][]permissions.RoleInfo)
+	for rows.Next() {
+		var guildID string
+		var ri permissions.RoleInfo
+		var allow, deny int64
+		if err := rows.Scan(&guildID, &ri.ID, &ri.Position, &allow, &deny); err != nil {
+			return nil, fmt.Errorf("reading roles: %w", err)
+		}
+		ri.PermissionsAllow, ri.PermissionsDeny = uint64(allow), uint64(deny)
+		roles[guildID] = append(roles[guildID], ri)
+	}
+	return roles, rows.Err()
+}
+
+// selfGuildUnread is one guild's unread summary.
+type selfGuildUnread struct {
+	UnreadChannels int
+	MentionCount   int
+	LastActivityAt *time.Time
+}
+
+// selfGuildUnreads sums the user's read state in each of the guilds. A
+// channel is unread when someone else has posted since the user last read
+// it; the user's own messages do not count.
+func (h *Handler) selfGuildUnreads(ctx context.Context, userID string, guildIDs []string) (map[string]selfGuildUnread, error) {
+	rows, err := h.Pool.Query(ctx,
+		`WITH per_guild AS (
+		     SELECT c.guild_id,
+		            COUNT(*) FILTER (WHERE
+		                (rs.last_read_id IS NULL OR c.last_message_id > rs.last_read_id)
+		                AND EXISTS (SELECT 1 FROM messages m
+		                            WHERE m.channel_id = c.id AND m.author_id <> $1
+		                              AND (rs.last_read_id IS NULL OR m.id > rs.last_read_id))) AS unread_channels,
+		            COALESCE(SUM(rs.mention_count), 0) AS mention_count,
+		            MAX(c.last_message_id) AS last_message_id
+		     FROM read_state rs
+		     JOIN channels c ON c.id = rs.channel_id
+		     WHERE rs.user_id = $1 AND c.guild_id = ANY($2) AND c.last_message_id IS NOT NULL
+		     GROUP BY c.guild_id)
+		 SELECT p.guild_id, p.unread_channels, p.mention_count, lm.created_at
+		 FROM per_guild p
+		 LEFT JOIN messages lm ON lm.id = p.last_message_id`, userID, guildIDs)
+	if err != nil {
+		return nil, fmt.Errorf("loading unread counts: %w", err)
+	}
+	defer rows.Close()
+
+	unread := make(map[string]selfGuildUnread)
+	for rows.Next() {
+		var guildID string
+		var u selfGuildUnread
+		if err := rows.Scan(&guildID, &u.UnreadChannels, &u.MentionCount, &u.LastActivityAt); err != nil {
+			return nil, fmt.Errorf("reading unread counts: %w", err)
+		}
+		unread[guildID] = u
+	}
+	return unread, rows.Err()
+}
+
+func selfGuildsGenKey(userID string) string {
+	return "self_guilds:" + userID
+}
+
+// selfGuildsKey returns the cache key for the first page of a user's guild
+// list, or "" when the cache is unavailable.
+func (h *Handler) selfGuildsKey(ctx context.Context, userID string) string {
+	if h.Cache == nil {
+		return ""
+	}
+	gen, err := h.Cache.Generation(ctx, selfGuildsGenKey(userID))
+	if err != nil {
+		h.Logger.Debug("guild list cache unavailable", slog.String("error", err.Error()))
+		return ""
+	}
+	return fmt.Sprintf("self_guilds:%s:%d", userID, gen)
+}
+
+// cachedSelfGuilds returns the cached page under key, if there is one.
+func (h *Handler) cachedSelfGuilds(ctx context.Context, key string) (cachedSelfGuildPage, bool) {
+	var page cachedSelfGuildPage
+	if key == "" {
+		return page, false
+	}
+	found, err := h.Cache.Get(ctx, key, &page)
+	if err != nil {
+		h.Logger.Debug("reading cached guild list failed", slog.String("error", err.Error()))
+		return page, false
+	}
+	return page, found
+}
+
+func (h *Handler) storeSelfGuilds(ctx context.Context, key string, page cachedSelfGuildPage) {
+	if key == "" {
+		return
+	}
+	if err := h.Cache.Set(ctx, key, page, selfGuildsTTL); err != nil {
+		h.Logger.Debug("caching guild list failed", slog.String("error", err.Error()))
+	}
+}
+
+// invalidateSelfGuilds drops the cached guild list of a user.
+func (h *Handler) invalidateSelfGuilds(ctx context.Context, userID string) {
+	if err := h.Cache.BumpGeneration(ctx, selfGuildsGenKey(userID)); err != nil {
+		h.Logger.Warn("invalidating guild list cache failed",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+}
+
+// selfGuildsUserSubjects are user events that change the user's guild list.
+var selfGuildsUserSubjects = []string{
+	events.SubjectChannelAck,
+	events.SubjectGuildLayoutUpdate,
+}
+
+// selfGuildsMemberSubjects are guild events about one member that change
+// that member's guild list.
+var selfGuildsMemberSubjects = []string{
+	events.SubjectGuildMemberAdd,
+	events.SubjectGuildMemberUpdate,
+	events.SubjectGuildMemberRemove,
+}
+
+// StartSelfGuildsInvalidation drops cached guild lists as users read
+// channels, rearrange their guilds, join, leave, or have their roles
+// changed. One API node handles each event. Call this once during server
+// startup.
+func (h *Handler) StartSelfGuildsInvalidation() {
+	if h.EventBus == nil || h.Cache == nil {
+		return
+	}
+
+	subscribe := func(subject string, userOf func(events.Event) string) {
+		if _, err := h.EventBus.QueueSubscribe(subject, "self-guilds-cache", func(event events.Event) {
+			userID := userOf(event)
+			if userID == "" {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			h.invalidateSelfGuilds(ctx, userID)
+		}); err != nil {
+			h.Logger.Error("failed to subscribe for guild list invalidation",
+				slog.String("subject", subject), slog.String("error", err.Error()))
+		}
+	}
+
+	for _, subject := range selfGuildsUserSubjects {
+		subscribe(subject, func(event events.Event) string { return event.UserID })
+	}
+	for _, subject := range selfGuildsMemberSubjects {
+		subscribe(subject, func(event events.Event) string {
+			var member struct {
+				UserID string `json:"user_id"`
+			}
+			if json.Unmarshal(event.Data, &member) != nil {
+				return ""
+			}
+			return member.UserID
+		})
+	}
+}
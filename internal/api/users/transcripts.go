@@ -0,0 +1,390 @@
+// Package users — shareable transcript links for channel exports. A link
+// freezes the channel's messages at the moment it is made and serves them,
+// watermarked with the exporter's identity, to guild members or to anyone
+// holding the link until it expires or is revoked.
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// Transcript link visibilities.
+const (
+	TranscriptVisibilityMembers = "members" // guild members who can read the channel, or DM participants
+	TranscriptVisibilityLink    = "link"    // anyone with the link
+)
+
+const (
+	defaultTranscriptTTL = 7 * 24 * time.Hour
+	maxTranscriptTTL     = 30 * 24 * time.Hour
+)
+
+const transcriptLinkColumns = `id, code, channel_id, created_by, visibility, snapshot_at,
+	expires_at, revoked_at, access_count, last_accessed_at, created_at`
+
+func scanTranscriptLink(row pgx.Row) (models.ChannelTranscriptLink, error) {
+	var l models.ChannelTranscriptLink
+	err := row.Scan(&l.ID, &l.Code, &l.ChannelID, &l.CreatedBy, &l.Visibility, &l.SnapshotAt,
+		&l.ExpiresAt, &l.RevokedAt, &l.AccessCount, &l.LastAccessedAt, &l.CreatedAt)
+	return l, err
+}
+
+// channelTranscript is a channel export served through a transcript link.
+type channelTranscript struct {
+	channelMessageExport
+	ChannelName  *string   `json:"channel_name,omitempty"`
+	ExporterName string    `json:"exporter_name"`
+	LinkID       string    `json:"link_id"`
+	Visibility   string    `json:"visibility"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Watermark    string    `json:"watermark"`
+}
+
+// transcriptWatermark is the line stamped on a shared transcript so any copy
+// of it names who exported it and through which link.
+func transcriptWatermark(exporterName, exporterID string, snapshotAt time.Time, linkID string) string {
+	return fmt.Sprintf("Exported by @%s (%s) on %s via transcript link %s",
+		exporterName, exporterID, snapshotAt.UTC().Format(time.RFC3339), linkID)
+}
+
+// HandleCreateTranscriptLink creates a shareable link to the channel's
+// messages as of now. Requires the same access as exporting the channel.
+// Body: {"visibility": "members"|"link", "expires_in_seconds": n}; links
+// default to guild members and seven days, and last at most thirty.
+// POST /api/v1/channels/{channelID}/transcripts
+func (h *Handler) HandleCreateTranscriptLink(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+
+	var req struct {
+		Visibility       string `json:"visibility"`
+		ExpiresInSeconds int    `json:"expires_in_seconds"`
+	}
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	switch req.Visibility {
+	case "":
+		req.Visibility = TranscriptVisibilityMembers
+	case TranscriptVisibilityMembers, TranscriptVisibilityLink:
+	default:
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_visibility", "visibility must be members or link")
+		return
+	}
+	ttl := defaultTranscriptTTL
+	if req.ExpiresInSeconds != 0 {
+		ttl = time.Duration(req.ExpiresInSeconds) * time.Second
+	}
+	if ttl <= 0 || ttl > maxTranscriptTTL {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_expiry", "expires_in_seconds must be between 1 and 2592000")
+		return
+	}
+
+	guildID, ok := h.exportableChannel(w, r, channelID, userID)
+	if !ok {
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to generate transcript code", err)
+		return
+	}
+
+	link, err := scanTranscriptLink(h.Pool.QueryRow(r.Context(),
+		`INSERT INTO channel_transcript_links
+		     (id, code, channel_id, created_by, visibility, snapshot_at, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, now(), now() + $6 * interval '1 second', now())
+		 RETURNING `+transcriptLinkColumns,
+		models.NewULID().String(), hex.EncodeToString(b), channelID, userID, req.Visibility, int64(ttl.Seconds())))
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to create transcript link", err)
+		return
+	}
+
+	if guildID != nil {
+		h.logTranscriptAudit(r.Context(), *guildID, userID, "transcript_link_create", link.ID)
+	}
+	apiutil.WriteJSON(w, http.StatusCreated, link)
+}
+
+// HandleGetTranscriptLinks lists the channel's transcript links, newest first,
+// including expired and revoked ones.
+// GET /api/v1/channels/{channelID}/transcripts
+func (h *Handler) HandleGetTranscriptLinks(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	if _, ok := h.transcriptManager(w, r, channelID, userID); !ok {
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT `+transcriptLinkColumns+` FROM channel_transcript_links
+		 WHERE channel_id = $1 ORDER BY created_at DESC LIMIT 100`, channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get transcript links", err)
+		return
+	}
+	defer rows.Close()
+
+	links := make([]models.ChannelTranscriptLink, 0)
+	for rows.Next() {
+		link, err := scanTranscriptLink(rows)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read transcript links", err)
+			return
+		}
+		links = append(links, link)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, links)
+}
+
+// HandleRevokeTranscriptLink stops a transcript link from working.
+// DELETE /api/v1/channels/{channelID}/transcripts/{linkID}
+func (h *Handler) HandleRevokeTranscriptLink(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	linkID := chi.URLParam(r, "linkID")
+
+	guildID, ok := h.transcriptManager(w, r, channelID, userID)
+	if !ok {
+		return
+	}
+
+	tag, err := h.Pool.Exec(r.Context(),
+		`UPDATE channel_transcript_links SET revoked_at = now()
+		 WHERE id = $1 AND channel_id = $2 AND revoked_at IS NULL`, linkID, channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to revoke transcript link", err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		apiutil.WriteError(w, http.StatusNotFound, "transcript_not_found", "No active transcript link with that ID")
+		return
+	}
+
+	if guildID != nil {
+		h.logTranscriptAudit(r.Context(), *guildID, userID, "transcript_link_revoke", linkID)
+	}
+	apiutil.WriteNoContent(w)
+}
+
+// HandleGetTranscriptAccesses lists who opened a transcript link and when,
+// newest first.
+// GET /api/v1/channels/{channelID}/transcripts/{linkID}/accesses
+func (h *Handler) HandleGetTranscriptAccesses(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	linkID := chi.URLParam(r, "linkID")
+	if _, ok := h.transcriptManager(w, r, channelID, userID); !ok {
+		return
+	}
+
+	rows, err := h.Pool.Query(r.Context(),
+		`SELECT a.id, a.link_id, a.viewer_id, a.remote_addr, a.accessed_at
+		 FROM channel_transcript_accesses a
+		 JOIN channel_transcript_links l ON l.id = a.link_id
+		 WHERE a.link_id = $1 AND l.channel_id = $2
+		 ORDER BY a.accessed_at DESC LIMIT 500`, linkID, channelID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get transcript accesses", err)
+		return
+	}
+	defer rows.Close()
+
+	accesses := make([]models.ChannelTranscriptAccess, 0)
+	for rows.Next() {
+		var a models.ChannelTranscriptAccess
+		if err := rows.Scan(&a.ID, &a.LinkID, &a.ViewerID, &a.RemoteAddr, &a.AccessedAt); err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to read transcript accesses", err)
+			return
+		}
+		accesses = append(accesses, a)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, accesses)
+}
+
+// HandleGetTranscript serves the transcript behind a link. Members-only links
+// need a signed-in guild member who can view the channel and read its
+// history (or a DM participant); link-visibility ones are
+// open to anyone with the code. Every view is logged against the link, and
+// views by signed-in users of guild channels also go to the guild audit log.
+// GET /api/v1/transcripts/{code}
+func (h *Handler) HandleGetTranscript(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	viewerID := auth.UserIDFromContext(ctx)
+
+	var (
+		link         models.ChannelTranscriptLink
+		guildID      *string
+		channelName  *string
+		exporterName string
+	)
+	err := h.Pool.QueryRow(ctx,
+		`SELECT l.id, l.channel_id, l.created_by, l.visibility, l.snapshot_at, l.expires_at,
+		        c.guild_id, c.name, COALESCE(u.username, '')
+		 FROM channel_transcript_links l
+		 JOIN channels c ON c.id = l.channel_id
+		 LEFT JOIN users u ON u.id = l.created_by
+		 WHERE l.code = $1 AND l.revoked_at IS NULL AND l.expires_at > now()`,
+		chi.URLParam(r, "code"),
+	).Scan(&link.ID, &link.ChannelID, &link.CreatedBy, &link.Visibility, &link.SnapshotAt, &link.ExpiresAt,
+		&guildID, &channelName, &exporterName)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "transcript_not_found", "This transcript link does not exist or has expired")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get transcript", err)
+		return
+	}
+
+	if link.Visibility == TranscriptVisibilityMembers {
+		if viewerID == "" {
+			apiutil.WriteCode(w, apierrors.Unauthorized, "Sign in to view this transcript")
+			return
+		}
+		var allowed bool
+		if guildID != nil {
+			perms, err := apiutil.MemberChannelPermissions(ctx, h.Pool, *guildID, link.ChannelID, viewerID)
+			need := permissions.ViewChannel | permissions.ReadHistory
+			allowed = err == nil && perms&need == need
+		} else {
+			h.Pool.QueryRow(ctx,
+				`SELECT EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2)`,
+				link.ChannelID, viewerID).Scan(&allowed)
+		}
+		if !allowed {
+			apiutil.WriteError(w, http.StatusForbidden, "members_only", "This transcript is only shared with members")
+			return
+		}
+	}
+
+	// A legal hold placed after the link was made freezes it too.
+	if guildID != nil {
+		if apiutil.RejectIfLegalHold(w, r, h.Pool, "guild", *guildID) {
+			return
+		}
+	} else if h.dmOnLegalHold(ctx, link.ChannelID) {
+		apiutil.WriteError(w, http.StatusLocked, "legal_hold", "This data is under legal hold and cannot be exported")
+		return
+	}
+
+	messages, err := h.collectChannelMessages(ctx, link.ChannelID, &link.SnapshotAt)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to load transcript", err)
+		return
+	}
+
+	h.recordTranscriptAccess(r, link.ID, viewerID)
+	if guildID != nil && viewerID != "" {
+		h.logTranscriptAudit(ctx, *guildID, viewerID, "transcript_link_access", link.ID)
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, channelTranscript{
+		channelMessageExport: channelMessageExport{
+			ChannelID:  link.ChannelID,
+			ExportedAt: link.SnapshotAt,
+			ExportedBy: link.CreatedBy,
+			Messages:   messages,
+		},
+		ChannelName:  channelName,
+		ExporterName: exporterName,
+		LinkID:       link.ID,
+		Visibility:   link.Visibility,
+		ExpiresAt:    link.ExpiresAt,
+		Watermark:    transcriptWatermark(exporterName, link.CreatedBy, link.SnapshotAt, link.ID),
+	})
+}
+
+// transcriptManager checks that the user may manage the channel's transcript
+// links: guild channels need EXPORT_MESSAGES there, DMs a participant. Unlike
+// exporting, this stays possible under legal hold. It writes the error
+// response when not allowed and returns the channel's guild.
+func (h *Handler) transcriptManager(w http.ResponseWriter, r *http.Request, channelID, userID string) (*string, bool) {
+	var guildID *string
+	err := h.Pool.QueryRow(r.Context(), `SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
+		return nil, false
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to get channel", err)
+		return nil, false
+	}
+
+	var allowed bool
+	if guildID != nil {
+		perms, err := apiutil.MemberChannelPermissions(r.Context(), h.Pool, *guildID, channelID, userID)
+		allowed = err == nil && perms&permissions.ExportMessages != 0
+	} else {
+		h.Pool.QueryRow(r.Context(),
+			`SELECT EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2)`,
+			channelID, userID).Scan(&allowed)
+	}
+	if !allowed {
+		apiutil.WriteCode(w, apierrors.MissingPermission,
+			"You need EXPORT_MESSAGES permission in this channel to manage transcript links")
+		return nil, false
+	}
+	return guildID, true
+}
+
+// recordTranscriptAccess logs a view of a transcript link. Failures are
+// logged rather than surfaced so the viewer still gets the transcript.
+func (h *Handler) recordTranscriptAccess(r *http.Request, linkID, viewerID string) {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	var viewer *string
+	if viewerID != "" {
+		viewer = &viewerID
+	}
+
+	err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO channel_transcript_accesses (id, link_id, viewer_id, remote_addr, accessed_at)
+			 VALUES ($1, $2, $3, $4, now())`,
+			models.NewULID().String(), linkID, viewer, remote); err != nil {
+			return err
+		}
+		_, err := tx.Exec(r.Context(),
+			`UPDATE channel_transcript_links
+			 SET access_count = access_count + 1, last_accessed_at = now() WHERE id = $1`, linkID)
+		return err
+	})
+	if err != nil {
+		h.Logger.Warn("failed to record transcript access",
+			slog.String("link_id", linkID), slog.String("error", err.Error()))
+	}
+}
+
+// logTranscriptAudit records a transcript link event in the guild audit log.
+func (h *Handler) logTranscriptAudit(ctx context.Context, guildID, actorID, action, linkID string) {
+	if _, err := h.Pool.Exec(ctx,
+		`INSERT INTO audit_log (id, guild_id, actor_id, action, target_type, target_id, created_at)
+		 VALUES ($1, $2, $3, $4, 'transcript_link', $5, now())`,
+		models.NewULID().String(), guildID, actorID, action, linkID); err != nil {
+		h.Logger.Warn("failed to write transcript audit entry",
+			slog.String("link_id", linkID), slog.String("action", action), slog.String("error", err.Error()))
+	}
+}
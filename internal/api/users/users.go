@@ -12,6 +12,7 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,10 +20,13 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/featureflags"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/presence"
 )
 
 // sessionDisplayID returns a non-reversible display identifier for a session
@@ -46,6 +50,8 @@ type Handler struct {
 	InstanceDomain string
 	Logger         *slog.Logger
 	NotifyFederatedDM FederationDMNotifier // optional — nil if federation disabled
+	FeatureFlags      *featureflags.Store  // optional — nil has every flag off
+	Cache             *presence.Cache      // optional — nil disables the guild list cache and DM open limits
 }
 
 // updateSelfRequest is the JSON body for PATCH /users/@me.
@@ -60,6 +66,9 @@ type updateSelfRequest struct {
 	BannerID        *string `json:"banner_id"`
 	AccentColor     *string `json:"accent_color"`
 	Pronouns        *string `json:"pronouns"`
+
+	ShareFederatedPresence *bool `json:"share_federated_presence"`
+	ReadReceipts           *bool `json:"read_receipts"`
 }
 
 // HandleGetSelf returns the authenticated user's profile.
@@ -70,7 +79,7 @@ func (h *Handler) HandleGetSelf(w http.ResponseWriter, r *http.Request) {
 	user, err := h.getUser(r.Context(), userID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "User not found")
+			apiutil.WriteCode(w, apierrors.UserNotFound, "")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to get user", err)
@@ -163,7 +172,7 @@ func (h *Handler) HandleGetUser(w http.ResponseWriter, r *http.Request) {
 	user, err := h.getUser(r.Context(), targetID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "User not found")
+			apiutil.WriteCode(w, apierrors.UserNotFound, "")
 			return
 		}
 		apiutil.InternalError(w, h.Logger, "Failed to get user", err)
@@ -177,49 +186,6 @@ func (h *Handler) HandleGetUser(w http.ResponseWriter, r *http.Request) {
 	apiutil.WriteJSON(w, http.StatusOK, user)
 }
 
-// HandleGetSelfGuilds returns the guilds the authenticated user is a member of.
-// GET /api/v1/users/@me/guilds
-func (h *Handler) HandleGetSelfGuilds(w http.ResponseWriter, r *http.Request) {
-	userID := auth.UserIDFromContext(r.Context())
-
-	rows, err := h.Pool.Query(r.Context(),
-		`SELECT g.id, g.instance_id, COALESCE(i.domain, ''), g.owner_id, g.name, g.description, g.icon_id,
-		        g.banner_id, g.default_permissions, g.flags, g.nsfw, g.discoverable,
-		        g.preferred_locale, g.max_members, g.vanity_url,
-		        g.verification_level, g.afk_channel_id, g.afk_timeout,
-		        g.tags, g.member_count, g.created_at
-		 FROM guilds g
-		 JOIN guild_members gm ON g.id = gm.guild_id
-		 LEFT JOIN instances i ON i.id = g.instance_id
-		 WHERE gm.user_id = $1
-		 ORDER BY g.name`,
-		userID,
-	)
-	if err != nil {
-		apiutil.InternalError(w, h.Logger, "Failed to get guilds", err)
-		return
-	}
-	defer rows.Close()
-
-	guilds := make([]models.Guild, 0)
-	for rows.Next() {
-		var g models.Guild
-		if err := rows.Scan(
-			&g.ID, &g.InstanceID, &g.InstanceDomain, &g.OwnerID, &g.Name, &g.Description, &g.IconID,
-			&g.BannerID, &g.DefaultPermissions, &g.Flags, &g.NSFW, &g.Discoverable,
-			&g.PreferredLocale, &g.MaxMembers, &g.VanityURL,
-			&g.VerificationLevel, &g.AFKChannelID, &g.AFKTimeout,
-			&g.Tags, &g.MemberCount, &g.CreatedAt,
-		); err != nil {
-			apiutil.InternalError(w, h.Logger, "Failed to read guilds", err)
-			return
-		}
-		guilds = append(guilds, g)
-	}
-
-	apiutil.WriteJSON(w, http.StatusOK, guilds)
-}
-
 // HandleGetSelfDMs returns the DM and group channels the authenticated user
 // is a participant in.
 // GET /api/v1/users/@me/dms
@@ -297,7 +263,22 @@ func (h *Handler) HandleCreateDM(w http.ResponseWriter, r *http.Request) {
 	var exists bool
 	err := h.Pool.QueryRow(r.Context(), `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, targetID).Scan(&exists)
 	if err != nil || !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "Target user not found")
+		apiutil.WriteCode(w, apierrors.UserNotFound, "Target user not found")
+		return
+	}
+
+	// Reopening an existing DM is free; only new ones count against the
+	// DM open rate class.
+	var dmExists bool
+	h.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(
+			SELECT 1 FROM channels c
+			JOIN channel_recipients cr1 ON c.id = cr1.channel_id AND cr1.user_id = $1
+			JOIN channel_recipients cr2 ON c.id = cr2.channel_id AND cr2.user_id = $2
+			WHERE c.channel_type = 'dm')`,
+		userID, targetID,
+	).Scan(&dmExists)
+	if !dmExists && !h.allowDMOpen(w, r, userID, []string{targetID}) {
 		return
 	}
 
@@ -563,7 +544,7 @@ func (h *Handler) HandleBlockUser(w http.ResponseWriter, r *http.Request) {
 	if err := h.Pool.QueryRow(r.Context(),
 		`SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, targetID,
 	).Scan(&exists); err != nil || !exists {
-		apiutil.WriteError(w, http.StatusNotFound, "user_not_found", "Target user not found")
+		apiutil.WriteCode(w, apierrors.UserNotFound, "Target user not found")
 		return
 	}
 
@@ -703,7 +684,8 @@ func (h *Handler) HandleUnblockUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// HandleUpdateBlockLevel updates the block level for an existing block.
+// HandleUpdateBlockLevel updates the block level for an existing block and
+// emits a RELATIONSHIP_UPDATE event.
 // PATCH /api/v1/users/{userID}/block
 func (h *Handler) HandleUpdateBlockLevel(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
@@ -731,10 +713,17 @@ func (h *Handler) HandleUpdateBlockLevel(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if tag.RowsAffected() == 0 {
-		apiutil.WriteError(w, http.StatusNotFound, "not_found", "Block not found")
+		apiutil.WriteCode(w, apierrors.NotFound, "Block not found")
 		return
 	}
 
+	h.EventBus.PublishUserEvent(r.Context(), events.SubjectRelationshipUpdate, "RELATIONSHIP_UPDATE", targetID, map[string]string{
+		"user_id":   userID,
+		"target_id": targetID,
+		"status":    models.RelationshipBlocked,
+		"level":     req.Level,
+	})
+
 	apiutil.WriteJSON(w, http.StatusOK, map[string]string{"level": req.Level})
 }
 
@@ -788,7 +777,7 @@ func (h *Handler) HandleGetBlockedUsers(w http.ResponseWriter, r *http.Request)
 func (h *Handler) HandleGetSelfSessions(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		apiutil.WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		apiutil.WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -837,7 +826,7 @@ func (h *Handler) HandleGetSelfSessions(w http.ResponseWriter, r *http.Request)
 func (h *Handler) HandleDeleteSelfSession(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
-		apiutil.WriteError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+		apiutil.WriteCode(w, apierrors.Unauthorized, "")
 		return
 	}
 
@@ -916,6 +905,76 @@ func (h *Handler) HandleGetSelfReadState(w http.ResponseWriter, r *http.Request)
 	apiutil.WriteJSON(w, http.StatusOK, states)
 }
 
+// HandleAckAll marks every channel the user can read as read: the readable
+// channels of all their guilds and their DMs and group DMs. One
+// CHANNELS_ACK is sent for the lot, and DM participants get read receipts.
+// POST /api/v1/users/@me/ack
+func (h *Handler) HandleAckAll(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	ctx := r.Context()
+
+	var guildIDs []string
+	rows, err := h.Pool.Query(ctx, `SELECT guild_id FROM guild_members WHERE user_id = $1`, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list guilds", err)
+		return
+	}
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			guildIDs = append(guildIDs, id)
+		}
+	}
+	rows.Close()
+
+	var channelIDs []string
+	for _, guildID := range guildIDs {
+		ids, err := apiutil.ReadableGuildChannels(ctx, h.Pool, guildID, userID)
+		if err == pgx.ErrNoRows {
+			continue // left the guild meanwhile
+		}
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to list guild channels", err)
+			return
+		}
+		channelIDs = append(channelIDs, ids...)
+	}
+
+	dmIDs := make(map[string]bool)
+	rows, err = h.Pool.Query(ctx,
+		`SELECT c.id FROM channels c
+		 JOIN channel_recipients cr ON cr.channel_id = c.id
+		 WHERE cr.user_id = $1 AND c.channel_type IN ('dm', 'group')`, userID)
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to list DMs", err)
+		return
+	}
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			dmIDs[id] = true
+			channelIDs = append(channelIDs, id)
+		}
+	}
+	rows.Close()
+
+	acked, err := apiutil.AckChannels(ctx, h.Pool, h.EventBus, userID, nil, channelIDs)
+	if err != nil {
+		h.Logger.Error("failed to ack all channels", slog.String("error", err.Error()))
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to mark everything as read")
+		return
+	}
+	for channelID, messageID := range acked {
+		if !dmIDs[channelID] {
+			continue
+		}
+		if err := apiutil.PublishDMRead(ctx, h.Pool, h.EventBus, channelID, userID, messageID); err != nil {
+			h.Logger.Warn("failed to publish DM read receipt", slog.String("error", err.Error()))
+		}
+	}
+	apiutil.WriteNoContent(w)
+}
+
 // HandleDeleteSelf soft-deletes the authenticated user's account.
 // The account is flagged as deleted and personal data is cleared.
 // DELETE /api/v1/users/@me
@@ -941,6 +1000,7 @@ func (h *Handler) HandleDeleteSelf(w http.ResponseWriter, r *http.Request) {
 		if _, err := tx.Exec(r.Context(),
 			`UPDATE users SET
 				flags = flags | $2,
+				deleted_at = now(),
 				display_name = NULL,
 				avatar_id = NULL,
 				status_text = NULL,
@@ -1055,15 +1115,13 @@ func (h *Handler) HandleSetUserNote(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// HandleGetUserSettings returns the authenticated user's client settings.
+// HandleGetUserSettings returns the authenticated user's client settings,
+// with their version.
 // GET /api/v1/users/@me/settings
 func (h *Handler) HandleGetUserSettings(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 
-	var settings json.RawMessage
-	err := h.Pool.QueryRow(r.Context(),
-		`SELECT settings FROM user_settings WHERE user_id = $1`, userID,
-	).Scan(&settings)
+	settings, err := h.loadUserSettings(r.Context(), userID)
 	if err == pgx.ErrNoRows {
 		apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{})
 		return
@@ -1078,7 +1136,9 @@ func (h *Handler) HandleGetUserSettings(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(map[string]interface{}{"data": settings})
 }
 
-// HandleUpdateUserSettings updates the authenticated user's client settings.
+// HandleUpdateUserSettings replaces the authenticated user's client
+// settings. A "version" key, if present, must match the stored settings'
+// version and is not saved.
 // PATCH /api/v1/users/@me/settings
 func (h *Handler) HandleUpdateUserSettings(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
@@ -1088,11 +1148,39 @@ func (h *Handler) HandleUpdateUserSettings(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	_, err := h.Pool.Exec(r.Context(),
+	var expected *int64
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(settings, &fields) == nil {
+		if raw, ok := fields["version"]; ok {
+			var v int64
+			if err := json.Unmarshal(raw, &v); err != nil {
+				apiutil.WriteError(w, http.StatusBadRequest, "invalid_version", "version must be an integer")
+				return
+			}
+			expected = &v
+			delete(fields, "version")
+			settings, _ = json.Marshal(fields)
+		}
+	}
+
+	var version int64
+	err := h.Pool.QueryRow(r.Context(),
 		`INSERT INTO user_settings (user_id, settings, updated_at)
 		 VALUES ($1, $2, now())
-		 ON CONFLICT (user_id) DO UPDATE SET settings = $2, updated_at = now()`,
-		userID, settings)
+		 ON CONFLICT (user_id) DO UPDATE SET
+		     settings = $2, updated_at = now(), version = user_settings.version + 1
+		 WHERE $3::bigint IS NULL OR user_settings.version = $3
+		 RETURNING version`,
+		userID, settings, expected).Scan(&version)
+	if err == pgx.ErrNoRows {
+		current, err := h.loadUserSettings(r.Context(), userID)
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to get settings", err)
+			return
+		}
+		apiutil.WriteVersionConflict(w, current)
+		return
+	}
 	if err != nil {
 		apiutil.InternalError(w, h.Logger, "Failed to update settings", err)
 		return
@@ -1100,7 +1188,34 @@ func (h *Handler) HandleUpdateUserSettings(w http.ResponseWriter, r *http.Reques
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{"data": settings})
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": withSettingsVersion(settings, version)})
+}
+
+// loadUserSettings returns the user's stored settings with their version.
+func (h *Handler) loadUserSettings(ctx context.Context, userID string) (json.RawMessage, error) {
+	var settings json.RawMessage
+	var version int64
+	if err := h.Pool.QueryRow(ctx,
+		`SELECT settings, version FROM user_settings WHERE user_id = $1`, userID,
+	).Scan(&settings, &version); err != nil {
+		return nil, err
+	}
+	return withSettingsVersion(settings, version), nil
+}
+
+// withSettingsVersion adds the version to a settings object. Settings that
+// are not an object are returned unchanged.
+func withSettingsVersion(settings json.RawMessage, version int64) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(settings, &fields) != nil || fields == nil {
+		return settings
+	}
+	fields["version"] = json.RawMessage(strconv.FormatInt(version, 10))
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return settings
+	}
+	return out
 }
 
 // HandleGetMutualFriends returns mutual friends between the current user and a target.
@@ -1336,7 +1451,8 @@ func (h *Handler) getUser(ctx context.Context, userID string) (*models.User, err
 		`SELECT id, instance_id, username, display_name, avatar_id, status_text,
 		        status_emoji, status_presence, status_expires_at, bio,
 		        banner_id, accent_color, pronouns,
-		        bot_owner_id, email, flags, last_online, created_at
+		        bot_owner_id, email, flags, last_online, created_at, share_federated_presence,
+		        read_receipts
 		 FROM users WHERE id = $1`,
 		userID,
 	).Scan(
@@ -1344,6 +1460,7 @@ func (h *Handler) getUser(ctx context.Context, userID string) (*models.User, err
 		&user.AvatarID, &user.StatusText, &user.StatusEmoji, &user.StatusPresence,
 		&user.StatusExpiresAt, &user.Bio, &user.BannerID, &user.AccentColor,
 		&user.Pronouns, &user.BotOwnerID, &user.Email, &user.Flags, &user.LastOnline, &user.CreatedAt,
+		&user.ShareFederatedPresence, &user.ReadReceipts,
 	)
 	return &user, err
 }
@@ -1361,20 +1478,24 @@ func (h *Handler) updateUser(ctx context.Context, userID string, req updateSelfR
 			status_expires_at = COALESCE($8, status_expires_at),
 			banner_id = COALESCE($9, banner_id),
 			accent_color = COALESCE($10, accent_color),
-			pronouns = COALESCE($11, pronouns)
+			pronouns = COALESCE($11, pronouns),
+			share_federated_presence = COALESCE($12, share_federated_presence),
+			read_receipts = COALESCE($13, read_receipts)
 		 WHERE id = $1
 		 RETURNING id, instance_id, username, display_name, avatar_id, status_text,
 		           status_emoji, status_presence, status_expires_at, bio,
 		           banner_id, accent_color, pronouns,
-		           bot_owner_id, email, flags, last_online, created_at`,
+		           bot_owner_id, email, flags, last_online, created_at, share_federated_presence,
+		           read_receipts`,
 		userID, req.DisplayName, req.AvatarID, req.StatusText, req.Bio,
 		req.StatusEmoji, req.StatusPresence, statusExpiresAt,
-		req.BannerID, req.AccentColor, req.Pronouns,
+		req.BannerID, req.AccentColor, req.Pronouns, req.ShareFederatedPresence, req.ReadReceipts,
 	).Scan(
 		&user.ID, &user.InstanceID, &user.Username, &user.DisplayName,
 		&user.AvatarID, &user.StatusText, &user.StatusEmoji, &user.StatusPresence,
 		&user.StatusExpiresAt, &user.Bio, &user.BannerID, &user.AccentColor,
 		&user.Pronouns, &user.BotOwnerID, &user.Email, &user.Flags, &user.LastOnline, &user.CreatedAt,
+		&user.ShareFederatedPresence, &user.ReadReceipts,
 	)
 	return &user, err
 }
@@ -1449,7 +1570,7 @@ func (h *Handler) HandleCreateLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if req.Platform == "" || req.Label == "" || req.URL == "" {
-		apiutil.WriteError(w, http.StatusBadRequest, "missing_fields", "platform, label, and url are required")
+		apiutil.WriteCode(w, apierrors.MissingFields, "platform, label, and url are required")
 		return
 	}
 	if !isValidLinkURL(req.URL) {
@@ -1636,6 +1757,9 @@ func (h *Handler) HandleCreateGroupDM(w http.ResponseWriter, r *http.Request) {
 		apiutil.WriteError(w, http.StatusBadRequest, "user_not_found", "One or more users not found")
 		return
 	}
+	if !h.allowDMOpen(w, r, userID, req.UserIDs) {
+		return
+	}
 
 	// Create the group DM channel in a transaction.
 	newID := models.NewULID().String()
@@ -1751,9 +1875,19 @@ func (h *Handler) HandleUpdateGuildPositions(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	guildIDs := make([]string, 0, len(positions))
+	for _, p := range positions {
+		if p.GuildID != "" {
+			guildIDs = append(guildIDs, p.GuildID)
+		}
+	}
+
+	// Rows of listed guilds are updated in place so their folders survive
+	// a reorder.
 	if err := apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
 		if _, err := tx.Exec(r.Context(),
-			`DELETE FROM user_guild_positions WHERE user_id = $1`, userID); err != nil {
+			`DELETE FROM user_guild_positions WHERE user_id = $1 AND NOT (guild_id = ANY($2))`,
+			userID, guildIDs); err != nil {
 			return err
 		}
 		for _, p := range positions {
@@ -1774,5 +1908,6 @@ func (h *Handler) HandleUpdateGuildPositions(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	h.publishGuildLayout(r.Context(), userID)
 	w.WriteHeader(http.StatusNoContent)
 }
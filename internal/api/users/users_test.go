@@ -5,8 +5,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
 )
 
 func TestWriteJSON(t *testing.T) {
@@ -123,3 +125,69 @@ func TestWriteError_Various(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateContentSettings(t *testing.T) {
+	s := models.DefaultUserContentSettings()
+	for _, mode := range []string{models.NSFWMediaShow, models.NSFWMediaBlur, models.NSFWMediaHide} {
+		s.NSFWMedia = mode
+		if msg := validateContentSettings(s); msg != "" {
+			t.Errorf("nsfw_media %q rejected: %s", mode, msg)
+		}
+	}
+	s.NSFWMedia = "pixelate"
+	if validateContentSettings(s) == "" {
+		t.Error("unknown nsfw_media accepted")
+	}
+}
+
+func TestWithSettingsVersion(t *testing.T) {
+	got := withSettingsVersion(json.RawMessage(`{"custom_css":"a{}"}`), 4)
+	var fields map[string]interface{}
+	if err := json.Unmarshal(got, &fields); err != nil {
+		t.Fatalf("failed to parse settings: %v", err)
+	}
+	if fields["version"] != float64(4) {
+		t.Errorf("version = %v, want 4", fields["version"])
+	}
+	if fields["custom_css"] != "a{}" {
+		t.Errorf("custom_css = %v, want a{}", fields["custom_css"])
+	}
+
+	// Settings that are not an object are left alone.
+	if got := withSettingsVersion(json.RawMessage(`[1,2]`), 4); string(got) != `[1,2]` {
+		t.Errorf("array settings = %s, want [1,2]", got)
+	}
+}
+
+func TestWriteVersionConflict(t *testing.T) {
+	w := httptest.NewRecorder()
+	apiutil.WriteVersionConflict(w, map[string]int64{"version": 7})
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	var resp struct {
+		Error apiutil.ErrorBody `json:"error"`
+		Data  struct {
+			Version int64 `json:"version"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.Error.Code != "version_conflict" {
+		t.Errorf("error code = %q, want version_conflict", resp.Error.Code)
+	}
+	if resp.Data.Version != 7 {
+		t.Errorf("current version = %d, want 7", resp.Data.Version)
+	}
+}
+
+func TestTranscriptWatermark(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 0, 0, time.FixedZone("", 2*60*60))
+	got := transcriptWatermark("alice", "user1", at, "link1")
+	want := "Exported by @alice (user1) on 2026-03-01T10:30:00Z via transcript link link1"
+	if got != want {
+		t.Errorf("transcriptWatermark = %q, want %q", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -12,11 +13,13 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oklog/ulid/v2"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/permissions"
+	"github.com/amityvox/amityvox/internal/regions"
 	"github.com/amityvox/amityvox/internal/voice"
 )
 
@@ -25,20 +28,17 @@ func newVoiceULID() string {
 	return ulid.MustNew(ulid.Timestamp(time.Now()), rand.Reader).String()
 }
 
-// liveKitPublicURL returns the browser-facing LiveKit URL, falling back to the
-// internal URL if no public URL is configured.
-func (s *Server) liveKitPublicURL() string {
-	if s.Config.LiveKit.PublicURL != "" {
-		return s.Config.LiveKit.PublicURL
-	}
-	return s.Config.LiveKit.URL
+// clientRegion returns the deployment region a geo-aware load balancer
+// reported for the request's client, or "".
+func (s *Server) clientRegion(r *http.Request) string {
+	return regions.ClientRegion(r, s.Config.Regions.ClientRegionHeader)
 }
 
 // handleVoiceJoin generates a LiveKit token for a user to join a voice channel.
 // POST /api/v1/voice/{channelID}/join
 func (s *Server) handleVoiceJoin(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -52,11 +52,11 @@ func (s *Server) handleVoiceJoin(w http.ResponseWriter, r *http.Request) {
 		`SELECT channel_type, guild_id FROM channels WHERE id = $1`, channelID,
 	).Scan(&channelType, &guildID)
 	if err == pgx.ErrNoRows {
-		WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get channel")
+		WriteCode(w, apierrors.InternalError, "Failed to get channel")
 		return
 	}
 
@@ -65,6 +65,11 @@ func (s *Server) handleVoiceJoin(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusBadRequest, "not_voice_channel", "Voice is not supported in this channel type")
 		return
 	}
+	isCall := channelType == models.ChannelTypeDM || channelType == models.ChannelTypeGroup
+	if isCall && !s.isChannelRecipient(r.Context(), channelID, userID) {
+		WriteError(w, http.StatusForbidden, "not_recipient", "You are not a recipient of this channel")
+		return
+	}
 
 	// Check if this is a remote (federated) guild channel.
 	if guildID != nil {
@@ -91,19 +96,20 @@ func (s *Server) handleVoiceJoin(w http.ResponseWriter, r *http.Request) {
 	// Check Connect permission.
 	if guildID != nil {
 		if !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, userID, permissions.Connect) {
-			WriteError(w, http.StatusForbidden, "missing_permission", "You need CONNECT permission")
+			WriteCode(w, apierrors.MissingPermission, "You need CONNECT permission")
+			return
+		}
+		if apiutil.GuildRulesPending(r.Context(), s.DB.Pool, *guildID, userID) {
+			WriteError(w, http.StatusForbidden, "rules_not_accepted", "You must accept the guild rules before joining voice")
 			return
 		}
 	}
 
-	// Check Speak permission for publish rights.
-	canSpeak := true
-	if guildID != nil {
-		canSpeak = checkGuildPerm(r.Context(), s.DB.Pool, *guildID, userID, permissions.Speak)
-	}
+	grant := s.voiceGrant(r.Context(), guildID, channelID, userID)
 
-	// Ensure the LiveKit room exists.
-	if err := s.Voice.EnsureRoom(r.Context(), channelID); err != nil {
+	// Ensure the LiveKit room exists, in the region nearest the caller if
+	// it is new.
+	if err := s.Voice.EnsureRoomIn(r.Context(), channelID, s.clientRegion(r)); err != nil {
 		s.Logger.Error("failed to ensure voice room", "error", err.Error())
 	}
 
@@ -131,7 +137,7 @@ func (s *Server) handleVoiceJoin(w http.ResponseWriter, r *http.Request) {
 	metaBytes, _ := json.Marshal(metaMap)
 
 	// Generate LiveKit token.
-	token, err := s.Voice.GenerateToken(userID, channelID, canSpeak, true, canSpeak, string(metaBytes))
+	token, err := s.Voice.GenerateGrantedToken(userID, channelID, grant, string(metaBytes))
 	if err != nil {
 		InternalError(w, s.Logger, "Failed to generate voice token", err)
 		return
@@ -143,6 +149,7 @@ func (s *Server) handleVoiceJoin(w http.ResponseWriter, r *http.Request) {
 		gID = *guildID
 	}
 	s.Voice.UpdateVoiceState(userID, gID, channelID, false, false)
+	s.Voice.SetPTTRequired(userID, grant.InputMode == voice.InputModePTT)
 
 	// Publish VOICE_STATE_UPDATE event with user info for other clients.
 	voiceEvent := map[string]interface{}{
@@ -163,13 +170,25 @@ func (s *Server) handleVoiceJoin(w http.ResponseWriter, r *http.Request) {
 	s.EventBus.PublishGuildEvent(r.Context(), events.SubjectVoiceStateUpdate, "VOICE_STATE_UPDATE", gID, voiceEvent)
 
 	// For DM/Group channels, ring the other participants so they see an incoming call.
-	// Only ring if this is the first person joining (no ring for joining an active call).
-	channelStates := s.Voice.GetChannelVoiceStates(channelID)
-	if (channelType == models.ChannelTypeDM || channelType == models.ChannelTypeGroup) && len(channelStates) <= 1 {
+	// Only the join that starts a call rings; joining a ringing call answers it.
+	var call *voice.Call
+	ring := false
+	if isCall {
+		call, ring, err = s.Voice.JoinCall(r.Context(), channelID, userID)
+		if err != nil {
+			s.Logger.Warn("failed to record call", "error", err.Error())
+			ring = len(s.Voice.GetChannelVoiceStates(channelID)) <= 1
+		}
+	}
+	if ring {
 		ringEvent := map[string]interface{}{
-			"channel_id":  channelID,
-			"caller_id":   userID,
-			"caller_name": username,
+			"channel_id":      channelID,
+			"caller_id":       userID,
+			"caller_name":     username,
+			"timeout_seconds": int(voice.CallRingTimeout.Seconds()),
+		}
+		if call != nil {
+			ringEvent["call_id"] = call.ID
 		}
 		if displayName != nil {
 			ringEvent["caller_display_name"] = *displayName
@@ -191,7 +210,7 @@ func (s *Server) handleVoiceJoin(w http.ResponseWriter, r *http.Request) {
 
 	WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"token":      token,
-		"url":        s.liveKitPublicURL(),
+		"url":        s.Voice.RoomURL(r.Context(), channelID),
 		"channel_id": channelID,
 	})
 }
@@ -200,7 +219,7 @@ func (s *Server) handleVoiceJoin(w http.ResponseWriter, r *http.Request) {
 // POST /api/v1/voice/{channelID}/leave
 func (s *Server) handleVoiceLeave(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -233,14 +252,131 @@ func (s *Server) handleVoiceLeave(w http.ResponseWriter, r *http.Request) {
 		"action":     "leave",
 	})
 
+	// The last one out of a DM call ends it.
+	if guildID == nil {
+		s.Voice.EndCallIfEmpty(r.Context(), channelID)
+	}
+
 	WriteNoContent(w)
 }
 
+// voiceGrant works out what a user may publish in a voice channel: the
+// microphone and camera with SPEAK, and screen shares with SCREEN_SHARE
+// within the guild's quality caps. The microphone comes with the channel's
+// required input mode. Nothing may be published in the guild's AFK channel,
+// and DM calls may publish everything.
+func (s *Server) voiceGrant(ctx context.Context, guildID *string, channelID, userID string) voice.PublishGrant {
+	if guildID == nil {
+		return voice.PublishGrant{Microphone: true, Camera: true, ScreenShare: true}
+	}
+
+	var isAFK bool
+	s.DB.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM guilds WHERE id = $1 AND afk_channel_id = $2)`,
+		*guildID, channelID).Scan(&isAFK)
+	if isAFK {
+		return voice.PublishGrant{}
+	}
+
+	canSpeak := checkGuildPerm(ctx, s.DB.Pool, *guildID, userID, permissions.Speak)
+	grant := voice.PublishGrant{
+		Microphone:  canSpeak,
+		Camera:      canSpeak,
+		ScreenShare: checkGuildPerm(ctx, s.DB.Pool, *guildID, userID, permissions.ScreenShare),
+	}
+	if grant.ScreenShare {
+		caps, err := s.Voice.GetScreenShareConfig(ctx, *guildID)
+		if err != nil {
+			s.Logger.Warn("failed to load screen share caps", "error", err.Error())
+		}
+		grant.ScreenShareCaps = caps
+	}
+	if grant.Microphone {
+		mode, err := s.Voice.GetChannelInputMode(ctx, *guildID, channelID)
+		if err != nil {
+			s.Logger.Warn("failed to load channel input mode", "error", err.Error())
+		} else {
+			grant.InputMode = mode.InputMode
+		}
+	}
+	return grant
+}
+
+// isChannelRecipient reports whether a user is a recipient of a DM or group DM.
+func (s *Server) isChannelRecipient(ctx context.Context, channelID, userID string) bool {
+	var ok bool
+	s.DB.Pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2)`,
+		channelID, userID).Scan(&ok)
+	return ok
+}
+
+// handleDeclineCall declines a ringing DM call. The call ends once every
+// recipient has declined.
+// POST /api/v1/voice/{channelID}/call/decline
+func (s *Server) handleDeclineCall(w http.ResponseWriter, r *http.Request) {
+	if s.Voice == nil {
+		WriteCode(w, apierrors.VoiceDisabled, "")
+		return
+	}
+
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	if !s.isChannelRecipient(r.Context(), channelID, userID) {
+		WriteError(w, http.StatusForbidden, "not_recipient", "You are not a recipient of this channel")
+		return
+	}
+
+	call, err := s.Voice.DeclineCall(r.Context(), channelID, userID)
+	if err == pgx.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "no_ringing_call", "No call is ringing for you in this channel")
+		return
+	}
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to decline call", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, call)
+}
+
+// handleGetCallLog lists a DM or group DM's calls, newest first.
+// GET /api/v1/voice/{channelID}/calls?before=&limit=
+func (s *Server) handleGetCallLog(w http.ResponseWriter, r *http.Request) {
+	if s.Voice == nil {
+		WriteCode(w, apierrors.VoiceDisabled, "")
+		return
+	}
+
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	if !s.isChannelRecipient(r.Context(), channelID, userID) {
+		WriteError(w, http.StatusForbidden, "not_recipient", "You are not a recipient of this channel")
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			WriteError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	calls, err := s.Voice.GetCallLog(r.Context(), channelID, r.URL.Query().Get("before"), limit)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to get call log", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, calls)
+}
+
 // handleVoiceServerMute server-mutes/unmutes a user in a voice channel.
 // POST /api/v1/voice/{channelID}/members/{userID}/mute
 func (s *Server) handleVoiceServerMute(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -253,7 +389,7 @@ func (s *Server) handleVoiceServerMute(w http.ResponseWriter, r *http.Request) {
 	err := s.DB.Pool.QueryRow(r.Context(),
 		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
 	if err != nil {
-		WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if guildID == nil {
@@ -263,7 +399,7 @@ func (s *Server) handleVoiceServerMute(w http.ResponseWriter, r *http.Request) {
 
 	// Check MuteMembers permission.
 	if !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, actorID, permissions.MuteMembers) {
-		WriteError(w, http.StatusForbidden, "missing_permission", "You need MUTE_MEMBERS permission")
+		WriteCode(w, apierrors.MissingPermission, "You need MUTE_MEMBERS permission")
 		return
 	}
 
@@ -309,7 +445,7 @@ func (s *Server) handleVoiceServerMute(w http.ResponseWriter, r *http.Request) {
 // POST /api/v1/voice/{channelID}/members/{userID}/deafen
 func (s *Server) handleVoiceServerDeafen(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -322,7 +458,7 @@ func (s *Server) handleVoiceServerDeafen(w http.ResponseWriter, r *http.Request)
 	err := s.DB.Pool.QueryRow(r.Context(),
 		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
 	if err != nil {
-		WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if guildID == nil {
@@ -332,7 +468,7 @@ func (s *Server) handleVoiceServerDeafen(w http.ResponseWriter, r *http.Request)
 
 	// Check DeafenMembers permission.
 	if !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, actorID, permissions.DeafenMembers) {
-		WriteError(w, http.StatusForbidden, "missing_permission", "You need DEAFEN_MEMBERS permission")
+		WriteCode(w, apierrors.MissingPermission, "You need DEAFEN_MEMBERS permission")
 		return
 	}
 
@@ -378,7 +514,7 @@ func (s *Server) handleVoiceServerDeafen(w http.ResponseWriter, r *http.Request)
 // POST /api/v1/voice/{channelID}/members/{userID}/move
 func (s *Server) handleVoiceMoveUser(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -391,7 +527,7 @@ func (s *Server) handleVoiceMoveUser(w http.ResponseWriter, r *http.Request) {
 	err := s.DB.Pool.QueryRow(r.Context(),
 		`SELECT guild_id FROM channels WHERE id = $1`, sourceChannelID).Scan(&guildID)
 	if err != nil {
-		WriteError(w, http.StatusNotFound, "channel_not_found", "Source channel not found")
+		WriteCode(w, apierrors.ChannelNotFound, "Source channel not found")
 		return
 	}
 	if guildID == nil {
@@ -401,7 +537,7 @@ func (s *Server) handleVoiceMoveUser(w http.ResponseWriter, r *http.Request) {
 
 	// Check MoveMembers permission.
 	if !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, actorID, permissions.MoveMembers) {
-		WriteError(w, http.StatusForbidden, "missing_permission", "You need MOVE_MEMBERS permission")
+		WriteCode(w, apierrors.MissingPermission, "You need MOVE_MEMBERS permission")
 		return
 	}
 
@@ -410,7 +546,7 @@ func (s *Server) handleVoiceMoveUser(w http.ResponseWriter, r *http.Request) {
 		TargetChannelID string `json:"target_channel_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TargetChannelID == "" {
-		WriteError(w, http.StatusBadRequest, "invalid_body", "target_channel_id is required")
+		WriteCode(w, apierrors.InvalidBody, "target_channel_id is required")
 		return
 	}
 
@@ -421,7 +557,7 @@ func (s *Server) handleVoiceMoveUser(w http.ResponseWriter, r *http.Request) {
 		`SELECT channel_type, guild_id FROM channels WHERE id = $1`, req.TargetChannelID,
 	).Scan(&targetType, &targetGuildID)
 	if err != nil {
-		WriteError(w, http.StatusNotFound, "channel_not_found", "Target channel not found")
+		WriteCode(w, apierrors.ChannelNotFound, "Target channel not found")
 		return
 	}
 	if targetGuildID == nil || *targetGuildID != *guildID {
@@ -446,7 +582,7 @@ func (s *Server) handleVoiceMoveUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Ensure target room exists.
-	if err := s.Voice.EnsureRoom(r.Context(), req.TargetChannelID); err != nil {
+	if err := s.Voice.EnsureRoomIn(r.Context(), req.TargetChannelID, s.clientRegion(r)); err != nil {
 		s.Logger.Warn("failed to ensure target voice room", "error", err.Error())
 	}
 
@@ -474,8 +610,9 @@ func (s *Server) handleVoiceMoveUser(w http.ResponseWriter, r *http.Request) {
 	})
 
 	// Generate a new token for the target channel so the client can reconnect.
-	canSpeak := checkGuildPerm(r.Context(), s.DB.Pool, *guildID, targetUserID, permissions.Speak)
-	token, err := s.Voice.GenerateToken(targetUserID, req.TargetChannelID, canSpeak, true, canSpeak, "")
+	grant := s.voiceGrant(r.Context(), guildID, req.TargetChannelID, targetUserID)
+	s.Voice.SetPTTRequired(targetUserID, grant.InputMode == voice.InputModePTT)
+	token, err := s.Voice.GenerateGrantedToken(targetUserID, req.TargetChannelID, grant, "")
 	if err != nil {
 		InternalError(w, s.Logger, "Failed to generate voice token for move", err)
 		return
@@ -483,16 +620,39 @@ func (s *Server) handleVoiceMoveUser(w http.ResponseWriter, r *http.Request) {
 
 	WriteJSON(w, http.StatusOK, map[string]interface{}{
 		"token":      token,
-		"url":        s.liveKitPublicURL(),
+		"url":        s.Voice.RoomURL(r.Context(), req.TargetChannelID),
 		"channel_id": req.TargetChannelID,
 	})
 }
 
+// handleLiveKitWebhook receives LiveKit's webhooks, which must be configured
+// to post to this endpoint signed with the instance's LiveKit API key.
+// POST /api/v1/voice/webhook
+func (s *Server) handleLiveKitWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.Voice == nil {
+		WriteCode(w, apierrors.VoiceDisabled, "")
+		return
+	}
+
+	event, err := s.Voice.ReceiveWebhook(r)
+	if err == voice.ErrInvalidWebhook {
+		WriteError(w, http.StatusUnauthorized, "invalid_signature", "Webhook signature is invalid")
+		return
+	}
+	if err != nil {
+		WriteCode(w, apierrors.InvalidBody, "Invalid webhook payload")
+		return
+	}
+
+	s.Voice.HandleWebhookEvent(r.Context(), event)
+	WriteNoContent(w)
+}
+
 // handleGetVoiceStates returns the current voice states for a channel.
 // GET /api/v1/voice/{channelID}/states
 func (s *Server) handleGetVoiceStates(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -512,7 +672,7 @@ func (s *Server) handleGetVoiceStates(w http.ResponseWriter, r *http.Request) {
 // GET /api/v1/voice/preferences
 func (s *Server) handleGetVoicePreferences(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -530,7 +690,7 @@ func (s *Server) handleGetVoicePreferences(w http.ResponseWriter, r *http.Reques
 // PATCH /api/v1/voice/preferences
 func (s *Server) handleUpdateVoicePreferences(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -668,7 +828,7 @@ func (s *Server) handleUpdateVoicePreferences(w http.ResponseWriter, r *http.Req
 // POST /api/v1/voice/{channelID}/input-mode
 func (s *Server) handleSetInputMode(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -692,6 +852,10 @@ func (s *Server) handleSetInputMode(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusBadRequest, "not_in_channel", "You are not in this voice channel")
 		return
 	}
+	if vs.PTTRequired && req.Mode != voice.InputModePTT {
+		WriteError(w, http.StatusForbidden, "ptt_required", "This channel requires push-to-talk")
+		return
+	}
 
 	s.Voice.SetInputMode(userID, req.Mode)
 
@@ -716,7 +880,7 @@ func (s *Server) handleSetInputMode(w http.ResponseWriter, r *http.Request) {
 // POST /api/v1/voice/{channelID}/members/{userID}/priority
 func (s *Server) handleSetPrioritySpeaker(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -729,7 +893,7 @@ func (s *Server) handleSetPrioritySpeaker(w http.ResponseWriter, r *http.Request
 	err := s.DB.Pool.QueryRow(r.Context(),
 		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
 	if err != nil {
-		WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if guildID == nil {
@@ -741,12 +905,12 @@ func (s *Server) handleSetPrioritySpeaker(w http.ResponseWriter, r *http.Request
 	// or an admin can set others.
 	if actorID != targetUserID {
 		if !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, actorID, permissions.MoveMembers) {
-			WriteError(w, http.StatusForbidden, "missing_permission", "You need MOVE_MEMBERS permission to set others as priority speaker")
+			WriteCode(w, apierrors.MissingPermission, "You need MOVE_MEMBERS permission to set others as priority speaker")
 			return
 		}
 	} else {
 		if !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, actorID, permissions.PrioritySpeaker) {
-			WriteError(w, http.StatusForbidden, "missing_permission", "You need PRIORITY_SPEAKER permission")
+			WriteCode(w, apierrors.MissingPermission, "You need PRIORITY_SPEAKER permission")
 			return
 		}
 	}
@@ -785,7 +949,7 @@ func (s *Server) handleSetPrioritySpeaker(w http.ResponseWriter, r *http.Request
 // GET /api/v1/guilds/{guildID}/soundboard/sounds
 func (s *Server) handleGetSoundboardSounds(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -798,7 +962,7 @@ func (s *Server) handleGetSoundboardSounds(w http.ResponseWriter, r *http.Reques
 		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
 		guildID, userID).Scan(&exists)
 	if !exists {
-		WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -818,7 +982,7 @@ func (s *Server) handleGetSoundboardSounds(w http.ResponseWriter, r *http.Reques
 // POST /api/v1/guilds/{guildID}/soundboard/sounds
 func (s *Server) handleCreateSoundboardSound(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -827,7 +991,7 @@ func (s *Server) handleCreateSoundboardSound(w http.ResponseWriter, r *http.Requ
 
 	// Check ManageGuild permission for creating sounds.
 	if !checkGuildPerm(r.Context(), s.DB.Pool, guildID, userID, permissions.ManageGuild) {
-		WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission to create sounds")
+		WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission to create sounds")
 		return
 	}
 
@@ -904,7 +1068,7 @@ func (s *Server) handleCreateSoundboardSound(w http.ResponseWriter, r *http.Requ
 // DELETE /api/v1/guilds/{guildID}/soundboard/sounds/{soundID}
 func (s *Server) handleDeleteSoundboardSound(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -914,7 +1078,7 @@ func (s *Server) handleDeleteSoundboardSound(w http.ResponseWriter, r *http.Requ
 
 	// Check ManageGuild permission.
 	if !checkGuildPerm(r.Context(), s.DB.Pool, guildID, userID, permissions.ManageGuild) {
-		WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission to delete sounds")
+		WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission to delete sounds")
 		return
 	}
 
@@ -947,7 +1111,7 @@ func (s *Server) handleDeleteSoundboardSound(w http.ResponseWriter, r *http.Requ
 // POST /api/v1/guilds/{guildID}/soundboard/sounds/{soundID}/play
 func (s *Server) handlePlaySoundboardSound(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1023,7 +1187,7 @@ func (s *Server) handlePlaySoundboardSound(w http.ResponseWriter, r *http.Reques
 // GET /api/v1/guilds/{guildID}/soundboard/config
 func (s *Server) handleGetSoundboardConfig(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1036,7 +1200,7 @@ func (s *Server) handleGetSoundboardConfig(w http.ResponseWriter, r *http.Reques
 		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
 		guildID, userID).Scan(&exists)
 	if !exists {
-		WriteError(w, http.StatusForbidden, "not_member", "You are not a member of this guild")
+		WriteCode(w, apierrors.NotMember, "")
 		return
 	}
 
@@ -1053,7 +1217,7 @@ func (s *Server) handleGetSoundboardConfig(w http.ResponseWriter, r *http.Reques
 // PATCH /api/v1/guilds/{guildID}/soundboard/config
 func (s *Server) handleUpdateSoundboardConfig(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1062,7 +1226,7 @@ func (s *Server) handleUpdateSoundboardConfig(w http.ResponseWriter, r *http.Req
 
 	// Check ManageGuild permission.
 	if !checkGuildPerm(r.Context(), s.DB.Pool, guildID, userID, permissions.ManageGuild) {
-		WriteError(w, http.StatusForbidden, "missing_permission", "You need MANAGE_GUILD permission")
+		WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
 		return
 	}
 
@@ -1117,7 +1281,7 @@ func (s *Server) handleUpdateSoundboardConfig(w http.ResponseWriter, r *http.Req
 // POST /api/v1/voice/{channelID}/broadcast/start
 func (s *Server) handleStartBroadcast(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1131,11 +1295,11 @@ func (s *Server) handleStartBroadcast(w http.ResponseWriter, r *http.Request) {
 		`SELECT channel_type, guild_id FROM channels WHERE id = $1`, channelID,
 	).Scan(&channelType, &guildID)
 	if err == pgx.ErrNoRows {
-		WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get channel")
+		WriteCode(w, apierrors.InternalError, "Failed to get channel")
 		return
 	}
 	if channelType != models.ChannelTypeVoice && channelType != models.ChannelTypeStage {
@@ -1146,7 +1310,7 @@ func (s *Server) handleStartBroadcast(w http.ResponseWriter, r *http.Request) {
 	// Check permission — Speak + PrioritySpeaker to start a broadcast.
 	if guildID != nil {
 		if !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, userID, permissions.PrioritySpeaker) {
-			WriteError(w, http.StatusForbidden, "missing_permission", "You need PRIORITY_SPEAKER permission to broadcast")
+			WriteCode(w, apierrors.MissingPermission, "You need PRIORITY_SPEAKER permission to broadcast")
 			return
 		}
 	}
@@ -1209,7 +1373,7 @@ func (s *Server) handleStartBroadcast(w http.ResponseWriter, r *http.Request) {
 // POST /api/v1/voice/{channelID}/broadcast/stop
 func (s *Server) handleStopBroadcast(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1229,7 +1393,7 @@ func (s *Server) handleStopBroadcast(w http.ResponseWriter, r *http.Request) {
 		s.DB.Pool.QueryRow(r.Context(),
 			`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
 		if guildID == nil || !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, userID, permissions.MoveMembers) {
-			WriteError(w, http.StatusForbidden, "missing_permission", "Only the broadcaster or a moderator can stop the broadcast")
+			WriteCode(w, apierrors.MissingPermission, "Only the broadcaster or a moderator can stop the broadcast")
 			return
 		}
 	}
@@ -1256,7 +1420,7 @@ func (s *Server) handleStopBroadcast(w http.ResponseWriter, r *http.Request) {
 // GET /api/v1/voice/{channelID}/broadcast
 func (s *Server) handleGetBroadcast(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1277,7 +1441,7 @@ func (s *Server) handleGetBroadcast(w http.ResponseWriter, r *http.Request) {
 // POST /api/v1/voice/{channelID}/screen-share/start
 func (s *Server) handleStartScreenShare(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1291,11 +1455,11 @@ func (s *Server) handleStartScreenShare(w http.ResponseWriter, r *http.Request)
 		`SELECT channel_type, guild_id FROM channels WHERE id = $1`, channelID,
 	).Scan(&channelType, &guildID)
 	if err == pgx.ErrNoRows {
-		WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get channel")
+		WriteCode(w, apierrors.InternalError, "Failed to get channel")
 		return
 	}
 	if channelType != models.ChannelTypeVoice && channelType != models.ChannelTypeStage {
@@ -1303,10 +1467,10 @@ func (s *Server) handleStartScreenShare(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Check Stream permission.
+	// Check ScreenShare permission.
 	if guildID != nil {
-		if !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, userID, permissions.Stream) {
-			WriteError(w, http.StatusForbidden, "missing_permission", "You need STREAM permission to share your screen")
+		if !checkGuildPerm(r.Context(), s.DB.Pool, *guildID, userID, permissions.ScreenShare) {
+			WriteCode(w, apierrors.MissingPermission, "You need SCREEN_SHARE permission to share your screen")
 			return
 		}
 	}
@@ -1340,18 +1504,27 @@ func (s *Server) handleStartScreenShare(w http.ResponseWriter, r *http.Request)
 	if req.ShareType != "screen" && req.ShareType != "window" {
 		req.ShareType = "screen"
 	}
-	validResolutions := map[string]bool{"720p": true, "1080p": true, "4k": true}
-	if !validResolutions[req.Resolution] {
+	if !voice.ValidScreenShareResolution(req.Resolution) {
 		req.Resolution = "1080p"
 	}
-	validFramerates := map[int]bool{15: true, 30: true, 60: true}
-	if !validFramerates[req.Framerate] {
+	if !voice.ValidScreenShareFramerate(req.Framerate) {
 		req.Framerate = 30
 	}
 	if req.MaxViewers <= 0 || req.MaxViewers > 50 {
 		req.MaxViewers = 50
 	}
 
+	gID := ""
+	if guildID != nil {
+		gID = *guildID
+	}
+	caps, err := s.Voice.GetScreenShareConfig(r.Context(), gID)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to load screen share limits", err)
+		return
+	}
+	req.Resolution, req.Framerate = caps.Clamp(req.Resolution, req.Framerate)
+
 	session := &voice.ScreenShareSession{
 		ID:           newVoiceULID(),
 		ChannelID:    channelID,
@@ -1370,11 +1543,6 @@ func (s *Server) handleStartScreenShare(w http.ResponseWriter, r *http.Request)
 
 	s.Voice.SetScreenSharing(userID, true)
 
-	gID := ""
-	if guildID != nil {
-		gID = *guildID
-	}
-
 	// Publish screen share start event.
 	s.EventBus.PublishGuildEvent(r.Context(), events.SubjectVoiceStateUpdate, "SCREEN_SHARE_START", gID, map[string]interface{}{
 		"session_id":    session.ID,
@@ -1389,8 +1557,8 @@ func (s *Server) handleStartScreenShare(w http.ResponseWriter, r *http.Request)
 	})
 
 	// Generate a LiveKit token with screen share publish permission.
-	canPublish := true
-	token, err := s.Voice.GenerateToken(userID, channelID, canPublish, true, canPublish, "")
+	grant := s.voiceGrant(r.Context(), guildID, channelID, userID)
+	token, err := s.Voice.GenerateGrantedToken(userID, channelID, grant, "")
 	if err != nil {
 		s.Logger.Error("failed to generate screen share token", "error", err.Error())
 	}
@@ -1398,7 +1566,7 @@ func (s *Server) handleStartScreenShare(w http.ResponseWriter, r *http.Request)
 	WriteJSON(w, http.StatusCreated, map[string]interface{}{
 		"session": session,
 		"token":   token,
-		"url":     s.liveKitPublicURL(),
+		"url":     s.Voice.RoomURL(r.Context(), channelID),
 	})
 }
 
@@ -1406,7 +1574,7 @@ func (s *Server) handleStartScreenShare(w http.ResponseWriter, r *http.Request)
 // POST /api/v1/voice/{channelID}/screen-share/stop
 func (s *Server) handleStopScreenShare(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1450,7 +1618,7 @@ func (s *Server) handleStopScreenShare(w http.ResponseWriter, r *http.Request) {
 // PATCH /api/v1/voice/{channelID}/screen-share
 func (s *Server) handleUpdateScreenShare(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1472,23 +1640,43 @@ func (s *Server) handleUpdateScreenShare(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	var guildID *string
+	s.DB.Pool.QueryRow(r.Context(),
+		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	gID := ""
+	if guildID != nil {
+		gID = *guildID
+	}
+	caps, err := s.Voice.GetScreenShareConfig(r.Context(), gID)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to load screen share limits", err)
+		return
+	}
+
 	// Build SET clause dynamically.
 	updates := make(map[string]interface{})
 	if req.Resolution != nil {
-		validRes := map[string]bool{"720p": true, "1080p": true, "4k": true}
-		if !validRes[*req.Resolution] {
+		if !voice.ValidScreenShareResolution(*req.Resolution) {
 			WriteError(w, http.StatusBadRequest, "invalid_resolution", "Resolution must be 720p, 1080p, or 4k")
 			return
 		}
+		if res, _ := caps.Clamp(*req.Resolution, 15); res != *req.Resolution {
+			WriteError(w, http.StatusBadRequest, "resolution_too_high", "This guild limits screen shares to "+caps.MaxResolution)
+			return
+		}
 		updates["resolution"] = *req.Resolution
 		session.Resolution = *req.Resolution
 	}
 	if req.Framerate != nil {
-		validFps := map[int]bool{15: true, 30: true, 60: true}
-		if !validFps[*req.Framerate] {
+		if !voice.ValidScreenShareFramerate(*req.Framerate) {
 			WriteError(w, http.StatusBadRequest, "invalid_framerate", "Framerate must be 15, 30, or 60")
 			return
 		}
+		if *req.Framerate > caps.MaxFramerate {
+			WriteError(w, http.StatusBadRequest, "framerate_too_high",
+				"This guild limits screen shares to "+strconv.Itoa(caps.MaxFramerate)+"fps")
+			return
+		}
 		updates["framerate"] = *req.Framerate
 		session.Framerate = *req.Framerate
 	}
@@ -1506,14 +1694,6 @@ func (s *Server) handleUpdateScreenShare(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	var guildID *string
-	s.DB.Pool.QueryRow(r.Context(),
-		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
-	gID := ""
-	if guildID != nil {
-		gID = *guildID
-	}
-
 	// Publish update event.
 	s.EventBus.PublishGuildEvent(r.Context(), events.SubjectVoiceStateUpdate, "SCREEN_SHARE_UPDATE", gID, map[string]interface{}{
 		"session_id":    session.ID,
@@ -1532,7 +1712,7 @@ func (s *Server) handleUpdateScreenShare(w http.ResponseWriter, r *http.Request)
 // GET /api/v1/voice/{channelID}/screen-shares
 func (s *Server) handleGetScreenShares(w http.ResponseWriter, r *http.Request) {
 	if s.Voice == nil {
-		WriteError(w, http.StatusServiceUnavailable, "voice_disabled", "Voice is not enabled on this instance")
+		WriteCode(w, apierrors.VoiceDisabled, "")
 		return
 	}
 
@@ -1550,6 +1730,114 @@ func (s *Server) handleGetScreenShares(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, sessions)
 }
 
+// voiceChannelGuild returns the guild of a voice channel, or writes an error
+// and returns false when the channel is missing or not in a guild.
+func (s *Server) voiceChannelGuild(w http.ResponseWriter, r *http.Request, channelID string) (string, bool) {
+	var guildID *string
+	err := s.DB.Pool.QueryRow(r.Context(),
+		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
+	if err == pgx.ErrNoRows {
+		WriteCode(w, apierrors.ChannelNotFound, "")
+		return "", false
+	}
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to get channel", err)
+		return "", false
+	}
+	if guildID == nil {
+		WriteError(w, http.StatusBadRequest, "not_guild_channel", "Only guild channels have voice settings")
+		return "", false
+	}
+	return *guildID, true
+}
+
+// handleGetScreenShareConfig returns the screen share quality caps of the
+// channel's guild.
+// GET /api/v1/voice/{channelID}/screen-share/config
+func (s *Server) handleGetScreenShareConfig(w http.ResponseWriter, r *http.Request) {
+	if s.Voice == nil {
+		WriteCode(w, apierrors.VoiceDisabled, "")
+		return
+	}
+
+	userID := auth.UserIDFromContext(r.Context())
+	guildID, ok := s.voiceChannelGuild(w, r, chi.URLParam(r, "channelID"))
+	if !ok {
+		return
+	}
+
+	var isMember bool
+	s.DB.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
+		guildID, userID).Scan(&isMember)
+	if !isMember {
+		WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	cfg, err := s.Voice.GetScreenShareConfig(r.Context(), guildID)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to get screen share limits", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, cfg)
+}
+
+// handleUpdateScreenShareConfig sets the screen share quality caps of the
+// channel's guild. New tokens carry the caps; members already sharing keep
+// their current quality until they rejoin.
+// PATCH /api/v1/voice/{channelID}/screen-share/config
+func (s *Server) handleUpdateScreenShareConfig(w http.ResponseWriter, r *http.Request) {
+	if s.Voice == nil {
+		WriteCode(w, apierrors.VoiceDisabled, "")
+		return
+	}
+
+	userID := auth.UserIDFromContext(r.Context())
+	guildID, ok := s.voiceChannelGuild(w, r, chi.URLParam(r, "channelID"))
+	if !ok {
+		return
+	}
+	if !checkGuildPerm(r.Context(), s.DB.Pool, guildID, userID, permissions.ManageGuild) {
+		WriteCode(w, apierrors.MissingPermission, "You need MANAGE_GUILD permission")
+		return
+	}
+
+	var req struct {
+		MaxResolution *string `json:"max_resolution"`
+		MaxFramerate  *int    `json:"max_framerate"`
+	}
+	if !DecodeJSON(w, r, &req) {
+		return
+	}
+
+	cfg, err := s.Voice.GetScreenShareConfig(r.Context(), guildID)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to get screen share limits", err)
+		return
+	}
+	if req.MaxResolution != nil {
+		if !voice.ValidScreenShareResolution(*req.MaxResolution) {
+			WriteError(w, http.StatusBadRequest, "invalid_resolution", "Resolution must be 720p, 1080p, or 4k")
+			return
+		}
+		cfg.MaxResolution = *req.MaxResolution
+	}
+	if req.MaxFramerate != nil {
+		if !voice.ValidScreenShareFramerate(*req.MaxFramerate) {
+			WriteError(w, http.StatusBadRequest, "invalid_framerate", "Framerate must be 15, 30, or 60")
+			return
+		}
+		cfg.MaxFramerate = *req.MaxFramerate
+	}
+
+	if err := s.Voice.UpdateScreenShareConfig(r.Context(), cfg); err != nil {
+		InternalError(w, s.Logger, "Failed to update screen share limits", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, cfg)
+}
+
 // checkGuildPerm checks if a user has a specific permission in a guild.
 // Used by voice handlers which are on *Server, not on a domain-specific Handler.
 func checkGuildPerm(ctx context.Context, pool *pgxpool.Pool, guildID, userID string, perm uint64) bool {
@@ -1597,3 +1885,75 @@ func checkGuildPerm(ctx context.Context, pool *pgxpool.Pool, guildID, userID str
 	}
 	return computed&perm != 0
 }
+
+// handleGetChannelInputMode returns the input mode a voice channel requires.
+// GET /api/v1/voice/{channelID}/input-mode/config
+func (s *Server) handleGetChannelInputMode(w http.ResponseWriter, r *http.Request) {
+	if s.Voice == nil {
+		WriteCode(w, apierrors.VoiceDisabled, "")
+		return
+	}
+
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	guildID, ok := s.voiceChannelGuild(w, r, channelID)
+	if !ok {
+		return
+	}
+
+	var isMember bool
+	s.DB.Pool.QueryRow(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
+		guildID, userID).Scan(&isMember)
+	if !isMember {
+		WriteCode(w, apierrors.NotMember, "")
+		return
+	}
+
+	mode, err := s.Voice.GetChannelInputMode(r.Context(), guildID, channelID)
+	if err != nil {
+		InternalError(w, s.Logger, "Failed to get channel input mode", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, mode)
+}
+
+// handleUpdateChannelInputMode sets whether a voice channel requires
+// push-to-talk. Connected clients see the change in their participant
+// attributes and switch over without rejoining.
+// PATCH /api/v1/voice/{channelID}/input-mode/config
+func (s *Server) handleUpdateChannelInputMode(w http.ResponseWriter, r *http.Request) {
+	if s.Voice == nil {
+		WriteCode(w, apierrors.VoiceDisabled, "")
+		return
+	}
+
+	userID := auth.UserIDFromContext(r.Context())
+	channelID := chi.URLParam(r, "channelID")
+	guildID, ok := s.voiceChannelGuild(w, r, channelID)
+	if !ok {
+		return
+	}
+	if !checkGuildPerm(r.Context(), s.DB.Pool, guildID, userID, permissions.ManageChannels) {
+		WriteCode(w, apierrors.MissingPermission, "You need MANAGE_CHANNELS permission")
+		return
+	}
+
+	var req struct {
+		InputMode string `json:"input_mode"`
+	}
+	if !DecodeJSON(w, r, &req) {
+		return
+	}
+	if !voice.ValidInputMode(req.InputMode) {
+		WriteError(w, http.StatusBadRequest, "invalid_mode", "Mode must be 'vad' or 'ptt'")
+		return
+	}
+
+	mode := &voice.ChannelInputMode{ChannelID: channelID, GuildID: guildID, InputMode: req.InputMode}
+	if err := s.Voice.UpdateChannelInputMode(r.Context(), mode); err != nil {
+		InternalError(w, s.Logger, "Failed to update channel input mode", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, mode)
+}
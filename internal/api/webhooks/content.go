@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/mentions"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// loadContentRules returns the channel's webhook content rules, or the
+// defaults.
+func (h *Handler) loadContentRules(ctx context.Context, channelID string) (models.ChannelWebhookContent, error) {
+	rules := models.DefaultChannelWebhookContent(channelID)
+	err := h.Pool.QueryRow(ctx,
+		`SELECT allow_attachments, allow_mentions, embeds_only
+		 FROM channel_webhook_content WHERE channel_id = $1`, channelID,
+	).Scan(&rules.AllowAttachments, &rules.AllowMentions, &rules.EmbedsOnly)
+	if err != nil && err != pgx.ErrNoRows {
+		return rules, err
+	}
+	return rules, nil
+}
+
+// contentViolation checks an execution against the channel's rules and
+// returns the error code and message for the first rule it breaks, or "".
+func contentViolation(rules models.ChannelWebhookContent, content string, embeds []webhookEmbed) (string, string) {
+	if rules.EmbedsOnly && (content != "" || len(embeds) == 0) {
+		return "embeds_only", "Webhooks may only post embeds in this channel"
+	}
+	if !rules.AllowMentions && content != "" {
+		if m := mentions.Parse(content); len(m.UserIDs) > 0 || len(m.RoleIDs) > 0 || m.MentionHere {
+			return "mentions_not_allowed", "Webhooks may not mention users or roles in this channel"
+		}
+	}
+	if !rules.AllowAttachments {
+		for _, e := range embeds {
+			if e.Image != nil {
+				return "attachments_not_allowed", "Webhooks may not attach images in this channel"
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,46 @@
+package webhooks
+
+import (
+	"testing"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+func TestContentViolation(t *testing.T) {
+	title := "Deploy finished"
+	textEmbed := webhookEmbed{Title: &title}
+	imageEmbed := webhookEmbed{Image: &webhookEmbedImage{URL: "https://example.com/a.png"}}
+	mention := "ping <@01ARZ3NDEKTSV4RRFFQ69G5FAV>"
+
+	open := models.DefaultChannelWebhookContent("c1")
+	strict := models.ChannelWebhookContent{ChannelID: "c1", EmbedsOnly: true}
+	noMentions := models.DefaultChannelWebhookContent("c1")
+	noMentions.AllowMentions = false
+	noMedia := models.DefaultChannelWebhookContent("c1")
+	noMedia.AllowAttachments = false
+
+	tests := []struct {
+		name    string
+		rules   models.ChannelWebhookContent
+		content string
+		embeds  []webhookEmbed
+		want    string
+	}{
+		{"defaults allow everything", open, mention, []webhookEmbed{imageEmbed}, ""},
+		{"embeds only with embed", strict, "", []webhookEmbed{textEmbed}, ""},
+		{"embeds only with content", strict, "hello", []webhookEmbed{textEmbed}, "embeds_only"},
+		{"embeds only without embeds", strict, "", nil, "embeds_only"},
+		{"mention blocked", noMentions, mention, nil, "mentions_not_allowed"},
+		{"here blocked", noMentions, "@here look", nil, "mentions_not_allowed"},
+		{"mention in code allowed", noMentions, "`<@01ARZ3NDEKTSV4RRFFQ69G5FAV>`", nil, ""},
+		{"image blocked", noMedia, "", []webhookEmbed{imageEmbed}, "attachments_not_allowed"},
+		{"text embed allowed", noMedia, "", []webhookEmbed{textEmbed}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := contentViolation(tt.rules, tt.content, tt.embeds); got != tt.want {
+				t.Errorf("contentViolation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Limits on the rich embeds a webhook execution may attach.
+const (
+	maxWebhookEmbeds      = 10
+	maxEmbedTitle         = 256
+	maxEmbedDescription   = 4096
+	maxEmbedAuthorName    = 256
+	maxEmbedURL           = 2048
+	maxEmbedTotalText     = 6000
+	maxEmbedColor         = 0xFFFFFF
+	maxWebhookContentSize = 4000
+)
+
+// webhookEmbed is a rich embed in a webhook execution. It covers exactly what
+// the embeds table stores; any other field is rejected rather than silently
+// dropped, so integrations find out their embed would not render as sent.
+type webhookEmbed struct {
+	Title       *string             `json:"title"`
+	Description *string             `json:"description"`
+	URL         *string             `json:"url"`
+	Color       *int                `json:"color"`
+	Image       *webhookEmbedImage  `json:"image"`
+	Author      *webhookEmbedAuthor `json:"author"`
+}
+
+type webhookEmbedImage struct {
+	URL string `json:"url"`
+}
+
+type webhookEmbedAuthor struct {
+	Name    string  `json:"name"`
+	IconURL *string `json:"icon_url"`
+}
+
+// parseEmbeds decodes and validates the embeds of an execution. The error
+// message is safe to return to the caller.
+func parseEmbeds(raw []json.RawMessage) ([]webhookEmbed, error) {
+	if len(raw) > maxWebhookEmbeds {
+		return nil, fmt.Errorf("a message can have at most %d embeds", maxWebhookEmbeds)
+	}
+
+	embeds := make([]webhookEmbed, 0, len(raw))
+	total := 0
+	for i, r := range raw {
+		dec := json.NewDecoder(bytes.NewReader(r))
+		dec.DisallowUnknownFields()
+		var e webhookEmbed
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("embeds[%d]: %s", i, strings.TrimPrefix(err.Error(), "json: "))
+		}
+		n, err := e.validate()
+		if err != nil {
+			return nil, fmt.Errorf("embeds[%d]: %w", i, err)
+		}
+		total += n
+		embeds = append(embeds, e)
+	}
+	if total > maxEmbedTotalText {
+		return nil, fmt.Errorf("embed text exceeds %d characters in total", maxEmbedTotalText)
+	}
+	return embeds, nil
+}
+
+// validate checks the embed's fields against their limits and returns how
+// many characters of text it carries.
+func (e webhookEmbed) validate() (int, error) {
+	text := 0
+	checkText := func(field string, v *string, max int) error {
+		if v == nil {
+			return nil
+		}
+		n := utf8.RuneCountInString(*v)
+		if n > max {
+			return fmt.Errorf("%s exceeds %d characters", field, max)
+		}
+		text += n
+		return nil
+	}
+	if err := checkText("title", e.Title, maxEmbedTitle); err != nil {
+		return 0, err
+	}
+	if err := checkText("description", e.Description, maxEmbedDescription); err != nil {
+		return 0, err
+	}
+	if e.Author != nil {
+		if strings.TrimSpace(e.Author.Name) == "" {
+			return 0, fmt.Errorf("author.name is required")
+		}
+		if err := checkText("author.name", &e.Author.Name, maxEmbedAuthorName); err != nil {
+			return 0, err
+		}
+		if err := checkEmbedURL("author.icon_url", e.Author.IconURL); err != nil {
+			return 0, err
+		}
+	}
+	if err := checkEmbedURL("url", e.URL); err != nil {
+		return 0, err
+	}
+	if e.Image != nil {
+		if err := checkEmbedURL("image.url", &e.Image.URL); err != nil {
+			return 0, err
+		}
+	}
+	if e.Color != nil && (*e.Color < 0 || *e.Color > maxEmbedColor) {
+		return 0, fmt.Errorf("color must be between 0 and %d", maxEmbedColor)
+	}
+	if text == 0 && e.Image == nil {
+		return 0, fmt.Errorf("embed must have a title, description, author or image")
+	}
+	return text, nil
+}
+
+// checkEmbedURL requires an absolute http or https URL.
+func checkEmbedURL(field string, v *string) error {
+	if v == nil {
+		return nil
+	}
+	if len(*v) > maxEmbedURL {
+		return fmt.Errorf("%s exceeds %d characters", field, maxEmbedURL)
+	}
+	u, err := url.Parse(*v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http or https URL", field)
+	}
+	return nil
+}
+
+// model converts the embed to its stored form.
+func (e webhookEmbed) model(messageID string, createdAt time.Time) models.Embed {
+	m := models.Embed{
+		ID:          models.NewULID().String(),
+		MessageID:   messageID,
+		EmbedType:   models.EmbedTypeRich,
+		URL:         e.URL,
+		Title:       e.Title,
+		Description: e.Description,
+		CreatedAt:   createdAt,
+	}
+	if e.Color != nil {
+		c := fmt.Sprintf("#%06x", *e.Color)
+		m.Color = &c
+	}
+	if e.Image != nil {
+		m.ImageURL = &e.Image.URL
+	}
+	if e.Author != nil {
+		m.SiteName = &e.Author.Name
+		m.IconURL = e.Author.IconURL
+	}
+	return m
+}
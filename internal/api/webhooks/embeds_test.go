@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rawEmbeds(embeds ...string) []json.RawMessage {
+	raw := make([]json.RawMessage, len(embeds))
+	for i, e := range embeds {
+		raw[i] = json.RawMessage(e)
+	}
+	return raw
+}
+
+func TestParseEmbeds(t *testing.T) {
+	long := strings.Repeat("a", maxEmbedDescription)
+	tests := []struct {
+		name    string
+		embeds  []json.RawMessage
+		wantErr string
+	}{
+		{"title only", rawEmbeds(`{"title":"Build passed"}`), ""},
+		{"full", rawEmbeds(`{"title":"t","description":"d","url":"https://ci.example.com/1","color":65280,
+			"image":{"url":"https://ci.example.com/badge.png"},"author":{"name":"CI","icon_url":"https://ci.example.com/i.png"}}`), ""},
+		{"image only", rawEmbeds(`{"image":{"url":"https://example.com/a.png"}}`), ""},
+		{"unknown field", rawEmbeds(`{"title":"t","footer":{"text":"f"}}`), "unknown field"},
+		{"empty", rawEmbeds(`{}`), "must have"},
+		{"title too long", rawEmbeds(`{"title":"` + strings.Repeat("x", maxEmbedTitle+1) + `"}`), "title exceeds"},
+		{"bad color", rawEmbeds(`{"title":"t","color":16777216}`), "color"},
+		{"bad url", rawEmbeds(`{"title":"t","url":"javascript:alert(1)"}`), "url must be"},
+		{"author without name", rawEmbeds(`{"author":{"name":" "}}`), "author.name"},
+		{"too many", rawEmbeds(strings.Split(strings.Repeat(`{"title":"t"}|`, maxWebhookEmbeds+1), "|")[:maxWebhookEmbeds+1]...), "at most"},
+		{"total too long", rawEmbeds(`{"description":"`+long+`"}`, `{"description":"`+long+`"}`), "in total"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseEmbeds(tt.embeds)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("parseEmbeds() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseEmbeds() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookEmbedModel(t *testing.T) {
+	embeds, err := parseEmbeds(rawEmbeds(`{"title":"t","color":255,"image":{"url":"https://x.example/a.png"},"author":{"name":"CI"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := embeds[0].model("msg1", time.Now())
+	if m.EmbedType != "rich" || m.MessageID != "msg1" {
+		t.Errorf("model() = %+v, want a rich embed on msg1", m)
+	}
+	if m.Color == nil || *m.Color != "#0000ff" {
+		t.Errorf("Color = %v, want #0000ff", m.Color)
+	}
+	if m.ImageURL == nil || m.SiteName == nil || *m.SiteName != "CI" {
+		t.Errorf("model() did not map image and author: %+v", m)
+	}
+}
@@ -0,0 +1,166 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// maxMasqueradeName is the longest name a webhook execution may post under.
+const maxMasqueradeName = 80
+
+// masquerade is the name and avatar a webhook message is shown with.
+// Override is false when the execution kept the webhook's own name.
+type masquerade struct {
+	Name     string
+	Avatar   *string
+	Override bool
+}
+
+// masqueradeError is an execution rejected by the guild's webhook policy.
+type masqueradeError struct {
+	status  int
+	code    string
+	message string
+}
+
+// loadWebhookPolicy returns the guild's webhook policy, or the defaults.
+func (h *Handler) loadWebhookPolicy(ctx context.Context, guildID string) (models.GuildWebhookPolicy, error) {
+	policy := models.DefaultWebhookPolicy(guildID)
+	err := h.Pool.QueryRow(ctx,
+		`SELECT allow_name_override, allow_avatar_override, block_member_names, require_tag, tag
+		 FROM guild_webhook_policy WHERE guild_id = $1`, guildID,
+	).Scan(&policy.AllowNameOverride, &policy.AllowAvatarOverride, &policy.BlockMemberNames,
+		&policy.RequireTag, &policy.Tag)
+	if err != nil && err != pgx.ErrNoRows {
+		return policy, err
+	}
+	return policy, nil
+}
+
+// resolveMasquerade applies the guild's policy to an execution's username
+// and avatar_url overrides. Overridden names that match a member's
+// username, display name or nickname are refused when the policy blocks
+// them, ignoring case, punctuation and any [BOT]/[APP] tag.
+func (h *Handler) resolveMasquerade(ctx context.Context, wh *models.Webhook, req executeWebhookRequest) (masquerade, *masqueradeError, error) {
+	m := masquerade{Name: wh.Name}
+	var name, avatar string
+	if req.Username != nil {
+		name = cleanMasqueradeName(*req.Username)
+	}
+	if req.AvatarURL != nil {
+		avatar = strings.TrimSpace(*req.AvatarURL)
+	}
+	if (name == "" || name == wh.Name) && avatar == "" {
+		return m, nil, nil
+	}
+
+	policy, err := h.loadWebhookPolicy(ctx, wh.GuildID)
+	if err != nil {
+		return m, nil, err
+	}
+
+	if avatar != "" {
+		if !policy.AllowAvatarOverride {
+			return m, &masqueradeError{http.StatusForbidden, "avatar_override_disabled",
+				"This guild does not allow webhooks to override their avatar"}, nil
+		}
+		if u, err := url.Parse(avatar); err != nil || u.Scheme != "https" || u.Host == "" {
+			return m, &masqueradeError{http.StatusBadRequest, "invalid_avatar_url",
+				"avatar_url must be an https URL"}, nil
+		}
+		m.Avatar = &avatar
+		m.Override = true
+	}
+
+	if name == "" || name == wh.Name {
+		return m, nil, nil
+	}
+	if !policy.AllowNameOverride {
+		return m, &masqueradeError{http.StatusForbidden, "name_override_disabled",
+			"This guild does not allow webhooks to override their name"}, nil
+	}
+	if utf8.RuneCountInString(name) > maxMasqueradeName {
+		return m, &masqueradeError{http.StatusBadRequest, "invalid_username",
+			"username must be at most 80 characters"}, nil
+	}
+	key := masqueradeKey(name)
+	if key == "" {
+		return m, &masqueradeError{http.StatusBadRequest, "invalid_username",
+			"username must contain a letter or digit"}, nil
+	}
+	if policy.BlockMemberNames {
+		var taken bool
+		err := h.Pool.QueryRow(ctx,
+			`SELECT EXISTS (
+			     SELECT 1 FROM guild_members gm JOIN users u ON u.id = gm.user_id
+			     WHERE gm.guild_id = $1 AND $2 IN (
+			         lower(regexp_replace(u.username, '[^[:alnum:]]', '', 'g')),
+			         lower(regexp_replace(COALESCE(u.display_name, ''), '[^[:alnum:]]', '', 'g')),
+			         lower(regexp_replace(COALESCE(gm.nickname, ''), '[^[:alnum:]]', '', 'g'))))`,
+			wh.GuildID, key).Scan(&taken)
+		if err != nil {
+			return m, nil, err
+		}
+		if taken {
+			return m, &masqueradeError{http.StatusForbidden, "impersonation_blocked",
+				"username matches a member of this guild"}, nil
+		}
+	}
+	if policy.RequireTag {
+		name = withTag(name, policy.Tag)
+	}
+	m.Name = name
+	m.Override = true
+	return m, nil, nil
+}
+
+// cleanMasqueradeName removes control, zero-width and bidi characters from a
+// requested name and collapses its whitespace.
+func cleanMasqueradeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+var tagPattern = regexp.MustCompile(`(?i)\[(bot|app)\]`)
+
+// stripTags removes [BOT] and [APP] tags from a name, in any case.
+func stripTags(name string) string {
+	return strings.Join(strings.Fields(tagPattern.ReplaceAllString(name, " ")), " ")
+}
+
+// masqueradeKey is the form names are compared in: tags removed, then only
+// letters and digits, lowercased.
+func masqueradeKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(stripTags(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// withTag returns name ending in exactly one tag, trimming the name so the
+// result stays within maxMasqueradeName.
+func withTag(name, tag string) string {
+	name = stripTags(name)
+	room := maxMasqueradeName - utf8.RuneCountInString(tag) - 1
+	if runes := []rune(name); len(runes) > room {
+		name = strings.TrimSpace(string(runes[:room]))
+	}
+	return name + " " + tag
+}
@@ -0,0 +1,49 @@
+package webhooks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanMasqueradeName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"  Deploy   Bot ", "Deploy Bot"},
+		{"Ali\u200bce", "Alice"},
+		{"Bob\u202e", "Bob"},
+		{"line\nbreak", "linebreak"},
+	}
+	for _, tt := range tests {
+		if got := cleanMasqueradeName(tt.in); got != tt.want {
+			t.Errorf("cleanMasqueradeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMasqueradeKey(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Alice", "alice"},
+		{"A.l-i_c e", "alice"},
+		{"Alice [BOT]", "alice"},
+		{"[app] Alice", "alice"},
+		{"[BOT]", ""},
+		{"Zoë", "zoë"},
+	}
+	for _, tt := range tests {
+		if got := masqueradeKey(tt.in); got != tt.want {
+			t.Errorf("masqueradeKey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWithTag(t *testing.T) {
+	if got := withTag("CI", "[BOT]"); got != "CI [BOT]" {
+		t.Errorf("withTag = %q", got)
+	}
+	if got := withTag("CI [bot]", "[APP]"); got != "CI [APP]" {
+		t.Errorf("existing tag not replaced: %q", got)
+	}
+	long := withTag(strings.Repeat("x", 80), "[BOT]")
+	if len([]rune(long)) != maxMasqueradeName || !strings.HasSuffix(long, " [BOT]") {
+		t.Errorf("long name = %q (%d runes)", long, len([]rune(long)))
+	}
+}
@@ -0,0 +1,314 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// Outgoing deliveries carry an HMAC-SHA256 of "<timestamp>.<body>" keyed
+// with the webhook's signing secret, so receivers can check both that the
+// request came from this instance and that it is recent. When the instance
+// has a signing key they also carry an Ed25519 signature over the same
+// message, which receivers can check without holding a secret.
+const (
+	signatureHeader          = "X-AmityVox-Signature"
+	signatureTimestampHeader = "X-AmityVox-Timestamp"
+)
+
+// outgoingWebhook is the outgoing configuration of a webhook. The signing
+// secret is only returned when it is set or rotated.
+type outgoingWebhook struct {
+	ID            string     `json:"id"`
+	URL           string     `json:"outgoing_url"`
+	Events        []string   `json:"outgoing_events"`
+	SigningSecret string     `json:"signing_secret,omitempty"`
+	VerifiedAt    *time.Time `json:"verified_at"`
+}
+
+type setOutgoingRequest struct {
+	URL          string   `json:"outgoing_url"`
+	Events       []string `json:"outgoing_events"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// deliveryResult reports the outcome of a challenge or test delivery.
+type deliveryResult struct {
+	Success         bool   `json:"success"`
+	StatusCode      int    `json:"status_code"`
+	ResponsePreview string `json:"response_preview,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// signOutgoing returns the signature header value for a delivery.
+func signOutgoing(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// challengeAnswered reports whether a verification response echoes the
+// challenge, either as {"challenge": "..."} or as the bare value.
+func challengeAnswered(body, challenge string) bool {
+	var resp struct {
+		Challenge string `json:"challenge"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err == nil && resp.Challenge != "" {
+		return hmac.Equal([]byte(resp.Challenge), []byte(challenge))
+	}
+	return hmac.Equal([]byte(strings.TrimSpace(body)), []byte(challenge))
+}
+
+// canManageWebhooks reports whether the user holds MANAGE_WEBHOOKS in the
+// guild, as owner, instance admin, or through the default permissions and
+// their roles.
+func (h *Handler) canManageWebhooks(ctx context.Context, guildID, userID string) bool {
+	var ownerID string
+	var defaultPerms int64
+	var userFlags int
+	if err := h.Pool.QueryRow(ctx,
+		`SELECT g.owner_id, g.default_permissions, u.flags
+		 FROM guilds g, users u WHERE g.id = $1 AND u.id = $2`,
+		guildID, userID,
+	).Scan(&ownerID, &defaultPerms, &userFlags); err != nil {
+		return false
+	}
+	if ownerID == userID || userFlags&models.UserFlagAdmin != 0 {
+		return true
+	}
+
+	computed := uint64(defaultPerms)
+	rows, err := h.Pool.Query(ctx,
+		`SELECT r.permissions_allow, r.permissions_deny
+		 FROM roles r
+		 JOIN member_roles mr ON r.id = mr.role_id
+		 WHERE mr.guild_id = $1 AND mr.user_id = $2
+		 ORDER BY r.position DESC`,
+		guildID, userID,
+	)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var allow, deny int64
+			rows.Scan(&allow, &deny)
+			computed |= uint64(allow)
+			computed &^= uint64(deny)
+		}
+	}
+	return computed&permissions.Administrator != 0 || computed&permissions.ManageWebhooks != 0
+}
+
+// loadOutgoing returns the webhook's outgoing configuration and secret, or a
+// written error response.
+func (h *Handler) loadOutgoing(w http.ResponseWriter, r *http.Request) (outgoingWebhook, string, bool) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	webhookID := chi.URLParam(r, "webhookID")
+
+	if !h.canManageWebhooks(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
+		return outgoingWebhook{}, "", false
+	}
+
+	ow := outgoingWebhook{ID: webhookID}
+	var whType string
+	var outURL, secret *string
+	err := h.Pool.QueryRow(r.Context(),
+		`SELECT webhook_type, outgoing_url, COALESCE(outgoing_events, '{}'), signing_secret, verified_at
+		 FROM webhooks WHERE id = $1 AND guild_id = $2`, webhookID, guildID,
+	).Scan(&whType, &outURL, &ow.Events, &secret, &ow.VerifiedAt)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "webhook_not_found", "Webhook not found")
+		return ow, "", false
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to look up webhook", err)
+		return ow, "", false
+	}
+	if whType != models.WebhookTypeOutgoing || outURL == nil || secret == nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "not_outgoing", "This webhook has no outgoing URL")
+		return ow, "", false
+	}
+	ow.URL = *outURL
+	return ow, *secret, true
+}
+
+// challenge sends a signed verification challenge to the webhook's URL and
+// marks it verified if the endpoint echoes it back.
+func (h *Handler) challenge(ctx context.Context, ow *outgoingWebhook, secret string) deliveryResult {
+	challenge := randomHex(16)
+	payload, _ := json.Marshal(map[string]interface{}{
+		"event":      "url_verification",
+		"webhook_id": ow.ID,
+		"challenge":  challenge,
+	})
+
+	status, preview, err := h.postOutgoing(ctx, ow.URL, secret, "url_verification", payload, time.Now())
+	res := deliveryResult{StatusCode: status, ResponsePreview: preview}
+	switch {
+	case err != nil:
+		res.Error = err.Error()
+	case status < 200 || status >= 300:
+		res.Error = fmt.Sprintf("HTTP %d: %s", status, http.StatusText(status))
+	case !challengeAnswered(preview, challenge):
+		res.Error = "Response did not echo the challenge"
+	default:
+		res.Success = true
+	}
+	h.logExecution(ctx, ow.ID, status, string(payload), preview, res.Success, res.Error)
+
+	if res.Success {
+		if err := h.Pool.QueryRow(ctx,
+			`UPDATE webhooks SET verified_at = now() WHERE id = $1 RETURNING verified_at`, ow.ID,
+		).Scan(&ow.VerifiedAt); err != nil {
+			res.Success, res.Error = false, "Failed to record verification"
+		}
+	}
+	return res
+}
+
+// HandleSetOutgoing registers or changes a webhook's outgoing URL and events.
+// A new signing secret is issued when the webhook first becomes outgoing or
+// rotate_secret is set, and the URL must then answer a verification challenge
+// before real events are delivered to it.
+// PUT /api/v1/guilds/{guildID}/webhooks/{webhookID}/outgoing
+func (h *Handler) HandleSetOutgoing(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	guildID := chi.URLParam(r, "guildID")
+	webhookID := chi.URLParam(r, "webhookID")
+
+	if !h.canManageWebhooks(r.Context(), guildID, userID) {
+		apiutil.WriteCode(w, apierrors.MissingPermission, "You need MANAGE_WEBHOOKS permission")
+		return
+	}
+
+	var req setOutgoingRequest
+	if !apiutil.DecodeJSON(w, r, &req) {
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if u, err := url.Parse(req.URL); err != nil || u.Scheme != "https" || u.Host == "" || len(req.URL) > 2048 {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_url", "outgoing_url must be an https URL")
+		return
+	}
+	if len(req.Events) == 0 {
+		apiutil.WriteError(w, http.StatusBadRequest, "missing_events", "outgoing_events is required")
+		return
+	}
+	for _, e := range req.Events {
+		if _, ok := outgoingEvents[e]; !ok {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_event", "Unknown outgoing event: "+e)
+			return
+		}
+	}
+
+	// The webhook stays verified only while its URL and secret are unchanged.
+	ow := outgoingWebhook{ID: webhookID}
+	var secret string
+	err := h.Pool.QueryRow(r.Context(),
+		`UPDATE webhooks SET
+		     webhook_type = 'outgoing',
+		     outgoing_url = $3,
+		     outgoing_events = $4,
+		     signing_secret = CASE WHEN $5 OR signing_secret IS NULL THEN $6 ELSE signing_secret END,
+		     verified_at = CASE WHEN $5 OR signing_secret IS NULL OR outgoing_url IS DISTINCT FROM $3
+		                        THEN NULL ELSE verified_at END
+		 WHERE id = $1 AND guild_id = $2
+		 RETURNING outgoing_url, outgoing_events, signing_secret, verified_at`,
+		webhookID, guildID, req.URL, req.Events, req.RotateSecret, randomHex(32),
+	).Scan(&ow.URL, &ow.Events, &secret, &ow.VerifiedAt)
+	if err == pgx.ErrNoRows {
+		apiutil.WriteError(w, http.StatusNotFound, "webhook_not_found", "Webhook not found")
+		return
+	}
+	if err != nil {
+		apiutil.InternalError(w, h.Logger, "Failed to update webhook", err)
+		return
+	}
+
+	resp := map[string]interface{}{"webhook": ow}
+	if ow.VerifiedAt == nil {
+		ow.SigningSecret = secret
+		result := h.challenge(r.Context(), &ow, secret)
+		resp["webhook"] = ow
+		resp["verification"] = result
+	}
+
+	apiutil.WriteJSON(w, http.StatusOK, resp)
+}
+
+// HandleVerifyOutgoing sends the verification challenge again, for when the
+// endpoint was not ready at registration.
+// POST /api/v1/guilds/{guildID}/webhooks/{webhookID}/outgoing/verify
+func (h *Handler) HandleVerifyOutgoing(w http.ResponseWriter, r *http.Request) {
+	ow, secret, ok := h.loadOutgoing(w, r)
+	if !ok {
+		return
+	}
+	result := h.challenge(r.Context(), &ow, secret)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"webhook":      ow,
+		"verification": result,
+	})
+}
+
+// HandleTestOutgoing sends a signed test event to the webhook's URL and
+// reports how the endpoint answered. Works before verification, so
+// integrators can check their signature handling first.
+// POST /api/v1/guilds/{guildID}/webhooks/{webhookID}/outgoing/test
+func (h *Handler) HandleTestOutgoing(w http.ResponseWriter, r *http.Request) {
+	ow, secret, ok := h.loadOutgoing(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	payload, _ := json.Marshal(map[string]interface{}{
+		"event": "test",
+		"data": map[string]interface{}{
+			"webhook_id": ow.ID,
+			"guild_id":   chi.URLParam(r, "guildID"),
+			"message":    "Test delivery from AmityVox",
+			"sent_at":    now,
+		},
+	})
+
+	status, preview, err := h.postOutgoing(r.Context(), ow.URL, secret, "test", payload, now)
+	res := deliveryResult{StatusCode: status, ResponsePreview: preview}
+	if err != nil {
+		res.Error = err.Error()
+	} else if status < 200 || status >= 300 {
+		res.Error = fmt.Sprintf("HTTP %d: %s", status, http.StatusText(status))
+	} else {
+		res.Success = true
+	}
+	h.logExecution(r.Context(), ow.ID, status, string(payload), preview, res.Success, res.Error)
+
+	apiutil.WriteJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,43 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSignOutgoing(t *testing.T) {
+	body := []byte(`{"event":"test"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := signOutgoing("secret", "1700000000", body); got != want {
+		t.Errorf("signOutgoing() = %q, want %q", got, want)
+	}
+	if signOutgoing("secret", "1700000001", body) == want {
+		t.Error("signature should cover the timestamp")
+	}
+	if signOutgoing("other", "1700000000", body) == want {
+		t.Error("signature should depend on the secret")
+	}
+}
+
+func TestChallengeAnswered(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"challenge":"abc123"}`, true},
+		{"abc123\n", true},
+		{`{"challenge":"wrong"}`, false},
+		{`{"ok":true}`, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := challengeAnswered(tt.body, "abc123"); got != tt.want {
+			t.Errorf("challengeAnswered(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
@@ -8,12 +8,14 @@ package webhooks
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,9 +23,12 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/callback"
 	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/presence"
 )
 
 // Handler implements webhook-related REST API endpoints.
@@ -31,13 +36,22 @@ type Handler struct {
 	Pool     *pgxpool.Pool
 	EventBus *events.Bus
 	Logger   *slog.Logger
+
+	// SigningKey, when set, adds an Ed25519 signature to outgoing deliveries
+	// alongside the per-webhook HMAC. See package callback.
+	SigningKey ed25519.PrivateKey
+
+	// Cache counts executions for per-source channel rate limits; without
+	// it they are not enforced.
+	Cache *presence.Cache
 }
 
 type executeWebhookRequest struct {
-	Content    string  `json:"content"`
-	Username   *string `json:"username"`
-	AvatarURL  *string `json:"avatar_url"`
-	TemplateID *string `json:"template_id"`
+	Content    string            `json:"content"`
+	Username   *string           `json:"username"`
+	AvatarURL  *string           `json:"avatar_url"`
+	TemplateID *string           `json:"template_id"`
+	Embeds     []json.RawMessage `json:"embeds"`
 }
 
 // --- Webhook Templates (in-memory) ---
@@ -616,39 +630,50 @@ func ValidOutgoingEvents() []string {
 }
 
 // DeliverOutgoingWebhook sends the event payload to the outgoing webhook URL
-// via HTTP POST. This should be called asynchronously from the event bus
-// subscriber. It logs the execution result.
-func (h *Handler) DeliverOutgoingWebhook(ctx context.Context, webhookID, outgoingURL string, eventType string, payload json.RawMessage) {
-	reqBody := string(payload)
+// via HTTP POST, signed with the webhook's secret. This should be called
+// asynchronously from the event bus subscriber. It logs the execution result.
+func (h *Handler) DeliverOutgoingWebhook(ctx context.Context, webhookID, outgoingURL, secret string, eventType string, payload json.RawMessage) {
+	status, preview, err := h.postOutgoing(ctx, outgoingURL, secret, eventType, payload, time.Now())
+	if err != nil {
+		h.logExecution(ctx, webhookID, 0, string(payload), "", false, err.Error())
+		return
+	}
+
+	success := status >= 200 && status < 300
+	errMsg := ""
+	if !success {
+		errMsg = fmt.Sprintf("HTTP %d: %s", status, http.StatusText(status))
+	}
 
-	client := safeHTTPClient()
+	h.logExecution(ctx, webhookID, status, string(payload), preview, success, errMsg)
+}
+
+// postOutgoing POSTs a signed payload to an outgoing webhook URL and returns
+// the response status and up to 2KB of the response body.
+func (h *Handler) postOutgoing(ctx context.Context, outgoingURL, secret, eventType string, payload []byte, now time.Time) (int, string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, outgoingURL, bytes.NewReader(payload))
 	if err != nil {
-		h.logExecution(ctx, webhookID, 0, reqBody, "", false, fmt.Sprintf("failed to create request: %v", err))
-		return
+		return 0, "", fmt.Errorf("failed to create request: %v", err)
 	}
+	ts := strconv.FormatInt(now.Unix(), 10)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "AmityVox-Webhook/1.0")
 	req.Header.Set("X-AmityVox-Event", eventType)
+	req.Header.Set(signatureTimestampHeader, ts)
+	req.Header.Set(signatureHeader, signOutgoing(secret, ts, payload))
+	if len(h.SigningKey) == ed25519.PrivateKeySize {
+		callback.SignRequest(req, h.SigningKey, payload, now)
+	}
 
-	resp, err := client.Do(req)
+	resp, err := callback.SafeClient().Do(req)
 	if err != nil {
-		h.logExecution(ctx, webhookID, 0, reqBody, "", false, fmt.Sprintf("request failed: %v", err))
-		return
+		return 0, "", fmt.Errorf("request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
 	// Read up to 2KB of the response for the preview.
 	respBodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
-	respPreview := string(respBodyBytes)
-
-	success := resp.StatusCode >= 200 && resp.StatusCode < 300
-	errMsg := ""
-	if !success {
-		errMsg = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
-	}
-
-	h.logExecution(ctx, webhookID, resp.StatusCode, reqBody, respPreview, success, errMsg)
+	return resp.StatusCode, string(respBodyBytes), nil
 }
 
 // StartOutgoingWebhookSubscriber subscribes to all NATS event subjects and
@@ -676,11 +701,14 @@ func (h *Handler) StartOutgoingWebhookSubscriber() {
 		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 
+		// Only webhooks whose endpoint answered the verification challenge
+		// receive real events.
 		rows, err := h.Pool.Query(ctx,
-			`SELECT id, outgoing_url FROM webhooks
+			`SELECT id, outgoing_url, COALESCE(signing_secret, '') FROM webhooks
 			 WHERE webhook_type = 'outgoing'
 			   AND outgoing_url IS NOT NULL
 			   AND outgoing_url != ''
+			   AND verified_at IS NOT NULL
 			   AND $1 = ANY(outgoing_events)`,
 			eventName,
 		)
@@ -694,13 +722,14 @@ func (h *Handler) StartOutgoingWebhookSubscriber() {
 		defer rows.Close()
 
 		type target struct {
-			id  string
-			url string
+			id     string
+			url    string
+			secret string
 		}
 		var targets []target
 		for rows.Next() {
 			var t target
-			if err := rows.Scan(&t.id, &t.url); err != nil {
+			if err := rows.Scan(&t.id, &t.url, &t.secret); err != nil {
 				continue
 			}
 			targets = append(targets, t)
@@ -712,8 +741,14 @@ func (h *Handler) StartOutgoingWebhookSubscriber() {
 			"data":  json.RawMessage(event.Data),
 		})
 
+		// Each delivery gets its own deadline; ctx ends when this callback
+		// returns.
 		for _, t := range targets {
-			go h.DeliverOutgoingWebhook(ctx, t.id, t.url, eventName, outPayload)
+			go func(t target) {
+				dctx, dcancel := context.WithTimeout(context.Background(), 15*time.Second)
+				defer dcancel()
+				h.DeliverOutgoingWebhook(dctx, t.id, t.url, t.secret, eventName, outPayload)
+			}(t)
 		}
 	})
 	if err != nil {
@@ -729,6 +764,8 @@ func (h *Handler) StartOutgoingWebhookSubscriber() {
 
 // HandleExecute handles POST /api/v1/webhooks/{webhookID}/{token}.
 // This endpoint does NOT require Bearer auth — the token in the URL is the secret.
+// Query parameters: wait=true returns the created message instead of 204 No
+// Content, and thread_id posts into a thread of the webhook's channel.
 func (h *Handler) HandleExecute(w http.ResponseWriter, r *http.Request) {
 	webhookID := chi.URLParam(r, "webhookID")
 	token := chi.URLParam(r, "token")
@@ -762,22 +799,33 @@ func (h *Handler) HandleExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query := r.URL.Query()
+	wait := false
+	if v := query.Get("wait"); v != "" {
+		if wait, err = strconv.ParseBool(v); err != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_wait", "wait must be true or false")
+			return
+		}
+	}
+
 	// Read raw body for logging, then decode.
 	bodyBytes, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1MB
 	if err != nil {
-		apiutil.WriteError(w, http.StatusBadRequest, "invalid_body", "Failed to read request body")
+		apiutil.WriteCode(w, apierrors.InvalidBody, "Failed to read request body")
 		return
 	}
 
 	var req executeWebhookRequest
 	if err := json.Unmarshal(bodyBytes, &req); err != nil {
 		h.logExecution(r.Context(), webhookID, http.StatusBadRequest, string(bodyBytes), "", false, "Invalid request body")
-		apiutil.WriteError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		apiutil.WriteCode(w, apierrors.InvalidBody, "")
 		return
 	}
 
-	// If a template_id is provided, try to transform the raw payload.
+	// If a template_id is provided, try to transform the raw payload. The
+	// payload is the service's own JSON, so its fields are not embeds.
 	finalContent := req.Content
+	var embeds []webhookEmbed
 	if req.TemplateID != nil && *req.TemplateID != "" {
 		result, err := transformPayload(*req.TemplateID, bodyBytes)
 		if err != nil {
@@ -787,72 +835,195 @@ func (h *Handler) HandleExecute(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		finalContent = result.Content
+	} else if len(req.Embeds) > 0 {
+		embeds, err = parseEmbeds(req.Embeds)
+		if err != nil {
+			h.logExecution(r.Context(), webhookID, http.StatusBadRequest, string(bodyBytes), "", false, err.Error())
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_embed", err.Error())
+			return
+		}
 	}
 
-	if finalContent == "" {
+	if finalContent == "" && len(embeds) == 0 {
 		h.logExecution(r.Context(), webhookID, http.StatusBadRequest, string(bodyBytes), "", false, "Message content cannot be empty")
 		apiutil.WriteError(w, http.StatusBadRequest, "empty_content", "Message content cannot be empty")
 		return
 	}
-	if len(finalContent) > 4000 {
+	if len(finalContent) > maxWebhookContentSize {
 		h.logExecution(r.Context(), webhookID, http.StatusBadRequest, string(bodyBytes), "", false, "Message content exceeds 4000 characters")
 		apiutil.WriteError(w, http.StatusBadRequest, "content_too_long", "Message content exceeds 4000 characters")
 		return
 	}
 
-	// Create the message.
-	messageID := models.NewULID().String()
-	now := time.Now().UTC()
+	// Moderators can keep a channel free of webhook posts; like the content
+	// rules, this also covers the channel's threads.
+	var disallowed bool
+	if err := h.Pool.QueryRow(r.Context(),
+		`SELECT disallow_webhook_posts FROM channels WHERE id = $1`, wh.ChannelID,
+	).Scan(&disallowed); err != nil {
+		h.logExecution(r.Context(), webhookID, http.StatusInternalServerError, string(bodyBytes), "", false, "Failed to check channel settings")
+		apiutil.InternalError(w, h.Logger, "Failed to check channel settings", err)
+		return
+	}
+	if disallowed {
+		h.logExecution(r.Context(), webhookID, http.StatusForbidden, string(bodyBytes), "", false, "Webhook posts are disallowed in this channel")
+		apiutil.WriteError(w, http.StatusForbidden, "webhook_posts_disallowed", "Webhooks may not post in this channel")
+		return
+	}
+
+	// Apply the channel's content rules. Threads follow their parent, so
+	// the webhook's own channel decides.
+	rules, err := h.loadContentRules(r.Context(), wh.ChannelID)
+	if err != nil {
+		h.logExecution(r.Context(), webhookID, http.StatusInternalServerError, string(bodyBytes), "", false, "Failed to check channel content rules")
+		apiutil.InternalError(w, h.Logger, "Failed to check channel content rules", err)
+		return
+	}
+	if code, msg := contentViolation(rules, finalContent, embeds); code != "" {
+		h.logExecution(r.Context(), webhookID, http.StatusForbidden, string(bodyBytes), "", false, msg)
+		apiutil.WriteError(w, http.StatusForbidden, code, msg)
+		return
+	}
 
-	// Determine the display name: use override if provided, otherwise webhook name.
-	displayName := wh.Name
-	if req.Username != nil && *req.Username != "" {
-		displayName = *req.Username
+	// A thread_id must name an open thread under the webhook's channel; the
+	// message is then posted there instead.
+	channelID := wh.ChannelID
+	if threadID := query.Get("thread_id"); threadID != "" {
+		var archived, locked bool
+		err := h.Pool.QueryRow(r.Context(),
+			`SELECT archived, locked FROM channels WHERE id = $1 AND parent_channel_id = $2`,
+			threadID, wh.ChannelID,
+		).Scan(&archived, &locked)
+		if err == pgx.ErrNoRows {
+			h.logExecution(r.Context(), webhookID, http.StatusNotFound, string(bodyBytes), "", false, "Unknown thread")
+			apiutil.WriteError(w, http.StatusNotFound, "thread_not_found", "No thread with that ID in the webhook's channel")
+			return
+		}
+		if err != nil {
+			apiutil.InternalError(w, h.Logger, "Failed to look up thread", err)
+			return
+		}
+		if archived || locked {
+			h.logExecution(r.Context(), webhookID, http.StatusForbidden, string(bodyBytes), "", false, "Thread is archived or locked")
+			apiutil.WriteError(w, http.StatusForbidden, "thread_closed", "The thread is archived or locked")
+			return
+		}
+		channelID = threadID
 	}
 
-	_, err = h.Pool.Exec(r.Context(),
-		`INSERT INTO messages (id, channel_id, author_id, content, message_type, created_at)
-		 VALUES ($1, $2, NULL, $3, 'webhook', $4)`,
-		messageID, wh.ChannelID, finalContent, now)
+	// Resolve the per-message name and avatar overrides against the guild's
+	// webhook policy.
+	mq, denied, err := h.resolveMasquerade(r.Context(), &wh, req)
+	if err != nil {
+		h.logExecution(r.Context(), webhookID, http.StatusInternalServerError, string(bodyBytes), "", false, "Failed to check webhook policy")
+		apiutil.InternalError(w, h.Logger, "Failed to check webhook policy", err)
+		return
+	}
+	if denied != nil {
+		h.logExecution(r.Context(), webhookID, denied.status, string(bodyBytes), "", false, denied.message)
+		apiutil.WriteError(w, denied.status, denied.code, denied.message)
+		return
+	}
+
+	// Like content rules, the webhook's own channel's limit applies to
+	// messages it posts in threads.
+	if retryAfter := apiutil.SourceRateLimited(r.Context(), h.Pool, h.Cache, wh.ChannelID, models.MessageSourceWebhook); retryAfter > 0 {
+		h.logExecution(r.Context(), webhookID, http.StatusTooManyRequests, string(bodyBytes), "", false, "Channel webhook rate limit reached")
+		apiutil.WriteSourceRateLimited(w, models.MessageSourceWebhook, retryAfter)
+		return
+	}
+
+	displayName := mq.Name
+	var masqueradeName *string
+	if mq.Override {
+		masqueradeName = &displayName
+	}
+
+	// Create the message and its embeds.
+	messageID := models.NewULID().String()
+	now := time.Now().UTC()
+	var content *string
+	if finalContent != "" {
+		content = &finalContent
+	}
+	stored := make([]models.Embed, 0, len(embeds))
+	for _, e := range embeds {
+		stored = append(stored, e.model(messageID, now))
+	}
+
+	err = apiutil.WithTx(r.Context(), h.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(r.Context(),
+			`INSERT INTO messages (id, channel_id, author_id, content, message_type,
+			                       masquerade_name, masquerade_avatar, webhook_id, created_at)
+			 VALUES ($1, $2, NULL, $3, 'webhook', $4, $5, $6, $7)`,
+			messageID, channelID, content, masqueradeName, mq.Avatar, webhookID, now); err != nil {
+			return err
+		}
+		for _, e := range stored {
+			if _, err := tx.Exec(r.Context(),
+				`INSERT INTO embeds (id, message_id, embed_type, url, title, description,
+				                     site_name, icon_url, color, image_url, created_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+				e.ID, e.MessageID, e.EmbedType, e.URL, e.Title, e.Description,
+				e.SiteName, e.IconURL, e.Color, e.ImageURL, e.CreatedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		h.logExecution(r.Context(), webhookID, http.StatusInternalServerError, string(bodyBytes), "", false, "Failed to create message")
 		apiutil.InternalError(w, h.Logger, "Failed to create message", err)
 		return
 	}
 
-	// Update channel last_message_id.
+	// Update channel last_message_id, and thread activity when posting to one.
 	h.Pool.Exec(r.Context(),
-		`UPDATE channels SET last_message_id = $1 WHERE id = $2`, messageID, wh.ChannelID)
+		`UPDATE channels SET last_message_id = $1 WHERE id = $2`, messageID, channelID)
+	if channelID != wh.ChannelID {
+		h.Pool.Exec(r.Context(),
+			`UPDATE channels SET last_activity_at = now(), reply_count = reply_count + 1
+			 WHERE id = $1`, channelID)
+	}
 
 	// Publish message create event.
-	h.EventBus.PublishChannelEvent(r.Context(), events.SubjectMessageCreate, "MESSAGE_CREATE", wh.ChannelID,
+	h.EventBus.PublishChannelEvent(r.Context(), events.SubjectMessageCreate, "MESSAGE_CREATE", channelID,
 		map[string]interface{}{
 			"id":           messageID,
-			"channel_id":   wh.ChannelID,
+			"channel_id":   channelID,
 			"guild_id":     wh.GuildID,
 			"content":      finalContent,
+			"embeds":       stored,
 			"webhook_id":   webhookID,
 			"display_name": displayName,
-			"avatar_url":   req.AvatarURL,
+			"avatar_url":   mq.Avatar,
 			"created_at":   now,
 		})
 
 	// Log successful execution.
 	respData := map[string]interface{}{
-		"id":         messageID,
-		"channel_id": wh.ChannelID,
-		"content":    finalContent,
-		"webhook_id": webhookID,
+		"id":           messageID,
+		"channel_id":   channelID,
+		"guild_id":     wh.GuildID,
+		"content":      finalContent,
+		"message_type": "webhook",
+		"embeds":       stored,
+		"webhook_id":   webhookID,
 		"author": map[string]interface{}{
-			"id":       webhookID,
-			"username": displayName,
-			"bot":      true,
+			"id":         webhookID,
+			"username":   displayName,
+			"avatar_url": mq.Avatar,
+			"bot":        true,
 		},
 		"created_at": now,
 	}
 	respBytes, _ := json.Marshal(respData)
 	h.logExecution(r.Context(), webhookID, http.StatusOK, string(bodyBytes), string(respBytes), true, "")
 
+	if !wait {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	apiutil.WriteJSON(w, http.StatusOK, respData)
 }
 
@@ -917,7 +1088,7 @@ func (h *Handler) HandleGetWebhookLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to look up webhook")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to look up webhook")
 		return
 	}
 	if whGuildID != guildID {
@@ -934,7 +1105,7 @@ func (h *Handler) HandleGetWebhookLogs(w http.ResponseWriter, r *http.Request) {
 		webhookID,
 	)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to get webhook logs")
+		apiutil.WriteCode(w, apierrors.InternalError, "Failed to get webhook logs")
 		return
 	}
 	defer rows.Close()
@@ -946,7 +1117,7 @@ func (h *Handler) HandleGetWebhookLogs(w http.ResponseWriter, r *http.Request) {
 			&log.ID, &log.WebhookID, &log.StatusCode, &log.RequestBody,
 			&log.ResponsePreview, &log.Success, &log.ErrorMessage, &log.CreatedAt,
 		); err != nil {
-			apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Failed to read webhook logs")
+			apiutil.WriteCode(w, apierrors.InternalError, "Failed to read webhook logs")
 			return
 		}
 		logs = append(logs, log)
@@ -20,6 +20,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apierrors"
 	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
@@ -163,7 +164,7 @@ func (h *Handler) HandleUpdateGuildWidget(w http.ResponseWriter, r *http.Request
 
 	// Only guild owner or admin can update widget settings.
 	if !h.isGuildAdmin(r, guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only the guild owner or an admin can manage the widget")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only the guild owner or an admin can manage the widget")
 		return
 	}
 
@@ -228,7 +229,7 @@ func (h *Handler) HandleGetGuildWidgetEmbed(w http.ResponseWriter, r *http.Reque
 	).Scan(&embed.ID, &embed.Name, &embed.Description, &embed.IconID, &embed.MemberCount)
 	if err != nil {
 		h.Logger.Error("failed to fetch guild for widget", slog.String("error", err.Error()))
-		apiutil.WriteError(w, http.StatusNotFound, "guild_not_found", "Guild not found")
+		apiutil.WriteCode(w, apierrors.GuildNotFound, "")
 		return
 	}
 
@@ -358,7 +359,7 @@ func (h *Handler) HandleCreateChannelWidget(w http.ResponseWriter, r *http.Reque
 		`SELECT guild_id FROM channels WHERE id = $1`, channelID,
 	).Scan(&guildID)
 	if err != nil {
-		apiutil.WriteError(w, http.StatusNotFound, "channel_not_found", "Channel not found")
+		apiutil.WriteCode(w, apierrors.ChannelNotFound, "")
 		return
 	}
 	if guildID == nil {
@@ -368,7 +369,7 @@ func (h *Handler) HandleCreateChannelWidget(w http.ResponseWriter, r *http.Reque
 
 	// Check if user has permission to add widgets.
 	if !h.hasWidgetPermission(r, *guildID, userID, "add") {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to add widgets")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to add widgets")
 		return
 	}
 
@@ -427,7 +428,7 @@ func (h *Handler) HandleUpdateChannelWidget(w http.ResponseWriter, r *http.Reque
 
 	// Creator or guild admin/owner can update.
 	if creatorID != userID && !h.hasWidgetPermission(r, guildID, userID, "configure") {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to update this widget")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to update this widget")
 		return
 	}
 
@@ -477,7 +478,7 @@ func (h *Handler) HandleDeleteChannelWidget(w http.ResponseWriter, r *http.Reque
 
 	// Creator or guild admin/owner can delete.
 	if creatorID != userID && !h.hasWidgetPermission(r, guildID, userID, "remove") {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "You do not have permission to remove this widget")
+		apiutil.WriteCode(w, apierrors.Forbidden, "You do not have permission to remove this widget")
 		return
 	}
 
@@ -622,7 +623,7 @@ func (h *Handler) HandleInstallPlugin(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	if !h.isGuildAdmin(r, guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild owners or admins can install plugins")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild owners or admins can install plugins")
 		return
 	}
 
@@ -744,7 +745,7 @@ func (h *Handler) HandleUpdateGuildPlugin(w http.ResponseWriter, r *http.Request
 	installID := chi.URLParam(r, "installID")
 
 	if !h.isGuildAdmin(r, guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild owners or admins can manage plugins")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild owners or admins can manage plugins")
 		return
 	}
 
@@ -784,7 +785,7 @@ func (h *Handler) HandleUninstallPlugin(w http.ResponseWriter, r *http.Request)
 	installID := chi.URLParam(r, "installID")
 
 	if !h.isGuildAdmin(r, guildID, userID) {
-		apiutil.WriteError(w, http.StatusForbidden, "forbidden", "Only guild owners or admins can uninstall plugins")
+		apiutil.WriteCode(w, apierrors.Forbidden, "Only guild owners or admins can uninstall plugins")
 		return
 	}
 
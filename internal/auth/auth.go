@@ -10,6 +10,7 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base32"
 	"encoding/binary"
@@ -27,6 +28,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/presence"
 )
@@ -37,23 +39,31 @@ var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{2,32}$`)
 type Service struct {
 	pool            *pgxpool.Pool
 	cache           *presence.Cache
+	bus             *events.Bus
 	instanceID      string
 	sessionDuration time.Duration
 	regEnabled      bool
 	inviteOnly      bool
 	requireEmail    bool
-	logger          *slog.Logger
+	ldap            *LDAPConfig
+	abuse           AbuseConfig
+	// disposableDomains is the lookup set built from abuse.DisposableDomains.
+	disposableDomains map[string]bool
+	logger            *slog.Logger
 }
 
 // Config holds the parameters needed to create an auth Service.
 type Config struct {
 	Pool            *pgxpool.Pool
 	Cache           *presence.Cache
+	Bus             *events.Bus // may be nil
 	InstanceID      string
 	SessionDuration time.Duration
 	RegEnabled      bool
 	InviteOnly      bool
 	RequireEmail    bool
+	LDAP            *LDAPConfig // nil disables the LDAP backend.
+	Abuse           AbuseConfig
 	Logger          *slog.Logger
 }
 
@@ -62,12 +72,17 @@ func NewService(cfg Config) *Service {
 	return &Service{
 		pool:            cfg.Pool,
 		cache:           cfg.Cache,
+		bus:             cfg.Bus,
 		instanceID:      cfg.InstanceID,
 		sessionDuration: cfg.SessionDuration,
 		regEnabled:      cfg.RegEnabled,
 		inviteOnly:      cfg.InviteOnly,
 		requireEmail:    cfg.RequireEmail,
+		ldap:            cfg.LDAP,
+		abuse:           cfg.Abuse,
 		logger:          cfg.Logger,
+
+		disposableDomains: buildDisposableSet(cfg.Abuse.DisposableDomains),
 	}
 }
 
@@ -77,6 +92,9 @@ type RegisterRequest struct {
 	Password string  `json:"password"`
 	Email    *string `json:"email,omitempty"`
 	Token    string  `json:"token,omitempty"` // Registration token for invite-only instances.
+	// DeviceFingerprint is an opaque client-generated identifier used to
+	// correlate alternate accounts. It is hashed before storage.
+	DeviceFingerprint string `json:"device_fingerprint,omitempty"`
 }
 
 // LoginRequest is the request body for user login.
@@ -84,6 +102,8 @@ type LoginRequest struct {
 	Username string  `json:"username"`
 	Password string  `json:"password"`
 	TOTPCode *string `json:"totp_code,omitempty"` // Required if user has TOTP enabled.
+	// DeviceFingerprint is the same opaque identifier sent at registration.
+	DeviceFingerprint string `json:"device_fingerprint,omitempty"`
 }
 
 // AuthError represents an authentication-related error with an HTTP-friendly code.
@@ -115,6 +135,10 @@ func (s *Service) Register(ctx context.Context, req RegisterRequest, ip, userAge
 		return nil, nil, &AuthError{Code: "email_required", Message: "Email is required for registration", Status: 400}
 	}
 
+	if err := s.checkRegistrationAbuse(ctx, req.Email, ip); err != nil {
+		return nil, nil, err
+	}
+
 	hash, err := argon2id.CreateHash(req.Password, argon2id.DefaultParams)
 	if err != nil {
 		return nil, nil, fmt.Errorf("hashing password: %w", err)
@@ -129,13 +153,14 @@ func (s *Service) Register(ctx context.Context, req RegisterRequest, ip, userAge
 		 RETURNING id, instance_id, username, display_name, avatar_id, status_text,
 		           status_emoji, status_presence, status_expires_at, bio,
 		           banner_id, accent_color, pronouns,
-		           bot_owner_id, email, flags, created_at`,
+		           bot_owner_id, email, flags, created_at, read_receipts`,
 		userID, s.instanceID, req.Username, hash, req.Email,
 	).Scan(
 		&user.ID, &user.InstanceID, &user.Username, &user.DisplayName,
 		&user.AvatarID, &user.StatusText, &user.StatusEmoji, &user.StatusPresence,
 		&user.StatusExpiresAt, &user.Bio, &user.BannerID, &user.AccentColor,
 		&user.Pronouns, &user.BotOwnerID, &user.Email, &user.Flags, &user.CreatedAt,
+		&user.ReadReceipts,
 	)
 	if err != nil {
 		if strings.Contains(err.Error(), "unique constraint") || strings.Contains(err.Error(), "duplicate key") {
@@ -144,6 +169,9 @@ func (s *Service) Register(ctx context.Context, req RegisterRequest, ip, userAge
 		return nil, nil, fmt.Errorf("inserting user: %w", err)
 	}
 
+	// The registration email is unverified, so it is not used for correlation.
+	s.recordSignals(ctx, user.ID, true, ip, nil, req.DeviceFingerprint)
+
 	session, err := s.createSession(ctx, user.ID, ip, userAgent)
 	if err != nil {
 		return nil, nil, err
@@ -158,7 +186,19 @@ func (s *Service) Register(ctx context.Context, req RegisterRequest, ip, userAge
 }
 
 // Login authenticates a user by username and password and creates a new session.
+// When the LDAP backend is configured, the directory is consulted first and
+// local password authentication is used for names it does not know.
 func (s *Service) Login(ctx context.Context, req LoginRequest, ip, userAgent string) (*models.User, *models.UserSession, error) {
+	if s.ldap != nil {
+		user, err := s.loginLDAP(ctx, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		if user != nil {
+			return s.completeLogin(ctx, user, req, ip, userAgent)
+		}
+	}
+
 	if err := validateUsername(req.Username); err != nil {
 		return nil, nil, err
 	}
@@ -169,7 +209,8 @@ func (s *Service) Login(ctx context.Context, req LoginRequest, ip, userAgent str
 		`SELECT id, instance_id, username, display_name, avatar_id, status_text,
 		        status_emoji, status_presence, status_expires_at, bio,
 		        banner_id, accent_color, pronouns,
-		        bot_owner_id, password_hash, totp_secret, email, flags, created_at
+		        bot_owner_id, password_hash, totp_secret, email, flags, created_at,
+		        share_federated_presence, read_receipts
 		 FROM users
 		 WHERE username = $1 AND instance_id = $2`,
 		req.Username, s.instanceID,
@@ -178,7 +219,8 @@ func (s *Service) Login(ctx context.Context, req LoginRequest, ip, userAgent str
 		&user.AvatarID, &user.StatusText, &user.StatusEmoji, &user.StatusPresence,
 		&user.StatusExpiresAt, &user.Bio, &user.BannerID, &user.AccentColor,
 		&user.Pronouns, &user.BotOwnerID, &passwordHash, &user.TOTPSecret,
-		&user.Email, &user.Flags, &user.CreatedAt,
+		&user.Email, &user.Flags, &user.CreatedAt, &user.ShareFederatedPresence,
+		&user.ReadReceipts,
 	)
 	if err == pgx.ErrNoRows {
 		return nil, nil, &AuthError{Code: "invalid_credentials", Message: "Invalid username or password", Status: 401}
@@ -187,10 +229,6 @@ func (s *Service) Login(ctx context.Context, req LoginRequest, ip, userAgent str
 		return nil, nil, fmt.Errorf("querying user: %w", err)
 	}
 
-	if user.IsSuspended() {
-		return nil, nil, &AuthError{Code: "user_suspended", Message: "This account has been suspended", Status: 403}
-	}
-
 	if user.IsDeleted() {
 		return nil, nil, &AuthError{Code: "invalid_credentials", Message: "Invalid username or password", Status: 401}
 	}
@@ -207,6 +245,18 @@ func (s *Service) Login(ctx context.Context, req LoginRequest, ip, userAgent str
 		return nil, nil, &AuthError{Code: "invalid_credentials", Message: "Invalid username or password", Status: 401}
 	}
 
+	// Checked only once the password is verified so the suspension reason is
+	// not disclosed to anyone who knows the username.
+	if user.IsSuspended() {
+		return nil, nil, s.suspensionError(ctx, user.ID)
+	}
+
+	return s.completeLogin(ctx, &user, req, ip, userAgent)
+}
+
+// completeLogin enforces TOTP for a user whose primary credentials have been
+// verified and creates the session.
+func (s *Service) completeLogin(ctx context.Context, user *models.User, req LoginRequest, ip, userAgent string) (*models.User, *models.UserSession, error) {
 	// Check if TOTP is enabled and verify the code.
 	if user.TOTPSecret != nil && *user.TOTPSecret != "" {
 		if req.TOTPCode == nil || *req.TOTPCode == "" {
@@ -221,13 +271,14 @@ func (s *Service) Login(ctx context.Context, req LoginRequest, ip, userAgent str
 	if err != nil {
 		return nil, nil, err
 	}
+	s.recordSignals(ctx, user.ID, false, ip, nil, req.DeviceFingerprint)
 
 	s.logger.Info("user logged in",
 		slog.String("user_id", user.ID),
 		slog.String("username", user.Username),
 	)
 
-	return &user, session, nil
+	return user, session, nil
 }
 
 // Logout invalidates a session by removing it from the database and cache.
@@ -306,6 +357,37 @@ func (s *Service) ValidateSession(ctx context.Context, sessionID string) (string
 	return userID, nil
 }
 
+// BotTokenPrefix marks bot API tokens. They are looked up by hash in
+// bot_tokens rather than as sessions.
+const BotTokenPrefix = "avbot_"
+
+// ValidateBotToken checks a bot API token and returns the bot's user ID, the
+// token's ID and the token's scope.
+func (s *Service) ValidateBotToken(ctx context.Context, token string) (botID, tokenID, scope string, err error) {
+	sum := sha256.Sum256([]byte(token))
+	hash := hex.EncodeToString(sum[:])
+	err = s.pool.QueryRow(ctx,
+		`SELECT id, bot_id, scope FROM bot_tokens WHERE token_hash = $1`, hash,
+	).Scan(&tokenID, &botID, &scope)
+	if err == pgx.ErrNoRows {
+		return "", "", "", &AuthError{Code: "invalid_token", Message: "Invalid bot token", Status: 401}
+	}
+	if err != nil {
+		return "", "", "", fmt.Errorf("querying bot token: %w", err)
+	}
+	if err := s.checkUserFlags(ctx, botID); err != nil {
+		return "", "", "", err
+	}
+
+	go func() {
+		bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.pool.Exec(bgCtx, "UPDATE bot_tokens SET last_used_at = now() WHERE id = $1", tokenID)
+	}()
+
+	return botID, tokenID, scope, nil
+}
+
 // checkUserFlags verifies a user is not suspended or deleted.
 func (s *Service) checkUserFlags(ctx context.Context, userID string) error {
 	var flags int
@@ -507,8 +589,14 @@ func generateSessionToken() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
+// ValidUsername reports whether username meets the rules registration
+// applies: 2-32 letters, numbers, underscores, hyphens and dots.
+func ValidUsername(username string) bool {
+	return usernameRegex.MatchString(username)
+}
+
 func validateUsername(username string) *AuthError {
-	if !usernameRegex.MatchString(username) {
+	if !ValidUsername(username) {
 		return &AuthError{
 			Code:    "invalid_username",
 			Message: "Username must be 2-32 characters and contain only letters, numbers, underscores, hyphens, and dots",
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestValidateUsername(t *testing.T) {
@@ -126,6 +127,17 @@ func TestSessionIDFromContext(t *testing.T) {
 	}
 }
 
+func TestTokenScopeFromContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ContextKeyTokenScope, "user_app")
+	if got := TokenScopeFromContext(ctx); got != "user_app" {
+		t.Errorf("TokenScopeFromContext = %q, want %q", got, "user_app")
+	}
+
+	if got := TokenScopeFromContext(context.Background()); got != "" {
+		t.Errorf("TokenScopeFromContext(empty) = %q, want empty", got)
+	}
+}
+
 func TestWriteAuthError(t *testing.T) {
 	w := httptest.NewRecorder()
 	writeAuthError(w, http.StatusUnauthorized, "test_code", "test message")
@@ -144,3 +156,140 @@ func TestAuthError_Error(t *testing.T) {
 		t.Errorf("Error() = %q, want %q", got, "test message")
 	}
 }
+
+func TestBuildUserFilter(t *testing.T) {
+	tests := []struct {
+		template string
+		username string
+		want     string
+	}{
+		{"(uid=%s)", "alice", "(uid=alice)"},
+		{"(&(objectClass=user)(sAMAccountName=%s))", "bob", "(&(objectClass=user)(sAMAccountName=bob))"},
+		{"(uid=%s)", "*)(uid=*", `(uid=\2a\29\28uid=\2a)`},
+		{"(|(uid=%s)(mail=%s))", "carol", "(|(uid=carol)(mail=carol))"},
+	}
+	for _, tc := range tests {
+		if got := buildUserFilter(tc.template, tc.username); got != tc.want {
+			t.Errorf("buildUserFilter(%q, %q) = %q, want %q", tc.template, tc.username, got, tc.want)
+		}
+	}
+}
+
+func TestHasGroup(t *testing.T) {
+	groups := []string{"CN=Engineering,OU=Groups,DC=example,DC=com", "cn=ops,ou=groups,dc=example,dc=com"}
+	if !hasGroup(groups, "cn=engineering,ou=groups,dc=example,dc=com") {
+		t.Error("expected case-insensitive DN match")
+	}
+	if hasGroup(groups, "cn=sales,ou=groups,dc=example,dc=com") {
+		t.Error("unexpected match for absent group")
+	}
+	if hasGroup(nil, "cn=ops,ou=groups,dc=example,dc=com") {
+		t.Error("unexpected match for empty group list")
+	}
+}
+
+func TestFormatSuspensionMessage(t *testing.T) {
+	until := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	reason := "Spam"
+	blank := "  "
+	tests := []struct {
+		reason *string
+		until  *time.Time
+		want   string
+	}{
+		{nil, nil, "This account has been suspended"},
+		{&reason, nil, "This account has been suspended. Reason: Spam"},
+		{nil, &until, "This account has been suspended until 2026-11-01T12:00:00Z"},
+		{&reason, &until, "This account has been suspended until 2026-11-01T12:00:00Z. Reason: Spam"},
+		{&blank, nil, "This account has been suspended"},
+	}
+	for _, tt := range tests {
+		if got := formatSuspensionMessage(tt.reason, tt.until); got != tt.want {
+			t.Errorf("formatSuspensionMessage = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email, want, domain string
+	}{
+		{"Alice@Example.com", "alice@example.com", "example.com"},
+		{"alice+spam@example.com", "alice@example.com", "example.com"},
+		{" bob@mail.example.org ", "bob@mail.example.org", "mail.example.org"},
+		{"+tag@example.com", "+tag@example.com", "example.com"},
+		{"no-at-sign", "", ""},
+		{"@example.com", "", ""},
+		{"alice@", "", ""},
+	}
+	for _, tt := range tests {
+		got, domain := normalizeEmail(tt.email)
+		if got != tt.want || domain != tt.domain {
+			t.Errorf("normalizeEmail(%q) = %q, %q, want %q, %q", tt.email, got, domain, tt.want, tt.domain)
+		}
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct {
+		ip, want string
+	}{
+		{"203.0.113.7", "203.0.113.7"},
+		{"203.0.113.7:443", "203.0.113.7"},
+		{"::ffff:203.0.113.7", "203.0.113.7"},
+		{"2001:db8:1:2:aaaa::1", "2001:db8:1:2::/64"},
+		{"[2001:db8:1:2:bbbb::9]:8080", "2001:db8:1:2::/64"},
+		{"not-an-ip", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeIP(tt.ip); got != tt.want {
+			t.Errorf("normalizeIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestHashSignalKeyed(t *testing.T) {
+	a := &Service{abuse: AbuseConfig{SignalKey: []byte("key-a")}}
+	b := &Service{abuse: AbuseConfig{SignalKey: []byte("key-b")}}
+	if a.hashSignal(SignalIP, "203.0.113.7") != a.hashSignal(SignalIP, "203.0.113.7") {
+		t.Error("hashSignal is not deterministic")
+	}
+	if a.hashSignal(SignalIP, "203.0.113.7") == b.hashSignal(SignalIP, "203.0.113.7") {
+		t.Error("hashSignal does not depend on the key")
+	}
+	if a.hashSignal(SignalIP, "x") == a.hashSignal(SignalDevice, "x") {
+		t.Error("hashSignal does not separate kinds")
+	}
+}
+
+func TestIsDisposableDomain(t *testing.T) {
+	set := buildDisposableSet([]string{" Spam.Example "})
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"mailinator.com", true},
+		{"eu.mailinator.com", true},
+		{"spam.example", true},
+		{"example", false},
+		{"gmail.com", false},
+		{"notmailinator.com", false},
+	}
+	for _, tt := range tests {
+		if got := isDisposableDomain(set, tt.domain); got != tt.want {
+			t.Errorf("isDisposableDomain(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestTokenIDFromContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ContextKeyTokenID, "tok_1")
+	if got := TokenIDFromContext(ctx); got != "tok_1" {
+		t.Errorf("TokenIDFromContext = %q, want %q", got, "tok_1")
+	}
+
+	if got := TokenIDFromContext(context.Background()); got != "" {
+		t.Errorf("TokenIDFromContext(empty) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,342 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// LDAPConfig holds the settings for the optional LDAP / Active Directory
+// authentication backend.
+type LDAPConfig struct {
+	URL                  string
+	StartTLS             bool
+	InsecureSkipVerify   bool
+	BindDN               string
+	BindPassword         string
+	BaseDN               string
+	UserFilter           string
+	UsernameAttribute    string
+	DisplayNameAttribute string
+	EmailAttribute       string
+	GroupAttribute       string
+	GroupRoles           []LDAPGroupRole
+}
+
+// LDAPGroupRole maps a directory group DN to a role in a designated guild.
+type LDAPGroupRole struct {
+	Group   string
+	GuildID string
+	RoleID  string
+}
+
+// ldapIdentity is the subset of a directory entry used for provisioning.
+type ldapIdentity struct {
+	DN          string
+	Username    string
+	DisplayName string
+	Email       string
+	Groups      []string
+}
+
+// errLDAPUserNotFound means the directory has no entry for the login name, so
+// the caller should fall back to local password authentication.
+var errLDAPUserNotFound = errors.New("ldap: user not found")
+
+// ldapTimeout bounds every directory round trip so a slow server cannot stall
+// the login endpoint.
+const ldapTimeout = 10 * time.Second
+
+// buildUserFilter substitutes the escaped login name into the configured
+// search filter template.
+func buildUserFilter(template, username string) string {
+	return strings.ReplaceAll(template, "%s", ldap.EscapeFilter(username))
+}
+
+// hasGroup reports whether groups contains the given DN. DN comparison is
+// case-insensitive, matching directory semantics.
+func hasGroup(groups []string, group string) bool {
+	for _, g := range groups {
+		if strings.EqualFold(strings.TrimSpace(g), strings.TrimSpace(group)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dial opens a connection to the directory, upgrading it with StartTLS when
+// configured.
+func (c *LDAPConfig) dial() (*ldap.Conn, error) {
+	tlsCfg := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if u, err := url.Parse(c.URL); err == nil {
+		tlsCfg.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(c.URL, ldap.DialWithTLSConfig(tlsCfg))
+	if err != nil {
+		return nil, fmt.Errorf("dialing LDAP server: %w", err)
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if c.StartTLS {
+		if err := conn.StartTLS(tlsCfg); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("starting TLS: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// authenticate locates the user's entry with the service account, then binds
+// as that entry with the supplied password. It returns errLDAPUserNotFound if
+// the search yields no entry and an AuthError if the password is rejected.
+func (c *LDAPConfig) authenticate(username, password string) (*ldapIdentity, error) {
+	// An empty password would turn the bind into an unauthenticated bind,
+	// which many servers accept.
+	if password == "" {
+		return nil, &AuthError{Code: "invalid_credentials", Message: "Invalid username or password", Status: 401}
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if c.BindDN != "" {
+		if err := conn.Bind(c.BindDN, c.BindPassword); err != nil {
+			return nil, fmt.Errorf("binding LDAP service account: %w", err)
+		}
+	}
+
+	attrs := []string{c.UsernameAttribute, c.DisplayNameAttribute, c.EmailAttribute, c.GroupAttribute}
+	res, err := conn.Search(ldap.NewSearchRequest(
+		c.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(ldapTimeout.Seconds()), false,
+		buildUserFilter(c.UserFilter, username), attrs, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("searching LDAP directory: %w", err)
+	}
+	if len(res.Entries) == 0 {
+		return nil, errLDAPUserNotFound
+	}
+	if len(res.Entries) > 1 {
+		return nil, fmt.Errorf("LDAP filter matched %d entries for %q", len(res.Entries), username)
+	}
+	entry := res.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, &AuthError{Code: "invalid_credentials", Message: "Invalid username or password", Status: 401}
+		}
+		return nil, fmt.Errorf("binding as LDAP user: %w", err)
+	}
+
+	ident := &ldapIdentity{
+		DN:          entry.DN,
+		Username:    entry.GetAttributeValue(c.UsernameAttribute),
+		DisplayName: entry.GetAttributeValue(c.DisplayNameAttribute),
+		Email:       entry.GetAttributeValue(c.EmailAttribute),
+		Groups:      entry.GetAttributeValues(c.GroupAttribute),
+	}
+	if ident.Username == "" {
+		ident.Username = username
+	}
+	return ident, nil
+}
+
+// loginLDAP verifies credentials against the directory and returns the linked
+// local user, creating it on first login. A nil user with a nil error means
+// the directory does not know the login name (or is unreachable) and local
+// authentication should be attempted instead.
+func (s *Service) loginLDAP(ctx context.Context, req LoginRequest) (*models.User, error) {
+	ident, err := s.ldap.authenticate(req.Username, req.Password)
+	if err != nil {
+		var authErr *AuthError
+		if errors.As(err, &authErr) {
+			return nil, authErr
+		}
+		if !errors.Is(err, errLDAPUserNotFound) {
+			s.logger.Warn("LDAP authentication unavailable, falling back to local login",
+				slog.String("error", err.Error()))
+		}
+		return nil, nil
+	}
+
+	userID, err := s.provisionLDAPUser(ctx, ident)
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, nil
+	}
+
+	s.syncLDAPGroupRoles(ctx, userID, ident.Groups)
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// GetUser omits the TOTP secret; completeLogin needs it to enforce 2FA.
+	if err := s.pool.QueryRow(ctx,
+		`SELECT totp_secret FROM users WHERE id = $1`, userID).Scan(&user.TOTPSecret); err != nil {
+		return nil, fmt.Errorf("querying TOTP secret: %w", err)
+	}
+	if user.IsSuspended() {
+		return nil, s.suspensionError(ctx, user.ID)
+	}
+	if user.IsDeleted() {
+		return nil, &AuthError{Code: "invalid_credentials", Message: "Invalid username or password", Status: 401}
+	}
+	return user, nil
+}
+
+// provisionLDAPUser finds the local account linked to a directory entry,
+// refreshing its mapped attributes, or creates one just in time. An existing
+// local account with the same username but no directory link is never taken
+// over; an empty ID is returned so the caller falls back to local login.
+func (s *Service) provisionLDAPUser(ctx context.Context, ident *ldapIdentity) (string, error) {
+	displayName := nullIfEmpty(ident.DisplayName)
+	email := nullIfEmpty(ident.Email)
+
+	var userID string
+	err := s.pool.QueryRow(ctx,
+		`UPDATE users SET display_name = COALESCE($3, display_name), email = COALESCE($4, email)
+		 WHERE ldap_dn = $1 AND instance_id = $2
+		 RETURNING id`,
+		ident.DN, s.instanceID, displayName, email).Scan(&userID)
+	if err == nil {
+		return userID, nil
+	}
+	if err != pgx.ErrNoRows {
+		return "", fmt.Errorf("updating LDAP user: %w", err)
+	}
+
+	if validateUsername(ident.Username) != nil {
+		s.logger.Warn("LDAP username is not a valid local username",
+			slog.String("dn", ident.DN), slog.String("username", ident.Username))
+		return "", &AuthError{Code: "invalid_username", Message: "Directory username cannot be used on this instance", Status: 403}
+	}
+
+	var exists bool
+	s.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(username) = LOWER($1) AND instance_id = $2)`,
+		ident.Username, s.instanceID).Scan(&exists)
+	if exists {
+		s.logger.Warn("LDAP login matches an unlinked local account, not linking",
+			slog.String("dn", ident.DN), slog.String("username", ident.Username))
+		return "", nil
+	}
+
+	userID = models.NewULID().String()
+	_, err = s.pool.Exec(ctx,
+		`INSERT INTO users (id, instance_id, username, display_name, email, ldap_dn, flags, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, 0, now())`,
+		userID, s.instanceID, ident.Username, displayName, email, ident.DN)
+	if err != nil {
+		return "", fmt.Errorf("creating LDAP user: %w", err)
+	}
+
+	s.logger.Info("LDAP user provisioned",
+		slog.String("user_id", userID),
+		slog.String("username", ident.Username),
+		slog.String("dn", ident.DN),
+	)
+	return userID, nil
+}
+
+// syncLDAPGroupRoles grants or revokes the configured guild roles according
+// to the user's current directory groups. Users are joined to a designated
+// guild the first time one of its mapped groups applies, unless they are
+// banned there, the guild is full or its join origin policy refuses them. A
+// role mapped from several groups is kept as long as any of them matches.
+func (s *Service) syncLDAPGroupRoles(ctx context.Context, userID string, groups []string) {
+	granted := make(map[string]bool)
+	for _, m := range s.ldap.GroupRoles {
+		if hasGroup(groups, m.Group) {
+			granted[m.RoleID] = true
+		}
+	}
+
+	for _, m := range s.ldap.GroupRoles {
+		if !granted[m.RoleID] {
+			tag, err := s.pool.Exec(ctx,
+				`DELETE FROM member_roles WHERE guild_id = $1 AND user_id = $2 AND role_id = $3`,
+				m.GuildID, userID, m.RoleID)
+			if err != nil {
+				s.logger.Warn("LDAP group sync: removing role failed",
+					slog.String("guild_id", m.GuildID), slog.String("error", err.Error()))
+			} else if tag.RowsAffected() > 0 {
+				s.publishRoleChange(ctx, m, userID, "role_remove")
+			}
+			continue
+		}
+
+		ref, err := apiutil.CheckUserJoinOrigin(ctx, s.pool, s.instanceID, m.GuildID, userID)
+		if err != nil {
+			s.logger.Warn("LDAP group sync: checking join origin failed",
+				slog.String("guild_id", m.GuildID), slog.String("error", err.Error()))
+			continue
+		}
+		if ref != nil {
+			continue
+		}
+		var joinedAt time.Time
+		var joined bool
+		err = apiutil.WithTx(ctx, s.pool, func(tx pgx.Tx) error {
+			var err error
+			joinedAt, joined, err = apiutil.AddGuildMember(ctx, tx, m.GuildID, userID)
+			return err
+		})
+		if errors.Is(err, apiutil.ErrJoinBanned) || errors.Is(err, apiutil.ErrJoinGuildFull) {
+			continue
+		}
+		if err != nil {
+			s.logger.Warn("LDAP group sync: adding guild member failed",
+				slog.String("guild_id", m.GuildID), slog.String("error", err.Error()))
+			continue
+		}
+		if joined {
+			apiutil.PublishMemberAdd(ctx, s.bus, m.GuildID, userID, joinedAt)
+		}
+
+		tag, err := s.pool.Exec(ctx,
+			`INSERT INTO member_roles (guild_id, user_id, role_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			m.GuildID, userID, m.RoleID)
+		if err != nil {
+			s.logger.Warn("LDAP group sync: granting role failed",
+				slog.String("guild_id", m.GuildID), slog.String("error", err.Error()))
+		} else if tag.RowsAffected() > 0 {
+			s.publishRoleChange(ctx, m, userID, "role_add")
+		}
+	}
+}
+
+// publishRoleChange tells the guild a group sync added or removed a role.
+func (s *Service) publishRoleChange(ctx context.Context, m LDAPGroupRole, userID, action string) {
+	if s.bus == nil {
+		return
+	}
+	s.bus.PublishGuildEvent(ctx, events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", m.GuildID,
+		map[string]interface{}{"guild_id": m.GuildID, "user_id": userID, "role_id": m.RoleID, "action": action})
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+
+	"github.com/amityvox/amityvox/internal/models"
 )
 
 type contextKey string
@@ -17,6 +19,12 @@ const (
 	ContextKeyUserID contextKey = "user_id"
 	// ContextKeySessionID is the context key for the current session token.
 	ContextKeySessionID contextKey = "session_id"
+	// ContextKeyTokenScope is the context key for a bot token's scope. It is
+	// unset for user sessions.
+	ContextKeyTokenScope contextKey = "token_scope"
+	// ContextKeyTokenID is the context key for the ID of the bot token that
+	// authenticated the request. It is unset for user sessions.
+	ContextKeyTokenID contextKey = "token_id"
 )
 
 // UserIDFromContext retrieves the authenticated user ID from the request context.
@@ -33,10 +41,58 @@ func SessionIDFromContext(ctx context.Context) string {
 	return v
 }
 
+// TokenScopeFromContext returns the scope of the bot token that
+// authenticated the request, or empty string for user sessions.
+func TokenScopeFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(ContextKeyTokenScope).(string)
+	return v
+}
+
+// TokenIDFromContext returns the ID of the bot token that authenticated
+// the request, or empty string for user sessions.
+func TokenIDFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(ContextKeyTokenID).(string)
+	return v
+}
+
+// authenticate resolves a bearer token, either a session or a bot token, to
+// a request context carrying the caller's identity. User-app tokens are
+// refused unless allowUserApp is set.
+func authenticate(ctx context.Context, svc *Service, token string, allowUserApp bool) (context.Context, error) {
+	if strings.HasPrefix(token, BotTokenPrefix) {
+		botID, tokenID, scope, err := svc.ValidateBotToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if scope == models.BotTokenScopeUserApp && !allowUserApp {
+			return nil, &AuthError{Code: "token_scope", Message: "This token may only be used for interactions", Status: 403}
+		}
+		ctx = context.WithValue(ctx, ContextKeyUserID, botID)
+		ctx = context.WithValue(ctx, ContextKeyTokenID, tokenID)
+		return context.WithValue(ctx, ContextKeyTokenScope, scope), nil
+	}
+	userID, err := svc.ValidateSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, ContextKeyUserID, userID)
+	return context.WithValue(ctx, ContextKeySessionID, token), nil
+}
+
 // RequireAuth returns middleware that validates the Bearer token and injects
 // the authenticated user ID into the request context. Requests without a valid
 // token receive a 401 Unauthorized response.
 func RequireAuth(svc *Service) func(http.Handler) http.Handler {
+	return requireAuth(svc, false)
+}
+
+// RequireAppAuth is RequireAuth for the interaction endpoints, which also
+// accept user-app tokens. Those tokens are refused everywhere else.
+func RequireAppAuth(svc *Service) func(http.Handler) http.Handler {
+	return requireAuth(svc, true)
+}
+
+func requireAuth(svc *Service, allowUserApp bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			token := extractBearerToken(r)
@@ -45,7 +101,7 @@ func RequireAuth(svc *Service) func(http.Handler) http.Handler {
 				return
 			}
 
-			userID, err := svc.ValidateSession(r.Context(), token)
+			ctx, err := authenticate(r.Context(), svc, token, allowUserApp)
 			if err != nil {
 				if authErr, ok := err.(*AuthError); ok {
 					writeAuthError(w, authErr.Status, authErr.Code, authErr.Message)
@@ -55,8 +111,6 @@ func RequireAuth(svc *Service) func(http.Handler) http.Handler {
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), ContextKeyUserID, userID)
-			ctx = context.WithValue(ctx, ContextKeySessionID, token)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -74,10 +128,7 @@ func OptionalAuth(svc *Service) func(http.Handler) http.Handler {
 				return
 			}
 
-			userID, err := svc.ValidateSession(r.Context(), token)
-			if err == nil && userID != "" {
-				ctx := context.WithValue(r.Context(), ContextKeyUserID, userID)
-				ctx = context.WithValue(ctx, ContextKeySessionID, token)
+			if ctx, err := authenticate(r.Context(), svc, token, false); err == nil {
 				r = r.WithContext(ctx)
 			}
 
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+)
+
+// Account signal kinds recorded for alt-account correlation.
+const (
+	SignalIP     = "ip"
+	SignalEmail  = "email"
+	SignalDevice = "device"
+)
+
+// AbuseConfig holds the registration abuse protections. Zero limits
+// disable the corresponding cap.
+type AbuseConfig struct {
+	RegistrationsPerIPHour int
+	RegistrationsPerIPDay  int
+	BlockDisposableEmail   bool
+	DisposableDomains      []string // Added to defaultDisposableDomains.
+	SignalKey              []byte   // HMAC key for stored signal hashes.
+}
+
+// defaultDisposableDomains is a small built-in list of well-known throwaway
+// email providers. Operators extend it with auth.abuse.disposable_domains.
+var defaultDisposableDomains = []string{
+	"10minutemail.com",
+	"discard.email",
+	"dispostable.com",
+	"emailondeck.com",
+	"fakeinbox.com",
+	"getnada.com",
+	"guerrillamail.com",
+	"maildrop.cc",
+	"mailinator.com",
+	"mailnesia.com",
+	"mintemail.com",
+	"mohmal.com",
+	"sharklasers.com",
+	"temp-mail.org",
+	"tempmail.com",
+	"throwawaymail.com",
+	"trashmail.com",
+	"yopmail.com",
+}
+
+// buildDisposableSet merges the built-in and configured disposable domains
+// into a lookup set of lowercased names.
+func buildDisposableSet(extra []string) map[string]bool {
+	set := make(map[string]bool, len(defaultDisposableDomains)+len(extra))
+	for _, list := range [][]string{defaultDisposableDomains, extra} {
+		for _, d := range list {
+			if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+				set[d] = true
+			}
+		}
+	}
+	return set
+}
+
+// isDisposableDomain reports whether domain or any parent domain of it is in
+// the set, so subdomains of a throwaway provider are caught too.
+func isDisposableDomain(set map[string]bool, domain string) bool {
+	for domain != "" {
+		if set[domain] {
+			return true
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	return false
+}
+
+// normalizeEmail lowercases an address and drops any "+tag" suffix from the
+// local part, so plus-addressed variants of one mailbox compare equal. It
+// returns the normalized address and its domain, or empty strings if the
+// address is malformed.
+func normalizeEmail(email string) (string, string) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 {
+		return "", ""
+	}
+	local, domain := email[:at], email[at+1:]
+	if plus := strings.IndexByte(local, '+'); plus > 0 {
+		local = local[:plus]
+	}
+	return local + "@" + domain, domain
+}
+
+// normalizeIP strips any port from ip and returns the canonical address
+// string, or an empty string if it does not parse. IPv6 addresses are
+// reduced to their /64, since a single subscriber usually holds a whole /64
+// and can rotate through it freely.
+func normalizeIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(ip)
+	if err != nil {
+		host = ip
+	}
+	parsed := net.ParseIP(host)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.String()
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// hashSignal keys a signal value with the server's signal secret so that
+// raw IPs, emails, and fingerprints are never stored in the signals table
+// and the small IP and email spaces cannot be brute-forced from a copy of it.
+func (s *Service) hashSignal(kind, value string) string {
+	mac := hmac.New(sha256.New, s.abuse.SignalKey)
+	mac.Write([]byte(kind + "\x00" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkRegistrationAbuse rejects a registration from a disposable email
+// domain or from an IP address that has exceeded the hourly or daily cap.
+func (s *Service) checkRegistrationAbuse(ctx context.Context, email *string, ip string) error {
+	if s.abuse.BlockDisposableEmail && email != nil {
+		if _, domain := normalizeEmail(*email); domain != "" && isDisposableDomain(s.disposableDomains, domain) {
+			return &AuthError{Code: "disposable_email", Message: "Disposable email addresses are not allowed on this instance", Status: 400}
+		}
+	}
+
+	addr := normalizeIP(ip)
+	if addr == "" || (s.abuse.RegistrationsPerIPHour <= 0 && s.abuse.RegistrationsPerIPDay <= 0) {
+		return nil
+	}
+
+	var lastHour, lastDay int
+	err := s.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FILTER (WHERE first_seen_at > now() - interval '1 hour'), COUNT(*)
+		 FROM account_signals
+		 WHERE kind = $1 AND value_hash = $2 AND registration
+		   AND first_seen_at > now() - interval '1 day'`,
+		SignalIP, s.hashSignal(SignalIP, addr)).Scan(&lastHour, &lastDay)
+	if err != nil {
+		return fmt.Errorf("counting registrations by IP: %w", err)
+	}
+	if (s.abuse.RegistrationsPerIPHour > 0 && lastHour >= s.abuse.RegistrationsPerIPHour) ||
+		(s.abuse.RegistrationsPerIPDay > 0 && lastDay >= s.abuse.RegistrationsPerIPDay) {
+		return &AuthError{Code: "registration_rate_limited", Message: "Too many accounts have been created from this network. Please try again later", Status: 429}
+	}
+	return nil
+}
+
+// recordSignals stores hashed correlation signals for a user. registration
+// marks signals seen at account creation, which count towards the per-IP
+// registration caps. verifiedEmail must only be set for an address the user
+// has proven they control; anyone can type someone else's address, and
+// correlating on it would link an account to a stranger's. Failures are
+// logged rather than returned so that abuse bookkeeping never blocks a login.
+func (s *Service) recordSignals(ctx context.Context, userID string, registration bool, ip string, verifiedEmail *string, fingerprint string) {
+	signals := make(map[string]string, 3)
+	if addr := normalizeIP(ip); addr != "" {
+		signals[SignalIP] = addr
+	}
+	if verifiedEmail != nil {
+		if normalized, _ := normalizeEmail(*verifiedEmail); normalized != "" {
+			signals[SignalEmail] = normalized
+		}
+	}
+	if fp := strings.TrimSpace(fingerprint); fp != "" && len(fp) <= 256 {
+		signals[SignalDevice] = fp
+	}
+
+	for kind, value := range signals {
+		_, err := s.pool.Exec(ctx,
+			`INSERT INTO account_signals (user_id, kind, value_hash, registration, first_seen_at, last_seen_at)
+			 VALUES ($1, $2, $3, $4, now(), now())
+			 ON CONFLICT (user_id, kind, value_hash) DO UPDATE SET last_seen_at = now()`,
+			userID, kind, s.hashSignal(kind, value), registration)
+		if err != nil {
+			s.logger.Warn("failed to record account signal",
+				slog.String("user_id", userID), slog.String("kind", kind), slog.String("error", err.Error()))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// AppealRequest is the request body for appealing a suspension. Suspended
+// users have no session, so the appeal is authenticated with credentials.
+type AppealRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Message  string `json:"message"`
+}
+
+// formatSuspensionMessage builds the message shown to a suspended user at
+// login from the public reason and expiry, either of which may be unset.
+func formatSuspensionMessage(reason *string, until *time.Time) string {
+	msg := "This account has been suspended"
+	if until != nil {
+		msg += " until " + until.UTC().Format(time.RFC3339)
+	}
+	if reason != nil && strings.TrimSpace(*reason) != "" {
+		msg += ". Reason: " + strings.TrimSpace(*reason)
+	}
+	return msg
+}
+
+// suspensionError returns the login error for a suspended user, including the
+// public suspension reason and expiry when set.
+func (s *Service) suspensionError(ctx context.Context, userID string) *AuthError {
+	var reason *string
+	var until *time.Time
+	s.pool.QueryRow(ctx,
+		`SELECT suspension_reason, suspended_until FROM users WHERE id = $1`, userID).Scan(&reason, &until)
+	return &AuthError{Code: "user_suspended", Message: formatSuspensionMessage(reason, until), Status: 403}
+}
+
+// SubmitSuspensionAppeal records an appeal from a suspended user after
+// verifying their credentials. Only one appeal may be pending at a time.
+func (s *Service) SubmitSuspensionAppeal(ctx context.Context, req AppealRequest) (*models.SuspensionAppeal, error) {
+	message := strings.TrimSpace(req.Message)
+	if message == "" || len([]rune(message)) > 2000 {
+		return nil, &AuthError{Code: "invalid_message", Message: "Appeal message must be 1-2000 characters", Status: 400}
+	}
+
+	userID, flags, err := s.verifyCredentials(ctx, req.Username, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if flags&models.UserFlagSuspended == 0 || flags&models.UserFlagDeleted != 0 {
+		return nil, &AuthError{Code: "not_suspended", Message: "This account is not suspended", Status: 400}
+	}
+
+	appeal := &models.SuspensionAppeal{
+		ID:      models.NewULID().String(),
+		UserID:  userID,
+		Message: message,
+		Status:  models.AppealStatusPending,
+	}
+	err = s.pool.QueryRow(ctx,
+		`INSERT INTO suspension_appeals (id, user_id, message, status, created_at)
+		 VALUES ($1, $2, $3, $4, now())
+		 ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+		 RETURNING created_at`,
+		appeal.ID, appeal.UserID, appeal.Message, appeal.Status).Scan(&appeal.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, &AuthError{Code: "appeal_pending", Message: "An appeal is already awaiting review", Status: 409}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inserting suspension appeal: %w", err)
+	}
+	return appeal, nil
+}
+
+// verifyCredentials checks a username and password against the local hash,
+// or against the directory for LDAP-linked accounts, without the suspension
+// and TOTP checks of a full login. It returns the user's ID and flags.
+func (s *Service) verifyCredentials(ctx context.Context, username, password string) (string, int, error) {
+	invalid := &AuthError{Code: "invalid_credentials", Message: "Invalid username or password", Status: 401}
+
+	var userID string
+	var flags int
+	var passwordHash, ldapDN *string
+	err := s.pool.QueryRow(ctx,
+		`SELECT id, flags, password_hash, ldap_dn FROM users WHERE username = $1 AND instance_id = $2`,
+		username, s.instanceID).Scan(&userID, &flags, &passwordHash, &ldapDN)
+	if err == pgx.ErrNoRows {
+		return "", 0, invalid
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("querying user: %w", err)
+	}
+
+	switch {
+	case passwordHash != nil:
+		match, err := argon2id.ComparePasswordAndHash(password, *passwordHash)
+		if err != nil {
+			return "", 0, fmt.Errorf("comparing password hash: %w", err)
+		}
+		if !match {
+			return "", 0, invalid
+		}
+	case ldapDN != nil && s.ldap != nil:
+		ident, err := s.ldap.authenticate(username, password)
+		if err != nil {
+			var authErr *AuthError
+			if errors.As(err, &authErr) || errors.Is(err, errLDAPUserNotFound) {
+				return "", 0, invalid
+			}
+			return "", 0, err
+		}
+		if !strings.EqualFold(ident.DN, *ldapDN) {
+			return "", 0, invalid
+		}
+	default:
+		return "", 0, invalid
+	}
+	return userID, flags, nil
+}
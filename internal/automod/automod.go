@@ -15,6 +15,7 @@ import (
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
 )
@@ -28,39 +29,57 @@ const (
 	RuleCapsFilter   = "caps_filter"
 	RuleSpamFilter   = "spam_filter"
 	RuleLinkFilter   = "link_filter"
+	RuleEmojiHash    = "emoji_hash" // checked on emoji upload, not messages
+
+	// RuleContentWarning requires a content warning on messages in the
+	// rule's channels.
+	RuleContentWarning = "content_warning"
+
+	// RuleAttachmentHash matches the SHA-256 of a message's attachments
+	// against a blocklist. Only the instance policy can use it.
+	RuleAttachmentHash = "attachment_hash"
+
+	// RuleNameFilter checks member nicknames and display names on join and
+	// when they change, not messages. See names.go.
+	RuleNameFilter = "name_filter"
 )
 
 // Actions that can be taken when a rule triggers.
 const (
-	ActionDelete  = "delete"
-	ActionWarn    = "warn"
-	ActionTimeout = "timeout"
-	ActionLog     = "log"
+	ActionDelete     = "delete"
+	ActionWarn       = "warn"
+	ActionTimeout    = "timeout"
+	ActionLog        = "log"
+	ActionQuarantine = "quarantine" // shadow-quarantine the author pending moderator review
 )
 
-// Rule represents an automod rule configured for a guild.
+// Rule represents an automod rule configured for a guild, or for every
+// guild when Instance is set.
 type Rule struct {
-	ID                     string    `json:"id"`
-	GuildID                string    `json:"guild_id"`
-	Name                   string    `json:"name"`
-	Enabled                bool      `json:"enabled"`
-	RuleType               string    `json:"rule_type"`
+	ID                     string     `json:"id"`
+	GuildID                string     `json:"guild_id,omitempty"`
+	Instance               bool       `json:"instance,omitempty"`
+	Name                   string     `json:"name"`
+	Enabled                bool       `json:"enabled"`
+	RuleType               string     `json:"rule_type"`
 	Config                 RuleConfig `json:"config"`
-	Action                 string    `json:"action"`
-	TimeoutDurationSeconds int       `json:"timeout_duration_seconds,omitempty"`
-	ExemptChannelIDs       []string  `json:"exempt_channel_ids,omitempty"`
-	ExemptRoleIDs          []string  `json:"exempt_role_ids,omitempty"`
-	CreatedBy              string    `json:"created_by,omitempty"`
-	CreatedAt              time.Time `json:"created_at"`
-	UpdatedAt              time.Time `json:"updated_at"`
+	Action                 string     `json:"action"`
+	TimeoutDurationSeconds int        `json:"timeout_duration_seconds,omitempty"`
+	ExemptChannelIDs       []string   `json:"exempt_channel_ids,omitempty"`
+	ExemptRoleIDs          []string   `json:"exempt_role_ids,omitempty"`
+	CreatedBy              string     `json:"created_by,omitempty"`
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
 }
 
 // RuleConfig is the JSON configuration blob for a rule. Fields are optional
 // and depend on the rule type.
 type RuleConfig struct {
-	// word_filter
+	// word_filter. Languages, if set, limits the word list to channels
+	// declaring one of those languages, plus channels that declare none.
 	Words          []string `json:"words,omitempty"`
 	MatchWholeWord bool     `json:"match_whole_word,omitempty"`
+	Languages      []string `json:"languages,omitempty"`
 
 	// regex_filter
 	Patterns []string `json:"patterns,omitempty"`
@@ -83,19 +102,37 @@ type RuleConfig struct {
 	// link_filter
 	AllowedDomains []string `json:"allowed_domains,omitempty"`
 	BlockedDomains []string `json:"blocked_domains,omitempty"`
+
+	// emoji_hash: hex SHA-256 digests of blocked emoji images. A "delete"
+	// action rejects the upload; "log" keeps the emoji flagged for review.
+	// attachment_hash: digests of blocked attachments.
+	BlockedHashes []string `json:"blocked_hashes,omitempty"`
+
+	// content_warning: the designated channels, empty for every channel not
+	// exempted, and whether only messages with attachments need a warning.
+	ChannelIDs      []string `json:"channel_ids,omitempty"`
+	AttachmentsOnly bool     `json:"attachments_only,omitempty"`
+
+	// name_filter also takes Words, MatchWholeWord and Patterns. Dehoist
+	// matches names starting with symbols; ReplacementName is what the
+	// delete action renames members to when their name cannot be kept.
+	Dehoist         bool   `json:"dehoist,omitempty"`
+	ReplacementName string `json:"replacement_name,omitempty"`
 }
 
 // ActionRecord is an audit log entry for an automod action.
 type ActionRecord struct {
-	ID        string    `json:"id"`
-	GuildID   string    `json:"guild_id"`
-	RuleID    string    `json:"rule_id"`
-	ChannelID string    `json:"channel_id"`
-	MessageID string    `json:"message_id,omitempty"`
-	UserID    string    `json:"user_id"`
-	Action    string    `json:"action"`
-	Reason    string    `json:"reason"`
-	CreatedAt time.Time `json:"created_at"`
+	ID      string `json:"id"`
+	GuildID string `json:"guild_id"`
+	RuleID  string `json:"rule_id,omitempty"`
+	// InstanceRuleID is set instead of RuleID for instance policy actions.
+	InstanceRuleID string    `json:"instance_rule_id,omitempty"`
+	ChannelID      string    `json:"channel_id"`
+	MessageID      string    `json:"message_id,omitempty"`
+	UserID         string    `json:"user_id"`
+	Action         string    `json:"action"`
+	Reason         string    `json:"reason"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 // MessageContext holds the data needed to evaluate automod rules against a message.
@@ -107,6 +144,18 @@ type MessageContext struct {
 	Content   string
 	// MemberRoleIDs are the role IDs of the message author in the guild.
 	MemberRoleIDs []string
+	// Strict is set for authors from restricted federation peers: role
+	// exemptions do not apply and rule limits are halved.
+	Strict bool
+	// ContentWarning is the message's content warning, empty if it has none.
+	ContentWarning string
+	// HasAttachments is set when the message carries files.
+	HasAttachments bool
+	// Language is the channel's declared language, empty if it has none.
+	Language string
+	// AttachmentHashes are the SHA-256 digests of the message's files, where
+	// known.
+	AttachmentHashes []string
 }
 
 // Service is the automod engine. It loads guild rules and evaluates messages.
@@ -186,14 +235,70 @@ func (s *Service) LoadGuildRules(ctx context.Context, guildID string) ([]Rule, e
 	return rules, nil
 }
 
-// Evaluate checks a message against all enabled rules for its guild.
+// LoadInstanceRules fetches the enabled rules of the instance policy.
+func (s *Service) LoadInstanceRules(ctx context.Context) ([]Rule, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT id, name, enabled, rule_type, config, action,
+		        timeout_duration_seconds, created_by, created_at, updated_at
+		 FROM instance_automod_rules
+		 WHERE enabled = true
+		 ORDER BY created_at ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("loading instance automod rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []Rule
+	for rows.Next() {
+		r := Rule{Instance: true}
+		var configJSON []byte
+		var createdBy *string
+		if err := rows.Scan(
+			&r.ID, &r.Name, &r.Enabled, &r.RuleType, &configJSON, &r.Action,
+			&r.TimeoutDurationSeconds, &createdBy, &r.CreatedAt, &r.UpdatedAt,
+		); err != nil {
+			s.logger.Error("scanning instance automod rule", slog.String("error", err.Error()))
+			continue
+		}
+		if err := json.Unmarshal(configJSON, &r.Config); err != nil {
+			s.logger.Error("parsing instance automod rule config",
+				slog.String("rule_id", r.ID),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		if createdBy != nil {
+			r.CreatedBy = *createdBy
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// loadRules returns the instance policy followed by the guild's own rules,
+// the order they are evaluated in.
+func (s *Service) loadRules(ctx context.Context, guildID string) ([]Rule, error) {
+	rules, err := s.LoadInstanceRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	guildRules, err := s.LoadGuildRules(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, guildRules...), nil
+}
+
+// Evaluate checks a message against the instance policy and then the enabled
+// rules of its guild. Guild exemptions do not apply to instance rules.
 // Returns the first triggered rule and reason, or nil if no rules triggered.
 func (s *Service) Evaluate(ctx context.Context, msg MessageContext) (*Rule, string, error) {
 	if msg.GuildID == "" {
 		return nil, "", nil // DMs have no automod.
 	}
 
-	rules, err := s.LoadGuildRules(ctx, msg.GuildID)
+	rules, err := s.loadRules(ctx, msg.GuildID)
 	if err != nil {
 		return nil, "", err
 	}
@@ -207,10 +312,16 @@ func (s *Service) Evaluate(ctx context.Context, msg MessageContext) (*Rule, stri
 		}
 
 		// Check role exemptions.
-		if hasExemptRole(msg.MemberRoleIDs, rule.ExemptRoleIDs) {
+		if !msg.Strict && hasExemptRole(msg.MemberRoleIDs, rule.ExemptRoleIDs) {
 			continue
 		}
 
+		if msg.Strict {
+			strict := *rule
+			strict.Config = strictRuleConfig(rule.Config)
+			rule = &strict
+		}
+
 		triggered, reason := s.checkRule(rule, msg)
 		if triggered {
 			return rule, reason, nil
@@ -220,6 +331,39 @@ func (s *Service) Evaluate(ctx context.Context, msg MessageContext) (*Rule, stri
 	return nil, "", nil
 }
 
+// EvaluateEmoji checks an emoji image hash against the enabled emoji_hash
+// rules of the instance policy and the guild. Returns the first rule that
+// blocks it and the reason, or nil if none do.
+func (s *Service) EvaluateEmoji(ctx context.Context, guildID, imageHash string) (*Rule, string, error) {
+	rules, err := s.loadRules(ctx, guildID)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range rules {
+		if rules[i].RuleType != RuleEmojiHash {
+			continue
+		}
+		if triggered, reason := checkEmojiHash(imageHash, rules[i].Config); triggered {
+			return &rules[i], reason, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// ReportEmoji announces that an emoji upload tripped a rule, for the guild's
+// moderators.
+func (s *Service) ReportEmoji(ctx context.Context, rule *Rule, guildID, userID, emojiName, reason string) error {
+	return s.bus.PublishGuildEvent(ctx, events.SubjectAutomodAction, "AUTOMOD_ACTION", guildID, map[string]interface{}{
+		"guild_id":   guildID,
+		"user_id":    userID,
+		"emoji_name": emojiName,
+		"rule_id":    rule.ID,
+		"rule_name":  rule.Name,
+		"action":     rule.Action,
+		"reason":     reason,
+	})
+}
+
 // CleanupSpam removes stale entries from the spam tracker.
 func (s *Service) CleanupSpam(maxAge time.Duration) {
 	s.spam.Cleanup(maxAge)
@@ -228,11 +372,17 @@ func (s *Service) CleanupSpam(maxAge time.Duration) {
 // ExecuteAction performs the configured action for a triggered rule.
 func (s *Service) ExecuteAction(ctx context.Context, rule *Rule, msg MessageContext, reason string) error {
 	// Log the action to the audit table.
+	var ruleID, instanceRuleID *string
+	if rule.Instance {
+		instanceRuleID = &rule.ID
+	} else {
+		ruleID = &rule.ID
+	}
 	actionID := models.NewULID().String()
 	_, err := s.pool.Exec(ctx,
-		`INSERT INTO automod_actions (id, guild_id, rule_id, channel_id, message_id, user_id, action, reason, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())`,
-		actionID, rule.GuildID, rule.ID, msg.ChannelID, msg.MessageID, msg.AuthorID, rule.Action, reason,
+		`INSERT INTO automod_actions (id, guild_id, rule_id, instance_rule_id, channel_id, message_id, user_id, action, reason, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())`,
+		actionID, msg.GuildID, ruleID, instanceRuleID, msg.ChannelID, msg.MessageID, msg.AuthorID, rule.Action, reason,
 	)
 	if err != nil {
 		s.logger.Error("failed to log automod action",
@@ -255,6 +405,8 @@ func (s *Service) ExecuteAction(ctx context.Context, rule *Rule, msg MessageCont
 	case ActionLog:
 		// Just log — the audit entry above is the record.
 		return s.publishAutomodEvent(ctx, rule, msg, reason)
+	case ActionQuarantine:
+		return s.quarantineUser(ctx, rule, msg, reason)
 	}
 
 	return nil
@@ -291,6 +443,12 @@ func (s *Service) timeoutUser(ctx context.Context, msg MessageContext, duration
 	if err != nil {
 		return fmt.Errorf("timing out user %s: %w", msg.AuthorID, err)
 	}
+	if roleID := apiutil.TimeoutRoleID(ctx, s.pool, msg.GuildID); roleID != "" {
+		if err := apiutil.SyncTimeoutRole(ctx, s.pool, s.bus, msg.GuildID, roleID, msg.AuthorID); err != nil {
+			s.logger.Warn("failed to sync timeout role",
+				slog.String("user_id", msg.AuthorID), slog.String("error", err.Error()))
+		}
+	}
 
 	// Also delete the offending message.
 	s.deleteMessage(ctx, msg)
@@ -303,6 +461,48 @@ func (s *Service) timeoutUser(ctx context.Context, msg MessageContext, duration
 	return nil
 }
 
+// quarantineUser places the author under shadow quarantine and hides the
+// triggering message from everyone else. Other clients already received the
+// message, so they get a MESSAGE_DELETE while the author is re-sent the
+// message on the quarantined subject and keeps seeing it.
+func (s *Service) quarantineUser(ctx context.Context, rule *Rule, msg MessageContext, reason string) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO user_quarantines (id, user_id, source, reason, guild_id, status, created_at)
+		 VALUES ($1, $2, $3, $4, $5, 'pending', now())
+		 ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING`,
+		models.NewULID().String(), msg.AuthorID, models.QuarantineSourceAutoMod,
+		fmt.Sprintf("AutoMod rule %q: %s", rule.Name, reason), msg.GuildID)
+	if err != nil {
+		return fmt.Errorf("quarantining user %s: %w", msg.AuthorID, err)
+	}
+
+	var m models.Message
+	err = s.pool.QueryRow(ctx,
+		`UPDATE messages SET flags = flags | $2 WHERE id = $1
+		 RETURNING id, channel_id, author_id, content, nonce, message_type, edited_at, flags,
+		           reply_to_ids, mention_user_ids, mention_role_ids, mention_here, created_at`,
+		msg.MessageID, models.MessageFlagQuarantined).Scan(
+		&m.ID, &m.ChannelID, &m.AuthorID, &m.Content, &m.Nonce, &m.MessageType, &m.EditedAt, &m.Flags,
+		&m.ReplyToIDs, &m.MentionUserIDs, &m.MentionRoleIDs, &m.MentionHere, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("quarantining message %s: %w", msg.MessageID, err)
+	}
+
+	s.bus.PublishChannelEvent(ctx, events.SubjectMessageDelete, "MESSAGE_DELETE", msg.ChannelID, map[string]string{
+		"id":         msg.MessageID,
+		"channel_id": msg.ChannelID,
+		"guild_id":   msg.GuildID,
+	})
+	s.bus.PublishUserEvent(ctx, events.SubjectQuarantinedMessage, "MESSAGE_CREATE", msg.AuthorID, m)
+
+	s.logger.Info("automod quarantined user",
+		slog.String("user_id", msg.AuthorID),
+		slog.String("guild_id", msg.GuildID),
+		slog.String("rule_id", rule.ID),
+	)
+	return s.publishAutomodEvent(ctx, rule, msg, reason)
+}
+
 // publishAutomodEvent publishes an automod action event to the event bus.
 func (s *Service) publishAutomodEvent(ctx context.Context, rule *Rule, msg MessageContext, reason string) error {
 	return s.bus.PublishGuildEvent(ctx, events.SubjectAutomodAction, "AUTOMOD_ACTION", msg.GuildID, map[string]interface{}{
@@ -312,15 +512,23 @@ func (s *Service) publishAutomodEvent(ctx context.Context, rule *Rule, msg Messa
 		"user_id":    msg.AuthorID,
 		"rule_id":    rule.ID,
 		"rule_name":  rule.Name,
+		"instance":   rule.Instance,
 		"action":     rule.Action,
 		"reason":     reason,
 	})
 }
 
-// checkRule evaluates a single rule against a message.
+// checkRule evaluates a single rule against a message. Rules on the text
+// pass messages without any, such as files posted alone.
 func (s *Service) checkRule(rule *Rule, msg MessageContext) (bool, string) {
+	if msg.Content == "" && rule.RuleType != RuleContentWarning && rule.RuleType != RuleAttachmentHash {
+		return false, ""
+	}
 	switch rule.RuleType {
 	case RuleWordFilter:
+		if !wordListApplies(rule.Config, msg.Language) {
+			return false, ""
+		}
 		return checkWordFilter(msg.Content, rule.Config)
 	case RuleRegexFilter:
 		return checkRegexFilter(msg.Content, rule.Config)
@@ -334,6 +542,10 @@ func (s *Service) checkRule(rule *Rule, msg MessageContext) (bool, string) {
 		return s.spam.Check(msg.AuthorID, msg.ChannelID, msg.Content, rule.Config)
 	case RuleLinkFilter:
 		return checkLinkFilter(msg.Content, rule.Config)
+	case RuleContentWarning:
+		return checkContentWarning(msg, rule.Config)
+	case RuleAttachmentHash:
+		return checkAttachmentHashes(msg.AttachmentHashes, rule.Config)
 	default:
 		return false, ""
 	}
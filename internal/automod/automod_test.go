@@ -36,6 +36,34 @@ func TestCheckWordFilter_WholeWord(t *testing.T) {
 	}
 }
 
+func TestWordFilter_Languages(t *testing.T) {
+	s := &Service{}
+	rule := &Rule{RuleType: RuleWordFilter, Config: RuleConfig{Words: []string{"scheisse"}, Languages: []string{"de"}}}
+
+	if ok, _ := s.checkRule(rule, MessageContext{Content: "scheisse", Language: "de"}); !ok {
+		t.Error("expected German word list to apply in a German channel")
+	}
+	if ok, _ := s.checkRule(rule, MessageContext{Content: "scheisse", Language: "en"}); ok {
+		t.Error("expected German word list to skip an English channel")
+	}
+	if ok, _ := s.checkRule(rule, MessageContext{Content: "scheisse"}); !ok {
+		t.Error("expected word list to apply in a channel without a language")
+	}
+}
+
+func TestNormalizeLanguages(t *testing.T) {
+	cfg := RuleConfig{Languages: []string{"de-DE", "pt_BR"}}
+	if err := normalizeLanguages(&cfg); err != nil {
+		t.Fatalf("normalizeLanguages: %v", err)
+	}
+	if cfg.Languages[0] != "de" || cfg.Languages[1] != "pt" {
+		t.Errorf("Languages = %v, want [de pt]", cfg.Languages)
+	}
+	if err := normalizeLanguages(&RuleConfig{Languages: []string{"not a language"}}); err == nil {
+		t.Error("expected an invalid language to be rejected")
+	}
+}
+
 func TestCheckRegexFilter(t *testing.T) {
 	cfg := RuleConfig{Patterns: []string{`\b\d{16}\b`}} // credit card pattern
 
@@ -83,6 +111,28 @@ func TestCheckMentionSpam(t *testing.T) {
 	}
 }
 
+func TestStrictRuleConfig(t *testing.T) {
+	cfg := strictRuleConfig(RuleConfig{MaxMentions: 3, MaxMessages: 10})
+	if cfg.MaxMentions != 2 {
+		t.Errorf("MaxMentions = %d, want floor of 2", cfg.MaxMentions)
+	}
+	if cfg.MaxMessages != 5 {
+		t.Errorf("MaxMessages = %d, want 5", cfg.MaxMessages)
+	}
+	// Unset limits are tightened from their defaults.
+	if cfg.MaxCapsPercent != 35 || cfg.MaxDuplicates != 1 || cfg.WindowSeconds != 10 {
+		t.Errorf("defaults not tightened: %+v", cfg)
+	}
+
+	msg := "<@user1> <@user2>"
+	if ok, _ := checkMentionSpam(msg, RuleConfig{MaxMentions: 4}); ok {
+		t.Error("expected no trigger with 2 mentions and max 4")
+	}
+	if ok, _ := checkMentionSpam(msg, strictRuleConfig(RuleConfig{MaxMentions: 4})); !ok {
+		t.Error("expected strict limits to trigger with 2 mentions")
+	}
+}
+
 func TestCheckCapsFilter(t *testing.T) {
 	cfg := RuleConfig{MaxCapsPercent: 70, MinLength: 5}
 
@@ -130,6 +180,20 @@ func TestCheckLinkFilter_Subdomain(t *testing.T) {
 	}
 }
 
+func TestCheckEmojiHash(t *testing.T) {
+	cfg := RuleConfig{BlockedHashes: []string{" ABCDEF0123 ", "99ff"}}
+
+	if ok, _ := checkEmojiHash("abcdef0123", cfg); !ok {
+		t.Error("expected blocklisted hash to trigger regardless of case and spacing")
+	}
+	if ok, _ := checkEmojiHash("abcdef0124", cfg); ok {
+		t.Error("expected other hash to pass")
+	}
+	if ok, _ := checkEmojiHash("", cfg); ok {
+		t.Error("expected missing hash to pass")
+	}
+}
+
 func TestSpamTracker_RateLimit(t *testing.T) {
 	st := NewSpamTracker()
 	cfg := RuleConfig{MaxMessages: 3, WindowSeconds: 5, MaxDuplicates: 10}
@@ -265,3 +329,120 @@ func TestContainsWholeWord(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckContentWarning(t *testing.T) {
+	designated := RuleConfig{ChannelIDs: []string{"art"}}
+	tests := []struct {
+		name string
+		msg  MessageContext
+		cfg  RuleConfig
+		want bool
+	}{
+		{"missing in designated channel", MessageContext{ChannelID: "art", Content: "hi"}, designated, true},
+		{"present in designated channel", MessageContext{ChannelID: "art", ContentWarning: "gore"}, designated, false},
+		{"other channel", MessageContext{ChannelID: "general", Content: "hi"}, designated, false},
+		{"every channel", MessageContext{ChannelID: "general", Content: "hi"}, RuleConfig{}, true},
+		{"attachments only, text", MessageContext{ChannelID: "art", Content: "hi"},
+			RuleConfig{ChannelIDs: []string{"art"}, AttachmentsOnly: true}, false},
+		{"attachments only, files", MessageContext{ChannelID: "art", HasAttachments: true},
+			RuleConfig{ChannelIDs: []string{"art"}, AttachmentsOnly: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := checkContentWarning(tt.msg, tt.cfg); got != tt.want {
+				t.Errorf("checkContentWarning() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckAttachmentHashes(t *testing.T) {
+	cfg := RuleConfig{BlockedHashes: []string{" ABC123 "}}
+
+	if ok, _ := checkAttachmentHashes([]string{"", "abc123"}, cfg); !ok {
+		t.Error("expected blocklisted attachment to match case-insensitively")
+	}
+	if ok, _ := checkAttachmentHashes([]string{"def456", ""}, cfg); ok {
+		t.Error("expected unlisted attachments to pass")
+	}
+
+	// Files posted without text are still checked.
+	s := &Service{spam: NewSpamTracker()}
+	rule := &Rule{RuleType: RuleAttachmentHash, Config: cfg}
+	if ok, _ := s.checkRule(rule, MessageContext{AttachmentHashes: []string{"abc123"}}); !ok {
+		t.Error("expected attachment_hash rule to check messages without content")
+	}
+}
+
+func TestDehoist(t *testing.T) {
+	tests := []struct {
+		name    string
+		hoisted bool
+		want    string
+	}{
+		{"Sam", false, "Sam"},
+		{"7even", false, "7even"},
+		{"Élodie", false, "Élodie"},
+		{"!!! Sam", true, "Sam"},
+		{".sam", true, "sam"},
+		{" Sam", true, "Sam"},
+		{"!!!", true, DehoistFallback},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		if got := IsHoisted(tt.name); got != tt.hoisted {
+			t.Errorf("IsHoisted(%q) = %v, want %v", tt.name, got, tt.hoisted)
+		}
+		if got := Dehoist(tt.name); got != tt.want {
+			t.Errorf("Dehoist(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	cfg := RuleConfig{Words: []string{"slur"}, Patterns: []string{`(?i)^admin`}, Dehoist: true}
+
+	if ok, _, _ := checkName("Sam", cfg); ok {
+		t.Error("clean name triggered")
+	}
+	if ok, _, hoist := checkName("xslurx", cfg); !ok || hoist {
+		t.Errorf("word match = %v, hoistOnly %v; want true, false", ok, hoist)
+	}
+	if ok, _, hoist := checkName("ADMIN Sam", cfg); !ok || hoist {
+		t.Errorf("pattern match = %v, hoistOnly %v; want true, false", ok, hoist)
+	}
+	if ok, _, hoist := checkName("!Sam", cfg); !ok || !hoist {
+		t.Errorf("hoisted name = %v, hoistOnly %v; want true, true", ok, hoist)
+	}
+	if ok, _, _ := checkName("!Sam", RuleConfig{}); ok {
+		t.Error("hoisted name triggered without dehoist")
+	}
+}
+
+func TestRename(t *testing.T) {
+	cfg := RuleConfig{Words: []string{"slur"}, Dehoist: true}
+	str := func(p *string) string {
+		if p == nil {
+			return "<nil>"
+		}
+		return *p
+	}
+
+	tests := []struct {
+		name string
+		m    MemberName
+		cfg  RuleConfig
+		want string
+	}{
+		{"hoisted nickname dehoisted", MemberName{Nickname: "!!Sam", Name: "sam"}, cfg, "Sam"},
+		{"bad nickname cleared", MemberName{Nickname: "slurry", Name: "sam"}, cfg, "<nil>"},
+		{"bad nickname over bad name replaced", MemberName{Nickname: "slurry", Name: "slur"}, cfg, DefaultReplacementName},
+		{"bad name replaced", MemberName{Name: "slur"}, cfg, DefaultReplacementName},
+		{"custom replacement", MemberName{Name: "slur"}, RuleConfig{Words: []string{"slur"}, ReplacementName: "Member"}, "Member"},
+	}
+	for _, tt := range tests {
+		if got := str(rename(tt.m, tt.cfg)); got != tt.want {
+			t.Errorf("%s: rename = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package automod
 
 import (
+	"fmt"
 	"net/url"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 	"unicode"
+
+	"github.com/amityvox/amityvox/internal/locale"
 )
 
 // --- Word Filter ---
@@ -33,6 +36,34 @@ func checkWordFilter(content string, cfg RuleConfig) (bool, string) {
 	return false, ""
 }
 
+// wordListApplies reports whether a word list is checked in a channel of
+// the given language. A channel without a language is checked against every
+// list, so that declaring no language never lets words through.
+func wordListApplies(cfg RuleConfig, lang string) bool {
+	if len(cfg.Languages) == 0 || lang == "" {
+		return true
+	}
+	for _, l := range cfg.Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeLanguages reduces the word list languages to ISO 639 codes, the
+// form channels declare them in.
+func normalizeLanguages(cfg *RuleConfig) error {
+	for i, l := range cfg.Languages {
+		lang, err := locale.ParseLanguage(l)
+		if err != nil {
+			return fmt.Errorf("invalid language %q", l)
+		}
+		cfg.Languages[i] = lang
+	}
+	return nil
+}
+
 // containsWholeWord checks for a word bounded by non-alphanumeric characters.
 func containsWholeWord(text, word string) bool {
 	idx := 0
@@ -176,6 +207,36 @@ func checkCapsFilter(content string, cfg RuleConfig) (bool, string) {
 	return false, ""
 }
 
+// --- Strict Limits ---
+
+// strictRuleConfig returns cfg with its limits tightened for authors from
+// restricted federation peers: mention, caps and rate limits are halved and
+// the spam window doubled. Unset limits are tightened from their defaults.
+func strictRuleConfig(cfg RuleConfig) RuleConfig {
+	cfg.MaxMentions = halveLimit(cfg.MaxMentions, 5, 2)
+	cfg.MaxCapsPercent = halveLimit(cfg.MaxCapsPercent, 70, 1)
+	cfg.MinLength = halveLimit(cfg.MinLength, 10, 1)
+	cfg.MaxMessages = halveLimit(cfg.MaxMessages, 5, 1)
+	cfg.MaxDuplicates = halveLimit(cfg.MaxDuplicates, 3, 1)
+	if cfg.WindowSeconds <= 0 {
+		cfg.WindowSeconds = 5
+	}
+	cfg.WindowSeconds *= 2
+	return cfg
+}
+
+// halveLimit halves a limit, using def when it is unset and never going
+// below floor.
+func halveLimit(v, def, floor int) int {
+	if v <= 0 {
+		v = def
+	}
+	if v/2 < floor {
+		return floor
+	}
+	return v / 2
+}
+
 // --- Spam Filter ---
 
 // SpamTracker tracks recent messages per user for spam detection.
@@ -335,3 +396,56 @@ func domainMatch(host string, domains []string) bool {
 	}
 	return false
 }
+
+// --- Emoji Hash Filter ---
+
+// checkEmojiHash checks an emoji image's SHA-256 against the blocklist.
+// Hashes compare case-insensitively.
+func checkEmojiHash(imageHash string, cfg RuleConfig) (bool, string) {
+	if imageHash == "" {
+		return false, ""
+	}
+	for _, h := range cfg.BlockedHashes {
+		if strings.EqualFold(strings.TrimSpace(h), imageHash) {
+			return true, "blocklisted emoji image: " + strings.ToLower(imageHash)
+		}
+	}
+	return false, ""
+}
+
+// checkAttachmentHashes checks each attachment's SHA-256 against the
+// blocklist.
+func checkAttachmentHashes(hashes []string, cfg RuleConfig) (bool, string) {
+	for _, h := range hashes {
+		if h == "" {
+			continue
+		}
+		for _, blocked := range cfg.BlockedHashes {
+			if strings.EqualFold(strings.TrimSpace(blocked), h) {
+				return true, "blocklisted attachment: " + strings.ToLower(h)
+			}
+		}
+	}
+	return false, ""
+}
+
+// --- Content Warning ---
+
+// checkContentWarning requires a content warning on messages posted in the
+// rule's channels, or only on those with attachments when AttachmentsOnly
+// is set.
+func checkContentWarning(msg MessageContext, cfg RuleConfig) (bool, string) {
+	if msg.ContentWarning != "" {
+		return false, ""
+	}
+	if len(cfg.ChannelIDs) > 0 && !isExempt(msg.ChannelID, cfg.ChannelIDs) {
+		return false, ""
+	}
+	if cfg.AttachmentsOnly {
+		if !msg.HasAttachments {
+			return false, ""
+		}
+		return true, "attachments posted without a content warning"
+	}
+	return true, "message posted without a content warning"
+}
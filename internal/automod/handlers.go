@@ -2,6 +2,7 @@ package automod
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/jackc/pgx/v5"
 
 	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/locale"
 	"github.com/amityvox/amityvox/internal/models"
 )
 
@@ -89,7 +91,8 @@ func (s *Service) HandleCreateRule(w http.ResponseWriter, r *http.Request) {
 	validTypes := map[string]bool{
 		RuleWordFilter: true, RuleRegexFilter: true, RuleInviteFilter: true,
 		RuleMentionSpam: true, RuleCapsFilter: true, RuleSpamFilter: true,
-		RuleLinkFilter: true,
+		RuleLinkFilter: true, RuleEmojiHash: true, RuleContentWarning: true,
+		RuleNameFilter: true,
 	}
 	if !validTypes[req.RuleType] {
 		writeError(w, http.StatusBadRequest, "invalid_type", "Invalid rule_type")
@@ -102,11 +105,28 @@ func (s *Service) HandleCreateRule(w http.ResponseWriter, r *http.Request) {
 	}
 	validActions := map[string]bool{
 		ActionDelete: true, ActionWarn: true, ActionTimeout: true, ActionLog: true,
+		ActionQuarantine: true,
 	}
 	if !validActions[action] {
 		writeError(w, http.StatusBadRequest, "invalid_action", "Invalid action")
 		return
 	}
+	if req.RuleType == RuleEmojiHash && action != ActionDelete && action != ActionLog {
+		writeError(w, http.StatusBadRequest, "invalid_action", "emoji_hash rules support only the delete and log actions")
+		return
+	}
+	if req.RuleType == RuleNameFilter && !validNameAction(action) {
+		writeError(w, http.StatusBadRequest, "invalid_action", "name_filter rules support only the delete, warn and log actions")
+		return
+	}
+	if err := validateReplacementName(req.Config); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_replacement_name", err.Error())
+		return
+	}
+	if err := normalizeLanguages(&req.Config); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_language", err.Error())
+		return
+	}
 
 	enabled := true
 	if req.Enabled != nil {
@@ -172,16 +192,33 @@ func (s *Service) HandleUpdateRule(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify rule exists and belongs to guild.
-	var exists bool
-	s.pool.QueryRow(r.Context(),
-		`SELECT EXISTS(SELECT 1 FROM automod_rules WHERE id = $1 AND guild_id = $2)`,
+	var ruleType string
+	err := s.pool.QueryRow(r.Context(),
+		`SELECT rule_type FROM automod_rules WHERE id = $1 AND guild_id = $2`,
 		ruleID, guildID,
-	).Scan(&exists)
-
-	if !exists {
+	).Scan(&ruleType)
+	if err != nil {
 		writeError(w, http.StatusNotFound, "not_found", "Rule not found")
 		return
 	}
+	if ruleType == RuleEmojiHash && req.Action != nil && *req.Action != ActionDelete && *req.Action != ActionLog {
+		writeError(w, http.StatusBadRequest, "invalid_action", "emoji_hash rules support only the delete and log actions")
+		return
+	}
+	if ruleType == RuleNameFilter && req.Action != nil && !validNameAction(*req.Action) {
+		writeError(w, http.StatusBadRequest, "invalid_action", "name_filter rules support only the delete, warn and log actions")
+		return
+	}
+	if req.Config != nil {
+		if err := validateReplacementName(*req.Config); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_replacement_name", err.Error())
+			return
+		}
+		if err := normalizeLanguages(req.Config); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_language", err.Error())
+			return
+		}
+	}
 
 	// Build dynamic update.
 	if req.Name != nil {
@@ -226,7 +263,7 @@ func (s *Service) HandleUpdateRule(w http.ResponseWriter, r *http.Request) {
 	var configJSON []byte
 	var exemptChannels, exemptRoles []string
 	var createdBy *string
-	err := s.pool.QueryRow(r.Context(),
+	err = s.pool.QueryRow(r.Context(),
 		`SELECT id, guild_id, name, enabled, rule_type, config, action,
 		        timeout_duration_seconds, exempt_channel_ids, exempt_role_ids,
 		        created_by, created_at, updated_at
@@ -279,7 +316,8 @@ func (s *Service) HandleGetActions(w http.ResponseWriter, r *http.Request) {
 	guildID := chi.URLParam(r, "guildID")
 
 	rows, err := s.pool.Query(r.Context(),
-		`SELECT id, guild_id, rule_id, channel_id, message_id, user_id, action, reason, created_at
+		`SELECT id, guild_id, COALESCE(rule_id, ''), COALESCE(instance_rule_id, ''),
+		        channel_id, message_id, user_id, action, reason, created_at
 		 FROM automod_actions
 		 WHERE guild_id = $1
 		 ORDER BY created_at DESC
@@ -296,7 +334,7 @@ func (s *Service) HandleGetActions(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var a ActionRecord
 		var messageID, reason *string
-		if err := rows.Scan(&a.ID, &a.GuildID, &a.RuleID, &a.ChannelID, &messageID, &a.UserID, &a.Action, &reason, &a.CreatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.GuildID, &a.RuleID, &a.InstanceRuleID, &a.ChannelID, &messageID, &a.UserID, &a.Action, &reason, &a.CreatedAt); err != nil {
 			continue
 		}
 		if messageID != nil {
@@ -361,6 +399,8 @@ func (s *Service) HandleTestRule(w http.ResponseWriter, r *http.Request) {
 		RuleType   string     `json:"rule_type"`
 		Config     RuleConfig `json:"config"`
 		SampleText string     `json:"sample_text"`
+		// Language is the channel language to test as, if any.
+		Language string `json:"language"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
@@ -381,6 +421,7 @@ func (s *Service) HandleTestRule(w http.ResponseWriter, r *http.Request) {
 	validTypes := map[string]bool{
 		RuleWordFilter: true, RuleRegexFilter: true, RuleInviteFilter: true,
 		RuleMentionSpam: true, RuleCapsFilter: true, RuleLinkFilter: true,
+		RuleNameFilter: true,
 	}
 	if !validTypes[req.RuleType] {
 		// spam_filter requires stateful tracking and cannot be meaningfully tested
@@ -390,6 +431,11 @@ func (s *Service) HandleTestRule(w http.ResponseWriter, r *http.Request) {
 				"Spam filter cannot be tested with a single message (it requires message history)")
 			return
 		}
+		if req.RuleType == RuleContentWarning {
+			writeError(w, http.StatusBadRequest, "unsupported_type",
+				"Content warning rules check the message's warning, not its text")
+			return
+		}
 		writeError(w, http.StatusBadRequest, "invalid_type", "Invalid rule_type for testing")
 		return
 	}
@@ -400,9 +446,36 @@ func (s *Service) HandleTestRule(w http.ResponseWriter, r *http.Request) {
 		Config:   req.Config,
 	}
 
-	matched, reason := s.checkRule(rule, MessageContext{
-		Content: req.SampleText,
-	})
+	if err := normalizeLanguages(&rule.Config); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_language", err.Error())
+		return
+	}
+	if req.Language != "" {
+		lang, err := locale.ParseLanguage(req.Language)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_language", err.Error())
+			return
+		}
+		req.Language = lang
+	}
+
+	var matched bool
+	var reason string
+	if rule.RuleType == RuleNameFilter {
+		// The sample is a name; a match shows what the member would be
+		// renamed to.
+		matched, reason, _ = checkName(req.SampleText, rule.Config)
+		if matched {
+			if to := rename(MemberName{Name: req.SampleText}, rule.Config); to != nil {
+				reason += fmt.Sprintf(" (renamed to %q)", *to)
+			}
+		}
+	} else {
+		matched, reason = s.checkRule(rule, MessageContext{
+			Content:  req.SampleText,
+			Language: req.Language,
+		})
+	}
 
 	type testResult struct {
 		Matched        bool    `json:"matched"`
@@ -417,6 +490,20 @@ func (s *Service) HandleTestRule(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, result)
 }
 
+// validNameAction reports whether action suits a name_filter rule. Names
+// have no message to delete or author to time out for.
+func validNameAction(action string) bool {
+	return action == ActionDelete || action == ActionWarn || action == ActionLog
+}
+
+// validateReplacementName checks the name a name_filter rule renames to.
+func validateReplacementName(cfg RuleConfig) error {
+	if n := len([]rune(cfg.ReplacementName)); n > 32 {
+		return fmt.Errorf("replacement_name must be at most 32 characters")
+	}
+	return nil
+}
+
 // --- Helpers ---
 
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -0,0 +1,205 @@
+package automod
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- Name Filter ---
+//
+// name_filter rules check the name a member is shown under in the guild —
+// their nickname, or their display name without one — when they join and
+// whenever it changes. They take the word_filter words and regex_filter
+// patterns, and with dehoist set also match names that start with symbols
+// to sort above everyone else. The delete action renames the member; warn
+// and log only report.
+
+// Names members are renamed to when nothing of their own name can be kept.
+const (
+	DefaultReplacementName = "Moderated Nickname"
+	DehoistFallback        = "Dehoisted"
+)
+
+// MemberName is a guild member's names, for name_filter rules.
+type MemberName struct {
+	GuildID  string
+	UserID   string
+	Nickname string // the guild nickname, empty without one
+	Name     string // the display name, or the username without one
+	// MemberRoleIDs are the member's role IDs in the guild.
+	MemberRoleIDs []string
+	// Strict is set for members from restricted federation peers: role
+	// exemptions do not apply.
+	Strict bool
+}
+
+// Shown returns the name the member is shown under in the guild.
+func (m MemberName) Shown() string {
+	if m.Nickname != "" {
+		return m.Nickname
+	}
+	return m.Name
+}
+
+// hoistRune reports whether r is one a hoisted name starts with: anything
+// but a letter or digit, since those sort first in member lists.
+func hoistRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// IsHoisted reports whether a name starts with symbols that sort it above
+// names starting with letters and digits, e.g. "!!! Sam" or ".sam".
+func IsHoisted(name string) bool {
+	return name != "" && strings.IndexFunc(name, hoistRune) == 0
+}
+
+// Dehoist strips the symbols a hoisted name starts with. A name made only
+// of symbols becomes DehoistFallback.
+func Dehoist(name string) string {
+	if !IsHoisted(name) {
+		return name
+	}
+	if d := strings.TrimLeftFunc(name, hoistRune); d != "" {
+		return d
+	}
+	return DehoistFallback
+}
+
+// checkName tests a name against a name_filter rule. hoistOnly is set when
+// the only problem is that the name is hoisted.
+func checkName(name string, cfg RuleConfig) (triggered bool, reason string, hoistOnly bool) {
+	if name == "" {
+		return false, "", false
+	}
+	if ok, reason := checkWordFilter(name, cfg); ok {
+		return true, reason, false
+	}
+	if ok, reason := checkRegexFilter(name, cfg); ok {
+		return true, reason, false
+	}
+	if cfg.Dehoist && IsHoisted(name) {
+		return true, "hoisted name", true
+	}
+	return false, "", false
+}
+
+// HasNameRules reports whether any name_filter rule applies in the guild,
+// so callers can skip loading the member when none does.
+func (s *Service) HasNameRules(ctx context.Context, guildID string) bool {
+	var exists bool
+	s.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM automod_rules WHERE guild_id = $1 AND enabled AND rule_type = $2)
+		     OR EXISTS(SELECT 1 FROM instance_automod_rules WHERE enabled AND rule_type = $2)`,
+		guildID, RuleNameFilter).Scan(&exists)
+	return exists
+}
+
+// EvaluateName checks a member's shown name against the name_filter rules
+// of the instance policy and the guild. Returns the first rule that
+// triggers and the reason, or nil if none do.
+func (s *Service) EvaluateName(ctx context.Context, m MemberName) (*Rule, string, error) {
+	rules, err := s.loadRules(ctx, m.GuildID)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.RuleType != RuleNameFilter {
+			continue
+		}
+		if !m.Strict && hasExemptRole(m.MemberRoleIDs, rule.ExemptRoleIDs) {
+			continue
+		}
+		if triggered, reason, _ := checkName(m.Shown(), rule.Config); triggered {
+			return rule, reason, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// rename picks the nickname a triggered rule gives the member: the name
+// without its hoisting symbols if that was the only problem, no nickname if
+// the display name underneath is fine, and otherwise the rule's replacement
+// name. nil clears the nickname.
+func rename(m MemberName, cfg RuleConfig) *string {
+	replacement := cfg.ReplacementName
+	if replacement == "" {
+		replacement = DefaultReplacementName
+	}
+	_, _, hoistOnly := checkName(m.Shown(), cfg)
+	switch {
+	case hoistOnly:
+		d := Dehoist(m.Shown())
+		return &d
+	case m.Nickname != "":
+		if triggered, _, _ := checkName(m.Name, cfg); !triggered {
+			return nil
+		}
+		fallthrough
+	default:
+		return &replacement
+	}
+}
+
+// ExecuteNameAction performs a triggered name_filter rule's action. A
+// rename that would leave the nickname as it is does nothing, so a
+// replacement name that itself matches cannot loop.
+func (s *Service) ExecuteNameAction(ctx context.Context, rule *Rule, m MemberName, reason string) error {
+	var nickname *string
+	if rule.Action == ActionDelete {
+		nickname = rename(m, rule.Config)
+		if (nickname == nil && m.Nickname == "") || (nickname != nil && *nickname == m.Nickname) {
+			return nil
+		}
+	}
+
+	var ruleID, instanceRuleID *string
+	if rule.Instance {
+		instanceRuleID = &rule.ID
+	} else {
+		ruleID = &rule.ID
+	}
+	if _, err := s.pool.Exec(ctx,
+		`INSERT INTO automod_actions (id, guild_id, rule_id, instance_rule_id, channel_id, user_id, action, reason, created_at)
+		 VALUES ($1, $2, $3, $4, '', $5, $6, $7, now())`,
+		models.NewULID().String(), m.GuildID, ruleID, instanceRuleID, m.UserID, rule.Action,
+		fmt.Sprintf("%s (name %q)", reason, m.Shown())); err != nil {
+		s.logger.Error("failed to log automod action", slog.String("error", err.Error()))
+	}
+
+	if rule.Action == ActionDelete {
+		var member models.GuildMember
+		err := s.pool.QueryRow(ctx,
+			`UPDATE guild_members SET nickname = $3 WHERE guild_id = $1 AND user_id = $2
+			 RETURNING guild_id, user_id, nickname, avatar_id, joined_at, timeout_until, deaf, mute`,
+			m.GuildID, m.UserID, nickname,
+		).Scan(&member.GuildID, &member.UserID, &member.Nickname, &member.AvatarID, &member.JoinedAt,
+			&member.TimeoutUntil, &member.Deaf, &member.Mute)
+		if err != nil {
+			return fmt.Errorf("renaming member %s: %w", m.UserID, err)
+		}
+		s.bus.PublishGuildEvent(ctx, events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", m.GuildID, member)
+		s.logger.Info("automod renamed member",
+			slog.String("user_id", m.UserID),
+			slog.String("guild_id", m.GuildID),
+			slog.String("rule_id", rule.ID),
+		)
+	}
+
+	return s.bus.PublishGuildEvent(ctx, events.SubjectAutomodAction, "AUTOMOD_ACTION", m.GuildID, map[string]interface{}{
+		"guild_id":  m.GuildID,
+		"user_id":   m.UserID,
+		"name":      m.Shown(),
+		"rule_id":   rule.ID,
+		"rule_name": rule.Name,
+		"instance":  rule.Instance,
+		"action":    rule.Action,
+		"reason":    reason,
+	})
+}
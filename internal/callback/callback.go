@@ -0,0 +1,114 @@
+// Package callback signs the requests this instance makes to integrators:
+// outgoing webhook deliveries and interactions sent to app endpoints.
+//
+// Each request carries the Unix time it was sent and an Ed25519 signature,
+// made with the instance's callback key, over "<timestamp>.<body>". Receivers
+// verify it against the key published at GET /api/v1/instance and reject
+// stale timestamps, so a spoofed or replayed request is refused without any
+// shared secret to leak. The Go SDK's VerifyRequest implements the receiving
+// side. The callback key signs nothing else; in particular it is not the
+// federation key, so these signatures never pass as federation traffic.
+package callback
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header names of the signing scheme. The timestamp header is shared with
+// the HMAC signatures of outgoing webhooks.
+const (
+	SignatureHeader = "X-AmityVox-Signature-Ed25519"
+	TimestampHeader = "X-AmityVox-Timestamp"
+)
+
+// MaxSkew is how far a timestamp may be from the receiver's clock before the
+// request is treated as a replay.
+const MaxSkew = 5 * time.Minute
+
+// Sign returns the hex signature of body sent at timestamp.
+func Sign(key ed25519.PrivateKey, timestamp string, body []byte) string {
+	return hex.EncodeToString(ed25519.Sign(key, signedMessage(timestamp, body)))
+}
+
+// Verify reports whether signature is key's signature of body sent at
+// timestamp. It does not check the timestamp's age.
+func Verify(key ed25519.PublicKey, signature, timestamp string, body []byte) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(key, signedMessage(timestamp, body), sig)
+}
+
+func signedMessage(timestamp string, body []byte) []byte {
+	msg := make([]byte, 0, len(timestamp)+1+len(body))
+	msg = append(msg, timestamp...)
+	msg = append(msg, '.')
+	return append(msg, body...)
+}
+
+// SignRequest sets the timestamp and signature headers for body on req.
+func SignRequest(req *http.Request, key ed25519.PrivateKey, body []byte, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, Sign(key, ts, body))
+}
+
+// PublicKey returns the hex public key receivers verify signatures with, or
+// "" when no key is configured.
+func PublicKey(key ed25519.PrivateKey) string {
+	if len(key) != ed25519.PrivateKeySize {
+		return ""
+	}
+	return hex.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// Response is what an endpoint answered to a callback, with the body cut to
+// a short preview.
+type Response struct {
+	StatusCode int
+	Preview    string
+}
+
+// OK reports whether the endpoint answered with a 2xx status.
+func (r Response) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// ErrNoKey is returned by Post when the instance has no signing key.
+var ErrNoKey = errors.New("no callback signing key configured")
+
+// Post sends a signed JSON body to url with the SSRF-safe client. Extra
+// headers are set before signing.
+func Post(ctx context.Context, key ed25519.PrivateKey, url string, body []byte, header http.Header) (Response, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return Response{}, ErrNoKey
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to create request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	SignRequest(req, key, body, time.Now())
+
+	resp, err := SafeClient().Do(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	preview, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	return Response{StatusCode: resp.StatusCode, Preview: string(preview)}, nil
+}
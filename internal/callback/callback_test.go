@@ -0,0 +1,84 @@
+package callback
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amityvox/amityvox/sdk/go/amityvox"
+)
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	body := []byte(`{"type":"PING"}`)
+	sig := Sign(priv, "1700000000", body)
+
+	if !Verify(pub, sig, "1700000000", body) {
+		t.Fatal("Verify rejected a valid signature")
+	}
+	if Verify(pub, sig, "1700000001", body) {
+		t.Error("signature should cover the timestamp")
+	}
+	if Verify(pub, sig, "1700000000", []byte(`{"type":"PONG"}`)) {
+		t.Error("signature should cover the body")
+	}
+	if Verify(pub, "zz", "1700000000", body) {
+		t.Error("Verify accepted a malformed signature")
+	}
+
+	if got := PublicKey(priv); got != hex.EncodeToString(pub) {
+		t.Errorf("PublicKey() = %q, want %x", got, pub)
+	}
+	if PublicKey(nil) != "" {
+		t.Error("PublicKey(nil) should be empty")
+	}
+}
+
+// TestSDKVerifyRequest checks that requests signed here verify with the
+// SDK's receiving-side helper.
+func TestSDKVerifyRequest(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(nil)
+	key, err := amityvox.ParsePublicKey(PublicKey(priv))
+	if err != nil {
+		t.Fatalf("ParsePublicKey: %v", err)
+	}
+	body := `{"type":"INTERACTION_CREATE","data":{"id":"01"}}`
+
+	signed := func(sentAt time.Time) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/interactions", strings.NewReader(body))
+		SignRequest(req, priv, []byte(body), sentAt)
+		return req
+	}
+
+	got, err := amityvox.VerifyRequest(key, signed(time.Now()))
+	if err != nil || string(got) != body {
+		t.Fatalf("VerifyRequest = %q, %v", got, err)
+	}
+
+	tampered := signed(time.Now())
+	tampered.Body = http.NoBody
+	if _, err := amityvox.VerifyRequest(key, tampered); !errors.Is(err, amityvox.ErrInvalidSignature) {
+		t.Errorf("tampered body: err = %v, want ErrInvalidSignature", err)
+	}
+
+	if _, err := amityvox.VerifyRequest(key, signed(time.Now().Add(-10*time.Minute))); !errors.Is(err, amityvox.ErrStaleRequest) {
+		t.Errorf("old timestamp: err = %v, want ErrStaleRequest", err)
+	}
+
+	_, other, _ := ed25519.GenerateKey(nil)
+	forged := httptest.NewRequest(http.MethodPost, "/interactions", strings.NewReader(body))
+	SignRequest(forged, other, []byte(body), time.Now())
+	if _, err := amityvox.VerifyRequest(key, forged); !errors.Is(err, amityvox.ErrInvalidSignature) {
+		t.Errorf("other key: err = %v, want ErrInvalidSignature", err)
+	}
+
+	unsigned := httptest.NewRequest(http.MethodPost, "/interactions", strings.NewReader(body))
+	if _, err := amityvox.VerifyRequest(key, unsigned); !errors.Is(err, amityvox.ErrMissingSignature) {
+		t.Errorf("unsigned: err = %v, want ErrMissingSignature", err)
+	}
+}
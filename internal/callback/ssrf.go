@@ -1,5 +1,4 @@
-// Package webhooks — SSRF protection for outgoing webhook delivery.
-package webhooks
+package callback
 
 import (
 	"context"
@@ -10,8 +9,8 @@ import (
 )
 
 // isPrivateIP returns true if the IP is in a private, loopback, link-local,
-// or otherwise non-public range. Used to prevent SSRF attacks in outgoing
-// webhook delivery.
+// or otherwise non-public range. Used to keep callbacks from being aimed at
+// the instance's own network (SSRF).
 func isPrivateIP(ip net.IP) bool {
 	return ip.IsLoopback() ||
 		ip.IsPrivate() ||
@@ -46,7 +45,7 @@ func safeTransport() *http.Transport {
 			// Validate ALL resolved IPs — reject if any are private.
 			for _, ipAddr := range ips {
 				if isPrivateIP(ipAddr.IP) {
-					return nil, fmt.Errorf("webhook URL resolves to private address %s", ipAddr.IP)
+					return nil, fmt.Errorf("callback URL resolves to private address %s", ipAddr.IP)
 				}
 			}
 
@@ -54,14 +53,15 @@ func safeTransport() *http.Transport {
 			return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
 		},
 		TLSHandshakeTimeout:   5 * time.Second,
-		ResponseHeaderTimeout:  10 * time.Second,
-		MaxIdleConns:           10,
-		IdleConnTimeout:        30 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
 	}
 }
 
-// safeHTTPClient returns an http.Client with SSRF-safe transport.
-func safeHTTPClient() *http.Client {
+// SafeClient returns an http.Client with SSRF-safe transport, for requests to
+// URLs supplied by users and integrators.
+func SafeClient() *http.Client {
 	return &http.Client{
 		Timeout:   10 * time.Second,
 		Transport: safeTransport(),
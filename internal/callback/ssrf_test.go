@@ -1,4 +1,4 @@
-package webhooks
+package callback
 
 import (
 	"net"
@@ -0,0 +1,115 @@
+// Package cdn builds the URLs clients load media from: attachments, avatars,
+// banners, guild icons and custom emoji. Payloads carry these URLs fully
+// resolved so clients do not each assemble them from IDs and storage keys.
+//
+// Media URLs are content-addressed: a file's ID never refers to other bytes,
+// and emoji URLs carry the image hash, so a URL changes whenever the media
+// does and caches may keep it forever.
+//
+// The builder is configured once at startup with Configure. Until then, or
+// when the instance turns resolved URLs off, Current returns nil and every
+// method returns empty URLs, which the models omit.
+package cdn
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultThumbnailSize is the width thumbnail URLs in payloads ask for.
+// Clients may change the size parameter to pick another variant.
+const DefaultThumbnailSize = 256
+
+// Builder builds media URLs under a base URL.
+type Builder struct {
+	base            string
+	localInstanceID string
+}
+
+// New creates a Builder for media served under baseURL, such as
+// "https://chat.example.com" or a CDN in front of it. Media of instances
+// other than localInstanceID is addressed through the federation proxy.
+func New(baseURL, localInstanceID string) *Builder {
+	return &Builder{base: strings.TrimRight(baseURL, "/"), localInstanceID: localInstanceID}
+}
+
+var current atomic.Pointer[Builder]
+
+// Configure sets the builder used when payloads are written. Passing nil
+// turns resolved URLs off.
+func Configure(b *Builder) {
+	current.Store(b)
+}
+
+// Current returns the configured builder, or nil.
+func Current() *Builder {
+	return current.Load()
+}
+
+// File returns the URL of a file. instanceID is the instance that stores
+// it; empty or the local instance means it is stored here.
+func (b *Builder) File(fileID, instanceID string) string {
+	if b == nil || fileID == "" {
+		return ""
+	}
+	if b.remote(instanceID) {
+		return b.base + "/api/v1/federation/media/" + url.PathEscape(instanceID) + "/" + url.PathEscape(fileID)
+	}
+	return b.base + "/api/v1/files/" + url.PathEscape(fileID)
+}
+
+// Thumbnail returns the URL of an image file's thumbnail at least size
+// pixels wide. Remote files have no thumbnails, so their full URL is
+// returned instead.
+func (b *Builder) Thumbnail(fileID, instanceID string, size int) string {
+	if b == nil || fileID == "" {
+		return ""
+	}
+	if b.remote(instanceID) {
+		return b.File(fileID, instanceID)
+	}
+	return b.base + "/api/v1/files/" + url.PathEscape(fileID) + "/thumbnail?size=" + strconv.Itoa(size)
+}
+
+// Emoji returns the URL of a custom emoji's image. The image hash, when
+// known, is added so the URL changes if the image ever does.
+func (b *Builder) Emoji(emojiID string, imageHash *string) string {
+	if b == nil || emojiID == "" {
+		return ""
+	}
+	u := b.base + "/api/v1/emoji/" + url.PathEscape(emojiID)
+	if imageHash != nil && *imageHash != "" {
+		u += "?v=" + url.QueryEscape(shortHash(*imageHash))
+	}
+	return u
+}
+
+// OptionalFile is File for an optional file ID, as stored for avatars,
+// banners and icons. It returns nil when there is no file or no URL.
+func (b *Builder) OptionalFile(fileID *string, instanceID string) *string {
+	if fileID == nil {
+		return nil
+	}
+	return optional(b.File(*fileID, instanceID))
+}
+
+func (b *Builder) remote(instanceID string) bool {
+	return instanceID != "" && instanceID != b.localInstanceID
+}
+
+// shortHash keeps enough of a hex digest to tell images apart.
+func shortHash(h string) string {
+	if len(h) > 16 {
+		return h[:16]
+	}
+	return h
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
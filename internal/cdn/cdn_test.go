@@ -0,0 +1,40 @@
+package cdn
+
+import "testing"
+
+func TestBuilder(t *testing.T) {
+	b := New("https://cdn.example.com/", "local")
+	hash := "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"local file", b.File("f1", "local"), "https://cdn.example.com/api/v1/files/f1"},
+		{"file without instance", b.File("f1", ""), "https://cdn.example.com/api/v1/files/f1"},
+		{"remote file", b.File("f1", "peer"), "https://cdn.example.com/api/v1/federation/media/peer/f1"},
+		{"thumbnail", b.Thumbnail("f1", "", 512), "https://cdn.example.com/api/v1/files/f1/thumbnail?size=512"},
+		{"remote thumbnail", b.Thumbnail("f1", "peer", 512), "https://cdn.example.com/api/v1/federation/media/peer/f1"},
+		{"emoji", b.Emoji("e1", &hash), "https://cdn.example.com/api/v1/emoji/e1?v=0123456789abcdef"},
+		{"emoji without hash", b.Emoji("e1", nil), "https://cdn.example.com/api/v1/emoji/e1"},
+		{"no file", b.File("", ""), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilBuilder(t *testing.T) {
+	var b *Builder
+	id := "f1"
+	if b.File("f1", "") != "" || b.Thumbnail("f1", "", 256) != "" || b.Emoji("e1", nil) != "" {
+		t.Error("a nil builder returns empty URLs")
+	}
+	if b.OptionalFile(&id, "") != nil {
+		t.Error("a nil builder returns no optional URLs")
+	}
+}
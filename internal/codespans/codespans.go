@@ -0,0 +1,25 @@
+// Package codespans finds the code in message content. Text inside code
+// blocks (``` ```) and inline code (` `) is shown as written, so markup such
+// as spoilers and :shortcode: emoji is not interpreted there.
+package codespans
+
+import (
+	"regexp"
+	"strings"
+)
+
+var codeRe = regexp.MustCompile("(?s)```.*?```|`[^`]+`")
+
+// EachOutside applies fn to every run of content outside code spans and
+// blocks, leaving code as written.
+func EachOutside(content string, fn func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range codeRe.FindAllStringIndex(content, -1) {
+		b.WriteString(fn(content[last:loc[0]]))
+		b.WriteString(content[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(fn(content[last:]))
+	return b.String()
+}
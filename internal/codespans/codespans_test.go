@@ -0,0 +1,27 @@
+package codespans
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEachOutside(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no code", "abc", "ABC"},
+		{"inline code", "a `b` c", "A `b` C"},
+		{"code block", "a\n```\nb\n```\nc", "A\n```\nb\n```\nC"},
+		{"unterminated", "a `b", "A `B"},
+		{"empty backticks", "a `` b", "A `` B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EachOutside(tt.content, strings.ToUpper); got != tt.want {
+				t.Errorf("EachOutside(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// clientVersion is a parsed "major.minor.patch[-prerelease][+build]" version.
+// Missing minor and patch numbers count as zero.
+type clientVersion struct {
+	parts      [3]int
+	prerelease string
+}
+
+func parseClientVersion(s string) (clientVersion, error) {
+	var v clientVersion
+	core := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	core, _, _ = strings.Cut(core, "+")
+	core, v.prerelease, _ = strings.Cut(core, "-")
+
+	fields := strings.Split(core, ".")
+	if len(fields) > 3 {
+		return v, fmt.Errorf("version %q has more than three numbers", s)
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		v.parts[i] = n
+	}
+	return v, nil
+}
+
+// compare orders versions as semver does, except that prereleases of the
+// same version compare as plain strings.
+func (v clientVersion) compare(o clientVersion) int {
+	for i := range v.parts {
+		if c := cmp.Compare(v.parts[i], o.parts[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case v.prerelease == o.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case o.prerelease == "":
+		return -1
+	}
+	return cmp.Compare(v.prerelease, o.prerelease)
+}
+
+// Outdated reports whether a client reporting version is older than
+// MinVersion. Clients that send no version, or one that does not parse, are
+// let through: bots and third-party clients often send none.
+func (c ClientsConfig) Outdated(version string) bool {
+	if c.MinVersion == "" || version == "" {
+		return false
+	}
+	min, err := parseClientVersion(c.MinVersion)
+	if err != nil {
+		return false
+	}
+	v, err := parseClientVersion(version)
+	if err != nil {
+		return false
+	}
+	return v.compare(min) < 0
+}
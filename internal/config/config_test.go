@@ -1,6 +1,7 @@
 package config
 
 import (
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
@@ -80,6 +81,48 @@ cors_origins = ["https://test.example.com"]
 	}
 }
 
+func TestLoad_Regions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "amityvox.toml")
+	content := `
+[regions]
+primary = "us-east"
+local = "eu-west"
+
+[storage]
+bucket = "amityvox"
+
+[[storage.regions]]
+name = "eu-west"
+endpoint = "https://s3.eu.example.com"
+bucket = "amityvox-eu"
+
+[[livekit.regions]]
+name = "eu-west"
+url = "ws://livekit-eu:7880"
+public_url = "wss://eu.example.com/rtc"
+api_key = "key"
+api_secret = "secret"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing test config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(cfg.Storage.Regions) != 1 || cfg.Storage.Regions[0].Bucket != "amityvox-eu" {
+		t.Errorf("storage.regions = %+v", cfg.Storage.Regions)
+	}
+	if len(cfg.LiveKit.Regions) != 1 || cfg.LiveKit.Regions[0].PublicURL != "wss://eu.example.com/rtc" {
+		t.Errorf("livekit.regions = %+v", cfg.LiveKit.Regions)
+	}
+	if cfg.Regions.LocalRegion() != "eu-west" || cfg.Regions.Strategy != "nearest" {
+		t.Errorf("regions = %+v, want local eu-west with the default strategy", cfg.Regions)
+	}
+}
+
 func TestLoad_InvalidTOML(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "amityvox.toml")
@@ -123,6 +166,95 @@ url = ""`,
 			`[database]
 max_connections = 0`,
 		},
+		{
+			"invalid slow query threshold",
+			`[database]
+slow_query_threshold = "fast"`,
+		},
+		{
+			"cors origin with path",
+			`[http]
+cors_origins = ["https://example.com/app"]`,
+		},
+		{
+			"cors wildcard mid-host",
+			`[http]
+cors_origins = ["https://app.*.example.com"]`,
+		},
+		{
+			"invalid embed image ttl",
+			`[media]
+embed_image_ttl = "forever"`,
+		},
+		{
+			"zero embed image max size",
+			`[media]
+embed_image_max_size = "0MB"`,
+		},
+		{
+			"invalid trusted proxy",
+			`[http]
+trusted_proxies = ["10.0.0.0/33"]`,
+		},
+		{
+			"negative slow query threshold",
+			`[database]
+slow_query_threshold = "-1s"`,
+		},
+		{
+			"matrix appservice without tokens",
+			`[federation.matrix]
+enabled = true
+homeserver_url = "http://synapse:8008"
+server_name = "example.com"`,
+		},
+		{
+			"negative dispatch shards",
+			`[websocket]
+dispatch_shards = -1`,
+		},
+		{
+			"invalid minimum client version",
+			`[clients]
+min_version = "latest"`,
+		},
+		{
+			"invalid region strategy",
+			`[regions]
+strategy = "random"`,
+		},
+		{
+			"storage region without primary",
+			`[[storage.regions]]
+name = "eu-west"
+endpoint = "s3.eu.example.com"
+bucket = "amityvox-eu"`,
+		},
+		{
+			"storage region reusing bucket",
+			`[regions]
+primary = "us-east"
+
+[storage]
+bucket = "amityvox"
+
+[[storage.regions]]
+name = "eu-west"
+endpoint = "s3.eu.example.com"
+bucket = "amityvox"`,
+		},
+		{
+			"unknown local region",
+			`[regions]
+primary = "us-east"
+local = "eu-west"`,
+		},
+		{
+			"email gateway without JMAP credentials",
+			`[email_gateway]
+enabled = true
+domain = "mail.example.com"`,
+		},
 	}
 
 	for _, tc := range tests {
@@ -144,8 +276,11 @@ func TestEnvOverrides(t *testing.T) {
 	// Set env vars before loading.
 	t.Setenv("AMITYVOX_INSTANCE_DOMAIN", "env.example.com")
 	t.Setenv("AMITYVOX_DATABASE_MAX_CONNECTIONS", "50")
+	t.Setenv("AMITYVOX_DATABASE_SLOW_QUERY_THRESHOLD", "0")
 	t.Setenv("AMITYVOX_AUTH_REGISTRATION_ENABLED", "false")
 	t.Setenv("AMITYVOX_SEARCH_ENABLED", "false")
+	t.Setenv("AMITYVOX_PUSH_VAPID_PREVIOUS_PUBLIC_KEY", "old-public")
+	t.Setenv("AMITYVOX_PUSH_VAPID_PREVIOUS_PRIVATE_KEY", "old-private")
 
 	cfg, err := Load("/nonexistent/config.toml")
 	if err != nil {
@@ -158,12 +293,19 @@ func TestEnvOverrides(t *testing.T) {
 	if cfg.Database.MaxConnections != 50 {
 		t.Errorf("max_connections = %d, want 50", cfg.Database.MaxConnections)
 	}
+	if cfg.Database.SlowQueryThreshold != "0" {
+		t.Errorf("slow_query_threshold = %q, want %q", cfg.Database.SlowQueryThreshold, "0")
+	}
 	if cfg.Auth.RegistrationEnabled {
 		t.Error("registration should be disabled via env")
 	}
 	if cfg.Search.Enabled {
 		t.Error("search should be disabled via env")
 	}
+	if cfg.Push.VAPIDPreviousPublicKey != "old-public" || cfg.Push.VAPIDPreviousPrivateKey != "old-private" {
+		t.Errorf("previous VAPID keys = %q, %q; want old-public, old-private",
+			cfg.Push.VAPIDPreviousPublicKey, cfg.Push.VAPIDPreviousPrivateKey)
+	}
 }
 
 func TestSessionDurationParsed(t *testing.T) {
@@ -218,3 +360,69 @@ func TestMaxUploadSizeBytes_Invalid(t *testing.T) {
 		t.Fatal("expected error for invalid size")
 	}
 }
+
+func TestClientsConfigOutdated(t *testing.T) {
+	c := ClientsConfig{MinVersion: "1.4.0"}
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"1.3.9", true},
+		{"v1.3", true},
+		{"1.4.0-rc.1", true},
+		{"1.4.0", false},
+		{"1.4.0+build.7", false},
+		{"1.10.0", false},
+		{"2", false},
+		{"", false},
+		{"nightly", false},
+	}
+	for _, tt := range tests {
+		if got := c.Outdated(tt.version); got != tt.want {
+			t.Errorf("Outdated(%q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+	if (ClientsConfig{}).Outdated("0.0.1") {
+		t.Error("no minimum version should accept every client")
+	}
+}
+
+func TestHTTPConfigOriginAllowed(t *testing.T) {
+	h := HTTPConfig{CORSOrigins: []string{"https://chat.example.com", "https://*.example.org", "http://*.localhost:5173"}}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://chat.example.com", true},
+		{"https://other.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"http://a.example.org", false},
+		{"https://a.example.org:8443", false},
+		{"https://evilexample.org", false},
+		{"http://app.localhost:5173", true},
+		{"http://app.localhost", false},
+	}
+	for _, tt := range tests {
+		if got := h.OriginAllowed(tt.origin); got != tt.want {
+			t.Errorf("OriginAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+	if !(HTTPConfig{CORSOrigins: []string{"*"}}).OriginAllowed("https://anything.test") {
+		t.Error("\"*\" should allow every origin")
+	}
+}
+
+func TestHTTPConfigTrustedProxyNets(t *testing.T) {
+	nets, err := HTTPConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.7", "2001:db8::1"}}.TrustedProxyNets()
+	if err != nil {
+		t.Fatalf("TrustedProxyNets() error = %v", err)
+	}
+	if len(nets) != 3 || !nets[1].Contains(net.ParseIP("192.0.2.7")) || nets[1].Contains(net.ParseIP("192.0.2.8")) {
+		t.Errorf("TrustedProxyNets() = %v, want 10.0.0.0/8, 192.0.2.7/32 and 2001:db8::1/128", nets)
+	}
+	if _, err := (HTTPConfig{TrustedProxies: []string{"proxy.local"}}).TrustedProxyNets(); err == nil {
+		t.Error("TrustedProxyNets() accepted a host name")
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// defaultTrustedProxies are the loopback and private ranges reverse proxies
+// usually reach the API from, such as Caddy on the Docker network.
+var defaultTrustedProxies = []string{
+	"127.0.0.0/8", "::1/128",
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+}
+
+// TrustedProxyNets parses TrustedProxies. Entries are CIDRs or single
+// addresses.
+func (h HTTPConfig) TrustedProxyNets() ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(h.TrustedProxies))
+	for _, entry := range h.TrustedProxies {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// validOriginPattern reports whether p is "*" or an origin such as
+// https://chat.example.com, optionally with a "*." wildcard for subdomains:
+// https://*.example.com.
+func validOriginPattern(p string) bool {
+	if p == "*" {
+		return true
+	}
+	u, err := url.Parse(strings.Replace(p, "://*.", "://wildcard.", 1))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") &&
+		u.Host != "" && u.Path == "" && u.RawQuery == "" && u.User == nil &&
+		!strings.Contains(u.Host, "*")
+}
+
+// OriginAllowed reports whether a browser origin matches one of the CORS
+// origin patterns. A "*." pattern matches any depth of subdomain but not the
+// bare domain, and the scheme and port must match exactly.
+func (h HTTPConfig) OriginAllowed(origin string) bool {
+	for _, p := range h.CORSOrigins {
+		if p == "*" || p == origin {
+			return true
+		}
+		scheme, rest, ok := strings.Cut(p, "://*.")
+		if !ok {
+			continue
+		}
+		prefix, suffix := scheme+"://", "."+rest
+		if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) ||
+			len(origin) <= len(prefix)+len(suffix) {
+			continue
+		}
+		if sub := origin[len(prefix) : len(origin)-len(suffix)]; !strings.ContainsAny(sub, "/:@") {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyOrigin reports whether CORS is open to every origin, in which case
+// credentials are not allowed.
+func (h HTTPConfig) AnyOrigin() bool {
+	return len(h.CORSOrigins) == 1 && h.CORSOrigins[0] == "*"
+}
@@ -0,0 +1,65 @@
+package config
+
+import "fmt"
+
+// validateRegions checks the deployment regions: strategy, a parsable health
+// check interval, and regional endpoints with unique names that differ from
+// the primary region. Storage regions also need their own bucket names,
+// since stored attachments are located by bucket.
+func validateRegions(cfg *Config) error {
+	r := cfg.Regions
+	if r.Strategy != "nearest" && r.Strategy != "primary" {
+		return fmt.Errorf("regions.strategy must be one of: nearest, primary (got %q)", r.Strategy)
+	}
+	if d, err := r.HealthCheckIntervalParsed(); err != nil || d <= 0 {
+		return fmt.Errorf("regions.health_check_interval must be a positive duration (got %q)", r.HealthCheckInterval)
+	}
+
+	known := map[string]bool{r.Primary: true}
+	seen := map[string]bool{}
+	buckets := map[string]bool{cfg.Storage.Bucket: true}
+	for _, sr := range cfg.Storage.Regions {
+		if err := checkRegionName("storage.regions", sr.Name, r.Primary, seen); err != nil {
+			return err
+		}
+		if sr.Endpoint == "" || sr.Bucket == "" {
+			return fmt.Errorf("storage.regions %q needs an endpoint and a bucket", sr.Name)
+		}
+		if buckets[sr.Bucket] {
+			return fmt.Errorf("storage.regions %q reuses bucket %q; every region needs its own bucket name", sr.Name, sr.Bucket)
+		}
+		buckets[sr.Bucket] = true
+		known[sr.Name] = true
+	}
+	seen = map[string]bool{}
+	for _, lr := range cfg.LiveKit.Regions {
+		if err := checkRegionName("livekit.regions", lr.Name, r.Primary, seen); err != nil {
+			return err
+		}
+		if lr.URL == "" || lr.APIKey == "" || lr.APISecret == "" {
+			return fmt.Errorf("livekit.regions %q needs a url, api_key and api_secret", lr.Name)
+		}
+		known[lr.Name] = true
+	}
+
+	if (len(cfg.Storage.Regions) > 0 || len(cfg.LiveKit.Regions) > 0) && r.Primary == "" {
+		return fmt.Errorf("regions.primary is required when storage or livekit regions are configured")
+	}
+	if r.Local != "" && !known[r.Local] {
+		return fmt.Errorf("regions.local %q is not the primary region or a configured storage or livekit region", r.Local)
+	}
+	return nil
+}
+
+func checkRegionName(section, name, primary string, seen map[string]bool) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%s entries need a name", section)
+	case name == primary:
+		return fmt.Errorf("%s %q is the primary region, which is configured by the main section", section, name)
+	case seen[name]:
+		return fmt.Errorf("%s %q is listed twice", section, name)
+	}
+	seen[name] = true
+	return nil
+}
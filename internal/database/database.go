@@ -24,6 +24,7 @@ var migrationsFS embed.FS
 type DB struct {
 	Pool   *pgxpool.Pool
 	logger *slog.Logger
+	tracer *queryTracer
 }
 
 // New creates a new database connection pool with the given PostgreSQL URL and
@@ -39,6 +40,8 @@ func New(ctx context.Context, databaseURL string, maxConns int, logger *slog.Log
 	config.MaxConnLifetime = 30 * time.Minute
 	config.MaxConnIdleTime = 5 * time.Minute
 	config.HealthCheckPeriod = 30 * time.Second
+	tracer := newQueryTracer(logger)
+	config.ConnConfig.Tracer = tracer
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
@@ -55,7 +58,19 @@ func New(ctx context.Context, databaseURL string, maxConns int, logger *slog.Log
 		slog.Int("max_conns", maxConns),
 	)
 
-	return &DB{Pool: pool, logger: logger}, nil
+	return &DB{Pool: pool, logger: logger, tracer: tracer}, nil
+}
+
+// SetSlowQueryThreshold sets how long a query may run before it is logged as
+// slow. Zero turns slow query logging off.
+func (db *DB) SetSlowQueryThreshold(d time.Duration) {
+	db.tracer.slow.Store(int64(d))
+}
+
+// QueryStats returns the timing of every query run so far, grouped by query
+// name and sorted by name.
+func (db *DB) QueryStats() []QueryStat {
+	return db.tracer.snapshot()
 }
 
 // HealthCheck verifies the database connection is alive by executing a simple query.
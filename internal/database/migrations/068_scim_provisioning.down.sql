@@ -0,0 +1,10 @@
+-- Rollback migration 068: SCIM 2.0 provisioning
+
+DROP TABLE IF EXISTS scim_group_role_mappings;
+DROP TABLE IF EXISTS scim_group_members;
+DROP TABLE IF EXISTS scim_groups;
+
+DROP INDEX IF EXISTS idx_users_scim_external_id;
+ALTER TABLE users DROP COLUMN IF EXISTS scim_external_id;
+
+DROP TABLE IF EXISTS scim_tokens;
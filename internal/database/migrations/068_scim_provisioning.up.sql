@@ -0,0 +1,52 @@
+-- Migration 068: SCIM 2.0 provisioning
+-- Bearer tokens for identity providers, external ID linkage on users, and
+-- SCIM groups that map onto guild roles.
+
+-- ============================================================
+-- SCIM TOKENS (only the SHA-256 hash of each token is stored)
+-- ============================================================
+
+CREATE TABLE IF NOT EXISTS scim_tokens (
+    id              TEXT PRIMARY KEY,
+    name            TEXT NOT NULL,
+    token_hash      TEXT NOT NULL UNIQUE,
+    created_by      TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at    TIMESTAMPTZ
+);
+
+-- ============================================================
+-- USERS: identity provider linkage
+-- ============================================================
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scim_external_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_scim_external_id
+    ON users(scim_external_id) WHERE scim_external_id IS NOT NULL;
+
+-- ============================================================
+-- SCIM GROUPS and their guild role mappings
+-- ============================================================
+
+CREATE TABLE IF NOT EXISTS scim_groups (
+    id              TEXT PRIMARY KEY,
+    display_name    TEXT NOT NULL,
+    external_id     TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS scim_group_members (
+    group_id        TEXT NOT NULL REFERENCES scim_groups(id) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_scim_group_members_user ON scim_group_members(user_id);
+
+CREATE TABLE IF NOT EXISTS scim_group_role_mappings (
+    group_id        TEXT NOT NULL REFERENCES scim_groups(id) ON DELETE CASCADE,
+    guild_id        TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    role_id         TEXT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (group_id, role_id)
+);
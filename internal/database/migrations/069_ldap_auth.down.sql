@@ -0,0 +1,4 @@
+-- Rollback migration 069: LDAP / Active Directory authentication
+
+DROP INDEX IF EXISTS idx_users_ldap_dn;
+ALTER TABLE users DROP COLUMN IF EXISTS ldap_dn;
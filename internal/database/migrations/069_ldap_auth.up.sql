@@ -0,0 +1,7 @@
+-- Migration 069: LDAP / Active Directory authentication
+-- Links local accounts to their directory entry so just-in-time provisioned
+-- users are matched by DN on later logins, even if their username changes.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS ldap_dn TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_ldap_dn
+    ON users(ldap_dn) WHERE ldap_dn IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Rollback migration 070: Role-based instance administration
+
+DROP TABLE IF EXISTS user_instance_roles;
+DROP TABLE IF EXISTS instance_roles;
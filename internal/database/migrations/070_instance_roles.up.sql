@@ -0,0 +1,30 @@
+-- Migration 070: Role-based instance administration
+-- Instance roles carry a bitfield of instance-scoped staff permissions
+-- (see internal/permissions/instance.go). The users.flags admin bit keeps
+-- granting full control; roles delegate a subset of it.
+
+CREATE TABLE IF NOT EXISTS instance_roles (
+    id              TEXT PRIMARY KEY,
+    name            TEXT NOT NULL UNIQUE,
+    description     TEXT,
+    permissions     BIGINT NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS user_instance_roles (
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id         TEXT NOT NULL REFERENCES instance_roles(id) ON DELETE CASCADE,
+    assigned_by     TEXT REFERENCES users(id) ON DELETE SET NULL,
+    assigned_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_instance_roles_role ON user_instance_roles(role_id);
+
+-- Default roles, one per staff duty.
+INSERT INTO instance_roles (id, name, description, permissions) VALUES
+    ('instance_role_user_manager',       'User Manager',       'Suspend, ban, and review user accounts', 1),
+    ('instance_role_federation_manager', 'Federation Manager', 'Manage federation peers and bridges',    2),
+    ('instance_role_media_moderator',    'Media Moderator',    'Review and remove uploaded media',       4),
+    ('instance_role_report_handler',     'Report Handler',     'Review and resolve escalated reports',   8)
+ON CONFLICT DO NOTHING;
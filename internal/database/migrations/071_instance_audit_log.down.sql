@@ -0,0 +1,5 @@
+-- Rollback migration 071: Instance staff audit log
+
+DROP TRIGGER IF EXISTS trg_instance_audit_log_immutable ON instance_audit_log;
+DROP FUNCTION IF EXISTS instance_audit_log_immutable();
+DROP TABLE IF EXISTS instance_audit_log;
@@ -0,0 +1,32 @@
+-- Migration 071: Instance staff audit log
+-- Append-only record of instance-level staff actions (suspensions, bans,
+-- federation changes, report resolutions, configuration changes). Actor and
+-- target are stored without foreign keys so entries survive account deletion,
+-- and a trigger rejects UPDATE and DELETE.
+
+CREATE TABLE IF NOT EXISTS instance_audit_log (
+    id              TEXT PRIMARY KEY,
+    actor_id        TEXT NOT NULL,
+    action          TEXT NOT NULL,
+    target_type     TEXT,
+    target_id       TEXT,
+    before_value    JSONB,
+    after_value     JSONB,
+    reason          TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_instance_audit_log_created ON instance_audit_log(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_instance_audit_log_actor ON instance_audit_log(actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_instance_audit_log_target ON instance_audit_log(target_type, target_id);
+
+CREATE OR REPLACE FUNCTION instance_audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'instance_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_instance_audit_log_immutable ON instance_audit_log;
+CREATE TRIGGER trg_instance_audit_log_immutable
+    BEFORE UPDATE OR DELETE ON instance_audit_log
+    FOR EACH ROW EXECUTE FUNCTION instance_audit_log_immutable();
@@ -0,0 +1,6 @@
+-- Rollback migration 072: Timed suspensions and suspension appeals
+
+DROP TABLE IF EXISTS suspension_appeals;
+DROP INDEX IF EXISTS idx_users_suspended_until;
+ALTER TABLE users DROP COLUMN IF EXISTS suspension_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_until;
@@ -0,0 +1,25 @@
+-- Migration 072: Timed suspensions and suspension appeals
+-- suspended_until lets the suspension worker lift a suspension automatically;
+-- suspension_reason is shown to the user at login. Suspended users can submit
+-- one pending appeal at a time for staff review.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspension_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_users_suspended_until ON users(suspended_until)
+    WHERE suspended_until IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS suspension_appeals (
+    id              TEXT PRIMARY KEY,
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message         TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'pending', -- pending, accepted, rejected
+    response        TEXT,
+    reviewed_by     TEXT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_suspension_appeals_pending ON suspension_appeals(user_id)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_suspension_appeals_status ON suspension_appeals(status, created_at);
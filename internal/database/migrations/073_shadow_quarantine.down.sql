@@ -0,0 +1,9 @@
+-- Rollback migration 073: Shadow quarantine for suspected spam accounts
+
+UPDATE automod_rules SET action = 'log' WHERE action = 'quarantine';
+ALTER TABLE automod_rules DROP CONSTRAINT IF EXISTS automod_rules_action_check;
+ALTER TABLE automod_rules ADD CONSTRAINT automod_rules_action_check
+    CHECK (action IN ('delete','warn','timeout','log'));
+
+DROP INDEX IF EXISTS idx_messages_quarantined;
+DROP TABLE IF EXISTS user_quarantines;
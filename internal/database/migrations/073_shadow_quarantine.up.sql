@@ -0,0 +1,28 @@
+-- Migration 073: Shadow quarantine for suspected spam accounts
+-- A quarantined user can keep posting, but their messages carry the
+-- quarantined message flag (1 << 4) and are only shown to the author and to
+-- moderators until a moderator releases or confirms the quarantine.
+
+CREATE TABLE IF NOT EXISTS user_quarantines (
+    id              TEXT PRIMARY KEY,
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source          TEXT NOT NULL,                   -- dm_spam, automod, manual
+    reason          TEXT,
+    guild_id        TEXT REFERENCES guilds(id) ON DELETE SET NULL, -- AutoMod origin, if any
+    status          TEXT NOT NULL DEFAULT 'pending', -- pending, released, confirmed
+    created_by      TEXT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_by     TEXT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_quarantines_pending ON user_quarantines(user_id)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_user_quarantines_status ON user_quarantines(status, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_messages_quarantined ON messages(author_id)
+    WHERE flags & 16 != 0;
+
+ALTER TABLE automod_rules DROP CONSTRAINT IF EXISTS automod_rules_action_check;
+ALTER TABLE automod_rules ADD CONSTRAINT automod_rules_action_check
+    CHECK (action IN ('delete','warn','timeout','log','quarantine'));
@@ -0,0 +1,3 @@
+-- Rollback migration 074: Alt-account correlation signals
+
+DROP TABLE IF EXISTS account_signals;
@@ -0,0 +1,17 @@
+-- Migration 074: Alt-account correlation signals
+-- Hashed IP addresses, normalized emails, and device fingerprints seen for
+-- each account. Registration rows also drive the per-IP registration caps.
+
+CREATE TABLE IF NOT EXISTS account_signals (
+    user_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind          TEXT NOT NULL CHECK (kind IN ('ip', 'email', 'device')),
+    value_hash    TEXT NOT NULL,
+    registration  BOOLEAN NOT NULL DEFAULT false,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, kind, value_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_signals_value ON account_signals(kind, value_hash);
+CREATE INDEX IF NOT EXISTS idx_account_signals_registrations ON account_signals(value_hash, first_seen_at)
+    WHERE registration AND kind = 'ip';
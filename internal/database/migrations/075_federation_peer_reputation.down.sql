@@ -0,0 +1,5 @@
+-- Rollback migration 075: Federation spam shield
+
+ALTER TABLE federation_peer_status DROP COLUMN IF EXISTS throttled_until;
+ALTER TABLE federation_peer_status DROP COLUMN IF EXISTS quota_violations;
+ALTER TABLE federation_peer_status DROP COLUMN IF EXISTS reputation;
@@ -0,0 +1,8 @@
+-- Migration 075: Federation spam shield
+-- Reputation score, cumulative inbound quota violations, and current throttle
+-- expiry for each peer, persisted from the in-memory shield for the admin
+-- federation dashboard.
+
+ALTER TABLE federation_peer_status ADD COLUMN IF NOT EXISTS reputation INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE federation_peer_status ADD COLUMN IF NOT EXISTS quota_violations BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE federation_peer_status ADD COLUMN IF NOT EXISTS throttled_until TIMESTAMPTZ;
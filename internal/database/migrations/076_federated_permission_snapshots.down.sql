@@ -0,0 +1,3 @@
+-- Rollback migration 076: Federated permission snapshots
+
+DROP TABLE IF EXISTS federated_permission_snapshots;
@@ -0,0 +1,15 @@
+-- Migration 076: Federated permission snapshots
+-- Mirrors the remote roles and per-channel effective permissions of a local
+-- user's membership in a federated guild, so proxy actions the host would
+-- refuse can be rejected locally and the client can hide inaccessible
+-- channels. channel_permissions maps channel ID to a decimal permission
+-- bitmask; NULL means the host does not support permission snapshots.
+
+CREATE TABLE IF NOT EXISTS federated_permission_snapshots (
+    guild_id            TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    user_id             TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_ids            TEXT[] NOT NULL DEFAULT '{}',
+    channel_permissions JSONB,
+    fetched_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, user_id)
+);
@@ -0,0 +1,3 @@
+-- Rollback migration 077: Federated DM presence
+
+ALTER TABLE users DROP COLUMN IF EXISTS share_federated_presence;
@@ -0,0 +1,5 @@
+-- Migration 077: Federated DM presence
+-- Lets a user opt in to sharing their online presence with remote
+-- instances they only share DMs with (guild peers already receive it).
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS share_federated_presence BOOLEAN NOT NULL DEFAULT false;
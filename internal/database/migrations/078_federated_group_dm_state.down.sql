@@ -0,0 +1,3 @@
+-- Rollback migration 078: Federated group DM state
+
+DROP TABLE IF EXISTS federated_group_dm_state;
@@ -0,0 +1,13 @@
+-- Migration 078: Federated group DM state
+-- Group DMs with participants on several instances are owned by the instance
+-- that created them (the host). Every instance stores the channel under the
+-- host's channel ID; membership changes are applied by the host and pushed
+-- to the other instances as versioned snapshots, and a snapshot is only
+-- applied if its membership_version is newer than the local one.
+
+CREATE TABLE IF NOT EXISTS federated_group_dm_state (
+    channel_id         TEXT PRIMARY KEY REFERENCES channels(id) ON DELETE CASCADE,
+    host_instance_id   TEXT NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    membership_version BIGINT NOT NULL DEFAULT 0,
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
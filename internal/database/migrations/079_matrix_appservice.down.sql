@@ -0,0 +1,6 @@
+-- Rollback migration 079: Embedded Matrix appservice
+
+DROP INDEX IF EXISTS idx_messages_matrix_event;
+DROP TABLE IF EXISTS matrix_puppets;
+DROP TABLE IF EXISTS matrix_rooms;
+DROP TABLE IF EXISTS matrix_bridged_guilds;
@@ -0,0 +1,26 @@
+-- Migration 079: Embedded Matrix appservice
+-- Guilds an admin has exposed to Matrix, the Matrix room created for each of
+-- their channels on first alias lookup, and the local puppet users that
+-- represent Matrix users. AmityVox users appear on Matrix as appservice
+-- virtual users, so both sides see a real identity for every author.
+
+CREATE TABLE IF NOT EXISTS matrix_bridged_guilds (
+    guild_id    TEXT PRIMARY KEY REFERENCES guilds(id) ON DELETE CASCADE,
+    created_by  TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS matrix_rooms (
+    channel_id  TEXT PRIMARY KEY REFERENCES channels(id) ON DELETE CASCADE,
+    room_id     TEXT NOT NULL UNIQUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS matrix_puppets (
+    matrix_user_id TEXT PRIMARY KEY,
+    user_id        TEXT NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_matrix_event
+    ON messages(bridge_remote_id) WHERE bridge_source = 'matrix';
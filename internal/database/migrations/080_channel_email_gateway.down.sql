@@ -0,0 +1,4 @@
+-- Rollback migration 080: Channel email gateway
+
+DROP TABLE IF EXISTS channel_email_messages;
+DROP TABLE IF EXISTS channel_email_addresses;
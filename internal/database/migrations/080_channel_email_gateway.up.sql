@@ -0,0 +1,23 @@
+-- Migration 080: Channel email gateway
+-- Inbound email addresses for channels, and the link between a message and
+-- the email it came from or was sent as. The link is what lets an emailed
+-- reply thread back onto the right message and lets channel replies be
+-- mailed to the original sender.
+
+CREATE TABLE IF NOT EXISTS channel_email_addresses (
+    channel_id    TEXT PRIMARY KEY REFERENCES channels(id) ON DELETE CASCADE,
+    localpart     TEXT NOT NULL UNIQUE,
+    reply_enabled BOOLEAN NOT NULL DEFAULT false,
+    created_by    TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS channel_email_messages (
+    message_id       TEXT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    channel_id       TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    email_message_id TEXT NOT NULL,   -- RFC 5322 Message-ID, without angle brackets
+    correspondent    TEXT NOT NULL,   -- external address the email came from or went to
+    subject          TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (channel_id, email_message_id)
+);
@@ -0,0 +1,3 @@
+-- Rollback migration 081: Bulk message import
+
+DROP INDEX IF EXISTS idx_messages_bridge_remote;
@@ -0,0 +1,7 @@
+-- Migration 081: Bulk message import
+-- Imports record the remote platform's message ID in bridge_remote_id. This
+-- index backs the duplicate check that makes a retried import batch safe.
+
+CREATE INDEX IF NOT EXISTS idx_messages_bridge_remote
+    ON messages(channel_id, bridge_source, bridge_remote_id)
+    WHERE bridge_remote_id IS NOT NULL;
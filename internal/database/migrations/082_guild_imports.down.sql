@@ -0,0 +1,4 @@
+-- Rollback migration 082: Guild imports
+
+DROP TABLE IF EXISTS guild_import_mappings;
+DROP TABLE IF EXISTS guild_imports;
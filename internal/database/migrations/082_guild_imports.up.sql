@@ -0,0 +1,40 @@
+-- Migration 082: Guild imports
+-- An import job recreates a guild exported from another platform. Jobs are
+-- claimed by a background worker; progress is written back to the row as the
+-- job runs. guild_import_mappings remembers which local object each remote
+-- object became, so an interrupted job can be resumed without duplicating
+-- roles, channels or emoji.
+
+CREATE TABLE IF NOT EXISTS guild_imports (
+    id           TEXT PRIMARY KEY,
+    guild_id     TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    requested_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    source       TEXT NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending','running','completed','failed','cancelled')),
+    options      JSONB NOT NULL DEFAULT '{}',
+    archive_id   TEXT REFERENCES attachments(id) ON DELETE SET NULL,
+    credentials  TEXT,                -- e.g. a bot token; cleared when the job ends
+    progress     JSONB NOT NULL DEFAULT '{}',
+    error        TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),  -- heartbeat while running
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_guild_imports_guild ON guild_imports(guild_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_guild_imports_pending ON guild_imports(created_at)
+    WHERE status = 'pending';
+
+-- At most one unfinished import per guild.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_guild_imports_active ON guild_imports(guild_id)
+    WHERE status IN ('pending','running');
+
+CREATE TABLE IF NOT EXISTS guild_import_mappings (
+    import_id TEXT NOT NULL REFERENCES guild_imports(id) ON DELETE CASCADE,
+    kind      TEXT NOT NULL,          -- role, category, channel, emoji
+    remote_id TEXT NOT NULL,
+    local_id  TEXT NOT NULL,
+    PRIMARY KEY (import_id, kind, remote_id)
+);
@@ -0,0 +1,3 @@
+-- Rollback migration 083: Webhook masquerade policy
+
+DROP TABLE IF EXISTS guild_webhook_policy;
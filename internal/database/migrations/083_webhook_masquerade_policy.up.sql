@@ -0,0 +1,13 @@
+-- Migration 083: Webhook masquerade policy
+-- Per-guild limits on the name and avatar overrides webhook executions may
+-- set on individual messages.
+
+CREATE TABLE guild_webhook_policy (
+    guild_id               TEXT PRIMARY KEY REFERENCES guilds(id) ON DELETE CASCADE,
+    allow_name_override    BOOLEAN NOT NULL DEFAULT true,
+    allow_avatar_override  BOOLEAN NOT NULL DEFAULT true,
+    block_member_names     BOOLEAN NOT NULL DEFAULT true,  -- reject names matching a member's
+    require_tag            BOOLEAN NOT NULL DEFAULT false, -- append tag to overridden names
+    tag                    TEXT NOT NULL DEFAULT '[BOT]' CHECK (tag IN ('[BOT]', '[APP]')),
+    updated_at             TIMESTAMPTZ NOT NULL DEFAULT now()
+);
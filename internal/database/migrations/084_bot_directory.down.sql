@@ -0,0 +1,4 @@
+-- Rollback migration 084: Bot directory
+
+DROP TABLE IF EXISTS bot_installs;
+DROP TABLE IF EXISTS bot_listings;
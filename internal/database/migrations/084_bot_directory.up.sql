@@ -0,0 +1,37 @@
+-- Migration 084: Bot directory
+-- Developers describe their bots in a listing that guild admins can browse
+-- and install from. Listings only appear in the public directory once an
+-- instance admin has approved them; a bot's owner can always install it.
+
+CREATE TABLE bot_listings (
+    bot_id            TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    short_description TEXT NOT NULL,
+    description       TEXT NOT NULL DEFAULT '',
+    permissions       BIGINT NOT NULL DEFAULT 0,     -- guild permissions requested on install
+    scopes            TEXT[] NOT NULL DEFAULT '{}',  -- bot scopes granted on install
+    tags              TEXT[] NOT NULL DEFAULT '{}',
+    website_url       TEXT,
+    support_url       TEXT,
+    review_status     TEXT NOT NULL DEFAULT 'unlisted'
+                      CHECK (review_status IN ('unlisted', 'pending', 'approved', 'rejected')),
+    review_note       TEXT,
+    reviewed_by       TEXT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at       TIMESTAMPTZ,
+    install_count     INTEGER NOT NULL DEFAULT 0,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_bot_listings_review ON bot_listings(review_status, install_count DESC);
+CREATE INDEX idx_bot_listings_tags ON bot_listings USING GIN (tags);
+
+-- One row per bot installed into a guild, with the role created for it.
+CREATE TABLE bot_installs (
+    bot_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    guild_id     TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    installed_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    role_id      TEXT REFERENCES roles(id) ON DELETE SET NULL,
+    permissions  BIGINT NOT NULL DEFAULT 0,
+    installed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (bot_id, guild_id)
+);
+CREATE INDEX idx_bot_installs_guild ON bot_installs(guild_id);
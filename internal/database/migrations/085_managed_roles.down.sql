@@ -0,0 +1,2 @@
+-- Rollback migration 085: Managed Roles
+ALTER TABLE roles DROP COLUMN IF EXISTS managed_by;
@@ -0,0 +1,8 @@
+-- Migration 085: Managed Roles
+-- Roles created for an installed app are managed by that bot: they cannot be
+-- deleted, reassigned or given more permissions than the install granted.
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS managed_by TEXT REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE roles r SET managed_by = bi.bot_id
+FROM bot_installs bi
+WHERE bi.role_id = r.id;
@@ -0,0 +1,3 @@
+-- Rollback migration 086: Bot message content access
+
+DROP TABLE IF EXISTS bot_message_content_access;
@@ -0,0 +1,16 @@
+-- Migration 086: Bot message content access
+-- Reading what members write is a privileged bot capability. A bot's owner
+-- asks for it with a reason and an instance admin approves or denies it;
+-- until approved, message events and history reach the bot without content.
+
+CREATE TABLE bot_message_content_access (
+    bot_id       TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    status       TEXT NOT NULL DEFAULT 'pending'
+                 CHECK (status IN ('pending', 'approved', 'denied')),
+    reason       TEXT NOT NULL,
+    review_note  TEXT,
+    reviewed_by  TEXT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at  TIMESTAMPTZ,
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_bot_message_content_status ON bot_message_content_access(status, requested_at);
@@ -0,0 +1,7 @@
+-- Rollback migration 087: User-installable apps
+
+DROP TABLE IF EXISTS interactions;
+DROP TABLE IF EXISTS user_app_installs;
+ALTER TABLE bot_tokens DROP COLUMN IF EXISTS scope;
+ALTER TABLE slash_commands DROP COLUMN IF EXISTS context;
+ALTER TABLE bot_listings DROP COLUMN IF EXISTS user_installable;
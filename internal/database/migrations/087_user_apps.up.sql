@@ -0,0 +1,37 @@
+-- Migration 087: User-installable apps
+-- Apps can be installed to a user instead of a guild. They register DM
+-- commands, are invoked only in that user's DMs and group DMs, and answer
+-- through interaction callbacks using tokens scoped to that purpose.
+
+ALTER TABLE bot_listings ADD COLUMN IF NOT EXISTS user_installable BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE slash_commands ADD COLUMN IF NOT EXISTS context TEXT NOT NULL DEFAULT 'guild'
+    CHECK (context IN ('guild', 'dm'));
+
+-- 'bot' tokens act as the bot account; 'user_app' tokens may only answer
+-- interactions and are rate limited separately.
+ALTER TABLE bot_tokens ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT 'bot'
+    CHECK (scope IN ('bot', 'user_app'));
+
+CREATE TABLE user_app_installs (
+    user_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    bot_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    installed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, bot_id)
+);
+CREATE INDEX idx_user_app_installs_bot ON user_app_installs(bot_id);
+
+-- Command invocations awaiting or holding the app's response.
+CREATE TABLE interactions (
+    id           TEXT PRIMARY KEY,
+    bot_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    channel_id   TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    guild_id     TEXT REFERENCES guilds(id) ON DELETE CASCADE,
+    command_id   TEXT NOT NULL REFERENCES slash_commands(id) ON DELETE CASCADE,
+    context      TEXT NOT NULL CHECK (context IN ('guild', 'dm')),
+    options      JSONB NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    responded_at TIMESTAMPTZ
+);
+CREATE INDEX idx_interactions_created ON interactions(created_at);
@@ -0,0 +1,12 @@
+-- Rollback migration 088: Emoji quotas and moderation
+
+ALTER TABLE custom_emoji DROP COLUMN IF EXISTS flag_reason;
+ALTER TABLE custom_emoji DROP COLUMN IF EXISTS flagged;
+ALTER TABLE custom_emoji DROP COLUMN IF EXISTS image_hash;
+
+ALTER TABLE attachments DROP COLUMN IF EXISTS sha256;
+
+ALTER TABLE guilds DROP COLUMN IF EXISTS emoji_max_animated;
+ALTER TABLE guilds DROP COLUMN IF EXISTS emoji_max_static;
+
+DROP TABLE IF EXISTS emoji_quota_tiers;
@@ -0,0 +1,28 @@
+-- Migration 088: Emoji quotas and moderation
+-- Emoji slots per guild come from instance-configured tiers keyed by boost
+-- tier, optionally overridden per guild by an instance admin. Uploaded files
+-- record a SHA-256 of their bytes so AutoMod can match new emoji against
+-- blocklisted images; flagged emoji are held back until a moderator clears
+-- them.
+
+CREATE TABLE emoji_quota_tiers (
+    boost_tier   INT PRIMARY KEY CHECK (boost_tier >= 0),
+    max_static   INT NOT NULL CHECK (max_static >= 0),
+    max_animated INT NOT NULL CHECK (max_animated >= 0),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO emoji_quota_tiers (boost_tier, max_static, max_animated) VALUES
+    (0, 50, 50),
+    (1, 100, 100),
+    (2, 150, 150),
+    (3, 250, 250);
+
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS emoji_max_static INT CHECK (emoji_max_static >= 0);
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS emoji_max_animated INT CHECK (emoji_max_animated >= 0);
+
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS sha256 TEXT;
+
+ALTER TABLE custom_emoji ADD COLUMN IF NOT EXISTS image_hash TEXT;
+ALTER TABLE custom_emoji ADD COLUMN IF NOT EXISTS flagged BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE custom_emoji ADD COLUMN IF NOT EXISTS flag_reason TEXT;
@@ -0,0 +1,3 @@
+-- Rollback migration 089: Channel auto-translation
+
+DROP TABLE IF EXISTS channel_auto_translate;
@@ -0,0 +1,10 @@
+-- Migration 089: Channel auto-translation
+-- A row here turns on auto-translation for a guild channel. target_lang NULL
+-- follows the guild's preferred_locale.
+
+CREATE TABLE IF NOT EXISTS channel_auto_translate (
+    channel_id  TEXT PRIMARY KEY REFERENCES channels(id) ON DELETE CASCADE,
+    target_lang TEXT,
+    enabled_by  TEXT REFERENCES users(id) ON DELETE SET NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
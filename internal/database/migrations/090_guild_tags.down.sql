@@ -0,0 +1,3 @@
+-- Rollback migration 090: Guild tags
+
+DROP TABLE IF EXISTS guild_tags;
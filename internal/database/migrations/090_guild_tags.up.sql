@@ -0,0 +1,19 @@
+-- Migration 090: Guild tags
+-- Canned text responses that members post with /tag <name>. Names are unique
+-- per guild regardless of case. uses and last_used_at back the usage stats
+-- and the per-tag cooldown.
+
+CREATE TABLE guild_tags (
+    id               TEXT PRIMARY KEY,
+    guild_id         TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    name             TEXT NOT NULL,
+    content          TEXT NOT NULL,
+    cooldown_seconds INT NOT NULL DEFAULT 0 CHECK (cooldown_seconds >= 0),
+    uses             BIGINT NOT NULL DEFAULT 0,
+    last_used_at     TIMESTAMPTZ,
+    creator_id       TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_guild_tags_name ON guild_tags(guild_id, lower(name));
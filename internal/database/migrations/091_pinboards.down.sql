@@ -0,0 +1,31 @@
+-- Rollback migration 091: Pinboards
+
+CREATE TABLE IF NOT EXISTS guild_starboard_config (
+    guild_id TEXT PRIMARY KEY REFERENCES guilds(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    channel_id TEXT REFERENCES channels(id) ON DELETE SET NULL,
+    emoji TEXT NOT NULL DEFAULT 'star',
+    threshold INT NOT NULL DEFAULT 3,
+    self_star BOOLEAN NOT NULL DEFAULT false,
+    nsfw_allowed BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Each guild keeps its oldest board.
+INSERT INTO guild_starboard_config (guild_id, enabled, channel_id, emoji, threshold, self_star, nsfw_allowed, updated_at)
+SELECT DISTINCT ON (guild_id) guild_id, enabled, channel_id, emoji, threshold, self_star, nsfw_allowed, updated_at
+FROM pinboards
+ORDER BY guild_id, created_at;
+
+DELETE FROM starboard_entries e
+WHERE NOT EXISTS (
+    SELECT 1 FROM guild_starboard_config c
+    JOIN pinboards p ON p.guild_id = c.guild_id AND p.channel_id = c.channel_id
+    WHERE p.id = e.board_id
+);
+
+ALTER TABLE starboard_entries DROP CONSTRAINT IF EXISTS starboard_entries_board_message_key;
+ALTER TABLE starboard_entries DROP COLUMN IF EXISTS board_id;
+ALTER TABLE starboard_entries ADD CONSTRAINT starboard_entries_guild_id_source_message_id_key UNIQUE (guild_id, source_message_id);
+
+DROP TABLE IF EXISTS pinboards;
@@ -0,0 +1,43 @@
+-- Migration 091: Pinboards
+-- Generalizes the single per-guild starboard into any number of boards, each
+-- with its own target channel, emoji, threshold and source-channel filters.
+-- Existing starboard configs become the guild's first board, and starboard
+-- entries are tracked per board.
+
+CREATE TABLE pinboards (
+    id                  TEXT PRIMARY KEY,
+    guild_id            TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    name                TEXT NOT NULL,
+    channel_id          TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    emoji               TEXT NOT NULL DEFAULT 'star',
+    threshold           INT NOT NULL DEFAULT 3 CHECK (threshold BETWEEN 1 AND 100),
+    self_star           BOOLEAN NOT NULL DEFAULT false,
+    nsfw_allowed        BOOLEAN NOT NULL DEFAULT false,
+    -- Empty source_channel_ids means every channel except ignored ones.
+    source_channel_ids  TEXT[] NOT NULL DEFAULT '{}',
+    ignored_channel_ids TEXT[] NOT NULL DEFAULT '{}',
+    enabled             BOOLEAN NOT NULL DEFAULT true,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_pinboards_guild ON pinboards(guild_id);
+
+INSERT INTO pinboards (id, guild_id, name, channel_id, emoji, threshold, self_star, nsfw_allowed, enabled, updated_at)
+SELECT gen_random_uuid()::text, guild_id, 'Starboard', channel_id, emoji, threshold, self_star, nsfw_allowed,
+       enabled, COALESCE(updated_at, now())
+FROM guild_starboard_config
+WHERE channel_id IS NOT NULL;
+
+ALTER TABLE starboard_entries ADD COLUMN board_id TEXT REFERENCES pinboards(id) ON DELETE CASCADE;
+
+UPDATE starboard_entries e SET board_id = p.id
+FROM pinboards p WHERE p.guild_id = e.guild_id;
+
+DELETE FROM starboard_entries WHERE board_id IS NULL;
+
+ALTER TABLE starboard_entries ALTER COLUMN board_id SET NOT NULL;
+ALTER TABLE starboard_entries DROP CONSTRAINT IF EXISTS starboard_entries_guild_id_source_message_id_key;
+ALTER TABLE starboard_entries ADD CONSTRAINT starboard_entries_board_message_key UNIQUE (board_id, source_message_id);
+
+DROP TABLE guild_starboard_config;
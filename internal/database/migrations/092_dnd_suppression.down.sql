@@ -0,0 +1,3 @@
+-- Rollback migration 092: Do Not Disturb notification suppression
+
+DROP TABLE IF EXISTS dnd_suppressed_notifications;
@@ -0,0 +1,13 @@
+-- Migration 092: Do Not Disturb notification suppression
+-- While a user is in Do Not Disturb, push delivery and @here notifications
+-- are held back server-side. What was held back is counted here and sent as
+-- one summary when the user leaves Do Not Disturb.
+
+CREATE TABLE dnd_suppressed_notifications (
+    user_id         TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    mentions        INT NOT NULL DEFAULT 0,
+    here_mentions   INT NOT NULL DEFAULT 0,
+    direct_messages INT NOT NULL DEFAULT 0,
+    other           INT NOT NULL DEFAULT 0,
+    since           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
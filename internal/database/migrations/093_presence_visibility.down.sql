@@ -0,0 +1,3 @@
+-- Rollback migration 093: Invisible mode exceptions
+
+DROP TABLE IF EXISTS presence_visibility_exceptions;
@@ -0,0 +1,12 @@
+-- Migration 093: Invisible mode exceptions
+-- A user in invisible mode appears offline to everyone except the friends
+-- and guilds listed here, who see them online. The gateway applies the
+-- lists when it fans out presence.
+
+CREATE TABLE presence_visibility_exceptions (
+    user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type TEXT NOT NULL CHECK (target_type IN ('user', 'guild')),
+    target_id   TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, target_type, target_id)
+);
@@ -0,0 +1,4 @@
+-- Rollback migration 094: Join-requestable private channels
+
+DROP TABLE IF EXISTS channel_join_requests;
+DROP TABLE IF EXISTS channel_join_settings;
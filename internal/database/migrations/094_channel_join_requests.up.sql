@@ -0,0 +1,28 @@
+-- Migration 094: Join-requestable private channels
+-- A private channel can accept join requests from guild members. Holders of
+-- the approver role (or MANAGE_CHANNELS) review them, and an approval adds a
+-- user permission override that lets the member see the channel.
+
+CREATE TABLE channel_join_settings (
+    channel_id       TEXT PRIMARY KEY REFERENCES channels(id) ON DELETE CASCADE,
+    approver_role_id TEXT REFERENCES roles(id) ON DELETE SET NULL,
+    description      TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE channel_join_requests (
+    id          TEXT PRIMARY KEY,
+    channel_id  TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    guild_id    TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message     TEXT,
+    status      TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'denied')),
+    reviewer_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One open request per member and channel.
+CREATE UNIQUE INDEX idx_channel_join_requests_pending
+    ON channel_join_requests (channel_id, user_id) WHERE status = 'pending';
+CREATE INDEX idx_channel_join_requests_channel ON channel_join_requests (channel_id, status, created_at);
@@ -0,0 +1,15 @@
+-- Rollback migration 095: Category defaults inherited by channels
+
+DROP TRIGGER IF EXISTS trg_category_propagate_defaults ON guild_categories;
+DROP TRIGGER IF EXISTS trg_channel_inherit_category ON channels;
+DROP FUNCTION IF EXISTS category_propagate_defaults();
+DROP FUNCTION IF EXISTS channel_inherit_category();
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS notification_level_inherited,
+    DROP COLUMN IF EXISTS notification_level,
+    DROP COLUMN IF EXISTS slowmode_inherited,
+    DROP COLUMN IF EXISTS nsfw_inherited;
+ALTER TABLE guild_categories
+    DROP COLUMN IF EXISTS notification_level,
+    DROP COLUMN IF EXISTS slowmode_seconds,
+    DROP COLUMN IF EXISTS nsfw;
@@ -0,0 +1,65 @@
+-- Migration 095: Category defaults inherited by channels
+-- Categories get NSFW, slowmode and default notification level settings.
+-- Each channel either inherits a setting from its category or overrides it.
+-- channels.nsfw, slowmode_seconds and notification_level always hold the
+-- effective value, so readers need not resolve the category themselves.
+
+ALTER TABLE guild_categories
+    ADD COLUMN nsfw               BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN slowmode_seconds   INT NOT NULL DEFAULT 0,
+    ADD COLUMN notification_level TEXT CHECK (notification_level IN ('all', 'mentions', 'none'));
+
+-- Existing channels keep their own values.
+ALTER TABLE channels
+    ADD COLUMN nsfw_inherited               BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN slowmode_inherited           BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN notification_level           TEXT CHECK (notification_level IN ('all', 'mentions', 'none')),
+    ADD COLUMN notification_level_inherited BOOLEAN NOT NULL DEFAULT false;
+
+-- Fill in inherited values when a channel is created, moves category or
+-- switches a setting back to inherited.
+CREATE OR REPLACE FUNCTION channel_inherit_category() RETURNS TRIGGER AS $$
+DECLARE
+    cat guild_categories%ROWTYPE;
+BEGIN
+    IF NEW.category_id IS NULL THEN
+        RETURN NEW;
+    END IF;
+    SELECT * INTO cat FROM guild_categories WHERE id = NEW.category_id;
+    IF NOT FOUND THEN
+        RETURN NEW;
+    END IF;
+    IF NEW.nsfw_inherited THEN
+        NEW.nsfw := cat.nsfw;
+    END IF;
+    IF NEW.slowmode_inherited THEN
+        NEW.slowmode_seconds := cat.slowmode_seconds;
+    END IF;
+    IF NEW.notification_level_inherited THEN
+        NEW.notification_level := cat.notification_level;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_channel_inherit_category
+    BEFORE INSERT OR UPDATE OF category_id, nsfw_inherited, slowmode_inherited, notification_level_inherited
+    ON channels
+    FOR EACH ROW EXECUTE FUNCTION channel_inherit_category();
+
+-- Push category changes to the channels that inherit them.
+CREATE OR REPLACE FUNCTION category_propagate_defaults() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE channels SET
+        nsfw = CASE WHEN nsfw_inherited THEN NEW.nsfw ELSE nsfw END,
+        slowmode_seconds = CASE WHEN slowmode_inherited THEN NEW.slowmode_seconds ELSE slowmode_seconds END,
+        notification_level = CASE WHEN notification_level_inherited THEN NEW.notification_level ELSE notification_level END
+    WHERE category_id = NEW.id
+      AND (nsfw_inherited OR slowmode_inherited OR notification_level_inherited);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_category_propagate_defaults
+    AFTER UPDATE OF nsfw, slowmode_seconds, notification_level ON guild_categories
+    FOR EACH ROW EXECUTE FUNCTION category_propagate_defaults();
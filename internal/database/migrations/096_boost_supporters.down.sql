@@ -0,0 +1,6 @@
+-- Rollback migration 096: Boost supporters and tier perks
+
+DELETE FROM instance_settings WHERE key = 'boosts_require_supporter';
+
+DROP TABLE IF EXISTS boost_tier_perks;
+DROP TABLE IF EXISTS supporter_entitlements;
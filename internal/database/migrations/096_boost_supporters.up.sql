@@ -0,0 +1,36 @@
+-- Migration 096: Boost supporters and tier perks
+-- Instances can require users to be supporters before they boost a guild. A
+-- supporter entitlement grants a number of boost slots, either by hand from an
+-- instance admin or from a payment provider. Boost tiers unlock per-tier
+-- perks on top of the emoji quotas from migration 088: a larger attachment
+-- limit and a higher voice bitrate ceiling.
+
+CREATE TABLE supporter_entitlements (
+    user_id     TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    boost_slots INT NOT NULL CHECK (boost_slots >= 0),
+    source      TEXT NOT NULL DEFAULT 'manual',
+    expires_at  TIMESTAMPTZ,
+    granted_by  TEXT REFERENCES users(id) ON DELETE SET NULL,
+    note        TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- NULL limits mean the instance-wide maximum applies.
+CREATE TABLE boost_tier_perks (
+    boost_tier       INT PRIMARY KEY CHECK (boost_tier >= 0),
+    max_upload_bytes BIGINT CHECK (max_upload_bytes > 0),
+    max_bitrate      INT CHECK (max_bitrate > 0),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO boost_tier_perks (boost_tier, max_upload_bytes, max_bitrate) VALUES
+    (0, 26214400, 96000),
+    (1, 52428800, 128000),
+    (2, 104857600, 256000),
+    (3, NULL, 384000);
+
+-- Off by default, so every member can boost as before.
+INSERT INTO instance_settings (key, value)
+VALUES ('boosts_require_supporter', 'false')
+ON CONFLICT (key) DO NOTHING;
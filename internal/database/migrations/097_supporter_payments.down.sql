@@ -0,0 +1,7 @@
+-- Rollback migration 097: Supporter payments
+
+DROP TABLE IF EXISTS payment_events;
+
+DROP INDEX IF EXISTS idx_supporter_entitlements_external;
+ALTER TABLE supporter_entitlements DROP COLUMN IF EXISTS admin_override;
+ALTER TABLE supporter_entitlements DROP COLUMN IF EXISTS external_id;
@@ -0,0 +1,24 @@
+-- Migration 097: Supporter payments
+-- Stripe and Ko-fi webhooks grant and revoke supporter entitlements. An
+-- entitlement remembers the provider's subscription (or payer) it came from,
+-- and an admin override keeps providers and reconciliation from touching it.
+-- Each delivered webhook is recorded once, by provider and event ID, so
+-- retried deliveries are not applied twice.
+
+ALTER TABLE supporter_entitlements ADD COLUMN IF NOT EXISTS external_id TEXT;
+ALTER TABLE supporter_entitlements ADD COLUMN IF NOT EXISTS admin_override BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_supporter_entitlements_external
+    ON supporter_entitlements(source, external_id) WHERE external_id IS NOT NULL;
+
+CREATE TABLE payment_events (
+    provider    TEXT NOT NULL,
+    event_id    TEXT NOT NULL,
+    event_type  TEXT NOT NULL,
+    user_id     TEXT REFERENCES users(id) ON DELETE SET NULL,
+    outcome     TEXT NOT NULL CHECK (outcome IN ('granted', 'revoked', 'ignored', 'unmatched')),
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (provider, event_id)
+);
+
+CREATE INDEX idx_payment_events_received ON payment_events(received_at DESC);
@@ -0,0 +1,4 @@
+-- Rollback migration 098: Instance policies
+
+DROP TABLE IF EXISTS instance_policy_acknowledgments;
+DROP TABLE IF EXISTS instance_policies;
@@ -0,0 +1,28 @@
+-- Migration 098: Instance policies
+-- Instance admins publish versioned terms of service and instance rules.
+-- Users must acknowledge the current version of each before they can use the
+-- API. A version published without requiring acknowledgment (a typo fix, say)
+-- is covered by an acknowledgment of an earlier version. Acknowledgments are
+-- kept with their time and client IP as a compliance record.
+
+CREATE TABLE instance_policies (
+    id                      TEXT PRIMARY KEY,
+    kind                    TEXT NOT NULL CHECK (kind IN ('terms', 'rules')),
+    version                 INT NOT NULL CHECK (version > 0),
+    title                   TEXT NOT NULL,
+    content                 TEXT NOT NULL,
+    requires_acknowledgment BOOLEAN NOT NULL DEFAULT true,
+    published_by            TEXT REFERENCES users(id) ON DELETE SET NULL,
+    published_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (kind, version)
+);
+
+CREATE TABLE instance_policy_acknowledgments (
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    policy_id       TEXT NOT NULL REFERENCES instance_policies(id) ON DELETE CASCADE,
+    acknowledged_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ip_address      TEXT,
+    PRIMARY KEY (user_id, policy_id)
+);
+
+CREATE INDEX idx_instance_policy_acks_policy ON instance_policy_acknowledgments(policy_id, acknowledged_at DESC);
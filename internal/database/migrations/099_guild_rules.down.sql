@@ -0,0 +1,10 @@
+-- Rollback migration 099: Guild rules acknowledgment
+
+ALTER TABLE guild_members
+    DROP COLUMN IF EXISTS rules_accepted_at,
+    DROP COLUMN IF EXISTS rules_accepted_version;
+
+ALTER TABLE guild_onboarding
+    DROP COLUMN IF EXISTS rules_version,
+    DROP COLUMN IF EXISTS require_rules_acceptance,
+    DROP COLUMN IF EXISTS rules_channel_id;
@@ -0,0 +1,15 @@
+-- Migration 099: Guild rules acknowledgment
+-- Guilds can require members to accept their onboarding rules before they
+-- may post, react or join voice. The rules carry a version that is bumped
+-- when an edit asks members to accept them again; each member records the
+-- version they accepted. Members present when the requirement is switched on
+-- are recorded at the current version with no acceptance time.
+
+ALTER TABLE guild_onboarding
+    ADD COLUMN IF NOT EXISTS rules_channel_id TEXT REFERENCES channels(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS require_rules_acceptance BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS rules_version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE guild_members
+    ADD COLUMN IF NOT EXISTS rules_accepted_version INTEGER,
+    ADD COLUMN IF NOT EXISTS rules_accepted_at TIMESTAMPTZ;
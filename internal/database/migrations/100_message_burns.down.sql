@@ -0,0 +1,3 @@
+-- Rollback migration 100: Burn-after-reading DMs
+
+DROP TABLE IF EXISTS message_burns;
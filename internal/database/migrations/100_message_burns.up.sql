@@ -0,0 +1,17 @@
+-- Migration 100: Burn-after-reading DMs
+-- A DM sender can ask for a message to delete itself some minutes after the
+-- recipient reads it. burn_at stays NULL until the recipient's read state
+-- passes the message; a worker then sets it and deletes the message once it
+-- is due.
+
+CREATE TABLE IF NOT EXISTS message_burns (
+    message_id          TEXT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    channel_id          TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    author_id           TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    burn_after_minutes  INTEGER NOT NULL CHECK (burn_after_minutes BETWEEN 1 AND 10080),
+    burn_at             TIMESTAMPTZ,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_burns_unarmed ON message_burns(channel_id) WHERE burn_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_message_burns_due ON message_burns(burn_at) WHERE burn_at IS NOT NULL;
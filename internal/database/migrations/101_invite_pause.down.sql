@@ -0,0 +1,7 @@
+-- Rollback migration 101: Guild invite pause
+
+DROP INDEX IF EXISTS idx_guilds_invites_paused_until;
+
+ALTER TABLE guilds
+    DROP COLUMN IF EXISTS invites_paused_by,
+    DROP COLUMN IF EXISTS invites_paused_until;
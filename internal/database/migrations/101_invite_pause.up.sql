@@ -0,0 +1,11 @@
+-- Migration 101: Guild invite pause
+-- Moderators can pause every invite to a guild for a while, for example during
+-- a raid, without deleting them. Redemptions are refused until the pause runs
+-- out or is lifted; the invite expiry worker clears pauses that have ended.
+
+ALTER TABLE guilds
+    ADD COLUMN IF NOT EXISTS invites_paused_until TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS invites_paused_by TEXT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_guilds_invites_paused_until
+    ON guilds(invites_paused_until) WHERE invites_paused_until IS NOT NULL;
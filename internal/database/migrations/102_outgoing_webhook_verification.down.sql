@@ -0,0 +1,5 @@
+-- Rollback migration 102: Outgoing webhook verification
+
+ALTER TABLE webhooks
+    DROP COLUMN IF EXISTS verified_at,
+    DROP COLUMN IF EXISTS signing_secret;
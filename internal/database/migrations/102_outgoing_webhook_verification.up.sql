@@ -0,0 +1,14 @@
+-- Migration 102: Outgoing webhook verification
+-- Outgoing webhook deliveries are signed with a per-webhook secret, and a URL
+-- only receives real events once it has echoed a signed verification
+-- challenge. Outgoing webhooks that existed before this keep delivering: they
+-- get a secret and count as verified.
+
+ALTER TABLE webhooks
+    ADD COLUMN IF NOT EXISTS signing_secret TEXT,
+    ADD COLUMN IF NOT EXISTS verified_at TIMESTAMPTZ;
+
+UPDATE webhooks
+SET signing_secret = md5(random()::text || id) || md5(random()::text || clock_timestamp()::text),
+    verified_at = now()
+WHERE webhook_type = 'outgoing' AND outgoing_url IS NOT NULL AND signing_secret IS NULL;
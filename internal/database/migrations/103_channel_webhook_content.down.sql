@@ -0,0 +1,3 @@
+-- Rollback migration 103: Channel webhook content rules
+
+DROP TABLE IF EXISTS channel_webhook_content;
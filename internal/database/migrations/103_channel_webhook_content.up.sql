@@ -0,0 +1,12 @@
+-- Migration 103: Channel webhook content rules
+-- Channel managers can limit what incoming webhooks post in a channel: no
+-- attached media, no mentions, or embeds only. Channels without a row allow
+-- everything. Threads follow the rules of their parent channel.
+
+CREATE TABLE IF NOT EXISTS channel_webhook_content (
+    channel_id        TEXT PRIMARY KEY REFERENCES channels(id) ON DELETE CASCADE,
+    allow_attachments BOOLEAN NOT NULL DEFAULT true,
+    allow_mentions    BOOLEAN NOT NULL DEFAULT true,
+    embeds_only       BOOLEAN NOT NULL DEFAULT false,
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
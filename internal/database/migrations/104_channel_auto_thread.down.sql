@@ -0,0 +1,4 @@
+-- Rollback migration 104: Auto-thread channels
+
+ALTER TABLE channels DROP CONSTRAINT IF EXISTS channels_auto_thread_check;
+ALTER TABLE channels DROP COLUMN IF EXISTS auto_thread;
@@ -0,0 +1,10 @@
+-- Migration 104: Auto-thread channels
+-- In an auto-thread channel every top-level message opens its own thread and
+-- replies must be posted in that thread, so the channel itself only lists
+-- conversation starters. Only text and announcement channels that are not
+-- threads themselves can use the mode.
+
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS auto_thread BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE channels ADD CONSTRAINT channels_auto_thread_check
+    CHECK (NOT auto_thread OR (channel_type IN ('text', 'announcement') AND parent_channel_id IS NULL));
@@ -0,0 +1,3 @@
+-- Rollback migration 105: Adaptive slowmode
+
+DROP TABLE IF EXISTS channel_adaptive_slowmode;
@@ -0,0 +1,19 @@
+-- Migration 105: Adaptive slowmode
+-- A channel can scale its slowmode with how busy it is. Once the number of
+-- messages in the last window passes low_threshold, posters wait between
+-- min_seconds and max_seconds, following the curve until high_threshold.
+-- The channel's fixed slowmode still applies as a floor.
+
+CREATE TABLE IF NOT EXISTS channel_adaptive_slowmode (
+    channel_id     TEXT PRIMARY KEY REFERENCES channels(id) ON DELETE CASCADE,
+    enabled        BOOLEAN NOT NULL DEFAULT false,
+    window_seconds INT NOT NULL DEFAULT 60,
+    low_threshold  INT NOT NULL DEFAULT 20,
+    high_threshold INT NOT NULL DEFAULT 120,
+    min_seconds    INT NOT NULL DEFAULT 2,
+    max_seconds    INT NOT NULL DEFAULT 30,
+    curve          TEXT NOT NULL DEFAULT 'linear' CHECK (curve IN ('linear', 'ease_in', 'ease_out')),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (low_threshold < high_threshold),
+    CHECK (min_seconds <= max_seconds)
+);
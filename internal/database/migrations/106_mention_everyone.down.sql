@@ -0,0 +1,3 @@
+-- Rollback migration 106: @everyone mentions
+
+ALTER TABLE messages DROP COLUMN IF EXISTS mention_everyone;
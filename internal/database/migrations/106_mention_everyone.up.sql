@@ -0,0 +1,5 @@
+-- Migration 106: @everyone mentions
+-- @everyone is gated by its own MENTION_EVERYONE permission, separate from
+-- @here, so the message records which of the two it used.
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS mention_everyone BOOLEAN NOT NULL DEFAULT false;
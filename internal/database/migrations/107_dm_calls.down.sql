@@ -0,0 +1,8 @@
+-- Rollback migration 107: DM call log
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check
+    CHECK (message_type IN ('default', 'system_join', 'system_leave', 'system_kick',
+           'system_ban', 'system_pin', 'reply', 'thread_created')) NOT VALID;
+
+DROP TABLE IF EXISTS dm_calls;
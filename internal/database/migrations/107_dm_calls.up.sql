@@ -0,0 +1,29 @@
+-- Migration 107: DM call log
+-- A call in a DM or group DM is recorded from the first ring until the last
+-- participant hangs up. At most one call per channel is open at a time.
+
+CREATE TABLE IF NOT EXISTS dm_calls (
+    id               TEXT PRIMARY KEY,
+    channel_id       TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    caller_id        TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status           TEXT NOT NULL DEFAULT 'ringing'
+        CHECK (status IN ('ringing', 'active', 'ended', 'missed', 'declined')),
+    participant_ids  TEXT[] NOT NULL DEFAULT '{}',  -- everyone who joined
+    declined_ids     TEXT[] NOT NULL DEFAULT '{}',
+    started_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    answered_at      TIMESTAMPTZ,
+    ended_at         TIMESTAMPTZ,
+    duration_seconds INTEGER                        -- answered to ended; NULL if unanswered
+);
+
+CREATE INDEX IF NOT EXISTS idx_dm_calls_channel ON dm_calls(channel_id, started_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dm_calls_open ON dm_calls(channel_id) WHERE ended_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_dm_calls_ringing ON dm_calls(started_at) WHERE status = 'ringing';
+
+-- The message type check from the initial schema predates most message
+-- types; list all of them, including missed call notices.
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check
+    CHECK (message_type IN ('default', 'system_join', 'system_leave', 'system_kick',
+           'system_ban', 'system_pin', 'reply', 'thread_created', 'voice', 'poll',
+           'forward', 'scheduled', 'system_lockdown', 'system_missed_call'));
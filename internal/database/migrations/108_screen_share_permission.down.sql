@@ -0,0 +1,10 @@
+-- Rollback migration 108: Screen share permission and quality caps
+
+DROP TABLE IF EXISTS screenshare_config;
+
+UPDATE guilds SET default_permissions = default_permissions & ~1099511627776;
+UPDATE channels SET default_permissions = default_permissions & ~1099511627776;
+UPDATE roles SET permissions_allow = permissions_allow & ~1099511627776,
+                 permissions_deny = permissions_deny & ~1099511627776;
+UPDATE channel_permission_overrides SET permissions_allow = permissions_allow & ~1099511627776,
+                                        permissions_deny = permissions_deny & ~1099511627776;
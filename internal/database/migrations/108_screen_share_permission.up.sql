@@ -0,0 +1,26 @@
+-- Migration 108: Screen share permission and quality caps
+-- SCREEN_SHARE (bit 40) now gates screen sharing, which STREAM (bit 35) used
+-- to. Everyone who could stream keeps screen sharing: the new bit is granted
+-- or denied wherever STREAM already is.
+
+UPDATE guilds SET default_permissions = default_permissions | 1099511627776
+ WHERE default_permissions & 34359738368 <> 0;
+UPDATE channels SET default_permissions = default_permissions | 1099511627776
+ WHERE default_permissions & 34359738368 <> 0;
+UPDATE roles SET permissions_allow = permissions_allow | 1099511627776
+ WHERE permissions_allow & 34359738368 <> 0;
+UPDATE roles SET permissions_deny = permissions_deny | 1099511627776
+ WHERE permissions_deny & 34359738368 <> 0;
+UPDATE channel_permission_overrides SET permissions_allow = permissions_allow | 1099511627776
+ WHERE permissions_allow & 34359738368 <> 0;
+UPDATE channel_permission_overrides SET permissions_deny = permissions_deny | 1099511627776
+ WHERE permissions_deny & 34359738368 <> 0;
+
+-- Per-guild screen share quality caps. Guilds without a row use the highest
+-- tier (4k at 60fps).
+CREATE TABLE IF NOT EXISTS screenshare_config (
+    guild_id       TEXT PRIMARY KEY REFERENCES guilds(id) ON DELETE CASCADE,
+    max_resolution TEXT NOT NULL DEFAULT '4k' CHECK (max_resolution IN ('720p', '1080p', '4k')),
+    max_framerate  INTEGER NOT NULL DEFAULT 60 CHECK (max_framerate IN (15, 30, 60)),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
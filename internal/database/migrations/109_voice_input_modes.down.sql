@@ -0,0 +1,3 @@
+-- Rollback migration 109: Voice channel input modes
+
+DROP TABLE IF EXISTS voice_channel_modes;
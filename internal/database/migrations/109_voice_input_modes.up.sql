@@ -0,0 +1,12 @@
+-- Migration 109: Voice channel input modes
+-- Lets a guild require push-to-talk in some of its voice channels. Channels
+-- without a row allow voice activation.
+
+CREATE TABLE IF NOT EXISTS voice_channel_modes (
+    channel_id TEXT PRIMARY KEY REFERENCES channels(id) ON DELETE CASCADE,
+    guild_id   TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    input_mode TEXT NOT NULL DEFAULT 'vad' CHECK (input_mode IN ('vad', 'ptt')),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_voice_channel_modes_guild ON voice_channel_modes(guild_id);
@@ -0,0 +1,7 @@
+-- Rollback migration 110: Push subscription health and VAPID key rotation
+
+ALTER TABLE push_subscriptions
+    DROP COLUMN IF EXISTS failure_count,
+    DROP COLUMN IF EXISTS last_failure_at,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS vapid_public_key;
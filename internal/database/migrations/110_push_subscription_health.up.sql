@@ -0,0 +1,11 @@
+-- Migration 110: Push subscription health and VAPID key rotation
+-- Consecutive delivery failures are counted per subscription so endpoints
+-- that keep failing can be pruned. vapid_public_key records the key the
+-- subscription was created with; NULL means the key current at the time,
+-- which is worked out on the next delivery.
+
+ALTER TABLE push_subscriptions
+    ADD COLUMN IF NOT EXISTS failure_count    INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_failure_at  TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS last_error       TEXT,
+    ADD COLUMN IF NOT EXISTS vapid_public_key TEXT;
@@ -0,0 +1,5 @@
+-- Rollback migration 111: Mention inbox
+
+DROP INDEX IF EXISTS idx_messages_mention_roles;
+DROP INDEX IF EXISTS idx_messages_mention_users;
+DROP TABLE IF EXISTS mention_dismissals;
@@ -0,0 +1,13 @@
+-- Migration 111: Mention inbox
+-- Users can dismiss mentions from their inbox without reading the channel.
+-- The GIN indexes let the inbox find a user's mentions across all channels.
+
+CREATE TABLE IF NOT EXISTS mention_dismissals (
+    user_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id   TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    dismissed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_mention_users ON messages USING GIN (mention_user_ids);
+CREATE INDEX IF NOT EXISTS idx_messages_mention_roles ON messages USING GIN (mention_role_ids);
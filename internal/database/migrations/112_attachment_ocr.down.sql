@@ -0,0 +1,3 @@
+-- Rollback migration 112: Attachment OCR text
+
+ALTER TABLE attachments DROP COLUMN IF EXISTS ocr_text;
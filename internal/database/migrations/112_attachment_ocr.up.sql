@@ -0,0 +1,6 @@
+-- Migration 112: Attachment OCR text
+-- Text recognised in image attachments, indexed for search alongside the
+-- filename and alt text. NULL means the image has not been processed; an
+-- empty string means it was and no text was found.
+
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS ocr_text TEXT;
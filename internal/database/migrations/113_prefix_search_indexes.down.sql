@@ -0,0 +1,6 @@
+-- Rollback migration 113: Prefix search indexes
+
+DROP INDEX IF EXISTS idx_guilds_discoverable_name_prefix;
+DROP INDEX IF EXISTS idx_guild_members_nickname_prefix;
+DROP INDEX IF EXISTS idx_users_display_name_prefix;
+DROP INDEX IF EXISTS idx_users_username_prefix;
@@ -0,0 +1,13 @@
+-- Migration 113: Prefix search indexes
+-- Member search, the mention pickers and guild discovery autocomplete match
+-- names by prefix. These indexes let lower(name) LIKE 'prefix%' use an index
+-- scan where the old '%q%' patterns forced a scan of every member.
+
+CREATE INDEX IF NOT EXISTS idx_users_username_prefix
+    ON users (lower(username) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_users_display_name_prefix
+    ON users (lower(display_name) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_guild_members_nickname_prefix
+    ON guild_members (guild_id, lower(nickname) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_guilds_discoverable_name_prefix
+    ON guilds (lower(name) text_pattern_ops) WHERE discoverable = true;
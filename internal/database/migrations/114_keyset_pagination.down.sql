@@ -0,0 +1,14 @@
+-- Rollback migration 114: Keyset pagination indexes
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_guild ON audit_log (guild_id, created_at DESC);
+DROP INDEX IF EXISTS idx_audit_log_guild_created;
+DROP INDEX IF EXISTS idx_webhooks_guild_created;
+DROP INDEX IF EXISTS idx_invites_guild_created;
+DROP INDEX IF EXISTS idx_guild_bans_guild_created;
+DROP INDEX IF EXISTS idx_guild_members_guild_joined;
+
+ALTER TABLE audit_log ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE webhooks ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE invites ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE guild_bans ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE guild_members ALTER COLUMN joined_at DROP NOT NULL;
@@ -0,0 +1,33 @@
+-- Migration 114: Keyset pagination indexes
+-- Guild members, bans, invites, webhooks and the audit log are paged by
+-- cursor over (timestamp, id). Each index below serves one list in its own
+-- order, so fetching page N costs the same as page 1. Keyset comparisons
+-- need non-NULL keys; every insert already sets these timestamps, so the
+-- backfill only touches rows written before the columns had defaults.
+
+UPDATE guild_members SET joined_at = now() WHERE joined_at IS NULL;
+ALTER TABLE guild_members ALTER COLUMN joined_at SET NOT NULL;
+UPDATE guild_bans SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE guild_bans ALTER COLUMN created_at SET NOT NULL;
+UPDATE invites SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE invites ALTER COLUMN created_at SET NOT NULL;
+UPDATE webhooks SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE webhooks ALTER COLUMN created_at SET NOT NULL;
+UPDATE audit_log SET created_at = now() WHERE created_at IS NULL;
+ALTER TABLE audit_log ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_guild_members_guild_joined
+    ON guild_members (guild_id, joined_at, user_id);
+CREATE INDEX IF NOT EXISTS idx_guild_bans_guild_created
+    ON guild_bans (guild_id, created_at DESC, user_id DESC);
+CREATE INDEX IF NOT EXISTS idx_invites_guild_created
+    ON invites (guild_id, created_at DESC, code DESC);
+CREATE INDEX IF NOT EXISTS idx_webhooks_guild_created
+    ON webhooks (guild_id, created_at DESC, id DESC);
+
+-- Supersedes idx_audit_log_guild (guild_id, created_at DESC).
+CREATE INDEX IF NOT EXISTS idx_audit_log_guild_created
+    ON audit_log (guild_id, created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_audit_log_guild;
+
+-- Emoji are paged by name, which the UNIQUE (guild_id, name) index covers.
@@ -0,0 +1,3 @@
+-- Rollback migration 115: Background job run history
+
+DROP TABLE IF EXISTS job_runs;
@@ -0,0 +1,20 @@
+-- Migration 115: Background job run history
+-- Every run of a background job, scheduled or triggered by an admin, is
+-- recorded here so failures can be inspected after the fact. The worker
+-- prunes successful runs after a day and failed runs after a week.
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id          TEXT PRIMARY KEY,
+    job         TEXT NOT NULL,
+    trigger     TEXT NOT NULL CHECK (trigger IN ('schedule', 'manual')),
+    status      TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed')),
+    attempts    INTEGER NOT NULL DEFAULT 0,
+    error       TEXT,
+    node        TEXT NOT NULL DEFAULT '',
+    started_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_started ON job_runs (started_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_started ON job_runs (job, started_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_job_runs_failed ON job_runs (started_at DESC, id DESC) WHERE status = 'failed';
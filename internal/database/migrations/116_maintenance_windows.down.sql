@@ -0,0 +1,3 @@
+-- Rollback migration 116: Scheduled maintenance windows
+
+DROP TABLE IF EXISTS maintenance_windows;
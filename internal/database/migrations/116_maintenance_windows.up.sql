@@ -0,0 +1,22 @@
+-- Migration 116: Scheduled maintenance windows
+-- Admins schedule maintenance ahead of time so clients can warn users. A
+-- read-only window makes the API reject writes with 503 until it ends. The
+-- started/ended timestamps record when the worker announced each edge.
+
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id             TEXT PRIMARY KEY,
+    title          TEXT NOT NULL,
+    message        TEXT NOT NULL DEFAULT '',
+    starts_at      TIMESTAMPTZ NOT NULL,
+    ends_at        TIMESTAMPTZ NOT NULL,
+    read_only      BOOLEAN NOT NULL DEFAULT false,
+    created_by     TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    cancelled_at   TIMESTAMPTZ,
+    started_at     TIMESTAMPTZ,
+    ended_at       TIMESTAMPTZ,
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_windows_ends ON maintenance_windows (ends_at)
+    WHERE cancelled_at IS NULL;
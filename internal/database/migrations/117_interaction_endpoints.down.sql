@@ -0,0 +1,3 @@
+-- Rollback migration 117: Interaction endpoints
+
+DROP TABLE IF EXISTS bot_interaction_endpoints;
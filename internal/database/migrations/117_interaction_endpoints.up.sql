@@ -0,0 +1,10 @@
+-- Migration 117: Interaction endpoints
+-- An app may receive its interactions as signed HTTP POSTs instead of, or as
+-- well as, over the gateway. The URL is only stored once it has answered a
+-- signed ping and refused a badly signed one.
+
+CREATE TABLE IF NOT EXISTS bot_interaction_endpoints (
+    bot_id      TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    url         TEXT NOT NULL,
+    verified_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
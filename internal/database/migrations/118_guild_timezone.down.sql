@@ -0,0 +1,3 @@
+-- Rollback migration 118: Guild timezone
+
+ALTER TABLE guilds DROP COLUMN IF EXISTS timezone;
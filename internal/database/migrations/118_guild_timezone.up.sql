@@ -0,0 +1,7 @@
+-- Migration 118: Guild timezone
+-- Guilds pick an IANA timezone alongside preferred_locale. Together they
+-- decide how times are written in text the server produces for the guild:
+-- system messages, event reminders and emails sent on its behalf. Clients
+-- still render message timestamps in each reader's own zone.
+
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,3 @@
+-- Rollback migration 119: API usage rollups
+
+DROP TABLE IF EXISTS api_usage_daily;
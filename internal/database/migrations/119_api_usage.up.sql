@@ -0,0 +1,17 @@
+-- Migration 119: API usage rollups
+-- Authenticated API requests are counted per user and per bot token and
+-- written here as daily totals. Session requests have an empty token_id.
+-- Bot developers read their own rows; admins rank callers to find abusive
+-- integrations. Rows older than 90 days are pruned by a nightly job.
+
+CREATE TABLE IF NOT EXISTS api_usage_daily (
+    day          DATE NOT NULL,
+    user_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_id     TEXT NOT NULL DEFAULT '',
+    requests     BIGINT NOT NULL DEFAULT 0,
+    errors       BIGINT NOT NULL DEFAULT 0,
+    rate_limited BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, user_id, token_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_usage_daily_user ON api_usage_daily(user_id, day);
@@ -0,0 +1,7 @@
+-- Rollback migration 120: Soft-deleted messages
+
+DELETE FROM messages WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_messages_deleted;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE guilds DROP COLUMN IF EXISTS message_restore_days;
@@ -0,0 +1,14 @@
+-- Migration 120: Soft-deleted messages
+-- A guild may keep deleted messages for up to 30 days. Within that window a
+-- deleted message carries the deleted flag (1 << 5) and is hidden from
+-- everyone but members with MANAGE_MESSAGES, who can restore it. A worker
+-- purges it once the window passes. A window of 0 deletes immediately.
+
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS message_restore_days INT NOT NULL DEFAULT 0
+    CHECK (message_restore_days BETWEEN 0 AND 30);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_by TEXT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_deleted ON messages(channel_id, deleted_at)
+    WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Rollback migration 121: Duplicate message handling
+
+ALTER TABLE messages DROP COLUMN IF EXISTS repeat_count;
+DROP TABLE IF EXISTS channel_duplicate_policy;
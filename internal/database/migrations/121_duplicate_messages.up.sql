@@ -0,0 +1,13 @@
+-- Migration 121: Duplicate message handling
+-- A channel can catch a member sending the same text twice in a row within
+-- window_seconds. In collapse mode the repeat bumps repeat_count on the
+-- earlier message instead of posting again; in reject mode it is refused.
+
+CREATE TABLE IF NOT EXISTS channel_duplicate_policy (
+    channel_id     TEXT PRIMARY KEY REFERENCES channels(id) ON DELETE CASCADE,
+    mode           TEXT NOT NULL DEFAULT 'off' CHECK (mode IN ('off', 'collapse', 'reject')),
+    window_seconds INT NOT NULL DEFAULT 300 CHECK (window_seconds BETWEEN 10 AND 86400),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS repeat_count INT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Rollback migration 122: Guild broadcast DMs
+
+DROP TABLE IF EXISTS guild_broadcast_deliveries;
+DROP TABLE IF EXISTS guild_broadcast_subscriptions;
+DROP TABLE IF EXISTS guild_broadcasts;
@@ -0,0 +1,42 @@
+-- Migration 122: Guild broadcast DMs
+-- A guild owner can send an announcement to the DMs of members who opted in.
+-- Recipients are fixed when the broadcast is created; the broadcast worker
+-- delivers them a few at a time under the instance's broadcast_limits.
+
+CREATE TABLE IF NOT EXISTS guild_broadcasts (
+    id              TEXT PRIMARY KEY,
+    guild_id        TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    author_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content         TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'pending'
+                    CHECK (status IN ('pending', 'sending', 'completed', 'cancelled')),
+    recipient_count INT NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at      TIMESTAMPTZ,
+    completed_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_guild_broadcasts_guild ON guild_broadcasts(guild_id, created_at DESC);
+
+-- A member's choice for a guild. Members without a row are not subscribed;
+-- subscribed = false records an explicit opt-out.
+CREATE TABLE IF NOT EXISTS guild_broadcast_subscriptions (
+    guild_id   TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    subscribed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS guild_broadcast_deliveries (
+    broadcast_id TEXT NOT NULL REFERENCES guild_broadcasts(id) ON DELETE CASCADE,
+    user_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status       TEXT NOT NULL DEFAULT 'pending'
+                 CHECK (status IN ('pending', 'delivered', 'skipped', 'failed')),
+    message_id   TEXT,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (broadcast_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_guild_broadcast_deliveries_pending
+    ON guild_broadcast_deliveries(broadcast_id) WHERE status = 'pending';
@@ -0,0 +1,3 @@
+-- Rollback migration 123: User content settings
+
+DROP TABLE IF EXISTS user_content_settings;
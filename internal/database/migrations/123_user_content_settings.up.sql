@@ -0,0 +1,13 @@
+-- Migration 123: User content settings
+-- NSFW filters enforced by the server. Users without a row see no NSFW
+-- channels, get no NSFW media and search safely. show_nsfw can only be
+-- turned on after the user confirms their age, recorded in age_confirmed_at.
+
+CREATE TABLE IF NOT EXISTS user_content_settings (
+    user_id          TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    show_nsfw        BOOLEAN NOT NULL DEFAULT false,
+    nsfw_media       TEXT NOT NULL DEFAULT 'blur' CHECK (nsfw_media IN ('show', 'blur', 'hide')),
+    safe_search      BOOLEAN NOT NULL DEFAULT true,
+    age_confirmed_at TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
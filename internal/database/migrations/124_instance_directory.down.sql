@@ -0,0 +1,3 @@
+-- Rollback migration 124: Federated instance directory
+
+DROP TABLE IF EXISTS instance_directory;
@@ -0,0 +1,17 @@
+-- Migration 124: Federated instance directory
+-- Instances that opt in (the directory_listed instance setting) publish an
+-- entry at /federation/v1/directory. The directory refresher fetches the
+-- entries of peers and of the instances they know about and keeps them here
+-- for admins to browse and for recommending remote guilds.
+
+CREATE TABLE IF NOT EXISTS instance_directory (
+    domain           TEXT PRIMARY KEY,
+    instance_id      TEXT NOT NULL,
+    name             TEXT,
+    description      TEXT,
+    user_count       INT NOT NULL DEFAULT 0,
+    federation_mode  TEXT NOT NULL,
+    software_version TEXT,
+    featured_guilds  JSONB NOT NULL DEFAULT '[]',
+    fetched_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,4 @@
+-- Rollback migration 125: Peer trust levels
+
+DROP TABLE IF EXISTS federation_dm_requests;
+ALTER TABLE federation_peers DROP COLUMN IF EXISTS trust_level;
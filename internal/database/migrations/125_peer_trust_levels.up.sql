@@ -0,0 +1,22 @@
+-- Migration 125: Peer trust levels
+-- Each federation peer gets a trust level. Restricted peers have their media
+-- proxied without caching, their users cannot create invites, their events
+-- get stricter AutoMod and their DMs to local users wait in
+-- federation_dm_requests until a recipient approves them.
+
+ALTER TABLE federation_peers ADD COLUMN IF NOT EXISTS trust_level TEXT NOT NULL DEFAULT 'normal'
+    CHECK (trust_level IN ('trusted', 'normal', 'restricted'));
+
+CREATE TABLE IF NOT EXISTS federation_dm_requests (
+    id                 TEXT PRIMARY KEY,
+    remote_instance_id TEXT NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    remote_channel_id  TEXT NOT NULL,
+    requester_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    payload            JSONB NOT NULL,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (remote_instance_id, remote_channel_id, recipient_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_federation_dm_requests_recipient
+    ON federation_dm_requests (recipient_id, created_at DESC);
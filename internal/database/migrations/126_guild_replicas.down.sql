@@ -0,0 +1,8 @@
+-- Rollback migration 126: Guild replicas
+
+DROP TABLE IF EXISTS federated_replica_messages;
+DROP TABLE IF EXISTS federated_replica_members;
+DROP TABLE IF EXISTS federated_replica_channels;
+DROP TABLE IF EXISTS federated_guild_replicas;
+DROP TABLE IF EXISTS guild_replica_log;
+DROP TABLE IF EXISTS guild_replica_seq;
@@ -0,0 +1,61 @@
+-- Migration 126: Guild replicas
+-- A guild's home instance numbers the events it federates (channels,
+-- members, messages) per guild in guild_replica_log, so peers can detect
+-- gaps and fetch what they missed. Peers keep a local replica of the remote
+-- guilds their users browse (member list and recent messages) and serve
+-- reads from it instead of proxying every read to the home instance.
+
+CREATE TABLE IF NOT EXISTS guild_replica_seq (
+    guild_id TEXT PRIMARY KEY REFERENCES guilds(id) ON DELETE CASCADE,
+    seq      BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS guild_replica_log (
+    guild_id   TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    seq        BIGINT NOT NULL,
+    event_type TEXT NOT NULL,
+    channel_id TEXT,
+    data       JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, seq)
+);
+
+CREATE INDEX IF NOT EXISTS idx_guild_replica_log_created
+    ON guild_replica_log (created_at);
+
+CREATE TABLE IF NOT EXISTS federated_guild_replicas (
+    guild_id    TEXT PRIMARY KEY REFERENCES guilds(id) ON DELETE CASCADE,
+    instance_id TEXT NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    last_seq    BIGINT NOT NULL DEFAULT 0,
+    status      TEXT NOT NULL DEFAULT 'syncing'
+        CHECK (status IN ('syncing', 'ready', 'stale')),
+    synced_at   TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS federated_replica_channels (
+    channel_id TEXT PRIMARY KEY,
+    guild_id   TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    complete   BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS federated_replica_members (
+    guild_id        TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL,
+    username        TEXT NOT NULL,
+    display_name    TEXT,
+    avatar_id       TEXT,
+    instance_domain TEXT NOT NULL DEFAULT '',
+    role_ids        TEXT[] NOT NULL DEFAULT '{}',
+    joined_at       TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (guild_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS federated_replica_messages (
+    id         TEXT PRIMARY KEY,
+    guild_id   TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    channel_id TEXT NOT NULL,
+    data       JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_federated_replica_messages_channel
+    ON federated_replica_messages (channel_id, id DESC);
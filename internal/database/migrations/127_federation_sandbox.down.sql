@@ -0,0 +1,4 @@
+-- Rollback migration 127: Federation sandbox
+
+DROP TABLE IF EXISTS federation_sandbox_events;
+ALTER TABLE federation_peers DROP COLUMN IF EXISTS sandbox;
@@ -0,0 +1,22 @@
+-- Migration 127: Federation sandbox
+-- In dry-run mode (instance setting federation_dry_run) outbound federation
+-- events are signed and recorded here instead of being delivered. Events
+-- from peers marked sandbox are verified and recorded here instead of being
+-- applied, so operators can test config and compatibility before going live.
+
+ALTER TABLE federation_peers ADD COLUMN IF NOT EXISTS sandbox BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS federation_sandbox_events (
+    id         TEXT PRIMARY KEY,
+    direction  TEXT NOT NULL CHECK (direction IN ('outbound', 'inbound')),
+    peer_id    TEXT REFERENCES instances(id) ON DELETE CASCADE,
+    domain     TEXT NOT NULL DEFAULT '',
+    event_type TEXT NOT NULL,
+    guild_id   TEXT,
+    channel_id TEXT,
+    payload    JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_federation_sandbox_events_created
+    ON federation_sandbox_events (created_at DESC);
@@ -0,0 +1,3 @@
+-- Rollback migration 128: Voice room regions
+
+DROP TABLE IF EXISTS voice_room_regions;
@@ -0,0 +1,10 @@
+-- Migration 128: Voice room regions
+-- In a multi-region deployment each voice room lives on one regional LiveKit
+-- server. The placement is shared by every API node so all participants of
+-- a room are sent to the same server. Rooms without a row use the primary.
+
+CREATE TABLE IF NOT EXISTS voice_room_regions (
+    room      TEXT PRIMARY KEY,
+    region    TEXT NOT NULL,
+    placed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+-- Rollback migration 129: Embed image cache
+
+DROP TABLE IF EXISTS embed_images;
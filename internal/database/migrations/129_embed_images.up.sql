@@ -0,0 +1,19 @@
+-- Migration 129: Embed image cache
+-- Link preview images re-hosted in storage so embeds do not hotlink the
+-- sites they describe. Rows are shared by every embed of the same source
+-- image and removed, with their objects, once expires_at passes.
+
+CREATE TABLE IF NOT EXISTS embed_images (
+    id           TEXT PRIMARY KEY,
+    source_url   TEXT NOT NULL UNIQUE,
+    content_type TEXT NOT NULL,
+    size_bytes   BIGINT NOT NULL,
+    width        INTEGER,
+    height       INTEGER,
+    s3_bucket    TEXT NOT NULL,
+    s3_key       TEXT NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_embed_images_expires ON embed_images (expires_at);
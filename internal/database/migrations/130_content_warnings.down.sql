@@ -0,0 +1,4 @@
+-- Rollback migration 130: Content warnings and spoilered attachments
+
+ALTER TABLE attachments DROP COLUMN IF EXISTS spoiler;
+DROP TABLE IF EXISTS message_content_warnings;
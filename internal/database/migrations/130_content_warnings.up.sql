@@ -0,0 +1,12 @@
+-- Migration 130: Content warnings and spoilered attachments
+-- A message may carry a short content warning that clients show in place of
+-- its content until revealed. Spoilered attachments are served blurred by
+-- default; every attachment of a message with a content warning is one.
+
+CREATE TABLE IF NOT EXISTS message_content_warnings (
+    message_id TEXT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    reason     TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS spoiler BOOLEAN NOT NULL DEFAULT false;
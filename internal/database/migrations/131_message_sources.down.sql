@@ -0,0 +1,5 @@
+-- Rollback migration 131: Message source rollups and per-source rate limits
+
+DROP TABLE IF EXISTS channel_source_rate_limits;
+DROP TABLE IF EXISTS message_source_daily;
+ALTER TABLE messages DROP COLUMN IF EXISTS webhook_id;
@@ -0,0 +1,33 @@
+-- Migration 131: Message source rollups and per-source rate limits
+-- Messages are counted per UTC day, guild, channel and source: human, bot,
+-- webhook or bridge. source_id names the integration: the webhook ID, the
+-- bot user ID, or for bridge messages the bridge name or relaying bot.
+-- Human rows have an empty source_id. A periodic job recomputes today and
+-- yesterday from messages; rows older than 90 days are pruned nightly.
+-- webhook_id on messages lets the rollup attribute webhook messages, whose
+-- author_id is NULL.
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS webhook_id TEXT REFERENCES webhooks(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS message_source_daily (
+    day        DATE NOT NULL,
+    guild_id   TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    channel_id TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    source     TEXT NOT NULL CHECK (source IN ('human', 'bot', 'webhook', 'bridge')),
+    source_id  TEXT NOT NULL DEFAULT '',
+    messages   BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, guild_id, channel_id, source, source_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_source_daily_guild ON message_source_daily(guild_id, day);
+
+-- Caps on how many messages one source may post in a channel within a
+-- sliding window, shared by every author of that source.
+CREATE TABLE IF NOT EXISTS channel_source_rate_limits (
+    channel_id     TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    source         TEXT NOT NULL CHECK (source IN ('human', 'bot', 'webhook', 'bridge')),
+    max_messages   INT NOT NULL,
+    window_seconds INT NOT NULL,
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (channel_id, source)
+);
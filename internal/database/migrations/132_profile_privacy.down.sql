@@ -0,0 +1,3 @@
+-- Rollback migration 132: Profile privacy for federated lookups
+
+DROP TABLE IF EXISTS user_profile_privacy;
@@ -0,0 +1,16 @@
+-- Migration 132: Profile privacy for federated lookups
+-- Which optional profile fields remote instances receive from user lookups
+-- and profile fetches. 'everyone' shares the field with remote instances;
+-- 'local' keeps it to members of this instance. Users without a row share
+-- pronouns and banner, and keep bio, connections (profile links) and
+-- activity local.
+
+CREATE TABLE IF NOT EXISTS user_profile_privacy (
+    user_id     TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    bio         TEXT NOT NULL DEFAULT 'local' CHECK (bio IN ('everyone', 'local')),
+    pronouns    TEXT NOT NULL DEFAULT 'everyone' CHECK (pronouns IN ('everyone', 'local')),
+    banner      TEXT NOT NULL DEFAULT 'everyone' CHECK (banner IN ('everyone', 'local')),
+    connections TEXT NOT NULL DEFAULT 'local' CHECK (connections IN ('everyone', 'local')),
+    activity    TEXT NOT NULL DEFAULT 'local' CHECK (activity IN ('everyone', 'local')),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
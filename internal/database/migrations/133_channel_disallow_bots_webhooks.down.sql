@@ -0,0 +1,4 @@
+-- Rollback migration 133: Per-channel bot and webhook posting toggles
+
+ALTER TABLE channels DROP COLUMN IF EXISTS disallow_webhook_posts;
+ALTER TABLE channels DROP COLUMN IF EXISTS disallow_bot_posts;
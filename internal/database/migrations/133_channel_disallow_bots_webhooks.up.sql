@@ -0,0 +1,7 @@
+-- Migration 133: Per-channel bot and webhook posting toggles
+-- Lets moderators keep a channel human-only. Bots are refused when posting
+-- messages and webhooks when executed, whatever their permissions; humans
+-- still need SEND_MESSAGES as before.
+
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS disallow_bot_posts BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS disallow_webhook_posts BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,4 @@
+-- Rollback migration 134: Dead-link cleanup
+
+DROP INDEX IF EXISTS idx_attachments_unattached;
+DROP TABLE IF EXISTS cleanup_stats;
@@ -0,0 +1,15 @@
+-- Migration 134: Dead-link cleanup
+-- Running totals for the periodic cleanup of expired or used-up invites,
+-- channel follows whose webhook can no longer post, and uploads that were
+-- never attached to a message. One row per kind, shown in the admin stats.
+
+CREATE TABLE IF NOT EXISTS cleanup_stats (
+    kind            TEXT PRIMARY KEY,
+    last_removed    BIGINT NOT NULL DEFAULT 0,
+    total_removed   BIGINT NOT NULL DEFAULT 0,
+    bytes_reclaimed BIGINT NOT NULL DEFAULT 0,
+    last_run_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_unattached
+    ON attachments (created_at) WHERE message_id IS NULL;
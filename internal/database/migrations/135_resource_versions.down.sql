@@ -0,0 +1,6 @@
+-- Rollback migration 135: Resource versions
+
+ALTER TABLE user_settings DROP COLUMN IF EXISTS version;
+ALTER TABLE roles DROP COLUMN IF EXISTS version;
+ALTER TABLE channels DROP COLUMN IF EXISTS version;
+ALTER TABLE guilds DROP COLUMN IF EXISTS version;
@@ -0,0 +1,11 @@
+-- Migration 135: Resource versions
+-- Edit counters for guilds, channels, roles and user settings. Each PATCH to
+-- one of them increments its version; a PATCH that names an older version
+-- is refused so two people editing at once cannot overwrite each other
+-- unnoticed. Other writes, such as member counts or position reorders,
+-- leave the version alone.
+
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+-- Rollback migration 136: Guild permission presets
+
+DROP TABLE IF EXISTS guild_permission_presets;
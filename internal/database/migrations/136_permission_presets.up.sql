@@ -0,0 +1,16 @@
+-- Migration 136: Guild permission presets
+-- Named allow/deny permission sets a guild saves for reuse when creating
+-- roles, alongside the built-in presets (Moderator, Helper, Muted, Bot)
+-- defined in code.
+
+CREATE TABLE IF NOT EXISTS guild_permission_presets (
+    id                TEXT PRIMARY KEY,
+    guild_id          TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    name              TEXT NOT NULL,
+    description       TEXT,
+    permissions_allow BIGINT NOT NULL DEFAULT 0,
+    permissions_deny  BIGINT NOT NULL DEFAULT 0,
+    created_by        TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (guild_id, name)
+);
@@ -0,0 +1,4 @@
+-- Rollback migration 137: Timeout role
+
+DROP INDEX IF EXISTS idx_guilds_timeout_role;
+ALTER TABLE guilds DROP COLUMN IF EXISTS timeout_role_id;
@@ -0,0 +1,11 @@
+-- Migration 137: Timeout role
+-- A guild may back timeouts with a server-managed "Timed Out" role. The role
+-- carries a channel override denying the timeout permissions on every
+-- channel, and members hold it for as long as their timeout lasts, so bots
+-- and federated instances that only read roles and overrides still see the
+-- restriction. A NULL timeout_role_id means the option is off.
+
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS timeout_role_id TEXT REFERENCES roles(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_guilds_timeout_role ON guilds(timeout_role_id)
+    WHERE timeout_role_id IS NOT NULL;
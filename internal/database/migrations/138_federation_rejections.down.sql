@@ -0,0 +1,3 @@
+-- Rollback migration 138: Federation rejections
+
+DROP TABLE IF EXISTS federation_rejections;
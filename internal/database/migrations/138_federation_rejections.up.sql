@@ -0,0 +1,21 @@
+-- Migration 138: Federation rejections
+-- Inbound federation requests refused because the signature did not verify,
+-- the sender instance is unknown, or the timestamp fell outside the allowed
+-- clock skew. Kept for the backfill window so operators can tell why a peer's
+-- traffic is being dropped. sender_id is whatever the request claimed and may
+-- not match any instance.
+
+CREATE TABLE IF NOT EXISTS federation_rejections (
+    id          TEXT PRIMARY KEY,
+    sender_id   TEXT NOT NULL DEFAULT '',
+    remote_addr TEXT NOT NULL DEFAULT '',
+    endpoint    TEXT NOT NULL,
+    reason      TEXT NOT NULL CHECK (reason IN ('bad_signature', 'unknown_peer', 'clock_skew')),
+    detail      TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_federation_rejections_created
+    ON federation_rejections (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_federation_rejections_sender
+    ON federation_rejections (sender_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Rollback migration 139: Admin action tokens
+
+DROP TABLE IF EXISTS admin_action_tokens;
@@ -0,0 +1,17 @@
+-- Migration 139: Admin action tokens
+-- Short-lived tokens that let `amityvox admin` run against a remote instance
+-- over the API when the CLI host cannot reach the database. Each token is
+-- bound to the admin actions it was minted for and expires within a day.
+-- Only the SHA-256 of the token is stored.
+
+CREATE TABLE IF NOT EXISTS admin_action_tokens (
+    id           TEXT PRIMARY KEY,
+    token_hash   TEXT NOT NULL UNIQUE,
+    actions      TEXT[] NOT NULL,
+    created_by   TEXT REFERENCES users(id) ON DELETE CASCADE,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_action_tokens_expires ON admin_action_tokens(expires_at);
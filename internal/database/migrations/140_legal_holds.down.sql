@@ -0,0 +1,7 @@
+-- Rollback migration 140: Instance retention and legal holds
+
+DROP INDEX IF EXISTS idx_users_deleted_unpurged;
+ALTER TABLE users DROP COLUMN IF EXISTS data_purged_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+DROP TABLE IF EXISTS legal_holds;
+DELETE FROM instance_settings WHERE key IN ('dm_retention_days', 'deleted_account_retention_days');
@@ -0,0 +1,29 @@
+-- Migration 140: Instance retention and legal holds
+-- Legal holds exempt a user or guild from every message purge (retention
+-- policies, the soft-delete restore window, instance DM and deleted-account
+-- retention) and freeze their data exports until released. Released holds
+-- are kept for the record. The users columns let deleted-account retention
+-- count from the deletion and skip accounts already purged; accounts deleted
+-- before this migration start their window now.
+
+CREATE TABLE IF NOT EXISTS legal_holds (
+    id          TEXT PRIMARY KEY,
+    target_type TEXT NOT NULL CHECK (target_type IN ('user', 'guild')),
+    target_id   TEXT NOT NULL,
+    reason      TEXT NOT NULL,
+    created_by  TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    released_by TEXT,
+    released_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_legal_holds_active
+    ON legal_holds (target_type, target_id) WHERE released_at IS NULL;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS data_purged_at TIMESTAMPTZ;
+
+UPDATE users SET deleted_at = now() WHERE flags & 2 <> 0 AND deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_unpurged
+    ON users (deleted_at) WHERE deleted_at IS NOT NULL AND data_purged_at IS NULL;
@@ -0,0 +1,7 @@
+-- Rollback migration 141: Channel transcript links
+
+DROP TABLE IF EXISTS channel_transcript_accesses;
+DROP TABLE IF EXISTS channel_transcript_links;
+UPDATE roles SET permissions_allow = permissions_allow & ~(1::bigint << 41);
+UPDATE roles SET permissions_deny = permissions_deny & ~(1::bigint << 41);
+UPDATE guilds SET default_permissions = default_permissions & ~(1::bigint << 41);
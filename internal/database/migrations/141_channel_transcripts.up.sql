@@ -0,0 +1,43 @@
+-- Migration 141: Channel transcript links
+-- Exporting a channel now takes the channel-scoped EXPORT_MESSAGES permission
+-- (bit 41), so it can be granted or denied per channel through overrides.
+-- Roles that could export before, through MANAGE_CHANNELS, are given it.
+--
+-- Transcript links share a channel's messages up to the moment the link was
+-- made, either with guild members (DM participants for DMs) or with anyone
+-- holding the link, until they expire or are revoked. Every view is logged.
+
+UPDATE roles SET permissions_allow = permissions_allow | (1::bigint << 41)
+WHERE permissions_allow & 1 <> 0;
+UPDATE guilds SET default_permissions = default_permissions | (1::bigint << 41)
+WHERE default_permissions & 1 <> 0;
+
+CREATE TABLE IF NOT EXISTS channel_transcript_links (
+    id               TEXT PRIMARY KEY,
+    code             TEXT NOT NULL UNIQUE,
+    channel_id       TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    created_by       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    visibility       TEXT NOT NULL CHECK (visibility IN ('members', 'link')),
+    snapshot_at      TIMESTAMPTZ NOT NULL,
+    expires_at       TIMESTAMPTZ NOT NULL,
+    revoked_at       TIMESTAMPTZ,
+    access_count     INTEGER NOT NULL DEFAULT 0,
+    last_accessed_at TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_transcript_links_channel
+    ON channel_transcript_links (channel_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_channel_transcript_links_expires
+    ON channel_transcript_links (expires_at);
+
+CREATE TABLE IF NOT EXISTS channel_transcript_accesses (
+    id          TEXT PRIMARY KEY,
+    link_id     TEXT NOT NULL REFERENCES channel_transcript_links(id) ON DELETE CASCADE,
+    viewer_id   TEXT,
+    remote_addr TEXT NOT NULL DEFAULT '',
+    accessed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_channel_transcript_accesses_link
+    ON channel_transcript_accesses (link_id, accessed_at DESC);
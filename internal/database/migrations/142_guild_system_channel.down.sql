@@ -0,0 +1,13 @@
+-- Rollback migration 142: Guild system channel
+
+DELETE FROM messages WHERE message_type IN ('system_boost', 'system_event_reminder');
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check
+    CHECK (message_type IN ('default', 'system_join', 'system_leave', 'system_kick',
+           'system_ban', 'system_pin', 'reply', 'thread_created', 'voice', 'poll',
+           'forward', 'scheduled', 'system_lockdown', 'system_missed_call'));
+
+ALTER TABLE guilds DROP COLUMN IF EXISTS system_event_reminders;
+ALTER TABLE guilds DROP COLUMN IF EXISTS system_boost_messages;
+ALTER TABLE guilds DROP COLUMN IF EXISTS system_join_messages;
+ALTER TABLE guilds DROP COLUMN IF EXISTS system_channel_id;
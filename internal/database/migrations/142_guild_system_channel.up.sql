@@ -0,0 +1,15 @@
+-- Migration 142: Guild system channel
+-- One channel receives the messages the server writes on a guild's behalf,
+-- with a switch per kind. Existing guilds start with no system channel.
+
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS system_channel_id TEXT REFERENCES channels(id) ON DELETE SET NULL;
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS system_join_messages BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS system_boost_messages BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS system_event_reminders BOOLEAN NOT NULL DEFAULT true;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check
+    CHECK (message_type IN ('default', 'system_join', 'system_leave', 'system_kick',
+           'system_ban', 'system_pin', 'reply', 'thread_created', 'voice', 'poll',
+           'forward', 'scheduled', 'system_lockdown', 'system_missed_call',
+           'system_boost', 'system_event_reminder'));
@@ -0,0 +1,8 @@
+-- Rollback migration 143: Instance AutoMod policy
+
+DELETE FROM automod_actions WHERE instance_rule_id IS NOT NULL;
+ALTER TABLE automod_actions DROP CONSTRAINT IF EXISTS automod_actions_rule_check;
+ALTER TABLE automod_actions DROP COLUMN IF EXISTS instance_rule_id;
+ALTER TABLE automod_actions ALTER COLUMN rule_id SET NOT NULL;
+
+DROP TABLE IF EXISTS instance_automod_rules;
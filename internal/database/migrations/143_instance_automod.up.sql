@@ -0,0 +1,26 @@
+-- Migration 143: Instance AutoMod policy
+-- Baseline AutoMod rules set by instance admins. They apply to every guild
+-- ahead of the guild's own rules, and guild exemptions do not reach them.
+
+CREATE TABLE instance_automod_rules (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    enabled     BOOLEAN NOT NULL DEFAULT true,
+    rule_type   TEXT NOT NULL,
+    config      JSONB NOT NULL DEFAULT '{}',
+    action      TEXT NOT NULL DEFAULT 'delete'
+        CHECK (action IN ('delete','warn','timeout','log','quarantine')),
+    timeout_duration_seconds INTEGER NOT NULL DEFAULT 60,
+    created_by  TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_instance_automod_rules_enabled ON instance_automod_rules(created_at) WHERE enabled;
+
+-- Actions taken by an instance rule point at it instead of a guild rule.
+ALTER TABLE automod_actions ALTER COLUMN rule_id DROP NOT NULL;
+ALTER TABLE automod_actions
+    ADD COLUMN instance_rule_id TEXT REFERENCES instance_automod_rules(id) ON DELETE CASCADE;
+ALTER TABLE automod_actions ADD CONSTRAINT automod_actions_rule_check
+    CHECK (num_nonnulls(rule_id, instance_rule_id) = 1);
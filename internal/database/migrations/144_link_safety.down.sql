@@ -0,0 +1,4 @@
+-- Rollback migration 144: Link safety
+
+DROP TABLE IF EXISTS message_link_flags;
+DROP TABLE IF EXISTS link_blocklist;
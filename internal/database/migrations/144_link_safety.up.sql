@@ -0,0 +1,26 @@
+-- Migration 144: Link safety
+-- Domains instance admins have blocked outright, and the messages whose
+-- links were found malicious, queued for the guild's moderators.
+
+CREATE TABLE link_blocklist (
+    domain      TEXT PRIMARY KEY,
+    reason      TEXT,
+    created_by  TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE message_link_flags (
+    id          TEXT PRIMARY KEY,
+    guild_id    TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    channel_id  TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    message_id  TEXT NOT NULL UNIQUE REFERENCES messages(id) ON DELETE CASCADE,
+    author_id   TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    verdicts    JSONB NOT NULL DEFAULT '[]',
+    status      TEXT NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'resolved', 'dismissed')),
+    resolved_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_message_link_flags_guild ON message_link_flags(guild_id, status, created_at DESC, id DESC);
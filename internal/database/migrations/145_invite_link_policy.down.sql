@@ -0,0 +1,4 @@
+-- Rollback migration 145: Invite link policy
+
+ALTER TABLE channels DROP COLUMN IF EXISTS invite_links_exempt;
+ALTER TABLE guilds DROP COLUMN IF EXISTS invite_link_policy;
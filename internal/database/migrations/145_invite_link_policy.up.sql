@@ -0,0 +1,7 @@
+-- Migration 145: Invite link policy
+-- What happens to messages linking to other guilds' invites, local,
+-- federated or Discord. Channels can be exempted, e.g. a partners channel.
+
+ALTER TABLE guilds ADD COLUMN IF NOT EXISTS invite_link_policy TEXT NOT NULL DEFAULT 'allow'
+    CHECK (invite_link_policy IN ('allow', 'strip', 'require_manage_guild'));
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS invite_links_exempt BOOLEAN NOT NULL DEFAULT false;
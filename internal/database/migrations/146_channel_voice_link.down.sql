@@ -0,0 +1,4 @@
+-- Rollback migration 146: Text channels tied to a voice channel
+
+ALTER TABLE channels DROP COLUMN IF EXISTS voice_participant_allow;
+ALTER TABLE channels DROP COLUMN IF EXISTS voice_channel_id;
@@ -0,0 +1,7 @@
+-- Migration 146: Text channels tied to a voice channel
+-- A text channel can be tied to a voice room. Members connected to the room
+-- skip the text channel's slowmode and gain voice_participant_allow there.
+-- Voice and stage channels are tied to their own chat.
+
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS voice_channel_id TEXT REFERENCES channels(id) ON DELETE SET NULL;
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS voice_participant_allow BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Rollback migration 147: Text-in-voice chat
+
+DROP TABLE IF EXISTS voice_chat_participants;
+ALTER TABLE channels DROP COLUMN IF EXISTS voice_chat_history;
+ALTER TABLE channels DROP COLUMN IF EXISTS voice_chat_recent_minutes;
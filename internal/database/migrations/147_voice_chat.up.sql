@@ -0,0 +1,19 @@
+-- Migration 147: Text-in-voice chat
+-- Voice and stage channels carry their own chat, visible to members who are
+-- connected or left within voice_chat_recent_minutes. voice_chat_history
+-- 'session' clears the chat once the room empties.
+
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS voice_chat_recent_minutes INTEGER NOT NULL DEFAULT 60
+    CHECK (voice_chat_recent_minutes BETWEEN 0 AND 10080);
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS voice_chat_history TEXT NOT NULL DEFAULT 'keep'
+    CHECK (voice_chat_history IN ('keep', 'session'));
+
+CREATE TABLE IF NOT EXISTS voice_chat_participants (
+    channel_id TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    left_at    TIMESTAMPTZ, -- NULL while connected
+    PRIMARY KEY (channel_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_voice_chat_participants_connected
+    ON voice_chat_participants (channel_id) WHERE left_at IS NULL;
@@ -0,0 +1,4 @@
+-- Rollback migration 148: Role-gated channel preview
+
+ALTER TABLE channels DROP COLUMN IF EXISTS preview_role_id;
+ALTER TABLE channels DROP COLUMN IF EXISTS preview_enabled;
@@ -0,0 +1,6 @@
+-- Migration 148: Role-gated channel preview
+-- A preview channel stays listed to members who cannot view it, with its
+-- name, topic and the role that unlocks it, but its history stays closed.
+
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS preview_enabled BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS preview_role_id TEXT REFERENCES roles(id) ON DELETE SET NULL;
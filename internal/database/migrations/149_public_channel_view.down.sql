@@ -0,0 +1,4 @@
+-- Rollback migration 149: Public read-only channel view
+
+DROP INDEX IF EXISTS idx_channels_public_view;
+ALTER TABLE channels DROP COLUMN IF EXISTS public_view;
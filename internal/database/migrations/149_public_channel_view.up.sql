@@ -0,0 +1,7 @@
+-- Migration 149: Public read-only channel view
+-- Channels with public_view are served as read-only HTML without sign-in;
+-- their guild gets a public page listing them.
+
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS public_view BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_channels_public_view ON channels(guild_id) WHERE public_view;
@@ -0,0 +1,4 @@
+-- Rollback migration 150: DM delivery and read receipts
+
+ALTER TABLE users DROP COLUMN IF EXISTS read_receipts;
+DROP TABLE IF EXISTS dm_delivery_state;
@@ -0,0 +1,13 @@
+-- Migration 150: DM delivery and read receipts
+-- How far each participant of a DM or group DM has received messages, by a
+-- gateway session or a push. Read progress is read_state.last_read_id.
+-- Users who turn read receipts off neither send nor see read status.
+
+CREATE TABLE IF NOT EXISTS dm_delivery_state (
+    channel_id        TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    user_id           TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_delivered_id TEXT NOT NULL,
+    PRIMARY KEY (channel_id, user_id)
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS read_receipts BOOLEAN NOT NULL DEFAULT true;
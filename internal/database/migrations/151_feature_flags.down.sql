@@ -0,0 +1,4 @@
+-- Rollback migration 151: Feature flags
+
+DROP TABLE IF EXISTS feature_flag_overrides;
+DROP TABLE IF EXISTS feature_flags;
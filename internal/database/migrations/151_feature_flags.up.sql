@@ -0,0 +1,25 @@
+-- Migration 151: Feature flags
+-- Staged rollout of risky features. A flag is on for a user, guild or peer
+-- instance when an override says so, or when the target falls within the
+-- flag's rollout percentage for its kind. Switching a flag off disables it
+-- everywhere, overrides included.
+
+CREATE TABLE IF NOT EXISTS feature_flags (
+    key              TEXT PRIMARY KEY,
+    description      TEXT NOT NULL DEFAULT '',
+    enabled          BOOLEAN NOT NULL DEFAULT true,
+    user_percent     INT NOT NULL DEFAULT 0 CHECK (user_percent BETWEEN 0 AND 100),
+    guild_percent    INT NOT NULL DEFAULT 0 CHECK (guild_percent BETWEEN 0 AND 100),
+    instance_percent INT NOT NULL DEFAULT 0 CHECK (instance_percent BETWEEN 0 AND 100),
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS feature_flag_overrides (
+    flag_key    TEXT NOT NULL REFERENCES feature_flags(key) ON DELETE CASCADE,
+    target_type TEXT NOT NULL CHECK (target_type IN ('user', 'guild', 'instance')),
+    target_id   TEXT NOT NULL,
+    enabled     BOOLEAN NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (flag_key, target_type, target_id)
+);
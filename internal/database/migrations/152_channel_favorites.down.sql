@@ -0,0 +1,3 @@
+-- Rollback migration 152: Channel favorites
+
+DROP TABLE IF EXISTS user_channel_favorites;
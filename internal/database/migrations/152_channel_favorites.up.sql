@@ -0,0 +1,11 @@
+-- Migration 152: Channel favorites
+-- A user's favorite channels in their own order, synced across devices with
+-- their guild positions and folders (migrations 057 and 058).
+CREATE TABLE user_channel_favorites (
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    channel_id TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, channel_id)
+);
+CREATE INDEX idx_user_channel_favorites_user ON user_channel_favorites(user_id, position);
@@ -0,0 +1,11 @@
+-- Migration 153: Security alert system messages
+-- Posted to a guild's system channel when one of its webhooks is found
+-- leaked and its token is reset. Unlike the other system messages these
+-- cannot be turned off.
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check
+    CHECK (message_type IN ('default', 'system_join', 'system_leave', 'system_kick',
+           'system_ban', 'system_pin', 'reply', 'thread_created', 'voice', 'poll',
+           'forward', 'scheduled', 'system_lockdown', 'system_missed_call',
+           'system_boost', 'system_event_reminder', 'system_security_alert'));
@@ -0,0 +1,5 @@
+-- Rollback migration 154: Operator alert webhooks
+
+DROP INDEX IF EXISTS idx_automod_actions_created;
+DROP INDEX IF EXISTS idx_users_created_at;
+DROP TABLE IF EXISTS operator_alert_webhooks;
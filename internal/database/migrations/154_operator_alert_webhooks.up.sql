@@ -0,0 +1,25 @@
+-- Migration 154: Operator alert webhooks
+-- Outbound webhooks that tell the instance operators about operational
+-- problems (federation peers down, JetStream lag, storage errors,
+-- registration spikes, AutoMod surges) in Slack, Matrix or plain JSON form.
+CREATE TABLE operator_alert_webhooks (
+    id                TEXT PRIMARY KEY,
+    name              TEXT NOT NULL,
+    url               TEXT NOT NULL,
+    format            TEXT NOT NULL DEFAULT 'json'
+        CHECK (format IN ('json', 'slack', 'matrix')),
+    min_severity      TEXT NOT NULL DEFAULT 'warning'
+        CHECK (min_severity IN ('info', 'warning', 'critical')),
+    kinds             TEXT[] NOT NULL DEFAULT '{}',   -- empty means every kind
+    signing_secret    TEXT NOT NULL,
+    enabled           BOOLEAN NOT NULL DEFAULT true,
+    created_by        TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_delivered_at TIMESTAMPTZ,
+    last_status       INTEGER,
+    last_error        TEXT
+);
+
+-- The registration spike and AutoMod surge checks count recent rows.
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
+CREATE INDEX IF NOT EXISTS idx_automod_actions_created ON automod_actions(created_at);
@@ -0,0 +1,4 @@
+-- Rollback migration 155: Guild message edit policy
+
+DROP INDEX IF EXISTS idx_reports_message_status;
+DROP TABLE IF EXISTS guild_edit_policy;
@@ -0,0 +1,15 @@
+-- Migration 155: Guild message edit policy
+-- How long members may edit their messages, whether each edit is recorded in
+-- the audit log for moderators, and whether reported messages are locked
+-- against edits until the report is reviewed.
+CREATE TABLE guild_edit_policy (
+    guild_id            TEXT PRIMARY KEY REFERENCES guilds(id) ON DELETE CASCADE,
+    edit_window_minutes INTEGER NOT NULL DEFAULT 0
+        CHECK (edit_window_minutes BETWEEN 0 AND 10080),   -- 0 means no limit
+    snapshot_edits      BOOLEAN NOT NULL DEFAULT false,
+    lock_reported       BOOLEAN NOT NULL DEFAULT false,
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Pending reports are looked up per message when a locked guild's member edits.
+CREATE INDEX IF NOT EXISTS idx_reports_message_status ON message_reports(message_id, status);
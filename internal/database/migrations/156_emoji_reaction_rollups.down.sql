@@ -0,0 +1,4 @@
+-- Rollback migration 156: Weekly emoji reaction rollups
+
+DROP INDEX IF EXISTS idx_reactions_created;
+DROP TABLE IF EXISTS emoji_reaction_weekly;
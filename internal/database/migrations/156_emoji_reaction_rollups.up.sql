@@ -0,0 +1,19 @@
+-- Migration 156: Weekly emoji reaction rollups
+-- Reactions are counted per ISO week (starting Monday, UTC), guild and
+-- emoji, for the guild insights dashboard and the emoji picker's
+-- "frequently used in this server" section. A periodic job recomputes the
+-- current and previous week from reactions; rows older than a year are
+-- pruned nightly.
+
+CREATE TABLE IF NOT EXISTS emoji_reaction_weekly (
+    week      DATE NOT NULL,
+    guild_id  TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    emoji     TEXT NOT NULL,
+    reactions BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (week, guild_id, emoji)
+);
+
+CREATE INDEX IF NOT EXISTS idx_emoji_reaction_weekly_guild ON emoji_reaction_weekly(guild_id, week);
+
+-- The rollup reads reactions added since the start of last week.
+CREATE INDEX IF NOT EXISTS idx_reactions_created ON reactions(created_at);
@@ -0,0 +1,5 @@
+-- Rollback migration 157: Attachment thumbhash and image variants
+
+DROP INDEX IF EXISTS idx_attachments_variants_pending;
+ALTER TABLE attachments DROP COLUMN IF EXISTS variants;
+ALTER TABLE attachments DROP COLUMN IF EXISTS thumbhash;
@@ -0,0 +1,11 @@
+-- Migration 157: Attachment thumbhash and image variants
+-- Image attachments carry a thumbhash next to their blurhash, and a manifest
+-- of the resized renditions (width, format, URL) the image-variants worker
+-- has rendered for them. A NULL manifest means the worker has not processed
+-- the image yet; images it cannot render get an empty one.
+
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS thumbhash TEXT;
+ALTER TABLE attachments ADD COLUMN IF NOT EXISTS variants JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_attachments_variants_pending ON attachments(created_at)
+    WHERE variants IS NULL AND width IS NOT NULL AND content_type LIKE 'image/%';
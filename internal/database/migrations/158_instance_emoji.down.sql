@@ -0,0 +1,3 @@
+-- Rollback migration 158: Instance emoji
+
+DROP TABLE IF EXISTS instance_emoji;
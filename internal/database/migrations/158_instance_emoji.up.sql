@@ -0,0 +1,16 @@
+-- Migration 158: Instance emoji
+-- Emoji managed by instance admins and usable in every guild and DM. Each
+-- points at an uploaded image attachment, so peers can fetch it through the
+-- signed federation media endpoint like any other local file. Names are
+-- unique regardless of case.
+
+CREATE TABLE IF NOT EXISTS instance_emoji (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    file_id    TEXT NOT NULL REFERENCES attachments(id) ON DELETE CASCADE,
+    animated   BOOLEAN NOT NULL DEFAULT false,
+    creator_id TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_instance_emoji_name ON instance_emoji(lower(name));
@@ -0,0 +1,3 @@
+-- Rollback migration 159: Group DM invites
+
+DROP TABLE IF EXISTS group_dm_invites;
@@ -0,0 +1,16 @@
+-- Migration 159: Group DM invites
+-- Users choose who can add them to group DMs through the group_dm_privacy
+-- user setting. Anyone else adding them creates a pending invite here that
+-- the invitee accepts or declines.
+
+CREATE TABLE IF NOT EXISTS group_dm_invites (
+    id         TEXT PRIMARY KEY,
+    channel_id TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+    inviter_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_id  TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (channel_id, target_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_dm_invites_target
+    ON group_dm_invites (target_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Rollback migration 160: Bot presence subscriptions
+
+DROP TABLE IF EXISTS bot_presence_subscriptions;
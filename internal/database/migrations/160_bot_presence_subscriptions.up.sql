@@ -0,0 +1,17 @@
+-- Migration 160: Bot presence subscriptions
+-- A bot's owner names users whose presence the bot should receive even
+-- without a shared guild, e.g. for a status board. Each user consents before
+-- their presence reaches the bot, and can withdraw consent at any time.
+
+CREATE TABLE IF NOT EXISTS bot_presence_subscriptions (
+    bot_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status       TEXT NOT NULL DEFAULT 'pending'
+                 CHECK (status IN ('pending', 'granted')),
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    granted_at   TIMESTAMPTZ,
+    PRIMARY KEY (bot_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bot_presence_subscriptions_user
+    ON bot_presence_subscriptions (user_id, requested_at DESC);
@@ -0,0 +1,3 @@
+-- Rollback migration 161: Guild scheduled purges
+
+DROP TABLE IF EXISTS guild_scheduled_purges;
@@ -0,0 +1,27 @@
+-- Migration 161: Guild scheduled purges
+-- Cleanup tasks a guild schedules ahead of time, typically around an event:
+-- delete a temporary category and its channels, or take an event role away
+-- from every member. A background worker runs them when due and records the
+-- outcome in the guild audit log. Role purges may repeat, for communities
+-- that hold the same event every week or month.
+
+CREATE TABLE IF NOT EXISTS guild_scheduled_purges (
+    id            TEXT PRIMARY KEY,
+    guild_id      TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    kind          TEXT NOT NULL CHECK (kind IN ('category', 'role')),
+    target_id     TEXT NOT NULL,
+    run_at        TIMESTAMPTZ NOT NULL,
+    repeat_days   INTEGER CHECK (repeat_days IS NULL OR repeat_days BETWEEN 1 AND 365),
+    status        TEXT NOT NULL DEFAULT 'pending'
+                  CHECK (status IN ('pending', 'completed', 'failed')),
+    affected      INTEGER NOT NULL DEFAULT 0,
+    error         TEXT,
+    created_by    TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_run_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_guild_scheduled_purges_guild
+    ON guild_scheduled_purges (guild_id, run_at);
+CREATE INDEX IF NOT EXISTS idx_guild_scheduled_purges_due
+    ON guild_scheduled_purges (run_at) WHERE status = 'pending';
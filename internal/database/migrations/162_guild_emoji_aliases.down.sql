@@ -0,0 +1,3 @@
+-- Rollback migration 162: Guild emoji shortcode aliases
+
+DROP TABLE IF EXISTS guild_emoji_aliases;
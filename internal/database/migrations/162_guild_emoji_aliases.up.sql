@@ -0,0 +1,13 @@
+-- Migration 162: Guild emoji shortcode aliases
+-- Extra :shortcode: names a guild maps to unicode emoji, on top of the
+-- standard set. When the instance normalizes shortcodes in message content,
+-- a guild's aliases are applied to messages sent in its channels.
+
+CREATE TABLE IF NOT EXISTS guild_emoji_aliases (
+    guild_id    TEXT NOT NULL REFERENCES guilds(id) ON DELETE CASCADE,
+    alias       TEXT NOT NULL,
+    emoji       TEXT NOT NULL,
+    created_by  TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, alias)
+);
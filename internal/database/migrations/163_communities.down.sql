@@ -0,0 +1,17 @@
+-- Rollback migration 163: Communities
+
+DROP TRIGGER IF EXISTS trg_community_roles_on_join ON guild_members;
+DROP FUNCTION IF EXISTS community_roles_on_join();
+
+DELETE FROM guild_bans WHERE community_id IS NOT NULL;
+ALTER TABLE guild_bans DROP COLUMN IF EXISTS community_id;
+
+DELETE FROM roles WHERE community_role_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_roles_community_role;
+ALTER TABLE roles DROP COLUMN IF EXISTS community_role_id;
+
+DROP TABLE IF EXISTS community_bans;
+DROP TABLE IF EXISTS community_role_members;
+DROP TABLE IF EXISTS community_roles;
+DROP TABLE IF EXISTS community_guilds;
+DROP TABLE IF EXISTS communities;
@@ -0,0 +1,89 @@
+-- Migration 163: Communities
+-- A community groups several guilds under one name and branding, with a ban
+-- list and roles shared by every guild in it. A guild joins when its owner
+-- accepts the community's invitation, and belongs to at most one community.
+--
+-- Community roles are mirrored into each member guild as an ordinary role
+-- (roles.community_role_id), so every permission check sees them without
+-- knowing about communities. Holding a community role gives a user the
+-- mirrored role in each community guild they are a member of; a trigger adds
+-- it when they join one later. Community bans are likewise copied into each
+-- guild's bans (guild_bans.community_id) and lifted with the community ban.
+
+CREATE TABLE IF NOT EXISTS communities (
+    id            TEXT PRIMARY KEY,
+    owner_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name          TEXT NOT NULL,
+    description   TEXT,
+    icon_id       TEXT,
+    banner_id     TEXT,
+    accent_color  TEXT,
+    discoverable  BOOLEAN NOT NULL DEFAULT false,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_communities_owner ON communities(owner_id);
+
+CREATE TABLE IF NOT EXISTS community_guilds (
+    guild_id      TEXT PRIMARY KEY REFERENCES guilds(id) ON DELETE CASCADE,
+    community_id  TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+    status        TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'active')),
+    added_by      TEXT REFERENCES users(id) ON DELETE SET NULL,
+    added_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_community_guilds_community ON community_guilds(community_id);
+
+CREATE TABLE IF NOT EXISTS community_roles (
+    id                 TEXT PRIMARY KEY,
+    community_id       TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+    name               TEXT NOT NULL,
+    color              TEXT,
+    permissions_allow  BIGINT NOT NULL DEFAULT 0,
+    permissions_deny   BIGINT NOT NULL DEFAULT 0,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_community_roles_community ON community_roles(community_id);
+
+CREATE TABLE IF NOT EXISTS community_role_members (
+    role_id     TEXT NOT NULL REFERENCES community_roles(id) ON DELETE CASCADE,
+    user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    granted_by  TEXT REFERENCES users(id) ON DELETE SET NULL,
+    granted_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (role_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_community_role_members_user ON community_role_members(user_id);
+
+CREATE TABLE IF NOT EXISTS community_bans (
+    community_id  TEXT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+    user_id       TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason        TEXT,
+    banned_by     TEXT REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (community_id, user_id)
+);
+
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS community_role_id TEXT REFERENCES community_roles(id) ON DELETE CASCADE;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_community_role ON roles(guild_id, community_role_id)
+    WHERE community_role_id IS NOT NULL;
+
+ALTER TABLE guild_bans ADD COLUMN IF NOT EXISTS community_id TEXT REFERENCES communities(id) ON DELETE CASCADE;
+
+-- Give a joining member the mirrored roles for the community roles they hold.
+CREATE OR REPLACE FUNCTION community_roles_on_join() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO member_roles (guild_id, user_id, role_id)
+    SELECT NEW.guild_id, NEW.user_id, r.id
+    FROM roles r
+    JOIN community_role_members m ON m.role_id = r.community_role_id
+    WHERE r.guild_id = NEW.guild_id AND m.user_id = NEW.user_id
+    ON CONFLICT DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_community_roles_on_join
+    AFTER INSERT ON guild_members
+    FOR EACH ROW EXECUTE FUNCTION community_roles_on_join();
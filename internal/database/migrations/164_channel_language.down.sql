@@ -0,0 +1,3 @@
+-- Rollback migration 164: Channel language
+
+ALTER TABLE channels DROP COLUMN IF EXISTS language;
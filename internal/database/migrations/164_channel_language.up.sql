@@ -0,0 +1,6 @@
+-- Migration 164: Channel language
+-- A channel's primary language, an ISO 639 code such as "ja". Search indexes
+-- its messages with that language's tokenizer, translation takes it as the
+-- source language and AutoMod word filters can be limited to languages.
+
+ALTER TABLE channels ADD COLUMN IF NOT EXISTS language TEXT;
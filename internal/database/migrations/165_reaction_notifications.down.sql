@@ -0,0 +1,4 @@
+-- Rollback migration 165: Reaction notification preferences and digests
+
+DROP TABLE IF EXISTS reaction_digests;
+ALTER TABLE notification_preferences DROP COLUMN IF EXISTS reactions;
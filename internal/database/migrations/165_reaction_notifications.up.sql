@@ -0,0 +1,27 @@
+-- Migration 165: Reaction notification preferences and digests
+-- Users choose whose reactions to their messages notify them: anyone, only
+-- friends, or nobody. The setting lives on the global preferences row.
+--
+-- The first few reactions to a message notify one by one; after that they
+-- are held in reaction_digests and sent as one digest per message once the
+-- oldest held reaction has waited out the digest window.
+
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS reactions TEXT NOT NULL DEFAULT 'all'
+    CHECK (reactions IN ('all', 'friends', 'off'));
+
+CREATE TABLE IF NOT EXISTS reaction_digests (
+    user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id  TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    channel_id  TEXT NOT NULL,
+    guild_id    TEXT,
+    sent        INT NOT NULL DEFAULT 0,          -- reactions notified individually
+    held        INT NOT NULL DEFAULT 0,          -- reactions waiting for the next digest
+    emoji       JSONB NOT NULL DEFAULT '{}',     -- held count per emoji
+    actor_ids   TEXT[] NOT NULL DEFAULT '{}',    -- held reactors, most recent last
+    held_since  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reaction_digests_due ON reaction_digests(held_since) WHERE held > 0;
+CREATE INDEX IF NOT EXISTS idx_reaction_digests_updated ON reaction_digests(updated_at);
@@ -0,0 +1,12 @@
+-- Rollback migration 166: AutoMod name_filter rules
+
+DROP INDEX IF EXISTS idx_automod_rules_name_filter;
+DELETE FROM instance_automod_rules WHERE rule_type = 'name_filter';
+DELETE FROM automod_rules WHERE rule_type = 'name_filter';
+ALTER TABLE automod_rules DROP CONSTRAINT IF EXISTS automod_rules_rule_type_check;
+ALTER TABLE automod_rules ADD CONSTRAINT automod_rules_rule_type_check
+    CHECK (rule_type IN (
+        'word_filter', 'regex_filter', 'invite_filter',
+        'mention_spam', 'caps_filter', 'spam_filter', 'link_filter',
+        'emoji_hash', 'content_warning'
+    ));
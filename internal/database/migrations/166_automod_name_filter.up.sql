@@ -0,0 +1,17 @@
+-- Migration 166: AutoMod name_filter rules
+-- name_filter rules check member nicknames and display names on join and
+-- when they change. The rule_type check from migration 007 predates the
+-- emoji_hash and content_warning types too, so it is rebuilt with every
+-- type the API accepts.
+
+ALTER TABLE automod_rules DROP CONSTRAINT IF EXISTS automod_rules_rule_type_check;
+ALTER TABLE automod_rules ADD CONSTRAINT automod_rules_rule_type_check
+    CHECK (rule_type IN (
+        'word_filter', 'regex_filter', 'invite_filter',
+        'mention_spam', 'caps_filter', 'spam_filter', 'link_filter',
+        'emoji_hash', 'content_warning', 'name_filter'
+    ));
+
+-- Every member join and name change asks whether the guild has any.
+CREATE INDEX IF NOT EXISTS idx_automod_rules_name_filter ON automod_rules(guild_id)
+    WHERE rule_type = 'name_filter' AND enabled;
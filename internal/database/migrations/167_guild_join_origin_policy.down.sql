@@ -0,0 +1,3 @@
+-- Rollback migration 167: Guild join origin policy
+
+DROP TABLE IF EXISTS guild_join_origin_policy;
@@ -0,0 +1,12 @@
+-- Migration 167: Guild join origin policy
+-- Which instances a guild accepts new members from: any (open), only its own
+-- (local_only), or only listed peer domains (allowlist). Blocked domains are
+-- refused in every mode. Enforced on local joins and on federated joins.
+CREATE TABLE guild_join_origin_policy (
+    guild_id        TEXT PRIMARY KEY REFERENCES guilds(id) ON DELETE CASCADE,
+    mode            TEXT NOT NULL DEFAULT 'open'
+        CHECK (mode IN ('open', 'local_only', 'allowlist')),
+    allowed_domains TEXT[] NOT NULL DEFAULT '{}',
+    blocked_domains TEXT[] NOT NULL DEFAULT '{}',
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
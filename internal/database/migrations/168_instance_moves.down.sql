@@ -0,0 +1,3 @@
+-- Rollback migration 168: Instance domain moves
+
+DROP TABLE IF EXISTS federation_instance_moves;
@@ -0,0 +1,21 @@
+-- Migration 168: Instance domain moves
+-- An instance re-homed to a new domain with `amityvox migrate-instance` signs
+-- a move notice with its current key (and with its new key, if it generated
+-- one) and sends it to its peers. Both sides record the move here: the moving
+-- instance keeps the notice so it can be re-sent to peers that missed it, and
+-- peers keep the issue time so an older notice cannot be replayed. The notice
+-- is kept as sent, since its signatures cover the exact payload bytes.
+CREATE TABLE federation_instance_moves (
+    id                  TEXT PRIMARY KEY,
+    instance_id         TEXT NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    old_domain          TEXT NOT NULL,
+    new_domain          TEXT NOT NULL,
+    new_key_fingerprint TEXT,
+    issued_at           TIMESTAMPTZ NOT NULL,
+    signature           TEXT NOT NULL,
+    notice              TEXT NOT NULL,
+    recorded_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_federation_instance_moves_instance
+    ON federation_instance_moves (instance_id, issued_at DESC);
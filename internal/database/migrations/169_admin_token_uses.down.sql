@@ -0,0 +1,4 @@
+-- Rollback migration 169: Admin action token use counts
+
+ALTER TABLE admin_action_tokens DROP COLUMN IF EXISTS uses;
+ALTER TABLE admin_action_tokens DROP COLUMN IF EXISTS max_uses;
@@ -0,0 +1,8 @@
+-- Migration 169: Admin action token use counts
+-- Admin action tokens used to work for any number of requests until they
+-- expired, so a leaked token could be replayed. Each token now carries the
+-- number of uses it was minted for and is consumed on every use. Tokens
+-- minted before this migration keep a single use.
+
+ALTER TABLE admin_action_tokens ADD COLUMN IF NOT EXISTS max_uses INT NOT NULL DEFAULT 1;
+ALTER TABLE admin_action_tokens ADD COLUMN IF NOT EXISTS uses INT NOT NULL DEFAULT 0;
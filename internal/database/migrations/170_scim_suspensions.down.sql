@@ -0,0 +1,4 @@
+-- Rollback migration 170: SCIM-owned suspensions
+
+ALTER TABLE users DROP COLUMN IF EXISTS scim_suspended;
+ALTER TABLE users DROP COLUMN IF EXISTS scim_provisioned;
@@ -0,0 +1,12 @@
+-- Migration 170: SCIM-owned suspensions
+-- A SCIM token may only reactivate accounts it provisioned and that it
+-- suspended itself, never an account instance staff suspended. Accounts
+-- created through SCIM before this migration are recognised by their
+-- external ID and missing password; their existing suspensions are treated
+-- as staff suspensions.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scim_provisioned BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scim_suspended BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users SET scim_provisioned = true
+WHERE scim_external_id IS NOT NULL AND password_hash IS NULL;
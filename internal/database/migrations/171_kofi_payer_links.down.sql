@@ -0,0 +1,4 @@
+-- Rollback migration 171: Ko-fi payer links
+
+DROP TABLE IF EXISTS kofi_payers;
+DROP TABLE IF EXISTS kofi_link_codes;
@@ -0,0 +1,20 @@
+-- Migration 171: Ko-fi payer links
+-- Ko-fi payments used to be matched to accounts by email, but account email
+-- addresses are not verified, so anyone could claim another payer's
+-- subscription by changing theirs. A user now puts a link code in their
+-- Ko-fi message; the first payment carrying it links the payer's Ko-fi email
+-- to the account, and later payments from that email follow the link.
+
+CREATE TABLE IF NOT EXISTS kofi_link_codes (
+    user_id    TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    code       TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS kofi_payers (
+    email      TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    linked_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_kofi_payers_user ON kofi_payers(user_id);
@@ -0,0 +1,2 @@
+-- Rollback migration 172: Re-key account signals
+-- The dropped signal rows cannot be restored.
@@ -0,0 +1,7 @@
+-- Migration 172: Re-key account signals
+-- Signal hashes are now keyed with a server secret and IPv6 addresses are
+-- grouped by /64. Hashes written under the old unkeyed scheme can be
+-- reversed by brute force and never match new ones, and email signals came
+-- from unverified addresses, so all existing rows are dropped.
+
+DELETE FROM account_signals;
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QueryBuckets are the upper bounds of the query duration histogram.
+var QueryBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+const (
+	// maxQueryNames caps how many query names are tracked separately; later
+	// names are counted under otherQueryName.
+	maxQueryNames  = 1000
+	otherQueryName = "other"
+	// maxSlowSQL is how much of a slow query's SQL is logged.
+	maxSlowSQL = 500
+	// modulePrefix is trimmed from function names used as query names.
+	modulePrefix = "github.com/amityvox/amityvox/internal/"
+)
+
+// QueryStat is the timing of every query run under one name.
+type QueryStat struct {
+	Name   string        `json:"name"`
+	Count  uint64        `json:"count"`
+	Errors uint64        `json:"errors"`
+	Total  time.Duration `json:"total_ns"`
+	Max    time.Duration `json:"max_ns"`
+	// Buckets counts queries per QueryBuckets bound, cumulatively; queries
+	// slower than the last bound are only in Count.
+	Buckets []uint64 `json:"buckets"`
+}
+
+type queryNameKey struct{}
+
+// WithQueryName names the queries run with ctx in the query statistics.
+// Queries without a name are named after the function that ran them.
+func WithQueryName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queryNameKey{}, name)
+}
+
+// queryTracer times every query the pool runs, records it under its query
+// name and logs those slower than the slow query threshold.
+type queryTracer struct {
+	logger *slog.Logger
+	slow   atomic.Int64 // threshold in nanoseconds; 0 disables slow query logging
+
+	mu    sync.Mutex
+	stats map[string]*QueryStat
+}
+
+type queryTrace struct {
+	name  string
+	sql   string
+	start time.Time
+}
+
+type queryTraceKey struct{}
+
+func newQueryTracer(logger *slog.Logger) *queryTracer {
+	return &queryTracer{logger: logger, stats: make(map[string]*QueryStat)}
+}
+
+// TraceQueryStart implements pgx.QueryTracer.
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	name, _ := ctx.Value(queryNameKey{}).(string)
+	if name == "" {
+		name = callerName()
+	}
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{name: name, sql: data.SQL, start: time.Now()})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer. For queries returning rows it
+// runs when the rows are closed, so the time includes reading them.
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(trace.start)
+	failed := data.Err != nil && data.Err != pgx.ErrNoRows
+	t.record(trace.name, elapsed, failed)
+
+	if slow := time.Duration(t.slow.Load()); slow > 0 && elapsed >= slow {
+		t.logger.Warn("slow query",
+			slog.String("query", trace.name),
+			slog.Duration("duration", elapsed),
+			slog.String("sql", compactSQL(trace.sql)),
+			slog.Bool("failed", failed),
+		)
+	}
+}
+
+func (t *queryTracer) record(name string, elapsed time.Duration, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st, ok := t.stats[name]
+	if !ok {
+		if len(t.stats) >= maxQueryNames {
+			name = otherQueryName
+			st = t.stats[name]
+		}
+		if st == nil {
+			st = &QueryStat{Name: name, Buckets: make([]uint64, len(QueryBuckets))}
+			t.stats[name] = st
+		}
+	}
+	st.Count++
+	if failed {
+		st.Errors++
+	}
+	st.Total += elapsed
+	st.Max = max(st.Max, elapsed)
+	for i, bound := range QueryBuckets {
+		if elapsed <= bound {
+			st.Buckets[i]++
+		}
+	}
+}
+
+// snapshot returns a copy of the statistics sorted by name.
+func (t *queryTracer) snapshot() []QueryStat {
+	t.mu.Lock()
+	out := make([]QueryStat, 0, len(t.stats))
+	for _, st := range t.stats {
+		c := *st
+		c.Buckets = slices.Clone(st.Buckets)
+		out = append(out, c)
+	}
+	t.mu.Unlock()
+
+	slices.SortFunc(out, func(a, b QueryStat) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// callerName names a query after the first function on the stack outside
+// pgx and this package, such as "api/channels.(*Handler).HandleCreateMessage".
+// Closures are folded into the function that declares them.
+func callerName() string {
+	var pcs [24]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		fn := frame.Function
+		if !strings.HasPrefix(fn, "github.com/jackc/") && !strings.HasPrefix(fn, modulePrefix+"database.") {
+			return queryNameFromFunc(fn)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+(\.\d+)*$`)
+
+// queryNameFromFunc turns a fully qualified function name into a query name.
+func queryNameFromFunc(fn string) string {
+	if fn == "" {
+		return "unknown"
+	}
+	fn = strings.TrimPrefix(fn, modulePrefix)
+	return closureSuffix.ReplaceAllString(fn, "")
+}
+
+var sqlSpace = regexp.MustCompile(`\s+`)
+
+// compactSQL collapses whitespace in sql and truncates it for logging.
+func compactSQL(sql string) string {
+	sql = strings.TrimSpace(sqlSpace.ReplaceAllString(sql, " "))
+	if len(sql) > maxSlowSQL {
+		sql = sql[:maxSlowSQL] + "…"
+	}
+	return sql
+}
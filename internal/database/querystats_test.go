@@ -0,0 +1,74 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryTracerRecord(t *testing.T) {
+	tr := newQueryTracer(nil)
+	tr.record("a", 3*time.Millisecond, false)
+	tr.record("a", 200*time.Millisecond, true)
+	tr.record("b", 10*time.Second, false)
+
+	stats := tr.snapshot()
+	if len(stats) != 2 || stats[0].Name != "a" || stats[1].Name != "b" {
+		t.Fatalf("snapshot = %+v, want a and b sorted", stats)
+	}
+	a := stats[0]
+	if a.Count != 2 || a.Errors != 1 || a.Max != 200*time.Millisecond || a.Total != 203*time.Millisecond {
+		t.Errorf("a = %+v", a)
+	}
+	// 1ms holds neither; 5ms holds the 3ms query; 250ms and above hold both.
+	want := []uint64{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 2}
+	for i, n := range want {
+		if a.Buckets[i] != n {
+			t.Errorf("a bucket %v = %d, want %d", QueryBuckets[i], a.Buckets[i], n)
+		}
+	}
+	if b := stats[1]; b.Buckets[len(b.Buckets)-1] != 0 || b.Count != 1 {
+		t.Errorf("query slower than every bucket: %+v", b)
+	}
+}
+
+func TestQueryTracerCapsNames(t *testing.T) {
+	tr := newQueryTracer(nil)
+	for i := 0; i < maxQueryNames+10; i++ {
+		tr.record(strings.Repeat("q", i+1), time.Millisecond, false)
+	}
+	stats := tr.snapshot()
+	if len(stats) != maxQueryNames+1 {
+		t.Fatalf("tracked %d names, want %d", len(stats), maxQueryNames+1)
+	}
+	for _, st := range stats {
+		if st.Name == otherQueryName && st.Count != 10 {
+			t.Errorf("other count = %d, want 10", st.Count)
+		}
+	}
+}
+
+func TestQueryNameFromFunc(t *testing.T) {
+	tests := map[string]string{
+		"github.com/amityvox/amityvox/internal/api/channels.(*Handler).HandleCreateMessage": "api/channels.(*Handler).HandleCreateMessage",
+		"github.com/amityvox/amityvox/internal/workers.(*Manager).startEventWorker.func1.2": "workers.(*Manager).startEventWorker",
+		"github.com/amityvox/amityvox/internal/api/apiutil.WithTx.func1":                    "api/apiutil.WithTx",
+		"main.ensureLocalInstance": "main.ensureLocalInstance",
+		"":                         "unknown",
+	}
+	for fn, want := range tests {
+		if got := queryNameFromFunc(fn); got != want {
+			t.Errorf("queryNameFromFunc(%q) = %q, want %q", fn, got, want)
+		}
+	}
+}
+
+func TestCompactSQL(t *testing.T) {
+	got := compactSQL("SELECT id\n\t\t FROM users\n\t\t WHERE id = $1  ")
+	if got != "SELECT id FROM users WHERE id = $1" {
+		t.Errorf("compactSQL = %q", got)
+	}
+	if long := compactSQL(strings.Repeat("x", maxSlowSQL+10)); len(long) != maxSlowSQL+len("…") {
+		t.Errorf("compactSQL did not truncate: %d bytes", len(long))
+	}
+}
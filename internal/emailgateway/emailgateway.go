@@ -0,0 +1,443 @@
+// Package emailgateway posts email sent to per-channel addresses as channel
+// messages and, for channels that allow it, emails replies to those messages
+// back to the original sender. Inbound mail is read over JMAP from a
+// catch-all mailbox for the gateway domain; replies are sent over SMTP.
+package emailgateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/locale"
+	"github.com/amityvox/amityvox/internal/media"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+const (
+	// pollBatchSize is the number of unread emails handled per poll.
+	pollBatchSize = 50
+
+	// maxAttachmentsPerEmail caps how many attachments one email can post.
+	maxAttachmentsPerEmail = 10
+
+	// gatewayUserSetting is the instance_settings key holding the ID of the
+	// bot user that authors inbound email messages.
+	gatewayUserSetting = "email_gateway_user_id"
+)
+
+// Config holds the settings of the email gateway.
+type Config struct {
+	Domain         string
+	JMAPSessionURL string
+	JMAPToken      string
+	PollInterval   time.Duration
+	MaxAttachment  int64 // bytes
+	SMTPHost       string
+	SMTPPort       int
+	SMTPUsername   string
+	SMTPPassword   string
+	InstanceID     string
+}
+
+// Service is the channel email gateway.
+type Service struct {
+	cfg    Config
+	pool   *pgxpool.Pool
+	bus    *events.Bus
+	media  *media.Service // nil drops attachments
+	jmap   *jmapClient
+	logger *slog.Logger
+
+	userID string // gateway bot user, set by Start
+}
+
+// New creates the email gateway. mediaSvc may be nil if storage is not
+// configured, in which case attachments are dropped.
+func New(cfg Config, pool *pgxpool.Pool, bus *events.Bus, mediaSvc *media.Service, logger *slog.Logger) *Service {
+	return &Service{
+		cfg:   cfg,
+		pool:  pool,
+		bus:   bus,
+		media: mediaSvc,
+		jmap: &jmapClient{
+			sessionURL: cfg.JMAPSessionURL,
+			token:      cfg.JMAPToken,
+			http:       &http.Client{Timeout: 60 * time.Second},
+		},
+		logger: logger,
+	}
+}
+
+// Start provisions the gateway user, starts polling the mailbox and, if SMTP
+// is configured, starts emailing replies.
+func (s *Service) Start(ctx context.Context) error {
+	userID, err := s.ensureGatewayUser(ctx)
+	if err != nil {
+		return fmt.Errorf("provisioning email gateway user: %w", err)
+	}
+	s.userID = userID
+
+	if s.cfg.SMTPHost != "" {
+		if _, err := s.bus.QueueSubscribe(events.SubjectMessageCreate, "email-gateway", func(event events.Event) {
+			s.handleMessageCreate(ctx, event)
+		}); err != nil {
+			return fmt.Errorf("subscribing to messages: %w", err)
+		}
+	}
+
+	go s.pollLoop(ctx)
+	s.logger.Info("email gateway started",
+		slog.String("domain", s.cfg.Domain),
+		slog.Bool("replies", s.cfg.SMTPHost != ""))
+	return nil
+}
+
+// ensureGatewayUser returns the bot user that authors inbound email,
+// creating it on first start.
+func (s *Service) ensureGatewayUser(ctx context.Context) (string, error) {
+	var userID string
+	err := s.pool.QueryRow(ctx,
+		`SELECT u.id FROM instance_settings st JOIN users u ON u.id = st.value
+		 WHERE st.key = $1`, gatewayUserSetting,
+	).Scan(&userID)
+	if err == nil {
+		return userID, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+
+	userID = models.NewULID().String()
+	// The username is only cosmetic; suffix it so it never collides with a
+	// real account.
+	username := "email-gateway-" + strings.ToLower(userID[len(userID)-6:])
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO users (id, instance_id, username, display_name, flags, status_presence, created_at)
+			 VALUES ($1, $2, $3, 'Email', $4, 'offline', now())`,
+			userID, s.cfg.InstanceID, username, models.UserFlagBot); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO instance_settings (key, value, updated_at) VALUES ($1, $2, now())
+			 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = now()`,
+			gatewayUserSetting, userID)
+		return err
+	})
+	return userID, err
+}
+
+// pollLoop reads new mail until ctx is cancelled.
+func (s *Service) pollLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		s.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll processes one batch of unread inbox mail.
+func (s *Service) poll(ctx context.Context) {
+	if err := s.jmap.connect(ctx); err != nil {
+		s.logger.Warn("email gateway: JMAP connect failed", slog.String("error", err.Error()))
+		return
+	}
+	emails, err := s.jmap.unreadEmails(ctx, pollBatchSize)
+	if err != nil {
+		s.logger.Warn("email gateway: failed to list mail", slog.String("error", err.Error()))
+		return
+	}
+	for _, email := range emails {
+		if err := s.processEmail(ctx, email); err != nil {
+			// Leave it unread so the next poll retries.
+			s.logger.Warn("email gateway: failed to post email",
+				slog.String("email_id", email.ID), slog.String("error", err.Error()))
+			continue
+		}
+		if err := s.jmap.markSeen(ctx, email.ID); err != nil {
+			s.logger.Warn("email gateway: failed to mark email seen",
+				slog.String("email_id", email.ID), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// emailChannel is the channel an inbound email is addressed to.
+type emailChannel struct {
+	ID      string
+	GuildID *string
+}
+
+// processEmail posts an email to the channel it is addressed to. Mail that
+// cannot be delivered to any channel is dropped (returns nil) so it is not
+// retried forever.
+func (s *Service) processEmail(ctx context.Context, email jmapEmail) error {
+	if len(email.From) == 0 {
+		return nil
+	}
+	sender, err := mail.ParseAddress(email.From[0].Email)
+	if err != nil {
+		return nil
+	}
+	// Ignore bounces, vacation replies and anything sent by the gateway
+	// itself, which would otherwise loop.
+	if email.AutoSubmitted != nil && !strings.EqualFold(strings.TrimSpace(*email.AutoSubmitted), "no") {
+		return nil
+	}
+	if _, ours := channelLocalpart(sender.Address, s.cfg.Domain); ours {
+		return nil
+	}
+
+	ch, ok := s.lookupChannel(ctx, append(email.To, email.Cc...))
+	if !ok {
+		return nil
+	}
+
+	emailMessageID := ""
+	if len(email.MessageID) > 0 {
+		emailMessageID = trimMessageID(email.MessageID[0])
+	}
+	if emailMessageID == "" {
+		emailMessageID = "jmap-" + email.ID + "@" + s.cfg.Domain
+	}
+	var exists bool
+	s.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM channel_email_messages WHERE channel_id = $1 AND email_message_id = $2)`,
+		ch.ID, emailMessageID).Scan(&exists)
+	if exists {
+		return nil // already posted; the seen flag was lost
+	}
+
+	// Thread replies onto the message the email answers.
+	var replyTo []string
+	for _, id := range email.InReplyTo {
+		var messageID string
+		if s.pool.QueryRow(ctx,
+			`SELECT message_id FROM channel_email_messages WHERE channel_id = $1 AND email_message_id = $2`,
+			ch.ID, trimMessageID(id)).Scan(&messageID) == nil {
+			replyTo = []string{messageID}
+			break
+		}
+	}
+
+	var text strings.Builder
+	for _, part := range email.TextBody {
+		if v, ok := email.BodyValues[part.PartID]; ok {
+			text.WriteString(v.Value)
+		}
+	}
+	body := text.String()
+	if replyTo != nil {
+		body = stripQuotedReply(body)
+	}
+	content := formatInbound(email.Subject, body, replyTo != nil)
+
+	attachmentIDs := s.storeAttachments(ctx, email)
+	if content == "" && len(attachmentIDs) == 0 {
+		return nil
+	}
+
+	senderName := sender.Address
+	if name := strings.TrimSpace(email.From[0].Name); name != "" {
+		senderName = name
+	}
+	messageType := models.MessageTypeDefault
+	if replyTo != nil {
+		messageType = models.MessageTypeReply
+	}
+
+	messageID := models.NewULID().String()
+	now := time.Now().UTC()
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO messages (id, channel_id, author_id, content, message_type, reply_to_ids,
+			                       masquerade_name, bridge_source, bridge_remote_id, bridge_author_name, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, 'email', $8, $9, $10)`,
+			messageID, ch.ID, s.userID, content, messageType, replyTo,
+			senderName, emailMessageID, sender.Address, now); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO channel_email_messages (message_id, channel_id, email_message_id, correspondent, subject)
+			 VALUES ($1, $2, $3, $4, $5)`,
+			messageID, ch.ID, emailMessageID, sender.Address, email.Subject); err != nil {
+			return err
+		}
+		if len(attachmentIDs) > 0 {
+			if _, err := tx.Exec(ctx,
+				`UPDATE attachments SET message_id = $1 WHERE id = ANY($2) AND message_id IS NULL`,
+				messageID, attachmentIDs); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(ctx, `UPDATE channels SET last_message_id = $1 WHERE id = $2`, messageID, ch.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := models.Message{
+		ID:             messageID,
+		ChannelID:      ch.ID,
+		AuthorID:       s.userID,
+		Content:        &content,
+		MessageType:    messageType,
+		ReplyToIDs:     replyTo,
+		MasqueradeName: &senderName,
+		CreatedAt:      now,
+	}
+	for _, id := range attachmentIDs {
+		msg.Attachments = append(msg.Attachments, models.Attachment{ID: id})
+	}
+	s.bus.PublishChannelEvent(ctx, events.SubjectMessageCreate, "MESSAGE_CREATE", ch.ID, msg)
+	return nil
+}
+
+// lookupChannel returns the first channel addressed by one of the recipients.
+func (s *Service) lookupChannel(ctx context.Context, recipients []jmapAddress) (emailChannel, bool) {
+	for _, rcpt := range recipients {
+		localpart, ok := channelLocalpart(rcpt.Email, s.cfg.Domain)
+		if !ok {
+			continue
+		}
+		var ch emailChannel
+		err := s.pool.QueryRow(ctx,
+			`SELECT c.id, c.guild_id FROM channel_email_addresses a
+			 JOIN channels c ON c.id = a.channel_id
+			 WHERE a.localpart = $1 AND NOT COALESCE(c.archived, false)`, localpart,
+		).Scan(&ch.ID, &ch.GuildID)
+		if err == nil {
+			return ch, true
+		}
+	}
+	return emailChannel{}, false
+}
+
+// storeAttachments uploads an email's attachments and returns their IDs.
+// Attachments over the size limit or beyond the per-email cap are skipped.
+func (s *Service) storeAttachments(ctx context.Context, email jmapEmail) []string {
+	if s.media == nil {
+		return nil
+	}
+	var ids []string
+	for _, part := range email.Attachments {
+		if len(ids) >= maxAttachmentsPerEmail {
+			break
+		}
+		if part.Size > s.cfg.MaxAttachment {
+			continue
+		}
+		data, err := s.jmap.download(ctx, part, s.cfg.MaxAttachment)
+		if err != nil {
+			s.logger.Debug("email gateway: skipping attachment",
+				slog.String("email_id", email.ID), slog.String("error", err.Error()))
+			continue
+		}
+		name := part.Name
+		if name == "" {
+			name = "attachment"
+		}
+		att, err := s.media.StoreAttachment(ctx, s.userID, name, part.Type, "", data)
+		if err != nil {
+			s.logger.Warn("email gateway: failed to store attachment",
+				slog.String("email_id", email.ID), slog.String("error", err.Error()))
+			continue
+		}
+		ids = append(ids, att.ID)
+	}
+	return ids
+}
+
+// handleMessageCreate emails a channel reply to the sender of the emailed
+// message it answers, if the channel allows replies.
+func (s *Service) handleMessageCreate(ctx context.Context, event events.Event) {
+	var msg models.Message
+	if err := json.Unmarshal(event.Data, &msg); err != nil {
+		return
+	}
+	if msg.AuthorID == "" || msg.AuthorID == s.userID || len(msg.ReplyToIDs) == 0 ||
+		msg.Content == nil || strings.TrimSpace(*msg.Content) == "" || msg.Encrypted {
+		return
+	}
+
+	var correspondent, subject, inReplyTo, localpart string
+	var guildID *string
+	err := s.pool.QueryRow(ctx,
+		`SELECT m.correspondent, m.subject, m.email_message_id, a.localpart, c.guild_id
+		 FROM channel_email_messages m
+		 JOIN channel_email_addresses a ON a.channel_id = m.channel_id
+		 JOIN channels c ON c.id = m.channel_id
+		 WHERE m.message_id = $1 AND m.channel_id = $2 AND a.reply_enabled`,
+		msg.ReplyToIDs[0], msg.ChannelID,
+	).Scan(&correspondent, &subject, &inReplyTo, &localpart, &guildID)
+	if err != nil {
+		return
+	}
+
+	// Replies are dated in the guild's timezone and tagged with its locale.
+	settings := locale.Settings{Locale: locale.DefaultLocale, Timezone: locale.DefaultTimezone}
+	if guildID != nil {
+		settings = locale.ForGuild(ctx, s.pool, *guildID)
+	}
+
+	fromName := "AmityVox"
+	if msg.Author != nil {
+		if msg.Author.DisplayName != nil && *msg.Author.DisplayName != "" {
+			fromName = *msg.Author.DisplayName
+		} else {
+			fromName = msg.Author.Username
+		}
+	}
+
+	out := outboundEmail{
+		FromName:  fromName,
+		FromAddr:  localpart + "@" + s.cfg.Domain,
+		To:        correspondent,
+		Subject:   replySubject(subject),
+		MessageID: strings.ToLower(msg.ID) + "@" + s.cfg.Domain,
+		InReplyTo: inReplyTo,
+		Body:      *msg.Content,
+		Date:      time.Now().In(settings.Location()),
+		Language:  settings.Locale,
+	}
+	if err := s.send(out); err != nil {
+		s.logger.Warn("email gateway: failed to send reply",
+			slog.String("message_id", msg.ID), slog.String("error", err.Error()))
+		return
+	}
+
+	// Record the reply so the correspondent's answer threads onto it.
+	s.pool.Exec(ctx,
+		`INSERT INTO channel_email_messages (message_id, channel_id, email_message_id, correspondent, subject)
+		 VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`,
+		msg.ID, msg.ChannelID, out.MessageID, correspondent, out.Subject)
+}
+
+// send delivers an email over SMTP, upgrading to TLS when the server offers it.
+func (s *Service) send(m outboundEmail) error {
+	var auth smtp.Auth
+	if s.cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", s.cfg.SMTPUsername, s.cfg.SMTPPassword, s.cfg.SMTPHost)
+	}
+	addr := net.JoinHostPort(s.cfg.SMTPHost, strconv.Itoa(s.cfg.SMTPPort))
+	return smtp.SendMail(addr, auth, m.FromAddr, []string{m.To}, m.render())
+}
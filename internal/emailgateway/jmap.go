@@ -0,0 +1,254 @@
+package emailgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// JMAP capabilities used by the gateway (RFC 8620, RFC 8621).
+var jmapUsing = []string{"urn:ietf:params:jmap:core", "urn:ietf:params:jmap:mail"}
+
+// jmapSession is the subset of the JMAP session resource the gateway needs.
+type jmapSession struct {
+	APIURL          string            `json:"apiUrl"`
+	DownloadURL     string            `json:"downloadUrl"`
+	PrimaryAccounts map[string]string `json:"primaryAccounts"`
+}
+
+// jmapAddress is an EmailAddress object.
+type jmapAddress struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// jmapBodyPart is an EmailBodyPart object.
+type jmapBodyPart struct {
+	PartID string `json:"partId"`
+	BlobID string `json:"blobId"`
+	Size   int64  `json:"size"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+}
+
+// jmapEmail is the subset of an Email object the gateway requests.
+type jmapEmail struct {
+	ID            string         `json:"id"`
+	MessageID     []string       `json:"messageId"`
+	InReplyTo     []string       `json:"inReplyTo"`
+	From          []jmapAddress  `json:"from"`
+	To            []jmapAddress  `json:"to"`
+	Cc            []jmapAddress  `json:"cc"`
+	Subject       string         `json:"subject"`
+	AutoSubmitted *string        `json:"header:Auto-Submitted:asText"`
+	TextBody      []jmapBodyPart `json:"textBody"`
+	BodyValues    map[string]struct {
+		Value string `json:"value"`
+	} `json:"bodyValues"`
+	Attachments []jmapBodyPart `json:"attachments"`
+}
+
+// jmapEmailProperties are the Email properties fetched for each new message.
+var jmapEmailProperties = []string{
+	"id", "messageId", "inReplyTo", "from", "to", "cc", "subject",
+	"header:Auto-Submitted:asText", "textBody", "bodyValues", "attachments",
+}
+
+// jmapClient talks to a single JMAP account.
+type jmapClient struct {
+	sessionURL string
+	token      string
+	http       *http.Client
+
+	session   *jmapSession
+	accountID string
+	inboxID   string
+}
+
+// connect fetches the session resource and locates the inbox. It is called
+// lazily so a mail server outage at startup does not disable the gateway.
+func (c *jmapClient) connect(ctx context.Context) error {
+	if c.session != nil && c.inboxID != "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.sessionURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating session request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching JMAP session: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JMAP session: status %d", resp.StatusCode)
+	}
+	var session jmapSession
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&session); err != nil {
+		return fmt.Errorf("decoding JMAP session: %w", err)
+	}
+	accountID := session.PrimaryAccounts["urn:ietf:params:jmap:mail"]
+	if session.APIURL == "" || accountID == "" {
+		return fmt.Errorf("JMAP session has no mail account")
+	}
+	c.session, c.accountID = &session, accountID
+
+	var mailboxes struct {
+		IDs []string `json:"ids"`
+	}
+	if err := c.call(ctx, "Mailbox/query", map[string]interface{}{
+		"accountId": c.accountID,
+		"filter":    map[string]string{"role": "inbox"},
+	}, &mailboxes); err != nil {
+		return err
+	}
+	if len(mailboxes.IDs) == 0 {
+		return fmt.Errorf("JMAP account has no inbox")
+	}
+	c.inboxID = mailboxes.IDs[0]
+	return nil
+}
+
+// call invokes a single JMAP method and decodes its arguments into out.
+func (c *jmapClient) call(ctx context.Context, method string, args interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"using":       jmapUsing,
+		"methodCalls": []interface{}{[]interface{}{method, args, "0"}},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling %s: %w", method, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.session.APIURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating %s request: %w", method, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusUnauthorized {
+			c.inboxID = "" // refetch the session on the next poll
+		}
+		return fmt.Errorf("%s: status %d", method, resp.StatusCode)
+	}
+
+	var result struct {
+		MethodResponses [][]json.RawMessage `json:"methodResponses"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 32<<20)).Decode(&result); err != nil {
+		return fmt.Errorf("decoding %s response: %w", method, err)
+	}
+	if len(result.MethodResponses) == 0 || len(result.MethodResponses[0]) < 2 {
+		return fmt.Errorf("%s: empty response", method)
+	}
+	var name string
+	json.Unmarshal(result.MethodResponses[0][0], &name)
+	if name == "error" {
+		var jerr struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(result.MethodResponses[0][1], &jerr)
+		return fmt.Errorf("%s: %s", method, jerr.Type)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(result.MethodResponses[0][1], out)
+}
+
+// unreadEmails returns up to limit unread emails in the inbox, oldest first.
+func (c *jmapClient) unreadEmails(ctx context.Context, limit int) ([]jmapEmail, error) {
+	var query struct {
+		IDs []string `json:"ids"`
+	}
+	if err := c.call(ctx, "Email/query", map[string]interface{}{
+		"accountId": c.accountID,
+		"filter":    map[string]string{"inMailbox": c.inboxID, "notKeyword": "$seen"},
+		"sort":      []map[string]interface{}{{"property": "receivedAt", "isAscending": true}},
+		"limit":     limit,
+	}, &query); err != nil {
+		return nil, err
+	}
+	if len(query.IDs) == 0 {
+		return nil, nil
+	}
+
+	var got struct {
+		List []jmapEmail `json:"list"`
+	}
+	if err := c.call(ctx, "Email/get", map[string]interface{}{
+		"accountId":           c.accountID,
+		"ids":                 query.IDs,
+		"properties":          jmapEmailProperties,
+		"fetchTextBodyValues": true,
+		"maxBodyValueBytes":   256 << 10,
+	}, &got); err != nil {
+		return nil, err
+	}
+	return got.List, nil
+}
+
+// markSeen sets the $seen keyword so the email is not processed again.
+func (c *jmapClient) markSeen(ctx context.Context, emailID string) error {
+	return c.call(ctx, "Email/set", map[string]interface{}{
+		"accountId": c.accountID,
+		"update": map[string]interface{}{
+			emailID: map[string]bool{"keywords/$seen": true},
+		},
+	}, nil)
+}
+
+// download fetches a blob, reading at most maxBytes. Blobs larger than that
+// return an error rather than a truncated file.
+func (c *jmapClient) download(ctx context.Context, part jmapBodyPart, maxBytes int64) ([]byte, error) {
+	u := expandDownloadURL(c.session.DownloadURL, c.accountID, part.BlobID, part.Type, part.Name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating download request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading blob %s: %w", part.BlobID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading blob %s: status %d", part.BlobID, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", part.BlobID, err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("blob %s exceeds %d bytes", part.BlobID, maxBytes)
+	}
+	return data, nil
+}
+
+// expandDownloadURL fills in the session's downloadUrl URI template.
+func expandDownloadURL(template, accountID, blobID, contentType, name string) string {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if name == "" {
+		name = "attachment"
+	}
+	return strings.NewReplacer(
+		"{accountId}", url.PathEscape(accountID),
+		"{blobId}", url.PathEscape(blobID),
+		"{type}", url.QueryEscape(contentType),
+		"{name}", url.PathEscape(name),
+	).Replace(template)
+}
@@ -0,0 +1,141 @@
+package emailgateway
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxContentLength mirrors the message content limit of the messages API.
+const maxContentLength = 4000
+
+// channelLocalpart returns the lowercase localpart of addr if it belongs to
+// the gateway domain. Plus-addressing tags (chan+tag@domain) are ignored.
+func channelLocalpart(addr, domain string) (string, bool) {
+	at := strings.LastIndexByte(addr, '@')
+	if at <= 0 || !strings.EqualFold(addr[at+1:], domain) {
+		return "", false
+	}
+	localpart := strings.ToLower(addr[:at])
+	if plus := strings.IndexByte(localpart, '+'); plus > 0 {
+		localpart = localpart[:plus]
+	}
+	return localpart, true
+}
+
+// trimMessageID strips the angle brackets and whitespace around a Message-ID.
+func trimMessageID(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
+}
+
+// quoteHeaderRe matches the attribution line mail clients put above a quoted
+// reply, e.g. "On Tue, 3 Mar 2026 at 10:00, Alice <a@example.com> wrote:".
+var quoteHeaderRe = regexp.MustCompile(`(?i)^on .+ wrote:\s*$`)
+
+// stripQuotedReply removes the quoted original from a reply so only the new
+// text is posted. It cuts at the first attribution line, Outlook-style
+// separator, or run of ">" lines that continues to the end of the message.
+func stripQuotedReply(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	cut := len(lines)
+	for i, line := range lines {
+		t := strings.TrimSpace(line)
+		if quoteHeaderRe.MatchString(t) || strings.HasPrefix(t, "-----Original Message-----") {
+			cut = i
+			break
+		}
+		if strings.HasPrefix(t, ">") && onlyQuotesFollow(lines[i:]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines[:cut], "\n"))
+}
+
+// onlyQuotesFollow reports whether every non-blank line is a "> " quote.
+func onlyQuotesFollow(lines []string) bool {
+	for _, line := range lines {
+		t := strings.TrimSpace(line)
+		if t != "" && !strings.HasPrefix(t, ">") {
+			return false
+		}
+	}
+	return true
+}
+
+// formatInbound builds the message content for an inbound email. New
+// conversations lead with the subject; replies are posted as plain text.
+func formatInbound(subject, body string, isReply bool) string {
+	body = strings.TrimSpace(body)
+	if !isReply && strings.TrimSpace(subject) != "" {
+		if body == "" {
+			body = "**" + strings.TrimSpace(subject) + "**"
+		} else {
+			body = "**" + strings.TrimSpace(subject) + "**\n\n" + body
+		}
+	}
+	if r := []rune(body); len(r) > maxContentLength {
+		body = string(r[:maxContentLength-1]) + "…"
+	}
+	return body
+}
+
+// replySubject prefixes a subject with "Re:" unless it already has one.
+func replySubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	if len(subject) >= 3 && strings.EqualFold(subject[:3], "re:") {
+		return subject
+	}
+	if subject == "" {
+		return "Re: your message"
+	}
+	return "Re: " + subject
+}
+
+// outboundEmail is a plain-text reply sent on behalf of a channel.
+type outboundEmail struct {
+	FromName  string
+	FromAddr  string
+	To        string
+	Subject   string
+	MessageID string // without angle brackets
+	InReplyTo string // without angle brackets
+	Body      string
+	Date      time.Time
+	Language  string // Content-Language, when known
+}
+
+// render returns the RFC 5322 message.
+func (m outboundEmail) render() []byte {
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	from := m.FromAddr
+	if m.FromName != "" {
+		from = mime.QEncoding.Encode("utf-8", m.FromName) + " <" + m.FromAddr + ">"
+	}
+	header("From", from)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", m.Date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+m.MessageID+">")
+	if m.InReplyTo != "" {
+		header("In-Reply-To", "<"+m.InReplyTo+">")
+		header("References", "<"+m.InReplyTo+">")
+	}
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	if m.Language != "" {
+		header("Content-Language", m.Language)
+	}
+	b.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(m.Body, "\n", "\r\n")))
+	qp.Close()
+	return b.Bytes()
+}
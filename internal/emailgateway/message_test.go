@@ -0,0 +1,138 @@
+package emailgateway
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChannelLocalpart(t *testing.T) {
+	tests := []struct {
+		addr  string
+		want  string
+		match bool
+	}{
+		{"general-1a2b3c@mail.example.com", "general-1a2b3c", true},
+		{"General-1A2B3C@MAIL.example.com", "general-1a2b3c", true},
+		{"general-1a2b3c+urgent@mail.example.com", "general-1a2b3c", true},
+		{"general@other.example.com", "", false},
+		{"@mail.example.com", "", false},
+		{"no-at-sign", "", false},
+	}
+	for _, tc := range tests {
+		got, ok := channelLocalpart(tc.addr, "mail.example.com")
+		if got != tc.want || ok != tc.match {
+			t.Errorf("channelLocalpart(%q) = %q, %v; want %q, %v", tc.addr, got, ok, tc.want, tc.match)
+		}
+	}
+}
+
+func TestStripQuotedReply(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			"attribution line",
+			"Sounds good.\n\nOn Tue, 3 Mar 2026 at 10:00, Alice <a@example.com> wrote:\n> original\n",
+			"Sounds good.",
+		},
+		{
+			"outlook separator",
+			"Thanks!\r\n\r\n-----Original Message-----\r\nFrom: Alice\r\n",
+			"Thanks!",
+		},
+		{
+			"trailing quote block",
+			"Yes.\n> earlier text\n>\n> more\n",
+			"Yes.",
+		},
+		{
+			"interleaved quotes are kept",
+			"> question one\nanswer one\n> question two\nanswer two",
+			"> question one\nanswer one\n> question two\nanswer two",
+		},
+		{
+			"no quote",
+			"Just a message.",
+			"Just a message.",
+		},
+	}
+	for _, tc := range tests {
+		if got := stripQuotedReply(tc.body); got != tc.want {
+			t.Errorf("%s: stripQuotedReply() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFormatInbound(t *testing.T) {
+	if got := formatInbound("Outage", "The site is down.", false); got != "**Outage**\n\nThe site is down." {
+		t.Errorf("new conversation = %q", got)
+	}
+	if got := formatInbound("Re: Outage", "Fixed now.", true); got != "Fixed now." {
+		t.Errorf("reply = %q", got)
+	}
+	if got := formatInbound("Photo", "", false); got != "**Photo**" {
+		t.Errorf("subject only = %q", got)
+	}
+
+	long := formatInbound("", strings.Repeat("é", maxContentLength+10), false)
+	if n := len([]rune(long)); n != maxContentLength {
+		t.Errorf("truncated length = %d runes, want %d", n, maxContentLength)
+	}
+}
+
+func TestReplySubject(t *testing.T) {
+	tests := map[string]string{
+		"Outage":      "Re: Outage",
+		"Re: Outage":  "Re: Outage",
+		"RE: Outage":  "RE: Outage",
+		"":            "Re: your message",
+		"  Question ": "Re: Question",
+	}
+	for in, want := range tests {
+		if got := replySubject(in); got != want {
+			t.Errorf("replySubject(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestOutboundEmailRender(t *testing.T) {
+	m := outboundEmail{
+		FromName:  "Zoë",
+		FromAddr:  "support-1a2b3c@mail.example.com",
+		To:        "alice@example.com",
+		Subject:   "Re: Outage",
+		MessageID: "01hqzx@mail.example.com",
+		InReplyTo: "abc123@example.com",
+		Body:      "Fixed now.\nThanks for the report.",
+		Date:      time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC),
+		Language:  "de-DE",
+	}
+	out := string(m.render())
+
+	for _, want := range []string{
+		"From: =?utf-8?q?Zo=C3=AB?= <support-1a2b3c@mail.example.com>\r\n",
+		"To: alice@example.com\r\n",
+		"Message-ID: <01hqzx@mail.example.com>\r\n",
+		"In-Reply-To: <abc123@example.com>\r\n",
+		"References: <abc123@example.com>\r\n",
+		"Content-Language: de-DE\r\n",
+		"\r\n\r\nFixed now.\r\nThanks for the report.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered email missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestExpandDownloadURL(t *testing.T) {
+	got := expandDownloadURL(
+		"https://mail.example.com/jmap/download/{accountId}/{blobId}/{name}?accept={type}",
+		"u1", "B42", "image/png", "my photo.png")
+	want := "https://mail.example.com/jmap/download/u1/B42/my%20photo.png?accept=image%2Fpng"
+	if got != want {
+		t.Errorf("expandDownloadURL = %q, want %q", got, want)
+	}
+}
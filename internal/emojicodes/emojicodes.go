@@ -0,0 +1,98 @@
+// Package emojicodes converts :shortcode: emoji in message content to their
+// unicode characters, so search, bridges and clients that do not know a
+// shortcode all see the same text. The standard shortcodes are data
+// (shortcodes.json); guilds may add their own aliases on top. Shortcodes
+// inside code blocks (``` ```) and inline code (` `) are literal text.
+package emojicodes
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/amityvox/amityvox/internal/codespans"
+)
+
+//go:embed shortcodes.json
+var shortcodesJSON []byte
+
+// standard maps each standard shortcode name, without colons, to its emoji.
+var standard map[string]string
+
+var nameRe = regexp.MustCompile(`^[a-z0-9_+\-]+$`)
+
+func init() {
+	if err := json.Unmarshal(shortcodesJSON, &standard); err != nil {
+		panic("emojicodes: parsing shortcodes.json: " + err.Error())
+	}
+}
+
+// Lookup returns the emoji for a standard shortcode name, without colons.
+func Lookup(name string) (string, bool) {
+	e, ok := standard[name]
+	return e, ok
+}
+
+// ValidName reports whether name may be used as a shortcode alias.
+func ValidName(name string) bool {
+	return len(name) <= 32 && nameRe.MatchString(name)
+}
+
+// Normalize replaces every known :shortcode: outside code with its emoji.
+// aliases, which may be nil, is checked before the standard shortcodes; an
+// empty value leaves that shortcode as written, for names a guild uses for
+// its own custom emoji. Unknown shortcodes are left alone.
+func Normalize(content string, aliases map[string]string) string {
+	if !strings.Contains(content, ":") {
+		return content
+	}
+	return codespans.EachOutside(content, func(text string) string {
+		return replaceShortcodes(text, aliases)
+	})
+}
+
+// replaceShortcodes scans text for :name: pairs. When a pair is not a known
+// shortcode its closing colon may still open the next one, as in "a:b:smile:".
+func replaceShortcodes(text string, aliases map[string]string) string {
+	var b strings.Builder
+	last, i := 0, strings.IndexByte(text, ':')
+	for i >= 0 {
+		j := strings.IndexByte(text[i+1:], ':')
+		if j < 0 {
+			break
+		}
+		j += i + 1
+		name := text[i+1 : j]
+		// "<:name:id>" is custom emoji markup, not a shortcode.
+		if emoji, ok := resolve(name, aliases); ok && (i == 0 || text[i-1] != '<') {
+			b.WriteString(text[last:i])
+			b.WriteString(emoji)
+			last = j + 1
+			if next := strings.IndexByte(text[last:], ':'); next >= 0 {
+				i = last + next
+			} else {
+				i = -1
+			}
+			continue
+		}
+		i = j
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// resolve returns the emoji to write for name, if any.
+func resolve(name string, aliases map[string]string) (string, bool) {
+	if name == "" || !nameRe.MatchString(name) {
+		return "", false
+	}
+	if emoji, ok := aliases[name]; ok {
+		return emoji, emoji != ""
+	}
+	emoji, ok := standard[name]
+	return emoji, ok
+}
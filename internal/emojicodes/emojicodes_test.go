@@ -0,0 +1,61 @@
+package emojicodes
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	aliases := map[string]string{
+		"yay":    "🎉",
+		"smile":  "😺",
+		"partyp": "",
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no shortcode", "hello world", "hello world"},
+		{"single", "nice :thumbsup:", "nice 👍"},
+		{"adjacent", ":fire::100:", "🔥💯"},
+		{"unknown", "see :nope: here", "see :nope: here"},
+		{"unknown before known", "a:b:fire:", "a:b🔥"},
+		{"time of day", "at 10:30:45", "at 10:30:45"},
+		{"uppercase", ":FIRE:", ":FIRE:"},
+		{"guild alias", ":yay:", "🎉"},
+		{"alias overrides standard", ":smile:", "😺"},
+		{"custom emoji name", ":partyp: :tada:", ":partyp: 🎉"},
+		{"custom emoji markup", "<:fire:01HXYZ>", "<:fire:01HXYZ>"},
+		{"inline code", "`:fire:` and :fire:", "`:fire:` and 🔥"},
+		{"code block", "```\n:fire:\n```", "```\n:fire:\n```"},
+		{"url", "https://example.com/:fire:", "https://example.com/🔥"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.content, aliases); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if e, ok := Lookup("+1"); !ok || e != "👍" {
+		t.Errorf("Lookup(+1) = %q, %v", e, ok)
+	}
+	if _, ok := Lookup("not_an_emoji"); ok {
+		t.Error("Lookup(not_an_emoji) found an emoji")
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"party_parrot": true,
+		"+1":           true,
+		"":             false,
+		"Has Caps":     false,
+		"a:b":          false,
+	} {
+		if got := ValidName(name); got != want {
+			t.Errorf("ValidName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
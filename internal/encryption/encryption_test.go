@@ -2,6 +2,12 @@ package encryption
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -183,3 +189,33 @@ func TestGroupState_ZeroEpoch(t *testing.T) {
 		t.Errorf("epoch = %d, want 0", decoded.Epoch)
 	}
 }
+
+func TestWriteRelayError(t *testing.T) {
+	s := NewService(Config{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{ErrNoKeyPackages, http.StatusNotFound, "no_key_packages"},
+		{ErrNotFederatedDM, http.StatusForbidden, "not_federated_dm"},
+		{fmt.Errorf("claim: %w", ErrPeerUnreachable), http.StatusServiceUnavailable, "peer_unreachable"},
+		{errors.New("boom"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		s.writeRelayError(w, tc.err)
+		if w.Code != tc.status {
+			t.Errorf("%v: status = %d, want %d", tc.err, w.Code, tc.status)
+		}
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if body.Error.Code != tc.code {
+			t.Errorf("%v: code = %q, want %q", tc.err, body.Error.Code, tc.code)
+		}
+	}
+}
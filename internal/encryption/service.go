@@ -1,7 +1,9 @@
 package encryption
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -21,8 +23,39 @@ import (
 type Service struct {
 	pool   *pgxpool.Pool
 	logger *slog.Logger
+
+	// FederatedDMs, when set, carries key package claims, Welcome messages and
+	// Commits to the instances of remote participants in federated DMs.
+	FederatedDMs FederatedDMRelay
 }
 
+// FederatedDMRelay negotiates MLS sessions with DM participants hosted on
+// other instances. It is implemented by the federation sync service.
+type FederatedDMRelay interface {
+	// ClaimRemoteKeyPackage claims a key package of userID from their home
+	// instance for use in channelID. It reports false if userID is local.
+	ClaimRemoteKeyPackage(ctx context.Context, callerID, channelID, userID string) (*KeyPackage, bool, error)
+
+	// RelayWelcome delivers a Welcome to receiverID's home instance. It
+	// reports false if receiverID is local.
+	RelayWelcome(ctx context.Context, senderID, channelID, receiverID string, data []byte) (bool, error)
+
+	// RelayCommit forwards a local user's Commit to the other instances of a
+	// federated DM. It does nothing for other channels.
+	RelayCommit(ctx context.Context, commit Commit)
+}
+
+// Errors returned by a FederatedDMRelay.
+var (
+	ErrNoKeyPackages   = errors.New("no available key packages")
+	ErrNotFederatedDM  = errors.New("not a federated DM shared with the user")
+	ErrPeerUnreachable = errors.New("remote instance unreachable")
+)
+
+// peerRetryAfter is the Retry-After, in seconds, sent when a remote
+// participant's instance cannot be reached.
+const peerRetryAfter = "30"
+
 // Config holds configuration for the encryption service.
 type Config struct {
 	Pool   *pgxpool.Pool
@@ -127,9 +160,24 @@ func (s *Service) HandleGetKeyPackages(w http.ResponseWriter, r *http.Request) {
 // HandleClaimKeyPackage handles POST /api/v1/encryption/key-packages/{userID}/claim.
 // Claims (consumes) one key package for the target user. This is used when adding
 // a user to an encrypted group — the key package is consumed so it can't be reused.
+// Users on other instances are claimed from their home instance and need
+// ?channel_id= naming the federated DM the session is for.
 func (s *Service) HandleClaimKeyPackage(w http.ResponseWriter, r *http.Request) {
 	targetUserID := chi.URLParam(r, "userID")
 
+	if s.FederatedDMs != nil {
+		callerID := auth.UserIDFromContext(r.Context())
+		kp, remote, err := s.FederatedDMs.ClaimRemoteKeyPackage(r.Context(), callerID, r.URL.Query().Get("channel_id"), targetUserID)
+		if remote || err != nil {
+			if err != nil {
+				s.writeRelayError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, kp)
+			return
+		}
+	}
+
 	// Claim one non-expired key package via DELETE RETURNING.
 	var kp KeyPackage
 	err := s.pool.QueryRow(r.Context(),
@@ -200,6 +248,26 @@ func (s *Service) HandleSendWelcome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A Welcome for a user on another instance is delivered there, with
+	// retries if that instance is down, instead of being stored here.
+	if s.FederatedDMs != nil {
+		senderID := auth.UserIDFromContext(r.Context())
+		remote, err := s.FederatedDMs.RelayWelcome(r.Context(), senderID, channelID, req.ReceiverID, req.Data)
+		if err != nil {
+			s.writeRelayError(w, err)
+			return
+		}
+		if remote {
+			writeJSON(w, http.StatusAccepted, WelcomeMessage{
+				ChannelID:  channelID,
+				ReceiverID: req.ReceiverID,
+				Data:       req.Data,
+				CreatedAt:  time.Now().UTC(),
+			})
+			return
+		}
+	}
+
 	id := models.NewULID().String()
 	_, err := s.pool.Exec(r.Context(),
 		`INSERT INTO mls_welcome_messages (id, channel_id, receiver_id, data, created_at)
@@ -374,14 +442,19 @@ func (s *Service) HandlePublishCommit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, Commit{
+	commit := Commit{
 		ID:        id,
 		ChannelID: channelID,
 		SenderID:  userID,
 		Epoch:     req.Epoch,
 		Data:      req.Data,
 		CreatedAt: time.Now().UTC(),
-	})
+	}
+	if s.FederatedDMs != nil {
+		s.FederatedDMs.RelayCommit(r.Context(), commit)
+	}
+
+	writeJSON(w, http.StatusCreated, commit)
 }
 
 // HandleGetCommits handles GET /api/v1/encryption/channels/{channelID}/commits.
@@ -426,6 +499,22 @@ func (s *Service) HandleGetCommits(w http.ResponseWriter, r *http.Request) {
 
 // --- Helpers ---
 
+// writeRelayError maps a FederatedDMRelay error to a response.
+func (s *Service) writeRelayError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNoKeyPackages):
+		writeError(w, http.StatusNotFound, "no_key_packages", "No available key packages for this user")
+	case errors.Is(err, ErrNotFederatedDM):
+		writeError(w, http.StatusForbidden, "not_federated_dm", "The user is on another instance; channel_id must name a federated DM you share with them")
+	case errors.Is(err, ErrPeerUnreachable):
+		w.Header().Set("Retry-After", peerRetryAfter)
+		writeError(w, http.StatusServiceUnavailable, "peer_unreachable", "The user's instance is unreachable; try again later")
+	default:
+		s.logger.Error("federated MLS relay failed", slog.String("error", err.Error()))
+		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to reach the user's instance")
+	}
+}
+
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
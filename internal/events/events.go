@@ -9,9 +9,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
+
+	"github.com/amityvox/amityvox/internal/slo"
 )
 
 // Subject constants define the NATS subject hierarchy for all event types.
@@ -26,6 +29,9 @@ const (
 	SubjectMessageReactionDel  = "amityvox.message.reaction_remove"
 	SubjectMessageReactionClr  = "amityvox.message.reaction_clear"
 	SubjectMessageEmbedUpdate  = "amityvox.message.embed_update"
+	SubjectMessageImport       = "amityvox.message.import"
+	SubjectMessageTranslation  = "amityvox.message.translation"
+	SubjectMessageBurn         = "amityvox.message.burn"
 
 	// Channel events.
 	SubjectChannelCreate     = "amityvox.channel.create"
@@ -35,18 +41,20 @@ const (
 	SubjectTypingStart       = "amityvox.channel.typing_start"
 
 	// Guild events.
-	SubjectGuildCreate       = "amityvox.guild.create"
-	SubjectGuildUpdate       = "amityvox.guild.update"
-	SubjectGuildDelete       = "amityvox.guild.delete"
-	SubjectGuildMemberAdd    = "amityvox.guild.member_add"
-	SubjectGuildMemberUpdate = "amityvox.guild.member_update"
-	SubjectGuildMemberRemove = "amityvox.guild.member_remove"
-	SubjectGuildRoleCreate   = "amityvox.guild.role_create"
-	SubjectGuildRoleUpdate   = "amityvox.guild.role_update"
-	SubjectGuildRoleDelete   = "amityvox.guild.role_delete"
-	SubjectGuildBanAdd       = "amityvox.guild.ban_add"
-	SubjectGuildBanRemove    = "amityvox.guild.ban_remove"
-	SubjectGuildEmojiUpdate  = "amityvox.guild.emoji_update"
+	SubjectGuildCreate         = "amityvox.guild.create"
+	SubjectGuildUpdate         = "amityvox.guild.update"
+	SubjectGuildDelete         = "amityvox.guild.delete"
+	SubjectGuildMemberAdd      = "amityvox.guild.member_add"
+	SubjectGuildMemberUpdate   = "amityvox.guild.member_update"
+	SubjectGuildMemberRemove   = "amityvox.guild.member_remove"
+	SubjectGuildRoleCreate     = "amityvox.guild.role_create"
+	SubjectGuildRoleUpdate     = "amityvox.guild.role_update"
+	SubjectGuildRoleDelete     = "amityvox.guild.role_delete"
+	SubjectGuildBanAdd         = "amityvox.guild.ban_add"
+	SubjectGuildBanRemove      = "amityvox.guild.ban_remove"
+	SubjectGuildEmojiUpdate    = "amityvox.guild.emoji_update"
+	SubjectGuildImportProgress = "amityvox.guild.import_progress"
+	SubjectGuildCommunity      = "amityvox.guild.community_update"
 
 	// Guild channel group events.
 	SubjectChannelGroupCreate      = "amityvox.guild.channel_group_create"
@@ -54,23 +62,43 @@ const (
 	SubjectChannelGroupDelete      = "amityvox.guild.channel_group_delete"
 	SubjectChannelGroupItemsUpdate = "amityvox.guild.channel_group_items_update"
 
+	// Guild channel layout events.
+	SubjectChannelPositionsUpdate = "amityvox.guild.channel_positions_update"
+
 	// User/presence events.
 	SubjectPresenceUpdate      = "amityvox.presence.update"
 	SubjectUserUpdate          = "amityvox.user.update"
 	SubjectRelationshipAdd     = "amityvox.user.relationship_add"
 	SubjectRelationshipUpdate  = "amityvox.user.relationship_update"
 	SubjectRelationshipRemove  = "amityvox.user.relationship_remove"
+	SubjectUserSessionsRevoked = "amityvox.user.sessions_revoked"
+	SubjectBotCapabilities     = "amityvox.user.bot_capabilities"
+	SubjectInteractionCreate   = "amityvox.user.interaction_create"
+	SubjectFederatedDMRequest  = "amityvox.user.federated_dm_request"
+	SubjectGuildLayoutUpdate   = "amityvox.user.guild_layout_update"
+	SubjectGroupDMInvite       = "amityvox.user.group_dm_invite"
+	SubjectBotPresenceRequest  = "amityvox.user.bot_presence_request"
+	SubjectCommunityInvite     = "amityvox.user.community_invite"
+
+	// Message events for shadow-quarantined users' messages. They keep their
+	// MESSAGE_CREATE/MESSAGE_UPDATE type but are routed only to the author and
+	// live outside amityvox.message.> so search, notifications, webhooks, and
+	// federation never see them.
+	SubjectQuarantinedMessage = "amityvox.user.quarantined_message"
 
 	// Voice events.
 	SubjectVoiceStateUpdate  = "amityvox.voice.state_update"
 	SubjectVoiceServerUpdate = "amityvox.voice.server_update"
 	SubjectCallRing          = "amityvox.voice.call_ring"
+	SubjectCallUpdate        = "amityvox.voice.call_update"
 
 	// Read state events.
 	SubjectChannelAck = "amityvox.channel.ack"
+	SubjectDMReceipt  = "amityvox.user.dm_receipt" // delivery and read status for a DM message's author
 
 	// AutoMod events.
 	SubjectAutomodAction = "amityvox.automod.action"
+	SubjectLinkFlag      = "amityvox.automod.link_flag"
 
 	// Poll events.
 	SubjectPollCreate = "amityvox.poll.create"
@@ -89,6 +117,12 @@ const (
 	SubjectAnnouncementCreate = "amityvox.announcement.create"
 	SubjectAnnouncementUpdate = "amityvox.announcement.update"
 	SubjectAnnouncementDelete = "amityvox.announcement.delete"
+	SubjectPolicyPublish      = "amityvox.announcement.policy_publish"
+	SubjectMaintenance        = "amityvox.announcement.maintenance"
+	SubjectMOTDUpdate         = "amityvox.announcement.motd"
+	SubjectSubsystemsUpdate   = "amityvox.announcement.subsystems"
+	SubjectFeatureFlagsUpdate = "amityvox.announcement.feature_flags"
+	SubjectInstanceEmoji      = "amityvox.announcement.instance_emoji"
 
 	// Notification events (server-generated, dispatched to specific users).
 	SubjectNotificationCreate = "amityvox.notification.create"
@@ -97,6 +131,9 @@ const (
 
 	// Federation events.
 	SubjectFederationRetry = "amityvox.federation.retry"
+
+	// Operational alerts, dispatched to each instance admin.
+	SubjectAdminAlert = "amityvox.user.admin_alert"
 )
 
 // Event is the envelope for all events published through NATS. It mirrors the
@@ -108,6 +145,14 @@ type Event struct {
 	ChannelID string          `json:"channel_id,omitempty"`
 	UserID    string          `json:"user_id,omitempty"`
 	Data      json.RawMessage `json:"d"`
+
+	// OriginInstanceID is set on events received from a federation peer so
+	// the federation router does not send them back out.
+	OriginInstanceID string `json:"origin_instance_id,omitempty"`
+
+	// TracedAt is when the request behind a sampled message send arrived,
+	// in Unix nanoseconds, so consumers can report delivery latency.
+	TracedAt int64 `json:"traced_at,omitempty"`
 }
 
 // Bus wraps a NATS connection and provides publish/subscribe methods for the
@@ -117,6 +162,11 @@ type Bus struct {
 	conn   *nats.Conn
 	js     nats.JetStreamContext
 	logger *slog.Logger
+
+	// consumers tracks durable JetStream consumers so they can be recreated
+	// after stream changes; see jetstream.go.
+	consumers   map[string]*durableConsumer
+	consumersMu sync.Mutex
 }
 
 // New connects to the NATS server at the given URL and returns an event Bus.
@@ -155,12 +205,17 @@ func New(natsURL string, logger *slog.Logger) (*Bus, error) {
 	return &Bus{conn: nc, js: js, logger: logger}, nil
 }
 
-// EnsureStreams creates the JetStream streams required by AmityVox if they don't
-// already exist. Call this during server startup.
-func (b *Bus) EnsureStreams() error {
-	streams := []nats.StreamConfig{
+// JetStream stream names.
+const (
+	StreamEvents     = "AMITYVOX_EVENTS"
+	StreamFederation = "AMITYVOX_FEDERATION"
+)
+
+// StreamConfigs returns the JetStream streams AmityVox expects to exist.
+func StreamConfigs() []nats.StreamConfig {
+	return []nats.StreamConfig{
 		{
-			Name: "AMITYVOX_EVENTS",
+			Name: StreamEvents,
 			Subjects: []string{
 				"amityvox.message.>",
 				"amityvox.channel.>",
@@ -179,7 +234,7 @@ func (b *Bus) EnsureStreams() error {
 			Replicas:  1,
 		},
 		{
-			Name:      "AMITYVOX_FEDERATION",
+			Name:      StreamFederation,
 			Subjects:  []string{"amityvox.federation.>"},
 			Retention: nats.WorkQueuePolicy,
 			MaxAge:    7 * 24 * time.Hour,
@@ -187,8 +242,12 @@ func (b *Bus) EnsureStreams() error {
 			Replicas:  1,
 		},
 	}
+}
 
-	for _, cfg := range streams {
+// EnsureStreams creates the JetStream streams required by AmityVox if they don't
+// already exist. Call this during server startup.
+func (b *Bus) EnsureStreams() error {
+	for _, cfg := range StreamConfigs() {
 		info, err := b.js.StreamInfo(cfg.Name)
 		if err != nil && err != nats.ErrStreamNotFound {
 			return fmt.Errorf("checking stream %s: %w", cfg.Name, err)
@@ -208,8 +267,12 @@ func (b *Bus) EnsureStreams() error {
 }
 
 // Publish sends an event to the specified NATS subject. The event data is JSON
-// encoded before publishing.
-func (b *Bus) Publish(_ context.Context, subject string, event Event) error {
+// encoded before publishing. If ctx carries a traced send, the event is
+// stamped with its start time.
+func (b *Bus) Publish(ctx context.Context, subject string, event Event) error {
+	if start, ok := slo.Start(ctx); ok && event.TracedAt == 0 {
+		event.TracedAt = start.UnixNano()
+	}
 	data, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("marshaling event for %s: %w", subject, err)
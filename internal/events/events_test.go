@@ -124,3 +124,26 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestStreamConfigs_Names(t *testing.T) {
+	for _, name := range []string{StreamEvents, StreamFederation} {
+		if !isKnownStream(name) {
+			t.Errorf("stream %s missing from StreamConfigs", name)
+		}
+	}
+	if isKnownStream("OTHER") {
+		t.Error("unexpected stream OTHER reported as known")
+	}
+}
+
+func TestSameSubjects(t *testing.T) {
+	if !sameSubjects([]string{"a.>", "b.>"}, []string{"b.>", "a.>"}) {
+		t.Error("reordered subjects should match")
+	}
+	if sameSubjects([]string{"a.>"}, []string{"a.>", "b.>"}) {
+		t.Error("added subject should not match")
+	}
+	if sameSubjects([]string{"a.>", "b.>"}, []string{"a.>", "c.>"}) {
+		t.Error("changed subject should not match")
+	}
+}
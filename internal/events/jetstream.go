@@ -0,0 +1,251 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// durableConsumer is a registered durable JetStream subscription and the
+// function that recreates it.
+type durableConsumer struct {
+	stream    string
+	durable   string
+	subscribe func(opts ...nats.SubOpt) (*nats.Subscription, error)
+	sub       *nats.Subscription
+}
+
+// StreamStatus summarizes a JetStream stream and its consumers.
+type StreamStatus struct {
+	Name      string           `json:"name"`
+	Subjects  []string         `json:"subjects"`
+	Retention string           `json:"retention"`
+	MaxAge    string           `json:"max_age"`
+	Created   time.Time        `json:"created"`
+	Messages  uint64           `json:"messages"`
+	Bytes     uint64           `json:"bytes"`
+	FirstSeq  uint64           `json:"first_seq"`
+	LastSeq   uint64           `json:"last_seq"`
+	LastTime  time.Time        `json:"last_time"`
+	Consumers []ConsumerStatus `json:"consumers"`
+}
+
+// ConsumerStatus summarizes a JetStream consumer's backlog.
+type ConsumerStatus struct {
+	Name         string     `json:"name"`
+	Pending      uint64     `json:"pending"`
+	AckPending   int        `json:"ack_pending"`
+	Redelivered  int        `json:"redelivered"`
+	DeliveredSeq uint64     `json:"delivered_seq"`
+	AckFloorSeq  uint64     `json:"ack_floor_seq"`
+	LastActive   *time.Time `json:"last_active,omitempty"`
+	Registered   bool       `json:"registered"`
+}
+
+// SubscribeDurable creates a durable JetStream subscription through subscribe
+// and registers it, so ReconcileStreams and ReplayConsumer can recreate it
+// with the same handler. subscribe must pass the given options through to
+// the JetStream subscribe call.
+func (b *Bus) SubscribeDurable(stream, durable string, subscribe func(opts ...nats.SubOpt) (*nats.Subscription, error)) (*nats.Subscription, error) {
+	sub, err := subscribe()
+	if err != nil {
+		return nil, err
+	}
+	b.consumersMu.Lock()
+	if b.consumers == nil {
+		b.consumers = make(map[string]*durableConsumer)
+	}
+	b.consumers[durable] = &durableConsumer{stream: stream, durable: durable, subscribe: subscribe, sub: sub}
+	b.consumersMu.Unlock()
+	return sub, nil
+}
+
+// RecreateConsumer drops a registered durable consumer and subscribes again.
+// Extra options, e.g. nats.StartSequence, control where delivery resumes;
+// without them the new consumer starts from the beginning of the stream.
+func (b *Bus) RecreateConsumer(durable string, opts ...nats.SubOpt) error {
+	b.consumersMu.Lock()
+	defer b.consumersMu.Unlock()
+
+	dc, ok := b.consumers[durable]
+	if !ok {
+		return fmt.Errorf("consumer %s is not registered", durable)
+	}
+	if dc.sub != nil {
+		dc.sub.Unsubscribe()
+	}
+	if err := b.js.DeleteConsumer(dc.stream, dc.durable); err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+		return fmt.Errorf("deleting consumer %s: %w", durable, err)
+	}
+	sub, err := dc.subscribe(opts...)
+	if err != nil {
+		dc.sub = nil
+		return fmt.Errorf("resubscribing consumer %s: %w", durable, err)
+	}
+	dc.sub = sub
+	b.logger.Info("JetStream consumer recreated",
+		slog.String("stream", dc.stream), slog.String("consumer", durable))
+	return nil
+}
+
+// RegisteredConsumers returns the durable names registered on this bus, keyed
+// to their stream.
+func (b *Bus) RegisteredConsumers() map[string]string {
+	b.consumersMu.Lock()
+	defer b.consumersMu.Unlock()
+	out := make(map[string]string, len(b.consumers))
+	for name, dc := range b.consumers {
+		out[name] = dc.stream
+	}
+	return out
+}
+
+// StreamStatuses reports every expected stream and its consumers.
+func (b *Bus) StreamStatuses() ([]StreamStatus, error) {
+	registered := b.RegisteredConsumers()
+	statuses := make([]StreamStatus, 0)
+	for _, cfg := range StreamConfigs() {
+		info, err := b.js.StreamInfo(cfg.Name)
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("getting stream %s: %w", cfg.Name, err)
+		}
+		st := StreamStatus{
+			Name:      info.Config.Name,
+			Subjects:  info.Config.Subjects,
+			Retention: info.Config.Retention.String(),
+			MaxAge:    info.Config.MaxAge.String(),
+			Created:   info.Created,
+			Messages:  info.State.Msgs,
+			Bytes:     info.State.Bytes,
+			FirstSeq:  info.State.FirstSeq,
+			LastSeq:   info.State.LastSeq,
+			LastTime:  info.State.LastTime,
+			Consumers: make([]ConsumerStatus, 0),
+		}
+		for ci := range b.js.Consumers(cfg.Name) {
+			_, isRegistered := registered[ci.Name]
+			st.Consumers = append(st.Consumers, ConsumerStatus{
+				Name:         ci.Name,
+				Pending:      ci.NumPending,
+				AckPending:   ci.NumAckPending,
+				Redelivered:  ci.NumRedelivered,
+				DeliveredSeq: ci.Delivered.Stream,
+				AckFloorSeq:  ci.AckFloor.Stream,
+				LastActive:   ci.Delivered.Last,
+				Registered:   isRegistered,
+			})
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// PurgeStream removes messages from an expected stream. A non-empty subject
+// limits the purge to matching messages.
+func (b *Bus) PurgeStream(name, subject string) error {
+	if !isKnownStream(name) {
+		return fmt.Errorf("unknown stream %s", name)
+	}
+	if subject == "" {
+		return b.js.PurgeStream(name)
+	}
+	return b.js.PurgeStream(name, &nats.StreamPurgeRequest{Subject: subject})
+}
+
+// ReplayConsumer recreates a registered consumer so it redelivers the stream
+// from startSeq, or from startTime when startSeq is zero.
+func (b *Bus) ReplayConsumer(durable string, startSeq uint64, startTime time.Time) error {
+	if startSeq > 0 {
+		return b.RecreateConsumer(durable, nats.StartSequence(startSeq))
+	}
+	if !startTime.IsZero() {
+		return b.RecreateConsumer(durable, nats.StartTime(startTime))
+	}
+	return b.RecreateConsumer(durable, nats.DeliverAll())
+}
+
+// StreamDrift describes how a stream on the server differs from the
+// configuration AmityVox expects.
+type StreamDrift struct {
+	Stream    string
+	Created   bool // the stream was missing and has been created
+	Updated   bool // subjects or limits were updated in place
+	Immutable bool // retention or storage differ and need manual migration
+}
+
+// ReconcileStreams brings the server's streams in line with StreamConfigs.
+// Missing streams are created and mutable settings are updated; retention and
+// storage cannot be changed in place and are only reported.
+func (b *Bus) ReconcileStreams() ([]StreamDrift, error) {
+	drifts := make([]StreamDrift, 0)
+	for _, want := range StreamConfigs() {
+		info, err := b.js.StreamInfo(want.Name)
+		if err != nil && !errors.Is(err, nats.ErrStreamNotFound) {
+			return drifts, fmt.Errorf("checking stream %s: %w", want.Name, err)
+		}
+		if info == nil {
+			cfg := want
+			if _, err := b.js.AddStream(&cfg); err != nil {
+				return drifts, fmt.Errorf("creating stream %s: %w", want.Name, err)
+			}
+			drifts = append(drifts, StreamDrift{Stream: want.Name, Created: true})
+			continue
+		}
+
+		have := info.Config
+		if have.Retention != want.Retention || have.Storage != want.Storage {
+			drifts = append(drifts, StreamDrift{Stream: want.Name, Immutable: true})
+			continue
+		}
+		if !sameSubjects(have.Subjects, want.Subjects) || have.MaxAge != want.MaxAge {
+			cfg := have
+			cfg.Subjects, cfg.MaxAge = want.Subjects, want.MaxAge
+			if _, err := b.js.UpdateStream(&cfg); err != nil {
+				return drifts, fmt.Errorf("updating stream %s: %w", want.Name, err)
+			}
+			drifts = append(drifts, StreamDrift{Stream: want.Name, Updated: true})
+		}
+	}
+	return drifts, nil
+}
+
+// ConsumerExists reports whether a durable consumer exists on its stream.
+func (b *Bus) ConsumerExists(stream, durable string) (bool, error) {
+	_, err := b.js.ConsumerInfo(stream, durable)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func isKnownStream(name string) bool {
+	for _, cfg := range StreamConfigs() {
+		if cfg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sameSubjects compares subject lists ignoring order.
+func sameSubjects(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, s := range a {
+		set[s] = true
+	}
+	for _, s := range b {
+		if !set[s] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+// Package featureflags rolls risky features out in stages. Each flag is on
+// for a percentage of users, guilds and federation peers, chosen by a stable
+// hash so a target stays in or out as the percentage grows, and can be
+// forced on or off for single targets. Flags live in the database and are
+// cached per node, so operators can widen a rollout or switch a feature off
+// without a deploy.
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// refresh is how long flags are cached per node.
+const refresh = 5 * time.Second
+
+// Target is what a flag is evaluated for. Empty fields are not considered:
+// a flag rolled out only to guilds is off for a Target without a GuildID.
+type Target struct {
+	UserID     string
+	GuildID    string
+	InstanceID string // the federation peer, for federation features
+}
+
+// Evaluate reports whether flag is on for target. A disabled flag is off.
+// Otherwise the most specific override wins (user, then guild, then
+// instance), and without one the flag is on if any of the target's IDs
+// falls within the rollout percentage for its kind.
+func Evaluate(flag models.FeatureFlag, target Target) bool {
+	if !flag.Enabled {
+		return false
+	}
+	for _, kind := range []struct {
+		targetType string
+		id         string
+	}{
+		{models.FeatureFlagTargetUser, target.UserID},
+		{models.FeatureFlagTargetGuild, target.GuildID},
+		{models.FeatureFlagTargetInstance, target.InstanceID},
+	} {
+		if kind.id == "" {
+			continue
+		}
+		for _, o := range flag.Overrides {
+			if o.TargetType == kind.targetType && o.TargetID == kind.id {
+				return o.Enabled
+			}
+		}
+	}
+	return inRollout(flag.Key, target.UserID, flag.UserPercent) ||
+		inRollout(flag.Key, target.GuildID, flag.GuildPercent) ||
+		inRollout(flag.Key, target.InstanceID, flag.InstancePercent)
+}
+
+// inRollout reports whether id falls within the first percent of the flag's
+// buckets. Hashing the key with the ID gives each flag its own rollout
+// order, so the same users are not always first.
+func inRollout(key, id string, percent int) bool {
+	if id == "" || percent <= 0 {
+		return false
+	}
+	return bucket(key, id) < percent
+}
+
+// bucket maps a flag and target to one of 100 buckets.
+func bucket(key, id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(id))
+	return int(h.Sum32() % 100)
+}
+
+// Store caches the flags and evaluates them. A nil Store has every flag off.
+type Store struct {
+	pool   *pgxpool.Pool
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	flags   map[string]models.FeatureFlag
+	checked time.Time
+}
+
+// NewStore creates a Store reading flags from pool.
+func NewStore(pool *pgxpool.Pool, logger *slog.Logger) *Store {
+	return &Store{pool: pool, logger: logger}
+}
+
+// Enabled reports whether the flag named key is on for target. Unknown
+// flags are off.
+func (s *Store) Enabled(ctx context.Context, key string, target Target) bool {
+	if s == nil {
+		return false
+	}
+	flag, ok := s.current(ctx)[key]
+	return ok && Evaluate(flag, target)
+}
+
+// ForUser returns the flags on for a user and, per guild, those on for the
+// user in that guild but not everywhere.
+func (s *Store) ForUser(ctx context.Context, userID string, guildIDs []string) models.FeatureFlagState {
+	state := models.FeatureFlagState{Flags: []string{}, Guilds: map[string][]string{}}
+	if s == nil {
+		return state
+	}
+	flags := s.current(ctx)
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		flag := flags[key]
+		if Evaluate(flag, Target{UserID: userID}) {
+			state.Flags = append(state.Flags, key)
+			continue
+		}
+		for _, guildID := range guildIDs {
+			if Evaluate(flag, Target{UserID: userID, GuildID: guildID}) {
+				state.Guilds[guildID] = append(state.Guilds[guildID], key)
+			}
+		}
+	}
+	return state
+}
+
+// Invalidate drops the cached flags so the next evaluation reloads them.
+func (s *Store) Invalidate() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.checked = time.Time{}
+	s.mu.Unlock()
+}
+
+// Watch drops the cached flags whenever an operator changes them, so nodes
+// apply the change at once rather than when the cache expires.
+func (s *Store) Watch(bus *events.Bus) error {
+	_, err := bus.Subscribe(events.SubjectFeatureFlagsUpdate, func(events.Event) {
+		s.Invalidate()
+	})
+	return err
+}
+
+// current returns the cached flags, reloading them when stale. On lookup
+// errors the last known flags stay in effect.
+func (s *Store) current(ctx context.Context) map[string]models.FeatureFlag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.checked) < refresh {
+		return s.flags
+	}
+	flags, err := Load(ctx, s.pool)
+	if err != nil {
+		s.logger.Warn("loading feature flags failed", slog.String("error", err.Error()))
+	} else {
+		s.flags = make(map[string]models.FeatureFlag, len(flags))
+		for _, f := range flags {
+			s.flags[f.Key] = f
+		}
+	}
+	s.checked = time.Now()
+	return s.flags
+}
+
+// Load returns every flag with its overrides, ordered by key.
+func Load(ctx context.Context, pool *pgxpool.Pool) ([]models.FeatureFlag, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT key, description, enabled, user_percent, guild_percent, instance_percent,
+		        created_at, updated_at
+		 FROM feature_flags ORDER BY key`)
+	if err != nil {
+		return nil, fmt.Errorf("loading feature flags: %w", err)
+	}
+	flags := []models.FeatureFlag{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var f models.FeatureFlag
+		if err := rows.Scan(&f.Key, &f.Description, &f.Enabled, &f.UserPercent, &f.GuildPercent,
+			&f.InstancePercent, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("reading feature flag: %w", err)
+		}
+		f.Overrides = []models.FeatureFlagOverride{}
+		index[f.Key] = len(flags)
+		flags = append(flags, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("loading feature flags: %w", err)
+	}
+
+	rows, err = pool.Query(ctx,
+		`SELECT flag_key, target_type, target_id, enabled, created_at
+		 FROM feature_flag_overrides ORDER BY flag_key, target_type, target_id`)
+	if err != nil {
+		return nil, fmt.Errorf("loading feature flag overrides: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var o models.FeatureFlagOverride
+		if err := rows.Scan(&key, &o.TargetType, &o.TargetID, &o.Enabled, &o.CreatedAt); err != nil {
+			return nil, fmt.Errorf("reading feature flag override: %w", err)
+		}
+		if i, ok := index[key]; ok {
+			flags[i].Overrides = append(flags[i].Overrides, o)
+		}
+	}
+	return flags, rows.Err()
+}
@@ -0,0 +1,86 @@
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+func TestEvaluate(t *testing.T) {
+	override := func(targetType, id string, enabled bool) models.FeatureFlagOverride {
+		return models.FeatureFlagOverride{TargetType: targetType, TargetID: id, Enabled: enabled}
+	}
+	tests := []struct {
+		name   string
+		flag   models.FeatureFlag
+		target Target
+		want   bool
+	}{
+		{"no rollout", models.FeatureFlag{Enabled: true}, Target{UserID: "u1"}, false},
+		{"everyone", models.FeatureFlag{Enabled: true, UserPercent: 100}, Target{UserID: "u1"}, true},
+		{"switched off", models.FeatureFlag{UserPercent: 100}, Target{UserID: "u1"}, false},
+		{"switched off beats override", models.FeatureFlag{
+			Overrides: []models.FeatureFlagOverride{override(models.FeatureFlagTargetUser, "u1", true)},
+		}, Target{UserID: "u1"}, false},
+		{"user override", models.FeatureFlag{Enabled: true,
+			Overrides: []models.FeatureFlagOverride{override(models.FeatureFlagTargetUser, "u1", true)},
+		}, Target{UserID: "u1"}, true},
+		{"user override beats guild override", models.FeatureFlag{Enabled: true,
+			Overrides: []models.FeatureFlagOverride{
+				override(models.FeatureFlagTargetGuild, "g1", true),
+				override(models.FeatureFlagTargetUser, "u1", false),
+			},
+		}, Target{UserID: "u1", GuildID: "g1"}, false},
+		{"override beats rollout", models.FeatureFlag{Enabled: true, GuildPercent: 100,
+			Overrides: []models.FeatureFlagOverride{override(models.FeatureFlagTargetGuild, "g1", false)},
+		}, Target{UserID: "u1", GuildID: "g1"}, false},
+		{"guild rollout needs a guild", models.FeatureFlag{Enabled: true, GuildPercent: 100}, Target{UserID: "u1"}, false},
+		{"instance rollout", models.FeatureFlag{Enabled: true, InstancePercent: 100}, Target{InstanceID: "i1"}, true},
+		{"instance override", models.FeatureFlag{Enabled: true,
+			Overrides: []models.FeatureFlagOverride{override(models.FeatureFlagTargetInstance, "i1", true)},
+		}, Target{InstanceID: "i1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Evaluate(tt.flag, tt.target); got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRolloutIsStableAndProportional(t *testing.T) {
+	flag := models.FeatureFlag{Key: "new_thing", Enabled: true, UserPercent: 25}
+	wider := flag
+	wider.UserPercent = 50
+
+	on := 0
+	for i := 0; i < 10000; i++ {
+		target := Target{UserID: fmt.Sprintf("user-%d", i)}
+		got := Evaluate(flag, target)
+		if got != Evaluate(flag, target) {
+			t.Fatalf("evaluation of %s is not stable", target.UserID)
+		}
+		if got {
+			on++
+			if !Evaluate(wider, target) {
+				t.Fatalf("%s dropped out when the rollout widened", target.UserID)
+			}
+		}
+	}
+	if on < 2200 || on > 2800 {
+		t.Errorf("25%% rollout enabled %d of 10000 users", on)
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	if s.Enabled(context.Background(), "anything", Target{UserID: "u1"}) {
+		t.Error("a nil store has every flag off")
+	}
+	if state := s.ForUser(context.Background(), "u1", []string{"g1"}); len(state.Flags) != 0 || len(state.Guilds) != 0 {
+		t.Errorf("ForUser() = %+v, want nothing enabled", state)
+	}
+}
@@ -0,0 +1,371 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// DirectoryListedSetting is the instance_settings key that opts this
+// instance in to the federated instance directory ("true" or "false").
+const DirectoryListedSetting = "directory_listed"
+
+const (
+	// directoryMaxFetches bounds how many instances one refresh contacts.
+	directoryMaxFetches = 200
+	// directoryMaxKnown bounds the known domains read from one response.
+	directoryMaxKnown = 50
+	// directoryMaxFeatured is how many guilds an entry may feature.
+	directoryMaxFeatured = 5
+	// directoryStaleAfter is how long an entry that cannot be refreshed is
+	// kept before it is dropped.
+	directoryStaleAfter = 30 * 24 * time.Hour
+	// directoryRefreshInterval is how often StartDirectoryRefresher runs.
+	directoryRefreshInterval = 6 * time.Hour
+	// maxRecommendedGuilds is how many remote guilds discovery recommends.
+	maxRecommendedGuilds = 20
+)
+
+// errNotListed is returned by fetchDirectory when the instance is not in the
+// directory.
+var errNotListed = errors.New("instance is not listed")
+
+// DirectoryResponse is the payload of /federation/v1/directory: the
+// instance's own entry and the domains of other listed instances it knows
+// about. Known domains are only hints; their entries are always fetched
+// from the instances themselves.
+type DirectoryResponse struct {
+	Instance     models.DirectoryEntry `json:"instance"`
+	KnownDomains []string              `json:"known_domains"`
+}
+
+// DirectoryListed reports whether the instance opted in to the directory.
+func (s *Service) DirectoryListed(ctx context.Context) bool {
+	var value string
+	s.pool.QueryRow(ctx,
+		`SELECT value FROM instance_settings WHERE key = $1`, DirectoryListedSetting).Scan(&value)
+	return value == "true"
+}
+
+// LocalDirectoryEntry returns this instance's directory entry: its name and
+// description, local user count, federation mode and its largest
+// discoverable guilds.
+func (s *Service) LocalDirectoryEntry(ctx context.Context) (models.DirectoryEntry, error) {
+	e := models.DirectoryEntry{InstanceID: s.instanceID, FeaturedGuilds: []models.DirectoryGuild{}}
+	err := s.pool.QueryRow(ctx,
+		`SELECT domain, name, description, COALESCE(federation_mode, 'open'), software_version,
+		        (SELECT COUNT(*) FROM users WHERE instance_id = $1)
+		 FROM instances WHERE id = $1`, s.instanceID,
+	).Scan(&e.Domain, &e.Name, &e.Description, &e.FederationMode, &e.SoftwareVersion, &e.UserCount)
+	if err != nil {
+		return e, fmt.Errorf("loading local instance: %w", err)
+	}
+
+	rows, err := s.pool.Query(ctx,
+		`SELECT id, name, description, icon_id, member_count FROM guilds
+		 WHERE discoverable = true AND instance_id IS NULL
+		 ORDER BY member_count DESC LIMIT $1`, directoryMaxFeatured)
+	if err != nil {
+		return e, fmt.Errorf("loading featured guilds: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var g models.DirectoryGuild
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.IconID, &g.MemberCount); err != nil {
+			return e, fmt.Errorf("reading featured guilds: %w", err)
+		}
+		e.FeaturedGuilds = append(e.FeaturedGuilds, g)
+	}
+	return e, rows.Err()
+}
+
+// HandleDirectory handles GET /federation/v1/directory — the public
+// directory entry of an instance that opted in. Instances that did not
+// answer 404, which tells refreshers to drop them.
+func (s *Service) HandleDirectory(w http.ResponseWriter, r *http.Request) {
+	if !s.DirectoryListed(r.Context()) {
+		http.Error(w, "Not listed", http.StatusNotFound)
+		return
+	}
+
+	entry, err := s.LocalDirectoryEntry(r.Context())
+	if err != nil {
+		s.logger.Error("federation directory: failed to build entry", slog.String("error", err.Error()))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	known := make([]string, 0)
+	rows, err := s.pool.Query(r.Context(),
+		`SELECT domain FROM instance_directory ORDER BY fetched_at DESC LIMIT $1`, directoryMaxKnown)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var domain string
+			if rows.Scan(&domain) == nil {
+				known = append(known, domain)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(DirectoryResponse{Instance: entry, KnownDomains: known})
+}
+
+// sanitizeDirectoryEntry makes a fetched entry safe to store: the domain is
+// the one it was fetched from, whatever the entry claims, and free text and
+// the featured guilds are bounded.
+func sanitizeDirectoryEntry(e models.DirectoryEntry, domain string) models.DirectoryEntry {
+	e.Domain = domain
+	e.Name = truncateText(e.Name, 100)
+	e.Description = truncateText(e.Description, 1000)
+	if len(e.FeaturedGuilds) > directoryMaxFeatured {
+		e.FeaturedGuilds = e.FeaturedGuilds[:directoryMaxFeatured]
+	}
+	for i := range e.FeaturedGuilds {
+		g := &e.FeaturedGuilds[i]
+		if len(g.Name) > 100 {
+			g.Name = g.Name[:100]
+		}
+		g.Description = truncateText(g.Description, 1000)
+	}
+	if e.FeaturedGuilds == nil {
+		e.FeaturedGuilds = []models.DirectoryGuild{}
+	}
+	return e
+}
+
+func truncateText(s *string, n int) *string {
+	if s == nil || len(*s) <= n {
+		return s
+	}
+	t := (*s)[:n]
+	return &t
+}
+
+// fetchDirectory fetches a remote instance's directory entry. It returns
+// errNotListed if the instance answered 404.
+func fetchDirectory(ctx context.Context, domain string) (*DirectoryResponse, error) {
+	if err := ValidateFederationDomain(domain); err != nil {
+		return nil, fmt.Errorf("domain validation failed: %w", err)
+	}
+
+	target := (&url.URL{Scheme: "https", Host: domain, Path: "/federation/v1/directory"}).String()
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "AmityVox/1.0 (+federation)")
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			return errors.New("redirects are not followed")
+		},
+	}
+	resp, err := client.Do(req) // SSRF validated: domain checked by ValidateFederationDomain above
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotListed
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("instance %s returned status %d", domain, resp.StatusCode)
+	}
+
+	var dir DirectoryResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&dir); err != nil {
+		return nil, fmt.Errorf("decoding response from %s: %w", domain, err)
+	}
+	return &dir, nil
+}
+
+// RefreshDirectory re-fetches the directory entries of active peers, of
+// instances already in the directory and of the instances they know about,
+// up to directoryMaxFetches per run. Instances that stopped listing
+// themselves are removed, as are entries that could not be refreshed for
+// directoryStaleAfter. Blocked instances are never contacted. It returns the
+// number of entries refreshed.
+func (s *Service) RefreshDirectory(ctx context.Context) (int, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT i.domain FROM federation_peers fp JOIN instances i ON i.id = fp.peer_id
+		 WHERE fp.instance_id = $1 AND fp.status = 'active'
+		 UNION
+		 SELECT domain FROM instance_directory`, s.instanceID)
+	if err != nil {
+		return 0, fmt.Errorf("loading directory candidates: %w", err)
+	}
+	var queue []string
+	for rows.Next() {
+		var domain string
+		if rows.Scan(&domain) == nil {
+			queue = append(queue, domain)
+		}
+	}
+	rows.Close()
+
+	skip := map[string]bool{strings.ToLower(s.domain): true}
+	rows, err = s.pool.Query(ctx,
+		`SELECT i.domain FROM federation_peers fp JOIN instances i ON i.id = fp.peer_id
+		 WHERE fp.instance_id = $1 AND fp.status = 'blocked'`, s.instanceID)
+	if err != nil {
+		return 0, fmt.Errorf("loading blocked instances: %w", err)
+	}
+	for rows.Next() {
+		var domain string
+		if rows.Scan(&domain) == nil {
+			skip[strings.ToLower(domain)] = true
+		}
+	}
+	rows.Close()
+
+	refreshed, fetches := 0, 0
+	for len(queue) > 0 && fetches < directoryMaxFetches && ctx.Err() == nil {
+		domain := strings.ToLower(queue[0])
+		queue = queue[1:]
+		if skip[domain] {
+			continue
+		}
+		skip[domain] = true
+		fetches++
+
+		dir, err := fetchDirectory(ctx, domain)
+		if errors.Is(err, errNotListed) {
+			s.pool.Exec(ctx, `DELETE FROM instance_directory WHERE domain = $1`, domain)
+			continue
+		}
+		if err != nil {
+			s.logger.Debug("federation directory: fetch failed",
+				slog.String("domain", domain), slog.String("error", err.Error()))
+			continue
+		}
+
+		e := sanitizeDirectoryEntry(dir.Instance, domain)
+		featured, _ := json.Marshal(e.FeaturedGuilds)
+		if _, err := s.pool.Exec(ctx,
+			`INSERT INTO instance_directory (domain, instance_id, name, description, user_count,
+			     federation_mode, software_version, featured_guilds, fetched_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+			 ON CONFLICT (domain) DO UPDATE SET
+			     instance_id = EXCLUDED.instance_id, name = EXCLUDED.name,
+			     description = EXCLUDED.description, user_count = EXCLUDED.user_count,
+			     federation_mode = EXCLUDED.federation_mode,
+			     software_version = EXCLUDED.software_version,
+			     featured_guilds = EXCLUDED.featured_guilds, fetched_at = now()`,
+			domain, e.InstanceID, e.Name, e.Description, e.UserCount,
+			e.FederationMode, e.SoftwareVersion, featured); err != nil {
+			return refreshed, fmt.Errorf("storing directory entry for %s: %w", domain, err)
+		}
+		refreshed++
+
+		known := dir.KnownDomains
+		if len(known) > directoryMaxKnown {
+			known = known[:directoryMaxKnown]
+		}
+		queue = append(queue, known...)
+	}
+
+	if _, err := s.pool.Exec(ctx,
+		`DELETE FROM instance_directory WHERE fetched_at < $1`,
+		time.Now().Add(-directoryStaleAfter)); err != nil {
+		return refreshed, fmt.Errorf("pruning directory: %w", err)
+	}
+	return refreshed, nil
+}
+
+// StartDirectoryRefresher refreshes the instance directory in the
+// background every directoryRefreshInterval, starting shortly after boot.
+func (s *Service) StartDirectoryRefresher(ctx context.Context) {
+	go func() {
+		timer := time.NewTimer(time.Minute)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				n, err := s.RefreshDirectory(ctx)
+				if err != nil {
+					s.logger.Warn("federation directory refresh failed", slog.String("error", err.Error()))
+				} else {
+					s.logger.Info("federation directory refreshed", slog.Int("entries", n))
+				}
+				timer.Reset(directoryRefreshInterval)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// HandleRecommendedGuilds returns the guilds featured by directory instances
+// this instance is actively peered with, largest first, for the discovery
+// page. Guilds on instances without an active peering are left out since
+// local users could not join them.
+// GET /api/v1/federation/discover/recommended
+func (ss *SyncService) HandleRecommendedGuilds(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := ss.fed.pool.Query(r.Context(),
+		`SELECT i.id, d.domain, i.shorthand, d.featured_guilds
+		 FROM instance_directory d
+		 JOIN instances i ON i.domain = d.domain
+		 JOIN federation_peers fp ON fp.peer_id = i.id AND fp.instance_id = $1 AND fp.status = 'active'`, ss.fed.instanceID)
+	if err != nil {
+		http.Error(w, "Failed to query directory", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	guilds := make([]aggregatedDiscoverGuild, 0)
+	for rows.Next() {
+		var instanceID, domain string
+		var shorthand *string
+		var featured []models.DirectoryGuild
+		if err := rows.Scan(&instanceID, &domain, &shorthand, &featured); err != nil {
+			http.Error(w, "Failed to read directory", http.StatusInternalServerError)
+			return
+		}
+		for _, g := range featured {
+			dg := aggregatedDiscoverGuild{
+				ID:             g.ID,
+				Name:           g.Name,
+				Description:    g.Description,
+				IconID:         g.IconID,
+				MemberCount:    g.MemberCount,
+				InstanceID:     instanceID,
+				InstanceDomain: domain,
+			}
+			if shorthand != nil {
+				dg.InstanceShorthand = *shorthand
+			}
+			guilds = append(guilds, dg)
+		}
+	}
+
+	sort.SliceStable(guilds, func(i, j int) bool { return guilds[i].MemberCount > guilds[j].MemberCount })
+	if len(guilds) > maxRecommendedGuilds {
+		guilds = guilds[:maxRecommendedGuilds]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": guilds})
+}
@@ -26,6 +26,7 @@ type federatedDMCreateRequest struct {
 	RecipientIDs []string            `json:"recipient_ids"`  // all participant user IDs
 	Recipients   []federatedUserInfo `json:"recipients"`     // full user info for stubs
 	GroupName    *string             `json:"group_name,omitempty"`
+	Encrypted    bool                `json:"encrypted,omitempty"` // end-to-end encrypted with MLS
 }
 
 // federatedUserInfo carries the minimum user data for creating stub records.
@@ -44,13 +45,16 @@ type federatedDMMessageRequest struct {
 }
 
 // federatedMessageData carries the message content for federation.
+// In encrypted DMs Content is the MLS ciphertext envelope.
 type federatedMessageData struct {
-	ID          string          `json:"id"`
-	AuthorID    string          `json:"author_id"`
-	Content     string          `json:"content"`
-	Attachments json.RawMessage `json:"attachments,omitempty"`
-	Embeds      json.RawMessage `json:"embeds,omitempty"`
-	CreatedAt   time.Time       `json:"created_at"`
+	ID                  string          `json:"id"`
+	AuthorID            string          `json:"author_id"`
+	Content             string          `json:"content"`
+	Encrypted           bool            `json:"encrypted,omitempty"`
+	EncryptionSessionID *string         `json:"encryption_session_id,omitempty"`
+	Attachments         json.RawMessage `json:"attachments,omitempty"`
+	Embeds              json.RawMessage `json:"embeds,omitempty"`
+	CreatedAt           time.Time       `json:"created_at"`
 }
 
 // federatedDMRecipientRequest is the signed payload for adding/removing recipients.
@@ -119,6 +123,44 @@ func (ss *SyncService) HandleFederatedDMCreate(w http.ResponseWriter, r *http.Re
 		ss.ensureRemoteUserStub(ctx, instanceID, u)
 	}
 
+	// DMs from restricted peers wait for a local recipient to accept them,
+	// unless the conversation was already accepted.
+	if ss.fed.PeerTrustLevel(ctx, senderID) == models.PeerTrustRestricted && !ss.dmAlreadyMirrored(ctx, senderID, req) {
+		if req.ChannelType == "group" {
+			http.Error(w, "Group DMs from this instance are not accepted", http.StatusForbidden)
+			return
+		}
+		if !ss.isInstanceUser(ctx, req.Creator.ID, senderID) {
+			http.Error(w, "creator does not match signed sender", http.StatusForbidden)
+			return
+		}
+		if err := ss.queueDMRequest(ctx, senderID, req, signed.Payload); err != nil {
+			ss.logger.Error("failed to queue federated DM request", slog.String("error", err.Error()))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "pending_approval"})
+		return
+	}
+
+	localChannelID, status, err := ss.mirrorFederatedDM(ctx, senderID, req)
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"channel_id": localChannelID})
+}
+
+// mirrorFederatedDM creates the local mirror of a DM channel created on the
+// sender instance, or returns the existing mirror or 1:1 DM between the same
+// pair. The status is http.StatusCreated for a new channel and
+// http.StatusOK otherwise. Errors are logged here.
+func (ss *SyncService) mirrorFederatedDM(ctx context.Context, senderID string, req federatedDMCreateRequest) (string, int, error) {
 	// Create the local mirror channel inside a transaction with duplicate check.
 	localChannelID := models.NewULID().String()
 	now := time.Now()
@@ -127,8 +169,7 @@ func (ss *SyncService) HandleFederatedDMCreate(w http.ResponseWriter, r *http.Re
 	tx, err := ss.fed.pool.Begin(ctx)
 	if err != nil {
 		ss.logger.Error("failed to begin tx for federated DM", slog.String("error", err.Error()))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
+		return "", 0, err
 	}
 	defer tx.Rollback(ctx)
 
@@ -144,18 +185,13 @@ func (ss *SyncService) HandleFederatedDMCreate(w http.ResponseWriter, r *http.Re
 		// Already mirrored — return existing channel.
 		if err := tx.Commit(ctx); err != nil {
 			ss.logger.Error("failed to commit (mirror lookup)", slog.String("error", err.Error()))
-			http.Error(w, "Internal error", http.StatusInternalServerError)
-			return
+			return "", 0, err
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"channel_id": existingLocalID})
-		return
+		return existingLocalID, http.StatusOK, nil
 	}
 	if err != pgx.ErrNoRows {
 		ss.logger.Error("failed to check DM mirror", slog.String("error", err.Error()))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
+		return "", 0, err
 	}
 
 	// For 1:1 DMs, also check if a DM already exists between the same pair.
@@ -184,36 +220,45 @@ func (ss *SyncService) HandleFederatedDMCreate(w http.ResponseWriter, r *http.Re
 			)
 			if err := tx.Commit(ctx); err != nil {
 				ss.logger.Error("failed to commit (DM pair reuse)", slog.String("error", err.Error()))
-				http.Error(w, "Internal error", http.StatusInternalServerError)
-				return
+				return "", 0, err
 			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]string{"channel_id": existingDM})
-			return
+			return existingDM, http.StatusOK, nil
 		}
 		if err != pgx.ErrNoRows {
 			ss.logger.Error("failed to check existing DM pair", slog.String("error", err.Error()))
-			http.Error(w, "Internal error", http.StatusInternalServerError)
-			return
+			return "", 0, err
 		}
 	}
 
 	if req.ChannelType == "group" {
+		// Group DMs keep the creating instance's channel ID so that every
+		// participating instance can address them the same way. The creator's
+		// instance hosts the group; see group_dm.go.
+		var taken bool
+		if err := tx.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM channels WHERE id = $1)`, req.ChannelID,
+		).Scan(&taken); err == nil && !taken {
+			localChannelID = req.ChannelID
+		}
 		_, err = tx.Exec(ctx,
-			`INSERT INTO channels (id, channel_type, name, owner_id, created_at) VALUES ($1, 'group', $2, $3, $4)`,
-			localChannelID, req.GroupName, req.Creator.ID, now,
+			`INSERT INTO channels (id, channel_type, name, owner_id, encrypted, created_at) VALUES ($1, 'group', $2, $3, $4, $5)`,
+			localChannelID, req.GroupName, req.Creator.ID, req.Encrypted, now,
 		)
+		if err == nil && localChannelID == req.ChannelID {
+			_, err = tx.Exec(ctx,
+				`INSERT INTO federated_group_dm_state (channel_id, host_instance_id) VALUES ($1, $2)`,
+				localChannelID, senderID,
+			)
+		}
 	} else {
 		_, err = tx.Exec(ctx,
-			`INSERT INTO channels (id, channel_type, created_at) VALUES ($1, 'dm', $2)`,
-			localChannelID, now,
+			`INSERT INTO channels (id, channel_type, encrypted, created_at) VALUES ($1, 'dm', $2, $3)`,
+			localChannelID, req.Encrypted, now,
 		)
 	}
 	if err != nil {
 		ss.logger.Error("failed to create federated DM channel", slog.String("error", err.Error()))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
+		return "", 0, err
 	}
 	created = true
 
@@ -228,8 +273,7 @@ func (ss *SyncService) HandleFederatedDMCreate(w http.ResponseWriter, r *http.Re
 		if err != nil {
 			ss.logger.Error("failed to add federated DM recipient",
 				slog.String("user_id", uid), slog.String("error", err.Error()))
-			http.Error(w, "Internal error", http.StatusInternalServerError)
-			return
+			return "", 0, err
 		}
 	}
 
@@ -241,8 +285,7 @@ func (ss *SyncService) HandleFederatedDMCreate(w http.ResponseWriter, r *http.Re
 	)
 	if err != nil {
 		ss.logger.Error("failed to store channel mirror", slog.String("error", err.Error()))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
+		return "", 0, err
 	}
 
 	// Register the sender instance as a channel peer.
@@ -253,14 +296,12 @@ func (ss *SyncService) HandleFederatedDMCreate(w http.ResponseWriter, r *http.Re
 	)
 	if err != nil {
 		ss.logger.Error("failed to register channel peer", slog.String("error", err.Error()))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
+		return "", 0, err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
 		ss.logger.Error("failed to commit federated DM", slog.String("error", err.Error()))
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
+		return "", 0, err
 	}
 
 	if created {
@@ -269,14 +310,13 @@ func (ss *SyncService) HandleFederatedDMCreate(w http.ResponseWriter, r *http.Re
 			"id":           localChannelID,
 			"channel_type": req.ChannelType,
 			"name":         req.GroupName,
+			"encrypted":    req.Encrypted,
 			"created_at":   now,
 		}
 		ss.bus.PublishChannelEvent(ctx, events.SubjectChannelCreate, "CHANNEL_CREATE", localChannelID, channel)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"channel_id": localChannelID})
+	return localChannelID, http.StatusCreated, nil
 }
 
 // HandleFederatedDMMessage handles POST /federation/v1/dm/message — receives
@@ -300,6 +340,11 @@ func (ss *SyncService) HandleFederatedDMMessage(w http.ResponseWriter, r *http.R
 
 	ctx := r.Context()
 
+	if !ss.isInstanceUser(ctx, req.Message.AuthorID, senderID) {
+		http.Error(w, "author_id does not match signed sender", http.StatusForbidden)
+		return
+	}
+
 	// Look up the local channel via mirror mapping.
 	var localChannelID string
 	err := ss.fed.pool.QueryRow(ctx,
@@ -324,10 +369,11 @@ func (ss *SyncService) HandleFederatedDMMessage(w http.ResponseWriter, r *http.R
 	}
 
 	tag, err := ss.fed.pool.Exec(ctx,
-		`INSERT INTO messages (id, channel_id, author_id, content, created_at)
-		 VALUES ($1, $2, $3, $4, $5)
+		`INSERT INTO messages (id, channel_id, author_id, content, encrypted, encryption_session_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
 		 ON CONFLICT (id) DO NOTHING`,
-		req.Message.ID, localChannelID, req.Message.AuthorID, req.Message.Content, createdAt,
+		req.Message.ID, localChannelID, req.Message.AuthorID, req.Message.Content,
+		req.Message.Encrypted, req.Message.EncryptionSessionID, createdAt,
 	)
 	if err != nil {
 		ss.logger.Error("failed to persist federated DM message",
@@ -369,15 +415,26 @@ func (ss *SyncService) HandleFederatedDMMessage(w http.ResponseWriter, r *http.R
 		"channel_id": localChannelID,
 		"author_id":  req.Message.AuthorID,
 		"content":    req.Message.Content,
+		"encrypted":  req.Message.Encrypted,
 		"created_at": createdAt,
 	}
+	if req.Message.EncryptionSessionID != nil {
+		msg["encryption_session_id"] = *req.Message.EncryptionSessionID
+	}
 	if req.Message.Attachments != nil {
 		msg["attachments"] = req.Message.Attachments
 	}
 	if req.Message.Embeds != nil {
 		msg["embeds"] = req.Message.Embeds
 	}
-	ss.bus.PublishChannelEvent(ctx, events.SubjectMessageCreate, "MESSAGE_CREATE", localChannelID, msg)
+	// Marked with its origin so the federation router doesn't echo it back.
+	msgData, _ := json.Marshal(msg)
+	ss.bus.Publish(ctx, events.SubjectMessageCreate, events.Event{
+		Type:             "MESSAGE_CREATE",
+		ChannelID:        localChannelID,
+		Data:             msgData,
+		OriginInstanceID: senderID,
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
@@ -529,6 +586,7 @@ func (ss *SyncService) verifyFederationRequest(w http.ResponseWriter, r *http.Re
 			ss.logger.Warn("federation: unknown sender instance",
 				slog.String("sender_id", signed.SenderID),
 				slog.String("remote", r.RemoteAddr))
+			ss.fed.recordRejection(r, signed.SenderID, RejectUnknownPeer, "")
 			http.Error(w, "Unknown sender instance", http.StatusForbidden)
 		} else {
 			ss.logger.Error("failed to look up sender", slog.String("error", err.Error()))
@@ -544,15 +602,17 @@ func (ss *SyncService) verifyFederationRequest(w http.ResponseWriter, r *http.Re
 			slog.String("sender_id", signed.SenderID),
 			slog.String("remote", r.RemoteAddr),
 			slog.String("path", r.URL.Path))
+		ss.fed.recordRejection(r, signed.SenderID, RejectBadSignature, signatureDetail(err))
 		http.Error(w, "Invalid signature", http.StatusForbidden)
 		return nil, "", false
 	}
 
 	// Check timestamp freshness.
-	if msg := validateTimestamp(signed.Timestamp); msg != "" {
+	if msg := ss.fed.validateTimestamp(signed.Timestamp); msg != "" {
 		ss.logger.Warn("federation request rejected: stale timestamp",
 			slog.String("sender_id", signed.SenderID),
 			slog.String("detail", msg))
+		ss.fed.recordRejection(r, signed.SenderID, RejectClockSkew, msg)
 		http.Error(w, "Stale or future timestamp", http.StatusBadRequest)
 		return nil, "", false
 	}
@@ -642,6 +702,13 @@ func (ss *SyncService) NotifyFederatedDM(ctx context.Context, remoteDomain, loca
 		return fmt.Errorf("iterating recipients: %w", err)
 	}
 
+	var encrypted bool
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT encrypted FROM channels WHERE id = $1`, localChannelID,
+	).Scan(&encrypted); err != nil {
+		return fmt.Errorf("looking up channel: %w", err)
+	}
+
 	// Build the federation request.
 	req := federatedDMCreateRequest{
 		ChannelID:    localChannelID,
@@ -650,6 +717,7 @@ func (ss *SyncService) NotifyFederatedDM(ctx context.Context, remoteDomain, loca
 		RecipientIDs: recipientIDs,
 		Recipients:   recipients,
 		GroupName:    groupName,
+		Encrypted:    encrypted,
 	}
 
 	signed, err := ss.fed.Sign(req)
@@ -676,6 +744,11 @@ func (ss *SyncService) NotifyFederatedDM(ctx context.Context, remoteDomain, loca
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusAccepted {
+		// The remote instance holds the DM until its user accepts it, and
+		// then sends its own create request back.
+		return nil
+	}
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
 		return fmt.Errorf("remote instance returned %d: %s", resp.StatusCode, string(respBody))
@@ -726,6 +799,18 @@ func (ss *SyncService) NotifyFederatedDM(ctx context.Context, remoteDomain, loca
 		slog.String("channel_id", localChannelID),
 	)
 
+	// Push the full membership so every participating instance learns about
+	// the others, not only about this one. Peers that predate group DM state
+	// answer with their own channel ID and keep the legacy behaviour.
+	if channelType == "group" && result.ChannelID == localChannelID {
+		if _, err := ss.syncGroupDMState(ctx, localChannelID); err != nil {
+			ss.logger.Warn("failed to sync new group DM state",
+				slog.String("channel_id", localChannelID),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,234 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// isInstanceUser reports whether userID belongs to instanceID. Used to stop a
+// peer from acting on behalf of users it does not host in a mirrored DM.
+func (ss *SyncService) isInstanceUser(ctx context.Context, userID, instanceID string) bool {
+	var ok bool
+	ss.fed.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND instance_id = $2)`,
+		userID, instanceID,
+	).Scan(&ok)
+	return ok
+}
+
+// persistInboundReadAck records a remote DM participant's read position in
+// the local mirror so local participants can show read receipts. Returns
+// false if the ack is not acceptable from the sender.
+func (ss *SyncService) persistInboundReadAck(ctx context.Context, remoteInstanceID, localChannelID string, data json.RawMessage) bool {
+	var ack struct {
+		UserID     string `json:"user_id"`
+		LastReadID string `json:"last_read_id"`
+	}
+	if err := json.Unmarshal(data, &ack); err != nil || ack.UserID == "" || ack.LastReadID == "" {
+		return false
+	}
+	if !ss.isInstanceUser(ctx, ack.UserID, remoteInstanceID) {
+		return false
+	}
+
+	tag, err := ss.fed.pool.Exec(ctx,
+		`INSERT INTO read_state (user_id, channel_id, last_read_id, mention_count)
+		 SELECT $1, $2, $3, 0
+		 WHERE EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $2 AND user_id = $1)
+		 ON CONFLICT (user_id, channel_id) DO UPDATE SET last_read_id = EXCLUDED.last_read_id, mention_count = 0`,
+		ack.UserID, localChannelID, ack.LastReadID)
+	if err != nil {
+		ss.logger.Warn("failed to persist inbound read ack",
+			slog.String("channel_id", localChannelID),
+			slog.String("user_id", ack.UserID),
+			slog.String("error", err.Error()))
+		return false
+	}
+	if tag.RowsAffected() == 0 {
+		return false
+	}
+	if err := apiutil.PublishDMRead(ctx, ss.fed.pool, ss.bus, localChannelID, ack.UserID, ack.LastReadID); err != nil {
+		ss.logger.Debug("failed to publish DM read receipt", slog.String("error", err.Error()))
+	}
+	return true
+}
+
+// routeReadAck forwards a local user's CHANNEL_ACK to the remote participants
+// of a federated DM. Acks for other channels are never federated.
+func (ss *SyncService) routeReadAck(ctx context.Context, event events.Event) {
+	if event.UserID == "" {
+		return
+	}
+	var ack struct {
+		ChannelID string `json:"channel_id"`
+	}
+	if json.Unmarshal(event.Data, &ack) != nil || ack.ChannelID == "" {
+		return
+	}
+	ss.forwardReadAck(ctx, event.UserID, ack.ChannelID)
+}
+
+// routeBulkReadAck forwards the DMs of a CHANNELS_ACK the way routeReadAck
+// does a single ack. Guild-wide acks hold no DMs and are skipped.
+func (ss *SyncService) routeBulkReadAck(ctx context.Context, event events.Event) {
+	if event.UserID == "" {
+		return
+	}
+	var ack struct {
+		GuildID    *string  `json:"guild_id"`
+		ChannelIDs []string `json:"channel_ids"`
+	}
+	if json.Unmarshal(event.Data, &ack) != nil || ack.GuildID != nil {
+		return
+	}
+	for _, channelID := range ack.ChannelIDs {
+		ss.forwardReadAck(ctx, event.UserID, channelID)
+	}
+}
+
+// forwardReadAck sends a user's read position in a federated DM to the
+// peers of its remote participants.
+func (ss *SyncService) forwardReadAck(ctx context.Context, userID, channelID string) {
+	var lastReadID *string
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT rs.last_read_id FROM read_state rs
+		 JOIN users u ON u.id = rs.user_id AND u.instance_id = $3
+		 WHERE rs.user_id = $1 AND rs.channel_id = $2
+		   AND EXISTS(SELECT 1 FROM federation_dm_channel_map WHERE local_channel_id = $2)`,
+		userID, channelID, ss.fed.instanceID,
+	).Scan(&lastReadID); err != nil || lastReadID == nil {
+		return
+	}
+
+	ss.DeliverToChannelPeers(ctx, FederatedMessage{
+		Type:      "CHANNEL_ACK",
+		ChannelID: channelID,
+		Data: map[string]string{
+			"channel_id":   channelID,
+			"user_id":      userID,
+			"last_read_id": *lastReadID,
+		},
+	})
+}
+
+// dmPresencePeers returns the peers hosting participants of a user's
+// federated DMs, or nil if the user has not opted in to sharing presence
+// with them.
+func (ss *SyncService) dmPresencePeers(ctx context.Context, userID string) []peerTarget {
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT DISTINCT fp.peer_id, i.domain
+		 FROM users u
+		 JOIN channel_recipients cr ON cr.user_id = u.id
+		 JOIN federation_dm_channel_map m ON m.local_channel_id = cr.channel_id
+		 JOIN federation_peers fp ON fp.peer_id = m.remote_instance_id
+		  AND fp.instance_id = $2 AND fp.status = 'active'
+		 JOIN instances i ON i.id = fp.peer_id
+		 WHERE u.id = $1 AND u.share_federated_presence`,
+		userID, ss.fed.instanceID)
+	if err != nil {
+		ss.logger.Warn("failed to query DM presence peers",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+		return nil
+	}
+	defer rows.Close()
+
+	var peers []peerTarget
+	for rows.Next() {
+		var p peerTarget
+		if rows.Scan(&p.peerID, &p.domain) == nil {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// dmPresenceRecipients returns the local users who share a federated DM with
+// a remote user, so an opted-in presence update can be routed to them.
+func (ss *SyncService) dmPresenceRecipients(ctx context.Context, remoteInstanceID, remoteUserID string) []string {
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT DISTINCT other.user_id
+		 FROM channel_recipients cr
+		 JOIN federation_dm_channel_map m ON m.local_channel_id = cr.channel_id AND m.remote_instance_id = $2
+		 JOIN channel_recipients other ON other.channel_id = cr.channel_id AND other.user_id <> cr.user_id
+		 WHERE cr.user_id = $1`,
+		remoteUserID, remoteInstanceID)
+	if err != nil {
+		ss.logger.Warn("failed to query DM presence recipients",
+			slog.String("user_id", remoteUserID), slog.String("error", err.Error()))
+		return nil
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// deliverToPeers signs msg once and sends it to each of peers.
+func (ss *SyncService) deliverToPeers(ctx context.Context, msg FederatedMessage, peers []peerTarget) {
+	msg.OriginID = ss.fed.instanceID
+	msg.Timestamp = ss.hlc.Now()
+
+	signed, err := ss.fed.Sign(msg)
+	if err != nil {
+		ss.logger.Error("failed to sign federation message",
+			slog.String("type", msg.Type),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	for _, peer := range peers {
+		p := peer
+		go func() {
+			ss.deliverySem <- struct{}{}
+			defer func() { <-ss.deliverySem }()
+			ss.deliverToPeer(ctx, p.domain, p.peerID, signed)
+		}()
+	}
+}
+
+// setChannelID rewrites the channel_id field of an event payload, used when a
+// DM event is re-keyed from the sender's channel to the local mirror.
+func setChannelID(data json.RawMessage, channelID string) json.RawMessage {
+	var m map[string]interface{}
+	if json.Unmarshal(data, &m) != nil || m == nil {
+		return data
+	}
+	if _, ok := m["channel_id"]; !ok {
+		return data
+	}
+	m["channel_id"] = channelID
+	out, err := json.Marshal(m)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// addPresenceRecipients adds local DM partners to an inbound opted-in
+// presence payload; the gateway routes to them in addition to shared guilds.
+func addPresenceRecipients(data json.RawMessage, recipients []string) json.RawMessage {
+	if len(recipients) == 0 {
+		return data
+	}
+	var m map[string]interface{}
+	if json.Unmarshal(data, &m) != nil || m == nil {
+		return data
+	}
+	m["recipient_ids"] = recipients
+	out, err := json.Marshal(m)
+	if err != nil {
+		return data
+	}
+	return out
+}
@@ -0,0 +1,434 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/encryption"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// MLS sessions in federated DMs. Key packages are claimed synchronously from
+// the participant's home instance through a signed endpoint; Welcome and
+// Commit messages travel through the inbox as MLS_WELCOME and MLS_COMMIT so
+// they share the retry queue when a peer is temporarily unreachable.
+
+// dmClaimAttempts is how many times a key package claim is sent to a peer
+// before the client is told to retry later. A claim whose response was lost
+// consumes a package on the peer, which is harmless: clients upload several.
+const dmClaimAttempts = 3
+
+// dmClaimTimeout bounds each claim attempt.
+const dmClaimTimeout = 5 * time.Second
+
+// dmKeyPackageClaimRequest is the signed payload for claiming a key package
+// of a local participant of a federated DM.
+type dmKeyPackageClaimRequest struct {
+	RemoteChannelID string `json:"remote_channel_id"` // sender's channel ID
+	ClaimerID       string `json:"claimer_id"`        // sender's user negotiating the session
+	UserID          string `json:"user_id"`           // local user whose package is claimed
+}
+
+// dmMLSWelcome is the data of an MLS_WELCOME federated message.
+type dmMLSWelcome struct {
+	ID         string `json:"id"`
+	SenderID   string `json:"sender_id"`
+	ReceiverID string `json:"receiver_id"`
+	Data       []byte `json:"data"` // Opaque MLS Welcome bytes
+}
+
+// dmMLSCommit is the data of an MLS_COMMIT federated message.
+type dmMLSCommit struct {
+	ID       string `json:"id"`
+	SenderID string `json:"sender_id"`
+	Epoch    uint64 `json:"epoch"`
+	Data     []byte `json:"data"` // Opaque MLS Commit bytes
+}
+
+// HandleFederatedDMClaimKeyPackage handles POST /federation/v1/dm/mls/key-packages/claim.
+// A peer claims one key package of a local user so one of its users can
+// start an MLS session in a DM they share.
+func (ss *SyncService) HandleFederatedDMClaimKeyPackage(w http.ResponseWriter, r *http.Request) {
+	signed, senderID, ok := ss.verifyFederationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req dmKeyPackageClaimRequest
+	if err := json.Unmarshal(signed.Payload, &req); err != nil {
+		writeMLSError(w, http.StatusBadRequest, "invalid_payload", "Invalid request payload")
+		return
+	}
+	if req.RemoteChannelID == "" || req.ClaimerID == "" || req.UserID == "" {
+		writeMLSError(w, http.StatusBadRequest, "missing_fields", "remote_channel_id, claimer_id and user_id are required")
+		return
+	}
+
+	ctx := r.Context()
+
+	if !ss.isInstanceUser(ctx, req.ClaimerID, senderID) {
+		writeMLSError(w, http.StatusForbidden, "invalid_claimer", "claimer_id does not match signed sender")
+		return
+	}
+	channelID, err := ss.dmMirrorFor(ctx, senderID, req.RemoteChannelID)
+	if err == pgx.ErrNoRows {
+		writeMLSError(w, http.StatusNotFound, "unknown_channel", "Unknown DM channel")
+		return
+	}
+	if err != nil {
+		ss.logger.Error("DM MLS claim: failed to look up channel mirror",
+			slog.String("remote_channel_id", req.RemoteChannelID),
+			slog.String("error", err.Error()))
+		writeMLSError(w, http.StatusInternalServerError, "internal_error", "Failed to look up channel")
+		return
+	}
+	if !ss.isDMRecipient(ctx, channelID, req.ClaimerID) || !ss.isInstanceUser(ctx, req.UserID, ss.fed.instanceID) ||
+		!ss.isDMRecipient(ctx, channelID, req.UserID) {
+		writeMLSError(w, http.StatusForbidden, "not_recipient", "Both users must be participants of the DM")
+		return
+	}
+
+	var kp mlsKeyPackageResponse
+	err = ss.fed.pool.QueryRow(ctx,
+		`DELETE FROM mls_key_packages
+		 WHERE id = (
+			 SELECT id FROM mls_key_packages
+			 WHERE user_id = $1 AND expires_at > now()
+			 ORDER BY created_at ASC LIMIT 1
+		 )
+		 RETURNING id, user_id, device_id, data, expires_at, created_at`,
+		req.UserID,
+	).Scan(&kp.ID, &kp.UserID, &kp.DeviceID, &kp.Data, &kp.ExpiresAt, &kp.CreatedAt)
+	if err == pgx.ErrNoRows {
+		writeMLSError(w, http.StatusNotFound, "no_key_packages", "No available key packages for this user")
+		return
+	}
+	if err != nil {
+		ss.logger.Error("DM MLS claim: failed to claim",
+			slog.String("user_id", req.UserID),
+			slog.String("error", err.Error()))
+		writeMLSError(w, http.StatusInternalServerError, "internal_error", "Failed to claim key package")
+		return
+	}
+
+	writeMLSJSON(w, http.StatusOK, kp)
+}
+
+// ClaimRemoteKeyPackage claims a key package of a user on another instance
+// for an MLS session in the federated DM channelID. Unreachable peers are
+// retried a few times before encryption.ErrPeerUnreachable is returned.
+func (ss *SyncService) ClaimRemoteKeyPackage(ctx context.Context, callerID, channelID, userID string) (*encryption.KeyPackage, bool, error) {
+	peer, remote, err := ss.dmPeerOf(ctx, channelID, userID, callerID)
+	if !remote || err != nil {
+		return nil, remote, err
+	}
+
+	signed, err := ss.fed.Sign(dmKeyPackageClaimRequest{
+		RemoteChannelID: channelID,
+		ClaimerID:       callerID,
+		UserID:          userID,
+	})
+	if err != nil {
+		return nil, true, fmt.Errorf("signing key package claim: %w", err)
+	}
+	body, err := json.Marshal(signed)
+	if err != nil {
+		return nil, true, fmt.Errorf("marshaling key package claim: %w", err)
+	}
+	url := fmt.Sprintf("https://%s/federation/v1/dm/mls/key-packages/claim", peer.domain)
+
+	for attempt := 0; attempt < dmClaimAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, true, ctx.Err()
+			case <-time.After(time.Duration(attempt) * 500 * time.Millisecond):
+			}
+		}
+		kp, retry, err := ss.postKeyPackageClaim(ctx, url, body)
+		if err == nil {
+			return kp, true, nil
+		}
+		if !retry {
+			return nil, true, err
+		}
+		ss.logger.Debug("DM MLS claim attempt failed",
+			slog.String("domain", peer.domain),
+			slog.Int("attempt", attempt+1),
+			slog.String("error", err.Error()))
+	}
+	ss.fed.IncrementPeerErrors(ctx, peer.peerID)
+	return nil, true, encryption.ErrPeerUnreachable
+}
+
+// postKeyPackageClaim sends one claim. retry reports whether the failure is
+// transient.
+func (ss *SyncService) postKeyPackageClaim(ctx context.Context, url string, body []byte) (*encryption.KeyPackage, bool, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, dmClaimTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, fmt.Errorf("creating key package claim: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AmityVox/1.0 (+federation)")
+
+	resp, err := ss.client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		var errBody struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&errBody)
+		if errBody.Error.Code == "no_key_packages" {
+			return nil, false, encryption.ErrNoKeyPackages
+		}
+		return nil, false, encryption.ErrNotFederatedDM
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, false, encryption.ErrNotFederatedDM
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, true, fmt.Errorf("peer returned %d", resp.StatusCode)
+	default:
+		return nil, false, fmt.Errorf("peer returned %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data encryption.KeyPackage `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return nil, false, fmt.Errorf("decoding key package: %w", err)
+	}
+	return &result.Data, false, nil
+}
+
+// RelayWelcome delivers an MLS Welcome to a DM participant on another
+// instance through the inbox.
+func (ss *SyncService) RelayWelcome(ctx context.Context, senderID, channelID, receiverID string, data []byte) (bool, error) {
+	peer, remote, err := ss.dmPeerOf(ctx, channelID, receiverID, senderID)
+	if !remote || err != nil {
+		return remote, err
+	}
+
+	// Delivery outlives the request; failures go to the retry queue.
+	ss.deliverToPeers(context.WithoutCancel(ctx), FederatedMessage{
+		Type:      "MLS_WELCOME",
+		ChannelID: channelID,
+		Data: dmMLSWelcome{
+			ID:         models.NewULID().String(),
+			SenderID:   senderID,
+			ReceiverID: receiverID,
+			Data:       data,
+		},
+	}, []peerTarget{peer})
+	return true, nil
+}
+
+// RelayCommit forwards a local user's MLS Commit to every other instance
+// taking part in a federated DM.
+func (ss *SyncService) RelayCommit(ctx context.Context, commit encryption.Commit) {
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT fp.peer_id, i.domain
+		 FROM channels c
+		 JOIN federation_channel_peers fcp ON fcp.channel_id = c.id
+		 JOIN federation_peers fp ON fp.peer_id = fcp.instance_id
+		  AND fp.instance_id = $2 AND fp.status = 'active'
+		 JOIN instances i ON i.id = fp.peer_id
+		 WHERE c.id = $1 AND c.channel_type IN ('dm', 'group')`,
+		commit.ChannelID, ss.fed.instanceID)
+	if err != nil {
+		ss.logger.Warn("failed to query DM peers for MLS commit",
+			slog.String("channel_id", commit.ChannelID),
+			slog.String("error", err.Error()))
+		return
+	}
+	peers, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (peerTarget, error) {
+		var p peerTarget
+		err := row.Scan(&p.peerID, &p.domain)
+		return p, err
+	})
+	if err != nil || len(peers) == 0 {
+		return
+	}
+
+	ss.deliverToPeers(context.WithoutCancel(ctx), FederatedMessage{
+		Type:      "MLS_COMMIT",
+		ChannelID: commit.ChannelID,
+		Data: dmMLSCommit{
+			ID:       commit.ID,
+			SenderID: commit.SenderID,
+			Epoch:    commit.Epoch,
+			Data:     commit.Data,
+		},
+	}, peers)
+}
+
+// persistInboundMLS stores an MLS_WELCOME or MLS_COMMIT received for the
+// local DM channelID. The sending user must be a participant hosted by the
+// peer, and a Welcome must be for a local participant. Redeliveries from
+// the retry queue are ignored by ID.
+func (ss *SyncService) persistInboundMLS(ctx context.Context, remoteInstanceID, eventType, channelID string, data json.RawMessage) bool {
+	var channelType string
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT channel_type FROM channels WHERE id = $1`, channelID,
+	).Scan(&channelType); err != nil || (channelType != "dm" && channelType != "group") {
+		return false
+	}
+
+	switch eventType {
+	case "MLS_WELCOME":
+		var wm dmMLSWelcome
+		if json.Unmarshal(data, &wm) != nil || wm.ID == "" || len(wm.Data) == 0 {
+			return false
+		}
+		if !ss.isInstanceUser(ctx, wm.SenderID, remoteInstanceID) || !ss.isDMRecipient(ctx, channelID, wm.SenderID) ||
+			!ss.isInstanceUser(ctx, wm.ReceiverID, ss.fed.instanceID) || !ss.isDMRecipient(ctx, channelID, wm.ReceiverID) {
+			return false
+		}
+		if _, err := ss.fed.pool.Exec(ctx,
+			`INSERT INTO mls_welcome_messages (id, channel_id, receiver_id, data, created_at)
+			 VALUES ($1, $2, $3, $4, now())
+			 ON CONFLICT (id) DO NOTHING`,
+			wm.ID, channelID, wm.ReceiverID, wm.Data); err != nil {
+			ss.logger.Warn("failed to store federated MLS welcome",
+				slog.String("channel_id", channelID),
+				slog.String("error", err.Error()))
+			return false
+		}
+
+	case "MLS_COMMIT":
+		var c dmMLSCommit
+		if json.Unmarshal(data, &c) != nil || c.ID == "" || len(c.Data) == 0 {
+			return false
+		}
+		if !ss.isInstanceUser(ctx, c.SenderID, remoteInstanceID) || !ss.isDMRecipient(ctx, channelID, c.SenderID) {
+			return false
+		}
+		err := pgx.BeginFunc(ctx, ss.fed.pool, func(tx pgx.Tx) error {
+			tag, err := tx.Exec(ctx,
+				`INSERT INTO mls_commits (id, channel_id, sender_id, epoch, data, created_at)
+				 VALUES ($1, $2, $3, $4, $5, now())
+				 ON CONFLICT (id) DO NOTHING`,
+				c.ID, channelID, c.SenderID, c.Epoch, c.Data)
+			if err != nil || tag.RowsAffected() == 0 {
+				return err
+			}
+			_, err = tx.Exec(ctx,
+				`INSERT INTO mls_group_states (channel_id, epoch, updated_at)
+				 VALUES ($1, $2, now())
+				 ON CONFLICT (channel_id) DO UPDATE SET
+					epoch = GREATEST(mls_group_states.epoch, EXCLUDED.epoch),
+					updated_at = now()`,
+				channelID, c.Epoch)
+			return err
+		})
+		if err != nil {
+			ss.logger.Warn("failed to store federated MLS commit",
+				slog.String("channel_id", channelID),
+				slog.String("error", err.Error()))
+			return false
+		}
+	}
+	return true
+}
+
+// dmPeerOf returns the instance hosting userID when it is a remote
+// participant of the federated DM channelID that localUserID also takes part
+// in. remote is false if userID is a local user.
+func (ss *SyncService) dmPeerOf(ctx context.Context, channelID, userID, localUserID string) (peerTarget, bool, error) {
+	var peer peerTarget
+	var instanceID *string
+	err := ss.fed.pool.QueryRow(ctx,
+		`SELECT u.instance_id, i.domain FROM users u
+		 LEFT JOIN instances i ON i.id = u.instance_id
+		 WHERE u.id = $1`, userID,
+	).Scan(&instanceID, &peer.domain)
+	if err != nil && err != pgx.ErrNoRows {
+		return peer, false, fmt.Errorf("looking up user instance: %w", err)
+	}
+	if err == pgx.ErrNoRows || instanceID == nil || *instanceID == ss.fed.instanceID {
+		// Unknown and local users are left to the local delivery service.
+		return peer, false, nil
+	}
+	peer.peerID = *instanceID
+
+	if channelID == "" {
+		return peer, true, encryption.ErrNotFederatedDM
+	}
+	var shared bool
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT EXISTS(
+		     SELECT 1 FROM channels c
+		     JOIN federation_channel_peers fcp ON fcp.channel_id = c.id AND fcp.instance_id = $2
+		     WHERE c.id = $1 AND c.channel_type IN ('dm', 'group'))`,
+		channelID, peer.peerID,
+	).Scan(&shared); err != nil {
+		return peer, true, fmt.Errorf("checking federated DM: %w", err)
+	}
+	if !shared || !ss.isDMRecipient(ctx, channelID, userID) || !ss.isDMRecipient(ctx, channelID, localUserID) {
+		return peer, true, encryption.ErrNotFederatedDM
+	}
+	return peer, true, nil
+}
+
+// dmMirrorFor resolves the local channel a peer means by channelID in a
+// federated DM: the mirror of the peer's channel or, for group DMs, which
+// keep one ID on every instance, the channel itself when the peer takes part.
+func (ss *SyncService) dmMirrorFor(ctx context.Context, peerID, channelID string) (string, error) {
+	var localID string
+	err := ss.fed.pool.QueryRow(ctx,
+		`SELECT local_channel_id FROM federation_dm_channel_map
+		 WHERE remote_channel_id = $1 AND remote_instance_id = $2
+		 UNION ALL
+		 SELECT c.id FROM channels c
+		 JOIN federation_channel_peers fcp ON fcp.channel_id = c.id AND fcp.instance_id = $2
+		 WHERE c.id = $1 AND c.channel_type = 'group'
+		 LIMIT 1`,
+		channelID, peerID,
+	).Scan(&localID)
+	return localID, err
+}
+
+// isDMRecipient reports whether userID is a participant of the DM channelID.
+func (ss *SyncService) isDMRecipient(ctx context.Context, channelID, userID string) bool {
+	var ok bool
+	ss.fed.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2)`,
+		channelID, userID,
+	).Scan(&ok)
+	return ok
+}
+
+// applyDMEncryption turns end-to-end encryption of a 1:1 DM mirror on or off
+// to follow a CHANNEL_UPDATE from the peer of the other participant.
+func (ss *SyncService) applyDMEncryption(ctx context.Context, channelID string, data json.RawMessage) {
+	var update struct {
+		Encrypted *bool `json:"encrypted"`
+	}
+	if json.Unmarshal(data, &update) != nil || update.Encrypted == nil {
+		return
+	}
+	if _, err := ss.fed.pool.Exec(ctx,
+		`UPDATE channels SET encrypted = $1 WHERE id = $2 AND channel_type = 'dm'`,
+		*update.Encrypted, channelID); err != nil {
+		ss.logger.Warn("failed to apply DM encryption setting",
+			slog.String("channel_id", channelID),
+			slog.String("error", err.Error()))
+	}
+}
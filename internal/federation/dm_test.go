@@ -2,6 +2,7 @@ package federation
 
 import (
 	"encoding/json"
+	"slices"
 	"testing"
 	"time"
 )
@@ -252,3 +253,151 @@ func TestFederatedUserInfo_MinimalFields(t *testing.T) {
 		t.Errorf("AvatarID should be nil, got %v", decoded.AvatarID)
 	}
 }
+
+func TestSetChannelID(t *testing.T) {
+	got := setChannelID(json.RawMessage(`{"id":"m1","channel_id":"remote"}`), "local")
+	var m map[string]string
+	if err := json.Unmarshal(got, &m); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if m["channel_id"] != "local" || m["id"] != "m1" {
+		t.Errorf("setChannelID = %s, want channel_id rewritten and id kept", got)
+	}
+
+	// Payloads without a channel_id are left untouched.
+	in := json.RawMessage(`{"id":"m1"}`)
+	if got := setChannelID(in, "local"); string(got) != string(in) {
+		t.Errorf("setChannelID without channel_id = %s, want %s", got, in)
+	}
+}
+
+func TestAddPresenceRecipients(t *testing.T) {
+	in := json.RawMessage(`{"user_id":"u1","status":"online","share_dm":true}`)
+	if got := addPresenceRecipients(in, nil); string(got) != string(in) {
+		t.Errorf("addPresenceRecipients(nil) = %s, want unchanged", got)
+	}
+
+	got := addPresenceRecipients(in, []string{"local-1", "local-2"})
+	var payload struct {
+		UserID       string   `json:"user_id"`
+		RecipientIDs []string `json:"recipient_ids"`
+	}
+	if err := json.Unmarshal(got, &payload); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if payload.UserID != "u1" || len(payload.RecipientIDs) != 2 || payload.RecipientIDs[1] != "local-2" {
+		t.Errorf("addPresenceRecipients = %s, want recipient_ids added", got)
+	}
+}
+
+func TestDiffRecipients(t *testing.T) {
+	added, removed := diffRecipients([]string{"a", "b", "c"}, []string{"b", "c", "d", "e"})
+	if !slices.Equal(added, []string{"d", "e"}) {
+		t.Errorf("added = %v, want [d e]", added)
+	}
+	if !slices.Equal(removed, []string{"a"}) {
+		t.Errorf("removed = %v, want [a]", removed)
+	}
+
+	added, removed = diffRecipients([]string{"a"}, []string{"a"})
+	if added != nil || removed != nil {
+		t.Errorf("unchanged membership: added = %v, removed = %v, want none", added, removed)
+	}
+}
+
+func TestGroupDMEventData_Rekey(t *testing.T) {
+	st := &groupDMState{
+		ChannelID:         "ch-1",
+		Encrypted:         true,
+		MembershipVersion: 4,
+		Recipients:        []federatedUserInfo{{ID: "u1"}, {ID: "u2"}},
+	}
+	tests := []struct {
+		encrypted, changed, want bool
+	}{
+		{true, true, true},
+		{true, false, false},
+		{false, true, false},
+	}
+	for _, tt := range tests {
+		st.Encrypted = tt.encrypted
+		var got struct {
+			RecipientIDs      []string `json:"recipient_ids"`
+			MembershipVersion int64    `json:"membership_version"`
+			RekeyRequired     bool     `json:"mls_rekey_required"`
+		}
+		if err := json.Unmarshal(groupDMEventData(st, tt.changed), &got); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		if got.RekeyRequired != tt.want {
+			t.Errorf("encrypted=%v changed=%v: mls_rekey_required = %v, want %v", tt.encrypted, tt.changed, got.RekeyRequired, tt.want)
+		}
+		if !slices.Equal(got.RecipientIDs, []string{"u1", "u2"}) || got.MembershipVersion != 4 {
+			t.Errorf("payload = %+v, want recipients [u1 u2] at version 4", got)
+		}
+	}
+}
+
+func TestFederatedDMMessageRequest_Encrypted(t *testing.T) {
+	session := "session-1"
+	req := federatedDMMessageRequest{
+		RemoteChannelID: "ch-200",
+		Message: federatedMessageData{
+			ID:                  "msg-3",
+			AuthorID:            "user-3",
+			Content:             "bWxzLWNpcGhlcnRleHQ=",
+			Encrypted:           true,
+			EncryptionSessionID: &session,
+			CreatedAt:           time.Now(),
+		},
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	var decoded federatedDMMessageRequest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if !decoded.Message.Encrypted {
+		t.Error("Message.Encrypted should be true")
+	}
+	if decoded.Message.EncryptionSessionID == nil || *decoded.Message.EncryptionSessionID != session {
+		t.Errorf("Message.EncryptionSessionID = %v, want %q", decoded.Message.EncryptionSessionID, session)
+	}
+}
+
+func TestDMMLSWelcome_JSON(t *testing.T) {
+	wm := dmMLSWelcome{
+		ID:         "wm-1",
+		SenderID:   "user-1",
+		ReceiverID: "user-2",
+		Data:       []byte{0x01, 0x02, 0x03},
+	}
+
+	data, err := json.Marshal(FederatedMessage{Type: "MLS_WELCOME", ChannelID: "ch-1", Data: wm})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	// Inbound messages decode Data generically and re-marshal it before it
+	// reaches persistInboundMLS, so the bytes must survive that round trip.
+	var msg FederatedMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	eventData, err := json.Marshal(msg.Data)
+	if err != nil {
+		t.Fatalf("re-marshal error: %v", err)
+	}
+	var decoded dmMLSWelcome
+	if err := json.Unmarshal(eventData, &decoded); err != nil {
+		t.Fatalf("unmarshal data error: %v", err)
+	}
+	if decoded.ReceiverID != "user-2" || string(decoded.Data) != string(wm.Data) {
+		t.Errorf("decoded = %+v, want %+v", decoded, wm)
+	}
+}
@@ -0,0 +1,141 @@
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// maxFederatedEmoji caps the instance emoji listed to a peer.
+const maxFederatedEmoji = 500
+
+// federatedEmoji is an instance emoji as listed to peers. FileID names the
+// image on the owning instance's signed media endpoint.
+type federatedEmoji struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Animated  bool      `json:"animated"`
+	FileID    string    `json:"file_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HandleFederatedInstanceEmoji lists this instance's emoji to a verified
+// peer, so its users can render and use them.
+// POST /federation/v1/emoji
+func (ss *SyncService) HandleFederatedInstanceEmoji(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := ss.verifyFederationRequest(w, r); !ok {
+		return
+	}
+
+	rows, err := ss.fed.pool.Query(r.Context(),
+		`SELECT id, name, animated, file_id, created_at FROM instance_emoji
+		 ORDER BY lower(name)
+		 LIMIT $1`, maxFederatedEmoji)
+	if err != nil {
+		http.Error(w, "Failed to query emoji", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	emoji := make([]federatedEmoji, 0)
+	for rows.Next() {
+		var e federatedEmoji
+		if err := rows.Scan(&e.ID, &e.Name, &e.Animated, &e.FileID, &e.CreatedAt); err != nil {
+			http.Error(w, "Failed to read emoji", http.StatusInternalServerError)
+			return
+		}
+		emoji = append(emoji, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(emoji)
+}
+
+// HandleProxyRemoteEmoji fetches a peer's instance emoji for a local user.
+// Their images are served through the federation media proxy.
+// GET /api/v1/federation/peers/{peerID}/emoji
+func (ss *SyncService) HandleProxyRemoteEmoji(w http.ResponseWriter, r *http.Request) {
+	if auth.UserIDFromContext(r.Context()) == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	peerID := chi.URLParam(r, "peerID")
+	ctx := r.Context()
+
+	var peerDomain, peerStatus string
+	err := ss.fed.pool.QueryRow(ctx,
+		`SELECT i.domain, fp.status FROM federation_peers fp
+		 JOIN instances i ON i.id = fp.peer_id
+		 WHERE fp.instance_id = $1 AND fp.peer_id = $2`,
+		ss.fed.instanceID, peerID,
+	).Scan(&peerDomain, &peerStatus)
+	if err != nil {
+		http.Error(w, "Federation peer not found", http.StatusNotFound)
+		return
+	}
+	if peerStatus != "active" {
+		http.Error(w, "Federation peer is not active", http.StatusForbidden)
+		return
+	}
+
+	respBody, statusCode, err := ss.signAndPost(ctx,
+		fmt.Sprintf("https://%s/federation/v1/emoji", peerDomain), map[string]string{"action": "list_emoji"})
+	if err != nil {
+		ss.logger.Warn("failed to fetch remote instance emoji",
+			slog.String("peer", peerDomain), slog.String("error", err.Error()))
+		http.Error(w, "Failed to contact remote instance", http.StatusBadGateway)
+		return
+	}
+	if statusCode != http.StatusOK {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		w.Write(respBody)
+		return
+	}
+
+	var remote []federatedEmoji
+	if err := json.Unmarshal(respBody, &remote); err != nil {
+		http.Error(w, "Invalid response from remote instance", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": remoteEmoji(peerID, remote)})
+}
+
+// remoteEmoji converts a peer's listed emoji to the local emoji model, with
+// image URLs on the federation media proxy. At most maxFederatedEmoji are
+// kept; entries without an ID, name or file, or whose file ID the media
+// proxy would refuse, are dropped.
+func remoteEmoji(peerID string, listed []federatedEmoji) []models.CustomEmoji {
+	emoji := make([]models.CustomEmoji, 0, min(len(listed), maxFederatedEmoji))
+	for _, e := range listed {
+		if len(emoji) == maxFederatedEmoji {
+			break
+		}
+		if e.ID == "" || e.Name == "" || e.FileID == "" ||
+			strings.Contains(e.FileID, "..") || strings.ContainsAny(e.FileID, `/\`) {
+			continue
+		}
+		fileID, instanceID := e.FileID, peerID
+		emoji = append(emoji, models.CustomEmoji{
+			ID:         e.ID,
+			Name:       e.Name,
+			Animated:   e.Animated,
+			Origin:     models.EmojiOriginRemote,
+			FileID:     &fileID,
+			InstanceID: &instanceID,
+			URL:        "/api/v1/federation/media/" + peerID + "/" + fileID,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	return emoji
+}
@@ -0,0 +1,45 @@
+package federation
+
+import (
+	"testing"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+func TestRemoteEmoji(t *testing.T) {
+	listed := []federatedEmoji{
+		{ID: "e1", Name: "wave", FileID: "f1", Animated: true},
+		{ID: "e2", Name: "", FileID: "f2"},
+		{ID: "e3", Name: "sneaky", FileID: "../admin"},
+		{ID: "e4", Name: "slash", FileID: "a/b"},
+		{ID: "e5", Name: "nofile"},
+	}
+
+	got := remoteEmoji("peer-1", listed)
+	if len(got) != 1 {
+		t.Fatalf("len(remoteEmoji) = %d, want 1", len(got))
+	}
+	e := got[0]
+	if e.ID != "e1" || e.Name != "wave" || !e.Animated {
+		t.Errorf("emoji = %+v, want e1 wave animated", e)
+	}
+	if e.Origin != models.EmojiOriginRemote {
+		t.Errorf("Origin = %q, want %q", e.Origin, models.EmojiOriginRemote)
+	}
+	if e.InstanceID == nil || *e.InstanceID != "peer-1" {
+		t.Errorf("InstanceID = %v, want peer-1", e.InstanceID)
+	}
+	if want := "/api/v1/federation/media/peer-1/f1"; e.URL != want {
+		t.Errorf("URL = %q, want %q", e.URL, want)
+	}
+}
+
+func TestRemoteEmoji_Capped(t *testing.T) {
+	listed := make([]federatedEmoji, maxFederatedEmoji+10)
+	for i := range listed {
+		listed[i] = federatedEmoji{ID: "e", Name: "n", FileID: "f"}
+	}
+	if got := remoteEmoji("peer-1", listed); len(got) != maxFederatedEmoji {
+		t.Errorf("len(remoteEmoji) = %d, want %d", len(got), maxFederatedEmoji)
+	}
+}
@@ -121,6 +121,8 @@ type Service struct {
 	domain          string
 	privateKey      ed25519.PrivateKey
 	enforceIPCheck  bool
+	maxTimestampAge time.Duration // oldest inbound payload timestamp accepted
+	clockSkew       time.Duration // how far in the future a payload timestamp may be
 	logger          *slog.Logger
 	onPeerRecovered func(ctx context.Context, peerID string) // called when a peer transitions to healthy
 
@@ -128,6 +130,8 @@ type Service struct {
 	allowedCache *TTLCache[bool]   // remoteInstanceID -> allowed
 	pubKeyCache  *TTLCache[string] // instanceID -> public_key PEM
 	fedModeCache *TTLCache[string] // "__local__" -> federation_mode
+	trustCache   *TTLCache[string] // peerID -> trust_level
+	sandboxCache *TTLCache[bool]   // peerID -> sandbox, DryRunSetting -> dry run
 
 	// Batched counter increments — flushed every 5s by StartCounterFlusher.
 	counterMu       sync.Mutex
@@ -146,6 +150,10 @@ type Config struct {
 	PrivateKey     ed25519.PrivateKey // loaded from PEM at startup
 	EnforceIPCheck bool
 	Logger         *slog.Logger
+
+	// Inbound timestamp window; zero values use 5 minutes and 30 seconds.
+	MaxTimestampAge time.Duration
+	ClockSkew       time.Duration
 }
 
 // counterEntry accumulates sent/received event counts per peer for batch flushing.
@@ -162,12 +170,22 @@ func New(cfg Config) *Service {
 		domain:          cfg.Domain,
 		privateKey:      cfg.PrivateKey,
 		enforceIPCheck:  cfg.EnforceIPCheck,
+		maxTimestampAge: cfg.MaxTimestampAge,
+		clockSkew:       cfg.ClockSkew,
 		logger:          cfg.Logger,
 		allowedCache:    NewTTLCache[bool](60*time.Second, 500),
 		pubKeyCache:     NewTTLCache[string](5*time.Minute, 500),
 		fedModeCache:    NewTTLCache[string](60*time.Second, 1),
+		trustCache:      NewTTLCache[string](60*time.Second, 500),
+		sandboxCache:    NewTTLCache[bool](60*time.Second, 500),
 		pendingCounters: make(map[string]*counterEntry),
 	}
+	if s.maxTimestampAge <= 0 {
+		s.maxTimestampAge = defaultMaxTimestampAge
+	}
+	if s.clockSkew <= 0 {
+		s.clockSkew = defaultClockSkew
+	}
 
 	// Pre-load federation mode cache at startup.
 	var mode string
@@ -525,6 +543,7 @@ func (s *Service) migrateInstanceID(ctx context.Context, oldID, newID string) er
 		`UPDATE guilds SET instance_id = $1 WHERE instance_id = $2`,
 		`UPDATE users SET instance_id = $1 WHERE instance_id = $2`,
 		`UPDATE federation_key_audit SET instance_id = $1 WHERE instance_id = $2`,
+		`UPDATE federation_instance_moves SET instance_id = $1 WHERE instance_id = $2`,
 	}
 	for _, q := range fkUpdates {
 		if _, err := tx.Exec(ctx, q, newID, oldID); err != nil {
@@ -753,7 +772,8 @@ func (s *Service) HandleHandshake(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify timestamp freshness.
-	if msg := validateTimestamp(req.Timestamp); msg != "" {
+	if msg := s.validateTimestamp(req.Timestamp); msg != "" {
+		s.recordRejection(r, req.SenderID, RejectClockSkew, msg)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(HandshakeResponse{
@@ -1127,6 +1147,8 @@ func (s *Service) GetPeerCapabilities(ctx context.Context, peerID string) ([]str
 // HandleUserLookup handles GET /federation/v1/users/lookup?username=... — a public
 // endpoint that allows remote instances to look up a local user by username.
 // Rate-limited. Returns 403 if the instance's federation_mode is not "open".
+// Bio, pronouns, banner, connections and activity follow the user's profile
+// privacy.
 func (s *Service) HandleUserLookup(w http.ResponseWriter, r *http.Request) {
 	username := r.URL.Query().Get("username")
 	if username == "" {
@@ -1155,20 +1177,17 @@ func (s *Service) HandleUserLookup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Look up the user.
-	var user struct {
-		ID          string
-		Username    string
-		DisplayName *string
-		AvatarID    *string
-		Bio         *string
-		CreatedAt   time.Time
-	}
+	var user userProfileResponse
+	var activity profileActivity
+	var createdAt time.Time
 	err = s.pool.QueryRow(r.Context(),
-		`SELECT id, username, display_name, avatar_id, bio, created_at
+		`SELECT id, username, display_name, avatar_id, bio, pronouns, banner_id,
+		        activity_type, activity_name, created_at
 		 FROM users
 		 WHERE LOWER(username) = LOWER($1) AND instance_id = $2`,
 		username, s.instanceID,
-	).Scan(&user.ID, &user.Username, &user.DisplayName, &user.AvatarID, &user.Bio, &user.CreatedAt)
+	).Scan(&user.ID, &user.Username, &user.DisplayName, &user.AvatarID, &user.Bio, &user.Pronouns, &user.BannerID,
+		&activity.Type, &activity.Name, &createdAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			http.Error(w, "user not found", http.StatusNotFound)
@@ -1179,15 +1198,40 @@ func (s *Service) HandleUserLookup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	// Optional fields are included only if the user shares them with
+	// remote instances.
+	privacy := LoadProfilePrivacy(r.Context(), s.pool, user.ID)
+	if privacy.Connections == models.ProfileVisibilityEveryone {
+		user.Connections = loadProfileConnections(r.Context(), s.pool, user.ID)
+	}
+	if activity.Type != nil || activity.Name != nil {
+		user.Activity = &activity
+	}
+	applyProfilePrivacy(&user, privacy)
+
+	resp := map[string]interface{}{
 		"id":           user.ID,
 		"username":     user.Username,
 		"display_name": user.DisplayName,
 		"avatar_id":    user.AvatarID,
 		"bio":          user.Bio,
-		"created_at":   user.CreatedAt.Format(time.RFC3339),
-	})
+		"created_at":   createdAt.Format(time.RFC3339),
+	}
+	if user.Pronouns != nil {
+		resp["pronouns"] = user.Pronouns
+	}
+	if user.BannerID != nil {
+		resp["banner_id"] = user.BannerID
+	}
+	if user.Connections != nil {
+		resp["connections"] = user.Connections
+	}
+	if user.Activity != nil {
+		resp["activity"] = user.Activity
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 // PeerSupportsCapability checks if a given peer supports a specific capability.
@@ -1211,20 +1255,6 @@ func ComputeKeyFingerprint(publicKeyPEM string) (string, error) {
 	return hex.EncodeToString(hash[:]), nil
 }
 
-// validateTimestamp checks that a federation payload timestamp is fresh.
-// Rejects payloads older than 5 minutes or more than 30 seconds in the future.
-func validateTimestamp(ts time.Time) string {
-	now := time.Now().UTC()
-	age := now.Sub(ts)
-	if age > 5*time.Minute {
-		return fmt.Sprintf("timestamp too old: %s ago", age.Truncate(time.Second))
-	}
-	if age < -30*time.Second {
-		return fmt.Sprintf("timestamp too far in the future: %s ahead", (-age).Truncate(time.Second))
-	}
-	return ""
-}
-
 // verifySourceIP checks that the connecting IP matches the stored resolved IPs
 // for a sender instance. Returns an empty string if valid, or a warning message.
 func (s *Service) verifySourceIP(r *http.Request, senderID string) string {
@@ -6,10 +6,12 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
 )
 
 func TestSign_And_Verify(t *testing.T) {
@@ -81,6 +83,34 @@ func TestVerifySignature_BadPEM(t *testing.T) {
 	}
 }
 
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		ts    time.Time
+		fresh bool
+	}{
+		{"now", now, true},
+		{"within max age", now.Add(-4 * time.Minute), true},
+		{"past max age", now.Add(-6 * time.Minute), false},
+		{"within skew", now.Add(20 * time.Second), true},
+		{"past skew", now.Add(2 * time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := checkTimestamp(tt.ts, now, 5*time.Minute, 30*time.Second)
+			if (msg == "") != tt.fresh {
+				t.Errorf("checkTimestamp = %q, want fresh=%v", msg, tt.fresh)
+			}
+		})
+	}
+
+	// A wider tolerance accepts a peer whose clock runs ahead.
+	if msg := checkTimestamp(now.Add(2*time.Minute), now, 5*time.Minute, 5*time.Minute); msg != "" {
+		t.Errorf("checkTimestamp with 5m skew = %q, want fresh", msg)
+	}
+}
+
 func TestDiscoveryResponse_JSON(t *testing.T) {
 	name := "Test Instance"
 	resp := DiscoveryResponse{
@@ -266,6 +296,7 @@ func TestEventTypeToSubject(t *testing.T) {
 		{"CHANNEL_DELETE", "amityvox.channel.delete"},
 		{"VOICE_STATE_UPDATE", events.SubjectVoiceStateUpdate},
 		{"CALL_RING", events.SubjectCallRing},
+		{"CHANNEL_ACK", events.SubjectChannelAck},
 		{"UNKNOWN_EVENT", ""},
 	}
 
@@ -430,3 +461,68 @@ func TestNegotiateCapabilities(t *testing.T) {
 		}
 	}
 }
+
+func TestSanitizeDirectoryEntry(t *testing.T) {
+	long := strings.Repeat("a", 2000)
+	entry := models.DirectoryEntry{
+		Domain:      "spoofed.example.com",
+		Name:        &long,
+		Description: &long,
+	}
+	for i := 0; i < 8; i++ {
+		entry.FeaturedGuilds = append(entry.FeaturedGuilds, models.DirectoryGuild{ID: "g", Name: long})
+	}
+
+	got := sanitizeDirectoryEntry(entry, "real.example.com")
+	if got.Domain != "real.example.com" {
+		t.Errorf("Domain = %q, want the fetched domain", got.Domain)
+	}
+	if len(*got.Name) != 100 || len(*got.Description) != 1000 {
+		t.Errorf("name/description not truncated: %d/%d", len(*got.Name), len(*got.Description))
+	}
+	if len(got.FeaturedGuilds) != directoryMaxFeatured {
+		t.Errorf("featured guilds = %d, want %d", len(got.FeaturedGuilds), directoryMaxFeatured)
+	}
+	if len(got.FeaturedGuilds[0].Name) != 100 {
+		t.Errorf("guild name not truncated: %d", len(got.FeaturedGuilds[0].Name))
+	}
+
+	empty := sanitizeDirectoryEntry(models.DirectoryEntry{}, "x.example.com")
+	if empty.FeaturedGuilds == nil || empty.Name != nil {
+		t.Errorf("empty entry = %+v, want non-nil featured guilds and nil name", empty)
+	}
+}
+
+func TestApplyProfilePrivacy(t *testing.T) {
+	s := func(v string) *string { return &v }
+	full := func() userProfileResponse {
+		return userProfileResponse{
+			ID: "u1", Username: "alice", Bio: s("hi"), Pronouns: s("she/her"), BannerID: s("b1"),
+			Connections: []profileConnection{{Platform: "github", Label: "alice", URL: "https://github.com/alice"}},
+			Activity:    &profileActivity{Type: s("playing"), Name: s("chess")},
+		}
+	}
+
+	p := full()
+	applyProfilePrivacy(&p, models.DefaultProfilePrivacy())
+	if p.Bio != nil || p.Connections != nil || p.Activity != nil {
+		t.Errorf("defaults should keep bio, connections and activity local: %+v", p)
+	}
+	if p.Pronouns == nil || p.BannerID == nil {
+		t.Errorf("defaults should share pronouns and banner: %+v", p)
+	}
+
+	p = full()
+	applyProfilePrivacy(&p, models.ProfilePrivacy{
+		Bio: "everyone", Pronouns: "local", Banner: "local", Connections: "everyone", Activity: "everyone",
+	})
+	if p.Bio == nil || p.Connections == nil || p.Activity == nil {
+		t.Errorf("shared fields were removed: %+v", p)
+	}
+	if p.Pronouns != nil || p.BannerID != nil {
+		t.Errorf("local fields were shared: %+v", p)
+	}
+	if p.ID != "u1" || p.Username != "alice" {
+		t.Errorf("identity fields must always be shared: %+v", p)
+	}
+}
@@ -0,0 +1,748 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// maxGroupDMRecipients matches the member limit enforced by the channels API.
+const maxGroupDMRecipients = 10
+
+var (
+	errGroupDMNotHost  = errors.New("sender is not the host of this group DM")
+	errGroupDMConflict = errors.New("channel ID is already in use by another channel")
+)
+
+// groupDMState is a full snapshot of a federated group DM as seen by its host
+// instance. Snapshots are pushed to every participating instance after each
+// membership or metadata change.
+type groupDMState struct {
+	ChannelID         string              `json:"channel_id"`
+	Name              *string             `json:"name,omitempty"`
+	OwnerID           *string             `json:"owner_id,omitempty"`
+	Encrypted         bool                `json:"encrypted"`
+	MLSEpoch          int64               `json:"mls_epoch"`
+	MembershipVersion int64               `json:"membership_version"`
+	Recipients        []federatedUserInfo `json:"recipients"`
+}
+
+// groupDMRecipientChangeRequest is the signed payload a participating
+// instance sends to the host to add or remove a group DM recipient on behalf
+// of one of its users.
+type groupDMRecipientChangeRequest struct {
+	ChannelID string            `json:"channel_id"`
+	ActorID   string            `json:"actor_id"`
+	User      federatedUserInfo `json:"user"`
+	Add       bool              `json:"add"`
+}
+
+// diffRecipients returns the IDs in desired that are missing from current and
+// the IDs in current that are missing from desired.
+func diffRecipients(current, desired []string) (added, removed []string) {
+	for _, id := range desired {
+		if !slices.Contains(current, id) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range current {
+		if !slices.Contains(desired, id) {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+// groupDMEventData builds the CHANNEL_UPDATE payload published to local
+// clients for a group DM snapshot. When the membership of an encrypted group
+// changed, clients are asked to commit the change to the MLS group.
+func groupDMEventData(st *groupDMState, membershipChanged bool) json.RawMessage {
+	ids := make([]string, 0, len(st.Recipients))
+	for _, u := range st.Recipients {
+		ids = append(ids, u.ID)
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":                 st.ChannelID,
+		"channel_type":       "group",
+		"name":               st.Name,
+		"owner_id":           st.OwnerID,
+		"encrypted":          st.Encrypted,
+		"recipient_ids":      ids,
+		"recipients":         st.Recipients,
+		"membership_version": st.MembershipVersion,
+		"mls_epoch":          st.MLSEpoch,
+		"mls_rekey_required": st.Encrypted && membershipChanged,
+	})
+	return data
+}
+
+// groupDMHost returns the instance that owns a federated group DM. ok is
+// false for channels that are not federated group DMs.
+func (ss *SyncService) groupDMHost(ctx context.Context, channelID string) (string, bool) {
+	var hostID string
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT host_instance_id FROM federated_group_dm_state WHERE channel_id = $1`, channelID,
+	).Scan(&hostID); err != nil {
+		return "", false
+	}
+	return hostID, true
+}
+
+// lookupFederatedUser returns the federation identity of a known user.
+func (ss *SyncService) lookupFederatedUser(ctx context.Context, userID string) (federatedUserInfo, error) {
+	var u federatedUserInfo
+	var instanceID string
+	var domain *string
+	err := ss.fed.pool.QueryRow(ctx,
+		`SELECT u.id, u.username, u.display_name, u.avatar_id, u.instance_id, i.domain
+		 FROM users u LEFT JOIN instances i ON i.id = u.instance_id
+		 WHERE u.id = $1`, userID,
+	).Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarID, &instanceID, &domain)
+	if err != nil {
+		return u, err
+	}
+	if instanceID == ss.fed.instanceID {
+		u.InstanceDomain = ss.fed.domain
+	} else if domain != nil {
+		u.InstanceDomain = *domain
+	}
+	return u, nil
+}
+
+// loadGroupDMState reads the current snapshot of a group DM.
+func (ss *SyncService) loadGroupDMState(ctx context.Context, channelID string) (*groupDMState, error) {
+	st := &groupDMState{ChannelID: channelID}
+	err := ss.fed.pool.QueryRow(ctx,
+		`SELECT c.name, c.owner_id, COALESCE(c.encrypted, false),
+		        COALESCE(m.epoch, 0), COALESCE(s.membership_version, 0)
+		 FROM channels c
+		 LEFT JOIN mls_group_states m ON m.channel_id = c.id
+		 LEFT JOIN federated_group_dm_state s ON s.channel_id = c.id
+		 WHERE c.id = $1 AND c.channel_type = 'group'`, channelID,
+	).Scan(&st.Name, &st.OwnerID, &st.Encrypted, &st.MLSEpoch, &st.MembershipVersion)
+	if err != nil {
+		return nil, fmt.Errorf("loading group DM %s: %w", channelID, err)
+	}
+
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT user_id FROM channel_recipients WHERE channel_id = $1 ORDER BY joined_at, user_id`, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("loading group DM recipients: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating group DM recipients: %w", err)
+	}
+
+	for _, id := range ids {
+		u, err := ss.lookupFederatedUser(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("looking up group DM recipient %s: %w", id, err)
+		}
+		st.Recipients = append(st.Recipients, u)
+	}
+	return st, nil
+}
+
+// routeGroupDMUpdate handles a local CHANNEL_UPDATE for a group DM with
+// remote participants. The host pushes a new snapshot instead of forwarding
+// the raw event; other instances never forward group DM updates because the
+// host is authoritative. Returns false if the channel is not such a group DM.
+func (ss *SyncService) routeGroupDMUpdate(ctx context.Context, channelID string) bool {
+	if hostID, ok := ss.groupDMHost(ctx, channelID); ok {
+		if hostID == ss.fed.instanceID {
+			if _, err := ss.syncGroupDMState(ctx, channelID); err != nil {
+				ss.logger.Warn("failed to sync group DM state",
+					slog.String("channel_id", channelID), slog.String("error", err.Error()))
+			}
+		}
+		return true
+	}
+
+	// A local group DM becomes hosted here the first time it has remote
+	// participants. Mirrors created by peers that predate group DM state use
+	// their own channel IDs and keep the legacy event forwarding.
+	var hosted bool
+	ss.fed.pool.QueryRow(ctx,
+		`SELECT EXISTS(
+		   SELECT 1 FROM channels c
+		   JOIN channel_recipients cr ON cr.channel_id = c.id
+		   JOIN users u ON u.id = cr.user_id AND u.instance_id <> $2
+		   WHERE c.id = $1 AND c.channel_type = 'group')
+		 AND NOT EXISTS(
+		   SELECT 1 FROM federation_dm_channel_map
+		   WHERE local_channel_id = $1 AND remote_channel_id <> local_channel_id)`,
+		channelID, ss.fed.instanceID,
+	).Scan(&hosted)
+	if !hosted {
+		return false
+	}
+	if _, err := ss.syncGroupDMState(ctx, channelID); err != nil {
+		ss.logger.Warn("failed to sync group DM state",
+			slog.String("channel_id", channelID), slog.String("error", err.Error()))
+	}
+	return true
+}
+
+// syncGroupDMState is run by the host after any change to a group DM. It
+// bumps the membership version, pushes the new snapshot to every instance
+// that participates or participated, and updates the channel's peers to the
+// instances of the current recipients.
+func (ss *SyncService) syncGroupDMState(ctx context.Context, channelID string) (*groupDMState, error) {
+	tag, err := ss.fed.pool.Exec(ctx,
+		`INSERT INTO federated_group_dm_state (channel_id, host_instance_id, membership_version)
+		 VALUES ($1, $2, 1)
+		 ON CONFLICT (channel_id) DO UPDATE SET
+		   membership_version = federated_group_dm_state.membership_version + 1,
+		   updated_at = now()
+		 WHERE federated_group_dm_state.host_instance_id = EXCLUDED.host_instance_id`,
+		channelID, ss.fed.instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("bumping group DM version: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, errGroupDMNotHost
+	}
+
+	st, err := ss.loadGroupDMState(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everyone who may hold a copy of the channel gets the snapshot, so that
+	// instances whose last user was removed learn about it.
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT i.id, i.domain,
+		        EXISTS(SELECT 1 FROM channel_recipients cr JOIN users u ON u.id = cr.user_id
+		               WHERE cr.channel_id = $1 AND u.instance_id = i.id)
+		 FROM instances i
+		 WHERE i.id <> $2 AND (
+		   i.id IN (SELECT u.instance_id FROM channel_recipients cr JOIN users u ON u.id = cr.user_id
+		            WHERE cr.channel_id = $1)
+		   OR i.id IN (SELECT instance_id FROM federation_channel_peers WHERE channel_id = $1))`,
+		channelID, ss.fed.instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("querying group DM instances: %w", err)
+	}
+	var targets []peerTarget
+	var current []string
+	for rows.Next() {
+		var t peerTarget
+		var participating bool
+		if rows.Scan(&t.peerID, &t.domain, &participating) != nil {
+			continue
+		}
+		targets = append(targets, t)
+		if participating {
+			current = append(current, t.peerID)
+		}
+	}
+	rows.Close()
+
+	if err := ss.setGroupDMPeers(ctx, ss.fed.pool, channelID, current); err != nil {
+		ss.logger.Warn("failed to update group DM peers",
+			slog.String("channel_id", channelID), slog.String("error", err.Error()))
+	}
+
+	sendCtx := context.WithoutCancel(ctx)
+	for _, t := range targets {
+		target := t
+		go func() {
+			ss.deliverySem <- struct{}{}
+			defer func() { <-ss.deliverySem }()
+			url := fmt.Sprintf("https://%s/federation/v1/dm/group/state", target.domain)
+			_, status, err := ss.signAndPost(sendCtx, url, st)
+			if err == nil && status != http.StatusOK {
+				err = fmt.Errorf("remote returned %d", status)
+			}
+			if err != nil {
+				ss.logger.Warn("failed to push group DM state",
+					slog.String("channel_id", channelID),
+					slog.String("domain", target.domain),
+					slog.String("error", err.Error()))
+			}
+		}()
+	}
+	return st, nil
+}
+
+// groupDMExecer is satisfied by both the pool and a transaction.
+type groupDMExecer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// setGroupDMPeers makes instanceIDs the complete set of peers and mirror
+// mappings of a group DM. Group DMs use the same channel ID everywhere, so
+// the mapping is the identity.
+func (ss *SyncService) setGroupDMPeers(ctx context.Context, db groupDMExecer, channelID string, instanceIDs []string) error {
+	if instanceIDs == nil {
+		instanceIDs = []string{}
+	}
+	if _, err := db.Exec(ctx,
+		`DELETE FROM federation_channel_peers WHERE channel_id = $1 AND NOT (instance_id = ANY($2))`,
+		channelID, instanceIDs); err != nil {
+		return err
+	}
+	if _, err := db.Exec(ctx,
+		`DELETE FROM federation_dm_channel_map WHERE local_channel_id = $1 AND NOT (remote_instance_id = ANY($2))`,
+		channelID, instanceIDs); err != nil {
+		return err
+	}
+	if _, err := db.Exec(ctx,
+		`INSERT INTO federation_channel_peers (channel_id, instance_id)
+		 SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`,
+		channelID, instanceIDs); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx,
+		`INSERT INTO federation_dm_channel_map (local_channel_id, remote_channel_id, remote_instance_id, created_at)
+		 SELECT $1, $1, unnest($2::text[]), now() ON CONFLICT DO NOTHING`,
+		channelID, instanceIDs)
+	return err
+}
+
+// applyGroupDMState applies a snapshot received from a group DM's host,
+// creating the local copy if needed. Snapshots that are not newer than the
+// local state are ignored, which resolves concurrent changes in favour of the
+// order the host applied them in. Returns whether the snapshot was applied.
+func (ss *SyncService) applyGroupDMState(ctx context.Context, hostID string, st *groupDMState) (bool, error) {
+	// Create stubs for remote recipients first; the host may only vouch for
+	// users of instances we already know, and never for our own users.
+	var memberIDs []string
+	instanceSet := []string{hostID}
+	for _, u := range st.Recipients {
+		if u.ID == "" {
+			continue
+		}
+		if u.InstanceDomain == ss.fed.domain {
+			if ss.isInstanceUser(ctx, u.ID, ss.fed.instanceID) {
+				memberIDs = append(memberIDs, u.ID)
+			}
+			continue
+		}
+		var instanceID string
+		if err := ss.fed.pool.QueryRow(ctx,
+			`SELECT id FROM instances WHERE domain = $1`, u.InstanceDomain,
+		).Scan(&instanceID); err != nil {
+			ss.logger.Warn("skipping group DM recipient from unknown instance",
+				slog.String("channel_id", st.ChannelID),
+				slog.String("user_id", u.ID),
+				slog.String("domain", u.InstanceDomain))
+			continue
+		}
+		ss.ensureRemoteUserStub(ctx, instanceID, u)
+		if !ss.isInstanceUser(ctx, u.ID, instanceID) {
+			continue
+		}
+		memberIDs = append(memberIDs, u.ID)
+		if !slices.Contains(instanceSet, instanceID) {
+			instanceSet = append(instanceSet, instanceID)
+		}
+	}
+	if memberIDs == nil {
+		memberIDs = []string{}
+	}
+	ownerID := st.OwnerID
+	if ownerID != nil && !slices.Contains(memberIDs, *ownerID) {
+		ownerID = nil
+	}
+
+	tx, err := ss.fed.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var currentHost string
+	var version int64
+	err = tx.QueryRow(ctx,
+		`SELECT host_instance_id, membership_version FROM federated_group_dm_state
+		 WHERE channel_id = $1 FOR UPDATE`, st.ChannelID,
+	).Scan(&currentHost, &version)
+	switch {
+	case err == nil:
+		if currentHost != hostID {
+			return false, errGroupDMNotHost
+		}
+		if st.MembershipVersion <= version {
+			return false, nil
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		var channelType string
+		err = tx.QueryRow(ctx, `SELECT channel_type FROM channels WHERE id = $1`, st.ChannelID).Scan(&channelType)
+		if err == nil {
+			// Only a copy created through /dm/create for this host may be adopted.
+			var mapped bool
+			tx.QueryRow(ctx,
+				`SELECT EXISTS(SELECT 1 FROM federation_dm_channel_map
+				 WHERE local_channel_id = $1 AND remote_channel_id = $1 AND remote_instance_id = $2)`,
+				st.ChannelID, hostID,
+			).Scan(&mapped)
+			if channelType != "group" || !mapped {
+				return false, errGroupDMConflict
+			}
+		} else if errors.Is(err, pgx.ErrNoRows) {
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO channels (id, channel_type, name, owner_id, encrypted, created_at)
+				 VALUES ($1, 'group', $2, $3, $4, now())`,
+				st.ChannelID, st.Name, ownerID, st.Encrypted); err != nil {
+				return false, fmt.Errorf("creating group DM copy: %w", err)
+			}
+		} else {
+			return false, err
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO federated_group_dm_state (channel_id, host_instance_id) VALUES ($1, $2)`,
+			st.ChannelID, hostID); err != nil {
+			return false, err
+		}
+	default:
+		return false, err
+	}
+
+	var current []string
+	rows, err := tx.Query(ctx, `SELECT user_id FROM channel_recipients WHERE channel_id = $1`, st.ChannelID)
+	if err != nil {
+		return false, err
+	}
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			current = append(current, id)
+		}
+	}
+	rows.Close()
+	added, removed := diffRecipients(current, memberIDs)
+
+	if _, err := tx.Exec(ctx,
+		`UPDATE channels SET name = $2, owner_id = $3, encrypted = $4 WHERE id = $1`,
+		st.ChannelID, st.Name, ownerID, st.Encrypted); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM channel_recipients WHERE channel_id = $1 AND NOT (user_id = ANY($2))`,
+		st.ChannelID, memberIDs); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO channel_recipients (channel_id, user_id, joined_at)
+		 SELECT $1, unnest($2::text[]), now() ON CONFLICT DO NOTHING`,
+		st.ChannelID, memberIDs); err != nil {
+		return false, err
+	}
+	if err := ss.setGroupDMPeers(ctx, tx, st.ChannelID, instanceSet); err != nil {
+		return false, err
+	}
+	// The host's MLS epoch is authoritative; never move a local epoch back.
+	if st.MLSEpoch > 0 {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO mls_group_states (channel_id, epoch, updated_at) VALUES ($1, $2, now())
+			 ON CONFLICT (channel_id) DO UPDATE SET
+			   epoch = GREATEST(mls_group_states.epoch, EXCLUDED.epoch), updated_at = now()`,
+			st.ChannelID, st.MLSEpoch); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE federated_group_dm_state SET membership_version = $2, updated_at = now() WHERE channel_id = $1`,
+		st.ChannelID, st.MembershipVersion); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	ss.bus.Publish(ctx, events.SubjectChannelUpdate, events.Event{
+		Type:             "CHANNEL_UPDATE",
+		ChannelID:        st.ChannelID,
+		Data:             groupDMEventData(st, len(added) > 0 || len(removed) > 0),
+		OriginInstanceID: hostID,
+	})
+	return true, nil
+}
+
+// HandleFederatedGroupDMState handles POST /federation/v1/dm/group/state —
+// receives a group DM snapshot from the group's host instance.
+func (ss *SyncService) HandleFederatedGroupDMState(w http.ResponseWriter, r *http.Request) {
+	signed, senderID, ok := ss.verifyFederationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var st groupDMState
+	if err := json.Unmarshal(signed.Payload, &st); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if st.ChannelID == "" || st.MembershipVersion <= 0 {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+	if len(st.Recipients) > maxGroupDMRecipients {
+		http.Error(w, "Too many recipients", http.StatusBadRequest)
+		return
+	}
+
+	applied, err := ss.applyGroupDMState(r.Context(), senderID, &st)
+	switch {
+	case errors.Is(err, errGroupDMNotHost):
+		http.Error(w, "Sender is not the group DM host", http.StatusForbidden)
+		return
+	case errors.Is(err, errGroupDMConflict):
+		http.Error(w, "Channel ID conflict", http.StatusConflict)
+		return
+	case err != nil:
+		ss.logger.Error("failed to apply group DM state",
+			slog.String("channel_id", st.ChannelID),
+			slog.String("sender_id", senderID),
+			slog.String("error", err.Error()))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]bool{"applied": applied})
+}
+
+// HandleFederatedGroupDMRecipients handles POST /federation/v1/dm/group/recipients —
+// applies a recipient change requested by a user of another participating
+// instance to a group DM hosted here, using the same rules as the local API.
+// Responds with the resulting snapshot.
+func (ss *SyncService) HandleFederatedGroupDMRecipients(w http.ResponseWriter, r *http.Request) {
+	signed, senderID, ok := ss.verifyFederationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req groupDMRecipientChangeRequest
+	if err := json.Unmarshal(signed.Payload, &req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if req.ChannelID == "" || req.ActorID == "" || req.User.ID == "" {
+		http.Error(w, "Missing required fields", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if hostID, ok := ss.groupDMHost(ctx, req.ChannelID); !ok || hostID != ss.fed.instanceID {
+		http.Error(w, "Unknown group DM", http.StatusNotFound)
+		return
+	}
+	if !ss.validateSenderUser(ctx, w, senderID, req.ActorID) {
+		return
+	}
+
+	tx, err := ss.fed.pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the group so concurrent changes from different instances are
+	// applied one at a time.
+	var ownerID *string
+	if err := tx.QueryRow(ctx,
+		`SELECT c.owner_id FROM channels c
+		 JOIN federated_group_dm_state s ON s.channel_id = c.id
+		 WHERE c.id = $1 FOR UPDATE OF s`, req.ChannelID,
+	).Scan(&ownerID); err != nil {
+		http.Error(w, "Unknown group DM", http.StatusNotFound)
+		return
+	}
+
+	var actorIsMember, targetIsMember bool
+	var count int
+	tx.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $2),
+		        EXISTS(SELECT 1 FROM channel_recipients WHERE channel_id = $1 AND user_id = $3),
+		        (SELECT COUNT(*) FROM channel_recipients WHERE channel_id = $1)`,
+		req.ChannelID, req.ActorID, req.User.ID,
+	).Scan(&actorIsMember, &targetIsMember, &count)
+	if !actorIsMember {
+		http.Error(w, "Actor is not a member of this group DM", http.StatusForbidden)
+		return
+	}
+
+	if req.Add {
+		if targetIsMember {
+			http.Error(w, "User is already a member of this group DM", http.StatusConflict)
+			return
+		}
+		if count >= maxGroupDMRecipients {
+			http.Error(w, "Group DM cannot have more than 10 members", http.StatusBadRequest)
+			return
+		}
+		if req.User.InstanceDomain == ss.fed.domain {
+			if !ss.isInstanceUser(ctx, req.User.ID, ss.fed.instanceID) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+		} else {
+			var instanceID string
+			if err := ss.fed.pool.QueryRow(ctx,
+				`SELECT id FROM instances WHERE domain = $1`, req.User.InstanceDomain,
+			).Scan(&instanceID); err != nil {
+				http.Error(w, "Unknown instance for user", http.StatusBadRequest)
+				return
+			}
+			ss.ensureRemoteUserStub(ctx, instanceID, req.User)
+			if !ss.isInstanceUser(ctx, req.User.ID, instanceID) {
+				http.Error(w, "User does not belong to the claimed instance", http.StatusForbidden)
+				return
+			}
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO channel_recipients (channel_id, user_id, joined_at) VALUES ($1, $2, now())`,
+			req.ChannelID, req.User.ID); err != nil {
+			ss.logger.Error("failed to add federated group DM recipient", slog.String("error", err.Error()))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		if req.User.ID != req.ActorID && (ownerID == nil || *ownerID != req.ActorID) {
+			http.Error(w, "Only the group DM owner can remove other members", http.StatusForbidden)
+			return
+		}
+		if !targetIsMember {
+			http.Error(w, "User is not a member of this group DM", http.StatusNotFound)
+			return
+		}
+		if _, err := tx.Exec(ctx,
+			`DELETE FROM channel_recipients WHERE channel_id = $1 AND user_id = $2`,
+			req.ChannelID, req.User.ID); err != nil {
+			ss.logger.Error("failed to remove federated group DM recipient", slog.String("error", err.Error()))
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		if ownerID != nil && *ownerID == req.User.ID {
+			if err := transferGroupDMOwnership(ctx, tx, req.ChannelID); err != nil {
+				ss.logger.Error("failed to transfer group DM ownership", slog.String("error", err.Error()))
+				http.Error(w, "Internal error", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	st, err := ss.syncGroupDMState(ctx, req.ChannelID)
+	if err != nil {
+		ss.logger.Error("failed to sync group DM state",
+			slog.String("channel_id", req.ChannelID), slog.String("error", err.Error()))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	// Marked with the requesting instance as origin: the snapshot has already
+	// been pushed, so the router must not sync again.
+	ss.bus.Publish(ctx, events.SubjectChannelUpdate, events.Event{
+		Type:             "CHANNEL_UPDATE",
+		ChannelID:        req.ChannelID,
+		Data:             groupDMEventData(st, true),
+		OriginInstanceID: senderID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(st)
+}
+
+// transferGroupDMOwnership hands a group DM whose owner left to the
+// longest-standing remaining recipient.
+func transferGroupDMOwnership(ctx context.Context, tx pgx.Tx, channelID string) error {
+	_, err := tx.Exec(ctx,
+		`UPDATE channels SET owner_id = (
+		   SELECT user_id FROM channel_recipients WHERE channel_id = $1
+		   ORDER BY joined_at, user_id LIMIT 1)
+		 WHERE id = $1`, channelID)
+	return err
+}
+
+// ProxyGroupDMRecipientChange forwards a recipient change on a group DM
+// hosted by another instance to that host and applies the resulting snapshot
+// locally. proxied is false for group DMs hosted here; when proxied, ok
+// reports whether the host accepted the change (on failure the error
+// response has been written).
+func (ss *SyncService) ProxyGroupDMRecipientChange(w http.ResponseWriter, r *http.Request, channelID, userID, targetUserID string, add bool) (proxied, ok bool) {
+	ctx := r.Context()
+	hostID, isGroup := ss.groupDMHost(ctx, channelID)
+	if !isGroup || hostID == ss.fed.instanceID {
+		return false, false
+	}
+
+	var hostDomain string
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT domain FROM instances WHERE id = $1`, hostID,
+	).Scan(&hostDomain); err != nil {
+		writeGroupDMProxyError(w, http.StatusBadGateway, "Failed to resolve group DM host instance")
+		return true, false
+	}
+	target, err := ss.lookupFederatedUser(ctx, targetUserID)
+	if err != nil {
+		writeGroupDMProxyError(w, http.StatusNotFound, "User not found")
+		return true, false
+	}
+
+	body, status, err := ss.signAndPost(ctx,
+		fmt.Sprintf("https://%s/federation/v1/dm/group/recipients", hostDomain),
+		groupDMRecipientChangeRequest{ChannelID: channelID, ActorID: userID, User: target, Add: add})
+	if err != nil {
+		ss.logger.Error("failed to proxy group DM recipient change",
+			slog.String("channel_id", channelID),
+			slog.String("domain", hostDomain),
+			slog.String("error", err.Error()))
+		writeGroupDMProxyError(w, http.StatusBadGateway, "Failed to reach group DM host instance")
+		return true, false
+	}
+	if status != http.StatusOK {
+		writeGroupDMProxyError(w, status, strings.TrimSpace(string(body)))
+		return true, false
+	}
+
+	var st groupDMState
+	if err := json.Unmarshal(body, &st); err != nil || st.ChannelID != channelID {
+		writeGroupDMProxyError(w, http.StatusBadGateway, "Invalid response from group DM host instance")
+		return true, false
+	}
+	applyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if _, err := ss.applyGroupDMState(applyCtx, hostID, &st); err != nil {
+		ss.logger.Warn("failed to apply group DM state from host",
+			slog.String("channel_id", channelID), slog.String("error", err.Error()))
+	}
+	return true, true
+}
+
+// writeGroupDMProxyError writes an API-style error for a failed group DM proxy.
+func writeGroupDMProxyError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"code":    "FEDERATION_PROXY_ERROR",
+			"message": message,
+		},
+	})
+}
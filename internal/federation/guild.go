@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -17,7 +18,9 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 
+	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
@@ -98,6 +101,9 @@ type guildJoinResponse struct {
 	OwnerUsername    string  `json:"owner_username"`
 	OwnerDisplayName *string `json:"owner_display_name,omitempty"`
 	OwnerAvatarID    *string `json:"owner_avatar_id,omitempty"`
+	// Invite is the redeemed invite's state after the join, when joining
+	// through an invite.
+	Invite *federatedInvite `json:"invite,omitempty"`
 }
 
 // ============================================================
@@ -201,6 +207,9 @@ func (ss *SyncService) HandleFederatedGuildJoin(w http.ResponseWriter, r *http.R
 	if !ok {
 		return
 	}
+	if !ss.checkJoinOrigin(ctx, w, guildID, req.InstanceDomain) {
+		return
+	}
 
 	// Create remote user stub.
 	ss.ensureRemoteUserStub(ctx, instanceID, federatedUserInfo{
@@ -227,13 +236,9 @@ func (ss *SyncService) HandleFederatedGuildJoin(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Only update counts and peers if a new row was inserted.
+	// Only update peers if a new row was inserted. The member count trigger
+	// has already counted the join.
 	if tag.RowsAffected() > 0 {
-		if _, err := ss.fed.pool.Exec(ctx,
-			`UPDATE guilds SET member_count = member_count + 1 WHERE id = $1`, guildID); err != nil {
-			ss.logger.Warn("failed to increment member count", slog.String("error", err.Error()))
-		}
-
 		ss.addInstanceToGuildChannelPeers(ctx, guildID, instanceID)
 
 		ss.bus.PublishGuildEvent(ctx, events.SubjectGuildMemberAdd, "GUILD_MEMBER_ADD", guildID, map[string]interface{}{
@@ -298,11 +303,6 @@ func (ss *SyncService) HandleFederatedGuildLeave(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if _, err := ss.fed.pool.Exec(ctx,
-		`UPDATE guilds SET member_count = GREATEST(member_count - 1, 0) WHERE id = $1`, guildID); err != nil {
-		ss.logger.Warn("failed to decrement member count", slog.String("error", err.Error()))
-	}
-
 	// Check if any members from this instance remain.
 	// Only remove channel peers if no members from this instance remain.
 	// On query error, skip removal to avoid breaking federation for remaining members.
@@ -353,31 +353,23 @@ func (ss *SyncService) HandleFederatedGuildInviteAccept(w http.ResponseWriter, r
 
 	ctx := r.Context()
 
-	// Validate invite.
-	var guildID string
-	var maxUses, uses int
-	var expiresAt *time.Time
-	err := ss.fed.pool.QueryRow(ctx,
-		`SELECT guild_id, max_uses, uses, expires_at FROM invites WHERE code = $1`,
-		req.InviteCode,
-	).Scan(&guildID, &maxUses, &uses, &expiresAt)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			http.Error(w, "Invalid invite code", http.StatusNotFound)
-		} else {
-			http.Error(w, "Internal error", http.StatusInternalServerError)
-		}
+	// Validate invite. This instance is the invite's home, so its expiry and
+	// use count are authoritative; the joining instance relays the result.
+	inv, err := ss.lookupInvite(ctx, req.InviteCode)
+	var invErr *inviteError
+	if errors.As(err, &invErr) {
+		writeInviteError(w, invErr)
 		return
 	}
-
-	if expiresAt != nil && time.Now().After(*expiresAt) {
-		http.Error(w, "Invite has expired", http.StatusGone)
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
 	}
-	if maxUses > 0 && uses >= maxUses {
-		http.Error(w, "Invite has been exhausted", http.StatusGone)
+	if e := inv.usable(time.Now()); e != nil {
+		writeInviteError(w, e)
 		return
 	}
+	guildID := inv.GuildID
 
 	// Check ban (fail closed on query error).
 	var banned bool
@@ -399,6 +391,9 @@ func (ss *SyncService) HandleFederatedGuildInviteAccept(w http.ResponseWriter, r
 	if !ok {
 		return
 	}
+	if !ss.checkJoinOrigin(ctx, w, guildID, req.InstanceDomain) {
+		return
+	}
 
 	// Create user stub.
 	ss.ensureRemoteUserStub(ctx, instanceID, federatedUserInfo{
@@ -407,19 +402,18 @@ func (ss *SyncService) HandleFederatedGuildInviteAccept(w http.ResponseWriter, r
 		InstanceDomain: req.InstanceDomain,
 	})
 
-	// Add to guild_members.
-	tag, err := ss.fed.pool.Exec(ctx,
-		`INSERT INTO guild_members (guild_id, user_id, joined_at)
-		 VALUES ($1, $2, now()) ON CONFLICT DO NOTHING`,
-		guildID, req.UserID)
+	// Add to guild_members, counting the invite use.
+	inv, joined, err := ss.redeemInvite(ctx, req.InviteCode, req.UserID)
+	if errors.As(err, &invErr) {
+		writeInviteError(w, invErr)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
 	}
 
-	if tag.RowsAffected() > 0 {
-		ss.fed.pool.Exec(ctx, `UPDATE invites SET uses = uses + 1 WHERE code = $1`, req.InviteCode)
-		ss.fed.pool.Exec(ctx, `UPDATE guilds SET member_count = member_count + 1 WHERE id = $1`, guildID)
+	if joined {
 		ss.addInstanceToGuildChannelPeers(ctx, guildID, instanceID)
 
 		ss.bus.PublishGuildEvent(ctx, events.SubjectGuildMemberAdd, "GUILD_MEMBER_ADD", guildID, map[string]interface{}{
@@ -432,6 +426,7 @@ func (ss *SyncService) HandleFederatedGuildInviteAccept(w http.ResponseWriter, r
 		http.Error(w, "Internal error", http.StatusInternalServerError)
 		return
 	}
+	resp.Invite = &inv
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -510,6 +505,20 @@ func (ss *SyncService) HandleFederatedGuildMessages(w http.ResponseWriter, r *ht
 		limit = 50
 	}
 
+	messages, err := ss.queryFederatedMessages(ctx, channelID, req.Before, req.After, limit)
+	if err != nil {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": messages})
+}
+
+// queryFederatedMessages loads a page of a channel's messages in the shape
+// served to federated peers, with authors and attachments. Newest first
+// unless after is set.
+func (ss *SyncService) queryFederatedMessages(ctx context.Context, channelID, before, after string, limit int) ([]map[string]interface{}, error) {
 	query := `SELECT m.id, m.channel_id, m.author_id, m.content, m.created_at,
 	                 u.username, u.display_name, u.avatar_id, u.instance_id, COALESCE(i.domain, '')
 	          FROM messages m
@@ -519,18 +528,18 @@ func (ss *SyncService) HandleFederatedGuildMessages(w http.ResponseWriter, r *ht
 	args := []interface{}{channelID}
 	argN := 2
 
-	if req.Before != "" {
+	if before != "" {
 		query += fmt.Sprintf(` AND m.id < $%d`, argN)
-		args = append(args, req.Before)
+		args = append(args, before)
 		argN++
 	}
-	if req.After != "" {
+	if after != "" {
 		query += fmt.Sprintf(` AND m.id > $%d`, argN)
-		args = append(args, req.After)
+		args = append(args, after)
 		argN++
 	}
 
-	if req.After != "" {
+	if after != "" {
 		query += ` ORDER BY m.id ASC`
 	} else {
 		query += ` ORDER BY m.id DESC`
@@ -540,8 +549,7 @@ func (ss *SyncService) HandleFederatedGuildMessages(w http.ResponseWriter, r *ht
 
 	rows, err := ss.fed.pool.Query(ctx, query, args...)
 	if err != nil {
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -626,8 +634,7 @@ func (ss *SyncService) HandleFederatedGuildMessages(w http.ResponseWriter, r *ht
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"data": messages})
+	return messages, nil
 }
 
 // HandleFederatedGuildPostMessage creates a message in a guild channel from a federated user.
@@ -666,9 +673,14 @@ func (ss *SyncService) HandleFederatedGuildPostMessage(w http.ResponseWriter, r
 	var channelGuildID *string
 	var locked bool
 	var channelType *string
+	var invitePolicy string
+	var inviteExempt bool
 	if err := ss.fed.pool.QueryRow(ctx,
-		`SELECT guild_id, locked, channel_type FROM channels WHERE id = $1`, channelID,
-	).Scan(&channelGuildID, &locked, &channelType); err != nil || channelGuildID == nil || *channelGuildID != guildID {
+		`SELECT c.guild_id, c.locked, c.channel_type,
+		        COALESCE(g.invite_link_policy, 'allow'), c.invite_links_exempt
+		 FROM channels c LEFT JOIN guilds g ON g.id = c.guild_id
+		 WHERE c.id = $1`, channelID,
+	).Scan(&channelGuildID, &locked, &channelType, &invitePolicy, &inviteExempt); err != nil || channelGuildID == nil || *channelGuildID != guildID {
 		http.Error(w, "Channel not found in guild", http.StatusNotFound)
 		return
 	}
@@ -696,6 +708,23 @@ func (ss *SyncService) HandleFederatedGuildPostMessage(w http.ResponseWriter, r
 		return
 	}
 
+	// Federated members are held to the guild's invite link policy like
+	// local ones.
+	var canManageGuild bool
+	if perms, err := apiutil.MemberChannelPermissions(ctx, ss.fed.pool, guildID, channelID, req.UserID); err == nil {
+		canManageGuild = perms&permissions.ManageGuild != 0
+	}
+	content, refuse := apiutil.ApplyInvitePolicy(invitePolicy, inviteExempt, canManageGuild, req.Content)
+	if refuse {
+		http.Error(w, "Invite links require MANAGE_GUILD in this guild", http.StatusForbidden)
+		return
+	}
+	if content == "" {
+		http.Error(w, "Message is empty once invite links are removed", http.StatusBadRequest)
+		return
+	}
+	req.Content = content
+
 	msgID := models.NewULID().String()
 	now := time.Now()
 
@@ -714,10 +743,21 @@ func (ss *SyncService) HandleFederatedGuildPostMessage(w http.ResponseWriter, r
 		replyToIDs = req.ReplyToIDs
 	}
 
+	// The nonce travels with the message so the sender's instance can match
+	// the MESSAGE_CREATE to its optimistic echo.
+	var nonce *string
+	if req.Nonce != "" {
+		nonce = &req.Nonce
+	}
 	_, err := ss.fed.pool.Exec(ctx,
-		`INSERT INTO messages (id, channel_id, author_id, content, reply_to_ids, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6)`,
-		msgID, channelID, req.UserID, req.Content, replyToIDs, now)
+		`INSERT INTO messages (id, channel_id, author_id, content, nonce, reply_to_ids, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		msgID, channelID, req.UserID, req.Content, nonce, replyToIDs, now)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		http.Error(w, "Nonce already used in this channel", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		ss.logger.Error("failed to create federated guild message", slog.String("error", err.Error()))
 		http.Error(w, "Internal error", http.StatusInternalServerError)
@@ -759,6 +799,9 @@ func (ss *SyncService) HandleFederatedGuildPostMessage(w http.ResponseWriter, r
 		"author_id": req.UserID, "content": req.Content, "created_at": now,
 		"reply_to_ids": replyToIDs, "author": authorObj,
 	}
+	if nonce != nil {
+		msg["nonce"] = *nonce
+	}
 	ss.bus.PublishChannelEvent(ctx, events.SubjectMessageCreate, "MESSAGE_CREATE", channelID, msg)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1116,6 +1159,10 @@ func (ss *SyncService) HandleProxyFederatedTyping(w http.ResponseWriter, r *http
 		return
 	}
 
+	if !ss.requireMirroredPermission(ctx, w, instanceDomain, guildID, channelID, userID, permissions.SendMessages) {
+		return
+	}
+
 	remoteURL := fmt.Sprintf("https://%s/federation/v1/guilds/%s/channels/%s/typing",
 		instanceDomain, guildID, channelID)
 	_, statusCode, err := ss.signAndPost(ctx, remoteURL, federatedGuildTypingRequest{UserID: userID})
@@ -1149,6 +1196,25 @@ func (ss *SyncService) validateSenderDomain(ctx context.Context, w http.Response
 	return senderID, true
 }
 
+// checkJoinOrigin checks a user from domain against the guild's join origin
+// policy. It writes the refusal, with its error code for the joining
+// instance to pass on, and returns false if the user may not join. Lookup
+// errors fail closed.
+func (ss *SyncService) checkJoinOrigin(ctx context.Context, w http.ResponseWriter, guildID, domain string) bool {
+	ref, err := apiutil.CheckJoinOrigin(ctx, ss.fed.pool, guildID, domain)
+	if err != nil {
+		ss.logger.Error("failed to check join origin policy",
+			slog.String("guild_id", guildID), slog.String("error", err.Error()))
+		http.Error(w, `{"error":{"code":"internal","message":"Internal error"}}`, http.StatusInternalServerError)
+		return false
+	}
+	if ref != nil {
+		apiutil.WriteJoinOriginRefusal(w, ref)
+		return false
+	}
+	return true
+}
+
 // validateSenderUser verifies that the claimed user_id belongs to the signed
 // sender's instance, preventing cross-instance user_id spoofing.
 func (ss *SyncService) validateSenderUser(ctx context.Context, w http.ResponseWriter, senderID, userID string) bool {
@@ -1303,6 +1369,14 @@ func (ss *SyncService) updateFederatedGuildFromEvent(ctx context.Context, sender
 		return
 	}
 
+	switch eventType {
+	case "GUILD_UPDATE", "CHANNEL_CREATE", "CHANNEL_UPDATE", "CHANNEL_DELETE",
+		"GUILD_MEMBER_UPDATE", "GUILD_ROLE_CREATE", "GUILD_ROLE_UPDATE", "GUILD_ROLE_DELETE":
+		// Default permissions, channel overrides, or role assignments may
+		// have changed; refetch mirrored permissions on next use.
+		ss.invalidatePermissionSnapshots(ctx, guildID)
+	}
+
 	switch eventType {
 	case "GUILD_UPDATE":
 		var update struct {
@@ -1571,6 +1645,12 @@ func (ss *SyncService) HandleProxyJoinFederatedGuild(w http.ResponseWriter, r *h
 		return
 	}
 
+	ss.proxyJoinGuild(w, r, userID, req.InstanceDomain, req.GuildID, req.InviteCode)
+}
+
+// proxyJoinGuild joins userID to a guild on the instance at domain, by guild
+// ID or by invite code, and mirrors the guild locally.
+func (ss *SyncService) proxyJoinGuild(w http.ResponseWriter, r *http.Request, userID, domain, guildID, inviteCode string) {
 	ctx := r.Context()
 
 	// Look up local user info.
@@ -1586,14 +1666,14 @@ func (ss *SyncService) HandleProxyJoinFederatedGuild(w http.ResponseWriter, r *h
 	var remoteURL string
 	var payload interface{}
 
-	if req.InviteCode != "" {
-		remoteURL = fmt.Sprintf("https://%s/federation/v1/guilds/invite-accept", req.InstanceDomain)
+	if inviteCode != "" {
+		remoteURL = fmt.Sprintf("https://%s/federation/v1/guilds/invite-accept", domain)
 		payload = federatedGuildInviteRequest{
-			InviteCode: req.InviteCode, UserID: userID, Username: username,
+			InviteCode: inviteCode, UserID: userID, Username: username,
 			DisplayName: displayName, AvatarID: avatarID, InstanceDomain: ss.fed.domain,
 		}
 	} else {
-		remoteURL = fmt.Sprintf("https://%s/federation/v1/guilds/%s/join", req.InstanceDomain, req.GuildID)
+		remoteURL = fmt.Sprintf("https://%s/federation/v1/guilds/%s/join", domain, guildID)
 		payload = federatedGuildJoinRequest{
 			UserID: userID, Username: username,
 			DisplayName: displayName, AvatarID: avatarID, InstanceDomain: ss.fed.domain,
@@ -1627,12 +1707,12 @@ func (ss *SyncService) HandleProxyJoinFederatedGuild(w http.ResponseWriter, r *h
 	// Ensure remote instance is registered locally.
 	var remoteInstanceID string
 	if err := ss.fed.pool.QueryRow(ctx,
-		`SELECT id FROM instances WHERE domain = $1`, req.InstanceDomain,
+		`SELECT id FROM instances WHERE domain = $1`, domain,
 	).Scan(&remoteInstanceID); err != nil {
-		disc, discErr := DiscoverInstance(ctx, req.InstanceDomain)
+		disc, discErr := DiscoverInstance(ctx, domain)
 		if discErr != nil {
 			ss.logger.Error("failed to discover remote instance for guild join",
-				slog.String("domain", req.InstanceDomain), slog.String("error", discErr.Error()))
+				slog.String("domain", domain), slog.String("error", discErr.Error()))
 			http.Error(w, "Failed to register remote instance", http.StatusBadGateway)
 			return
 		}
@@ -1746,6 +1826,12 @@ func (ss *SyncService) HandleProxyJoinFederatedGuild(w http.ResponseWriter, r *h
 	// Register this instance as a peer for the guild's channels so events route here.
 	ss.addInstanceToGuildChannelPeers(ctx, joinResp.GuildID, ss.fed.instanceID)
 
+	// Mirror the user's remote roles and channel permissions.
+	if _, err := ss.refreshPermissionSnapshot(ctx, domain, joinResp.GuildID, userID); err != nil {
+		ss.logger.Warn("failed to fetch federated permission snapshot after join",
+			slog.String("guild_id", joinResp.GuildID), slog.String("error", err.Error()))
+	}
+
 	// Wrap in API response envelope for frontend compatibility.
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -1797,6 +1883,8 @@ func (ss *SyncService) HandleProxyLeaveFederatedGuild(w http.ResponseWriter, r *
 	// Remove the local user from guild_members.
 	ss.fed.pool.Exec(ctx,
 		`DELETE FROM guild_members WHERE guild_id = $1 AND user_id = $2`, guildID, userID)
+	ss.fed.pool.Exec(ctx,
+		`DELETE FROM federated_permission_snapshots WHERE guild_id = $1 AND user_id = $2`, guildID, userID)
 
 	// If no local members remain in this federated guild, clean up the local guild data.
 	// Local users have NULL instance_id, so use IS NULL instead of matching instance_id.
@@ -1852,6 +1940,10 @@ func (ss *SyncService) HandleProxyGetFederatedGuildMessages(w http.ResponseWrite
 		return
 	}
 
+	if !ss.requireMirroredPermission(ctx, w, instanceDomain, guildID, channelID, userID, permissions.ViewChannel|permissions.ReadHistory) {
+		return
+	}
+
 	limit := 50
 	if l := r.URL.Query().Get("limit"); l != "" {
 		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 100 {
@@ -1920,6 +2012,10 @@ func (ss *SyncService) HandleProxyPostFederatedGuildMessage(w http.ResponseWrite
 		return
 	}
 
+	if !ss.requireMirroredPermission(ctx, w, instanceDomain, guildID, channelID, userID, permissions.SendMessages) {
+		return
+	}
+
 	payload := federatedGuildPostMessageRequest{
 		UserID: userID, Content: localReq.Content, Nonce: localReq.Nonce,
 		ReplyToIDs: localReq.ReplyToIDs,
@@ -2324,6 +2420,10 @@ func (ss *SyncService) HandleProxyAddFederatedReaction(w http.ResponseWriter, r
 		return
 	}
 
+	if !ss.requireMirroredPermission(ctx, w, instanceDomain, guildID, channelID, userID, permissions.AddReactions) {
+		return
+	}
+
 	remoteURL := fmt.Sprintf("https://%s/federation/v1/guilds/%s/channels/%s/messages/%s/reactions",
 		instanceDomain, guildID, channelID, messageID)
 	respBody, statusCode, err := ss.signAndPost(ctx, remoteURL, federatedGuildReactionRequest{
@@ -2399,15 +2499,26 @@ func (ss *SyncService) HandleProxyRemoveFederatedReaction(w http.ResponseWriter,
 // and checks whether the required permission bits are set. Used by federation
 // handlers to enforce ViewChannel/ReadHistory before serving content.
 func (ss *SyncService) hasChannelPermission(ctx context.Context, guildID, channelID, userID string, perm uint64) bool {
+	computed, ok := ss.computeChannelPermissions(ctx, guildID, channelID, userID)
+	if !ok {
+		return false
+	}
+	return computed&perm == perm
+}
+
+// computeChannelPermissions returns a user's effective permission bits for a
+// channel. The guild owner and administrators get every bit; a user without
+// ViewChannel gets none. ok is false if the guild does not exist.
+func (ss *SyncService) computeChannelPermissions(ctx context.Context, guildID, channelID, userID string) (uint64, bool) {
 	// Guild owner has all permissions.
 	var ownerID string
 	if err := ss.fed.pool.QueryRow(ctx,
 		`SELECT owner_id FROM guilds WHERE id = $1`, guildID,
 	).Scan(&ownerID); err != nil {
-		return false
+		return 0, false
 	}
 	if userID == ownerID {
-		return true
+		return permissions.AllPermissions | permissions.Administrator, true
 	}
 
 	// Start with guild default permissions.
@@ -2438,7 +2549,7 @@ func (ss *SyncService) hasChannelPermission(ctx context.Context, guildID, channe
 
 	// Administrator bypasses everything.
 	if computed&permissions.Administrator != 0 {
-		return true
+		return permissions.AllPermissions | permissions.Administrator, true
 	}
 
 	// Apply channel-level permission overrides.
@@ -2488,10 +2599,10 @@ func (ss *SyncService) hasChannelPermission(ctx context.Context, guildID, channe
 
 	// No ViewChannel means no permissions at all.
 	if computed&permissions.ViewChannel == 0 {
-		return false
+		return 0, true
 	}
 
-	return computed&perm == perm
+	return computed, true
 }
 
 // signAndPost signs a payload with the federation service and POSTs to a URL.
@@ -0,0 +1,321 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// permissionSnapshotTTL is how long a mirrored permission snapshot is trusted
+// before a proxy action fetches a fresh one from the guild's host.
+const permissionSnapshotTTL = 5 * time.Minute
+
+type federatedGuildPermissionsRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// guildPermissionSnapshot is a user's remote role membership and effective
+// per-channel permissions in a federated guild. Permission bitmasks are
+// decimal strings, as in the local @me/permissions endpoint, because the
+// Administrator bit does not survive a JSON number in JavaScript clients.
+//
+// Channels only lists channels the user can see or that are public; a nil
+// map means the host does not support permission snapshots.
+type guildPermissionSnapshot struct {
+	GuildID   string            `json:"guild_id"`
+	RoleIDs   []string          `json:"role_ids"`
+	Channels  map[string]string `json:"channels"`
+	FetchedAt time.Time         `json:"fetched_at"`
+}
+
+// allows reports whether the snapshot grants every bit of perm in channelID.
+// Hosts without snapshot support allow everything, leaving enforcement to
+// the host; a channel missing from a supported snapshot allows nothing.
+func (snap *guildPermissionSnapshot) allows(channelID string, perm uint64) bool {
+	if snap.Channels == nil {
+		return true
+	}
+	bits, err := strconv.ParseUint(snap.Channels[channelID], 10, 64)
+	if err != nil {
+		return false
+	}
+	return bits&perm == perm
+}
+
+// HandleFederatedGuildPermissions returns a federated user's role IDs and
+// effective channel permissions so their home instance can mirror them.
+// POST /federation/v1/guilds/{guildID}/permissions
+func (ss *SyncService) HandleFederatedGuildPermissions(w http.ResponseWriter, r *http.Request) {
+	signed, senderID, ok := ss.verifyFederationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	guildID := chi.URLParam(r, "guildID")
+	if guildID == "" {
+		http.Error(w, "Missing guild ID", http.StatusBadRequest)
+		return
+	}
+
+	var req federatedGuildPermissionsRequest
+	if err := json.Unmarshal(signed.Payload, &req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" {
+		http.Error(w, "Missing user_id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if !ss.validateSenderUser(ctx, w, senderID, req.UserID) {
+		return
+	}
+
+	var isMember bool
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
+		guildID, req.UserID,
+	).Scan(&isMember); err != nil || !isMember {
+		http.Error(w, "Not a guild member", http.StatusForbidden)
+		return
+	}
+
+	snap := guildPermissionSnapshot{
+		GuildID:   guildID,
+		RoleIDs:   []string{},
+		Channels:  map[string]string{},
+		FetchedAt: time.Now().UTC(),
+	}
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT COALESCE(array_agg(role_id ORDER BY role_id), '{}')
+		 FROM member_roles WHERE guild_id = $1 AND user_id = $2`,
+		guildID, req.UserID,
+	).Scan(&snap.RoleIDs); err != nil {
+		ss.logger.Error("failed to query member roles for permission snapshot", slog.String("error", err.Error()))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	type channelRow struct {
+		id      string
+		private bool
+	}
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT id, COALESCE(channel_type = 'private', false) FROM channels WHERE guild_id = $1`, guildID)
+	if err != nil {
+		ss.logger.Error("failed to query channels for permission snapshot", slog.String("error", err.Error()))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	channels, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (channelRow, error) {
+		var c channelRow
+		err := row.Scan(&c.id, &c.private)
+		return c, err
+	})
+	if err != nil {
+		ss.logger.Error("failed to read channels for permission snapshot", slog.String("error", err.Error()))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, ch := range channels {
+		perms, _ := ss.computeChannelPermissions(ctx, guildID, ch.id, req.UserID)
+		// Don't reveal private channels the user cannot see.
+		if ch.private && perms&permissions.ViewChannel == 0 {
+			continue
+		}
+		snap.Channels[ch.id] = strconv.FormatUint(perms, 10)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": snap})
+}
+
+// fetchPermissionSnapshot requests a fresh permission snapshot from the
+// guild's host. A host that predates the endpoint yields a snapshot with a
+// nil channel map.
+func (ss *SyncService) fetchPermissionSnapshot(ctx context.Context, domain, guildID, userID string) (*guildPermissionSnapshot, error) {
+	remoteURL := fmt.Sprintf("https://%s/federation/v1/guilds/%s/permissions", domain, guildID)
+	respBody, statusCode, err := ss.signAndPost(ctx, remoteURL, federatedGuildPermissionsRequest{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	switch statusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return &guildPermissionSnapshot{GuildID: guildID, RoleIDs: []string{}, FetchedAt: time.Now().UTC()}, nil
+	default:
+		return nil, fmt.Errorf("remote returned status %d", statusCode)
+	}
+
+	var resp struct {
+		Data guildPermissionSnapshot `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("decoding permission snapshot: %w", err)
+	}
+	snap := resp.Data
+	snap.GuildID = guildID
+	snap.FetchedAt = time.Now().UTC()
+	if snap.RoleIDs == nil {
+		snap.RoleIDs = []string{}
+	}
+	if snap.Channels == nil {
+		snap.Channels = map[string]string{}
+	}
+	return &snap, nil
+}
+
+// refreshPermissionSnapshot fetches and stores a user's permission snapshot
+// for a federated guild, and mirrors their remote roles into member_roles so
+// the client shows the same roles as the host.
+func (ss *SyncService) refreshPermissionSnapshot(ctx context.Context, domain, guildID, userID string) (*guildPermissionSnapshot, error) {
+	snap, err := ss.fetchPermissionSnapshot(ctx, domain, guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var channelsJSON []byte
+	if snap.Channels != nil {
+		if channelsJSON, err = json.Marshal(snap.Channels); err != nil {
+			return nil, fmt.Errorf("encoding channel permissions: %w", err)
+		}
+	}
+	if _, err := ss.fed.pool.Exec(ctx,
+		`INSERT INTO federated_permission_snapshots (guild_id, user_id, role_ids, channel_permissions, fetched_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (guild_id, user_id) DO UPDATE SET
+		   role_ids = EXCLUDED.role_ids,
+		   channel_permissions = EXCLUDED.channel_permissions,
+		   fetched_at = EXCLUDED.fetched_at`,
+		guildID, userID, snap.RoleIDs, channelsJSON, snap.FetchedAt); err != nil {
+		return nil, fmt.Errorf("storing permission snapshot: %w", err)
+	}
+
+	if snap.Channels != nil {
+		ss.fed.pool.Exec(ctx,
+			`DELETE FROM member_roles WHERE guild_id = $1 AND user_id = $2 AND role_id <> ALL($3)`,
+			guildID, userID, snap.RoleIDs)
+		ss.fed.pool.Exec(ctx,
+			`INSERT INTO member_roles (guild_id, user_id, role_id)
+			 SELECT $1, $2, id FROM roles WHERE guild_id = $1 AND id = ANY($3)
+			 ON CONFLICT DO NOTHING`,
+			guildID, userID, snap.RoleIDs)
+	}
+	return snap, nil
+}
+
+// permissionSnapshot returns the user's mirrored permissions for a federated
+// guild, refreshing them from the host when missing or older than
+// permissionSnapshotTTL. If the host cannot be reached the stale snapshot is
+// returned; nil means no snapshot is available at all.
+func (ss *SyncService) permissionSnapshot(ctx context.Context, domain, guildID, userID string) *guildPermissionSnapshot {
+	var snap guildPermissionSnapshot
+	var channelsJSON []byte
+	err := ss.fed.pool.QueryRow(ctx,
+		`SELECT role_ids, channel_permissions, fetched_at
+		 FROM federated_permission_snapshots WHERE guild_id = $1 AND user_id = $2`,
+		guildID, userID,
+	).Scan(&snap.RoleIDs, &channelsJSON, &snap.FetchedAt)
+	cached := err == nil
+	if cached {
+		snap.GuildID = guildID
+		if channelsJSON != nil && json.Unmarshal(channelsJSON, &snap.Channels) != nil {
+			cached = false
+		}
+	}
+	if cached && time.Since(snap.FetchedAt) < permissionSnapshotTTL {
+		return &snap
+	}
+
+	fresh, err := ss.refreshPermissionSnapshot(ctx, domain, guildID, userID)
+	if err != nil {
+		ss.logger.Warn("failed to refresh federated permission snapshot",
+			slog.String("guild_id", guildID), slog.String("user_id", userID),
+			slog.String("error", err.Error()))
+		if cached {
+			return &snap
+		}
+		return nil
+	}
+	return fresh
+}
+
+// requireMirroredPermission rejects a proxy action early when the user's
+// mirrored permissions show the host would refuse it. Without a snapshot the
+// action is allowed through and left to the host. Returns false after
+// writing a 403.
+func (ss *SyncService) requireMirroredPermission(ctx context.Context, w http.ResponseWriter, domain, guildID, channelID, userID string, perm uint64) bool {
+	snap := ss.permissionSnapshot(ctx, domain, guildID, userID)
+	if snap == nil || snap.allows(channelID, perm) {
+		return true
+	}
+	http.Error(w, "Missing permissions in remote channel", http.StatusForbidden)
+	return false
+}
+
+// invalidatePermissionSnapshots marks every mirrored snapshot for a guild as
+// stale so the next proxy action refetches it. Called when the host reports
+// a change that can affect effective permissions.
+func (ss *SyncService) invalidatePermissionSnapshots(ctx context.Context, guildID string) {
+	if _, err := ss.fed.pool.Exec(ctx,
+		`UPDATE federated_permission_snapshots SET fetched_at = 'epoch' WHERE guild_id = $1`,
+		guildID); err != nil {
+		ss.logger.Warn("failed to invalidate federated permission snapshots",
+			slog.String("guild_id", guildID), slog.String("error", err.Error()))
+	}
+}
+
+// HandleProxyGetFederatedGuildPermissions returns the caller's mirrored
+// roles and channel permissions for a federated guild, so the client can
+// hide channels it cannot access.
+// GET /api/v1/federation/guilds/{guildID}/permissions
+func (ss *SyncService) HandleProxyGetFederatedGuildPermissions(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	guildID := chi.URLParam(r, "guildID")
+	if guildID == "" {
+		http.Error(w, "Missing guild ID", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	var instanceDomain string
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT i.domain FROM guilds g
+		 JOIN instances i ON i.id = g.instance_id
+		 JOIN guild_members gm ON gm.guild_id = g.id AND gm.user_id = $2
+		 WHERE g.id = $1`,
+		guildID, userID,
+	).Scan(&instanceDomain); err != nil {
+		http.Error(w, "Guild not found", http.StatusNotFound)
+		return
+	}
+
+	snap := ss.permissionSnapshot(ctx, instanceDomain, guildID, userID)
+	if snap == nil {
+		http.Error(w, "Failed to contact remote instance", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": snap})
+}
@@ -2,7 +2,11 @@ package federation
 
 import (
 	"encoding/json"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/permissions"
 )
 
 func TestFederatedGuildJoinRequest_JSON(t *testing.T) {
@@ -359,3 +363,98 @@ func TestGuildJoinResponse_NilOptionalFields(t *testing.T) {
 		t.Errorf("Description should be nil, got %v", decoded.Description)
 	}
 }
+
+func TestGuildPermissionSnapshot_Allows(t *testing.T) {
+	view := permissions.ViewChannel | permissions.ReadHistory
+	snap := &guildPermissionSnapshot{Channels: map[string]string{
+		"open":     strconv.FormatUint(view|permissions.SendMessages, 10),
+		"readonly": strconv.FormatUint(view, 10),
+		"admin":    strconv.FormatUint(permissions.AllPermissions|permissions.Administrator, 10),
+	}}
+
+	tests := []struct {
+		channel string
+		perm    uint64
+		want    bool
+	}{
+		{"open", permissions.SendMessages, true},
+		{"readonly", view, true},
+		{"readonly", permissions.SendMessages, false},
+		{"admin", permissions.AddReactions | permissions.SendMessages, true},
+		{"hidden", permissions.ViewChannel, false},
+	}
+	for _, tt := range tests {
+		if got := snap.allows(tt.channel, tt.perm); got != tt.want {
+			t.Errorf("allows(%q, %d) = %v, want %v", tt.channel, tt.perm, got, tt.want)
+		}
+	}
+
+	unsupported := &guildPermissionSnapshot{}
+	if !unsupported.allows("any", permissions.SendMessages) {
+		t.Error("snapshot without channel map denied an action, want it left to the host")
+	}
+}
+
+func TestFederatedInvite_Usable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	two, zero := 2, 0
+
+	tests := []struct {
+		name string
+		inv  federatedInvite
+		want *inviteError
+	}{
+		{"unlimited", federatedInvite{Uses: 50}, nil},
+		{"not expired", federatedInvite{ExpiresAt: &future}, nil},
+		{"expired", federatedInvite{ExpiresAt: &past}, errInviteExpired},
+		{"uses left", federatedInvite{MaxUses: &two, Uses: 1}, nil},
+		{"used up", federatedInvite{MaxUses: &two, Uses: 2}, errInviteExhausted},
+		{"zero max uses is unlimited", federatedInvite{MaxUses: &zero, Uses: 9}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.inv.usable(now); got != tt.want {
+				t.Errorf("usable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanServeDelta(t *testing.T) {
+	tests := []struct {
+		name                  string
+		since, oldest, latest int64
+		want                  bool
+	}{
+		{"new replica", 0, 1, 10, false},
+		{"up to date", 10, 1, 10, true},
+		{"up to date with pruned log", 10, 0, 10, true},
+		{"behind within log", 4, 1, 10, true},
+		{"next entry is oldest", 4, 5, 10, true},
+		{"missed pruned entries", 4, 6, 10, false},
+		{"empty log", 4, 0, 10, false},
+		{"ahead of home", 11, 1, 10, false},
+		{"too far behind", 1, 1, 2 + replicaDeltaLimit, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canServeDelta(tt.since, tt.oldest, tt.latest); got != tt.want {
+				t.Errorf("canServeDelta(%d, %d, %d) = %v, want %v", tt.since, tt.oldest, tt.latest, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicaCanServe(t *testing.T) {
+	if !replicaCanServe(3, true, 50) {
+		t.Error("complete channel with few messages not served")
+	}
+	if !replicaCanServe(50, false, 50) {
+		t.Error("full page not served")
+	}
+	if replicaCanServe(49, false, 50) {
+		t.Error("short page of incomplete channel served, want proxied")
+	}
+}
@@ -1,10 +1,13 @@
 package federation
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	neturl "net/url"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -23,6 +26,122 @@ type inviteResolveResponse struct {
 	IconID      *string `json:"icon_id,omitempty"`
 	Description *string `json:"description,omitempty"`
 	MemberCount int     `json:"member_count"`
+
+	Invite *federatedInvite `json:"invite,omitempty"`
+}
+
+// federatedInvite is an invite as its guild's home instance sees it. Other
+// instances show this state and leave expiry and use counting to the home
+// instance.
+type federatedInvite struct {
+	Code      string     `json:"code"`
+	GuildID   string     `json:"guild_id"`
+	MaxUses   *int       `json:"max_uses,omitempty"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// inviteError is why an invite cannot be redeemed.
+type inviteError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *inviteError) Error() string { return e.message }
+
+var (
+	errInviteNotFound  = &inviteError{http.StatusNotFound, "not_found", "Invite not found"}
+	errInviteExpired   = &inviteError{http.StatusGone, "gone", "Invite has expired"}
+	errInviteExhausted = &inviteError{http.StatusGone, "gone", "Invite has been exhausted"}
+	errInvitesPaused   = &inviteError{http.StatusForbidden, "invites_paused", "Invites to this guild are paused"}
+)
+
+// usable returns why the invite cannot be redeemed at now, or nil.
+func (inv federatedInvite) usable(now time.Time) *inviteError {
+	if inv.ExpiresAt != nil && now.After(*inv.ExpiresAt) {
+		return errInviteExpired
+	}
+	if inv.MaxUses != nil && *inv.MaxUses > 0 && inv.Uses >= *inv.MaxUses {
+		return errInviteExhausted
+	}
+	return nil
+}
+
+// writeInviteError writes an invite error in the federation error format.
+func writeInviteError(w http.ResponseWriter, e *inviteError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"code": e.code, "message": e.message},
+	})
+}
+
+// lookupInvite loads a local invite by code.
+func (ss *SyncService) lookupInvite(ctx context.Context, code string) (federatedInvite, error) {
+	inv := federatedInvite{Code: code}
+	err := ss.fed.pool.QueryRow(ctx,
+		`SELECT guild_id, max_uses, uses, expires_at FROM invites WHERE code = $1`,
+		code,
+	).Scan(&inv.GuildID, &inv.MaxUses, &inv.Uses, &inv.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return inv, errInviteNotFound
+	}
+	return inv, err
+}
+
+// redeemInvite adds userID to the invite's guild and counts the use, in one
+// transaction so concurrent redemptions cannot overrun max_uses. A user who
+// is already a member does not use up the invite; joined reports whether
+// they were added.
+func (ss *SyncService) redeemInvite(ctx context.Context, code, userID string) (inv federatedInvite, joined bool, err error) {
+	tx, err := ss.fed.pool.Begin(ctx)
+	if err != nil {
+		return inv, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	inv = federatedInvite{Code: code}
+	err = tx.QueryRow(ctx,
+		`SELECT guild_id, max_uses, uses, expires_at FROM invites WHERE code = $1 FOR UPDATE`,
+		code,
+	).Scan(&inv.GuildID, &inv.MaxUses, &inv.Uses, &inv.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return inv, false, errInviteNotFound
+	}
+	if err != nil {
+		return inv, false, err
+	}
+	if e := inv.usable(time.Now()); e != nil {
+		return inv, false, e
+	}
+
+	var paused bool
+	if err := tx.QueryRow(ctx,
+		`SELECT COALESCE(invites_paused_until > now(), false) FROM guilds WHERE id = $1`, inv.GuildID,
+	).Scan(&paused); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return inv, false, err
+	}
+	if paused {
+		return inv, false, errInvitesPaused
+	}
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO guild_members (guild_id, user_id, joined_at)
+		 VALUES ($1, $2, now()) ON CONFLICT DO NOTHING`,
+		inv.GuildID, userID)
+	if err != nil {
+		return inv, false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return inv, false, tx.Commit(ctx)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE invites SET uses = uses + 1 WHERE code = $1`, code); err != nil {
+		return inv, false, err
+	}
+	inv.Uses++
+	return inv, true, tx.Commit(ctx)
 }
 
 // inviteAcceptRequest is the signed payload for accepting an invite.
@@ -56,38 +175,25 @@ func (ss *SyncService) HandleInviteResolve(w http.ResponseWriter, r *http.Reques
 
 	ctx := r.Context()
 
-	// Look up the invite.
-	var guildID string
-	var maxUses, uses int
-	var expiresAt *time.Time
-	err := ss.fed.pool.QueryRow(ctx,
-		`SELECT guild_id, max_uses, uses, expires_at FROM invites WHERE code = $1`,
-		code,
-	).Scan(&guildID, &maxUses, &uses, &expiresAt)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			http.Error(w, `{"error":{"code":"not_found","message":"Invite not found"}}`, http.StatusNotFound)
-		} else {
-			ss.logger.Error("failed to look up invite", slog.String("code", code), slog.String("error", err.Error()))
-			http.Error(w, `{"error":{"code":"internal","message":"Internal error"}}`, http.StatusInternalServerError)
-		}
+	inv, err := ss.lookupInvite(ctx, code)
+	var invErr *inviteError
+	if errors.As(err, &invErr) {
+		writeInviteError(w, invErr)
 		return
 	}
-
-	// Check if invite is expired.
-	if expiresAt != nil && time.Now().After(*expiresAt) {
-		http.Error(w, `{"error":{"code":"gone","message":"Invite has expired"}}`, http.StatusGone)
+	if err != nil {
+		ss.logger.Error("failed to look up invite", slog.String("code", code), slog.String("error", err.Error()))
+		http.Error(w, `{"error":{"code":"internal","message":"Internal error"}}`, http.StatusInternalServerError)
 		return
 	}
-
-	// Check if invite is exhausted.
-	if maxUses > 0 && uses >= maxUses {
-		http.Error(w, `{"error":{"code":"gone","message":"Invite has been exhausted"}}`, http.StatusGone)
+	if e := inv.usable(time.Now()); e != nil {
+		writeInviteError(w, e)
 		return
 	}
+	guildID := inv.GuildID
 
 	// Look up guild preview info.
-	var resp inviteResolveResponse
+	resp := inviteResolveResponse{Invite: &inv}
 	err = ss.fed.pool.QueryRow(ctx,
 		`SELECT id, name, icon_id, description, member_count FROM guilds WHERE id = $1`,
 		guildID,
@@ -133,36 +239,19 @@ func (ss *SyncService) HandleInviteAccept(w http.ResponseWriter, r *http.Request
 
 	ctx := r.Context()
 
-	// Look up the invite.
-	var guildID string
-	var maxUses, uses int
-	var expiresAt *time.Time
-	err := ss.fed.pool.QueryRow(ctx,
-		`SELECT guild_id, max_uses, uses, expires_at FROM invites WHERE code = $1`,
-		code,
-	).Scan(&guildID, &maxUses, &uses, &expiresAt)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			http.Error(w, `{"error":{"code":"not_found","message":"Invalid invite code"}}`, http.StatusNotFound)
-		} else {
-			ss.logger.Error("failed to look up invite for accept",
-				slog.String("code", code), slog.String("error", err.Error()))
-			http.Error(w, `{"error":{"code":"internal","message":"Internal error"}}`, http.StatusInternalServerError)
-		}
-		return
-	}
-
-	// Check if invite is expired.
-	if expiresAt != nil && time.Now().After(*expiresAt) {
-		http.Error(w, `{"error":{"code":"gone","message":"Invite has expired"}}`, http.StatusGone)
+	inv, err := ss.lookupInvite(ctx, code)
+	var invErr *inviteError
+	if errors.As(err, &invErr) {
+		writeInviteError(w, invErr)
 		return
 	}
-
-	// Check if invite is exhausted.
-	if maxUses > 0 && uses >= maxUses {
-		http.Error(w, `{"error":{"code":"gone","message":"Invite has been exhausted"}}`, http.StatusGone)
+	if err != nil {
+		ss.logger.Error("failed to look up invite for accept",
+			slog.String("code", code), slog.String("error", err.Error()))
+		http.Error(w, `{"error":{"code":"internal","message":"Internal error"}}`, http.StatusInternalServerError)
 		return
 	}
+	guildID := inv.GuildID
 
 	// Check if the user is banned from this guild (fail closed on query error).
 	var banned bool
@@ -186,6 +275,9 @@ func (ss *SyncService) HandleInviteAccept(w http.ResponseWriter, r *http.Request
 	if !ok {
 		return
 	}
+	if !ss.checkJoinOrigin(ctx, w, guildID, req.InstanceDomain) {
+		return
+	}
 
 	// Create or update the remote user stub.
 	ss.ensureRemoteUserStub(ctx, instanceID, federatedUserInfo{
@@ -196,12 +288,12 @@ func (ss *SyncService) HandleInviteAccept(w http.ResponseWriter, r *http.Request
 		InstanceDomain: req.InstanceDomain,
 	})
 
-	// Add to guild_members (idempotent).
-	tag, err := ss.fed.pool.Exec(ctx,
-		`INSERT INTO guild_members (guild_id, user_id, joined_at)
-		 VALUES ($1, $2, now()) ON CONFLICT DO NOTHING`,
-		guildID, req.UserID,
-	)
+	// Add to guild_members (idempotent), counting the invite use.
+	inv, joined, err := ss.redeemInvite(ctx, code, req.UserID)
+	if errors.As(err, &invErr) {
+		writeInviteError(w, invErr)
+		return
+	}
 	if err != nil {
 		ss.logger.Error("failed to add federated guild member via invite",
 			slog.String("guild_id", guildID), slog.String("user_id", req.UserID),
@@ -210,22 +302,8 @@ func (ss *SyncService) HandleInviteAccept(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Only update counts and register peers if a new row was inserted.
-	if tag.RowsAffected() > 0 {
-		// Increment invite uses.
-		if _, err := ss.fed.pool.Exec(ctx,
-			`UPDATE invites SET uses = uses + 1 WHERE code = $1`, code); err != nil {
-			ss.logger.Warn("failed to increment invite uses",
-				slog.String("code", code), slog.String("error", err.Error()))
-		}
-
-		// Increment guild member count.
-		if _, err := ss.fed.pool.Exec(ctx,
-			`UPDATE guilds SET member_count = member_count + 1 WHERE id = $1`, guildID); err != nil {
-			ss.logger.Warn("failed to increment guild member count",
-				slog.String("guild_id", guildID), slog.String("error", err.Error()))
-		}
-
+	// Only register peers if the user was newly added.
+	if joined {
 		// Register channel peers so federation events flow to the remote instance.
 		ss.addInstanceToGuildChannelPeers(ctx, guildID, instanceID)
 
@@ -245,6 +323,7 @@ func (ss *SyncService) HandleInviteAccept(w http.ResponseWriter, r *http.Request
 		http.Error(w, `{"error":{"code":"internal","message":"Internal error"}}`, http.StatusInternalServerError)
 		return
 	}
+	resp.Invite = &inv
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -278,15 +357,49 @@ func (ss *SyncService) HandleProxyResolveInvite(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	ctx := r.Context()
+	remote, status, errBody := ss.fetchRemoteInvite(r.Context(), req.InstanceDomain, req.Code)
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(errBody)
+		return
+	}
+
+	// Return the preview enriched with the instance domain.
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{
+			"guild_id":        remote.GuildID,
+			"guild_name":      remote.GuildName,
+			"icon_id":         remote.IconID,
+			"description":     remote.Description,
+			"member_count":    remote.MemberCount,
+			"instance_domain": req.InstanceDomain,
+			"invite":          remote.Invite,
+		},
+	})
+}
+
+// proxyError renders an API-style error body for fetchRemoteInvite.
+func proxyError(code, message string) []byte {
+	b, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]string{"code": code, "message": message},
+	})
+	return b
+}
+
+// fetchRemoteInvite resolves an invite code against the home instance at
+// domain, which must be an active federation peer. On failure it returns the
+// status and JSON error body to relay to the client.
+func (ss *SyncService) fetchRemoteInvite(ctx context.Context, domain, code string) (inviteResolveResponse, int, []byte) {
+	var none inviteResolveResponse
 
 	// Discover the remote instance to verify it exists and is reachable.
-	discovery, err := DiscoverInstance(ctx, req.InstanceDomain)
+	discovery, err := DiscoverInstance(ctx, domain)
 	if err != nil {
 		ss.logger.Warn("failed to discover remote instance for invite resolve",
-			slog.String("domain", req.InstanceDomain), slog.String("error", err.Error()))
-		http.Error(w, `{"error":{"code":"bad_gateway","message":"Failed to discover remote instance"}}`, http.StatusBadGateway)
-		return
+			slog.String("domain", domain), slog.String("error", err.Error()))
+		return none, http.StatusBadGateway, proxyError("bad_gateway", "Failed to discover remote instance")
 	}
 
 	// Validate that the discovered domain matches an active federation peer
@@ -301,25 +414,22 @@ func (ss *SyncService) HandleProxyResolveInvite(w http.ResponseWriter, r *http.R
 	).Scan(&peerExists); err != nil {
 		ss.logger.Error("failed to validate discovery domain against peers",
 			slog.String("domain", discovery.Domain), slog.String("error", err.Error()))
-		http.Error(w, `{"error":{"code":"internal","message":"Internal error"}}`, http.StatusInternalServerError)
-		return
+		return none, http.StatusInternalServerError, proxyError("internal", "Internal error")
 	}
 	if !peerExists {
 		ss.logger.Warn("discovery domain does not match any active federation peer",
-			slog.String("requested_domain", req.InstanceDomain),
+			slog.String("requested_domain", domain),
 			slog.String("discovered_domain", discovery.Domain))
-		http.Error(w, `{"error":{"code":"bad_gateway","message":"Remote instance is not a known federation peer"}}`, http.StatusBadGateway)
-		return
+		return none, http.StatusBadGateway, proxyError("bad_gateway", "Remote instance is not a known federation peer")
 	}
 
 	// Fetch the invite preview from the remote instance.
-	remoteURL := fmt.Sprintf("https://%s/federation/v1/invites/%s", discovery.Domain, req.Code)
+	remoteURL := fmt.Sprintf("https://%s/federation/v1/invites/%s", discovery.Domain, neturl.PathEscape(code))
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", remoteURL, nil)
 	if err != nil {
 		ss.logger.Error("failed to create invite resolve request",
 			slog.String("url", remoteURL), slog.String("error", err.Error()))
-		http.Error(w, `{"error":{"code":"internal","message":"Internal error"}}`, http.StatusInternalServerError)
-		return
+		return none, http.StatusInternalServerError, proxyError("internal", "Internal error")
 	}
 	httpReq.Header.Set("Accept", "application/json")
 	httpReq.Header.Set("User-Agent", "AmityVox/1.0 (+federation)")
@@ -328,46 +438,66 @@ func (ss *SyncService) HandleProxyResolveInvite(w http.ResponseWriter, r *http.R
 	if err != nil {
 		ss.logger.Warn("failed to contact remote instance for invite resolve",
 			slog.String("url", remoteURL), slog.String("error", err.Error()))
-		http.Error(w, `{"error":{"code":"bad_gateway","message":"Failed to contact remote instance"}}`, http.StatusBadGateway)
-		return
+		return none, http.StatusBadGateway, proxyError("bad_gateway", "Failed to contact remote instance")
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		// Forward the error response from the remote instance.
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(resp.StatusCode)
 		var buf [4096]byte
 		n, _ := resp.Body.Read(buf[:])
 		if n > 0 {
-			w.Write(buf[:n])
-		} else {
-			fmt.Fprintf(w, `{"error":{"code":"remote_error","message":"Remote instance returned status %d"}}`, resp.StatusCode)
+			return none, resp.StatusCode, buf[:n]
 		}
-		return
+		return none, resp.StatusCode, proxyError("remote_error",
+			fmt.Sprintf("Remote instance returned status %d", resp.StatusCode))
 	}
 
-	// Parse the remote response and enrich with instance_domain.
 	var remoteResp struct {
 		Data inviteResolveResponse `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&remoteResp); err != nil {
 		ss.logger.Warn("failed to decode invite resolve response",
-			slog.String("domain", req.InstanceDomain), slog.String("error", err.Error()))
-		http.Error(w, `{"error":{"code":"bad_gateway","message":"Invalid response from remote instance"}}`, http.StatusBadGateway)
+			slog.String("domain", domain), slog.String("error", err.Error()))
+		return none, http.StatusBadGateway, proxyError("bad_gateway", "Invalid response from remote instance")
+	}
+	return remoteResp.Data, http.StatusOK, nil
+}
+
+// ProxyResolveRemoteInvite writes the guild preview for an invite hosted on
+// another instance, in the same shape as a local invite lookup. The invite's
+// uses and expiry come from its home instance.
+func (ss *SyncService) ProxyResolveRemoteInvite(w http.ResponseWriter, r *http.Request, domain, code string) {
+	remote, status, errBody := ss.fetchRemoteInvite(r.Context(), domain, code)
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(errBody)
 		return
 	}
 
-	// Return the preview enriched with the instance domain.
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"data": map[string]interface{}{
-			"guild_id":        remoteResp.Data.GuildID,
-			"guild_name":      remoteResp.Data.GuildName,
-			"icon_id":         remoteResp.Data.IconID,
-			"description":     remoteResp.Data.Description,
-			"member_count":    remoteResp.Data.MemberCount,
-			"instance_domain": req.InstanceDomain,
-		},
+		"federated":       true,
+		"instance_domain": domain,
+		"invite_code":     code,
+		"invite":          remote.Invite,
+		"guild_id":        remote.GuildID,
+		"guild_name":      remote.GuildName,
+		"icon_id":         remote.IconID,
+		"description":     remote.Description,
+		"member_count":    remote.MemberCount,
 	})
 }
+
+// ProxyAcceptRemoteInvite joins userID to the guild behind an invite hosted
+// on another instance. The home instance validates and counts the use in
+// HandleFederatedGuildInviteAccept; the guild is then mirrored locally as
+// for any federated join.
+func (ss *SyncService) ProxyAcceptRemoteInvite(w http.ResponseWriter, r *http.Request, userID, domain, code string) {
+	if err := ValidateFederationDomain(domain); err != nil {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+	ss.proxyJoinGuild(w, r, userID, domain, "", code)
+}
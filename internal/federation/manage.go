@@ -12,7 +12,9 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 
+	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/locale"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/permissions"
 )
@@ -69,6 +71,9 @@ type manageResponse struct {
 	OK    bool            `json:"ok"`
 	Data  json.RawMessage `json:"data,omitempty"`
 	Error string          `json:"error,omitempty"`
+	// Code is a machine-readable error code for errors the user should see
+	// as is, e.g. a join origin refusal. Older peers leave it empty.
+	Code string `json:"code,omitempty"`
 }
 
 // HandleManage processes remote guild management requests.
@@ -236,6 +241,14 @@ func writeManageError(w http.ResponseWriter, status int, msg string) {
 	json.NewEncoder(w).Encode(manageResponse{OK: false, Error: msg})
 }
 
+// writeManageCodeError is writeManageError with an error code the proxying
+// instance passes on to the user.
+func writeManageCodeError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(manageResponse{OK: false, Error: msg, Code: code})
+}
+
 // --- Action implementations ---
 
 func (ss *SyncService) manageGuildUpdate(ctx context.Context, w http.ResponseWriter, guildID, userID string, data json.RawMessage) {
@@ -255,11 +268,50 @@ func (ss *SyncService) manageGuildUpdate(ctx context.Context, w http.ResponseWri
 		AFKChannelID      *string  `json:"afk_channel_id"`
 		AFKTimeout        *int     `json:"afk_timeout"`
 		Tags              []string `json:"tags"`
+		PreferredLocale   *string  `json:"preferred_locale"`
+		Timezone          *string  `json:"timezone"`
+
+		SystemChannelID      *string `json:"system_channel_id"`
+		SystemJoinMessages   *bool   `json:"system_join_messages"`
+		SystemBoostMessages  *bool   `json:"system_boost_messages"`
+		SystemEventReminders *bool   `json:"system_event_reminders"`
+		InviteLinkPolicy     *string `json:"invite_link_policy"`
 	}
 	if err := json.Unmarshal(data, &req); err != nil {
 		writeManageError(w, http.StatusBadRequest, "Invalid guild_update data")
 		return
 	}
+	if req.PreferredLocale != nil {
+		loc, err := locale.ParseLocale(*req.PreferredLocale)
+		if err != nil {
+			writeManageError(w, http.StatusBadRequest, "Invalid preferred_locale")
+			return
+		}
+		req.PreferredLocale = &loc
+	}
+	if req.Timezone != nil {
+		tz, err := locale.ParseTimezone(*req.Timezone)
+		if err != nil {
+			writeManageError(w, http.StatusBadRequest, "Invalid timezone")
+			return
+		}
+		req.Timezone = &tz
+	}
+	if req.InviteLinkPolicy != nil && !apiutil.ValidInviteLinkPolicy(*req.InviteLinkPolicy) {
+		writeManageError(w, http.StatusBadRequest, "Invalid invite_link_policy")
+		return
+	}
+	if req.SystemChannelID != nil && *req.SystemChannelID != "" {
+		var ok bool
+		ss.fed.pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM channels WHERE id = $1 AND guild_id = $2
+			    AND channel_type IN ('text', 'announcement') AND parent_channel_id IS NULL)`,
+			*req.SystemChannelID, guildID).Scan(&ok)
+		if !ok {
+			writeManageError(w, http.StatusBadRequest, "Invalid system_channel_id")
+			return
+		}
+	}
 
 	var tagsArg interface{} = nil
 	if req.Tags != nil {
@@ -278,20 +330,32 @@ func (ss *SyncService) manageGuildUpdate(ctx context.Context, w http.ResponseWri
 			verification_level = COALESCE($8, verification_level),
 			afk_channel_id = COALESCE($9, afk_channel_id),
 			afk_timeout = COALESCE($10, afk_timeout),
-			tags = COALESCE($11, tags)
+			tags = COALESCE($11, tags),
+			preferred_locale = COALESCE($12, preferred_locale),
+			timezone = COALESCE($13, timezone),
+			system_channel_id = CASE WHEN $14::text IS NULL THEN system_channel_id ELSE NULLIF($14, '') END,
+			system_join_messages = COALESCE($15, system_join_messages),
+			system_boost_messages = COALESCE($16, system_boost_messages),
+			system_event_reminders = COALESCE($17, system_event_reminders),
+			invite_link_policy = COALESCE($18, invite_link_policy)
 		 WHERE id = $1
 		 RETURNING id, instance_id, owner_id, name, description, icon_id, banner_id,
-		           default_permissions, flags, nsfw, discoverable, preferred_locale, max_members,
+		           default_permissions, flags, nsfw, discoverable, preferred_locale, timezone, max_members,
 		           vanity_url, verification_level, afk_channel_id, afk_timeout,
-		           tags, member_count, created_at`,
+		           system_channel_id, system_join_messages, system_boost_messages, system_event_reminders,
+		           invite_link_policy, tags, member_count, created_at`,
 		guildID, req.Name, req.Description, req.IconID, req.BannerID, req.NSFW,
 		req.Discoverable, req.VerificationLevel, req.AFKChannelID, req.AFKTimeout, tagsArg,
+		req.PreferredLocale, req.Timezone,
+		req.SystemChannelID, req.SystemJoinMessages, req.SystemBoostMessages, req.SystemEventReminders,
+		req.InviteLinkPolicy,
 	).Scan(
 		&guild.ID, &guild.InstanceID, &guild.OwnerID, &guild.Name, &guild.Description,
 		&guild.IconID, &guild.BannerID, &guild.DefaultPermissions, &guild.Flags,
-		&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.MaxMembers,
+		&guild.NSFW, &guild.Discoverable, &guild.PreferredLocale, &guild.Timezone, &guild.MaxMembers,
 		&guild.VanityURL, &guild.VerificationLevel, &guild.AFKChannelID, &guild.AFKTimeout,
-		&guild.Tags, &guild.MemberCount, &guild.CreatedAt,
+		&guild.SystemChannelID, &guild.SystemJoinMessages, &guild.SystemBoostMessages, &guild.SystemEventReminders,
+		&guild.InviteLinkPolicy, &guild.Tags, &guild.MemberCount, &guild.CreatedAt,
 	)
 	if err != nil {
 		ss.logger.Error("manage guild_update: DB error", slog.String("error", err.Error()))
@@ -428,11 +492,31 @@ func (ss *SyncService) manageChannelUpdate(ctx context.Context, w http.ResponseW
 		GalleryDefaultSort         *string  `json:"gallery_default_sort"`
 		GalleryPostGuidelines      *string  `json:"gallery_post_guidelines"`
 		GalleryRequireTags         *bool    `json:"gallery_require_tags"`
+		AutoThread                 *bool    `json:"auto_thread"`
+		DisallowBotPosts           *bool    `json:"disallow_bot_posts"`
+		DisallowWebhookPosts       *bool    `json:"disallow_webhook_posts"`
+		InviteLinksExempt          *bool    `json:"invite_links_exempt"`
+		VoiceChannelID             *string  `json:"voice_channel_id"`
+		VoiceParticipantAllow      *int64   `json:"voice_participant_allow"`
+		VoiceChatRecentMinutes     *int     `json:"voice_chat_recent_minutes"`
+		VoiceChatHistory           *string  `json:"voice_chat_history"`
+		PreviewEnabled             *bool    `json:"preview_enabled"`
+		PreviewRoleID              *string  `json:"preview_role_id"`
+		PublicView                 *bool    `json:"public_view"`
+		Language                   *string  `json:"language"`
 	}
 	if err := json.Unmarshal(data, &req); err != nil {
 		writeManageError(w, http.StatusBadRequest, "Invalid channel_update data")
 		return
 	}
+	if req.Language != nil && *req.Language != "" {
+		lang, err := locale.ParseLanguage(*req.Language)
+		if err != nil {
+			writeManageError(w, http.StatusBadRequest, "Invalid language")
+			return
+		}
+		req.Language = &lang
+	}
 
 	// Use channel_id from data, or fall back to "id" field.
 	channelID := req.ChannelID
@@ -456,6 +540,53 @@ func (ss *SyncService) manageChannelUpdate(ctx context.Context, w http.ResponseW
 		writeManageError(w, http.StatusForbidden, "Channel does not belong to this guild")
 		return
 	}
+	if req.VoiceChannelID != nil && *req.VoiceChannelID != "" {
+		var ok bool
+		ss.fed.pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM channels v JOIN channels c ON c.guild_id = v.guild_id
+			    WHERE c.id = $1 AND v.id = $2 AND v.channel_type IN ('voice', 'stage')
+			      AND c.channel_type IN ('text', 'announcement'))`,
+			channelID, *req.VoiceChannelID).Scan(&ok)
+		if !ok {
+			writeManageError(w, http.StatusBadRequest, "Invalid voice_channel_id")
+			return
+		}
+	}
+	if req.VoiceParticipantAllow != nil && uint64(*req.VoiceParticipantAllow)&permissions.Administrator != 0 {
+		writeManageError(w, http.StatusBadRequest, "voice_participant_allow cannot grant Administrator")
+		return
+	}
+	if req.VoiceChatRecentMinutes != nil && (*req.VoiceChatRecentMinutes < 0 || *req.VoiceChatRecentMinutes > 10080) {
+		writeManageError(w, http.StatusBadRequest, "Invalid voice_chat_recent_minutes")
+		return
+	}
+	if req.VoiceChatHistory != nil && *req.VoiceChatHistory != models.VoiceChatHistoryKeep &&
+		*req.VoiceChatHistory != models.VoiceChatHistorySession {
+		writeManageError(w, http.StatusBadRequest, "Invalid voice_chat_history")
+		return
+	}
+	if req.PreviewRoleID != nil && *req.PreviewRoleID != "" {
+		var ok bool
+		ss.fed.pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM roles WHERE id = $1 AND guild_id = $2)`,
+			*req.PreviewRoleID, guildID).Scan(&ok)
+		if !ok {
+			writeManageError(w, http.StatusBadRequest, "Invalid preview_role_id")
+			return
+		}
+	}
+	if req.PublicView != nil && *req.PublicView {
+		var ok bool
+		ss.fed.pool.QueryRow(ctx,
+			`SELECT channel_type IN ('text', 'announcement')
+			        AND NOT COALESCE($2, nsfw) AND NOT COALESCE($3, encrypted)
+			 FROM channels WHERE id = $1`,
+			channelID, req.NSFW, req.Encrypted).Scan(&ok)
+		if !ok {
+			writeManageError(w, http.StatusBadRequest, "Channel cannot be public")
+			return
+		}
+	}
 
 	var channel models.Channel
 	err := ss.fed.pool.QueryRow(ctx,
@@ -477,20 +608,37 @@ func (ss *SyncService) manageChannelUpdate(ctx context.Context, w http.ResponseW
 			forum_require_tags = COALESCE($16, forum_require_tags),
 			gallery_default_sort = COALESCE($17, gallery_default_sort),
 			gallery_post_guidelines = COALESCE($18, gallery_post_guidelines),
-			gallery_require_tags = COALESCE($19, gallery_require_tags)
+			gallery_require_tags = COALESCE($19, gallery_require_tags),
+			auto_thread = COALESCE($20, auto_thread),
+			disallow_bot_posts = COALESCE($21, disallow_bot_posts),
+			disallow_webhook_posts = COALESCE($22, disallow_webhook_posts),
+			invite_links_exempt = COALESCE($23, invite_links_exempt),
+			voice_channel_id = CASE WHEN $24::text IS NULL THEN voice_channel_id ELSE NULLIF($24, '') END,
+			voice_participant_allow = COALESCE($25, voice_participant_allow),
+			voice_chat_recent_minutes = COALESCE($26, voice_chat_recent_minutes),
+			voice_chat_history = COALESCE($27, voice_chat_history),
+			preview_enabled = COALESCE($28, preview_enabled),
+			preview_role_id = CASE WHEN $29::text IS NULL THEN preview_role_id ELSE NULLIF($29, '') END,
+			public_view = COALESCE($30, public_view),
+			language = CASE WHEN $31::text IS NULL THEN language ELSE NULLIF($31, '') END
 		 WHERE id = $1
 		 RETURNING id, guild_id, category_id, channel_type, name, topic, position,
 		           slowmode_seconds, nsfw, encrypted, last_message_id, owner_id,
 		           default_permissions, user_limit, bitrate, locked, locked_by, locked_at,
 		           archived, read_only, read_only_role_ids, default_auto_archive_duration,
 		           forum_default_sort, forum_post_guidelines, forum_require_tags,
-		           gallery_default_sort, gallery_post_guidelines, gallery_require_tags,
-		           parent_channel_id, last_activity_at, created_at`,
+		           gallery_default_sort, gallery_post_guidelines, gallery_require_tags, auto_thread,
+		           disallow_bot_posts, disallow_webhook_posts, invite_links_exempt,
+		           voice_channel_id, voice_participant_allow, voice_chat_recent_minutes, voice_chat_history,
+		           preview_enabled, preview_role_id, public_view, language, parent_channel_id, last_activity_at, created_at`,
 		channelID, req.Name, req.Topic, req.Position, req.NSFW, req.SlowmodeSeconds,
 		req.UserLimit, req.Bitrate, req.Archived, req.Encrypted, req.ReadOnly, req.ReadOnlyRoleIDs,
 		req.DefaultAutoArchiveDuration,
 		req.ForumDefaultSort, req.ForumPostGuidelines, req.ForumRequireTags,
-		req.GalleryDefaultSort, req.GalleryPostGuidelines, req.GalleryRequireTags,
+		req.GalleryDefaultSort, req.GalleryPostGuidelines, req.GalleryRequireTags, req.AutoThread,
+		req.DisallowBotPosts, req.DisallowWebhookPosts, req.InviteLinksExempt,
+		req.VoiceChannelID, req.VoiceParticipantAllow, req.VoiceChatRecentMinutes, req.VoiceChatHistory,
+		req.PreviewEnabled, req.PreviewRoleID, req.PublicView, req.Language,
 	).Scan(
 		&channel.ID, &channel.GuildID, &channel.CategoryID, &channel.ChannelType, &channel.Name,
 		&channel.Topic, &channel.Position, &channel.SlowmodeSeconds, &channel.NSFW, &channel.Encrypted,
@@ -500,8 +648,10 @@ func (ss *SyncService) manageChannelUpdate(ctx context.Context, w http.ResponseW
 		&channel.Archived, &channel.ReadOnly, &channel.ReadOnlyRoleIDs,
 		&channel.DefaultAutoArchiveDuration,
 		&channel.ForumDefaultSort, &channel.ForumPostGuidelines, &channel.ForumRequireTags,
-		&channel.GalleryDefaultSort, &channel.GalleryPostGuidelines, &channel.GalleryRequireTags,
-		&channel.ParentChannelID, &channel.LastActivityAt, &channel.CreatedAt,
+		&channel.GalleryDefaultSort, &channel.GalleryPostGuidelines, &channel.GalleryRequireTags, &channel.AutoThread,
+		&channel.DisallowBotPosts, &channel.DisallowWebhookPosts, &channel.InviteLinksExempt,
+		&channel.VoiceChannelID, &channel.VoiceParticipantAllow, &channel.VoiceChatRecentMinutes, &channel.VoiceChatHistory,
+		&channel.PreviewEnabled, &channel.PreviewRoleID, &channel.PublicView, &channel.Language, &channel.ParentChannelID, &channel.LastActivityAt, &channel.CreatedAt,
 	)
 	if err != nil {
 		ss.logger.Error("manage channel_update: DB error", slog.String("error", err.Error()))
@@ -1362,6 +1512,25 @@ func (ss *SyncService) manageMemberJoin(ctx context.Context, w http.ResponseWrit
 		return
 	}
 
+	// Check the guild accepts members from the sender instance (fail closed).
+	var senderDomain string
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT domain FROM instances WHERE id = $1`, senderInstanceID).Scan(&senderDomain); err != nil {
+		writeManageError(w, http.StatusInternalServerError, "Failed to look up sender instance")
+		return
+	}
+	ref, err := apiutil.CheckJoinOrigin(ctx, ss.fed.pool, guildID, senderDomain)
+	if err != nil {
+		ss.logger.Error("failed to check join origin policy for federated join",
+			slog.String("guild_id", guildID), slog.String("error", err.Error()))
+		writeManageError(w, http.StatusInternalServerError, "Failed to check join origin policy")
+		return
+	}
+	if ref != nil {
+		writeManageCodeError(w, http.StatusForbidden, ref.Code, ref.Message)
+		return
+	}
+
 	// Create a minimal user stub if the user doesn't exist locally yet.
 	ss.ensureRemoteUserStub(ctx, senderInstanceID, federatedUserInfo{
 		ID: userID,
@@ -1416,6 +1585,15 @@ func (ss *SyncService) manageMemberJoin(ctx context.Context, w http.ResponseWrit
 		return
 	}
 
+	var invitesPaused bool
+	tx.QueryRow(ctx,
+		`SELECT COALESCE(invites_paused_until > now(), false) FROM guilds WHERE id = $1`, guildID,
+	).Scan(&invitesPaused)
+	if invitesPaused {
+		writeManageError(w, http.StatusForbidden, "Invites to this guild are paused")
+		return
+	}
+
 	// Check max members (locked for update).
 	var maxMembers, currentMembers int
 	if err := tx.QueryRow(ctx,
@@ -1446,12 +1624,6 @@ func (ss *SyncService) manageMemberJoin(ctx context.Context, w http.ResponseWrit
 			writeManageError(w, http.StatusInternalServerError, "Failed to increment invite usage")
 			return
 		}
-
-		if _, err := tx.Exec(ctx,
-			`UPDATE guilds SET member_count = member_count + 1 WHERE id = $1`, guildID); err != nil {
-			writeManageError(w, http.StatusInternalServerError, "Failed to update member count")
-			return
-		}
 	}
 
 	if err := tx.Commit(ctx); err != nil {
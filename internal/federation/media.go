@@ -0,0 +1,108 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// MediaHeader is set on every response from the signed media endpoint, so a
+// fetching instance can tell a missing file apart from a peer that predates
+// the endpoint and should be fetched through the legacy public file route.
+const MediaHeader = "X-AmityVox-Media"
+
+// MediaSource is the subset of media.Service that federation needs to serve
+// local files to peers.
+type MediaSource interface {
+	OpenFile(ctx context.Context, fileID string) (io.ReadSeekCloser, string, int64, error)
+}
+
+// mediaFetchRequest is the signed payload of a federated media fetch. The file
+// ID is repeated in the payload so a captured signature cannot be replayed
+// against a different file.
+type mediaFetchRequest struct {
+	FileID string `json:"file_id"`
+}
+
+// SetMediaSource configures the storage used to serve the signed federation
+// media endpoint.
+func (ss *SyncService) SetMediaSource(src MediaSource) {
+	ss.mediaSrc = src
+}
+
+// HandleFederatedMedia handles POST /federation/v1/media/{fileID} — a signed
+// federation endpoint that serves a local attachment or avatar to a peer's
+// media proxy. Range requests are supported.
+func (ss *SyncService) HandleFederatedMedia(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(MediaHeader, "1")
+
+	signed, senderID, ok := ss.verifyFederationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	fileID := chi.URLParam(r, "fileID")
+	var req mediaFetchRequest
+	if err := json.Unmarshal(signed.Payload, &req); err != nil || req.FileID != fileID {
+		http.Error(w, `{"error":{"code":"bad_request","message":"Signed file ID does not match request"}}`, http.StatusBadRequest)
+		return
+	}
+
+	if ss.mediaSrc == nil {
+		http.Error(w, `{"error":{"code":"media_unavailable","message":"Media storage is not configured"}}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	obj, contentType, _, err := ss.mediaSrc.OpenFile(r.Context(), fileID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			http.Error(w, `{"error":{"code":"not_found","message":"File not found"}}`, http.StatusNotFound)
+			return
+		}
+		ss.logger.Error("federated media: failed to open file",
+			slog.String("file_id", fileID),
+			slog.String("sender_id", senderID),
+			slog.String("error", err.Error()))
+		http.Error(w, `{"error":{"code":"internal_error","message":"Failed to retrieve file"}}`, http.StatusInternalServerError)
+		return
+	}
+	defer obj.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// The file ID is immutable, so there is no meaningful modification time.
+	http.ServeContent(w, r, "", time.Time{}, obj)
+}
+
+// NewSignedMediaRequest builds a signed request for a file on a peer's
+// federation media endpoint. The caller sends it and owns the response.
+func (s *Service) NewSignedMediaRequest(ctx context.Context, domain, fileID string) (*http.Request, error) {
+	if err := ValidateFederationDomain(domain); err != nil {
+		return nil, fmt.Errorf("invalid media domain %q: %w", domain, err)
+	}
+	signed, err := s.Sign(mediaFetchRequest{FileID: fileID})
+	if err != nil {
+		return nil, fmt.Errorf("signing media request: %w", err)
+	}
+	body, err := json.Marshal(signed)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling signed media request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("https://%s/federation/v1/media/%s", domain, fileID), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating media request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AmityVox/1.0 (+federation-media-proxy)")
+	return req, nil
+}
@@ -0,0 +1,345 @@
+// Instance domain moves.
+// An instance re-homed to a new domain keeps its instance ID and tells its
+// peers with a move notice: a MoveProof signed with the key peers already
+// hold and, when the move also replaces that key, with the new key as well,
+// so peers know both that the old identity agreed to the move and that the
+// new key belongs to the same operator. Peers accept notices at
+// POST /federation/v1/instance-move and update their instances row in place.
+package federation
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// MaxMoveNoticeAge is how long after it is issued a move notice is accepted,
+// so that peers unreachable during the move can be sent it again.
+const MaxMoveNoticeAge = 30 * 24 * time.Hour
+
+const (
+	moveNoticeSkew    = 5 * time.Minute
+	maxMoveNoticeSize = 64 << 10
+)
+
+// ErrMoveSignature is returned by VerifyMoveNotice when a signature on the
+// notice does not verify.
+var ErrMoveSignature = errors.New("move notice signature does not verify")
+
+// MoveProof is the statement an instance signs when it moves to a new domain.
+// NewPublicKey is set when the move also replaces the instance's key.
+type MoveProof struct {
+	InstanceID   string    `json:"instance_id"`
+	OldDomain    string    `json:"old_domain"`
+	NewDomain    string    `json:"new_domain"`
+	NewPublicKey string    `json:"new_public_key,omitempty"`
+	IssuedAt     time.Time `json:"issued_at"`
+}
+
+// MoveNotice is a MoveProof signed with the instance's current key.
+// NewKeySignature is the signature of the same payload with the new key, and
+// is present exactly when the proof names one.
+type MoveNotice struct {
+	SignedPayload
+	NewKeySignature string `json:"new_key_signature,omitempty"`
+}
+
+// NewMoveNotice signs a move of instanceID from oldDomain to newDomain with
+// key. If newKey is not nil the move also replaces key with it.
+func NewMoveNotice(instanceID string, key, newKey ed25519.PrivateKey, oldDomain, newDomain string, issuedAt time.Time) (*MoveNotice, error) {
+	proof := MoveProof{
+		InstanceID: instanceID,
+		OldDomain:  oldDomain,
+		NewDomain:  newDomain,
+		IssuedAt:   issuedAt.UTC(),
+	}
+	if newKey != nil {
+		pubPEM, err := EncodePublicKeyPEM(newKey.Public().(ed25519.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		proof.NewPublicKey = pubPEM
+	}
+
+	payload, err := json.Marshal(proof)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling move proof: %w", err)
+	}
+	notice := &MoveNotice{SignedPayload: SignedPayload{
+		Payload:   payload,
+		Signature: fmt.Sprintf("%x", ed25519.Sign(key, payload)),
+		SenderID:  instanceID,
+		Timestamp: proof.IssuedAt,
+	}}
+	if newKey != nil {
+		notice.NewKeySignature = fmt.Sprintf("%x", ed25519.Sign(newKey, payload))
+	}
+	return notice, nil
+}
+
+// VerifyMoveNotice checks a move notice against what this instance knows of
+// the sender: its current public key and domain. It returns the proof if the
+// notice is signed by that key (and by the new key it names), moves away from
+// currentDomain, and was issued recently.
+func VerifyMoveNotice(notice *MoveNotice, currentPublicKey, currentDomain string, now time.Time) (*MoveProof, error) {
+	valid, err := VerifySignature(currentPublicKey, notice.Payload, notice.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMoveSignature, err)
+	}
+	if !valid {
+		return nil, ErrMoveSignature
+	}
+
+	var proof MoveProof
+	if err := json.Unmarshal(notice.Payload, &proof); err != nil {
+		return nil, fmt.Errorf("invalid move proof: %w", err)
+	}
+	if proof.InstanceID != notice.SenderID {
+		return nil, fmt.Errorf("move proof is for instance %s, not the sender", proof.InstanceID)
+	}
+	if !strings.EqualFold(proof.OldDomain, currentDomain) {
+		return nil, fmt.Errorf("move proof is from %s, but the instance is known as %s", proof.OldDomain, currentDomain)
+	}
+	if proof.NewDomain == "" || strings.EqualFold(proof.NewDomain, proof.OldDomain) {
+		return nil, fmt.Errorf("move proof does not name a new domain")
+	}
+	if msg := checkTimestamp(proof.IssuedAt, now, MaxMoveNoticeAge, moveNoticeSkew); msg != "" {
+		return nil, fmt.Errorf("move proof %s", msg)
+	}
+
+	switch {
+	case proof.NewPublicKey == "" && notice.NewKeySignature != "":
+		return nil, fmt.Errorf("move notice has a new key signature but names no new key")
+	case proof.NewPublicKey != "":
+		if notice.NewKeySignature == "" {
+			return nil, fmt.Errorf("move notice names a new key but is not signed with it")
+		}
+		valid, err := VerifySignature(proof.NewPublicKey, notice.Payload, notice.NewKeySignature)
+		if err != nil {
+			return nil, fmt.Errorf("%w: new key: %v", ErrMoveSignature, err)
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: new key", ErrMoveSignature)
+		}
+	}
+	return &proof, nil
+}
+
+// HandleInstanceMove handles POST /federation/v1/instance-move — a peer
+// announcing that it has moved to a new domain. The instances row keeps its
+// ID and takes the new domain and key; guild join origin lists and replica
+// member records naming the old domain follow it. Sending a notice that was
+// already applied again is harmless.
+func (s *Service) HandleInstanceMove(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMoveNoticeSize))
+	if err != nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_body", "Failed to read body")
+		return
+	}
+	var notice MoveNotice
+	if err := json.Unmarshal(body, &notice); err != nil || notice.SenderID == "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_body", "Invalid move notice")
+		return
+	}
+	ctx := r.Context()
+
+	var already bool
+	if err := s.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM federation_instance_moves
+		               WHERE instance_id = $1 AND signature = $2)`,
+		notice.SenderID, notice.Signature,
+	).Scan(&already); err != nil {
+		s.logger.Error("instance move: failed to check recorded moves", slog.String("error", err.Error()))
+		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Internal error")
+		return
+	}
+	if already {
+		apiutil.WriteJSONRaw(w, http.StatusOK, map[string]string{"status": "already_applied"})
+		return
+	}
+
+	var domain, publicKey string
+	var lastIssued *time.Time
+	err = s.pool.QueryRow(ctx,
+		`SELECT i.domain, i.public_key,
+		        (SELECT MAX(issued_at) FROM federation_instance_moves WHERE instance_id = i.id)
+		 FROM instances i WHERE i.id = $1 AND i.id <> $2`,
+		notice.SenderID, s.instanceID,
+	).Scan(&domain, &publicKey, &lastIssued)
+	if err == pgx.ErrNoRows {
+		s.recordRejection(r, notice.SenderID, RejectUnknownPeer, "")
+		apiutil.WriteError(w, http.StatusForbidden, "unknown_instance", "Unknown sender instance")
+		return
+	}
+	if err != nil {
+		s.logger.Error("instance move: failed to look up sender", slog.String("error", err.Error()))
+		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Internal error")
+		return
+	}
+
+	proof, err := VerifyMoveNotice(&notice, publicKey, domain, time.Now().UTC())
+	if errors.Is(err, ErrMoveSignature) {
+		s.recordRejection(r, notice.SenderID, RejectBadSignature, err.Error())
+		apiutil.WriteError(w, http.StatusForbidden, "invalid_signature", err.Error())
+		return
+	}
+	if err != nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_move_notice", err.Error())
+		return
+	}
+	if lastIssued != nil && !proof.IssuedAt.After(*lastIssued) {
+		apiutil.WriteError(w, http.StatusConflict, "stale_move_notice",
+			"A newer move of this instance has already been recorded")
+		return
+	}
+	newDomain := strings.ToLower(proof.NewDomain)
+	if err := ValidateFederationDomain(newDomain); err != nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_domain", err.Error())
+		return
+	}
+
+	var holder string
+	err = s.pool.QueryRow(ctx,
+		`SELECT id FROM instances WHERE domain = $1 AND id <> $2`, newDomain, notice.SenderID,
+	).Scan(&holder)
+	if err == nil {
+		apiutil.WriteError(w, http.StatusConflict, "domain_taken",
+			"Another instance is already known by "+newDomain)
+		return
+	}
+	if err != pgx.ErrNoRows {
+		s.logger.Error("instance move: failed to check new domain", slog.String("error", err.Error()))
+		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Internal error")
+		return
+	}
+
+	var newFingerprint *string
+	if proof.NewPublicKey != "" {
+		fp, err := ComputeKeyFingerprint(proof.NewPublicKey)
+		if err != nil {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_move_notice", err.Error())
+			return
+		}
+		newFingerprint = &fp
+	}
+
+	err = apiutil.WithTx(ctx, s.pool, func(tx pgx.Tx) error {
+		if proof.NewPublicKey == "" {
+			if _, err := tx.Exec(ctx,
+				`UPDATE instances SET domain = $1, resolved_ips = NULL WHERE id = $2`,
+				newDomain, notice.SenderID); err != nil {
+				return fmt.Errorf("updating instance: %w", err)
+			}
+		} else {
+			oldFingerprint, _ := ComputeKeyFingerprint(publicKey)
+			if _, err := tx.Exec(ctx,
+				`UPDATE instances SET domain = $1, public_key = $2, key_fingerprint = $3, resolved_ips = NULL
+				 WHERE id = $4`,
+				newDomain, proof.NewPublicKey, *newFingerprint, notice.SenderID); err != nil {
+				return fmt.Errorf("updating instance: %w", err)
+			}
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO federation_key_audit (id, instance_id, old_fingerprint, new_fingerprint, old_public_key, detected_at)
+				 VALUES ($1, $2, $3, $4, $5, now())`,
+				models.NewULID().String(), notice.SenderID, oldFingerprint, *newFingerprint, publicKey); err != nil {
+				return fmt.Errorf("recording key change: %w", err)
+			}
+		}
+
+		if _, err := tx.Exec(ctx,
+			`UPDATE federated_replica_members SET instance_domain = $2 WHERE instance_domain = $1`,
+			domain, newDomain); err != nil {
+			return fmt.Errorf("updating replica members: %w", err)
+		}
+		if _, err := tx.Exec(ctx,
+			`UPDATE guild_join_origin_policy
+			 SET allowed_domains = array_replace(allowed_domains, $1, $2),
+			     blocked_domains = array_replace(blocked_domains, $1, $2)
+			 WHERE $1 = ANY(allowed_domains) OR $1 = ANY(blocked_domains)`,
+			domain, newDomain); err != nil {
+			return fmt.Errorf("updating join origin policies: %w", err)
+		}
+		if _, err := tx.Exec(ctx,
+			`DELETE FROM instance_directory WHERE domain = $1`, domain); err != nil {
+			return fmt.Errorf("removing directory entry: %w", err)
+		}
+
+		_, err := tx.Exec(ctx,
+			`INSERT INTO federation_instance_moves
+			     (id, instance_id, old_domain, new_domain, new_key_fingerprint, issued_at, signature, notice, recorded_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())`,
+			models.NewULID().String(), notice.SenderID, domain, newDomain, newFingerprint, proof.IssuedAt,
+			notice.Signature, string(body))
+		return err
+	})
+	if err != nil {
+		s.logger.Error("instance move: failed to apply move",
+			slog.String("instance_id", notice.SenderID), slog.String("error", err.Error()))
+		apiutil.WriteError(w, http.StatusInternalServerError, "internal_error", "Internal error")
+		return
+	}
+
+	s.pubKeyCache.Invalidate(notice.SenderID)
+	s.allowedCache.Invalidate(notice.SenderID)
+	if ips, err := net.LookupHost(newDomain); err == nil && len(ips) > 0 {
+		s.pool.Exec(ctx, `UPDATE instances SET resolved_ips = $1 WHERE id = $2`, ips, notice.SenderID)
+	}
+
+	s.logger.Info("federation peer moved to a new domain",
+		slog.String("instance_id", notice.SenderID),
+		slog.String("old_domain", domain),
+		slog.String("new_domain", newDomain),
+		slog.Bool("new_key", proof.NewPublicKey != ""))
+	apiutil.WriteJSONRaw(w, http.StatusOK, map[string]string{"status": "applied"})
+}
+
+// EncodePublicKeyPEM encodes an Ed25519 public key the way instances publish
+// it: PKIX in a PUBLIC KEY block.
+func EncodePublicKeyPEM(key ed25519.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("marshaling public key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// EncodePrivateKeyPEM encodes an Ed25519 private key the way it is stored in
+// instances.private_key_pem: PKCS#8 in a PRIVATE KEY block.
+func EncodePrivateKeyPEM(key ed25519.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("marshaling private key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParsePrivateKeyPEM decodes a key encoded by EncodePrivateKeyPEM.
+func ParsePrivateKeyPEM(keyPEM string) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not Ed25519")
+	}
+	return edKey, nil
+}
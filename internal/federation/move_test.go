@@ -0,0 +1,139 @@
+package federation
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func moveTestKey(t *testing.T) (ed25519.PrivateKey, string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("keygen error: %v", err)
+	}
+	pubPEM, err := EncodePublicKeyPEM(pub)
+	if err != nil {
+		t.Fatalf("EncodePublicKeyPEM: %v", err)
+	}
+	return priv, pubPEM
+}
+
+func TestVerifyMoveNotice(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	oldKey, oldPub := moveTestKey(t)
+	newKey, newPub := moveTestKey(t)
+	_, otherPub := moveTestKey(t)
+
+	sameKey, err := NewMoveNotice("inst1", oldKey, nil, "old.example", "new.example", now)
+	if err != nil {
+		t.Fatalf("NewMoveNotice: %v", err)
+	}
+	rekeyed, err := NewMoveNotice("inst1", oldKey, newKey, "old.example", "new.example", now)
+	if err != nil {
+		t.Fatalf("NewMoveNotice: %v", err)
+	}
+
+	proof, err := VerifyMoveNotice(sameKey, oldPub, "old.example", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("same key move: %v", err)
+	}
+	if proof.NewDomain != "new.example" || proof.NewPublicKey != "" {
+		t.Errorf("same key move proof = %+v", proof)
+	}
+
+	proof, err = VerifyMoveNotice(rekeyed, oldPub, "OLD.example", now)
+	if err != nil {
+		t.Fatalf("rekeyed move: %v", err)
+	}
+	if proof.NewPublicKey != newPub {
+		t.Errorf("rekeyed move names key %q, want the new key", proof.NewPublicKey)
+	}
+
+	if _, err := VerifyMoveNotice(sameKey, otherPub, "old.example", now); !errors.Is(err, ErrMoveSignature) {
+		t.Errorf("wrong key: err = %v, want ErrMoveSignature", err)
+	}
+
+	stripped := *rekeyed
+	stripped.NewKeySignature = ""
+	if _, err := VerifyMoveNotice(&stripped, oldPub, "old.example", now); err == nil {
+		t.Error("rekeyed move without the new key signature accepted")
+	}
+	forged := *rekeyed
+	forged.NewKeySignature = sameKey.Signature
+	if _, err := VerifyMoveNotice(&forged, oldPub, "old.example", now); !errors.Is(err, ErrMoveSignature) {
+		t.Errorf("bad new key signature: err = %v, want ErrMoveSignature", err)
+	}
+	extra := *sameKey
+	extra.NewKeySignature = rekeyed.NewKeySignature
+	if _, err := VerifyMoveNotice(&extra, oldPub, "old.example", now); err == nil {
+		t.Error("new key signature without a new key accepted")
+	}
+
+	wrongSender := *sameKey
+	wrongSender.SenderID = "inst2"
+	if _, err := VerifyMoveNotice(&wrongSender, oldPub, "old.example", now); err == nil {
+		t.Error("notice from another sender accepted")
+	}
+	if _, err := VerifyMoveNotice(sameKey, oldPub, "elsewhere.example", now); err == nil {
+		t.Error("notice moving from another domain accepted")
+	}
+	if _, err := VerifyMoveNotice(sameKey, oldPub, "old.example", now.Add(MaxMoveNoticeAge+time.Hour)); err == nil {
+		t.Error("expired notice accepted")
+	}
+	if _, err := VerifyMoveNotice(sameKey, oldPub, "old.example", now.Add(-time.Hour)); err == nil {
+		t.Error("notice from the future accepted")
+	}
+
+	noop, _ := NewMoveNotice("inst1", oldKey, nil, "old.example", "Old.Example", now)
+	if _, err := VerifyMoveNotice(noop, oldPub, "old.example", now); err == nil {
+		t.Error("move to the same domain accepted")
+	}
+}
+
+func TestMoveNotice_JSON(t *testing.T) {
+	key, _ := moveTestKey(t)
+	newKey, _ := moveTestKey(t)
+	notice, err := NewMoveNotice("inst1", key, newKey, "old.example", "new.example", time.Now())
+	if err != nil {
+		t.Fatalf("NewMoveNotice: %v", err)
+	}
+	data, err := json.Marshal(notice)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, field := range []string{`"payload"`, `"signature"`, `"sender_id"`, `"new_key_signature"`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("notice JSON lacks %s: %s", field, data)
+		}
+	}
+
+	var decoded MoveNotice
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.SenderID != "inst1" || decoded.NewKeySignature != notice.NewKeySignature {
+		t.Errorf("decoded notice = %+v", decoded)
+	}
+}
+
+func TestPrivateKeyPEM_RoundTrip(t *testing.T) {
+	key, _ := moveTestKey(t)
+	keyPEM, err := EncodePrivateKeyPEM(key)
+	if err != nil {
+		t.Fatalf("EncodePrivateKeyPEM: %v", err)
+	}
+	parsed, err := ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM: %v", err)
+	}
+	if !parsed.Equal(key) {
+		t.Error("parsed key differs from the encoded one")
+	}
+	if _, err := ParsePrivateKeyPEM("not a key"); err == nil {
+		t.Error("garbage parsed as a key")
+	}
+}
@@ -0,0 +1,228 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// Federated event ordering
+//
+// Within a federated guild the home instance's replica log sequence number
+// (FederatedMessage.Seq) is authoritative. Inbound events of a guild are
+// fanned out to local clients in sequence order: an event that arrives ahead
+// of a missing one waits in a reorder buffer until the gap fills or
+// reorderWait passes. Events without a sequence number (DMs, peers that
+// predate it) are fanned out as they arrive and ordered by message ID, which
+// is a ULID minted by the instance that owns the channel.
+//
+// A MESSAGE_CREATE that orders before a message already fanned out in the
+// same channel is marked "inserted_above" so clients place it in history
+// instead of appending it.
+
+const (
+	// reorderWait is how long buffered events wait for a missing sequence
+	// number before they are fanned out without it.
+	reorderWait = 2 * time.Second
+
+	// reorderBufferLimit caps the events buffered per guild; a full buffer
+	// is fanned out at once.
+	reorderBufferLimit = 256
+)
+
+// orderKey is the position of a message in a federated channel.
+type orderKey struct {
+	Seq uint64
+	ID  string
+}
+
+// before reports whether k orders before o: by sequence number when both
+// have one, otherwise by message ID.
+func (k orderKey) before(o orderKey) bool {
+	if k.Seq > 0 && o.Seq > 0 && k.Seq != o.Seq {
+		return k.Seq < o.Seq
+	}
+	return k.ID < o.ID
+}
+
+type bufferedEvent struct {
+	subject string
+	event   events.Event
+}
+
+// guildOrder is the reorder state of one guild's inbound event stream.
+type guildOrder struct {
+	first   uint64 // first sequence number seen; earlier ones predate the buffer
+	next    uint64
+	pending map[uint64]bufferedEvent
+	skipped map[uint64]bool // given up on; fanned out late if they arrive
+	timer   *time.Timer
+}
+
+// reorderBuffer releases sequenced events of each guild in order. publish is
+// called with the buffer's lock held, so events of a guild are never
+// published concurrently.
+type reorderBuffer struct {
+	mu      sync.Mutex
+	guilds  map[string]*guildOrder
+	wait    time.Duration
+	limit   int
+	publish func(subject string, event events.Event, seq uint64)
+}
+
+func newReorderBuffer(wait time.Duration, limit int, publish func(subject string, event events.Event, seq uint64)) *reorderBuffer {
+	return &reorderBuffer{
+		guilds:  make(map[string]*guildOrder),
+		wait:    wait,
+		limit:   limit,
+		publish: publish,
+	}
+}
+
+// add accepts event seq of a guild. It is published now if it is the next
+// one expected, buffered if it is ahead, and dropped as a duplicate if it is
+// behind and was neither skipped nor older than the first event seen.
+func (b *reorderBuffer) add(guildID string, seq uint64, subject string, event events.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g, ok := b.guilds[guildID]
+	if !ok {
+		g = &guildOrder{first: seq, next: seq, pending: make(map[uint64]bufferedEvent), skipped: make(map[uint64]bool)}
+		b.guilds[guildID] = g
+	}
+
+	switch {
+	case seq < g.next:
+		if seq < g.first || g.skipped[seq] {
+			delete(g.skipped, seq)
+			b.publish(subject, event, seq)
+		}
+
+	case seq == g.next:
+		b.publish(subject, event, seq)
+		g.next++
+		b.drain(g)
+
+	default:
+		if _, dup := g.pending[seq]; dup {
+			return
+		}
+		g.pending[seq] = bufferedEvent{subject: subject, event: event}
+		if len(g.pending) >= b.limit {
+			b.flush(g)
+			return
+		}
+		if g.timer == nil {
+			g.timer = time.AfterFunc(b.wait, func() { b.expire(guildID) })
+		}
+	}
+}
+
+// drain publishes buffered events that are now next in sequence.
+func (b *reorderBuffer) drain(g *guildOrder) {
+	for {
+		ev, ok := g.pending[g.next]
+		if !ok {
+			break
+		}
+		delete(g.pending, g.next)
+		b.publish(ev.subject, ev.event, g.next)
+		g.next++
+	}
+	if len(g.pending) == 0 && g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+}
+
+// flush publishes every buffered event in sequence order, skipping the
+// missing ones.
+func (b *reorderBuffer) flush(g *guildOrder) {
+	seqs := make([]uint64, 0, len(g.pending))
+	for seq := range g.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	if len(g.skipped) > b.limit {
+		g.skipped = make(map[uint64]bool)
+	}
+	for _, seq := range seqs {
+		for missing := g.next; missing < seq; missing++ {
+			g.skipped[missing] = true
+		}
+		ev := g.pending[seq]
+		delete(g.pending, seq)
+		b.publish(ev.subject, ev.event, seq)
+		g.next = seq + 1
+	}
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+}
+
+// expire gives up waiting for the gap in a guild's stream.
+func (b *reorderBuffer) expire(guildID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if g, ok := b.guilds[guildID]; ok {
+		g.timer = nil
+		b.flush(g)
+	}
+}
+
+// dispatchInbound fans an inbound federated event out to local clients,
+// through the reorder buffer if it carries a guild sequence number.
+func (ss *SyncService) dispatchInbound(guildID string, seq uint64, subject string, event events.Event) {
+	if seq > 0 && guildID != "" {
+		ss.reorder.add(guildID, seq, subject, event)
+		return
+	}
+	ss.publishInbound(subject, event, seq)
+}
+
+// publishInbound publishes an inbound federated event on the local bus,
+// marking messages that order before the channel's latest one.
+func (ss *SyncService) publishInbound(subject string, event events.Event, seq uint64) {
+	if event.Type == "MESSAGE_CREATE" && event.ChannelID != "" {
+		event.Data = ss.markInsertedAbove(event.ChannelID, seq, event.Data)
+	}
+	if err := ss.bus.Publish(context.Background(), subject, event); err != nil {
+		ss.logger.Error("failed to publish federated event",
+			slog.String("type", event.Type),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// markInsertedAbove sets "inserted_above" on a message that orders before
+// the latest message fanned out in its channel, and otherwise records it as
+// the latest.
+func (ss *SyncService) markInsertedAbove(channelID string, seq uint64, data json.RawMessage) json.RawMessage {
+	var msg map[string]interface{}
+	if json.Unmarshal(data, &msg) != nil {
+		return data
+	}
+	id, _ := msg["id"].(string)
+	if id == "" {
+		return data
+	}
+
+	key := orderKey{Seq: seq, ID: id}
+	if latest, ok := ss.latestMessages.Get(channelID); ok && key.before(latest) {
+		msg["inserted_above"] = true
+		if marked, err := json.Marshal(msg); err == nil {
+			return marked
+		}
+		return data
+	}
+	ss.latestMessages.Set(channelID, key)
+	return data
+}
@@ -0,0 +1,111 @@
+package federation
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// newTestReorderBuffer returns a buffer that records published sequence
+// numbers.
+func newTestReorderBuffer(wait time.Duration, limit int) (*reorderBuffer, func() []uint64) {
+	var mu sync.Mutex
+	var got []uint64
+	b := newReorderBuffer(wait, limit, func(_ string, _ events.Event, seq uint64) {
+		mu.Lock()
+		got = append(got, seq)
+		mu.Unlock()
+	})
+	return b, func() []uint64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]uint64(nil), got...)
+	}
+}
+
+func TestOrderKey_Before(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b orderKey
+		want bool
+	}{
+		{"lower seq", orderKey{1, "B"}, orderKey{2, "A"}, true},
+		{"higher seq", orderKey{3, "A"}, orderKey{2, "B"}, false},
+		{"same seq uses ID", orderKey{2, "A"}, orderKey{2, "B"}, true},
+		{"no seq uses ID", orderKey{0, "B"}, orderKey{5, "A"}, false},
+		{"no seq lower ID", orderKey{0, "A"}, orderKey{0, "B"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.before(tt.b); got != tt.want {
+				t.Errorf("%v.before(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReorderBuffer_ReordersGap(t *testing.T) {
+	b, got := newTestReorderBuffer(time.Hour, 16)
+	for _, seq := range []uint64{5, 7, 8, 6, 9} {
+		b.add("g", seq, "", events.Event{})
+	}
+	if want := []uint64{5, 6, 7, 8, 9}; !reflect.DeepEqual(got(), want) {
+		t.Errorf("published %v, want %v", got(), want)
+	}
+}
+
+func TestReorderBuffer_DropsDuplicates(t *testing.T) {
+	b, got := newTestReorderBuffer(time.Hour, 16)
+	for _, seq := range []uint64{5, 6, 6, 5, 8, 8, 7} {
+		b.add("g", seq, "", events.Event{})
+	}
+	if want := []uint64{5, 6, 7, 8}; !reflect.DeepEqual(got(), want) {
+		t.Errorf("published %v, want %v", got(), want)
+	}
+}
+
+func TestReorderBuffer_TimeoutSkipsGap(t *testing.T) {
+	b, got := newTestReorderBuffer(10*time.Millisecond, 16)
+	b.add("g", 1, "", events.Event{})
+	b.add("g", 3, "", events.Event{})
+	b.add("g", 4, "", events.Event{})
+
+	deadline := time.Now().Add(time.Second)
+	for len(got()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if want := []uint64{1, 3, 4}; !reflect.DeepEqual(got(), want) {
+		t.Fatalf("published %v, want %v", got(), want)
+	}
+
+	// The skipped event is still fanned out if it shows up late, once.
+	b.add("g", 2, "", events.Event{})
+	b.add("g", 2, "", events.Event{})
+	b.add("g", 5, "", events.Event{})
+	if want := []uint64{1, 3, 4, 2, 5}; !reflect.DeepEqual(got(), want) {
+		t.Errorf("published %v, want %v", got(), want)
+	}
+}
+
+func TestReorderBuffer_FullBufferFlushes(t *testing.T) {
+	b, got := newTestReorderBuffer(time.Hour, 3)
+	for _, seq := range []uint64{1, 3, 4, 5} {
+		b.add("g", seq, "", events.Event{})
+	}
+	if want := []uint64{1, 3, 4, 5}; !reflect.DeepEqual(got(), want) {
+		t.Errorf("published %v, want %v", got(), want)
+	}
+}
+
+func TestReorderBuffer_GuildsIndependent(t *testing.T) {
+	b, got := newTestReorderBuffer(time.Hour, 16)
+	b.add("a", 1, "", events.Event{})
+	b.add("a", 3, "", events.Event{})
+	b.add("b", 7, "", events.Event{})
+	if want := []uint64{1, 7}; !reflect.DeepEqual(got(), want) {
+		t.Errorf("published %v, want %v", got(), want)
+	}
+}
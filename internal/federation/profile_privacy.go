@@ -0,0 +1,77 @@
+package federation
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// profileConnection is a profile link as shared with remote instances.
+type profileConnection struct {
+	Platform string `json:"platform"`
+	Label    string `json:"label"`
+	URL      string `json:"url"`
+	Verified bool   `json:"verified"`
+}
+
+// profileActivity is a user's activity status as shared with remote
+// instances.
+type profileActivity struct {
+	Type *string `json:"type,omitempty"`
+	Name *string `json:"name,omitempty"`
+}
+
+// LoadProfilePrivacy returns which profile fields the user shares with
+// remote instances, or the defaults if they have never changed them or the
+// lookup fails.
+func LoadProfilePrivacy(ctx context.Context, pool *pgxpool.Pool, userID string) models.ProfilePrivacy {
+	p := models.DefaultProfilePrivacy()
+	if err := pool.QueryRow(ctx,
+		`SELECT bio, pronouns, banner, connections, activity, updated_at
+		 FROM user_profile_privacy WHERE user_id = $1`, userID,
+	).Scan(&p.Bio, &p.Pronouns, &p.Banner, &p.Connections, &p.Activity, &p.UpdatedAt); err != nil {
+		return models.DefaultProfilePrivacy()
+	}
+	return p
+}
+
+// loadProfileConnections returns the user's profile links in display order.
+func loadProfileConnections(ctx context.Context, pool *pgxpool.Pool, userID string) []profileConnection {
+	rows, err := pool.Query(ctx,
+		`SELECT platform, label, url, COALESCE(verified, false)
+		 FROM user_links WHERE user_id = $1 ORDER BY position`, userID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var conns []profileConnection
+	for rows.Next() {
+		var c profileConnection
+		if rows.Scan(&c.Platform, &c.Label, &c.URL, &c.Verified) == nil {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// applyProfilePrivacy clears the fields of a local user's profile that the
+// user keeps from remote instances.
+func applyProfilePrivacy(p *userProfileResponse, privacy models.ProfilePrivacy) {
+	if privacy.Bio != models.ProfileVisibilityEveryone {
+		p.Bio = nil
+	}
+	if privacy.Pronouns != models.ProfileVisibilityEveryone {
+		p.Pronouns = nil
+	}
+	if privacy.Banner != models.ProfileVisibilityEveryone {
+		p.BannerID = nil
+	}
+	if privacy.Connections != models.ProfileVisibilityEveryone {
+		p.Connections = nil
+	}
+	if privacy.Activity != models.ProfileVisibilityEveryone {
+		p.Activity = nil
+	}
+}
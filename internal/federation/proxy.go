@@ -137,11 +137,16 @@ func (ss *SyncService) ProxyToHomeInstance(
 	w.Header().Set("Content-Type", "application/json")
 
 	if !resp.OK {
-		// The remote instance returned an error — pass it through.
+		// The remote instance returned an error — pass it through, keeping
+		// its code when it gave one.
+		code := "FEDERATION_REMOTE_ERROR"
+		if resp.Code != "" {
+			code = resp.Code
+		}
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"error": map[string]string{
-				"code":    "FEDERATION_REMOTE_ERROR",
+				"code":    code,
 				"message": resp.Error,
 			},
 		})
@@ -183,6 +188,10 @@ func (ss *SyncService) ProxyReadGuildMembers(w http.ResponseWriter, r *http.Requ
 		return false // local guild
 	}
 
+	if ss.serveReplicaMembers(w, r, guildID, *instanceID, instanceDomain) {
+		return true
+	}
+
 	// Fetch members from the home instance.
 	remoteURL := fmt.Sprintf("https://%s/federation/v1/guilds/%s/members", instanceDomain, guildID)
 	respBody, statusCode, err := ss.signAndPost(ctx, remoteURL, federatedGuildMembersRequest{UserID: userID})
@@ -211,15 +220,7 @@ func (ss *SyncService) ProxyReadGuildMembers(w http.ResponseWriter, r *http.Requ
 
 	// Parse the flat remote response and transform into GuildMember shape.
 	var remoteResp struct {
-		Data []struct {
-			UserID         string          `json:"user_id"`
-			Username       string          `json:"username"`
-			DisplayName    *string         `json:"display_name"`
-			AvatarID       *string         `json:"avatar_id"`
-			InstanceDomain string          `json:"instance_domain"`
-			RoleIDs        json.RawMessage `json:"role_ids"`
-			JoinedAt       time.Time       `json:"joined_at"`
-		} `json:"data"`
+		Data []replicaMember `json:"data"`
 	}
 	if err := json.Unmarshal(respBody, &remoteResp); err != nil {
 		// Fallback: return raw response if parsing fails.
@@ -229,9 +230,17 @@ func (ss *SyncService) ProxyReadGuildMembers(w http.ResponseWriter, r *http.Requ
 		return true
 	}
 
+	ss.writeRemoteGuildMembers(ctx, w, guildID, instanceDomain, remoteResp.Data)
+	return true
+}
+
+// writeRemoteGuildMembers writes members of a federated guild in the local
+// GuildMember shape. Members without an instance domain belong to the
+// guild's home instance.
+func (ss *SyncService) writeRemoteGuildMembers(ctx context.Context, w http.ResponseWriter, guildID, instanceDomain string, remoteMembers []replicaMember) {
 	// Build domain → instance_id map for avatar proxy URLs.
 	domainSet := map[string]bool{}
-	for _, m := range remoteResp.Data {
+	for _, m := range remoteMembers {
 		domain := m.InstanceDomain
 		if domain == "" {
 			domain = instanceDomain
@@ -257,12 +266,9 @@ func (ss *SyncService) ProxyReadGuildMembers(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	members := make([]map[string]interface{}, 0, len(remoteResp.Data))
-	for _, m := range remoteResp.Data {
-		var roles []string
-		if len(m.RoleIDs) > 0 {
-			json.Unmarshal(m.RoleIDs, &roles)
-		}
+	members := make([]map[string]interface{}, 0, len(remoteMembers))
+	for _, m := range remoteMembers {
+		roles := m.RoleIDs
 		if roles == nil {
 			roles = []string{}
 		}
@@ -295,7 +301,6 @@ func (ss *SyncService) ProxyReadGuildMembers(w http.ResponseWriter, r *http.Requ
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"data": members})
-	return true
 }
 
 // ProxyReadChannelMessages checks if the channel belongs to a federated guild
@@ -342,6 +347,10 @@ func (ss *SyncService) ProxyReadChannelMessages(w http.ResponseWriter, r *http.R
 		After:  r.URL.Query().Get("after"),
 		Limit:  limit,
 	}
+	if payload.After == "" &&
+		ss.serveReplicaMessages(w, r, guildID, *instanceID, instanceDomain, channelID, payload.Before, limit) {
+		return true
+	}
 
 	remoteURL := fmt.Sprintf("https://%s/federation/v1/guilds/%s/channels/%s/messages",
 		instanceDomain, guildID, channelID)
@@ -0,0 +1,73 @@
+package federation
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Reasons an inbound federation request is rejected, as recorded in
+// federation_rejections.
+const (
+	RejectBadSignature = "bad_signature"
+	RejectUnknownPeer  = "unknown_peer"
+	RejectClockSkew    = "clock_skew"
+)
+
+// Default timestamp window used when Config leaves it unset.
+const (
+	defaultMaxTimestampAge = 5 * time.Minute
+	defaultClockSkew       = 30 * time.Second
+)
+
+// checkTimestamp reports why ts is outside the window accepted at now: older
+// than maxAge, or more than skew in the future. It returns "" if ts is fresh.
+func checkTimestamp(ts, now time.Time, maxAge, skew time.Duration) string {
+	age := now.Sub(ts)
+	if age > maxAge {
+		return fmt.Sprintf("timestamp too old: %s ago", age.Truncate(time.Second))
+	}
+	if age < -skew {
+		return fmt.Sprintf("timestamp too far in the future: %s ahead", (-age).Truncate(time.Second))
+	}
+	return ""
+}
+
+// validateTimestamp checks that a federation payload timestamp is fresh
+// within the configured age and clock skew.
+func (s *Service) validateTimestamp(ts time.Time) string {
+	return checkTimestamp(ts, time.Now().UTC(), s.maxTimestampAge, s.clockSkew)
+}
+
+// signatureDetail describes why VerifySignature refused a payload: the error
+// for a malformed key or signature, or nothing for a plain mismatch.
+func signatureDetail(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// recordRejection logs a refused inbound request to federation_rejections.
+// Failures are only logged; the request is refused either way.
+func (s *Service) recordRejection(r *http.Request, senderID, reason, detail string) {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	var detailArg *string
+	if detail != "" {
+		detailArg = &detail
+	}
+	if _, err := s.pool.Exec(r.Context(),
+		`INSERT INTO federation_rejections (id, sender_id, remote_addr, endpoint, reason, detail, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, now())`,
+		models.NewULID().String(), senderID, remote, r.URL.Path, reason, detailArg); err != nil {
+		s.logger.Warn("failed to record federation rejection",
+			slog.String("reason", reason), slog.String("error", err.Error()))
+	}
+}
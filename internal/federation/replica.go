@@ -0,0 +1,753 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// Guild replicas
+//
+// The home instance of a guild numbers the events it federates for that
+// guild (guild_replica_log) and stamps the number on the FederatedMessage.
+// A peer whose users browse the guild keeps a replica of its member list and
+// recent messages, applies numbered events in order, and on a gap fetches
+// the missed events (or a full snapshot if the log no longer covers them)
+// from /federation/v1/guilds/{guildID}/replica. Reads are served from the
+// replica once it is ready and fall back to proxying otherwise.
+
+const (
+	// replicaMessageLimit is how many recent messages per channel a replica
+	// keeps. It matches the default page size of a message read.
+	replicaMessageLimit = 50
+
+	// replicaMemberLimit caps the members sent in a snapshot.
+	replicaMemberLimit = 1000
+
+	// replicaDeltaLimit is the most log entries returned as a delta before
+	// the home instance sends a snapshot instead.
+	replicaDeltaLimit = 500
+
+	replicaStatusSyncing = "syncing"
+	replicaStatusReady   = "ready"
+)
+
+// replicatedEventTypes are the events recorded in a guild's replica log.
+var replicatedEventTypes = map[string]bool{
+	"GUILD_UPDATE":        true,
+	"CHANNEL_CREATE":      true,
+	"CHANNEL_UPDATE":      true,
+	"CHANNEL_DELETE":      true,
+	"GUILD_MEMBER_ADD":    true,
+	"GUILD_MEMBER_UPDATE": true,
+	"GUILD_MEMBER_REMOVE": true,
+	"MESSAGE_CREATE":      true,
+	"MESSAGE_UPDATE":      true,
+	"MESSAGE_DELETE":      true,
+}
+
+type federatedReplicaRequest struct {
+	Since int64 `json:"since"`
+}
+
+// replicaMember is a guild member as stored in a replica. Member events in
+// the replica log carry one under "member" so peers need no extra lookup.
+type replicaMember struct {
+	UserID         string    `json:"user_id"`
+	Username       string    `json:"username"`
+	DisplayName    *string   `json:"display_name"`
+	AvatarID       *string   `json:"avatar_id"`
+	InstanceDomain string    `json:"instance_domain"`
+	RoleIDs        []string  `json:"role_ids"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+// replicaChannel is a non-private guild channel in a snapshot. Complete is
+// true when Messages holds every message of the channel.
+type replicaChannel struct {
+	ID              string  `json:"id"`
+	ChannelType     string  `json:"channel_type"`
+	Name            *string `json:"name"`
+	Topic           *string `json:"topic"`
+	Position        int     `json:"position"`
+	CategoryID      *string `json:"category_id"`
+	ParentChannelID *string `json:"parent_channel_id"`
+	Encrypted       bool    `json:"encrypted"`
+	Complete        bool    `json:"complete"`
+}
+
+type replicaLogEntry struct {
+	Seq       int64           `json:"seq"`
+	Type      string          `json:"type"`
+	ChannelID string          `json:"channel_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// replicaState is the answer to a replica request: either the log entries
+// after the requested sequence number, or a snapshot of the guild as of
+// LatestSeq.
+type replicaState struct {
+	Mode      string            `json:"mode"` // "delta" or "snapshot"
+	LatestSeq int64             `json:"latest_seq"`
+	Events    []replicaLogEntry `json:"events,omitempty"`
+	Channels  []replicaChannel  `json:"channels,omitempty"`
+	Members   []replicaMember   `json:"members,omitempty"`
+	Messages  []json.RawMessage `json:"messages,omitempty"`
+}
+
+// canServeDelta reports whether a peer at sequence since can catch up to
+// latest from log entries starting at oldest (0 if the log is empty).
+func canServeDelta(since, oldest, latest int64) bool {
+	if since <= 0 || since > latest {
+		return false
+	}
+	if since == latest {
+		return true
+	}
+	return oldest > 0 && oldest <= since+1 && latest-since <= replicaDeltaLimit
+}
+
+// replicaCanServe reports whether a replica holding stored messages for a
+// page can answer a read of limit messages without asking the home instance.
+func replicaCanServe(stored int, complete bool, limit int) bool {
+	return complete || stored >= limit
+}
+
+// ============================================================
+// Home instance
+// ============================================================
+
+// queryReplicaMembers loads members of a local guild in replica form. An
+// empty userID loads the first replicaMemberLimit members by join date.
+func (ss *SyncService) queryReplicaMembers(ctx context.Context, guildID, userID string) ([]replicaMember, error) {
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT gm.user_id, u.username, u.display_name, u.avatar_id,
+		        COALESCE(i.domain, ''),
+		        COALESCE((SELECT array_agg(mr.role_id ORDER BY mr.role_id) FROM member_roles mr
+		                  WHERE mr.guild_id = gm.guild_id AND mr.user_id = gm.user_id), '{}'),
+		        gm.joined_at
+		 FROM guild_members gm
+		 JOIN users u ON u.id = gm.user_id
+		 LEFT JOIN instances i ON i.id = u.instance_id
+		 WHERE gm.guild_id = $1 AND ($2 = '' OR gm.user_id = $2)
+		 ORDER BY gm.joined_at ASC
+		 LIMIT $3`, guildID, userID, replicaMemberLimit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (replicaMember, error) {
+		var m replicaMember
+		err := row.Scan(&m.UserID, &m.Username, &m.DisplayName, &m.AvatarID,
+			&m.InstanceDomain, &m.RoleIDs, &m.JoinedAt)
+		return m, err
+	})
+}
+
+// appendReplicaLog records an outbound event of a local guild in the guild's
+// replica log and returns its sequence number, or 0 if the event is not
+// replicated. Events in private channels are not replicated, and nothing is
+// logged for guilds without members on other instances. Member events get
+// the member's replica row added under "member".
+func (ss *SyncService) appendReplicaLog(ctx context.Context, guildID, eventType, channelID string, data interface{}) uint64 {
+	if guildID == "" || !replicatedEventTypes[eventType] {
+		return 0
+	}
+	if channelID != "" {
+		var private bool
+		ss.fed.pool.QueryRow(ctx,
+			`SELECT COALESCE(channel_type = 'private', false) FROM channels WHERE id = $1`, channelID,
+		).Scan(&private)
+		if private {
+			return 0
+		}
+	}
+
+	if eventType == "GUILD_MEMBER_ADD" || eventType == "GUILD_MEMBER_UPDATE" {
+		if dataMap, ok := data.(map[string]interface{}); ok {
+			if userID, _ := dataMap["user_id"].(string); userID != "" {
+				if members, err := ss.queryReplicaMembers(ctx, guildID, userID); err == nil && len(members) == 1 {
+					dataMap["member"] = members[0]
+				}
+			}
+		}
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return 0
+	}
+	var seq int64
+	err = ss.fed.pool.QueryRow(ctx,
+		`WITH next AS (
+		     INSERT INTO guild_replica_seq (guild_id, seq)
+		     SELECT $1, 1 WHERE EXISTS(
+		         SELECT 1 FROM guild_members gm JOIN users u ON u.id = gm.user_id
+		         WHERE gm.guild_id = $1 AND u.instance_id <> $5)
+		     ON CONFLICT (guild_id) DO UPDATE SET seq = guild_replica_seq.seq + 1
+		     RETURNING seq
+		 )
+		 INSERT INTO guild_replica_log (guild_id, seq, event_type, channel_id, data)
+		 SELECT $1, seq, $2, NULLIF($3, ''), $4 FROM next
+		 RETURNING seq`,
+		guildID, eventType, channelID, payload, ss.fed.instanceID,
+	).Scan(&seq)
+	if err == pgx.ErrNoRows {
+		return 0
+	}
+	if err != nil {
+		ss.logger.Warn("failed to append guild replica log",
+			slog.String("guild_id", guildID), slog.String("type", eventType),
+			slog.String("error", err.Error()))
+		return 0
+	}
+	return uint64(seq)
+}
+
+// HandleGuildReplica returns what a peer needs to bring its replica of a
+// local guild up to date: the log entries after the peer's last sequence
+// number if the log still has them, otherwise a snapshot of the guild's
+// non-private channels, members and recent messages. The peer must have at
+// least one user in the guild.
+// POST /federation/v1/guilds/{guildID}/replica
+func (ss *SyncService) HandleGuildReplica(w http.ResponseWriter, r *http.Request) {
+	signed, senderID, ok := ss.verifyFederationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	guildID := chi.URLParam(r, "guildID")
+	if guildID == "" {
+		http.Error(w, "Missing guild ID", http.StatusBadRequest)
+		return
+	}
+
+	var req federatedReplicaRequest
+	if err := json.Unmarshal(signed.Payload, &req); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	var local, hasMembers bool
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM guilds WHERE id = $1 AND (instance_id IS NULL OR instance_id = $2)),
+		        EXISTS(SELECT 1 FROM guild_members gm JOIN users u ON u.id = gm.user_id
+		               WHERE gm.guild_id = $1 AND u.instance_id = $3)`,
+		guildID, ss.fed.instanceID, senderID,
+	).Scan(&local, &hasMembers); err != nil {
+		ss.logger.Error("failed to check guild replica access", slog.String("error", err.Error()))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	if !local {
+		http.Error(w, "Guild not found", http.StatusNotFound)
+		return
+	}
+	if !hasMembers {
+		http.Error(w, "No members of your instance in this guild", http.StatusForbidden)
+		return
+	}
+
+	state, err := ss.buildReplicaState(ctx, guildID, req.Since)
+	if err != nil {
+		ss.logger.Error("failed to build guild replica state",
+			slog.String("guild_id", guildID), slog.String("error", err.Error()))
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": state})
+}
+
+// buildReplicaState answers a replica request for a local guild. The
+// snapshot's sequence number is read before the snapshot itself, so events
+// racing with it are replayed by the peer rather than lost.
+func (ss *SyncService) buildReplicaState(ctx context.Context, guildID string, since int64) (*replicaState, error) {
+	var latest, oldest int64
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT COALESCE((SELECT seq FROM guild_replica_seq WHERE guild_id = $1), 0),
+		        COALESCE((SELECT min(seq) FROM guild_replica_log WHERE guild_id = $1), 0)`,
+		guildID,
+	).Scan(&latest, &oldest); err != nil {
+		return nil, fmt.Errorf("reading replica sequence: %w", err)
+	}
+
+	state := &replicaState{LatestSeq: latest}
+	if canServeDelta(since, oldest, latest) {
+		state.Mode = "delta"
+		rows, err := ss.fed.pool.Query(ctx,
+			`SELECT seq, event_type, COALESCE(channel_id, ''), data
+			 FROM guild_replica_log
+			 WHERE guild_id = $1 AND seq > $2 AND seq <= $3
+			 ORDER BY seq`, guildID, since, latest)
+		if err != nil {
+			return nil, fmt.Errorf("reading replica log: %w", err)
+		}
+		state.Events, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (replicaLogEntry, error) {
+			var e replicaLogEntry
+			err := row.Scan(&e.Seq, &e.Type, &e.ChannelID, &e.Data)
+			return e, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading replica log: %w", err)
+		}
+		return state, nil
+	}
+
+	state.Mode = "snapshot"
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT id, channel_type, name, topic, position, category_id, parent_channel_id, encrypted
+		 FROM channels
+		 WHERE guild_id = $1 AND channel_type <> 'private'
+		 ORDER BY position, id`, guildID)
+	if err != nil {
+		return nil, fmt.Errorf("reading channels: %w", err)
+	}
+	state.Channels, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (replicaChannel, error) {
+		var c replicaChannel
+		err := row.Scan(&c.ID, &c.ChannelType, &c.Name, &c.Topic, &c.Position,
+			&c.CategoryID, &c.ParentChannelID, &c.Encrypted)
+		return c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading channels: %w", err)
+	}
+
+	for i := range state.Channels {
+		messages, err := ss.queryFederatedMessages(ctx, state.Channels[i].ID, "", "", replicaMessageLimit)
+		if err != nil {
+			return nil, fmt.Errorf("reading messages: %w", err)
+		}
+		state.Channels[i].Complete = len(messages) < replicaMessageLimit
+		for _, m := range messages {
+			raw, err := json.Marshal(m)
+			if err != nil {
+				continue
+			}
+			state.Messages = append(state.Messages, raw)
+		}
+	}
+
+	if state.Members, err = ss.queryReplicaMembers(ctx, guildID, ""); err != nil {
+		return nil, fmt.Errorf("reading members: %w", err)
+	}
+	return state, nil
+}
+
+// ============================================================
+// Replicating instance
+// ============================================================
+
+// applyReplicaSeq handles a numbered event of a federated guild. The next
+// event in sequence is applied to the replica; an event after a gap marks
+// the replica stale and starts a resync. Guilds without a replica are
+// ignored.
+func (ss *SyncService) applyReplicaSeq(ctx context.Context, senderID, guildID string, seq uint64, eventType, channelID string, data json.RawMessage) {
+	var instanceID, status string
+	var lastSeq int64
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT instance_id, last_seq, status FROM federated_guild_replicas WHERE guild_id = $1`, guildID,
+	).Scan(&instanceID, &lastSeq, &status); err != nil || instanceID != senderID {
+		return
+	}
+	if int64(seq) <= lastSeq {
+		return // already applied
+	}
+
+	if status == replicaStatusReady && int64(seq) == lastSeq+1 {
+		tag, err := ss.fed.pool.Exec(ctx,
+			`UPDATE federated_guild_replicas SET last_seq = $2
+			 WHERE guild_id = $1 AND last_seq = $3 AND status = 'ready'`,
+			guildID, int64(seq), lastSeq)
+		if err == nil && tag.RowsAffected() == 1 {
+			ss.applyReplicaEvent(ctx, guildID, eventType, channelID, data)
+			return
+		}
+	}
+
+	ss.fed.pool.Exec(ctx,
+		`UPDATE federated_guild_replicas SET status = 'stale' WHERE guild_id = $1 AND status = 'ready'`, guildID)
+	go ss.resyncReplica(context.Background(), guildID)
+}
+
+// applyReplicaEvent applies one replicated event to the replica tables.
+// Channel and guild metadata live in the regular tables and are updated by
+// updateFederatedGuildFromEvent.
+func (ss *SyncService) applyReplicaEvent(ctx context.Context, guildID, eventType, channelID string, data json.RawMessage) {
+	var err error
+	switch eventType {
+	case "GUILD_MEMBER_ADD", "GUILD_MEMBER_UPDATE":
+		var payload struct {
+			Member *replicaMember `json:"member"`
+		}
+		if json.Unmarshal(data, &payload) != nil || payload.Member == nil {
+			return
+		}
+		err = ss.upsertReplicaMember(ctx, ss.fed.pool, guildID, *payload.Member)
+
+	case "GUILD_MEMBER_REMOVE":
+		var payload struct {
+			UserID string `json:"user_id"`
+		}
+		if json.Unmarshal(data, &payload) != nil || payload.UserID == "" {
+			return
+		}
+		_, err = ss.fed.pool.Exec(ctx,
+			`DELETE FROM federated_replica_members WHERE guild_id = $1 AND user_id = $2`,
+			guildID, payload.UserID)
+
+	case "CHANNEL_CREATE":
+		var ch struct {
+			ID          string `json:"id"`
+			ChannelType string `json:"channel_type"`
+		}
+		if json.Unmarshal(data, &ch) != nil || ch.ID == "" || ch.ChannelType == "category" {
+			return
+		}
+		// A new channel has no history, so the replica holds all of it.
+		_, err = ss.fed.pool.Exec(ctx,
+			`INSERT INTO federated_replica_channels (channel_id, guild_id, complete)
+			 VALUES ($1, $2, true) ON CONFLICT (channel_id) DO NOTHING`,
+			ch.ID, guildID)
+
+	case "CHANNEL_DELETE":
+		var ch struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(data, &ch) != nil || ch.ID == "" {
+			return
+		}
+		ss.fed.pool.Exec(ctx, `DELETE FROM federated_replica_messages WHERE channel_id = $1`, ch.ID)
+		_, err = ss.fed.pool.Exec(ctx, `DELETE FROM federated_replica_channels WHERE channel_id = $1`, ch.ID)
+
+	case "MESSAGE_CREATE":
+		var msg struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(data, &msg) != nil || msg.ID == "" || channelID == "" {
+			return
+		}
+		var tag pgconn.CommandTag
+		tag, err = ss.fed.pool.Exec(ctx,
+			`INSERT INTO federated_replica_messages (id, guild_id, channel_id, data)
+			 SELECT $1, $2, $3, $4 WHERE EXISTS(
+			     SELECT 1 FROM federated_replica_channels WHERE channel_id = $3 AND guild_id = $2)
+			 ON CONFLICT (id) DO NOTHING`,
+			msg.ID, guildID, channelID, []byte(data))
+		if err == nil && tag.RowsAffected() > 0 {
+			err = ss.trimReplicaMessages(ctx, channelID)
+		}
+
+	case "MESSAGE_UPDATE":
+		var msg struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(data, &msg) != nil || msg.ID == "" {
+			return
+		}
+		_, err = ss.fed.pool.Exec(ctx,
+			`UPDATE federated_replica_messages SET data = data || $2 WHERE id = $1 AND guild_id = $3`,
+			msg.ID, []byte(data), guildID)
+
+	case "MESSAGE_DELETE":
+		var msg struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(data, &msg) != nil || msg.ID == "" {
+			return
+		}
+		_, err = ss.fed.pool.Exec(ctx,
+			`DELETE FROM federated_replica_messages WHERE id = $1 AND guild_id = $2`, msg.ID, guildID)
+	}
+
+	if err != nil {
+		ss.logger.Warn("failed to apply guild replica event",
+			slog.String("guild_id", guildID), slog.String("type", eventType),
+			slog.String("error", err.Error()))
+	}
+}
+
+// replicaExecer is satisfied by both the pool and a transaction.
+type replicaExecer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// upsertReplicaMember stores or refreshes a member of a replicated guild.
+func (ss *SyncService) upsertReplicaMember(ctx context.Context, db replicaExecer, guildID string, m replicaMember) error {
+	if m.RoleIDs == nil {
+		m.RoleIDs = []string{}
+	}
+	_, err := db.Exec(ctx,
+		`INSERT INTO federated_replica_members
+		     (guild_id, user_id, username, display_name, avatar_id, instance_domain, role_ids, joined_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (guild_id, user_id) DO UPDATE SET
+		     username = EXCLUDED.username, display_name = EXCLUDED.display_name,
+		     avatar_id = EXCLUDED.avatar_id, instance_domain = EXCLUDED.instance_domain,
+		     role_ids = EXCLUDED.role_ids, joined_at = EXCLUDED.joined_at`,
+		guildID, m.UserID, m.Username, m.DisplayName, m.AvatarID, m.InstanceDomain, m.RoleIDs, m.JoinedAt)
+	return err
+}
+
+// trimReplicaMessages keeps only the newest replicaMessageLimit messages of
+// a channel. Once messages are dropped the replica no longer holds the whole
+// channel.
+func (ss *SyncService) trimReplicaMessages(ctx context.Context, channelID string) error {
+	tag, err := ss.fed.pool.Exec(ctx,
+		`DELETE FROM federated_replica_messages
+		 WHERE channel_id = $1 AND id <= (
+		     SELECT id FROM federated_replica_messages WHERE channel_id = $1
+		     ORDER BY id DESC OFFSET $2 LIMIT 1)`,
+		channelID, replicaMessageLimit)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		_, err = ss.fed.pool.Exec(ctx,
+			`UPDATE federated_replica_channels SET complete = false WHERE channel_id = $1`, channelID)
+	}
+	return err
+}
+
+// resyncReplica brings the replica of a federated guild up to date from its
+// home instance. At most one resync per guild runs at a time, and a failed
+// one is not retried for a minute.
+func (ss *SyncService) resyncReplica(ctx context.Context, guildID string) {
+	if _, busy := ss.replicaSyncs.Get(guildID); busy {
+		return
+	}
+	ss.replicaSyncs.Set(guildID, true)
+
+	if err := ss.syncReplica(ctx, guildID); err != nil {
+		ss.logger.Warn("failed to resync guild replica",
+			slog.String("guild_id", guildID), slog.String("error", err.Error()))
+		return
+	}
+	ss.replicaSyncs.Invalidate(guildID)
+}
+
+func (ss *SyncService) syncReplica(ctx context.Context, guildID string) error {
+	var instanceID, domain, status string
+	var lastSeq int64
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT r.instance_id, i.domain, r.last_seq, r.status
+		 FROM federated_guild_replicas r JOIN instances i ON i.id = r.instance_id
+		 WHERE r.guild_id = $1`, guildID,
+	).Scan(&instanceID, &domain, &lastSeq, &status); err != nil {
+		return fmt.Errorf("loading replica: %w", err)
+	}
+	since := lastSeq
+	if status == replicaStatusSyncing {
+		since = 0
+	}
+
+	remoteURL := fmt.Sprintf("https://%s/federation/v1/guilds/%s/replica", domain, guildID)
+	respBody, statusCode, err := ss.signAndPost(ctx, remoteURL, federatedReplicaRequest{Since: since})
+	if err != nil {
+		return err
+	}
+	if statusCode != http.StatusOK {
+		return fmt.Errorf("remote returned status %d", statusCode)
+	}
+	var resp struct {
+		Data replicaState `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("decoding replica state: %w", err)
+	}
+	state := resp.Data
+
+	switch state.Mode {
+	case "delta":
+		for _, e := range state.Events {
+			if e.Seq <= lastSeq {
+				continue
+			}
+			ss.updateFederatedGuildFromEvent(ctx, instanceID, e.Type, guildID, e.Data)
+			ss.applyReplicaEvent(ctx, guildID, e.Type, e.ChannelID, e.Data)
+		}
+	case "snapshot":
+		if err := ss.applyReplicaSnapshot(ctx, instanceID, guildID, &state); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown replica mode %q", state.Mode)
+	}
+
+	_, err = ss.fed.pool.Exec(ctx,
+		`UPDATE federated_guild_replicas SET last_seq = $2, status = 'ready', synced_at = now()
+		 WHERE guild_id = $1`, guildID, state.LatestSeq)
+	return err
+}
+
+// applyReplicaSnapshot replaces a replica with a snapshot from the home
+// instance. Snapshot channels are upserted into the regular channels table
+// like CHANNEL_CREATE events.
+func (ss *SyncService) applyReplicaSnapshot(ctx context.Context, instanceID, guildID string, state *replicaState) error {
+	for _, ch := range state.Channels {
+		if data, err := json.Marshal(ch); err == nil {
+			ss.updateFederatedGuildFromEvent(ctx, instanceID, "CHANNEL_CREATE", guildID, data)
+		}
+	}
+
+	tx, err := ss.fed.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning replica snapshot: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"federated_replica_messages", "federated_replica_members", "federated_replica_channels"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE guild_id = $1`, guildID); err != nil {
+			return fmt.Errorf("clearing %s: %w", table, err)
+		}
+	}
+	channels := make(map[string]bool, len(state.Channels))
+	for _, ch := range state.Channels {
+		channels[ch.ID] = true
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO federated_replica_channels (channel_id, guild_id, complete) VALUES ($1, $2, $3)
+			 ON CONFLICT (channel_id) DO UPDATE SET guild_id = EXCLUDED.guild_id, complete = EXCLUDED.complete`,
+			ch.ID, guildID, ch.Complete); err != nil {
+			return fmt.Errorf("storing replica channel: %w", err)
+		}
+	}
+	for _, m := range state.Members {
+		if err := ss.upsertReplicaMember(ctx, tx, guildID, m); err != nil {
+			return fmt.Errorf("storing replica member: %w", err)
+		}
+	}
+	for _, raw := range state.Messages {
+		var msg struct {
+			ID        string `json:"id"`
+			ChannelID string `json:"channel_id"`
+		}
+		if json.Unmarshal(raw, &msg) != nil || msg.ID == "" || !channels[msg.ChannelID] {
+			continue
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO federated_replica_messages (id, guild_id, channel_id, data) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data`,
+			msg.ID, guildID, msg.ChannelID, []byte(raw)); err != nil {
+			return fmt.Errorf("storing replica message: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// replicaReady reports whether reads of a federated guild can be served from
+// its replica. The first read of a guild creates the replica and a stale
+// replica is resynced; both are proxied until the sync completes.
+func (ss *SyncService) replicaReady(ctx context.Context, guildID, instanceID string) bool {
+	var status string
+	err := ss.fed.pool.QueryRow(ctx,
+		`SELECT status FROM federated_guild_replicas WHERE guild_id = $1`, guildID,
+	).Scan(&status)
+	if err == pgx.ErrNoRows {
+		tag, err := ss.fed.pool.Exec(ctx,
+			`INSERT INTO federated_guild_replicas (guild_id, instance_id) VALUES ($1, $2)
+			 ON CONFLICT (guild_id) DO NOTHING`, guildID, instanceID)
+		if err == nil && tag.RowsAffected() > 0 {
+			go ss.resyncReplica(context.Background(), guildID)
+		}
+		return false
+	}
+	if err != nil {
+		return false
+	}
+	if status != replicaStatusReady {
+		go ss.resyncReplica(context.Background(), guildID)
+		return false
+	}
+	return true
+}
+
+// serveReplicaMembers answers a member list read of a federated guild from
+// its replica. Returns false if the replica cannot answer it.
+func (ss *SyncService) serveReplicaMembers(w http.ResponseWriter, r *http.Request, guildID, instanceID, instanceDomain string) bool {
+	ctx := r.Context()
+	if !ss.replicaReady(ctx, guildID, instanceID) {
+		return false
+	}
+
+	// The home instance only shows members to members.
+	var isMember bool
+	ss.fed.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM guild_members WHERE guild_id = $1 AND user_id = $2)`,
+		guildID, auth.UserIDFromContext(ctx),
+	).Scan(&isMember)
+	if !isMember {
+		return false
+	}
+
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT user_id, username, display_name, avatar_id, instance_domain, role_ids, joined_at
+		 FROM federated_replica_members WHERE guild_id = $1
+		 ORDER BY joined_at ASC LIMIT 200`, guildID)
+	if err != nil {
+		return false
+	}
+	members, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (replicaMember, error) {
+		var m replicaMember
+		err := row.Scan(&m.UserID, &m.Username, &m.DisplayName, &m.AvatarID,
+			&m.InstanceDomain, &m.RoleIDs, &m.JoinedAt)
+		return m, err
+	})
+	if err != nil {
+		return false
+	}
+
+	ss.writeRemoteGuildMembers(ctx, w, guildID, instanceDomain, members)
+	return true
+}
+
+// serveReplicaMessages answers a message read of a federated guild channel
+// from its replica. Only reads the caller's mirrored permissions allow, and
+// that the replica holds enough messages for, are answered; returns false
+// otherwise.
+func (ss *SyncService) serveReplicaMessages(w http.ResponseWriter, r *http.Request, guildID, instanceID, instanceDomain, channelID, before string, limit int) bool {
+	ctx := r.Context()
+	if !ss.replicaReady(ctx, guildID, instanceID) {
+		return false
+	}
+
+	snap := ss.permissionSnapshot(ctx, instanceDomain, guildID, auth.UserIDFromContext(ctx))
+	if snap == nil || snap.Channels == nil || !snap.allows(channelID, permissions.ViewChannel|permissions.ReadHistory) {
+		return false
+	}
+
+	var complete bool
+	if err := ss.fed.pool.QueryRow(ctx,
+		`SELECT complete FROM federated_replica_channels WHERE channel_id = $1 AND guild_id = $2`,
+		channelID, guildID,
+	).Scan(&complete); err != nil {
+		return false
+	}
+
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT data FROM federated_replica_messages
+		 WHERE channel_id = $1 AND ($2 = '' OR id < $2)
+		 ORDER BY id DESC LIMIT $3`, channelID, before, limit)
+	if err != nil {
+		return false
+	}
+	messages, err := pgx.CollectRows(rows, pgx.RowTo[json.RawMessage])
+	if err != nil || !replicaCanServe(len(messages), complete, limit) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": messages})
+	return true
+}
@@ -0,0 +1,92 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// DryRunSetting is the instance_settings key that puts outbound federation
+// in dry-run mode: events are still generated and signed, but recorded in
+// federation_sandbox_events instead of being delivered. Deliveries already
+// queued for retry are unaffected.
+const DryRunSetting = "federation_dry_run"
+
+const (
+	sandboxOutbound = "outbound"
+	sandboxInbound  = "inbound"
+)
+
+// DryRun reports whether outbound federation is in dry-run mode.
+func (s *Service) DryRun(ctx context.Context) bool {
+	if dryRun, ok := s.sandboxCache.Get(DryRunSetting); ok {
+		return dryRun
+	}
+	var value string
+	s.pool.QueryRow(ctx,
+		`SELECT value FROM instance_settings WHERE key = $1`, DryRunSetting).Scan(&value)
+	dryRun := value == "true"
+	s.sandboxCache.Set(DryRunSetting, dryRun)
+	return dryRun
+}
+
+// IsSandboxPeer reports whether a peer is marked as a test peer whose events
+// go to the sandbox instead of being applied.
+func (s *Service) IsSandboxPeer(ctx context.Context, peerID string) bool {
+	if sandbox, ok := s.sandboxCache.Get(peerID); ok {
+		return sandbox
+	}
+	var sandbox bool
+	s.pool.QueryRow(ctx,
+		`SELECT sandbox FROM federation_peers WHERE instance_id = $1 AND peer_id = $2`,
+		s.instanceID, peerID).Scan(&sandbox)
+	s.sandboxCache.Set(peerID, sandbox)
+	return sandbox
+}
+
+// InvalidateSandboxCache forgets the cached dry-run setting and sandbox
+// flag of a peer. Called by the admin handlers when either changes; pass
+// DryRunSetting for the former.
+func (s *Service) InvalidateSandboxCache(key string) {
+	s.sandboxCache.Invalidate(key)
+}
+
+// recordSandboxEvent stores a federated event in the sandbox. payload is the
+// signed envelope for outbound events and the verified message for inbound
+// ones.
+func (ss *SyncService) recordSandboxEvent(ctx context.Context, direction, peerID, domain string, msg FederatedMessage, payload []byte) {
+	var peer, guildID, channelID *string
+	if peerID != "" {
+		peer = &peerID
+	}
+	if msg.GuildID != "" {
+		guildID = &msg.GuildID
+	}
+	if msg.ChannelID != "" {
+		channelID = &msg.ChannelID
+	}
+	if _, err := ss.fed.pool.Exec(ctx,
+		`INSERT INTO federation_sandbox_events (id, direction, peer_id, domain, event_type, guild_id, channel_id, payload)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		models.NewULID().String(), direction, peer, domain, msg.Type, guildID, channelID, payload); err != nil {
+		ss.logger.Warn("failed to record federation sandbox event",
+			slog.String("direction", direction), slog.String("type", msg.Type),
+			slog.String("error", err.Error()))
+	}
+}
+
+// recordDryRun logs and records an outbound delivery that dry-run mode held
+// back.
+func (ss *SyncService) recordDryRun(ctx context.Context, domain, peerID string, signed *SignedPayload) {
+	var msg FederatedMessage
+	json.Unmarshal(signed.Payload, &msg)
+	body, err := json.Marshal(signed)
+	if err != nil {
+		return
+	}
+	ss.logger.Info("federation dry run: event not delivered",
+		slog.String("domain", domain), slog.String("type", msg.Type))
+	ss.recordSandboxEvent(ctx, sandboxOutbound, peerID, domain, msg, body)
+}
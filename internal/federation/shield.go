@@ -0,0 +1,212 @@
+package federation
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Default inbound quotas applied to each federation peer. A zero value in
+// SyncConfig selects the default; a negative value disables the quota.
+const (
+	defaultPeerEventsPerMinute = 600
+	defaultPeerBytesPerMinute  = 10 << 20 // 10 MB
+	defaultPeerThrottle        = time.Minute
+
+	// maxThrottleDoublings caps the exponential throttle backoff for peers
+	// that keep exceeding their quota (2^5 × base).
+	maxThrottleDoublings = 5
+)
+
+// Reputation scoring. Peers start at maxReputation; each violation costs
+// points and a clean minute recovers one.
+const (
+	maxReputation           = 100
+	quotaViolationPenalty   = 10
+	invalidRequestPenalty   = 5
+	reputationRecoveryEvery = time.Minute
+)
+
+// peerShieldState tracks one peer's usage in the current one-minute window
+// together with its throttle and reputation state.
+type peerShieldState struct {
+	windowStart    time.Time
+	events         int
+	bytes          int64
+	violations     int64 // quota violations since process start
+	unflushed      int64 // violations not yet added to federation_peer_status
+	throttledUntil time.Time
+	reputation     int
+	lastPenaltyAt  time.Time
+}
+
+// peerShield enforces per-peer inbound event and byte quotas. State is kept
+// in memory and the reputation/throttle fields are periodically persisted to
+// federation_peer_status for the admin federation dashboard.
+type peerShield struct {
+	eventsPerMinute int
+	bytesPerMinute  int64
+	throttle        time.Duration
+
+	mu    sync.Mutex
+	peers map[string]*peerShieldState
+	dirty map[string]struct{}
+	now   func() time.Time
+}
+
+func newPeerShield(eventsPerMinute int, bytesPerMinute int64, throttle time.Duration) *peerShield {
+	if eventsPerMinute == 0 {
+		eventsPerMinute = defaultPeerEventsPerMinute
+	}
+	if bytesPerMinute == 0 {
+		bytesPerMinute = defaultPeerBytesPerMinute
+	}
+	if throttle <= 0 {
+		throttle = defaultPeerThrottle
+	}
+	return &peerShield{
+		eventsPerMinute: eventsPerMinute,
+		bytesPerMinute:  bytesPerMinute,
+		throttle:        throttle,
+		peers:           make(map[string]*peerShieldState),
+		dirty:           make(map[string]struct{}),
+		now:             time.Now,
+	}
+}
+
+// state returns the peer's state, creating it if needed, with reputation
+// recovery applied. Callers must hold ps.mu.
+func (ps *peerShield) state(peerID string, now time.Time) *peerShieldState {
+	st, ok := ps.peers[peerID]
+	if !ok {
+		st = &peerShieldState{windowStart: now, reputation: maxReputation, lastPenaltyAt: now}
+		ps.peers[peerID] = st
+		return st
+	}
+	if st.reputation < maxReputation {
+		if recovered := int(now.Sub(st.lastPenaltyAt) / reputationRecoveryEvery); recovered > 0 {
+			st.reputation = min(maxReputation, st.reputation+recovered)
+			st.lastPenaltyAt = st.lastPenaltyAt.Add(time.Duration(recovered) * reputationRecoveryEvery)
+			ps.dirty[peerID] = struct{}{}
+		}
+	}
+	return st
+}
+
+// penalize lowers a peer's reputation. Callers must hold ps.mu.
+func (ps *peerShield) penalize(peerID string, st *peerShieldState, points int, now time.Time) {
+	st.reputation = max(0, st.reputation-points)
+	st.lastPenaltyAt = now
+	ps.dirty[peerID] = struct{}{}
+}
+
+// admit records an inbound request of size bytes from peerID and reports
+// whether it is within quota. When it is not, the peer is throttled and the
+// returned duration tells the caller how long the peer should back off.
+func (ps *peerShield) admit(peerID string, size int) (bool, time.Duration) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	now := ps.now()
+	st := ps.state(peerID, now)
+
+	if now.Before(st.throttledUntil) {
+		return false, st.throttledUntil.Sub(now)
+	}
+
+	if now.Sub(st.windowStart) >= time.Minute {
+		st.windowStart = now
+		st.events = 0
+		st.bytes = 0
+	}
+	st.events++
+	st.bytes += int64(size)
+
+	overEvents := ps.eventsPerMinute > 0 && st.events > ps.eventsPerMinute
+	overBytes := ps.bytesPerMinute > 0 && st.bytes > ps.bytesPerMinute
+	if !overEvents && !overBytes {
+		return true, 0
+	}
+
+	// Repeat offenders are throttled for exponentially longer.
+	st.violations++
+	st.unflushed++
+	backoff := ps.throttle << min(st.violations-1, maxThrottleDoublings)
+	st.throttledUntil = now.Add(backoff)
+	st.windowStart = st.throttledUntil
+	st.events = 0
+	st.bytes = 0
+	ps.penalize(peerID, st, quotaViolationPenalty, now)
+	return false, backoff
+}
+
+// reportInvalid lowers a peer's reputation after a request that failed
+// signature, timestamp, or payload validation.
+func (ps *peerShield) reportInvalid(peerID string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	now := ps.now()
+	ps.penalize(peerID, ps.state(peerID, now), invalidRequestPenalty, now)
+}
+
+// peerShieldSnapshot is the persisted view of a peer's shield state.
+type peerShieldSnapshot struct {
+	peerID         string
+	reputation     int
+	newViolations  int64
+	throttledUntil *time.Time
+}
+
+// takeDirty returns snapshots of peers whose state changed since the last
+// call and clears the dirty set.
+func (ps *peerShield) takeDirty() []peerShieldSnapshot {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	now := ps.now()
+	snaps := make([]peerShieldSnapshot, 0, len(ps.dirty))
+	for peerID := range ps.dirty {
+		st := ps.state(peerID, now)
+		snap := peerShieldSnapshot{peerID: peerID, reputation: st.reputation, newViolations: st.unflushed}
+		st.unflushed = 0
+		if now.Before(st.throttledUntil) {
+			t := st.throttledUntil
+			snap.throttledUntil = &t
+		}
+		snaps = append(snaps, snap)
+	}
+	ps.dirty = make(map[string]struct{})
+	return snaps
+}
+
+// requeue restores a snapshot that failed to persist so that it is retried
+// on the next flush.
+func (ps *peerShield) requeue(snap peerShieldSnapshot) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if st, ok := ps.peers[snap.peerID]; ok {
+		st.unflushed += snap.newViolations
+	}
+	ps.dirty[snap.peerID] = struct{}{}
+}
+
+// flushShieldState persists changed peer reputation and throttle state to
+// federation_peer_status. Called from the timestamp flusher.
+func (ss *SyncService) flushShieldState(ctx context.Context) {
+	for _, snap := range ss.shield.takeDirty() {
+		_, err := ss.fed.pool.Exec(ctx,
+			`INSERT INTO federation_peer_status (peer_id, instance_id, reputation, quota_violations, throttled_until, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, now())
+			 ON CONFLICT (peer_id) DO UPDATE SET
+			   reputation = EXCLUDED.reputation,
+			   quota_violations = federation_peer_status.quota_violations + EXCLUDED.quota_violations,
+			   throttled_until = EXCLUDED.throttled_until,
+			   updated_at = now()`,
+			snap.peerID, ss.fed.instanceID, snap.reputation, snap.newViolations, snap.throttledUntil)
+		if err != nil {
+			ss.logger.Warn("shield flush: failed to update peer status",
+				slog.String("peer_id", snap.peerID), slog.String("error", err.Error()))
+			ss.shield.requeue(snap)
+		}
+	}
+}
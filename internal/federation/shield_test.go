@@ -0,0 +1,115 @@
+package federation
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestShield returns a shield with a controllable clock.
+func newTestShield(events int, bytes int64) (*peerShield, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ps := newPeerShield(events, bytes, time.Minute)
+	ps.now = func() time.Time { return now }
+	return ps, &now
+}
+
+func TestPeerShield_EventQuota(t *testing.T) {
+	ps, _ := newTestShield(3, -1)
+	for i := 0; i < 3; i++ {
+		if ok, _ := ps.admit("peer", 100); !ok {
+			t.Fatalf("request %d rejected, want admitted", i+1)
+		}
+	}
+	ok, retry := ps.admit("peer", 100)
+	if ok {
+		t.Fatal("4th request admitted, want throttled")
+	}
+	if retry != time.Minute {
+		t.Errorf("retry after = %v, want %v", retry, time.Minute)
+	}
+	if ok, _ := ps.admit("other", 100); !ok {
+		t.Error("other peer throttled by unrelated peer's quota")
+	}
+}
+
+func TestPeerShield_ByteQuota(t *testing.T) {
+	ps, _ := newTestShield(-1, 1000)
+	if ok, _ := ps.admit("peer", 600); !ok {
+		t.Fatal("first request rejected")
+	}
+	if ok, _ := ps.admit("peer", 600); ok {
+		t.Fatal("request over byte quota admitted")
+	}
+}
+
+func TestPeerShield_ThrottleBackoff(t *testing.T) {
+	ps, now := newTestShield(1, -1)
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		ps.admit("peer", 1)
+		_, retry := ps.admit("peer", 1)
+		if retry != w {
+			t.Errorf("violation %d: retry after = %v, want %v", i+1, retry, w)
+		}
+		// Still throttled until the backoff elapses.
+		*now = now.Add(retry - time.Second)
+		if ok, _ := ps.admit("peer", 1); ok {
+			t.Errorf("violation %d: admitted before throttle expired", i+1)
+		}
+		*now = now.Add(time.Second)
+	}
+}
+
+func TestPeerShield_Reputation(t *testing.T) {
+	ps, now := newTestShield(1, -1)
+	ps.admit("peer", 1)
+	ps.admit("peer", 1) // quota violation
+	ps.reportInvalid("peer")
+
+	snaps := ps.takeDirty()
+	if len(snaps) != 1 {
+		t.Fatalf("dirty snapshots = %d, want 1", len(snaps))
+	}
+	if got, want := snaps[0].reputation, maxReputation-quotaViolationPenalty-invalidRequestPenalty; got != want {
+		t.Errorf("reputation = %d, want %d", got, want)
+	}
+	if snaps[0].newViolations != 1 {
+		t.Errorf("new violations = %d, want 1", snaps[0].newViolations)
+	}
+	if snaps[0].throttledUntil == nil {
+		t.Error("throttledUntil = nil, want set")
+	}
+
+	// Three clean minutes recover three points.
+	*now = now.Add(3 * time.Minute)
+	ps.admit("peer", 1)
+	snaps = ps.takeDirty()
+	if len(snaps) != 1 {
+		t.Fatalf("dirty snapshots after recovery = %d, want 1", len(snaps))
+	}
+	if got, want := snaps[0].reputation, maxReputation-quotaViolationPenalty-invalidRequestPenalty+3; got != want {
+		t.Errorf("recovered reputation = %d, want %d", got, want)
+	}
+	if snaps[0].newViolations != 0 {
+		t.Errorf("new violations after flush = %d, want 0", snaps[0].newViolations)
+	}
+}
+
+func TestBackpressureDelay(t *testing.T) {
+	tests := []struct {
+		header  string
+		backoff time.Duration
+		want    time.Duration
+	}{
+		{"", 5 * time.Second, 5 * time.Second},
+		{"garbage", 5 * time.Second, 5 * time.Second},
+		{"120", 5 * time.Second, 2 * time.Minute},
+		{"1", 5 * time.Second, 5 * time.Second},
+		{"999999", 5 * time.Second, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backpressureDelay(tt.header, tt.backoff); got != tt.want {
+			t.Errorf("backpressureDelay(%q, %v) = %v, want %v", tt.header, tt.backoff, got, tt.want)
+		}
+	}
+}
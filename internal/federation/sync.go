@@ -7,7 +7,10 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +19,7 @@ import (
 
 	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/slo"
 )
 
 // unknownSenderTTL is how long a negative-cache entry lasts before the next DB lookup.
@@ -35,6 +39,7 @@ type FederatedMessage struct {
 	Timestamp HLCTimestamp `json:"timestamp"`              // HLC timestamp for causal ordering
 	GuildID   string       `json:"guild_id,omitempty"`
 	ChannelID string       `json:"channel_id,omitempty"`
+	Seq       uint64       `json:"seq,omitempty"`          // Position in the guild's replica log
 	Data      interface{}  `json:"data"`                   // Event payload
 }
 
@@ -71,7 +76,8 @@ type SyncService struct {
 	logger     *slog.Logger
 	client     *http.Client
 	voiceSvc   VoiceTokenGenerator // optional, for federated voice
-	liveKitURL string              // public LiveKit URL for this instance
+	mediaSrc   MediaSource         // optional, serves the signed media endpoint
+	paused     func() bool         // optional, reports an operator pause of outbound federation
 
 	// unknownCache is a negative cache for sender IDs that are not in the
 	// instances table. Prevents repeated DB queries from unknown senders.
@@ -85,6 +91,17 @@ type SyncService struct {
 	deliverySem        chan struct{} // global outbound delivery limiter
 	backfillWindowDays int          // configurable, default 7
 
+	// Per-peer inbound event/byte quotas and reputation scoring.
+	shield *peerShield
+
+	// Guild replicas with a resync running or recently failed.
+	replicaSyncs *TTLCache[bool]
+
+	// Inbound fan-out ordering: per-guild reorder buffer and the latest
+	// message fanned out per channel.
+	reorder        *reorderBuffer
+	latestMessages *TTLCache[orderKey] // channelID -> latest message
+
 	// Async timestamp tracking — flushed every 10s by StartTimestampFlusher.
 	touchMu          sync.Mutex
 	touchedInstances map[string]struct{}
@@ -95,6 +112,7 @@ type SyncService struct {
 type VoiceTokenGenerator interface {
 	GenerateToken(userID, channelID string, canPublish, canSubscribe, canVideo bool, metadata string) (string, error)
 	EnsureRoom(ctx context.Context, channelID string) error
+	RoomURL(ctx context.Context, channelID string) string
 }
 
 // SyncConfig holds tunable federation sync parameters.
@@ -102,6 +120,11 @@ type SyncConfig struct {
 	PeerInboxLimit      int
 	DeliveryConcurrency int
 	BackfillWindowDays  int
+
+	// Inbound quotas per peer. Zero selects the default, negative disables.
+	PeerEventsPerMinute int
+	PeerBytesPerMinute  int64
+	PeerThrottle        time.Duration // base throttle after exceeding a quota
 }
 
 // NewSyncService creates a new federation sync service.
@@ -118,7 +141,7 @@ func NewSyncService(fed *Service, bus *events.Bus, logger *slog.Logger, cfg Sync
 	if backfillDays < 1 {
 		backfillDays = 7
 	}
-	return &SyncService{
+	ss := &SyncService{
 		fed:                fed,
 		bus:                bus,
 		hlc:                NewHLC(),
@@ -128,9 +151,14 @@ func NewSyncService(fed *Service, bus *events.Bus, logger *slog.Logger, cfg Sync
 		peerInboxLimit:     peerLimit,
 		deliverySem:        make(chan struct{}, deliveryConcurrency),
 		backfillWindowDays: backfillDays,
+		shield:             newPeerShield(cfg.PeerEventsPerMinute, cfg.PeerBytesPerMinute, cfg.PeerThrottle),
+		replicaSyncs:       NewTTLCache[bool](time.Minute, 1000),
+		latestMessages:     NewTTLCache[orderKey](10*time.Minute, 10000),
 		touchedInstances:   make(map[string]struct{}),
 		touchedPeers:       make(map[[2]string]struct{}),
 	}
+	ss.reorder = newReorderBuffer(reorderWait, reorderBufferLimit, ss.publishInbound)
+	return ss
 }
 
 // isNegativelyCached returns true if the sender is in the negative cache and
@@ -213,7 +241,7 @@ func (ss *SyncService) TouchPeer(instanceID, peerID string) {
 }
 
 // StartTimestampFlusher starts a background goroutine that flushes accumulated
-// timestamp updates every 10 seconds. On context cancellation it performs a
+// timestamp updates and peer shield state every 10 seconds. On context cancellation it performs a
 // final flush before returning.
 func (ss *SyncService) StartTimestampFlusher(ctx context.Context) {
 	go func() {
@@ -223,8 +251,10 @@ func (ss *SyncService) StartTimestampFlusher(ctx context.Context) {
 			select {
 			case <-ticker.C:
 				ss.flushTimestamps(context.Background())
+				ss.flushShieldState(context.Background())
 			case <-ctx.Done():
 				ss.flushTimestamps(context.Background())
+				ss.flushShieldState(context.Background())
 				return
 			}
 		}
@@ -281,9 +311,15 @@ func (ss *SyncService) flushTimestamps(ctx context.Context) {
 }
 
 // SetVoiceService configures the voice service for federated voice token generation.
-func (ss *SyncService) SetVoiceService(voiceSvc VoiceTokenGenerator, liveKitPublicURL string) {
+func (ss *SyncService) SetVoiceService(voiceSvc VoiceTokenGenerator) {
 	ss.voiceSvc = voiceSvc
-	ss.liveKitURL = liveKitPublicURL
+}
+
+// SetOutboundPause wires a check for an operator pause of federation. While
+// it reports true, local events are not sent to peers; peers request what
+// they missed through backfill once deliveries succeed again.
+func (ss *SyncService) SetOutboundPause(paused func() bool) {
+	ss.paused = paused
 }
 
 // HandleInbox handles POST /federation/v1/inbox — receives signed messages from
@@ -318,6 +354,7 @@ func (ss *SyncService) HandleInbox(w http.ResponseWriter, r *http.Request) {
 			ss.cacheUnknownSender(signed.SenderID)
 			ss.logger.Warn("unknown sender, cached for 60s",
 				slog.String("sender_id", signed.SenderID))
+			ss.fed.recordRejection(r, signed.SenderID, RejectUnknownPeer, "")
 			http.Error(w, "Unknown sender instance", http.StatusForbidden)
 			return
 		}
@@ -342,15 +379,29 @@ func (ss *SyncService) HandleInbox(w http.ResponseWriter, r *http.Request) {
 	valid, err := VerifySignature(publicKeyPEM, signed.Payload, signed.Signature)
 	if err != nil || !valid {
 		ss.logger.Warn("invalid federation signature", slog.String("sender_id", signed.SenderID))
+		ss.fed.recordRejection(r, signed.SenderID, RejectBadSignature, signatureDetail(err))
 		http.Error(w, "Invalid signature", http.StatusForbidden)
 		return
 	}
 
+	// Per-peer event and byte quotas. Checked only after the signature is
+	// verified so a forged sender ID cannot exhaust a real peer's quota.
+	if ok, retryAfter := ss.shield.admit(signed.SenderID, len(body)); !ok {
+		ss.logger.Warn("inbox throttled: peer over quota",
+			slog.String("sender_id", signed.SenderID),
+			slog.Duration("retry_after", retryAfter))
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "Peer quota exceeded", http.StatusTooManyRequests)
+		return
+	}
+
 	// Check timestamp freshness.
-	if msg := validateTimestamp(signed.Timestamp); msg != "" {
+	if msg := ss.fed.validateTimestamp(signed.Timestamp); msg != "" {
 		ss.logger.Warn("inbox rejected: stale timestamp",
 			slog.String("sender_id", signed.SenderID),
 			slog.String("detail", msg))
+		ss.fed.recordRejection(r, signed.SenderID, RejectClockSkew, msg)
+		ss.shield.reportInvalid(signed.SenderID)
 		http.Error(w, "Stale or future timestamp", http.StatusBadRequest)
 		return
 	}
@@ -376,10 +427,19 @@ func (ss *SyncService) HandleInbox(w http.ResponseWriter, r *http.Request) {
 	// Decode the federated message.
 	var msg FederatedMessage
 	if err := json.Unmarshal(signed.Payload, &msg); err != nil {
+		ss.shield.reportInvalid(signed.SenderID)
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
 
+	// Events from test peers are recorded in the sandbox and go no further.
+	if ss.fed.IsSandboxPeer(r.Context(), signed.SenderID) {
+		ss.recordSandboxEvent(r.Context(), sandboxInbound, signed.SenderID, "", msg, signed.Payload)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "sandboxed"})
+		return
+	}
+
 	// If GuildID is empty, try to extract it from the data payload. The HOST
 	// message handler may only set ChannelID; without GuildID, the gateway's
 	// shouldDispatchTo cannot route the event to the correct clients.
@@ -400,7 +460,9 @@ func (ss *SyncService) HandleInbox(w http.ResponseWriter, r *http.Request) {
 	ss.TouchInstance(signed.SenderID)
 	ss.TouchPeer(ss.fed.instanceID, signed.SenderID)
 
-	// Persist inbound message events to the local database.
+	// Persist inbound message events to the local database. DM events are
+	// re-keyed to the local mirror channel so clients receive them.
+	localChannelID := msg.ChannelID
 	if msg.ChannelID != "" {
 		eventData, err := json.Marshal(msg.Data)
 		if err != nil {
@@ -410,7 +472,18 @@ func (ss *SyncService) HandleInbox(w http.ResponseWriter, r *http.Request) {
 				slog.String("error", err.Error()),
 			)
 		} else {
-			ss.persistInboundMessage(r.Context(), signed.SenderID, msg.Type, msg.GuildID, msg.ChannelID, eventData)
+			resolved, accepted := ss.persistInboundMessage(r.Context(), signed.SenderID, msg.Type, msg.GuildID, msg.ChannelID, eventData)
+			if !accepted {
+				ss.logger.Debug("dropped rejected federated event",
+					slog.String("type", msg.Type),
+					slog.String("sender", signed.SenderID))
+				w.WriteHeader(http.StatusAccepted)
+				json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+				return
+			}
+			if resolved != "" {
+				localChannelID = resolved
+			}
 		}
 	}
 
@@ -427,33 +500,38 @@ func (ss *SyncService) HandleInbox(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if localChannelID != msg.ChannelID {
+		eventData = setChannelID(eventData, localChannelID)
+	}
+
 	// For PRESENCE_UPDATE, extract user_id into the event envelope so the
-	// gateway's shouldDispatchTo can route it to shared-guild clients.
+	// gateway's shouldDispatchTo can route it to shared-guild clients. Users
+	// who opted in to DM presence are also routed to their local DM partners.
 	var eventUserID string
 	if msg.Type == "PRESENCE_UPDATE" {
 		var presPayload struct {
-			UserID string `json:"user_id"`
+			UserID  string `json:"user_id"`
+			ShareDM bool   `json:"share_dm"`
 		}
 		json.Unmarshal(eventData, &presPayload)
 		eventUserID = presPayload.UserID
+		if presPayload.ShareDM && eventUserID != "" {
+			eventData = addPresenceRecipients(eventData, ss.dmPresenceRecipients(r.Context(), signed.SenderID, eventUserID))
+		}
 	}
 
 	event := events.Event{
-		Type:      federationToGatewayType(msg.Type),
-		GuildID:   msg.GuildID,
-		ChannelID: msg.ChannelID,
-		UserID:    eventUserID,
-		Data:      eventData,
+		Type:             federationToGatewayType(msg.Type),
+		GuildID:          msg.GuildID,
+		ChannelID:        localChannelID,
+		UserID:           eventUserID,
+		Data:             eventData,
+		OriginInstanceID: signed.SenderID,
 	}
 
 	subject := eventTypeToSubject(msg.Type)
 	if subject != "" {
-		if err := ss.bus.Publish(r.Context(), subject, event); err != nil {
-			ss.logger.Error("failed to publish federated event",
-				slog.String("type", msg.Type),
-				slog.String("error", err.Error()),
-			)
-		}
+		ss.dispatchInbound(msg.GuildID, msg.Seq, subject, event)
 	}
 
 	// Update real tables for guild-level events from remote instances.
@@ -461,6 +539,11 @@ func (ss *SyncService) HandleInbox(w http.ResponseWriter, r *http.Request) {
 		ss.updateFederatedGuildFromEvent(r.Context(), signed.SenderID, msg.Type, msg.GuildID, eventData)
 	}
 
+	// Keep the local replica of the guild in step with its home instance.
+	if msg.Seq > 0 && msg.GuildID != "" {
+		ss.applyReplicaSeq(r.Context(), signed.SenderID, msg.GuildID, msg.Seq, msg.Type, localChannelID, eventData)
+	}
+
 	// Track inbound event count for the sender peer.
 	ss.fed.IncrementPeerEventCount(r.Context(), signed.SenderID, false)
 
@@ -477,7 +560,11 @@ func (ss *SyncService) HandleInbox(w http.ResponseWriter, r *http.Request) {
 // database. Guild channels are now stored in the real channels table with the
 // same IDs as the remote instance, so we check for direct existence first.
 // DM channels still use federation_dm_channel_map for ID mapping.
-func (ss *SyncService) persistInboundMessage(ctx context.Context, remoteInstanceID, eventType, guildID, remoteChannelID string, data json.RawMessage) {
+//
+// It returns the local channel ID the event applies to (empty if unknown) and
+// false if the event was rejected and must not be dispatched. In a DM mirror a
+// peer may only act for its own users.
+func (ss *SyncService) persistInboundMessage(ctx context.Context, remoteInstanceID, eventType, guildID, remoteChannelID string, data json.RawMessage) (string, bool) {
 	channelID := remoteChannelID // Start with the channel ID as-is
 
 	// Check if this channel exists directly (guild channels stored with real IDs).
@@ -489,10 +576,19 @@ func (ss *SyncService) persistInboundMessage(ctx context.Context, remoteInstance
 		ss.logger.Warn("failed to check channel existence",
 			slog.String("channel_id", channelID),
 			slog.String("error", err.Error()))
-		return
-	}
-
-	if !exists {
+		return "", true
+	}
+
+	dmMirror := !exists
+	if exists {
+		// Federated group DMs share the host's channel ID on every instance
+		// but are still DM mirrors.
+		ss.fed.pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM federation_dm_channel_map
+			 WHERE local_channel_id = $1 AND remote_instance_id = $2)`,
+			channelID, remoteInstanceID,
+		).Scan(&dmMirror)
+	} else {
 		// Fall back to DM mirror lookup.
 		err = ss.fed.pool.QueryRow(ctx,
 			`SELECT local_channel_id FROM federation_dm_channel_map
@@ -508,33 +604,44 @@ func (ss *SyncService) persistInboundMessage(ctx context.Context, remoteInstance
 				)
 			}
 			// Channel unknown — skip persistence.
-			return
+			return "", true
 		}
 	}
 
+	// MLS handshake messages for DMs go to the delivery service tables; they
+	// are neither dispatched to clients nor kept for backfill.
+	if eventType == "MLS_WELCOME" || eventType == "MLS_COMMIT" {
+		return channelID, ss.persistInboundMLS(ctx, remoteInstanceID, eventType, channelID, data)
+	}
+
 	switch eventType {
 	case "MESSAGE_CREATE":
 		var msgData struct {
-			ID          string          `json:"id"`
-			AuthorID    string          `json:"author_id"`
-			Content     string          `json:"content"`
-			Attachments json.RawMessage `json:"attachments,omitempty"`
-			Embeds      json.RawMessage `json:"embeds,omitempty"`
-			CreatedAt   *time.Time      `json:"created_at,omitempty"`
+			ID                  string          `json:"id"`
+			AuthorID            string          `json:"author_id"`
+			Content             string          `json:"content"`
+			Encrypted           bool            `json:"encrypted"`
+			EncryptionSessionID *string         `json:"encryption_session_id,omitempty"`
+			Attachments         json.RawMessage `json:"attachments,omitempty"`
+			Embeds              json.RawMessage `json:"embeds,omitempty"`
+			CreatedAt           *time.Time      `json:"created_at,omitempty"`
 		}
 		if err := json.Unmarshal(data, &msgData); err != nil {
 			ss.logger.Warn("failed to unmarshal inbound message", slog.String("error", err.Error()))
-			return
+			return channelID, false
+		}
+		if dmMirror && !ss.isInstanceUser(ctx, msgData.AuthorID, remoteInstanceID) {
+			return channelID, false
 		}
 		createdAt := time.Now().UTC()
 		if msgData.CreatedAt != nil {
 			createdAt = *msgData.CreatedAt
 		}
 		_, err := ss.fed.pool.Exec(ctx,
-			`INSERT INTO messages (id, channel_id, author_id, content, created_at)
-			 VALUES ($1, $2, $3, $4, $5)
+			`INSERT INTO messages (id, channel_id, author_id, content, encrypted, encryption_session_id, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)
 			 ON CONFLICT (id) DO NOTHING`,
-			msgData.ID, channelID, msgData.AuthorID, msgData.Content, createdAt)
+			msgData.ID, channelID, msgData.AuthorID, msgData.Content, msgData.Encrypted, msgData.EncryptionSessionID, createdAt)
 		if err != nil {
 			ss.logger.Warn("failed to persist inbound message",
 				slog.String("message_id", msgData.ID),
@@ -543,19 +650,26 @@ func (ss *SyncService) persistInboundMessage(ctx context.Context, remoteInstance
 
 	case "MESSAGE_UPDATE":
 		var msgData struct {
-			ID      string `json:"id"`
-			Content string `json:"content"`
+			ID                  string  `json:"id"`
+			Content             string  `json:"content"`
+			Encrypted           bool    `json:"encrypted"`
+			EncryptionSessionID *string `json:"encryption_session_id,omitempty"`
 		}
 		if err := json.Unmarshal(data, &msgData); err != nil {
 			ss.logger.Warn("failed to unmarshal inbound message update", slog.String("error", err.Error()))
-			return
+			return channelID, false
 		}
-		if _, err := ss.fed.pool.Exec(ctx,
-			`UPDATE messages SET content = $1, edited_at = now() WHERE id = $2 AND channel_id = $3`,
-			msgData.Content, msgData.ID, channelID); err != nil {
+		tag, err := ss.fed.pool.Exec(ctx,
+			`UPDATE messages SET content = $1, encrypted = $6, encryption_session_id = $7, edited_at = now()
+			 WHERE id = $2 AND channel_id = $3
+			   AND (NOT $4 OR author_id IN (SELECT id FROM users WHERE instance_id = $5))`,
+			msgData.Content, msgData.ID, channelID, dmMirror, remoteInstanceID, msgData.Encrypted, msgData.EncryptionSessionID)
+		if err != nil {
 			ss.logger.Warn("failed to persist inbound message update",
 				slog.String("message_id", msgData.ID),
 				slog.String("error", err.Error()))
+		} else if dmMirror && tag.RowsAffected() == 0 {
+			return channelID, false
 		}
 
 	case "MESSAGE_DELETE":
@@ -564,18 +678,48 @@ func (ss *SyncService) persistInboundMessage(ctx context.Context, remoteInstance
 		}
 		if err := json.Unmarshal(data, &msgData); err != nil {
 			ss.logger.Warn("failed to unmarshal inbound message delete", slog.String("error", err.Error()))
-			return
+			return channelID, false
 		}
-		if _, err := ss.fed.pool.Exec(ctx,
-			`DELETE FROM messages WHERE id = $1 AND channel_id = $2`,
-			msgData.ID, channelID); err != nil {
+		tag, err := ss.fed.pool.Exec(ctx,
+			`DELETE FROM messages
+			 WHERE id = $1 AND channel_id = $2
+			   AND (NOT $3 OR author_id IN (SELECT id FROM users WHERE instance_id = $4))`,
+			msgData.ID, channelID, dmMirror, remoteInstanceID)
+		if err != nil {
 			ss.logger.Warn("failed to persist inbound message delete",
 				slog.String("message_id", msgData.ID),
 				slog.String("error", err.Error()))
+		} else if dmMirror && tag.RowsAffected() == 0 {
+			return channelID, false
 		}
 
 	case "TYPING_START":
 		// No DB persistence needed — just let the event flow through NATS to the gateway.
+		if dmMirror {
+			var typing struct {
+				UserID string `json:"user_id"`
+			}
+			if json.Unmarshal(data, &typing) != nil || !ss.isInstanceUser(ctx, typing.UserID, remoteInstanceID) {
+				return channelID, false
+			}
+		}
+
+	case "CHANNEL_UPDATE":
+		// Federated group DM metadata and membership only change through
+		// snapshots from the host (HandleFederatedGroupDMState).
+		if dmMirror {
+			if _, ok := ss.groupDMHost(ctx, channelID); ok {
+				return channelID, false
+			}
+			ss.applyDMEncryption(ctx, channelID, data)
+		}
+
+	case "CHANNEL_ACK":
+		// Read receipts are only federated for DMs and are not backfilled.
+		if !dmMirror {
+			return channelID, false
+		}
+		return channelID, ss.persistInboundReadAck(ctx, remoteInstanceID, channelID, data)
 
 	case "REACTION_ADD":
 		var rxData struct {
@@ -585,7 +729,10 @@ func (ss *SyncService) persistInboundMessage(ctx context.Context, remoteInstance
 		}
 		if err := json.Unmarshal(data, &rxData); err != nil {
 			ss.logger.Warn("failed to unmarshal inbound reaction add", slog.String("error", err.Error()))
-			return
+			return channelID, false
+		}
+		if dmMirror && !ss.isInstanceUser(ctx, rxData.UserID, remoteInstanceID) {
+			return channelID, false
 		}
 		if _, err := ss.fed.pool.Exec(ctx,
 			`INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
@@ -604,7 +751,10 @@ func (ss *SyncService) persistInboundMessage(ctx context.Context, remoteInstance
 		}
 		if err := json.Unmarshal(data, &rxData); err != nil {
 			ss.logger.Warn("failed to unmarshal inbound reaction remove", slog.String("error", err.Error()))
-			return
+			return channelID, false
+		}
+		if dmMirror && !ss.isInstanceUser(ctx, rxData.UserID, remoteInstanceID) {
+			return channelID, false
 		}
 		if _, err := ss.fed.pool.Exec(ctx,
 			`DELETE FROM message_reactions WHERE message_id = $1 AND user_id = $2 AND emoji = $3`,
@@ -637,6 +787,7 @@ func (ss *SyncService) persistInboundMessage(ctx context.Context, remoteInstance
 		ss.logger.Warn("failed to store federation event for backfill",
 			slog.String("event_type", eventType), slog.String("instance_id", remoteInstanceID), slog.String("error", err.Error()))
 	}
+	return channelID, true
 }
 
 // persistInboundPresence updates the local user stub's presence when a
@@ -780,6 +931,11 @@ func (ss *SyncService) DeliverToChannelPeers(ctx context.Context, msg FederatedM
 
 // deliverToPeer sends a signed payload to a specific peer instance.
 func (ss *SyncService) deliverToPeer(ctx context.Context, domain, peerID string, signed *SignedPayload) {
+	if ss.fed.DryRun(ctx) {
+		ss.recordDryRun(ctx, domain, peerID, signed)
+		return
+	}
+
 	url := fmt.Sprintf("https://%s/federation/v1/inbox", domain)
 
 	body, err := json.Marshal(signed)
@@ -803,6 +959,7 @@ func (ss *SyncService) deliverToPeer(ctx context.Context, domain, peerID string,
 			slog.String("error", err.Error()),
 		)
 		ss.fed.IncrementPeerErrors(ctx, peerID)
+		slo.ObserveSince(ctx, slo.FederationDelivery, false)
 		ss.queueForRetry(domain, peerID, signed, 0)
 		return
 	}
@@ -814,13 +971,17 @@ func (ss *SyncService) deliverToPeer(ctx context.Context, domain, peerID string,
 			slog.Int("status", resp.StatusCode),
 		)
 		ss.fed.IncrementPeerErrors(ctx, peerID)
-		if resp.StatusCode >= 500 {
+		slo.ObserveSince(ctx, slo.FederationDelivery, false)
+		// 429 is backpressure from the peer's inbound quota; retry later
+		// with the usual backoff rather than dropping the event.
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
 			ss.queueForRetry(domain, peerID, signed, 0)
 		}
 		return
 	}
 
 	// Delivery succeeded — update health tracking.
+	slo.ObserveSince(ctx, slo.FederationDelivery, true)
 	ss.fed.IncrementPeerEventCount(ctx, peerID, true)
 	ss.fed.UpdatePeerHealth(ctx, peerID, true, 0)
 
@@ -876,7 +1037,9 @@ func (ss *SyncService) StartRouter(ctx context.Context) {
 		events.SubjectTypingStart,
 		events.SubjectVoiceStateUpdate,
 		events.SubjectCallRing,
+		events.SubjectCallUpdate,
 		events.SubjectPresenceUpdate,
+		events.SubjectChannelAck,
 	}
 
 	for _, subject := range subjects {
@@ -897,7 +1060,7 @@ func (ss *SyncService) StartRouter(ctx context.Context) {
 func (ss *SyncService) startRetryConsumer(ctx context.Context) {
 	js := ss.bus.JetStream()
 
-	sub, err := js.QueueSubscribe(events.SubjectFederationRetry, "federation-retry", func(natsMsg *nats.Msg) {
+	handler := func(natsMsg *nats.Msg) {
 		var evt events.Event
 		if err := json.Unmarshal(natsMsg.Data, &evt); err != nil {
 			ss.logger.Error("failed to unmarshal retry event", slog.String("error", err.Error()))
@@ -990,13 +1153,25 @@ func (ss *SyncService) startRetryConsumer(ctx context.Context) {
 			natsMsg.NakWithDelay(retryDelay(attempt))
 			return
 		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			natsMsg.NakWithDelay(backpressureDelay(resp.Header.Get("Retry-After"), retryDelay(attempt)))
+			return
+		}
 
 		// 4xx — permanent failure, dead letter it.
 		retry.Attempts = attempt
 		ss.insertDeadLetter(ctx, retry)
 		natsMsg.Ack()
-	}, nats.Durable("federation-retry-consumer"), nats.ManualAck(),
-		nats.AckWait(30*time.Second), nats.MaxDeliver(maxRetryAttempts+5))
+	}
+
+	// Registered with the bus so the consumer can be recreated after stream
+	// changes or replayed from the admin API.
+	sub, err := ss.bus.SubscribeDurable(events.StreamFederation, "federation-retry-consumer",
+		func(opts ...nats.SubOpt) (*nats.Subscription, error) {
+			return js.QueueSubscribe(events.SubjectFederationRetry, "federation-retry", handler,
+				append([]nats.SubOpt{nats.Durable("federation-retry-consumer"), nats.ManualAck(),
+					nats.AckWait(30 * time.Second), nats.MaxDeliver(maxRetryAttempts + 5)}, opts...)...)
+		})
 	if err != nil {
 		ss.logger.Error("failed to subscribe to federation retry queue", slog.String("error", err.Error()))
 		return
@@ -1060,6 +1235,30 @@ func retryDelay(attempt int) time.Duration {
 // the appropriate peers. If the event has a ChannelID, it uses targeted delivery
 // to only reach instances with members in that channel.
 func (ss *SyncService) routeEvent(ctx context.Context, event events.Event) {
+	// Events that arrived from a peer are never sent back out.
+	if event.OriginInstanceID != "" {
+		return
+	}
+	if ss.paused != nil && ss.paused() {
+		return
+	}
+	// Carry a traced send's start through to delivery so each peer's
+	// acceptance is measured against it.
+	if event.TracedAt != 0 {
+		ctx = slo.WithStart(ctx, time.Unix(0, event.TracedAt))
+	}
+	if event.Type == "CHANNEL_ACK" {
+		ss.routeReadAck(ctx, event)
+		return
+	}
+	if event.Type == "CHANNELS_ACK" {
+		ss.routeBulkReadAck(ctx, event)
+		return
+	}
+	if event.Type == "CHANNEL_UPDATE" && event.ChannelID != "" && ss.routeGroupDMUpdate(ctx, event.ChannelID) {
+		return
+	}
+
 	var data interface{}
 	if err := json.Unmarshal(event.Data, &data); err != nil {
 		return
@@ -1129,13 +1328,22 @@ func (ss *SyncService) routeEvent(ctx context.Context, event events.Event) {
 				slog.String("error", err.Error()))
 			return // Fail-closed
 		}
-		if len(guildIDs) == 0 {
-			return // No guilds = no one to forward to
+		dmPeers := ss.dmPresencePeers(ctx, event.UserID)
+		if len(guildIDs) == 0 && len(dmPeers) == 0 {
+			return // No guilds or opted-in DMs = no one to forward to
 		}
 		if dataMap, ok := data.(map[string]interface{}); ok {
 			dataMap["guild_ids"] = guildIDs
+			if len(dmPeers) > 0 {
+				dataMap["share_dm"] = true
+			}
 			data = dataMap
 		}
+		if len(guildIDs) == 0 {
+			// Only DM partners need this update; don't broadcast it.
+			ss.deliverToPeers(ctx, FederatedMessage{Type: event.Type, Data: data}, dmPeers)
+			return
+		}
 	}
 
 	// Stamp instance_id on attachments so remote instances can route through
@@ -1156,6 +1364,7 @@ func (ss *SyncService) routeEvent(ctx context.Context, event events.Event) {
 		Type:      event.Type,
 		GuildID:   guildID,
 		ChannelID: event.ChannelID,
+		Seq:       ss.appendReplicaLog(ctx, guildID, event.Type, event.ChannelID, data),
 		Data:      data,
 	}
 
@@ -1181,6 +1390,9 @@ func federationToGatewayType(fedType string) string {
 		return "MESSAGE_REACTION_ADD"
 	case "REACTION_REMOVE":
 		return "MESSAGE_REACTION_REMOVE"
+	case "CHANNEL_ACK":
+		// A remote participant's read position, not the local user's own ack.
+		return "READ_RECEIPT"
 	default:
 		return fedType
 	}
@@ -1206,11 +1418,24 @@ func eventTypeToSubject(eventType string) string {
 		"TYPING_START":         events.SubjectTypingStart,
 		"VOICE_STATE_UPDATE":   events.SubjectVoiceStateUpdate,
 		"CALL_RING":            events.SubjectCallRing,
+		"CALL_UPDATE":          events.SubjectCallUpdate,
 		"PRESENCE_UPDATE":      events.SubjectPresenceUpdate,
+		"CHANNEL_ACK":          events.SubjectChannelAck,
 	}
 	return mapping[eventType]
 }
 
+// backpressureDelay returns how long to wait before retrying a peer that
+// answered 429. The peer's Retry-After (in seconds) is honored when it is
+// longer than the normal backoff, capped at one hour.
+func backpressureDelay(retryAfter string, backoff time.Duration) time.Duration {
+	secs, err := strconv.Atoi(strings.TrimSpace(retryAfter))
+	if err != nil || secs <= 0 {
+		return backoff
+	}
+	return max(backoff, min(time.Duration(secs)*time.Second, time.Hour))
+}
+
 // RetryDelay is exported for testing.
 func RetryDelay(attempt int) time.Duration {
 	return retryDelay(attempt)
@@ -1272,6 +1497,7 @@ func (ss *SyncService) HandleSync(w http.ResponseWriter, r *http.Request) {
 		if err == pgx.ErrNoRows {
 			ss.cacheUnknownSender(signed.SenderID)
 			ss.logger.Warn("sync: unknown sender, cached for 60s", slog.String("sender_id", signed.SenderID))
+			ss.fed.recordRejection(r, signed.SenderID, RejectUnknownPeer, "")
 			http.Error(w, "Unknown sender instance", http.StatusForbidden)
 			return
 		}
@@ -1287,15 +1513,17 @@ func (ss *SyncService) HandleSync(w http.ResponseWriter, r *http.Request) {
 	valid, err := VerifySignature(publicKeyPEM, signed.Payload, signed.Signature)
 	if err != nil || !valid {
 		ss.logger.Warn("sync: invalid federation signature", slog.String("sender_id", signed.SenderID))
+		ss.fed.recordRejection(r, signed.SenderID, RejectBadSignature, signatureDetail(err))
 		http.Error(w, "Invalid signature", http.StatusForbidden)
 		return
 	}
 
 	// Check timestamp freshness.
-	if msg := validateTimestamp(signed.Timestamp); msg != "" {
+	if msg := ss.fed.validateTimestamp(signed.Timestamp); msg != "" {
 		ss.logger.Warn("sync rejected: stale timestamp",
 			slog.String("sender_id", signed.SenderID),
 			slog.String("detail", msg))
+		ss.fed.recordRejection(r, signed.SenderID, RejectClockSkew, msg)
 		http.Error(w, "Stale or future timestamp", http.StatusBadRequest)
 		return
 	}
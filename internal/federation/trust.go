@@ -0,0 +1,223 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// PeerTrustLevel returns this instance's trust level for a peer. Instances
+// without a peering row are treated as normal.
+func (s *Service) PeerTrustLevel(ctx context.Context, peerID string) string {
+	if level, ok := s.trustCache.Get(peerID); ok {
+		return level
+	}
+
+	level := models.PeerTrustNormal
+	var stored string
+	if err := s.pool.QueryRow(ctx,
+		`SELECT trust_level FROM federation_peers WHERE instance_id = $1 AND peer_id = $2`,
+		s.instanceID, peerID).Scan(&stored); err == nil {
+		level = stored
+	}
+	s.trustCache.Set(peerID, level)
+	return level
+}
+
+// InvalidateTrustCache forgets the cached trust level of a peer. Called by
+// the admin handler when a peer's trust level changes.
+func (s *Service) InvalidateTrustCache(peerID string) {
+	s.trustCache.Invalidate(peerID)
+}
+
+// dmAlreadyMirrored reports whether a DM create request refers to a
+// conversation that already exists locally, either as a mirror of the same
+// remote channel or as a 1:1 DM between the same pair.
+func (ss *SyncService) dmAlreadyMirrored(ctx context.Context, senderID string, req federatedDMCreateRequest) bool {
+	var exists bool
+	ss.fed.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM federation_dm_channel_map
+		               WHERE remote_channel_id = $1 AND remote_instance_id = $2)
+		     OR ($3 = 'dm' AND EXISTS(
+		         SELECT 1 FROM channels c
+		         JOIN channel_recipients cr1 ON c.id = cr1.channel_id AND cr1.user_id = $4
+		         JOIN channel_recipients cr2 ON c.id = cr2.channel_id AND cr2.user_id = $5
+		         WHERE c.channel_type = 'dm'))`,
+		req.ChannelID, senderID, req.ChannelType, req.Creator.ID, req.RecipientIDs[0],
+	).Scan(&exists)
+	return exists
+}
+
+// queueDMRequest stores a DM create request from a restricted peer for each
+// local recipient and tells them about it. The signed payload is kept so the
+// mirror can be created unchanged once a recipient accepts.
+func (ss *SyncService) queueDMRequest(ctx context.Context, senderID string, req federatedDMCreateRequest, payload json.RawMessage) error {
+	rows, err := ss.fed.pool.Query(ctx,
+		`SELECT id FROM users WHERE id = ANY($1) AND instance_id = $2`,
+		req.RecipientIDs, ss.fed.instanceID)
+	if err != nil {
+		return fmt.Errorf("loading local recipients: %w", err)
+	}
+	recipients, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("loading local recipients: %w", err)
+	}
+
+	for _, recipientID := range recipients {
+		requestID := models.NewULID().String()
+		tag, err := ss.fed.pool.Exec(ctx,
+			`INSERT INTO federation_dm_requests (id, remote_instance_id, remote_channel_id, requester_id, recipient_id, payload)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (remote_instance_id, remote_channel_id, recipient_id) DO NOTHING`,
+			requestID, senderID, req.ChannelID, req.Creator.ID, recipientID, []byte(payload))
+		if err != nil {
+			return fmt.Errorf("storing DM request: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+		ss.bus.PublishUserEvent(ctx, events.SubjectFederatedDMRequest, "FEDERATED_DM_REQUEST_CREATE", recipientID, map[string]interface{}{
+			"id":                 requestID,
+			"remote_instance_id": senderID,
+			"requester_id":       req.Creator.ID,
+			"channel_type":       req.ChannelType,
+		})
+	}
+	return nil
+}
+
+// HandleListDMRequests returns the DMs from users on restricted peers that
+// wait for the caller to accept them, newest first.
+// GET /api/v1/federation/dm-requests
+func (ss *SyncService) HandleListDMRequests(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := ss.fed.pool.Query(r.Context(),
+		`SELECT dr.id, dr.remote_instance_id, i.domain, dr.requester_id, dr.payload->>'channel_type', dr.created_at,
+		        u.id, u.instance_id, u.username, u.display_name, u.avatar_id
+		 FROM federation_dm_requests dr
+		 JOIN instances i ON i.id = dr.remote_instance_id
+		 JOIN users u ON u.id = dr.requester_id
+		 WHERE dr.recipient_id = $1
+		 ORDER BY dr.created_at DESC
+		 LIMIT 100`, userID)
+	if err != nil {
+		http.Error(w, "Failed to query DM requests", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	requests := make([]models.FederatedDMRequest, 0)
+	for rows.Next() {
+		var dr models.FederatedDMRequest
+		var u models.User
+		if err := rows.Scan(&dr.ID, &dr.RemoteInstanceID, &dr.InstanceDomain, &dr.RequesterID,
+			&dr.ChannelType, &dr.CreatedAt,
+			&u.ID, &u.InstanceID, &u.Username, &u.DisplayName, &u.AvatarID); err != nil {
+			http.Error(w, "Failed to read DM requests", http.StatusInternalServerError)
+			return
+		}
+		dr.Requester = &u
+		requests = append(requests, dr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": requests})
+}
+
+// HandleAcceptDMRequest accepts a pending DM from a user on a restricted
+// peer: the local mirror channel is created as if the peer were not
+// restricted, and the peer is told the local channel ID so messages flow
+// both ways.
+// POST /api/v1/federation/dm-requests/{requestID}/accept
+func (ss *SyncService) HandleAcceptDMRequest(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx := r.Context()
+	requestID := chi.URLParam(r, "requestID")
+
+	var senderID, domain, remoteChannelID string
+	var payload []byte
+	err := ss.fed.pool.QueryRow(ctx,
+		`SELECT dr.remote_instance_id, i.domain, dr.remote_channel_id, dr.payload
+		 FROM federation_dm_requests dr
+		 JOIN instances i ON i.id = dr.remote_instance_id
+		 WHERE dr.id = $1 AND dr.recipient_id = $2`, requestID, userID,
+	).Scan(&senderID, &domain, &remoteChannelID, &payload)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "DM request not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load DM request", http.StatusInternalServerError)
+		return
+	}
+
+	var req federatedDMCreateRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		http.Error(w, "Stored DM request is invalid", http.StatusInternalServerError)
+		return
+	}
+
+	localChannelID, status, err := ss.mirrorFederatedDM(ctx, senderID, req)
+	if err != nil {
+		http.Error(w, "Failed to create DM channel", http.StatusInternalServerError)
+		return
+	}
+	ss.fed.pool.Exec(ctx,
+		`DELETE FROM federation_dm_requests WHERE remote_instance_id = $1 AND remote_channel_id = $2`,
+		senderID, remoteChannelID)
+
+	if status == http.StatusCreated {
+		go func() {
+			if err := ss.NotifyFederatedDM(context.Background(), domain, localChannelID, "dm",
+				userID, []string{req.Creator.ID}, nil); err != nil {
+				ss.logger.Warn("failed to notify peer of accepted DM request",
+					slog.String("domain", domain), slog.String("error", err.Error()))
+			}
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"channel_id": localChannelID}})
+}
+
+// HandleDeclineDMRequest drops a pending DM from a user on a restricted
+// peer. The sender is not told.
+// DELETE /api/v1/federation/dm-requests/{requestID}
+func (ss *SyncService) HandleDeclineDMRequest(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tag, err := ss.fed.pool.Exec(r.Context(),
+		`DELETE FROM federation_dm_requests WHERE id = $1 AND recipient_id = $2`,
+		chi.URLParam(r, "requestID"), userID)
+	if err != nil {
+		http.Error(w, "Failed to decline DM request", http.StatusInternalServerError)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		http.Error(w, "DM request not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
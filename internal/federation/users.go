@@ -13,6 +13,7 @@ import (
 	"github.com/jackc/pgx/v5"
 
 	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
 )
 
 // profileCacheTTL is how long a remote user profile is considered fresh before
@@ -33,6 +34,10 @@ type userProfileResponse struct {
 	Pronouns       *string `json:"pronouns,omitempty"`
 	Flags          int     `json:"flags"`
 	InstanceID     *string `json:"instance_id,omitempty"`
+
+	// Shared only if the user allows it; see profile_privacy.go.
+	Connections []profileConnection `json:"connections,omitempty"`
+	Activity    *profileActivity    `json:"activity,omitempty"`
 }
 
 // profileFetchTime tracks when each remote user's profile was last fetched.
@@ -62,15 +67,18 @@ func (ss *SyncService) HandleUserProfile(w http.ResponseWriter, r *http.Request)
 	// Query the local user — must belong to this instance (instance_id IS NULL for local users).
 	var profile userProfileResponse
 	var instanceID *string
+	var activity profileActivity
 	err := ss.fed.pool.QueryRow(ctx,
 		`SELECT id, instance_id, username, display_name, avatar_id, bio,
-		        status_text, status_emoji, banner_id, accent_color, pronouns, flags
+		        status_text, status_emoji, banner_id, accent_color, pronouns, flags,
+		        activity_type, activity_name
 		 FROM users WHERE id = $1`,
 		userID,
 	).Scan(
 		&profile.ID, &instanceID, &profile.Username, &profile.DisplayName,
 		&profile.AvatarID, &profile.Bio, &profile.StatusText, &profile.StatusEmoji,
 		&profile.BannerID, &profile.AccentColor, &profile.Pronouns, &profile.Flags,
+		&activity.Type, &activity.Name,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -90,6 +98,16 @@ func (ss *SyncService) HandleUserProfile(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Only the fields the user shares with remote instances leave.
+	privacy := LoadProfilePrivacy(ctx, ss.fed.pool, userID)
+	if privacy.Connections == models.ProfileVisibilityEveryone {
+		profile.Connections = loadProfileConnections(ctx, ss.fed.pool, userID)
+	}
+	if activity.Type != nil || activity.Name != nil {
+		profile.Activity = &activity
+	}
+	applyProfilePrivacy(&profile, privacy)
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{"data": profile}); err != nil {
 		ss.logger.Error("federation user profile: failed to encode response",
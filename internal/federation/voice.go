@@ -181,7 +181,7 @@ func (ss *SyncService) HandleFederatedVoiceToken(w http.ResponseWriter, r *http.
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"token":      token,
-		"url":        ss.liveKitURL,
+		"url":        ss.voiceSvc.RoomURL(ctx, req.ChannelID),
 		"channel_id": req.ChannelID,
 	})
 }
@@ -478,7 +478,7 @@ func (ss *SyncService) handleVoiceRelay(
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"data": map[string]interface{}{
 			"token":        token,
-			"url":          ss.liveKitURL,
+			"url":          ss.voiceSvc.RoomURL(ctx, relayRoomID),
 			"channel_id":   channelID,
 			"relay":        true,
 			"remote_token": remoteToken,
@@ -0,0 +1,99 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// loadBlocks records the users the client has blocked at the "block" level.
+// Their messages reach the client as placeholders; ignored users' messages
+// are delivered in full and collapsed by the client.
+func (s *Server) loadBlocks(ctx context.Context, client *Client) {
+	if s.pool == nil {
+		return
+	}
+	rows, err := s.pool.Query(ctx,
+		`SELECT target_id FROM user_blocks WHERE user_id = $1 AND level = $2`,
+		client.userID, models.BlockLevelBlock)
+	if err != nil {
+		s.logger.Error("failed to load blocks", slog.String("error", err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.blockedIDs == nil {
+		client.blockedIDs = make(map[string]bool)
+	}
+	for rows.Next() {
+		var targetID string
+		if rows.Scan(&targetID) == nil {
+			client.blockedIDs[targetID] = true
+		}
+	}
+}
+
+// setBlocked adds targetID to, or removes it from, the blocked users of all
+// of userID's connected clients.
+func (s *Server) setBlocked(userID, targetID string, blocked bool) {
+	s.userClientsMu.RLock()
+	defer s.userClientsMu.RUnlock()
+	for c := range s.userClients[userID] {
+		c.mu.Lock()
+		if blocked {
+			if c.blockedIDs == nil {
+				c.blockedIDs = make(map[string]bool)
+			}
+			c.blockedIDs[targetID] = true
+		} else {
+			delete(c.blockedIDs, targetID)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// hidesBlockedAuthor reports whether a message event is by a user the
+// client has blocked and must reach it as a placeholder.
+func (s *Server) hidesBlockedAuthor(client *Client, event events.Event) bool {
+	if event.Type != "MESSAGE_CREATE" && event.Type != "MESSAGE_UPDATE" && event.Type != "MESSAGE_TRANSLATION" {
+		return false
+	}
+	client.mu.Lock()
+	none := len(client.blockedIDs) == 0
+	client.mu.Unlock()
+	if none {
+		return false
+	}
+
+	var m struct {
+		AuthorID string `json:"author_id"`
+	}
+	if json.Unmarshal(event.Data, &m) != nil || m.AuthorID == "" {
+		return false
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.blockedIDs[m.AuthorID]
+}
+
+// blockedMessage returns the placeholder for a message payload: the payload
+// without its content fields or content warning, marked blocked. It matches
+// what models.Message.RedactBlocked produces.
+func blockedMessage(data json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(redactMessage(data), &fields) != nil {
+		return json.RawMessage(`{"blocked":true}`)
+	}
+	delete(fields, "content_warning")
+	fields["blocked"] = json.RawMessage(`true`)
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return json.RawMessage(`{"blocked":true}`)
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// Bots get presence for the users of their guilds like anyone else. A bot
+// that needs presence of users it shares no guild with, such as a status
+// board, names them instead, and each user consents before their updates
+// reach it.
+
+// loadBotPresenceTargets records the users who consented to the bot client
+// receiving their presence.
+func (s *Server) loadBotPresenceTargets(ctx context.Context, client *Client) {
+	client.mu.Lock()
+	isBot := client.isBot
+	client.mu.Unlock()
+	if s.pool == nil || !isBot {
+		return
+	}
+	rows, err := s.pool.Query(ctx,
+		`SELECT user_id FROM bot_presence_subscriptions WHERE bot_id = $1 AND status = 'granted'`,
+		client.userID)
+	if err != nil {
+		s.logger.Error("failed to load bot presence targets", slog.String("error", err.Error()))
+		return
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		s.logger.Error("failed to load bot presence targets", slog.String("error", err.Error()))
+		return
+	}
+	client.mu.Lock()
+	client.presenceUsers = toSet(ids)
+	client.mu.Unlock()
+}
+
+// handleBotPresenceTargetsEvent applies a granted or withdrawn consent to
+// the bot's open sessions, so it takes effect without a reconnect.
+func (s *Server) handleBotPresenceTargetsEvent(subject string, event events.Event) {
+	if subject != events.SubjectBotCapabilities || event.Type != "BOT_PRESENCE_TARGETS_UPDATE" || event.UserID == "" {
+		return
+	}
+	var data struct {
+		UserIDs []string `json:"user_ids"`
+	}
+	if json.Unmarshal(event.Data, &data) != nil {
+		return
+	}
+	s.userClientsMu.RLock()
+	defer s.userClientsMu.RUnlock()
+	for c := range s.userClients[event.UserID] {
+		c.mu.Lock()
+		c.presenceUsers = toSet(data.UserIDs)
+		c.mu.Unlock()
+	}
+}
+
+// toSet returns ids as a set.
+func toSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
@@ -0,0 +1,41 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/coder/websocket"
+)
+
+// CloseUpgradeRequired is the close code sent to clients older than the
+// instance's minimum supported version, after the UPGRADE_REQUIRED dispatch.
+// Clients should not reconnect until they have been updated.
+const CloseUpgradeRequired websocket.StatusCode = 4010
+
+// UpgradeRequiredPayload is the data of the UPGRADE_REQUIRED dispatch.
+type UpgradeRequiredPayload struct {
+	ClientVersion    string `json:"client_version"`
+	MinClientVersion string `json:"min_client_version"`
+	UpgradeURL       string `json:"upgrade_url,omitempty"`
+}
+
+// upgradeRequiredError rejects an IDENTIFY or RESUME from an outdated client.
+type upgradeRequiredError struct {
+	version string
+}
+
+func (e *upgradeRequiredError) Error() string {
+	return fmt.Sprintf("client version %s is no longer supported", e.version)
+}
+
+// sendUpgradeRequired tells a client it must update before it can connect.
+func (s *Server) sendUpgradeRequired(client *Client, version string) {
+	data, _ := json.Marshal(UpgradeRequiredPayload{
+		ClientVersion:    version,
+		MinClientVersion: s.clientVersions.MinVersion,
+		UpgradeURL:       s.clientVersions.UpgradeURL,
+	})
+	s.sendMessage(client, GatewayMessage{Op: OpDispatch, Type: "UPGRADE_REQUIRED", Data: data})
+	s.logger.Debug("rejected outdated client", slog.String("client_version", version))
+}
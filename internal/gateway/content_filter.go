@@ -0,0 +1,55 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// showsNSFW reports whether the user opted in to NSFW content. Users who
+// never did, and lookups that fail, do not see NSFW channels.
+func (s *Server) showsNSFW(ctx context.Context, userID string) bool {
+	if s.pool == nil {
+		return false
+	}
+	var show bool
+	s.pool.QueryRow(ctx,
+		`SELECT show_nsfw FROM user_content_settings WHERE user_id = $1`, userID).Scan(&show)
+	return show
+}
+
+// withoutNSFWChannels drops NSFW channels from a JSON channel list as built
+// by buildFederatedChannelsJSON. The list is returned unchanged if it cannot
+// be read.
+func withoutNSFWChannels(channels json.RawMessage) json.RawMessage {
+	var list []map[string]json.RawMessage
+	if err := json.Unmarshal(channels, &list); err != nil {
+		return channels
+	}
+	kept := make([]map[string]json.RawMessage, 0, len(list))
+	for _, ch := range list {
+		if string(ch["nsfw"]) != "true" {
+			kept = append(kept, ch)
+		}
+	}
+	if len(kept) == len(list) {
+		return channels
+	}
+	data, _ := json.Marshal(kept)
+	return data
+}
+
+// guildStateWithoutNSFW returns a cached guild state with its NSFW channels
+// dropped. The shared cache entry is not modified.
+func guildStateWithoutNSFW(state json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(state, &fields); err != nil {
+		return state
+	}
+	channels, ok := fields["channels"]
+	if !ok {
+		return state
+	}
+	fields["channels"] = withoutNSFWChannels(channels)
+	data, _ := json.Marshal(fields)
+	return data
+}
@@ -9,21 +9,31 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coder/websocket"
 	"github.com/coder/websocket/wsjson"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/config"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/featureflags"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
 	"github.com/amityvox/amityvox/internal/presence"
+	"github.com/amityvox/amityvox/internal/slo"
 	"github.com/amityvox/amityvox/internal/voice"
 )
 
@@ -41,6 +51,7 @@ const (
 	OpSubscribe         = 9
 	OpHello             = 10
 	OpHeartbeatAck      = 11
+	OpGuildSync         = 12
 )
 
 // GatewayMessage is the wire format for all WebSocket messages.
@@ -53,27 +64,97 @@ type GatewayMessage struct {
 
 // IdentifyPayload is the data sent by clients in op:2 IDENTIFY.
 type IdentifyPayload struct {
-	Token string `json:"token"`
+	Token  string              `json:"token"`
+	Filter *SubscriptionFilter `json:"filter,omitempty"`
+	// LazyGuilds requests a slim READY with guild summaries only; full guild
+	// state is then pulled per guild with op:12 GUILD_SYNC.
+	LazyGuilds bool `json:"lazy_guilds,omitempty"`
+	// ClientVersion is the client build, checked against the instance's
+	// minimum supported version.
+	ClientVersion string `json:"client_version,omitempty"`
 }
 
 // ResumePayload is the data sent by clients in op:5 RESUME.
 type ResumePayload struct {
-	Token     string `json:"token"`
-	SessionID string `json:"session_id"`
-	Seq       int64  `json:"seq"`
+	Token      string              `json:"token"`
+	SessionID  string              `json:"session_id"`
+	Seq        int64               `json:"seq"`
+	Filter     *SubscriptionFilter `json:"filter,omitempty"`
+	LazyGuilds    bool                `json:"lazy_guilds,omitempty"`
+	ClientVersion string              `json:"client_version,omitempty"`
 }
 
-// RequestMembersPayload is sent by clients in op:7 REQUEST_MEMBERS.
+// SubscriptionFilter narrows the events dispatched to a connection. It is
+// sent in IDENTIFY (or RESUME) and can be replaced later with op:9 SUBSCRIBE.
+// Each non-empty list is an allow-list: guild_ids limits guild-scoped events
+// (including messages in guild channels), channel_ids limits channel-scoped
+// events, and events limits dispatch types. DM and user-targeted events are
+// only affected by channel_ids and events.
+type SubscriptionFilter struct {
+	GuildIDs   []string `json:"guild_ids,omitempty"`
+	ChannelIDs []string `json:"channel_ids,omitempty"`
+	Events     []string `json:"events,omitempty"`
+}
+
+// maxFilterEntries caps each list in a SubscriptionFilter.
+const maxFilterEntries = 1000
+
+// eventFilter is the compiled form of a SubscriptionFilter. A nil set means
+// "no restriction" for that dimension.
+type eventFilter struct {
+	guilds   map[string]bool
+	channels map[string]bool
+	types    map[string]bool
+}
+
+// compileFilter validates a SubscriptionFilter and builds its lookup sets.
+// An empty filter compiles to nil, which matches everything.
+func compileFilter(f *SubscriptionFilter) (*eventFilter, error) {
+	if f == nil {
+		return nil, nil
+	}
+	if len(f.GuildIDs) > maxFilterEntries || len(f.ChannelIDs) > maxFilterEntries || len(f.Events) > maxFilterEntries {
+		return nil, fmt.Errorf("subscription filter lists are limited to %d entries", maxFilterEntries)
+	}
+	toSet := func(list []string) map[string]bool {
+		if len(list) == 0 {
+			return nil
+		}
+		set := make(map[string]bool, len(list))
+		for _, v := range list {
+			set[v] = true
+		}
+		return set
+	}
+	ef := &eventFilter{
+		guilds:   toSet(f.GuildIDs),
+		channels: toSet(f.ChannelIDs),
+		types:    toSet(f.Events),
+	}
+	if ef.guilds == nil && ef.channels == nil && ef.types == nil {
+		return nil, nil
+	}
+	return ef, nil
+}
+
+// RequestMembersPayload is sent by clients in op:7 REQUEST_GUILD_MEMBERS.
+// With neither query nor user_ids, all members (up to limit, if set) are
+// streamed as a series of GUILD_MEMBERS_CHUNK dispatches.
 type RequestMembersPayload struct {
-	GuildID string `json:"guild_id"`
-	Query   string `json:"query"`
-	Limit   int    `json:"limit"`
+	GuildID   string   `json:"guild_id"`
+	Query     string   `json:"query"`
+	Limit     int      `json:"limit"`
+	UserIDs   []string `json:"user_ids,omitempty"`
+	Presences bool     `json:"presences,omitempty"` // include live presences per chunk
+	Nonce     string   `json:"nonce,omitempty"`     // echoed in every chunk
 }
 
 // HelloPayload is the data sent in op:10 HELLO.
 type HelloPayload struct {
 	HeartbeatInterval int64  `json:"heartbeat_interval"`
 	BuildVersion      string `json:"build_version"`
+	MinClientVersion  string `json:"min_client_version,omitempty"`
+	UpgradeURL        string `json:"upgrade_url,omitempty"`
 }
 
 // Client represents a single connected WebSocket client.
@@ -85,18 +166,30 @@ type Client struct {
 	identified     bool
 	statusPresence string          // user's saved status (online/idle/busy/invisible)
 	guildIDs       map[string]bool // guilds this user is a member of
+	filter         *eventFilter    // subscription filter, nil = everything
+	lazyGuilds     bool            // slim READY; guild state via GUILD_SYNC
 	friendIDs      map[string]bool // accepted friends for presence dispatch
+	blockedIDs     map[string]bool // users blocked at the "block" level; see blocks.go
+	isBot          bool            // bot account; see message_content.go
+	messageContent bool            // bot may receive message content
+	presenceUsers  map[string]bool // users who let this bot see their presence; see bot_presence.go
 	mu             sync.Mutex
 	done           chan struct{}
 	replayBuf      []GatewayMessage // buffer for resume replay
 	lastHeartbeat  time.Time        // tracks when last heartbeat was received
+	rtt            time.Duration    // last ping round trip; see latency.go
 	cancelRead     context.CancelFunc
+
+	requestingMembers atomic.Bool // a REQUEST_GUILD_MEMBERS stream is in flight
+	measuringRTT      atomic.Bool // a latency ping is in flight
 }
 
 // channelGuildEntry caches the result of a channel→guild lookup.
 type channelGuildEntry struct {
-	guildID *string // nil means DM/group channel (no guild)
-	expires time.Time
+	guildID   *string // nil means DM/group channel (no guild)
+	voiceRoom bool    // voice or stage channel, whose chat only participants see
+	preview   bool    // listed locked to members who cannot view it
+	expires   time.Time
 }
 
 // Server manages WebSocket connections and event dispatch.
@@ -106,11 +199,13 @@ type Server struct {
 	cache             *presence.Cache
 	pool              *pgxpool.Pool
 	voice             *voice.Service
+	featureFlags      *featureflags.Store
 	heartbeatInterval time.Duration
 	heartbeatTimeout  time.Duration
 	listenAddr        string
 	buildVersion      string
 	localInstanceID   string // local instance ID for distinguishing federated guilds
+	clientVersions    config.ClientsConfig
 	logger            *slog.Logger
 
 	clients   map[*Client]struct{}
@@ -124,6 +219,26 @@ type Server struct {
 	// Entries expire after 60 seconds to avoid stale data after channel moves.
 	channelGuildCache sync.Map
 
+	// guildStateCache maps guildID → assembled GUILD_SYNC state. Entries are
+	// dropped when the guild, its channels or roles change.
+	guildStateCache sync.Map
+
+	// shards partition event fan-out by guild; see shards.go.
+	shards        []*dispatchShard
+	shardsMu      sync.RWMutex
+	shardCount    int
+	maxShardCount int
+	cancelReshard context.CancelFunc
+
+	// traffic tracks per-guild dispatch load; see guild_traffic.go.
+	traffic        sync.Map // guild ID → *guildTraffic
+	trafficSwept   atomic.Int64
+	connectedTotal atomic.Int64
+
+	// region labels this node's heartbeat latency; see latency.go.
+	region  string
+	latency *latencyHistogram
+
 	httpServer     *http.Server
 	originPatterns []string
 }
@@ -140,16 +255,39 @@ type ServerConfig struct {
 	ListenAddr        string
 	BuildVersion      string
 	LocalInstanceID   string // local instance ID for distinguishing federated guilds
+	Clients           config.ClientsConfig // minimum supported client version
 	Logger            *slog.Logger
 	CORSOrigins       []string // Allowed WebSocket origin patterns; empty = allow all.
+	DispatchShards    int      // Initial event fan-out shards; 0 = GOMAXPROCS.
+	MaxDispatchShards int      // Upper bound for automatic resharding; 0 = fixed.
+	Region            string   // Deployment region reported with latency metrics.
+	FeatureFlags      *featureflags.Store // Flags sent in READY; nil sends none.
+}
+
+// originHostPatterns converts CORS origins such as https://chat.example.com
+// and https://*.example.com to the host patterns the websocket library
+// matches origins against.
+func originHostPatterns(origins []string) []string {
+	hosts := make([]string, 0, len(origins))
+	for _, o := range origins {
+		if _, host, ok := strings.Cut(o, "://"); ok {
+			o = host
+		}
+		hosts = append(hosts, o)
+	}
+	return hosts
 }
 
 // NewServer creates a new WebSocket gateway server.
 func NewServer(cfg ServerConfig) *Server {
-	origins := cfg.CORSOrigins
+	origins := originHostPatterns(cfg.CORSOrigins)
 	if len(origins) == 0 {
 		origins = []string{"*"}
 	}
+	shardCount := cfg.DispatchShards
+	if shardCount <= 0 {
+		shardCount = runtime.GOMAXPROCS(0)
+	}
 
 	return &Server{
 		authService:       cfg.AuthService,
@@ -157,15 +295,21 @@ func NewServer(cfg ServerConfig) *Server {
 		cache:             cfg.Cache,
 		pool:              cfg.Pool,
 		voice:             cfg.Voice,
+		featureFlags:      cfg.FeatureFlags,
 		heartbeatInterval: cfg.HeartbeatInterval,
 		heartbeatTimeout:  cfg.HeartbeatTimeout,
 		listenAddr:        cfg.ListenAddr,
 		buildVersion:      cfg.BuildVersion,
 		localInstanceID:   cfg.LocalInstanceID,
+		clientVersions:    cfg.Clients,
 		logger:            cfg.Logger,
 		clients:           make(map[*Client]struct{}),
 		userClients:       make(map[string]map[*Client]struct{}),
 		originPatterns:    origins,
+		shardCount:        shardCount,
+		maxShardCount:     cfg.MaxDispatchShards,
+		region:            cfg.Region,
+		latency:           newLatencyHistogram(),
 	}
 }
 
@@ -180,10 +324,15 @@ func (s *Server) Start() error {
 		Handler: mux,
 	}
 
-	// Subscribe to all events for gateway dispatch.
-	_, err := s.eventBus.SubscribeWildcard("amityvox.>", func(subject string, event events.Event) {
-		s.dispatchEvent(subject, event)
-	})
+	// Subscribe to all events for gateway dispatch. Fan-out runs on the
+	// dispatch shards so one large guild does not hold up the others.
+	s.startShards(s.shardCount)
+	if s.maxShardCount > s.shardCount {
+		var reshardCtx context.Context
+		reshardCtx, s.cancelReshard = context.WithCancel(context.Background())
+		go s.autoReshard(reshardCtx, s.maxShardCount)
+	}
+	_, err := s.eventBus.SubscribeWildcard("amityvox.>", s.enqueueEvent)
 	if err != nil {
 		return fmt.Errorf("subscribing to events: %w", err)
 	}
@@ -205,6 +354,11 @@ func (s *Server) Start() error {
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("WebSocket gateway shutting down")
 
+	if s.cancelReshard != nil {
+		s.cancelReshard()
+	}
+	s.stopShards()
+
 	s.clientsMu.RLock()
 	for client := range s.clients {
 		s.sendReconnect(client)
@@ -230,13 +384,16 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		conn:      conn,
 		done:      make(chan struct{}),
 		guildIDs:  make(map[string]bool),
-		friendIDs: make(map[string]bool),
+		friendIDs:  make(map[string]bool),
+		blockedIDs: make(map[string]bool),
 	}
 
 	// Send HELLO with heartbeat interval and build version.
 	helloData, _ := json.Marshal(HelloPayload{
 		HeartbeatInterval: s.heartbeatInterval.Milliseconds(),
 		BuildVersion:      s.buildVersion,
+		MinClientVersion:  s.clientVersions.MinVersion,
+		UpgradeURL:        s.clientVersions.UpgradeURL,
 	})
 	s.sendMessage(client, GatewayMessage{
 		Op:   OpHello,
@@ -248,6 +405,12 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	defer identifyCancel()
 
 	if err := s.waitForIdentify(identifyCtx, client); err != nil {
+		var outdated *upgradeRequiredError
+		if errors.As(err, &outdated) {
+			s.sendUpgradeRequired(client, outdated.version)
+			conn.Close(CloseUpgradeRequired, "client version no longer supported")
+			return
+		}
 		s.logger.Debug("client failed to identify", slog.String("error", err.Error()))
 		conn.Close(websocket.StatusPolicyViolation, "identify timeout or invalid token")
 		return
@@ -321,6 +484,7 @@ func (s *Server) registerClient(client *Client) {
 	s.clientsMu.Lock()
 	s.clients[client] = struct{}{}
 	s.clientsMu.Unlock()
+	s.connectedTotal.Add(1)
 
 	s.userClientsMu.Lock()
 	if s.userClients[client.userID] == nil {
@@ -437,24 +601,31 @@ func (s *Server) waitForIdentify(ctx context.Context, client *Client) error {
 	// Accept either IDENTIFY (op 2) or RESUME (op 5). After a server restart
 	// session state is lost, so Resume is treated as a fresh Identify — the
 	// client will receive a new READY and re-sync.
-	var token string
+	var token, clientVersion string
+	var filter *SubscriptionFilter
 	switch msg.Op {
 	case OpIdentify:
 		var payload IdentifyPayload
 		if err := json.Unmarshal(msg.Data, &payload); err != nil {
 			return fmt.Errorf("parsing identify payload: %w", err)
 		}
-		token = payload.Token
+		token, filter, clientVersion = payload.Token, payload.Filter, payload.ClientVersion
+		client.lazyGuilds = payload.LazyGuilds
 	case OpResume:
 		var payload ResumePayload
 		if err := json.Unmarshal(msg.Data, &payload); err != nil {
 			return fmt.Errorf("parsing resume payload: %w", err)
 		}
-		token = payload.Token
+		token, filter, clientVersion = payload.Token, payload.Filter, payload.ClientVersion
+		client.lazyGuilds = payload.LazyGuilds
 	default:
 		return fmt.Errorf("expected op %d (IDENTIFY) or %d (RESUME), got %d", OpIdentify, OpResume, msg.Op)
 	}
 
+	if s.clientVersions.Outdated(clientVersion) {
+		return &upgradeRequiredError{version: clientVersion}
+	}
+
 	if token == "" {
 		return fmt.Errorf("empty token in identify/resume payload")
 	}
@@ -464,6 +635,11 @@ func (s *Server) waitForIdentify(ctx context.Context, client *Client) error {
 		return fmt.Errorf("invalid session token: %w", err)
 	}
 
+	client.filter, err = compileFilter(filter)
+	if err != nil {
+		return err
+	}
+
 	client.userID = userID
 	client.sessionID = generateWSSessionID()
 	client.identified = true
@@ -474,6 +650,13 @@ func (s *Server) waitForIdentify(ctx context.Context, client *Client) error {
 	// Load friendships for presence dispatch to friends outside shared guilds.
 	s.loadFriendships(ctx, client)
 
+	// Messages from blocked users are delivered as placeholders.
+	s.loadBlocks(ctx, client)
+
+	// Bots without message content access get message events redacted.
+	s.loadBotCapabilities(ctx, client)
+	s.loadBotPresenceTargets(ctx, client)
+
 	// Send READY dispatch.
 	user, err := s.authService.GetUser(ctx, userID)
 	if err != nil {
@@ -488,9 +671,18 @@ func (s *Server) waitForIdentify(ctx context.Context, client *Client) error {
 	// Include guild IDs in READY payload.
 	guildIDList := make([]string, 0, len(client.guildIDs))
 	for gid := range client.guildIDs {
+		if client.filter != nil && client.filter.guilds != nil && !client.filter.guilds[gid] {
+			continue // outside the subscription filter
+		}
 		guildIDList = append(guildIDList, gid)
 	}
 
+	if client.lazyGuilds {
+		s.sendLazyReady(ctx, client, user, guildIDList)
+		s.sendReadStateSummary(ctx, client)
+		return nil
+	}
+
 	// Collect all guild member user IDs for bulk presence lookup.
 	memberUserIDs := make([]string, 0)
 	if s.pool != nil {
@@ -538,6 +730,7 @@ func (s *Server) waitForIdentify(ctx context.Context, client *Client) error {
 					presences[uid] = status
 				}
 			}
+			s.resolveInvisible(ctx, client, presences)
 		}
 	}
 
@@ -594,6 +787,7 @@ func (s *Server) waitForIdentify(ctx context.Context, client *Client) error {
 	// Federated guilds are those where g.instance_id differs from the local instance.
 	federatedGuilds := make([]map[string]interface{}, 0)
 	if s.pool != nil && s.localInstanceID != "" {
+		showNSFW := s.showsNSFW(ctx, userID)
 		fgRows, err := s.pool.Query(ctx,
 			`SELECT g.id, g.name, g.icon_id, g.description, g.member_count, i.domain
 			 FROM guild_members gm
@@ -610,6 +804,9 @@ func (s *Server) waitForIdentify(ctx context.Context, client *Client) error {
 					&memberCount, &domain) == nil {
 					// Build channels_json and roles_json from real tables.
 					channelsJSON := s.buildFederatedChannelsJSON(ctx, guildID)
+					if !showNSFW {
+						channelsJSON = withoutNSFWChannels(channelsJSON)
+					}
 					rolesJSON := s.buildFederatedRolesJSON(ctx, guildID)
 					federatedGuilds = append(federatedGuilds, map[string]interface{}{
 						"guild_id":        guildID,
@@ -633,6 +830,9 @@ func (s *Server) waitForIdentify(ctx context.Context, client *Client) error {
 		"presences":        presences,
 		"voice_states":     voiceStates,
 		"federated_guilds": federatedGuilds,
+		"preferences":      s.loadUserPreferences(ctx, userID),
+		"feature_flags":    s.featureFlags.ForUser(ctx, userID, guildIDList),
+		"guild_layout":     s.loadGuildLayout(ctx, userID),
 	})
 
 	s.sendMessage(client, GatewayMessage{
@@ -641,6 +841,9 @@ func (s *Server) waitForIdentify(ctx context.Context, client *Client) error {
 		Data: readyData,
 	})
 
+	// Badge data follows READY so the client can render unreads at once.
+	s.sendReadStateSummary(ctx, client)
+
 	return nil
 }
 
@@ -655,7 +858,8 @@ func (s *Server) readLoop(ctx context.Context, client *Client) {
 
 		switch msg.Op {
 		case OpHeartbeat:
-			s.sendMessage(client, GatewayMessage{Op: OpHeartbeatAck})
+			s.sendMessage(client, s.heartbeatAck(client))
+			go s.measureRTT(client)
 			client.mu.Lock()
 			client.lastHeartbeat = time.Now()
 			client.mu.Unlock()
@@ -713,7 +917,8 @@ func (s *Server) readLoop(ctx context.Context, client *Client) {
 			s.handleResume(ctx, client, msg.Data)
 
 		case OpRequestMembers:
-			s.handleRequestMembers(ctx, client, msg.Data)
+			// Streams can be long; don't hold up heartbeats.
+			go s.handleRequestMembers(ctx, client, msg.Data)
 
 		case OpVoiceStateUpdate:
 			var data struct {
@@ -758,17 +963,25 @@ func (s *Server) readLoop(ctx context.Context, client *Client) {
 				})
 			}
 
+		case OpGuildSync:
+			go s.handleGuildSync(ctx, client, msg.Data)
+
 		case OpSubscribe:
-			// Channel-level subscription for DMs or specific channels.
-			var data struct {
-				ChannelIDs []string `json:"channel_ids"`
+			// Replace the subscription filter; an empty payload clears it.
+			var data SubscriptionFilter
+			if err := json.Unmarshal(msg.Data, &data); err != nil {
+				break
 			}
-			if err := json.Unmarshal(msg.Data, &data); err == nil {
-				s.logger.Debug("client subscribed to channels",
+			ef, err := compileFilter(&data)
+			if err != nil {
+				s.logger.Debug("invalid subscription filter",
 					slog.String("user_id", client.userID),
-					slog.Int("count", len(data.ChannelIDs)),
-				)
+					slog.String("error", err.Error()))
+				break
 			}
+			client.mu.Lock()
+			client.filter = ef
+			client.mu.Unlock()
 
 		default:
 			s.logger.Debug("unhandled gateway op",
@@ -821,14 +1034,36 @@ func (s *Server) handleResume(ctx context.Context, client *Client, data json.Raw
 	)
 }
 
-// handleRequestMembers fetches guild members and dispatches them to the requesting client.
+// memberChunkSize is the number of members per GUILD_MEMBERS_CHUNK when
+// streaming a full member list.
+const memberChunkSize = 1000
+
+// maxMemberSearch caps query and user_ids requests, which are answered in a
+// single chunk.
+const maxMemberSearch = 100
+
+// memberInfo is a guild member as sent in GUILD_MEMBERS_CHUNK.
+type memberInfo struct {
+	UserID         string  `json:"user_id"`
+	Username       string  `json:"username"`
+	DisplayName    *string `json:"display_name,omitempty"`
+	AvatarID       *string `json:"avatar_id,omitempty"`
+	StatusPresence string  `json:"status_presence"`
+	Nickname       *string `json:"nickname,omitempty"`
+	JoinedAt       string  `json:"joined_at"`
+}
+
+// handleRequestMembers answers op:7 REQUEST_GUILD_MEMBERS. Without a query
+// or user_ids it streams the whole member list in chunks of memberChunkSize,
+// ordered by user ID, so large guilds can be cached on demand instead of at
+// READY. A client can have one request in flight; others are dropped.
 func (s *Server) handleRequestMembers(ctx context.Context, client *Client, data json.RawMessage) {
 	var payload RequestMembersPayload
 	if err := json.Unmarshal(data, &payload); err != nil {
 		return
 	}
 
-	if payload.GuildID == "" {
+	if payload.GuildID == "" || s.pool == nil {
 		return
 	}
 
@@ -840,67 +1075,155 @@ func (s *Server) handleRequestMembers(ctx context.Context, client *Client, data
 		return
 	}
 
-	if payload.Limit <= 0 || payload.Limit > 100 {
-		payload.Limit = 100
+	if !client.requestingMembers.CompareAndSwap(false, true) {
+		return
 	}
+	defer client.requestingMembers.Store(false)
 
-	if s.pool == nil {
+	if payload.Query != "" || len(payload.UserIDs) > 0 {
+		s.sendMemberSearch(ctx, client, payload)
 		return
 	}
 
-	var query string
-	var args []interface{}
-	if payload.Query != "" {
-		query = `SELECT u.id, u.username, u.display_name, u.avatar_id, u.status_presence,
-		                gm.nickname, gm.joined_at
-		         FROM guild_members gm
-		         JOIN users u ON u.id = gm.user_id
-		         WHERE gm.guild_id = $1 AND u.username ILIKE '%' || $2 || '%'
-		         ORDER BY u.username LIMIT $3`
-		args = []interface{}{payload.GuildID, payload.Query, payload.Limit}
-	} else {
-		query = `SELECT u.id, u.username, u.display_name, u.avatar_id, u.status_presence,
-		                gm.nickname, gm.joined_at
-		         FROM guild_members gm
-		         JOIN users u ON u.id = gm.user_id
-		         WHERE gm.guild_id = $1
-		         ORDER BY u.username LIMIT $2`
-		args = []interface{}{payload.GuildID, payload.Limit}
+	var total int
+	if err := s.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM guild_members WHERE guild_id = $1`, payload.GuildID,
+	).Scan(&total); err != nil {
+		s.logger.Error("failed to count guild members", slog.String("error", err.Error()))
+		return
+	}
+	if payload.Limit > 0 && payload.Limit < total {
+		total = payload.Limit
 	}
+	chunkCount := max(1, (total+memberChunkSize-1)/memberChunkSize)
 
-	rows, err := s.pool.Query(ctx, query, args...)
+	after := ""
+	sent := 0
+	for i := 0; i < chunkCount; i++ {
+		size := min(memberChunkSize, total-sent)
+		members, err := s.queryMembers(ctx,
+			`WHERE gm.guild_id = $1 AND gm.user_id > $2 ORDER BY gm.user_id LIMIT $3`,
+			payload.GuildID, after, size)
+		if err != nil {
+			s.logger.Error("failed to query guild members", slog.String("error", err.Error()))
+			return
+		}
+		s.sendMemberChunk(ctx, client, payload, members, i, chunkCount, nil)
+		if len(members) < size {
+			return // membership shrank while streaming
+		}
+		sent += len(members)
+		after = members[len(members)-1].UserID
+	}
+}
+
+// sendMemberSearch answers a query or user_ids request with a single chunk.
+func (s *Server) sendMemberSearch(ctx context.Context, client *Client, payload RequestMembersPayload) {
+	var members []memberInfo
+	var notFound []string
+	var err error
+	if len(payload.UserIDs) > 0 {
+		ids := payload.UserIDs[:min(len(payload.UserIDs), maxMemberSearch)]
+		members, err = s.queryMembers(ctx,
+			`WHERE gm.guild_id = $1 AND gm.user_id = ANY($2) ORDER BY gm.user_id`,
+			payload.GuildID, ids)
+		if err == nil {
+			found := make(map[string]bool, len(members))
+			for _, m := range members {
+				found[m.UserID] = true
+			}
+			notFound = make([]string, 0)
+			for _, id := range ids {
+				if !found[id] {
+					notFound = append(notFound, id)
+				}
+			}
+		}
+	} else {
+		limit := payload.Limit
+		if limit <= 0 || limit > maxMemberSearch {
+			limit = maxMemberSearch
+		}
+		members, err = s.queryMembers(ctx,
+			`WHERE gm.guild_id = $1
+			   AND (lower(u.username) LIKE $2 OR lower(gm.nickname) LIKE $2)
+			 ORDER BY u.username LIMIT $3`,
+			payload.GuildID, strings.ToLower(escapeLike(payload.Query))+"%", limit)
+	}
 	if err != nil {
 		s.logger.Error("failed to query guild members", slog.String("error", err.Error()))
 		return
 	}
-	defer rows.Close()
+	s.sendMemberChunk(ctx, client, payload, members, 0, 1, notFound)
+}
 
-	type memberInfo struct {
-		UserID         string  `json:"user_id"`
-		Username       string  `json:"username"`
-		DisplayName    *string `json:"display_name,omitempty"`
-		AvatarID       *string `json:"avatar_id,omitempty"`
-		StatusPresence string  `json:"status_presence"`
-		Nickname       *string `json:"nickname,omitempty"`
-		JoinedAt       string  `json:"joined_at"`
+// queryMembers selects guild members; where is appended to the base query.
+func (s *Server) queryMembers(ctx context.Context, where string, args ...interface{}) ([]memberInfo, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT u.id, u.username, u.display_name, u.avatar_id, u.status_presence,
+		        gm.nickname, gm.joined_at
+		 FROM guild_members gm
+		 JOIN users u ON u.id = gm.user_id
+		 `+where, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
 	members := make([]memberInfo, 0)
 	for rows.Next() {
 		var m memberInfo
 		var joinedAt time.Time
+		var status *string
 		if err := rows.Scan(&m.UserID, &m.Username, &m.DisplayName, &m.AvatarID,
-			&m.StatusPresence, &m.Nickname, &joinedAt); err == nil {
-			m.JoinedAt = joinedAt.Format(time.RFC3339)
-			members = append(members, m)
+			&status, &m.Nickname, &joinedAt); err != nil {
+			continue
 		}
+		m.StatusPresence = presence.StatusOffline
+		if status != nil && *status != presence.StatusInvisible {
+			m.StatusPresence = *status
+		}
+		m.JoinedAt = joinedAt.Format(time.RFC3339)
+		members = append(members, m)
 	}
+	return members, rows.Err()
+}
 
-	chunkData, _ := json.Marshal(map[string]interface{}{
-		"guild_id": payload.GuildID,
-		"members":  members,
-	})
+// sendMemberChunk dispatches one GUILD_MEMBERS_CHUNK, with live presences
+// for the chunk's members if the client asked for them.
+func (s *Server) sendMemberChunk(ctx context.Context, client *Client, payload RequestMembersPayload,
+	members []memberInfo, index, count int, notFound []string) {
+	chunk := map[string]interface{}{
+		"guild_id":    payload.GuildID,
+		"members":     members,
+		"chunk_index": index,
+		"chunk_count": count,
+	}
+	if payload.Nonce != "" {
+		chunk["nonce"] = payload.Nonce
+	}
+	if notFound != nil {
+		chunk["not_found"] = notFound
+	}
+	if payload.Presences {
+		presences := make(map[string]string)
+		ids := make([]string, len(members))
+		for i, m := range members {
+			ids[i] = m.UserID
+		}
+		if bulk, err := s.cache.GetBulkPresence(ctx, ids); err == nil {
+			for uid, status := range bulk {
+				if status != "" && status != presence.StatusOffline {
+					presences[uid] = status
+				}
+			}
+			// Invisible users appear offline unless they granted the client.
+			s.resolveInvisible(ctx, client, presences)
+		}
+		chunk["presences"] = presences
+	}
 
+	chunkData, _ := json.Marshal(chunk)
 	s.sendMessage(client, GatewayMessage{
 		Op:   OpDispatch,
 		Type: "GUILD_MEMBERS_CHUNK",
@@ -908,38 +1231,142 @@ func (s *Server) handleRequestMembers(ctx context.Context, client *Client, data
 	})
 }
 
+// escapeLike escapes LIKE wildcards so a member query matches literally.
+func escapeLike(q string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q)
+}
+
 // dispatchEvent routes a NATS event to the appropriate connected clients based
 // on event type and guild/channel membership.
 func (s *Server) dispatchEvent(subject string, event events.Event) {
+	// Traced sends report their latency once fan-out has finished.
+	if event.TracedAt != 0 {
+		defer func() {
+			slo.Observe(slo.GatewayDelivery, time.Since(time.Unix(0, event.TracedAt)), true)
+		}()
+	}
+
 	// Update in-memory friend ID caches when relationships change.
 	s.handleRelationshipEvent(subject, event)
 
+	// Drop cached GUILD_SYNC state the event makes stale.
+	s.invalidateGuildState(subject, event)
+
+	// Apply message content approvals and revocations to open bot sessions.
+	s.handleBotCapabilityEvent(subject, event)
+	s.handleBotPresenceTargetsEvent(subject, event)
+
+	// Re-announce invisible users whose visibility exceptions changed.
+	s.handlePresenceVisibilityEvent(subject, event)
+
+	// Revoked users get the dispatch below, then are disconnected once the
+	// clients lock is released.
+	if subject == events.SubjectUserSessionsRevoked && event.UserID != "" {
+		defer s.disconnectUser(event.UserID, "session revoked")
+	}
+
 	msg := GatewayMessage{
 		Op:   OpDispatch,
 		Type: event.Type,
 		Data: event.Data,
 	}
+	var redacted, blocked *GatewayMessage // built on first use
+
+	// An invisible user's "offline" update reads "online" to the friends and
+	// guild members they made an exception for.
+	grant := s.presenceGrant(context.Background(), event)
+	var granted *GatewayMessage
+
+	guildID := s.trafficGuildID(event)
+	delivered := 0
+
+	// DM messages count as delivered to each recipient with a session.
+	dm := s.dmMessage(subject, event)
+	var dmReceivers []string
 
 	s.clientsMu.RLock()
 	defer s.clientsMu.RUnlock()
+	if guildID != "" {
+		defer func() { s.recordGuildTraffic(guildID, event.Type, eventProducer(event), delivered) }()
+	}
 
 	for client := range s.clients {
 		if !client.identified {
 			continue
 		}
 
+		// The subscription filter is checked first: it is cheap and lets
+		// bots that filter aggressively skip the membership lookups.
+		if !s.matchesFilter(client, event) {
+			continue
+		}
+
 		if s.shouldDispatchTo(client, subject, event) {
-			s.sendMessage(client, msg)
+			out := msg
+			if s.hidesBlockedAuthor(client, event) {
+				if blocked == nil {
+					blocked = &GatewayMessage{Op: msg.Op, Type: msg.Type, Data: blockedMessage(msg.Data)}
+				}
+				out = *blocked
+			} else if s.hidesContentFrom(client, event) {
+				if redacted == nil {
+					redacted = &GatewayMessage{Op: msg.Op, Type: msg.Type, Data: redactMessage(msg.Data)}
+				}
+				out = *redacted
+			}
+			if grant != nil && client.userID != event.UserID && grant.clientAllowed(client) {
+				if granted == nil {
+					g := grantedPresence(msg)
+					granted = &g
+				}
+				out = *granted
+			}
+			s.sendMessage(client, out)
+			delivered++
+			if dm != nil && client.userID != dm.AuthorID && !slices.Contains(dmReceivers, client.userID) {
+				dmReceivers = append(dmReceivers, client.userID)
+			}
 
 			// Buffer for potential resume replay (keep last 100 events per client).
 			client.mu.Lock()
-			client.replayBuf = append(client.replayBuf, msg)
+			client.replayBuf = append(client.replayBuf, out)
 			if len(client.replayBuf) > 100 {
 				client.replayBuf = client.replayBuf[len(client.replayBuf)-100:]
 			}
 			client.mu.Unlock()
 		}
 	}
+	if len(dmReceivers) > 0 {
+		go s.recordDMDelivery(event.ChannelID, dm.ID, dmReceivers)
+	}
+}
+
+// dmMessage returns the ID and author of a MESSAGE_CREATE in a DM or group
+// DM, or nil for other events.
+func (s *Server) dmMessage(subject string, event events.Event) *models.Message {
+	if subject != events.SubjectMessageCreate || event.ChannelID == "" || s.pool == nil {
+		return nil
+	}
+	if s.lookupChannel(event.ChannelID).guildID != nil {
+		return nil
+	}
+	var msg models.Message
+	if json.Unmarshal(event.Data, &msg) != nil || msg.ID == "" {
+		return nil
+	}
+	return &msg
+}
+
+// recordDMDelivery marks a DM message delivered to the recipients it was
+// dispatched to.
+func (s *Server) recordDMDelivery(channelID, messageID string, userIDs []string) {
+	ctx := context.Background()
+	for _, userID := range userIDs {
+		if err := apiutil.RecordDMDelivery(ctx, s.pool, s.eventBus, channelID, userID, messageID); err != nil {
+			s.logger.Debug("failed to record DM delivery",
+				slog.String("channel_id", channelID), slog.String("error", err.Error()))
+		}
+	}
 }
 
 // shouldDispatchTo determines if a client should receive a given event based on
@@ -977,20 +1404,27 @@ func (s *Server) shouldDispatchTo(client *Client, subject string, event events.E
 		s.userClientsMu.RUnlock()
 
 		// For offline events, the user is already unregistered so userClients
-		// is empty. Fall back to guild IDs embedded in the event data.
+		// is empty. Fall back to guild IDs embedded in the event data. Remote
+		// users who opted in to DM presence also list their local DM partners.
 		if len(eventUserGuildIDs) == 0 && event.Type == "PRESENCE_UPDATE" {
 			var payload struct {
-				GuildIDs []string `json:"guild_ids"`
+				GuildIDs     []string `json:"guild_ids"`
+				RecipientIDs []string `json:"recipient_ids"`
 			}
 			if json.Unmarshal(event.Data, &payload) == nil {
 				eventUserGuildIDs = payload.GuildIDs
+				if slices.Contains(payload.RecipientIDs, client.userID) {
+					return true
+				}
 			}
 		}
 
 		client.mu.Lock()
 		isFriend := client.friendIDs[event.UserID]
+		// Bots also get presence of users who consented; see bot_presence.go.
+		consented := event.Type == "PRESENCE_UPDATE" && client.presenceUsers[event.UserID]
 		client.mu.Unlock()
-		if isFriend {
+		if isFriend || consented {
 			return true
 		}
 
@@ -1044,7 +1478,9 @@ func (s *Server) shouldDispatchTo(client *Client, subject string, event events.E
 
 	// 8. Channel-scoped events: look up which guild the channel belongs to.
 	if event.ChannelID != "" && s.pool != nil {
-		return s.checkChannelAccess(client, event.ChannelID)
+		return s.checkChannelAccess(client, event.ChannelID) &&
+			s.checkVoiceChatAccess(client, subject, event.ChannelID) &&
+			s.checkPreviewAccess(client, subject, event.ChannelID)
 	}
 
 	// 9. Fallback: extract routing info from event data for events that didn't
@@ -1052,25 +1488,98 @@ func (s *Server) shouldDispatchTo(client *Client, subject string, event events.E
 	return s.fallbackDispatch(client, subject, event)
 }
 
+// matchesFilter reports whether an event passes the client's subscription
+// filter. Clients without a filter receive everything.
+func (s *Server) matchesFilter(client *Client, event events.Event) bool {
+	client.mu.Lock()
+	f := client.filter
+	client.mu.Unlock()
+	if f == nil {
+		return true
+	}
+
+	if f.types != nil && !f.types[event.Type] {
+		return false
+	}
+
+	channelID := event.ChannelID
+	guildID := event.GuildID
+	if guildID == "__broadcast__" {
+		guildID = ""
+	}
+	if channelID == "" && (f.channels != nil || (f.guilds != nil && guildID == "")) {
+		// Legacy publishes carry routing info only in the payload.
+		var data struct {
+			GuildID   string `json:"guild_id"`
+			ChannelID string `json:"channel_id"`
+		}
+		if json.Unmarshal(event.Data, &data) == nil {
+			channelID = data.ChannelID
+			if guildID == "" {
+				guildID = data.GuildID
+			}
+		}
+	}
+
+	if f.channels != nil && channelID != "" && !f.channels[channelID] {
+		return false
+	}
+	if f.guilds != nil {
+		if guildID == "" && channelID != "" && s.pool != nil {
+			if gid := s.lookupChannelGuild(channelID); gid != nil {
+				guildID = *gid
+			}
+		}
+		if guildID != "" && !f.guilds[guildID] {
+			return false
+		}
+	}
+	return true
+}
+
+// disconnectUser closes every gateway connection belonging to a user, e.g.
+// after their sessions were revoked by a suspension.
+func (s *Server) disconnectUser(userID, reason string) {
+	s.userClientsMu.RLock()
+	clients := make([]*Client, 0, len(s.userClients[userID]))
+	for c := range s.userClients[userID] {
+		clients = append(clients, c)
+	}
+	s.userClientsMu.RUnlock()
+
+	for _, c := range clients {
+		go c.conn.Close(websocket.StatusPolicyViolation, reason)
+	}
+	if len(clients) > 0 {
+		s.logger.Info("disconnected user from gateway",
+			slog.String("user_id", userID),
+			slog.Int("connections", len(clients)),
+			slog.String("reason", reason))
+	}
+}
+
 // lookupChannelGuild returns the guild_id for a channel, using a short-lived
 // cache to avoid repeated DB queries during dispatch loops.
 func (s *Server) lookupChannelGuild(channelID string) *string {
+	return s.lookupChannel(channelID).guildID
+}
+
+// lookupChannel returns the cached routing facts for a channel.
+func (s *Server) lookupChannel(channelID string) channelGuildEntry {
 	now := time.Now()
 	if cached, ok := s.channelGuildCache.Load(channelID); ok {
 		entry := cached.(channelGuildEntry)
 		if now.Before(entry.expires) {
-			return entry.guildID
+			return entry
 		}
 		s.channelGuildCache.Delete(channelID)
 	}
-	var guildID *string
+	entry := channelGuildEntry{expires: now.Add(60 * time.Second)}
 	_ = s.pool.QueryRow(context.Background(),
-		`SELECT guild_id FROM channels WHERE id = $1`, channelID).Scan(&guildID)
-	s.channelGuildCache.Store(channelID, channelGuildEntry{
-		guildID: guildID,
-		expires: now.Add(60 * time.Second),
-	})
-	return guildID
+		`SELECT guild_id, channel_type IN ('voice', 'stage'), preview_enabled FROM channels WHERE id = $1`,
+		channelID).Scan(&entry.guildID, &entry.voiceRoom, &entry.preview)
+	s.channelGuildCache.Store(channelID, entry)
+	return entry
 }
 
 // checkChannelAccess checks if a client has access to a channel by looking up
@@ -1092,6 +1601,42 @@ func (s *Server) checkChannelAccess(client *Client, channelID string) bool {
 	return isRecipient
 }
 
+// checkVoiceChatAccess limits the message events of a voice or stage
+// channel's chat to its current and recent participants and to members with
+// MANAGE_MESSAGES. Other events and channels pass.
+func (s *Server) checkVoiceChatAccess(client *Client, subject, channelID string) bool {
+	if !strings.HasPrefix(subject, "amityvox.message.") {
+		return true
+	}
+	entry := s.lookupChannel(channelID)
+	if !entry.voiceRoom || entry.guildID == nil {
+		return true
+	}
+	if s.voice != nil && s.voice.InVoiceChannel(client.userID, channelID) {
+		return true
+	}
+	ctx := context.Background()
+	if _, recent := apiutil.VoiceChatAccess(ctx, s.pool, channelID, client.userID); recent {
+		return true
+	}
+	perms, err := apiutil.MemberChannelPermissions(ctx, s.pool, *entry.guildID, channelID, client.userID)
+	return err == nil && perms&permissions.ManageMessages != 0
+}
+
+// checkPreviewAccess keeps the message events of a preview channel from
+// members it is locked for. Other events and channels pass.
+func (s *Server) checkPreviewAccess(client *Client, subject, channelID string) bool {
+	if !strings.HasPrefix(subject, "amityvox.message.") {
+		return true
+	}
+	entry := s.lookupChannel(channelID)
+	if !entry.preview || entry.guildID == nil {
+		return true
+	}
+	perms, err := apiutil.MemberChannelPermissions(context.Background(), s.pool, *entry.guildID, channelID, client.userID)
+	return err == nil && perms&permissions.ViewChannel != 0
+}
+
 // fallbackDispatch handles events where envelope fields are empty by extracting
 // routing information from the event data based on the NATS subject prefix.
 func (s *Server) fallbackDispatch(client *Client, subject string, event events.Event) bool {
@@ -1126,7 +1671,9 @@ func (s *Server) fallbackDispatch(client *Client, subject string, event events.E
 		strings.HasPrefix(subject, "amityvox.poll.") ||
 		strings.HasPrefix(subject, "amityvox.channel.") {
 		if data.ChannelID != "" && s.pool != nil {
-			return s.checkChannelAccess(client, data.ChannelID)
+			return s.checkChannelAccess(client, data.ChannelID) &&
+				s.checkVoiceChatAccess(client, subject, data.ChannelID) &&
+				s.checkPreviewAccess(client, subject, data.ChannelID)
 		}
 	}
 
@@ -1192,17 +1739,22 @@ func (s *Server) NotifyFriendAdd(userID, friendID string) {
 	if s.cache != nil {
 		status, err := s.cache.GetPresence(ctx, friendID)
 		if err == nil && status != "" && status != "offline" {
-			data, _ := json.Marshal(map[string]string{
-				"user_id": friendID,
-				"status":  status,
-			})
-			msg := GatewayMessage{
-				Op:   OpDispatch,
-				Type: "PRESENCE_UPDATE",
-				Data: data,
-			}
 			for _, client := range clients {
-				s.sendMessage(client, msg)
+				// An invisible friend shows only to clients they granted.
+				presences := map[string]string{friendID: status}
+				s.resolveInvisible(ctx, client, presences)
+				if presences[friendID] == "" {
+					continue
+				}
+				data, _ := json.Marshal(map[string]string{
+					"user_id": friendID,
+					"status":  presences[friendID],
+				})
+				s.sendMessage(client, GatewayMessage{
+					Op:   OpDispatch,
+					Type: "PRESENCE_UPDATE",
+					Data: data,
+				})
 			}
 		}
 	}
@@ -1226,7 +1778,8 @@ func (s *Server) NotifyFriendRemove(userID, friendID string) {
 }
 
 // handleRelationshipEvent intercepts RELATIONSHIP_UPDATE and RELATIONSHIP_REMOVE
-// events from the event bus and updates the in-memory friendIDs on connected clients.
+// events from the event bus and updates the in-memory friendIDs and blockedIDs
+// on connected clients.
 // This keeps the gateway's friend cache in sync without needing a direct reference
 // from the users Handler to the gateway Server.
 func (s *Server) handleRelationshipEvent(subject string, event events.Event) {
@@ -1239,6 +1792,7 @@ func (s *Server) handleRelationshipEvent(subject string, event events.Event) {
 			TargetID string `json:"target_id"`
 			Type     string `json:"type"`
 			Status   string `json:"status"`
+			Level    string `json:"level"`
 		}
 		if err := json.Unmarshal(event.Data, &payload); err != nil {
 			return
@@ -1252,6 +1806,14 @@ func (s *Server) handleRelationshipEvent(subject string, event events.Event) {
 			// to include TargetID as a friend.
 			s.NotifyFriendAdd(payload.UserID, payload.TargetID)
 		}
+		// Block events are dispatched to the target but name the blocker
+		// as UserID, whose clients hold the blocked set.
+		switch payload.Status {
+		case models.RelationshipBlocked:
+			s.setBlocked(payload.UserID, payload.TargetID, payload.Level != models.BlockLevelIgnore)
+		case "none":
+			s.setBlocked(payload.UserID, payload.TargetID, false)
+		}
 
 	case events.SubjectRelationshipRemove:
 		var payload struct {
@@ -1375,11 +1937,13 @@ func (s *Server) buildFederatedChannelsJSON(ctx context.Context, guildID string)
 		}
 	}
 
-	// Load non-private channels.
+	// Load non-private channels. Archived threads are reached through the
+	// archive browser instead.
 	chRows, err := s.pool.Query(ctx,
-		`SELECT id, channel_type, name, topic, position, category_id, parent_channel_id, encrypted
+		`SELECT id, channel_type, name, topic, position, category_id, parent_channel_id, encrypted, nsfw
 		 FROM channels
 		 WHERE guild_id = $1 AND (channel_type <> 'private' OR channel_type IS NULL)
+		   AND NOT (archived AND parent_channel_id IS NOT NULL)
 		 ORDER BY position`, guildID)
 	if err != nil {
 		s.logger.Error("buildFederatedChannelsJSON: failed to query channels",
@@ -1392,12 +1956,12 @@ func (s *Server) buildFederatedChannelsJSON(ctx context.Context, guildID string)
 			var name, topic *string
 			var position int
 			var categoryID, parentChannelID *string
-			var encrypted bool
-			if chRows.Scan(&id, &channelType, &name, &topic, &position, &categoryID, &parentChannelID, &encrypted) == nil {
+			var encrypted, nsfw bool
+			if chRows.Scan(&id, &channelType, &name, &topic, &position, &categoryID, &parentChannelID, &encrypted, &nsfw) == nil {
 				channels = append(channels, map[string]interface{}{
 					"id": id, "channel_type": channelType, "name": name, "topic": topic,
 					"position": position, "category_id": categoryID,
-					"parent_channel_id": parentChannelID, "encrypted": encrypted,
+					"parent_channel_id": parentChannelID, "encrypted": encrypted, "nsfw": nsfw,
 				})
 			}
 		}
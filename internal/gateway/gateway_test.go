@@ -1,8 +1,12 @@
 package gateway
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/amityvox/amityvox/internal/events"
 )
@@ -22,6 +26,7 @@ func TestOpcodeConstants(t *testing.T) {
 		"Subscribe":        OpSubscribe,
 		"Hello":            OpHello,
 		"HeartbeatAck":     OpHeartbeatAck,
+		"GuildSync":        OpGuildSync,
 	}
 
 	// Check uniqueness.
@@ -43,6 +48,9 @@ func TestOpcodeConstants(t *testing.T) {
 	if OpHeartbeatAck != 11 {
 		t.Errorf("OpHeartbeatAck = %d, want 11", OpHeartbeatAck)
 	}
+	if OpGuildSync != 12 {
+		t.Errorf("OpGuildSync = %d, want 12", OpGuildSync)
+	}
 }
 
 func TestGatewayMessage_JSON(t *testing.T) {
@@ -417,3 +425,588 @@ func TestNotifyFriendRemove_RemovesFriendIDs(t *testing.T) {
 		t.Error("friendIDs should not contain user-B after NotifyFriendRemove")
 	}
 }
+
+func TestCompileFilter(t *testing.T) {
+	if f, err := compileFilter(nil); f != nil || err != nil {
+		t.Errorf("compileFilter(nil) = %v, %v; want nil, nil", f, err)
+	}
+	if f, err := compileFilter(&SubscriptionFilter{}); f != nil || err != nil {
+		t.Errorf("empty filter = %v, %v; want nil, nil", f, err)
+	}
+
+	f, err := compileFilter(&SubscriptionFilter{GuildIDs: []string{"g1"}, Events: []string{"MESSAGE_CREATE"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.guilds["g1"] || !f.types["MESSAGE_CREATE"] || f.channels != nil {
+		t.Errorf("compiled filter = %+v", f)
+	}
+
+	tooMany := make([]string, maxFilterEntries+1)
+	if _, err := compileFilter(&SubscriptionFilter{ChannelIDs: tooMany}); err == nil {
+		t.Error("expected an error for an oversized filter")
+	}
+}
+
+func TestMatchesFilter(t *testing.T) {
+	s := &Server{}
+	msgData, _ := json.Marshal(map[string]string{"guild_id": "g2", "channel_id": "c9"})
+
+	tests := []struct {
+		name   string
+		filter SubscriptionFilter
+		event  events.Event
+		want   bool
+	}{
+		{"no filter", SubscriptionFilter{}, events.Event{Type: "MESSAGE_CREATE", GuildID: "g1"}, true},
+		{"event type allowed", SubscriptionFilter{Events: []string{"MESSAGE_CREATE"}},
+			events.Event{Type: "MESSAGE_CREATE", GuildID: "g1"}, true},
+		{"event type filtered", SubscriptionFilter{Events: []string{"MESSAGE_CREATE"}},
+			events.Event{Type: "TYPING_START", GuildID: "g1"}, false},
+		{"guild allowed", SubscriptionFilter{GuildIDs: []string{"g1"}},
+			events.Event{Type: "MESSAGE_CREATE", GuildID: "g1", ChannelID: "c1"}, true},
+		{"guild filtered", SubscriptionFilter{GuildIDs: []string{"g1"}},
+			events.Event{Type: "MESSAGE_CREATE", GuildID: "g2", ChannelID: "c1"}, false},
+		{"guild from payload", SubscriptionFilter{GuildIDs: []string{"g1"}},
+			events.Event{Type: "MESSAGE_CREATE", Data: msgData}, false},
+		{"user event passes guild filter", SubscriptionFilter{GuildIDs: []string{"g1"}},
+			events.Event{Type: "USER_UPDATE", UserID: "u1"}, true},
+		{"broadcast passes guild filter", SubscriptionFilter{GuildIDs: []string{"g1"}},
+			events.Event{Type: "ANNOUNCEMENT_CREATE", GuildID: "__broadcast__"}, true},
+		{"channel allowed", SubscriptionFilter{ChannelIDs: []string{"c1"}},
+			events.Event{Type: "MESSAGE_CREATE", GuildID: "g1", ChannelID: "c1"}, true},
+		{"channel filtered", SubscriptionFilter{ChannelIDs: []string{"c1"}},
+			events.Event{Type: "MESSAGE_CREATE", GuildID: "g1", ChannelID: "c2"}, false},
+		{"channel from payload", SubscriptionFilter{ChannelIDs: []string{"c9"}},
+			events.Event{Type: "MESSAGE_CREATE", Data: msgData}, true},
+		{"guild event passes channel filter", SubscriptionFilter{ChannelIDs: []string{"c1"}},
+			events.Event{Type: "GUILD_MEMBER_ADD", GuildID: "g1"}, true},
+	}
+	for _, tc := range tests {
+		f, err := compileFilter(&tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &Client{userID: "u1", identified: true, filter: f}
+		if got := s.matchesFilter(client, tc.event); got != tc.want {
+			t.Errorf("%s: matchesFilter = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRequestMembersPayload_Streaming(t *testing.T) {
+	raw := `{"guild_id":"abc","user_ids":["u1","u2"],"presences":true,"nonce":"n-1"}`
+	var payload RequestMembersPayload
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if len(payload.UserIDs) != 2 || !payload.Presences || payload.Nonce != "n-1" {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"alice":   "alice",
+		"100%":    `100\%`,
+		"a_b":     `a\_b`,
+		`back\sl`: `back\\sl`,
+	}
+	for in, want := range tests {
+		if got := escapeLike(in); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIdentifyPayload_LazyGuilds(t *testing.T) {
+	var payload IdentifyPayload
+	if err := json.Unmarshal([]byte(`{"token":"t","lazy_guilds":true}`), &payload); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if !payload.LazyGuilds {
+		t.Error("lazy_guilds not decoded")
+	}
+
+	data, _ := json.Marshal(IdentifyPayload{Token: "t"})
+	if string(data) != `{"token":"t"}` {
+		t.Errorf("lazy_guilds should be omitted when false, got %s", data)
+	}
+}
+
+func TestInvalidateGuildState(t *testing.T) {
+	s := &Server{}
+	fresh := guildStateEntry{data: json.RawMessage(`{}`), expires: time.Now().Add(time.Minute)}
+
+	tests := []struct {
+		name    string
+		subject string
+		event   events.Event
+		dropped bool
+	}{
+		{"guild event", events.SubjectGuildUpdate, events.Event{GuildID: "g1"}, true},
+		{"role event", events.SubjectGuildRoleCreate, events.Event{GuildID: "g1"}, true},
+		{"channel event with guild in payload", events.SubjectChannelUpdate,
+			events.Event{ChannelID: "c1", Data: json.RawMessage(`{"id":"c1","guild_id":"g1"}`)}, true},
+		{"other guild", events.SubjectGuildUpdate, events.Event{GuildID: "g2"}, false},
+		{"unrelated subject", events.SubjectMessageCreate, events.Event{GuildID: "g1"}, false},
+	}
+	for _, tc := range tests {
+		s.guildStateCache.Store("g1", fresh)
+		s.invalidateGuildState(tc.subject, tc.event)
+		_, ok := s.guildStateCache.Load("g1")
+		if ok == tc.dropped {
+			t.Errorf("%s: cached = %v, want dropped = %v", tc.name, ok, tc.dropped)
+		}
+	}
+}
+
+func TestSummarizeReadStates(t *testing.T) {
+	guild := "g1"
+	summary := summarizeReadStates([]channelReadState{
+		{ChannelID: "dm1", ChannelType: "dm", UnreadCount: 3},
+		{ChannelID: "dm2", ChannelType: "group", MentionCount: 1},
+		{ChannelID: "c1", GuildID: &guild, ChannelType: "text", UnreadCount: 5},
+	})
+	if summary.PendingDMCount != 1 {
+		t.Errorf("PendingDMCount = %d, want 1", summary.PendingDMCount)
+	}
+
+	data, _ := json.Marshal(summarizeReadStates(nil))
+	if string(data) != `{"channels":[],"pending_dm_count":0}` {
+		t.Errorf("empty summary = %s", data)
+	}
+}
+
+func TestShardIndex_Stable(t *testing.T) {
+	for _, key := range []string{"", "guild-a", "guild-b", "01HQZX"} {
+		first := shardIndex(key, 8)
+		if first < 0 || first >= 8 {
+			t.Fatalf("shardIndex(%q, 8) = %d, out of range", key, first)
+		}
+		if again := shardIndex(key, 8); again != first {
+			t.Errorf("shardIndex(%q) not stable: %d then %d", key, first, again)
+		}
+	}
+}
+
+func TestTargetShards(t *testing.T) {
+	tests := []struct {
+		clients, min, max, want int
+	}{
+		{0, 4, 16, 4},
+		{clientsPerShard * 6, 4, 16, 6},
+		{clientsPerShard*6 + 1, 4, 16, 7},
+		{clientsPerShard * 100, 4, 16, 16},
+	}
+	for _, tc := range tests {
+		if got := targetShards(tc.clients, tc.min, tc.max); got != tc.want {
+			t.Errorf("targetShards(%d, %d, %d) = %d, want %d", tc.clients, tc.min, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestReshard_DrainsQueuedEvents(t *testing.T) {
+	s := &Server{
+		clients:     make(map[*Client]struct{}),
+		userClients: make(map[string]map[*Client]struct{}),
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	s.startShards(2)
+	old := s.shards
+	for i := 0; i < 50; i++ {
+		s.enqueueEvent(events.SubjectGuildUpdate, events.Event{GuildID: "g1", Type: "GUILD_UPDATE"})
+	}
+
+	s.Reshard(4)
+	defer s.stopShards()
+
+	var drained int64
+	for _, shard := range old {
+		drained += shard.dispatched.Load()
+	}
+	if drained != 50 {
+		t.Errorf("old shards dispatched %d events before reshard, want 50", drained)
+	}
+	if n := len(s.ShardStats()); n != 4 {
+		t.Errorf("shards after reshard = %d, want 4", n)
+	}
+}
+
+func TestHidesContentFrom(t *testing.T) {
+	s := &Server{}
+	bot := &Client{userID: "bot-1", isBot: true}
+	data, _ := json.Marshal(map[string]interface{}{
+		"channel_id": "chan-1", "author_id": "user-A", "content": "secret",
+		"mention_user_ids": []string{"user-B"},
+	})
+	event := events.Event{Type: "MESSAGE_CREATE", ChannelID: "chan-1", Data: data}
+
+	if !s.hidesContentFrom(bot, event) {
+		t.Error("bot without message content access should get redacted messages")
+	}
+	if s.hidesContentFrom(&Client{userID: "user-B"}, event) {
+		t.Error("users always get message content")
+	}
+	if s.hidesContentFrom(&Client{userID: "bot-1", isBot: true, messageContent: true}, event) {
+		t.Error("approved bots get message content")
+	}
+	if s.hidesContentFrom(bot, events.Event{Type: "MESSAGE_DELETE", Data: data}) {
+		t.Error("only message create and update carry content")
+	}
+
+	mention, _ := json.Marshal(map[string]interface{}{
+		"channel_id": "chan-1", "author_id": "user-A", "mention_user_ids": []string{"bot-1"},
+	})
+	if s.hidesContentFrom(bot, events.Event{Type: "MESSAGE_UPDATE", Data: mention}) {
+		t.Error("bots see messages that mention them")
+	}
+	own, _ := json.Marshal(map[string]interface{}{"channel_id": "chan-1", "author_id": "bot-1"})
+	if s.hidesContentFrom(bot, events.Event{Type: "MESSAGE_CREATE", Data: own}) {
+		t.Error("bots see their own messages")
+	}
+}
+
+func TestRedactMessage(t *testing.T) {
+	data := json.RawMessage(`{"id":"m1","author_id":"u1","content":"secret","embeds":[{}],` +
+		`"attachments":[{}],"components":[],"mention_user_ids":["u2"]}`)
+	var got map[string]interface{}
+	if err := json.Unmarshal(redactMessage(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"content", "embeds", "attachments", "components"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("redacted message still has %q", k)
+		}
+	}
+	if got["id"] != "m1" || got["author_id"] != "u1" || got["mention_user_ids"] == nil {
+		t.Errorf("redacted message lost metadata: %v", got)
+	}
+	if string(redactMessage(json.RawMessage(`"x"`))) != "{}" {
+		t.Error("non-object payloads should redact to {}")
+	}
+}
+
+func TestHandleBotCapabilityEvent(t *testing.T) {
+	s := &Server{userClients: make(map[string]map[*Client]struct{})}
+	bot := &Client{userID: "bot-1", isBot: true}
+	s.userClients["bot-1"] = map[*Client]struct{}{bot: {}}
+
+	s.handleBotCapabilityEvent(events.SubjectBotCapabilities, events.Event{
+		UserID: "bot-1", Data: json.RawMessage(`{"message_content":true}`),
+	})
+	if !bot.messageContent {
+		t.Error("approval should apply to the open session")
+	}
+	s.handleBotCapabilityEvent(events.SubjectBotCapabilities, events.Event{
+		UserID: "bot-1", Data: json.RawMessage(`{"message_content":false}`),
+	})
+	if bot.messageContent {
+		t.Error("revocation should apply to the open session")
+	}
+}
+
+func TestShouldDispatchTo_PresenceUpdate_BotConsent(t *testing.T) {
+	s := &Server{
+		clients:     make(map[*Client]struct{}),
+		userClients: make(map[string]map[*Client]struct{}),
+	}
+	bot := &Client{userID: "bot-1", identified: true, isBot: true, guildIDs: map[string]bool{}, friendIDs: map[string]bool{}}
+	s.userClients["bot-1"] = map[*Client]struct{}{bot: {}}
+
+	presence := events.Event{Type: "PRESENCE_UPDATE", UserID: "user-A", Data: json.RawMessage(`{"user_id":"user-A","status":"online"}`)}
+	if s.shouldDispatchTo(bot, "amityvox.presence.update", presence) {
+		t.Fatal("bot should not see presence before consent")
+	}
+
+	s.handleBotPresenceTargetsEvent(events.SubjectBotCapabilities, events.Event{
+		Type: "BOT_PRESENCE_TARGETS_UPDATE", UserID: "bot-1", Data: json.RawMessage(`{"user_ids":["user-A"]}`),
+	})
+	if !s.shouldDispatchTo(bot, "amityvox.presence.update", presence) {
+		t.Error("bot should see presence of a consenting user")
+	}
+	userUpdate := events.Event{Type: "USER_UPDATE", UserID: "user-A", Data: json.RawMessage(`{}`)}
+	if s.shouldDispatchTo(bot, "amityvox.user.update", userUpdate) {
+		t.Error("consent covers presence only, not profile updates")
+	}
+
+	// The targets event must not touch message content access.
+	bot.messageContent = true
+	s.handleBotCapabilityEvent(events.SubjectBotCapabilities, events.Event{
+		Type: "BOT_PRESENCE_TARGETS_UPDATE", UserID: "bot-1", Data: json.RawMessage(`{"user_ids":[]}`),
+	})
+	if !bot.messageContent {
+		t.Error("presence targets update should not revoke message content")
+	}
+}
+
+func TestHandleRelationshipEvent_Blocks(t *testing.T) {
+	s := &Server{userClients: make(map[string]map[*Client]struct{})}
+	blocker := &Client{userID: "user-A"}
+	s.userClients["user-A"] = map[*Client]struct{}{blocker: {}}
+	msg, _ := json.Marshal(map[string]interface{}{"author_id": "user-B", "content": "hi"})
+	event := events.Event{Type: "MESSAGE_CREATE", Data: msg}
+
+	relationship := func(status, level string) {
+		data, _ := json.Marshal(map[string]string{
+			"user_id": "user-A", "target_id": "user-B", "status": status, "level": level,
+		})
+		s.handleRelationshipEvent(events.SubjectRelationshipUpdate, events.Event{Data: data})
+	}
+
+	relationship("blocked", "block")
+	if !s.hidesBlockedAuthor(blocker, event) {
+		t.Error("blocked author's messages should be hidden")
+	}
+	if s.hidesBlockedAuthor(blocker, events.Event{Type: "MESSAGE_DELETE", Data: msg}) {
+		t.Error("only events carrying content are hidden")
+	}
+	relationship("blocked", "ignore")
+	if s.hidesBlockedAuthor(blocker, event) {
+		t.Error("ignored authors' messages are delivered in full")
+	}
+	relationship("blocked", "block")
+	relationship("none", "")
+	if s.hidesBlockedAuthor(blocker, event) {
+		t.Error("unblocking should deliver messages again")
+	}
+}
+
+func TestBlockedMessage(t *testing.T) {
+	data := json.RawMessage(`{"id":"m1","author_id":"u1","content":"secret","content_warning":"cw",` +
+		`"attachments":[{}]}`)
+	var got map[string]interface{}
+	if err := json.Unmarshal(blockedMessage(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"content", "content_warning", "attachments"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("blocked message still has %q", k)
+		}
+	}
+	if got["blocked"] != true || got["id"] != "m1" || got["author_id"] != "u1" {
+		t.Errorf("blocked placeholder = %v", got)
+	}
+}
+
+func TestVisibilityGrantAllows(t *testing.T) {
+	g := visibilityGrant{
+		userIDs:  map[string]bool{"friend-1": true},
+		guildIDs: map[string]bool{"guild-1": true},
+	}
+	if !g.allows("friend-1", nil) {
+		t.Error("listed friend should see the user online")
+	}
+	if !g.allows("member", map[string]bool{"guild-2": true, "guild-1": true}) {
+		t.Error("member of a listed guild should see the user online")
+	}
+	if g.allows("stranger", map[string]bool{"guild-2": true}) {
+		t.Error("unlisted viewer should not see the user online")
+	}
+}
+
+func TestResolveInvisible_NoGrants(t *testing.T) {
+	s := &Server{}
+	presences := map[string]string{"u1": "online", "u2": "invisible"}
+	s.resolveInvisible(context.Background(), &Client{userID: "viewer"}, presences)
+	if presences["u1"] != "online" {
+		t.Error("visible presences should be kept")
+	}
+	if _, ok := presences["u2"]; ok {
+		t.Error("invisible users without a grant should be dropped")
+	}
+}
+
+func TestGrantedPresence(t *testing.T) {
+	msg := GatewayMessage{Op: OpDispatch, Type: "PRESENCE_UPDATE",
+		Data: json.RawMessage(`{"user_id":"u1","status":"offline","guild_ids":["g1"]}`)}
+	var got map[string]interface{}
+	if err := json.Unmarshal(grantedPresence(msg).Data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["status"] != "online" || got["user_id"] != "u1" || got["guild_ids"] == nil {
+		t.Errorf("granted presence = %v", got)
+	}
+}
+
+func TestUserPreferencesFillMuted(t *testing.T) {
+	now := time.Now()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+	guild := "g1"
+	expired := "g2"
+	prefs := userPreferences{
+		Guilds: []guildPreference{
+			{GuildID: nil, Level: "mentions", MutedUntil: &later},
+			{GuildID: &guild, Level: "all", MutedUntil: &later},
+			{GuildID: &expired, Level: "all", MutedUntil: &earlier},
+		},
+		Channels: []channelPreference{
+			{ChannelID: "c1", Level: "none"},
+			{ChannelID: "c2", Level: "mentions", MutedUntil: &later},
+		},
+	}
+	prefs.fillMuted(now)
+
+	if len(prefs.MutedGuildIDs) != 1 || prefs.MutedGuildIDs[0] != guild {
+		t.Errorf("MutedGuildIDs = %v, want [%s]", prefs.MutedGuildIDs, guild)
+	}
+	if len(prefs.MutedChannelIDs) != 1 || prefs.MutedChannelIDs[0] != "c2" {
+		t.Errorf("MutedChannelIDs = %v, want [c2]", prefs.MutedChannelIDs)
+	}
+}
+
+func TestGuildTraffic(t *testing.T) {
+	s := &Server{
+		clients:     make(map[*Client]struct{}),
+		userClients: make(map[string]map[*Client]struct{}),
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, c := range []*Client{
+		{userID: "u1", identified: true, guildIDs: map[string]bool{"quiet": true, "busy": true}},
+		{userID: "u2", identified: true, guildIDs: map[string]bool{"busy": true}},
+		{userID: "u3", guildIDs: map[string]bool{"busy": true}}, // not identified yet
+	} {
+		s.registerClient(c)
+	}
+
+	for i := 0; i < 3; i++ {
+		s.recordGuildTraffic("busy", "MESSAGE_CREATE", "u1", 2)
+	}
+	s.recordGuildTraffic("busy", "TYPING_START", "u2", 1)
+	s.recordGuildTraffic("gone", "GUILD_UPDATE", "", 0)
+
+	traffic := s.GuildTraffic()
+	if len(traffic) != 3 {
+		t.Fatalf("GuildTraffic() = %+v, want busy, gone and quiet", traffic)
+	}
+	busy := traffic[0]
+	if busy.GuildID != "busy" || busy.Sessions != 2 || busy.Events != 4 || busy.Deliveries != 7 {
+		t.Errorf("busiest guild = %+v, want busy with 2 sessions, 4 events, 7 deliveries", busy)
+	}
+	if len(busy.TopProducers) != 2 || busy.TopProducers[0] != (TrafficCount{Key: "u1", Count: 3}) {
+		t.Errorf("top producers = %+v, want u1 first with 3", busy.TopProducers)
+	}
+	if busy.TopEventTypes[0].Key != "MESSAGE_CREATE" {
+		t.Errorf("top event types = %+v, want MESSAGE_CREATE first", busy.TopEventTypes)
+	}
+	if traffic[2].GuildID != "quiet" || traffic[2].Sessions != 1 || traffic[2].Events != 0 {
+		t.Errorf("idle guild = %+v, want quiet with 1 session and no events", traffic[2])
+	}
+
+	st := s.ConnectionStats()
+	if st.Sessions != 3 || st.Identified != 2 || st.Users != 3 || st.ConnectedTotal != 3 {
+		t.Errorf("ConnectionStats() = %+v", st)
+	}
+}
+
+func TestEventProducer(t *testing.T) {
+	msg, _ := json.Marshal(map[string]string{"author_id": "author"})
+	tests := []struct {
+		event events.Event
+		want  string
+	}{
+		{events.Event{UserID: "actor", Data: msg}, "actor"},
+		{events.Event{Data: msg}, "author"},
+		{events.Event{Data: json.RawMessage(`{"user_id":"member"}`)}, "member"},
+		{events.Event{Data: json.RawMessage(`[1,2]`)}, ""},
+	}
+	for _, tt := range tests {
+		if got := eventProducer(tt.event); got != tt.want {
+			t.Errorf("eventProducer(%+v) = %q, want %q", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestOriginHostPatterns(t *testing.T) {
+	got := originHostPatterns([]string{"*", "https://chat.example.com", "https://*.example.org", "http://localhost:5173"})
+	want := []string{"*", "chat.example.com", "*.example.org", "localhost:5173"}
+	if len(got) != len(want) {
+		t.Fatalf("originHostPatterns() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("originHostPatterns()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWithoutNSFWChannels(t *testing.T) {
+	channels := json.RawMessage(`[{"id":"a","nsfw":false},{"id":"b","nsfw":true},{"id":"cat","channel_type":"category"}]`)
+	var got []map[string]interface{}
+	if err := json.Unmarshal(withoutNSFWChannels(channels), &got); err != nil {
+		t.Fatalf("unreadable result: %v", err)
+	}
+	if len(got) != 2 || got[0]["id"] != "a" || got[1]["id"] != "cat" {
+		t.Errorf("withoutNSFWChannels kept %v, want a and cat", got)
+	}
+
+	state := json.RawMessage(`{"id":"g","channels":[{"id":"b","nsfw":true}]}`)
+	var guild struct {
+		ID       string            `json:"id"`
+		Channels []json.RawMessage `json:"channels"`
+	}
+	if err := json.Unmarshal(guildStateWithoutNSFW(state), &guild); err != nil {
+		t.Fatalf("unreadable guild state: %v", err)
+	}
+	if guild.ID != "g" || len(guild.Channels) != 0 {
+		t.Errorf("guildStateWithoutNSFW = %+v, want guild g with no channels", guild)
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	for _, ms := range []int{10, 20, 40, 80, 3000} {
+		h.observe(time.Duration(ms) * time.Millisecond)
+	}
+	st := h.snapshot("eu-west")
+	if st.Region != "eu-west" || st.Count != 5 {
+		t.Fatalf("snapshot = %+v, want region eu-west with 5 samples", st)
+	}
+	// Cumulative: ≤25ms 2, ≤50ms 3, ≤100ms 4, and the 3s sample above every bound.
+	want := []uint64{2, 3, 4, 4, 4, 4, 4}
+	for i := range want {
+		if st.Buckets[i] != want[i] {
+			t.Errorf("Buckets = %v, want %v", st.Buckets, want)
+			break
+		}
+	}
+	if st.MeanMs != 630 {
+		t.Errorf("MeanMs = %v, want 630", st.MeanMs)
+	}
+	if st.P50Ms <= 25 || st.P50Ms > 50 {
+		t.Errorf("P50Ms = %v, want within the 25-50ms bucket", st.P50Ms)
+	}
+	if st.P95Ms != 2500 {
+		t.Errorf("P95Ms = %v, want the last bound 2500", st.P95Ms)
+	}
+}
+
+func TestRTTPercentile_Empty(t *testing.T) {
+	if got := rttPercentile(make([]uint64, len(RTTBuckets)+1), 0, 0.5); got != 0 {
+		t.Errorf("rttPercentile of no samples = %v, want 0", got)
+	}
+}
+
+func TestHeartbeatAck(t *testing.T) {
+	s := &Server{region: "us-east"}
+	client := &Client{}
+
+	var payload HeartbeatAckPayload
+	msg := s.heartbeatAck(client)
+	if msg.Op != OpHeartbeatAck {
+		t.Fatalf("Op = %d, want %d", msg.Op, OpHeartbeatAck)
+	}
+	if err := json.Unmarshal(msg.Data, &payload); err != nil {
+		t.Fatalf("unreadable ack: %v", err)
+	}
+	if payload.RTTMs != nil || payload.Region != "us-east" {
+		t.Errorf("ack before a measurement = %+v, want no rtt_ms", payload)
+	}
+
+	client.rtt = 42 * time.Millisecond
+	payload = HeartbeatAckPayload{}
+	json.Unmarshal(s.heartbeatAck(client).Data, &payload)
+	if payload.RTTMs == nil || *payload.RTTMs != 42 {
+		t.Errorf("ack rtt_ms = %v, want 42", payload.RTTMs)
+	}
+}
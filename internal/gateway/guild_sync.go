@@ -0,0 +1,269 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/presence"
+)
+
+// GuildSyncPayload is sent by clients (op 12) to pull full state for guilds
+// after a lazy READY. One GUILD_SYNC dispatch is sent per guild.
+type GuildSyncPayload struct {
+	GuildIDs []string `json:"guild_ids"`
+}
+
+const (
+	// maxGuildSyncBatch bounds how many guilds a single GUILD_SYNC may request.
+	maxGuildSyncBatch = 100
+
+	// guildStateTTL is how long assembled guild state is reused. Events that
+	// change the cached fields invalidate entries before they expire.
+	guildStateTTL = 60 * time.Second
+)
+
+// guildStateEntry caches the static part of a GUILD_SYNC payload: guild
+// metadata, channels and roles. Presences and voice states are always live.
+type guildStateEntry struct {
+	data    json.RawMessage
+	expires time.Time
+}
+
+// sendLazyReady sends the slim READY used when the client identified with
+// lazy_guilds. It carries one summary row per guild and friend presences;
+// channels, roles, member presences and voice states come from GUILD_SYNC.
+func (s *Server) sendLazyReady(ctx context.Context, client *Client, user *models.User, guildIDs []string) {
+	guilds := make([]map[string]interface{}, 0, len(guildIDs))
+	if s.pool != nil && len(guildIDs) > 0 {
+		rows, err := s.pool.Query(ctx,
+			`SELECT g.id, g.name, g.icon_id, g.member_count,
+			        CASE WHEN g.instance_id <> $2 THEN i.domain END
+			 FROM guilds g
+			 LEFT JOIN instances i ON i.id = g.instance_id
+			 WHERE g.id = ANY($1)`, guildIDs, s.localInstanceID)
+		if err != nil {
+			s.logger.Warn("failed to load guild summaries for lazy READY",
+				slog.String("user_id", client.userID), slog.String("error", err.Error()))
+		} else {
+			defer rows.Close()
+			for rows.Next() {
+				var id, name string
+				var iconID, domain *string
+				var memberCount int
+				if rows.Scan(&id, &name, &iconID, &memberCount, &domain) == nil {
+					summary := map[string]interface{}{
+						"id":           id,
+						"name":         name,
+						"icon_id":      iconID,
+						"member_count": memberCount,
+					}
+					if domain != nil {
+						summary["instance_domain"] = *domain
+					}
+					guilds = append(guilds, summary)
+				}
+			}
+		}
+	}
+
+	// Friends may share no synced guild, so their presences stay in READY.
+	client.mu.Lock()
+	friendIDs := make([]string, 0, len(client.friendIDs))
+	for fid := range client.friendIDs {
+		friendIDs = append(friendIDs, fid)
+	}
+	client.mu.Unlock()
+
+	readyData, _ := json.Marshal(map[string]interface{}{
+		"user":          user,
+		"guild_ids":     guildIDs,
+		"session_id":    client.sessionID,
+		"lazy_guilds":   true,
+		"guilds":        guilds,
+		"presences":     s.visiblePresences(ctx, client, friendIDs),
+		"preferences":   s.loadUserPreferences(ctx, client.userID),
+		"feature_flags": s.featureFlags.ForUser(ctx, client.userID, guildIDs),
+		"guild_layout":  s.loadGuildLayout(ctx, client.userID),
+	})
+
+	s.sendMessage(client, GatewayMessage{
+		Op:   OpDispatch,
+		Type: "READY",
+		Data: readyData,
+	})
+}
+
+// handleGuildSync processes an op:12 GUILD_SYNC request. Guilds the client is
+// not a member of, or that fall outside its subscription filter, are skipped.
+// NSFW channels are left out unless the user opted in to NSFW content.
+func (s *Server) handleGuildSync(ctx context.Context, client *Client, data json.RawMessage) {
+	var payload GuildSyncPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return
+	}
+	if len(payload.GuildIDs) > maxGuildSyncBatch {
+		payload.GuildIDs = payload.GuildIDs[:maxGuildSyncBatch]
+	}
+	showNSFW := s.showsNSFW(ctx, client.userID)
+
+	for _, guildID := range payload.GuildIDs {
+		client.mu.Lock()
+		allowed := client.guildIDs[guildID] &&
+			(client.filter == nil || client.filter.guilds == nil || client.filter.guilds[guildID])
+		client.mu.Unlock()
+		if !allowed {
+			continue
+		}
+
+		state := s.guildState(ctx, guildID)
+		if state == nil {
+			continue
+		}
+		if !showNSFW {
+			state = guildStateWithoutNSFW(state)
+		}
+		syncData, _ := json.Marshal(map[string]interface{}{
+			"guild_id":     guildID,
+			"guild":        state,
+			"presences":    s.guildPresences(ctx, client, guildID),
+			"voice_states": s.guildVoiceStates(guildID),
+		})
+		s.sendMessage(client, GatewayMessage{
+			Op:   OpDispatch,
+			Type: "GUILD_SYNC",
+			Data: syncData,
+		})
+	}
+}
+
+// guildState returns the cached static state for a guild, assembling and
+// caching it on a miss. It returns nil if the guild does not exist.
+func (s *Server) guildState(ctx context.Context, guildID string) json.RawMessage {
+	now := time.Now()
+	if cached, ok := s.guildStateCache.Load(guildID); ok {
+		entry := cached.(guildStateEntry)
+		if now.Before(entry.expires) {
+			return entry.data
+		}
+		s.guildStateCache.Delete(guildID)
+	}
+	if s.pool == nil {
+		return nil
+	}
+
+	var name string
+	var iconID, description, ownerID *string
+	var memberCount int
+	err := s.pool.QueryRow(ctx,
+		`SELECT name, icon_id, description, owner_id, member_count FROM guilds WHERE id = $1`,
+		guildID).Scan(&name, &iconID, &description, &ownerID, &memberCount)
+	if err != nil {
+		return nil
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":           guildID,
+		"name":         name,
+		"icon_id":      iconID,
+		"description":  description,
+		"owner_id":     ownerID,
+		"member_count": memberCount,
+		"channels":     s.buildFederatedChannelsJSON(ctx, guildID),
+		"roles":        s.buildFederatedRolesJSON(ctx, guildID),
+	})
+	s.guildStateCache.Store(guildID, guildStateEntry{data: data, expires: now.Add(guildStateTTL)})
+	return data
+}
+
+// guildPresences returns non-offline presences of a guild's members, excluding
+// the requesting client's user.
+func (s *Server) guildPresences(ctx context.Context, client *Client, guildID string) map[string]string {
+	memberIDs := make([]string, 0)
+	if s.pool != nil {
+		rows, err := s.pool.Query(ctx,
+			`SELECT user_id FROM guild_members WHERE guild_id = $1 AND user_id <> $2`, guildID, client.userID)
+		if err == nil {
+			defer rows.Close()
+			for rows.Next() {
+				var uid string
+				if rows.Scan(&uid) == nil {
+					memberIDs = append(memberIDs, uid)
+				}
+			}
+		}
+	}
+	return s.visiblePresences(ctx, client, memberIDs)
+}
+
+// visiblePresences looks up presences in bulk as the client may see them,
+// dropping offline users and invisible ones that did not grant the client.
+func (s *Server) visiblePresences(ctx context.Context, client *Client, userIDs []string) map[string]string {
+	presences := make(map[string]string)
+	if s.cache == nil || len(userIDs) == 0 {
+		return presences
+	}
+	bulk, err := s.cache.GetBulkPresence(ctx, userIDs)
+	if err != nil {
+		return presences
+	}
+	for uid, status := range bulk {
+		if status != presence.StatusOffline {
+			presences[uid] = status
+		}
+	}
+	s.resolveInvisible(ctx, client, presences)
+	return presences
+}
+
+// guildVoiceStates returns the voice states for a single guild.
+func (s *Server) guildVoiceStates(guildID string) []map[string]interface{} {
+	states := make([]map[string]interface{}, 0)
+	if s.voice == nil {
+		return states
+	}
+	for _, vs := range s.voice.GetGuildVoiceStates(guildID) {
+		states = append(states, map[string]interface{}{
+			"user_id":    vs.UserID,
+			"channel_id": vs.ChannelID,
+			"guild_id":   vs.GuildID,
+			"self_mute":  vs.SelfMute,
+			"self_deaf":  vs.SelfDeaf,
+		})
+	}
+	return states
+}
+
+// invalidateGuildState drops the cached GUILD_SYNC state for the guild an
+// event touches, if the event changes guild metadata, channels or roles.
+func (s *Server) invalidateGuildState(subject string, event events.Event) {
+	switch subject {
+	case events.SubjectGuildUpdate, events.SubjectGuildDelete,
+		events.SubjectGuildMemberAdd, events.SubjectGuildMemberRemove,
+		events.SubjectGuildRoleCreate, events.SubjectGuildRoleUpdate, events.SubjectGuildRoleDelete,
+		events.SubjectChannelCreate, events.SubjectChannelUpdate, events.SubjectChannelDelete:
+	default:
+		return
+	}
+
+	guildID := event.GuildID
+	if guildID == "" {
+		var payload struct {
+			GuildID *string `json:"guild_id"`
+		}
+		if json.Unmarshal(event.Data, &payload) == nil && payload.GuildID != nil {
+			guildID = *payload.GuildID
+		}
+	}
+	if guildID == "" && event.ChannelID != "" && s.pool != nil {
+		if gid := s.lookupChannelGuild(event.ChannelID); gid != nil {
+			guildID = *gid
+		}
+	}
+	if guildID != "" {
+		s.guildStateCache.Delete(guildID)
+	}
+}
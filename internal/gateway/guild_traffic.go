@@ -0,0 +1,270 @@
+package gateway
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+const (
+	// trafficWindow is how many one-minute buckets of per-guild traffic are
+	// kept. Rates are averaged over the whole window.
+	trafficWindow = 5
+
+	// trafficTopN caps the event types and producers listed per guild.
+	trafficTopN = 10
+
+	// maxTrackedProducers caps the distinct producers counted per guild per
+	// minute, so a raid of throwaway accounts cannot grow the map unbounded.
+	maxTrackedProducers = 1000
+)
+
+// trafficBucket is one minute of a guild's traffic.
+type trafficBucket struct {
+	minute     int64 // Unix minute the bucket covers
+	events     int64
+	deliveries int64
+	types      map[string]int64
+	producers  map[string]int64
+}
+
+// guildTraffic is a guild's traffic over the last trafficWindow minutes.
+type guildTraffic struct {
+	mu      sync.Mutex
+	buckets [trafficWindow]trafficBucket
+}
+
+// TrafficCount is one entry of a top-N list.
+type TrafficCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// GuildTraffic is a snapshot of one guild's gateway load on this node over
+// the traffic window.
+type GuildTraffic struct {
+	GuildID             string         `json:"guild_id"`
+	Sessions            int            `json:"sessions"`
+	Events              int64          `json:"events"`
+	Deliveries          int64          `json:"deliveries"`
+	EventsPerMinute     float64        `json:"events_per_minute"`
+	DeliveriesPerMinute float64        `json:"deliveries_per_minute"`
+	TopEventTypes       []TrafficCount `json:"top_event_types"`
+	TopProducers        []TrafficCount `json:"top_producers"`
+}
+
+// ConnectionStats is a snapshot of this node's gateway connections.
+type ConnectionStats struct {
+	Sessions       int   `json:"sessions"`
+	Identified     int   `json:"identified"`
+	Users          int   `json:"users"`
+	Bots           int   `json:"bots"`
+	ConnectedTotal int64 `json:"connected_total"`
+	// WindowMinutes is the span per-guild traffic is measured over.
+	WindowMinutes int `json:"window_minutes"`
+}
+
+// trafficGuildID returns the guild an event is attributed to, or "" for
+// events outside any guild.
+func (s *Server) trafficGuildID(event events.Event) string {
+	if event.GuildID == "__broadcast__" {
+		return ""
+	}
+	if event.GuildID != "" {
+		return event.GuildID
+	}
+	if event.ChannelID != "" && s.pool != nil {
+		if gid := s.lookupChannelGuild(event.ChannelID); gid != nil {
+			return *gid
+		}
+	}
+	return ""
+}
+
+// eventProducer returns the user an event was caused by: the envelope's
+// user, else the author or user named in its payload.
+func eventProducer(event events.Event) string {
+	if event.UserID != "" {
+		return event.UserID
+	}
+	var payload struct {
+		AuthorID string `json:"author_id"`
+		UserID   string `json:"user_id"`
+	}
+	if json.Unmarshal(event.Data, &payload) != nil {
+		return ""
+	}
+	return cmp.Or(payload.AuthorID, payload.UserID)
+}
+
+// recordGuildTraffic counts one event dispatched in a guild and the number
+// of sessions it was delivered to.
+func (s *Server) recordGuildTraffic(guildID, eventType, producer string, deliveries int) {
+	now := time.Now()
+	minute := now.Unix() / 60
+	s.sweepTraffic(minute)
+
+	v, _ := s.traffic.LoadOrStore(guildID, &guildTraffic{})
+	gt := v.(*guildTraffic)
+
+	gt.mu.Lock()
+	defer gt.mu.Unlock()
+	b := &gt.buckets[minute%trafficWindow]
+	if b.minute != minute {
+		*b = trafficBucket{minute: minute, types: make(map[string]int64), producers: make(map[string]int64)}
+	}
+	b.events++
+	b.deliveries += int64(deliveries)
+	b.types[eventType]++
+	if producer != "" {
+		if _, ok := b.producers[producer]; ok || len(b.producers) < maxTrackedProducers {
+			b.producers[producer]++
+		}
+	}
+}
+
+// sweepTraffic drops guilds with no traffic in the window, at most once a
+// minute.
+func (s *Server) sweepTraffic(minute int64) {
+	last := s.trafficSwept.Load()
+	if last >= minute || !s.trafficSwept.CompareAndSwap(last, minute) {
+		return
+	}
+	s.traffic.Range(func(key, v any) bool {
+		gt := v.(*guildTraffic)
+		gt.mu.Lock()
+		idle := true
+		for i := range gt.buckets {
+			if gt.buckets[i].minute > minute-trafficWindow {
+				idle = false
+				break
+			}
+		}
+		gt.mu.Unlock()
+		if idle {
+			s.traffic.Delete(key)
+		}
+		return true
+	})
+}
+
+// snapshot sums the buckets still inside the window ending at minute.
+func (gt *guildTraffic) snapshot(guildID string, minute int64) GuildTraffic {
+	out := GuildTraffic{GuildID: guildID}
+	types := make(map[string]int64)
+	producers := make(map[string]int64)
+
+	gt.mu.Lock()
+	for i := range gt.buckets {
+		b := &gt.buckets[i]
+		if b.minute <= minute-trafficWindow {
+			continue
+		}
+		out.Events += b.events
+		out.Deliveries += b.deliveries
+		for k, n := range b.types {
+			types[k] += n
+		}
+		for k, n := range b.producers {
+			producers[k] += n
+		}
+	}
+	gt.mu.Unlock()
+
+	out.EventsPerMinute = float64(out.Events) / trafficWindow
+	out.DeliveriesPerMinute = float64(out.Deliveries) / trafficWindow
+	out.TopEventTypes = topCounts(types, trafficTopN)
+	out.TopProducers = topCounts(producers, trafficTopN)
+	return out
+}
+
+// topCounts returns the n largest entries of counts, largest first.
+func topCounts(counts map[string]int64, n int) []TrafficCount {
+	out := make([]TrafficCount, 0, len(counts))
+	for k, c := range counts {
+		out = append(out, TrafficCount{Key: k, Count: c})
+	}
+	slices.SortFunc(out, func(a, b TrafficCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Key, b.Key))
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// GuildTraffic returns the gateway load of every guild with connected
+// sessions or recent traffic on this node, heaviest first by deliveries.
+func (s *Server) GuildTraffic() []GuildTraffic {
+	sessions := make(map[string]int)
+	s.clientsMu.RLock()
+	for client := range s.clients {
+		if !client.identified {
+			continue
+		}
+		client.mu.Lock()
+		for gid := range client.guildIDs {
+			sessions[gid]++
+		}
+		client.mu.Unlock()
+	}
+	s.clientsMu.RUnlock()
+
+	minute := time.Now().Unix() / 60
+	byGuild := make(map[string]GuildTraffic, len(sessions))
+	s.traffic.Range(func(key, v any) bool {
+		gid := key.(string)
+		byGuild[gid] = v.(*guildTraffic).snapshot(gid, minute)
+		return true
+	})
+	for gid, n := range sessions {
+		gt, ok := byGuild[gid]
+		if !ok {
+			gt = GuildTraffic{GuildID: gid, TopEventTypes: []TrafficCount{}, TopProducers: []TrafficCount{}}
+		}
+		gt.Sessions = n
+		byGuild[gid] = gt
+	}
+
+	out := make([]GuildTraffic, 0, len(byGuild))
+	for _, gt := range byGuild {
+		out = append(out, gt)
+	}
+	slices.SortFunc(out, func(a, b GuildTraffic) int {
+		return cmp.Or(
+			cmp.Compare(b.Deliveries, a.Deliveries),
+			cmp.Compare(b.Events, a.Events),
+			cmp.Compare(b.Sessions, a.Sessions),
+			cmp.Compare(a.GuildID, b.GuildID),
+		)
+	})
+	return out
+}
+
+// ConnectionStats returns a snapshot of this node's gateway connections.
+func (s *Server) ConnectionStats() ConnectionStats {
+	st := ConnectionStats{
+		ConnectedTotal: s.connectedTotal.Load(),
+		WindowMinutes:  trafficWindow,
+	}
+	s.clientsMu.RLock()
+	st.Sessions = len(s.clients)
+	for client := range s.clients {
+		if client.identified {
+			st.Identified++
+			if client.isBot {
+				st.Bots++
+			}
+		}
+	}
+	s.clientsMu.RUnlock()
+
+	s.userClientsMu.RLock()
+	st.Users = len(s.userClients)
+	s.userClientsMu.RUnlock()
+	return st
+}
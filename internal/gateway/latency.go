@@ -0,0 +1,171 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// RTTBuckets are the upper bounds of the heartbeat round-trip histogram.
+var RTTBuckets = []time.Duration{
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+}
+
+// HeartbeatAckPayload is the data of op:11 HEARTBEAT_ACK. RTTMs is the
+// round trip last measured by the server with a WebSocket ping, omitted
+// until the first measurement completes.
+type HeartbeatAckPayload struct {
+	RTTMs  *int64 `json:"rtt_ms,omitempty"`
+	Region string `json:"region,omitempty"`
+}
+
+// LatencyStats is a snapshot of the heartbeat round trips measured on this
+// node since start.
+type LatencyStats struct {
+	Region  string        `json:"region"`
+	Count   uint64        `json:"count"`
+	Total   time.Duration `json:"-"`
+	Buckets []uint64      `json:"buckets"` // cumulative, one per RTTBuckets bound
+	MeanMs  float64       `json:"mean_ms"`
+	P50Ms   float64       `json:"p50_ms"`
+	P95Ms   float64       `json:"p95_ms"`
+}
+
+// latencyHistogram aggregates heartbeat round trips for the node's region.
+type latencyHistogram struct {
+	mu      sync.Mutex
+	count   uint64
+	total   time.Duration
+	buckets []uint64 // non-cumulative; the last slot counts samples above every bound
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{buckets: make([]uint64, len(RTTBuckets)+1)}
+}
+
+// observe records one round trip.
+func (h *latencyHistogram) observe(rtt time.Duration) {
+	i := 0
+	for i < len(RTTBuckets) && rtt > RTTBuckets[i] {
+		i++
+	}
+	h.mu.Lock()
+	h.count++
+	h.total += rtt
+	h.buckets[i]++
+	h.mu.Unlock()
+}
+
+// snapshot returns the histogram with cumulative buckets and estimated
+// percentiles.
+func (h *latencyHistogram) snapshot(region string) LatencyStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st := LatencyStats{
+		Region:  region,
+		Count:   h.count,
+		Total:   h.total,
+		Buckets: make([]uint64, len(RTTBuckets)),
+	}
+	var running uint64
+	for i := range RTTBuckets {
+		running += h.buckets[i]
+		st.Buckets[i] = running
+	}
+	if h.count > 0 {
+		st.MeanMs = durationMs(h.total / time.Duration(h.count))
+	}
+	st.P50Ms = durationMs(rttPercentile(h.buckets, h.count, 0.50))
+	st.P95Ms = durationMs(rttPercentile(h.buckets, h.count, 0.95))
+	return st
+}
+
+// rttPercentile estimates quantile q from non-cumulative bucket counts by
+// interpolating linearly within the bucket it falls in. Samples above the
+// last bound are reported as that bound.
+func rttPercentile(buckets []uint64, count uint64, q float64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	rank := q * float64(count)
+	var seen float64
+	for i, n := range buckets {
+		if n == 0 {
+			continue
+		}
+		if i >= len(RTTBuckets) {
+			return RTTBuckets[len(RTTBuckets)-1]
+		}
+		if seen+float64(n) >= rank {
+			var lower time.Duration
+			if i > 0 {
+				lower = RTTBuckets[i-1]
+			}
+			frac := (rank - seen) / float64(n)
+			return lower + time.Duration(frac*float64(RTTBuckets[i]-lower))
+		}
+		seen += float64(n)
+	}
+	return RTTBuckets[len(RTTBuckets)-1]
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// LatencyStats returns the heartbeat round trips measured on this node.
+func (s *Server) LatencyStats() LatencyStats {
+	return s.latency.snapshot(s.region)
+}
+
+// measureRTT pings the client and records the round trip. Ping waits for
+// the pong to be read, so it must not run on the client's read loop; only
+// one measurement per client is in flight at a time.
+func (s *Server) measureRTT(client *Client) {
+	if !client.measuringRTT.CompareAndSwap(false, true) {
+		return
+	}
+	defer client.measuringRTT.Store(false)
+
+	timeout := s.heartbeatInterval
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := client.conn.Ping(ctx); err != nil {
+		return
+	}
+	rtt := time.Since(start)
+
+	client.mu.Lock()
+	client.rtt = rtt
+	client.mu.Unlock()
+	s.latency.observe(rtt)
+}
+
+// heartbeatAck builds the HEARTBEAT_ACK for a client with its last measured
+// round trip.
+func (s *Server) heartbeatAck(client *Client) GatewayMessage {
+	client.mu.Lock()
+	rtt := client.rtt
+	client.mu.Unlock()
+
+	payload := HeartbeatAckPayload{Region: s.region}
+	if rtt > 0 {
+		ms := rtt.Milliseconds()
+		payload.RTTMs = &ms
+	}
+	data, _ := json.Marshal(payload)
+	return GatewayMessage{Op: OpHeartbeatAck, Data: data}
+}
@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// redactedMessageFields are the parts of a message payload a bot without the
+// message content capability does not receive. They match what
+// models.Message.RedactContent clears, plus the text of MESSAGE_TRANSLATION.
+var redactedMessageFields = []string{"content", "attachments", "embeds", "components", "voice_waveform", "translation", "text"}
+
+// loadBotCapabilities records whether the client is a bot and, if so,
+// whether an instance admin has approved its message content access.
+func (s *Server) loadBotCapabilities(ctx context.Context, client *Client) {
+	if s.pool == nil {
+		return
+	}
+	var isBot, messageContent bool
+	err := s.pool.QueryRow(ctx,
+		`SELECT u.flags & $2 != 0,
+		        EXISTS(SELECT 1 FROM bot_message_content_access a WHERE a.bot_id = u.id AND a.status = 'approved')
+		 FROM users u WHERE u.id = $1`,
+		client.userID, models.UserFlagBot).Scan(&isBot, &messageContent)
+	if err != nil {
+		s.logger.Error("failed to load bot capabilities", slog.String("error", err.Error()))
+		return
+	}
+	client.mu.Lock()
+	client.isBot, client.messageContent = isBot, messageContent
+	client.mu.Unlock()
+}
+
+// handleBotCapabilityEvent applies an approval or revocation to the bot's
+// open sessions, so it takes effect without a reconnect.
+func (s *Server) handleBotCapabilityEvent(subject string, event events.Event) {
+	if subject != events.SubjectBotCapabilities || event.UserID == "" || event.Type == "BOT_PRESENCE_TARGETS_UPDATE" {
+		return
+	}
+	var data struct {
+		MessageContent bool `json:"message_content"`
+	}
+	if json.Unmarshal(event.Data, &data) != nil {
+		return
+	}
+	s.userClientsMu.RLock()
+	defer s.userClientsMu.RUnlock()
+	for c := range s.userClients[event.UserID] {
+		c.mu.Lock()
+		c.messageContent = data.MessageContent
+		c.mu.Unlock()
+	}
+}
+
+// hidesContentFrom reports whether a message event must reach the client
+// without its content: the client is a bot without message content access,
+// and the message is in a guild channel, was not sent by the bot and does
+// not mention it.
+func (s *Server) hidesContentFrom(client *Client, event events.Event) bool {
+	if event.Type != "MESSAGE_CREATE" && event.Type != "MESSAGE_UPDATE" && event.Type != "MESSAGE_TRANSLATION" {
+		return false
+	}
+	client.mu.Lock()
+	hidden := client.isBot && !client.messageContent
+	client.mu.Unlock()
+	if !hidden {
+		return false
+	}
+
+	var m struct {
+		ChannelID      string   `json:"channel_id"`
+		AuthorID       string   `json:"author_id"`
+		MentionUserIDs []string `json:"mention_user_ids"`
+	}
+	if json.Unmarshal(event.Data, &m) != nil {
+		return true
+	}
+	if m.AuthorID == client.userID || slices.Contains(m.MentionUserIDs, client.userID) {
+		return false
+	}
+	channelID := m.ChannelID
+	if channelID == "" {
+		channelID = event.ChannelID
+	}
+	if s.pool != nil && channelID != "" && s.lookupChannelGuild(channelID) == nil {
+		return false // DM or group
+	}
+	return true
+}
+
+// redactMessage returns a message payload without its content fields.
+// Payloads that are not JSON objects come back empty.
+func redactMessage(data json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return json.RawMessage(`{}`)
+	}
+	for _, k := range redactedMessageFields {
+		delete(fields, k)
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return json.RawMessage(`{}`)
+	}
+	return out
+}
@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// guildPreference is a guild notification setting. GuildID is nil for the
+// user's global default.
+type guildPreference struct {
+	GuildID       *string    `json:"guild_id"`
+	Level         string     `json:"level"`
+	SuppressHere  bool       `json:"suppress_here"`
+	SuppressRoles bool       `json:"suppress_roles"`
+	MutedUntil    *time.Time `json:"muted_until"`
+}
+
+// channelPreference is a per-channel notification override.
+type channelPreference struct {
+	ChannelID  string     `json:"channel_id"`
+	Level      string     `json:"level"`
+	MutedUntil *time.Time `json:"muted_until"`
+}
+
+// userPreferences is the "preferences" field of READY: everything a client
+// needs to draw muted guilds, muted channels and hidden threads in the
+// sidebar without asking the REST API first. The muted ID lists are derived
+// from the settings and only hold mutes still in effect.
+type userPreferences struct {
+	Guilds          []guildPreference   `json:"guilds"`
+	Channels        []channelPreference `json:"channels"`
+	HiddenThreadIDs []string            `json:"hidden_thread_ids"`
+	MutedGuildIDs   []string            `json:"muted_guild_ids"`
+	MutedChannelIDs []string            `json:"muted_channel_ids"`
+}
+
+// fillMuted sets the muted ID lists from the guild and channel settings.
+// The global default is not a guild, so muting it lists nothing.
+func (p *userPreferences) fillMuted(now time.Time) {
+	p.MutedGuildIDs = make([]string, 0)
+	p.MutedChannelIDs = make([]string, 0)
+	for _, g := range p.Guilds {
+		if g.GuildID != nil && g.MutedUntil != nil && now.Before(*g.MutedUntil) {
+			p.MutedGuildIDs = append(p.MutedGuildIDs, *g.GuildID)
+		}
+	}
+	for _, c := range p.Channels {
+		if c.MutedUntil != nil && now.Before(*c.MutedUntil) {
+			p.MutedChannelIDs = append(p.MutedChannelIDs, c.ChannelID)
+		}
+	}
+}
+
+// loadUserPreferences reads the user's notification settings and hidden
+// threads. A failed query is logged and leaves its list empty, so READY is
+// never held back by preferences.
+func (s *Server) loadUserPreferences(ctx context.Context, userID string) userPreferences {
+	prefs := userPreferences{
+		Guilds:          make([]guildPreference, 0),
+		Channels:        make([]channelPreference, 0),
+		HiddenThreadIDs: make([]string, 0),
+	}
+	if s.pool == nil {
+		prefs.fillMuted(time.Now())
+		return prefs
+	}
+	warn := func(what string, err error) {
+		s.logger.Warn("failed to load "+what+" for READY",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+
+	rows, err := s.pool.Query(ctx,
+		`SELECT NULLIF(guild_id, '__global__'), level, suppress_here, suppress_roles, muted_until
+		 FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		warn("guild notification preferences", err)
+	} else {
+		for rows.Next() {
+			var g guildPreference
+			if rows.Scan(&g.GuildID, &g.Level, &g.SuppressHere, &g.SuppressRoles, &g.MutedUntil) == nil {
+				prefs.Guilds = append(prefs.Guilds, g)
+			}
+		}
+		rows.Close()
+	}
+
+	rows, err = s.pool.Query(ctx,
+		`SELECT channel_id, level, muted_until
+		 FROM channel_notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		warn("channel notification preferences", err)
+	} else {
+		for rows.Next() {
+			var c channelPreference
+			if rows.Scan(&c.ChannelID, &c.Level, &c.MutedUntil) == nil {
+				prefs.Channels = append(prefs.Channels, c)
+			}
+		}
+		rows.Close()
+	}
+
+	rows, err = s.pool.Query(ctx,
+		`SELECT thread_id FROM user_hidden_threads WHERE user_id = $1`, userID)
+	if err != nil {
+		warn("hidden threads", err)
+	} else {
+		for rows.Next() {
+			var id string
+			if rows.Scan(&id) == nil {
+				prefs.HiddenThreadIDs = append(prefs.HiddenThreadIDs, id)
+			}
+		}
+		rows.Close()
+	}
+
+	prefs.fillMuted(time.Now())
+	return prefs
+}
+
+// loadGuildLayout reads the user's guild order, guild folders and favorite
+// channels for the "guild_layout" field of READY. Like the preferences, a
+// failure is logged and sends an empty layout.
+func (s *Server) loadGuildLayout(ctx context.Context, userID string) models.GuildLayout {
+	empty := models.GuildLayout{
+		GuildPositions: []models.GuildPosition{},
+		Folders:        []models.GuildFolder{},
+		Favorites:      []models.ChannelFavorite{},
+	}
+	if s.pool == nil {
+		return empty
+	}
+	layout, err := apiutil.LoadGuildLayout(ctx, s.pool, userID)
+	if err != nil {
+		s.logger.Warn("failed to load guild layout for READY",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+		return empty
+	}
+	return layout
+}
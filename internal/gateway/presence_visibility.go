@@ -0,0 +1,162 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/presence"
+)
+
+// An invisible user's PRESENCE_UPDATE is published as "offline", which is
+// what federated peers and most clients see. The user can list friends and
+// guilds that still see them online; those exceptions are applied here when
+// presence is fanned out, so a client cannot claim one it was not given.
+
+// visibilityGrant lists who an invisible user still appears online to.
+type visibilityGrant struct {
+	userIDs  map[string]bool
+	guildIDs map[string]bool
+}
+
+// allows reports whether a viewer who belongs to viewerGuilds may see the
+// user online.
+func (g visibilityGrant) allows(viewerID string, viewerGuilds map[string]bool) bool {
+	if g.userIDs[viewerID] {
+		return true
+	}
+	for gid := range g.guildIDs {
+		if viewerGuilds[gid] {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAllowed applies a grant to a connected client.
+func (g visibilityGrant) clientAllowed(client *Client) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return g.allows(client.userID, client.guildIDs)
+}
+
+// loadVisibilityGrants reads the exceptions of the given users. Friends who
+// were since removed and guilds the user left no longer count. Users with no
+// exceptions are left out of the result.
+func (s *Server) loadVisibilityGrants(ctx context.Context, userIDs []string) map[string]visibilityGrant {
+	grants := make(map[string]visibilityGrant)
+	if s.pool == nil || len(userIDs) == 0 {
+		return grants
+	}
+	rows, err := s.pool.Query(ctx,
+		`SELECT e.user_id, e.target_type, e.target_id FROM presence_visibility_exceptions e
+		 WHERE e.user_id = ANY($1)
+		   AND CASE e.target_type
+		       WHEN 'user' THEN EXISTS(SELECT 1 FROM user_relationships r
+		                               WHERE r.user_id = e.user_id AND r.target_id = e.target_id AND r.status = 'friend')
+		       ELSE EXISTS(SELECT 1 FROM guild_members m
+		                   WHERE m.user_id = e.user_id AND m.guild_id = e.target_id)
+		       END`, userIDs)
+	if err != nil {
+		s.logger.Error("failed to load presence visibility exceptions", slog.String("error", err.Error()))
+		return grants
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID, targetType, targetID string
+		if rows.Scan(&userID, &targetType, &targetID) != nil {
+			continue
+		}
+		g, ok := grants[userID]
+		if !ok {
+			g = visibilityGrant{userIDs: map[string]bool{}, guildIDs: map[string]bool{}}
+			grants[userID] = g
+		}
+		if targetType == "guild" {
+			g.guildIDs[targetID] = true
+		} else {
+			g.userIDs[targetID] = true
+		}
+	}
+	return grants
+}
+
+// resolveInvisible rewrites the invisible entries of a presence snapshot for
+// one viewer: users who granted the viewer show as online, the rest are
+// dropped.
+func (s *Server) resolveInvisible(ctx context.Context, client *Client, presences map[string]string) {
+	var invisible []string
+	for uid, status := range presences {
+		if status == presence.StatusInvisible {
+			invisible = append(invisible, uid)
+			delete(presences, uid)
+		}
+	}
+	if len(invisible) == 0 {
+		return
+	}
+	for uid, g := range s.loadVisibilityGrants(ctx, invisible) {
+		if g.clientAllowed(client) {
+			presences[uid] = presence.StatusOnline
+		}
+	}
+}
+
+// presenceGrant returns the grant to apply while dispatching a
+// PRESENCE_UPDATE, or nil. Only an "offline" update from a user the cache
+// still holds as invisible is rewritten; a real disconnect clears the cache
+// first.
+func (s *Server) presenceGrant(ctx context.Context, event events.Event) *visibilityGrant {
+	if event.Type != "PRESENCE_UPDATE" || event.UserID == "" || s.cache == nil || s.pool == nil {
+		return nil
+	}
+	var data struct {
+		Status string `json:"status"`
+	}
+	if json.Unmarshal(event.Data, &data) != nil || data.Status != presence.StatusOffline {
+		return nil
+	}
+	if status, err := s.cache.GetPresence(ctx, event.UserID); err != nil || status != presence.StatusInvisible {
+		return nil
+	}
+	g, ok := s.loadVisibilityGrants(ctx, []string{event.UserID})[event.UserID]
+	if !ok {
+		return nil
+	}
+	return &g
+}
+
+// grantedPresence is the PRESENCE_UPDATE sent to viewers an invisible user
+// granted: the original payload with the status set to online.
+func grantedPresence(msg GatewayMessage) GatewayMessage {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(msg.Data, &fields) != nil {
+		return msg
+	}
+	fields["status"], _ = json.Marshal(presence.StatusOnline)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return msg
+	}
+	return GatewayMessage{Op: msg.Op, Type: msg.Type, Data: data}
+}
+
+// handlePresenceVisibilityEvent re-announces the presence of a connected,
+// invisible user whose exceptions changed, so viewers added to or removed
+// from the lists see the change at once.
+func (s *Server) handlePresenceVisibilityEvent(subject string, event events.Event) {
+	if subject != events.SubjectUserUpdate || event.Type != "PRESENCE_VISIBILITY_UPDATE" || event.UserID == "" || s.cache == nil {
+		return
+	}
+	s.userClientsMu.RLock()
+	connected := len(s.userClients[event.UserID]) > 0
+	s.userClientsMu.RUnlock()
+	if !connected {
+		return
+	}
+	ctx := context.Background()
+	if status, err := s.cache.GetPresence(ctx, event.UserID); err == nil && status == presence.StatusInvisible {
+		s.broadcastPresence(ctx, event.UserID, presence.StatusOffline)
+	}
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+const (
+	// maxUnreadCount caps the per-channel unread count in the summary;
+	// clients render anything at the cap as "99+"-style badges.
+	maxUnreadCount = 100
+
+	// maxReadStateChannels bounds the channels listed in one summary. The
+	// most recently active channels are kept.
+	maxReadStateChannels = 1000
+)
+
+// channelReadState is one channel's badge data in READ_STATE_SUMMARY.
+type channelReadState struct {
+	ChannelID     string     `json:"channel_id"`
+	GuildID       *string    `json:"guild_id"`
+	ChannelType   string     `json:"channel_type"`
+	LastReadID    *string    `json:"last_read_id"`
+	LastMessageID string     `json:"last_message_id"`
+	LastAuthorID  *string    `json:"last_message_author_id"`
+	LastMessageAt *time.Time `json:"last_message_at"`
+	UnreadCount   int        `json:"unread_count"`
+	MentionCount  int        `json:"mention_count"`
+}
+
+// readStateSummary is the READ_STATE_SUMMARY dispatch sent right after READY.
+type readStateSummary struct {
+	Channels       []channelReadState `json:"channels"`
+	PendingDMCount int                `json:"pending_dm_count"`
+}
+
+// summarizeReadStates builds the summary, counting DM and group channels
+// with unread messages toward the pending DM badge.
+func summarizeReadStates(channels []channelReadState) readStateSummary {
+	summary := readStateSummary{Channels: channels}
+	if summary.Channels == nil {
+		summary.Channels = make([]channelReadState, 0)
+	}
+	for _, ch := range channels {
+		if ch.GuildID == nil && ch.UnreadCount > 0 {
+			summary.PendingDMCount++
+		}
+	}
+	return summary
+}
+
+// sendReadStateSummary dispatches unread and mention counts for every channel
+// with activity since the user last read it, so clients can render badges
+// without fetching read state per channel. DM channels are included even if
+// the user has never opened them; guild channels only once they have a
+// read_state row.
+func (s *Server) sendReadStateSummary(ctx context.Context, client *Client) {
+	if s.pool == nil {
+		return
+	}
+
+	rows, err := s.pool.Query(ctx,
+		`WITH visible AS (
+			SELECT c.id, c.guild_id, c.channel_type, c.last_message_id
+			FROM channels c
+			JOIN read_state rs ON rs.channel_id = c.id AND rs.user_id = $1
+			JOIN guild_members gm ON gm.guild_id = c.guild_id AND gm.user_id = $1
+			WHERE c.last_message_id IS NOT NULL
+			UNION
+			SELECT c.id, c.guild_id, c.channel_type, c.last_message_id
+			FROM channels c
+			JOIN channel_recipients cr ON cr.channel_id = c.id AND cr.user_id = $1
+			WHERE c.last_message_id IS NOT NULL
+		)
+		SELECT v.id, v.guild_id, v.channel_type, v.last_message_id, rs.last_read_id,
+		       COALESCE(rs.mention_count, 0), lm.author_id, lm.created_at,
+		       (SELECT COUNT(*) FROM (
+		            SELECT 1 FROM messages m
+		            WHERE m.channel_id = v.id AND m.author_id <> $1
+		              AND (rs.last_read_id IS NULL OR m.id > rs.last_read_id)
+		            LIMIT $2) u)
+		FROM visible v
+		LEFT JOIN read_state rs ON rs.channel_id = v.id AND rs.user_id = $1
+		LEFT JOIN messages lm ON lm.id = v.last_message_id
+		WHERE rs.last_read_id IS NULL OR v.last_message_id > rs.last_read_id
+		   OR COALESCE(rs.mention_count, 0) > 0
+		ORDER BY v.last_message_id DESC
+		LIMIT $3`,
+		client.userID, maxUnreadCount, maxReadStateChannels)
+	if err != nil {
+		s.logger.Warn("failed to load read state summary",
+			slog.String("user_id", client.userID), slog.String("error", err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	channels := make([]channelReadState, 0)
+	for rows.Next() {
+		var ch channelReadState
+		if err := rows.Scan(&ch.ChannelID, &ch.GuildID, &ch.ChannelType, &ch.LastMessageID,
+			&ch.LastReadID, &ch.MentionCount, &ch.LastAuthorID, &ch.LastMessageAt,
+			&ch.UnreadCount); err != nil {
+			continue
+		}
+		if ch.UnreadCount == 0 && ch.MentionCount == 0 {
+			continue // only the user's own messages since last read
+		}
+		if ch.GuildID != nil && client.filter != nil && client.filter.guilds != nil &&
+			!client.filter.guilds[*ch.GuildID] {
+			continue
+		}
+		channels = append(channels, ch)
+	}
+
+	data, _ := json.Marshal(summarizeReadStates(channels))
+	s.sendMessage(client, GatewayMessage{
+		Op:   OpDispatch,
+		Type: "READ_STATE_SUMMARY",
+		Data: data,
+	})
+}
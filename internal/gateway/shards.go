@@ -0,0 +1,221 @@
+package gateway
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+const (
+	// shardQueueSize is the per-shard event buffer. When it fills, the NATS
+	// callback blocks until the shard catches up rather than dropping events.
+	shardQueueSize = 4096
+
+	// clientsPerShard is the connection count each shard is sized for when
+	// resharding automatically.
+	clientsPerShard = 5000
+
+	// reshardCheckInterval is how often the connection count is compared
+	// against the current shard count.
+	reshardCheckInterval = 30 * time.Second
+)
+
+// shardedEvent is an event waiting in a shard queue.
+type shardedEvent struct {
+	subject  string
+	event    events.Event
+	enqueued time.Time
+}
+
+// dispatchShard is one fan-out worker. Events with the same key always land
+// on the same shard, so per-guild ordering is preserved while different
+// guilds fan out in parallel.
+type dispatchShard struct {
+	queue chan shardedEvent
+	done  chan struct{}
+
+	dispatched atomic.Int64
+	stalled    atomic.Int64 // enqueues that found the queue full
+	lastLag    atomic.Int64 // nanoseconds from enqueue to dispatch
+	busyNanos  atomic.Int64 // total time spent fanning out
+}
+
+// ShardStats is a snapshot of one dispatch shard's counters.
+type ShardStats struct {
+	Shard      int
+	Queued     int
+	Dispatched int64
+	Stalled    int64
+	LastLag    time.Duration
+	Busy       time.Duration
+}
+
+// startShards creates n dispatch shards and starts their workers.
+func (s *Server) startShards(n int) {
+	if n < 1 {
+		n = 1
+	}
+	shards := make([]*dispatchShard, n)
+	for i := range shards {
+		shards[i] = &dispatchShard{
+			queue: make(chan shardedEvent, shardQueueSize),
+			done:  make(chan struct{}),
+		}
+		go s.runShard(shards[i])
+	}
+	s.shards = shards
+}
+
+// runShard dispatches queued events until the shard's queue is closed.
+func (s *Server) runShard(shard *dispatchShard) {
+	defer close(shard.done)
+	for ev := range shard.queue {
+		start := time.Now()
+		shard.lastLag.Store(int64(start.Sub(ev.enqueued)))
+		s.dispatchEvent(ev.subject, ev.event)
+		shard.busyNanos.Add(int64(time.Since(start)))
+		shard.dispatched.Add(1)
+	}
+}
+
+// enqueueEvent routes an event from the bus to its shard. Without shards
+// (e.g. in tests) the event is dispatched inline.
+func (s *Server) enqueueEvent(subject string, event events.Event) {
+	s.shardsMu.RLock()
+	defer s.shardsMu.RUnlock()
+
+	if len(s.shards) == 0 {
+		s.dispatchEvent(subject, event)
+		return
+	}
+
+	shard := s.shards[shardIndex(s.shardKey(event), len(s.shards))]
+	ev := shardedEvent{subject: subject, event: event, enqueued: time.Now()}
+	select {
+	case shard.queue <- ev:
+	default:
+		shard.stalled.Add(1)
+		shard.queue <- ev
+	}
+}
+
+// shardKey picks the partition key for an event: its guild, the guild that
+// owns its channel, then the channel itself (DMs) or target user. Events
+// without any routing field share the empty key.
+func (s *Server) shardKey(event events.Event) string {
+	if event.GuildID != "" {
+		return event.GuildID
+	}
+	if event.ChannelID != "" {
+		if s.pool != nil {
+			if gid := s.lookupChannelGuild(event.ChannelID); gid != nil {
+				return *gid
+			}
+		}
+		return event.ChannelID
+	}
+	return event.UserID
+}
+
+// shardIndex maps a key onto one of n shards.
+func shardIndex(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// Reshard replaces the dispatch shards with n new ones. Old shards are drained
+// before the new ones accept events, so no key is processed out of order;
+// publishers block for the duration of the drain.
+func (s *Server) Reshard(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.shardsMu.Lock()
+	defer s.shardsMu.Unlock()
+
+	old := s.shards
+	if len(old) == n {
+		return
+	}
+	for _, shard := range old {
+		close(shard.queue)
+	}
+	for _, shard := range old {
+		<-shard.done
+	}
+	s.startShards(n)
+
+	s.logger.Info("gateway dispatch resharded",
+		slog.Int("from", len(old)), slog.Int("to", n))
+}
+
+// ShardStats returns a snapshot of every dispatch shard's counters.
+func (s *Server) ShardStats() []ShardStats {
+	s.shardsMu.RLock()
+	defer s.shardsMu.RUnlock()
+
+	stats := make([]ShardStats, len(s.shards))
+	for i, shard := range s.shards {
+		stats[i] = ShardStats{
+			Shard:      i,
+			Queued:     len(shard.queue),
+			Dispatched: shard.dispatched.Load(),
+			Stalled:    shard.stalled.Load(),
+			LastLag:    time.Duration(shard.lastLag.Load()),
+			Busy:       time.Duration(shard.busyNanos.Load()),
+		}
+	}
+	return stats
+}
+
+// targetShards returns the shard count for the given number of connections,
+// never below min and never above max.
+func targetShards(clients, min, max int) int {
+	n := (clients + clientsPerShard - 1) / clientsPerShard
+	if n < min {
+		n = min
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+// autoReshard grows the shard count as connections increase, up to
+// maxShards. It never shrinks below the current count: resharding stalls
+// dispatch briefly, so it should not flap as clients come and go.
+func (s *Server) autoReshard(ctx context.Context, maxShards int) {
+	ticker := time.NewTicker(reshardCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.shardsMu.RLock()
+			current := len(s.shards)
+			s.shardsMu.RUnlock()
+			if want := targetShards(s.ClientCount(), current, maxShards); want > current {
+				s.Reshard(want)
+			}
+		}
+	}
+}
+
+// stopShards drains and stops all dispatch shards.
+func (s *Server) stopShards() {
+	s.shardsMu.Lock()
+	defer s.shardsMu.Unlock()
+	for _, shard := range s.shards {
+		close(shard.queue)
+	}
+	for _, shard := range s.shards {
+		<-shard.done
+	}
+	s.shards = nil
+}
@@ -0,0 +1,627 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discord exports are read in two ways: from a ZIP of DiscordChatExporter
+// JSON files (one file per channel, optionally with downloaded media), or
+// live from the Discord API with a bot token that can read the server.
+
+// dceExport is one DiscordChatExporter JSON file.
+type dceExport struct {
+	Guild struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"guild"`
+	Channel struct {
+		ID         string  `json:"id"`
+		Type       string  `json:"type"`
+		CategoryID string  `json:"categoryId"`
+		Category   string  `json:"category"`
+		Name       string  `json:"name"`
+		Topic      *string `json:"topic"`
+	} `json:"channel"`
+	Messages []dceMessage `json:"messages"`
+}
+
+type dceMessage struct {
+	ID              string     `json:"id"`
+	Type            string     `json:"type"`
+	Timestamp       time.Time  `json:"timestamp"`
+	TimestampEdited *time.Time `json:"timestampEdited"`
+	IsPinned        bool       `json:"isPinned"`
+	Content         string     `json:"content"`
+	Author          struct {
+		ID        string    `json:"id"`
+		Name      string    `json:"name"`
+		Nickname  string    `json:"nickname"`
+		Color     *string   `json:"color"`
+		AvatarURL string    `json:"avatarUrl"`
+		Roles     []dceRole `json:"roles"`
+	} `json:"author"`
+	Attachments []struct {
+		URL      string `json:"url"`
+		FileName string `json:"fileName"`
+	} `json:"attachments"`
+	Reference *struct {
+		MessageID string `json:"messageId"`
+	} `json:"reference"`
+	InlineEmojis []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		IsAnimated bool   `json:"isAnimated"`
+		ImageURL   string `json:"imageUrl"`
+	} `json:"inlineEmojis"`
+}
+
+type dceRole struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Color    *string `json:"color"`
+	Position int     `json:"position"`
+}
+
+// dceChannelTypes maps DiscordChatExporter channel types to AmityVox ones.
+// Threads and unsupported types are not imported.
+var dceChannelTypes = map[string]string{
+	"GuildTextChat":     "text",
+	"GuildNewsChat":     "announcement",
+	"GuildAnnouncement": "announcement",
+	"GuildVoiceChat":    "voice",
+	"GuildStageVoice":   "stage",
+}
+
+// discordExport reads a ZIP of DiscordChatExporter JSON files.
+type discordExport struct {
+	zip    *zip.Reader
+	closer io.Closer
+	files  map[string]string // remote channel ID -> JSON file name
+}
+
+// openDiscordExport opens an uploaded export archive.
+func (s *Service) openDiscordExport(ctx context.Context, archiveID string) (Source, error) {
+	if s.media == nil {
+		return nil, errors.New("media storage is not configured")
+	}
+	obj, _, size, err := s.media.OpenFile(ctx, archiveID)
+	if err != nil {
+		return nil, fmt.Errorf("opening export archive: %w", err)
+	}
+	zr, err := openZip(obj, size)
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+	return &discordExport{zip: zr, closer: obj, files: make(map[string]string)}, nil
+}
+
+// openZip reads a ZIP archive, buffering it in memory if r cannot seek to
+// arbitrary offsets itself.
+func openZip(r io.ReadSeeker, size int64) (*zip.Reader, error) {
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading export archive: %w", err)
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, fmt.Errorf("export archive is not a ZIP file: %w", err)
+	}
+	return zr, nil
+}
+
+func (d *discordExport) Close() error { return d.closer.Close() }
+
+// readFile decodes one JSON file from the archive.
+func (d *discordExport) readFile(name string) (*dceExport, error) {
+	f, err := d.zip.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var exp dceExport
+	if err := json.NewDecoder(f).Decode(&exp); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return &exp, nil
+}
+
+// Load scans every channel file. DiscordChatExporter has no server-level
+// file, so roles and emoji are collected from the messages themselves.
+func (d *discordExport) Load(ctx context.Context) (*Archive, error) {
+	names := make([]string, 0)
+	for _, f := range d.zip.File {
+		if strings.HasSuffix(strings.ToLower(f.Name), ".json") && !f.FileInfo().IsDir() {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+
+	c := newDCECollector()
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		exp, err := d.readFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if exp.Channel.ID == "" {
+			continue // not a channel export
+		}
+		d.files[exp.Channel.ID] = name
+		c.add(exp, d.fileOpener(name))
+	}
+	if len(d.files) == 0 {
+		return nil, errors.New("the archive contains no DiscordChatExporter JSON files")
+	}
+	return c.archive(), nil
+}
+
+// Messages decodes a channel's file and yields its messages in batches.
+func (d *discordExport) Messages(ctx context.Context, ch Channel, fn func([]Message) error) error {
+	name, ok := d.files[ch.RemoteID]
+	if !ok {
+		return ErrChannelUnavailable
+	}
+	exp, err := d.readFile(name)
+	if err != nil {
+		return err
+	}
+	open := d.fileOpener(name)
+
+	const batchSize = 100
+	batch := make([]Message, 0, batchSize)
+	for _, m := range exp.Messages {
+		msg, ok := convertDCEMessage(m, open)
+		if !ok {
+			continue
+		}
+		batch = append(batch, msg)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]Message, 0, batchSize)
+		}
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// fileOpener resolves a media reference in a channel file. Exports made with
+// downloaded media refer to files by a path relative to the JSON file; those
+// are read from the archive. Anything else is left to be downloaded.
+func (d *discordExport) fileOpener(jsonName string) func(ref, name string) File {
+	dir := path.Dir(jsonName)
+	return func(ref, name string) File {
+		f := File{Name: name, URL: ref, ContentType: mime.TypeByExtension(path.Ext(name))}
+		if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+			return f
+		}
+		rel, err := url.PathUnescape(strings.ReplaceAll(ref, `\`, "/"))
+		if err != nil {
+			rel = ref
+		}
+		p := path.Clean(path.Join(dir, rel))
+		f.Open = func(context.Context) (io.ReadCloser, error) { return d.zip.Open(p) }
+		return f
+	}
+}
+
+// dceCollector gathers the guild structure spread across channel files.
+type dceCollector struct {
+	roles      map[string]dceRole
+	categories []Category
+	catSeen    map[string]bool
+	channels   []Channel
+	emoji      []Emoji
+	emojiSeen  map[string]bool
+}
+
+func newDCECollector() *dceCollector {
+	return &dceCollector{
+		roles:     make(map[string]dceRole),
+		catSeen:   make(map[string]bool),
+		emojiSeen: make(map[string]bool),
+	}
+}
+
+func (c *dceCollector) add(exp *dceExport, open func(ref, name string) File) {
+	chType, ok := dceChannelTypes[exp.Channel.Type]
+	if !ok {
+		return
+	}
+	if id := exp.Channel.CategoryID; id != "" && !c.catSeen[id] {
+		c.catSeen[id] = true
+		c.categories = append(c.categories, Category{
+			RemoteID: id, Name: exp.Channel.Category, Position: len(c.categories),
+		})
+	}
+	c.channels = append(c.channels, Channel{
+		RemoteID:         exp.Channel.ID,
+		Name:             exp.Channel.Name,
+		Type:             chType,
+		Topic:            exp.Channel.Topic,
+		CategoryRemoteID: exp.Channel.CategoryID,
+		Position:         len(c.channels),
+	})
+
+	for _, m := range exp.Messages {
+		for _, r := range m.Author.Roles {
+			if r.ID != "" && r.ID != exp.Guild.ID {
+				c.roles[r.ID] = r
+			}
+		}
+		for _, e := range m.InlineEmojis {
+			if e.ID == "" || c.emojiSeen[e.ID] {
+				continue // Unicode emoji, or already collected
+			}
+			c.emojiSeen[e.ID] = true
+			ext := ".png"
+			if e.IsAnimated {
+				ext = ".gif"
+			}
+			c.emoji = append(c.emoji, Emoji{
+				RemoteID: e.ID, Name: e.Name, Animated: e.IsAnimated,
+				Image: open(e.ImageURL, e.Name+ext),
+			})
+		}
+	}
+}
+
+// archive returns the collected structure. Discord ranks roles highest
+// position first, the reverse of AmityVox.
+func (c *dceCollector) archive() *Archive {
+	roles := make([]dceRole, 0, len(c.roles))
+	for _, r := range c.roles {
+		roles = append(roles, r)
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		if roles[i].Position != roles[j].Position {
+			return roles[i].Position > roles[j].Position
+		}
+		return roles[i].ID < roles[j].ID
+	})
+	arc := &Archive{Categories: c.categories, Channels: c.channels, Emoji: c.emoji}
+	for i, r := range roles {
+		arc.Roles = append(arc.Roles, Role{RemoteID: r.ID, Name: r.Name, Color: r.Color, Position: i})
+	}
+	return arc
+}
+
+// convertDCEMessage converts an exported message, reporting false for
+// system messages (joins, pin notices, calls) that are not imported.
+func convertDCEMessage(m dceMessage, open func(ref, name string) File) (Message, bool) {
+	if m.Type != "Default" && m.Type != "Reply" {
+		return Message{}, false
+	}
+	name := m.Author.Nickname
+	if name == "" {
+		name = m.Author.Name
+	}
+	msg := Message{
+		RemoteID:    m.ID,
+		AuthorName:  name,
+		AuthorColor: m.Author.Color,
+		Content:     m.Content,
+		CreatedAt:   m.Timestamp,
+		EditedAt:    m.TimestampEdited,
+		Pinned:      m.IsPinned,
+	}
+	if strings.HasPrefix(m.Author.AvatarURL, "https://") {
+		avatar := m.Author.AvatarURL
+		msg.AuthorAvatar = &avatar
+	}
+	if m.Reference != nil {
+		msg.ReplyToRemoteID = m.Reference.MessageID
+	}
+	for _, a := range m.Attachments {
+		msg.Files = append(msg.Files, open(a.URL, a.FileName))
+	}
+	return msg, true
+}
+
+// discordAPIBase is the Discord REST API root.
+const discordAPIBase = "https://discord.com/api/v10"
+
+// discordAPI reads a server live through the Discord API.
+type discordAPI struct {
+	http    *http.Client
+	base    string
+	token   string
+	guildID string
+}
+
+func newDiscordAPI(client *http.Client, token, guildID string) *discordAPI {
+	return &discordAPI{http: client, base: discordAPIBase, token: token, guildID: guildID}
+}
+
+func (d *discordAPI) Close() error { return nil }
+
+// discordStatusError is a non-2xx response from the Discord API.
+type discordStatusError struct {
+	Path   string
+	Status int
+}
+
+func (e *discordStatusError) Error() string {
+	return fmt.Sprintf("discord API %s: status %d", e.Path, e.Status)
+}
+
+// get fetches an API path into out, waiting out rate limits.
+func (d *discordAPI) get(ctx context.Context, p string, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.base+p, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bot "+d.token)
+		req.Header.Set("User-Agent", "AmityVox-Importer (https://github.com/amityvox/amityvox, 1)")
+		resp, err := d.http.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < 5 {
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&limit)
+			resp.Body.Close()
+			wait := time.Duration(limit.RetryAfter * float64(time.Second))
+			if wait <= 0 {
+				wait = time.Second
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return &discordStatusError{Path: p, Status: resp.StatusCode}
+		}
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
+
+type discordRole struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Color       int    `json:"color"`
+	Hoist       bool   `json:"hoist"`
+	Position    int    `json:"position"`
+	Mentionable bool   `json:"mentionable"`
+	Managed     bool   `json:"managed"`
+}
+
+type discordChannel struct {
+	ID       string  `json:"id"`
+	Type     int     `json:"type"`
+	Name     string  `json:"name"`
+	Topic    *string `json:"topic"`
+	Position int     `json:"position"`
+	ParentID *string `json:"parent_id"`
+	NSFW     bool    `json:"nsfw"`
+}
+
+type discordEmoji struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Animated bool   `json:"animated"`
+}
+
+type discordMessage struct {
+	ID              string     `json:"id"`
+	Type            int        `json:"type"`
+	Content         string     `json:"content"`
+	Timestamp       time.Time  `json:"timestamp"`
+	EditedTimestamp *time.Time `json:"edited_timestamp"`
+	Pinned          bool       `json:"pinned"`
+	Author          struct {
+		ID         string  `json:"id"`
+		Username   string  `json:"username"`
+		GlobalName *string `json:"global_name"`
+		Avatar     *string `json:"avatar"`
+	} `json:"author"`
+	Attachments []struct {
+		Filename    string `json:"filename"`
+		URL         string `json:"url"`
+		ContentType string `json:"content_type"`
+	} `json:"attachments"`
+	MessageReference *struct {
+		MessageID string `json:"message_id"`
+	} `json:"message_reference"`
+}
+
+// Discord channel types.
+const (
+	discordChannelText         = 0
+	discordChannelVoice        = 2
+	discordChannelCategory     = 4
+	discordChannelAnnouncement = 5
+	discordChannelStage        = 13
+	discordChannelForum        = 15
+)
+
+// discordChannelTypes maps Discord channel types to AmityVox ones.
+var discordChannelTypes = map[int]string{
+	discordChannelText:         "text",
+	discordChannelVoice:        "voice",
+	discordChannelAnnouncement: "announcement",
+	discordChannelStage:        "stage",
+	discordChannelForum:        "forum",
+}
+
+func (d *discordAPI) Load(ctx context.Context) (*Archive, error) {
+	var roles []discordRole
+	if err := d.get(ctx, "/guilds/"+d.guildID+"/roles", &roles); err != nil {
+		return nil, err
+	}
+	var channels []discordChannel
+	if err := d.get(ctx, "/guilds/"+d.guildID+"/channels", &channels); err != nil {
+		return nil, err
+	}
+	var emoji []discordEmoji
+	if err := d.get(ctx, "/guilds/"+d.guildID+"/emojis", &emoji); err != nil {
+		return nil, err
+	}
+	return convertDiscordGuild(d.guildID, roles, channels, emoji), nil
+}
+
+// convertDiscordGuild builds an Archive from Discord API objects. Managed
+// (integration) roles and @everyone, whose ID is the guild's, are skipped.
+func convertDiscordGuild(guildID string, roles []discordRole, channels []discordChannel, emoji []discordEmoji) *Archive {
+	arc := &Archive{}
+
+	sort.SliceStable(roles, func(i, j int) bool { return roles[i].Position > roles[j].Position })
+	for _, r := range roles {
+		if r.Managed || r.ID == guildID {
+			continue
+		}
+		role := Role{
+			RemoteID: r.ID, Name: r.Name, Hoist: r.Hoist, Mentionable: r.Mentionable,
+			Position: len(arc.Roles),
+		}
+		if r.Color != 0 {
+			color := fmt.Sprintf("#%06x", r.Color)
+			role.Color = &color
+		}
+		arc.Roles = append(arc.Roles, role)
+	}
+
+	sort.SliceStable(channels, func(i, j int) bool { return channels[i].Position < channels[j].Position })
+	for _, c := range channels {
+		if c.Type == discordChannelCategory {
+			arc.Categories = append(arc.Categories, Category{RemoteID: c.ID, Name: c.Name, Position: c.Position})
+			continue
+		}
+		chType, ok := discordChannelTypes[c.Type]
+		if !ok {
+			continue
+		}
+		ch := Channel{RemoteID: c.ID, Name: c.Name, Type: chType, Topic: c.Topic, Position: c.Position, NSFW: c.NSFW}
+		if c.ParentID != nil {
+			ch.CategoryRemoteID = *c.ParentID
+		}
+		arc.Channels = append(arc.Channels, ch)
+	}
+
+	for _, e := range emoji {
+		ext := ".png"
+		if e.Animated {
+			ext = ".gif"
+		}
+		arc.Emoji = append(arc.Emoji, Emoji{
+			RemoteID: e.ID, Name: e.Name, Animated: e.Animated,
+			Image: File{
+				Name:        e.Name + ext,
+				ContentType: mime.TypeByExtension(ext),
+				URL:         "https://cdn.discordapp.com/emojis/" + e.ID + ext,
+			},
+		})
+	}
+	return arc
+}
+
+// Messages pages through a channel's history oldest first. Channels the bot
+// cannot read are reported as unavailable.
+func (d *discordAPI) Messages(ctx context.Context, ch Channel, fn func([]Message) error) error {
+	after := "0"
+	for {
+		var page []discordMessage
+		err := d.get(ctx, "/channels/"+ch.RemoteID+"/messages?limit=100&after="+after, &page)
+		var statusErr *discordStatusError
+		if errors.As(err, &statusErr) && (statusErr.Status == http.StatusForbidden || statusErr.Status == http.StatusNotFound) {
+			return ErrChannelUnavailable
+		}
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		sort.Slice(page, func(i, j int) bool { return snowflakeLess(page[i].ID, page[j].ID) })
+		batch := make([]Message, 0, len(page))
+		for _, m := range page {
+			if msg, ok := convertDiscordMessage(m); ok {
+				batch = append(batch, msg)
+			}
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		after = page[len(page)-1].ID
+		if len(page) < 100 {
+			return nil
+		}
+	}
+}
+
+// convertDiscordMessage converts an API message, reporting false for system
+// messages. Only default (0) and reply (19) messages are imported.
+func convertDiscordMessage(m discordMessage) (Message, bool) {
+	if m.Type != 0 && m.Type != 19 {
+		return Message{}, false
+	}
+	name := m.Author.Username
+	if m.Author.GlobalName != nil && *m.Author.GlobalName != "" {
+		name = *m.Author.GlobalName
+	}
+	msg := Message{
+		RemoteID:   m.ID,
+		AuthorName: name,
+		Content:    m.Content,
+		CreatedAt:  m.Timestamp,
+		EditedAt:   m.EditedTimestamp,
+		Pinned:     m.Pinned,
+	}
+	if m.Author.Avatar != nil {
+		avatar := "https://cdn.discordapp.com/avatars/" + m.Author.ID + "/" + *m.Author.Avatar + ".png"
+		msg.AuthorAvatar = &avatar
+	}
+	if m.MessageReference != nil {
+		msg.ReplyToRemoteID = m.MessageReference.MessageID
+	}
+	for _, a := range m.Attachments {
+		msg.Files = append(msg.Files, File{Name: a.Filename, ContentType: a.ContentType, URL: a.URL})
+	}
+	return msg, true
+}
+
+// snowflakeLess orders Discord IDs numerically.
+func snowflakeLess(a, b string) bool {
+	x, errA := strconv.ParseUint(a, 10, 64)
+	y, errB := strconv.ParseUint(b, 10, 64)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return x < y
+}
@@ -0,0 +1,199 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+const dceGeneral = `{
+  "guild": {"id": "100", "name": "Test Server"},
+  "channel": {"id": "200", "type": "GuildTextChat", "categoryId": "300", "category": "Text Channels", "name": "general", "topic": "hello"},
+  "messages": [
+    {"id": "1001", "type": "Default", "timestamp": "2021-03-01T10:00:00+00:00", "isPinned": true, "content": "first",
+     "author": {"id": "9", "name": "alice", "nickname": "Alice", "color": "#ff0000", "avatarUrl": "https://cdn.discordapp.com/avatars/9/a.png",
+                "roles": [{"id": "100", "name": "@everyone", "position": 0}, {"id": "401", "name": "Mods", "color": "#00ff00", "position": 5}]},
+     "attachments": [{"url": "general.json_Files/cat-1A2B.png", "fileName": "cat.png"}],
+     "inlineEmojis": [{"id": "", "name": "👍"}, {"id": "501", "name": "party", "isAnimated": true, "imageUrl": "https://cdn.discordapp.com/emojis/501.gif"}]},
+    {"id": "1002", "type": "GuildMemberJoin", "timestamp": "2021-03-01T10:01:00+00:00", "content": "", "author": {"id": "8", "name": "bob"}},
+    {"id": "1003", "type": "Reply", "timestamp": "2021-03-01T10:02:00+00:00", "content": "reply", "reference": {"messageId": "1001"},
+     "author": {"id": "7", "name": "carol", "roles": [{"id": "402", "name": "Members", "position": 1}]}}
+  ]
+}`
+
+const dceVoice = `{
+  "guild": {"id": "100", "name": "Test Server"},
+  "channel": {"id": "201", "type": "GuildVoiceChat", "categoryId": "301", "category": "Voice", "name": "lounge"},
+  "messages": []
+}`
+
+func buildZip(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestDiscordExport_LoadAndMessages(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"export/general.json":                    dceGeneral,
+		"export/lounge.json":                     dceVoice,
+		"export/general.json_Files/cat-1A2B.png": "png-bytes",
+		"export/notes.txt":                       "ignored",
+	})
+	zr, err := openZip(r, r.Size())
+	if err != nil {
+		t.Fatalf("openZip: %v", err)
+	}
+	d := &discordExport{zip: zr, closer: io.NopCloser(nil), files: make(map[string]string)}
+
+	arc, err := d.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(arc.Channels) != 2 || arc.Channels[0].Name != "general" || arc.Channels[1].Type != "voice" {
+		t.Fatalf("channels = %+v", arc.Channels)
+	}
+	if len(arc.Categories) != 2 || arc.Channels[0].CategoryRemoteID != "300" {
+		t.Errorf("categories = %+v", arc.Categories)
+	}
+	// @everyone is dropped; Mods (Discord position 5) outranks Members.
+	if len(arc.Roles) != 2 || arc.Roles[0].Name != "Mods" || arc.Roles[1].Name != "Members" {
+		t.Errorf("roles = %+v", arc.Roles)
+	}
+	if len(arc.Emoji) != 1 || arc.Emoji[0].Name != "party" || !arc.Emoji[0].Animated {
+		t.Errorf("emoji = %+v", arc.Emoji)
+	}
+
+	var got []Message
+	err = d.Messages(context.Background(), arc.Channels[0], func(batch []Message) error {
+		got = append(got, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d messages, want 2 (join notice skipped)", len(got))
+	}
+	first := got[0]
+	if first.AuthorName != "Alice" || !first.Pinned || first.AuthorAvatar == nil {
+		t.Errorf("first message = %+v", first)
+	}
+	if got[1].ReplyToRemoteID != "1001" {
+		t.Errorf("reply target = %q, want 1001", got[1].ReplyToRemoteID)
+	}
+
+	if len(first.Files) != 1 || first.Files[0].Open == nil {
+		t.Fatalf("expected attachment to be read from the archive, got %+v", first.Files)
+	}
+	rc, err := first.Files[0].Open(context.Background())
+	if err != nil {
+		t.Fatalf("opening attachment: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "png-bytes" {
+		t.Errorf("attachment data = %q", data)
+	}
+}
+
+func TestDiscordExport_NoChannelFiles(t *testing.T) {
+	r := buildZip(t, map[string]string{"readme.txt": "nothing here"})
+	zr, err := openZip(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &discordExport{zip: zr, files: make(map[string]string)}
+	if _, err := d.Load(context.Background()); err == nil {
+		t.Error("expected an error for an archive without channel exports")
+	}
+}
+
+func TestConvertDiscordGuild(t *testing.T) {
+	topic := "rules"
+	parent := "10"
+	arc := convertDiscordGuild("1",
+		[]discordRole{
+			{ID: "1", Name: "@everyone", Position: 0},
+			{ID: "2", Name: "Members", Position: 1},
+			{ID: "3", Name: "Admins", Position: 3, Color: 0xff8800, Hoist: true},
+			{ID: "4", Name: "SomeBot", Position: 2, Managed: true},
+		},
+		[]discordChannel{
+			{ID: "11", Type: discordChannelText, Name: "general", Topic: &topic, Position: 1, ParentID: &parent},
+			{ID: "10", Type: discordChannelCategory, Name: "Info", Position: 0},
+			{ID: "12", Type: 11, Name: "a-thread", Position: 2},
+			{ID: "13", Type: discordChannelVoice, Name: "Voice", Position: 3},
+		},
+		[]discordEmoji{{ID: "50", Name: "wave", Animated: true}},
+	)
+
+	if len(arc.Roles) != 2 || arc.Roles[0].Name != "Admins" || arc.Roles[1].Name != "Members" {
+		t.Fatalf("roles = %+v", arc.Roles)
+	}
+	if arc.Roles[0].Color == nil || *arc.Roles[0].Color != "#ff8800" {
+		t.Errorf("Admins color = %v", arc.Roles[0].Color)
+	}
+	if arc.Roles[1].Color != nil {
+		t.Errorf("Members should have no color, got %v", *arc.Roles[1].Color)
+	}
+	if len(arc.Categories) != 1 || arc.Categories[0].RemoteID != "10" {
+		t.Errorf("categories = %+v", arc.Categories)
+	}
+	if len(arc.Channels) != 2 || arc.Channels[0].CategoryRemoteID != "10" || arc.Channels[1].Type != "voice" {
+		t.Errorf("channels = %+v", arc.Channels)
+	}
+	if len(arc.Emoji) != 1 || arc.Emoji[0].Image.URL != "https://cdn.discordapp.com/emojis/50.gif" {
+		t.Errorf("emoji = %+v", arc.Emoji)
+	}
+}
+
+func TestConvertDiscordMessage(t *testing.T) {
+	global := "Dana"
+	avatar := "abc"
+	m := discordMessage{ID: "5", Type: 19, Content: "hi"}
+	m.Author.ID = "42"
+	m.Author.Username = "dana"
+	m.Author.GlobalName = &global
+	m.Author.Avatar = &avatar
+	m.MessageReference = &struct {
+		MessageID string `json:"message_id"`
+	}{MessageID: "4"}
+
+	msg, ok := convertDiscordMessage(m)
+	if !ok {
+		t.Fatal("reply message should be imported")
+	}
+	if msg.AuthorName != "Dana" || msg.ReplyToRemoteID != "4" {
+		t.Errorf("msg = %+v", msg)
+	}
+	if msg.AuthorAvatar == nil || *msg.AuthorAvatar != "https://cdn.discordapp.com/avatars/42/abc.png" {
+		t.Errorf("avatar = %v", msg.AuthorAvatar)
+	}
+
+	if _, ok := convertDiscordMessage(discordMessage{ID: "6", Type: 7}); ok {
+		t.Error("member join message should be skipped")
+	}
+}
+
+func TestSnowflakeLess(t *testing.T) {
+	if !snowflakeLess("999", "1000") {
+		t.Error("999 should sort before 1000")
+	}
+	if snowflakeLess("1000", "999") {
+		t.Error("1000 should not sort before 999")
+	}
+}
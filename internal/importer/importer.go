@@ -0,0 +1,1200 @@
+// Package importer recreates guilds exported from other chat platforms. An
+// import runs as a background job: a Source reads the export (or the remote
+// platform's API) and the Service writes roles, categories, channels, emoji,
+// message history and pins into the target guild, recording progress on the
+// guild_imports row as it goes.
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/federation"
+	"github.com/amityvox/amityvox/internal/media"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Import sources.
+const (
+	SourceDiscord = "discord"
+	SourceMatrix  = "matrix"
+	SourceSlack   = "slack"
+	SourceMbox    = "mbox"
+)
+
+// ValidSources is the set of recognized import sources.
+var ValidSources = map[string]bool{
+	SourceDiscord: true,
+	SourceMatrix:  true,
+	SourceSlack:   true,
+	SourceMbox:    true,
+}
+
+// Author modes for imported messages.
+const (
+	// AuthorMasquerade posts every message as the requesting user, shown
+	// under the remote author's name and avatar.
+	AuthorMasquerade = "masquerade"
+
+	// AuthorStub posts each remote author's messages as a local stub user
+	// that cannot log in.
+	AuthorStub = "stub"
+)
+
+const (
+	// maxFileSize bounds a single attachment or emoji image fetched during an
+	// import. Larger files are skipped and linked in the message instead.
+	maxFileSize = 25 << 20
+
+	// maxContentLength is the longest message body AmityVox stores; longer
+	// imported messages are truncated.
+	maxContentLength = 4000
+
+	// staleAfter is how long a running job may go without a progress update
+	// before another worker assumes its runner died and requeues it.
+	staleAfter = 10 * time.Minute
+)
+
+// Import stages reported in Progress.Stage.
+const (
+	StageLoading   = "loading"
+	StagePlanning  = "planning"
+	StageStructure = "structure"
+	StageEmoji     = "emoji"
+	StageMessages  = "messages"
+	StageDone      = "done"
+)
+
+// ErrCancelled is returned when a job is cancelled while it runs.
+var ErrCancelled = errors.New("import cancelled")
+
+// ErrChannelUnavailable is returned by a Source when one channel's history
+// cannot be read (e.g. the bot lacks access). The channel is skipped.
+var ErrChannelUnavailable = errors.New("channel history unavailable")
+
+// Options are the settings an import was requested with.
+type Options struct {
+	// Channels limits the import to these remote channel IDs. Empty imports
+	// every channel in the export.
+	Channels        []string `json:"channels,omitempty"`
+	SkipMessages    bool     `json:"skip_messages,omitempty"`
+	SkipAttachments bool     `json:"skip_attachments,omitempty"`
+	SkipEmoji       bool     `json:"skip_emoji,omitempty"`
+
+	// DryRun reads the export and reports what would be created in
+	// Progress.Plan without writing anything to the guild.
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// TargetChannelID backfills an existing channel instead of recreating
+	// the export's structure. The export must then hold exactly one channel.
+	TargetChannelID string `json:"target_channel_id,omitempty"`
+
+	// AuthorMode is AuthorMasquerade (the default) or AuthorStub.
+	AuthorMode string `json:"author_mode,omitempty"`
+
+	// DiscordGuildID is the server to read when importing through the
+	// Discord API with a bot token.
+	DiscordGuildID string `json:"discord_guild_id,omitempty"`
+
+	// MatrixHomeserver and MatrixRoomID locate the room to read when
+	// importing through the Matrix client API with an access token.
+	MatrixHomeserver string `json:"matrix_homeserver,omitempty"`
+	MatrixRoomID     string `json:"matrix_room_id,omitempty"`
+}
+
+// Progress is written to guild_imports.progress and sent to the guild in
+// GUILD_IMPORT_PROGRESS events.
+type Progress struct {
+	Stage           string `json:"stage"`
+	Roles           int    `json:"roles"`
+	Categories      int    `json:"categories"`
+	Channels        int    `json:"channels"`
+	Emoji           int    `json:"emoji"`
+	ChannelsTotal   int    `json:"channels_total"`
+	ChannelsDone    int    `json:"channels_done"`
+	CurrentChannel  string `json:"current_channel,omitempty"`
+	Messages        int    `json:"messages"`
+	Threads         int    `json:"threads"`
+	Attachments     int    `json:"attachments"`
+	Pins            int    `json:"pins"`
+	SkippedChannels int    `json:"skipped_channels"`
+	SkippedMessages int    `json:"skipped_messages"`
+	SkippedEmoji    int    `json:"skipped_emoji"`
+	FailedFiles     int    `json:"failed_files"`
+	Plan            *Plan  `json:"plan,omitempty"`
+}
+
+// Plan is the dry-run report of an import. Channels lists every channel in
+// the export so the requester can pick which ones to import; history is
+// only counted for the selected ones.
+type Plan struct {
+	Roles      []string      `json:"roles"`
+	Categories []string      `json:"categories"`
+	Emoji      []string      `json:"emoji"`
+	Channels   []PlanChannel `json:"channels"`
+	Authors    int           `json:"authors"`
+}
+
+// PlanChannel is one channel in a Plan.
+type PlanChannel struct {
+	RemoteID    string `json:"remote_id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Selected    bool   `json:"selected"`
+	Unavailable bool   `json:"unavailable,omitempty"`
+	Messages    int    `json:"messages"`
+	Threads     int    `json:"threads"`
+	Files       int    `json:"files"`
+	Pins        int    `json:"pins"`
+}
+
+// Job is a claimed import.
+type Job struct {
+	ID          string
+	GuildID     string
+	RequestedBy string
+	Source      string
+	Options     Options
+	ArchiveID   *string
+	Credentials string
+	Progress    Progress
+
+	authors map[string]string // remote author ID -> stub user ID
+}
+
+// Archive is a platform-neutral view of an exported guild. Positions use
+// AmityVox's convention: lower sorts first, and for roles lower means higher
+// priority.
+type Archive struct {
+	Roles      []Role
+	Categories []Category
+	Channels   []Channel
+	Emoji      []Emoji
+}
+
+// Role is an exported role. Permissions are not carried over; remote
+// permission models do not map onto AmityVox's bits.
+type Role struct {
+	RemoteID    string
+	Name        string
+	Color       *string
+	Hoist       bool
+	Mentionable bool
+	Position    int
+}
+
+// Category is an exported channel category.
+type Category struct {
+	RemoteID string
+	Name     string
+	Position int
+}
+
+// Channel is an exported guild channel. Type is an AmityVox channel type.
+type Channel struct {
+	RemoteID         string
+	Name             string
+	Type             string
+	Topic            *string
+	CategoryRemoteID string
+	Position         int
+	NSFW             bool
+}
+
+// Emoji is an exported custom emoji.
+type Emoji struct {
+	RemoteID string
+	Name     string
+	Animated bool
+	Image    File
+}
+
+// Message is one exported message. A message with ReplacesRemoteID set is an
+// edit: its content replaces that earlier message's instead of being posted.
+// ThreadRemoteID names the root of the thread a reply belongs to; replies
+// are imported into a thread started from that root.
+type Message struct {
+	RemoteID         string
+	AuthorRemoteID   string
+	AuthorName       string
+	AuthorAvatar     *string
+	AuthorColor      *string
+	Content          string
+	CreatedAt        time.Time
+	EditedAt         *time.Time
+	ReplyToRemoteID  string
+	ReplacesRemoteID string
+	ThreadRemoteID   string
+	Pinned           bool
+	Files            []File
+}
+
+// File is an exported file. It is read from the export when Open is set and
+// downloaded from URL otherwise.
+type File struct {
+	Name        string
+	ContentType string
+	URL         string
+	Open        func(ctx context.Context) (io.ReadCloser, error)
+}
+
+// Source reads one export.
+type Source interface {
+	// Load returns the guild's structure.
+	Load(ctx context.Context) (*Archive, error)
+
+	// Messages calls fn with successive batches of a channel's history,
+	// oldest first.
+	Messages(ctx context.Context, ch Channel, fn func([]Message) error) error
+
+	Close() error
+}
+
+// PuppetProvider maps a remote user to a local stub user, creating it and
+// adding it to the guild on first use.
+type PuppetProvider interface {
+	EnsurePuppet(ctx context.Context, guildID, remoteUserID, displayName string) (string, error)
+}
+
+// Service runs import jobs.
+type Service struct {
+	pool   *pgxpool.Pool
+	bus    *events.Bus
+	media  *media.Service
+	http   *http.Client
+	logger *slog.Logger
+
+	mu      sync.RWMutex
+	puppets map[string]PuppetProvider // by source
+}
+
+// Config holds configuration for the import service.
+type Config struct {
+	Pool   *pgxpool.Pool
+	Bus    *events.Bus
+	Media  *media.Service // nil disables archive uploads, emoji and attachments
+	Logger *slog.Logger
+}
+
+// NewService creates a new import service.
+func NewService(cfg Config) *Service {
+	return &Service{
+		pool:   cfg.Pool,
+		bus:    cfg.Bus,
+		media:  cfg.Media,
+		http:   &http.Client{Timeout: 2 * time.Minute},
+		logger: cfg.Logger,
+
+		puppets: make(map[string]PuppetProvider),
+	}
+}
+
+// SetPuppetProvider enables AuthorStub imports from source. Stub users are
+// shared with the source's live bridge, if any, so a remote user has one
+// local identity.
+func (s *Service) SetPuppetProvider(source string, p PuppetProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.puppets[source] = p
+}
+
+func (s *Service) puppetProvider(source string) PuppetProvider {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.puppets[source]
+}
+
+// RequeueStale returns running jobs whose runner has stopped reporting
+// progress to the queue. Mappings recorded by the earlier run let the
+// resumed job skip everything already imported.
+func (s *Service) RequeueStale(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE guild_imports SET status = 'pending', updated_at = now()
+		 WHERE status = 'running' AND updated_at < $1`,
+		time.Now().Add(-staleAfter))
+	if err != nil {
+		return 0, fmt.Errorf("requeueing stale imports: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ClaimNext marks the oldest pending job as running and returns it, or nil
+// if there is nothing to do.
+func (s *Service) ClaimNext(ctx context.Context) (*Job, error) {
+	var job Job
+	var requestedBy, credentials *string
+	var options, progress []byte
+	err := s.pool.QueryRow(ctx,
+		`UPDATE guild_imports SET status = 'running', started_at = COALESCE(started_at, now()), updated_at = now()
+		 WHERE id = (SELECT id FROM guild_imports WHERE status = 'pending'
+		             ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED)
+		 RETURNING id, guild_id, requested_by, source, options, archive_id, credentials, progress`,
+	).Scan(&job.ID, &job.GuildID, &requestedBy, &job.Source, &options, &job.ArchiveID, &credentials, &progress)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claiming import: %w", err)
+	}
+	if requestedBy != nil {
+		job.RequestedBy = *requestedBy
+	}
+	if credentials != nil {
+		job.Credentials = *credentials
+	}
+	json.Unmarshal(options, &job.Options)
+	json.Unmarshal(progress, &job.Progress)
+	return &job, nil
+}
+
+// Run executes a claimed job and records its outcome. If ctx is cancelled
+// (shutdown) the job is left running so it is requeued once stale.
+func (s *Service) Run(ctx context.Context, job *Job) {
+	err := s.run(ctx, job)
+	if ctx.Err() != nil {
+		return
+	}
+
+	status := models.ImportStatusCompleted
+	var errMsg *string
+	switch {
+	case errors.Is(err, ErrCancelled):
+		status = models.ImportStatusCancelled
+	case err != nil:
+		status = models.ImportStatusFailed
+		msg := err.Error()
+		errMsg = &msg
+		s.logger.Warn("guild import failed",
+			slog.String("import_id", job.ID), slog.String("guild_id", job.GuildID),
+			slog.String("error", msg))
+	default:
+		job.Progress.Stage = StageDone
+		job.Progress.CurrentChannel = ""
+	}
+
+	progress, _ := json.Marshal(job.Progress)
+	_, dbErr := s.pool.Exec(ctx,
+		`UPDATE guild_imports
+		 SET status = CASE WHEN status = 'cancelled' THEN status ELSE $2 END,
+		     error = $3, progress = $4, credentials = NULL,
+		     completed_at = now(), updated_at = now()
+		 WHERE id = $1`,
+		job.ID, status, errMsg, progress)
+	if dbErr != nil {
+		s.logger.Error("failed to record import outcome",
+			slog.String("import_id", job.ID), slog.String("error", dbErr.Error()))
+	}
+	s.publishProgress(ctx, job, status)
+}
+
+func (s *Service) run(ctx context.Context, job *Job) error {
+	if job.RequestedBy == "" {
+		return errors.New("the requesting user no longer exists")
+	}
+	src, err := s.open(ctx, job)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	job.Progress.Stage = StageLoading
+	if err := s.report(ctx, job); err != nil {
+		return err
+	}
+	arc, err := src.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("reading export: %w", err)
+	}
+	channels := selectChannels(arc.Channels, job.Options.Channels)
+	if job.Options.AuthorMode == AuthorStub && s.puppetProvider(job.Source) == nil {
+		return fmt.Errorf("stub authors are not available for %s imports on this instance", job.Source)
+	}
+	if job.Options.DryRun {
+		return s.plan(ctx, job, src, arc, channels)
+	}
+
+	ids, err := s.loadMappings(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+
+	if target := job.Options.TargetChannelID; target != "" {
+		if len(channels) != 1 {
+			return fmt.Errorf("backfilling a channel needs an export with exactly one channel, found %d", len(channels))
+		}
+		ids["channel:"+channels[0].RemoteID] = target
+	} else {
+		job.Progress.Stage = StageStructure
+		if err := s.importRoles(ctx, job, arc.Roles, ids); err != nil {
+			return err
+		}
+		if err := s.importCategories(ctx, job, arc.Categories, ids); err != nil {
+			return err
+		}
+		if err := s.importChannels(ctx, job, channels, ids); err != nil {
+			return err
+		}
+		if err := s.report(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	if !job.Options.SkipEmoji && s.media != nil && len(arc.Emoji) > 0 {
+		job.Progress.Stage = StageEmoji
+		if err := s.importEmoji(ctx, job, arc.Emoji, ids); err != nil {
+			return err
+		}
+		if err := s.report(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	if job.Options.SkipMessages {
+		return nil
+	}
+	job.Progress.Stage = StageMessages
+	job.Progress.ChannelsTotal = 0
+	for _, ch := range channels {
+		if hasHistory(ch.Type) {
+			job.Progress.ChannelsTotal++
+		}
+	}
+	job.Progress.ChannelsDone = 0
+	for _, ch := range channels {
+		if !hasHistory(ch.Type) {
+			continue
+		}
+		localID := ids["channel:"+ch.RemoteID]
+		job.Progress.CurrentChannel = ch.Name
+		if err := s.report(ctx, job); err != nil {
+			return err
+		}
+
+		parents := make(map[string]string)
+		err := src.Messages(ctx, ch, func(batch []Message) error {
+			if err := s.importMessages(ctx, job, localID, batch, parents, ids); err != nil {
+				return err
+			}
+			return s.report(ctx, job)
+		})
+		if errors.Is(err, ErrChannelUnavailable) {
+			job.Progress.SkippedChannels++
+		} else if err != nil {
+			return err
+		}
+		job.Progress.ChannelsDone++
+	}
+	return nil
+}
+
+// plan fills in the job's dry-run report. Counting history means reading
+// every selected channel in full, as a real import would.
+func (s *Service) plan(ctx context.Context, job *Job, src Source, arc *Archive, channels []Channel) error {
+	job.Progress.Stage = StagePlanning
+	plan := &Plan{
+		Roles:      make([]string, 0, len(arc.Roles)),
+		Categories: make([]string, 0, len(arc.Categories)),
+		Emoji:      make([]string, 0, len(arc.Emoji)),
+		Channels:   make([]PlanChannel, 0, len(arc.Channels)),
+	}
+	for _, r := range arc.Roles {
+		plan.Roles = append(plan.Roles, r.Name)
+	}
+	for _, c := range arc.Categories {
+		plan.Categories = append(plan.Categories, c.Name)
+	}
+	for _, e := range arc.Emoji {
+		plan.Emoji = append(plan.Emoji, e.Name)
+	}
+	job.Progress.Plan = plan
+
+	selected := make(map[string]bool, len(channels))
+	for _, ch := range channels {
+		selected[ch.RemoteID] = true
+	}
+	authors := make(map[string]bool)
+	for _, ch := range arc.Channels {
+		pc := PlanChannel{RemoteID: ch.RemoteID, Name: ch.Name, Type: ch.Type, Selected: selected[ch.RemoteID]}
+		if pc.Selected && hasHistory(ch.Type) && !job.Options.SkipMessages {
+			job.Progress.CurrentChannel = ch.Name
+			if err := s.report(ctx, job); err != nil {
+				return err
+			}
+			threads := make(map[string]bool)
+			err := src.Messages(ctx, ch, func(batch []Message) error {
+				for _, m := range batch {
+					if m.ReplacesRemoteID != "" {
+						continue
+					}
+					pc.Messages++
+					pc.Files += len(m.Files)
+					if m.Pinned {
+						pc.Pins++
+					}
+					if m.ThreadRemoteID != "" && m.ThreadRemoteID != m.RemoteID {
+						threads[m.ThreadRemoteID] = true
+					}
+					if m.AuthorRemoteID != "" {
+						authors[m.AuthorRemoteID] = true
+					} else {
+						authors["name:"+m.AuthorName] = true
+					}
+				}
+				return nil
+			})
+			if errors.Is(err, ErrChannelUnavailable) {
+				pc.Unavailable = true
+			} else if err != nil {
+				return err
+			}
+			pc.Threads = len(threads)
+		}
+		plan.Channels = append(plan.Channels, pc)
+	}
+	plan.Authors = len(authors)
+	return nil
+}
+
+// open returns the Source for a job.
+func (s *Service) open(ctx context.Context, job *Job) (Source, error) {
+	switch job.Source {
+	case SourceDiscord:
+		if job.ArchiveID != nil {
+			return s.openDiscordExport(ctx, *job.ArchiveID)
+		}
+		if job.Credentials == "" || job.Options.DiscordGuildID == "" {
+			return nil, errors.New("a Discord import needs an export archive or a bot token and server ID")
+		}
+		return newDiscordAPI(s.http, job.Credentials, job.Options.DiscordGuildID), nil
+	case SourceMatrix:
+		if job.ArchiveID != nil {
+			return s.openMatrixExport(ctx, *job.ArchiveID)
+		}
+		if job.Credentials == "" || job.Options.MatrixHomeserver == "" || job.Options.MatrixRoomID == "" {
+			return nil, errors.New("a Matrix import needs an export file or an access token, homeserver and room ID")
+		}
+		if err := checkHost(job.Options.MatrixHomeserver); err != nil {
+			return nil, err
+		}
+		return newMatrixAPI(s.http, job.Options.MatrixHomeserver, job.Credentials, job.Options.MatrixRoomID), nil
+	case SourceSlack:
+		if job.ArchiveID == nil {
+			return nil, errors.New("a Slack import needs a workspace export archive")
+		}
+		return s.openSlackExport(ctx, *job.ArchiveID)
+	case SourceMbox:
+		if job.ArchiveID == nil {
+			return nil, errors.New("an mbox import needs an mbox file or a ZIP of them")
+		}
+		return s.openMbox(ctx, *job.ArchiveID)
+	}
+	return nil, fmt.Errorf("unknown import source %q", job.Source)
+}
+
+// selectChannels keeps the channels whose remote IDs are in want, or all of
+// them if want is empty.
+func selectChannels(channels []Channel, want []string) []Channel {
+	if len(want) == 0 {
+		return channels
+	}
+	keep := make(map[string]bool, len(want))
+	for _, id := range want {
+		keep[id] = true
+	}
+	out := make([]Channel, 0, len(want))
+	for _, ch := range channels {
+		if keep[ch.RemoteID] {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+// hasHistory reports whether messages are imported for a channel type.
+func hasHistory(channelType string) bool {
+	return channelType == "text" || channelType == "announcement"
+}
+
+// report saves progress and notifies the guild. It returns ErrCancelled if
+// the job was cancelled since the last report.
+func (s *Service) report(ctx context.Context, job *Job) error {
+	progress, _ := json.Marshal(job.Progress)
+	tag, err := s.pool.Exec(ctx,
+		`UPDATE guild_imports SET progress = $2, updated_at = now()
+		 WHERE id = $1 AND status = 'running'`,
+		job.ID, progress)
+	if err != nil {
+		return fmt.Errorf("saving import progress: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCancelled
+	}
+	s.publishProgress(ctx, job, models.ImportStatusRunning)
+	return nil
+}
+
+func (s *Service) publishProgress(ctx context.Context, job *Job, status string) {
+	s.bus.PublishGuildEvent(ctx, events.SubjectGuildImportProgress, "GUILD_IMPORT_PROGRESS", job.GuildID, map[string]interface{}{
+		"import_id": job.ID,
+		"guild_id":  job.GuildID,
+		"source":    job.Source,
+		"status":    status,
+		"progress":  job.Progress,
+	})
+}
+
+// loadMappings returns the local IDs of everything an earlier run of the job
+// already created, keyed "kind:remote_id".
+func (s *Service) loadMappings(ctx context.Context, jobID string) (map[string]string, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT kind, remote_id, local_id FROM guild_import_mappings WHERE import_id = $1`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("loading import mappings: %w", err)
+	}
+	defer rows.Close()
+	ids := make(map[string]string)
+	for rows.Next() {
+		var kind, remoteID, localID string
+		if err := rows.Scan(&kind, &remoteID, &localID); err != nil {
+			return nil, err
+		}
+		ids[kind+":"+remoteID] = localID
+	}
+	return ids, rows.Err()
+}
+
+// remember records that remoteID became localID, inside the transaction that
+// created it.
+func remember(ctx context.Context, tx pgx.Tx, jobID, kind, remoteID, localID string) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO guild_import_mappings (import_id, kind, remote_id, local_id)
+		 VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
+		jobID, kind, remoteID, localID)
+	return err
+}
+
+// nextPosition returns the position after the last existing row of a
+// guild-scoped table, so imported items sort after existing ones.
+func (s *Service) nextPosition(ctx context.Context, table, guildID string) int {
+	var pos int
+	s.pool.QueryRow(ctx,
+		`SELECT COALESCE(MAX(position), -1) + 1 FROM `+table+` WHERE guild_id = $1`, guildID).Scan(&pos)
+	return pos
+}
+
+func (s *Service) importRoles(ctx context.Context, job *Job, roles []Role, ids map[string]string) error {
+	sort.SliceStable(roles, func(i, j int) bool { return roles[i].Position < roles[j].Position })
+	base := s.nextPosition(ctx, "roles", job.GuildID)
+	for i, r := range roles {
+		if _, done := ids["role:"+r.RemoteID]; done {
+			continue
+		}
+		role := models.Role{
+			ID:          models.NewULID().String(),
+			GuildID:     job.GuildID,
+			Name:        truncate(r.Name, 100),
+			Color:       r.Color,
+			Hoist:       r.Hoist,
+			Mentionable: r.Mentionable,
+			Position:    base + i,
+		}
+		err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+			if err := tx.QueryRow(ctx,
+				`INSERT INTO roles (id, guild_id, name, color, hoist, mentionable, position, created_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+				 RETURNING created_at`,
+				role.ID, role.GuildID, role.Name, role.Color, role.Hoist, role.Mentionable, role.Position,
+			).Scan(&role.CreatedAt); err != nil {
+				return err
+			}
+			return remember(ctx, tx, job.ID, "role", r.RemoteID, role.ID)
+		})
+		if err != nil {
+			return fmt.Errorf("creating role %q: %w", r.Name, err)
+		}
+		ids["role:"+r.RemoteID] = role.ID
+		job.Progress.Roles++
+		s.bus.PublishGuildEvent(ctx, events.SubjectGuildRoleCreate, "GUILD_ROLE_CREATE", job.GuildID, role)
+	}
+	return nil
+}
+
+func (s *Service) importCategories(ctx context.Context, job *Job, categories []Category, ids map[string]string) error {
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Position < categories[j].Position })
+	base := s.nextPosition(ctx, "guild_categories", job.GuildID)
+	for i, c := range categories {
+		if _, done := ids["category:"+c.RemoteID]; done {
+			continue
+		}
+		id := models.NewULID().String()
+		err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO guild_categories (id, guild_id, name, position, created_at)
+				 VALUES ($1, $2, $3, $4, now())`,
+				id, job.GuildID, truncate(c.Name, 100), base+i); err != nil {
+				return err
+			}
+			return remember(ctx, tx, job.ID, "category", c.RemoteID, id)
+		})
+		if err != nil {
+			return fmt.Errorf("creating category %q: %w", c.Name, err)
+		}
+		ids["category:"+c.RemoteID] = id
+		job.Progress.Categories++
+	}
+	return nil
+}
+
+func (s *Service) importChannels(ctx context.Context, job *Job, channels []Channel, ids map[string]string) error {
+	sort.SliceStable(channels, func(i, j int) bool { return channels[i].Position < channels[j].Position })
+	base := s.nextPosition(ctx, "channels", job.GuildID)
+	for i, c := range channels {
+		if _, done := ids["channel:"+c.RemoteID]; done {
+			continue
+		}
+		name := truncate(c.Name, 100)
+		ch := models.Channel{
+			ID:          models.NewULID().String(),
+			GuildID:     &job.GuildID,
+			ChannelType: c.Type,
+			Name:        &name,
+			Topic:       c.Topic,
+			Position:    base + i,
+			NSFW:        c.NSFW,
+		}
+		if catID, ok := ids["category:"+c.CategoryRemoteID]; ok && c.CategoryRemoteID != "" {
+			ch.CategoryID = &catID
+		}
+		err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+			if err := tx.QueryRow(ctx,
+				`INSERT INTO channels (id, guild_id, category_id, channel_type, name, topic, position, nsfw, created_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+				 RETURNING created_at`,
+				ch.ID, job.GuildID, ch.CategoryID, ch.ChannelType, ch.Name, ch.Topic, ch.Position, ch.NSFW,
+			).Scan(&ch.CreatedAt); err != nil {
+				return err
+			}
+			return remember(ctx, tx, job.ID, "channel", c.RemoteID, ch.ID)
+		})
+		if err != nil {
+			return fmt.Errorf("creating channel %q: %w", c.Name, err)
+		}
+		ids["channel:"+c.RemoteID] = ch.ID
+		job.Progress.Channels++
+		s.bus.PublishGuildEvent(ctx, events.SubjectChannelCreate, "CHANNEL_CREATE", job.GuildID, ch)
+	}
+	return nil
+}
+
+func (s *Service) importEmoji(ctx context.Context, job *Job, emoji []Emoji, ids map[string]string) error {
+	created := 0
+	for _, e := range emoji {
+		if _, done := ids["emoji:"+e.RemoteID]; done {
+			continue
+		}
+		name := sanitizeEmojiName(e.Name)
+		data, err := s.fetch(ctx, e.Image)
+		if err != nil || name == "" {
+			job.Progress.SkippedEmoji++
+			continue
+		}
+		att, err := s.media.StoreAttachment(ctx, job.RequestedBy, e.Image.Name, e.Image.ContentType, "", data)
+		if err != nil {
+			return fmt.Errorf("storing emoji %q: %w", e.Name, err)
+		}
+
+		id := models.NewULID().String()
+		inserted := false
+		err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+			tag, err := tx.Exec(ctx,
+				`INSERT INTO custom_emoji (id, guild_id, name, creator_id, animated, s3_key, created_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, now())
+				 ON CONFLICT (guild_id, name) DO NOTHING`,
+				id, job.GuildID, name, job.RequestedBy, e.Animated, att.S3Key)
+			if err != nil {
+				return err
+			}
+			inserted = tag.RowsAffected() > 0
+			if !inserted {
+				return nil
+			}
+			return remember(ctx, tx, job.ID, "emoji", e.RemoteID, id)
+		})
+		if err != nil {
+			return fmt.Errorf("creating emoji %q: %w", e.Name, err)
+		}
+		if !inserted {
+			// An emoji with this name already exists in the guild.
+			s.media.Delete(ctx, att.ID)
+			job.Progress.SkippedEmoji++
+			continue
+		}
+		ids["emoji:"+e.RemoteID] = id
+		job.Progress.Emoji++
+		created++
+	}
+	if created > 0 {
+		s.bus.PublishGuildEvent(ctx, events.SubjectGuildEmojiUpdate, "GUILD_EMOJI_UPDATE", job.GuildID, map[string]interface{}{
+			"guild_id": job.GuildID,
+		})
+	}
+	return nil
+}
+
+// pendingMessage is an exported message ready to insert.
+type pendingMessage struct {
+	Message
+	id            string
+	authorID      string
+	attachmentIDs []string
+}
+
+// importMessages writes one batch of a channel's history. parents maps remote
+// message IDs to local ones across batches so replies and threads resolve.
+func (s *Service) importMessages(ctx context.Context, job *Job, channelID string, batch []Message, parents, ids map[string]string) error {
+	sort.SliceStable(batch, func(i, j int) bool { return batch[i].CreatedAt.Before(batch[j].CreatedAt) })
+
+	remoteIDs := make([]string, 0, len(batch))
+	for _, m := range batch {
+		remoteIDs = append(remoteIDs, m.RemoteID)
+	}
+	rows, err := s.pool.Query(ctx,
+		`SELECT bridge_remote_id, id FROM messages
+		 WHERE (channel_id = $1 OR channel_id IN (SELECT id FROM channels WHERE parent_channel_id = $1))
+		   AND bridge_source = $2 AND bridge_remote_id = ANY($3)`,
+		channelID, job.Source, remoteIDs)
+	if err != nil {
+		return fmt.Errorf("checking imported messages: %w", err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var remoteID, id string
+		if err := rows.Scan(&remoteID, &id); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[remoteID] = true
+		parents[remoteID] = id
+	}
+	rows.Close()
+
+	pending := make([]pendingMessage, 0, len(batch))
+	edits := make([]Message, 0)
+	for _, m := range batch {
+		if m.ReplacesRemoteID != "" {
+			edits = append(edits, m)
+			continue
+		}
+		if existing[m.RemoteID] || m.RemoteID == "" || m.CreatedAt.IsZero() {
+			continue
+		}
+		authorID, err := s.author(ctx, job, m)
+		if err != nil {
+			return err
+		}
+		p := pendingMessage{Message: m, id: models.NewULIDWithTime(m.CreatedAt).String(), authorID: authorID}
+		if !job.Options.SkipAttachments && s.media != nil {
+			for _, f := range m.Files {
+				data, err := s.fetch(ctx, f)
+				if err != nil {
+					job.Progress.FailedFiles++
+					if strings.HasPrefix(f.URL, "http") {
+						p.Content = strings.TrimSpace(p.Content + "\n" + f.URL)
+					}
+					continue
+				}
+				att, err := s.media.StoreAttachment(ctx, job.RequestedBy, f.Name, f.ContentType, "", data)
+				if err != nil {
+					return fmt.Errorf("storing attachment: %w", err)
+				}
+				p.attachmentIDs = append(p.attachmentIDs, att.ID)
+			}
+		}
+		if strings.TrimSpace(p.Content) == "" && len(p.attachmentIDs) == 0 {
+			job.Progress.SkippedMessages++
+			continue
+		}
+		p.Content = truncate(p.Content, maxContentLength)
+		p.AuthorName = truncate(p.AuthorName, 80)
+		parents[m.RemoteID] = p.id
+		pending = append(pending, p)
+	}
+
+	// Thread replies go into a thread channel started from their root,
+	// which has to be stored first. Replies whose root was not imported
+	// stay in the channel.
+	inChannel := make([]pendingMessage, 0, len(pending))
+	threads := make(map[string][]pendingMessage)
+	var roots []string
+	for _, m := range pending {
+		if _, ok := parents[m.ThreadRemoteID]; !ok || m.ThreadRemoteID == m.RemoteID {
+			inChannel = append(inChannel, m)
+			continue
+		}
+		if _, ok := threads[m.ThreadRemoteID]; !ok {
+			roots = append(roots, m.ThreadRemoteID)
+		}
+		threads[m.ThreadRemoteID] = append(threads[m.ThreadRemoteID], m)
+	}
+
+	if err := s.insertMessages(ctx, job, channelID, inChannel, parents); err != nil {
+		return err
+	}
+	for _, root := range roots {
+		threadID, err := s.ensureThread(ctx, job, channelID, root, parents[root], ids)
+		if err != nil {
+			return err
+		}
+		if err := s.insertMessages(ctx, job, threadID, threads[root], parents); err != nil {
+			return err
+		}
+	}
+	return s.applyEdits(ctx, job, channelID, edits)
+}
+
+// insertMessages stores prepared messages in one channel.
+func (s *Service) insertMessages(ctx context.Context, job *Job, channelID string, pending []pendingMessage, parents map[string]string) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	pins := 0
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		b := &pgx.Batch{}
+		for _, m := range pending {
+			msgType := models.MessageTypeDefault
+			var replyTo []string
+			if parent, ok := parents[m.ReplyToRemoteID]; ok && m.ReplyToRemoteID != "" {
+				msgType = models.MessageTypeReply
+				replyTo = []string{parent}
+			}
+			// Stub authors speak for themselves; only shared authorship
+			// needs a masquerade.
+			var masqName, masqAvatar, masqColor *string
+			if m.authorID == job.RequestedBy {
+				masqName, masqAvatar, masqColor = &m.AuthorName, m.AuthorAvatar, m.AuthorColor
+			}
+			b.Queue(
+				`INSERT INTO messages (id, channel_id, author_id, content, message_type, reply_to_ids,
+				                       masquerade_name, masquerade_avatar, masquerade_color,
+				                       bridge_source, bridge_remote_id, bridge_author_name,
+				                       edited_at, created_at)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+				m.id, channelID, m.authorID, m.Content, msgType, replyTo,
+				masqName, masqAvatar, masqColor,
+				job.Source, m.RemoteID, m.AuthorName, m.EditedAt, m.CreatedAt)
+			if len(m.attachmentIDs) > 0 {
+				b.Queue(`UPDATE attachments SET message_id = $1 WHERE id = ANY($2)`, m.id, m.attachmentIDs)
+			}
+			if m.Pinned {
+				b.Queue(
+					`INSERT INTO pins (channel_id, message_id, pinned_by, pinned_at)
+					 VALUES ($1, $2, $3, now()) ON CONFLICT DO NOTHING`,
+					channelID, m.id, job.RequestedBy)
+				pins++
+			}
+		}
+		if err := tx.SendBatch(ctx, b).Close(); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx,
+			`UPDATE channels SET last_message_id = $1
+			 WHERE id = $2 AND (last_message_id IS NULL OR last_message_id < $1)`,
+			pending[len(pending)-1].id, channelID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("inserting messages: %w", err)
+	}
+
+	for _, m := range pending {
+		job.Progress.Attachments += len(m.attachmentIDs)
+	}
+	job.Progress.Messages += len(pending)
+	job.Progress.Pins += pins
+
+	s.bus.PublishChannelEvent(ctx, events.SubjectMessageImport, "MESSAGE_IMPORT", channelID, map[string]interface{}{
+		"channel_id":       channelID,
+		"guild_id":         job.GuildID,
+		"source":           job.Source,
+		"count":            len(pending),
+		"first_message_id": pending[0].id,
+		"last_message_id":  pending[len(pending)-1].id,
+	})
+	return nil
+}
+
+// ensureThread returns the thread started from an imported root message,
+// creating it on first use. Threads are named after the root's first line.
+func (s *Service) ensureThread(ctx context.Context, job *Job, parentID, rootRemoteID, rootID string, ids map[string]string) (string, error) {
+	if id, ok := ids["thread:"+rootRemoteID]; ok {
+		return id, nil
+	}
+	var content string
+	var createdAt time.Time
+	if err := s.pool.QueryRow(ctx,
+		`SELECT COALESCE(content, ''), created_at FROM messages WHERE id = $1`, rootID,
+	).Scan(&content, &createdAt); err != nil {
+		return "", fmt.Errorf("loading thread root: %w", err)
+	}
+
+	id := models.NewULIDWithTime(createdAt).String()
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO channels (id, guild_id, channel_type, name, owner_id, position,
+			                       parent_channel_id, last_activity_at, created_at)
+			 VALUES ($1, $2, 'text', $3, $4, 0, $5, $6, $6)`,
+			id, job.GuildID, threadName(content), job.RequestedBy, parentID, createdAt); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx,
+			`UPDATE messages SET thread_id = $1 WHERE id = $2`, id, rootID); err != nil {
+			return err
+		}
+		return remember(ctx, tx, job.ID, "thread", rootRemoteID, id)
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating thread: %w", err)
+	}
+	ids["thread:"+rootRemoteID] = id
+	job.Progress.Threads++
+	return id, nil
+}
+
+// threadName derives a thread name from its root message.
+func threadName(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	if line = strings.TrimSpace(line); line == "" {
+		return "Thread"
+	}
+	return truncate(line, 40)
+}
+
+// author returns the local user a message is posted as.
+func (s *Service) author(ctx context.Context, job *Job, m Message) (string, error) {
+	if job.Options.AuthorMode != AuthorStub || m.AuthorRemoteID == "" {
+		return job.RequestedBy, nil
+	}
+	if id, ok := job.authors[m.AuthorRemoteID]; ok {
+		return id, nil
+	}
+	id, err := s.puppetProvider(job.Source).EnsurePuppet(ctx, job.GuildID, m.AuthorRemoteID, m.AuthorName)
+	if err != nil {
+		return "", fmt.Errorf("creating stub user for %s: %w", m.AuthorRemoteID, err)
+	}
+	if job.authors == nil {
+		job.authors = make(map[string]string)
+	}
+	job.authors[m.AuthorRemoteID] = id
+	return id, nil
+}
+
+// applyEdits replaces the content of already imported messages. Edits of
+// messages that were not imported are ignored.
+func (s *Service) applyEdits(ctx context.Context, job *Job, channelID string, edits []Message) error {
+	for _, e := range edits {
+		editedAt := e.CreatedAt
+		if e.EditedAt != nil {
+			editedAt = *e.EditedAt
+		}
+		_, err := s.pool.Exec(ctx,
+			`UPDATE messages SET content = $1, edited_at = $2
+			 WHERE (channel_id = $3 OR channel_id IN (SELECT id FROM channels WHERE parent_channel_id = $3))
+			   AND bridge_source = $4 AND bridge_remote_id = $5
+			   AND (edited_at IS NULL OR edited_at < $2)`,
+			truncate(e.Content, maxContentLength), editedAt, channelID, job.Source, e.ReplacesRemoteID)
+		if err != nil {
+			return fmt.Errorf("applying edit: %w", err)
+		}
+	}
+	return nil
+}
+
+// fetch reads an exported file, refusing anything over maxFileSize.
+func (s *Service) fetch(ctx context.Context, f File) ([]byte, error) {
+	var body io.ReadCloser
+	if f.Open != nil {
+		rc, err := f.Open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		body = rc
+	} else {
+		if err := checkHost(f.URL); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetching %s: status %d", f.URL, resp.StatusCode)
+		}
+		body = resp.Body
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFileSize {
+		return nil, fmt.Errorf("file %q exceeds %d bytes", f.Name, maxFileSize)
+	}
+	return data, nil
+}
+
+// checkHost rejects URLs that are not http(s) or that point at private or
+// loopback addresses, since export files name arbitrary URLs.
+func checkHost(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return fmt.Errorf("unsupported file location %q", rawURL)
+	}
+	return federation.ValidateFederationDomain(u.Hostname())
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// sanitizeEmojiName reduces a remote emoji name to letters, digits and
+// underscores, capped at 32 characters. It returns "" if nothing is left.
+func sanitizeEmojiName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		}
+	}
+	out := b.String()
+	if len(out) > 32 {
+		out = out[:32]
+	}
+	return out
+}
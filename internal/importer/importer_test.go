@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectChannels(t *testing.T) {
+	channels := []Channel{{RemoteID: "a"}, {RemoteID: "b"}, {RemoteID: "c"}}
+
+	if got := selectChannels(channels, nil); len(got) != 3 {
+		t.Errorf("no selection: got %d channels, want 3", len(got))
+	}
+	got := selectChannels(channels, []string{"c", "a", "missing"})
+	if len(got) != 2 || got[0].RemoteID != "a" || got[1].RemoteID != "c" {
+		t.Errorf("selection = %+v, want a and c in export order", got)
+	}
+}
+
+func TestSanitizeEmojiName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"party_parrot", "party_parrot"},
+		{"blob-wave!", "blobwave"},
+		{"ünïcode", "ncode"},
+		{"---", ""},
+		{"abcdefghijklmnopqrstuvwxyz0123456789", "abcdefghijklmnopqrstuvwxyz012345"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeEmojiName(tt.in); got != tt.want {
+			t.Errorf("sanitizeEmojiName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 3); got != "hél" {
+		t.Errorf("truncate = %q, want %q", got, "hél")
+	}
+	if got := truncate("hi", 10); got != "hi" {
+		t.Errorf("truncate = %q, want %q", got, "hi")
+	}
+}
+
+func TestHasHistory(t *testing.T) {
+	for typ, want := range map[string]bool{"text": true, "announcement": true, "voice": false, "forum": false} {
+		if got := hasHistory(typ); got != want {
+			t.Errorf("hasHistory(%q) = %v, want %v", typ, got, want)
+		}
+	}
+}
+
+func TestThreadName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Release plan\n\nmore text", "Release plan"},
+		{"   ", "Thread"},
+		{strings.Repeat("a", 60), strings.Repeat("a", 40)},
+	}
+	for _, tt := range tests {
+		if got := threadName(tt.in); got != tt.want {
+			t.Errorf("threadName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
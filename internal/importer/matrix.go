@@ -0,0 +1,490 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Matrix history is read from a room export (Element's JSON export, as a
+// bare file or zipped together with its media) or live from a homeserver's
+// /messages endpoint with an access token. Either way the room becomes a
+// single channel, normally an existing one named by Options.TargetChannelID.
+
+// matrixEvent is a room event as it appears in exports and /messages.
+type matrixEvent struct {
+	Type           string          `json:"type"`
+	EventID        string          `json:"event_id"`
+	RoomID         string          `json:"room_id"`
+	Sender         string          `json:"sender"`
+	OriginServerTS int64           `json:"origin_server_ts"`
+	StateKey       *string         `json:"state_key"`
+	Content        json.RawMessage `json:"content"`
+}
+
+type matrixMessageContent struct {
+	MsgType  string `json:"msgtype"`
+	Body     string `json:"body"`
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Info     struct {
+		MimeType string `json:"mimetype"`
+	} `json:"info"`
+	RelatesTo *struct {
+		RelType   string `json:"rel_type"`
+		EventID   string `json:"event_id"`
+		InReplyTo *struct {
+			EventID string `json:"event_id"`
+		} `json:"m.in_reply_to"`
+	} `json:"m.relates_to"`
+	NewContent *struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	} `json:"m.new_content"`
+}
+
+// matrixConverter turns room events into messages. It follows membership
+// events to know each sender's display name at the time they spoke.
+type matrixConverter struct {
+	names map[string]string
+	media func(mxc, name, mimeType string) (File, bool)
+}
+
+func newMatrixConverter(media func(mxc, name, mimeType string) (File, bool)) *matrixConverter {
+	return &matrixConverter{names: make(map[string]string), media: media}
+}
+
+// convert returns the message for an event, or false for events that carry
+// no importable message (state, redacted, reactions, calls).
+func (c *matrixConverter) convert(ev matrixEvent) (Message, bool) {
+	switch ev.Type {
+	case "m.room.member":
+		var member struct {
+			Membership  string `json:"membership"`
+			DisplayName string `json:"displayname"`
+		}
+		if ev.StateKey != nil && json.Unmarshal(ev.Content, &member) == nil &&
+			member.Membership == "join" && member.DisplayName != "" {
+			c.names[*ev.StateKey] = member.DisplayName
+		}
+		return Message{}, false
+	case "m.room.message":
+	default:
+		return Message{}, false
+	}
+
+	var content matrixMessageContent
+	if json.Unmarshal(ev.Content, &content) != nil || content.MsgType == "" {
+		return Message{}, false // redacted
+	}
+
+	msg := Message{
+		RemoteID:       ev.EventID,
+		AuthorRemoteID: ev.Sender,
+		AuthorName:     c.displayName(ev.Sender),
+		CreatedAt:      time.UnixMilli(ev.OriginServerTS).UTC(),
+	}
+
+	if rel := content.RelatesTo; rel != nil {
+		if rel.RelType == "m.replace" && content.NewContent != nil {
+			msg.ReplacesRemoteID = rel.EventID
+			msg.Content = formatMatrixBody(content.NewContent.MsgType, content.NewContent.Body)
+			return msg, msg.Content != ""
+		}
+		if rel.InReplyTo != nil {
+			msg.ReplyToRemoteID = rel.InReplyTo.EventID
+			content.Body = stripReplyFallback(content.Body)
+		}
+	}
+
+	switch content.MsgType {
+	case "m.image", "m.file", "m.audio", "m.video":
+		name := content.Filename
+		if name == "" {
+			name = content.Body
+		}
+		if f, ok := c.media(content.URL, name, content.Info.MimeType); ok {
+			msg.Files = []File{f}
+		}
+		// A caption is only present when the body differs from the file name.
+		if content.Filename != "" && content.Body != content.Filename {
+			msg.Content = content.Body
+		} else if len(msg.Files) == 0 {
+			msg.Content = "[" + name + "]"
+		}
+	default:
+		msg.Content = formatMatrixBody(content.MsgType, content.Body)
+	}
+	return msg, msg.Content != "" || len(msg.Files) > 0
+}
+
+// displayName returns the sender's current display name, falling back to
+// the localpart of their Matrix ID.
+func (c *matrixConverter) displayName(mxid string) string {
+	if name, ok := c.names[mxid]; ok {
+		return name
+	}
+	if local, _, ok := strings.Cut(strings.TrimPrefix(mxid, "@"), ":"); ok {
+		return local
+	}
+	return mxid
+}
+
+// formatMatrixBody renders a text-like message body, or "" for message
+// types that are not imported.
+func formatMatrixBody(msgType, body string) string {
+	switch msgType {
+	case "m.text", "m.notice":
+		return body
+	case "m.emote":
+		return "_" + body + "_"
+	}
+	return ""
+}
+
+// stripReplyFallback removes the quoted "> <@user> ..." block that Matrix
+// clients prepend to reply bodies; the reply link is imported instead.
+func stripReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> ") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], ">") {
+		i++
+	}
+	if i < len(lines) && lines[i] == "" {
+		i++
+	}
+	return strings.Join(lines[i:], "\n")
+}
+
+// splitMXC splits an mxc://server/mediaID URI.
+func splitMXC(mxc string) (server, mediaID string, ok bool) {
+	rest, found := strings.CutPrefix(mxc, "mxc://")
+	if !found {
+		return "", "", false
+	}
+	server, mediaID, ok = strings.Cut(rest, "/")
+	return server, mediaID, ok && server != "" && mediaID != ""
+}
+
+// matrixExportFile is Element's JSON room export.
+type matrixExportFile struct {
+	RoomName string        `json:"room_name"`
+	Topic    string        `json:"topic"`
+	Messages []matrixEvent `json:"messages"`
+}
+
+// matrixExport reads a room export. Media is matched by file name against
+// the other files in a zipped export.
+type matrixExport struct {
+	export matrixExportFile
+	zip    *zip.Reader
+	files  map[string]string // base name -> path in the archive
+	closer io.Closer
+}
+
+// openMatrixExport opens an uploaded export, either a JSON file or a ZIP
+// containing one.
+func (s *Service) openMatrixExport(ctx context.Context, archiveID string) (Source, error) {
+	if s.media == nil {
+		return nil, errors.New("media storage is not configured")
+	}
+	obj, _, size, err := s.media.OpenFile(ctx, archiveID)
+	if err != nil {
+		return nil, fmt.Errorf("opening export: %w", err)
+	}
+	m, err := readMatrixExport(obj, size)
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+	m.closer = obj
+	return m, nil
+}
+
+// readMatrixExport parses an export from r, which holds either the JSON
+// export itself or a ZIP archive with it.
+func readMatrixExport(r io.ReadSeeker, size int64) (*matrixExport, error) {
+	m := &matrixExport{files: make(map[string]string)}
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, fmt.Errorf("reading export: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != "PK\x03\x04" {
+		if err := json.NewDecoder(r).Decode(&m.export); err != nil {
+			return nil, fmt.Errorf("parsing Matrix export: %w", err)
+		}
+		return m, nil
+	}
+
+	zr, err := openZip(r, size)
+	if err != nil {
+		return nil, err
+	}
+	m.zip = zr
+	var jsonName string
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if strings.HasSuffix(strings.ToLower(f.Name), ".json") && jsonName == "" {
+			jsonName = f.Name
+			continue
+		}
+		m.files[path.Base(f.Name)] = f.Name
+	}
+	if jsonName == "" {
+		return nil, errors.New("the archive contains no JSON room export")
+	}
+	f, err := zr.Open(jsonName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&m.export); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", jsonName, err)
+	}
+	return m, nil
+}
+
+func (m *matrixExport) Close() error {
+	if m.closer == nil {
+		return nil
+	}
+	return m.closer.Close()
+}
+
+// Load returns the room as a single text channel.
+func (m *matrixExport) Load(ctx context.Context) (*Archive, error) {
+	roomID := "room"
+	for _, ev := range m.export.Messages {
+		if ev.RoomID != "" {
+			roomID = ev.RoomID
+			break
+		}
+	}
+	ch := Channel{RemoteID: roomID, Name: m.export.RoomName, Type: "text"}
+	if ch.Name == "" {
+		ch.Name = "matrix-import"
+	}
+	if m.export.Topic != "" {
+		topic := m.export.Topic
+		ch.Topic = &topic
+	}
+	return &Archive{Channels: []Channel{ch}}, nil
+}
+
+// Messages yields the export's events in batches, in export order.
+func (m *matrixExport) Messages(ctx context.Context, ch Channel, fn func([]Message) error) error {
+	conv := newMatrixConverter(m.mediaFile)
+	const batchSize = 100
+	batch := make([]Message, 0, batchSize)
+	for _, ev := range m.export.Messages {
+		msg, ok := conv.convert(ev)
+		if !ok {
+			continue
+		}
+		batch = append(batch, msg)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]Message, 0, batchSize)
+		}
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// mediaFile finds an attachment in a zipped export by file name.
+func (m *matrixExport) mediaFile(mxc, name, mimeType string) (File, bool) {
+	p, ok := m.files[path.Base(name)]
+	if !ok || m.zip == nil {
+		return File{}, false
+	}
+	return File{
+		Name:        name,
+		ContentType: mimeType,
+		Open:        func(context.Context) (io.ReadCloser, error) { return m.zip.Open(p) },
+	}, true
+}
+
+// matrixAPI reads a room through the Matrix client-server API.
+type matrixAPI struct {
+	http       *http.Client
+	homeserver string
+	token      string
+	roomID     string
+}
+
+func newMatrixAPI(client *http.Client, homeserver, token, roomID string) *matrixAPI {
+	return &matrixAPI{
+		http:       client,
+		homeserver: strings.TrimRight(homeserver, "/"),
+		token:      token,
+		roomID:     roomID,
+	}
+}
+
+func (a *matrixAPI) Close() error { return nil }
+
+// matrixStatusError is a non-2xx response from the homeserver.
+type matrixStatusError struct {
+	Path    string
+	Status  int
+	ErrCode string
+}
+
+func (e *matrixStatusError) Error() string {
+	return fmt.Sprintf("matrix API %s: status %d %s", e.Path, e.Status, e.ErrCode)
+}
+
+// request performs an authenticated GET, waiting out rate limits, and
+// returns the response body for the caller to close.
+func (a *matrixAPI) request(ctx context.Context, p string) (io.ReadCloser, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.homeserver+p, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+a.token)
+		resp, err := a.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp.Body, nil
+		}
+
+		var merr struct {
+			ErrCode      string `json:"errcode"`
+			RetryAfterMS int64  `json:"retry_after_ms"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&merr)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= 5 {
+			return nil, &matrixStatusError{Path: p, Status: resp.StatusCode, ErrCode: merr.ErrCode}
+		}
+		wait := time.Duration(merr.RetryAfterMS) * time.Millisecond
+		if wait <= 0 {
+			wait = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (a *matrixAPI) get(ctx context.Context, p string, out interface{}) error {
+	body, err := a.request(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(out)
+}
+
+func (a *matrixAPI) roomPath(suffix string) string {
+	return "/_matrix/client/v3/rooms/" + url.PathEscape(a.roomID) + suffix
+}
+
+// Load reads the room's name and topic. Rooms without them still import.
+func (a *matrixAPI) Load(ctx context.Context) (*Archive, error) {
+	ch := Channel{RemoteID: a.roomID, Name: "matrix-import", Type: "text"}
+
+	var name struct {
+		Name string `json:"name"`
+	}
+	err := a.get(ctx, a.roomPath("/state/m.room.name/"), &name)
+	var statusErr *matrixStatusError
+	switch {
+	case err == nil && name.Name != "":
+		ch.Name = name.Name
+	case errors.As(err, &statusErr) && statusErr.Status == http.StatusNotFound:
+	case err != nil:
+		return nil, err
+	}
+
+	var topic struct {
+		Topic string `json:"topic"`
+	}
+	if a.get(ctx, a.roomPath("/state/m.room.topic/"), &topic) == nil && topic.Topic != "" {
+		ch.Topic = &topic.Topic
+	}
+	return &Archive{Channels: []Channel{ch}}, nil
+}
+
+// Messages paginates the room timeline forwards from its start.
+func (a *matrixAPI) Messages(ctx context.Context, ch Channel, fn func([]Message) error) error {
+	conv := newMatrixConverter(a.mediaFile)
+	from := ""
+	for {
+		q := url.Values{"dir": {"f"}, "limit": {"100"}}
+		if from != "" {
+			q.Set("from", from)
+		}
+		var page struct {
+			Chunk []matrixEvent `json:"chunk"`
+			End   string        `json:"end"`
+		}
+		err := a.get(ctx, a.roomPath("/messages?"+q.Encode()), &page)
+		var statusErr *matrixStatusError
+		if errors.As(err, &statusErr) && statusErr.Status == http.StatusForbidden {
+			return ErrChannelUnavailable
+		}
+		if err != nil {
+			return err
+		}
+
+		batch := make([]Message, 0, len(page.Chunk))
+		for _, ev := range page.Chunk {
+			if msg, ok := conv.convert(ev); ok {
+				batch = append(batch, msg)
+			}
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		if page.End == "" || len(page.Chunk) == 0 {
+			return nil
+		}
+		from = page.End
+	}
+}
+
+// mediaFile downloads an attachment from the homeserver's authenticated
+// media endpoint.
+func (a *matrixAPI) mediaFile(mxc, name, mimeType string) (File, bool) {
+	server, mediaID, ok := splitMXC(mxc)
+	if !ok {
+		return File{}, false
+	}
+	p := "/_matrix/client/v1/media/download/" + url.PathEscape(server) + "/" + url.PathEscape(mediaID)
+	return File{
+		Name:        name,
+		ContentType: mimeType,
+		URL:         mxc,
+		Open: func(ctx context.Context) (io.ReadCloser, error) {
+			return a.request(ctx, p)
+		},
+	}, true
+}
@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+const matrixExportJSON = `{
+  "room_name": "Project Room",
+  "topic": "Planning",
+  "messages": [
+    {"type": "m.room.member", "event_id": "$m1", "room_id": "!abc:example.org", "sender": "@alice:example.org",
+     "state_key": "@alice:example.org", "origin_server_ts": 1600000000000,
+     "content": {"membership": "join", "displayname": "Alice A."}},
+    {"type": "m.room.message", "event_id": "$e1", "room_id": "!abc:example.org", "sender": "@alice:example.org",
+     "origin_server_ts": 1600000001000, "content": {"msgtype": "m.text", "body": "hello"}},
+    {"type": "m.room.message", "event_id": "$e2", "room_id": "!abc:example.org", "sender": "@bob:example.org",
+     "origin_server_ts": 1600000002000,
+     "content": {"msgtype": "m.text", "body": "> <@alice:example.org> hello\n\nhi alice",
+                 "m.relates_to": {"m.in_reply_to": {"event_id": "$e1"}}}},
+    {"type": "m.room.message", "event_id": "$e3", "room_id": "!abc:example.org", "sender": "@alice:example.org",
+     "origin_server_ts": 1600000003000,
+     "content": {"msgtype": "m.text", "body": "* hello there", "m.new_content": {"msgtype": "m.text", "body": "hello there"},
+                 "m.relates_to": {"rel_type": "m.replace", "event_id": "$e1"}}},
+    {"type": "m.room.message", "event_id": "$e4", "room_id": "!abc:example.org", "sender": "@bob:example.org",
+     "origin_server_ts": 1600000004000, "content": {}},
+    {"type": "m.room.message", "event_id": "$e5", "room_id": "!abc:example.org", "sender": "@bob:example.org",
+     "origin_server_ts": 1600000005000,
+     "content": {"msgtype": "m.image", "body": "diagram.png", "url": "mxc://example.org/xyz", "info": {"mimetype": "image/png"}}},
+    {"type": "m.reaction", "event_id": "$e6", "room_id": "!abc:example.org", "sender": "@bob:example.org",
+     "origin_server_ts": 1600000006000, "content": {}}
+  ]
+}`
+
+func collectMessages(t *testing.T, src Source) (*Archive, []Message) {
+	t.Helper()
+	arc, err := src.Load(context.Background())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var msgs []Message
+	err = src.Messages(context.Background(), arc.Channels[0], func(batch []Message) error {
+		msgs = append(msgs, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	return arc, msgs
+}
+
+func TestMatrixExport_JSON(t *testing.T) {
+	m, err := readMatrixExport(strings.NewReader(matrixExportJSON), int64(len(matrixExportJSON)))
+	if err != nil {
+		t.Fatalf("readMatrixExport: %v", err)
+	}
+	arc, msgs := collectMessages(t, m)
+
+	if len(arc.Channels) != 1 || arc.Channels[0].RemoteID != "!abc:example.org" || arc.Channels[0].Name != "Project Room" {
+		t.Fatalf("channels = %+v", arc.Channels)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want 4: %+v", len(msgs), msgs)
+	}
+	if msgs[0].AuthorName != "Alice A." || msgs[0].AuthorRemoteID != "@alice:example.org" {
+		t.Errorf("first author = %q (%q)", msgs[0].AuthorName, msgs[0].AuthorRemoteID)
+	}
+	if msgs[1].AuthorName != "bob" || msgs[1].ReplyToRemoteID != "$e1" || msgs[1].Content != "hi alice" {
+		t.Errorf("reply = %+v", msgs[1])
+	}
+	if msgs[2].ReplacesRemoteID != "$e1" || msgs[2].Content != "hello there" {
+		t.Errorf("edit = %+v", msgs[2])
+	}
+	// No zipped media to read the image from, so it becomes a placeholder.
+	if msgs[3].Content != "[diagram.png]" || len(msgs[3].Files) != 0 {
+		t.Errorf("image = %+v", msgs[3])
+	}
+}
+
+func TestMatrixExport_ZipWithMedia(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("export/room.json")
+	w.Write([]byte(matrixExportJSON))
+	w, _ = zw.Create("export/images/diagram.png")
+	w.Write([]byte("png"))
+	zw.Close()
+
+	r := bytes.NewReader(buf.Bytes())
+	m, err := readMatrixExport(r, r.Size())
+	if err != nil {
+		t.Fatalf("readMatrixExport: %v", err)
+	}
+	_, msgs := collectMessages(t, m)
+	img := msgs[len(msgs)-1]
+	if len(img.Files) != 1 || img.Files[0].Open == nil || img.Content != "" {
+		t.Fatalf("image = %+v", img)
+	}
+}
+
+func TestStripReplyFallback(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"> <@a:b> quoted\n> more\n\nanswer", "answer"},
+		{"plain", "plain"},
+		{">not a fallback", ">not a fallback"},
+	}
+	for _, tt := range tests {
+		if got := stripReplyFallback(tt.in); got != tt.want {
+			t.Errorf("stripReplyFallback(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitMXC(t *testing.T) {
+	if server, id, ok := splitMXC("mxc://example.org/abc123"); !ok || server != "example.org" || id != "abc123" {
+		t.Errorf("splitMXC = %q, %q, %v", server, id, ok)
+	}
+	for _, bad := range []string{"https://example.org/abc", "mxc://example.org", "mxc:///abc"} {
+		if _, _, ok := splitMXC(bad); ok {
+			t.Errorf("splitMXC(%q) should fail", bad)
+		}
+	}
+}
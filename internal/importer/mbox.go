@@ -0,0 +1,374 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mailing list archives are imported from an mbox file, which becomes one
+// channel, or a ZIP of them, one channel per file. Each mail is a message;
+// replies are gathered into a thread under the first mail of their
+// conversation.
+
+// mboxExport reads mbox files, either one bare file or the .mbox entries of a
+// ZIP archive.
+type mboxExport struct {
+	single io.ReadSeeker
+	zip    *zip.Reader
+	closer io.Closer
+}
+
+// openMbox opens an uploaded mbox file or ZIP of mbox files.
+func (s *Service) openMbox(ctx context.Context, archiveID string) (Source, error) {
+	if s.media == nil {
+		return nil, errors.New("media storage is not configured")
+	}
+	obj, _, size, err := s.media.OpenFile(ctx, archiveID)
+	if err != nil {
+		return nil, fmt.Errorf("opening mbox: %w", err)
+	}
+	m, err := readMboxExport(obj, size)
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+	m.closer = obj
+	return m, nil
+}
+
+func readMboxExport(r io.ReadSeeker, size int64) (*mboxExport, error) {
+	var magic [5]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, fmt.Errorf("reading mbox: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch {
+	case string(magic[:4]) == "PK\x03\x04":
+		zr, err := openZip(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return &mboxExport{zip: zr}, nil
+	case string(magic[:]) == "From ":
+		return &mboxExport{single: r}, nil
+	}
+	return nil, errors.New("the file is neither an mbox file nor a ZIP archive")
+}
+
+func (m *mboxExport) Close() error {
+	if m.closer == nil {
+		return nil
+	}
+	return m.closer.Close()
+}
+
+// Load lists one channel per mbox file, named after the file.
+func (m *mboxExport) Load(ctx context.Context) (*Archive, error) {
+	if m.single != nil {
+		return &Archive{Channels: []Channel{{RemoteID: "mbox", Name: "mail-archive", Type: "text"}}}, nil
+	}
+	arc := &Archive{}
+	for _, f := range m.zip.File {
+		ext := strings.ToLower(path.Ext(f.Name))
+		if f.FileInfo().IsDir() || (ext != ".mbox" && ext != ".mbx") {
+			continue
+		}
+		name := strings.TrimSuffix(path.Base(f.Name), path.Ext(f.Name))
+		arc.Channels = append(arc.Channels, Channel{
+			RemoteID: f.Name, Name: name, Type: "text", Position: len(arc.Channels),
+		})
+	}
+	if len(arc.Channels) == 0 {
+		return nil, errors.New("the archive contains no .mbox files")
+	}
+	return arc, nil
+}
+
+// Messages parses a channel's mbox file and yields its mails in batches.
+func (m *mboxExport) Messages(ctx context.Context, ch Channel, fn func([]Message) error) error {
+	var r io.Reader
+	if m.single != nil {
+		if _, err := m.single.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = m.single
+	} else {
+		f, err := m.zip.Open(ch.RemoteID)
+		if err != nil {
+			return ErrChannelUnavailable
+		}
+		defer f.Close()
+		r = f
+	}
+
+	const batchSize = 100
+	batch := make([]Message, 0, batchSize)
+	err := splitMbox(r, func(raw []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msg, ok := convertMail(raw)
+		if !ok {
+			return nil
+		}
+		batch = append(batch, msg)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]Message, 0, batchSize)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// splitMbox calls fn with each mail in an mbox stream, without its "From "
+// separator line and with ">From " quoting undone.
+func splitMbox(r io.Reader, fn func([]byte) error) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var cur bytes.Buffer
+	started := false
+	flush := func() error {
+		if !started || cur.Len() == 0 {
+			return nil
+		}
+		raw := append([]byte(nil), cur.Bytes()...)
+		cur.Reset()
+		return fn(raw)
+	}
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case bytes.HasPrefix(line, []byte("From ")):
+				if err := flush(); err != nil {
+					return err
+				}
+				started = true
+			case started:
+				if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) && line[0] == '>' {
+					line = line[1:]
+				}
+				if cur.Len() > maxFileSize {
+					return errors.New("mbox contains a mail larger than the file size limit")
+				}
+				cur.Write(line)
+			}
+		}
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+var mailIDPattern = regexp.MustCompile(`<([^<>\s]+)>`)
+
+// mailIDs returns the message IDs in a Message-ID, In-Reply-To or
+// References header, in order.
+func mailIDs(header string) []string {
+	var ids []string
+	for _, m := range mailIDPattern.FindAllStringSubmatch(header, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+// convertMail turns one raw mail into a Message, reporting false if it
+// cannot be parsed. A reply is threaded under the first mail named in its
+// References header and quotes of earlier mails are dropped from its text.
+func convertMail(raw []byte) (Message, bool) {
+	mm, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Message{}, false
+	}
+	created, err := mm.Header.Date()
+	if err != nil {
+		return Message{}, false
+	}
+
+	var msg Message
+	msg.CreatedAt = created.UTC()
+	if ids := mailIDs(mm.Header.Get("Message-Id")); len(ids) > 0 {
+		msg.RemoteID = ids[0]
+	} else {
+		sum := sha256.Sum256(raw)
+		msg.RemoteID = "sha256:" + hex.EncodeToString(sum[:16])
+	}
+
+	if addr, err := mail.ParseAddress(mm.Header.Get("From")); err == nil {
+		msg.AuthorRemoteID = strings.ToLower(addr.Address)
+		msg.AuthorName = addr.Name
+		if msg.AuthorName == "" {
+			msg.AuthorName, _, _ = strings.Cut(addr.Address, "@")
+		}
+	} else {
+		msg.AuthorName = strings.TrimSpace(mm.Header.Get("From"))
+	}
+	if msg.AuthorName == "" {
+		msg.AuthorName = "Unknown sender"
+	}
+
+	inReplyTo := mailIDs(mm.Header.Get("In-Reply-To"))
+	refs := mailIDs(mm.Header.Get("References"))
+	if len(inReplyTo) > 0 {
+		msg.ReplyToRemoteID = inReplyTo[0]
+		root := inReplyTo[0]
+		if len(refs) > 0 {
+			root = refs[0]
+		}
+		if root != msg.RemoteID {
+			msg.ThreadRemoteID = root
+		}
+		if msg.ReplyToRemoteID == root {
+			msg.ReplyToRemoteID = ""
+		}
+	}
+
+	body, files := readMailBody(mm.Header.Get, mm.Body)
+	if msg.ThreadRemoteID != "" {
+		body = stripQuoted(body)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(mm.Header.Get("Subject"))
+	if err != nil {
+		subject = mm.Header.Get("Subject")
+	}
+	if subject = strings.TrimSpace(subject); subject != "" && len(inReplyTo) == 0 {
+		body = "**" + subject + "**\n\n" + body
+	}
+	msg.Content = strings.TrimSpace(body)
+	msg.Files = files
+	return msg, true
+}
+
+// readMailBody returns a mail's text and attachments. The first text/plain
+// part is the text, falling back to text/html with its tags removed.
+func readMailBody(header func(string) string, body io.Reader) (string, []File) {
+	var plain, html string
+	var files []File
+	var walk func(header func(string) string, body io.Reader, depth int)
+	walk = func(header func(string) string, body io.Reader, depth int) {
+		mediaType, params, err := mime.ParseMediaType(header("Content-Type"))
+		if err != nil {
+			mediaType = "text/plain"
+		}
+		if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" && depth < 5 {
+			mr := multipart.NewReader(body, params["boundary"])
+			for {
+				part, err := mr.NextPart()
+				if err != nil {
+					return
+				}
+				walk(part.Header.Get, part, depth+1)
+			}
+		}
+
+		data, err := io.ReadAll(io.LimitReader(decodeTransfer(header("Content-Transfer-Encoding"), body), maxFileSize+1))
+		if err != nil || len(data) > maxFileSize {
+			return
+		}
+		disposition, dparams, _ := mime.ParseMediaType(header("Content-Disposition"))
+		filename := dparams["filename"]
+		if filename == "" {
+			filename = params["name"]
+		}
+		if disposition == "attachment" || filename != "" || !strings.HasPrefix(mediaType, "text/") {
+			if filename == "" {
+				filename = "attachment"
+			}
+			if dec, err := new(mime.WordDecoder).DecodeHeader(filename); err == nil {
+				filename = dec
+			}
+			files = append(files, File{
+				Name:        path.Base(filename),
+				ContentType: mediaType,
+				Open: func(context.Context) (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(data)), nil
+				},
+			})
+			return
+		}
+		text := string(data)
+		if !utf8.ValidString(text) {
+			text = strings.ToValidUTF8(text, "�")
+		}
+		switch {
+		case mediaType == "text/plain" && plain == "":
+			plain = text
+		case mediaType == "text/html" && html == "":
+			html = text
+		}
+	}
+	walk(header, body, 0)
+
+	if plain == "" && html != "" {
+		plain = htmlTag.ReplaceAllString(html, "")
+		plain = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&", "&nbsp;", " ", "&quot;", `"`).Replace(plain)
+	}
+	return strings.ReplaceAll(plain, "\r\n", "\n"), files
+}
+
+var htmlTag = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// decodeTransfer undoes a part's Content-Transfer-Encoding. multipart.Reader
+// already decodes quoted-printable parts and drops the header.
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// stripQuoted drops quoted lines, and the "On ... wrote:" line introducing
+// them, from a reply.
+func stripQuoted(body string) string {
+	lines := strings.Split(body, "\n")
+	quoted := func(line string) bool { return strings.HasPrefix(strings.TrimSpace(line), ">") }
+	kept := make([]string, 0, len(lines))
+	for i, line := range lines {
+		if quoted(line) {
+			continue
+		}
+		if strings.HasSuffix(strings.TrimSpace(line), "wrote:") {
+			j := i + 1
+			for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+				j++
+			}
+			if j < len(lines) && quoted(lines[j]) {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testMbox = `From alice@example.org Mon Jan  4 10:00:00 2021
+From: Alice Smith <Alice@Example.org>
+To: list@example.org
+Subject: =?UTF-8?Q?Release_plan_=E2=9C=93?=
+Date: Mon, 04 Jan 2021 10:00:00 +0000
+Message-ID: <root@example.org>
+
+Let's ship on Friday.
+>From the notes: nothing blocks.
+
+From bob@example.org Mon Jan  4 11:00:00 2021
+From: bob@example.org
+Subject: Re: Release plan
+Date: Mon, 04 Jan 2021 11:00:00 +0000
+Message-ID: <r1@example.org>
+In-Reply-To: <root@example.org>
+References: <root@example.org>
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="XYZ"
+
+--XYZ
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Sounds good =E2=80=94 logs attached.
+
+On Mon, Alice Smith wrote:
+> Let's ship on Friday.
+--XYZ
+Content-Type: text/plain; name="build.log"
+Content-Disposition: attachment; filename="build.log"
+Content-Transfer-Encoding: base64
+
+b2sK
+--XYZ--
+
+From carol@example.org Mon Jan  4 12:00:00 2021
+From: Carol <carol@example.org>
+Subject: Re: Release plan
+Date: Mon, 04 Jan 2021 12:00:00 +0000
+Message-ID: <r2@example.org>
+In-Reply-To: <r1@example.org>
+References: <root@example.org> <r1@example.org>
+
++1
+
+From nobody Mon Jan  4 13:00:00 2021
+Subject: no date
+
+dropped
+`
+
+func TestMboxExport(t *testing.T) {
+	m, err := readMboxExport(strings.NewReader(testMbox), int64(len(testMbox)))
+	if err != nil {
+		t.Fatalf("readMboxExport: %v", err)
+	}
+	arc, msgs := collectMessages(t, m)
+	if len(arc.Channels) != 1 {
+		t.Fatalf("channels = %+v", arc.Channels)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("got %d messages, want 3: %+v", len(msgs), msgs)
+	}
+
+	root := msgs[0]
+	if root.RemoteID != "root@example.org" || root.AuthorRemoteID != "alice@example.org" || root.AuthorName != "Alice Smith" {
+		t.Errorf("root = %+v", root)
+	}
+	if root.Content != "**Release plan ✓**\n\nLet's ship on Friday.\nFrom the notes: nothing blocks." {
+		t.Errorf("root content = %q", root.Content)
+	}
+
+	r1 := msgs[1]
+	if r1.ThreadRemoteID != "root@example.org" || r1.ReplyToRemoteID != "" || r1.AuthorName != "bob" {
+		t.Errorf("first reply = %+v", r1)
+	}
+	if r1.Content != "Sounds good — logs attached." {
+		t.Errorf("first reply content = %q", r1.Content)
+	}
+	if len(r1.Files) != 1 || r1.Files[0].Name != "build.log" {
+		t.Fatalf("files = %+v", r1.Files)
+	}
+	rc, err := r1.Files[0].Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	if string(data) != "ok\n" {
+		t.Errorf("attachment = %q", data)
+	}
+
+	r2 := msgs[2]
+	if r2.ThreadRemoteID != "root@example.org" || r2.ReplyToRemoteID != "r1@example.org" || r2.Content != "+1" {
+		t.Errorf("second reply = %+v", r2)
+	}
+}
+
+func TestMboxExport_Zip(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"lists/dev.mbox":  testMbox,
+		"lists/users.mbx": testMbox,
+		"README":          "ignored",
+	})
+	m, err := readMboxExport(r, r.Size())
+	if err != nil {
+		t.Fatalf("readMboxExport: %v", err)
+	}
+	arc, err := m.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arc.Channels) != 2 {
+		t.Fatalf("channels = %+v", arc.Channels)
+	}
+	for _, ch := range arc.Channels {
+		if ch.Name != "dev" && ch.Name != "users" {
+			t.Errorf("unexpected channel %q", ch.Name)
+		}
+	}
+}
+
+func TestReadMboxExport_Rejects(t *testing.T) {
+	const notMbox = "Subject: hi\n\nbody\n"
+	if _, err := readMboxExport(strings.NewReader(notMbox), int64(len(notMbox))); err == nil {
+		t.Error("expected an error for a file that is not an mbox")
+	}
+}
+
+func TestStripQuoted(t *testing.T) {
+	in := "Top reply\n\nOn Tue, Bob wrote:\n> quoted\n>> older\nafter"
+	if got := stripQuoted(in); got != "Top reply\n\nafter" {
+		t.Errorf("stripQuoted = %q", got)
+	}
+}
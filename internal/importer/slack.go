@@ -0,0 +1,413 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Slack workspace exports are ZIPs with channels.json (and groups.json for
+// private channels in full exports), users.json, and one folder per channel
+// holding a JSON file of messages for each day. Direct messages are not
+// imported.
+
+type slackChannel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Topic struct {
+		Value string `json:"value"`
+	} `json:"topic"`
+	Purpose struct {
+		Value string `json:"value"`
+	} `json:"purpose"`
+	Pins []struct {
+		ID string `json:"id"` // message ts
+	} `json:"pins"`
+}
+
+type slackProfile struct {
+	DisplayName string `json:"display_name"`
+	RealName    string `json:"real_name"`
+	Name        string `json:"name"`
+	Image72     string `json:"image_72"`
+	Image192    string `json:"image_192"`
+}
+
+type slackUser struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	RealName string       `json:"real_name"`
+	Color    string       `json:"color"`
+	Profile  slackProfile `json:"profile"`
+}
+
+type slackMessage struct {
+	Type        string        `json:"type"`
+	Subtype     string        `json:"subtype"`
+	TS          string        `json:"ts"`
+	ThreadTS    string        `json:"thread_ts"`
+	User        string        `json:"user"`
+	BotID       string        `json:"bot_id"`
+	Username    string        `json:"username"`
+	Text        string        `json:"text"`
+	UserProfile *slackProfile `json:"user_profile"`
+	Edited      *struct {
+		TS string `json:"ts"`
+	} `json:"edited"`
+	PinnedTo []string `json:"pinned_to"`
+	Files    []struct {
+		Name               string `json:"name"`
+		Title              string `json:"title"`
+		Mimetype           string `json:"mimetype"`
+		URLPrivate         string `json:"url_private"`
+		URLPrivateDownload string `json:"url_private_download"`
+	} `json:"files"`
+}
+
+// slackSubtypes are the message subtypes carrying user content. Everything
+// else (joins, topic changes, pin notices) is a system message.
+var slackSubtypes = map[string]bool{
+	"":                 true,
+	"thread_broadcast": true,
+	"bot_message":      true,
+	"file_share":       true,
+	"me_message":       true,
+}
+
+// slackExport reads a Slack workspace export archive.
+type slackExport struct {
+	zip      *zip.Reader
+	closer   io.Closer
+	users    map[string]slackUser
+	channels map[string]slackChannel // by remote ID
+	days     map[string][]string     // remote channel ID -> day files, oldest first
+}
+
+// openSlackExport opens an uploaded workspace export.
+func (s *Service) openSlackExport(ctx context.Context, archiveID string) (Source, error) {
+	if s.media == nil {
+		return nil, errors.New("media storage is not configured")
+	}
+	obj, _, size, err := s.media.OpenFile(ctx, archiveID)
+	if err != nil {
+		return nil, fmt.Errorf("opening export archive: %w", err)
+	}
+	zr, err := openZip(obj, size)
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+	return newSlackExport(zr, obj), nil
+}
+
+func newSlackExport(zr *zip.Reader, closer io.Closer) *slackExport {
+	return &slackExport{
+		zip:      zr,
+		closer:   closer,
+		users:    make(map[string]slackUser),
+		channels: make(map[string]slackChannel),
+		days:     make(map[string][]string),
+	}
+}
+
+func (e *slackExport) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// readJSON decodes a file at the root of the export, which may sit inside a
+// single top-level folder. missing reports whether the file was absent.
+func (e *slackExport) readJSON(name string, v interface{}) (missing bool, err error) {
+	for _, f := range e.zip.File {
+		if path.Base(f.Name) != name || strings.Count(f.Name, "/") > 1 {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return false, err
+		}
+		defer rc.Close()
+		if err := json.NewDecoder(rc).Decode(v); err != nil {
+			return false, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// Load reads the channel and user lists and indexes each channel's day
+// files. Slack channels have no categories, so all land uncategorized.
+func (e *slackExport) Load(ctx context.Context) (*Archive, error) {
+	var public, private []slackChannel
+	missing, err := e.readJSON("channels.json", &public)
+	if err != nil {
+		return nil, err
+	}
+	if missing {
+		return nil, errors.New("the archive is not a Slack export: channels.json is missing")
+	}
+	if _, err := e.readJSON("groups.json", &private); err != nil {
+		return nil, err
+	}
+	var users []slackUser
+	if _, err := e.readJSON("users.json", &users); err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		e.users[u.ID] = u
+	}
+
+	byName := make(map[string]string)
+	arc := &Archive{}
+	for _, ch := range append(public, private...) {
+		if ch.ID == "" || ch.Name == "" {
+			continue
+		}
+		e.channels[ch.ID] = ch
+		byName[ch.Name] = ch.ID
+		c := Channel{RemoteID: ch.ID, Name: ch.Name, Type: "text", Position: len(arc.Channels)}
+		if topic := ch.Purpose.Value; topic != "" {
+			c.Topic = &topic
+		} else if topic := ch.Topic.Value; topic != "" {
+			c.Topic = &topic
+		}
+		arc.Channels = append(arc.Channels, c)
+	}
+
+	for _, f := range e.zip.File {
+		if f.FileInfo().IsDir() || !slackDayFile.MatchString(path.Base(f.Name)) {
+			continue
+		}
+		dir := path.Base(path.Dir(f.Name))
+		if id, ok := byName[dir]; ok {
+			e.days[id] = append(e.days[id], f.Name)
+		}
+	}
+	for id := range e.days {
+		sort.Strings(e.days[id])
+	}
+	return arc, nil
+}
+
+// Messages reads a channel's day files in order and yields a batch per day.
+func (e *slackExport) Messages(ctx context.Context, ch Channel, fn func([]Message) error) error {
+	info, ok := e.channels[ch.RemoteID]
+	if !ok {
+		return ErrChannelUnavailable
+	}
+	pinned := make(map[string]bool, len(info.Pins))
+	for _, p := range info.Pins {
+		pinned[p.ID] = true
+	}
+	for _, name := range e.days[ch.RemoteID] {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		day, err := e.readDay(name)
+		if err != nil {
+			return err
+		}
+		batch := make([]Message, 0, len(day))
+		for _, m := range day {
+			msg, ok := e.convert(m, ch.RemoteID, pinned)
+			if ok {
+				batch = append(batch, msg)
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *slackExport) readDay(name string) ([]slackMessage, error) {
+	f, err := e.zip.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var day []slackMessage
+	if err := json.NewDecoder(f).Decode(&day); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return day, nil
+}
+
+// convert turns an exported message into a Message, reporting false for
+// system messages. Thread replies name their root; replies also sent to the
+// channel are imported there as ordinary replies to the root.
+func (e *slackExport) convert(m slackMessage, channelID string, pinned map[string]bool) (Message, bool) {
+	if m.Type != "message" || !slackSubtypes[m.Subtype] || m.TS == "" {
+		return Message{}, false
+	}
+	created, ok := slackTime(m.TS)
+	if !ok {
+		return Message{}, false
+	}
+	msg := Message{
+		RemoteID:  m.TS,
+		CreatedAt: created,
+		Content:   e.formatText(m.Text),
+		Pinned:    pinned[m.TS],
+	}
+	for _, c := range m.PinnedTo {
+		if c == channelID {
+			msg.Pinned = true
+		}
+	}
+	if m.Subtype == "me_message" && msg.Content != "" {
+		msg.Content = "_" + msg.Content + "_"
+	}
+	if m.ThreadTS != "" && m.ThreadTS != m.TS {
+		if m.Subtype == "thread_broadcast" {
+			msg.ReplyToRemoteID = m.ThreadTS
+		} else {
+			msg.ThreadRemoteID = m.ThreadTS
+		}
+	}
+	if m.Edited != nil {
+		if t, ok := slackTime(m.Edited.TS); ok && t.After(created) {
+			msg.EditedAt = &t
+		}
+	}
+
+	profile := m.UserProfile
+	if u, ok := e.users[m.User]; ok {
+		if profile == nil {
+			profile = &u.Profile
+		}
+		if u.Color != "" {
+			color := "#" + u.Color
+			msg.AuthorColor = &color
+		}
+		msg.AuthorName = u.RealName
+		if msg.AuthorName == "" {
+			msg.AuthorName = u.Name
+		}
+	}
+	switch {
+	case m.User != "":
+		msg.AuthorRemoteID = m.User
+	case m.BotID != "":
+		msg.AuthorRemoteID = m.BotID
+	}
+	if profile != nil {
+		for _, name := range []string{profile.DisplayName, profile.RealName, profile.Name} {
+			if name != "" {
+				msg.AuthorName = name
+				break
+			}
+		}
+		avatar := profile.Image192
+		if avatar == "" {
+			avatar = profile.Image72
+		}
+		if avatar != "" {
+			msg.AuthorAvatar = &avatar
+		}
+	}
+	if msg.AuthorName == "" {
+		msg.AuthorName = m.Username
+	}
+	if msg.AuthorName == "" {
+		msg.AuthorName = "Slack user"
+	}
+
+	for _, f := range m.Files {
+		u := f.URLPrivateDownload
+		if u == "" {
+			u = f.URLPrivate
+		}
+		if u == "" {
+			continue // deleted or hidden by the workspace's plan limits
+		}
+		name := f.Name
+		if name == "" {
+			name = f.Title
+		}
+		msg.Files = append(msg.Files, File{Name: name, ContentType: f.Mimetype, URL: u})
+	}
+	return msg, true
+}
+
+// slackTime parses a Slack message timestamp ("1577836800.000200").
+func slackTime(ts string) (time.Time, bool) {
+	sec, frac, _ := strings.Cut(ts, ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil || s <= 0 {
+		return time.Time{}, false
+	}
+	var usec int64
+	if frac != "" {
+		frac = (frac + "000000")[:6]
+		if usec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, false
+		}
+	}
+	return time.Unix(s, usec*1000).UTC(), true
+}
+
+var (
+	slackDayFile = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}\.json$`)
+	slackLink    = regexp.MustCompile(`<([^<>]+)>`)
+	slackBold    = regexp.MustCompile(`(^|[\s(])\*([^*\n]+)\*`)
+	slackStrike  = regexp.MustCompile(`(^|[\s(])~([^~\n]+)~`)
+)
+
+// formatText converts Slack mrkdwn to Markdown. Mentions become plain text
+// because Slack user and channel IDs mean nothing here.
+func (e *slackExport) formatText(text string) string {
+	text = slackLink.ReplaceAllStringFunc(text, func(tag string) string {
+		inner := tag[1 : len(tag)-1]
+		target, label, hasLabel := strings.Cut(inner, "|")
+		switch {
+		case strings.HasPrefix(target, "@"):
+			if hasLabel {
+				return "@" + strings.TrimPrefix(label, "@")
+			}
+			if u, ok := e.users[target[1:]]; ok {
+				if u.Profile.DisplayName != "" {
+					return "@" + u.Profile.DisplayName
+				}
+				return "@" + u.Name
+			}
+			return target
+		case strings.HasPrefix(target, "#"):
+			if hasLabel {
+				return "#" + label
+			}
+			if ch, ok := e.channels[target[1:]]; ok {
+				return "#" + ch.Name
+			}
+			return target
+		case strings.HasPrefix(target, "!"):
+			if hasLabel {
+				return label
+			}
+			return "@" + strings.TrimPrefix(target, "!")
+		case hasLabel:
+			return "[" + label + "](" + target + ")"
+		default:
+			return strings.TrimPrefix(target, "mailto:")
+		}
+	})
+	text = slackBold.ReplaceAllString(text, "$1**$2**")
+	text = slackStrike.ReplaceAllString(text, "$1~~$2~~")
+	r := strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+	return r.Replace(text)
+}
@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"testing"
+	"time"
+)
+
+const slackChannels = `[
+  {"id": "C1", "name": "general", "purpose": {"value": "Company-wide"}, "pins": [{"id": "1600000000.000100"}]},
+  {"id": "C2", "name": "random", "topic": {"value": "Anything"}}
+]`
+
+const slackUsers = `[
+  {"id": "U1", "name": "alice", "real_name": "Alice Smith", "color": "9f69e7",
+   "profile": {"display_name": "alice", "image_192": "https://avatars.slack-edge.com/u1.png"}},
+  {"id": "U2", "name": "bob", "profile": {"real_name": "Bob"}}
+]`
+
+const slackGeneralDay1 = `[
+  {"type": "message", "ts": "1600000000.000100", "user": "U1", "text": "Hello <@U2>, see <https://example.com|the docs> &amp; *this*",
+   "thread_ts": "1600000000.000100", "reply_count": 1},
+  {"type": "message", "subtype": "channel_join", "ts": "1600000001.000000", "user": "U2", "text": "<@U2> has joined the channel"},
+  {"type": "message", "ts": "1600000002.000000", "user": "U2", "text": "reply in thread", "thread_ts": "1600000000.000100",
+   "edited": {"ts": "1600000003.000000"}},
+  {"type": "message", "subtype": "thread_broadcast", "ts": "1600000004.000000", "user": "U2", "text": "also in channel",
+   "thread_ts": "1600000000.000100"}
+]`
+
+const slackGeneralDay2 = `[
+  {"type": "message", "subtype": "bot_message", "ts": "1600090000.000000", "bot_id": "B1", "username": "deploybot", "text": "~done~",
+   "files": [{"name": "log.txt", "mimetype": "text/plain", "url_private_download": "https://files.slack.com/log.txt"},
+             {"name": "gone.txt", "mode": "hidden_by_limit"}]}
+]`
+
+func TestSlackExport(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"channels.json":           slackChannels,
+		"users.json":              slackUsers,
+		"general/2020-09-14.json": slackGeneralDay2,
+		"general/2020-09-13.json": slackGeneralDay1,
+		"random/2020-09-13.json":  `[]`,
+		"integration_logs.json":   `[]`,
+		"general/not-a-day.json":  `{}`,
+		"unknown/2020-09-13.json": `[]`,
+	})
+	zr, err := openZip(r, r.Size())
+	if err != nil {
+		t.Fatalf("openZip: %v", err)
+	}
+	arc, msgs := collectMessages(t, newSlackExport(zr, nil))
+
+	if len(arc.Channels) != 2 || arc.Channels[0].Name != "general" || *arc.Channels[0].Topic != "Company-wide" ||
+		*arc.Channels[1].Topic != "Anything" {
+		t.Fatalf("channels = %+v", arc.Channels)
+	}
+	if len(arc.Roles) != 0 || len(arc.Categories) != 0 {
+		t.Errorf("Slack exports have no roles or categories: %+v", arc)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want 4: %+v", len(msgs), msgs)
+	}
+
+	root := msgs[0]
+	if root.Content != "Hello @bob, see [the docs](https://example.com) & **this**" {
+		t.Errorf("root content = %q", root.Content)
+	}
+	if !root.Pinned || root.ThreadRemoteID != "" || root.AuthorName != "alice" || *root.AuthorColor != "#9f69e7" ||
+		*root.AuthorAvatar != "https://avatars.slack-edge.com/u1.png" {
+		t.Errorf("root = %+v", root)
+	}
+	if !root.CreatedAt.Equal(time.Unix(1600000000, 100000)) {
+		t.Errorf("root time = %v", root.CreatedAt)
+	}
+
+	reply := msgs[1]
+	if reply.ThreadRemoteID != root.RemoteID || reply.AuthorName != "Bob" || reply.EditedAt == nil {
+		t.Errorf("thread reply = %+v", reply)
+	}
+	broadcast := msgs[2]
+	if broadcast.ThreadRemoteID != "" || broadcast.ReplyToRemoteID != root.RemoteID {
+		t.Errorf("broadcast = %+v", broadcast)
+	}
+	bot := msgs[3]
+	if bot.Content != "~~done~~" || bot.AuthorName != "deploybot" || bot.AuthorRemoteID != "B1" ||
+		len(bot.Files) != 1 || bot.Files[0].URL != "https://files.slack.com/log.txt" {
+		t.Errorf("bot message = %+v", bot)
+	}
+}
+
+func TestSlackExport_NotSlack(t *testing.T) {
+	r := buildZip(t, map[string]string{"general.json": `{}`})
+	zr, err := openZip(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newSlackExport(zr, nil).Load(t.Context()); err == nil {
+		t.Error("expected an error for an archive without channels.json")
+	}
+}
+
+func TestSlackTime(t *testing.T) {
+	tests := []struct {
+		ts   string
+		want time.Time
+		ok   bool
+	}{
+		{"1600000000.000100", time.Unix(1600000000, 100000), true},
+		{"1600000000.5", time.Unix(1600000000, 500000000), true},
+		{"1600000000", time.Unix(1600000000, 0), true},
+		{"abc", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := slackTime(tt.ts)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("slackTime(%q) = %v, %v; want %v, %v", tt.ts, got, ok, tt.want, tt.ok)
+		}
+	}
+}
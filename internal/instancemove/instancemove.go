@@ -0,0 +1,356 @@
+// Package instancemove implements `amityvox migrate-instance`, which re-homes
+// an instance to a new domain. The instance keeps its ID, users and guilds;
+// its instances row takes the new domain and, optionally, a freshly generated
+// federation key. The move is signed with the key peers already hold and sent
+// to every active peer, which update their record of the instance in place
+// instead of meeting a stranger at the new domain.
+//
+// What cannot be done from the database — config, DNS, TLS, the reverse
+// proxy, restarting the server — is listed in the report as a checklist.
+// Run it with the server stopped: a server still running with the old key
+// signs federation traffic peers no longer accept.
+package instancemove
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/federation"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Config controls a move.
+type Config struct {
+	Pool      *pgxpool.Pool
+	OldDomain string // instance.domain the server runs with today
+	NewDomain string // domain to move to; its DNS must already point here
+	NewKey    bool   // replace the federation key as part of the move
+
+	DryRun     bool // report what would happen without changing anything
+	SkipNotify bool // update local records but do not contact peers
+	// Resend sends the latest recorded move of the instance known by
+	// OldDomain (before or after the move) to its peers again, for peers
+	// that were unreachable the first time. NewDomain and NewKey are unused.
+	Resend bool
+
+	// StaleSettings are config settings still naming the old domain, as
+	// "setting = value", listed in the checklist.
+	StaleSettings []string
+	Client        *http.Client // for notifying peers; nil for a default
+}
+
+// PeerResult is how one peer took the move notice.
+type PeerResult struct {
+	Domain string
+	Status string // "applied" or "already_applied"; empty if Err is set
+	Err    string
+}
+
+// Report is the outcome of a move.
+type Report struct {
+	InstanceID     string
+	OldDomain      string
+	NewDomain      string
+	NewKey         bool
+	NewFingerprint string
+	IssuedAt       time.Time
+	DryRun         bool
+	Resend         bool
+	Notified       bool
+	Peers          []PeerResult
+	StaleSettings  []string
+}
+
+// Failed returns the domains of the peers that did not accept the notice.
+func (r *Report) Failed() []string {
+	var out []string
+	for _, p := range r.Peers {
+		if p.Err != "" {
+			out = append(out, p.Domain)
+		}
+	}
+	return out
+}
+
+// localInstance is the instances row of this instance.
+type localInstance struct {
+	id     string
+	domain string
+	key    ed25519.PrivateKey
+}
+
+// Run moves the instance, or with cfg.Resend re-sends its latest move.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 15 * time.Second}
+	}
+	if cfg.Resend {
+		return resend(ctx, cfg)
+	}
+
+	newDomain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(cfg.NewDomain)), ".")
+	if newDomain == "" {
+		return nil, fmt.Errorf("the new domain is required")
+	}
+	if strings.EqualFold(newDomain, cfg.OldDomain) {
+		return nil, fmt.Errorf("the instance is already at %s", newDomain)
+	}
+	if err := federation.ValidateFederationDomain(newDomain); err != nil {
+		return nil, fmt.Errorf("new domain: %w (point its DNS at this server before moving)", err)
+	}
+
+	inst, err := loadLocalInstance(ctx, cfg.Pool, cfg.OldDomain)
+	if err != nil {
+		return nil, err
+	}
+	var holder string
+	err = cfg.Pool.QueryRow(ctx, `SELECT id FROM instances WHERE domain = $1`, newDomain).Scan(&holder)
+	if err == nil {
+		return nil, fmt.Errorf("%s is already the domain of instance %s", newDomain, holder)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("checking new domain: %w", err)
+	}
+
+	var newKey ed25519.PrivateKey
+	if cfg.NewKey {
+		if _, newKey, err = ed25519.GenerateKey(nil); err != nil {
+			return nil, fmt.Errorf("generating Ed25519 key pair: %w", err)
+		}
+	}
+	notice, err := federation.NewMoveNotice(inst.id, inst.key, newKey, inst.domain, newDomain, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		InstanceID:    inst.id,
+		OldDomain:     inst.domain,
+		NewDomain:     newDomain,
+		NewKey:        cfg.NewKey,
+		IssuedAt:      notice.Timestamp,
+		DryRun:        cfg.DryRun,
+		StaleSettings: cfg.StaleSettings,
+	}
+	peers, err := activePeers(ctx, cfg.Pool, inst.id)
+	if err != nil {
+		return nil, err
+	}
+
+	var pubPEM, privPEM string
+	var fingerprint *string
+	if newKey != nil {
+		if pubPEM, err = federation.EncodePublicKeyPEM(newKey.Public().(ed25519.PublicKey)); err != nil {
+			return nil, err
+		}
+		if privPEM, err = federation.EncodePrivateKeyPEM(newKey); err != nil {
+			return nil, err
+		}
+		fp, err := federation.ComputeKeyFingerprint(pubPEM)
+		if err != nil {
+			return nil, err
+		}
+		fingerprint = &fp
+		report.NewFingerprint = fp
+	}
+
+	if cfg.DryRun {
+		for _, p := range peers {
+			report.Peers = append(report.Peers, PeerResult{Domain: p})
+		}
+		return report, nil
+	}
+
+	noticeJSON, err := json.Marshal(notice)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling move notice: %w", err)
+	}
+	err = apiutil.WithTx(ctx, cfg.Pool, func(tx pgx.Tx) error {
+		if newKey == nil {
+			if _, err := tx.Exec(ctx,
+				`UPDATE instances SET domain = $1 WHERE id = $2`, newDomain, inst.id); err != nil {
+				return fmt.Errorf("updating instance: %w", err)
+			}
+		} else if _, err := tx.Exec(ctx,
+			`UPDATE instances SET domain = $1, public_key = $2, private_key_pem = $3, key_fingerprint = $4
+			 WHERE id = $5`,
+			newDomain, pubPEM, privPEM, *fingerprint, inst.id); err != nil {
+			return fmt.Errorf("updating instance: %w", err)
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO federation_instance_moves
+			     (id, instance_id, old_domain, new_domain, new_key_fingerprint, issued_at, signature, notice, recorded_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())`,
+			models.NewULID().String(), inst.id, inst.domain, newDomain, fingerprint, notice.Timestamp,
+			notice.Signature, string(noticeJSON))
+		if err != nil {
+			return fmt.Errorf("recording move: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.SkipNotify {
+		report.Notified = true
+		report.Peers = notifyPeers(ctx, cfg.Client, peers, noticeJSON)
+	}
+	return report, nil
+}
+
+// resend sends the latest recorded move of the local instance to its peers
+// again.
+func resend(ctx context.Context, cfg Config) (*Report, error) {
+	report := &Report{Resend: true, DryRun: cfg.DryRun, StaleSettings: cfg.StaleSettings}
+	var (
+		noticeJSON  string
+		fingerprint *string
+	)
+	err := cfg.Pool.QueryRow(ctx,
+		`SELECT m.instance_id, m.old_domain, m.new_domain, m.new_key_fingerprint, m.issued_at, m.notice
+		 FROM federation_instance_moves m
+		 JOIN instances i ON i.id = m.instance_id
+		 WHERE i.private_key_pem IS NOT NULL AND i.domain = m.new_domain
+		   AND (m.old_domain = $1 OR m.new_domain = $1)
+		 ORDER BY m.issued_at DESC LIMIT 1`,
+		cfg.OldDomain,
+	).Scan(&report.InstanceID, &report.OldDomain, &report.NewDomain, &fingerprint, &report.IssuedAt, &noticeJSON)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("no move of this instance from or to %s is recorded", cfg.OldDomain)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading recorded move: %w", err)
+	}
+	if fingerprint != nil {
+		report.NewKey = true
+		report.NewFingerprint = *fingerprint
+	}
+
+	peers, err := activePeers(ctx, cfg.Pool, report.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DryRun {
+		for _, p := range peers {
+			report.Peers = append(report.Peers, PeerResult{Domain: p})
+		}
+		return report, nil
+	}
+	report.Notified = true
+	report.Peers = notifyPeers(ctx, cfg.Client, peers, []byte(noticeJSON))
+	return report, nil
+}
+
+// loadLocalInstance loads the instance served at domain along with its
+// private key.
+func loadLocalInstance(ctx context.Context, pool *pgxpool.Pool, domain string) (*localInstance, error) {
+	inst := &localInstance{}
+	var keyPEM *string
+	err := pool.QueryRow(ctx,
+		`SELECT id, domain, private_key_pem FROM instances WHERE domain = $1`, domain,
+	).Scan(&inst.id, &inst.domain, &keyPEM)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("no instance with domain %s; check instance.domain in the config", domain)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading local instance: %w", err)
+	}
+	if keyPEM == nil || *keyPEM == "" {
+		return nil, fmt.Errorf("%s has no stored private key; it is not this instance, or the server has never started", domain)
+	}
+	if inst.key, err = federation.ParsePrivateKeyPEM(*keyPEM); err != nil {
+		return nil, fmt.Errorf("loading local instance key: %w", err)
+	}
+	return inst, nil
+}
+
+// activePeers lists the domains of the instance's active federation peers.
+func activePeers(ctx context.Context, pool *pgxpool.Pool, instanceID string) ([]string, error) {
+	rows, err := pool.Query(ctx,
+		`SELECT i.domain FROM federation_peers fp
+		 JOIN instances i ON i.id = fp.peer_id
+		 WHERE fp.instance_id = $1 AND fp.status = 'active'
+		 ORDER BY i.domain`, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("listing peers: %w", err)
+	}
+	defer rows.Close()
+	var peers []string
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, fmt.Errorf("reading peers: %w", err)
+		}
+		peers = append(peers, d)
+	}
+	return peers, rows.Err()
+}
+
+// notifyPeers posts the move notice to each peer in turn.
+func notifyPeers(ctx context.Context, client *http.Client, peers []string, notice []byte) []PeerResult {
+	results := make([]PeerResult, 0, len(peers))
+	for _, domain := range peers {
+		res := PeerResult{Domain: domain}
+		status, err := notifyPeer(ctx, client, domain, notice)
+		if err != nil {
+			res.Err = err.Error()
+		} else {
+			res.Status = status
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// notifyPeer posts the move notice to one peer and returns the status it
+// answered with.
+func notifyPeer(ctx context.Context, client *http.Client, domain string, notice []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("https://%s/federation/v1/instance-move", domain), bytes.NewReader(notice))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return parsePeerResponse(resp.StatusCode, io.LimitReader(resp.Body, 64<<10))
+}
+
+// parsePeerResponse reads a peer's answer to a move notice: a status on
+// success, otherwise the error envelope.
+func parsePeerResponse(statusCode int, body io.Reader) (string, error) {
+	data, _ := io.ReadAll(body)
+	if statusCode == http.StatusOK {
+		var ok struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(data, &ok); err != nil || ok.Status == "" {
+			return "applied", nil
+		}
+		return ok.Status, nil
+	}
+	var failed apiutil.ErrorResponse
+	if err := json.Unmarshal(data, &failed); err == nil && failed.Error.Code != "" {
+		return "", fmt.Errorf("HTTP %d: %s: %s", statusCode, failed.Error.Code, failed.Error.Message)
+	}
+	if statusCode == http.StatusNotFound || statusCode == http.StatusMethodNotAllowed {
+		return "", fmt.Errorf("HTTP %d: the peer does not support instance moves yet", statusCode)
+	}
+	return "", fmt.Errorf("HTTP %d", statusCode)
+}
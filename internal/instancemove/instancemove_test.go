@@ -0,0 +1,99 @@
+package instancemove
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePeerResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       int
+		body       string
+		wantStatus string
+		wantErr    string
+	}{
+		{"applied", http.StatusOK, `{"status":"applied"}`, "applied", ""},
+		{"already applied", http.StatusOK, `{"status":"already_applied"}`, "already_applied", ""},
+		{"ok without body", http.StatusOK, ``, "applied", ""},
+		{"coded error", http.StatusConflict, `{"error":{"code":"domain_taken","message":"Another instance is already known by b.example"}}`,
+			"", "HTTP 409: domain_taken: Another instance is already known by b.example"},
+		{"old peer", http.StatusNotFound, `404 page not found`, "", "does not support instance moves"},
+		{"plain error", http.StatusBadGateway, `bad gateway`, "", "HTTP 502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := parsePeerResponse(tt.code, strings.NewReader(tt.body))
+			if status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", status, tt.wantStatus)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReportChecklist(t *testing.T) {
+	r := &Report{
+		InstanceID: "inst1",
+		OldDomain:  "old.example",
+		NewDomain:  "new.example",
+		IssuedAt:   time.Now(),
+		Notified:   true,
+		Peers: []PeerResult{
+			{Domain: "a.example", Status: "applied"},
+			{Domain: "b.example", Err: "HTTP 502"},
+		},
+		StaleSettings: []string{`auth.webauthn.rp_id = "old.example"`},
+	}
+	if got := r.Failed(); len(got) != 1 || got[0] != "b.example" {
+		t.Errorf("Failed() = %v, want [b.example]", got)
+	}
+
+	steps := strings.Join(r.Checklist(), "\n")
+	for _, want := range []string{`instance.domain = "new.example"`, "auth.webauthn.rp_id", "--resend", "Redirect old.example"} {
+		if !strings.Contains(steps, want) {
+			t.Errorf("checklist lacks %q:\n%s", want, steps)
+		}
+	}
+
+	r.Peers = r.Peers[:1]
+	if steps := strings.Join(r.Checklist(), "\n"); strings.Contains(steps, "--resend") {
+		t.Errorf("checklist asks to re-send with every peer notified:\n%s", steps)
+	}
+
+	resent := &Report{Resend: true, OldDomain: "old.example", NewDomain: "new.example", Notified: true}
+	if steps := resent.Checklist(); len(steps) != 0 {
+		t.Errorf("re-send with every peer notified left steps: %v", steps)
+	}
+}
+
+func TestReportPrint_DryRun(t *testing.T) {
+	r := &Report{
+		InstanceID: "inst1",
+		OldDomain:  "old.example",
+		NewDomain:  "new.example",
+		NewKey:     true,
+		DryRun:     true,
+		IssuedAt:   time.Now(),
+		Peers:      []PeerResult{{Domain: "a.example"}},
+	}
+	var buf bytes.Buffer
+	r.Print(&buf)
+	out := buf.String()
+	for _, want := range []string{"Dry run: would move old.example to new.example", "federation key  replaced", "a.example"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Steps left") {
+		t.Errorf("dry run printed a checklist:\n%s", out)
+	}
+}
@@ -0,0 +1,97 @@
+package instancemove
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/federation"
+)
+
+// Print writes the report and the checklist of steps left to the operator.
+func (r *Report) Print(w io.Writer) {
+	switch {
+	case r.Resend && r.DryRun:
+		fmt.Fprintf(w, "Dry run: would re-send the move of %s to %s\n", r.OldDomain, r.NewDomain)
+	case r.Resend:
+		fmt.Fprintf(w, "Re-sent the move of %s to %s\n", r.OldDomain, r.NewDomain)
+	case r.DryRun:
+		fmt.Fprintf(w, "Dry run: would move %s to %s\n", r.OldDomain, r.NewDomain)
+	default:
+		fmt.Fprintf(w, "Moved %s to %s\n", r.OldDomain, r.NewDomain)
+	}
+	fmt.Fprintf(w, "  instance ID     %s (unchanged)\n", r.InstanceID)
+	if r.NewKey {
+		fmt.Fprintf(w, "  federation key  replaced, fingerprint %s\n", r.NewFingerprint)
+	} else {
+		fmt.Fprintf(w, "  federation key  kept\n")
+	}
+	fmt.Fprintf(w, "  move issued     %s (peers accept it for %s)\n",
+		r.IssuedAt.Format(time.RFC3339), noticeLifetime())
+
+	fmt.Fprintln(w)
+	switch {
+	case r.DryRun:
+		fmt.Fprintf(w, "Peers to notify   %d\n", len(r.Peers))
+		for _, p := range r.Peers {
+			fmt.Fprintf(w, "  %s\n", p.Domain)
+		}
+	case !r.Notified:
+		fmt.Fprintln(w, "Peers             not notified (--skip-notify)")
+	default:
+		fmt.Fprintf(w, "Peers notified    %d of %d\n", len(r.Peers)-len(r.Failed()), len(r.Peers))
+		for _, p := range r.Peers {
+			if p.Err != "" {
+				fmt.Fprintf(w, "  %-30s FAILED %s\n", p.Domain, p.Err)
+			} else {
+				fmt.Fprintf(w, "  %-30s %s\n", p.Domain, p.Status)
+			}
+		}
+	}
+
+	if r.DryRun {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Steps left:")
+	for i, step := range r.Checklist() {
+		fmt.Fprintf(w, "  %d. %s\n", i+1, step)
+	}
+}
+
+// noticeLifetime is how long peers accept a move notice after it was issued.
+func noticeLifetime() string {
+	return fmt.Sprintf("%d days", int(federation.MaxMoveNoticeAge.Hours()/24))
+}
+
+// Checklist returns the manual steps that remain after the move.
+func (r *Report) Checklist() []string {
+	var steps []string
+	if !r.Resend {
+		steps = append(steps,
+			fmt.Sprintf("Set instance.domain = %q in amityvox.toml (or AMITYVOX_INSTANCE_DOMAIN). "+
+				"The server finds its identity by this domain and would create a new one under the old name.", r.NewDomain))
+	}
+	for _, s := range r.StaleSettings {
+		steps = append(steps, fmt.Sprintf("Update %s, which still names %s.", s, r.OldDomain))
+	}
+	if !r.Resend {
+		steps = append(steps,
+			fmt.Sprintf("Serve %s: DNS records, a TLS certificate and the reverse proxy's server name.", r.NewDomain),
+			fmt.Sprintf("Redirect %s to %s so old invite links and bookmarks keep working.", r.OldDomain, r.NewDomain),
+			"Passkeys are bound to the old domain; users sign in another way and register them again.",
+		)
+	}
+	switch failed := r.Failed(); {
+	case !r.Notified:
+		steps = append(steps, "Notify peers with `amityvox migrate-instance --resend`.")
+	case len(failed) > 0:
+		steps = append(steps, fmt.Sprintf(
+			"Re-send the move to the %d peers that did not take it with `amityvox migrate-instance --resend` "+
+				"within %s; after that they must be re-added by hand.", len(failed), noticeLifetime()))
+	}
+	if !r.Resend {
+		steps = append(steps, "Start the server and check /.well-known/amityvox on "+r.NewDomain+".")
+	}
+	return steps
+}
@@ -0,0 +1,345 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+
+	"github.com/amityvox/amityvox/internal/api"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/config"
+	"github.com/amityvox/amityvox/internal/gateway"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// stack is an API server and WebSocket gateway running in-process against
+// the test containers, wired the way cmd/amityvox does it.
+type stack struct {
+	t          *testing.T
+	APIURL     string // base URL of the REST API, without /api/v1
+	GatewayURL string // ws:// URL of the gateway endpoint
+	InstanceID string
+}
+
+// newStack boots the API and gateway for one test and stops them when it
+// ends. Tests share the containers, so scenarios should use unique names.
+func newStack(t *testing.T) *stack {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := testBus.EnsureStreams(); err != nil {
+		t.Fatalf("ensuring NATS streams: %v", err)
+	}
+	instanceID := ensureTestInstance(t)
+
+	// A config path that does not exist yields the built-in defaults.
+	cfg, err := config.Load(filepath.Join(t.TempDir(), "amityvox.toml"))
+	if err != nil {
+		t.Fatalf("loading default config: %v", err)
+	}
+	cfg.Auth.RegistrationEnabled = true
+	cfg.Auth.InviteOnly = false
+	cfg.Auth.RequireEmail = false
+
+	authSvc := auth.NewService(auth.Config{
+		Pool:            testPool,
+		Cache:           testCache,
+		InstanceID:      instanceID,
+		SessionDuration: 24 * time.Hour,
+		RegEnabled:      true,
+		Logger:          testLogger,
+	})
+
+	srv := api.NewServer(testDB, cfg, authSvc, testBus, testCache, testMedia, nil, nil, instanceID, testLogger)
+	srv.Version = "integration"
+	srv.RegisterRoutes()
+	apiServer := httptest.NewServer(srv.Router)
+	t.Cleanup(apiServer.Close)
+
+	addr := freeAddr(t)
+	gw := gateway.NewServer(gateway.ServerConfig{
+		AuthService:       authSvc,
+		EventBus:          testBus,
+		Cache:             testCache,
+		Pool:              testPool,
+		HeartbeatInterval: 30 * time.Second,
+		HeartbeatTimeout:  90 * time.Second,
+		ListenAddr:        addr,
+		BuildVersion:      "integration",
+		LocalInstanceID:   instanceID,
+		Logger:            testLogger,
+	})
+	go func() {
+		if err := gw.Start(); err != nil {
+			testLogger.Error("integration gateway stopped", "error", err)
+		}
+	}()
+	t.Cleanup(func() {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		gw.Shutdown(shutdownCtx)
+	})
+
+	// Wait for the gateway to accept connections.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gateway did not start on %s: %v", addr, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return &stack{
+		t:          t,
+		APIURL:     apiServer.URL,
+		GatewayURL: "ws://" + addr + "/ws",
+		InstanceID: instanceID,
+	}
+}
+
+// ensureTestInstance returns the local instance ID, creating the instance row
+// if no test has yet.
+func ensureTestInstance(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+
+	var instanceID string
+	testPool.QueryRow(ctx, `SELECT id FROM instances LIMIT 1`).Scan(&instanceID)
+	if instanceID != "" {
+		return instanceID
+	}
+	instanceID = models.NewULID().String()
+	if _, err := testPool.Exec(ctx,
+		`INSERT INTO instances (id, domain, public_key, name, software_version, federation_mode, created_at)
+		 VALUES ($1, 'test.local', 'test-key', 'Test Instance', 'test', 'closed', now())`,
+		instanceID); err != nil {
+		t.Fatalf("creating test instance: %v", err)
+	}
+	return instanceID
+}
+
+// freeAddr returns a loopback address with a port that was free a moment ago.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("finding a free port: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// --- REST client ---
+
+// apiClient calls the REST API as one user.
+type apiClient struct {
+	s     *stack
+	User  models.User
+	Token string
+}
+
+// anonymous returns a client without a session, for public endpoints.
+func (s *stack) anonymous() *apiClient {
+	return &apiClient{s: s}
+}
+
+// register creates a user with a unique name derived from prefix and returns
+// a client logged in as that user.
+func (s *stack) register(prefix string) *apiClient {
+	s.t.Helper()
+	username := prefix + "_" + strings.ToLower(models.NewULID().String()[16:])
+
+	var resp struct {
+		User  models.User `json:"user"`
+		Token string      `json:"token"`
+	}
+	s.anonymous().mustDo(http.MethodPost, "/auth/register", map[string]string{
+		"username": username,
+		"password": "Integration1234!",
+	}, http.StatusCreated, &resp)
+	if resp.Token == "" {
+		s.t.Fatalf("registering %s: no session token returned", username)
+	}
+	return &apiClient{s: s, User: resp.User, Token: resp.Token}
+}
+
+// do sends a JSON request to /api/v1 + path and decodes the data envelope of
+// the response into out, if given. It returns the status code.
+func (c *apiClient) do(method, path string, body, out interface{}) int {
+	c.s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.s.t.Fatalf("%s %s: encoding body: %v", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.s.APIURL+"/api/v1"+path, reader)
+	if err != nil {
+		c.s.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.send(req, out)
+}
+
+// send performs req with the client's session and decodes the response.
+func (c *apiClient) send(req *http.Request, out interface{}) int {
+	c.s.t.Helper()
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.s.t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	if out != nil && resp.StatusCode < 300 {
+		var envelope struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(raw, &envelope); err != nil {
+			c.s.t.Fatalf("%s %s: decoding response %s: %v", req.Method, req.URL.Path, raw, err)
+		}
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			c.s.t.Fatalf("%s %s: decoding data %s: %v", req.Method, req.URL.Path, envelope.Data, err)
+		}
+	}
+	if resp.StatusCode >= 300 {
+		c.s.t.Logf("%s %s -> %d: %s", req.Method, req.URL.Path, resp.StatusCode, raw)
+	}
+	return resp.StatusCode
+}
+
+// mustDo is do that fails the test unless the response has the want status.
+func (c *apiClient) mustDo(method, path string, body interface{}, want int, out interface{}) {
+	c.s.t.Helper()
+	if got := c.do(method, path, body, out); got != want {
+		c.s.t.Fatalf("%s %s: expected status %d, got %d", method, path, want, got)
+	}
+}
+
+// upload stores a file through the media service and returns the attachment.
+func (c *apiClient) upload(filename string, content []byte) models.Attachment {
+	c.s.t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		c.s.t.Fatalf("building upload: %v", err)
+	}
+	fw.Write(content)
+	mw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, c.s.APIURL+"/api/v1/files/upload", &buf)
+	if err != nil {
+		c.s.t.Fatalf("building upload request: %v", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var attachment models.Attachment
+	if got := c.send(req, &attachment); got != http.StatusCreated {
+		c.s.t.Fatalf("uploading %s: expected status 201, got %d", filename, got)
+	}
+	return attachment
+}
+
+// --- Gateway client ---
+
+// gatewayClient is an identified WebSocket gateway connection.
+type gatewayClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// connect opens a gateway connection for the client's user and waits for
+// READY. The connection is closed when the test ends.
+func (c *apiClient) connect() *gatewayClient {
+	c.s.t.Helper()
+	t := c.s.t
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, c.s.GatewayURL, nil)
+	if err != nil {
+		t.Fatalf("dialing gateway: %v", err)
+	}
+	conn.SetReadLimit(4 << 20)
+	t.Cleanup(func() { conn.Close(websocket.StatusNormalClosure, "") })
+
+	var hello gateway.GatewayMessage
+	if err := wsjson.Read(ctx, conn, &hello); err != nil {
+		t.Fatalf("reading HELLO: %v", err)
+	}
+	if hello.Op != gateway.OpHello {
+		t.Fatalf("expected HELLO (op %d), got op %d", gateway.OpHello, hello.Op)
+	}
+
+	identify, _ := json.Marshal(gateway.IdentifyPayload{Token: c.Token})
+	if err := wsjson.Write(ctx, conn, gateway.GatewayMessage{Op: gateway.OpIdentify, Data: identify}); err != nil {
+		t.Fatalf("sending IDENTIFY: %v", err)
+	}
+
+	g := &gatewayClient{t: t, conn: conn}
+	g.waitFor("READY", nil)
+	return g
+}
+
+// waitFor reads dispatches until one of type eventType matches, or fails the
+// test after a timeout. A nil match accepts the first event of that type.
+func (g *gatewayClient) waitFor(eventType string, match func(json.RawMessage) bool) json.RawMessage {
+	g.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for {
+		var msg gateway.GatewayMessage
+		if err := wsjson.Read(ctx, g.conn, &msg); err != nil {
+			g.t.Fatalf("waiting for %s: %v", eventType, err)
+		}
+		if msg.Op == gateway.OpDispatch && msg.Type == eventType && (match == nil || match(msg.Data)) {
+			return msg.Data
+		}
+	}
+}
+
+// hasID matches event payloads whose "id" field equals id.
+func hasID(id string) func(json.RawMessage) bool {
+	return func(data json.RawMessage) bool {
+		var v struct {
+			ID string `json:"id"`
+		}
+		return json.Unmarshal(data, &v) == nil && v.ID == id
+	}
+}
+
+// describe formats a value for failure messages.
+func describe(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return string(data)
+}
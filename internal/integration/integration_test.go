@@ -1,7 +1,9 @@
 // Package integration provides integration tests for AmityVox using dockertest.
-// These tests spin up real PostgreSQL, NATS, and DragonflyDB containers, run
-// migrations, and test the full stack including database queries, event bus
-// pub/sub, and cache operations. Tests are skipped if Docker is unavailable.
+// These tests spin up real PostgreSQL, NATS, DragonflyDB, and MinIO containers,
+// run migrations, and test the full stack including database queries, event
+// bus pub/sub, and cache operations. Scenario tests boot the API and gateway
+// in-process (see harness_test.go) and drive them as real clients would.
+// Tests are skipped if Docker is unavailable.
 //
 // Run with: go test -tags integration ./internal/integration/ -v
 package integration
@@ -24,6 +26,7 @@ import (
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/database"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/media"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/presence"
 )
@@ -33,6 +36,7 @@ var (
 	testDB     *database.DB
 	testBus    *events.Bus
 	testCache  *presence.Cache
+	testMedia  *media.Service
 	testLogger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
 	dockerPool *dockertest.Pool
 )
@@ -161,6 +165,52 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	// Start MinIO (S3-compatible storage).
+	minioResource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "minio/minio",
+		Tag:        "latest",
+		Cmd:        []string{"server", "/data"},
+		Env: []string{
+			"MINIO_ROOT_USER=amityvox_test",
+			"MINIO_ROOT_PASSWORD=testpass123",
+		},
+	}, func(config *docker.HostConfig) {
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	if err != nil {
+		fmt.Printf("Could not start MinIO: %v\n", err)
+		pgResource.Close()
+		natsResource.Close()
+		redisResource.Close()
+		os.Exit(1)
+	}
+
+	// Wait for MinIO to be ready and create the bucket.
+	if err := pool.Retry(func() error {
+		svc, err := media.New(media.Config{
+			Endpoint:  "localhost:" + minioResource.GetPort("9000/tcp"),
+			Bucket:    "amityvox-test",
+			AccessKey: "amityvox_test",
+			SecretKey: "testpass123",
+			Region:    "us-east-1",
+			Pool:      testPool,
+			Logger:    testLogger,
+		})
+		if err != nil {
+			return err
+		}
+		testMedia = svc
+		return svc.EnsureBucket(context.Background())
+	}); err != nil {
+		fmt.Printf("Could not connect to MinIO: %v\n", err)
+		pgResource.Close()
+		natsResource.Close()
+		redisResource.Close()
+		minioResource.Close()
+		os.Exit(1)
+	}
+
 	// Run tests.
 	code := m.Run()
 
@@ -171,6 +221,7 @@ func TestMain(m *testing.M) {
 	pgResource.Close()
 	natsResource.Close()
 	redisResource.Close()
+	minioResource.Close()
 
 	os.Exit(code)
 }
@@ -0,0 +1,111 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// --- Multi-user Scenarios ---
+
+// setupGuild has owner create a guild with a text channel and invites the
+// members into it. It returns the guild and channel.
+func setupGuild(t *testing.T, owner *apiClient, members ...*apiClient) (models.Guild, models.Channel) {
+	t.Helper()
+
+	var guild models.Guild
+	owner.mustDo(http.MethodPost, "/guilds", map[string]string{"name": "Scenario Guild"}, http.StatusCreated, &guild)
+
+	var channel models.Channel
+	owner.mustDo(http.MethodPost, "/guilds/"+guild.ID+"/channels", map[string]string{
+		"name":         "general",
+		"channel_type": models.ChannelTypeText,
+	}, http.StatusCreated, &channel)
+
+	var invite models.Invite
+	owner.mustDo(http.MethodPost, "/guilds/"+guild.ID+"/invites", map[string]interface{}{
+		"channel_id": channel.ID,
+	}, http.StatusCreated, &invite)
+
+	for _, m := range members {
+		m.mustDo(http.MethodPost, "/invites/"+invite.Code, nil, http.StatusOK, nil)
+	}
+	return guild, channel
+}
+
+func TestScenarioGuildMessaging(t *testing.T) {
+	s := newStack(t)
+	alice := s.register("alice")
+	bob := s.register("bob")
+	mallory := s.register("mallory")
+
+	_, channel := setupGuild(t, alice, bob)
+	bobGateway := bob.connect()
+
+	var sent models.Message
+	alice.mustDo(http.MethodPost, "/channels/"+channel.ID+"/messages", map[string]string{
+		"content": "hello from alice",
+	}, http.StatusCreated, &sent)
+
+	// Bob sees the message live over the gateway...
+	var got models.Message
+	if err := json.Unmarshal(bobGateway.waitFor("MESSAGE_CREATE", hasID(sent.ID)), &got); err != nil {
+		t.Fatalf("decoding MESSAGE_CREATE: %v", err)
+	}
+	if got.ChannelID != channel.ID || got.AuthorID != alice.User.ID {
+		t.Errorf("MESSAGE_CREATE has channel %q author %q, want %q and %q",
+			got.ChannelID, got.AuthorID, channel.ID, alice.User.ID)
+	}
+	if got.Content == nil || *got.Content != "hello from alice" {
+		t.Errorf("MESSAGE_CREATE content = %s, want %q", describe(got.Content), "hello from alice")
+	}
+
+	// ...and in the channel history.
+	var history []models.Message
+	bob.mustDo(http.MethodGet, "/channels/"+channel.ID+"/messages", nil, http.StatusOK, &history)
+	found := false
+	for _, m := range history {
+		found = found || m.ID == sent.ID
+	}
+	if !found {
+		t.Errorf("message %s missing from bob's channel history", sent.ID)
+	}
+
+	// Someone outside the guild can neither read nor post.
+	if code := mallory.do(http.MethodGet, "/channels/"+channel.ID+"/messages", nil, nil); code < 400 {
+		t.Errorf("non-member read history with status %d", code)
+	}
+	if code := mallory.do(http.MethodPost, "/channels/"+channel.ID+"/messages", map[string]string{"content": "hi"}, nil); code < 400 {
+		t.Errorf("non-member posted with status %d", code)
+	}
+}
+
+func TestScenarioAttachmentMessage(t *testing.T) {
+	s := newStack(t)
+	alice := s.register("alice")
+	bob := s.register("bob")
+
+	_, channel := setupGuild(t, alice, bob)
+	bobGateway := bob.connect()
+
+	attachment := alice.upload("notes.txt", []byte("integration attachment"))
+	if attachment.ID == "" {
+		t.Fatal("upload returned no attachment ID")
+	}
+
+	var sent models.Message
+	alice.mustDo(http.MethodPost, "/channels/"+channel.ID+"/messages", map[string]interface{}{
+		"content":        "see attached",
+		"attachment_ids": []string{attachment.ID},
+	}, http.StatusCreated, &sent)
+
+	var got models.Message
+	if err := json.Unmarshal(bobGateway.waitFor("MESSAGE_CREATE", hasID(sent.ID)), &got); err != nil {
+		t.Fatalf("decoding MESSAGE_CREATE: %v", err)
+	}
+	if len(got.Attachments) != 1 || got.Attachments[0].ID != attachment.ID {
+		t.Errorf("MESSAGE_CREATE attachments = %s, want [%s]", describe(got.Attachments), attachment.ID)
+	}
+}
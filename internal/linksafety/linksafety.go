@@ -0,0 +1,335 @@
+// Package linksafety checks links posted in messages against the instance's
+// domain blocklist and, when an API key is configured, Google Safe Browsing.
+// Links found malicious are rewritten to point at a warning interstitial, so
+// a click shows what the link was flagged for before anyone follows it.
+package linksafety
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// DefaultSafeBrowsingURL is the Safe Browsing v4 lookup endpoint.
+const DefaultSafeBrowsingURL = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+
+// ThreatBlocklisted is the threat recorded for links on blocklisted domains.
+const ThreatBlocklisted = "BLOCKLISTED"
+
+// maxCacheEntries bounds the Safe Browsing verdict cache. Expired entries
+// are dropped once it is reached.
+const maxCacheEntries = 10000
+
+// Config holds the configuration for a Checker.
+type Config struct {
+	Pool               *pgxpool.Pool
+	SafeBrowsingAPIKey string        // empty to check the blocklist only
+	SafeBrowsingURL    string        // defaults to DefaultSafeBrowsingURL
+	CacheTTL           time.Duration // how long a Safe Browsing verdict is reused
+	InterstitialURL    string        // warning page links are rewritten to
+}
+
+// Checker looks up the reputation of message links.
+type Checker struct {
+	pool         *pgxpool.Pool
+	apiKey       string
+	apiURL       string
+	ttl          time.Duration
+	interstitial string
+	client       *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedVerdict
+}
+
+type cachedVerdict struct {
+	threat  string // empty when the link is clean
+	expires time.Time
+}
+
+// New creates a Checker.
+func New(cfg Config) *Checker {
+	apiURL := cfg.SafeBrowsingURL
+	if apiURL == "" {
+		apiURL = DefaultSafeBrowsingURL
+	}
+	return &Checker{
+		pool:         cfg.Pool,
+		apiKey:       cfg.SafeBrowsingAPIKey,
+		apiURL:       apiURL,
+		ttl:          cfg.CacheTTL,
+		interstitial: cfg.InterstitialURL,
+		client:       &http.Client{Timeout: 5 * time.Second},
+		cache:        make(map[string]cachedVerdict),
+	}
+}
+
+// urlRegex finds http(s) links, including those wrapped in <> or markdown
+// link syntax.
+var urlRegex = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `()\[\]]+`)
+
+// trimURL drops punctuation that ends a sentence rather than the link.
+func trimURL(match string) string {
+	return strings.TrimRight(match, ".,;:!?}'\"")
+}
+
+// ExtractURLs returns the distinct links in content, leaving out links that
+// already point at the interstitial.
+func (c *Checker) ExtractURLs(content string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, match := range urlRegex.FindAllString(content, -1) {
+		u := trimURL(match)
+		if seen[u] || (c.interstitial != "" && strings.HasPrefix(u, c.interstitial)) {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// Check returns a verdict for each of urls found malicious. Blocklisted
+// domains are matched first; the remaining links go to Safe Browsing when it
+// is configured. If Safe Browsing cannot be reached the blocklist verdicts
+// are still returned, alongside the error.
+func (c *Checker) Check(ctx context.Context, urls []string) ([]models.LinkVerdict, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	blocked, err := c.checkBlocklist(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	verdicts := make([]models.LinkVerdict, 0, len(blocked))
+	var rest []string
+	for _, u := range urls {
+		if threat, ok := blocked[u]; ok {
+			verdicts = append(verdicts, models.LinkVerdict{URL: u, Threat: threat, Source: models.LinkSourceBlocklist})
+			continue
+		}
+		rest = append(rest, u)
+	}
+
+	if c.apiKey == "" || len(rest) == 0 {
+		return verdicts, nil
+	}
+	threats, err := c.checkSafeBrowsing(ctx, rest)
+	for _, u := range rest {
+		if threat := threats[u]; threat != "" {
+			verdicts = append(verdicts, models.LinkVerdict{URL: u, Threat: threat, Source: models.LinkSourceSafeBrowsing})
+		}
+	}
+	return verdicts, err
+}
+
+// Rewrite replaces each link in content that has a verdict with a link to
+// the interstitial, carrying the original link and its threat.
+func (c *Checker) Rewrite(content string, verdicts []models.LinkVerdict) string {
+	threats := make(map[string]string, len(verdicts))
+	for _, v := range verdicts {
+		threats[v.URL] = v.Threat
+	}
+	return urlRegex.ReplaceAllStringFunc(content, func(match string) string {
+		u := trimURL(match)
+		threat, ok := threats[u]
+		if !ok {
+			return match
+		}
+		q := url.Values{"url": {u}, "threat": {threat}}
+		return c.interstitial + "?" + q.Encode() + match[len(u):]
+	})
+}
+
+// checkBlocklist returns the links whose host, or a parent domain of it, is
+// on the blocklist, mapped to their threat.
+func (c *Checker) checkBlocklist(ctx context.Context, urls []string) (map[string]string, error) {
+	hosts := make(map[string][]string) // candidate domain -> links under it
+	var candidates []string
+	for _, u := range urls {
+		for _, d := range domainCandidates(u) {
+			if _, ok := hosts[d]; !ok {
+				candidates = append(candidates, d)
+			}
+			hosts[d] = append(hosts[d], u)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	rows, err := c.pool.Query(ctx, `SELECT domain FROM link_blocklist WHERE domain = ANY($1)`, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("checking link blocklist: %w", err)
+	}
+	defer rows.Close()
+
+	blocked := make(map[string]string)
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, fmt.Errorf("scanning link blocklist: %w", err)
+		}
+		for _, u := range hosts[domain] {
+			blocked[u] = ThreatBlocklisted
+		}
+	}
+	return blocked, rows.Err()
+}
+
+// NormalizeDomain turns a blocklist entry, given as a domain or a link, into
+// the lower-case host stored in the blocklist. ok is false when no usable
+// domain remains.
+func NormalizeDomain(entry string) (domain string, ok bool) {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if !strings.Contains(entry, "://") {
+		entry = "http://" + entry
+	}
+	parsed, err := url.Parse(entry)
+	if err != nil {
+		return "", false
+	}
+	domain = strings.TrimSuffix(parsed.Hostname(), ".")
+	if len(domain) > 253 || !strings.Contains(domain, ".") || strings.ContainsAny(domain, " *") {
+		return "", false
+	}
+	return domain, true
+}
+
+// domainCandidates returns the link's host and each parent domain of it,
+// e.g. a.b.example.com, b.example.com, example.com.
+func domainCandidates(rawURL string) []string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" {
+		return nil
+	}
+	var out []string
+	for {
+		out = append(out, host)
+		i := strings.IndexByte(host, '.')
+		if i < 0 || !strings.Contains(host[i+1:], ".") {
+			return out
+		}
+		host = host[i+1:]
+	}
+}
+
+// safeBrowsingRequest is the body of a Safe Browsing threatMatches:find call.
+type safeBrowsingRequest struct {
+	Client struct {
+		ClientID      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion"`
+	} `json:"client"`
+	ThreatInfo struct {
+		ThreatTypes      []string            `json:"threatTypes"`
+		PlatformTypes    []string            `json:"platformTypes"`
+		ThreatEntryTypes []string            `json:"threatEntryTypes"`
+		ThreatEntries    []map[string]string `json:"threatEntries"`
+	} `json:"threatInfo"`
+}
+
+type safeBrowsingResponse struct {
+	Matches []struct {
+		ThreatType string `json:"threatType"`
+		Threat     struct {
+			URL string `json:"url"`
+		} `json:"threat"`
+	} `json:"matches"`
+}
+
+// checkSafeBrowsing looks urls up in Safe Browsing, answering from the cache
+// where it can. The result maps each malicious link to its threat type.
+func (c *Checker) checkSafeBrowsing(ctx context.Context, urls []string) (map[string]string, error) {
+	threats := make(map[string]string)
+	var lookup []string
+	now := time.Now()
+	c.mu.Lock()
+	for _, u := range urls {
+		if v, ok := c.cache[u]; ok && now.Before(v.expires) {
+			if v.threat != "" {
+				threats[u] = v.threat
+			}
+			continue
+		}
+		lookup = append(lookup, u)
+	}
+	c.mu.Unlock()
+	if len(lookup) == 0 {
+		return threats, nil
+	}
+
+	var req safeBrowsingRequest
+	req.Client.ClientID = "amityvox"
+	req.Client.ClientVersion = "1.0"
+	req.ThreatInfo.ThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+	req.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	req.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	for _, u := range lookup {
+		req.ThreatInfo.ThreatEntries = append(req.ThreatInfo.ThreatEntries, map[string]string{"url": u})
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return threats, fmt.Errorf("encoding safe browsing request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.apiURL+"?key="+url.QueryEscape(c.apiKey), bytes.NewReader(body))
+	if err != nil {
+		return threats, fmt.Errorf("creating safe browsing request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return threats, fmt.Errorf("safe browsing lookup: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return threats, fmt.Errorf("safe browsing returned status %d", resp.StatusCode)
+	}
+	var result safeBrowsingResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return threats, fmt.Errorf("decoding safe browsing response: %w", err)
+	}
+
+	found := make(map[string]string)
+	for _, m := range result.Matches {
+		found[m.Threat.URL] = m.ThreatType
+	}
+	expires := time.Now().Add(c.ttl)
+	c.mu.Lock()
+	if len(c.cache)+len(lookup) > maxCacheEntries {
+		for u, v := range c.cache {
+			if !now.Before(v.expires) {
+				delete(c.cache, u)
+			}
+		}
+	}
+	for _, u := range lookup {
+		if threat := found[u]; threat != "" {
+			threats[u] = threat
+		}
+		if len(c.cache) < maxCacheEntries {
+			c.cache[u] = cachedVerdict{threat: found[u], expires: expires}
+		}
+	}
+	c.mu.Unlock()
+	return threats, nil
+}
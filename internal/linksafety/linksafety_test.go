@@ -0,0 +1,113 @@
+package linksafety
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+const testInterstitial = "https://chat.example.com/app/link-warning"
+
+func TestExtractURLs(t *testing.T) {
+	c := New(Config{InterstitialURL: testInterstitial})
+	content := "see https://evil.example/login, [docs](https://docs.example/a) and <http://x.example/p?q=1>. " +
+		"again https://evil.example/login and " + testInterstitial + "?url=https%3A%2F%2Fold.example"
+
+	got := c.ExtractURLs(content)
+	want := []string{"https://evil.example/login", "https://docs.example/a", "http://x.example/p?q=1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractURLs() = %v, want %v", got, want)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	c := New(Config{InterstitialURL: testInterstitial})
+	content := "free nitro https://evil.example/gift! or https://evil.example/gift2"
+	verdicts := []models.LinkVerdict{{URL: "https://evil.example/gift", Threat: "SOCIAL_ENGINEERING"}}
+
+	got := c.Rewrite(content, verdicts)
+	want := "free nitro " + testInterstitial +
+		"?threat=SOCIAL_ENGINEERING&url=https%3A%2F%2Fevil.example%2Fgift! or https://evil.example/gift2"
+	if got != want {
+		t.Errorf("Rewrite() = %q, want %q", got, want)
+	}
+	if urls := c.ExtractURLs(got); !reflect.DeepEqual(urls, []string{"https://evil.example/gift2"}) {
+		t.Errorf("rewritten links should not be checked again, got %v", urls)
+	}
+}
+
+func TestDomainCandidates(t *testing.T) {
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://a.b.Example.com/x", []string{"a.b.example.com", "b.example.com", "example.com"}},
+		{"https://example.com:8443", []string{"example.com"}},
+		{"http://localhost/", []string{"localhost"}},
+		{"https:///nohost", nil},
+	}
+	for _, tt := range tests {
+		if got := domainCandidates(tt.url); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("domainCandidates(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  string
+		ok    bool
+	}{
+		{" Scam.Example ", "scam.example", true},
+		{"https://login.scam.example/path?q=1", "login.scam.example", true},
+		{"scam.example.", "scam.example", true},
+		{"localhost", "", false},
+		{"*.scam.example", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeDomain(tt.entry)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeDomain(%q) = %q, %v; want %q, %v", tt.entry, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheckSafeBrowsing_Caches(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("key") != "test-key" {
+			t.Errorf("api key = %q", r.URL.Query().Get("key"))
+		}
+		var req safeBrowsingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.ThreatInfo.ThreatEntries) != 2 {
+			t.Errorf("looked up %d links, want 2", len(req.ThreatInfo.ThreatEntries))
+		}
+		w.Write([]byte(`{"matches":[{"threatType":"MALWARE","threat":{"url":"https://bad.example/"}}]}`))
+	}))
+	defer srv.Close()
+
+	c := New(Config{SafeBrowsingAPIKey: "test-key", SafeBrowsingURL: srv.URL, CacheTTL: time.Minute})
+	urls := []string{"https://bad.example/", "https://good.example/"}
+	for i := 0; i < 2; i++ {
+		threats, err := c.checkSafeBrowsing(context.Background(), urls)
+		if err != nil {
+			t.Fatalf("checkSafeBrowsing: %v", err)
+		}
+		if !reflect.DeepEqual(threats, map[string]string{"https://bad.example/": "MALWARE"}) {
+			t.Errorf("threats = %v", threats)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("safe browsing called %d times, want 1", calls)
+	}
+}
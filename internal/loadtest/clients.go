@@ -0,0 +1,286 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+
+	"github.com/amityvox/amityvox/internal/gateway"
+)
+
+// httpClient is shared by all REST senders so connections are pooled.
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		MaxIdleConnsPerHost: 256,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// apiError is a non-2xx API response.
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Body)
+}
+
+// restClient calls the REST API with one session token.
+type restClient struct {
+	baseURL string
+	token   string
+}
+
+func (r *run) rest(token string) *restClient {
+	return &restClient{baseURL: r.cfg.BaseURL, token: token}
+}
+
+// do sends a JSON request to /api/v1 + path and decodes the data envelope of
+// the response into out, if given.
+func (c *restClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v1"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "amityvox-loadtest")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		return &apiError{Status: resp.StatusCode, Body: string(bytes.TrimSpace(raw))}
+	}
+	if out == nil {
+		return nil
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// sessions returns the session tokens to run with, registering throwaway
+// accounts when none were given.
+func (r *run) sessions(ctx context.Context) ([]string, error) {
+	if len(r.cfg.Tokens) > 0 {
+		return r.cfg.Tokens, nil
+	}
+
+	n := r.cfg.Clients + r.cfg.Senders
+	tokens := make([]string, n)
+	errs := make(chan error, n)
+	sem := make(chan struct{}, 8)
+	var wg sync.WaitGroup
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			secret := make([]byte, 16)
+			rand.Read(secret)
+			var resp struct {
+				Token string `json:"token"`
+			}
+			err := r.rest("").do(ctx, "POST", "/auth/register", map[string]string{
+				"username": fmt.Sprintf("lt_%s_%d", r.runID, i),
+				"password": hex.EncodeToString(secret),
+			}, &resp)
+			if err != nil {
+				errs <- err
+				return
+			}
+			tokens[i] = resp.Token
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return nil, fmt.Errorf("registering synthetic users (pass existing session tokens to skip this): %w", err)
+	}
+	r.cfg.Logger.Info("registered synthetic users", slog.Int("count", n))
+	return tokens, nil
+}
+
+// setupChannel creates a guild and text channel owned by the first session
+// and joins every other session to it through an invite.
+func (r *run) setupChannel(ctx context.Context, tokens []string) (string, error) {
+	owner := r.rest(tokens[0])
+	var guild struct {
+		ID string `json:"id"`
+	}
+	if err := owner.do(ctx, "POST", "/guilds", map[string]string{"name": "Load test " + r.runID}, &guild); err != nil {
+		return "", fmt.Errorf("creating guild: %w", err)
+	}
+	var channel struct {
+		ID string `json:"id"`
+	}
+	if err := owner.do(ctx, "POST", "/guilds/"+guild.ID+"/channels", map[string]string{
+		"name": "load-test", "channel_type": "text",
+	}, &channel); err != nil {
+		return "", fmt.Errorf("creating channel: %w", err)
+	}
+	var invite struct {
+		Code string `json:"code"`
+	}
+	if err := owner.do(ctx, "POST", "/guilds/"+guild.ID+"/invites", map[string]string{"channel_id": channel.ID}, &invite); err != nil {
+		return "", fmt.Errorf("creating invite: %w", err)
+	}
+
+	seen := map[string]bool{tokens[0]: true}
+	for _, token := range tokens {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		if err := r.rest(token).do(ctx, "POST", "/invites/"+invite.Code, nil, nil); err != nil {
+			return "", fmt.Errorf("joining load test guild: %w", err)
+		}
+	}
+	r.cfg.Logger.Info("created load test channel",
+		slog.String("guild_id", guild.ID), slog.String("channel_id", channel.ID))
+	return channel.ID, nil
+}
+
+// listen runs one synthetic gateway client: it connects after delay,
+// identifies, calls ready once it has READY (or has failed), and then times
+// MESSAGE_CREATE deliveries in channelID until ctx ends.
+func (r *run) listen(ctx context.Context, token, channelID string, delay time.Duration, ready func()) {
+	var once sync.Once
+	markReady := func() { once.Do(ready) }
+	defer markReady()
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+
+	started := time.Now()
+	conn, interval, err := r.identify(ctx, token)
+	if err != nil {
+		r.failed.Add(1)
+		r.cfg.Logger.Debug("gateway client failed to connect", slog.String("error", err.Error()))
+		return
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "")
+	r.connect.add(time.Since(started))
+	r.connected.Add(1)
+	markReady()
+
+	go heartbeat(ctx, conn, interval)
+
+	for {
+		var msg gateway.GatewayMessage
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			if ctx.Err() == nil {
+				r.cfg.Logger.Debug("gateway client disconnected", slog.String("error", err.Error()))
+			}
+			return
+		}
+		if msg.Op != gateway.OpDispatch || msg.Type != "MESSAGE_CREATE" {
+			continue
+		}
+		at := time.Now()
+		var m struct {
+			ChannelID string  `json:"channel_id"`
+			Nonce     *string `json:"nonce"`
+		}
+		if json.Unmarshal(msg.Data, &m) == nil && m.ChannelID == channelID && m.Nonce != nil {
+			r.delivered(*m.Nonce, at)
+		}
+	}
+}
+
+// identify dials the gateway and completes HELLO, IDENTIFY and READY. It
+// returns the connection and the heartbeat interval the server asked for.
+func (r *run) identify(ctx context.Context, token string) (*websocket.Conn, time.Duration, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(dialCtx, r.cfg.GatewayURL, &websocket.DialOptions{
+		HTTPHeader: http.Header{"User-Agent": {"amityvox-loadtest"}},
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("dialing gateway: %w", err)
+	}
+	conn.SetReadLimit(16 << 20)
+
+	var hello gateway.GatewayMessage
+	if err := wsjson.Read(dialCtx, conn, &hello); err != nil || hello.Op != gateway.OpHello {
+		conn.Close(websocket.StatusProtocolError, "")
+		return nil, 0, fmt.Errorf("expected HELLO: %v", err)
+	}
+	var hp gateway.HelloPayload
+	json.Unmarshal(hello.Data, &hp)
+
+	data, _ := json.Marshal(gateway.IdentifyPayload{Token: token})
+	if err := wsjson.Write(dialCtx, conn, gateway.GatewayMessage{Op: gateway.OpIdentify, Data: data}); err != nil {
+		conn.Close(websocket.StatusProtocolError, "")
+		return nil, 0, fmt.Errorf("sending IDENTIFY: %w", err)
+	}
+	for {
+		var msg gateway.GatewayMessage
+		if err := wsjson.Read(dialCtx, conn, &msg); err != nil {
+			conn.Close(websocket.StatusProtocolError, "")
+			return nil, 0, fmt.Errorf("waiting for READY: %w", err)
+		}
+		if msg.Op == gateway.OpDispatch && msg.Type == "READY" {
+			break
+		}
+	}
+
+	interval := time.Duration(hp.HeartbeatInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return conn, interval, nil
+}
+
+// heartbeat keeps a gateway connection alive until ctx ends.
+func heartbeat(ctx context.Context, conn *websocket.Conn, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if err := wsjson.Write(ctx, conn, gateway.GatewayMessage{Op: gateway.OpHeartbeat}); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Package loadtest drives synthetic users against a running AmityVox instance
+// to measure how it holds up before a large community moves in. Gateway
+// clients sit in one channel while REST senders post to it at a fixed rate;
+// every sent message carries a nonce so each client can time its delivery
+// from POST to MESSAGE_CREATE.
+//
+// Point it at a staging instance: it registers throwaway accounts and a
+// guild unless given existing sessions and a channel, and per-IP rate limits
+// will skew the results unless they are relaxed for the run.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Config controls a load test run.
+type Config struct {
+	BaseURL    string        // instance root URL, e.g. https://chat.example.com
+	GatewayURL string        // WebSocket URL; defaults to BaseURL with /ws
+	Clients    int           // synthetic gateway clients listening in the channel
+	Senders    int           // REST clients posting messages
+	Rate       float64       // messages per second per sender
+	Duration   time.Duration // how long senders post for
+	RampUp     time.Duration // spread gateway connects over this long
+	Drain      time.Duration // wait for late deliveries after sending stops
+
+	// ChannelID targets an existing channel every session can read and post
+	// in. When empty a guild and channel are created for the run.
+	ChannelID string
+	// Tokens are existing session tokens, used round-robin by clients and
+	// senders. When empty, one account is registered per client and sender.
+	Tokens []string
+
+	Progress io.Writer // periodic progress lines; nil for none
+	Logger   *slog.Logger
+}
+
+// setDefaults fills unset fields and checks the rest.
+func (c *Config) setDefaults() error {
+	u, err := url.Parse(strings.TrimRight(c.BaseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("target must be an http(s) URL, got %q", c.BaseURL)
+	}
+	c.BaseURL = u.String()
+	if c.GatewayURL == "" {
+		ws := *u
+		ws.Scheme = "ws"
+		if u.Scheme == "https" {
+			ws.Scheme = "wss"
+		}
+		ws.Path += "/ws"
+		c.GatewayURL = ws.String()
+	}
+	if c.Clients < 0 || c.Senders < 1 {
+		return errors.New("need at least one sender and a non-negative number of clients")
+	}
+	if c.Rate <= 0 {
+		return errors.New("rate must be positive")
+	}
+	if c.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+	if c.ChannelID != "" && len(c.Tokens) == 0 {
+		return errors.New("an existing channel needs existing session tokens")
+	}
+	if c.Drain <= 0 {
+		c.Drain = 5 * time.Second
+	}
+	if c.Logger == nil {
+		c.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return nil
+}
+
+// run is the shared state of one load test.
+type run struct {
+	cfg   Config
+	runID string
+
+	sendTimes sync.Map // nonce -> time.Time the POST started
+
+	connect  latencies
+	send     latencies
+	delivery latencies
+
+	connected  atomic.Int64
+	failed     atomic.Int64
+	sent       atomic.Int64
+	deliveries atomic.Int64
+
+	errMu      sync.Mutex
+	sendErrors map[string]int
+}
+
+// Run performs a load test and reports the results. Cancelling ctx stops it
+// early; the report then covers what ran.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	r := &run{
+		cfg:        cfg,
+		runID:      strings.ToLower(models.NewULID().String()[20:]),
+		sendErrors: make(map[string]int),
+	}
+
+	tokens, err := r.sessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	channelID := cfg.ChannelID
+	if channelID == "" {
+		if channelID, err = r.setupChannel(ctx, tokens); err != nil {
+			return nil, err
+		}
+	}
+
+	// Connect the gateway clients, spread over the ramp-up.
+	listenCtx, stopListening := context.WithCancel(ctx)
+	defer stopListening()
+	var ready, listeners sync.WaitGroup
+	for i := 0; i < cfg.Clients; i++ {
+		ready.Add(1)
+		listeners.Add(1)
+		delay := time.Duration(0)
+		if cfg.Clients > 1 {
+			delay = cfg.RampUp * time.Duration(i) / time.Duration(cfg.Clients-1)
+		}
+		go func(token string) {
+			defer listeners.Done()
+			r.listen(listenCtx, token, channelID, delay, ready.Done)
+		}(tokens[i%len(tokens)])
+	}
+	ready.Wait()
+	cfg.Logger.Info("gateway clients ready",
+		slog.Int64("connected", r.connected.Load()), slog.Int64("failed", r.failed.Load()))
+
+	// Send for the configured duration, then let late deliveries arrive.
+	start := time.Now()
+	stopProgress := r.reportProgress(ctx, start)
+	var senders sync.WaitGroup
+	for i := 0; i < cfg.Senders; i++ {
+		senders.Add(1)
+		go func(i int) {
+			defer senders.Done()
+			r.sendLoop(ctx, start.Add(cfg.Duration), i, tokens[(cfg.Clients+i)%len(tokens)], channelID)
+		}(i)
+	}
+	senders.Wait()
+	elapsed := time.Since(start)
+	r.drain(ctx)
+	stopProgress()
+	stopListening()
+	listeners.Wait()
+
+	sent := int(r.sent.Load())
+	r.errMu.Lock()
+	defer r.errMu.Unlock()
+	return &Report{
+		Target:             cfg.BaseURL,
+		Duration:           elapsed,
+		ClientsConnected:   int(r.connected.Load()),
+		ClientsFailed:      int(r.failed.Load()),
+		Connect:            r.connect.summary(),
+		Sent:               sent,
+		SendErrors:         r.sendErrors,
+		Send:               r.send.summary(),
+		ExpectedDeliveries: sent * int(r.connected.Load()),
+		Delivery:           r.delivery.summary(),
+	}, nil
+}
+
+// drain waits until every expected delivery has arrived, the drain period
+// passes, or ctx ends.
+func (r *run) drain(ctx context.Context) {
+	deadline := time.After(r.cfg.Drain)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for r.deliveries.Load() < r.sent.Load()*r.connected.Load() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// reportProgress prints a progress line every few seconds until stopped.
+func (r *run) reportProgress(ctx context.Context, start time.Time) (stop func()) {
+	if r.cfg.Progress == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tick := time.NewTicker(5 * time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				fmt.Fprintf(r.cfg.Progress, "[%4.0fs] sent %d, delivered %d, clients %d\n",
+					time.Since(start).Seconds(), r.sent.Load(), r.deliveries.Load(), r.connected.Load())
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// sendLoop posts messages at the configured rate until the deadline or ctx
+// ends. A request in flight at the deadline is allowed to finish so every
+// message the server created is counted.
+func (r *run) sendLoop(ctx context.Context, deadline time.Time, sender int, token, channelID string) {
+	c := r.rest(token)
+	interval := time.Duration(float64(time.Second) / r.cfg.Rate)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for seq := 0; ; seq++ {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C:
+			if !now.Before(deadline) {
+				return
+			}
+		}
+
+		nonce := fmt.Sprintf("lt-%s-%d-%d", r.runID, sender, seq)
+		started := time.Now()
+		r.sendTimes.Store(nonce, started)
+		err := c.do(ctx, "POST", "/channels/"+channelID+"/messages", map[string]string{
+			"content": fmt.Sprintf("load test message %d from sender %d", seq, sender),
+			"nonce":   nonce,
+		}, nil)
+		if err != nil {
+			r.sendTimes.Delete(nonce)
+			if ctx.Err() != nil {
+				return // the run was cancelled mid-request
+			}
+			r.recordSendError(err)
+			continue
+		}
+		r.send.add(time.Since(started))
+		r.sent.Add(1)
+	}
+}
+
+// recordSendError counts a failed send by status code or error kind.
+func (r *run) recordSendError(err error) {
+	kind := "network error"
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		kind = fmt.Sprintf("HTTP %d", apiErr.Status)
+	}
+	r.errMu.Lock()
+	r.sendErrors[kind]++
+	r.errMu.Unlock()
+	r.cfg.Logger.Debug("send failed", slog.String("error", err.Error()))
+}
+
+// delivered records a MESSAGE_CREATE seen by a gateway client.
+func (r *run) delivered(nonce string, at time.Time) {
+	v, ok := r.sendTimes.Load(nonce)
+	if !ok {
+		return // not ours, or its POST failed
+	}
+	r.delivery.add(at.Sub(v.(time.Time)))
+	r.deliveries.Add(1)
+}
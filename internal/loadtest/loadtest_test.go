@@ -0,0 +1,89 @@
+package loadtest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var samples []time.Duration
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+
+	s := summarize(samples)
+	if s.Count != 100 || s.P50 != 50*time.Millisecond || s.P90 != 90*time.Millisecond ||
+		s.P99 != 99*time.Millisecond || s.Max != 100*time.Millisecond {
+		t.Errorf("summarize(1..100ms) = %+v", s)
+	}
+
+	if got := percentile([]time.Duration{7 * time.Millisecond}, 99); got != 7*time.Millisecond {
+		t.Errorf("percentile of one sample = %s, want 7ms", got)
+	}
+	if got := summarize(nil); got.Count != 0 || got.String() != "no samples" {
+		t.Errorf("summarize(nil) = %+v (%s)", got, got)
+	}
+}
+
+func TestLatenciesSummary(t *testing.T) {
+	var l latencies
+	for _, d := range []time.Duration{30, 10, 20} {
+		l.add(d * time.Millisecond)
+	}
+	if s := l.summary(); s.P50 != 20*time.Millisecond || s.Max != 30*time.Millisecond {
+		t.Errorf("summary of unsorted samples = %+v", s)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg := Config{BaseURL: "https://chat.example.com/", Clients: 10, Senders: 1, Rate: 1, Duration: time.Minute}
+	if err := cfg.setDefaults(); err != nil {
+		t.Fatalf("setDefaults: %v", err)
+	}
+	if cfg.BaseURL != "https://chat.example.com" {
+		t.Errorf("BaseURL = %q", cfg.BaseURL)
+	}
+	if cfg.GatewayURL != "wss://chat.example.com/ws" {
+		t.Errorf("GatewayURL = %q, want wss://chat.example.com/ws", cfg.GatewayURL)
+	}
+
+	cfg = Config{BaseURL: "http://localhost:8080", Senders: 1, Rate: 1, Duration: time.Minute}
+	cfg.setDefaults()
+	if cfg.GatewayURL != "ws://localhost:8080/ws" {
+		t.Errorf("GatewayURL = %q, want ws://localhost:8080/ws", cfg.GatewayURL)
+	}
+
+	for name, bad := range map[string]Config{
+		"no scheme":          {BaseURL: "chat.example.com", Senders: 1, Rate: 1, Duration: time.Minute},
+		"no senders":         {BaseURL: "http://x", Rate: 1, Duration: time.Minute},
+		"zero rate":          {BaseURL: "http://x", Senders: 1, Duration: time.Minute},
+		"zero duration":      {BaseURL: "http://x", Senders: 1, Rate: 1},
+		"channel, no tokens": {BaseURL: "http://x", Senders: 1, Rate: 1, Duration: time.Minute, ChannelID: "c"},
+	} {
+		if err := bad.setDefaults(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+	r := &Report{
+		Target:             "https://chat.example.com",
+		Duration:           time.Minute,
+		ClientsConnected:   2,
+		Sent:               10,
+		SendErrors:         map[string]int{"HTTP 429": 3},
+		ExpectedDeliveries: 20,
+		Delivery:           Summary{Count: 19, P50: 12 * time.Millisecond, Max: 40 * time.Millisecond},
+	}
+	var buf bytes.Buffer
+	r.Print(&buf)
+	out := buf.String()
+	for _, want := range []string{"10 (3 errors)", "HTTP 429", "19 of 20 (95.00%)", "p50 12ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
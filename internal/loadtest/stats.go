@@ -0,0 +1,142 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencies collects duration samples from many goroutines.
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.mu.Lock()
+	l.samples = append(l.samples, d)
+	l.mu.Unlock()
+}
+
+// summary sorts the samples collected so far and summarizes them.
+func (l *latencies) summary() Summary {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	l.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return summarize(sorted)
+}
+
+// Summary describes a latency distribution.
+type Summary struct {
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// summarize computes percentiles over samples, which must be sorted.
+func summarize(sorted []time.Duration) Summary {
+	if len(sorted) == 0 {
+		return Summary{}
+	}
+	return Summary{
+		Count: len(sorted),
+		P50:   percentile(sorted, 50),
+		P90:   percentile(sorted, 90),
+		P99:   percentile(sorted, 99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func (s Summary) String() string {
+	if s.Count == 0 {
+		return "no samples"
+	}
+	return fmt.Sprintf("p50 %s  p90 %s  p99 %s  max %s",
+		round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+}
+
+// round trims durations to a readable precision.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
+
+// Report is the outcome of a load test run.
+type Report struct {
+	Target   string
+	Duration time.Duration
+
+	ClientsConnected int
+	ClientsFailed    int
+	Connect          Summary
+
+	Sent       int
+	SendErrors map[string]int // by HTTP status or error kind
+	Send       Summary        // REST round trip of message creation
+
+	// ExpectedDeliveries is messages sent times connected clients: every
+	// client is in the channel, so each should see every message.
+	ExpectedDeliveries int
+	Delivery           Summary // send to MESSAGE_CREATE on a gateway client
+}
+
+// DeliveryRate is the share of expected deliveries that arrived.
+func (r *Report) DeliveryRate() float64 {
+	if r.ExpectedDeliveries == 0 {
+		return 0
+	}
+	return float64(r.Delivery.Count) / float64(r.ExpectedDeliveries)
+}
+
+// Print writes a human-readable report.
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Load test against %s (%s)\n\n", r.Target, r.Duration.Round(time.Second))
+	fmt.Fprintf(w, "Gateway clients   %d connected, %d failed\n", r.ClientsConnected, r.ClientsFailed)
+	fmt.Fprintf(w, "  connect         %s\n", r.Connect)
+
+	errors := 0
+	for _, n := range r.SendErrors {
+		errors += n
+	}
+	fmt.Fprintf(w, "Messages sent     %d (%d errors)\n", r.Sent, errors)
+	kinds := make([]string, 0, len(r.SendErrors))
+	for k := range r.SendErrors {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		fmt.Fprintf(w, "  %-15s %d\n", k, r.SendErrors[k])
+	}
+	fmt.Fprintf(w, "  REST latency    %s\n", r.Send)
+
+	fmt.Fprintf(w, "Deliveries        %d of %d (%.2f%%)\n",
+		r.Delivery.Count, r.ExpectedDeliveries, 100*r.DeliveryRate())
+	fmt.Fprintf(w, "  end-to-end      %s\n", r.Delivery)
+}
@@ -0,0 +1,159 @@
+// Package locale writes times for a guild the way its members expect: in
+// the guild's timezone, with the clock and date order of its preferred
+// locale. It is used wherever the server produces text for a guild, such as
+// system messages and event reminders, rather than sending a timestamp for
+// the client to render.
+package locale
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // guild timezones must resolve in minimal containers
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/text/language"
+)
+
+// Defaults for guilds that have not chosen.
+const (
+	DefaultLocale   = "en"
+	DefaultTimezone = "UTC"
+)
+
+// Settings are a guild's locale and timezone.
+type Settings struct {
+	Locale   string
+	Timezone string
+}
+
+// ForGuild loads a guild's settings. Unknown guilds and unreadable values
+// fall back to the defaults, so callers can always format something.
+func ForGuild(ctx context.Context, pool *pgxpool.Pool, guildID string) Settings {
+	s := Settings{Locale: DefaultLocale, Timezone: DefaultTimezone}
+	pool.QueryRow(ctx,
+		`SELECT COALESCE(preferred_locale, 'en'), timezone FROM guilds WHERE id = $1`, guildID,
+	).Scan(&s.Locale, &s.Timezone)
+	return s
+}
+
+// ParseLocale checks a BCP 47 language tag and returns its canonical form,
+// e.g. "en-us" becomes "en-US".
+func ParseLocale(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || len(s) > 35 {
+		return "", fmt.Errorf("invalid locale %q", s)
+	}
+	tag, err := language.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid locale %q", s)
+	}
+	return tag.String(), nil
+}
+
+// ParseLanguage checks a language tag and returns just its ISO 639 language,
+// e.g. "pt-BR" becomes "pt". Channels declare a language rather than a
+// locale, since search, translation and word lists work per language.
+func ParseLanguage(s string) (string, error) {
+	loc, err := ParseLocale(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid language %q", s)
+	}
+	base, conf := language.MustParse(loc).Base()
+	if conf != language.Exact {
+		return "", fmt.Errorf("invalid language %q", s)
+	}
+	return base.String(), nil
+}
+
+// ParseTimezone checks an IANA timezone name such as "Europe/Berlin".
+func ParseTimezone(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "Local" {
+		return "", fmt.Errorf("invalid timezone %q", s)
+	}
+	if _, err := time.LoadLocation(s); err != nil {
+		return "", fmt.Errorf("unknown timezone %q", s)
+	}
+	return s, nil
+}
+
+// Location returns the guild's timezone, or UTC if it does not load.
+func (s Settings) Location() *time.Location {
+	if s.Timezone != "" && s.Timezone != "Local" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// Clock writes the time of day with the zone, e.g. "3:04 PM EST" or
+// "15:04 CET".
+func (s Settings) Clock(t time.Time) string {
+	t = t.In(s.Location())
+	if s.twelveHour() {
+		return t.Format("3:04 PM MST")
+	}
+	return t.Format("15:04 MST")
+}
+
+// DateTime writes the date and time with the zone, e.g.
+// "Jan 2, 2006 3:04 PM EST" or "02.01.2006 15:04 CET".
+func (s Settings) DateTime(t time.Time) string {
+	t = t.In(s.Location())
+	return t.Format(s.dateLayout()) + " " + s.Clock(t)
+}
+
+// twelveHourLanguages use a 12-hour clock by default.
+var twelveHourLanguages = map[string]bool{
+	"en": true, "hi": true, "bn": true, "ur": true, "ar": true, "ko": true, "fil": true,
+}
+
+// twentyFourHourEnglish are regions where English uses a 24-hour clock.
+var twentyFourHourEnglish = map[string]bool{
+	"GB": true, "IE": true, "ZA": true, "MT": true,
+}
+
+func (s Settings) twelveHour() bool {
+	lang, region := s.parts()
+	if lang == "en" && twentyFourHourEnglish[region] {
+		return false
+	}
+	return twelveHourLanguages[lang]
+}
+
+// dateLayout picks the numeric date order for the locale. Month names are
+// only used for English, since Go formats them in English.
+func (s Settings) dateLayout() string {
+	lang, region := s.parts()
+	switch lang {
+	case "en":
+		if region == "" || region == "US" || region == "PH" {
+			return "Jan 2, 2006"
+		}
+		return "2 Jan 2006"
+	case "de", "ru", "pl", "fi", "nb", "nn", "no", "da", "cs", "sk", "tr", "uk", "ro":
+		return "02.01.2006"
+	case "ja", "zh", "ko", "sv", "lt", "hu":
+		return "2006-01-02"
+	case "nl":
+		return "02-01-2006"
+	default:
+		return "02/01/2006"
+	}
+}
+
+// parts splits the locale into its base language and region.
+func (s Settings) parts() (lang, region string) {
+	tag, err := language.Parse(s.Locale)
+	if err != nil {
+		return DefaultLocale, ""
+	}
+	base, _ := tag.Base()
+	if r, conf := tag.Region(); conf == language.Exact {
+		region = r.String()
+	}
+	return base.String(), region
+}
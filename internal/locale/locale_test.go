@@ -0,0 +1,78 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLocale(t *testing.T) {
+	for in, want := range map[string]string{
+		"en":     "en",
+		"en-us":  "en-US",
+		"pt_BR":  "pt-BR",
+		" de-DE": "de-DE",
+	} {
+		if got, err := ParseLocale(in); err != nil || got != want {
+			t.Errorf("ParseLocale(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "not a locale", "x"} {
+		if _, err := ParseLocale(bad); err == nil {
+			t.Errorf("ParseLocale(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseTimezone(t *testing.T) {
+	if got, err := ParseTimezone("Europe/Berlin"); err != nil || got != "Europe/Berlin" {
+		t.Errorf("ParseTimezone(Europe/Berlin) = %q, %v", got, err)
+	}
+	for _, bad := range []string{"", "Local", "Mars/Olympus"} {
+		if _, err := ParseTimezone(bad); err == nil {
+			t.Errorf("ParseTimezone(%q) should fail", bad)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	at := time.Date(2026, 3, 5, 19, 30, 0, 0, time.UTC)
+	tests := []struct {
+		settings Settings
+		clock    string
+		dateTime string
+	}{
+		{Settings{"en-US", "America/New_York"}, "2:30 PM EST", "Mar 5, 2026 2:30 PM EST"},
+		{Settings{"en-GB", "Europe/London"}, "19:30 GMT", "5 Mar 2026 19:30 GMT"},
+		{Settings{"de-DE", "Europe/Berlin"}, "20:30 CET", "05.03.2026 20:30 CET"},
+		{Settings{"ja", "Asia/Tokyo"}, "04:30 JST", "2026-03-06 04:30 JST"},
+		{Settings{"fr", "UTC"}, "19:30 UTC", "05/03/2026 19:30 UTC"},
+		// Unreadable settings fall back to English in UTC.
+		{Settings{"", "Nowhere/Special"}, "7:30 PM UTC", "Mar 5, 2026 7:30 PM UTC"},
+	}
+	for _, tt := range tests {
+		if got := tt.settings.Clock(at); got != tt.clock {
+			t.Errorf("%+v Clock = %q, want %q", tt.settings, got, tt.clock)
+		}
+		if got := tt.settings.DateTime(at); got != tt.dateTime {
+			t.Errorf("%+v DateTime = %q, want %q", tt.settings, got, tt.dateTime)
+		}
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	for in, want := range map[string]string{
+		"en":      "en",
+		"pt-BR":   "pt",
+		"ja":      "ja",
+		"zh-Hant": "zh",
+	} {
+		if got, err := ParseLanguage(in); err != nil || got != want {
+			t.Errorf("ParseLanguage(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "und", "not a language"} {
+		if _, err := ParseLanguage(bad); err == nil {
+			t.Errorf("ParseLanguage(%q) should fail", bad)
+		}
+	}
+}
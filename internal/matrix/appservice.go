@@ -0,0 +1,593 @@
+// Package matrix implements an embedded Matrix application service. When
+// enabled, guilds selected by an instance admin are exposed as Matrix rooms:
+// each channel is reachable at #<alias_prefix><channel_id>:<server_name>,
+// local users post on Matrix as appservice virtual users, and Matrix users
+// post locally as puppet users. It is the in-process alternative to the
+// standalone bridge in bridges/matrix for deployments that do not want to
+// run a separate bridge container.
+package matrix
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/permissions"
+)
+
+// bridgeLocalpart is the virtual user (after the user prefix) that relays
+// messages without a local author, such as webhook messages.
+const bridgeLocalpart = "bridge"
+
+// Config holds the settings of the embedded appservice.
+type Config struct {
+	HomeserverURL string
+	ServerName    string
+	ASToken       string
+	HSToken       string
+	UserPrefix    string
+	AliasPrefix   string
+	InstanceID    string
+}
+
+// Service is the embedded Matrix appservice.
+type Service struct {
+	cfg    Config
+	pool   *pgxpool.Pool
+	bus    *events.Bus
+	client *http.Client
+	logger *slog.Logger
+
+	// Virtual users known to be registered, and room memberships known to
+	// exist, so relaying a message does not repeat the setup calls.
+	mu         sync.Mutex
+	registered map[string]bool
+	joined     map[string]bool
+}
+
+// New creates the embedded Matrix appservice.
+func New(cfg Config, pool *pgxpool.Pool, bus *events.Bus, logger *slog.Logger) *Service {
+	cfg.HomeserverURL = strings.TrimRight(cfg.HomeserverURL, "/")
+	return &Service{
+		cfg:        cfg,
+		pool:       pool,
+		bus:        bus,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+		registered: make(map[string]bool),
+		joined:     make(map[string]bool),
+	}
+}
+
+// Routes returns the appservice API called by the homeserver. Mount it at
+// /_matrix/app/v1.
+func (s *Service) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(s.requireHSToken)
+	r.Put("/transactions/{txnID}", s.handleTransaction)
+	r.Get("/users/{userID}", s.handleUserQuery)
+	r.Get("/rooms/{alias}", s.handleRoomQuery)
+	return r
+}
+
+// Start relays new local messages in bridged channels to Matrix.
+func (s *Service) Start(ctx context.Context) error {
+	_, err := s.bus.QueueSubscribe(events.SubjectMessageCreate, "matrix-appservice", func(event events.Event) {
+		s.relayMessage(ctx, event)
+	})
+	if err != nil {
+		return err
+	}
+	s.logger.Info("matrix appservice started",
+		slog.String("homeserver", s.cfg.HomeserverURL),
+		slog.String("server_name", s.cfg.ServerName))
+	return nil
+}
+
+// requireHSToken rejects requests that do not carry the homeserver token.
+func (s *Service) requireHSToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			// Older homeservers send the token as a query parameter.
+			token = r.URL.Query().Get("access_token")
+		}
+		if token == "" {
+			writeMatrixError(w, http.StatusUnauthorized, "M_UNAUTHORIZED", "Missing homeserver token")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.HSToken)) != 1 {
+			writeMatrixError(w, http.StatusForbidden, "M_FORBIDDEN", "Invalid homeserver token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeMatrixError writes an error in the Matrix API format.
+func writeMatrixError(w http.ResponseWriter, status int, errcode, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"errcode": errcode, "error": message})
+}
+
+// writeEmpty writes the empty JSON object the appservice API expects on success.
+func writeEmpty(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{}`))
+}
+
+// bridgedChannel is a channel exposed to Matrix.
+type bridgedChannel struct {
+	ID          string
+	GuildID     string
+	Name        string
+	Topic       string
+	ChannelType string
+	EveryoneCan uint64 // guild default permissions
+}
+
+// lookupBridgedChannel returns a channel if its guild is bridged and everyone
+// in the guild can read it. Channels with a ViewChannel or ReadHistory deny
+// for any role or user are never exposed.
+func (s *Service) lookupBridgedChannel(ctx context.Context, channelID string) (*bridgedChannel, bool) {
+	const readable = int64(permissions.ViewChannel | permissions.ReadHistory)
+	var c bridgedChannel
+	var defaults int64
+	err := s.pool.QueryRow(ctx,
+		`SELECT c.id, c.guild_id, COALESCE(c.name, ''), COALESCE(c.topic, ''), c.channel_type,
+		        COALESCE(g.default_permissions, 0)
+		 FROM channels c
+		 JOIN matrix_bridged_guilds b ON b.guild_id = c.guild_id
+		 JOIN guilds g ON g.id = c.guild_id
+		 WHERE c.id = $1 AND c.channel_type IN ('text', 'announcement')
+		   AND COALESCE(g.default_permissions, 0) & $2 = $2
+		   AND NOT EXISTS(SELECT 1 FROM channel_permission_overrides o
+		                  WHERE o.channel_id = c.id AND COALESCE(o.permissions_deny, 0) & $2 <> 0)`,
+		channelID, readable,
+	).Scan(&c.ID, &c.GuildID, &c.Name, &c.Topic, &c.ChannelType, &defaults)
+	if err != nil {
+		return nil, false
+	}
+	c.EveryoneCan = uint64(defaults)
+	return &c, true
+}
+
+// handleUserQuery answers whether a virtual user exists, registering it on
+// demand. GET /_matrix/app/v1/users/{userID}
+func (s *Service) handleUserQuery(w http.ResponseWriter, r *http.Request) {
+	mxid := chi.URLParam(r, "userID")
+	userID, ok := s.parseVirtualUserID(mxid)
+	if !ok {
+		writeMatrixError(w, http.StatusNotFound, "M_NOT_FOUND", "Not an AmityVox user")
+		return
+	}
+	if _, err := s.ensureVirtualUser(r.Context(), userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeMatrixError(w, http.StatusNotFound, "M_NOT_FOUND", "No such AmityVox user")
+			return
+		}
+		s.logger.Warn("matrix: failed to provision virtual user",
+			slog.String("mxid", mxid), slog.String("error", err.Error()))
+		writeMatrixError(w, http.StatusInternalServerError, "M_UNKNOWN", "Failed to provision user")
+		return
+	}
+	writeEmpty(w)
+}
+
+// handleRoomQuery creates the room for a bridged channel the first time its
+// alias is looked up. GET /_matrix/app/v1/rooms/{alias}
+func (s *Service) handleRoomQuery(w http.ResponseWriter, r *http.Request) {
+	alias := chi.URLParam(r, "alias")
+	channelID, ok := s.parseRoomAlias(alias)
+	if !ok {
+		writeMatrixError(w, http.StatusNotFound, "M_NOT_FOUND", "Not an AmityVox room")
+		return
+	}
+	ch, ok := s.lookupBridgedChannel(r.Context(), channelID)
+	if !ok {
+		writeMatrixError(w, http.StatusNotFound, "M_NOT_FOUND", "Channel is not bridged to Matrix")
+		return
+	}
+
+	localpart, _, _ := splitMatrixID(alias, '#')
+	roomID, err := s.createRoom(r.Context(), localpart, "#"+ch.Name, ch.Topic)
+	if err != nil {
+		s.logger.Warn("matrix: failed to create room",
+			slog.String("channel_id", ch.ID), slog.String("error", err.Error()))
+		writeMatrixError(w, http.StatusInternalServerError, "M_UNKNOWN", "Failed to create room")
+		return
+	}
+	if _, err := s.pool.Exec(r.Context(),
+		`INSERT INTO matrix_rooms (channel_id, room_id) VALUES ($1, $2)
+		 ON CONFLICT (channel_id) DO UPDATE SET room_id = EXCLUDED.room_id, created_at = now()`,
+		ch.ID, roomID); err != nil {
+		s.logger.Error("matrix: failed to store room mapping",
+			slog.String("channel_id", ch.ID), slog.String("error", err.Error()))
+		writeMatrixError(w, http.StatusInternalServerError, "M_UNKNOWN", "Failed to store room")
+		return
+	}
+
+	s.logger.Info("matrix: created room for channel",
+		slog.String("channel_id", ch.ID), slog.String("room_id", roomID))
+	writeEmpty(w)
+}
+
+// roomEvent is the subset of a Matrix room event the appservice handles.
+type roomEvent struct {
+	Type     string          `json:"type"`
+	RoomID   string          `json:"room_id"`
+	Sender   string          `json:"sender"`
+	EventID  string          `json:"event_id"`
+	StateKey *string         `json:"state_key,omitempty"`
+	Content  json.RawMessage `json:"content"`
+}
+
+// handleTransaction receives events from the homeserver.
+// PUT /_matrix/app/v1/transactions/{txnID}
+func (s *Service) handleTransaction(w http.ResponseWriter, r *http.Request) {
+	var txn struct {
+		Events []roomEvent `json:"events"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 10<<20)).Decode(&txn); err != nil {
+		writeMatrixError(w, http.StatusBadRequest, "M_NOT_JSON", "Invalid transaction body")
+		return
+	}
+
+	// Transactions are retried until acknowledged; message events are
+	// deduplicated by event ID, so replaying one is harmless.
+	for _, ev := range txn.Events {
+		s.processEvent(r.Context(), ev)
+	}
+	writeEmpty(w)
+}
+
+// processEvent applies one Matrix event to the mapped channel.
+func (s *Service) processEvent(ctx context.Context, ev roomEvent) {
+	// Our own virtual users are echoes of local activity.
+	if localpart, _, ok := splitMatrixID(ev.Sender, '@'); !ok || strings.HasPrefix(localpart, s.cfg.UserPrefix) {
+		return
+	}
+
+	var channelID string
+	if err := s.pool.QueryRow(ctx,
+		`SELECT channel_id FROM matrix_rooms WHERE room_id = $1`, ev.RoomID,
+	).Scan(&channelID); err != nil {
+		return
+	}
+	ch, ok := s.lookupBridgedChannel(ctx, channelID)
+	if !ok {
+		return
+	}
+
+	switch ev.Type {
+	case "m.room.message":
+		s.relayMatrixMessage(ctx, ch, ev)
+	case "m.room.member":
+		if ev.StateKey == nil || *ev.StateKey != ev.Sender {
+			return
+		}
+		var content struct {
+			Membership  string `json:"membership"`
+			DisplayName string `json:"displayname"`
+		}
+		if json.Unmarshal(ev.Content, &content) != nil {
+			return
+		}
+		switch content.Membership {
+		case "join":
+			if _, err := s.EnsurePuppet(ctx, ch.GuildID, ev.Sender, content.DisplayName); err != nil {
+				s.logger.Warn("matrix: failed to provision puppet",
+					slog.String("mxid", ev.Sender), slog.String("error", err.Error()))
+			}
+		case "leave":
+			s.removePuppetMember(ctx, ch.GuildID, ev.Sender)
+		}
+	}
+}
+
+// relayMatrixMessage stores a Matrix message as a message by the sender's
+// puppet and publishes it to local clients.
+func (s *Service) relayMatrixMessage(ctx context.Context, ch *bridgedChannel, ev roomEvent) {
+	var content struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	}
+	if json.Unmarshal(ev.Content, &content) != nil || strings.TrimSpace(content.Body) == "" {
+		return
+	}
+	body := content.Body
+	switch content.MsgType {
+	case "m.text", "m.notice":
+	case "m.emote":
+		body = "_" + body + "_"
+	default:
+		return // media and other message types are not bridged
+	}
+
+	// Matrix users act with the guild's @everyone permissions and cannot
+	// post in announcement channels.
+	if ch.ChannelType != "text" || ch.EveryoneCan&permissions.SendMessages == 0 {
+		return
+	}
+
+	puppetID, err := s.EnsurePuppet(ctx, ch.GuildID, ev.Sender, "")
+	if err != nil {
+		s.logger.Warn("matrix: failed to provision puppet",
+			slog.String("mxid", ev.Sender), slog.String("error", err.Error()))
+		return
+	}
+	var banned bool
+	s.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM guild_bans WHERE guild_id = $1 AND user_id = $2)`,
+		ch.GuildID, puppetID,
+	).Scan(&banned)
+	if banned {
+		return
+	}
+
+	messageID := models.NewULID().String()
+	now := time.Now()
+	tag, err := s.pool.Exec(ctx,
+		`INSERT INTO messages (id, channel_id, author_id, content, bridge_source, bridge_remote_id, bridge_author_name, created_at)
+		 VALUES ($1, $2, $3, $4, 'matrix', $5, $6, $7)
+		 ON CONFLICT DO NOTHING`,
+		messageID, ch.ID, puppetID, body, ev.EventID, ev.Sender, now)
+	if err != nil {
+		s.logger.Warn("matrix: failed to store message",
+			slog.String("event_id", ev.EventID), slog.String("error", err.Error()))
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		return // already relayed
+	}
+	s.pool.Exec(ctx, `UPDATE channels SET last_message_id = $1 WHERE id = $2`, messageID, ch.ID)
+
+	s.bus.PublishChannelEvent(ctx, events.SubjectMessageCreate, "MESSAGE_CREATE", ch.ID, map[string]interface{}{
+		"id":                 messageID,
+		"channel_id":         ch.ID,
+		"guild_id":           ch.GuildID,
+		"author_id":          puppetID,
+		"content":            body,
+		"bridge_source":      "matrix",
+		"bridge_author_name": ev.Sender,
+		"created_at":         now,
+	})
+}
+
+// EnsurePuppet returns the local user that represents a Matrix user,
+// creating it on first contact, and makes it a member of the guild. History
+// imports use it too, so imported and bridged messages share an author.
+func (s *Service) EnsurePuppet(ctx context.Context, guildID, mxid, displayName string) (string, error) {
+	var userID string
+	err := s.pool.QueryRow(ctx,
+		`SELECT user_id FROM matrix_puppets WHERE matrix_user_id = $1`, mxid,
+	).Scan(&userID)
+	switch {
+	case err == nil:
+		if displayName != "" {
+			s.pool.Exec(ctx, `UPDATE users SET display_name = $1 WHERE id = $2`, displayName, userID)
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		if userID, err = s.createPuppet(ctx, mxid, displayName); err != nil {
+			return "", err
+		}
+	default:
+		return "", err
+	}
+
+	tag, err := s.pool.Exec(ctx,
+		`INSERT INTO guild_members (guild_id, user_id, joined_at) VALUES ($1, $2, now())
+		 ON CONFLICT DO NOTHING`, guildID, userID)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() > 0 {
+		s.bus.PublishGuildEvent(ctx, events.SubjectGuildMemberAdd, "GUILD_MEMBER_ADD", guildID, map[string]string{
+			"guild_id": guildID,
+			"user_id":  userID,
+		})
+	}
+	return userID, nil
+}
+
+// createPuppet creates the local user for a Matrix user. Puppets have no
+// password and cannot log in.
+func (s *Service) createPuppet(ctx context.Context, mxid, displayName string) (string, error) {
+	if displayName == "" {
+		displayName, _, _ = splitMatrixID(mxid, '@')
+	}
+	userID := models.NewULID().String()
+	username := puppetUsername(mxid)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO users (id, instance_id, username, display_name, status_presence, created_at)
+		 VALUES ($1, $2, $3, $4, 'offline', now())
+		 ON CONFLICT (username, instance_id) DO NOTHING`,
+		userID, s.cfg.InstanceID, username, displayName)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		// Another Matrix user sanitized to the same name; disambiguate with
+		// the tail of the new user's ID.
+		username = username[:min(len(username), 25)] + "_" + strings.ToLower(userID[len(userID)-6:])
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO users (id, instance_id, username, display_name, status_presence, created_at)
+			 VALUES ($1, $2, $3, $4, 'offline', now())`,
+			userID, s.cfg.InstanceID, username, displayName); err != nil {
+			return "", err
+		}
+	}
+	tag, err = tx.Exec(ctx,
+		`INSERT INTO matrix_puppets (matrix_user_id, user_id) VALUES ($1, $2)
+		 ON CONFLICT (matrix_user_id) DO NOTHING`, mxid, userID)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		// Created concurrently by another transaction.
+		tx.Rollback(ctx)
+		err := s.pool.QueryRow(ctx,
+			`SELECT user_id FROM matrix_puppets WHERE matrix_user_id = $1`, mxid,
+		).Scan(&userID)
+		return userID, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// removePuppetMember removes a Matrix user's puppet from the guild after it
+// left the room.
+func (s *Service) removePuppetMember(ctx context.Context, guildID, mxid string) {
+	var userID string
+	err := s.pool.QueryRow(ctx,
+		`DELETE FROM guild_members
+		 WHERE guild_id = $1 AND user_id = (SELECT user_id FROM matrix_puppets WHERE matrix_user_id = $2)
+		 RETURNING user_id`, guildID, mxid,
+	).Scan(&userID)
+	if err != nil {
+		return
+	}
+	s.bus.PublishGuildEvent(ctx, events.SubjectGuildMemberRemove, "GUILD_MEMBER_REMOVE", guildID, map[string]string{
+		"guild_id": guildID,
+		"user_id":  userID,
+	})
+}
+
+// ensureVirtualUser registers the virtual user of a local user and sets its
+// display name. Returns pgx.ErrNoRows if the user does not exist.
+func (s *Service) ensureVirtualUser(ctx context.Context, userID string) (string, error) {
+	mxid := s.virtualUserID(userID)
+	s.mu.Lock()
+	done := s.registered[mxid]
+	s.mu.Unlock()
+	if done {
+		return mxid, nil
+	}
+
+	var name string
+	if strings.EqualFold(userID, bridgeLocalpart) {
+		name = "AmityVox"
+	} else if err := s.pool.QueryRow(ctx,
+		`SELECT COALESCE(display_name, username) FROM users
+		 WHERE id = $1 AND id NOT IN (SELECT user_id FROM matrix_puppets)`, userID,
+	).Scan(&name); err != nil {
+		return "", err
+	}
+
+	localpart, _, _ := splitMatrixID(mxid, '@')
+	if err := s.registerVirtualUser(ctx, localpart); err != nil {
+		return "", err
+	}
+	if err := s.setDisplayName(ctx, mxid, name); err != nil {
+		s.logger.Debug("matrix: failed to set display name",
+			slog.String("mxid", mxid), slog.String("error", err.Error()))
+	}
+
+	s.mu.Lock()
+	s.registered[mxid] = true
+	s.mu.Unlock()
+	return mxid, nil
+}
+
+// ensureJoined joins a virtual user to a room once.
+func (s *Service) ensureJoined(ctx context.Context, roomID, mxid string) error {
+	key := roomID + "|" + mxid
+	s.mu.Lock()
+	done := s.joined[key]
+	s.mu.Unlock()
+	if done {
+		return nil
+	}
+	if err := s.joinRoom(ctx, roomID, mxid); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.joined[key] = true
+	s.mu.Unlock()
+	return nil
+}
+
+// relayMessage sends a local MESSAGE_CREATE in a bridged channel to its room.
+func (s *Service) relayMessage(ctx context.Context, event events.Event) {
+	var msg struct {
+		ID           string `json:"id"`
+		ChannelID    string `json:"channel_id"`
+		AuthorID     string `json:"author_id"`
+		Content      string `json:"content"`
+		BridgeSource string `json:"bridge_source"`
+		DisplayName  string `json:"display_name"` // webhook messages
+	}
+	if json.Unmarshal(event.Data, &msg) != nil || msg.ID == "" || msg.BridgeSource == "matrix" {
+		return
+	}
+	if msg.ChannelID == "" {
+		msg.ChannelID = event.ChannelID
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return
+	}
+
+	var roomID string
+	if err := s.pool.QueryRow(ctx,
+		`SELECT room_id FROM matrix_rooms WHERE channel_id = $1`, msg.ChannelID,
+	).Scan(&roomID); err != nil {
+		return
+	}
+	if _, ok := s.lookupBridgedChannel(ctx, msg.ChannelID); !ok {
+		return
+	}
+
+	sender, body := msg.AuthorID, msg.Content
+	if sender == "" {
+		sender = bridgeLocalpart
+		if msg.DisplayName != "" {
+			body = msg.DisplayName + ": " + body
+		}
+	}
+	mxid, err := s.ensureVirtualUser(ctx, sender)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) { // puppets are never relayed back
+			s.logger.Warn("matrix: failed to provision virtual user",
+				slog.String("user_id", sender), slog.String("error", err.Error()))
+		}
+		return
+	}
+	if err := s.ensureJoined(ctx, roomID, mxid); err != nil {
+		s.logger.Warn("matrix: failed to join room",
+			slog.String("room_id", roomID), slog.String("mxid", mxid), slog.String("error", err.Error()))
+		return
+	}
+	if err := s.sendMessage(ctx, roomID, mxid, "amityvox_"+msg.ID, body); err != nil {
+		if hasErrCode(err, "M_FORBIDDEN") {
+			// Kicked or never joined after all; rejoin on the next message.
+			s.mu.Lock()
+			delete(s.joined, roomID+"|"+mxid)
+			s.mu.Unlock()
+		}
+		s.logger.Warn("matrix: failed to relay message",
+			slog.String("message_id", msg.ID), slog.String("room_id", roomID), slog.String("error", err.Error()))
+	}
+}
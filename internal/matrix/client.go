@@ -0,0 +1,141 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// matrixError is the error body returned by the client-server API.
+type matrixError struct {
+	ErrCode string `json:"errcode"`
+	Error   string `json:"error"`
+}
+
+// do sends an appservice-authenticated request to the homeserver. If asUser
+// is set the request is made on behalf of that virtual user. A non-2xx
+// response is returned as an error carrying the Matrix errcode.
+func (s *Service) do(ctx context.Context, method, path, asUser string, body, out interface{}) error {
+	u, err := url.Parse(s.cfg.HomeserverURL + path)
+	if err != nil {
+		return fmt.Errorf("building homeserver URL: %w", err)
+	}
+	if asUser != "" {
+		q := u.Query()
+		q.Set("user_id", asUser)
+		u.RawQuery = q.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshaling request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.cfg.ASToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var me matrixError
+		json.Unmarshal(respBody, &me)
+		return &homeserverError{Status: resp.StatusCode, ErrCode: me.ErrCode, Message: me.Error}
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("decoding %s response: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// homeserverError is a non-2xx response from the homeserver.
+type homeserverError struct {
+	Status  int
+	ErrCode string
+	Message string
+}
+
+func (e *homeserverError) Error() string {
+	return fmt.Sprintf("homeserver returned %d %s: %s", e.Status, e.ErrCode, e.Message)
+}
+
+// hasErrCode reports whether err is a homeserver error with the given errcode.
+func hasErrCode(err error, code string) bool {
+	he, ok := err.(*homeserverError)
+	return ok && he.ErrCode == code
+}
+
+// registerVirtualUser registers a virtual user in the appservice namespace.
+// Registering an existing user is not an error.
+func (s *Service) registerVirtualUser(ctx context.Context, localpart string) error {
+	err := s.do(ctx, http.MethodPost, "/_matrix/client/v3/register", "", map[string]string{
+		"type":     "m.login.application_service",
+		"username": localpart,
+	}, nil)
+	if err != nil && !hasErrCode(err, "M_USER_IN_USE") {
+		return err
+	}
+	return nil
+}
+
+// setDisplayName sets a virtual user's Matrix display name.
+func (s *Service) setDisplayName(ctx context.Context, mxid, name string) error {
+	return s.do(ctx, http.MethodPut,
+		"/_matrix/client/v3/profile/"+url.PathEscape(mxid)+"/displayname", mxid,
+		map[string]string{"displayname": name}, nil)
+}
+
+// createRoom creates a public room with the given alias localpart, owned by
+// the appservice bot, and returns its room ID.
+func (s *Service) createRoom(ctx context.Context, aliasLocalpart, name, topic string) (string, error) {
+	req := map[string]interface{}{
+		"room_alias_name": aliasLocalpart,
+		"name":            name,
+		"preset":          "public_chat",
+		"visibility":      "private", // reachable by alias, not listed in the directory
+	}
+	if topic != "" {
+		req["topic"] = topic
+	}
+	var resp struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/_matrix/client/v3/createRoom", "", req, &resp); err != nil {
+		return "", err
+	}
+	return resp.RoomID, nil
+}
+
+// joinRoom joins a virtual user to a room.
+func (s *Service) joinRoom(ctx context.Context, roomID, mxid string) error {
+	return s.do(ctx, http.MethodPost, "/_matrix/client/v3/join/"+url.PathEscape(roomID), mxid,
+		map[string]interface{}{}, nil)
+}
+
+// sendMessage sends an m.text message to a room as asUser (or the appservice
+// bot if empty). txnID makes retries idempotent on the homeserver.
+func (s *Service) sendMessage(ctx context.Context, roomID, asUser, txnID, body string) error {
+	return s.do(ctx, http.MethodPut,
+		"/_matrix/client/v3/rooms/"+url.PathEscape(roomID)+"/send/m.room.message/"+url.PathEscape(txnID), asUser,
+		map[string]string{"msgtype": "m.text", "body": body}, nil)
+}
@@ -0,0 +1,84 @@
+package matrix
+
+import (
+	"strings"
+)
+
+// Matrix localparts are lowercase, while AmityVox IDs are upper-case ULIDs.
+// IDs are lowercased on the way out and uppercased on the way back in.
+
+// virtualUserID returns the Matrix ID of the virtual user that represents a
+// local user on the homeserver.
+func (s *Service) virtualUserID(userID string) string {
+	return "@" + s.cfg.UserPrefix + strings.ToLower(userID) + ":" + s.cfg.ServerName
+}
+
+// parseVirtualUserID returns the local user ID behind a virtual user's
+// Matrix ID, or false if mxid is not in the appservice's user namespace.
+func (s *Service) parseVirtualUserID(mxid string) (string, bool) {
+	localpart, server, ok := splitMatrixID(mxid, '@')
+	if !ok || server != s.cfg.ServerName || !strings.HasPrefix(localpart, s.cfg.UserPrefix) {
+		return "", false
+	}
+	id := strings.TrimPrefix(localpart, s.cfg.UserPrefix)
+	if id == "" {
+		return "", false
+	}
+	return strings.ToUpper(id), true
+}
+
+// roomAlias returns the alias under which a channel is reachable on Matrix.
+func (s *Service) roomAlias(channelID string) string {
+	return "#" + s.cfg.AliasPrefix + strings.ToLower(channelID) + ":" + s.cfg.ServerName
+}
+
+// parseRoomAlias returns the channel ID behind a room alias, or false if the
+// alias is not in the appservice's alias namespace.
+func (s *Service) parseRoomAlias(alias string) (string, bool) {
+	localpart, server, ok := splitMatrixID(alias, '#')
+	if !ok || server != s.cfg.ServerName || !strings.HasPrefix(localpart, s.cfg.AliasPrefix) {
+		return "", false
+	}
+	id := strings.TrimPrefix(localpart, s.cfg.AliasPrefix)
+	if id == "" {
+		return "", false
+	}
+	return strings.ToUpper(id), true
+}
+
+// splitMatrixID splits a sigil-prefixed Matrix identifier such as
+// @alice:example.org into its localpart and server name.
+func splitMatrixID(id string, sigil byte) (localpart, server string, ok bool) {
+	if len(id) < 2 || id[0] != sigil {
+		return "", "", false
+	}
+	localpart, server, ok = strings.Cut(id[1:], ":")
+	if !ok || localpart == "" || server == "" {
+		return "", "", false
+	}
+	return localpart, server, true
+}
+
+// puppetUsername derives a local username for the puppet of a Matrix user.
+// Usernames allow letters, digits, underscores, hyphens and dots and are at
+// most 32 characters; anything else in the localpart becomes an underscore.
+func puppetUsername(mxid string) string {
+	localpart, _, ok := splitMatrixID(mxid, '@')
+	if !ok {
+		localpart = "user"
+	}
+	var b strings.Builder
+	b.WriteString("mx_")
+	for _, r := range localpart {
+		if b.Len() >= 32 {
+			break
+		}
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
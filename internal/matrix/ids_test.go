@@ -0,0 +1,93 @@
+package matrix
+
+import "testing"
+
+func testService() *Service {
+	return &Service{cfg: Config{ServerName: "example.com", UserPrefix: "amityvox_", AliasPrefix: "amityvox_"}}
+}
+
+func TestVirtualUserID_RoundTrip(t *testing.T) {
+	s := testService()
+	const userID = "01HQZX3V5K8M2N4P6R7S9T0W1Y"
+
+	mxid := s.virtualUserID(userID)
+	if mxid != "@amityvox_01hqzx3v5k8m2n4p6r7s9t0w1y:example.com" {
+		t.Fatalf("virtualUserID = %q", mxid)
+	}
+	got, ok := s.parseVirtualUserID(mxid)
+	if !ok || got != userID {
+		t.Errorf("parseVirtualUserID(%q) = %q, %v; want %q", mxid, got, ok, userID)
+	}
+}
+
+func TestParseVirtualUserID_Rejects(t *testing.T) {
+	s := testService()
+	for _, mxid := range []string{
+		"@alice:example.com",        // outside the namespace
+		"@amityvox_abc:other.org",   // other server
+		"@amityvox_:example.com",    // empty ID
+		"#amityvox_abc:example.com", // wrong sigil
+		"amityvox_abc:example.com",  // no sigil
+	} {
+		if _, ok := s.parseVirtualUserID(mxid); ok {
+			t.Errorf("parseVirtualUserID(%q) accepted", mxid)
+		}
+	}
+}
+
+func TestRoomAlias_RoundTrip(t *testing.T) {
+	s := testService()
+	const channelID = "01HQZX3V5K8M2N4P6R7S9T0W1Y"
+
+	alias := s.roomAlias(channelID)
+	if alias != "#amityvox_01hqzx3v5k8m2n4p6r7s9t0w1y:example.com" {
+		t.Fatalf("roomAlias = %q", alias)
+	}
+	got, ok := s.parseRoomAlias(alias)
+	if !ok || got != channelID {
+		t.Errorf("parseRoomAlias(%q) = %q, %v; want %q", alias, got, ok, channelID)
+	}
+	if _, ok := s.parseRoomAlias("#general:example.com"); ok {
+		t.Error("parseRoomAlias accepted an alias outside the namespace")
+	}
+}
+
+func TestSplitMatrixID(t *testing.T) {
+	tests := []struct {
+		id        string
+		localpart string
+		server    string
+		ok        bool
+	}{
+		{"@alice:example.com", "alice", "example.com", true},
+		{"@alice:example.com:8448", "alice", "example.com:8448", true},
+		{"@alice", "", "", false},
+		{"@:example.com", "", "", false},
+		{"alice:example.com", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tc := range tests {
+		localpart, server, ok := splitMatrixID(tc.id, '@')
+		if localpart != tc.localpart || server != tc.server || ok != tc.ok {
+			t.Errorf("splitMatrixID(%q) = %q, %q, %v; want %q, %q, %v",
+				tc.id, localpart, server, ok, tc.localpart, tc.server, tc.ok)
+		}
+	}
+}
+
+func TestPuppetUsername(t *testing.T) {
+	tests := []struct {
+		mxid string
+		want string
+	}{
+		{"@alice:matrix.org", "mx_alice"},
+		{"@bob.smith-2:matrix.org", "mx_bob.smith-2"},
+		{"@weird=name/x:matrix.org", "mx_weird_name_x"},
+		{"@abcdefghijklmnopqrstuvwxyz0123456789:matrix.org", "mx_abcdefghijklmnopqrstuvwxyz012"},
+	}
+	for _, tc := range tests {
+		if got := puppetUsername(tc.mxid); got != tc.want {
+			t.Errorf("puppetUsername(%q) = %q, want %q", tc.mxid, got, tc.want)
+		}
+	}
+}
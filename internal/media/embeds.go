@@ -0,0 +1,311 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/minio/minio-go/v7"
+
+	"github.com/amityvox/amityvox/internal/callback"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Errors returned by CacheEmbedImage for images that are not cached.
+var (
+	ErrEmbedImageTooLarge    = errors.New("embed image exceeds size limit")
+	ErrEmbedImageUnsupported = errors.New("embed image type not supported")
+)
+
+// embedImageTypes are the sniffed content types cached for link previews.
+// SVG is excluded because it can carry script.
+var embedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// EmbedImage is a link preview image re-hosted in storage.
+type EmbedImage struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// EmbedImageURL returns the path that serves a cached embed image.
+func EmbedImageURL(id string) string {
+	return "/api/v1/embeds/images/" + id
+}
+
+// CachesEmbedImages reports whether link preview images should be re-hosted.
+func (s *Service) CachesEmbedImages() bool {
+	return s.embedImages
+}
+
+// CacheEmbedImage downloads the image at sourceURL and stores it, or reuses
+// an unexpired copy of it, extending its expiry. Images that cannot be
+// fetched, exceed the size limit or are not a supported raster type return
+// an error, and the caller should drop them from the embed.
+func (s *Service) CacheEmbedImage(ctx context.Context, sourceURL string) (*EmbedImage, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid embed image URL %q", sourceURL)
+	}
+
+	var id string
+	var width, height *int
+	err = s.pool.QueryRow(ctx,
+		`UPDATE embed_images SET expires_at = now() + $2 * interval '1 second'
+		 WHERE source_url = $1 AND expires_at > now()
+		 RETURNING id, width, height`,
+		sourceURL, int64(s.embedImageTTL.Seconds()),
+	).Scan(&id, &width, &height)
+	if err == nil {
+		return embedImage(id, width, height), nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("looking up cached embed image: %w", err)
+	}
+
+	data, err := s.fetchEmbedImage(ctx, sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	contentType := http.DetectContentType(data)
+	if !embedImageTypes[contentType] {
+		return nil, ErrEmbedImageUnsupported
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		width, height = &cfg.Width, &cfg.Height
+	}
+
+	// An expired copy that cleanup has not reached yet would block the
+	// insert below; remove it now.
+	s.removeEmbedImages(ctx,
+		`DELETE FROM embed_images WHERE source_url = $1 AND expires_at <= now()
+		 RETURNING s3_bucket, s3_key`, sourceURL)
+
+	id = models.NewULID().String()
+	s3Key := fmt.Sprintf("embeds/%s/%s", time.Now().UTC().Format("2006/01/02"), id)
+	st, err := s.putObject(ctx, s3Key, data, minio.PutObjectOptions{
+		ContentType:  contentType,
+		UserMetadata: map[string]string{"source-url": sourceURL},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("uploading %s: %w", s3Key, err)
+	}
+
+	tag, err := s.pool.Exec(ctx,
+		`INSERT INTO embed_images (id, source_url, content_type, size_bytes, width, height, s3_bucket, s3_key, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now() + $9 * interval '1 second')
+		 ON CONFLICT (source_url) DO NOTHING`,
+		id, sourceURL, contentType, len(data), width, height, st.bucket, s3Key, int64(s.embedImageTTL.Seconds()))
+	if err == nil && tag.RowsAffected() == 1 {
+		return embedImage(id, width, height), nil
+	}
+
+	// Either recording failed or a concurrent unfurl cached the same image
+	// first; drop this copy and use theirs.
+	_ = st.client.RemoveObject(ctx, st.bucket, s3Key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("recording embed image: %w", err)
+	}
+	err = s.pool.QueryRow(ctx,
+		`SELECT id, width, height FROM embed_images WHERE source_url = $1`, sourceURL,
+	).Scan(&id, &width, &height)
+	if err != nil {
+		return nil, fmt.Errorf("looking up cached embed image: %w", err)
+	}
+	return embedImage(id, width, height), nil
+}
+
+func embedImage(id string, width, height *int) *EmbedImage {
+	img := &EmbedImage{ID: id, URL: EmbedImageURL(id)}
+	if width != nil && height != nil {
+		img.Width, img.Height = *width, *height
+	}
+	return img
+}
+
+// fetchEmbedImage downloads an embed image with the SSRF-safe client,
+// reading at most the configured limit.
+func (s *Service) fetchEmbedImage(ctx context.Context, sourceURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", "AmityVox/0.2.0 (Embed Unfurler)")
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := callback.SafeClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", sourceURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: status %d", sourceURL, resp.StatusCode)
+	}
+	if resp.ContentLength > s.embedImageMax {
+		return nil, ErrEmbedImageTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, s.embedImageMax+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", sourceURL, err)
+	}
+	if int64(len(data)) > s.embedImageMax {
+		return nil, ErrEmbedImageTooLarge
+	}
+	return data, nil
+}
+
+// CleanExpiredEmbedImages deletes up to 500 cached embed images past their
+// expiry and returns how many were removed.
+func (s *Service) CleanExpiredEmbedImages(ctx context.Context) (int, error) {
+	return s.removeEmbedImages(ctx,
+		`DELETE FROM embed_images WHERE id IN (
+			SELECT id FROM embed_images WHERE expires_at <= now() LIMIT 500
+		 ) RETURNING s3_bucket, s3_key`)
+}
+
+// removeEmbedImages runs a DELETE returning s3_bucket and s3_key and removes
+// the returned objects. Object removal is best-effort: an object left behind
+// is unreachable once its row is gone.
+func (s *Service) removeEmbedImages(ctx context.Context, query string, args ...any) (int, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("deleting embed images: %w", err)
+	}
+	type object struct{ bucket, key string }
+	var objects []object
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.bucket, &o.key); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning embed image: %w", err)
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("deleting embed images: %w", err)
+	}
+
+	for _, o := range objects {
+		st := s.storeForBucket(o.bucket)
+		if err := st.client.RemoveObject(ctx, st.bucket, o.key, minio.RemoveObjectOptions{}); err != nil {
+			s.logger.Warn("failed to remove embed image object",
+				slog.String("key", o.key), slog.String("error", err.Error()))
+		}
+	}
+	return len(objects), nil
+}
+
+// HandleGetEmbedImage serves a cached link preview image.
+// GET /api/v1/embeds/images/{imageID}
+func (s *Service) HandleGetEmbedImage(w http.ResponseWriter, r *http.Request) {
+	imageID := chi.URLParam(r, "imageID")
+
+	var contentType, bucket, s3Key string
+	err := s.pool.QueryRow(r.Context(),
+		`SELECT content_type, s3_bucket, s3_key FROM embed_images
+		 WHERE id = $1 AND expires_at > now()`, imageID,
+	).Scan(&contentType, &bucket, &s3Key)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "image_not_found", "Embed image not found")
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s"`, imageID)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	st := s.storeForBucket(bucket)
+	obj, err := st.client.GetObject(r.Context(), st.bucket, s3Key, minio.GetObjectOptions{})
+	if err != nil {
+		s.logger.Error("failed to get embed image from S3",
+			slog.String("error", err.Error()),
+			slog.String("key", s3Key),
+		)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to retrieve image")
+		return
+	}
+	defer obj.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	io.Copy(w, obj)
+}
+
+// HandleGetEmoji serves a guild or instance emoji's image. Emoji images
+// never change, so a request carrying the image hash the emoji payload links
+// to may be cached for good.
+// GET /api/v1/emoji/{emojiID}[?v=hash]
+func (s *Service) HandleGetEmoji(w http.ResponseWriter, r *http.Request) {
+	emojiID := chi.URLParam(r, "emojiID")
+
+	var contentType, bucket, s3Key, imageHash string
+	err := s.pool.QueryRow(r.Context(),
+		`SELECT COALESCE(a.content_type, CASE WHEN e.animated THEN 'image/gif' ELSE 'image/png' END),
+		        COALESCE(a.s3_bucket, ''), e.s3_key, COALESCE(e.image_hash, '')
+		 FROM custom_emoji e
+		 LEFT JOIN attachments a ON a.s3_key = e.s3_key
+		 WHERE e.id = $1
+		 UNION ALL
+		 SELECT a.content_type, a.s3_bucket, a.s3_key, COALESCE(a.sha256, '')
+		 FROM instance_emoji ie
+		 JOIN attachments a ON a.id = ie.file_id
+		 WHERE ie.id = $1
+		 LIMIT 1`, emojiID,
+	).Scan(&contentType, &bucket, &s3Key, &imageHash)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "emoji_not_found", "Emoji not found")
+		return
+	}
+
+	cacheControl := "public, max-age=86400"
+	if v := r.URL.Query().Get("v"); v != "" && imageHash != "" && strings.HasPrefix(imageHash, v) {
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+	etag := fmt.Sprintf(`"%s"`, emojiID)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	st := s.storeForBucket(bucket)
+	obj, err := st.client.GetObject(r.Context(), st.bucket, s3Key, minio.GetObjectOptions{})
+	if err != nil {
+		s.logger.Error("failed to get emoji from S3",
+			slog.String("error", err.Error()),
+			slog.String("key", s3Key),
+		)
+		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to retrieve emoji")
+		return
+	}
+	defer obj.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	io.Copy(w, obj)
+}
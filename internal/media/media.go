@@ -7,7 +7,10 @@ package media
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
@@ -26,11 +29,13 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 
+	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/models"
 	"github.com/amityvox/amityvox/internal/permissions"
+	"github.com/amityvox/amityvox/internal/regions"
+	"github.com/amityvox/amityvox/internal/spoilers"
 )
 
 // Config holds the configuration for the media storage service.
@@ -46,36 +51,53 @@ type Config struct {
 	StripExif      bool
 	Pool           *pgxpool.Pool
 	Logger         *slog.Logger
+
+	// Regions are storage endpoints in other deployment regions; the
+	// endpoint above serves PrimaryRegion. Uploads go to the region picked
+	// by Strategy for the client named in RegionHeader.
+	Regions       []RegionConfig
+	PrimaryRegion string
+	LocalRegion   string
+	Strategy      string
+	RegionHeader  string
+
+	// EmbedImageCache re-hosts link preview images of up to
+	// EmbedImageMaxBytes for EmbedImageTTL after they were last unfurled.
+	EmbedImageCache    bool
+	EmbedImageMaxBytes int64
+	EmbedImageTTL      time.Duration
 }
 
 // Service provides file upload, image processing, and S3 storage operations.
 type Service struct {
-	client         *minio.Client
-	bucket         string
+	stores         []store // primary region first; see regions.go
+	regions        *regions.Set
+	regionHeader   string
 	maxUpload      int64 // bytes
 	thumbnailSizes []int
 	stripExif      bool
+	embedImages    bool
+	embedImageMax  int64 // bytes
+	embedImageTTL  time.Duration
 	pool           *pgxpool.Pool
 	logger         *slog.Logger
 }
 
 // New creates a new media service connected to S3-compatible storage.
 func New(cfg Config) (*Service, error) {
-	// minio.New expects host:port without scheme; strip http:// or https:// if present.
-	endpoint := cfg.Endpoint
-	if strings.HasPrefix(endpoint, "http://") {
-		endpoint = strings.TrimPrefix(endpoint, "http://")
-	} else if strings.HasPrefix(endpoint, "https://") {
-		endpoint = strings.TrimPrefix(endpoint, "https://")
-		cfg.UseSSL = true
-	}
-	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
-		Secure: cfg.UseSSL,
-		Region: cfg.Region,
-	})
+	client, err := newS3Client(cfg.Endpoint, cfg.AccessKey, cfg.SecretKey, cfg.Region, cfg.UseSSL)
 	if err != nil {
-		return nil, fmt.Errorf("creating S3 client: %w", err)
+		return nil, err
+	}
+	stores := []store{{client: client, bucket: cfg.Bucket}}
+	names := []string{cfg.PrimaryRegion}
+	for _, rc := range cfg.Regions {
+		client, err := newS3Client(rc.Endpoint, rc.AccessKey, rc.SecretKey, rc.Region, rc.UseSSL)
+		if err != nil {
+			return nil, fmt.Errorf("storage region %s: %w", rc.Name, err)
+		}
+		stores = append(stores, store{client: client, bucket: rc.Bucket})
+		names = append(names, rc.Name)
 	}
 
 	maxBytes := cfg.MaxUploadMB * 1024 * 1024
@@ -88,28 +110,43 @@ func New(cfg Config) (*Service, error) {
 		thumbSizes = []int{128, 256, 512}
 	}
 
+	embedMax := cfg.EmbedImageMaxBytes
+	if embedMax <= 0 {
+		embedMax = 8 * 1024 * 1024 // default 8MB
+	}
+	embedTTL := cfg.EmbedImageTTL
+	if embedTTL <= 0 {
+		embedTTL = 30 * 24 * time.Hour
+	}
+
 	return &Service{
-		client:         client,
-		bucket:         cfg.Bucket,
+		stores:         stores,
+		regions:        regions.NewSet(names, cfg.Strategy, cfg.LocalRegion),
+		regionHeader:   cfg.RegionHeader,
 		maxUpload:      maxBytes,
 		thumbnailSizes: thumbSizes,
 		stripExif:      cfg.StripExif,
+		embedImages:    cfg.EmbedImageCache,
+		embedImageMax:  embedMax,
+		embedImageTTL:  embedTTL,
 		pool:           cfg.Pool,
 		logger:         cfg.Logger,
 	}, nil
 }
 
-// EnsureBucket creates the storage bucket if it doesn't exist.
+// EnsureBucket creates the storage bucket of every region if it doesn't exist.
 func (s *Service) EnsureBucket(ctx context.Context) error {
-	exists, err := s.client.BucketExists(ctx, s.bucket)
-	if err != nil {
-		return fmt.Errorf("checking bucket existence: %w", err)
-	}
-	if !exists {
-		if err := s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{}); err != nil {
-			return fmt.Errorf("creating bucket %q: %w", s.bucket, err)
+	for _, st := range s.stores {
+		exists, err := st.client.BucketExists(ctx, st.bucket)
+		if err != nil {
+			return fmt.Errorf("checking bucket existence: %w", err)
+		}
+		if !exists {
+			if err := st.client.MakeBucket(ctx, st.bucket, minio.MakeBucketOptions{}); err != nil {
+				return fmt.Errorf("creating bucket %q: %w", st.bucket, err)
+			}
+			s.logger.Info("created S3 bucket", slog.String("bucket", st.bucket))
 		}
-		s.logger.Info("created S3 bucket", slog.String("bucket", s.bucket))
 	}
 	return nil
 }
@@ -150,11 +187,41 @@ func (s *Service) HandleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx := withClientRegion(r.Context(), regions.ClientRegion(r, s.regionHeader))
+	attachment, err := s.StoreAttachment(ctx, userID, header.Filename, header.Header.Get("Content-Type"), altText, fileData)
+	if errors.Is(err, errRecordAttachment) {
+		writeError(w, http.StatusInternalServerError, "internal_error", "File uploaded but metadata save failed")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "upload_failed", "Failed to upload file to storage")
+		return
+	}
+
+	// The uploader can also mark a file as a spoiler without renaming it.
+	if !attachment.Spoiler && r.FormValue("spoiler") == "true" {
+		if _, err := s.pool.Exec(r.Context(),
+			`UPDATE attachments SET spoiler = true WHERE id = $1`, attachment.ID); err == nil {
+			attachment.Spoiler = true
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, attachment)
+}
+
+// errRecordAttachment reports that an attachment reached storage but its
+// database row could not be written.
+var errRecordAttachment = errors.New("recording attachment")
+
+// StoreAttachment uploads data as a new, unlinked attachment owned by
+// uploaderID and records it in the database. declaredType is the sender's
+// claimed content type; it is only trusted for non-scriptable media types.
+func (s *Service) StoreAttachment(ctx context.Context, uploaderID, filename, declaredType, altText string, data []byte) (*models.Attachment, error) {
 	// Determine content type by sniffing the first 512 bytes (authoritative).
-	contentType := http.DetectContentType(fileData)
+	contentType := http.DetectContentType(data)
 
 	// Only allow user-provided content type for safe, non-scriptable media types.
-	if ct := header.Header.Get("Content-Type"); ct != "" && ct != "application/octet-stream" {
+	if ct := declaredType; ct != "" && ct != "application/octet-stream" {
 		// Allow user type only for image/audio/video subtypes, never text/html, application/*, svg, etc.
 		if strings.HasPrefix(ct, "image/") && ct != "image/svg+xml" {
 			contentType = ct
@@ -163,38 +230,44 @@ func (s *Service) HandleUpload(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Hash the bytes as uploaded, before any re-encoding, so they can be
+	// matched against hashes of known files.
+	sum := sha256.Sum256(data)
+	fileHash := hex.EncodeToString(sum[:])
+
 	// Generate attachment ID and S3 key.
 	attachmentID := models.NewULID().String()
-	ext := path.Ext(header.Filename)
+	ext := path.Ext(filename)
 	datePath := time.Now().UTC().Format("2006/01/02")
 	s3Key := fmt.Sprintf("attachments/%s/%s%s", datePath, attachmentID, ext)
 
 	isImage := strings.HasPrefix(contentType, "image/")
+	spoiler := spoilers.IsSpoilerFilename(filename)
 
 	// Strip EXIF metadata from images by re-encoding.
 	var width, height *int
-	var bhash *string
-	uploadData := fileData
+	var bhash, thash *string
+	uploadData := data
 
 	if isImage {
-		result := s.processImage(fileData, contentType)
+		result := s.processImage(data, contentType)
 		width = result.width
 		height = result.height
 		bhash = result.blurhash
+		thash = result.thumbhash
 		if result.stripped != nil {
 			uploadData = result.stripped
 		}
 	}
 
-	// Upload to S3.
+	// Upload to S3 in the region nearest the uploader.
 	uploadSize := int64(len(uploadData))
-	_, err = s.client.PutObject(r.Context(), s.bucket, s3Key,
-		bytes.NewReader(uploadData), uploadSize,
+	st, err := s.putObject(ctx, s3Key, uploadData,
 		minio.PutObjectOptions{
 			ContentType: contentType,
 			UserMetadata: map[string]string{
-				"uploader-id":   userID,
-				"original-name": header.Filename,
+				"uploader-id":   uploaderID,
+				"original-name": filename,
 				"attachment-id": attachmentID,
 			},
 		})
@@ -203,8 +276,7 @@ func (s *Service) HandleUpload(w http.ResponseWriter, r *http.Request) {
 			slog.String("error", err.Error()),
 			slog.String("key", s3Key),
 		)
-		writeError(w, http.StatusInternalServerError, "upload_failed", "Failed to upload file to storage")
-		return
+		return nil, fmt.Errorf("uploading %s: %w", s3Key, err)
 	}
 
 	// Record in database.
@@ -213,53 +285,53 @@ func (s *Service) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	if altText != "" {
 		altTextPtr = &altText
 	}
-	_, err = s.pool.Exec(r.Context(),
-		`INSERT INTO attachments (id, uploader_id, filename, content_type, size_bytes, width, height, blurhash, s3_bucket, s3_key, alt_text, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
-		attachmentID, userID, header.Filename, contentType, uploadSize,
-		width, height, bhash, s.bucket, s3Key, altTextPtr, now,
+	_, err = s.pool.Exec(ctx,
+		`INSERT INTO attachments (id, uploader_id, filename, content_type, size_bytes, width, height, blurhash, thumbhash, s3_bucket, s3_key, alt_text, sha256, spoiler, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		attachmentID, uploaderID, filename, contentType, uploadSize,
+		width, height, bhash, thash, st.bucket, s3Key, altTextPtr, fileHash, spoiler, now,
 	)
 	if err != nil {
 		s.logger.Error("failed to record file in database",
 			slog.String("error", err.Error()),
 			slog.String("id", attachmentID),
 		)
-		writeError(w, http.StatusInternalServerError, "internal_error", "File uploaded but metadata save failed")
-		return
-	}
-
-	// Generate thumbnails asynchronously (non-blocking).
-	if isImage && width != nil {
-		go s.generateThumbnails(context.Background(), fileData, attachmentID, datePath)
+		return nil, fmt.Errorf("%w: %v", errRecordAttachment, err)
 	}
 
+	// Resized variants are rendered by the image-variants worker; see
+	// GenerateImageVariants.
 	attachment := models.Attachment{
 		ID:          attachmentID,
-		UploaderID:  &userID,
-		Filename:    header.Filename,
+		UploaderID:  &uploaderID,
+		Filename:    filename,
 		ContentType: contentType,
 		SizeBytes:   uploadSize,
 		Width:       width,
 		Height:      height,
 		Blurhash:    bhash,
-		S3Bucket:    s.bucket,
+		Thumbhash:   thash,
+		S3Bucket:    st.bucket,
 		S3Key:       s3Key,
+		SHA256:      fileHash,
 		AltText:     altTextPtr,
+		Spoiler:     spoiler,
 		CreatedAt:   now,
 	}
 
-	writeJSON(w, http.StatusCreated, attachment)
+	return &attachment, nil
 }
 
 // imageResult holds the output of image processing.
 type imageResult struct {
-	width    *int
-	height   *int
-	blurhash *string
-	stripped []byte // EXIF-stripped re-encoded image (nil if unchanged)
+	width     *int
+	height    *int
+	blurhash  *string
+	thumbhash *string
+	stripped  []byte // EXIF-stripped re-encoded image (nil if unchanged)
 }
 
-// processImage decodes an image, computes dimensions and blurhash, and optionally
+// processImage decodes an image, computes dimensions, blurhash and thumbhash, and optionally
 // strips EXIF metadata by re-encoding. This is done synchronously during upload
 // since it only requires decoding the image once.
 func (s *Service) processImage(data []byte, contentType string) imageResult {
@@ -284,6 +356,9 @@ func (s *Service) processImage(data []byte, contentType string) imageResult {
 	if bhash != "" {
 		result.blurhash = &bhash
 	}
+	if thash := ComputeThumbhash(img); thash != "" {
+		result.thumbhash = &thash
+	}
 
 	// Strip EXIF by re-encoding (only pixel data is preserved).
 	if s.stripExif {
@@ -356,87 +431,28 @@ func stripExifData(img image.Image, contentType string) []byte {
 	return buf.Bytes()
 }
 
-// generateThumbnails creates resized versions of an image at configured sizes
-// and uploads them to S3. Runs in a background goroutine.
-func (s *Service) generateThumbnails(ctx context.Context, data []byte, attachmentID, datePath string) {
-	img, _, err := image.Decode(bytes.NewReader(data))
-	if err != nil {
-		s.logger.Error("failed to decode image for thumbnails", slog.String("error", err.Error()))
-		return
-	}
-
-	bounds := img.Bounds()
-	origW := bounds.Dx()
-
-	for _, size := range s.thumbnailSizes {
-		// Skip thumbnail sizes larger than the original.
-		if size >= origW {
-			continue
-		}
-
-		thumb := resizeWidth(img, size)
-
-		var buf bytes.Buffer
-		if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85}); err != nil {
-			s.logger.Error("failed to encode thumbnail",
-				slog.String("attachment_id", attachmentID),
-				slog.Int("size", size),
-				slog.String("error", err.Error()),
-			)
-			continue
-		}
-
-		thumbKey := fmt.Sprintf("thumbnails/%s/%s_%d.jpg", datePath, attachmentID, size)
-		_, err := s.client.PutObject(ctx, s.bucket, thumbKey,
-			bytes.NewReader(buf.Bytes()), int64(buf.Len()),
-			minio.PutObjectOptions{
-				ContentType: "image/jpeg",
-				UserMetadata: map[string]string{
-					"attachment-id":  attachmentID,
-					"thumbnail-size": fmt.Sprintf("%d", size),
-				},
-			})
-		if err != nil {
-			s.logger.Error("failed to upload thumbnail",
-				slog.String("attachment_id", attachmentID),
-				slog.Int("size", size),
-				slog.String("error", err.Error()),
-			)
-			continue
-		}
-
-		s.logger.Debug("thumbnail generated",
-			slog.String("attachment_id", attachmentID),
-			slog.Int("size", size),
-			slog.String("key", thumbKey),
-		)
-	}
-}
-
 // ThumbnailURL returns the S3 key for a specific thumbnail size.
 func ThumbnailURL(attachmentID, datePath string, size int) string {
-	return fmt.Sprintf("thumbnails/%s/%s_%d.jpg", datePath, attachmentID, size)
+	f, _ := lookupVariantFormat("jpeg")
+	return variantKey(attachmentID, datePath, size, f)
 }
 
 // Delete removes a file and its thumbnails from S3 and the database.
 func (s *Service) Delete(ctx context.Context, attachmentID string) error {
-	var s3Key string
+	var bucket, s3Key string
 	err := s.pool.QueryRow(ctx,
-		`SELECT s3_key FROM attachments WHERE id = $1`, attachmentID).Scan(&s3Key)
+		`SELECT s3_bucket, s3_key FROM attachments WHERE id = $1`, attachmentID).Scan(&bucket, &s3Key)
 	if err != nil {
 		return fmt.Errorf("looking up file %s: %w", attachmentID, err)
 	}
 
-	if err := s.client.RemoveObject(ctx, s.bucket, s3Key, minio.RemoveObjectOptions{}); err != nil {
+	st := s.storeForBucket(bucket)
+	if err := st.client.RemoveObject(ctx, st.bucket, s3Key, minio.RemoveObjectOptions{}); err != nil {
 		return fmt.Errorf("removing S3 object %s: %w", s3Key, err)
 	}
 
 	// Clean up thumbnails (best-effort, they share a date path).
-	datePath := extractDatePath(s3Key)
-	for _, size := range s.thumbnailSizes {
-		thumbKey := ThumbnailURL(attachmentID, datePath, size)
-		_ = s.client.RemoveObject(ctx, s.bucket, thumbKey, minio.RemoveObjectOptions{})
-	}
+	s.removeVariants(ctx, st, attachmentID, extractDatePath(s3Key))
 
 	if _, err := s.pool.Exec(ctx, `DELETE FROM attachments WHERE id = $1`, attachmentID); err != nil {
 		return fmt.Errorf("deleting file record %s: %w", attachmentID, err)
@@ -447,12 +463,123 @@ func (s *Service) Delete(ctx context.Context, attachmentID string) error {
 
 // DeleteObject removes an object from S3 by bucket and key. Satisfies admin.MediaDeleter.
 func (s *Service) DeleteObject(ctx context.Context, bucket, key string) error {
-	return s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
+	return s.storeForBucket(bucket).client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
+}
+
+// ObjectExists reports whether an object is present in storage. An error
+// means storage could not say, not that the object is missing.
+func (s *Service) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	_, err := s.storeForBucket(bucket).client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return false, nil
+	}
+	return false, err
+}
+
+// UploadUnusedSQL is a WHERE condition, for a query over attachments aliased
+// a, that leaves out uploads in use outside messages: avatars, icons,
+// banners, emoji, stickers, sounds, event images, import archives and
+// scheduled message attachments. Every cleanup of uploads without a message
+// must include it.
+const UploadUnusedSQL = `a.id NOT IN (
+	    SELECT avatar_id FROM users WHERE avatar_id IS NOT NULL
+	    UNION ALL SELECT banner_id FROM users WHERE banner_id IS NOT NULL
+	    UNION ALL SELECT icon_id FROM guilds WHERE icon_id IS NOT NULL
+	    UNION ALL SELECT banner_id FROM guilds WHERE banner_id IS NOT NULL
+	    UNION ALL SELECT icon_id FROM communities WHERE icon_id IS NOT NULL
+	    UNION ALL SELECT banner_id FROM communities WHERE banner_id IS NOT NULL
+	    UNION ALL SELECT avatar_id FROM guild_members WHERE avatar_id IS NOT NULL
+	    UNION ALL SELECT avatar_id FROM webhooks WHERE avatar_id IS NOT NULL
+	    UNION ALL SELECT image_id FROM guild_events WHERE image_id IS NOT NULL
+	    UNION ALL SELECT file_id FROM stickers
+	    UNION ALL SELECT file_id FROM user_emoji
+	    UNION ALL SELECT file_id FROM instance_emoji
+	    UNION ALL SELECT archive_id FROM guild_imports WHERE archive_id IS NOT NULL
+	    UNION ALL SELECT attachment_id FROM attachment_tags
+	    UNION ALL SELECT unnest(attachment_ids) FROM scheduled_messages
+	)
+	AND a.s3_key NOT IN (
+	    SELECT s3_key FROM custom_emoji
+	    UNION ALL SELECT s3_key FROM channel_emoji
+	)
+	AND NOT EXISTS (
+	    SELECT 1 FROM soundboard_sounds ss
+	    WHERE strpos(ss.file_url, a.id) > 0 OR strpos(ss.file_url, a.s3_key) > 0
+	)`
+
+// PruneUnattachedFiles deletes up to 500 uploads created before cutoff that
+// never made it into a message and are not otherwise in use (see
+// UploadUnusedSQL). It returns how many files were removed and their total
+// size. Object removal is best-effort, as in Delete.
+func (s *Service) PruneUnattachedFiles(ctx context.Context, cutoff time.Time) (int, int64, error) {
+	rows, err := s.pool.Query(ctx,
+		`DELETE FROM attachments WHERE id IN (
+			SELECT a.id FROM attachments a
+			WHERE a.message_id IS NULL AND a.created_at < $1
+			  AND `+UploadUnusedSQL+`
+			ORDER BY a.created_at
+			LIMIT 500
+		 ) RETURNING id, s3_bucket, s3_key, size_bytes`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("deleting unattached files: %w", err)
+	}
+	type object struct {
+		id, bucket, key string
+		size            int64
+	}
+	var objects []object
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.id, &o.bucket, &o.key, &o.size); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("scanning unattached file: %w", err)
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("deleting unattached files: %w", err)
+	}
+
+	var reclaimed int64
+	for _, o := range objects {
+		reclaimed += o.size
+		st := s.storeForBucket(o.bucket)
+		if err := st.client.RemoveObject(ctx, st.bucket, o.key, minio.RemoveObjectOptions{}); err != nil {
+			s.logger.Warn("failed to remove unattached file object",
+				slog.String("key", o.key), slog.String("error", err.Error()))
+		}
+		s.removeVariants(ctx, st, o.id, extractDatePath(o.key))
+	}
+	return len(objects), reclaimed, nil
+}
+
+// OpenFile opens a stored attachment for reading by its ID, returning the
+// object along with its content type and size. It returns pgx.ErrNoRows if
+// no such attachment exists. Satisfies federation.MediaSource.
+func (s *Service) OpenFile(ctx context.Context, fileID string) (io.ReadSeekCloser, string, int64, error) {
+	var contentType, bucket, s3Key string
+	var sizeBytes int64
+	err := s.pool.QueryRow(ctx,
+		`SELECT content_type, size_bytes, s3_bucket, s3_key FROM attachments WHERE id = $1`, fileID,
+	).Scan(&contentType, &sizeBytes, &bucket, &s3Key)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	st := s.storeForBucket(bucket)
+	obj, err := st.client.GetObject(ctx, st.bucket, s3Key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("getting object %s: %w", s3Key, err)
+	}
+	return obj, contentType, sizeBytes, nil
 }
 
-// HealthCheck verifies S3 connectivity.
+// HealthCheck verifies S3 connectivity in the primary region.
 func (s *Service) HealthCheck(ctx context.Context) error {
-	_, err := s.client.BucketExists(ctx, s.bucket)
+	_, err := s.stores[0].client.BucketExists(ctx, s.stores[0].bucket)
 	return err
 }
 
@@ -465,7 +592,11 @@ func extractDatePath(s3Key string) string {
 	return time.Now().UTC().Format("2006/01/02")
 }
 
-// HandleGetFile serves a file by its attachment ID.
+// HandleGetFile serves a file by its attachment ID. NSFW files, flagged
+// themselves or posted in an NSFW channel, follow the content settings of
+// an authenticated caller: refused, or served with an X-Content-Blur hint.
+// Anonymous requests are not filtered. Spoilered files are always served
+// with an X-Content-Blur: spoiler hint.
 // GET /api/v1/files/{fileID}
 func (s *Service) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 	fileID := r.PathValue("fileID")
@@ -474,17 +605,42 @@ func (s *Service) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 		fileID = chi.URLParam(r, "fileID")
 	}
 
-	var filename, contentType, s3Key string
+	var filename, contentType, bucket, s3Key string
 	var sizeBytes int64
+	var nsfw, spoiler bool
 	err := s.pool.QueryRow(r.Context(),
-		`SELECT filename, content_type, size_bytes, s3_key FROM attachments WHERE id = $1`, fileID,
-	).Scan(&filename, &contentType, &sizeBytes, &s3Key)
+		`SELECT a.filename, a.content_type, a.size_bytes, a.s3_bucket, a.s3_key, a.nsfw OR COALESCE(c.nsfw, false), a.spoiler
+		 FROM attachments a
+		 LEFT JOIN messages m ON m.id = a.message_id
+		 LEFT JOIN channels c ON c.id = m.channel_id
+		 WHERE a.id = $1`, fileID,
+	).Scan(&filename, &contentType, &sizeBytes, &bucket, &s3Key, &nsfw, &spoiler)
 	if err != nil {
 		writeError(w, http.StatusNotFound, "file_not_found", "File not found")
 		return
 	}
 
-	obj, err := s.client.GetObject(r.Context(), s.bucket, s3Key, minio.GetObjectOptions{})
+	cacheControl := "public, max-age=3600, must-revalidate"
+	if nsfw {
+		// The response depends on who asks, so shared caches must not keep it.
+		cacheControl = "private, max-age=3600, must-revalidate"
+		w.Header().Set("Vary", "Authorization")
+		if userID := auth.UserIDFromContext(r.Context()); userID != "" {
+			switch apiutil.ContentSettings(r.Context(), s.pool, userID).MediaMode() {
+			case models.NSFWMediaHide:
+				writeError(w, http.StatusForbidden, "nsfw_hidden", "This file is NSFW and hidden by your content settings")
+				return
+			case models.NSFWMediaBlur:
+				w.Header().Set("X-Content-Blur", "nsfw")
+			}
+		}
+	}
+	if spoiler && w.Header().Get("X-Content-Blur") == "" {
+		w.Header().Set("X-Content-Blur", "spoiler")
+	}
+
+	st := s.storeForBucket(bucket)
+	obj, err := st.client.GetObject(r.Context(), st.bucket, s3Key, minio.GetObjectOptions{})
 	if err != nil {
 		s.logger.Error("failed to get file from S3",
 			slog.String("error", err.Error()),
@@ -500,7 +656,7 @@ func (s *Service) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, safeFilename))
-	w.Header().Set("Cache-Control", "public, max-age=3600, must-revalidate")
+	w.Header().Set("Cache-Control", cacheControl)
 	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, fileID))
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
@@ -554,6 +710,7 @@ func (s *Service) HandleUpdateAttachment(w http.ResponseWriter, r *http.Request)
 
 	var req struct {
 		NSFW        *bool   `json:"nsfw"`
+		Spoiler     *bool   `json:"spoiler"`
 		AltText     *string `json:"alt_text"`
 		Description *string `json:"description"`
 	}
@@ -566,9 +723,10 @@ func (s *Service) HandleUpdateAttachment(w http.ResponseWriter, r *http.Request)
 		`UPDATE attachments SET
 		   nsfw = COALESCE($1, nsfw),
 		   alt_text = COALESCE($2, alt_text),
-		   description = COALESCE($3, description)
+		   description = COALESCE($3, description),
+		   spoiler = COALESCE($5, spoiler)
 		 WHERE id = $4`,
-		req.NSFW, req.AltText, req.Description, fileID,
+		req.NSFW, req.AltText, req.Description, fileID, req.Spoiler,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to update attachment")
@@ -579,13 +737,13 @@ func (s *Service) HandleUpdateAttachment(w http.ResponseWriter, r *http.Request)
 	var a models.Attachment
 	err = s.pool.QueryRow(r.Context(),
 		`SELECT id, message_id, uploader_id, filename, content_type, size_bytes,
-		        width, height, duration_seconds, s3_bucket, s3_key, blurhash,
-		        alt_text, nsfw, description, created_at
+		        width, height, duration_seconds, s3_bucket, s3_key, blurhash, thumbhash, variants,
+		        alt_text, nsfw, spoiler, description, created_at
 		 FROM attachments WHERE id = $1`, fileID,
 	).Scan(
 		&a.ID, &a.MessageID, &a.UploaderID, &a.Filename, &a.ContentType, &a.SizeBytes,
-		&a.Width, &a.Height, &a.DurationSeconds, &a.S3Bucket, &a.S3Key, &a.Blurhash,
-		&a.AltText, &a.NSFW, &a.Description, &a.CreatedAt,
+		&a.Width, &a.Height, &a.DurationSeconds, &a.S3Bucket, &a.S3Key, &a.Blurhash, &a.Thumbhash, &a.Variants,
+		&a.AltText, &a.NSFW, &a.Spoiler, &a.Description, &a.CreatedAt,
 	)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to read updated attachment")
@@ -1,6 +1,7 @@
 package media
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"image"
 	"image/color"
@@ -233,6 +234,9 @@ func TestProcessImage(t *testing.T) {
 	if *result.blurhash == "" {
 		t.Error("expected non-empty blurhash")
 	}
+	if result.thumbhash == nil || *result.thumbhash == "" {
+		t.Error("expected non-empty thumbhash")
+	}
 
 	if result.stripped == nil {
 		t.Fatal("expected non-nil stripped data (EXIF strip enabled)")
@@ -311,3 +315,98 @@ func TestThumbnailURL(t *testing.T) {
 		t.Errorf("ThumbnailURL = %q, want %q", got, want)
 	}
 }
+
+func TestVariantKeyAndURL(t *testing.T) {
+	webp, ok := lookupVariantFormat("webp")
+	if !ok {
+		t.Fatal("webp is not a variant format")
+	}
+	if got, want := variantKey("abc123", "2026/02/10", 256, webp), "thumbnails/2026/02/10/abc123_256.webp"; got != want {
+		t.Errorf("variantKey = %q, want %q", got, want)
+	}
+	if got, want := variantURL("abc123", 256, webp), "/api/v1/files/abc123/thumbnail?size=256&format=webp"; got != want {
+		t.Errorf("variantURL = %q, want %q", got, want)
+	}
+	if _, ok := lookupVariantFormat("gif"); ok {
+		t.Error("gif should not be a variant format")
+	}
+}
+
+func TestComputeThumbhash(t *testing.T) {
+	// Scaled to 100x50: 7x4 luminance terms and 3x3 chroma terms give 28
+	// AC nibbles after the 5-byte header.
+	hash, err := base64.StdEncoding.DecodeString(ComputeThumbhash(createTestImage(200, 100)))
+	if err != nil {
+		t.Fatalf("thumbhash is not base64: %v", err)
+	}
+	if len(hash) != 19 {
+		t.Fatalf("thumbhash length = %d, want 19", len(hash))
+	}
+	if hash[2]&0x80 != 0 {
+		t.Error("opaque image should not set the alpha bit")
+	}
+	if hash[4]&0x80 == 0 {
+		t.Error("landscape image should set the landscape bit")
+	}
+	if again := ComputeThumbhash(createTestImage(200, 100)); again != base64.StdEncoding.EncodeToString(hash) {
+		t.Error("thumbhash not deterministic")
+	}
+}
+
+func TestComputeThumbhash_Alpha(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 25; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 40, B: 40, A: 255})
+		}
+	}
+	hash, err := base64.StdEncoding.DecodeString(ComputeThumbhash(img))
+	if err != nil {
+		t.Fatalf("thumbhash is not base64: %v", err)
+	}
+	// 6-byte header with alpha, then 14+5+5+14 AC nibbles.
+	if len(hash) != 25 {
+		t.Fatalf("thumbhash length = %d, want 25", len(hash))
+	}
+	if hash[2]&0x80 == 0 {
+		t.Error("transparent image should set the alpha bit")
+	}
+	if hash[4]&0x80 != 0 {
+		t.Error("square image should not set the landscape bit")
+	}
+}
+
+func TestThumbnailSize(t *testing.T) {
+	sizes := []int{512, 128, 256}
+	tests := []struct{ want, got int }{
+		{1, 128},
+		{128, 128},
+		{200, 256},
+		{512, 512},
+		{4096, 512},
+	}
+	for _, tt := range tests {
+		if got := thumbnailSize(sizes, tt.want); got != tt.got {
+			t.Errorf("thumbnailSize(%v, %d) = %d, want %d", sizes, tt.want, got, tt.got)
+		}
+	}
+}
+
+func TestBlurredThumbnail(t *testing.T) {
+	hash := ComputeBlurhash(createTestImage(200, 100))
+	data, err := blurredThumbnail(hash, 200, 100, 512)
+	if err != nil {
+		t.Fatalf("blurredThumbnail: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding result: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != blurredThumbnailMaxWidth || b.Dy() != blurredThumbnailMaxWidth/2 {
+		t.Errorf("blurred thumbnail is %dx%d, want %dx%d", b.Dx(), b.Dy(), blurredThumbnailMaxWidth, blurredThumbnailMaxWidth/2)
+	}
+
+	if _, err := blurredThumbnail("not-a-hash", 200, 100, 128); err == nil {
+		t.Error("expected error for invalid blurhash")
+	}
+}
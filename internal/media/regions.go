@@ -0,0 +1,121 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/amityvox/amityvox/internal/regions"
+)
+
+// RegionConfig is an S3-compatible endpoint in one deployment region. Every
+// region has its own bucket name, so a stored object's bucket identifies
+// the endpoint that holds it.
+type RegionConfig struct {
+	Name      string
+	Endpoint  string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Region    string // S3 region of the bucket
+	UseSSL    bool
+}
+
+// store is the S3 client and bucket of one region.
+type store struct {
+	client *minio.Client
+	bucket string
+}
+
+// newS3Client connects to an S3-compatible endpoint.
+func newS3Client(endpoint, accessKey, secretKey, region string, useSSL bool) (*minio.Client, error) {
+	// minio.New expects host:port without scheme; strip http:// or https:// if present.
+	if strings.HasPrefix(endpoint, "http://") {
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+	} else if strings.HasPrefix(endpoint, "https://") {
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+		useSSL = true
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating S3 client: %w", err)
+	}
+	return client, nil
+}
+
+type clientRegionKey struct{}
+
+// withClientRegion records the uploader's region for StoreAttachment.
+func withClientRegion(ctx context.Context, region string) context.Context {
+	if region == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, clientRegionKey{}, region)
+}
+
+func clientRegionFrom(ctx context.Context) string {
+	region, _ := ctx.Value(clientRegionKey{}).(string)
+	return region
+}
+
+// storeForBucket returns the region storing bucket. Rows written before a
+// bucket was renamed fall back to the primary region.
+func (s *Service) storeForBucket(bucket string) store {
+	for _, st := range s.stores {
+		if st.bucket == bucket {
+			return st
+		}
+	}
+	return s.stores[0]
+}
+
+// putObject uploads an object to the region picked for the client in ctx. If
+// the upload fails, that region is taken out of rotation and the upload is
+// retried once in the next region picked.
+func (s *Service) putObject(ctx context.Context, key string, data []byte, opts minio.PutObjectOptions) (store, error) {
+	preferred := clientRegionFrom(ctx)
+	i := s.regions.Pick(preferred)
+	st := s.stores[i]
+	_, err := st.client.PutObject(ctx, st.bucket, key, bytes.NewReader(data), int64(len(data)), opts)
+	if err == nil || s.regions.Len() < 2 {
+		return st, err
+	}
+
+	s.regions.MarkUnhealthy(i)
+	j := s.regions.Pick(preferred)
+	if j == i {
+		return st, err
+	}
+	s.logger.Warn("S3 upload failed, retrying in another region",
+		slog.String("from", s.regions.Name(i)),
+		slog.String("to", s.regions.Name(j)),
+		slog.String("error", err.Error()),
+	)
+	st = s.stores[j]
+	_, err = st.client.PutObject(ctx, st.bucket, key, bytes.NewReader(data), int64(len(data)), opts)
+	return st, err
+}
+
+// RegionStatuses returns the health of each storage region, primary first.
+func (s *Service) RegionStatuses() []regions.Status {
+	return s.regions.Statuses()
+}
+
+// StartHealthChecks probes each storage region every interval so uploads
+// fail over away from unreachable ones. It does nothing with one region.
+func (s *Service) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	s.regions.StartHealthChecks(ctx, interval, s.logger, "storage", func(ctx context.Context, i int) error {
+		_, err := s.stores[i].client.BucketExists(ctx, s.stores[i].bucket)
+		return err
+	})
+}
@@ -0,0 +1,163 @@
+package media
+
+import (
+	"encoding/base64"
+	"image"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// thumbhashMaxSize is the largest side an image is scaled to before it is
+// thumbhashed; larger inputs only slow the encoder down.
+const thumbhashMaxSize = 100
+
+// ComputeThumbhash generates a base64-encoded thumbhash for an image. Unlike
+// a blurhash it encodes the aspect ratio and alpha channel, so clients can
+// render a placeholder of the right shape without knowing the dimensions.
+// See https://evanw.github.io/thumbhash/ for the format.
+func ComputeThumbhash(img image.Image) string {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return ""
+	}
+	if w > thumbhashMaxSize || h > thumbhashMaxSize {
+		if w >= h {
+			w, h = thumbhashMaxSize, max(1, h*thumbhashMaxSize/w)
+		} else {
+			w, h = max(1, w*thumbhashMaxSize/h), thumbhashMaxSize
+		}
+	}
+	nrgba := image.NewNRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(nrgba, nrgba.Bounds(), img, bounds, xdraw.Src, nil)
+	return base64.StdEncoding.EncodeToString(encodeThumbhash(w, h, nrgba.Pix))
+}
+
+// encodeThumbhash encodes w×h non-premultiplied RGBA pixels as a thumbhash.
+func encodeThumbhash(w, h int, rgba []byte) []byte {
+	n := w * h
+
+	// Average color, weighted by alpha.
+	var avgR, avgG, avgB, avgA float64
+	for i := 0; i < n; i++ {
+		alpha := float64(rgba[i*4+3]) / 255
+		avgR += alpha / 255 * float64(rgba[i*4])
+		avgG += alpha / 255 * float64(rgba[i*4+1])
+		avgB += alpha / 255 * float64(rgba[i*4+2])
+		avgA += alpha
+	}
+	if avgA > 0 {
+		avgR /= avgA
+		avgG /= avgA
+		avgB /= avgA
+	}
+
+	hasAlpha := avgA < float64(n)
+	lLimit := 7.0
+	if hasAlpha {
+		lLimit = 5 // fewer luminance bits to make room for alpha
+	}
+	longest := float64(max(w, h))
+	lx := max(1, int(math.Round(lLimit*float64(w)/longest)))
+	ly := max(1, int(math.Round(lLimit*float64(h)/longest)))
+
+	// Convert to luminance, yellow-blue, red-green and alpha, composited
+	// over the average color.
+	l := make([]float64, n)
+	p := make([]float64, n)
+	q := make([]float64, n)
+	a := make([]float64, n)
+	for i := 0; i < n; i++ {
+		alpha := float64(rgba[i*4+3]) / 255
+		r := avgR*(1-alpha) + alpha/255*float64(rgba[i*4])
+		g := avgG*(1-alpha) + alpha/255*float64(rgba[i*4+1])
+		b := avgB*(1-alpha) + alpha/255*float64(rgba[i*4+2])
+		l[i] = (r + g + b) / 3
+		p[i] = (r+g)/2 - b
+		q[i] = r - g
+		a[i] = alpha
+	}
+
+	// encodeChannel splits a channel into its DC term and AC terms
+	// normalized to [0, 1], using the DCT.
+	encodeChannel := func(channel []float64, nx, ny int) (dc float64, ac []float64, scale float64) {
+		fx := make([]float64, w)
+		for cy := 0; cy < ny; cy++ {
+			for cx := 0; cx*ny < nx*(ny-cy); cx++ {
+				for x := 0; x < w; x++ {
+					fx[x] = math.Cos(math.Pi / float64(w) * float64(cx) * (float64(x) + 0.5))
+				}
+				var f float64
+				for y := 0; y < h; y++ {
+					fy := math.Cos(math.Pi / float64(h) * float64(cy) * (float64(y) + 0.5))
+					for x := 0; x < w; x++ {
+						f += channel[x+y*w] * fx[x] * fy
+					}
+				}
+				f /= float64(n)
+				if cx > 0 || cy > 0 {
+					ac = append(ac, f)
+					scale = math.Max(scale, math.Abs(f))
+				} else {
+					dc = f
+				}
+			}
+		}
+		if scale > 0 {
+			for i := range ac {
+				ac[i] = 0.5 + 0.5/scale*ac[i]
+			}
+		}
+		return dc, ac, scale
+	}
+	lDC, lAC, lScale := encodeChannel(l, max(3, lx), max(3, ly))
+	pDC, pAC, pScale := encodeChannel(p, 3, 3)
+	qDC, qAC, qScale := encodeChannel(q, 3, 3)
+	var aDC, aScale float64
+	var aAC []float64
+	if hasAlpha {
+		aDC, aAC, aScale = encodeChannel(a, 5, 5)
+	}
+
+	round := func(f float64) uint32 { return uint32(math.Round(f)) }
+	isLandscape := w > h
+	header24 := round(63*lDC) | round(31.5+31.5*pDC)<<6 | round(31.5+31.5*qDC)<<12 | round(31*lScale)<<18
+	if hasAlpha {
+		header24 |= 1 << 23
+	}
+	header16 := uint32(lx)
+	if isLandscape {
+		header16 = uint32(ly)
+	}
+	header16 |= round(63*pScale)<<3 | round(63*qScale)<<9
+	if isLandscape {
+		header16 |= 1 << 15
+	}
+	hash := []byte{
+		byte(header24), byte(header24 >> 8), byte(header24 >> 16),
+		byte(header16), byte(header16 >> 8),
+	}
+	if hasAlpha {
+		hash = append(hash, byte(round(15*aDC)|round(15*aScale)<<4))
+	}
+
+	// Pack the AC terms as nibbles, low nibble first.
+	channels := [][]float64{lAC, pAC, qAC}
+	if hasAlpha {
+		channels = append(channels, aAC)
+	}
+	acStart := len(hash)
+	acIndex := 0
+	for _, ac := range channels {
+		for _, f := range ac {
+			i := acStart + acIndex>>1
+			if i == len(hash) {
+				hash = append(hash, 0)
+			}
+			hash[i] |= byte(round(15*f)) << ((acIndex & 1) << 2)
+			acIndex++
+		}
+	}
+	return hash
+}
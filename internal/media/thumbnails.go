@@ -0,0 +1,175 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/buckket/go-blurhash"
+	"github.com/go-chi/chi/v5"
+	"github.com/minio/minio-go/v7"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// defaultThumbnailSize is the width requested when no size is given.
+const defaultThumbnailSize = 256
+
+// blurredThumbnailMaxWidth caps the width a blurred thumbnail is rendered
+// at; clients scale it up, which only blurs it further.
+const blurredThumbnailMaxWidth = 128
+
+// HandleGetThumbnail serves an image attachment's thumbnail no narrower
+// than the size query parameter. Spoilered images, and NSFW images for
+// callers whose content settings blur them, are served as a blur rendered
+// from their blurhash unless reveal=true is passed; NSFW images the caller
+// hides are refused. format picks one of the variant formats (avif, webp or
+// jpeg, the default) listed in the attachment's variants manifest; formats
+// not rendered for the image fall back to JPEG.
+// GET /api/v1/files/{fileID}/thumbnail?size=256[&format=webp][&reveal=true]
+func (s *Service) HandleGetThumbnail(w http.ResponseWriter, r *http.Request) {
+	fileID := chi.URLParam(r, "fileID")
+
+	var contentType, bucket, s3Key string
+	var width, height *int
+	var bhash *string
+	var nsfw, spoiler bool
+	err := s.pool.QueryRow(r.Context(),
+		`SELECT a.content_type, a.s3_bucket, a.s3_key, a.width, a.height, a.blurhash,
+		        a.nsfw OR COALESCE(c.nsfw, false), a.spoiler
+		 FROM attachments a
+		 LEFT JOIN messages m ON m.id = a.message_id
+		 LEFT JOIN channels c ON c.id = m.channel_id
+		 WHERE a.id = $1`, fileID,
+	).Scan(&contentType, &bucket, &s3Key, &width, &height, &bhash, &nsfw, &spoiler)
+	if err != nil || width == nil || height == nil {
+		writeError(w, http.StatusNotFound, "thumbnail_not_found", "Thumbnail not found")
+		return
+	}
+
+	want := defaultThumbnailSize
+	if v, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil && v > 0 {
+		want = v
+	}
+	size := thumbnailSize(s.thumbnailSizes, want)
+	jpegFormat, _ := lookupVariantFormat("jpeg")
+	format, ok := lookupVariantFormat(r.URL.Query().Get("format"))
+	if !ok {
+		format = jpegFormat
+	}
+
+	cacheControl := "public, max-age=3600, must-revalidate"
+	blur := spoiler
+	if nsfw {
+		cacheControl = "private, max-age=3600, must-revalidate"
+		w.Header().Set("Vary", "Authorization")
+		if userID := auth.UserIDFromContext(r.Context()); userID != "" {
+			switch apiutil.ContentSettings(r.Context(), s.pool, userID).MediaMode() {
+			case models.NSFWMediaHide:
+				writeError(w, http.StatusForbidden, "nsfw_hidden", "This file is NSFW and hidden by your content settings")
+				return
+			case models.NSFWMediaBlur:
+				blur = true
+			}
+		}
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if blur && r.URL.Query().Get("reveal") != "true" {
+		if bhash == nil {
+			writeError(w, http.StatusNotFound, "thumbnail_not_found", "Thumbnail not found")
+			return
+		}
+		data, err := blurredThumbnail(*bhash, *width, *height, size)
+		if err != nil {
+			s.logger.Warn("failed to render blurred thumbnail",
+				slog.String("attachment_id", fileID), slog.String("error", err.Error()))
+			writeError(w, http.StatusInternalServerError, "internal_error", "Failed to render thumbnail")
+			return
+		}
+		reason := "spoiler"
+		if !spoiler {
+			reason = "nsfw"
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("X-Content-Blur", reason)
+		w.Write(data)
+		return
+	}
+
+	// Thumbnails are only generated for sizes narrower than the original,
+	// and in the background; until one exists the original is served.
+	st := s.storeForBucket(bucket)
+	var obj *minio.Object
+	if size < *width {
+		for _, f := range []variantFormat{format, jpegFormat} {
+			obj, err = st.client.GetObject(r.Context(), st.bucket,
+				variantKey(fileID, extractDatePath(s3Key), size, f), minio.GetObjectOptions{})
+			if err == nil {
+				if _, err = obj.Stat(); err != nil {
+					obj.Close()
+				}
+			}
+			if err == nil {
+				contentType = f.contentType
+				break
+			}
+		}
+	}
+	if obj == nil || err != nil {
+		obj, err = st.client.GetObject(r.Context(), st.bucket, s3Key, minio.GetObjectOptions{})
+		if err != nil {
+			s.logger.Error("failed to get file from S3",
+				slog.String("error", err.Error()),
+				slog.String("key", s3Key),
+			)
+			writeError(w, http.StatusInternalServerError, "internal_error", "Failed to retrieve file")
+			return
+		}
+	}
+	defer obj.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	io.Copy(w, obj)
+}
+
+// thumbnailSize returns the smallest configured size of at least want, or
+// the largest size if want exceeds them all.
+func thumbnailSize(sizes []int, want int) int {
+	best := 0
+	for _, size := range sizes {
+		if size >= want && (best == 0 || size < best) {
+			best = size
+		}
+	}
+	if best == 0 {
+		for _, size := range sizes {
+			best = max(best, size)
+		}
+	}
+	return best
+}
+
+// blurredThumbnail renders a blurhash as a JPEG with the original's aspect
+// ratio, at most size and blurredThumbnailMaxWidth wide.
+func blurredThumbnail(hash string, origW, origH, size int) ([]byte, error) {
+	w := min(size, origW, blurredThumbnailMaxWidth)
+	h := max(1, w*origH/max(origW, 1))
+	img, err := blurhash.Decode(hash, max(w, 1), h, 1)
+	if err != nil {
+		return nil, fmt.Errorf("decoding blurhash: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 70}); err != nil {
+		return nil, fmt.Errorf("encoding blurred thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,249 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// variantFormat is an image format variants are rendered in.
+type variantFormat struct {
+	name        string // as in the manifest and the thumbnail format parameter
+	contentType string
+	ext         string
+	ffmpegArgs  []string // encoder arguments; nil for formats encoded in Go
+}
+
+// variantFormats lists the rendered formats, most compact first. JPEG is
+// encoded in Go and always rendered; AVIF and WebP need an ffmpeg built with
+// libaom and libwebp on the worker's PATH and are skipped without one.
+var variantFormats = []variantFormat{
+	{name: "avif", contentType: "image/avif", ext: "avif",
+		ffmpegArgs: []string{"-c:v", "libaom-av1", "-still-picture", "1", "-crf", "32", "-pix_fmt", "yuv420p"}},
+	{name: "webp", contentType: "image/webp", ext: "webp",
+		ffmpegArgs: []string{"-c:v", "libwebp", "-quality", "80"}},
+	{name: "jpeg", contentType: "image/jpeg", ext: "jpg"},
+}
+
+// lookupVariantFormat returns the variant format called name.
+func lookupVariantFormat(name string) (variantFormat, bool) {
+	for _, f := range variantFormats {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return variantFormat{}, false
+}
+
+// variantKey returns the S3 key of an image variant. JPEG variants keep the
+// key thumbnails have always been stored under (see ThumbnailURL).
+func variantKey(attachmentID, datePath string, size int, f variantFormat) string {
+	return fmt.Sprintf("thumbnails/%s/%s_%d.%s", datePath, attachmentID, size, f.ext)
+}
+
+// variantURL returns the API path a variant is served from.
+func variantURL(attachmentID string, size int, f variantFormat) string {
+	return "/api/v1/files/" + attachmentID + "/thumbnail?size=" + strconv.Itoa(size) + "&format=" + f.name
+}
+
+// removeVariants deletes every variant an image may have. Removal is
+// best-effort, as in Delete.
+func (s *Service) removeVariants(ctx context.Context, st store, attachmentID, datePath string) {
+	for _, size := range s.thumbnailSizes {
+		for _, f := range variantFormats {
+			_ = st.client.RemoveObject(ctx, st.bucket, variantKey(attachmentID, datePath, size, f), minio.RemoveObjectOptions{})
+		}
+	}
+}
+
+// GenerateImageVariants renders the configured thumbnail sizes of up to
+// limit image attachments that have no variants manifest yet, oldest first,
+// and records their manifests and thumbhashes. Images uploaded before
+// thumbhashes existed are backfilled the same way. It returns how many
+// images were processed.
+func (s *Service) GenerateImageVariants(ctx context.Context, limit int) (int, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT id, s3_bucket, s3_key FROM attachments
+		 WHERE variants IS NULL AND width IS NOT NULL AND content_type LIKE 'image/%'
+		 ORDER BY created_at
+		 LIMIT $1`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("listing images without variants: %w", err)
+	}
+	type pending struct{ id, bucket, key string }
+	var images []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.bucket, &p.key); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning image without variants: %w", err)
+		}
+		images = append(images, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("listing images without variants: %w", err)
+	}
+
+	_, ffmpegErr := exec.LookPath("ffmpeg")
+	processed := 0
+	for _, img := range images {
+		if ctx.Err() != nil {
+			break
+		}
+		variants, thash, err := s.renderVariants(ctx, img.id, img.bucket, img.key, ffmpegErr == nil)
+		if err != nil {
+			// Left without a manifest, so the next run retries it.
+			s.logger.Warn("failed to render image variants",
+				slog.String("attachment_id", img.id), slog.String("error", err.Error()))
+			continue
+		}
+		manifest, err := json.Marshal(variants)
+		if err != nil {
+			return processed, fmt.Errorf("encoding variants of %s: %w", img.id, err)
+		}
+		if _, err := s.pool.Exec(ctx,
+			`UPDATE attachments SET variants = $2, thumbhash = COALESCE(thumbhash, $3) WHERE id = $1`,
+			img.id, manifest, thash); err != nil {
+			return processed, fmt.Errorf("recording variants of %s: %w", img.id, err)
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+// renderVariants downloads an image and uploads its variants to the same
+// region, returning the manifest and the image's thumbhash. Images that
+// are gone or cannot be decoded get an empty manifest, so they are not
+// retried.
+func (s *Service) renderVariants(ctx context.Context, attachmentID, bucket, s3Key string, useFFmpeg bool) ([]models.AttachmentVariant, *string, error) {
+	variants := []models.AttachmentVariant{}
+
+	st := s.storeForBucket(bucket)
+	obj, err := st.client.GetObject(ctx, st.bucket, s3Key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting %s: %w", s3Key, err)
+	}
+	data, err := io.ReadAll(io.LimitReader(obj, s.maxUpload+1))
+	obj.Close()
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return variants, nil, nil
+		}
+		return nil, nil, fmt.Errorf("reading %s: %w", s3Key, err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		s.logger.Debug("failed to decode image for variants",
+			slog.String("attachment_id", attachmentID), slog.String("error", err.Error()))
+		return variants, nil, nil
+	}
+	var thash *string
+	if h := ComputeThumbhash(img); h != "" {
+		thash = &h
+	}
+
+	origW := img.Bounds().Dx()
+	datePath := extractDatePath(s3Key)
+	sizes := slices.Clone(s.thumbnailSizes)
+	slices.Sort(sizes)
+	for _, size := range slices.Compact(sizes) {
+		// Variants are only rendered narrower than the original.
+		if size >= origW {
+			continue
+		}
+		resized := resizeWidth(img, size)
+		for _, f := range variantFormats {
+			if f.ffmpegArgs != nil && !useFFmpeg {
+				continue
+			}
+			encoded, err := encodeVariant(ctx, resized, f)
+			if err != nil {
+				s.logger.Debug("failed to encode image variant",
+					slog.String("attachment_id", attachmentID),
+					slog.Int("size", size),
+					slog.String("format", f.name),
+					slog.String("error", err.Error()),
+				)
+				continue
+			}
+			key := variantKey(attachmentID, datePath, size, f)
+			if _, err := st.client.PutObject(ctx, st.bucket, key,
+				bytes.NewReader(encoded), int64(len(encoded)),
+				minio.PutObjectOptions{
+					ContentType: f.contentType,
+					UserMetadata: map[string]string{
+						"attachment-id":  attachmentID,
+						"thumbnail-size": strconv.Itoa(size),
+					},
+				}); err != nil {
+				return nil, nil, fmt.Errorf("uploading %s: %w", key, err)
+			}
+			variants = append(variants, models.AttachmentVariant{
+				Width:       size,
+				Height:      resized.Bounds().Dy(),
+				Format:      f.name,
+				ContentType: f.contentType,
+				SizeBytes:   int64(len(encoded)),
+				URL:         variantURL(attachmentID, size, f),
+			})
+		}
+	}
+	return variants, thash, nil
+}
+
+// encodeVariant encodes img in format f.
+func encodeVariant(ctx context.Context, img image.Image, f variantFormat) ([]byte, error) {
+	if f.ffmpegArgs == nil {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, fmt.Errorf("encoding jpeg: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+	return encodeWithFFmpeg(ctx, img, f)
+}
+
+// encodeWithFFmpeg pipes img to ffmpeg as PNG and reads back the encoded
+// image. ffmpeg writes to a temporary file because its AVIF muxer needs a
+// seekable output.
+func encodeWithFFmpeg(ctx context.Context, img image.Image, f variantFormat) ([]byte, error) {
+	var in bytes.Buffer
+	if err := png.Encode(&in, img); err != nil {
+		return nil, fmt.Errorf("encoding png: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "amityvox-variant-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "variant."+f.ext)
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-f", "png_pipe", "-i", "pipe:0"}
+	args = append(args, f.ffmpegArgs...)
+	args = append(args, "-frames:v", "1", "-y", out)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = &in
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg %s encode failed: %w, stderr: %s", f.name, err, stderr.String())
+	}
+	return os.ReadFile(out)
+}
@@ -1,5 +1,6 @@
-// Package mentions extracts user, role, and @here mentions from message content.
-// Mention syntax: <@ULID> for users, <@&ULID> for roles, @here for channel-wide pings.
+// Package mentions extracts user, role, @here and @everyone mentions from message content.
+// Mention syntax: <@ULID> for users, <@&ULID> for roles, @here for channel-wide pings,
+// @everyone for guild-wide pings.
 // Mentions inside code blocks (``` ```) and inline code (` `) are ignored.
 package mentions
 
@@ -9,9 +10,10 @@ import (
 
 // ParseResult holds the extracted mentions from a message.
 type ParseResult struct {
-	UserIDs     []string
-	RoleIDs     []string
-	MentionHere bool
+	UserIDs         []string
+	RoleIDs         []string
+	MentionHere     bool
+	MentionEveryone bool
 }
 
 var (
@@ -22,7 +24,8 @@ var (
 	codeBlockRe  = regexp.MustCompile("(?s)```.*?```")
 	inlineCodeRe = regexp.MustCompile("`[^`]+`")
 	// @here with word boundary awareness — prevents matching substrings like "email@here.com".
-	hereRe = regexp.MustCompile(`(?:^|\W)@here(?:\W|$)`)
+	hereRe     = regexp.MustCompile(`(?:^|\W)@here(?:\W|$)`)
+	everyoneRe = regexp.MustCompile(`(?:^|\W)@everyone(?:\W|$)`)
 )
 
 // Parse extracts mentions from message content, ignoring mentions inside code blocks
@@ -58,6 +61,9 @@ func Parse(content string) ParseResult {
 	if hereRe.MatchString(stripped) {
 		result.MentionHere = true
 	}
+	if everyoneRe.MatchString(stripped) {
+		result.MentionEveryone = true
+	}
 
 	return result
 }
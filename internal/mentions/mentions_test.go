@@ -176,3 +176,25 @@ func sliceEqual(a, b []string) bool {
 	}
 	return true
 }
+
+func TestParse_Everyone(t *testing.T) {
+	tests := []struct {
+		content      string
+		wantEveryone bool
+		wantHere     bool
+	}{
+		{"@everyone meeting now", true, false},
+		{"ping @everyone.", true, false},
+		{"@here and @everyone", true, true},
+		{"mail me at team@everyone.example", false, false},
+		{"`@everyone` is disabled here", false, false},
+		{"@everyoneelse", false, false},
+	}
+	for _, tt := range tests {
+		got := Parse(tt.content)
+		if got.MentionEveryone != tt.wantEveryone || got.MentionHere != tt.wantHere {
+			t.Errorf("Parse(%q) everyone=%v here=%v, want everyone=%v here=%v",
+				tt.content, got.MentionEveryone, got.MentionHere, tt.wantEveryone, tt.wantHere)
+		}
+	}
+}
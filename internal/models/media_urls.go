@@ -0,0 +1,114 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/amityvox/amityvox/internal/cdn"
+)
+
+// The types below add resolved media URLs when they are written as JSON,
+// using the builder configured in package cdn. The URLs are not stored and
+// are omitted when resolved URLs are off.
+//
+// Structs that embed one of these types inherit its MarshalJSON, which would
+// drop their own fields; they must define MarshalJSON with MergeJSON.
+
+// MarshalJSON adds avatar_url and banner_url.
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	b := cdn.Current()
+	return json.Marshal(struct {
+		user
+		AvatarURL *string `json:"avatar_url,omitempty"`
+		BannerURL *string `json:"banner_url,omitempty"`
+	}{user(u), b.OptionalFile(u.AvatarID, u.InstanceID), b.OptionalFile(u.BannerID, u.InstanceID)})
+}
+
+// MarshalJSON writes the user with the private fields of the owner.
+func (s SelfUser) MarshalJSON() ([]byte, error) {
+	if s.User == nil {
+		return []byte("null"), nil
+	}
+	return MergeJSON(s.User, struct {
+		Email                  *string `json:"email,omitempty"`
+		ShareFederatedPresence bool    `json:"share_federated_presence"`
+		ReadReceipts           bool    `json:"read_receipts"`
+	}{s.Email, s.ShareFederatedPresence, s.ReadReceipts})
+}
+
+// MarshalJSON adds icon_url and banner_url.
+func (g Guild) MarshalJSON() ([]byte, error) {
+	type guild Guild
+	b := cdn.Current()
+	return json.Marshal(struct {
+		guild
+		IconURL   *string `json:"icon_url,omitempty"`
+		BannerURL *string `json:"banner_url,omitempty"`
+	}{guild(g), b.OptionalFile(g.IconID, g.InstanceID), b.OptionalFile(g.BannerID, g.InstanceID)})
+}
+
+// MarshalJSON adds url.
+func (e CustomEmoji) MarshalJSON() ([]byte, error) {
+	type emoji CustomEmoji
+	return json.Marshal(struct {
+		emoji
+		URL string `json:"url,omitempty"`
+	}{emoji(e), cdn.Current().Emoji(e.ID, e.ImageHash)})
+}
+
+// MarshalJSON adds url and, for images, thumbnail_url.
+func (a Attachment) MarshalJSON() ([]byte, error) {
+	type attachment Attachment
+	b := cdn.Current()
+	var instanceID string
+	if a.InstanceID != nil {
+		instanceID = *a.InstanceID
+	}
+	var thumbnail string
+	if a.Width != nil && strings.HasPrefix(a.ContentType, "image/") {
+		thumbnail = b.Thumbnail(a.ID, instanceID, cdn.DefaultThumbnailSize)
+	}
+	return json.Marshal(struct {
+		attachment
+		URL          string `json:"url,omitempty"`
+		ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	}{attachment(a), b.File(a.ID, instanceID), thumbnail})
+}
+
+// MergeJSON writes base, which must encode as a JSON object, with the
+// fields of extra added. Fields of extra replace those of base with the
+// same name.
+func MergeJSON(base, extra interface{}) ([]byte, error) {
+	baseJSON, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+	extraJSON, err := json.Marshal(extra)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(baseJSON, &fields); err != nil {
+		return nil, fmt.Errorf("merging JSON: base is not an object: %w", err)
+	}
+	var extraFields map[string]json.RawMessage
+	if err := json.Unmarshal(extraJSON, &extraFields); err != nil {
+		return nil, fmt.Errorf("merging JSON: extra is not an object: %w", err)
+	}
+	if len(extraFields) == 0 {
+		return baseJSON, nil
+	}
+	for k, v := range extraFields {
+		fields[k] = v
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fields); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -28,6 +28,32 @@ type Instance struct {
 	LastSeenAt      *time.Time      `json:"last_seen_at,omitempty"`
 }
 
+// DirectoryEntry is how an instance that opted in to the federated instance
+// directory describes itself. Remote entries are stored in the
+// instance_directory table; PeerStatus is filled in for admin listings.
+type DirectoryEntry struct {
+	InstanceID      string           `json:"instance_id"`
+	Domain          string           `json:"domain"`
+	Name            *string          `json:"name"`
+	Description     *string          `json:"description"`
+	UserCount       int              `json:"user_count"`
+	FederationMode  string           `json:"federation_mode"`
+	SoftwareVersion *string          `json:"software_version"`
+	FeaturedGuilds  []DirectoryGuild `json:"featured_guilds"`
+	FetchedAt       *time.Time       `json:"fetched_at,omitempty"`
+	PeerStatus      *string          `json:"peer_status,omitempty"`
+}
+
+// DirectoryGuild is a discoverable guild an instance features in its
+// directory entry.
+type DirectoryGuild struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	IconID      *string `json:"icon_id,omitempty"`
+	MemberCount int     `json:"member_count"`
+}
+
 // User represents a user account on an AmityVox instance. Users are identified
 // globally as @username@instance.domain. Corresponds to the users table.
 type User struct {
@@ -53,18 +79,30 @@ type User struct {
 	LastOnline     *time.Time `json:"last_online,omitempty"`
 	CreatedAt      time.Time  `json:"created_at"`
 	InstanceDomain *string    `json:"instance_domain,omitempty"` // Set for remote/federated users
+
+	// ShareFederatedPresence opts the user in to sending presence to remote
+	// instances they only share DMs with. Only exposed to the user themselves.
+	ShareFederatedPresence bool `json:"-"`
+
+	// ReadReceipts lets DM participants see when the user has read their
+	// messages, and the user see when others have read theirs.
+	ReadReceipts bool `json:"-"`
 }
 
-// SelfUser is a response-only wrapper that includes the email field.
+// SelfUser is a response-only wrapper that includes the email field and
+// private settings.
 // Used for endpoints where the user is viewing their own profile (@me, login, register).
 type SelfUser struct {
 	*User
-	Email *string `json:"email,omitempty"`
+	Email                  *string `json:"email,omitempty"`
+	ShareFederatedPresence bool    `json:"share_federated_presence"`
+	ReadReceipts           bool    `json:"read_receipts"`
 }
 
 // ToSelf returns a SelfUser wrapper that includes the email field in JSON output.
 func (u *User) ToSelf() SelfUser {
-	return SelfUser{User: u, Email: u.Email}
+	return SelfUser{User: u, Email: u.Email, ShareFederatedPresence: u.ShareFederatedPresence,
+		ReadReceipts: u.ReadReceipts}
 }
 
 // UserFlags defines bitfield flags for user account status.
@@ -75,6 +113,7 @@ const (
 	UserFlagBot        = 1 << 3
 	UserFlagVerified   = 1 << 4
 	UserFlagGlobalMod  = 1 << 5
+	UserFlagSupporter  = 1 << 8 // holds a current supporter entitlement
 )
 
 // IsSuspended reports whether the user is suspended.
@@ -105,6 +144,59 @@ type UserLink struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// GuildPosition places a guild in a user's guild list: at Position at the
+// top level, or at FolderPosition inside the folder FolderID. Corresponds to
+// the user_guild_positions table.
+type GuildPosition struct {
+	GuildID        string  `json:"guild_id"`
+	Position       int     `json:"position"`
+	FolderID       *string `json:"folder_id"`
+	FolderPosition int     `json:"folder_position"`
+}
+
+// GuildFolder groups guilds in a user's guild list. GuildIDs are in folder
+// order. Corresponds to the guild_folders table.
+type GuildFolder struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	Color     *string   `json:"color"`
+	GuildIDs  []string  `json:"guild_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChannelFavorite is a channel a user pinned to their favorites. GuildID is
+// nil for DMs. Corresponds to the user_channel_favorites table.
+type ChannelFavorite struct {
+	ChannelID string    `json:"channel_id"`
+	GuildID   *string   `json:"guild_id"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GuildLayout is a user's own arrangement of their guild list and favorite
+// channels, kept on the server so every device shows the same order. Guilds
+// without a position are listed after positioned ones, by name.
+type GuildLayout struct {
+	GuildPositions []GuildPosition   `json:"guild_positions"`
+	Folders        []GuildFolder     `json:"folders"`
+	Favorites      []ChannelFavorite `json:"favorites"`
+}
+
+// SelfGuild is a guild as listed for one of its members: the guild, the
+// member's roles and guild-level permissions, and what they have not read.
+// Unread and mention counts cover the channels the member has opened, as
+// in READ_STATE_SUMMARY.
+type SelfGuild struct {
+	Guild
+	Owner          bool       `json:"owner"`
+	RoleIDs        []string   `json:"role_ids"`
+	Permissions    int64      `json:"permissions"`
+	UnreadChannels int        `json:"unread_channels"`
+	MentionCount   int        `json:"mention_count"`
+	LastActivityAt *time.Time `json:"last_activity_at"`
+}
+
 // UserSession represents an active login session. Session tokens are stored as
 // the primary key and used as Bearer tokens for API authentication.
 // Corresponds to the user_sessions table.
@@ -149,6 +241,14 @@ type UserBlock struct {
 	User      *User     `json:"user,omitempty"` // Populated on list (the blocked user's profile)
 }
 
+// BlockLevel constants for user_blocks.level. Ignored users' messages are
+// collapsed by the client and can still be shown; blocked users' messages
+// reach the blocker only as placeholders.
+const (
+	BlockLevelIgnore = "ignore"
+	BlockLevelBlock  = "block"
+)
+
 // WebAuthnCredential represents a WebAuthn/FIDO2 credential registered by a user
 // for passwordless authentication. Corresponds to the webauthn_credentials table.
 type WebAuthnCredential struct {
@@ -181,7 +281,16 @@ type Guild struct {
 	SystemChannelLeave   *string   `json:"system_channel_leave,omitempty"`
 	SystemChannelKick    *string   `json:"system_channel_kick,omitempty"`
 	SystemChannelBan     *string   `json:"system_channel_ban,omitempty"`
+	// SystemChannelID receives the messages the server posts for the guild;
+	// the toggles below turn each kind off.
+	SystemChannelID      *string   `json:"system_channel_id,omitempty"`
+	SystemJoinMessages   bool      `json:"system_join_messages"`
+	SystemBoostMessages  bool      `json:"system_boost_messages"`
+	SystemEventReminders bool      `json:"system_event_reminders"`
+	// InviteLinkPolicy is one of the InviteLinkPolicy constants.
+	InviteLinkPolicy     string    `json:"invite_link_policy,omitempty"`
 	PreferredLocale      string    `json:"preferred_locale"`
+	Timezone             string    `json:"timezone"`
 	MaxMembers           int       `json:"max_members"`
 	VanityURL            *string   `json:"vanity_url,omitempty"`
 	VerificationLevel    int       `json:"verification_level"`
@@ -189,17 +298,25 @@ type Guild struct {
 	AFKTimeout           int       `json:"afk_timeout"`
 	Tags                 []string  `json:"tags,omitempty"`
 	MemberCount          int       `json:"member_count,omitempty"`
+	// Version goes up with every PATCH. A PATCH that carries an older
+	// version is refused with the current guild.
+	Version              int64     `json:"version,omitempty"`
 	CreatedAt            time.Time `json:"created_at"`
 }
 
 // GuildCategory represents a channel category within a guild, used to organize
 // channels visually. Corresponds to the guild_categories table.
+// NSFW, SlowmodeSeconds and NotificationLevel are defaults inherited by the
+// category's channels unless a channel overrides them.
 type GuildCategory struct {
-	ID        string    `json:"id"`
-	GuildID   string    `json:"guild_id"`
-	Name      string    `json:"name"`
-	Position  int       `json:"position"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                string    `json:"id"`
+	GuildID           string    `json:"guild_id"`
+	Name              string    `json:"name"`
+	Position          int       `json:"position"`
+	NSFW              bool      `json:"nsfw"`
+	SlowmodeSeconds   int       `json:"slowmode_seconds"`
+	NotificationLevel *string   `json:"notification_level,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
 }
 
 // Channel represents a text, voice, DM, group, or other channel type. Guild
@@ -237,8 +354,47 @@ type Channel struct {
 	GalleryDefaultSort        string     `json:"gallery_default_sort,omitempty"`
 	GalleryPostGuidelines     *string    `json:"gallery_post_guidelines,omitempty"`
 	GalleryRequireTags        bool       `json:"gallery_require_tags,omitempty"`
+	// AutoThread opens a thread for every top-level message; replies must
+	// go in the thread.
+	AutoThread                bool       `json:"auto_thread"`
+	// DisallowBotPosts and DisallowWebhookPosts keep bots and webhooks out
+	// of the channel whatever their permissions, e.g. for human-only channels.
+	DisallowBotPosts          bool       `json:"disallow_bot_posts"`
+	DisallowWebhookPosts      bool       `json:"disallow_webhook_posts"`
+	// InviteLinksExempt lets invite links through whatever the guild's
+	// invite link policy.
+	InviteLinksExempt         bool       `json:"invite_links_exempt"`
+	// VoiceChannelID ties a text channel to a voice room; members connected
+	// to it skip slowmode and gain VoiceParticipantAllow. Voice and stage
+	// channels are tied to their own chat.
+	VoiceChannelID            *string    `json:"voice_channel_id,omitempty"`
+	VoiceParticipantAllow     int64      `json:"voice_participant_allow"`
+	// VoiceChatRecentMinutes and VoiceChatHistory apply to the chat of a
+	// voice or stage channel: who may still read it after leaving, and
+	// whether it is kept once the room empties.
+	VoiceChatRecentMinutes    int        `json:"voice_chat_recent_minutes"`
+	VoiceChatHistory          string     `json:"voice_chat_history,omitempty"`
+	// PreviewEnabled keeps the channel listed, locked, to members who
+	// cannot view it, naming PreviewRoleID as the role that unlocks it.
+	// PreviewLocked is set on channels the caller only previews.
+	PreviewEnabled            bool       `json:"preview_enabled"`
+	PreviewRoleID             *string    `json:"preview_role_id,omitempty"`
+	PreviewLocked             bool       `json:"preview_locked,omitempty"`
+	// PublicView serves the channel as read-only HTML to anyone, under
+	// /api/v1/public/guilds/{guild}/channels/{channel}.
+	PublicView                bool       `json:"public_view"`
+	// Language is the channel's primary language, an ISO 639 code used
+	// for search tokenizing, translation and AutoMod word filters.
+	Language                  *string    `json:"language,omitempty"`
 	Pinned                    bool       `json:"pinned,omitempty"`
 	ReplyCount                int        `json:"reply_count,omitempty"`
+	// NSFW, SlowmodeSeconds and NotificationLevel are effective values; the
+	// *Inherited flags say whether each one follows the category.
+	NSFWInherited              bool       `json:"nsfw_inherited"`
+	SlowmodeInherited          bool       `json:"slowmode_inherited"`
+	NotificationLevel          *string    `json:"notification_level,omitempty"`
+	NotificationLevelInherited bool       `json:"notification_level_inherited"`
+	Version                   int64      `json:"version,omitempty"`
 	CreatedAt                 time.Time  `json:"created_at"`
 	Recipients                []User     `json:"recipients,omitempty"`
 }
@@ -276,9 +432,27 @@ type Role struct {
 	Position         int       `json:"position"`
 	PermissionsAllow int64     `json:"permissions_allow"`
 	PermissionsDeny  int64     `json:"permissions_deny"`
+	ManagedBy        *string   `json:"managed_by,omitempty"`
+	CommunityRoleID  *string   `json:"community_role_id,omitempty"`
+	Version          int64     `json:"version,omitempty"`
 	CreatedAt        time.Time `json:"created_at"`
 }
 
+// PermissionPreset is a named permission set offered when creating a role:
+// one of the built-in presets, or one a guild saved itself (GuildID set).
+// Custom presets correspond to the guild_permission_presets table.
+type PermissionPreset struct {
+	ID               string     `json:"id"`
+	GuildID          *string    `json:"guild_id,omitempty"`
+	Name             string     `json:"name"`
+	Description      *string    `json:"description,omitempty"`
+	PermissionsAllow int64      `json:"permissions_allow"`
+	PermissionsDeny  int64      `json:"permissions_deny"`
+	Builtin          bool       `json:"builtin"`
+	CreatedBy        *string    `json:"created_by,omitempty"`
+	CreatedAt        *time.Time `json:"created_at,omitempty"`
+}
+
 // GuildMember represents a user's membership in a guild, including per-guild
 // nickname, avatar override, and timeout status. Corresponds to the guild_members table.
 type GuildMember struct {
@@ -340,6 +514,7 @@ type Message struct {
 	MentionUserIDs      []string   `json:"mention_user_ids,omitempty"`
 	MentionRoleIDs      []string   `json:"mention_role_ids,omitempty"`
 	MentionHere         bool       `json:"mention_here"`
+	MentionEveryone     bool       `json:"mention_everyone"`
 	ThreadID            *string    `json:"thread_id,omitempty"`
 	MasqueradeName      *string    `json:"masquerade_name,omitempty"`
 	MasqueradeAvatar    *string    `json:"masquerade_avatar,omitempty"`
@@ -351,10 +526,32 @@ type Message struct {
 	Components          json.RawMessage `json:"components,omitempty"`
 	Attachments         []Attachment    `json:"attachments,omitempty"`
 	Embeds              []Embed         `json:"embeds,omitempty"`
+	Translation         *MessageTranslation `json:"translation,omitempty"`
+	Burn                *MessageBurn        `json:"burn,omitempty"`
+	ContentWarning      *string             `json:"content_warning,omitempty"` // shown in place of the content until revealed
+	RepeatCount         int                 `json:"repeat_count,omitempty"` // times resent and collapsed into this message
+	Blocked             bool                `json:"blocked,omitempty"`      // author is blocked by the viewer; content removed
+	Status              string              `json:"status,omitempty"`       // DM delivery status of the viewer's own messages
 	CreatedAt           time.Time       `json:"created_at"`
 	Author              *User           `json:"author,omitempty"`
 }
 
+// MessageTranslation is a stored machine translation of a message's content,
+// attached in auto-translated channels. Clients choose whether to show it.
+type MessageTranslation struct {
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	Text       string `json:"text"`
+}
+
+// MessageBurn marks a DM that deletes itself after the recipient reads it.
+// BurnAt is unset until the recipient's read state passes the message; from
+// then on clients count down to it.
+type MessageBurn struct {
+	AfterMinutes int        `json:"after_minutes"`
+	BurnAt       *time.Time `json:"burn_at,omitempty"`
+}
+
 // MessageType constants for messages.message_type.
 const (
 	MessageTypeDefault       = "default"
@@ -370,19 +567,76 @@ const (
 	MessageTypeForward       = "forward"
 	MessageTypeScheduled     = "scheduled"
 	MessageTypeSystemLockdown = "system_lockdown"
+	MessageTypeSystemMissedCall = "system_missed_call"
+	MessageTypeSystemBoost = "system_boost"
+	MessageTypeSystemEventReminder = "system_event_reminder"
+	MessageTypeSystemSecurityAlert = "system_security_alert"
+)
+
+// MessageStatus constants for Message.Status, the delivery state of a DM
+// shown to its author.
+const (
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered" // reached a session or push of every recipient
+	MessageStatusRead      = "read"      // read by every recipient
+)
+
+// InviteLinkPolicy constants for guilds.invite_link_policy, which decides
+// what happens to messages linking to guild invites.
+const (
+	InviteLinkPolicyAllow              = "allow"
+	InviteLinkPolicyStrip              = "strip"                // links are removed from the message
+	InviteLinkPolicyRequireManageGuild = "require_manage_guild" // only MANAGE_GUILD holders may post them
+)
+
+// VoiceChatHistory constants for channels.voice_chat_history.
+const (
+	VoiceChatHistoryKeep    = "keep"
+	VoiceChatHistorySession = "session" // cleared once the room empties
 )
 
 // MessageFlag constants for messages.flags bitfield.
 const (
-	MessageFlagCrosspost = 1 << 0
-	MessageFlagPinned    = 1 << 1
-	MessageFlagUrgent    = 1 << 2
-	MessageFlagSilent    = 1 << 3
+	MessageFlagCrosspost   = 1 << 0
+	MessageFlagPinned      = 1 << 1
+	MessageFlagUrgent      = 1 << 2
+	MessageFlagSilent      = 1 << 3
+	MessageFlagQuarantined = 1 << 4
+	MessageFlagDeleted     = 1 << 5
 )
 
 // IsSilent reports whether the message has the silent flag set (no notifications).
 func (m Message) IsSilent() bool { return m.Flags&MessageFlagSilent != 0 }
 
+// IsQuarantined reports whether the message was sent by a shadow-quarantined
+// user and is hidden from everyone but its author and moderators.
+func (m Message) IsQuarantined() bool { return m.Flags&MessageFlagQuarantined != 0 }
+
+// IsDeleted reports whether the message was soft-deleted and is waiting out
+// its guild's restore window, visible only to moderators.
+func (m Message) IsDeleted() bool { return m.Flags&MessageFlagDeleted != 0 }
+
+// RedactContent removes what the message says — its content, attachments,
+// embeds, components, voice waveform and translation — and keeps everything
+// else. Bots without the message content capability see messages this way.
+func (m *Message) RedactContent() {
+	m.Content = nil
+	m.Attachments = nil
+	m.Embeds = nil
+	m.Components = nil
+	m.VoiceWaveform = nil
+	m.Translation = nil
+}
+
+// RedactBlocked turns the message into the placeholder shown to a user who
+// blocked its author: its content and content warning are removed and it is
+// marked blocked.
+func (m *Message) RedactBlocked() {
+	m.RedactContent()
+	m.ContentWarning = nil
+	m.Blocked = true
+}
+
 // ScheduledMessage represents a message scheduled for future delivery.
 // Corresponds to the scheduled_messages table.
 type ScheduledMessage struct {
@@ -398,23 +652,39 @@ type ScheduledMessage struct {
 // Attachment represents a file attached to a message, stored in S3-compatible
 // object storage. Corresponds to the attachments table.
 type Attachment struct {
-	ID              string    `json:"id"`
-	MessageID       *string   `json:"message_id,omitempty"`
-	UploaderID      *string   `json:"uploader_id,omitempty"`
-	Filename        string    `json:"filename"`
-	ContentType     string    `json:"content_type"`
-	SizeBytes       int64     `json:"size_bytes"`
-	Width           *int      `json:"width,omitempty"`
-	Height          *int      `json:"height,omitempty"`
-	DurationSeconds *float32  `json:"duration_seconds,omitempty"`
-	S3Bucket        string    `json:"s3_bucket"`
-	S3Key           string    `json:"s3_key"`
-	Blurhash        *string   `json:"blurhash,omitempty"`
-	AltText         *string   `json:"alt_text,omitempty"`
-	NSFW            bool      `json:"nsfw"`
-	Description     *string   `json:"description,omitempty"`
-	InstanceID      *string   `json:"instance_id,omitempty"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID              string              `json:"id"`
+	MessageID       *string             `json:"message_id,omitempty"`
+	UploaderID      *string             `json:"uploader_id,omitempty"`
+	Filename        string              `json:"filename"`
+	ContentType     string              `json:"content_type"`
+	SizeBytes       int64               `json:"size_bytes"`
+	Width           *int                `json:"width,omitempty"`
+	Height          *int                `json:"height,omitempty"`
+	DurationSeconds *float32            `json:"duration_seconds,omitempty"`
+	S3Bucket        string              `json:"s3_bucket"`
+	S3Key           string              `json:"s3_key"`
+	Blurhash        *string             `json:"blurhash,omitempty"`
+	Thumbhash       *string             `json:"thumbhash,omitempty"` // base64; images only
+	Variants        []AttachmentVariant `json:"variants,omitempty"`
+	SHA256          string              `json:"sha256,omitempty"` // of the uploaded bytes; set on upload
+	AltText         *string             `json:"alt_text,omitempty"`
+	NSFW            bool                `json:"nsfw"`
+	Spoiler         bool                `json:"spoiler"` // served blurred until revealed
+	Description     *string             `json:"description,omitempty"`
+	InstanceID      *string             `json:"instance_id,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+}
+
+// AttachmentVariant is a resized rendition of an image attachment. The
+// variants column lists them so clients can pick the smallest size and the
+// best format they can display.
+type AttachmentVariant struct {
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Format      string `json:"format"` // avif, webp or jpeg
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	URL         string `json:"url"`
 }
 
 // MediaTag represents a guild-scoped tag for categorizing attachments.
@@ -520,15 +790,365 @@ type GuildBan struct {
 // CustomEmoji represents a custom emoji uploaded to a guild. Corresponds to the
 // custom_emoji table.
 type CustomEmoji struct {
-	ID        string    `json:"id"`
-	GuildID   string    `json:"guild_id"`
-	Name      string    `json:"name"`
-	CreatorID *string   `json:"creator_id,omitempty"`
-	Animated  bool      `json:"animated"`
-	S3Key     string    `json:"s3_key"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         string    `json:"id"`
+	GuildID    string    `json:"guild_id,omitempty"` // empty for instance and remote emoji
+	Name       string    `json:"name"`
+	CreatorID  *string   `json:"creator_id,omitempty"`
+	Animated   bool      `json:"animated"`
+	S3Key      string    `json:"s3_key,omitempty"`
+	ImageHash  *string   `json:"image_hash,omitempty"`
+	Flagged    bool      `json:"flagged,omitempty"`
+	FlagReason *string   `json:"flag_reason,omitempty"`
+	Origin     string    `json:"origin"`                // EmojiOrigin*
+	FileID     *string   `json:"file_id,omitempty"`     // instance and remote emoji
+	InstanceID *string   `json:"instance_id,omitempty"` // remote emoji; fetch through the media proxy
+	URL        string    `json:"url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Emoji origins, for CustomEmoji.Origin.
+const (
+	EmojiOriginGuild    = "guild"    // a guild's custom_emoji
+	EmojiOriginInstance = "instance" // instance_emoji, usable everywhere
+	EmojiOriginRemote   = "remote"   // a federation peer's instance emoji
+)
+
+// EmojiQuotaTier is the number of emoji slots guilds at a boost tier get.
+// Guilds use the highest tier at or below their own. Corresponds to the
+// emoji_quota_tiers table.
+type EmojiQuotaTier struct {
+	BoostTier   int       `json:"boost_tier"`
+	MaxStatic   int       `json:"max_static"`
+	MaxAnimated int       `json:"max_animated"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EmojiQuota is a guild's emoji limits and current usage. Override is true
+// when an instance admin has set the limits for this guild directly.
+type EmojiQuota struct {
+	GuildID      string `json:"guild_id"`
+	BoostTier    int    `json:"boost_tier"`
+	MaxStatic    int    `json:"max_static"`
+	MaxAnimated  int    `json:"max_animated"`
+	StaticUsed   int    `json:"static_used"`
+	AnimatedUsed int    `json:"animated_used"`
+	Override     bool   `json:"override"`
+}
+
+// GuildDefaults is the template HandleCreateGuild applies to new guilds,
+// configured by instance admins and stored as JSON under the guild_defaults
+// instance setting. A nil DefaultPermissions or Channels keeps the built-in
+// default for that part. FollowChannelIDs are announcement channels every new
+// guild follows, such as platform news; this applies to guilds created from
+// a template too.
+type GuildDefaults struct {
+	DefaultPermissions *int64                `json:"default_permissions"`
+	Channels           []GuildDefaultChannel `json:"channels"`
+	Roles              []GuildDefaultRole    `json:"roles"`
+	FollowChannelIDs   []string              `json:"follow_channel_ids"`
+}
+
+// GuildDefaultChannel is a channel every new guild starts with.
+type GuildDefaultChannel struct {
+	Name        string  `json:"name"`
+	ChannelType string  `json:"channel_type"`
+	Topic       *string `json:"topic,omitempty"`
+}
+
+// GuildDefaultRole is a role every new guild starts with, in addition to
+// @everyone. Roles are stacked above @everyone in list order.
+type GuildDefaultRole struct {
+	Name             string  `json:"name"`
+	Color            *string `json:"color,omitempty"`
+	Hoist            bool    `json:"hoist"`
+	Mentionable      bool    `json:"mentionable"`
+	PermissionsAllow int64   `json:"permissions_allow"`
+	PermissionsDeny  int64   `json:"permissions_deny"`
+}
+
+// InstanceBranding is how white-labeled clients present the instance,
+// configured by instance admins and stored as JSON under the branding
+// instance setting. Empty fields leave the client's own default in place.
+type InstanceBranding struct {
+	ProductName  string         `json:"product_name,omitempty"`
+	LogoURL      string         `json:"logo_url,omitempty"`
+	IconURL      string         `json:"icon_url,omitempty"`
+	Colors       BrandingColors `json:"colors"`
+	LoginTitle   string         `json:"login_title,omitempty"`
+	LoginMessage string         `json:"login_message,omitempty"`
+	Links        []BrandingLink `json:"links"`
+}
+
+// BrandingColors are theme colors as #rrggbb.
+type BrandingColors struct {
+	Primary    string `json:"primary,omitempty"`
+	Accent     string `json:"accent,omitempty"`
+	Background string `json:"background,omitempty"`
+	Text       string `json:"text,omitempty"`
+}
+
+// BrandingLink is a link shown on the login page and in the client footer,
+// such as terms of service or a status page.
+type BrandingLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// PublicInstance is what GET /api/v1/instance tells clients before login.
+type PublicInstance struct {
+	Domain              string           `json:"domain"`
+	Name                string           `json:"name"`
+	Description         *string          `json:"description"`
+	SoftwareVersion     string           `json:"software_version"`
+	RegistrationMode    string           `json:"registration_mode"`
+	RegistrationMessage string           `json:"registration_message,omitempty"`
+	MinClientVersion    string           `json:"min_client_version,omitempty"`
+	MOTD                string           `json:"motd,omitempty"`
+	Branding            InstanceBranding `json:"branding"`
+	// SigningKey is the hex Ed25519 public key that signs webhook and
+	// interaction requests from this instance.
+	SigningKey string `json:"signing_key,omitempty"`
+}
+
+// MaintenanceWindow is a scheduled period of instance maintenance. While a
+// read-only window is active the API rejects writes. Corresponds to the
+// maintenance_windows table.
+type MaintenanceWindow struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Message     string     `json:"message"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      time.Time  `json:"ends_at"`
+	ReadOnly    bool       `json:"read_only"`
+	CreatedBy   *string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+}
+
+// Active reports whether the window covers now.
+func (w MaintenanceWindow) Active(now time.Time) bool {
+	return w.CancelledAt == nil && !now.Before(w.StartsAt) && now.Before(w.EndsAt)
+}
+
+// Subsystems an operator can switch off at runtime.
+const (
+	SubsystemRegistration = "registration"
+	SubsystemMessaging    = "messaging"
+	SubsystemUploads      = "uploads"
+	SubsystemFederation   = "federation"
+)
+
+// DisabledSubsystems records which subsystems an operator has switched off
+// to shed load or contain an incident. Stored as JSON in instance_settings.
+type DisabledSubsystems struct {
+	Registration bool   `json:"registration"`
+	Messaging    bool   `json:"messaging"`
+	Uploads      bool   `json:"uploads"`
+	Federation   bool   `json:"federation"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// Disabled reports whether the named subsystem is switched off.
+func (d DisabledSubsystems) Disabled(subsystem string) bool {
+	switch subsystem {
+	case SubsystemRegistration:
+		return d.Registration
+	case SubsystemMessaging:
+		return d.Messaging
+	case SubsystemUploads:
+		return d.Uploads
+	case SubsystemFederation:
+		return d.Federation
+	}
+	return false
 }
 
+// Names returns the switched-off subsystems in a fixed order.
+func (d DisabledSubsystems) Names() []string {
+	names := []string{}
+	for _, s := range []string{SubsystemRegistration, SubsystemMessaging, SubsystemUploads, SubsystemFederation} {
+		if d.Disabled(s) {
+			names = append(names, s)
+		}
+	}
+	return names
+}
+
+// Kinds of target a feature flag rolls out to.
+const (
+	FeatureFlagTargetUser     = "user"
+	FeatureFlagTargetGuild    = "guild"
+	FeatureFlagTargetInstance = "instance" // a federation peer
+)
+
+// FeatureFlag stages a risky feature: it is rolled out to a percentage of
+// users, guilds and peer instances, with per-target overrides, and can be
+// switched off everywhere at once.
+type FeatureFlag struct {
+	Key             string                `json:"key"`
+	Description     string                `json:"description"`
+	Enabled         bool                  `json:"enabled"`
+	UserPercent     int                   `json:"user_percent"`
+	GuildPercent    int                   `json:"guild_percent"`
+	InstancePercent int                   `json:"instance_percent"`
+	Overrides       []FeatureFlagOverride `json:"overrides"`
+	CreatedAt       time.Time             `json:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+}
+
+// FeatureFlagOverride turns a flag on or off for one target regardless of
+// the rollout percentages.
+type FeatureFlagOverride struct {
+	TargetType string    `json:"target_type"`
+	TargetID   string    `json:"target_id"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FeatureFlagState is the set of flags enabled for a user, as sent to
+// clients: those on for the user themselves, and per guild those on for the
+// guild but not already on for the user.
+type FeatureFlagState struct {
+	Flags  []string            `json:"flags"`
+	Guilds map[string][]string `json:"guilds"`
+}
+
+// InstanceStatus is what GET /api/v1/instance/status reports.
+type InstanceStatus struct {
+	// Status is "ok", "maintenance" or "read_only".
+	Status      string              `json:"status"`
+	MOTD        string              `json:"motd,omitempty"`
+	Maintenance *MaintenanceWindow  `json:"maintenance"`
+	Upcoming    []MaintenanceWindow `json:"upcoming"`
+	// DisabledSubsystems names the subsystems switched off by an operator.
+	DisabledSubsystems []string `json:"disabled_subsystems"`
+}
+
+// BoostTierPerks are the limits guilds at a boost tier get. Guilds use the
+// highest tier at or below their own; nil limits fall back to the instance
+// maximum. Corresponds to the boost_tier_perks table.
+type BoostTierPerks struct {
+	BoostTier      int       `json:"boost_tier"`
+	MaxUploadBytes *int64    `json:"max_upload_bytes"`
+	MaxBitrate     *int      `json:"max_bitrate"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Supporter entitlement sources.
+const (
+	SupporterSourceManual = "manual"
+	SupporterSourceStripe = "stripe"
+	SupporterSourceKofi   = "kofi"
+)
+
+// SupporterEntitlement grants a user boost slots while the instance requires
+// supporters to boost. Corresponds to the supporter_entitlements table.
+type SupporterEntitlement struct {
+	UserID     string     `json:"user_id"`
+	BoostSlots int        `json:"boost_slots"`
+	SlotsUsed  int        `json:"slots_used"`
+	Source     string     `json:"source"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	GrantedBy  *string    `json:"granted_by,omitempty"`
+	Note       *string    `json:"note,omitempty"`
+	ExternalID *string    `json:"external_id,omitempty"` // provider subscription or payer
+	// AdminOverride keeps payment webhooks and reconciliation from changing
+	// or removing the entitlement.
+	AdminOverride bool      `json:"admin_override"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Payment event outcomes.
+const (
+	PaymentOutcomeGranted   = "granted"
+	PaymentOutcomeRevoked   = "revoked"
+	PaymentOutcomeIgnored   = "ignored"
+	PaymentOutcomeUnmatched = "unmatched"
+)
+
+// PaymentEvent is a payment provider webhook delivery and what it did.
+// Corresponds to the payment_events table.
+type PaymentEvent struct {
+	Provider   string    `json:"provider"`
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	UserID     *string   `json:"user_id,omitempty"`
+	Outcome    string    `json:"outcome"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Instance policy kinds.
+const (
+	PolicyKindTerms = "terms"
+	PolicyKindRules = "rules"
+)
+
+// InstancePolicy is one published version of the instance's terms of service
+// or rules. AcknowledgedAt is filled in for the requesting user when they have
+// acknowledged this version or a later one. Corresponds to the
+// instance_policies table.
+type InstancePolicy struct {
+	ID                     string     `json:"id"`
+	Kind                   string     `json:"kind"`
+	Version                int        `json:"version"`
+	Title                  string     `json:"title"`
+	Content                string     `json:"content"`
+	RequiresAcknowledgment bool       `json:"requires_acknowledgment"`
+	PublishedBy            *string    `json:"published_by,omitempty"`
+	PublishedAt            time.Time  `json:"published_at"`
+	AcknowledgedAt         *time.Time `json:"acknowledged_at,omitempty"`
+}
+
+// PolicyAcknowledgment records a user accepting a policy version.
+// Corresponds to the instance_policy_acknowledgments table.
+type PolicyAcknowledgment struct {
+	UserID         string    `json:"user_id"`
+	Username       string    `json:"username,omitempty"`
+	PolicyID       string    `json:"policy_id"`
+	Kind           string    `json:"kind"`
+	Version        int       `json:"version"`
+	AcknowledgedAt time.Time `json:"acknowledged_at"`
+	IPAddress      *string   `json:"ip_address,omitempty"`
+}
+
+// GuildTag is a moderator-defined canned response posted with /tag <name>.
+// Content may contain {user}, {channel} and {guild} placeholders.
+// Corresponds to the guild_tags table.
+type GuildTag struct {
+	ID              string     `json:"id"`
+	GuildID         string     `json:"guild_id"`
+	Name            string     `json:"name"`
+	Content         string     `json:"content"`
+	CooldownSeconds int        `json:"cooldown_seconds"`
+	Uses            int64      `json:"uses"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	CreatorID       *string    `json:"creator_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// ChannelJoinRequest is a member's request to be let into a private channel
+// that accepts join requests. Corresponds to the channel_join_requests table.
+type ChannelJoinRequest struct {
+	ID         string     `json:"id"`
+	ChannelID  string     `json:"channel_id"`
+	GuildID    string     `json:"guild_id"`
+	UserID     string     `json:"user_id"`
+	Message    *string    `json:"message,omitempty"`
+	Status     string     `json:"status"`
+	ReviewerID *string    `json:"reviewer_id,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ChannelJoinRequest status constants.
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestDenied   = "denied"
+)
+
 // Webhook represents an incoming or outgoing webhook for a guild channel.
 // Corresponds to the webhooks table.
 type Webhook struct {
@@ -551,6 +1171,406 @@ const (
 	WebhookTypeOutgoing = "outgoing"
 )
 
+// GuildWebhookPolicy limits the per-message name and avatar overrides that
+// incoming webhook executions may set in a guild.
+// Corresponds to the guild_webhook_policy table.
+type GuildWebhookPolicy struct {
+	GuildID             string    `json:"guild_id"`
+	AllowNameOverride   bool      `json:"allow_name_override"`
+	AllowAvatarOverride bool      `json:"allow_avatar_override"`
+	BlockMemberNames    bool      `json:"block_member_names"`
+	RequireTag          bool      `json:"require_tag"`
+	Tag                 string    `json:"tag"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// DefaultWebhookPolicy is the policy of a guild that has not configured one.
+func DefaultWebhookPolicy(guildID string) GuildWebhookPolicy {
+	return GuildWebhookPolicy{
+		GuildID:             guildID,
+		AllowNameOverride:   true,
+		AllowAvatarOverride: true,
+		BlockMemberNames:    true,
+		Tag:                 "[BOT]",
+	}
+}
+
+// GuildEditPolicy limits how members may edit their messages in a guild. A
+// guild without one has no limits. Corresponds to the guild_edit_policy
+// table.
+type GuildEditPolicy struct {
+	GuildID           string    `json:"guild_id"`
+	EditWindowMinutes int       `json:"edit_window_minutes"` // 0 for no limit
+	SnapshotEdits     bool      `json:"snapshot_edits"`      // record each edit in the audit log
+	LockReported      bool      `json:"lock_reported"`       // refuse edits while a report is pending
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// GuildJoinOriginPolicy limits which instances new members of a guild may
+// come from. A guild without one is open. Corresponds to the
+// guild_join_origin_policy table.
+type GuildJoinOriginPolicy struct {
+	GuildID        string    `json:"guild_id"`
+	Mode           string    `json:"mode"`            // one of the JoinOrigin constants
+	AllowedDomains []string  `json:"allowed_domains"` // peers accepted in allowlist mode
+	BlockedDomains []string  `json:"blocked_domains"` // peers refused in every mode
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// JoinOrigin constants for guild_join_origin_policy.mode.
+const (
+	JoinOriginOpen      = "open"
+	JoinOriginLocalOnly = "local_only" // only users of the guild's own instance
+	JoinOriginAllowlist = "allowlist"  // local users and users of AllowedDomains
+)
+
+// ChannelWebhookContent limits what incoming webhooks may post in a channel.
+// Attachments covers the images a webhook can attach through embeds.
+// Corresponds to the channel_webhook_content table.
+type ChannelWebhookContent struct {
+	ChannelID        string     `json:"channel_id"`
+	AllowAttachments bool       `json:"allow_attachments"`
+	AllowMentions    bool       `json:"allow_mentions"`
+	EmbedsOnly       bool       `json:"embeds_only"`
+	UpdatedAt        *time.Time `json:"updated_at"`
+}
+
+// DefaultChannelWebhookContent is the rule set of a channel that has not
+// configured one: webhooks may post anything.
+func DefaultChannelWebhookContent(channelID string) ChannelWebhookContent {
+	return ChannelWebhookContent{
+		ChannelID:        channelID,
+		AllowAttachments: true,
+		AllowMentions:    true,
+	}
+}
+
+// ChannelAdaptiveSlowmode scales a channel's slowmode with its message
+// velocity: the number of messages posted in the last WindowSeconds.
+// Corresponds to the channel_adaptive_slowmode table.
+type ChannelAdaptiveSlowmode struct {
+	ChannelID     string     `json:"channel_id"`
+	Enabled       bool       `json:"enabled"`
+	WindowSeconds int        `json:"window_seconds"`
+	LowThreshold  int        `json:"low_threshold"`
+	HighThreshold int        `json:"high_threshold"`
+	MinSeconds    int        `json:"min_seconds"`
+	MaxSeconds    int        `json:"max_seconds"`
+	Curve         string     `json:"curve"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
+
+// Curves for ChannelAdaptiveSlowmode.Curve, shaping how the cooldown grows
+// between the low and high thresholds.
+const (
+	AdaptiveCurveLinear  = "linear"
+	AdaptiveCurveEaseIn  = "ease_in"  // slow start, steep near the high threshold
+	AdaptiveCurveEaseOut = "ease_out" // steep start, flattening out
+)
+
+// DefaultChannelAdaptiveSlowmode is the disabled configuration of a channel
+// that has never set one.
+func DefaultChannelAdaptiveSlowmode(channelID string) ChannelAdaptiveSlowmode {
+	return ChannelAdaptiveSlowmode{
+		ChannelID:     channelID,
+		WindowSeconds: 60,
+		LowThreshold:  20,
+		HighThreshold: 120,
+		MinSeconds:    2,
+		MaxSeconds:    30,
+		Curve:         AdaptiveCurveLinear,
+	}
+}
+
+// Message sources, as counted in message_source_daily and limited per
+// channel by ChannelSourceRateLimit.
+const (
+	MessageSourceHuman   = "human"
+	MessageSourceBot     = "bot"
+	MessageSourceWebhook = "webhook"
+	MessageSourceBridge  = "bridge"
+)
+
+// ValidMessageSource reports whether source is a known message source.
+func ValidMessageSource(source string) bool {
+	switch source {
+	case MessageSourceHuman, MessageSourceBot, MessageSourceWebhook, MessageSourceBridge:
+		return true
+	}
+	return false
+}
+
+// MessageSourceOf classifies a message posted by a user account with the
+// given flags. A bot posting under a masquerade is relaying someone else's
+// message, which is how the bridge adapters post.
+func MessageSourceOf(userFlags int, masquerade bool) string {
+	switch {
+	case userFlags&UserFlagBot == 0:
+		return MessageSourceHuman
+	case masquerade:
+		return MessageSourceBridge
+	default:
+		return MessageSourceBot
+	}
+}
+
+// ChannelSourceRateLimit caps how many messages one source may post in a
+// channel within a sliding window, across all authors of that source.
+// Corresponds to the channel_source_rate_limits table.
+type ChannelSourceRateLimit struct {
+	ChannelID     string    `json:"channel_id"`
+	Source        string    `json:"source"`
+	MaxMessages   int       `json:"max_messages"`
+	WindowSeconds int       `json:"window_seconds"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ChannelDuplicatePolicy decides what happens when a member sends the same
+// text twice in a row within WindowSeconds. Corresponds to the
+// channel_duplicate_policy table.
+type ChannelDuplicatePolicy struct {
+	ChannelID     string     `json:"channel_id"`
+	Mode          string     `json:"mode"`
+	WindowSeconds int        `json:"window_seconds"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
+
+// Modes for ChannelDuplicatePolicy.Mode.
+const (
+	DuplicateModeOff      = "off"
+	DuplicateModeCollapse = "collapse" // count the repeat on the earlier message
+	DuplicateModeReject   = "reject"   // refuse the repeat
+)
+
+// DefaultChannelDuplicatePolicy is the policy of a channel that has never
+// set one.
+func DefaultChannelDuplicatePolicy(channelID string) ChannelDuplicatePolicy {
+	return ChannelDuplicatePolicy{
+		ChannelID:     channelID,
+		Mode:          DuplicateModeOff,
+		WindowSeconds: 300,
+	}
+}
+
+// GuildBroadcast is an announcement a guild owner sends to the DMs of
+// members who opted in. Corresponds to the guild_broadcasts table; the
+// delivery counts are tallied from guild_broadcast_deliveries.
+type GuildBroadcast struct {
+	ID             string     `json:"id"`
+	GuildID        string     `json:"guild_id"`
+	AuthorID       string     `json:"author_id"`
+	Content        string     `json:"content"`
+	Status         string     `json:"status"`
+	RecipientCount int        `json:"recipient_count"`
+	DeliveredCount int        `json:"delivered_count"`
+	SkippedCount   int        `json:"skipped_count"`
+	FailedCount    int        `json:"failed_count"`
+	CreatedAt      time.Time  `json:"created_at"`
+	StartedAt      *time.Time `json:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+}
+
+// Statuses for GuildBroadcast.Status.
+const (
+	BroadcastStatusPending   = "pending"
+	BroadcastStatusSending   = "sending"
+	BroadcastStatusCompleted = "completed"
+	BroadcastStatusCancelled = "cancelled"
+)
+
+// GuildScheduledPurge is a cleanup a guild scheduled ahead of time: deleting
+// a category with its channels, or removing a role from every member.
+// Corresponds to the guild_scheduled_purges table. A purge with RepeatDays
+// goes back to pending, that many days later, after each run.
+type GuildScheduledPurge struct {
+	ID         string     `json:"id"`
+	GuildID    string     `json:"guild_id"`
+	Kind       string     `json:"kind"`
+	TargetID   string     `json:"target_id"`
+	RunAt      time.Time  `json:"run_at"`
+	RepeatDays *int       `json:"repeat_days"`
+	Status     string     `json:"status"`
+	Affected   int        `json:"affected"`
+	Error      *string    `json:"error,omitempty"`
+	CreatedBy  *string    `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastRunAt  *time.Time `json:"last_run_at"`
+}
+
+// Kinds and statuses for GuildScheduledPurge.
+const (
+	PurgeKindCategory = "category"
+	PurgeKindRole     = "role"
+
+	PurgeStatusPending   = "pending"
+	PurgeStatusCompleted = "completed"
+	PurgeStatusFailed    = "failed"
+)
+
+// GuildEmojiAlias is an extra :shortcode: a guild maps to a unicode emoji.
+// Corresponds to the guild_emoji_aliases table.
+type GuildEmojiAlias struct {
+	GuildID   string    `json:"guild_id"`
+	Alias     string    `json:"alias"`
+	Emoji     string    `json:"emoji"`
+	CreatedBy *string   `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Community groups several guilds under shared branding, a shared ban list
+// and shared roles. Corresponds to the communities table.
+type Community struct {
+	ID           string           `json:"id"`
+	OwnerID      string           `json:"owner_id"`
+	Name         string           `json:"name"`
+	Description  *string          `json:"description,omitempty"`
+	IconID       *string          `json:"icon_id,omitempty"`
+	BannerID     *string          `json:"banner_id,omitempty"`
+	AccentColor  *string          `json:"accent_color,omitempty"`
+	Discoverable bool             `json:"discoverable"`
+	CreatedAt    time.Time        `json:"created_at"`
+	Guilds       []CommunityGuild `json:"guilds,omitempty"`
+}
+
+// CommunityGuild is a guild in a community, or invited to join one.
+// Corresponds to the community_guilds table.
+type CommunityGuild struct {
+	GuildID     string    `json:"guild_id"`
+	CommunityID string    `json:"community_id"`
+	Status      string    `json:"status"`
+	Name        string    `json:"name"`
+	IconID      *string   `json:"icon_id,omitempty"`
+	MemberCount int       `json:"member_count"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+// Statuses for CommunityGuild.
+const (
+	CommunityGuildPending = "pending"
+	CommunityGuildActive  = "active"
+)
+
+// CommunityRole is a role defined once for a community and mirrored into
+// each of its guilds. Corresponds to the community_roles table.
+type CommunityRole struct {
+	ID               string    `json:"id"`
+	CommunityID      string    `json:"community_id"`
+	Name             string    `json:"name"`
+	Color            *string   `json:"color,omitempty"`
+	PermissionsAllow int64     `json:"permissions_allow"`
+	PermissionsDeny  int64     `json:"permissions_deny"`
+	MemberCount      int       `json:"member_count"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// CommunityBan bans a user from every guild in a community.
+// Corresponds to the community_bans table.
+type CommunityBan struct {
+	CommunityID string    `json:"community_id"`
+	UserID      string    `json:"user_id"`
+	Reason      *string   `json:"reason,omitempty"`
+	BannedBy    *string   `json:"banned_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	User        *User     `json:"user,omitempty"`
+}
+
+// BroadcastLimits are the instance-wide caps on guild broadcasts,
+// configured by instance admins and stored as JSON under the
+// broadcast_limits instance setting.
+type BroadcastLimits struct {
+	Enabled             bool `json:"enabled"`
+	PerGuildPerDay      int  `json:"per_guild_per_day"`
+	MaxRecipients       int  `json:"max_recipients"`
+	DeliveriesPerMinute int  `json:"deliveries_per_minute"`
+}
+
+// ParseBroadcastLimits reads the broadcast_limits setting. Fields missing
+// from raw, or all of them if raw is empty or unreadable, keep their
+// defaults.
+func ParseBroadcastLimits(raw string) BroadcastLimits {
+	l := BroadcastLimits{
+		Enabled:             true,
+		PerGuildPerDay:      1,
+		MaxRecipients:       5000,
+		DeliveriesPerMinute: 60,
+	}
+	if raw != "" {
+		parsed := l
+		if json.Unmarshal([]byte(raw), &parsed) == nil {
+			l = parsed
+		}
+	}
+	return l
+}
+
+// UserContentSettings are a user's NSFW filters, enforced by the server.
+// Corresponds to the user_content_settings table.
+type UserContentSettings struct {
+	ShowNSFW       bool       `json:"show_nsfw"`
+	NSFWMedia      string     `json:"nsfw_media"`
+	SafeSearch     bool       `json:"safe_search"`
+	AgeConfirmedAt *time.Time `json:"age_confirmed_at"`
+	UpdatedAt      *time.Time `json:"updated_at"`
+}
+
+// Modes for UserContentSettings.NSFWMedia.
+const (
+	NSFWMediaShow = "show"
+	NSFWMediaBlur = "blur" // served with a blur hint for the client
+	NSFWMediaHide = "hide" // refused
+)
+
+// DefaultUserContentSettings are the settings of a user who has never
+// changed them.
+func DefaultUserContentSettings() UserContentSettings {
+	return UserContentSettings{NSFWMedia: NSFWMediaBlur, SafeSearch: true}
+}
+
+// MediaMode is how NSFW media is served to the user. Users who have not
+// opted in to NSFW content never get it, whatever NSFWMedia says.
+func (s UserContentSettings) MediaMode() string {
+	if !s.ShowNSFW {
+		return NSFWMediaHide
+	}
+	return s.NSFWMedia
+}
+
+// ProfilePrivacy is which optional profile fields a user shares with remote
+// instances. Members of the local instance always see the whole profile.
+// Corresponds to the user_profile_privacy table.
+type ProfilePrivacy struct {
+	Bio         string     `json:"bio"`
+	Pronouns    string     `json:"pronouns"`
+	Banner      string     `json:"banner"`
+	Connections string     `json:"connections"` // profile links
+	Activity    string     `json:"activity"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+}
+
+// Visibilities for ProfilePrivacy fields.
+const (
+	ProfileVisibilityEveryone = "everyone" // local members and remote instances
+	ProfileVisibilityLocal    = "local"    // local members only
+)
+
+// ValidProfileVisibility reports whether v is a known profile visibility.
+func ValidProfileVisibility(v string) bool {
+	return v == ProfileVisibilityEveryone || v == ProfileVisibilityLocal
+}
+
+// DefaultProfilePrivacy is the privacy of a user who has never changed it:
+// pronouns and banner are shared, while the bio, connections and activity,
+// which say more about the person, stay local.
+func DefaultProfilePrivacy() ProfilePrivacy {
+	return ProfilePrivacy{
+		Bio:         ProfileVisibilityLocal,
+		Pronouns:    ProfileVisibilityEveryone,
+		Banner:      ProfileVisibilityEveryone,
+		Connections: ProfileVisibilityLocal,
+		Activity:    ProfileVisibilityLocal,
+	}
+}
+
 // AuditLogEntry represents an administrative action recorded for auditing purposes.
 // Corresponds to the audit_log table.
 // Audit log action constants for categorizing guild events.
@@ -591,6 +1611,187 @@ type AuditLogEntry struct {
 	CreatedAt  time.Time              `json:"created_at"`
 }
 
+// InstanceAuditEntry records an instance-level staff action. Corresponds to
+// the append-only instance_audit_log table.
+type InstanceAuditEntry struct {
+	ID         string          `json:"id"`
+	ActorID    string          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType *string         `json:"target_type,omitempty"`
+	TargetID   *string         `json:"target_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Reason     *string         `json:"reason,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Instance audit action constants for instance_audit_log.action.
+const (
+	StaffActionUserSuspend           = "user_suspend"
+	StaffActionUserUnsuspend         = "user_unsuspend"
+	StaffActionUserInstanceBan       = "user_instance_ban"
+	StaffActionUserInstanceUnban     = "user_instance_unban"
+	StaffActionUserSetAdmin          = "user_set_admin"
+	StaffActionUserSetGlobalMod      = "user_set_globalmod"
+	StaffActionInstanceUpdate        = "instance_update"
+	StaffActionRegistrationUpdate    = "registration_update"
+	StaffActionFederationPeerAdd     = "federation_peer_add"
+	StaffActionFederationPeerRemove  = "federation_peer_remove"
+	StaffActionFederationPeerControl = "federation_peer_control"
+	StaffActionFederationPeerApprove = "federation_peer_approve"
+	StaffActionFederationPeerReject  = "federation_peer_reject"
+	StaffActionFederationPeerTrust   = "federation_peer_trust"
+	StaffActionFederationSandbox     = "federation_sandbox"
+	StaffActionSandboxClear          = "federation_sandbox_clear"
+	StaffActionDirectoryRefresh      = "federation_directory_refresh"
+	StaffActionReportResolve         = "report_resolve"
+	StaffActionInstanceRoleCreate    = "instance_role_create"
+	StaffActionInstanceRoleUpdate    = "instance_role_update"
+	StaffActionInstanceRoleDelete    = "instance_role_delete"
+	StaffActionInstanceRoleAssign    = "instance_role_assign"
+	StaffActionInstanceRoleRevoke    = "instance_role_revoke"
+	StaffActionGuildDelete           = "guild_delete"
+	StaffActionMediaDelete           = "media_delete"
+	StaffActionAppealResolve         = "suspension_appeal_resolve"
+	StaffActionQuarantine            = "user_quarantine"
+	StaffActionQuarantineRelease     = "user_quarantine_release"
+	StaffActionQuarantineConfirm     = "user_quarantine_confirm"
+	StaffActionStreamPurge           = "jetstream_stream_purge"
+	StaffActionConsumerReplay        = "jetstream_consumer_replay"
+	StaffActionEmojiTierUpdate       = "emoji_tier_update"
+	StaffActionEmojiTierDelete       = "emoji_tier_delete"
+	StaffActionGuildEmojiQuota       = "guild_emoji_quota"
+	StaffActionBoostTierUpdate       = "boost_tier_update"
+	StaffActionBoostTierDelete       = "boost_tier_delete"
+	StaffActionSupporterGrant        = "supporter_grant"
+	StaffActionSupporterRevoke       = "supporter_revoke"
+	StaffActionPolicyPublish         = "policy_publish"
+	StaffActionRetentionUpdate       = "instance_retention_update"
+	StaffActionRetentionPurge        = "instance_retention_purge"
+	StaffActionLegalHoldCreate       = "legal_hold_create"
+	StaffActionLegalHoldRelease      = "legal_hold_release"
+	StaffActionAutomodRuleCreate     = "instance_automod_rule_create"
+	StaffActionAutomodRuleUpdate     = "instance_automod_rule_update"
+	StaffActionAutomodRuleDelete     = "instance_automod_rule_delete"
+	StaffActionLinkBlocklistAdd      = "link_blocklist_add"
+	StaffActionLinkBlocklistRemove   = "link_blocklist_remove"
+	StaffActionFeatureFlagUpdate     = "feature_flag_update"
+	StaffActionFeatureFlagDelete     = "feature_flag_delete"
+	StaffActionFeatureOverrideSet    = "feature_flag_override_set"
+	StaffActionFeatureOverrideDelete = "feature_flag_override_delete"
+	StaffActionAlertWebhookCreate    = "alert_webhook_create"
+	StaffActionAlertWebhookUpdate    = "alert_webhook_update"
+	StaffActionAlertWebhookDelete    = "alert_webhook_delete"
+	StaffActionInstanceEmojiCreate   = "instance_emoji_create"
+	StaffActionInstanceEmojiUpdate   = "instance_emoji_update"
+	StaffActionInstanceEmojiDelete   = "instance_emoji_delete"
+	StaffActionSCIMUserActivate      = "scim_user_activate"
+	StaffActionSCIMUserDeactivate    = "scim_user_deactivate"
+	StaffActionSCIMTokenCreate       = "scim_token_create"
+	StaffActionSCIMTokenDelete       = "scim_token_delete"
+	StaffActionSCIMMappingCreate     = "scim_mapping_create"
+	StaffActionSCIMMappingDelete     = "scim_mapping_delete"
+	StaffActionRegTokenCreate        = "registration_token_create"
+	StaffActionRegTokenDelete        = "registration_token_delete"
+	StaffActionAnnouncementCreate    = "announcement_create"
+	StaffActionAnnouncementUpdate    = "announcement_update"
+	StaffActionAnnouncementDelete    = "announcement_delete"
+	StaffActionContentScanRuleCreate = "content_scan_rule_create"
+	StaffActionContentScanRuleUpdate = "content_scan_rule_update"
+	StaffActionContentScanRuleDelete = "content_scan_rule_delete"
+	StaffActionFederationKeyAck      = "federation_key_acknowledge"
+	StaffActionFederationRetry       = "federation_delivery_retry"
+	StaffActionBridgeCreate          = "bridge_create"
+	StaffActionBridgeUpdate          = "bridge_update"
+	StaffActionBridgeDelete          = "bridge_delete"
+	StaffActionBridgeMappingCreate   = "bridge_mapping_create"
+	StaffActionBridgeMappingDelete   = "bridge_mapping_delete"
+	StaffActionMatrixGuildAdd        = "matrix_guild_add"
+	StaffActionMatrixGuildRemove     = "matrix_guild_remove"
+	StaffActionRetentionPolicyCreate = "retention_policy_create"
+	StaffActionRetentionPolicyUpdate = "retention_policy_update"
+	StaffActionRetentionPolicyDelete = "retention_policy_delete"
+	StaffActionCustomDomainCreate    = "custom_domain_create"
+	StaffActionCustomDomainVerify    = "custom_domain_verify"
+	StaffActionCustomDomainDelete    = "custom_domain_delete"
+	StaffActionBackupScheduleCreate  = "backup_schedule_create"
+	StaffActionBackupScheduleUpdate  = "backup_schedule_update"
+	StaffActionBackupScheduleDelete  = "backup_schedule_delete"
+	StaffActionBackupTrigger         = "backup_trigger"
+)
+
+// SuspensionAppeal is a suspended user's request for staff to lift their
+// suspension. Corresponds to the suspension_appeals table.
+type SuspensionAppeal struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Message    string     `json:"message"`
+	Status     string     `json:"status"`
+	Response   *string    `json:"response,omitempty"`
+	ReviewedBy *string    `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// UserQuarantine is a shadow quarantine placed on a suspected spam account.
+// Corresponds to the user_quarantines table.
+type UserQuarantine struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Source     string     `json:"source"`
+	Reason     *string    `json:"reason,omitempty"`
+	GuildID    *string    `json:"guild_id,omitempty"`
+	Status     string     `json:"status"`
+	CreatedBy  *string    `json:"created_by,omitempty"`
+	ReviewedBy *string    `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Quarantine source and status constants.
+const (
+	QuarantineSourceDMSpam  = "dm_spam"
+	QuarantineSourceAutoMod = "automod"
+	QuarantineSourceManual  = "manual"
+
+	QuarantineStatusPending   = "pending"
+	QuarantineStatusReleased  = "released"
+	QuarantineStatusConfirmed = "confirmed"
+)
+
+// Suspension appeal status constants.
+const (
+	AppealStatusPending  = "pending"
+	AppealStatusAccepted = "accepted"
+	AppealStatusRejected = "rejected"
+)
+
+// GuildImport is a job that recreates content exported from another platform
+// in a guild. Corresponds to the guild_imports table.
+type GuildImport struct {
+	ID          string          `json:"id"`
+	GuildID     string          `json:"guild_id"`
+	RequestedBy *string         `json:"requested_by,omitempty"`
+	Source      string          `json:"source"`
+	Status      string          `json:"status"`
+	Options     json.RawMessage `json:"options"`
+	ArchiveID   *string         `json:"archive_id,omitempty"`
+	Progress    json.RawMessage `json:"progress"`
+	Error       *string         `json:"error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// Guild import status constants.
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+	ImportStatusCancelled = "cancelled"
+)
+
 // FederationPeer represents a federation relationship between two instances.
 // Corresponds to the federation_peers table.
 type FederationPeer struct {
@@ -608,6 +1809,119 @@ const (
 	FederationPeerPending = "pending"
 )
 
+// PeerTrust constants for federation_peers.trust_level. Restricted peers have
+// their media proxied without caching, their users cannot create invites or
+// DM local users without approval, and their messages get stricter AutoMod.
+const (
+	PeerTrustTrusted    = "trusted"
+	PeerTrustNormal     = "normal"
+	PeerTrustRestricted = "restricted"
+)
+
+// FederatedDMRequest is a DM from a user on a restricted peer waiting for a
+// local recipient to accept it. Corresponds to federation_dm_requests.
+type FederatedDMRequest struct {
+	ID               string    `json:"id"`
+	RemoteInstanceID string    `json:"remote_instance_id"`
+	InstanceDomain   string    `json:"instance_domain"`
+	RequesterID      string    `json:"requester_id"`
+	Requester        *User     `json:"requester,omitempty"`
+	ChannelType      string    `json:"channel_type"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Group DM privacy levels, stored as the group_dm_privacy user setting.
+// They decide who can add the user to a group DM without an invite.
+const (
+	GroupDMPrivacyEveryone = "everyone"
+	GroupDMPrivacyFriends  = "friends"
+	GroupDMPrivacyNobody   = "nobody"
+)
+
+// GroupDMInvite is a pending invite to a group DM for a user whose group DM
+// privacy keeps the inviter from adding them directly. Corresponds to
+// group_dm_invites.
+type GroupDMInvite struct {
+	ID          string    `json:"id"`
+	ChannelID   string    `json:"channel_id"`
+	ChannelName *string   `json:"channel_name,omitempty"`
+	InviterID   string    `json:"inviter_id"`
+	Inviter     *User     `json:"inviter,omitempty"`
+	TargetID    string    `json:"target_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// FederationSandboxEvent is a federated event held in the sandbox: an
+// outbound event not delivered because of dry-run mode, or an inbound event
+// from a sandbox peer. Corresponds to federation_sandbox_events.
+type FederationSandboxEvent struct {
+	ID        string          `json:"id"`
+	Direction string          `json:"direction"`
+	PeerID    *string         `json:"peer_id,omitempty"`
+	Domain    string          `json:"domain"`
+	EventType string          `json:"event_type"`
+	GuildID   *string         `json:"guild_id,omitempty"`
+	ChannelID *string         `json:"channel_id,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// FederationRejection is an inbound federation request refused before it was
+// processed: a bad signature, an unknown sender or a timestamp outside the
+// allowed clock skew. Corresponds to federation_rejections.
+type FederationRejection struct {
+	ID         string    `json:"id"`
+	SenderID   string    `json:"sender_id"`
+	Domain     *string   `json:"domain,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	Endpoint   string    `json:"endpoint"`
+	Reason     string    `json:"reason"`
+	Detail     *string   `json:"detail,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ChannelTranscriptLink shares a channel's messages up to SnapshotAt, either
+// with guild members ("members") or with anyone holding the link ("link"),
+// until it expires or is revoked. Corresponds to channel_transcript_links.
+type ChannelTranscriptLink struct {
+	ID             string     `json:"id"`
+	Code           string     `json:"code"`
+	ChannelID      string     `json:"channel_id"`
+	CreatedBy      string     `json:"created_by"`
+	Visibility     string     `json:"visibility"`
+	SnapshotAt     time.Time  `json:"snapshot_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	AccessCount    int        `json:"access_count"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ChannelTranscriptAccess is one view of a transcript link. ViewerID is nil
+// for viewers who were not signed in. Corresponds to
+// channel_transcript_accesses.
+type ChannelTranscriptAccess struct {
+	ID         string    `json:"id"`
+	LinkID     string    `json:"link_id"`
+	ViewerID   *string   `json:"viewer_id,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	AccessedAt time.Time `json:"accessed_at"`
+}
+
+// LegalHold exempts a user or guild from message purges and freezes exports
+// of its data until released. Corresponds to the legal_holds table.
+type LegalHold struct {
+	ID         string     `json:"id"`
+	TargetType string     `json:"target_type"`
+	TargetID   string     `json:"target_id"`
+	TargetName *string    `json:"target_name,omitempty"`
+	Reason     string     `json:"reason"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReleasedBy *string    `json:"released_by,omitempty"`
+	ReleasedAt *time.Time `json:"released_at,omitempty"`
+}
+
 // ReadState tracks a user's read position in a channel for unread indicators.
 // Corresponds to the read_state table.
 type ReadState struct {
@@ -718,6 +2032,62 @@ type MessageReport struct {
 	Message    *Message   `json:"message,omitempty"`
 }
 
+// LinkVerdict is why one link in a message was judged malicious.
+type LinkVerdict struct {
+	URL    string `json:"url"`
+	Threat string `json:"threat"` // e.g. MALWARE, SOCIAL_ENGINEERING, BLOCKLISTED
+	Source string `json:"source"` // LinkSourceBlocklist or LinkSourceSafeBrowsing
+}
+
+// Sources of a LinkVerdict.
+const (
+	LinkSourceBlocklist    = "blocklist"
+	LinkSourceSafeBrowsing = "safe_browsing"
+)
+
+// MessageLinkFlag queues a message whose malicious links were rewritten for
+// review by the guild's moderators.
+type MessageLinkFlag struct {
+	ID         string        `json:"id"`
+	GuildID    string        `json:"guild_id"`
+	ChannelID  string        `json:"channel_id"`
+	MessageID  string        `json:"message_id"`
+	AuthorID   string        `json:"author_id"`
+	Verdicts   []LinkVerdict `json:"verdicts"`
+	Status     string        `json:"status"`
+	ResolvedBy *string       `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time    `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// LinkBlocklistEntry is a domain, and its subdomains, blocked on the
+// instance.
+type LinkBlocklistEntry struct {
+	Domain    string    `json:"domain"`
+	Reason    *string   `json:"reason,omitempty"`
+	CreatedBy *string   `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OperatorAlertWebhook is an outbound webhook that receives operational
+// alerts for the instance operators. Corresponds to the
+// operator_alert_webhooks table.
+type OperatorAlertWebhook struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	URL             string     `json:"url"`
+	Format          string     `json:"format"`       // "json", "slack" or "matrix"
+	MinSeverity     string     `json:"min_severity"` // "info", "warning" or "critical"
+	Kinds           []string   `json:"kinds"`        // empty for every kind
+	SigningSecret   string     `json:"signing_secret,omitempty"`
+	Enabled         bool       `json:"enabled"`
+	CreatedBy       *string    `json:"created_by,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at,omitempty"`
+	LastStatus      *int       `json:"last_status,omitempty"`
+	LastError       *string    `json:"last_error,omitempty"`
+}
+
 // GuildRaidConfig stores raid protection settings for a guild.
 type GuildRaidConfig struct {
 	GuildID           string     `json:"guild_id"`
@@ -750,10 +2120,18 @@ type BotToken struct {
 	TokenHash  string     `json:"-"` // never expose hash
 	Name       string     `json:"name"`
 	Token      string     `json:"token,omitempty"` // only set on creation response
+	Scope      string     `json:"scope"`
 	CreatedAt  time.Time  `json:"created_at"`
 	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
 }
 
+// Bot token scopes for bot_tokens.scope. User-app tokens may only answer
+// interactions.
+const (
+	BotTokenScopeBot     = "bot"
+	BotTokenScopeUserApp = "user_app"
+)
+
 // SlashCommand represents a bot-registered slash command. Commands can be
 // global (guild_id is nil) or guild-scoped. Corresponds to the slash_commands table.
 type SlashCommand struct {
@@ -763,10 +2141,45 @@ type SlashCommand struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	Options     json.RawMessage `json:"options"`
+	Context     string          `json:"context"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
 }
 
+// Command contexts for slash_commands.context. Guild commands are offered
+// where the bot is a member; DM commands are offered in the DMs of users
+// who installed the app.
+const (
+	CommandContextGuild = "guild"
+	CommandContextDM    = "dm"
+)
+
+// UserAppInstall records an app a user installed to their account.
+// Corresponds to the user_app_installs table.
+type UserAppInstall struct {
+	BotID       string    `json:"bot_id"`
+	Username    string    `json:"username"`
+	DisplayName *string   `json:"display_name,omitempty"`
+	AvatarID    *string   `json:"avatar_id,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// Interaction is a slash command invocation delivered to a bot.
+// Corresponds to the interactions table.
+type Interaction struct {
+	ID          string          `json:"id"`
+	BotID       string          `json:"bot_id"`
+	UserID      string          `json:"user_id"`
+	ChannelID   string          `json:"channel_id"`
+	GuildID     *string         `json:"guild_id,omitempty"`
+	CommandID   string          `json:"command_id"`
+	CommandName string          `json:"command_name"`
+	Context     string          `json:"context"`
+	Options     json.RawMessage `json:"options"`
+	CreatedAt   time.Time       `json:"created_at"`
+	RespondedAt *time.Time      `json:"responded_at,omitempty"`
+}
+
 // ChannelTemplate represents a saved channel configuration that can be reused
 // when creating new channels in a guild. Corresponds to the channel_templates table.
 type ChannelTemplate struct {
@@ -813,6 +2226,7 @@ const (
 	BotScopeRolesManage    = "roles.manage"
 	BotScopeWebhooksManage = "webhooks.manage"
 	BotScopeEventsManage   = "events.manage"
+	BotScopeMessagesImport = "messages.import"
 )
 
 // ValidBotScopes is the set of recognized bot permission scopes.
@@ -824,6 +2238,7 @@ var ValidBotScopes = map[string]bool{
 	BotScopeRolesManage:    true,
 	BotScopeWebhooksManage: true,
 	BotScopeEventsManage:   true,
+	BotScopeMessagesImport: true,
 }
 
 // MessageComponent represents an interactive UI element attached to a message
@@ -882,6 +2297,98 @@ type BotRateLimit struct {
 	UpdatedAt         time.Time `json:"updated_at"`
 }
 
+// BotInteractionEndpoint is the URL an app receives signed interaction
+// POSTs at. Corresponds to the bot_interaction_endpoints table.
+type BotInteractionEndpoint struct {
+	BotID      string    `json:"bot_id"`
+	URL        string    `json:"url"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// BotListing is a bot's entry in the instance's application directory.
+// Corresponds to the bot_listings table.
+type BotListing struct {
+	BotID            string     `json:"bot_id"`
+	Username         string     `json:"username"`
+	DisplayName      *string    `json:"display_name,omitempty"`
+	AvatarID         *string    `json:"avatar_id,omitempty"`
+	ShortDescription string     `json:"short_description"`
+	Description      string     `json:"description"`
+	Permissions      int64      `json:"permissions"`
+	Scopes           []string   `json:"scopes"`
+	Tags             []string   `json:"tags"`
+	WebsiteURL       *string    `json:"website_url,omitempty"`
+	SupportURL       *string    `json:"support_url,omitempty"`
+	ReviewStatus     string     `json:"review_status"`
+	ReviewNote       *string    `json:"review_note,omitempty"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty"`
+	InstallCount     int        `json:"install_count"`
+	UserInstallable  bool       `json:"user_installable"`
+	InstallURL       string     `json:"install_url"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Bot listing review statuses for bot_listings.review_status.
+const (
+	BotListingUnlisted = "unlisted"
+	BotListingPending  = "pending"
+	BotListingApproved = "approved"
+	BotListingRejected = "rejected"
+)
+
+// BotInstall records a bot installed into a guild from the directory.
+// Corresponds to the bot_installs table.
+type BotInstall struct {
+	BotID       string    `json:"bot_id"`
+	GuildID     string    `json:"guild_id"`
+	Username    string    `json:"username"`
+	InstalledBy *string   `json:"installed_by,omitempty"`
+	RoleID      *string   `json:"role_id,omitempty"`
+	Permissions int64     `json:"permissions"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// BotMessageContentAccess is a bot's request for the privileged message
+// content capability. Corresponds to the bot_message_content_access table.
+type BotMessageContentAccess struct {
+	BotID       string     `json:"bot_id"`
+	Username    string     `json:"username"`
+	Status      string     `json:"status"`
+	Reason      string     `json:"reason,omitempty"`
+	ReviewNote  *string    `json:"review_note,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	RequestedAt *time.Time `json:"requested_at,omitempty"`
+}
+
+// Message content access statuses. MessageContentNone is reported for bots
+// that never asked and is not stored.
+const (
+	MessageContentNone     = "none"
+	MessageContentPending  = "pending"
+	MessageContentApproved = "approved"
+	MessageContentDenied   = "denied"
+)
+
+// BotPresenceSubscription is a user a bot asked to receive presence for
+// outside shared guilds. Updates reach the bot only once the user grants it.
+// Corresponds to the bot_presence_subscriptions table.
+type BotPresenceSubscription struct {
+	BotID       string     `json:"bot_id"`
+	UserID      string     `json:"user_id"`
+	Status      string     `json:"status"`
+	RequestedAt time.Time  `json:"requested_at"`
+	GrantedAt   *time.Time `json:"granted_at,omitempty"`
+	Bot         *User      `json:"bot,omitempty"`
+	User        *User      `json:"user,omitempty"`
+}
+
+// Bot presence subscription statuses.
+const (
+	BotPresencePending = "pending"
+	BotPresenceGranted = "granted"
+)
+
 // UserReport represents a report filed against a user by another user.
 // Corresponds to the user_reports table.
 type UserReport struct {
@@ -1010,6 +2517,7 @@ const (
 	NotifTypeThreadReply    = "thread_reply"
 	NotifTypeMessagePinned  = "message_pinned"
 	NotifTypeReactionAdded  = "reaction_added"
+	NotifTypeReactionDigest = "reaction_digest"
 	NotifTypeFriendRequest  = "friend_request"
 	NotifTypeFriendAccepted = "friend_accepted"
 	NotifTypeGuildInvite    = "guild_invite"
@@ -1021,6 +2529,8 @@ const (
 	NotifTypeReportResolved = "report_resolved"
 	NotifTypeEventStarting  = "event_starting"
 	NotifTypeAnnouncement   = "announcement"
+	NotifTypeDNDSummary     = "dnd_summary"
+	NotifTypeSecurityAlert  = "security_alert"
 )
 
 // Notification category constants.
@@ -1034,11 +2544,11 @@ const (
 // NotificationCategoryForType returns the category for a given notification type.
 func NotificationCategoryForType(notifType string) string {
 	switch notifType {
-	case NotifTypeMention, NotifTypeReply, NotifTypeDM, NotifTypeThreadReply, NotifTypeMessagePinned, NotifTypeReactionAdded:
+	case NotifTypeMention, NotifTypeReply, NotifTypeDM, NotifTypeThreadReply, NotifTypeMessagePinned, NotifTypeReactionAdded, NotifTypeReactionDigest:
 		return NotifCategoryMessages
 	case NotifTypeFriendRequest, NotifTypeFriendAccepted, NotifTypeGuildInvite, NotifTypeMemberJoined:
 		return NotifCategorySocial
-	case NotifTypeWarned, NotifTypeMuted, NotifTypeKicked, NotifTypeBanned, NotifTypeReportResolved:
+	case NotifTypeWarned, NotifTypeMuted, NotifTypeKicked, NotifTypeBanned, NotifTypeReportResolved, NotifTypeSecurityAlert:
 		return NotifCategoryModeration
 	case NotifTypeEventStarting, NotifTypeAnnouncement:
 		return NotifCategoryContent
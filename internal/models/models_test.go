@@ -1,8 +1,11 @@
 package models
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
+
+	"github.com/amityvox/amityvox/internal/cdn"
 )
 
 func TestUserFlags(t *testing.T) {
@@ -145,3 +148,135 @@ func TestRelationshipConstants(t *testing.T) {
 
 func timePtr(t time.Time) *time.Time { return &t }
 func intPtr(n int) *int              { return &n }
+
+func TestDisabledSubsystems(t *testing.T) {
+	d := DisabledSubsystems{Messaging: true, Federation: true}
+	if !d.Disabled(SubsystemMessaging) || d.Disabled(SubsystemUploads) || d.Disabled("unknown") {
+		t.Errorf("Disabled() does not match the toggles in %+v", d)
+	}
+	got := d.Names()
+	if len(got) != 2 || got[0] != SubsystemMessaging || got[1] != SubsystemFederation {
+		t.Errorf("Names() = %v, want [messaging federation]", got)
+	}
+	if names := (DisabledSubsystems{}).Names(); names == nil || len(names) != 0 {
+		t.Errorf("Names() with nothing disabled = %#v, want empty slice", names)
+	}
+}
+
+func TestParseBroadcastLimits(t *testing.T) {
+	defaults := ParseBroadcastLimits("")
+	if !defaults.Enabled || defaults.PerGuildPerDay != 1 || defaults.DeliveriesPerMinute != 60 {
+		t.Errorf("ParseBroadcastLimits(\"\") = %+v, want the defaults", defaults)
+	}
+	if got := ParseBroadcastLimits("not json"); got != defaults {
+		t.Errorf("unreadable setting = %+v, want the defaults", got)
+	}
+	got := ParseBroadcastLimits(`{"enabled":false,"max_recipients":100}`)
+	want := defaults
+	want.Enabled = false
+	want.MaxRecipients = 100
+	if got != want {
+		t.Errorf("partial setting = %+v, want %+v", got, want)
+	}
+}
+
+func TestUserContentSettings_MediaMode(t *testing.T) {
+	s := DefaultUserContentSettings()
+	if got := s.MediaMode(); got != NSFWMediaHide {
+		t.Errorf("default MediaMode() = %q, want %q", got, NSFWMediaHide)
+	}
+	s.ShowNSFW = true
+	if got := s.MediaMode(); got != NSFWMediaBlur {
+		t.Errorf("opted-in MediaMode() = %q, want %q", got, NSFWMediaBlur)
+	}
+	s.NSFWMedia = NSFWMediaShow
+	if got := s.MediaMode(); got != NSFWMediaShow {
+		t.Errorf("MediaMode() = %q, want %q", got, NSFWMediaShow)
+	}
+}
+
+func TestMessageSourceOf(t *testing.T) {
+	tests := []struct {
+		flags      int
+		masquerade bool
+		want       string
+	}{
+		{0, false, MessageSourceHuman},
+		{UserFlagVerified, false, MessageSourceHuman},
+		{UserFlagBot, false, MessageSourceBot},
+		{UserFlagBot | UserFlagVerified, true, MessageSourceBridge},
+	}
+	for _, tt := range tests {
+		if got := MessageSourceOf(tt.flags, tt.masquerade); got != tt.want {
+			t.Errorf("MessageSourceOf(%d, %v) = %q, want %q", tt.flags, tt.masquerade, got, tt.want)
+		}
+	}
+	for _, s := range []string{MessageSourceHuman, MessageSourceBot, MessageSourceWebhook, MessageSourceBridge} {
+		if !ValidMessageSource(s) {
+			t.Errorf("ValidMessageSource(%q) = false", s)
+		}
+	}
+	if ValidMessageSource("system") {
+		t.Error(`ValidMessageSource("system") = true`)
+	}
+}
+
+func TestMarshalJSONAddsMediaURLs(t *testing.T) {
+	cdn.Configure(cdn.New("https://chat.example.com", "local"))
+	defer cdn.Configure(nil)
+
+	avatar := "a1"
+	email := "me@example.com"
+	user := User{ID: "u1", InstanceID: "local", Username: "me", AvatarID: &avatar, Email: &email}
+
+	var got map[string]interface{}
+	b, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(b, &got)
+	if got["avatar_url"] != "https://chat.example.com/api/v1/files/a1" {
+		t.Errorf("avatar_url = %v", got["avatar_url"])
+	}
+	if _, ok := got["banner_url"]; ok {
+		t.Error("banner_url is set without a banner")
+	}
+	if _, ok := got["email"]; ok {
+		t.Error("email leaked into the public user")
+	}
+
+	got = nil
+	b, err = json.Marshal(user.ToSelf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(b, &got)
+	if got["email"] != email || got["username"] != "me" || got["avatar_url"] == nil {
+		t.Errorf("self user = %s, want the user with its email and avatar_url", b)
+	}
+
+	width := 100
+	got = nil
+	b, _ = json.Marshal(Attachment{ID: "f1", ContentType: "image/png", Width: &width})
+	json.Unmarshal(b, &got)
+	if got["url"] != "https://chat.example.com/api/v1/files/f1" ||
+		got["thumbnail_url"] != "https://chat.example.com/api/v1/files/f1/thumbnail?size=256" {
+		t.Errorf("attachment = %s", b)
+	}
+}
+
+func TestMarshalJSONWithoutBuilder(t *testing.T) {
+	icon := "i1"
+	b, err := json.Marshal(Guild{ID: "g1", IconID: &icon})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(b, &got)
+	if _, ok := got["icon_url"]; ok {
+		t.Errorf("icon_url is set with resolved URLs off: %s", b)
+	}
+	if got["icon_id"] != "i1" {
+		t.Errorf("icon_id = %v, want i1", got["icon_id"])
+	}
+}
@@ -0,0 +1,108 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// pushCoalesceWindow is how long pushes to one user about one channel are
+// held and folded into a single summary after the first is sent.
+const pushCoalesceWindow = 30 * time.Second
+
+// pushGroup is one user's open coalescing window for one collapse key.
+type pushGroup struct {
+	held   int         // pushes held since the window opened
+	latest PushPayload // most recent held push
+}
+
+// pushCoalescer folds bursts of pushes into summaries. The first push for a
+// user and collapse key is sent at once; any that follow within the window
+// are held and sent as one "N new messages" push when it closes, after which
+// a new window opens if anything was sent. Pushes without a collapse key are
+// never held.
+type pushCoalescer struct {
+	window time.Duration
+	send   func(userID string, p PushPayload)
+
+	mu     sync.Mutex
+	groups map[string]*pushGroup
+}
+
+func newPushCoalescer(window time.Duration, send func(userID string, p PushPayload)) *pushCoalescer {
+	return &pushCoalescer{window: window, send: send, groups: make(map[string]*pushGroup)}
+}
+
+// add sends p now or holds it for the user's open window.
+func (c *pushCoalescer) add(userID string, p PushPayload) {
+	if p.CollapseKey == "" {
+		c.send(userID, p)
+		return
+	}
+	key := userID + "|" + p.CollapseKey
+
+	c.mu.Lock()
+	if g, ok := c.groups[key]; ok {
+		g.held++
+		g.latest = p
+		c.mu.Unlock()
+		return
+	}
+	c.groups[key] = &pushGroup{}
+	c.mu.Unlock()
+
+	c.send(userID, p)
+	time.AfterFunc(c.window, func() { c.flush(userID, key) })
+}
+
+// flush closes a window, sending what it held.
+func (c *pushCoalescer) flush(userID, key string) {
+	c.mu.Lock()
+	g := c.groups[key]
+	if g == nil || g.held == 0 {
+		delete(c.groups, key)
+		c.mu.Unlock()
+		return
+	}
+	summary := summarizePush(g.latest, g.held)
+	g.held = 0
+	c.mu.Unlock()
+
+	c.send(userID, summary)
+	time.AfterFunc(c.window, func() { c.flush(userID, key) })
+}
+
+// summarizePush turns the latest of count held pushes into one push that
+// stands for all of them. A single held push is sent unchanged.
+func summarizePush(latest PushPayload, count int) PushPayload {
+	if count <= 1 {
+		return latest
+	}
+	p := latest
+	p.Count = count
+	switch {
+	case latest.GroupName != "":
+		p.Title = fmt.Sprintf("%d new messages in %s", count, latest.GroupName)
+	case latest.ChannelID != "":
+		p.Title = fmt.Sprintf("%d new messages", count)
+	default:
+		p.Title = fmt.Sprintf("%d new notifications", count)
+	}
+	if latest.Title != "" && latest.Body != "" {
+		p.Body = latest.Title + ": " + latest.Body
+	}
+	return p
+}
+
+// pushNow sends a push under its own deadline, since held pushes go out
+// after whatever queued them has finished.
+func (s *Service) pushNow(userID string, p PushPayload) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.SendToUser(ctx, userID, p); err != nil {
+		s.logger.Debug("push delivery failed",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+}
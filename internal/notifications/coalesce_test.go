@@ -0,0 +1,61 @@
+package notifications
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPushCoalescer(t *testing.T) {
+	var mu sync.Mutex
+	var sent []PushPayload
+	c := newPushCoalescer(50*time.Millisecond, func(userID string, p PushPayload) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, p)
+	})
+
+	msg := PushPayload{Title: "alice in #general", Body: "hi", ChannelID: "ch1", CollapseKey: "ch1", GroupName: "#general"}
+	for i := 0; i < 5; i++ {
+		c.add("user1", msg)
+	}
+	c.add("user1", PushPayload{Title: "Friend request"})
+
+	mu.Lock()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d pushes before the window closed, want 2 (first message, ungrouped)", len(sent))
+	}
+	mu.Unlock()
+
+	time.Sleep(120 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 3 {
+		t.Fatalf("sent %d pushes after the window closed, want 3", len(sent))
+	}
+	summary := sent[2]
+	if summary.Count != 4 || summary.Title != "4 new messages in #general" {
+		t.Errorf("summary = %d %q, want 4 \"4 new messages in #general\"", summary.Count, summary.Title)
+	}
+}
+
+func TestSummarizePushSingle(t *testing.T) {
+	p := PushPayload{Title: "alice", Body: "hi", CollapseKey: "ch1"}
+	if got := summarizePush(p, 1); got != p {
+		t.Errorf("summarizePush(p, 1) = %+v, want p unchanged", got)
+	}
+}
+
+func TestValidTopic(t *testing.T) {
+	tests := map[string]bool{
+		"01HXYZABCDEFGHJKMNPQRSTVWX":         true,
+		"":                                   false,
+		"has space":                          false,
+		"0123456789012345678901234567890123": false,
+	}
+	for key, want := range tests {
+		if got := validTopic(key); got != want {
+			t.Errorf("validTopic(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
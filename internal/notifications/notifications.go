@@ -11,6 +11,7 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	webpush "github.com/SherClockHolmes/webpush-go"
@@ -18,9 +19,11 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/auth"
 	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/presence"
 )
 
 // Notification levels.
@@ -32,14 +35,18 @@ const (
 
 // PushSubscription represents a browser/device push subscription.
 type PushSubscription struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Endpoint  string    `json:"endpoint"`
-	KeyP256dh string    `json:"key_p256dh"`
-	KeyAuth   string    `json:"key_auth"`
-	UserAgent string    `json:"user_agent,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	LastUsed  time.Time `json:"last_used"`
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	Endpoint       string     `json:"endpoint"`
+	KeyP256dh      string     `json:"key_p256dh"`
+	KeyAuth        string     `json:"key_auth"`
+	UserAgent      string     `json:"user_agent,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsed       time.Time  `json:"last_used"`
+	FailureCount   int        `json:"failure_count"` // consecutive failed deliveries
+	LastFailureAt  *time.Time `json:"last_failure_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	VAPIDPublicKey string     `json:"vapid_public_key,omitempty"` // key the subscription is bound to
 }
 
 // NotificationPreferences holds a user's notification settings for a guild (or global).
@@ -50,6 +57,9 @@ type NotificationPreferences struct {
 	SuppressHere      bool       `json:"suppress_here"`
 	SuppressRoles     bool       `json:"suppress_roles"`
 	MutedUntil        *time.Time `json:"muted_until,omitempty"`
+	// Reactions is whose reactions to the user's messages notify them:
+	// all, friends or off. It is only set on global preferences.
+	Reactions string `json:"reactions,omitempty"`
 }
 
 // PushPayload is the JSON structure sent in push notifications.
@@ -62,38 +72,57 @@ type PushPayload struct {
 	ChannelID string `json:"channel_id,omitempty"`
 	GuildID   string `json:"guild_id,omitempty"`
 	MessageID string `json:"message_id,omitempty"`
+
+	// Grouping. Pushes sharing a collapse key replace each other at the push
+	// service and are coalesced here; Tag lets the service worker replace the
+	// notification on screen.
+	CollapseKey string `json:"collapse_key,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+	GroupName   string `json:"group_name,omitempty"` // e.g. "#general", used in summaries
+	Count       int    `json:"count,omitempty"`      // pushes this one stands for, when coalesced
 }
 
 // Service manages push subscriptions and sends WebPush notifications.
 type Service struct {
-	pool       *pgxpool.Pool
-	logger     *slog.Logger
-	vapidPub   string
-	vapidPriv  string
-	vapidEmail string
-	bus        *events.Bus
+	pool          *pgxpool.Pool
+	logger        *slog.Logger
+	vapidPub      string
+	vapidPriv     string
+	vapidPrevPub  string
+	vapidPrevPriv string
+	vapidEmail    string
+	bus           *events.Bus
+	pushes        *pushCoalescer
 }
 
 // Config holds configuration for the notification service.
 type Config struct {
-	Pool             *pgxpool.Pool
-	Logger           *slog.Logger
-	VAPIDPublicKey   string
-	VAPIDPrivateKey  string
+	Pool              *pgxpool.Pool
+	Logger            *slog.Logger
+	VAPIDPublicKey    string
+	VAPIDPrivateKey   string
 	VAPIDContactEmail string
-	Bus              *events.Bus
+	// The previous VAPID key pair stays valid for subscriptions created with
+	// it until they resubscribe under the current pair.
+	VAPIDPreviousPublicKey  string
+	VAPIDPreviousPrivateKey string
+	Bus                     *events.Bus
 }
 
 // NewService creates a new notification service.
 func NewService(cfg Config) *Service {
-	return &Service{
-		pool:       cfg.Pool,
-		logger:     cfg.Logger,
-		vapidPub:   cfg.VAPIDPublicKey,
-		vapidPriv:  cfg.VAPIDPrivateKey,
-		vapidEmail: cfg.VAPIDContactEmail,
-		bus:        cfg.Bus,
-	}
+	s := &Service{
+		pool:          cfg.Pool,
+		logger:        cfg.Logger,
+		vapidPub:      cfg.VAPIDPublicKey,
+		vapidPriv:     cfg.VAPIDPrivateKey,
+		vapidPrevPub:  cfg.VAPIDPreviousPublicKey,
+		vapidPrevPriv: cfg.VAPIDPreviousPrivateKey,
+		vapidEmail:    cfg.VAPIDContactEmail,
+		bus:           cfg.Bus,
+	}
+	s.pushes = newPushCoalescer(pushCoalesceWindow, s.pushNow)
+	return s
 }
 
 // Enabled returns true if VAPID keys are configured.
@@ -104,7 +133,8 @@ func (s *Service) Enabled() bool {
 // --- Push Subscription Handlers ---
 
 // HandleSubscribe handles POST /api/v1/notifications/subscriptions.
-// Registers a new push subscription for the authenticated user.
+// Registers a new push subscription for the authenticated user. The keys may
+// be sent flat or in the "keys" object of a browser PushSubscription.
 func (s *Service) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 
@@ -112,27 +142,52 @@ func (s *Service) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 		Endpoint  string `json:"endpoint"`
 		KeyP256dh string `json:"key_p256dh"`
 		KeyAuth   string `json:"key_auth"`
+		Keys      struct {
+			P256dh string `json:"p256dh"`
+			Auth   string `json:"auth"`
+		} `json:"keys"`
+		// VAPIDPublicKey is the applicationServerKey the browser subscribed
+		// with. Empty means the current key.
+		VAPIDPublicKey string `json:"vapid_public_key"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
 		return
 	}
+	if req.KeyP256dh == "" {
+		req.KeyP256dh = req.Keys.P256dh
+	}
+	if req.KeyAuth == "" {
+		req.KeyAuth = req.Keys.Auth
+	}
 
 	if req.Endpoint == "" || req.KeyP256dh == "" || req.KeyAuth == "" {
 		writeError(w, http.StatusBadRequest, "missing_fields", "endpoint, key_p256dh, and key_auth are required")
 		return
 	}
+	if req.VAPIDPublicKey == "" {
+		req.VAPIDPublicKey = s.vapidPub
+	}
+	if _, ok := s.vapidPrivateKey(req.VAPIDPublicKey); !ok {
+		writeError(w, http.StatusBadRequest, "unknown_vapid_key", "Subscription uses a VAPID key this server does not hold; resubscribe with the current key")
+		return
+	}
 
 	id := models.NewULID().String()
-	_, err := s.pool.Exec(r.Context(),
-		`INSERT INTO push_subscriptions (id, user_id, endpoint, key_p256dh, key_auth, user_agent, created_at, last_used)
-		 VALUES ($1, $2, $3, $4, $5, $6, now(), now())
+	err := s.pool.QueryRow(r.Context(),
+		`INSERT INTO push_subscriptions (id, user_id, endpoint, key_p256dh, key_auth, user_agent, vapid_public_key, created_at, last_used)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
 		 ON CONFLICT (user_id, endpoint) DO UPDATE SET
 		   key_p256dh = EXCLUDED.key_p256dh,
 		   key_auth = EXCLUDED.key_auth,
-		   last_used = now()`,
-		id, userID, req.Endpoint, req.KeyP256dh, req.KeyAuth, r.UserAgent(),
-	)
+		   vapid_public_key = EXCLUDED.vapid_public_key,
+		   failure_count = 0,
+		   last_failure_at = NULL,
+		   last_error = NULL,
+		   last_used = now()
+		 RETURNING id`,
+		id, userID, req.Endpoint, req.KeyP256dh, req.KeyAuth, r.UserAgent(), req.VAPIDPublicKey,
+	).Scan(&id)
 	if err != nil {
 		s.logger.Error("failed to store push subscription", slog.String("error", err.Error()))
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to register subscription")
@@ -140,23 +195,26 @@ func (s *Service) HandleSubscribe(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeJSON(w, http.StatusCreated, PushSubscription{
-		ID:        id,
-		UserID:    userID,
-		Endpoint:  req.Endpoint,
-		KeyP256dh: req.KeyP256dh,
-		KeyAuth:   req.KeyAuth,
-		UserAgent: r.UserAgent(),
-		CreatedAt: time.Now().UTC(),
-		LastUsed:  time.Now().UTC(),
+		ID:             id,
+		UserID:         userID,
+		Endpoint:       req.Endpoint,
+		KeyP256dh:      req.KeyP256dh,
+		KeyAuth:        req.KeyAuth,
+		UserAgent:      r.UserAgent(),
+		CreatedAt:      time.Now().UTC(),
+		LastUsed:       time.Now().UTC(),
+		VAPIDPublicKey: req.VAPIDPublicKey,
 	})
 }
 
 // HandleListSubscriptions handles GET /api/v1/notifications/subscriptions.
+// Lists the user's registered devices with their delivery health.
 func (s *Service) HandleListSubscriptions(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
 
 	rows, err := s.pool.Query(r.Context(),
-		`SELECT id, user_id, endpoint, key_p256dh, key_auth, user_agent, created_at, last_used
+		`SELECT id, user_id, endpoint, key_p256dh, key_auth, user_agent, created_at, last_used,
+		        failure_count, last_failure_at, last_error, vapid_public_key
 		 FROM push_subscriptions WHERE user_id = $1 ORDER BY created_at DESC`,
 		userID,
 	)
@@ -169,13 +227,20 @@ func (s *Service) HandleListSubscriptions(w http.ResponseWriter, r *http.Request
 	subs := []PushSubscription{}
 	for rows.Next() {
 		var sub PushSubscription
-		var ua *string
-		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.Endpoint, &sub.KeyP256dh, &sub.KeyAuth, &ua, &sub.CreatedAt, &sub.LastUsed); err != nil {
+		var ua, lastError, vapidKey *string
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.Endpoint, &sub.KeyP256dh, &sub.KeyAuth, &ua, &sub.CreatedAt, &sub.LastUsed,
+			&sub.FailureCount, &sub.LastFailureAt, &lastError, &vapidKey); err != nil {
 			continue
 		}
 		if ua != nil {
 			sub.UserAgent = *ua
 		}
+		if lastError != nil {
+			sub.LastError = *lastError
+		}
+		if vapidKey != nil {
+			sub.VAPIDPublicKey = *vapidKey
+		}
 		subs = append(subs, sub)
 	}
 
@@ -221,7 +286,7 @@ func (s *Service) HandleGetPreferences(w http.ResponseWriter, r *http.Request) {
 	var prefs NotificationPreferences
 	var mutedUntil *time.Time
 
-	query := `SELECT user_id, guild_id, level, suppress_here, suppress_roles, muted_until
+	query := `SELECT user_id, guild_id, level, suppress_here, suppress_roles, muted_until, reactions
 	          FROM notification_preferences WHERE user_id = $1`
 	args := []interface{}{userID}
 	if guildID != "" {
@@ -233,19 +298,23 @@ func (s *Service) HandleGetPreferences(w http.ResponseWriter, r *http.Request) {
 
 	err := s.pool.QueryRow(r.Context(), query, args...).Scan(
 		&prefs.UserID, &prefs.GuildID, &prefs.Level,
-		&prefs.SuppressHere, &prefs.SuppressRoles, &mutedUntil,
+		&prefs.SuppressHere, &prefs.SuppressRoles, &mutedUntil, &prefs.Reactions,
 	)
 	if err == pgx.ErrNoRows {
 		// Return defaults.
 		prefs = NotificationPreferences{
-			UserID:  userID,
-			GuildID: guildID,
-			Level:   LevelMentions,
+			UserID:    userID,
+			GuildID:   guildID,
+			Level:     LevelMentions,
+			Reactions: ReactionsAll,
 		}
 	} else if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to query preferences")
 		return
 	}
+	if guildID != "" {
+		prefs.Reactions = "" // a global setting
+	}
 
 	prefs.MutedUntil = mutedUntil
 	writeJSON(w, http.StatusOK, prefs)
@@ -261,6 +330,7 @@ func (s *Service) HandleUpdatePreferences(w http.ResponseWriter, r *http.Request
 		SuppressHere *bool      `json:"suppress_here"`
 		SuppressRoles    *bool      `json:"suppress_roles"`
 		MutedUntil       *time.Time `json:"muted_until"`
+		Reactions        *string    `json:"reactions"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
@@ -291,16 +361,32 @@ func (s *Service) HandleUpdatePreferences(w http.ResponseWriter, r *http.Request
 		guildIDVal = *req.GuildID
 	}
 
-	_, err := s.pool.Exec(r.Context(),
-		`INSERT INTO notification_preferences (user_id, guild_id, level, suppress_here, suppress_roles, muted_until)
-		 VALUES ($1, $2, $3, $4, $5, $6)
+	// Reaction notifications are set once for all guilds; left out, the
+	// current setting is kept.
+	if req.Reactions != nil {
+		switch {
+		case guildIDVal != "__global__":
+			writeError(w, http.StatusBadRequest, "invalid_reactions", "Reaction notifications can only be set globally")
+			return
+		case *req.Reactions != ReactionsAll && *req.Reactions != ReactionsFriends && *req.Reactions != ReactionsOff:
+			writeError(w, http.StatusBadRequest, "invalid_reactions", "Reactions must be all, friends, or off")
+			return
+		}
+	}
+
+	var reactions string
+	err := s.pool.QueryRow(r.Context(),
+		`INSERT INTO notification_preferences (user_id, guild_id, level, suppress_here, suppress_roles, muted_until, reactions)
+		 VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, 'all'))
 		 ON CONFLICT (user_id, guild_id) DO UPDATE SET
 		   level = EXCLUDED.level,
 		   suppress_here = EXCLUDED.suppress_here,
 		   suppress_roles = EXCLUDED.suppress_roles,
-		   muted_until = EXCLUDED.muted_until`,
-		userID, guildIDVal, level, suppressHere, suppressRoles, req.MutedUntil,
-	)
+		   muted_until = EXCLUDED.muted_until,
+		   reactions = COALESCE($7, notification_preferences.reactions)
+		 RETURNING reactions`,
+		userID, guildIDVal, level, suppressHere, suppressRoles, req.MutedUntil, req.Reactions,
+	).Scan(&reactions)
 	if err != nil {
 		s.logger.Error("failed to update notification preferences", slog.String("error", err.Error()))
 		writeError(w, http.StatusInternalServerError, "internal_error", "Failed to update preferences")
@@ -310,6 +396,8 @@ func (s *Service) HandleUpdatePreferences(w http.ResponseWriter, r *http.Request
 	guildIDStr := guildIDVal
 	if guildIDStr == "__global__" {
 		guildIDStr = ""
+	} else {
+		reactions = "" // a global setting
 	}
 
 	writeJSON(w, http.StatusOK, NotificationPreferences{
@@ -319,6 +407,7 @@ func (s *Service) HandleUpdatePreferences(w http.ResponseWriter, r *http.Request
 		SuppressHere: suppressHere,
 		SuppressRoles:    suppressRoles,
 		MutedUntil:       req.MutedUntil,
+		Reactions:        reactions,
 	})
 }
 
@@ -479,68 +568,174 @@ func (s *Service) SendToUser(ctx context.Context, userID string, payload PushPay
 	}
 
 	rows, err := s.pool.Query(ctx,
-		`SELECT id, endpoint, key_p256dh, key_auth FROM push_subscriptions WHERE user_id = $1`,
+		`SELECT id, endpoint, key_p256dh, key_auth, COALESCE(vapid_public_key, '')
+		 FROM push_subscriptions WHERE user_id = $1`,
 		userID,
 	)
 	if err != nil {
 		return fmt.Errorf("querying push subscriptions: %w", err)
 	}
-	defer rows.Close()
-
-	var staleIDs []string
-
+	type target struct {
+		id, vapidKey string
+		sub          webpush.Subscription
+	}
+	var targets []target
 	for rows.Next() {
-		var id, endpoint, p256dh, authKey string
-		if err := rows.Scan(&id, &endpoint, &p256dh, &authKey); err != nil {
+		var t target
+		if err := rows.Scan(&t.id, &t.sub.Endpoint, &t.sub.Keys.P256dh, &t.sub.Keys.Auth, &t.vapidKey); err != nil {
 			continue
 		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("querying push subscriptions: %w", err)
+	}
 
-		sub := &webpush.Subscription{
-			Endpoint: endpoint,
-			Keys: webpush.Keys{
-				P256dh: p256dh,
-				Auth:   authKey,
-			},
+	topic := ""
+	if validTopic(payload.CollapseKey) {
+		topic = payload.CollapseKey
+	}
+	sent := false
+	for _, t := range targets {
+		if s.deliver(ctx, t.id, &t.sub, t.vapidKey, topic, payloadJSON) {
+			sent = true
 		}
+	}
 
-		resp, err := webpush.SendNotification(payloadJSON, sub, &webpush.Options{
-			VAPIDPublicKey:  s.vapidPub,
-			VAPIDPrivateKey: s.vapidPriv,
+	// A push counts as delivery of a DM message, like a gateway dispatch.
+	if sent && payload.GuildID == "" && payload.ChannelID != "" && payload.MessageID != "" {
+		if err := apiutil.RecordDMDelivery(ctx, s.pool, s.bus, payload.ChannelID, userID, payload.MessageID); err != nil {
+			s.logger.Debug("failed to record DM delivery",
+				slog.String("channel_id", payload.ChannelID), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// validTopic reports whether key can be sent as a Web Push Topic header,
+// which RFC 8030 limits to 32 characters of the URL-safe base64 alphabet.
+func validTopic(key string) bool {
+	if key == "" || len(key) > 32 {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// maxPushFailures is how many deliveries in a row may fail before a
+// subscription is treated as dead and removed.
+const maxPushFailures = 10
+
+// vapidPrivateKey returns the private key paired with a VAPID public key
+// this server holds: the current one or, during a rotation, the previous one.
+func (s *Service) vapidPrivateKey(pub string) (string, bool) {
+	switch {
+	case pub == "":
+		return "", false
+	case pub == s.vapidPub:
+		return s.vapidPriv, true
+	case pub == s.vapidPrevPub && s.vapidPrevPriv != "":
+		return s.vapidPrevPriv, true
+	}
+	return "", false
+}
+
+// vapidCandidates lists the public keys to sign a push with, given the key
+// the subscription is bound to. Subscriptions from before keys were recorded
+// try the current key, then the previous one.
+func (s *Service) vapidCandidates(bound string) []string {
+	if bound != "" {
+		if _, ok := s.vapidPrivateKey(bound); ok {
+			return []string{bound}
+		}
+		return nil
+	}
+	keys := []string{s.vapidPub}
+	if _, ok := s.vapidPrivateKey(s.vapidPrevPub); ok {
+		keys = append(keys, s.vapidPrevPub)
+	}
+	return keys
+}
+
+// deliver sends one push, records the outcome on the subscription and
+// reports whether the push service accepted it.
+// Endpoints the push service reports gone, and subscriptions bound to a VAPID
+// key that has been retired, are deleted; other failures are counted until
+// maxPushFailures is reached.
+func (s *Service) deliver(ctx context.Context, id string, sub *webpush.Subscription, bound, topic string, payload []byte) bool {
+	candidates := s.vapidCandidates(bound)
+	if len(candidates) == 0 {
+		s.pruneSubscription(ctx, id, "vapid key retired")
+		return false
+	}
+
+	var failure string
+	for _, pub := range candidates {
+		priv, _ := s.vapidPrivateKey(pub)
+		resp, err := webpush.SendNotification(payload, sub, &webpush.Options{
+			VAPIDPublicKey:  pub,
+			VAPIDPrivateKey: priv,
 			Subscriber:      s.vapidEmail,
 			TTL:             86400,
+			Topic:           topic,
 		})
 		if err != nil {
-			s.logger.Debug("push send failed",
-				slog.String("user_id", userID),
-				slog.String("endpoint", endpoint[:min(50, len(endpoint))]),
-				slog.String("error", err.Error()),
-			)
-			continue
+			failure = err.Error()
+			break
 		}
 		resp.Body.Close()
 
-		if resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound {
-			staleIDs = append(staleIDs, id)
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			s.pool.Exec(ctx,
+				`UPDATE push_subscriptions SET last_used = now(), failure_count = 0,
+				     last_failure_at = NULL, last_error = NULL, vapid_public_key = $2
+				 WHERE id = $1`, id, pub)
+			return true
+		case resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound:
+			s.pruneSubscription(ctx, id, resp.Status)
+			return false
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			// The push service rejected the key; the next candidate may be
+			// the one the browser subscribed with.
+			failure = resp.Status
 			continue
 		}
-
-		// Update last_used timestamp.
-		s.pool.Exec(ctx,
-			`UPDATE push_subscriptions SET last_used = now() WHERE id = $1`, id)
+		failure = resp.Status
+		break
 	}
 
-	// Clean up stale subscriptions (gone/not found).
-	for _, id := range staleIDs {
-		s.pool.Exec(ctx,
-			`DELETE FROM push_subscriptions WHERE id = $1`, id)
-		s.logger.Debug("removed stale push subscription", slog.String("id", id))
+	s.logger.Debug("push send failed",
+		slog.String("subscription_id", id),
+		slog.String("endpoint", sub.Endpoint[:min(50, len(sub.Endpoint))]),
+		slog.String("error", failure),
+	)
+	var failures int
+	err := s.pool.QueryRow(ctx,
+		`UPDATE push_subscriptions SET failure_count = failure_count + 1,
+		     last_failure_at = now(), last_error = $2
+		 WHERE id = $1 RETURNING failure_count`, id, failure).Scan(&failures)
+	if err == nil && failures >= maxPushFailures {
+		s.pruneSubscription(ctx, id, fmt.Sprintf("%d consecutive failures", failures))
 	}
+	return false
+}
 
-	return nil
+// pruneSubscription deletes a subscription that can no longer be delivered to.
+func (s *Service) pruneSubscription(ctx context.Context, id, reason string) {
+	s.pool.Exec(ctx, `DELETE FROM push_subscriptions WHERE id = $1`, id)
+	s.logger.Debug("removed dead push subscription",
+		slog.String("id", id), slog.String("reason", reason))
 }
 
 // ShouldNotify checks if a user should receive a notification for this event based
-// on their notification preferences. Resolution order: Channel > Guild > Global > Default(mentions).
+// on their notification preferences. Resolution order: Channel > Guild > the
+// channel's default level (its own or its category's) > Global > Default(mentions).
 func (s *Service) ShouldNotify(ctx context.Context, userID, guildID, channelID string, isMention, isDM, isHere bool) bool {
 	// Check channel-level preferences first (most specific).
 	if channelID != "" {
@@ -597,6 +792,17 @@ func (s *Service) ShouldNotify(ctx context.Context, userID, guildID, channelID s
 		if err != nil {
 			level = LevelMentions // Default.
 		}
+
+		// A default level set on the channel or its category beats the
+		// global level; a global mute still applies.
+		if channelID != "" {
+			var channelDefault *string
+			s.pool.QueryRow(ctx,
+				`SELECT notification_level FROM channels WHERE id = $1`, channelID).Scan(&channelDefault)
+			if channelDefault != nil {
+				level = *channelDefault
+			}
+		}
 	}
 
 	// Check muted.
@@ -619,6 +825,102 @@ func (s *Service) ShouldNotify(ctx context.Context, userID, guildID, channelID s
 	return isMention
 }
 
+// --- Do Not Disturb ---
+
+// SuppressedHere is the RecordSuppressed kind for a dropped @here mention.
+const SuppressedHere = "here"
+
+// DoNotDisturb reports whether the user's presence is Do Not Disturb. The
+// stored status is used rather than the gateway's, so it also applies while
+// the user has no open session.
+func (s *Service) DoNotDisturb(ctx context.Context, userID string) bool {
+	var status string
+	s.pool.QueryRow(ctx, `SELECT status_presence FROM users WHERE id = $1`, userID).Scan(&status)
+	return presence.IsDoNotDisturb(status)
+}
+
+// RecordSuppressed counts a notification held back by Do Not Disturb. kind
+// is a notification type or SuppressedHere.
+func (s *Service) RecordSuppressed(ctx context.Context, userID, kind string) {
+	column := "other"
+	switch kind {
+	case models.NotifTypeMention, models.NotifTypeReply, models.NotifTypeThreadReply:
+		column = "mentions"
+	case SuppressedHere:
+		column = "here_mentions"
+	case models.NotifTypeDM:
+		column = "direct_messages"
+	}
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO dnd_suppressed_notifications (user_id, `+column+`, since)
+		 VALUES ($1, 1, now())
+		 ON CONFLICT (user_id) DO UPDATE SET `+column+` = dnd_suppressed_notifications.`+column+` + 1`,
+		userID)
+	if err != nil {
+		s.logger.Warn("failed to record suppressed notification", slog.String("error", err.Error()))
+	}
+}
+
+// dndSummary describes what was held back, e.g. "2 mentions and 1 direct
+// message". It is empty if nothing was.
+func dndSummary(mentions, here, dms, other int) string {
+	var parts []string
+	add := func(n int, one, many string) {
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+one)
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %s", n, many))
+		}
+	}
+	add(mentions, "mention", "mentions")
+	add(here, "@here mention", "@here mentions")
+	add(dms, "direct message", "direct messages")
+	add(other, "other notification", "other notifications")
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		return parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
+
+// FlushDNDSummary sends the user one notification summarizing what Do Not
+// Disturb held back, if anything, and resets the counts. Call it once the
+// user has left Do Not Disturb.
+func (s *Service) FlushDNDSummary(ctx context.Context, bus *events.Bus, userID string) error {
+	var mentions, here, dms, other int
+	var since time.Time
+	err := s.pool.QueryRow(ctx,
+		`DELETE FROM dnd_suppressed_notifications WHERE user_id = $1
+		 RETURNING mentions, here_mentions, direct_messages, other, since`,
+		userID).Scan(&mentions, &here, &dms, &other, &since)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading suppressed notifications: %w", err)
+	}
+
+	summary := dndSummary(mentions, here, dms, other)
+	if summary == "" {
+		return nil
+	}
+	content := "While you were in Do Not Disturb: " + summary
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"mentions": mentions, "here_mentions": here, "direct_messages": dms, "other": other,
+		"since": since,
+	})
+	return s.CreateNotification(ctx, bus, &models.Notification{
+		UserID:    userID,
+		Type:      models.NotifTypeDNDSummary,
+		ActorName: "Do Not Disturb",
+		Content:   &content,
+		Metadata:  metadata,
+	})
+}
+
 // CleanupStaleSubscriptions removes push subscriptions unused for longer than maxAge.
 func (s *Service) CleanupStaleSubscriptions(ctx context.Context, maxAge time.Duration) error {
 	cutoff := time.Now().Add(-maxAge)
@@ -1006,6 +1308,13 @@ func (s *Service) CreateNotification(ctx context.Context, bus *events.Bus, n *mo
 		}
 	}
 
+	// Do Not Disturb holds back push; the in-app notification above is kept
+	// and the user gets a summary later.
+	if n.Type != models.NotifTypeDNDSummary && s.DoNotDisturb(ctx, n.UserID) {
+		s.RecordSuppressed(ctx, n.UserID, n.Type)
+		push = false
+	}
+
 	// Send web push if enabled.
 	if push && s.Enabled() {
 		body := ""
@@ -1014,16 +1323,20 @@ func (s *Service) CreateNotification(ctx context.Context, bus *events.Bus, n *mo
 		}
 		title := n.ActorName
 		url := ""
+		groupName := ""
 		if n.GuildID != nil && n.ChannelID != nil {
 			url = fmt.Sprintf("/app/guilds/%s/channels/%s", *n.GuildID, *n.ChannelID)
 			if n.GuildName != nil && n.ChannelName != nil {
 				title = fmt.Sprintf("%s in #%s (%s)", n.ActorName, *n.ChannelName, *n.GuildName)
 			}
+			if n.ChannelName != nil {
+				groupName = "#" + *n.ChannelName
+			}
 		} else if n.ChannelID != nil {
 			url = fmt.Sprintf("/app/dms/%s", *n.ChannelID)
 		}
 
-		_ = s.SendToUser(ctx, n.UserID, PushPayload{
+		payload := PushPayload{
 			Type:      n.Type,
 			Title:     title,
 			Body:      body,
@@ -1031,7 +1344,15 @@ func (s *Service) CreateNotification(ctx context.Context, bus *events.Bus, n *mo
 			ChannelID: derefString(n.ChannelID),
 			GuildID:   derefString(n.GuildID),
 			MessageID: derefString(n.MessageID),
-		})
+		}
+		// Messages are grouped per channel (threads are channels too); other
+		// notifications each stand alone.
+		if n.ChannelID != nil && n.MessageID != nil {
+			payload.CollapseKey = *n.ChannelID
+			payload.Tag = "amityvox-ch-" + *n.ChannelID
+			payload.GroupName = groupName
+		}
+		s.pushes.add(n.UserID, payload)
 	}
 
 	return nil
@@ -0,0 +1,182 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Reaction notification settings: whose reactions to the user's messages
+// notify them.
+const (
+	ReactionsAll     = "all"
+	ReactionsFriends = "friends"
+	ReactionsOff     = "off"
+)
+
+const (
+	// reactionDigestAfter is how many reactions to one message notify
+	// individually before the rest are held for digests.
+	reactionDigestAfter = 3
+	// ReactionDigestWindow is how long the first held reaction waits
+	// before its digest is sent.
+	ReactionDigestWindow = 15 * time.Minute
+	// reactionDigestActors caps the reactors remembered per digest.
+	reactionDigestActors = 10
+)
+
+// ReactionSetting returns the user's reaction notification setting.
+func (s *Service) ReactionSetting(ctx context.Context, userID string) string {
+	setting := ReactionsAll
+	s.pool.QueryRow(ctx,
+		`SELECT reactions FROM notification_preferences WHERE user_id = $1 AND guild_id = '__global__'`,
+		userID).Scan(&setting)
+	return setting
+}
+
+// HoldReaction counts a reaction to the author's message and reports
+// whether it was held for a digest rather than to be notified now. The first
+// reactionDigestAfter reactions to a message are notified individually.
+func (s *Service) HoldReaction(ctx context.Context, authorID, messageID, channelID, guildID, actorID, emoji string) bool {
+	var guild *string
+	if guildID != "" {
+		guild = &guildID
+	}
+	var held int
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO reaction_digests (user_id, message_id, channel_id, guild_id, sent, updated_at)
+		 VALUES ($1, $2, $3, $4, 1, now())
+		 ON CONFLICT (user_id, message_id) DO UPDATE SET
+		   sent = reaction_digests.sent + CASE WHEN reaction_digests.sent < $7 THEN 1 ELSE 0 END,
+		   held = reaction_digests.held + CASE WHEN reaction_digests.sent < $7 THEN 0 ELSE 1 END,
+		   emoji = CASE WHEN reaction_digests.sent < $7 THEN reaction_digests.emoji
+		           ELSE jsonb_set(reaction_digests.emoji, ARRAY[$6::text],
+		                to_jsonb(COALESCE((reaction_digests.emoji->>$6)::int, 0) + 1)) END,
+		   actor_ids = CASE WHEN reaction_digests.sent < $7 THEN reaction_digests.actor_ids
+		               ELSE (array_remove(reaction_digests.actor_ids, $5) || $5::text)[greatest(1, cardinality(array_remove(reaction_digests.actor_ids, $5)) + 2 - $8):] END,
+		   held_since = CASE WHEN reaction_digests.sent < $7 THEN reaction_digests.held_since
+		                ELSE COALESCE(reaction_digests.held_since, now()) END,
+		   updated_at = now()
+		 RETURNING held`,
+		authorID, messageID, channelID, guild, actorID, emoji, reactionDigestAfter, reactionDigestActors,
+	).Scan(&held)
+	if err != nil {
+		s.logger.Warn("failed to count reaction for digest", slog.String("error", err.Error()))
+		return false
+	}
+	return held > 0
+}
+
+// reactionDigest is a message's held reactions, taken for sending.
+type reactionDigest struct {
+	userID, messageID, channelID string
+	guildID                      *string
+	count                        int
+	emoji                        map[string]int
+	actorIDs                     []string
+}
+
+// FlushReactionDigests sends a digest for every message whose held
+// reactions have waited out the digest window, and forgets messages that
+// have had no reactions for a week.
+func (s *Service) FlushReactionDigests(ctx context.Context, bus *events.Bus) error {
+	rows, err := s.pool.Query(ctx,
+		`UPDATE reaction_digests d
+		 SET held = 0, emoji = '{}', actor_ids = '{}', held_since = NULL
+		 FROM (SELECT user_id, message_id, held, emoji, actor_ids FROM reaction_digests
+		       WHERE held > 0 AND held_since <= $1
+		       FOR UPDATE SKIP LOCKED) due
+		 WHERE d.user_id = due.user_id AND d.message_id = due.message_id
+		 RETURNING d.user_id, d.message_id, d.channel_id, d.guild_id, due.held, due.emoji, due.actor_ids`,
+		time.Now().Add(-ReactionDigestWindow))
+	if err != nil {
+		return fmt.Errorf("taking due reaction digests: %w", err)
+	}
+	digests, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (reactionDigest, error) {
+		var d reactionDigest
+		err := row.Scan(&d.userID, &d.messageID, &d.channelID, &d.guildID, &d.count, &d.emoji, &d.actorIDs)
+		return d, err
+	})
+	if err != nil {
+		return fmt.Errorf("reading reaction digests: %w", err)
+	}
+
+	for _, d := range digests {
+		if err := s.sendReactionDigest(ctx, bus, d); err != nil {
+			s.logger.Warn("failed to send reaction digest",
+				slog.String("user_id", d.userID), slog.String("error", err.Error()))
+		}
+	}
+
+	if _, err := s.pool.Exec(ctx,
+		`DELETE FROM reaction_digests WHERE held = 0 AND updated_at < now() - INTERVAL '7 days'`); err != nil {
+		return fmt.Errorf("cleaning reaction digests: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) sendReactionDigest(ctx context.Context, bus *events.Bus, d reactionDigest) error {
+	n := models.Notification{
+		UserID:    d.userID,
+		Type:      models.NotifTypeReactionDigest,
+		GuildID:   d.guildID,
+		ChannelID: &d.channelID,
+		MessageID: &d.messageID,
+		ActorName: "Someone",
+	}
+	if len(d.actorIDs) > 0 {
+		n.ActorID = d.actorIDs[len(d.actorIDs)-1]
+		s.pool.QueryRow(ctx,
+			`SELECT COALESCE(display_name, username), avatar_id FROM users WHERE id = $1`, n.ActorID,
+		).Scan(&n.ActorName, &n.ActorAvatarID)
+	}
+	s.pool.QueryRow(ctx,
+		`SELECT c.name, g.name, g.icon_id
+		 FROM channels c LEFT JOIN guilds g ON g.id = c.guild_id
+		 WHERE c.id = $1`, d.channelID,
+	).Scan(&n.ChannelName, &n.GuildName, &n.GuildIconID)
+
+	content := reactionDigestContent(d.count, d.emoji)
+	n.Content = &content
+	n.Metadata, _ = json.Marshal(map[string]interface{}{
+		"count": d.count, "emoji": d.emoji, "actor_ids": d.actorIDs,
+	})
+	return s.CreateNotification(ctx, bus, &n)
+}
+
+// reactionDigestContent describes a digest, e.g. "5 more reactions to
+// your message: 👍 3, 🎉 2". Emoji are listed most used first.
+func reactionDigestContent(count int, emoji map[string]int) string {
+	head := "1 more reaction to your message"
+	if count != 1 {
+		head = fmt.Sprintf("%d more reactions to your message", count)
+	}
+
+	keys := make([]string, 0, len(emoji))
+	for e := range emoji {
+		keys = append(keys, e)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if emoji[keys[i]] != emoji[keys[j]] {
+			return emoji[keys[i]] > emoji[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) == 0 {
+		return head
+	}
+	parts := make([]string, len(keys))
+	for i, e := range keys {
+		parts[i] = fmt.Sprintf("%s %d", e, emoji[e])
+	}
+	return head + ": " + strings.Join(parts, ", ")
+}
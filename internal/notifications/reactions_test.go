@@ -0,0 +1,24 @@
+package notifications
+
+import "testing"
+
+func TestReactionDigestContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		emoji map[string]int
+		want  string
+	}{
+		{"single", 1, map[string]int{"👍": 1}, "1 more reaction to your message: 👍 1"},
+		{"ordered by count", 5, map[string]int{"🎉": 2, "👍": 3}, "5 more reactions to your message: 👍 3, 🎉 2"},
+		{"ties by emoji", 2, map[string]int{"b": 1, "a": 1}, "2 more reactions to your message: a 1, b 1"},
+		{"no emoji", 3, nil, "3 more reactions to your message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reactionDigestContent(tt.count, tt.emoji); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+// Package opsalerts tells the instance operators about operational problems
+// through outbound webhooks: federation peers going down, JetStream consumers
+// falling behind, storage errors, registration spikes and AutoMod surges.
+//
+// Each webhook receives alerts at or above its minimum severity, optionally
+// only of chosen kinds, rendered for a Slack incoming webhook, a Matrix hook
+// (such as matrix-hookshot's generic webhooks) or as plain JSON. Every
+// delivery is signed like an outgoing guild webhook: X-AmityVox-Signature is
+// "sha256=" and the hex HMAC-SHA256, keyed with the webhook's signing secret,
+// of "<X-AmityVox-Timestamp>.<body>".
+package opsalerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/callback"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Severity is how urgent an alert is.
+type Severity string
+
+// Severities, least urgent first.
+const (
+	Info     Severity = "info"
+	Warning  Severity = "warning"
+	Critical Severity = "critical"
+)
+
+// rank orders severities; unknown ones rank below Info.
+func (s Severity) rank() int {
+	switch s {
+	case Info:
+		return 1
+	case Warning:
+		return 2
+	case Critical:
+		return 3
+	}
+	return 0
+}
+
+// ValidSeverity reports whether s names a severity.
+func ValidSeverity(s string) bool {
+	return Severity(s).rank() > 0
+}
+
+// Alert kinds.
+const (
+	KindFederationPeerDown      = "federation_peer_down"
+	KindFederationPeerRecovered = "federation_peer_recovered"
+	KindJetStreamLag            = "jetstream_consumer_lag"
+	KindJetStreamDrift          = "jetstream_stream_drift"
+	KindStorageError            = "storage_error"
+	KindStorageRecovered        = "storage_recovered"
+	KindRegistrationSpike       = "registration_spike"
+	KindAutoModSurge            = "automod_surge"
+	KindTest                    = "test" // sent on request; ignores the kind filter
+)
+
+// Kinds lists the kinds a webhook may filter on.
+var Kinds = []string{
+	KindFederationPeerDown,
+	KindFederationPeerRecovered,
+	KindJetStreamLag,
+	KindJetStreamDrift,
+	KindStorageError,
+	KindStorageRecovered,
+	KindRegistrationSpike,
+	KindAutoModSurge,
+}
+
+// Webhook body formats.
+const (
+	FormatJSON   = "json"
+	FormatSlack  = "slack"
+	FormatMatrix = "matrix"
+)
+
+// ValidFormat reports whether f names a body format.
+func ValidFormat(f string) bool {
+	return f == FormatJSON || f == FormatSlack || f == FormatMatrix
+}
+
+// Alert is one operational event. The JSON format sends it as is.
+type Alert struct {
+	Kind      string                 `json:"kind"`
+	Severity  Severity               `json:"severity"`
+	Summary   string                 `json:"summary"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Instance  string                 `json:"instance"` // the instance's domain
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// Wants reports whether hook should receive a.
+func Wants(hook models.OperatorAlertWebhook, a Alert) bool {
+	if !hook.Enabled || a.Severity.rank() < Severity(hook.MinSeverity).rank() {
+		return false
+	}
+	return a.Kind == KindTest || len(hook.Kinds) == 0 || slices.Contains(hook.Kinds, a.Kind)
+}
+
+// Body renders a in format.
+func Body(format string, a Alert) ([]byte, error) {
+	switch format {
+	case FormatSlack:
+		lines := []string{fmt.Sprintf("*[%s]* %s: %s", strings.ToUpper(string(a.Severity)), a.Instance, a.Summary)}
+		for _, k := range detailKeys(a) {
+			lines = append(lines, fmt.Sprintf("• %s: %v", k, a.Details[k]))
+		}
+		return json.Marshal(map[string]string{"text": strings.Join(lines, "\n")})
+	case FormatMatrix:
+		head := fmt.Sprintf("[%s] %s: %s", strings.ToUpper(string(a.Severity)), a.Instance, a.Summary)
+		text := []string{head}
+		var b strings.Builder
+		fmt.Fprintf(&b, "<strong>[%s]</strong> %s: %s", strings.ToUpper(string(a.Severity)),
+			html.EscapeString(a.Instance), html.EscapeString(a.Summary))
+		if keys := detailKeys(a); len(keys) > 0 {
+			b.WriteString("<ul>")
+			for _, k := range keys {
+				v := fmt.Sprint(a.Details[k])
+				text = append(text, fmt.Sprintf("%s: %s", k, v))
+				fmt.Fprintf(&b, "<li>%s: %s</li>", html.EscapeString(k), html.EscapeString(v))
+			}
+			b.WriteString("</ul>")
+		}
+		return json.Marshal(map[string]string{
+			"text":     strings.Join(text, "\n"),
+			"html":     b.String(),
+			"username": "AmityVox",
+		})
+	case FormatJSON:
+		return json.Marshal(a)
+	}
+	return nil, fmt.Errorf("unknown alert webhook format %q", format)
+}
+
+func detailKeys(a Alert) []string {
+	keys := make([]string, 0, len(a.Details))
+	for k := range a.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSigningSecret returns a random signing secret for a webhook.
+func NewSigningSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating signing secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// deliveryTimeout bounds a single webhook delivery.
+const deliveryTimeout = 10 * time.Second
+
+// Delivery is the outcome of sending an alert to one webhook.
+type Delivery struct {
+	Success         bool   `json:"success"`
+	StatusCode      int    `json:"status_code"`
+	ResponsePreview string `json:"response_preview,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// Dispatcher sends alerts to the configured webhooks.
+type Dispatcher struct {
+	Pool       *pgxpool.Pool
+	InstanceID string
+	Logger     *slog.Logger
+}
+
+// Send delivers a to every enabled webhook that wants it. Failures are
+// logged and recorded against the webhook, never returned: an alert that
+// cannot be delivered must not break the check that raised it.
+func (d *Dispatcher) Send(ctx context.Context, a Alert) {
+	rows, err := d.Pool.Query(ctx,
+		`SELECT id, name, url, format, min_severity, kinds, signing_secret, enabled
+		 FROM operator_alert_webhooks WHERE enabled`)
+	if err != nil {
+		d.Logger.Error("failed to load alert webhooks", slog.String("error", err.Error()))
+		return
+	}
+	hooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OperatorAlertWebhook, error) {
+		var h models.OperatorAlertWebhook
+		err := row.Scan(&h.ID, &h.Name, &h.URL, &h.Format, &h.MinSeverity, &h.Kinds, &h.SigningSecret, &h.Enabled)
+		return h, err
+	})
+	if err != nil {
+		d.Logger.Error("failed to read alert webhooks", slog.String("error", err.Error()))
+		return
+	}
+
+	d.stamp(ctx, &a)
+	for _, h := range hooks {
+		if !Wants(h, a) {
+			continue
+		}
+		if res := d.Deliver(ctx, h, a); !res.Success {
+			d.Logger.Warn("alert webhook delivery failed",
+				slog.String("webhook_id", h.ID), slog.String("kind", a.Kind),
+				slog.Int("status", res.StatusCode), slog.String("error", res.Error))
+		}
+	}
+}
+
+// Deliver sends a to hook regardless of its filters and records the outcome
+// on the webhook. The instance and time are filled in when a lacks them.
+func (d *Dispatcher) Deliver(ctx context.Context, hook models.OperatorAlertWebhook, a Alert) Delivery {
+	d.stamp(ctx, &a)
+	res := d.post(ctx, hook, a)
+	var status *int
+	var lastErr *string
+	if res.StatusCode != 0 {
+		status = &res.StatusCode
+	}
+	if !res.Success {
+		msg := res.Error
+		if msg == "" {
+			msg = fmt.Sprintf("HTTP %d: %s", res.StatusCode, http.StatusText(res.StatusCode))
+		}
+		lastErr = &msg
+	}
+	if _, err := d.Pool.Exec(ctx,
+		`UPDATE operator_alert_webhooks
+		 SET last_delivered_at = now(), last_status = $2, last_error = $3
+		 WHERE id = $1`,
+		hook.ID, status, lastErr); err != nil {
+		d.Logger.Warn("failed to record alert webhook delivery",
+			slog.String("webhook_id", hook.ID), slog.String("error", err.Error()))
+	}
+	return res
+}
+
+// stamp fills in the alert's instance domain and time when missing.
+func (d *Dispatcher) stamp(ctx context.Context, a *Alert) {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC()
+	}
+	if a.Instance == "" {
+		d.Pool.QueryRow(ctx, `SELECT domain FROM instances WHERE id = $1`, d.InstanceID).Scan(&a.Instance)
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, hook models.OperatorAlertWebhook, a Alert) Delivery {
+	body, err := Body(hook.Format, a)
+	if err != nil {
+		return Delivery{Error: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return Delivery{Error: fmt.Sprintf("failed to create request: %v", err)}
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AmityVox-Alerts/1.0")
+	req.Header.Set("X-AmityVox-Alert", a.Kind)
+	req.Header.Set(callback.TimestampHeader, ts)
+	req.Header.Set("X-AmityVox-Signature", Sign(hook.SigningSecret, ts, body))
+
+	resp, err := callback.SafeClient().Do(req)
+	if err != nil {
+		return Delivery{Error: fmt.Sprintf("request failed: %v", err)}
+	}
+	defer resp.Body.Close()
+	preview, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	return Delivery{
+		Success:         resp.StatusCode >= 200 && resp.StatusCode < 300,
+		StatusCode:      resp.StatusCode,
+		ResponsePreview: string(preview),
+	}
+}
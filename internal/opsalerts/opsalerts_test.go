@@ -0,0 +1,106 @@
+package opsalerts
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+func TestWants(t *testing.T) {
+	lag := Alert{Kind: KindJetStreamLag, Severity: Warning}
+	tests := []struct {
+		name string
+		hook models.OperatorAlertWebhook
+		a    Alert
+		want bool
+	}{
+		{"matching severity", models.OperatorAlertWebhook{Enabled: true, MinSeverity: "warning"}, lag, true},
+		{"below minimum", models.OperatorAlertWebhook{Enabled: true, MinSeverity: "critical"}, lag, false},
+		{"disabled", models.OperatorAlertWebhook{MinSeverity: "info"}, lag, false},
+		{"kind listed", models.OperatorAlertWebhook{Enabled: true, MinSeverity: "info", Kinds: []string{KindJetStreamLag}}, lag, true},
+		{"kind not listed", models.OperatorAlertWebhook{Enabled: true, MinSeverity: "info", Kinds: []string{KindStorageError}}, lag, false},
+		{"test ignores kinds", models.OperatorAlertWebhook{Enabled: true, MinSeverity: "info", Kinds: []string{KindStorageError}},
+			Alert{Kind: KindTest, Severity: Info}, true},
+	}
+	for _, tt := range tests {
+		if got := Wants(tt.hook, tt.a); got != tt.want {
+			t.Errorf("%s: Wants() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBody(t *testing.T) {
+	a := Alert{
+		Kind:      KindFederationPeerDown,
+		Severity:  Critical,
+		Summary:   "Federation peer <b>.example is unreachable",
+		Details:   map[string]interface{}{"status": "unreachable", "peer": "b.example"},
+		Instance:  "chat.example.com",
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	tests := []struct {
+		format string
+		want   map[string]string
+	}{
+		{FormatSlack, map[string]string{
+			"text": "*[CRITICAL]* chat.example.com: Federation peer <b>.example is unreachable\n• peer: b.example\n• status: unreachable",
+		}},
+		{FormatMatrix, map[string]string{
+			"text":     "[CRITICAL] chat.example.com: Federation peer <b>.example is unreachable\npeer: b.example\nstatus: unreachable",
+			"html":     "<strong>[CRITICAL]</strong> chat.example.com: Federation peer &lt;b&gt;.example is unreachable<ul><li>peer: b.example</li><li>status: unreachable</li></ul>",
+			"username": "AmityVox",
+		}},
+	}
+	for _, tt := range tests {
+		body, err := Body(tt.format, a)
+		if err != nil {
+			t.Fatalf("Body(%s) error: %v", tt.format, err)
+		}
+		var got map[string]string
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("Body(%s) is not JSON: %v", tt.format, err)
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("Body(%s)[%s] = %q, want %q", tt.format, k, got[k], v)
+			}
+		}
+	}
+
+	body, err := Body(FormatJSON, a)
+	if err != nil {
+		t.Fatalf("Body(json) error: %v", err)
+	}
+	var round Alert
+	if err := json.Unmarshal(body, &round); err != nil || round.Kind != a.Kind || round.Severity != a.Severity {
+		t.Errorf("Body(json) = %s", body)
+	}
+
+	if _, err := Body("xml", a); err == nil {
+		t.Error("Body(xml) succeeded, want error")
+	}
+}
+
+func TestValidSeverity(t *testing.T) {
+	for _, s := range []string{"info", "warning", "critical"} {
+		if !ValidSeverity(s) {
+			t.Errorf("ValidSeverity(%q) = false", s)
+		}
+	}
+	if ValidSeverity("fatal") {
+		t.Error(`ValidSeverity("fatal") = true`)
+	}
+}
+
+func TestSign(t *testing.T) {
+	got := Sign("secret", "1700000000", []byte(`{"kind":"test"}`))
+	if got != Sign("secret", "1700000000", []byte(`{"kind":"test"}`)) || len(got) != len("sha256=")+64 {
+		t.Errorf("Sign() = %q", got)
+	}
+	if got == Sign("other", "1700000000", []byte(`{"kind":"test"}`)) {
+		t.Error("Sign() does not depend on the secret")
+	}
+}
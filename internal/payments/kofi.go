@@ -0,0 +1,167 @@
+package payments
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// kofiPeriod is how long one Ko-fi subscription payment lasts. Ko-fi bills
+// monthly and sends no cancellation event, so an entitlement simply runs out
+// when the payments stop.
+const kofiPeriod = 31 * 24 * time.Hour
+
+// kofiPayment is the JSON Ko-fi posts in the "data" form field.
+type kofiPayment struct {
+	VerificationToken     string `json:"verification_token"`
+	MessageID             string `json:"message_id"`
+	Type                  string `json:"type"`
+	Email                 string `json:"email"`
+	Message               string `json:"message"`
+	IsSubscriptionPayment bool   `json:"is_subscription_payment"`
+	TransactionID         string `json:"kofi_transaction_id"`
+}
+
+// grantsSupport reports whether the payment is part of a subscription.
+// One-off donations and shop orders do not make the payer a supporter.
+func (p kofiPayment) grantsSupport() bool {
+	return p.IsSubscriptionPayment || p.Type == "Subscription"
+}
+
+// handleKofiWebhook applies Ko-fi subscription payments. The payer is
+// matched to a local account by the link code in their Ko-fi message, or by
+// a Ko-fi email an earlier coded payment linked.
+// POST /api/v1/payments/kofi
+func (s *Service) handleKofiWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.KofiVerificationToken == "" {
+		apiutil.WriteError(w, http.StatusNotFound, "provider_disabled", "Ko-fi payments are not enabled")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxWebhookBody)
+	if err := r.ParseForm(); err != nil {
+		apiutil.WriteCode(w, apierrors.InvalidBody, "Malformed form body")
+		return
+	}
+
+	var payment kofiPayment
+	if err := json.Unmarshal([]byte(r.PostForm.Get("data")), &payment); err != nil {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_event", "Malformed Ko-fi payment")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(payment.VerificationToken), []byte(s.cfg.KofiVerificationToken)) != 1 {
+		apiutil.WriteError(w, http.StatusUnauthorized, "invalid_token", "Invalid Ko-fi verification token")
+		return
+	}
+	eventID := payment.MessageID
+	if eventID == "" {
+		eventID = payment.TransactionID
+	}
+	if eventID == "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_event", "Ko-fi payment has no ID")
+		return
+	}
+	if s.seenEvent(r.Context(), models.SupporterSourceKofi, eventID) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	outcome, userID := models.PaymentOutcomeIgnored, ""
+	if payment.grantsSupport() {
+		var err error
+		outcome, userID, err = s.applyKofiPayment(r.Context(), payment, time.Now())
+		if err != nil {
+			apiutil.InternalError(w, s.logger, "Failed to apply Ko-fi payment", err)
+			return
+		}
+	}
+
+	s.recordEvent(r.Context(), models.SupporterSourceKofi, eventID, payment.Type, userID, outcome)
+	w.WriteHeader(http.StatusOK)
+}
+
+// applyKofiPayment extends the payer's entitlement by one period from now.
+// Account email addresses are not verified, so they are never used to find
+// the payer.
+func (s *Service) applyKofiPayment(ctx context.Context, payment kofiPayment, now time.Time) (outcome, userID string, err error) {
+	email := strings.ToLower(strings.TrimSpace(payment.Email))
+	if code := findKofiLinkCode(payment.Message); code != "" {
+		err := s.pool.QueryRow(ctx,
+			`SELECT user_id FROM kofi_link_codes WHERE code = $1`, code).Scan(&userID)
+		if err != nil && err != pgx.ErrNoRows {
+			return "", "", err
+		}
+		if userID != "" && email != "" {
+			if _, err := s.pool.Exec(ctx,
+				`INSERT INTO kofi_payers (email, user_id, linked_at) VALUES ($1, $2, now())
+				 ON CONFLICT (email) DO UPDATE SET user_id = EXCLUDED.user_id, linked_at = now()`,
+				email, userID); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	if userID == "" && email != "" {
+		err := s.pool.QueryRow(ctx,
+			`SELECT user_id FROM kofi_payers WHERE email = $1`, email).Scan(&userID)
+		if err != nil && err != pgx.ErrNoRows {
+			return "", "", err
+		}
+	}
+	if userID == "" {
+		return models.PaymentOutcomeUnmatched, "", nil
+	}
+
+	ok, err := s.grant(ctx, userID, models.SupporterSourceKofi, email, s.cfg.SlotsPerUnit, now.Add(kofiPeriod+s.cfg.GracePeriod))
+	if err != nil || !ok {
+		return models.PaymentOutcomeIgnored, userID, err
+	}
+	return models.PaymentOutcomeGranted, userID, nil
+}
+
+// KofiLinkCodePrefix starts every Ko-fi link code.
+const KofiLinkCodePrefix = "AVKOFI-"
+
+var kofiLinkCodeRe = regexp.MustCompile(`(?i)\b` + KofiLinkCodePrefix + `[A-Z2-7]{12}\b`)
+
+// findKofiLinkCode returns the first link code in a Ko-fi message, or "".
+func findKofiLinkCode(message string) string {
+	return strings.ToUpper(kofiLinkCodeRe.FindString(message))
+}
+
+// KofiLinkCode returns the code a user puts in their Ko-fi message to link
+// their payments to their account, creating it on first use.
+func KofiLinkCode(ctx context.Context, pool *pgxpool.Pool, userID string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating random bytes: %w", err)
+	}
+	code := KofiLinkCodePrefix + base32.StdEncoding.EncodeToString(b)[:12]
+
+	err := pool.QueryRow(ctx,
+		`WITH ins AS (
+		     INSERT INTO kofi_link_codes (user_id, code, created_at) VALUES ($1, $2, now())
+		     ON CONFLICT (user_id) DO NOTHING
+		     RETURNING code
+		 )
+		 SELECT code FROM ins
+		 UNION ALL
+		 SELECT code FROM kofi_link_codes WHERE user_id = $1
+		 LIMIT 1`, userID, code).Scan(&code)
+	if err != nil {
+		return "", fmt.Errorf("loading Ko-fi link code: %w", err)
+	}
+	return code, nil
+}
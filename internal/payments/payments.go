@@ -0,0 +1,227 @@
+// Package payments turns payment provider webhooks into supporter
+// entitlements. Stripe subscription events and Ko-fi subscription payments
+// grant a user boost slots until the paid period (plus a grace period) ends;
+// cancelled subscriptions revoke them. A reconciliation job expires lapsed
+// entitlements, re-reads Stripe subscriptions when an API key is configured
+// and keeps the users' supporter flags in step. Entitlements an instance
+// admin has marked as overridden are never changed here.
+package payments
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+const (
+	// maxWebhookBody caps the size of a webhook request body.
+	maxWebhookBody = 64 << 10
+
+	// reconcileInterval is how often lapsed entitlements are cleaned up.
+	reconcileInterval = time.Hour
+)
+
+// Config holds the provider secrets and grant settings.
+type Config struct {
+	StripeWebhookSecret   string
+	StripeAPIKey          string
+	KofiVerificationToken string
+	SlotsPerUnit          int
+	GracePeriod           time.Duration
+}
+
+// Service handles payment webhooks and supporter reconciliation.
+type Service struct {
+	cfg    Config
+	pool   *pgxpool.Pool
+	bus    *events.Bus
+	client *http.Client
+	logger *slog.Logger
+}
+
+// New creates the payments service.
+func New(cfg Config, pool *pgxpool.Pool, bus *events.Bus, logger *slog.Logger) *Service {
+	return &Service{
+		cfg:    cfg,
+		pool:   pool,
+		bus:    bus,
+		client: &http.Client{Timeout: 15 * time.Second},
+		logger: logger,
+	}
+}
+
+// Routes returns the provider webhook endpoints. Mount it at
+// /api/v1/payments. The endpoints are unauthenticated; each checks its
+// provider's signature or token instead.
+func (s *Service) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Post("/stripe", s.handleStripeWebhook)
+	r.Post("/kofi", s.handleKofiWebhook)
+	return r
+}
+
+// Start runs the reconciliation job until ctx is cancelled.
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.Reconcile(ctx); err != nil {
+				s.logger.Error("supporter reconciliation failed", slog.String("error", err.Error()))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	s.logger.Info("payments service started",
+		slog.Bool("stripe", s.cfg.StripeWebhookSecret != ""),
+		slog.Bool("kofi", s.cfg.KofiVerificationToken != ""))
+}
+
+// seenEvent reports whether a webhook delivery was already handled.
+func (s *Service) seenEvent(ctx context.Context, provider, eventID string) bool {
+	var seen bool
+	s.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM payment_events WHERE provider = $1 AND event_id = $2)`,
+		provider, eventID).Scan(&seen)
+	return seen
+}
+
+// recordEvent stores a handled webhook delivery and its outcome.
+func (s *Service) recordEvent(ctx context.Context, provider, eventID, eventType, userID, outcome string) {
+	var uid *string
+	if userID != "" {
+		uid = &userID
+	}
+	if _, err := s.pool.Exec(ctx,
+		`INSERT INTO payment_events (provider, event_id, event_type, user_id, outcome, received_at)
+		 VALUES ($1, $2, $3, $4, $5, now())
+		 ON CONFLICT (provider, event_id) DO NOTHING`,
+		provider, eventID, eventType, uid, outcome); err != nil {
+		s.logger.Error("failed to record payment event",
+			slog.String("provider", provider), slog.String("event_id", eventID), slog.String("error", err.Error()))
+	}
+}
+
+// userExists reports whether userID names a local account.
+func (s *Service) userExists(ctx context.Context, userID string) bool {
+	var ok bool
+	s.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&ok)
+	return ok
+}
+
+// grant gives a user slots from a provider until expiresAt. An unexpired
+// entitlement from another provider is left alone, so that provider can still
+// find it to refresh or revoke. It reports false if an admin override or
+// another provider's entitlement kept the entitlement as it was.
+func (s *Service) grant(ctx context.Context, userID, source, externalID string, slots int, expiresAt time.Time) (bool, error) {
+	tag, err := s.pool.Exec(ctx,
+		`INSERT INTO supporter_entitlements (user_id, boost_slots, source, external_id, expires_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, now(), now())
+		 ON CONFLICT (user_id) DO UPDATE SET
+		     boost_slots = EXCLUDED.boost_slots, source = EXCLUDED.source, external_id = EXCLUDED.external_id,
+		     expires_at = EXCLUDED.expires_at, granted_by = NULL, note = NULL, updated_at = now()
+		 WHERE NOT supporter_entitlements.admin_override
+		   AND (supporter_entitlements.source IN (EXCLUDED.source, $6)
+		        OR supporter_entitlements.expires_at <= now())`,
+		userID, slots, source, externalID, expiresAt, models.SupporterSourceManual)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	s.syncUser(ctx, userID)
+	return true, nil
+}
+
+// revoke removes the entitlement a provider granted for externalID. It
+// reports false if there was none or an admin override protects it.
+func (s *Service) revoke(ctx context.Context, userID, source, externalID string) (bool, error) {
+	tag, err := s.pool.Exec(ctx,
+		`DELETE FROM supporter_entitlements
+		 WHERE user_id = $1 AND source = $2 AND external_id = $3 AND NOT admin_override`,
+		userID, source, externalID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	s.syncUser(ctx, userID)
+	return true, nil
+}
+
+// syncUser brings a user's supporter flag and boosts in line with their
+// entitlement and tells guilds that lost a boost.
+func (s *Service) syncUser(ctx context.Context, userID string) {
+	if err := apiutil.SyncSupporterFlag(ctx, s.pool, userID); err != nil {
+		s.logger.Error("failed to sync supporter flag",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+	guildIDs, err := apiutil.TrimSupporterBoosts(ctx, s.pool, userID)
+	if err != nil {
+		s.logger.Error("failed to trim supporter boosts",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+	for _, guildID := range guildIDs {
+		s.bus.PublishGuildEvent(ctx, events.SubjectGuildUpdate, "GUILD_UPDATE", guildID, map[string]interface{}{
+			"id":      guildID,
+			"boosted": false,
+			"booster": userID,
+		})
+	}
+}
+
+// Reconcile re-reads Stripe subscriptions when it can, removes lapsed
+// entitlements and repairs supporter flags that drifted from the
+// entitlements table.
+func (s *Service) Reconcile(ctx context.Context) error {
+	if s.cfg.StripeAPIKey != "" {
+		s.refreshStripeSubscriptions(ctx)
+	}
+
+	rows, err := s.pool.Query(ctx,
+		`DELETE FROM supporter_entitlements WHERE expires_at IS NOT NULL AND expires_at <= now()
+		 RETURNING user_id`)
+	if err != nil {
+		return err
+	}
+	var expired []string
+	for rows.Next() {
+		var userID string
+		if rows.Scan(&userID) == nil {
+			expired = append(expired, userID)
+		}
+	}
+	rows.Close()
+	for _, userID := range expired {
+		s.syncUser(ctx, userID)
+	}
+
+	if _, err := s.pool.Exec(ctx,
+		`UPDATE users u SET flags = CASE WHEN e.user_id IS NULL THEN u.flags & ~$1 ELSE u.flags | $1 END
+		 FROM users x
+		 LEFT JOIN supporter_entitlements e
+		        ON e.user_id = x.id AND (e.expires_at IS NULL OR e.expires_at > now())
+		 WHERE u.id = x.id AND ((u.flags & $1 <> 0) <> (e.user_id IS NOT NULL))`,
+		models.UserFlagSupporter); err != nil {
+		return err
+	}
+
+	if len(expired) > 0 {
+		s.logger.Info("expired supporter entitlements", slog.Int("count", len(expired)))
+	}
+	return nil
+}
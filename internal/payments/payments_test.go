@@ -0,0 +1,113 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func stripeHeader(payload []byte, secret string, ts time.Time) string {
+	t := fmt.Sprint(ts.Unix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "." + string(payload)))
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyStripeSignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"customer.subscription.updated"}`)
+	secret := "whsec_test"
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr bool
+	}{
+		{"valid", stripeHeader(payload, secret, now), false},
+		{"valid among several", stripeHeader(payload, secret, now) + ",v1=deadbeef,v0=abc", false},
+		{"wrong secret", stripeHeader(payload, "whsec_other", now), true},
+		{"too old", stripeHeader(payload, secret, now.Add(-10*time.Minute)), true},
+		{"from the future", stripeHeader(payload, secret, now.Add(10*time.Minute)), true},
+		{"missing signature", fmt.Sprintf("t=%d", now.Unix()), true},
+		{"empty", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyStripeSignature(payload, tt.header, secret, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyStripeSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	tampered := []byte(`{"id":"evt_2","type":"customer.subscription.updated"}`)
+	if err := verifyStripeSignature(tampered, stripeHeader(payload, secret, now), secret, now); err == nil {
+		t.Error("verifyStripeSignature() accepted a modified body")
+	}
+}
+
+func TestStripeSubscription(t *testing.T) {
+	var sub stripeSubscription
+	raw := `{"id":"sub_1","status":"past_due","current_period_end":0,
+		"items":{"data":[{"quantity":2,"current_period_end":1700000000},{"quantity":1,"current_period_end":1700003600}]}}`
+	if err := json.Unmarshal([]byte(raw), &sub); err != nil {
+		t.Fatal(err)
+	}
+	if got := sub.units(); got != 3 {
+		t.Errorf("units() = %d, want 3", got)
+	}
+	if got := sub.periodEnd(); !got.Equal(time.Unix(1700003600, 0)) {
+		t.Errorf("periodEnd() = %v, want the latest item period end", got)
+	}
+	if !sub.paid() {
+		t.Error("past_due subscription should still be paid")
+	}
+
+	sub.Status = "canceled"
+	if sub.paid() {
+		t.Error("canceled subscription should not be paid")
+	}
+	if got := (stripeSubscription{}).units(); got != 1 {
+		t.Errorf("units() with no items = %d, want 1", got)
+	}
+}
+
+func TestKofiGrantsSupport(t *testing.T) {
+	tests := []struct {
+		payment kofiPayment
+		want    bool
+	}{
+		{kofiPayment{Type: "Subscription"}, true},
+		{kofiPayment{Type: "Donation", IsSubscriptionPayment: true}, true},
+		{kofiPayment{Type: "Donation"}, false},
+		{kofiPayment{Type: "Shop Order"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.payment.grantsSupport(); got != tt.want {
+			t.Errorf("grantsSupport(%+v) = %v, want %v", tt.payment, got, tt.want)
+		}
+	}
+}
+
+func TestFindKofiLinkCode(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"Thanks! AVKOFI-ABCDEFGH2345", "AVKOFI-ABCDEFGH2345"},
+		{"code avkofi-abcdefgh2345 here", "AVKOFI-ABCDEFGH2345"},
+		{"AVKOFI-ABCDEFGH234", ""},
+		{"AVKOFI-ABCDEFGH23456", ""},
+		{"no code", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := findKofiLinkCode(tt.message); got != tt.want {
+			t.Errorf("findKofiLinkCode(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/api/apierrors"
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+const (
+	// stripeTolerance is how old a signed Stripe delivery may be.
+	stripeTolerance = 5 * time.Minute
+
+	// stripeUserKey is the subscription metadata key holding the local user ID.
+	stripeUserKey = "amityvox_user_id"
+
+	stripeAPIBase = "https://api.stripe.com/v1"
+)
+
+var errStripeNotFound = errors.New("stripe subscription not found")
+
+// stripeEvent is the envelope of a Stripe webhook delivery.
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// stripeSubscription is the part of a Stripe subscription object used here.
+// Newer API versions report the period end per item rather than on the
+// subscription itself.
+type stripeSubscription struct {
+	ID               string            `json:"id"`
+	Status           string            `json:"status"`
+	Metadata         map[string]string `json:"metadata"`
+	CurrentPeriodEnd int64             `json:"current_period_end"`
+	Items            struct {
+		Data []struct {
+			Quantity         int   `json:"quantity"`
+			CurrentPeriodEnd int64 `json:"current_period_end"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+// units is the number of subscription units paid for, at least one.
+func (sub stripeSubscription) units() int {
+	n := 0
+	for _, item := range sub.Items.Data {
+		n += item.Quantity
+	}
+	return max(n, 1)
+}
+
+// periodEnd is when the paid period ends.
+func (sub stripeSubscription) periodEnd() time.Time {
+	end := sub.CurrentPeriodEnd
+	for _, item := range sub.Items.Data {
+		end = max(end, item.CurrentPeriodEnd)
+	}
+	return time.Unix(end, 0)
+}
+
+// paid reports whether the subscription still entitles its user. Past-due
+// subscriptions keep their slots while Stripe retries the payment.
+func (sub stripeSubscription) paid() bool {
+	switch sub.Status {
+	case "active", "trialing", "past_due":
+		return true
+	}
+	return false
+}
+
+// verifyStripeSignature checks a Stripe-Signature header against the raw
+// request body: an HMAC-SHA256 of "<timestamp>.<body>" under the endpoint
+// secret, made no more than stripeTolerance before now.
+func verifyStripeSignature(payload []byte, header, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return errors.New("malformed signature header")
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > stripeTolerance || d < -stripeTolerance {
+		return errors.New("signature timestamp outside tolerance")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	for _, sig := range signatures {
+		if got, err := hex.DecodeString(sig); err == nil && hmac.Equal(got, expected) {
+			return nil
+		}
+	}
+	return errors.New("no matching signature")
+}
+
+// handleStripeWebhook applies Stripe subscription events.
+// POST /api/v1/payments/stripe
+func (s *Service) handleStripeWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.StripeWebhookSecret == "" {
+		apiutil.WriteError(w, http.StatusNotFound, "provider_disabled", "Stripe payments are not enabled")
+		return
+	}
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		apiutil.WriteCode(w, apierrors.InvalidBody, "Failed to read request body")
+		return
+	}
+	if err := verifyStripeSignature(payload, r.Header.Get("Stripe-Signature"), s.cfg.StripeWebhookSecret, time.Now()); err != nil {
+		apiutil.WriteError(w, http.StatusUnauthorized, "invalid_signature", "Invalid Stripe signature")
+		return
+	}
+
+	var event stripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil || event.ID == "" {
+		apiutil.WriteError(w, http.StatusBadRequest, "invalid_event", "Malformed Stripe event")
+		return
+	}
+	if s.seenEvent(r.Context(), models.SupporterSourceStripe, event.ID) {
+		apiutil.WriteJSON(w, http.StatusOK, map[string]bool{"received": true})
+		return
+	}
+
+	outcome, userID := models.PaymentOutcomeIgnored, ""
+	switch event.Type {
+	case "customer.subscription.created", "customer.subscription.updated", "customer.subscription.deleted":
+		var sub stripeSubscription
+		if err := json.Unmarshal(event.Data.Object, &sub); err != nil || sub.ID == "" {
+			apiutil.WriteError(w, http.StatusBadRequest, "invalid_event", "Malformed Stripe subscription")
+			return
+		}
+		if event.Type == "customer.subscription.deleted" {
+			sub.Status = "canceled"
+		}
+		outcome, userID, err = s.applyStripeSubscription(r.Context(), sub)
+		if err != nil {
+			// A 5xx makes Stripe retry the delivery later.
+			apiutil.InternalError(w, s.logger, "Failed to apply Stripe subscription", err)
+			return
+		}
+	}
+
+	s.recordEvent(r.Context(), models.SupporterSourceStripe, event.ID, event.Type, userID, outcome)
+	apiutil.WriteJSON(w, http.StatusOK, map[string]bool{"received": true})
+}
+
+// applyStripeSubscription grants or revokes the entitlement of the user named
+// in the subscription's metadata.
+func (s *Service) applyStripeSubscription(ctx context.Context, sub stripeSubscription) (outcome, userID string, err error) {
+	userID = sub.Metadata[stripeUserKey]
+	if userID == "" || !s.userExists(ctx, userID) {
+		return models.PaymentOutcomeUnmatched, "", nil
+	}
+
+	if sub.paid() {
+		expires := sub.periodEnd().Add(s.cfg.GracePeriod)
+		ok, err := s.grant(ctx, userID, models.SupporterSourceStripe, sub.ID, sub.units()*s.cfg.SlotsPerUnit, expires)
+		if err != nil || !ok {
+			return models.PaymentOutcomeIgnored, userID, err
+		}
+		return models.PaymentOutcomeGranted, userID, nil
+	}
+
+	ok, err := s.revoke(ctx, userID, models.SupporterSourceStripe, sub.ID)
+	if err != nil || !ok {
+		return models.PaymentOutcomeIgnored, userID, err
+	}
+	return models.PaymentOutcomeRevoked, userID, nil
+}
+
+// refreshStripeSubscriptions re-reads the subscription behind every Stripe
+// entitlement, catching changes whose webhooks never arrived.
+func (s *Service) refreshStripeSubscriptions(ctx context.Context) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT user_id, external_id FROM supporter_entitlements
+		 WHERE source = $1 AND external_id IS NOT NULL AND NOT admin_override`,
+		models.SupporterSourceStripe)
+	if err != nil {
+		s.logger.Error("failed to list Stripe supporters", slog.String("error", err.Error()))
+		return
+	}
+	type entitlement struct{ userID, subscriptionID string }
+	var ents []entitlement
+	for rows.Next() {
+		var e entitlement
+		if rows.Scan(&e.userID, &e.subscriptionID) == nil {
+			ents = append(ents, e)
+		}
+	}
+	rows.Close()
+
+	for _, e := range ents {
+		sub, err := s.fetchStripeSubscription(ctx, e.subscriptionID)
+		if errors.Is(err, errStripeNotFound) {
+			s.revoke(ctx, e.userID, models.SupporterSourceStripe, e.subscriptionID)
+			continue
+		}
+		if err != nil {
+			s.logger.Warn("failed to fetch Stripe subscription",
+				slog.String("subscription_id", e.subscriptionID), slog.String("error", err.Error()))
+			continue
+		}
+		// The entitlement belongs to this user even if the metadata changed.
+		sub.Metadata = map[string]string{stripeUserKey: e.userID}
+		if _, _, err := s.applyStripeSubscription(ctx, sub); err != nil {
+			s.logger.Error("failed to reconcile Stripe subscription",
+				slog.String("subscription_id", e.subscriptionID), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// fetchStripeSubscription reads a subscription from the Stripe API.
+func (s *Service) fetchStripeSubscription(ctx context.Context, id string) (stripeSubscription, error) {
+	var sub stripeSubscription
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, stripeAPIBase+"/subscriptions/"+url.PathEscape(id), nil)
+	if err != nil {
+		return sub, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.cfg.StripeAPIKey)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return sub, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return sub, errStripeNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return sub, fmt.Errorf("stripe returned %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&sub); err != nil {
+		return sub, fmt.Errorf("decoding stripe subscription: %w", err)
+	}
+	return sub, nil
+}
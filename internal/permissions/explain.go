@@ -0,0 +1,146 @@
+package permissions
+
+import "time"
+
+// Sources of a Step in an Explanation, in the order CalculatePermissions
+// applies them.
+const (
+	SourceOwner          = "owner"
+	SourceDefault        = "default"
+	SourceRole           = "role"
+	SourceCap            = "cap"
+	SourceAdministrator  = "administrator"
+	SourceChannelDefault = "channel_default"
+	SourceChannelRole    = "channel_role"
+	SourceChannelUser    = "channel_user"
+	SourceTimeout        = "timeout"
+	SourceNoView         = "no_view"
+	SourcePreview        = "preview"
+)
+
+// Step is one stage of a permission calculation: what it allowed and denied,
+// and the permission set once it was applied. TargetID names the role or
+// user for role and override steps.
+type Step struct {
+	Source   string
+	TargetID string
+	Allow    uint64
+	Deny     uint64
+	Result   uint64
+}
+
+// Explanation is the effective permission set of a member together with
+// every step that contributed to it. Preview is set when the member cannot
+// view the channel but it is listed to them locked.
+type Explanation struct {
+	Permissions uint64
+	Steps       []Step
+	Preview     bool
+}
+
+// Explain computes the same permission set as CalculatePermissions and
+// records how each source changed it. Steps that change nothing are still
+// listed for roles and overrides that apply to the member, so a role that
+// grants nothing new is visible too.
+func Explain(member MemberInfo, guild GuildInfo, roles []RoleInfo, channel *ChannelInfo) Explanation {
+	var e Explanation
+	add := func(s Step) {
+		e.Steps = append(e.Steps, s)
+		e.Permissions = s.Result
+	}
+
+	// 1. Guild owner always has everything.
+	if member.UserID == guild.OwnerID {
+		add(Step{Source: SourceOwner, Allow: AllPermissions, Result: AllPermissions})
+		return e
+	}
+
+	// 2. Start with @everyone base permissions.
+	perms := guild.DefaultPermissions
+	add(Step{Source: SourceDefault, Allow: perms, Result: perms})
+
+	// 3. Apply role permissions (sorted by position DESC — lowest position = highest priority last).
+	for _, role := range roles {
+		perms |= role.PermissionsAllow
+		perms &^= role.PermissionsDeny
+		add(Step{Source: SourceRole, TargetID: role.ID,
+			Allow: role.PermissionsAllow, Deny: role.PermissionsDeny, Result: perms})
+	}
+	if member.Cap != nil {
+		perms &= *member.Cap
+		add(Step{Source: SourceCap, Allow: *member.Cap, Result: perms})
+	}
+
+	// 4. Administrator bypasses everything.
+	if perms&Administrator != 0 {
+		add(Step{Source: SourceAdministrator, Allow: AllPermissions, Result: AllPermissions})
+		return e
+	}
+
+	if channel == nil {
+		return e
+	}
+
+	// 5. Apply channel-level @everyone overrides.
+	if channel.DefaultPermissionsAllow != nil || channel.DefaultPermissionsDeny != nil {
+		s := Step{Source: SourceChannelDefault}
+		if channel.DefaultPermissionsAllow != nil {
+			s.Allow = *channel.DefaultPermissionsAllow
+		}
+		if channel.DefaultPermissionsDeny != nil {
+			s.Deny = *channel.DefaultPermissionsDeny
+		}
+		perms |= s.Allow
+		perms &^= s.Deny
+		s.Result = perms
+		add(s)
+	}
+
+	// 6. Apply channel-level role overrides.
+	roleIDs := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		roleIDs[r.ID] = true
+	}
+	for _, o := range channel.Overrides {
+		if o.TargetType == "role" && roleIDs[o.TargetID] {
+			perms |= o.PermissionsAllow
+			perms &^= o.PermissionsDeny
+			add(Step{Source: SourceChannelRole, TargetID: o.TargetID,
+				Allow: o.PermissionsAllow, Deny: o.PermissionsDeny, Result: perms})
+		}
+	}
+
+	// 7. Apply channel-level user overrides.
+	for _, o := range channel.Overrides {
+		if o.TargetType == "user" && o.TargetID == member.UserID {
+			perms |= o.PermissionsAllow
+			perms &^= o.PermissionsDeny
+			add(Step{Source: SourceChannelUser, TargetID: o.TargetID,
+				Allow: o.PermissionsAllow, Deny: o.PermissionsDeny, Result: perms})
+		}
+	}
+
+	// Overrides cannot lift a capped member beyond its grant.
+	if member.Cap != nil && perms&^*member.Cap != 0 {
+		perms &= *member.Cap
+		add(Step{Source: SourceCap, Allow: *member.Cap, Result: perms})
+	}
+
+	// 8. Timeout strips action permissions.
+	if member.TimeoutUntil != nil && member.TimeoutUntil.After(time.Now()) {
+		perms &^= TimeoutActionMask
+		add(Step{Source: SourceTimeout, Deny: TimeoutActionMask, Result: perms})
+	}
+
+	// 9. Can't do anything in a channel you can't see.
+	if perms&ViewChannel == 0 {
+		add(Step{Source: SourceNoView, Deny: perms, Result: 0})
+		// A preview channel is still listed, but grants nothing.
+		if channel.Preview {
+			add(Step{Source: SourcePreview, Result: 0})
+			e.Preview = true
+		}
+	}
+
+	return e
+}
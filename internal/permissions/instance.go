@@ -0,0 +1,49 @@
+package permissions
+
+import "sort"
+
+// Instance-scoped administration permissions. These are a separate bitfield
+// from guild permissions and are granted through instance roles, letting
+// large instances delegate staff duties without handing out the admin flag.
+// Users with the admin flag implicitly hold every instance permission.
+const (
+	InstanceManageUsers      uint64 = 1 << 0 // Suspend, ban, and inspect accounts; manage registration.
+	InstanceManageFederation uint64 = 1 << 1 // Peer management, blocklists, bridges, delivery.
+	InstanceModerateMedia    uint64 = 1 << 2 // Browse/delete uploads and manage content scanning.
+	InstanceHandleReports    uint64 = 1 << 3 // Review and resolve escalated reports.
+)
+
+// AllInstancePermissions is the bitmask with every instance permission set.
+const AllInstancePermissions uint64 = InstanceManageUsers | InstanceManageFederation |
+	InstanceModerateMedia | InstanceHandleReports
+
+// instancePermissionNames maps each instance permission bit to its API name.
+var instancePermissionNames = map[uint64]string{
+	InstanceManageUsers:      "manage_users",
+	InstanceManageFederation: "manage_federation",
+	InstanceModerateMedia:    "moderate_media",
+	InstanceHandleReports:    "handle_reports",
+}
+
+// InstanceNames returns the sorted API names of all set instance permission
+// bits.
+func InstanceNames(perms uint64) []string {
+	names := make([]string, 0, len(instancePermissionNames))
+	for bit, name := range instancePermissionNames {
+		if perms&bit == bit {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseInstancePermission returns the bit for an instance permission API name.
+func ParseInstancePermission(name string) (uint64, bool) {
+	for bit, n := range instancePermissionNames {
+		if n == name {
+			return bit, true
+		}
+	}
+	return 0, false
+}
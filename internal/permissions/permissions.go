@@ -29,9 +29,10 @@ const (
 	ViewAuditLog      uint64 = 1 << 14
 	ViewGuildInsights uint64 = 1 << 15
 	MentionHere       uint64 = 1 << 16
+	MentionEveryone   uint64 = 1 << 17
 )
 
-// Channel-scoped permissions (bits 20-39).
+// Channel-scoped permissions (bits 20-41).
 const (
 	ViewChannel      uint64 = 1 << 20
 	ReadHistory      uint64 = 1 << 21
@@ -53,6 +54,8 @@ const (
 	CreateInvites    uint64 = 1 << 37
 	ManageThreads    uint64 = 1 << 38
 	CreateThreads    uint64 = 1 << 39
+	ScreenShare      uint64 = 1 << 40
+	ExportMessages   uint64 = 1 << 41 // Export the channel and create transcript links.
 )
 
 // Administrator (bit 63) bypasses all permission checks.
@@ -63,15 +66,21 @@ const AllPermissions uint64 = ManageChannels | ManageGuild | ManagePermissions |
 	ManageRoles | ManageEmoji | ManageWebhooks | KickMembers | BanMembers |
 	TimeoutMembers | AssignRoles | ChangeNickname | ManageNicknames |
 	ChangeAvatar | RemoveAvatars | ViewAuditLog | ViewGuildInsights |
-	MentionHere | ViewChannel | ReadHistory | SendMessages |
+	MentionHere | MentionEveryone | ViewChannel | ReadHistory | SendMessages |
 	ManageMessages | EmbedLinks | UploadFiles | AddReactions |
 	UseExternalEmoji | Connect | Speak | MuteMembers | DeafenMembers |
 	MoveMembers | UseVAD | PrioritySpeaker | Stream | Masquerade |
-	CreateInvites | ManageThreads | CreateThreads | Administrator
+	CreateInvites | ManageThreads | CreateThreads | ScreenShare | ExportMessages |
+	Administrator
 
 // TimeoutActionMask contains the permissions stripped from timed-out members.
 const TimeoutActionMask uint64 = SendMessages | AddReactions | Connect |
-	Speak | Stream | CreateThreads | CreateInvites
+	Speak | Stream | ScreenShare | CreateThreads | CreateInvites
+
+// DefaultGuildPermissions is the @everyone permission set of a new guild
+// when the instance has not configured its own.
+const DefaultGuildPermissions uint64 = ViewChannel | ReadHistory |
+	SendMessages | AddReactions | Connect | Speak | ChangeNickname | CreateInvites
 
 // permissionNames maps each permission bit to a human-readable name.
 var permissionNames = map[uint64]string{
@@ -92,6 +101,7 @@ var permissionNames = map[uint64]string{
 	ViewAuditLog:      "ViewAuditLog",
 	ViewGuildInsights: "ViewGuildInsights",
 	MentionHere:       "MentionHere",
+	MentionEveryone:   "MentionEveryone",
 	ViewChannel:       "ViewChannel",
 	ReadHistory:       "ReadHistory",
 	SendMessages:      "SendMessages",
@@ -112,6 +122,8 @@ var permissionNames = map[uint64]string{
 	CreateInvites:     "CreateInvites",
 	ManageThreads:     "ManageThreads",
 	CreateThreads:     "CreateThreads",
+	ScreenShare:       "ScreenShare",
+	ExportMessages:    "ExportMessages",
 	Administrator:     "Administrator",
 }
 
@@ -119,6 +131,9 @@ var permissionNames = map[uint64]string{
 type MemberInfo struct {
 	UserID       string
 	TimeoutUntil *time.Time
+	// Cap, if set, bounds everything the member can hold. It is the
+	// permission set granted to an app when it was installed.
+	Cap *uint64
 }
 
 // GuildInfo holds the guild-level fields needed for permission calculation.
@@ -148,6 +163,8 @@ type ChannelInfo struct {
 	DefaultPermissionsAllow *uint64
 	DefaultPermissionsDeny  *uint64
 	Overrides               []ChannelOverride
+	// Preview lists the channel, locked, to members who cannot view it.
+	Preview bool
 }
 
 // CalculatePermissions computes the effective permission set for a member in a
@@ -158,77 +175,15 @@ type ChannelInfo struct {
 //  1. Guild owner gets all permissions
 //  2. Start with @everyone base permissions
 //  3. Apply role allow/deny (sorted by position descending — lowest position = highest priority applied last)
+//     and the member's cap, if any
 //  4. Administrator bypass
 //  5. Channel-level @everyone overrides
 //  6. Channel-level role overrides
 //  7. Channel-level user overrides
 //  8. Timeout strips action permissions
-//  9. No view = no permissions
+//  9. No view = no permissions, though a preview channel stays listed
 func CalculatePermissions(member MemberInfo, guild GuildInfo, roles []RoleInfo, channel *ChannelInfo) uint64 {
-	// 1. Guild owner always has everything.
-	if member.UserID == guild.OwnerID {
-		return AllPermissions
-	}
-
-	// 2. Start with @everyone base permissions.
-	perms := guild.DefaultPermissions
-
-	// 3. Apply role permissions (sorted by position DESC — lowest position = highest priority last).
-	// The caller should provide roles sorted by position descending.
-	for _, role := range roles {
-		perms |= role.PermissionsAllow
-		perms &^= role.PermissionsDeny
-	}
-
-	// 4. Administrator bypasses everything.
-	if perms&Administrator != 0 {
-		return AllPermissions
-	}
-
-	// If no channel context, return guild-level permissions.
-	if channel == nil {
-		return perms
-	}
-
-	// 5. Apply channel-level @everyone overrides.
-	if channel.DefaultPermissionsAllow != nil {
-		perms |= *channel.DefaultPermissionsAllow
-	}
-	if channel.DefaultPermissionsDeny != nil {
-		perms &^= *channel.DefaultPermissionsDeny
-	}
-
-	// 6. Apply channel-level role overrides.
-	roleIDs := make(map[string]bool, len(roles))
-	for _, r := range roles {
-		roleIDs[r.ID] = true
-	}
-	for _, override := range channel.Overrides {
-		if override.TargetType == "role" && roleIDs[override.TargetID] {
-			perms |= override.PermissionsAllow
-			perms &^= override.PermissionsDeny
-		}
-	}
-
-	// 7. Apply channel-level user overrides.
-	for _, override := range channel.Overrides {
-		if override.TargetType == "user" && override.TargetID == member.UserID {
-			perms |= override.PermissionsAllow
-			perms &^= override.PermissionsDeny
-		}
-	}
-
-	// 8. Timeout strips action permissions.
-	if member.TimeoutUntil != nil && member.TimeoutUntil.After(time.Now()) {
-		perms &^= TimeoutActionMask
-	}
-
-	// 9. Can't do anything in a channel you can't see.
-	if perms&ViewChannel == 0 {
-		return 0
-	}
-
-	return perms
+	return Explain(member, guild, roles, channel).Permissions
 }
 
 // HasPermission reports whether the given permission set includes the specified permission.
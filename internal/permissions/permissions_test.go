@@ -196,6 +196,31 @@ func TestCalculatePermissions_NoViewNoPerms(t *testing.T) {
 	}
 }
 
+func TestCalculatePermissions_Cap(t *testing.T) {
+	grant := ViewChannel | SendMessages
+	member := MemberInfo{UserID: "bot1", Cap: &grant}
+	guild := GuildInfo{OwnerID: "other", DefaultPermissions: ViewChannel | ReadHistory}
+	roles := []RoleInfo{
+		{ID: "extra", Position: 2, PermissionsAllow: Administrator | ManageGuild},
+		{ID: "app", Position: 1, PermissionsAllow: SendMessages},
+	}
+
+	got := CalculatePermissions(member, guild, roles, nil)
+	if got != grant {
+		t.Errorf("capped member should hold only its grant, got 0x%X", got)
+	}
+
+	channel := &ChannelInfo{
+		Overrides: []ChannelOverride{
+			{TargetType: "user", TargetID: "bot1", PermissionsAllow: ManageMessages | ReadHistory},
+		},
+	}
+	got = CalculatePermissions(member, guild, roles, channel)
+	if got != grant {
+		t.Errorf("channel overrides should not lift the cap, got 0x%X", got)
+	}
+}
+
 func TestNames(t *testing.T) {
 	perms := SendMessages | ViewChannel
 	names := Names(perms)
@@ -246,3 +271,150 @@ func TestTimeoutActionMask_DoesNotIncludeView(t *testing.T) {
 		t.Error("TimeoutActionMask should include SendMessages")
 	}
 }
+
+func TestInstancePermissionNames(t *testing.T) {
+	got := InstanceNames(InstanceManageUsers | InstanceHandleReports)
+	want := []string{"handle_reports", "manage_users"}
+	if len(got) != len(want) {
+		t.Fatalf("InstanceNames = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("InstanceNames[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if len(InstanceNames(0)) != 0 {
+		t.Error("InstanceNames(0) should be empty")
+	}
+	if n := len(InstanceNames(AllInstancePermissions)); n != 4 {
+		t.Errorf("InstanceNames(AllInstancePermissions) has %d names, want 4", n)
+	}
+}
+
+func TestParseInstancePermission(t *testing.T) {
+	for _, name := range InstanceNames(AllInstancePermissions) {
+		bit, ok := ParseInstancePermission(name)
+		if !ok {
+			t.Errorf("ParseInstancePermission(%q) not found", name)
+			continue
+		}
+		if names := InstanceNames(bit); len(names) != 1 || names[0] != name {
+			t.Errorf("round trip of %q gave %v", name, names)
+		}
+	}
+	if _, ok := ParseInstancePermission("manage_everything"); ok {
+		t.Error("expected unknown permission to be rejected")
+	}
+}
+
+func TestExplain_Steps(t *testing.T) {
+	member := MemberInfo{UserID: "user1"}
+	guild := GuildInfo{OwnerID: "other", DefaultPermissions: ViewChannel | SendMessages}
+	roles := []RoleInfo{
+		{ID: "mod", Position: 2, PermissionsAllow: ManageMessages},
+		{ID: "muted", Position: 1, PermissionsDeny: SendMessages},
+	}
+	channel := &ChannelInfo{
+		Overrides: []ChannelOverride{
+			{TargetType: "role", TargetID: "mod", PermissionsAllow: SendMessages},
+			{TargetType: "role", TargetID: "unrelated", PermissionsAllow: Administrator},
+			{TargetType: "user", TargetID: "user1", PermissionsDeny: ManageMessages},
+		},
+	}
+
+	e := Explain(member, guild, roles, channel)
+	want := []string{SourceDefault, SourceRole, SourceRole, SourceChannelRole, SourceChannelUser}
+	if len(e.Steps) != len(want) {
+		t.Fatalf("got %d steps, want %d: %+v", len(e.Steps), len(want), e.Steps)
+	}
+	for i, s := range e.Steps {
+		if s.Source != want[i] {
+			t.Errorf("step %d source = %q, want %q", i, s.Source, want[i])
+		}
+	}
+	if e.Steps[2].TargetID != "muted" || HasPermission(e.Steps[2].Result, SendMessages) {
+		t.Errorf("muted role step = %+v, want SendMessages removed", e.Steps[2])
+	}
+	if want := ViewChannel | SendMessages; e.Permissions != want {
+		t.Errorf("Permissions = %s, want %s", Debug(e.Permissions), Debug(want))
+	}
+	if e.Permissions != CalculatePermissions(member, guild, roles, channel) {
+		t.Error("Explain and CalculatePermissions disagree")
+	}
+}
+
+func TestExplain_Bypasses(t *testing.T) {
+	owner := Explain(MemberInfo{UserID: "owner"}, GuildInfo{OwnerID: "owner"}, nil, nil)
+	if len(owner.Steps) != 1 || owner.Steps[0].Source != SourceOwner || owner.Permissions != AllPermissions {
+		t.Errorf("owner explanation = %+v", owner)
+	}
+
+	guild := GuildInfo{OwnerID: "other", DefaultPermissions: ViewChannel}
+	roles := []RoleInfo{{ID: "admin", PermissionsAllow: Administrator}}
+	admin := Explain(MemberInfo{UserID: "user1"}, guild, roles, &ChannelInfo{})
+	last := admin.Steps[len(admin.Steps)-1]
+	if last.Source != SourceAdministrator || admin.Permissions != AllPermissions {
+		t.Errorf("administrator explanation ends with %+v", last)
+	}
+
+	hidden := Explain(MemberInfo{UserID: "user1"}, GuildInfo{OwnerID: "other", DefaultPermissions: SendMessages}, nil, &ChannelInfo{})
+	last = hidden.Steps[len(hidden.Steps)-1]
+	if last.Source != SourceNoView || hidden.Permissions != 0 {
+		t.Errorf("explanation without ViewChannel ends with %+v", last)
+	}
+	if hidden.Preview {
+		t.Error("expected a channel without preview to stay hidden")
+	}
+}
+
+func TestExplain_Preview(t *testing.T) {
+	guild := GuildInfo{OwnerID: "other", DefaultPermissions: ViewChannel | ReadHistory | SendMessages}
+	deny := ViewChannel
+	channel := &ChannelInfo{
+		DefaultPermissionsDeny: &deny,
+		Overrides:              []ChannelOverride{{TargetType: "role", TargetID: "vip", PermissionsAllow: ViewChannel}},
+		Preview:                true,
+	}
+
+	locked := Explain(MemberInfo{UserID: "user1"}, guild, nil, channel)
+	if !locked.Preview || locked.Permissions != 0 {
+		t.Errorf("locked explanation = %+v, want a preview with no permissions", locked)
+	}
+	if last := locked.Steps[len(locked.Steps)-1]; last.Source != SourcePreview {
+		t.Errorf("locked explanation ends with %+v", last)
+	}
+
+	roles := []RoleInfo{{ID: "vip"}}
+	unlocked := Explain(MemberInfo{UserID: "user1"}, guild, roles, channel)
+	if unlocked.Preview || !HasPermission(unlocked.Permissions, ViewChannel|ReadHistory) {
+		t.Errorf("unlocked explanation = %+v, want full access", unlocked)
+	}
+}
+
+func TestBuiltinPresets(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, p := range BuiltinPresets {
+		if seen[p.ID] {
+			t.Errorf("duplicate preset ID %q", p.ID)
+		}
+		seen[p.ID] = true
+		if p.Allow&^AllPermissions != 0 || p.Deny&^AllPermissions != 0 {
+			t.Errorf("preset %q uses unknown bits", p.ID)
+		}
+		if p.Allow&Administrator != 0 {
+			t.Errorf("preset %q grants Administrator", p.ID)
+		}
+		if p.Allow&p.Deny != 0 {
+			t.Errorf("preset %q both allows and denies %s", p.ID, String(p.Allow&p.Deny))
+		}
+	}
+
+	muted, ok := BuiltinPreset("muted")
+	if !ok || muted.Deny&SendMessages == 0 {
+		t.Errorf("muted preset = %+v, want SendMessages denied", muted)
+	}
+	if _, ok := BuiltinPreset("owner"); ok {
+		t.Error("BuiltinPreset(owner) found a preset")
+	}
+}
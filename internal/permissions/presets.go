@@ -0,0 +1,57 @@
+package permissions
+
+// Preset is a named allow/deny pair for a new role.
+type Preset struct {
+	ID          string
+	Name        string
+	Description string
+	Allow       uint64
+	Deny        uint64
+}
+
+// memberBase is what every preset that allows anything starts from: being
+// able to take part in channels like any member.
+const memberBase = ViewChannel | ReadHistory | SendMessages | AddReactions |
+	EmbedLinks | UploadFiles | Connect | Speak | UseVAD | CreateThreads
+
+// BuiltinPresets are the presets every guild can use. Custom presets are
+// stored per guild; their IDs are ULIDs and never clash with these.
+var BuiltinPresets = []Preset{
+	{
+		ID:          "moderator",
+		Name:        "Moderator",
+		Description: "Removes and times out members, manages messages, threads and voice, and reads the audit log",
+		Allow: memberBase | KickMembers | BanMembers | TimeoutMembers | ManageNicknames |
+			RemoveAvatars | ViewAuditLog | ManageMessages | ManageThreads |
+			MuteMembers | DeafenMembers | MoveMembers | MentionHere,
+	},
+	{
+		ID:          "helper",
+		Name:        "Helper",
+		Description: "Times out members and tidies up messages and threads",
+		Allow:       memberBase | TimeoutMembers | ManageMessages | ManageThreads | MuteMembers,
+	},
+	{
+		ID:          "muted",
+		Name:        "Muted",
+		Description: "Can read but not post, react, speak or stream",
+		Deny: SendMessages | AddReactions | CreateThreads | UploadFiles | EmbedLinks |
+			Speak | Stream | ScreenShare,
+	},
+	{
+		ID:          "bot",
+		Name:        "Bot",
+		Description: "Reads and posts messages with embeds, files, reactions and external emoji",
+		Allow:       memberBase | UseExternalEmoji,
+	},
+}
+
+// BuiltinPreset returns the built-in preset with the given ID.
+func BuiltinPreset(id string) (Preset, bool) {
+	for _, p := range BuiltinPresets {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
@@ -20,6 +20,7 @@ const (
 	PrefixPresence = "presence:"
 	PrefixRateLimit = "ratelimit:"
 	PrefixCache    = "cache:"
+	PrefixCounter  = "counter:"
 )
 
 // Status constants for user presence.
@@ -28,10 +29,17 @@ const (
 	StatusIdle      = "idle"
 	StatusFocus     = "focus"
 	StatusBusy      = "busy"
+	StatusDND       = "dnd"
 	StatusInvisible = "invisible"
 	StatusOffline   = "offline"
 )
 
+// IsDoNotDisturb reports whether a status asks for notifications to be held
+// back. Clients label "busy" as Do Not Disturb; "dnd" is the older spelling.
+func IsDoNotDisturb(status string) bool {
+	return status == StatusBusy || status == StatusDND
+}
+
 // Cache provides a DragonflyDB/Redis client for session storage, presence
 // tracking, rate limiting, and general-purpose caching.
 type Cache struct {
@@ -264,6 +272,51 @@ func (c *Cache) CheckRateLimitInfo(ctx context.Context, key string, limit int, w
 	}, nil
 }
 
+// IncrWindowCount records an event under key and returns how many events
+// happened in the last window, counting the new one.
+func (c *Cache) IncrWindowCount(ctx context.Context, key string, window time.Duration) (float64, error) {
+	return c.windowCount(ctx, key, window, true)
+}
+
+// WindowCount returns how many events were recorded under key in the last
+// window, without recording one.
+func (c *Cache) WindowCount(ctx context.Context, key string, window time.Duration) (float64, error) {
+	return c.windowCount(ctx, key, window, false)
+}
+
+// windowCount keeps one counter per fixed window and estimates the sliding
+// count by weighting the previous window by how much of it is still inside
+// the sliding one. Each counter lives for two windows.
+func (c *Cache) windowCount(ctx context.Context, key string, window time.Duration, incr bool) (float64, error) {
+	now := time.Now()
+	bucket := now.UnixNano() / int64(window)
+	curKey := fmt.Sprintf("%s%s:%d", PrefixCounter, key, bucket)
+	prevKey := fmt.Sprintf("%s%s:%d", PrefixCounter, key, bucket-1)
+
+	pipe := c.client.Pipeline()
+	if incr {
+		pipe.Incr(ctx, curKey)
+		pipe.Expire(ctx, curKey, 2*window)
+	}
+	cur := pipe.Get(ctx, curKey)
+	prev := pipe.Get(ctx, prevKey)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("counting %s: %w", key, err)
+	}
+
+	curN, _ := cur.Int64()
+	prevN, _ := prev.Int64()
+	elapsed := float64(now.UnixNano()%int64(window)) / float64(window)
+	return SlidingWindowCount(prevN, curN, elapsed), nil
+}
+
+// SlidingWindowCount estimates the events in a sliding window from the
+// counts of the previous and current fixed windows, elapsed being the
+// fraction of the current fixed window that has passed.
+func SlidingWindowCount(prev, cur int64, elapsed float64) float64 {
+	return float64(prev)*(1-elapsed) + float64(cur)
+}
+
 // --- Generic Cache Operations ---
 
 // Set stores a value in the cache with an optional TTL.
@@ -305,3 +358,26 @@ func (c *Cache) Delete(ctx context.Context, key string) error {
 	}
 	return nil
 }
+
+// Generation returns the current generation of key, 0 if it was never
+// bumped. Embedding it in cache keys invalidates them all at once: after
+// BumpGeneration, readers look under new keys, and a value computed before
+// the bump is stored under a key no one reads.
+func (c *Cache) Generation(ctx context.Context, key string) (int64, error) {
+	n, err := c.client.Get(ctx, PrefixCounter+key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting generation %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// BumpGeneration advances the generation of key.
+func (c *Cache) BumpGeneration(ctx context.Context, key string) error {
+	if err := c.client.Incr(ctx, PrefixCounter+key).Err(); err != nil {
+		return fmt.Errorf("bumping generation %s: %w", key, err)
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ func TestStatusConstants(t *testing.T) {
 		StatusIdle,
 		StatusFocus,
 		StatusBusy,
+		StatusDND,
 		StatusInvisible,
 		StatusOffline,
 	}
@@ -27,8 +28,21 @@ func TestStatusConstants(t *testing.T) {
 		seen[s] = true
 	}
 
-	if len(statuses) != 6 {
-		t.Errorf("expected 6 status constants, got %d", len(statuses))
+	if len(statuses) != 7 {
+		t.Errorf("expected 7 status constants, got %d", len(statuses))
+	}
+}
+
+func TestIsDoNotDisturb(t *testing.T) {
+	for _, s := range []string{StatusBusy, StatusDND} {
+		if !IsDoNotDisturb(s) {
+			t.Errorf("IsDoNotDisturb(%q) = false, want true", s)
+		}
+	}
+	for _, s := range []string{StatusOnline, StatusIdle, StatusFocus, StatusInvisible, StatusOffline, ""} {
+		if IsDoNotDisturb(s) {
+			t.Errorf("IsDoNotDisturb(%q) = true, want false", s)
+		}
 	}
 }
 
@@ -38,6 +52,7 @@ func TestPrefixConstants(t *testing.T) {
 		"presence":  PrefixPresence,
 		"ratelimit": PrefixRateLimit,
 		"cache":     PrefixCache,
+		"counter":   PrefixCounter,
 	}
 
 	for name, prefix := range prefixes {
@@ -116,3 +131,21 @@ func TestPrefixKeyGeneration(t *testing.T) {
 		}
 	}
 }
+
+func TestSlidingWindowCount(t *testing.T) {
+	tests := []struct {
+		prev, cur int64
+		elapsed   float64
+		want      float64
+	}{
+		{10, 0, 0, 10},
+		{10, 4, 0.5, 9},
+		{10, 4, 1, 4},
+		{0, 7, 0.25, 7},
+	}
+	for _, tt := range tests {
+		if got := SlidingWindowCount(tt.prev, tt.cur, tt.elapsed); got != tt.want {
+			t.Errorf("SlidingWindowCount(%d, %d, %v) = %v, want %v", tt.prev, tt.cur, tt.elapsed, got, tt.want)
+		}
+	}
+}
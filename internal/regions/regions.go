@@ -0,0 +1,187 @@
+// Package regions picks which deployment region serves a media upload or a
+// voice room. Each service that spans regions keeps a Set of its endpoints,
+// primary first, and probes them periodically so unhealthy regions are
+// skipped until they recover.
+package regions
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Selection strategies.
+const (
+	// StrategyNearest prefers the client's region, then this node's, then
+	// the primary.
+	StrategyNearest = "nearest"
+	// StrategyPrimary uses the primary region unless it is unhealthy, then
+	// falls back like StrategyNearest.
+	StrategyPrimary = "primary"
+)
+
+// Status is the health of one region's endpoint.
+type Status struct {
+	Name      string    `json:"name"`
+	Primary   bool      `json:"primary"`
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checked_at,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type region struct {
+	name      string
+	unhealthy atomic.Bool // zero value is healthy until a probe fails
+	checked   atomic.Pointer[probeResult]
+}
+
+type probeResult struct {
+	at  time.Time
+	err string
+}
+
+// Set is the regions a service has endpoints in. Index 0 is the primary.
+type Set struct {
+	regions  []*region
+	strategy string
+	local    string
+}
+
+// NewSet returns a set of the named regions, primary first. local is the
+// region this node runs in.
+func NewSet(names []string, strategy, local string) *Set {
+	s := &Set{strategy: strategy, local: local}
+	for _, name := range names {
+		s.regions = append(s.regions, &region{name: name})
+	}
+	return s
+}
+
+// Len returns the number of regions.
+func (s *Set) Len() int { return len(s.regions) }
+
+// Name returns the name of region i.
+func (s *Set) Name(i int) string { return s.regions[i].name }
+
+// Index returns the index of the named region, or -1 if the set has none.
+func (s *Set) Index(name string) int {
+	for i, r := range s.regions {
+		if r.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Healthy reports whether region i passed its last probe.
+func (s *Set) Healthy(i int) bool { return !s.regions[i].unhealthy.Load() }
+
+// MarkUnhealthy takes region i out of rotation until a probe succeeds, for
+// callers that saw a request to it fail.
+func (s *Set) MarkUnhealthy(i int) { s.regions[i].unhealthy.Store(true) }
+
+// Pick returns the index of the region that should serve a client in the
+// preferred region, which may be empty or unknown.
+func (s *Set) Pick(preferred string) int {
+	healthy := make([]bool, len(s.regions))
+	names := make([]string, len(s.regions))
+	for i, r := range s.regions {
+		healthy[i] = !r.unhealthy.Load()
+		names[i] = r.name
+	}
+	return pick(names, healthy, s.strategy, preferred, s.local)
+}
+
+// pick chooses a region by strategy among the healthy ones, falling back to
+// the first healthy region and, when none is healthy, the primary.
+func pick(names []string, healthy []bool, strategy, preferred, local string) int {
+	order := []string{preferred, local}
+	if strategy == StrategyPrimary {
+		order = []string{names[0], preferred, local}
+	}
+	for _, name := range order {
+		if name == "" {
+			continue
+		}
+		for i, n := range names {
+			if n == name && healthy[i] {
+				return i
+			}
+		}
+	}
+	for i := range names {
+		if healthy[i] {
+			return i
+		}
+	}
+	return 0
+}
+
+// Statuses returns the health of every region, primary first.
+func (s *Set) Statuses() []Status {
+	out := make([]Status, len(s.regions))
+	for i, r := range s.regions {
+		out[i] = Status{Name: r.name, Primary: i == 0, Healthy: !r.unhealthy.Load()}
+		if res := r.checked.Load(); res != nil {
+			out[i].CheckedAt = res.at
+			out[i].Error = res.err
+		}
+	}
+	return out
+}
+
+// StartHealthChecks probes every region each interval with probe until ctx
+// is cancelled. A single region needs no failover and is not probed.
+func (s *Set) StartHealthChecks(ctx context.Context, interval time.Duration, logger *slog.Logger, service string, probe func(ctx context.Context, i int) error) {
+	if len(s.regions) < 2 || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.probeAll(ctx, interval, logger, service, probe)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Set) probeAll(ctx context.Context, timeout time.Duration, logger *slog.Logger, service string, probe func(ctx context.Context, i int) error) {
+	for i, r := range s.regions {
+		pctx, cancel := context.WithTimeout(ctx, timeout)
+		err := probe(pctx, i)
+		cancel()
+
+		res := &probeResult{at: time.Now()}
+		if err != nil {
+			res.err = err.Error()
+		}
+		r.checked.Store(res)
+
+		wasUnhealthy := r.unhealthy.Swap(err != nil)
+		switch {
+		case err != nil && !wasUnhealthy:
+			logger.Warn("region unhealthy, failing over",
+				slog.String("service", service), slog.String("region", r.name), slog.String("error", err.Error()))
+		case err == nil && wasUnhealthy:
+			logger.Info("region healthy again",
+				slog.String("service", service), slog.String("region", r.name))
+		}
+	}
+}
+
+// ClientRegion returns the client's region from the header a geo-aware load
+// balancer sets, or "" if header is empty or the request lacks it.
+func ClientRegion(r *http.Request, header string) string {
+	if header == "" {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get(header))
+}
@@ -0,0 +1,81 @@
+package regions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPick(t *testing.T) {
+	names := []string{"us-east", "eu-west", "ap-south"}
+	allUp := []bool{true, true, true}
+
+	tests := []struct {
+		name      string
+		healthy   []bool
+		strategy  string
+		preferred string
+		local     string
+		want      int
+	}{
+		{"nearest uses client region", allUp, StrategyNearest, "ap-south", "eu-west", 2},
+		{"nearest falls back to local", allUp, StrategyNearest, "", "eu-west", 1},
+		{"nearest ignores unknown region", allUp, StrategyNearest, "mars", "eu-west", 1},
+		{"nearest defaults to primary", allUp, StrategyNearest, "", "", 0},
+		{"nearest skips unhealthy client region", []bool{true, true, false}, StrategyNearest, "ap-south", "eu-west", 1},
+		{"primary ignores client region", allUp, StrategyPrimary, "ap-south", "eu-west", 0},
+		{"primary fails over to client region", []bool{false, true, true}, StrategyPrimary, "ap-south", "eu-west", 2},
+		{"failover to first healthy", []bool{false, false, true}, StrategyNearest, "eu-west", "eu-west", 2},
+		{"nothing healthy uses primary", []bool{false, false, false}, StrategyNearest, "eu-west", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pick(names, tt.healthy, tt.strategy, tt.preferred, tt.local); got != tt.want {
+				t.Errorf("pick() = %d (%s), want %d (%s)", got, names[got], tt.want, names[tt.want])
+			}
+		})
+	}
+}
+
+func TestSet_ProbeFailover(t *testing.T) {
+	s := NewSet([]string{"us-east", "eu-west"}, StrategyNearest, "us-east")
+	down := map[int]bool{0: true}
+	probe := func(_ context.Context, i int) error {
+		if down[i] {
+			return errors.New("unreachable")
+		}
+		return nil
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	s.probeAll(context.Background(), 0, logger, "test", probe)
+	if s.Healthy(0) || !s.Healthy(1) {
+		t.Fatalf("after probe: healthy = %v, %v; want false, true", s.Healthy(0), s.Healthy(1))
+	}
+	if got := s.Pick(""); got != 1 {
+		t.Errorf("Pick() with primary down = %d, want 1", got)
+	}
+	if st := s.Statuses(); st[0].Error != "unreachable" || !st[0].Primary {
+		t.Errorf("Statuses()[0] = %+v", st[0])
+	}
+
+	down[0] = false
+	s.probeAll(context.Background(), 0, logger, "test", probe)
+	if got := s.Pick(""); got != 0 {
+		t.Errorf("Pick() after recovery = %d, want 0", got)
+	}
+}
+
+func TestClientRegion(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Client-Region", " eu-west ")
+	if got := ClientRegion(r, "X-Client-Region"); got != "eu-west" {
+		t.Errorf("ClientRegion() = %q, want eu-west", got)
+	}
+	if got := ClientRegion(r, ""); got != "" {
+		t.Errorf("ClientRegion() with no header configured = %q, want empty", got)
+	}
+}
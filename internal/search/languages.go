@@ -0,0 +1,86 @@
+package search
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/meilisearch/meilisearch-go"
+)
+
+// meiliLocales maps ISO 639-1 languages to the locale codes Meilisearch
+// accepts. Messages in these languages are tokenized for the language
+// instead of by detection, which is unreliable on short text, and matters
+// most for scripts without spaces such as Japanese and Thai.
+var meiliLocales = map[string]string{
+	"af": "afr", "ak": "aka", "am": "amh", "ar": "ara", "az": "aze", "be": "bel",
+	"bg": "bul", "bn": "ben", "ca": "cat", "cs": "ces", "da": "dan", "de": "deu",
+	"el": "ell", "en": "eng", "eo": "epo", "es": "spa", "et": "est", "fa": "pes",
+	"fi": "fin", "fr": "fra", "gu": "guj", "he": "heb", "hi": "hin", "hr": "hrv",
+	"hu": "hun", "hy": "hye", "id": "ind", "it": "ita", "ja": "jpn", "jv": "jav",
+	"ka": "kat", "km": "khm", "kn": "kan", "ko": "kor", "la": "lat", "lt": "lit",
+	"lv": "lav", "mk": "mkd", "ml": "mal", "mr": "mar", "my": "mya", "nb": "nob",
+	"ne": "nep", "nl": "nld", "no": "nob", "or": "ori", "pa": "pan", "pl": "pol",
+	"pt": "por", "ro": "ron", "ru": "rus", "si": "sin", "sk": "slk", "sl": "slv",
+	"sn": "sna", "sr": "srp", "sv": "swe", "ta": "tam", "te": "tel", "th": "tha",
+	"tk": "tuk", "tl": "tgl", "tr": "tur", "uk": "ukr", "ur": "urd", "uz": "uzb",
+	"vi": "vie", "yi": "yid", "zh": "cmn", "zu": "zul",
+}
+
+// Locale returns the Meilisearch locale for a channel language, or "" if
+// the language has no dedicated tokenizer.
+func Locale(lang string) string {
+	return meiliLocales[lang]
+}
+
+// contentField is the attribute a message's content is stored under. Each
+// supported language has its own attribute so a localized attribute rule
+// can tie it to the language; everything else goes in "content".
+func contentField(lang string) string {
+	if loc := Locale(lang); loc != "" {
+		return "content_" + loc
+	}
+	return "content"
+}
+
+// localizedContent returns the searchable content attributes and the
+// localized attribute rules for them, one per supported locale.
+func localizedContent() ([]string, []*meilisearch.LocalizedAttributes) {
+	locs := make(map[string]bool, len(meiliLocales))
+	for _, loc := range meiliLocales {
+		locs[loc] = true
+	}
+	sorted := make([]string, 0, len(locs))
+	for loc := range locs {
+		sorted = append(sorted, loc)
+	}
+	sort.Strings(sorted)
+
+	fields := []string{"content"}
+	rules := make([]*meilisearch.LocalizedAttributes, 0, len(sorted))
+	for _, loc := range sorted {
+		fields = append(fields, "content_"+loc)
+		rules = append(rules, &meilisearch.LocalizedAttributes{Locales: []string{loc}, AttributePatterns: []string{"content_" + loc}})
+	}
+	return fields, rules
+}
+
+// MarshalJSON stores Content under the attribute for the message's
+// language.
+func (d MessageDoc) MarshalJSON() ([]byte, error) {
+	type plain MessageDoc
+	field := contentField(d.Language)
+	if field == "content" {
+		return json.Marshal(plain(d))
+	}
+	raw, err := json.Marshal(plain(d))
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	doc[field] = doc["content"]
+	delete(doc, "content")
+	return json.Marshal(doc)
+}
@@ -8,9 +8,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/meilisearch/meilisearch-go"
 )
@@ -20,14 +24,20 @@ const (
 	batchFlushInterval = 2 * time.Second
 	// batchMaxSize triggers an immediate flush when the buffer reaches this count.
 	batchMaxSize = 100
+	// tombstoneTTL is how long a deleted message ID is remembered so that late
+	// or out-of-order index requests cannot bring it back.
+	tombstoneTTL = 30 * time.Minute
+	// auditPageSize is how many document IDs an audit reads per request.
+	auditPageSize = 1000
 )
 
 // Index names for the Meilisearch collections.
 const (
-	IndexMessages = "messages"
-	IndexUsers    = "users"
-	IndexGuilds   = "guilds"
-	IndexChannels = "channels"
+	IndexMessages    = "messages"
+	IndexUsers       = "users"
+	IndexGuilds      = "guilds"
+	IndexChannels    = "channels"
+	IndexAttachments = "attachments"
 )
 
 // Service provides full-text search operations backed by Meilisearch.
@@ -41,6 +51,14 @@ type Service struct {
 	msgMu    sync.Mutex
 	flushNow chan struct{}
 	closing  bool
+
+	// tombstones holds recently deleted message IDs and when they were
+	// deleted. Guarded by msgMu.
+	tombstones map[string]time.Time
+	// writeMu orders index writes. Meilisearch applies tasks in the order it
+	// receives them, so holding it while a batch or a delete is sent keeps a
+	// delete from landing before an add of the same document.
+	writeMu sync.Mutex
 }
 
 // Config holds the configuration for the search service.
@@ -55,10 +73,11 @@ type Config struct {
 func New(cfg Config) (*Service, error) {
 	client := meilisearch.New(cfg.URL, meilisearch.WithAPIKey(cfg.APIKey))
 	return &Service{
-		client:   &client,
-		pool:     cfg.Pool,
-		logger:   cfg.Logger,
-		flushNow: make(chan struct{}, 1),
+		client:     &client,
+		pool:       cfg.Pool,
+		logger:     cfg.Logger,
+		flushNow:   make(chan struct{}, 1),
+		tombstones: make(map[string]time.Time),
 	}, nil
 }
 
@@ -88,9 +107,10 @@ func (s *Service) StartBatchWorker(ctx context.Context) {
 // EnqueueMessage adds a message to the batch buffer. It will be flushed to
 // Meilisearch within batchFlushInterval or when batchMaxSize is reached.
 // After shutdown begins, new messages are dropped (the periodic sync recovers them).
+// Messages deleted within tombstoneTTL are dropped too.
 func (s *Service) EnqueueMessage(doc MessageDoc) {
 	s.msgMu.Lock()
-	if s.closing {
+	if _, deleted := s.tombstones[doc.ID]; s.closing || deleted {
 		s.msgMu.Unlock()
 		return
 	}
@@ -117,6 +137,8 @@ func (s *Service) flushMessages() {
 	s.msgBuf = nil
 	s.msgMu.Unlock()
 
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 	index := (*s.client).Index(IndexMessages)
 	if _, err := index.AddDocuments(batch, docOpts()); err != nil {
 		s.logger.Error("batch index flush failed",
@@ -139,18 +161,28 @@ func docOpts() *meilisearch.DocumentOptions {
 // EnsureIndexes creates the Meilisearch indexes with proper settings if they
 // don't already exist.
 func (s *Service) EnsureIndexes(ctx context.Context) error {
+	contentFields, contentLocales := localizedContent()
 	indexes := []struct {
 		uid        string
 		primaryKey string
 		searchable []string
 		filterable []string
 		sortable   []string
+		localized  []*meilisearch.LocalizedAttributes
 	}{
 		{
 			uid:        IndexMessages,
 			primaryKey: "id",
-			searchable: []string{"content"},
-			filterable: []string{"channel_id", "guild_id", "author_id", "created_at"},
+			searchable: contentFields,
+			filterable: []string{"channel_id", "guild_id", "author_id", "created_at", "has_file", "language"},
+			sortable:   []string{"created_at"},
+			localized:  contentLocales,
+		},
+		{
+			uid:        IndexAttachments,
+			primaryKey: "id",
+			searchable: []string{"filename", "alt_text", "ocr_text"},
+			filterable: []string{"message_id", "channel_id", "guild_id", "uploader_id", "content_type", "created_at"},
 			sortable:   []string{"created_at"},
 		},
 		{
@@ -208,31 +240,159 @@ func (s *Service) EnsureIndexes(ctx context.Context) error {
 		if len(idx.sortable) > 0 {
 			index.UpdateSortableAttributes(&idx.sortable)
 		}
+		if len(idx.localized) > 0 {
+			index.UpdateLocalizedAttributes(idx.localized)
+		}
 	}
 
 	return nil
 }
 
 // MessageDoc is the document format for messages indexed in Meilisearch.
+// Content is stored under a per-language attribute when Language, the
+// channel's language, has a dedicated tokenizer.
 type MessageDoc struct {
 	ID        string `json:"id"`
 	ChannelID string `json:"channel_id"`
 	GuildID   string `json:"guild_id,omitempty"`
 	AuthorID  string `json:"author_id"`
 	Content   string `json:"content"`
+	Language  string `json:"language,omitempty"`
+	HasFile   bool   `json:"has_file"`
 	CreatedAt int64  `json:"created_at"`
 }
 
+// AttachmentDoc is the document format for message attachments indexed in
+// Meilisearch. Channel and guild are copied from the parent message so
+// results can be filtered like messages.
+type AttachmentDoc struct {
+	ID          string `json:"id"`
+	MessageID   string `json:"message_id"`
+	ChannelID   string `json:"channel_id"`
+	GuildID     string `json:"guild_id,omitempty"`
+	UploaderID  string `json:"uploader_id,omitempty"`
+	Filename    string `json:"filename"`
+	AltText     string `json:"alt_text,omitempty"`
+	OCRText     string `json:"ocr_text,omitempty"`
+	ContentType string `json:"content_type"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// IndexAttachments adds or updates attachments in the search index.
+func (s *Service) IndexAttachments(ctx context.Context, docs []AttachmentDoc) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := (*s.client).Index(IndexAttachments).AddDocumentsWithContext(ctx, docs, docOpts()); err != nil {
+		return fmt.Errorf("indexing %d attachments: %w", len(docs), err)
+	}
+	return nil
+}
+
 // DeleteMessage removes a message from the search index.
 func (s *Service) DeleteMessage(ctx context.Context, messageID string) error {
+	return s.DeleteMessages(ctx, []string{messageID})
+}
+
+// DeleteMessages removes messages and their attachments from the search
+// indexes. The IDs are
+// tombstoned first, so copies still waiting in the batch buffer are dropped
+// and late index requests for them are ignored.
+func (s *Service) DeleteMessages(ctx context.Context, messageIDs []string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	s.tombstone(messageIDs)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 	index := (*s.client).Index(IndexMessages)
-	_, err := index.DeleteDocument(messageID, nil)
-	if err != nil {
-		return fmt.Errorf("deleting message %s from index: %w", messageID, err)
+	if _, err := index.DeleteDocumentsWithContext(ctx, messageIDs, nil); err != nil {
+		return fmt.Errorf("deleting %d messages from index: %w", len(messageIDs), err)
+	}
+	if _, err := (*s.client).Index(IndexAttachments).DeleteDocumentsByFilterWithContext(ctx,
+		inFilter("message_id", messageIDs), nil); err != nil {
+		return fmt.Errorf("deleting attachments of %d messages from index: %w", len(messageIDs), err)
+	}
+	return nil
+}
+
+// inFilter builds a Meilisearch filter matching attr against any of values.
+func inFilter(attr string, values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return attr + " IN [" + strings.Join(quoted, ", ") + "]"
+}
+
+// DeleteChannel removes a channel and all of its messages and attachments
+// from the search indexes.
+func (s *Service) DeleteChannel(ctx context.Context, channelID string) error {
+	s.dropBuffered(func(doc MessageDoc) bool { return doc.ChannelID == channelID })
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	filter := fmt.Sprintf("channel_id = %q", channelID)
+	for _, uid := range []string{IndexMessages, IndexAttachments} {
+		if _, err := (*s.client).Index(uid).DeleteDocumentsByFilterWithContext(ctx, filter, nil); err != nil {
+			return fmt.Errorf("deleting %s of channel %s from index: %w", uid, channelID, err)
+		}
+	}
+	if _, err := (*s.client).Index(IndexChannels).DeleteDocumentWithContext(ctx, channelID, nil); err != nil {
+		return fmt.Errorf("deleting channel %s from index: %w", channelID, err)
+	}
+	return nil
+}
+
+// DeleteGuild removes a guild, its channels and all of their messages and
+// attachments from the search indexes.
+func (s *Service) DeleteGuild(ctx context.Context, guildID string) error {
+	s.dropBuffered(func(doc MessageDoc) bool { return doc.GuildID == guildID })
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	filter := fmt.Sprintf("guild_id = %q", guildID)
+	for _, uid := range []string{IndexMessages, IndexAttachments, IndexChannels} {
+		if _, err := (*s.client).Index(uid).DeleteDocumentsByFilterWithContext(ctx, filter, nil); err != nil {
+			return fmt.Errorf("deleting %s of guild %s from index: %w", uid, guildID, err)
+		}
+	}
+	if _, err := (*s.client).Index(IndexGuilds).DeleteDocumentWithContext(ctx, guildID, nil); err != nil {
+		return fmt.Errorf("deleting guild %s from index: %w", guildID, err)
 	}
 	return nil
 }
 
+// tombstone records messageIDs as deleted, drops any buffered copies, and
+// forgets tombstones older than tombstoneTTL.
+func (s *Service) tombstone(messageIDs []string) {
+	now := time.Now()
+	s.msgMu.Lock()
+	defer s.msgMu.Unlock()
+	for id, at := range s.tombstones {
+		if now.Sub(at) > tombstoneTTL {
+			delete(s.tombstones, id)
+		}
+	}
+	for _, id := range messageIDs {
+		s.tombstones[id] = now
+	}
+	s.msgBuf = slices.DeleteFunc(s.msgBuf, func(doc MessageDoc) bool {
+		_, deleted := s.tombstones[doc.ID]
+		return deleted
+	})
+}
+
+// dropBuffered removes buffered messages matching drop.
+func (s *Service) dropBuffered(drop func(MessageDoc) bool) {
+	s.msgMu.Lock()
+	s.msgBuf = slices.DeleteFunc(s.msgBuf, drop)
+	s.msgMu.Unlock()
+}
+
 // UserDoc is the document format for users indexed in Meilisearch.
 type UserDoc struct {
 	ID          string  `json:"id"`
@@ -276,6 +436,8 @@ type SearchRequest struct {
 	Filters string
 	Limit   int64
 	Offset  int64
+	// Language, if set, is the language the query is written in.
+	Language string
 }
 
 // SearchResult holds results from a search query.
@@ -301,38 +463,64 @@ func (s *Service) Search(ctx context.Context, req SearchRequest) (*SearchResult,
 	if req.Filters != "" {
 		searchReq.Filter = req.Filters
 	}
+	if loc := Locale(req.Language); loc != "" {
+		searchReq.Locales = []string{loc}
+	}
 
 	resp, err := index.Search(req.Query, searchReq)
 	if err != nil {
 		return nil, fmt.Errorf("searching %s: %w", req.Index, err)
 	}
 
-	// Extract IDs from hits. Each hit is map[string]json.RawMessage.
-	ids := make([]string, 0, len(resp.Hits))
-	for _, hit := range resp.Hits {
-		if raw, ok := hit["id"]; ok {
-			var id string
-			if err := json.Unmarshal(raw, &id); err == nil && id != "" {
-				ids = append(ids, id)
-			}
-		}
-	}
-
 	return &SearchResult{
-		IDs:              ids,
+		IDs:              hitIDs(resp.Hits),
 		EstimatedTotal:   resp.EstimatedTotalHits,
 		ProcessingTimeMs: resp.ProcessingTimeMs,
 	}, nil
 }
 
+// SearchAttachments searches attachment filenames, alt text and OCR text
+// and returns the IDs of the messages they belong to, in relevance order and
+// without repeats.
+func (s *Service) SearchAttachments(ctx context.Context, req SearchRequest) ([]string, error) {
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	searchReq := &meilisearch.SearchRequest{
+		Limit:                req.Limit,
+		Offset:               req.Offset,
+		AttributesToRetrieve: []string{"message_id"},
+	}
+	if req.Filters != "" {
+		searchReq.Filter = req.Filters
+	}
+
+	resp, err := (*s.client).Index(IndexAttachments).SearchWithContext(ctx, req.Query, searchReq)
+	if err != nil {
+		return nil, fmt.Errorf("searching %s: %w", IndexAttachments, err)
+	}
+
+	seen := make(map[string]bool, len(resp.Hits))
+	ids := make([]string, 0, len(resp.Hits))
+	for _, hit := range resp.Hits {
+		var id string
+		if raw, ok := hit["message_id"]; ok && json.Unmarshal(raw, &id) == nil && id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
 // SyncMessages reindexes all messages from the database. Used for initial
 // population or recovery. Should be run as a background job.
 func (s *Service) SyncMessages(ctx context.Context, since time.Time) (int, error) {
 	rows, err := s.pool.Query(ctx,
-		`SELECT m.id, m.channel_id, c.guild_id, m.author_id, m.content, m.created_at
+		`SELECT m.id, m.channel_id, c.guild_id, m.author_id, m.content, COALESCE(c.language, ''), m.created_at,
+		        EXISTS (SELECT 1 FROM attachments a WHERE a.message_id = m.id)
 		 FROM messages m
 		 LEFT JOIN channels c ON c.id = m.channel_id
-		 WHERE m.created_at > $1 AND m.content IS NOT NULL
+		 WHERE m.created_at > $1 AND m.content IS NOT NULL AND m.content <> ''
 		 ORDER BY m.created_at ASC
 		 LIMIT 10000`, since)
 	if err != nil {
@@ -346,7 +534,7 @@ func (s *Service) SyncMessages(ctx context.Context, since time.Time) (int, error
 		var guildID *string
 		var content *string
 		var createdAt time.Time
-		if err := rows.Scan(&doc.ID, &doc.ChannelID, &guildID, &doc.AuthorID, &content, &createdAt); err != nil {
+		if err := rows.Scan(&doc.ID, &doc.ChannelID, &guildID, &doc.AuthorID, &content, &doc.Language, &createdAt, &doc.HasFile); err != nil {
 			return 0, fmt.Errorf("scanning message for sync: %w", err)
 		}
 		if content != nil {
@@ -373,6 +561,178 @@ func (s *Service) SyncMessages(ctx context.Context, since time.Time) (int, error
 	return len(docs), nil
 }
 
+// SyncAttachments reindexes attachments of messages created since the given
+// time. Used alongside SyncMessages as a safety net for missed events.
+func (s *Service) SyncAttachments(ctx context.Context, since time.Time) (int, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+AttachmentDocColumns+`
+		 FROM attachments a
+		 JOIN messages m ON m.id = a.message_id
+		 LEFT JOIN channels c ON c.id = m.channel_id
+		 WHERE m.created_at > $1
+		 ORDER BY m.created_at ASC
+		 LIMIT 10000`, since)
+	if err != nil {
+		return 0, fmt.Errorf("querying attachments for sync: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []AttachmentDoc
+	for rows.Next() {
+		doc, err := ScanAttachmentDoc(rows)
+		if err != nil {
+			return 0, fmt.Errorf("scanning attachment for sync: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("querying attachments for sync: %w", err)
+	}
+
+	if err := s.IndexAttachments(ctx, docs); err != nil {
+		return 0, err
+	}
+	return len(docs), nil
+}
+
+// AttachmentDocColumns selects the fields of an AttachmentDoc from
+// attachments a joined to their messages m and channels c.
+const AttachmentDocColumns = `a.id, a.message_id, m.channel_id, c.guild_id, a.uploader_id,
+		        a.filename, a.alt_text, a.ocr_text, a.content_type, m.created_at`
+
+// ScanAttachmentDoc scans a row selected with AttachmentDocColumns.
+func ScanAttachmentDoc(row pgx.Row) (AttachmentDoc, error) {
+	var doc AttachmentDoc
+	var guildID, uploaderID, altText, ocrText *string
+	var createdAt time.Time
+	if err := row.Scan(&doc.ID, &doc.MessageID, &doc.ChannelID, &guildID, &uploaderID,
+		&doc.Filename, &altText, &ocrText, &doc.ContentType, &createdAt); err != nil {
+		return doc, err
+	}
+	doc.GuildID = deref(guildID)
+	doc.UploaderID = deref(uploaderID)
+	doc.AltText = deref(altText)
+	doc.OCRText = deref(ocrText)
+	doc.CreatedAt = createdAt.Unix()
+	return doc, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// AuditResult compares the message index with the database.
+type AuditResult struct {
+	Indexed int64 // documents in the message index
+	Stored  int64 // searchable messages in the database
+	Pruned  int   // index documents removed because their message is gone
+}
+
+// AuditMessages compares the message index's document count with the number
+// of searchable messages in the database. When the index holds more, it
+// walks the index and removes documents whose message no longer exists,
+// which catches deletes whose events were lost. A shortfall is left to the
+// periodic sync.
+func (s *Service) AuditMessages(ctx context.Context) (*AuditResult, error) {
+	var res AuditResult
+	if err := s.pool.QueryRow(ctx,
+		`SELECT count(*) FROM messages WHERE content IS NOT NULL AND content <> ''`,
+	).Scan(&res.Stored); err != nil {
+		return nil, fmt.Errorf("counting searchable messages: %w", err)
+	}
+
+	index := (*s.client).Index(IndexMessages)
+	stats, err := index.GetStatsWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading message index stats: %w", err)
+	}
+	res.Indexed = stats.NumberOfDocuments
+	if res.Indexed <= res.Stored {
+		return &res, nil
+	}
+
+	for offset := int64(0); ; offset += auditPageSize {
+		var page meilisearch.DocumentsResult
+		if err := index.GetDocumentsWithContext(ctx, &meilisearch.DocumentsQuery{
+			Offset: offset,
+			Limit:  auditPageSize,
+			Fields: []string{"id"},
+		}, &page); err != nil {
+			return nil, fmt.Errorf("listing message index documents: %w", err)
+		}
+		ids := hitIDs(page.Results)
+		if len(ids) == 0 {
+			break
+		}
+
+		orphans, err := s.missingMessages(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		if len(orphans) > 0 {
+			if err := s.DeleteMessages(ctx, orphans); err != nil {
+				return nil, err
+			}
+			res.Pruned += len(orphans)
+			// Deletes are applied asynchronously, so later pages are read
+			// from an index that may not have shifted yet; a missed orphan
+			// is caught by the next audit.
+		}
+		if int64(len(page.Results)) < auditPageSize {
+			break
+		}
+	}
+	return &res, nil
+}
+
+// missingMessages returns the IDs in ids that no longer name a searchable
+// message.
+func (s *Service) missingMessages(ctx context.Context, ids []string) ([]string, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT id FROM messages WHERE id = ANY($1) AND content IS NOT NULL AND content <> ''`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("checking indexed messages: %w", err)
+	}
+	defer rows.Close()
+
+	found := make(map[string]bool, len(ids))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning indexed message: %w", err)
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("checking indexed messages: %w", err)
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+// hitIDs extracts the "id" field of each hit.
+func hitIDs(hits meilisearch.Hits) []string {
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		if raw, ok := hit["id"]; ok {
+			var id string
+			if err := json.Unmarshal(raw, &id); err == nil && id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
 // HealthCheck verifies Meilisearch connectivity.
 func (s *Service) HealthCheck() error {
 	ok := (*s.client).IsHealthy()
@@ -3,6 +3,7 @@ package search
 import (
 	"encoding/json"
 	"testing"
+	"time"
 )
 
 func TestIndexConstants(t *testing.T) {
@@ -73,6 +74,47 @@ func TestMessageDoc_OmitEmptyGuildID(t *testing.T) {
 	}
 }
 
+func TestMessageDoc_LocalizedContent(t *testing.T) {
+	tests := []struct {
+		lang  string
+		field string
+	}{
+		{"", "content"},
+		{"ja", "content_jpn"},
+		{"zh", "content_cmn"},
+		{"xx", "content"},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(MessageDoc{ID: "msg_001", Content: "hello", Language: tt.lang})
+		if err != nil {
+			t.Fatalf("marshal error: %v", err)
+		}
+		var raw map[string]interface{}
+		json.Unmarshal(data, &raw)
+		if raw[tt.field] != "hello" {
+			t.Errorf("lang %q: %s = %v, want %q", tt.lang, tt.field, raw[tt.field], "hello")
+		}
+		if _, exists := raw["content"]; exists && tt.field != "content" {
+			t.Errorf("lang %q: content should be moved to %s", tt.lang, tt.field)
+		}
+	}
+}
+
+func TestLocalizedContent(t *testing.T) {
+	fields, rules := localizedContent()
+	if fields[0] != "content" {
+		t.Errorf("fields[0] = %q, want content", fields[0])
+	}
+	if len(fields) != len(rules)+1 {
+		t.Fatalf("%d fields for %d rules", len(fields), len(rules))
+	}
+	for i, rule := range rules {
+		if rule.AttributePatterns[0] != fields[i+1] || fields[i+1] != "content_"+rule.Locales[0] {
+			t.Errorf("rule %d = %v, field %q", i, rule, fields[i+1])
+		}
+	}
+}
+
 func TestUserDoc_JSON(t *testing.T) {
 	displayName := "Alice"
 	doc := UserDoc{
@@ -250,3 +292,45 @@ func TestDocOpts(t *testing.T) {
 		t.Errorf("PrimaryKey = %q, want %q", *opts.PrimaryKey, "id")
 	}
 }
+
+func TestTombstoneDropsBufferedAndLateMessages(t *testing.T) {
+	s := &Service{flushNow: make(chan struct{}, 1), tombstones: make(map[string]time.Time)}
+	s.EnqueueMessage(MessageDoc{ID: "a", Content: "first"})
+	s.EnqueueMessage(MessageDoc{ID: "b", Content: "second"})
+
+	s.tombstone([]string{"a"})
+	if len(s.msgBuf) != 1 || s.msgBuf[0].ID != "b" {
+		t.Fatalf("buffer after tombstone = %+v, want only b", s.msgBuf)
+	}
+
+	s.EnqueueMessage(MessageDoc{ID: "a", Content: "edited"})
+	if len(s.msgBuf) != 1 {
+		t.Errorf("tombstoned message was re-enqueued: %+v", s.msgBuf)
+	}
+
+	s.tombstones["a"] = time.Now().Add(-2 * tombstoneTTL)
+	s.tombstone([]string{"c"})
+	if _, ok := s.tombstones["a"]; ok {
+		t.Error("expired tombstone was not forgotten")
+	}
+}
+
+func TestDropBuffered(t *testing.T) {
+	s := &Service{flushNow: make(chan struct{}, 1), tombstones: make(map[string]time.Time)}
+	s.EnqueueMessage(MessageDoc{ID: "a", ChannelID: "c1", GuildID: "g1"})
+	s.EnqueueMessage(MessageDoc{ID: "b", ChannelID: "c2", GuildID: "g1"})
+	s.EnqueueMessage(MessageDoc{ID: "c", ChannelID: "c3", GuildID: "g2"})
+
+	s.dropBuffered(func(doc MessageDoc) bool { return doc.GuildID == "g1" })
+	if len(s.msgBuf) != 1 || s.msgBuf[0].ID != "c" {
+		t.Errorf("buffer = %+v, want only c", s.msgBuf)
+	}
+}
+
+func TestInFilter(t *testing.T) {
+	got := inFilter("message_id", []string{"a", `b"c`})
+	want := `message_id IN ["a", "b\"c"]`
+	if got != want {
+		t.Errorf("inFilter = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+// Package slo measures message delivery against latency objectives. A
+// sample of sent messages is traced: the REST handler stamps the time the
+// request arrived on the event, and the gateway and federation sender
+// observe how long delivery took once they have handed it on. Each
+// indicator (SLI) keeps a latency histogram, good and total counts, and a
+// per-minute history from which error budget burn rates are computed for
+// the /metrics endpoint.
+package slo
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// The delivery indicators.
+const (
+	// GatewayDelivery is the time from a message send request arriving to
+	// the gateway dispatching the MESSAGE_CREATE to connected sessions.
+	GatewayDelivery = "gateway_delivery"
+	// FederationDelivery is the time from a message send request arriving
+	// to a federation peer accepting the event.
+	FederationDelivery = "federation_delivery"
+)
+
+// LatencyBuckets are the upper bounds of the delivery latency histograms.
+var LatencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Windows are the lookback windows burn rates are reported for. They pair
+// up as the long and short windows of multiwindow burn rate alerts.
+var Windows = []time.Duration{
+	5 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+}
+
+// historyMinutes is how many minutes of good/total counts are kept; it must
+// cover the longest window.
+const historyMinutes = 360
+
+// Objective is a latency target for one indicator: Target of the sampled
+// deliveries complete successfully within Threshold.
+type Objective struct {
+	SLI       string
+	Threshold time.Duration
+	Target    float64 // e.g. 0.99
+}
+
+// Config selects the sample rate and objectives. A zero SampleRate turns
+// tracing off.
+type Config struct {
+	SampleRate float64 // fraction of message sends traced, 0..1
+	Objectives []Objective
+}
+
+// Stats is a snapshot of one indicator.
+type Stats struct {
+	Objective
+	Count    uint64        // sampled deliveries
+	Good     uint64        // deliveries within the threshold
+	Total    time.Duration // sum of latencies
+	Buckets  []uint64      // cumulative, one per LatencyBuckets bound
+	BurnRate []float64     // one per Windows entry
+}
+
+type minute struct {
+	at          int64 // unix minute the slot holds
+	good, total uint64
+}
+
+type tracker struct {
+	obj     Objective
+	count   uint64
+	good    uint64
+	sum     time.Duration
+	buckets []uint64 // non-cumulative; the last slot counts samples above every bound
+	history [historyMinutes]minute
+}
+
+var (
+	mu         sync.Mutex
+	sampleRate float64
+	trackers   map[string]*tracker
+	order      []string
+
+	// now is replaced in tests.
+	now = time.Now
+)
+
+// Configure sets the sample rate and objectives, discarding anything
+// measured so far. Until it is called nothing is traced.
+func Configure(cfg Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	sampleRate = cfg.SampleRate
+	trackers = make(map[string]*tracker, len(cfg.Objectives))
+	order = order[:0]
+	for _, obj := range cfg.Objectives {
+		trackers[obj.SLI] = &tracker{obj: obj, buckets: make([]uint64, len(LatencyBuckets)+1)}
+		order = append(order, obj.SLI)
+	}
+}
+
+type startKey struct{}
+
+// Trace marks ctx as carrying a traced send that started at start, if the
+// send is sampled. Events published with the returned context carry the
+// start time to their consumers.
+func Trace(ctx context.Context, start time.Time) context.Context {
+	mu.Lock()
+	rate := sampleRate
+	mu.Unlock()
+	if rate <= 0 || (rate < 1 && rand.Float64() >= rate) {
+		return ctx
+	}
+	return WithStart(ctx, start)
+}
+
+// WithStart returns ctx carrying an already sampled start time, for
+// consumers that pass a traced event on.
+func WithStart(ctx context.Context, start time.Time) context.Context {
+	return context.WithValue(ctx, startKey{}, start)
+}
+
+// Start returns the start time of the traced send in ctx.
+func Start(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(startKey{}).(time.Time)
+	return t, ok
+}
+
+// ObserveSince records a delivery for sli if ctx carries a traced send.
+func ObserveSince(ctx context.Context, sli string, ok bool) {
+	if start, traced := Start(ctx); traced {
+		Observe(sli, now().Sub(start), ok)
+	}
+}
+
+// Observe records one delivery. It counts as good when it succeeded within
+// the objective's threshold. Unknown indicators are ignored.
+func Observe(sli string, latency time.Duration, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	t := trackers[sli]
+	if t == nil {
+		return
+	}
+	i := 0
+	for i < len(LatencyBuckets) && latency > LatencyBuckets[i] {
+		i++
+	}
+	t.count++
+	t.sum += latency
+	t.buckets[i]++
+
+	good := ok && latency <= t.obj.Threshold
+	at := now().Unix() / 60
+	slot := &t.history[at%historyMinutes]
+	if slot.at != at {
+		*slot = minute{at: at}
+	}
+	slot.total++
+	if good {
+		t.good++
+		slot.good++
+	}
+}
+
+// Snapshot returns every configured indicator in configuration order.
+func Snapshot() []Stats {
+	mu.Lock()
+	defer mu.Unlock()
+	at := now().Unix() / 60
+	out := make([]Stats, 0, len(order))
+	for _, sli := range order {
+		t := trackers[sli]
+		st := Stats{
+			Objective: t.obj,
+			Count:     t.count,
+			Good:      t.good,
+			Total:     t.sum,
+			Buckets:   make([]uint64, len(LatencyBuckets)),
+			BurnRate:  make([]float64, len(Windows)),
+		}
+		var running uint64
+		for i := range LatencyBuckets {
+			running += t.buckets[i]
+			st.Buckets[i] = running
+		}
+		for i, w := range Windows {
+			st.BurnRate[i] = t.burnRate(at, int64(w/time.Minute))
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// burnRate is the error rate over the last minutes, including the current
+// one, divided by the error budget 1-Target. A burn rate of 1 spends the
+// budget exactly over the SLO period; no samples burn nothing.
+func (t *tracker) burnRate(at, minutes int64) float64 {
+	var good, total uint64
+	for _, m := range t.history {
+		if m.total > 0 && m.at > at-minutes && m.at <= at {
+			good += m.good
+			total += m.total
+		}
+	}
+	budget := 1 - t.obj.Target
+	if total == 0 || budget <= 0 {
+		return 0
+	}
+	return float64(total-good) / float64(total) / budget
+}
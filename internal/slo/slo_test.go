@@ -0,0 +1,111 @@
+package slo
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func setup(t *testing.T, rate float64) *time.Time {
+	t.Helper()
+	clock := time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() {
+		now = time.Now
+		Configure(Config{})
+	})
+	Configure(Config{
+		SampleRate: rate,
+		Objectives: []Objective{
+			{SLI: GatewayDelivery, Threshold: 500 * time.Millisecond, Target: 0.99},
+			{SLI: FederationDelivery, Threshold: 5 * time.Second, Target: 0.9},
+		},
+	})
+	return &clock
+}
+
+func TestTraceSampling(t *testing.T) {
+	setup(t, 0)
+	if _, ok := Start(Trace(context.Background(), time.Now())); ok {
+		t.Error("sample rate 0 traced a send")
+	}
+
+	setup(t, 1)
+	start := time.Now()
+	got, ok := Start(Trace(context.Background(), start))
+	if !ok || !got.Equal(start) {
+		t.Errorf("Start = %v, %v; want %v, true", got, ok, start)
+	}
+}
+
+func TestObserveCountsGoodAndBuckets(t *testing.T) {
+	setup(t, 1)
+	Observe(GatewayDelivery, 40*time.Millisecond, true)
+	Observe(GatewayDelivery, 300*time.Millisecond, true)
+	Observe(GatewayDelivery, 700*time.Millisecond, true) // over threshold
+	Observe(GatewayDelivery, 10*time.Millisecond, false) // failed
+	Observe(GatewayDelivery, time.Minute, true)          // above every bucket
+	Observe("unknown", time.Second, true)
+
+	st := Snapshot()
+	if len(st) != 2 || st[0].SLI != GatewayDelivery {
+		t.Fatalf("Snapshot = %+v", st)
+	}
+	g := st[0]
+	if g.Count != 5 || g.Good != 2 {
+		t.Errorf("count/good = %d/%d, want 5/2", g.Count, g.Good)
+	}
+	// Cumulative: <=50ms has 2, <=250ms 2, <=500ms 3, <=1s 4, <=10s 4.
+	want := []uint64{2, 2, 2, 3, 4, 4, 4, 4}
+	for i := range want {
+		if g.Buckets[i] != want[i] {
+			t.Errorf("bucket %v = %d, want %d", LatencyBuckets[i], g.Buckets[i], want[i])
+		}
+	}
+}
+
+func TestBurnRateWindows(t *testing.T) {
+	clock := setup(t, 1)
+
+	// An hour ago: 10 deliveries, all bad.
+	*clock = clock.Add(-time.Hour)
+	for range 10 {
+		Observe(FederationDelivery, time.Second, false)
+	}
+	// Now: 10 deliveries, 1 bad.
+	*clock = clock.Add(time.Hour)
+	for i := range 10 {
+		Observe(FederationDelivery, time.Second, i != 0)
+	}
+
+	fed := Snapshot()[1]
+	// 5m: 1/10 errors over a 0.1 budget burns at 1.
+	if got := fed.BurnRate[0]; math.Abs(got-1) > 1e-9 {
+		t.Errorf("5m burn = %v, want 1", got)
+	}
+	// 1h excludes the sample exactly an hour back.
+	if got := fed.BurnRate[2]; math.Abs(got-1) > 1e-9 {
+		t.Errorf("1h burn = %v, want 1", got)
+	}
+	// 6h: 11/20 errors over a 0.1 budget.
+	if got := fed.BurnRate[3]; math.Abs(got-5.5) > 1e-9 {
+		t.Errorf("6h burn = %v, want 5.5", got)
+	}
+	if got := Snapshot()[0].BurnRate[0]; got != 0 {
+		t.Errorf("burn with no samples = %v, want 0", got)
+	}
+}
+
+func TestObserveSinceUntraced(t *testing.T) {
+	setup(t, 1)
+	ObserveSince(context.Background(), GatewayDelivery, true)
+	if n := Snapshot()[0].Count; n != 0 {
+		t.Errorf("untraced delivery was counted: %d", n)
+	}
+	ctx := WithStart(context.Background(), now().Add(-100*time.Millisecond))
+	ObserveSince(ctx, GatewayDelivery, true)
+	if st := Snapshot()[0]; st.Count != 1 || st.Good != 1 {
+		t.Errorf("traced delivery: count %d good %d, want 1/1", st.Count, st.Good)
+	}
+}
@@ -0,0 +1,45 @@
+// Package spoilers handles spoiler markup in message content. Spoiler syntax
+// is ||text||; clients hide the text until it is clicked. Spoilers inside
+// code blocks (``` ```) and inline code (` `) are literal text.
+package spoilers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/amityvox/amityvox/internal/codespans"
+)
+
+// Placeholder replaces spoilered text in previews that cannot be revealed,
+// such as notifications.
+const Placeholder = "[spoiler]"
+
+// FilenamePrefix marks an uploaded file as a spoiler, the convention used
+// by other chat platforms and their exports.
+const FilenamePrefix = "SPOILER_"
+
+var spoilerRe = regexp.MustCompile(`(?s)\|\|.+?\|\|`)
+
+// Contains reports whether content has any spoiler outside code.
+func Contains(content string) bool {
+	found := false
+	codespans.EachOutside(content, func(text string) string {
+		if spoilerRe.MatchString(text) {
+			found = true
+		}
+		return text
+	})
+	return found
+}
+
+// Mask replaces every spoiler outside code with Placeholder.
+func Mask(content string) string {
+	return codespans.EachOutside(content, func(text string) string {
+		return spoilerRe.ReplaceAllString(text, Placeholder)
+	})
+}
+
+// IsSpoilerFilename reports whether a filename carries FilenamePrefix.
+func IsSpoilerFilename(name string) bool {
+	return strings.HasPrefix(strings.ToUpper(name), FilenamePrefix)
+}
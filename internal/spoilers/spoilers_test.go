@@ -0,0 +1,43 @@
+package spoilers
+
+import "testing"
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no spoiler", "hello world", "hello world"},
+		{"single", "the butler ||did it||", "the butler [spoiler]"},
+		{"multiple", "||a|| and ||b||", "[spoiler] and [spoiler]"},
+		{"multiline", "||line one\nline two|| end", "[spoiler] end"},
+		{"empty bars", "a |||| b", "a |||| b"},
+		{"unterminated", "a ||b", "a ||b"},
+		{"inline code", "`||x||` and ||y||", "`||x||` and [spoiler]"},
+		{"code block", "```\n||x||\n```", "```\n||x||\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mask(tt.content); got != tt.want {
+				t.Errorf("Mask(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+			if got, want := Contains(tt.content), tt.want != tt.content; got != want {
+				t.Errorf("Contains(%q) = %v, want %v", tt.content, got, want)
+			}
+		})
+	}
+}
+
+func TestIsSpoilerFilename(t *testing.T) {
+	for name, want := range map[string]bool{
+		"SPOILER_cat.png": true,
+		"spoiler_cat.png": true,
+		"cat.png":         false,
+		"SPOILER.png":     false,
+	} {
+		if got := IsSpoilerFilename(name); got != want {
+			t.Errorf("IsSpoilerFilename(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
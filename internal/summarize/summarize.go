@@ -0,0 +1,155 @@
+// Package summarize is the client for the optional language model that
+// writes catch-up recaps of channel activity. Any service exposing an
+// OpenAI-compatible chat completions API works. It is configured through
+// the AMITYVOX_SUMMARY_* environment variables; without them, activity
+// summaries carry statistics only.
+package summarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config locates the model endpoint.
+type Config struct {
+	APIURL string
+	APIKey string
+	Model  string
+}
+
+// FromEnv reads the summary model config from the environment. ok is false
+// when no model endpoint is configured.
+func FromEnv() (cfg Config, ok bool) {
+	cfg.APIURL = strings.TrimRight(os.Getenv("AMITYVOX_SUMMARY_API_URL"), "/")
+	if cfg.APIURL == "" {
+		return cfg, false
+	}
+	cfg.APIKey = os.Getenv("AMITYVOX_SUMMARY_API_KEY")
+	cfg.Model = os.Getenv("AMITYVOX_SUMMARY_MODEL")
+	return cfg, true
+}
+
+// MaxTranscriptBytes bounds the transcript sent to the model, so a long
+// absence does not produce an oversized prompt. The newest lines are kept.
+const MaxTranscriptBytes = 24 * 1024
+
+// Line is one message of a transcript.
+type Line struct {
+	Author  string
+	Content string
+}
+
+// ErrBadResponse means the model endpoint answered with something
+// unparseable or empty.
+var ErrBadResponse = errors.New("summarize: unparseable response")
+
+// StatusError is a non-200 answer from the model endpoint.
+type StatusError struct {
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("summarize: service returned status %d", e.Status)
+}
+
+const systemPrompt = "You summarize chat conversations for someone catching up after time away. " +
+	"Write a short recap of at most five sentences covering the main topics, decisions and open questions. " +
+	"Refer to people by the names shown. Do not invent details."
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model,omitempty"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// Recap asks the model for a recap of lines, given oldest first.
+func Recap(ctx context.Context, cfg Config, lines []Line) (string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: cfg.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: Transcript(lines, MaxTranscriptBytes)},
+		},
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.APIURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", &StatusError{Status: resp.StatusCode, Body: string(respBody)}
+	}
+
+	var out chatResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return "", ErrBadResponse
+	}
+	if len(out.Choices) == 0 {
+		return "", ErrBadResponse
+	}
+	recap := strings.TrimSpace(out.Choices[0].Message.Content)
+	if recap == "" {
+		return "", ErrBadResponse
+	}
+	return recap, nil
+}
+
+// Transcript renders lines as "author: content", one per line, dropping the
+// oldest lines until the result fits in maxBytes.
+func Transcript(lines []Line, maxBytes int) string {
+	rendered := make([]string, 0, len(lines))
+	size := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		content := strings.Join(strings.Fields(lines[i].Content), " ")
+		if content == "" {
+			continue
+		}
+		line := lines[i].Author + ": " + content
+		if size+len(line)+1 > maxBytes {
+			break
+		}
+		size += len(line) + 1
+		rendered = append(rendered, line)
+	}
+	for i, j := 0, len(rendered)-1; i < j; i, j = i+1, j-1 {
+		rendered[i], rendered[j] = rendered[j], rendered[i]
+	}
+	return strings.Join(rendered, "\n")
+}
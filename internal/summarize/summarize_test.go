@@ -0,0 +1,92 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTranscript(t *testing.T) {
+	lines := []Line{
+		{Author: "alice", Content: "first"},
+		{Author: "bob", Content: "  spread\nover  lines "},
+		{Author: "carol", Content: "   "},
+		{Author: "dave", Content: "last"},
+	}
+
+	got := Transcript(lines, 1000)
+	want := "alice: first\nbob: spread over lines\ndave: last"
+	if got != want {
+		t.Errorf("Transcript() = %q, want %q", got, want)
+	}
+
+	// Only the newest lines that fit are kept.
+	got = Transcript(lines, 40)
+	want = "bob: spread over lines\ndave: last"
+	if got != want {
+		t.Errorf("Transcript(40) = %q, want %q", got, want)
+	}
+}
+
+func TestRecap(t *testing.T) {
+	var gotAuth string
+	var gotReq chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotReq)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":" They planned the release. "}}]}`))
+	}))
+	defer srv.Close()
+
+	cfg := Config{APIURL: srv.URL, APIKey: "secret", Model: "small"}
+	recap, err := Recap(context.Background(), cfg, []Line{{Author: "alice", Content: "ship friday?"}})
+	if err != nil {
+		t.Fatalf("Recap() error = %v", err)
+	}
+	if recap != "They planned the release." {
+		t.Errorf("recap = %q", recap)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if gotReq.Model != "small" || len(gotReq.Messages) != 2 || gotReq.Messages[1].Content != "alice: ship friday?" {
+		t.Errorf("unexpected request %+v", gotReq)
+	}
+}
+
+func TestRecap_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		check  func(error) bool
+	}{
+		{"status", http.StatusTooManyRequests, `{}`, func(err error) bool {
+			var se *StatusError
+			return errors.As(err, &se) && se.Status == http.StatusTooManyRequests
+		}},
+		{"no choices", http.StatusOK, `{"choices":[]}`, func(err error) bool { return errors.Is(err, ErrBadResponse) }},
+		{"not json", http.StatusOK, `nope`, func(err error) bool { return errors.Is(err, ErrBadResponse) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := Recap(context.Background(), Config{APIURL: srv.URL}, []Line{{Author: "a", Content: "b"}})
+			if !tt.check(err) {
+				t.Errorf("Recap() error = %v", err)
+			}
+		})
+	}
+}
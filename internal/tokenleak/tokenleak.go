@@ -0,0 +1,306 @@
+// Package tokenleak responds to webhook and bot tokens found where they
+// should not be, such as a public paste or a pushed repository. Leaks are
+// reported by instance staff or by a paste scanning service calling the
+// report hook; either way the text is scanned for this instance's webhook
+// URLs and bot tokens, and every one that still works is revoked.
+//
+// A leaked webhook gets a new token at once, since its managers can read
+// the new URL from the webhook list. The guild is told in its system
+// channel, the owner gets a notification and the reset is recorded in the
+// guild audit log. A leaked bot token is deleted and the bot's owner is
+// notified; bot tokens are shown only when created, so the owner issues the
+// replacement.
+package tokenleak
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/auth"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/notifications"
+)
+
+// Report sources.
+const (
+	SourceStaff   = "staff"   // reported by instance staff
+	SourceScanner = "scanner" // reported through the scanning hook
+)
+
+// scannerActorID stands in for the reporter in the instance audit log when a
+// scanning service reports a leak.
+const scannerActorID = "token_leak_hook"
+
+var (
+	webhookURLPattern = regexp.MustCompile(`/api/v1/webhooks/([0-9A-Za-z]{26})/([A-Za-z0-9_-]{16,128})`)
+	botTokenPattern   = regexp.MustCompile(auth.BotTokenPrefix + `[0-9a-f]{64}`)
+)
+
+// Findings are the credentials found in a piece of text.
+type Findings struct {
+	Webhooks  []WebhookCredential
+	BotTokens []string
+}
+
+// WebhookCredential is a webhook ID and token, as found in a webhook URL.
+type WebhookCredential struct {
+	ID    string
+	Token string
+}
+
+// Empty reports whether nothing was found.
+func (f Findings) Empty() bool {
+	return len(f.Webhooks) == 0 && len(f.BotTokens) == 0
+}
+
+// Scan finds webhook URLs and bot tokens in text. Each credential is listed
+// once, in the order first seen. Whether they are valid is not checked.
+func Scan(text string) Findings {
+	var f Findings
+	seen := make(map[string]bool)
+	for _, m := range webhookURLPattern.FindAllStringSubmatch(text, -1) {
+		key := m[1] + "/" + m[2]
+		if !seen[key] {
+			seen[key] = true
+			f.Webhooks = append(f.Webhooks, WebhookCredential{ID: m[1], Token: m[2]})
+		}
+	}
+	for _, t := range botTokenPattern.FindAllString(text, -1) {
+		if !seen[t] {
+			seen[t] = true
+			f.BotTokens = append(f.BotTokens, t)
+		}
+	}
+	return f
+}
+
+// Report describes where leaked credentials were found.
+type Report struct {
+	Source     string  // SourceStaff or SourceScanner
+	ReporterID string  // staff user who reported the leak; empty for the hook
+	URL        *string // where the credentials were found, if known
+}
+
+// reason is the explanation recorded in audit entries.
+func (r Report) reason() string {
+	var s string
+	switch r.Source {
+	case SourceScanner:
+		s = "Token found by leak scanning"
+	case SourceStaff:
+		s = "Token reported leaked by instance staff"
+	default:
+		s = "Token found leaked"
+	}
+	if r.URL != nil && *r.URL != "" {
+		s += ": " + *r.URL
+	}
+	return s
+}
+
+// Result lists the credentials that were revoked. Credentials that were not
+// valid, or were already revoked, are left out.
+type Result struct {
+	Webhooks  []RevokedWebhook  `json:"webhooks"`
+	BotTokens []RevokedBotToken `json:"bot_tokens"`
+}
+
+// RevokedWebhook is a webhook whose token was reset.
+type RevokedWebhook struct {
+	ID        string `json:"id"`
+	GuildID   string `json:"guild_id"`
+	ChannelID string `json:"channel_id"`
+	Name      string `json:"name"`
+}
+
+// RevokedBotToken is a bot token that was deleted.
+type RevokedBotToken struct {
+	ID    string `json:"id"`
+	BotID string `json:"bot_id"`
+	Name  string `json:"name"`
+}
+
+// Responder revokes leaked credentials and tells the people affected.
+type Responder struct {
+	Pool          *pgxpool.Pool
+	EventBus      *events.Bus
+	Notifications *notifications.Service // optional — nil skips owner notifications
+	Logger        *slog.Logger
+}
+
+// Revoke revokes every valid credential in f. Alerts and audit entries that
+// fail are logged; only a failure to revoke is returned, and the credentials
+// revoked before it are still in the result.
+func (s *Responder) Revoke(ctx context.Context, f Findings, rep Report) (Result, error) {
+	res := Result{Webhooks: []RevokedWebhook{}, BotTokens: []RevokedBotToken{}}
+	for _, c := range f.Webhooks {
+		wh, ok, err := s.revokeWebhook(ctx, c, rep)
+		if err != nil {
+			return res, err
+		}
+		if ok {
+			res.Webhooks = append(res.Webhooks, wh)
+		}
+	}
+	for _, t := range f.BotTokens {
+		bt, ok, err := s.revokeBotToken(ctx, t, rep)
+		if err != nil {
+			return res, err
+		}
+		if ok {
+			res.BotTokens = append(res.BotTokens, bt)
+		}
+	}
+	if len(res.Webhooks) > 0 || len(res.BotTokens) > 0 {
+		s.Logger.Warn("revoked leaked tokens",
+			slog.String("source", rep.Source),
+			slog.Int("webhooks", len(res.Webhooks)),
+			slog.Int("bot_tokens", len(res.BotTokens)),
+		)
+	}
+	return res, nil
+}
+
+// revokeWebhook resets a webhook's token if c is still its current token,
+// then alerts the guild. Alerts are attributed to the reporting staff member,
+// or else to the webhook's creator, or the guild owner if that account is
+// gone.
+func (s *Responder) revokeWebhook(ctx context.Context, c WebhookCredential, rep Report) (RevokedWebhook, bool, error) {
+	token, err := newWebhookToken()
+	if err != nil {
+		return RevokedWebhook{}, false, err
+	}
+
+	var wh RevokedWebhook
+	var actorID, ownerID string
+	err = s.Pool.QueryRow(ctx,
+		`UPDATE webhooks w SET token = $3
+		 FROM guilds g
+		 WHERE w.id = $1 AND w.token = $2 AND g.id = w.guild_id
+		 RETURNING w.id, w.guild_id, w.channel_id, w.name, COALESCE(w.creator_id, g.owner_id), g.owner_id`,
+		c.ID, c.Token, token,
+	).Scan(&wh.ID, &wh.GuildID, &wh.ChannelID, &wh.Name, &actorID, &ownerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return RevokedWebhook{}, false, nil
+	}
+	if err != nil {
+		return RevokedWebhook{}, false, fmt.Errorf("resetting webhook token: %w", err)
+	}
+	if rep.ReporterID != "" {
+		actorID = rep.ReporterID
+	}
+
+	reason := rep.reason()
+	if _, err := s.Pool.Exec(ctx,
+		`INSERT INTO audit_log (id, guild_id, actor_id, action, target_type, target_id, reason, created_at)
+		 VALUES ($1, $2, $3, 'WEBHOOK_TOKEN_RESET', 'webhook', $4, $5, now())`,
+		models.NewULID().String(), wh.GuildID, actorID, wh.ID, reason); err != nil {
+		s.Logger.Warn("failed to audit leaked webhook reset",
+			slog.String("webhook_id", wh.ID), slog.String("error", err.Error()))
+	}
+
+	alert := fmt.Sprintf("Security alert: the URL of the webhook %q was found leaked, so it has been reset. "+
+		"Anything posting through this webhook needs its new URL from the server's webhook settings.", wh.Name)
+	if _, err := apiutil.PostSystemMessage(ctx, s.Pool, s.EventBus, wh.GuildID,
+		models.MessageTypeSystemSecurityAlert, actorID, &alert); err != nil {
+		s.Logger.Warn("failed to post leaked webhook alert",
+			slog.String("guild_id", wh.GuildID), slog.String("error", err.Error()))
+	}
+	s.notify(ctx, ownerID, actorID, &wh.GuildID, &wh.ChannelID, alert)
+
+	return wh, true, nil
+}
+
+// revokeBotToken deletes a bot token and notifies the bot's owner.
+func (s *Responder) revokeBotToken(ctx context.Context, raw string, rep Report) (RevokedBotToken, bool, error) {
+	sum := sha256.Sum256([]byte(raw))
+	var bt RevokedBotToken
+	var ownerID *string
+	err := s.Pool.QueryRow(ctx,
+		`DELETE FROM bot_tokens t USING users u
+		 WHERE t.token_hash = $1 AND u.id = t.bot_id
+		 RETURNING t.id, t.bot_id, t.name, u.bot_owner_id`,
+		hex.EncodeToString(sum[:]),
+	).Scan(&bt.ID, &bt.BotID, &bt.Name, &ownerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return RevokedBotToken{}, false, nil
+	}
+	if err != nil {
+		return RevokedBotToken{}, false, fmt.Errorf("revoking bot token: %w", err)
+	}
+
+	actorID := rep.ReporterID
+	if actorID == "" {
+		actorID = scannerActorID
+	}
+	reason := rep.reason()
+	if err := apiutil.LogStaffAction(ctx, s.Pool, apiutil.StaffAction{
+		ActorID:    actorID,
+		Action:     "bot_token_revoke",
+		TargetType: "bot",
+		TargetID:   bt.BotID,
+		Before:     map[string]string{"token_id": bt.ID, "name": bt.Name},
+		Reason:     &reason,
+	}); err != nil {
+		s.Logger.Warn("failed to audit leaked bot token",
+			slog.String("bot_id", bt.BotID), slog.String("error", err.Error()))
+	}
+
+	if ownerID != nil {
+		alert := fmt.Sprintf("Security alert: the bot token %q was found leaked, so it has been revoked. "+
+			"Create a new token for the bot and update wherever it runs.", bt.Name)
+		notifyActor := *ownerID
+		if rep.ReporterID != "" {
+			notifyActor = rep.ReporterID
+		}
+		s.notify(ctx, *ownerID, notifyActor, nil, nil, alert)
+	}
+
+	return bt, true, nil
+}
+
+// notify sends a security alert notification, when notifications are set up.
+func (s *Responder) notify(ctx context.Context, userID, actorID string, guildID, channelID *string, content string) {
+	if s.Notifications == nil {
+		return
+	}
+	n := &models.Notification{
+		UserID:    userID,
+		Type:      models.NotifTypeSecurityAlert,
+		GuildID:   guildID,
+		ChannelID: channelID,
+		ActorID:   actorID,
+		ActorName: "Security",
+		Content:   &content,
+	}
+	if guildID != nil {
+		var name string
+		if err := s.Pool.QueryRow(ctx, `SELECT name FROM guilds WHERE id = $1`, *guildID).Scan(&name); err == nil {
+			n.GuildName = &name
+		}
+	}
+	if err := s.Notifications.CreateNotification(ctx, s.EventBus, n); err != nil {
+		s.Logger.Warn("failed to send security alert notification",
+			slog.String("user_id", userID), slog.String("error", err.Error()))
+	}
+}
+
+// newWebhookToken returns a token in the form webhooks are created with.
+func newWebhookToken() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating webhook token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
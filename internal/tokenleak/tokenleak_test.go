@@ -0,0 +1,56 @@
+package tokenleak
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	webhookID := "01HZX3K9V4Q2M7N8P5R6S1T0AB"
+	webhookToken := "0123456789abcdef0123456789abcdef0123"
+	botToken := "avbot_" + strings.Repeat("ab", 32)
+
+	text := "config:\n" +
+		"  url: https://chat.example.com/api/v1/webhooks/" + webhookID + "/" + webhookToken + "\n" +
+		"  again: https://chat.example.com/api/v1/webhooks/" + webhookID + "/" + webhookToken + "?wait=true\n" +
+		"  BOT_TOKEN=" + botToken + "\n" +
+		"  not a token: avbot_1234\n" +
+		"  templates: https://chat.example.com/api/v1/webhooks/templates\n"
+
+	got := Scan(text)
+	want := Findings{
+		Webhooks:  []WebhookCredential{{ID: webhookID, Token: webhookToken}},
+		BotTokens: []string{botToken},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Scan() = %+v, want %+v", got, want)
+	}
+	if got.Empty() {
+		t.Error("Empty() = true for findings with credentials")
+	}
+}
+
+func TestScan_Nothing(t *testing.T) {
+	f := Scan("just a paste about webhooks, /api/v1/webhooks/short/abc")
+	if !f.Empty() {
+		t.Errorf("Scan() = %+v, want no findings", f)
+	}
+}
+
+func TestReportReason(t *testing.T) {
+	url := "https://paste.example.com/abc"
+	tests := []struct {
+		rep  Report
+		want string
+	}{
+		{Report{Source: SourceStaff}, "Token reported leaked by instance staff"},
+		{Report{Source: SourceScanner, URL: &url}, "Token found by leak scanning: " + url},
+		{Report{}, "Token found leaked"},
+	}
+	for _, tt := range tests {
+		if got := tt.rep.reason(); got != tt.want {
+			t.Errorf("reason() = %q, want %q", got, tt.want)
+		}
+	}
+}
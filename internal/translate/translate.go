@@ -0,0 +1,212 @@
+// Package translate is the client for the LibreTranslate instance that
+// backs message translation, plus the translation_cache table where results
+// are stored next to the original messages. It is configured through the
+// AMITYVOX_TRANSLATION_* environment variables.
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/amityvox/amityvox/internal/locale"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Config locates the translation service.
+type Config struct {
+	APIURL      string
+	DefaultLang string
+}
+
+// FromEnv reads the translation config from the environment. ok is false
+// when translation is disabled on this instance.
+func FromEnv() (cfg Config, ok bool) {
+	enabled := os.Getenv("AMITYVOX_TRANSLATION_ENABLED")
+	if enabled != "true" && enabled != "1" {
+		return cfg, false
+	}
+	cfg.APIURL = os.Getenv("AMITYVOX_TRANSLATION_API_URL")
+	if cfg.APIURL == "" {
+		cfg.APIURL = "http://localhost:5000"
+	}
+	cfg.DefaultLang = os.Getenv("AMITYVOX_TRANSLATION_DEFAULT_LANG")
+	if cfg.DefaultLang == "" {
+		cfg.DefaultLang = "en"
+	}
+	return cfg, true
+}
+
+// Errors returned by Translate besides transport failures.
+var (
+	// ErrBadResponse means the service answered with something unparseable.
+	ErrBadResponse = errors.New("translate: unparseable response")
+	// ErrGarbage means the service returned repeated-word output, which
+	// LibreTranslate produces when its language models fail to load.
+	ErrGarbage = errors.New("translate: service returned invalid output")
+)
+
+// StatusError is a non-200 answer from the translation service.
+type StatusError struct {
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("translate: service returned status %d", e.Status)
+}
+
+// libreTranslateRequest is the request body sent to the LibreTranslate API.
+type libreTranslateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+}
+
+// libreTranslateResponse is the response from the LibreTranslate API.
+type libreTranslateResponse struct {
+	TranslatedText   string `json:"translatedText"`
+	DetectedLanguage struct {
+		Confidence float64 `json:"confidence"`
+		Language   string  `json:"language"`
+	} `json:"detectedLanguage"`
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Translate translates text into target, detecting the source language.
+// The source language is "auto" when the service does not report one.
+func Translate(ctx context.Context, cfg Config, text, target string) (models.MessageTranslation, error) {
+	return TranslateFrom(ctx, cfg, text, "auto", target)
+}
+
+// SourceFor picks the source language for text posted in a channel whose
+// language is channelLang. Detection is unreliable on short messages, so
+// the channel's language is used, unless it is the target: a message in
+// such a channel that needs translating is in some other language.
+func SourceFor(channelLang, target string) string {
+	if channelLang == "" {
+		return "auto"
+	}
+	if base, err := locale.ParseLanguage(target); err == nil && base == channelLang {
+		return "auto"
+	}
+	return channelLang
+}
+
+// TranslateFrom translates text from source into target. A source of
+// "auto" has the service detect it.
+func TranslateFrom(ctx context.Context, cfg Config, text, source, target string) (models.MessageTranslation, error) {
+	out := models.MessageTranslation{TargetLang: target}
+	body, err := json.Marshal(libreTranslateRequest{Q: text, Source: source, Target: target, Format: "text"})
+	if err != nil {
+		return out, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.APIURL+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return out, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return out, &StatusError{Status: resp.StatusCode, Body: string(respBody)}
+	}
+
+	var ltResp libreTranslateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ltResp); err != nil {
+		return out, ErrBadResponse
+	}
+	if IsRepeatedWordGarbage(ltResp.TranslatedText) {
+		return out, ErrGarbage
+	}
+
+	out.Text = ltResp.TranslatedText
+	out.SourceLang = ltResp.DetectedLanguage.Language
+	if out.SourceLang == "" {
+		out.SourceLang = source
+	}
+	return out, nil
+}
+
+// Cached returns the stored translation of a message into target, if any.
+func Cached(ctx context.Context, pool *pgxpool.Pool, messageID, target string) (models.MessageTranslation, bool, error) {
+	t := models.MessageTranslation{TargetLang: target}
+	err := pool.QueryRow(ctx,
+		`SELECT translated_text, source_lang FROM translation_cache
+		 WHERE message_id = $1 AND target_lang = $2`,
+		messageID, target,
+	).Scan(&t.Text, &t.SourceLang)
+	if err == pgx.ErrNoRows {
+		return t, false, nil
+	}
+	return t, err == nil, err
+}
+
+// Store saves a translation of a message, replacing any earlier one into
+// the same language.
+func Store(ctx context.Context, pool *pgxpool.Pool, messageID string, t models.MessageTranslation) error {
+	_, err := pool.Exec(ctx,
+		`INSERT INTO translation_cache (id, message_id, source_lang, target_lang, translated_text, created_at)
+		 VALUES ($1, $2, $3, $4, $5, now())
+		 ON CONFLICT (message_id, target_lang) DO UPDATE SET
+		   translated_text = EXCLUDED.translated_text,
+		   source_lang = EXCLUDED.source_lang`,
+		models.NewULID().String(), messageID, t.SourceLang, t.TargetLang, t.Text,
+	)
+	return err
+}
+
+// IsRepeatedWordGarbage detects translation output that indicates a LibreTranslate
+// model failure. Catches two patterns:
+// 1. Space-separated: "MAINSTREAM MAINSTREAM MAINSTREAM"
+// 2. Concatenated: "MAINSTREMAINSTREMAINSTRE..." (single long token with repeated substring)
+func IsRepeatedWordGarbage(text string) bool {
+	// Pattern 1: all space-separated words are the same.
+	words := strings.Fields(text)
+	if len(words) >= 3 {
+		first := strings.ToLower(words[0])
+		allSame := true
+		for _, w := range words[1:] {
+			if strings.ToLower(w) != first {
+				allSame = false
+				break
+			}
+		}
+		if allSame {
+			return true
+		}
+	}
+
+	// Pattern 2: a short substring (3-20 chars) repeats 5+ times in the text.
+	lower := strings.ToLower(text)
+	if len(lower) < 30 {
+		return false
+	}
+	for subLen := 3; subLen <= 20 && subLen <= len(lower)/5; subLen++ {
+		sub := lower[:subLen]
+		count := strings.Count(lower, sub)
+		if count >= 5 && len(sub)*count >= len(lower)/2 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,39 @@
+package translate
+
+import "testing"
+
+func TestIsRepeatedWordGarbage(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"MAINSTREAM MAINSTREAM MAINSTREAM", true},
+		{"mainstream Mainstream MAINSTREAM mainstream", true},
+		{"MAINSTREMAINSTREMAINSTREMAINSTREMAINSTREMAINSTRE", true},
+		{"Hola, ¿cómo estás?", false},
+		{"no no", false},
+		{"The quick brown fox jumps over the lazy dog again and again", false},
+	}
+	for _, tt := range tests {
+		if got := IsRepeatedWordGarbage(tt.text); got != tt.want {
+			t.Errorf("IsRepeatedWordGarbage(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSourceFor(t *testing.T) {
+	tests := []struct {
+		channelLang, target, want string
+	}{
+		{"", "en", "auto"},
+		{"ja", "en", "ja"},
+		{"ja", "ja", "auto"},
+		{"pt", "pt-BR", "auto"},
+		{"de", "en-US", "de"},
+	}
+	for _, tt := range tests {
+		if got := SourceFor(tt.channelLang, tt.target); got != tt.want {
+			t.Errorf("SourceFor(%q, %q) = %q, want %q", tt.channelLang, tt.target, got, tt.want)
+		}
+	}
+}
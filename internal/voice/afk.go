@@ -0,0 +1,189 @@
+package voice
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// afkSweepInterval is how often idle members are looked for. A member may
+// stay up to this long past their guild's AFK timeout.
+const afkSweepInterval = 30 * time.Second
+
+// MarkActive records that a user in voice is active now.
+func (s *Service) MarkActive(userID string) {
+	s.statesMu.Lock()
+	defer s.statesMu.Unlock()
+	if vs, ok := s.states[userID]; ok {
+		vs.LastActiveAt = time.Now()
+	}
+}
+
+// hasLiveMicrophone reports whether a participant publishes an unmuted
+// microphone track, which is what counts as activity for AFK purposes.
+func hasLiveMicrophone(p *livekit.ParticipantInfo) bool {
+	for _, t := range p.GetTracks() {
+		if t.GetType() == livekit.TrackType_AUDIO && t.GetSource() != livekit.TrackSource_SCREEN_SHARE_AUDIO && !t.GetMuted() {
+			return true
+		}
+	}
+	return false
+}
+
+// afkDue reports whether a member last active at lastActive has been idle
+// for at least timeoutSeconds. A timeout of zero disables AFK moves.
+func afkDue(lastActive, now time.Time, timeoutSeconds int) bool {
+	return timeoutSeconds > 0 && now.Sub(lastActive) >= time.Duration(timeoutSeconds)*time.Second
+}
+
+// StartAFKSweep periodically moves idle members to their guild's AFK
+// channel until ctx is cancelled.
+func (s *Service) StartAFKSweep(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(afkSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.sweepAFK(ctx); err != nil {
+					s.logger.Warn("AFK sweep failed", slog.String("error", err.Error()))
+				}
+			}
+		}
+	}()
+}
+
+// guildAFK is a guild's AFK channel and timeout in seconds.
+type guildAFK struct {
+	channelID string
+	timeout   int
+}
+
+// sweepAFK moves every member idle past their guild's AFK timeout. Rooms
+// with candidates are polled first, since LiveKit sends no webhook when a
+// microphone is muted or unmuted.
+func (s *Service) sweepAFK(ctx context.Context) error {
+	s.statesMu.RLock()
+	var states []VoiceState
+	guildSet := map[string]bool{}
+	for _, vs := range s.states {
+		if vs.GuildID != "" && !vs.AFK {
+			states = append(states, *vs)
+			guildSet[vs.GuildID] = true
+		}
+	}
+	s.statesMu.RUnlock()
+	if len(states) == 0 || s.pool == nil {
+		return nil
+	}
+
+	guildIDs := make([]string, 0, len(guildSet))
+	for id := range guildSet {
+		guildIDs = append(guildIDs, id)
+	}
+	rows, err := s.pool.Query(ctx,
+		`SELECT id, afk_channel_id, afk_timeout FROM guilds
+		 WHERE id = ANY($1) AND afk_channel_id IS NOT NULL AND afk_timeout > 0`, guildIDs)
+	if err != nil {
+		return err
+	}
+	afk := map[string]guildAFK{}
+	for rows.Next() {
+		var id string
+		var cfg guildAFK
+		if rows.Scan(&id, &cfg.channelID, &cfg.timeout) == nil {
+			afk[id] = cfg
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	polled := map[string]map[string]bool{}
+	for _, vs := range states {
+		cfg, ok := afk[vs.GuildID]
+		if !ok || vs.ChannelID == cfg.channelID || !afkDue(vs.LastActiveAt, now, cfg.timeout) {
+			continue
+		}
+		live, ok := polled[vs.ChannelID]
+		if !ok {
+			live = map[string]bool{}
+			participants, err := s.ListParticipants(ctx, vs.ChannelID)
+			if err != nil {
+				s.logger.Warn("failed to poll voice room for AFK",
+					slog.String("channel_id", vs.ChannelID), slog.String("error", err.Error()))
+				continue
+			}
+			for _, p := range participants {
+				if hasLiveMicrophone(p) {
+					live[p.GetIdentity()] = true
+				}
+			}
+			polled[vs.ChannelID] = live
+		}
+		if live[vs.UserID] {
+			s.MarkActive(vs.UserID)
+			continue
+		}
+		s.moveToAFK(ctx, vs, cfg.channelID)
+	}
+	return nil
+}
+
+// moveToAFK disconnects an idle member and places them in the AFK channel,
+// where joining grants no publish rights. Clients learn of the move from the
+// VOICE_STATE_UPDATE pair, which carries afk: true.
+func (s *Service) moveToAFK(ctx context.Context, vs VoiceState, afkChannelID string) {
+	s.statesMu.Lock()
+	cur, ok := s.states[vs.UserID]
+	if !ok || cur.ChannelID != vs.ChannelID {
+		s.statesMu.Unlock()
+		return // left or moved since the snapshot
+	}
+	cur.ChannelID = afkChannelID
+	cur.AFK = true
+	cur.Muted = true
+	cur.JoinedAt = time.Now()
+	cur.LastActiveAt = time.Now()
+	s.statesMu.Unlock()
+
+	if err := s.RemoveParticipant(ctx, vs.ChannelID, vs.UserID); err != nil {
+		s.logger.Warn("failed to remove AFK participant",
+			slog.String("user_id", vs.UserID), slog.String("error", err.Error()))
+	}
+	if err := s.EnsureRoom(ctx, afkChannelID); err != nil {
+		s.logger.Warn("failed to ensure AFK voice room", slog.String("error", err.Error()))
+	}
+
+	s.logger.Info("moved idle member to AFK channel",
+		slog.String("user_id", vs.UserID), slog.String("guild_id", vs.GuildID))
+	if s.bus == nil {
+		return
+	}
+	s.bus.PublishGuildEvent(ctx, events.SubjectVoiceStateUpdate, "VOICE_STATE_UPDATE", vs.GuildID, map[string]interface{}{
+		"user_id":    vs.UserID,
+		"guild_id":   vs.GuildID,
+		"channel_id": vs.ChannelID,
+		"action":     "leave",
+		"afk":        true,
+	})
+	s.bus.PublishGuildEvent(ctx, events.SubjectVoiceStateUpdate, "VOICE_STATE_UPDATE", vs.GuildID, map[string]interface{}{
+		"user_id":    vs.UserID,
+		"guild_id":   vs.GuildID,
+		"channel_id": afkChannelID,
+		"self_mute":  vs.SelfMute,
+		"self_deaf":  vs.SelfDeaf,
+		"muted":      true,
+		"deafened":   vs.Deafened,
+		"action":     "join",
+		"afk":        true,
+	})
+}
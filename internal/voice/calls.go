@@ -0,0 +1,286 @@
+package voice
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+const (
+	// CallRingTimeout is how long a DM call rings before the recipients who
+	// have not answered are treated as declining.
+	CallRingTimeout = 30 * time.Second
+	// callSweepInterval is how often calls ringing past the timeout are
+	// ended, which bounds how late a missed call is noticed.
+	callSweepInterval = 5 * time.Second
+)
+
+// Call statuses in dm_calls.status.
+const (
+	CallStatusRinging  = "ringing"
+	CallStatusActive   = "active"
+	CallStatusEnded    = "ended"
+	CallStatusMissed   = "missed"
+	CallStatusDeclined = "declined"
+)
+
+// Call is a voice call in a DM or group DM, from the first ring until the
+// last participant hangs up.
+type Call struct {
+	ID              string     `json:"id"`
+	ChannelID       string     `json:"channel_id"`
+	CallerID        string     `json:"caller_id"`
+	Status          string     `json:"status"`
+	ParticipantIDs  []string   `json:"participant_ids"`
+	DeclinedIDs     []string   `json:"declined_ids"`
+	StartedAt       time.Time  `json:"started_at"`
+	AnsweredAt      *time.Time `json:"answered_at,omitempty"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	DurationSeconds *int       `json:"duration_seconds,omitempty"`
+}
+
+const callColumns = `id, channel_id, caller_id, status, participant_ids, declined_ids,
+	started_at, answered_at, ended_at, duration_seconds`
+
+func scanCall(row pgx.Row) (*Call, error) {
+	var c Call
+	err := row.Scan(&c.ID, &c.ChannelID, &c.CallerID, &c.Status, &c.ParticipantIDs, &c.DeclinedIDs,
+		&c.StartedAt, &c.AnsweredAt, &c.EndedAt, &c.DurationSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// JoinCall records a user joining voice in a DM or group DM. Joining a
+// channel with no open call starts one with the user as caller, and started
+// is true so the caller can ring the recipients. Anyone else joining a
+// ringing call answers it, and every join to an open call is announced.
+func (s *Service) JoinCall(ctx context.Context, channelID, userID string) (call *Call, started bool, err error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		call, err = scanCall(s.pool.QueryRow(ctx,
+			`UPDATE dm_calls SET
+			     participant_ids = CASE WHEN $2 = ANY(participant_ids) THEN participant_ids
+			                            ELSE array_append(participant_ids, $2) END,
+			     status = CASE WHEN status = 'ringing' AND caller_id <> $2 THEN 'active' ELSE status END,
+			     answered_at = CASE WHEN status = 'ringing' AND caller_id <> $2 THEN now() ELSE answered_at END
+			 WHERE channel_id = $1 AND ended_at IS NULL
+			 RETURNING `+callColumns, channelID, userID))
+		if err == nil {
+			s.publishCall(ctx, call)
+			return call, false, nil
+		}
+		if err != pgx.ErrNoRows {
+			return nil, false, fmt.Errorf("joining call: %w", err)
+		}
+
+		call, err = scanCall(s.pool.QueryRow(ctx,
+			`INSERT INTO dm_calls (id, channel_id, caller_id, participant_ids)
+			 VALUES ($1, $2, $3, ARRAY[$3])
+			 ON CONFLICT (channel_id) WHERE ended_at IS NULL DO NOTHING
+			 RETURNING `+callColumns, models.NewULID().String(), channelID, userID))
+		if err == nil {
+			return call, true, nil
+		}
+		if err != pgx.ErrNoRows {
+			return nil, false, fmt.Errorf("starting call: %w", err)
+		}
+		// Someone else started a call at the same moment; join theirs.
+	}
+	return nil, false, fmt.Errorf("joining call: channel %s kept changing", channelID)
+}
+
+// DeclineCall records a recipient declining a ringing call. Once every
+// recipient but the caller has declined, the call ends and the caller is
+// hung up. It returns pgx.ErrNoRows when no call is ringing for the user.
+func (s *Service) DeclineCall(ctx context.Context, channelID, userID string) (*Call, error) {
+	call, err := scanCall(s.pool.QueryRow(ctx,
+		`UPDATE dm_calls SET declined_ids = array_append(declined_ids, $2)
+		 WHERE channel_id = $1 AND status = 'ringing' AND caller_id <> $2 AND NOT $2 = ANY(declined_ids)
+		 RETURNING `+callColumns, channelID, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	var waiting bool
+	if err := s.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM channel_recipients
+		               WHERE channel_id = $1 AND user_id <> $2 AND NOT user_id = ANY($3))`,
+		channelID, call.CallerID, call.DeclinedIDs).Scan(&waiting); err != nil {
+		return nil, fmt.Errorf("checking call recipients: %w", err)
+	}
+	if waiting {
+		s.publishCall(ctx, call)
+		return call, nil
+	}
+
+	ended, err := scanCall(s.pool.QueryRow(ctx,
+		`UPDATE dm_calls SET status = 'declined', ended_at = now()
+		 WHERE id = $1 AND status = 'ringing'
+		 RETURNING `+callColumns, call.ID))
+	if err == pgx.ErrNoRows {
+		return call, nil // answered or timed out meanwhile
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ending declined call: %w", err)
+	}
+	s.finishUnanswered(ctx, ended)
+	return ended, nil
+}
+
+// EndCallIfEmpty ends the open call in a DM or group DM once nobody is left
+// in its voice channel. A call that was never answered is logged as missed.
+func (s *Service) EndCallIfEmpty(ctx context.Context, channelID string) {
+	if s.pool == nil || len(s.GetChannelVoiceStates(channelID)) > 0 {
+		return
+	}
+	call, err := scanCall(s.pool.QueryRow(ctx,
+		`UPDATE dm_calls SET ended_at = now(),
+		     status = CASE WHEN status = 'ringing' THEN 'missed' ELSE 'ended' END,
+		     duration_seconds = CASE WHEN answered_at IS NOT NULL
+		                             THEN EXTRACT(EPOCH FROM now() - answered_at)::int END
+		 WHERE channel_id = $1 AND ended_at IS NULL
+		 RETURNING `+callColumns, channelID))
+	if err == pgx.ErrNoRows {
+		return
+	}
+	if err != nil {
+		s.logger.Warn("failed to end call",
+			slog.String("channel_id", channelID), slog.String("error", err.Error()))
+		return
+	}
+	if call.Status == CallStatusMissed {
+		s.postMissedCall(ctx, call)
+	}
+	s.publishCall(ctx, call)
+}
+
+// GetCallLog returns a channel's calls, newest first. Pass the ID of the
+// last call of a page as before to fetch the next one.
+func (s *Service) GetCallLog(ctx context.Context, channelID, before string, limit int) ([]Call, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+callColumns+` FROM dm_calls
+		 WHERE channel_id = $1 AND ($2 = '' OR id < $2)
+		 ORDER BY id DESC LIMIT $3`, channelID, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying call log: %w", err)
+	}
+	defer rows.Close()
+
+	calls := make([]Call, 0)
+	for rows.Next() {
+		c, err := scanCall(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning call: %w", err)
+		}
+		calls = append(calls, *c)
+	}
+	return calls, rows.Err()
+}
+
+// StartCallSweep periodically ends calls that rang past CallRingTimeout
+// until ctx is cancelled.
+func (s *Service) StartCallSweep(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(callSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.expireRingingCalls(ctx); err != nil {
+					s.logger.Warn("call ring sweep failed", slog.String("error", err.Error()))
+				}
+			}
+		}
+	}()
+}
+
+// expireRingingCalls ends every call nobody answered within CallRingTimeout.
+func (s *Service) expireRingingCalls(ctx context.Context) error {
+	if s.pool == nil {
+		return nil
+	}
+	rows, err := s.pool.Query(ctx,
+		`UPDATE dm_calls SET status = 'missed', ended_at = now()
+		 WHERE status = 'ringing' AND started_at <= now() - make_interval(secs => $1)
+		 RETURNING `+callColumns, CallRingTimeout.Seconds())
+	if err != nil {
+		return err
+	}
+	var expired []*Call
+	for rows.Next() {
+		c, err := scanCall(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		expired = append(expired, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range expired {
+		s.finishUnanswered(ctx, c)
+	}
+	return nil
+}
+
+// finishUnanswered wraps up a call that ended while ringing: it posts the
+// missed call notice, tells the recipients, and hangs up the caller, who
+// would otherwise wait alone in the room.
+func (s *Service) finishUnanswered(ctx context.Context, call *Call) {
+	s.postMissedCall(ctx, call)
+	s.publishCall(ctx, call)
+	for _, vs := range s.GetChannelVoiceStates(call.ChannelID) {
+		if err := s.RemoveParticipant(ctx, call.ChannelID, vs.UserID); err != nil {
+			s.logger.Debug("failed to hang up unanswered caller",
+				slog.String("user_id", vs.UserID), slog.String("error", err.Error()))
+		}
+		s.dropState(ctx, vs.UserID, call.ChannelID)
+	}
+}
+
+// postMissedCall posts a system message recording a call nobody answered,
+// authored by the caller.
+func (s *Service) postMissedCall(ctx context.Context, call *Call) {
+	msg := models.Message{
+		ID:          models.NewULID().String(),
+		ChannelID:   call.ChannelID,
+		AuthorID:    call.CallerID,
+		MessageType: models.MessageTypeSystemMissedCall,
+	}
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO messages (id, channel_id, author_id, message_type, flags, created_at)
+		 VALUES ($1, $2, $3, $4, 0, now())
+		 RETURNING created_at`,
+		msg.ID, msg.ChannelID, msg.AuthorID, msg.MessageType).Scan(&msg.CreatedAt)
+	if err != nil {
+		s.logger.Warn("failed to post missed call message",
+			slog.String("channel_id", call.ChannelID), slog.String("error", err.Error()))
+		return
+	}
+	s.pool.Exec(ctx, `UPDATE channels SET last_message_id = $1 WHERE id = $2`, msg.ID, msg.ChannelID)
+
+	if s.bus != nil {
+		s.bus.PublishChannelEvent(ctx, events.SubjectMessageCreate, "MESSAGE_CREATE", msg.ChannelID, msg)
+	}
+}
+
+// publishCall tells the channel's recipients a call changed state, so
+// clients can stop ringing once it is answered, declined or over.
+func (s *Service) publishCall(ctx context.Context, call *Call) {
+	if s.bus == nil {
+		return
+	}
+	s.bus.PublishChannelEvent(ctx, events.SubjectCallUpdate, "CALL_UPDATE", call.ChannelID, call)
+}
@@ -0,0 +1,95 @@
+package voice
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/livekit/protocol/livekit"
+)
+
+// Voice input modes. Voice activation transmits whenever the user speaks;
+// push-to-talk only while a key is held.
+const (
+	InputModeVAD = "vad"
+	InputModePTT = "ptt"
+)
+
+// AttrInputMode is the participant attribute naming the input mode a
+// channel requires. Clients read it from their own participant and must not
+// offer voice activation when it is "ptt".
+const AttrInputMode = "input_mode"
+
+// ChannelInputMode is the input mode a guild requires in a voice channel.
+type ChannelInputMode struct {
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+	InputMode string `json:"input_mode"` // "vad" allows voice activation, "ptt" requires push-to-talk
+}
+
+// ValidInputMode reports whether mode is a known input mode.
+func ValidInputMode(mode string) bool {
+	return mode == InputModeVAD || mode == InputModePTT
+}
+
+// GetChannelInputMode loads the input mode a voice channel requires.
+// Channels that set none allow voice activation.
+func (s *Service) GetChannelInputMode(ctx context.Context, guildID, channelID string) (*ChannelInputMode, error) {
+	mode := &ChannelInputMode{ChannelID: channelID, GuildID: guildID, InputMode: InputModeVAD}
+	err := s.pool.QueryRow(ctx,
+		`SELECT input_mode FROM voice_channel_modes WHERE channel_id = $1`, channelID,
+	).Scan(&mode.InputMode)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("loading channel input mode: %w", err)
+	}
+	return mode, nil
+}
+
+// UpdateChannelInputMode stores the input mode a voice channel requires and
+// applies it to everyone already connected: their tracked state follows the
+// new mode and their participant attributes are rewritten, so clients switch
+// without rejoining.
+func (s *Service) UpdateChannelInputMode(ctx context.Context, mode *ChannelInputMode) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO voice_channel_modes (channel_id, guild_id, input_mode, updated_at)
+		 VALUES ($1, $2, $3, now())
+		 ON CONFLICT (channel_id) DO UPDATE SET
+		    input_mode = EXCLUDED.input_mode,
+		    updated_at = now()`,
+		mode.ChannelID, mode.GuildID, mode.InputMode,
+	)
+	if err != nil {
+		return fmt.Errorf("upserting channel input mode: %w", err)
+	}
+
+	required := mode.InputMode == InputModePTT
+	srv := s.roomServer(ctx, mode.ChannelID)
+	for _, vs := range s.GetChannelVoiceStates(mode.ChannelID) {
+		s.SetPTTRequired(vs.UserID, required)
+		_, err := srv.client.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+			Room:       mode.ChannelID,
+			Identity:   vs.UserID,
+			Attributes: map[string]string{AttrInputMode: mode.InputMode},
+		})
+		if err != nil {
+			s.logger.Debug("failed to update participant input mode",
+				slog.String("user_id", vs.UserID), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// SetPTTRequired records whether a user's channel requires push-to-talk. A
+// required user's input mode is pinned to push-to-talk until the channel
+// lifts the requirement or the user leaves.
+func (s *Service) SetPTTRequired(userID string, required bool) {
+	s.statesMu.Lock()
+	defer s.statesMu.Unlock()
+	if vs, ok := s.states[userID]; ok {
+		vs.PTTRequired = required
+		if required {
+			vs.InputMode = InputModePTT
+		}
+	}
+}
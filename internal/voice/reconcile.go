@@ -0,0 +1,113 @@
+package voice
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	// reconcileInterval is how often tracked voice state is checked against
+	// the participants LiveKit actually has.
+	reconcileInterval = time.Minute
+	// reconcileGrace is how long a new state may go without a LiveKit
+	// participant, giving the client time to connect with its token.
+	reconcileGrace = time.Minute
+)
+
+// StartReconcile periodically drops voice states whose user is no longer
+// connected to LiveKit until ctx is cancelled. It backs up the webhooks,
+// which are lost while this process is down or misconfigured.
+func (s *Service) StartReconcile(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.reconcileStates(ctx); err != nil {
+					s.logger.Warn("voice state reconcile failed", slog.String("error", err.Error()))
+				}
+			}
+		}
+	}()
+}
+
+// staleStates returns the states past their grace period whose user is not
+// a participant of their room. connected maps each polled room to its
+// participant identities; rooms LiveKit has no record of map to an empty
+// set, and rooms that could not be polled are absent and never stale.
+func staleStates(states []VoiceState, connected map[string]map[string]bool, now time.Time) []VoiceState {
+	var stale []VoiceState
+	for _, vs := range states {
+		if now.Sub(vs.JoinedAt) < reconcileGrace {
+			continue
+		}
+		participants, ok := connected[vs.ChannelID]
+		if ok && !participants[vs.UserID] {
+			stale = append(stale, vs)
+		}
+	}
+	return stale
+}
+
+// reconcileStates compares every tracked state with LiveKit's rooms and
+// drops the ones LiveKit no longer has.
+func (s *Service) reconcileStates(ctx context.Context) error {
+	s.statesMu.RLock()
+	states := make([]VoiceState, 0, len(s.states))
+	roomSet := map[string]bool{}
+	for _, vs := range s.states {
+		states = append(states, *vs)
+		roomSet[vs.ChannelID] = true
+	}
+	s.statesMu.RUnlock()
+	if len(states) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(roomSet))
+	for name := range roomSet {
+		names = append(names, name)
+	}
+	// Rooms may be on any region's server. A server that cannot be asked
+	// aborts the sweep rather than dropping the states it holds.
+	active := map[string]bool{}
+	for _, srv := range s.servers {
+		resp, err := srv.client.ListRooms(ctx, &livekit.ListRoomsRequest{Names: names})
+		if err != nil {
+			return err
+		}
+		for _, room := range resp.GetRooms() {
+			active[room.GetName()] = true
+		}
+	}
+
+	connected := map[string]map[string]bool{}
+	for _, name := range names {
+		participants := map[string]bool{}
+		if active[name] {
+			list, err := s.ListParticipants(ctx, name)
+			if err != nil {
+				s.logger.Warn("failed to poll voice room for reconcile",
+					slog.String("channel_id", name), slog.String("error", err.Error()))
+				continue
+			}
+			for _, p := range list {
+				participants[p.GetIdentity()] = true
+			}
+		}
+		connected[name] = participants
+	}
+
+	for _, vs := range staleStates(states, connected, time.Now()) {
+		s.logger.Info("dropping stale voice state",
+			slog.String("user_id", vs.UserID), slog.String("channel_id", vs.ChannelID))
+		s.dropState(ctx, vs.UserID, vs.ChannelID)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package voice
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/livekit/protocol/livekit"
+	lksdk "github.com/livekit/server-sdk-go/v2"
+
+	"github.com/amityvox/amityvox/internal/regions"
+)
+
+// roomReplaceAfter is how long a room's placement is kept even while the
+// room is empty, so clients that were handed the server but have not yet
+// connected are not split from later joiners.
+const roomReplaceAfter = 30 * time.Second
+
+// RegionConfig is a LiveKit server in one deployment region.
+type RegionConfig struct {
+	Name      string
+	URL       string // internal URL for the server SDK
+	PublicURL string // browser-facing URL; empty means URL
+	APIKey    string
+	APISecret string
+}
+
+// server is the LiveKit server of one region.
+type server struct {
+	client    *lksdk.RoomServiceClient
+	apiKey    string
+	apiSecret string
+	publicURL string
+}
+
+func newServer(url, publicURL, apiKey, apiSecret string) *server {
+	if publicURL == "" {
+		publicURL = url
+	}
+	return &server{
+		client:    lksdk.NewRoomServiceClient(url, apiKey, apiSecret),
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		publicURL: publicURL,
+	}
+}
+
+// roomServer returns the server hosting room: the one it was placed on, or
+// the primary for rooms never placed or placed in a region no longer
+// configured.
+func (s *Service) roomServer(ctx context.Context, room string) *server {
+	if len(s.servers) == 1 || s.pool == nil {
+		return s.servers[0]
+	}
+	var region string
+	s.pool.QueryRow(ctx,
+		`SELECT region FROM voice_room_regions WHERE room = $1`, room).Scan(&region)
+	if i := s.regions.Index(region); i >= 0 {
+		return s.servers[i]
+	}
+	return s.servers[0]
+}
+
+// placeRoom picks the server for a room a client in the preferred region is
+// joining. A room with participants on a healthy server stays there; an
+// empty room, or one whose server is unhealthy, moves to the region picked
+// for the client. A placement made in the last roomReplaceAfter is kept
+// unless its region is unhealthy, so concurrent first joins agree.
+func (s *Service) placeRoom(ctx context.Context, room, preferred string) *server {
+	if len(s.servers) == 1 || s.pool == nil {
+		return s.servers[0]
+	}
+
+	var current string
+	s.pool.QueryRow(ctx,
+		`SELECT region FROM voice_room_regions WHERE room = $1`, room).Scan(&current)
+	if i := s.regions.Index(current); i >= 0 && s.regions.Healthy(i) {
+		resp, err := s.servers[i].client.ListParticipants(ctx, &livekit.ListParticipantsRequest{Room: room})
+		if err == nil && len(resp.GetParticipants()) > 0 {
+			return s.servers[i]
+		}
+	}
+
+	healthy := make([]string, 0, s.regions.Len())
+	for i := 0; i < s.regions.Len(); i++ {
+		if s.regions.Healthy(i) {
+			healthy = append(healthy, s.regions.Name(i))
+		}
+	}
+	picked := s.regions.Pick(preferred)
+	var region string
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO voice_room_regions (room, region) VALUES ($1, $2)
+		 ON CONFLICT (room) DO UPDATE SET region = EXCLUDED.region, placed_at = now()
+		 WHERE voice_room_regions.placed_at < now() - $3 * interval '1 second'
+		    OR NOT (voice_room_regions.region = ANY($4))
+		 RETURNING region`,
+		room, s.regions.Name(picked), int(roomReplaceAfter.Seconds()), healthy).Scan(&region)
+	if err == pgx.ErrNoRows {
+		// Placed moments ago by another join; use that placement.
+		return s.roomServer(ctx, room)
+	}
+	if err != nil {
+		s.logger.Warn("failed to record voice room region",
+			slog.String("room", room), slog.String("error", err.Error()))
+	}
+	return s.servers[picked]
+}
+
+// RoomURL returns the browser-facing URL of the LiveKit server hosting a
+// channel's room.
+func (s *Service) RoomURL(ctx context.Context, channelID string) string {
+	return s.roomServer(ctx, channelID).publicURL
+}
+
+// RegionStatuses returns the health of each LiveKit region, primary first.
+func (s *Service) RegionStatuses() []regions.Status {
+	return s.regions.Statuses()
+}
+
+// StartHealthChecks probes each LiveKit region every interval so new rooms
+// fail over away from unreachable ones. It does nothing with one region.
+func (s *Service) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	s.regions.StartHealthChecks(ctx, interval, s.logger, "voice", func(ctx context.Context, i int) error {
+		// Listing a room that does not exist is a cheap authenticated call.
+		_, err := s.servers[i].client.ListRooms(ctx, &livekit.ListRoomsRequest{Names: []string{"health-check"}})
+		return err
+	})
+}
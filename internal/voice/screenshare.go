@@ -0,0 +1,198 @@
+package voice
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// Screen share quality tiers, lowest first.
+var (
+	screenShareResolutions = []string{"720p", "1080p", "4k"}
+	screenShareFramerates  = []int{15, 30, 60}
+)
+
+// Participant attributes carrying a guild's screen share caps. Clients read
+// them from their own participant and encode within them.
+const (
+	AttrScreenShareMaxResolution = "screenshare_max_resolution"
+	AttrScreenShareMaxFramerate  = "screenshare_max_framerate"
+)
+
+// ScreenShareConfig holds a guild's screen share quality caps.
+type ScreenShareConfig struct {
+	GuildID       string `json:"guild_id"`
+	MaxResolution string `json:"max_resolution"` // "720p", "1080p" or "4k"
+	MaxFramerate  int    `json:"max_framerate"`  // 15, 30 or 60
+}
+
+// ValidScreenShareResolution reports whether res is a known resolution tier.
+func ValidScreenShareResolution(res string) bool {
+	return slices.Contains(screenShareResolutions, res)
+}
+
+// ValidScreenShareFramerate reports whether fps is a known framerate tier.
+func ValidScreenShareFramerate(fps int) bool {
+	return slices.Contains(screenShareFramerates, fps)
+}
+
+// Clamp lowers a requested resolution and framerate to the caps. Both
+// values must be valid tiers.
+func (c *ScreenShareConfig) Clamp(resolution string, framerate int) (string, int) {
+	if slices.Index(screenShareResolutions, resolution) > slices.Index(screenShareResolutions, c.MaxResolution) {
+		resolution = c.MaxResolution
+	}
+	return resolution, min(framerate, c.MaxFramerate)
+}
+
+// defaultScreenShareConfig is the uncapped config of guilds that set none
+// and of DMs.
+func defaultScreenShareConfig(guildID string) *ScreenShareConfig {
+	return &ScreenShareConfig{GuildID: guildID, MaxResolution: "4k", MaxFramerate: 60}
+}
+
+// GetScreenShareConfig loads a guild's screen share caps. An empty guild ID,
+// as for DMs, gets the uncapped defaults.
+func (s *Service) GetScreenShareConfig(ctx context.Context, guildID string) (*ScreenShareConfig, error) {
+	cfg := defaultScreenShareConfig(guildID)
+	if guildID == "" {
+		return cfg, nil
+	}
+	err := s.pool.QueryRow(ctx,
+		`SELECT max_resolution, max_framerate FROM screenshare_config WHERE guild_id = $1`, guildID,
+	).Scan(&cfg.MaxResolution, &cfg.MaxFramerate)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("loading screen share config: %w", err)
+	}
+	return cfg, nil
+}
+
+// UpdateScreenShareConfig upserts a guild's screen share caps.
+func (s *Service) UpdateScreenShareConfig(ctx context.Context, cfg *ScreenShareConfig) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO screenshare_config (guild_id, max_resolution, max_framerate, updated_at)
+		 VALUES ($1, $2, $3, now())
+		 ON CONFLICT (guild_id) DO UPDATE SET
+		    max_resolution = EXCLUDED.max_resolution,
+		    max_framerate = EXCLUDED.max_framerate,
+		    updated_at = now()`,
+		cfg.GuildID, cfg.MaxResolution, cfg.MaxFramerate,
+	)
+	if err != nil {
+		return fmt.Errorf("upserting screen share config: %w", err)
+	}
+	return nil
+}
+
+// PublishGrant lists the track sources a participant may publish. LiveKit
+// rejects tracks from any other source.
+type PublishGrant struct {
+	Microphone  bool
+	Camera      bool
+	ScreenShare bool
+	// ScreenShareCaps is sent as participant attributes when ScreenShare is
+	// granted; nil means uncapped.
+	ScreenShareCaps *ScreenShareConfig
+	// InputMode is the input mode the channel requires, sent as a participant
+	// attribute when Microphone is granted. Empty sends nothing.
+	InputMode string
+}
+
+// sources returns the LiveKit track sources the grant allows.
+func (g PublishGrant) sources() []livekit.TrackSource {
+	var sources []livekit.TrackSource
+	if g.Microphone {
+		sources = append(sources, livekit.TrackSource_MICROPHONE)
+	}
+	if g.Camera {
+		sources = append(sources, livekit.TrackSource_CAMERA)
+	}
+	if g.ScreenShare {
+		sources = append(sources, livekit.TrackSource_SCREEN_SHARE, livekit.TrackSource_SCREEN_SHARE_AUDIO)
+	}
+	return sources
+}
+
+// GenerateGrantedToken creates a LiveKit access token that may subscribe
+// and publish only the sources in grant. The grant's limits travel as
+// participant attributes, which the token does not let the client rewrite.
+func (s *Service) GenerateGrantedToken(userID, channelID string, grant PublishGrant, metadata string) (string, error) {
+	sources := grant.sources()
+	canPublish := len(sources) > 0
+	canSubscribe := true
+	video := &auth.VideoGrant{
+		RoomJoin:       true,
+		Room:           channelID,
+		CanPublish:     &canPublish,
+		CanSubscribe:   &canSubscribe,
+		CanPublishData: &canPublish,
+	}
+	video.SetCanPublishSources(sources)
+	video.SetCanUpdateOwnMetadata(false)
+
+	srv := s.roomServer(context.Background(), channelID)
+	at := auth.NewAccessToken(srv.apiKey, srv.apiSecret)
+	at.SetVideoGrant(video).
+		SetIdentity(userID).
+		SetValidFor(24 * time.Hour)
+	if metadata != "" {
+		at.SetMetadata(metadata)
+	}
+	attrs := map[string]string{}
+	if grant.ScreenShare {
+		caps := grant.ScreenShareCaps
+		if caps == nil {
+			caps = defaultScreenShareConfig("")
+		}
+		attrs[AttrScreenShareMaxResolution] = caps.MaxResolution
+		attrs[AttrScreenShareMaxFramerate] = strconv.Itoa(caps.MaxFramerate)
+	}
+	if grant.Microphone && grant.InputMode != "" {
+		attrs[AttrInputMode] = grant.InputMode
+	}
+	if len(attrs) > 0 {
+		at.SetAttributes(attrs)
+	}
+
+	token, err := at.ToJWT()
+	if err != nil {
+		return "", fmt.Errorf("generating LiveKit token: %w", err)
+	}
+	return token, nil
+}
+
+// setStreaming records whether a user is sharing their screen and announces
+// changes with SCREEN_SHARE_START and SCREEN_SHARE_END, so clients can mark
+// who is live. Users not tracked in channelID are ignored.
+func (s *Service) setStreaming(ctx context.Context, userID, channelID string, streaming bool) {
+	s.statesMu.Lock()
+	vs, ok := s.states[userID]
+	if !ok || vs.ChannelID != channelID || vs.ScreenSharing == streaming {
+		s.statesMu.Unlock()
+		return
+	}
+	vs.ScreenSharing = streaming
+	guildID := vs.GuildID
+	s.statesMu.Unlock()
+
+	if s.bus == nil {
+		return
+	}
+	eventType := "SCREEN_SHARE_END"
+	if streaming {
+		eventType = "SCREEN_SHARE_START"
+	}
+	s.bus.PublishGuildEvent(ctx, events.SubjectVoiceStateUpdate, eventType, guildID, map[string]interface{}{
+		"guild_id":   guildID,
+		"channel_id": channelID,
+		"user_id":    userID,
+	})
+}
@@ -11,9 +11,11 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
-	lksdk "github.com/livekit/server-sdk-go/v2"
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/regions"
 )
 
 // VoiceState tracks a user's current voice channel presence.
@@ -29,6 +31,13 @@ type VoiceState struct {
 	PrioritySpeaker bool   `json:"priority_speaker"`  // attenuate others when speaking
 	Broadcasting    bool   `json:"broadcasting"`       // one-way broadcast mode
 	ScreenSharing   bool   `json:"screen_sharing"`     // screen share active
+	AFK             bool   `json:"afk"`                // moved to the AFK channel for idling
+	PTTRequired     bool   `json:"ptt_required"`       // channel requires push-to-talk
+
+	// JoinedAt is when the user joined their current channel.
+	JoinedAt time.Time `json:"-"`
+	// LastActiveAt is when the user last joined or had a live microphone.
+	LastActiveAt time.Time `json:"-"`
 }
 
 // VoicePreferences holds per-user voice settings persisted in the database.
@@ -101,18 +110,28 @@ type ScreenShareSession struct {
 // Config holds configuration for the voice service.
 type Config struct {
 	URL       string
+	PublicURL string // browser-facing URL; empty means URL
 	APIKey    string
 	APISecret string
 	Pool      *pgxpool.Pool
+	Bus       *events.Bus // used to announce AFK moves; may be nil
 	Logger    *slog.Logger
+
+	// Regions are LiveKit servers in other deployment regions; the server
+	// above serves PrimaryRegion. New rooms go to the region picked by
+	// Strategy for the first client to join.
+	Regions       []RegionConfig
+	PrimaryRegion string
+	LocalRegion   string
+	Strategy      string
 }
 
 // Service manages LiveKit rooms and voice state.
 type Service struct {
-	roomClient *lksdk.RoomServiceClient
-	apiKey     string
-	apiSecret  string
-	pool       *pgxpool.Pool
+	servers []*server // primary region first; see regions.go
+	regions *regions.Set
+	pool    *pgxpool.Pool
+	bus        *events.Bus
 	logger     *slog.Logger
 
 	// In-memory voice state tracking.
@@ -126,13 +145,18 @@ func New(cfg Config) (*Service, error) {
 		return nil, fmt.Errorf("LiveKit URL, API key, and API secret are required")
 	}
 
-	roomClient := lksdk.NewRoomServiceClient(cfg.URL, cfg.APIKey, cfg.APISecret)
+	servers := []*server{newServer(cfg.URL, cfg.PublicURL, cfg.APIKey, cfg.APISecret)}
+	names := []string{cfg.PrimaryRegion}
+	for _, rc := range cfg.Regions {
+		servers = append(servers, newServer(rc.URL, rc.PublicURL, rc.APIKey, rc.APISecret))
+		names = append(names, rc.Name)
+	}
 
 	return &Service{
-		roomClient: roomClient,
-		apiKey:     cfg.APIKey,
-		apiSecret:  cfg.APISecret,
+		servers:    servers,
+		regions:    regions.NewSet(names, cfg.Strategy, cfg.LocalRegion),
 		pool:       cfg.Pool,
+		bus:        cfg.Bus,
 		logger:     cfg.Logger,
 		states:     make(map[string]*VoiceState),
 	}, nil
@@ -142,7 +166,8 @@ func New(cfg Config) (*Service, error) {
 // The token grants permission to publish/subscribe audio and optionally video.
 // metadata is a JSON string embedded in the token for participant display info.
 func (s *Service) GenerateToken(userID, channelID string, canPublish, canSubscribe, canVideo bool, metadata string) (string, error) {
-	at := auth.NewAccessToken(s.apiKey, s.apiSecret)
+	srv := s.roomServer(context.Background(), channelID)
+	at := auth.NewAccessToken(srv.apiKey, srv.apiSecret)
 	grant := &auth.VideoGrant{
 		RoomJoin: true,
 		Room:     channelID, // use channel ID as room name
@@ -169,9 +194,17 @@ func (s *Service) GenerateToken(userID, channelID string, canPublish, canSubscri
 	return token, nil
 }
 
-// EnsureRoom creates a LiveKit room for a voice channel if it doesn't exist.
+// EnsureRoom creates a LiveKit room for a voice channel if it doesn't exist,
+// placing it in this node's region when it is new.
 func (s *Service) EnsureRoom(ctx context.Context, channelID string) error {
-	_, err := s.roomClient.CreateRoom(ctx, &livekit.CreateRoomRequest{
+	return s.EnsureRoomIn(ctx, channelID, "")
+}
+
+// EnsureRoomIn creates a LiveKit room for a voice channel if it doesn't
+// exist, on the server of the region picked for a client in clientRegion.
+// An occupied room stays where it is.
+func (s *Service) EnsureRoomIn(ctx context.Context, channelID, clientRegion string) error {
+	_, err := s.placeRoom(ctx, channelID, clientRegion).client.CreateRoom(ctx, &livekit.CreateRoomRequest{
 		Name:            channelID,
 		EmptyTimeout:    300, // 5 minutes after last participant leaves
 		MaxParticipants: 100,
@@ -188,7 +221,7 @@ func (s *Service) EnsureRoom(ctx context.Context, channelID string) error {
 
 // DeleteRoom removes a LiveKit room when a voice channel is deleted.
 func (s *Service) DeleteRoom(ctx context.Context, channelID string) error {
-	_, err := s.roomClient.DeleteRoom(ctx, &livekit.DeleteRoomRequest{
+	_, err := s.roomServer(ctx, channelID).client.DeleteRoom(ctx, &livekit.DeleteRoomRequest{
 		Room: channelID,
 	})
 	return err
@@ -196,7 +229,7 @@ func (s *Service) DeleteRoom(ctx context.Context, channelID string) error {
 
 // ListParticipants returns current participants in a voice channel.
 func (s *Service) ListParticipants(ctx context.Context, channelID string) ([]*livekit.ParticipantInfo, error) {
-	resp, err := s.roomClient.ListParticipants(ctx, &livekit.ListParticipantsRequest{
+	resp, err := s.roomServer(ctx, channelID).client.ListParticipants(ctx, &livekit.ListParticipantsRequest{
 		Room: channelID,
 	})
 	if err != nil {
@@ -216,7 +249,7 @@ func (s *Service) MuteParticipant(ctx context.Context, channelID, userID string,
 	}
 	s.statesMu.RUnlock()
 
-	_, err := s.roomClient.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+	_, err := s.roomServer(ctx, channelID).client.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
 		Room:     channelID,
 		Identity: userID,
 		Permission: &livekit.ParticipantPermission{
@@ -238,7 +271,7 @@ func (s *Service) DeafenParticipant(ctx context.Context, channelID, userID strin
 	}
 	s.statesMu.RUnlock()
 
-	_, err := s.roomClient.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+	_, err := s.roomServer(ctx, channelID).client.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
 		Room:     channelID,
 		Identity: userID,
 		Permission: &livekit.ParticipantPermission{
@@ -251,7 +284,7 @@ func (s *Service) DeafenParticipant(ctx context.Context, channelID, userID strin
 
 // RemoveParticipant kicks a user from a voice channel.
 func (s *Service) RemoveParticipant(ctx context.Context, channelID, userID string) error {
-	_, err := s.roomClient.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
+	_, err := s.roomServer(ctx, channelID).client.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
 		Room:     channelID,
 		Identity: userID,
 	})
@@ -288,11 +321,13 @@ func (s *Service) UpdateVoiceState(userID, guildID, channelID string, selfMute,
 	}
 
 	s.states[userID] = &VoiceState{
-		UserID:    userID,
-		GuildID:   guildID,
-		ChannelID: channelID,
-		SelfMute:  selfMute,
-		SelfDeaf:  selfDeaf,
+		UserID:       userID,
+		GuildID:      guildID,
+		ChannelID:    channelID,
+		SelfMute:     selfMute,
+		SelfDeaf:     selfDeaf,
+		JoinedAt:     time.Now(),
+		LastActiveAt: time.Now(),
 	}
 }
 
@@ -303,6 +338,14 @@ func (s *Service) GetVoiceState(userID string) *VoiceState {
 	return s.states[userID]
 }
 
+// InVoiceChannel reports whether the user is connected to channelID.
+func (s *Service) InVoiceChannel(userID, channelID string) bool {
+	s.statesMu.RLock()
+	defer s.statesMu.RUnlock()
+	vs, ok := s.states[userID]
+	return ok && vs.ChannelID == channelID
+}
+
 // GetChannelVoiceStates returns all voice states for a given channel.
 func (s *Service) GetChannelVoiceStates(channelID string) []*VoiceState {
 	s.statesMu.RLock()
@@ -332,10 +375,11 @@ func (s *Service) GetGuildVoiceStates(guildID string) []*VoiceState {
 }
 
 // SetInputMode updates the input mode (VAD/PTT) on a user's voice state.
+// Users in a channel that requires push-to-talk stay on PTT.
 func (s *Service) SetInputMode(userID, mode string) {
 	s.statesMu.Lock()
 	defer s.statesMu.Unlock()
-	if vs, ok := s.states[userID]; ok {
+	if vs, ok := s.states[userID]; ok && !vs.PTTRequired {
 		vs.InputMode = mode
 	}
 }
@@ -1,7 +1,16 @@
 package voice
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
 )
 
 func TestVoiceStateTracking(t *testing.T) {
@@ -24,6 +33,10 @@ func TestVoiceStateTracking(t *testing.T) {
 		t.Fatalf("unexpected voice state: %+v", vs)
 	}
 
+	if !s.InVoiceChannel("user1", "channel1") || s.InVoiceChannel("user1", "channel2") {
+		t.Fatal("expected user1 in channel1 only")
+	}
+
 	// Update mute.
 	s.UpdateVoiceState("user1", "guild1", "channel1", true, false)
 	vs = s.GetVoiceState("user1")
@@ -52,6 +65,10 @@ func TestVoiceStateTracking(t *testing.T) {
 		t.Fatalf("expected nil after disconnect, got %+v", vs)
 	}
 
+	if s.InVoiceChannel("user1", "channel1") {
+		t.Fatal("expected user1 out of voice after disconnect")
+	}
+
 	states = s.GetChannelVoiceStates("channel1")
 	if len(states) != 1 {
 		t.Fatalf("expected 1 channel state after disconnect, got %d", len(states))
@@ -65,3 +82,229 @@ func TestGenerateTokenRequiresService(t *testing.T) {
 		t.Fatal("expected error creating service without config")
 	}
 }
+
+func TestAFKDue(t *testing.T) {
+	now := time.Now()
+	if afkDue(now.Add(-299*time.Second), now, 300) {
+		t.Error("member idle under the timeout should stay")
+	}
+	if !afkDue(now.Add(-300*time.Second), now, 300) {
+		t.Error("member idle for the timeout should move")
+	}
+	if afkDue(now.Add(-time.Hour), now, 0) {
+		t.Error("a zero timeout should never move anyone")
+	}
+}
+
+func TestHasLiveMicrophone(t *testing.T) {
+	audio := func(source livekit.TrackSource, muted bool) *livekit.TrackInfo {
+		return &livekit.TrackInfo{Type: livekit.TrackType_AUDIO, Source: source, Muted: muted}
+	}
+	tests := []struct {
+		name   string
+		tracks []*livekit.TrackInfo
+		want   bool
+	}{
+		{"no tracks", nil, false},
+		{"unmuted mic", []*livekit.TrackInfo{audio(livekit.TrackSource_MICROPHONE, false)}, true},
+		{"muted mic", []*livekit.TrackInfo{audio(livekit.TrackSource_MICROPHONE, true)}, false},
+		{"screen share audio only", []*livekit.TrackInfo{audio(livekit.TrackSource_SCREEN_SHARE_AUDIO, false)}, false},
+		{"camera only", []*livekit.TrackInfo{{Type: livekit.TrackType_VIDEO, Source: livekit.TrackSource_CAMERA}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasLiveMicrophone(&livekit.ParticipantInfo{Tracks: tt.tracks}); got != tt.want {
+				t.Errorf("hasLiveMicrophone() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReceiveWebhook(t *testing.T) {
+	s := &Service{servers: []*server{{apiKey: "key", apiSecret: "secret"}}, states: make(map[string]*VoiceState)}
+	body := `{"event":"track_published","room":{"name":"channel1"},"participant":{"identity":"user1"},"track":{"type":"AUDIO"}}`
+	sign := func(secret, payload string) string {
+		sum := sha256.Sum256([]byte(payload))
+		token, err := auth.NewAccessToken("key", secret).
+			SetSha256(base64.StdEncoding.EncodeToString(sum[:])).
+			SetValidFor(time.Minute).ToJWT()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	request := func(token, payload string) *livekit.WebhookEvent {
+		r := httptest.NewRequest("POST", "/api/v1/voice/webhook", strings.NewReader(payload))
+		r.Header.Set("Authorization", token)
+		event, err := s.ReceiveWebhook(r)
+		if err != nil {
+			return nil
+		}
+		return event
+	}
+
+	event := request(sign("secret", body), body)
+	if event == nil || event.GetEvent() != "track_published" || event.GetParticipant().GetIdentity() != "user1" {
+		t.Fatalf("valid webhook not accepted: %+v", event)
+	}
+	if request(sign("wrong", body), body) != nil {
+		t.Error("webhook signed with another secret was accepted")
+	}
+	if request(sign("secret", body), strings.Replace(body, "user1", "user2", 1)) != nil {
+		t.Error("webhook with a tampered body was accepted")
+	}
+
+	s.UpdateVoiceState("user1", "guild1", "channel1", false, false)
+	s.states["user1"].LastActiveAt = time.Time{}
+	s.HandleWebhookEvent(context.Background(), event)
+	if s.GetVoiceState("user1").LastActiveAt.IsZero() {
+		t.Error("published microphone did not mark the user active")
+	}
+}
+
+func TestWebhookParticipantLeft(t *testing.T) {
+	s := &Service{states: make(map[string]*VoiceState)}
+	s.UpdateVoiceState("user1", "guild1", "channel1", false, false)
+	s.UpdateVoiceState("user2", "guild1", "channel2", false, false)
+	s.UpdateVoiceState("user3", "guild1", "channel2", false, false)
+
+	leave := func(event, user, room string) {
+		s.HandleWebhookEvent(context.Background(), &livekit.WebhookEvent{
+			Event:       event,
+			Room:        &livekit.Room{Name: room},
+			Participant: &livekit.ParticipantInfo{Identity: user},
+		})
+	}
+
+	leave("participant_left", "user1", "channel9")
+	if s.GetVoiceState("user1") == nil {
+		t.Error("leaving another room cleared the state")
+	}
+	leave("participant_left", "user1", "channel1")
+	if s.GetVoiceState("user1") != nil {
+		t.Error("participant_left did not clear the state")
+	}
+	leave("room_finished", "", "channel2")
+	if s.GetVoiceState("user2") != nil || s.GetVoiceState("user3") != nil {
+		t.Error("room_finished did not clear the room's states")
+	}
+}
+
+func TestStaleStates(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-2 * reconcileGrace)
+	states := []VoiceState{
+		{UserID: "connected", ChannelID: "room1", JoinedAt: old},
+		{UserID: "gone", ChannelID: "room1", JoinedAt: old},
+		{UserID: "connecting", ChannelID: "room1", JoinedAt: now},
+		{UserID: "closed_room", ChannelID: "room2", JoinedAt: old},
+		{UserID: "unpolled", ChannelID: "room3", JoinedAt: old},
+	}
+	connected := map[string]map[string]bool{
+		"room1": {"connected": true},
+		"room2": {},
+	}
+
+	var got []string
+	for _, vs := range staleStates(states, connected, now) {
+		got = append(got, vs.UserID)
+	}
+	if strings.Join(got, ",") != "gone,closed_room" {
+		t.Errorf("staleStates() = %v, want [gone closed_room]", got)
+	}
+}
+
+func TestScreenShareClamp(t *testing.T) {
+	cfg := &ScreenShareConfig{MaxResolution: "1080p", MaxFramerate: 30}
+	tests := []struct {
+		res     string
+		fps     int
+		wantRes string
+		wantFPS int
+	}{
+		{"720p", 15, "720p", 15},
+		{"1080p", 30, "1080p", 30},
+		{"4k", 60, "1080p", 30},
+		{"720p", 60, "720p", 30},
+	}
+	for _, tt := range tests {
+		res, fps := cfg.Clamp(tt.res, tt.fps)
+		if res != tt.wantRes || fps != tt.wantFPS {
+			t.Errorf("Clamp(%q, %d) = %q, %d; want %q, %d", tt.res, tt.fps, res, fps, tt.wantRes, tt.wantFPS)
+		}
+	}
+}
+
+func TestGenerateGrantedToken(t *testing.T) {
+	s := &Service{servers: []*server{{apiKey: "key", apiSecret: "secret"}}}
+	claims := func(grant PublishGrant) *auth.ClaimGrants {
+		token, err := s.GenerateGrantedToken("user1", "channel1", grant, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifier, err := auth.ParseAPIToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, c, err := verifier.Verify("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	voiceOnly := claims(PublishGrant{Microphone: true, Camera: true})
+	if voiceOnly.Video.GetCanPublishSource(livekit.TrackSource_SCREEN_SHARE) {
+		t.Error("token without screen share grant may publish a screen share")
+	}
+	if !voiceOnly.Video.GetCanPublishSource(livekit.TrackSource_MICROPHONE) {
+		t.Error("token with microphone grant may not publish a microphone")
+	}
+	if len(voiceOnly.Attributes) != 0 {
+		t.Errorf("attributes = %v, want none without screen share", voiceOnly.Attributes)
+	}
+
+	capped := claims(PublishGrant{ScreenShare: true, ScreenShareCaps: &ScreenShareConfig{MaxResolution: "720p", MaxFramerate: 15}})
+	if !capped.Video.GetCanPublishSource(livekit.TrackSource_SCREEN_SHARE) {
+		t.Error("token with screen share grant may not publish a screen share")
+	}
+	if capped.Video.GetCanPublishSource(livekit.TrackSource_MICROPHONE) {
+		t.Error("token without microphone grant may publish a microphone")
+	}
+	if capped.Attributes[AttrScreenShareMaxResolution] != "720p" || capped.Attributes[AttrScreenShareMaxFramerate] != "15" {
+		t.Errorf("attributes = %v, want 720p at 15fps", capped.Attributes)
+	}
+
+	muted := claims(PublishGrant{})
+	if muted.Video.GetCanPublish() {
+		t.Error("token with an empty grant may publish")
+	}
+
+	ptt := claims(PublishGrant{Microphone: true, InputMode: InputModePTT})
+	if ptt.Attributes[AttrInputMode] != InputModePTT {
+		t.Errorf("attributes = %v, want input_mode ptt", ptt.Attributes)
+	}
+	if ptt.Video.GetCanUpdateOwnMetadata() {
+		t.Error("token lets the client rewrite its own attributes")
+	}
+	if listener := claims(PublishGrant{InputMode: InputModePTT}); len(listener.Attributes) != 0 {
+		t.Errorf("attributes = %v, want none without microphone", listener.Attributes)
+	}
+}
+
+func TestPTTRequiredPinsInputMode(t *testing.T) {
+	s := &Service{states: make(map[string]*VoiceState)}
+	s.UpdateVoiceState("user1", "guild1", "channel1", false, false)
+
+	s.SetPTTRequired("user1", true)
+	s.SetInputMode("user1", InputModeVAD)
+	if got := s.GetVoiceState("user1").InputMode; got != InputModePTT {
+		t.Errorf("input mode = %q with push-to-talk required, want ptt", got)
+	}
+
+	s.SetPTTRequired("user1", false)
+	s.SetInputMode("user1", InputModeVAD)
+	if got := s.GetVoiceState("user1").InputMode; got != InputModeVAD {
+		t.Errorf("input mode = %q after requirement lifted, want vad", got)
+	}
+}
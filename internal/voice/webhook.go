@@ -0,0 +1,172 @@
+package voice
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// maxWebhookBody bounds a LiveKit webhook payload.
+const maxWebhookBody = 1 << 20
+
+// ErrInvalidWebhook is returned for webhook requests that are not signed by
+// one of this instance's LiveKit keys.
+var ErrInvalidWebhook = errors.New("invalid LiveKit webhook signature")
+
+// ReceiveWebhook reads a LiveKit webhook request and checks that it was
+// signed with the API key of one of the configured regions and that the
+// body matches the signed checksum.
+func (s *Service) ReceiveWebhook(r *http.Request) (*livekit.WebhookEvent, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := auth.ParseAPIToken(r.Header.Get("Authorization"))
+	if err != nil {
+		return nil, ErrInvalidWebhook
+	}
+	var srv *server
+	for _, candidate := range s.servers {
+		if verifier.APIKey() == candidate.apiKey {
+			srv = candidate
+			break
+		}
+	}
+	if srv == nil {
+		return nil, ErrInvalidWebhook
+	}
+	_, claims, err := verifier.Verify(srv.apiSecret)
+	if err != nil {
+		return nil, ErrInvalidWebhook
+	}
+	sum := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare([]byte(claims.Sha256), []byte(base64.StdEncoding.EncodeToString(sum[:]))) != 1 {
+		return nil, ErrInvalidWebhook
+	}
+
+	var event livekit.WebhookEvent
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// HandleWebhookEvent applies a verified LiveKit webhook to the tracked voice
+// state. Rooms are named after channel IDs and participants after user IDs.
+// LiveKit reports disconnects the client never announced, such as a crashed
+// tab, so these events keep the tracked state honest between reconcile
+// sweeps.
+func (s *Service) HandleWebhookEvent(ctx context.Context, event *livekit.WebhookEvent) {
+	channelID := event.GetRoom().GetName()
+	userID := event.GetParticipant().GetIdentity()
+
+	switch event.GetEvent() {
+	case "participant_joined":
+		s.trackParticipant(ctx, userID, channelID)
+	case "participant_left", "participant_connection_aborted":
+		s.dropState(ctx, userID, channelID)
+	case "room_finished":
+		for _, vs := range s.GetChannelVoiceStates(channelID) {
+			s.dropState(ctx, vs.UserID, channelID)
+		}
+	case "track_published":
+		track := event.GetTrack()
+		if track.GetType() == livekit.TrackType_AUDIO && track.GetSource() != livekit.TrackSource_SCREEN_SHARE_AUDIO && !track.GetMuted() {
+			s.MarkActive(userID)
+		}
+		if track.GetSource() == livekit.TrackSource_SCREEN_SHARE {
+			s.setStreaming(ctx, userID, channelID, true)
+		}
+	case "track_unpublished":
+		if event.GetTrack().GetSource() == livekit.TrackSource_SCREEN_SHARE {
+			s.setStreaming(ctx, userID, channelID, false)
+		}
+	}
+}
+
+// trackParticipant records a participant LiveKit has connected but that has
+// no tracked state in the room, which happens when this process restarted
+// after the join. Identities that are not local users, such as federated
+// guests, are ignored.
+func (s *Service) trackParticipant(ctx context.Context, userID, channelID string) {
+	if userID == "" || channelID == "" || s.pool == nil {
+		return
+	}
+	if vs := s.GetVoiceState(userID); vs != nil && vs.ChannelID == channelID {
+		return
+	}
+
+	var guildID *string
+	var username string
+	err := s.pool.QueryRow(ctx,
+		`SELECT c.guild_id, u.username FROM channels c, users u WHERE c.id = $1 AND u.id = $2`,
+		channelID, userID).Scan(&guildID, &username)
+	if err != nil {
+		if err != pgx.ErrNoRows {
+			s.logger.Warn("failed to look up voice participant",
+				slog.String("user_id", userID), slog.String("error", err.Error()))
+		}
+		return
+	}
+	gID := ""
+	if guildID != nil {
+		gID = *guildID
+	}
+
+	if prev := s.GetVoiceState(userID); prev != nil {
+		s.dropState(ctx, userID, prev.ChannelID)
+	}
+	s.UpdateVoiceState(userID, gID, channelID, false, false)
+	if s.bus == nil {
+		return
+	}
+	s.bus.PublishGuildEvent(ctx, events.SubjectVoiceStateUpdate, "VOICE_STATE_UPDATE", gID, map[string]interface{}{
+		"user_id":    userID,
+		"guild_id":   gID,
+		"channel_id": channelID,
+		"username":   username,
+		"self_mute":  false,
+		"self_deaf":  false,
+		"action":     "join",
+	})
+}
+
+// dropState clears a user's state if they are still tracked in channelID and
+// tells clients they left. A state that has since moved to another channel is
+// left alone. The last user out of a DM call ends it.
+func (s *Service) dropState(ctx context.Context, userID, channelID string) {
+	s.statesMu.Lock()
+	vs, ok := s.states[userID]
+	if !ok || vs.ChannelID != channelID {
+		s.statesMu.Unlock()
+		return
+	}
+	guildID := vs.GuildID
+	delete(s.states, userID)
+	s.statesMu.Unlock()
+
+	if s.bus != nil {
+		s.bus.PublishGuildEvent(ctx, events.SubjectVoiceStateUpdate, "VOICE_STATE_UPDATE", guildID, map[string]interface{}{
+			"user_id":    userID,
+			"guild_id":   guildID,
+			"channel_id": channelID,
+			"action":     "leave",
+		})
+	}
+	if guildID == "" {
+		s.EndCallIfEmpty(ctx, channelID)
+	}
+}
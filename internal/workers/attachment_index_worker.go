@@ -0,0 +1,142 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/search"
+)
+
+const (
+	// ocrTimeout bounds text recognition for one image.
+	ocrTimeout = 30 * time.Second
+	// maxOCRBytes is the largest image sent through text recognition.
+	maxOCRBytes = 20 << 20
+)
+
+// ocrSlots limits how many images are run through tesseract at once.
+var ocrSlots = make(chan struct{}, 2)
+
+// indexMessageAttachments indexes a message's attachments for search,
+// running text recognition on images that have not been through it yet.
+// It runs in the background so slow recognition does not hold up the
+// event subscription.
+func (m *Manager) indexMessageAttachments(ctx context.Context, messageID string) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		if m.media != nil {
+			m.recognizePendingImages(ctx, messageID)
+		}
+
+		rows, err := m.pool.Query(ctx,
+			`SELECT `+search.AttachmentDocColumns+`
+			 FROM attachments a
+			 JOIN messages m ON m.id = a.message_id
+			 LEFT JOIN channels c ON c.id = m.channel_id
+			 WHERE a.message_id = $1`, messageID)
+		if err != nil {
+			m.logger.Error("failed to load attachments for indexing",
+				slog.String("message_id", messageID), slog.String("error", err.Error()))
+			return
+		}
+		var docs []search.AttachmentDoc
+		for rows.Next() {
+			doc, err := search.ScanAttachmentDoc(rows)
+			if err != nil {
+				rows.Close()
+				m.logger.Error("failed to scan attachment for indexing",
+					slog.String("message_id", messageID), slog.String("error", err.Error()))
+				return
+			}
+			docs = append(docs, doc)
+		}
+		rows.Close()
+
+		if err := m.search.IndexAttachments(ctx, docs); err != nil {
+			m.logger.Error("failed to index attachments",
+				slog.String("message_id", messageID), slog.String("error", err.Error()))
+		}
+	}()
+}
+
+// recognizePendingImages stores the text recognised in a message's image
+// attachments that have none recorded. It does nothing when tesseract is not
+// installed.
+func (m *Manager) recognizePendingImages(ctx context.Context, messageID string) {
+	if _, err := exec.LookPath("tesseract"); err != nil {
+		return
+	}
+
+	rows, err := m.pool.Query(ctx,
+		`SELECT id FROM attachments
+		 WHERE message_id = $1 AND ocr_text IS NULL AND content_type LIKE 'image/%' AND size_bytes <= $2`,
+		messageID, maxOCRBytes)
+	if err != nil {
+		m.logger.Error("failed to list images for OCR",
+			slog.String("message_id", messageID), slog.String("error", err.Error()))
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		text, err := m.recognizeText(ctx, id)
+		if err != nil {
+			m.logger.Debug("OCR failed",
+				slog.String("attachment_id", id), slog.String("error", err.Error()))
+			continue
+		}
+		if _, err := m.pool.Exec(ctx,
+			`UPDATE attachments SET ocr_text = $2 WHERE id = $1`, id, text); err != nil {
+			m.logger.Error("failed to store OCR text",
+				slog.String("attachment_id", id), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// recognizeText runs an image attachment through tesseract and returns the
+// text it found, which is empty for images without any.
+func (m *Manager) recognizeText(ctx context.Context, attachmentID string) (string, error) {
+	select {
+	case ocrSlots <- struct{}{}:
+		defer func() { <-ocrSlots }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	obj, _, _, err := m.media.OpenFile(ctx, attachmentID)
+	if err != nil {
+		return "", fmt.Errorf("opening attachment: %w", err)
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(io.LimitReader(obj, maxOCRBytes))
+	if err != nil {
+		return "", fmt.Errorf("reading attachment: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ocrTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "tesseract", "stdin", "stdout")
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %w, stderr: %s", err, stderr.String())
+	}
+	return strings.Join(strings.Fields(stdout.String()), " "), nil
+}
@@ -8,11 +8,13 @@ import (
 
 	"github.com/amityvox/amityvox/internal/automod"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
 )
 
 // startAutomodWorker subscribes to MESSAGE_CREATE events and evaluates them
 // against the guild's automod rules. If a rule triggers, the configured action
-// is executed (delete, warn, timeout, or log).
+// is executed (delete, warn, timeout, or log). Member joins and nickname or
+// profile changes are checked against name_filter rules.
 func (m *Manager) startAutomodWorker(ctx context.Context) {
 	m.wg.Add(1)
 	go func() {
@@ -26,6 +28,20 @@ func (m *Manager) startAutomodWorker(ctx context.Context) {
 				slog.String("error", err.Error()))
 			return
 		}
+		for _, subject := range []string{events.SubjectGuildMemberAdd, events.SubjectGuildMemberUpdate} {
+			if _, err := m.bus.Subscribe(subject, func(event events.Event) {
+				m.processMemberName(ctx, event)
+			}); err != nil {
+				m.logger.Error("failed to subscribe for automod name checks",
+					slog.String("subject", subject), slog.String("error", err.Error()))
+			}
+		}
+		if _, err := m.bus.Subscribe(events.SubjectUserUpdate, func(event events.Event) {
+			m.processUserName(ctx, event)
+		}); err != nil {
+			m.logger.Error("failed to subscribe for automod name checks",
+				slog.String("subject", events.SubjectUserUpdate), slog.String("error", err.Error()))
+		}
 
 		m.logger.Info("automod worker started")
 
@@ -47,19 +63,23 @@ func (m *Manager) startAutomodWorker(ctx context.Context) {
 // processAutomod evaluates a message against automod rules.
 func (m *Manager) processAutomod(ctx context.Context, event events.Event) {
 	var msgData struct {
-		ID        string `json:"id"`
-		ChannelID string `json:"channel_id"`
-		GuildID   string `json:"guild_id"`
-		AuthorID  string `json:"author_id"`
-		Content   string `json:"content"`
+		ID             string  `json:"id"`
+		ChannelID      string  `json:"channel_id"`
+		GuildID        string  `json:"guild_id"`
+		AuthorID       string  `json:"author_id"`
+		Content        string  `json:"content"`
+		ContentWarning *string `json:"content_warning"`
+		Attachments    []struct {
+			SHA256 string `json:"sha256"`
+		} `json:"attachments"`
 	}
 
 	if err := json.Unmarshal(event.Data, &msgData); err != nil {
 		return
 	}
 
-	// Skip DMs (no guild_id) and empty content.
-	if msgData.GuildID == "" || msgData.Content == "" {
+	// Skip DMs (no guild_id) and messages with neither content nor files.
+	if msgData.GuildID == "" || (msgData.Content == "" && len(msgData.Attachments) == 0) {
 		return
 	}
 
@@ -79,13 +99,35 @@ func (m *Manager) processAutomod(ctx context.Context, event events.Event) {
 		}
 	}
 
+	// Authors from restricted federation peers get stricter rules.
+	var strict bool
+	m.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM users u
+		               JOIN federation_peers fp ON fp.peer_id = u.instance_id
+		               WHERE u.id = $1 AND fp.instance_id = $2 AND fp.trust_level = $3)`,
+		msgData.AuthorID, m.instanceID, models.PeerTrustRestricted,
+	).Scan(&strict)
+
+	var lang string
+	m.pool.QueryRow(ctx,
+		`SELECT COALESCE(language, '') FROM channels WHERE id = $1`, msgData.ChannelID).Scan(&lang)
+
 	msgCtx := automod.MessageContext{
-		MessageID:     msgData.ID,
-		ChannelID:     msgData.ChannelID,
-		GuildID:       msgData.GuildID,
-		AuthorID:      msgData.AuthorID,
-		Content:       msgData.Content,
-		MemberRoleIDs: roleIDs,
+		MessageID:      msgData.ID,
+		ChannelID:      msgData.ChannelID,
+		GuildID:        msgData.GuildID,
+		AuthorID:       msgData.AuthorID,
+		Content:        msgData.Content,
+		MemberRoleIDs:  roleIDs,
+		Strict:         strict,
+		HasAttachments: len(msgData.Attachments) > 0,
+		Language:       lang,
+	}
+	if msgData.ContentWarning != nil {
+		msgCtx.ContentWarning = *msgData.ContentWarning
+	}
+	for _, a := range msgData.Attachments {
+		msgCtx.AttachmentHashes = append(msgCtx.AttachmentHashes, a.SHA256)
 	}
 
 	rule, reason, err := m.automod.Evaluate(ctx, msgCtx)
@@ -117,3 +159,106 @@ func (m *Manager) processAutomod(ctx context.Context, event events.Event) {
 		)
 	}
 }
+
+// processMemberName checks a member who joined or changed their nickname
+// against the guild's name_filter rules.
+func (m *Manager) processMemberName(ctx context.Context, event events.Event) {
+	var data struct {
+		UserID string `json:"user_id"`
+	}
+	if event.GuildID == "" || json.Unmarshal(event.Data, &data) != nil || data.UserID == "" {
+		return
+	}
+	m.checkMemberName(ctx, event.GuildID, data.UserID)
+}
+
+// processUserName checks a user whose profile changed in every guild where
+// they have no nickname, since their display name is what those guilds show.
+func (m *Manager) processUserName(ctx context.Context, event events.Event) {
+	if event.Type != "USER_UPDATE" || event.UserID == "" {
+		return
+	}
+	rows, err := m.pool.Query(ctx,
+		`SELECT guild_id FROM guild_members WHERE user_id = $1 AND nickname IS NULL`, event.UserID)
+	if err != nil {
+		m.logger.Warn("listing guilds for automod name checks failed", slog.String("error", err.Error()))
+		return
+	}
+	var guildIDs []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			guildIDs = append(guildIDs, id)
+		}
+	}
+	rows.Close()
+	for _, guildID := range guildIDs {
+		m.checkMemberName(ctx, guildID, event.UserID)
+	}
+}
+
+// checkMemberName evaluates a member's shown name against name_filter rules
+// and executes the action of the first that triggers.
+func (m *Manager) checkMemberName(ctx context.Context, guildID, userID string) {
+	if !m.automod.HasNameRules(ctx, guildID) {
+		return
+	}
+
+	member := automod.MemberName{GuildID: guildID, UserID: userID}
+	var nickname *string
+	err := m.pool.QueryRow(ctx,
+		`SELECT gm.nickname, COALESCE(u.display_name, u.username),
+		        EXISTS(SELECT 1 FROM federation_peers fp
+		               WHERE fp.peer_id = u.instance_id AND fp.instance_id = $3 AND fp.trust_level = $4)
+		 FROM guild_members gm JOIN users u ON u.id = gm.user_id
+		 WHERE gm.guild_id = $1 AND gm.user_id = $2`,
+		guildID, userID, m.instanceID, models.PeerTrustRestricted,
+	).Scan(&nickname, &member.Name, &member.Strict)
+	if err != nil {
+		return // Left the guild, or the user is gone.
+	}
+	if nickname != nil {
+		member.Nickname = *nickname
+	}
+
+	rows, err := m.pool.Query(ctx,
+		`SELECT role_id FROM member_roles WHERE guild_id = $1 AND user_id = $2`, guildID, userID)
+	if err == nil {
+		for rows.Next() {
+			var roleID string
+			if rows.Scan(&roleID) == nil {
+				member.MemberRoleIDs = append(member.MemberRoleIDs, roleID)
+			}
+		}
+		rows.Close()
+	}
+
+	rule, reason, err := m.automod.EvaluateName(ctx, member)
+	if err != nil {
+		m.logger.Error("automod name evaluation failed",
+			slog.String("guild_id", guildID),
+			slog.String("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	if rule == nil {
+		return
+	}
+
+	m.logger.Info("automod name rule triggered",
+		slog.String("rule_id", rule.ID),
+		slog.String("rule_name", rule.Name),
+		slog.String("action", rule.Action),
+		slog.String("reason", reason),
+		slog.String("guild_id", guildID),
+		slog.String("user_id", userID),
+	)
+
+	if err := m.automod.ExecuteNameAction(ctx, rule, member, reason); err != nil {
+		m.logger.Error("automod name action failed",
+			slog.String("rule_id", rule.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
@@ -0,0 +1,188 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// broadcastDelivery is one member's copy of a guild broadcast.
+type broadcastDelivery struct {
+	BroadcastID string
+	UserID      string
+	GuildID     string
+	GuildName   string
+	AuthorID    string
+	Content     string
+}
+
+// broadcastPost is the DM a member receives for a broadcast, headed with
+// the guild it came from.
+func broadcastPost(guildName, content string) string {
+	return fmt.Sprintf("**Announcement from %s**\n%s", guildName, content)
+}
+
+// deliverGuildBroadcasts sends the next batch of guild broadcast DMs. Each
+// run delivers at most the instance's deliveries_per_minute, oldest
+// broadcast first, so a large guild cannot flood DMs. Members who opted
+// out, left the guild, are remote or have a block with the owner since the
+// broadcast was created are skipped.
+func (m *Manager) deliverGuildBroadcasts(ctx context.Context) error {
+	var raw string
+	m.pool.QueryRow(ctx,
+		`SELECT value FROM instance_settings WHERE key = 'broadcast_limits'`).Scan(&raw)
+	limits := models.ParseBroadcastLimits(raw)
+
+	rows, err := m.pool.Query(ctx,
+		`SELECT d.broadcast_id, d.user_id, b.guild_id, g.name, b.author_id, b.content
+		 FROM guild_broadcast_deliveries d
+		 JOIN guild_broadcasts b ON b.id = d.broadcast_id
+		 JOIN guilds g ON g.id = b.guild_id
+		 WHERE d.status = 'pending' AND b.status IN ($1, $2)
+		 ORDER BY b.created_at, d.user_id
+		 LIMIT $3`,
+		models.BroadcastStatusPending, models.BroadcastStatusSending, limits.DeliveriesPerMinute)
+	if err != nil {
+		return err
+	}
+	batch, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (broadcastDelivery, error) {
+		var d broadcastDelivery
+		err := row.Scan(&d.BroadcastID, &d.UserID, &d.GuildID, &d.GuildName, &d.AuthorID, &d.Content)
+		return d, err
+	})
+	if err != nil {
+		return err
+	}
+
+	delivered := 0
+	for _, d := range batch {
+		if ctx.Err() != nil {
+			break
+		}
+		m.pool.Exec(ctx,
+			`UPDATE guild_broadcasts SET status = $2, started_at = now() WHERE id = $1 AND status = $3`,
+			d.BroadcastID, models.BroadcastStatusSending, models.BroadcastStatusPending)
+
+		status, messageID := m.deliverBroadcast(ctx, d)
+		if status == "delivered" {
+			delivered++
+		}
+		m.pool.Exec(ctx,
+			`UPDATE guild_broadcast_deliveries SET status = $3, message_id = $4, updated_at = now()
+			 WHERE broadcast_id = $1 AND user_id = $2`,
+			d.BroadcastID, d.UserID, status, messageID)
+	}
+
+	m.pool.Exec(ctx,
+		`UPDATE guild_broadcasts b SET status = $1, completed_at = now()
+		 WHERE b.status = $2
+		   AND NOT EXISTS(SELECT 1 FROM guild_broadcast_deliveries d
+		                  WHERE d.broadcast_id = b.id AND d.status = 'pending')`,
+		models.BroadcastStatusCompleted, models.BroadcastStatusSending)
+
+	if delivered > 0 {
+		m.logger.Info("delivered guild broadcasts", slog.Int("delivered", delivered))
+	}
+	return nil
+}
+
+// deliverBroadcast sends one member's DM and returns the delivery status
+// and, if delivered, the message ID. The delivery is claimed first so two
+// workers never send the same copy.
+func (m *Manager) deliverBroadcast(ctx context.Context, d broadcastDelivery) (string, *string) {
+	tag, err := m.pool.Exec(ctx,
+		`UPDATE guild_broadcast_deliveries SET status = 'failed', updated_at = now()
+		 WHERE broadcast_id = $1 AND user_id = $2 AND status = 'pending'`,
+		d.BroadcastID, d.UserID)
+	if err != nil || tag.RowsAffected() == 0 {
+		return "failed", nil
+	}
+
+	var eligible bool
+	m.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM guild_broadcast_subscriptions s
+		               JOIN guild_members gm ON gm.guild_id = s.guild_id AND gm.user_id = s.user_id
+		               JOIN users u ON u.id = s.user_id
+		               WHERE s.guild_id = $1 AND s.user_id = $2 AND s.subscribed
+		                 AND (u.instance_id IS NULL OR u.instance_id = $4))
+		    AND NOT EXISTS(SELECT 1 FROM user_relationships
+		                   WHERE status = 'blocked'
+		                     AND ((user_id = $2 AND target_id = $3) OR (user_id = $3 AND target_id = $2)))`,
+		d.GuildID, d.UserID, d.AuthorID, m.instanceID).Scan(&eligible)
+	if !eligible {
+		return "skipped", nil
+	}
+
+	channelID, created, err := m.getOrCreateDM(ctx, d.AuthorID, d.UserID)
+	if err != nil {
+		m.logger.Error("failed to open broadcast DM",
+			slog.String("broadcast_id", d.BroadcastID), slog.String("error", err.Error()))
+		return "failed", nil
+	}
+	if created {
+		m.bus.PublishUserEvent(ctx, events.SubjectChannelCreate, "CHANNEL_CREATE", d.UserID, map[string]interface{}{
+			"id":           channelID,
+			"channel_type": "dm",
+		})
+	}
+
+	post := broadcastPost(d.GuildName, d.Content)
+	messageID := models.NewULID().String()
+	if _, err := m.pool.Exec(ctx,
+		`INSERT INTO messages (id, channel_id, author_id, content, message_type, created_at)
+		 VALUES ($1, $2, $3, $4, 'default', NOW())`,
+		messageID, channelID, d.AuthorID, post); err != nil {
+		m.logger.Error("failed to send broadcast DM",
+			slog.String("broadcast_id", d.BroadcastID), slog.String("error", err.Error()))
+		return "failed", nil
+	}
+	m.pool.Exec(ctx, `UPDATE channels SET last_message_id = $1 WHERE id = $2`, messageID, channelID)
+
+	m.bus.PublishChannelEvent(ctx, events.SubjectMessageCreate, "MESSAGE_CREATE", channelID, map[string]interface{}{
+		"id":         messageID,
+		"channel_id": channelID,
+		"author_id":  d.AuthorID,
+		"content":    post,
+	})
+	return "delivered", &messageID
+}
+
+// getOrCreateDM returns the DM channel between two users, creating it if
+// they have none. The check and insert share a transaction, as in
+// HandleCreateDM, so concurrent callers do not open two DMs.
+func (m *Manager) getOrCreateDM(ctx context.Context, userID, targetID string) (channelID string, created bool, err error) {
+	err = pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error {
+		e := tx.QueryRow(ctx,
+			`SELECT c.id FROM channels c
+			 JOIN channel_recipients cr1 ON c.id = cr1.channel_id AND cr1.user_id = $1
+			 JOIN channel_recipients cr2 ON c.id = cr2.channel_id AND cr2.user_id = $2
+			 WHERE c.channel_type = 'dm'
+			 LIMIT 1
+			 FOR UPDATE OF c`,
+			userID, targetID,
+		).Scan(&channelID)
+		if e != pgx.ErrNoRows {
+			return e
+		}
+
+		channelID = models.NewULID().String()
+		created = true
+		now := time.Now()
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO channels (id, channel_type, created_at) VALUES ($1, 'dm', $2)`,
+			channelID, now); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO channel_recipients (channel_id, user_id, joined_at) VALUES ($1, $2, $3), ($1, $4, $3)`,
+			channelID, userID, now, targetID)
+		return err
+	})
+	return channelID, created, err
+}
@@ -0,0 +1,90 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// startBurnWorker launches the burn-after-reading worker. Every 15 seconds it
+// starts the countdown on messages the recipient has read and deletes those
+// whose countdown has run out.
+func (m *Manager) startBurnWorker(ctx context.Context) {
+	m.startPeriodic(ctx, "message-burns", 15*time.Second, m.processMessageBurns)
+}
+
+// processMessageBurns arms and then deletes burn-after-reading messages.
+func (m *Manager) processMessageBurns(ctx context.Context) error {
+	if err := m.armMessageBurns(ctx); err != nil {
+		return err
+	}
+	return m.deleteBurnedMessages(ctx)
+}
+
+// armMessageBurns starts the countdown on every unarmed burn message whose
+// recipient's read state has reached it. Message IDs are ULIDs, so a read
+// marker at or past the message ID means the message was read.
+func (m *Manager) armMessageBurns(ctx context.Context) error {
+	rows, err := m.pool.Query(ctx,
+		`UPDATE message_burns b
+		 SET burn_at = now() + make_interval(mins => b.burn_after_minutes)
+		 FROM read_state rs
+		 WHERE b.burn_at IS NULL
+		   AND rs.channel_id = b.channel_id AND rs.user_id <> b.author_id
+		   AND rs.last_read_id >= b.message_id
+		 RETURNING b.message_id, b.channel_id, b.burn_after_minutes, b.burn_at`)
+	if err != nil {
+		return fmt.Errorf("arming message burns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID, channelID string
+		var afterMinutes int
+		var burnAt time.Time
+		if err := rows.Scan(&messageID, &channelID, &afterMinutes, &burnAt); err != nil {
+			m.logger.Error("failed to scan armed message burn", slog.String("error", err.Error()))
+			continue
+		}
+		m.bus.PublishChannelEvent(ctx, events.SubjectMessageBurn, "MESSAGE_BURN", channelID, map[string]interface{}{
+			"id":            messageID,
+			"channel_id":    channelID,
+			"after_minutes": afterMinutes,
+			"burn_at":       burnAt,
+		})
+	}
+	return rows.Err()
+}
+
+// deleteBurnedMessages deletes burn-after-reading messages whose countdown
+// has run out, up to 500 per tick.
+func (m *Manager) deleteBurnedMessages(ctx context.Context) error {
+	rows, err := m.pool.Query(ctx,
+		`DELETE FROM messages
+		 WHERE id IN (SELECT message_id FROM message_burns WHERE burn_at <= now() LIMIT 500)
+		 RETURNING id, channel_id`)
+	if err != nil {
+		return fmt.Errorf("deleting burned messages: %w", err)
+	}
+	defer rows.Close()
+
+	burned := 0
+	for rows.Next() {
+		var messageID, channelID string
+		if err := rows.Scan(&messageID, &channelID); err != nil {
+			m.logger.Error("failed to scan burned message", slog.String("error", err.Error()))
+			continue
+		}
+		m.bus.PublishChannelEvent(ctx, events.SubjectMessageDelete, "MESSAGE_DELETE", channelID, map[string]string{
+			"id": messageID, "channel_id": channelID,
+		})
+		burned++
+	}
+	if burned > 0 {
+		m.logger.Info("burned read messages", slog.Int("count", burned))
+	}
+	return rows.Err()
+}
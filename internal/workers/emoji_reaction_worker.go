@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// rollupEmojiReactions recomputes this week's and last week's rows of
+// emoji_reaction_weekly from the reactions table. The weeks are replaced
+// rather than incremented so removed reactions drop out and the job stays
+// idempotent. Reactions in DMs are not counted.
+func (m *Manager) rollupEmojiReactions(ctx context.Context) error {
+	since := weekStart(time.Now()).AddDate(0, 0, -7)
+	var rolled int64
+	err := pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM emoji_reaction_weekly WHERE week >= $1`, since); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx,
+			`INSERT INTO emoji_reaction_weekly (week, guild_id, emoji, reactions)
+			 SELECT date_trunc('week', r.created_at AT TIME ZONE 'UTC')::date, c.guild_id, r.emoji, count(*)
+			 FROM reactions r
+			 JOIN messages m ON m.id = r.message_id
+			 JOIN channels c ON c.id = m.channel_id
+			 WHERE r.created_at >= $1 AND c.guild_id IS NOT NULL
+			 GROUP BY 1, 2, 3`,
+			since)
+		rolled = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	m.logger.Debug("rolled up emoji reactions", slog.Int64("rows", rolled))
+	return nil
+}
+
+// weekStart returns midnight UTC on the Monday of t's week, where
+// date_trunc('week') starts it.
+func weekStart(t time.Time) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+func (m *Manager) cleanOldEmojiReactions(ctx context.Context) error {
+	tag, err := m.pool.Exec(ctx,
+		`DELETE FROM emoji_reaction_weekly WHERE week < CURRENT_DATE - 365`)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned old emoji reaction rollups",
+			slog.Int64("deleted", tag.RowsAffected()))
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/amityvox/amityvox/internal/locale"
 	"github.com/amityvox/amityvox/internal/notifications"
 )
 
@@ -163,11 +164,13 @@ func (m *Manager) sendEventReminder(ctx context.Context, eventID, guildID, event
 		timeLabel = "soon"
 	}
 
+	// The start time is written in the guild's timezone and clock style,
+	// not the server's.
 	title := fmt.Sprintf("Event Reminder - %s", guildName)
 	body := fmt.Sprintf("\"%s\" starts %s (%s)",
 		eventName,
 		timeLabel,
-		scheduledStart.Local().Format("3:04 PM"),
+		locale.ForGuild(ctx, m.pool, guildID).Clock(scheduledStart),
 	)
 
 	payload := notifications.PushPayload{
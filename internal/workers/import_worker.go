@@ -0,0 +1,33 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+)
+
+// runGuildImports requeues imports abandoned by a crashed worker, then runs
+// pending imports one at a time until none are left. Jobs are claimed with
+// SKIP LOCKED, so several instances can share the queue.
+func (m *Manager) runGuildImports(ctx context.Context) error {
+	if n, err := m.importer.RequeueStale(ctx); err != nil {
+		return err
+	} else if n > 0 {
+		m.logger.Info("requeued stale guild imports", slog.Int64("count", n))
+	}
+
+	for ctx.Err() == nil {
+		job, err := m.importer.ClaimNext(ctx)
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			return nil
+		}
+		m.logger.Info("starting guild import",
+			slog.String("import_id", job.ID),
+			slog.String("guild_id", job.GuildID),
+			slog.String("source", job.Source))
+		m.importer.Run(ctx, job)
+	}
+	return nil
+}
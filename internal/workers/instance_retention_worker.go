@@ -0,0 +1,258 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/media"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// instanceRetentionBatch is how many messages one purge statement removes.
+const instanceRetentionBatch = 1000
+
+// runInstanceRetention applies the instance-wide retention settings: DM and
+// group DM messages past the DM window are deleted, and accounts deleted
+// longer ago than the deleted-account window have their messages and uploads
+// removed. Users and guilds under legal hold are skipped. Each purge that
+// removes anything is recorded in the instance audit log.
+func (m *Manager) runInstanceRetention(ctx context.Context) error {
+	ret, err := apiutil.GetInstanceRetention(ctx, m.pool)
+	if err != nil {
+		return fmt.Errorf("reading instance retention settings: %w", err)
+	}
+	if ret.DMDays > 0 {
+		if err := m.purgeExpiredDMs(ctx, ret.DMDays); err != nil {
+			return fmt.Errorf("purging DMs: %w", err)
+		}
+	}
+	if ret.DeletedAccountDays > 0 {
+		if err := m.purgeDeletedAccounts(ctx, ret.DeletedAccountDays); err != nil {
+			return fmt.Errorf("purging deleted accounts: %w", err)
+		}
+	}
+	return nil
+}
+
+// purgeExpiredDMs deletes DM messages older than days. A conversation with
+// any participant under legal hold is kept whole.
+func (m *Manager) purgeExpiredDMs(ctx context.Context, days int) error {
+	cutoff := time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour)
+	var total int64
+	for {
+		n, err := m.purgeMessageBatch(ctx,
+			`SELECT m.id, m.channel_id FROM messages m
+			 JOIN channels c ON c.id = m.channel_id
+			 WHERE c.channel_type IN ('dm', 'group') AND m.created_at < $1
+			   AND `+apiutil.NotOnLegalHoldSQL+`
+			   AND NOT EXISTS (
+			       SELECT 1 FROM channel_recipients cr
+			       JOIN legal_holds lh ON lh.target_type = 'user' AND lh.target_id = cr.user_id
+			       WHERE cr.channel_id = m.channel_id AND lh.released_at IS NULL)
+			 LIMIT $2`, cutoff, instanceRetentionBatch)
+		total += n
+		if err != nil {
+			return err
+		}
+		if n < instanceRetentionBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		m.logger.Info("purged expired DMs", slog.Int64("deleted", total), slog.Int("retention_days", days))
+		m.logRetentionPurge(ctx, "dm", "", map[string]interface{}{
+			"messages_deleted": total, "retention_days": days, "cutoff": cutoff,
+		})
+	}
+	return nil
+}
+
+// purgeDeletedAccounts removes the messages and uploads of accounts deleted
+// more than days ago. An account is marked purged once nothing of it is left;
+// messages kept by a guild's legal hold leave it unmarked so they are purged
+// after the hold is released. Until then such an account is skipped when all
+// its remaining messages are held, so held accounts cannot fill every batch.
+func (m *Manager) purgeDeletedAccounts(ctx context.Context, days int) error {
+	rows, err := m.pool.Query(ctx,
+		`SELECT u.id FROM users u
+		 WHERE u.flags & $1 <> 0 AND u.data_purged_at IS NULL
+		   AND u.deleted_at < now() - make_interval(days => $2)
+		   AND NOT EXISTS (SELECT 1 FROM legal_holds lh
+		       WHERE lh.target_type = 'user' AND lh.target_id = u.id AND lh.released_at IS NULL)
+		   AND (NOT EXISTS (SELECT 1 FROM messages m WHERE m.author_id = u.id)
+		        OR EXISTS (SELECT 1 FROM messages m WHERE m.author_id = u.id AND `+apiutil.NotOnLegalHoldSQL+`))
+		 ORDER BY u.deleted_at
+		 LIMIT 100`, models.UserFlagDeleted, days)
+	if err != nil {
+		return err
+	}
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := m.purgeDeletedAccount(ctx, userID, days); err != nil {
+			m.logger.Error("deleted account purge failed",
+				slog.String("user_id", userID), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+func (m *Manager) purgeDeletedAccount(ctx context.Context, userID string, days int) error {
+	var messages int64
+	for {
+		n, err := m.purgeMessageBatch(ctx,
+			`SELECT m.id, m.channel_id FROM messages m
+			 WHERE m.author_id = $1 AND `+apiutil.NotOnLegalHoldSQL+`
+			 LIMIT $2`, userID, instanceRetentionBatch)
+		messages += n
+		if err != nil {
+			return err
+		}
+		if n < instanceRetentionBatch {
+			break
+		}
+	}
+
+	// The account's own banner goes with it; avatars were cleared when it was
+	// deleted. Uploads still attached to a kept message stay with it, as do
+	// uploads others still use, such as guild icons, emoji and stickers.
+	if _, err := m.pool.Exec(ctx, `UPDATE users SET banner_id = NULL WHERE id = $1`, userID); err != nil {
+		return err
+	}
+	uploads, err := m.deleteAttachments(ctx,
+		`SELECT a.id, a.s3_bucket, a.s3_key FROM attachments a
+		 WHERE a.uploader_id = $1 AND a.message_id IS NULL AND `+media.UploadUnusedSQL, userID)
+	if err != nil {
+		return err
+	}
+
+	tag, err := m.pool.Exec(ctx,
+		`UPDATE users SET data_purged_at = now()
+		 WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM messages WHERE author_id = $1)`, userID)
+	if err != nil {
+		return err
+	}
+
+	if messages > 0 || uploads > 0 || tag.RowsAffected() > 0 {
+		m.logger.Info("purged deleted account data",
+			slog.String("user_id", userID),
+			slog.Int64("messages_deleted", messages),
+			slog.Int("uploads_deleted", uploads))
+		m.logRetentionPurge(ctx, "user", userID, map[string]interface{}{
+			"messages_deleted": messages, "uploads_deleted": uploads,
+			"retention_days": days, "complete": tag.RowsAffected() > 0,
+		})
+	}
+	return nil
+}
+
+// purgeMessageBatch deletes the messages selected by query, which must return
+// message and channel IDs, along with their attachments, and announces the
+// deletion per channel. It returns how many messages were selected.
+func (m *Manager) purgeMessageBatch(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	rows, err := m.pool.Query(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("selecting messages: %w", err)
+	}
+	var ids []string
+	byChannel := make(map[string][]string)
+	for rows.Next() {
+		var id, channelID string
+		if err := rows.Scan(&id, &channelID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning message: %w", err)
+		}
+		ids = append(ids, id)
+		byChannel[channelID] = append(byChannel[channelID], id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if _, err := m.deleteAttachments(ctx,
+		`SELECT id, s3_bucket, s3_key FROM attachments WHERE message_id = ANY($1)`, ids); err != nil {
+		return 0, err
+	}
+	if _, err := m.pool.Exec(ctx, `DELETE FROM messages WHERE id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("deleting messages: %w", err)
+	}
+
+	for channelID, channelIDs := range byChannel {
+		data, _ := json.Marshal(map[string]interface{}{"ids": channelIDs, "channel_id": channelID})
+		m.bus.Publish(ctx, events.SubjectMessageDelete, events.Event{
+			Type:      "MESSAGE_DELETE_BULK",
+			ChannelID: channelID,
+			Data:      data,
+		})
+	}
+	if m.search != nil {
+		m.deleteFromIndex(ctx, ids)
+	}
+	return int64(len(ids)), nil
+}
+
+// deleteAttachments removes the stored objects and rows of the attachments
+// selected by query, which must return id, s3_bucket and s3_key.
+func (m *Manager) deleteAttachments(ctx context.Context, query string, args ...interface{}) (int, error) {
+	rows, err := m.pool.Query(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("selecting attachments: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id, bucket, key string
+		if err := rows.Scan(&id, &bucket, &key); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning attachment: %w", err)
+		}
+		if m.media != nil {
+			if err := m.media.DeleteObject(ctx, bucket, key); err != nil {
+				m.logger.Warn("failed to delete S3 object during instance retention",
+					slog.String("key", key), slog.String("error", err.Error()))
+			}
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if _, err := m.pool.Exec(ctx, `DELETE FROM attachments WHERE id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("deleting attachments: %w", err)
+	}
+	return len(ids), nil
+}
+
+func (m *Manager) logRetentionPurge(ctx context.Context, targetType, targetID string, after map[string]interface{}) {
+	if err := apiutil.LogStaffAction(ctx, m.pool, apiutil.StaffAction{
+		ActorID:    "system",
+		Action:     models.StaffActionRetentionPurge,
+		TargetType: targetType,
+		TargetID:   targetID,
+		After:      after,
+	}); err != nil {
+		m.logger.Warn("failed to write instance audit log",
+			slog.String("action", models.StaffActionRetentionPurge), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,60 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// liftExpiredInvitePauses clears guild invite pauses that have run out. The
+// pause already stops blocking redemptions at invites_paused_until; this
+// records the resume in the audit log, attributed to whoever set the pause
+// (or the owner if that account is gone), and tells clients.
+func (m *Manager) liftExpiredInvitePauses(ctx context.Context) error {
+	rows, err := m.pool.Query(ctx,
+		`UPDATE guilds SET invites_paused_until = NULL, invites_paused_by = NULL
+		 FROM guilds old
+		 WHERE old.id = guilds.id
+		   AND guilds.invites_paused_until IS NOT NULL AND guilds.invites_paused_until <= NOW()
+		 RETURNING guilds.id, COALESCE(old.invites_paused_by, guilds.owner_id)`)
+	if err != nil {
+		return err
+	}
+	type lifted struct{ guildID, actorID string }
+	var resumed []lifted
+	for rows.Next() {
+		var l lifted
+		if err := rows.Scan(&l.guildID, &l.actorID); err != nil {
+			m.logger.Error("failed to scan expired invite pause row", slog.String("error", err.Error()))
+			continue
+		}
+		resumed = append(resumed, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating expired invite pauses: %w", err)
+	}
+
+	reason := "Invite pause expired"
+	for _, l := range resumed {
+		if _, err := m.pool.Exec(ctx,
+			`INSERT INTO audit_log (id, guild_id, actor_id, action, target_type, target_id, reason, created_at)
+			 VALUES ($1, $2, $3, 'invites_resume', 'guild', $2, $4, now())`,
+			models.NewULID().String(), l.guildID, l.actorID, reason); err != nil {
+			m.logger.Warn("failed to audit invite pause expiry",
+				slog.String("guild_id", l.guildID), slog.String("error", err.Error()))
+		}
+		m.bus.PublishGuildEvent(ctx, events.SubjectGuildUpdate, "GUILD_UPDATE", l.guildID, map[string]interface{}{
+			"id":                   l.guildID,
+			"invites_paused_until": nil,
+		})
+	}
+	if len(resumed) > 0 {
+		m.logger.Info("lifted expired invite pauses",
+			slog.Int("lifted", len(resumed)))
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/opsalerts"
+)
+
+const (
+	// jetStreamLagThreshold is the consumer backlog (undelivered plus
+	// unacknowledged messages) that raises an admin alert.
+	jetStreamLagThreshold = 10000
+
+	// jetStreamLagRecovered is the backlog below which a raised alert is
+	// cleared, so a consumer hovering at the threshold does not re-alert.
+	jetStreamLagRecovered = jetStreamLagThreshold / 2
+)
+
+// jetStreamState carries what the monitor remembers between runs.
+type jetStreamState struct {
+	streamCreated map[string]time.Time // stream name -> creation time
+	lagAlerted    map[string]bool      // "stream/consumer" or "drift/stream"
+}
+
+// consumerBacklog is the number of messages a consumer has yet to finish.
+func consumerBacklog(c events.ConsumerStatus) uint64 {
+	return c.Pending + uint64(c.AckPending)
+}
+
+// monitorJetStream reconciles stream configuration, recreates durable
+// consumers whose stream was replaced or that have disappeared, and alerts
+// instance admins when a consumer falls behind.
+func (m *Manager) monitorJetStream(ctx context.Context) error {
+	if m.jetStream.streamCreated == nil {
+		m.jetStream.streamCreated = make(map[string]time.Time)
+		m.jetStream.lagAlerted = make(map[string]bool)
+	}
+
+	drifts, err := m.bus.ReconcileStreams()
+	if err != nil {
+		return err
+	}
+	replaced := make(map[string]bool)
+	for _, d := range drifts {
+		switch {
+		case d.Immutable:
+			m.logger.Error("JetStream stream retention or storage differs from configuration; recreate it manually",
+				slog.String("stream", d.Stream))
+			if key := "drift/" + d.Stream; !m.jetStream.lagAlerted[key] {
+				m.jetStream.lagAlerted[key] = true
+				m.alertAdmins(ctx, opsalerts.Alert{
+					Kind:     opsalerts.KindJetStreamDrift,
+					Severity: opsalerts.Critical,
+					Summary:  "JetStream stream " + d.Stream + " differs from its configuration and must be recreated",
+					Details:  map[string]interface{}{"stream": d.Stream},
+				})
+			}
+		case d.Created:
+			m.logger.Warn("JetStream stream was missing and has been recreated", slog.String("stream", d.Stream))
+			replaced[d.Stream] = true
+		case d.Updated:
+			m.logger.Info("JetStream stream configuration updated", slog.String("stream", d.Stream))
+			replaced[d.Stream] = true
+		}
+	}
+
+	statuses, err := m.bus.StreamStatuses()
+	if err != nil {
+		return err
+	}
+	for _, st := range statuses {
+		if prev, ok := m.jetStream.streamCreated[st.Name]; ok && !prev.Equal(st.Created) {
+			replaced[st.Name] = true
+		}
+		m.jetStream.streamCreated[st.Name] = st.Created
+	}
+
+	// Recreate registered consumers on replaced streams or that have vanished.
+	for durable, stream := range m.bus.RegisteredConsumers() {
+		exists, err := m.bus.ConsumerExists(stream, durable)
+		if err != nil {
+			m.logger.Warn("failed to check JetStream consumer",
+				slog.String("consumer", durable), slog.String("error", err.Error()))
+			continue
+		}
+		if exists && !replaced[stream] {
+			continue
+		}
+		if err := m.bus.RecreateConsumer(durable); err != nil {
+			m.logger.Error("failed to recreate JetStream consumer",
+				slog.String("consumer", durable), slog.String("error", err.Error()))
+		}
+	}
+
+	for _, st := range statuses {
+		for _, c := range st.Consumers {
+			key := st.Name + "/" + c.Name
+			backlog := consumerBacklog(c)
+			switch {
+			case backlog >= jetStreamLagThreshold && !m.jetStream.lagAlerted[key]:
+				m.jetStream.lagAlerted[key] = true
+				m.logger.Warn("JetStream consumer lagging",
+					slog.String("stream", st.Name), slog.String("consumer", c.Name),
+					slog.Uint64("backlog", backlog))
+				m.alertAdmins(ctx, opsalerts.Alert{
+					Kind:     opsalerts.KindJetStreamLag,
+					Severity: opsalerts.Warning,
+					Summary:  fmt.Sprintf("JetStream consumer %s on %s is %d messages behind", c.Name, st.Name, backlog),
+					Details: map[string]interface{}{
+						"stream":      st.Name,
+						"consumer":    c.Name,
+						"pending":     c.Pending,
+						"ack_pending": c.AckPending,
+						"threshold":   jetStreamLagThreshold,
+					},
+				})
+			case backlog < jetStreamLagRecovered && m.jetStream.lagAlerted[key]:
+				delete(m.jetStream.lagAlerted, key)
+				m.logger.Info("JetStream consumer caught up",
+					slog.String("stream", st.Name), slog.String("consumer", c.Name))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// Job is a named piece of background work that the Manager runs on a
+// schedule and on demand. Every run is recorded in job_runs.
+type Job struct {
+	Name        string
+	Description string
+	// Schedule is "@every <duration>" or a five-field cron expression in
+	// UTC. Interval jobs also run once at startup; cron jobs wait for their
+	// first slot.
+	Schedule string
+	Retry    RetryPolicy
+	// Concurrency caps how many runs of the job may be in flight at once;
+	// 0 means 1. A scheduled run that comes due at the cap is skipped.
+	Concurrency int
+	Run         func(context.Context) error
+}
+
+// RetryPolicy says how often a failing run is retried before it is recorded
+// as failed.
+type RetryPolicy struct {
+	Attempts int           // attempts per run, including the first; 0 means 1
+	Backoff  time.Duration // wait before the second attempt, doubling after each failure
+}
+
+// Job run triggers and statuses as stored in job_runs.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// SubjectJobRun asks a running server to run a job now. Data is
+// {"job": "<name>"}; one node picks each request up.
+const SubjectJobRun = "amityvox.jobs.run"
+
+var (
+	// ErrUnknownJob is returned when triggering a job that is not registered.
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrJobBusy is returned when a job already has as many runs in flight
+	// as its concurrency allows.
+	ErrJobBusy = errors.New("job is already running")
+)
+
+// JobInfo describes a registered job for the admin API.
+type JobInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Schedule    string     `json:"schedule"`
+	Concurrency int        `json:"concurrency"`
+	Attempts    int        `json:"attempts"`
+	Running     int        `json:"running"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+}
+
+// JobRun is one recorded run of a job.
+type JobRun struct {
+	ID         string     `json:"id"`
+	Job        string     `json:"job"`
+	Trigger    string     `json:"trigger"`
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts"`
+	Error      *string    `json:"error,omitempty"`
+	Node       string     `json:"node"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobRunColumns is the column list ScanJobRun reads, in order.
+const JobRunColumns = `id, job, trigger, status, attempts, error, node, started_at, finished_at`
+
+// ScanJobRun scans a row selected with JobRunColumns.
+func ScanJobRun(row pgx.Row) (JobRun, error) {
+	var r JobRun
+	err := row.Scan(&r.ID, &r.Job, &r.Trigger, &r.Status, &r.Attempts, &r.Error, &r.Node, &r.StartedAt, &r.FinishedAt)
+	return r, err
+}
+
+// jobState is a registered job and its in-flight runs.
+type jobState struct {
+	Job
+	sched schedule
+	slots chan struct{} // one token per run in flight
+
+	mu      sync.Mutex
+	nextRun time.Time
+}
+
+// startJob registers a job and schedules it until ctx is cancelled.
+func (m *Manager) startJob(ctx context.Context, job Job) {
+	sched, err := parseSchedule(job.Schedule)
+	if err != nil {
+		m.logger.Error("invalid job schedule, job not started",
+			slog.String("worker", job.Name), slog.String("error", err.Error()))
+		return
+	}
+	st := &jobState{Job: job, sched: sched, slots: make(chan struct{}, max(job.Concurrency, 1))}
+
+	m.jobsMu.Lock()
+	m.jobs[job.Name] = st
+	m.jobsMu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		next := sched.next(time.Now())
+		if _, ok := sched.(everySchedule); ok {
+			next = time.Now()
+		}
+		for !next.IsZero() {
+			st.mu.Lock()
+			st.nextRun = next
+			st.mu.Unlock()
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if _, err := m.startRun(ctx, st, TriggerSchedule); errors.Is(err, ErrJobBusy) {
+				m.logger.Debug("skipping scheduled run, previous run still in flight",
+					slog.String("worker", st.Name))
+			}
+			next = sched.next(time.Now())
+		}
+		m.logger.Error("job schedule never matches, job stopped", slog.String("worker", st.Name))
+	}()
+}
+
+// startPeriodic schedules fn every interval, starting now.
+func (m *Manager) startPeriodic(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	m.startJob(ctx, Job{Name: name, Schedule: "@every " + interval.String(), Run: fn})
+}
+
+// startRun records a run of st and starts it in the background, unless the
+// job is at its concurrency limit. It returns the run's ID.
+func (m *Manager) startRun(ctx context.Context, st *jobState, trigger string) (string, error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	select {
+	case st.slots <- struct{}{}:
+	default:
+		return "", ErrJobBusy
+	}
+
+	runID := models.NewULID().String()
+	m.recordRunStart(ctx, runID, st.Name, trigger)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() { <-st.slots }()
+
+		attempts, err := m.runAttempts(ctx, st)
+		m.recordRunEnd(runID, attempts, err)
+		if err != nil {
+			m.logger.Error("worker error",
+				slog.String("worker", st.Name),
+				slog.Int("attempts", attempts),
+				slog.String("error", err.Error()),
+			)
+		}
+	}()
+	return runID, nil
+}
+
+// runAttempts runs st under its retry policy, returning how many attempts
+// were made and the last error.
+func (m *Manager) runAttempts(ctx context.Context, st *jobState) (int, error) {
+	attempts := max(st.Retry.Attempts, 1)
+	backoff := st.Retry.Backoff
+	for i := 1; ; i++ {
+		err := runOnce(ctx, st.Run)
+		if err == nil || i == attempts || ctx.Err() != nil {
+			return i, err
+		}
+		m.logger.Warn("job attempt failed, retrying",
+			slog.String("worker", st.Name),
+			slog.Int("attempt", i),
+			slog.Duration("backoff", backoff),
+			slog.String("error", err.Error()),
+		)
+		select {
+		case <-ctx.Done():
+			return i, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// runOnce runs fn, turning a panic into an error so one bad run does not
+// take the server down.
+func runOnce(ctx context.Context, fn func(context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return fn(ctx)
+}
+
+func (m *Manager) recordRunStart(ctx context.Context, runID, job, trigger string) {
+	if m.pool == nil {
+		return
+	}
+	if _, err := m.pool.Exec(ctx,
+		`INSERT INTO job_runs (id, job, trigger, status, node, started_at)
+		 VALUES ($1, $2, $3, $4, $5, now())`,
+		runID, job, trigger, RunRunning, m.node); err != nil {
+		m.logger.Debug("recording job run failed", slog.String("worker", job), slog.String("error", err.Error()))
+	}
+}
+
+// recordRunEnd records how a run finished. It runs under its own deadline
+// so runs cut short by shutdown are still recorded.
+func (m *Manager) recordRunEnd(runID string, attempts int, runErr error) {
+	if m.pool == nil {
+		return
+	}
+	status, errText := RunSucceeded, (*string)(nil)
+	if runErr != nil {
+		status = RunFailed
+		msg := runErr.Error()
+		errText = &msg
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := m.pool.Exec(ctx,
+		`UPDATE job_runs SET status = $2, attempts = $3, error = $4, finished_at = now() WHERE id = $1`,
+		runID, status, attempts, errText); err != nil {
+		m.logger.Debug("recording job run result failed", slog.String("run_id", runID), slog.String("error", err.Error()))
+	}
+}
+
+// failInterruptedRuns closes runs this node left open when it last stopped
+// without finishing them.
+func (m *Manager) failInterruptedRuns(ctx context.Context) {
+	if m.pool == nil {
+		return
+	}
+	tag, err := m.pool.Exec(ctx,
+		`UPDATE job_runs SET status = $2, error = 'interrupted: server stopped during run', finished_at = now()
+		 WHERE node = $1 AND status = $3`,
+		m.node, RunFailed, RunRunning)
+	if err != nil {
+		m.logger.Debug("closing interrupted job runs failed", slog.String("error", err.Error()))
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Warn("marked interrupted job runs failed", slog.Int64("runs", tag.RowsAffected()))
+	}
+}
+
+// cleanJobHistory prunes run history: successful runs after a day, failed
+// ones after a week.
+func (m *Manager) cleanJobHistory(ctx context.Context) error {
+	tag, err := m.pool.Exec(ctx,
+		`DELETE FROM job_runs
+		 WHERE finished_at < now() - interval '7 days'
+		    OR (status = $1 AND finished_at < now() - interval '1 day')`,
+		RunSucceeded)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned job run history", slog.Int64("deleted", tag.RowsAffected()))
+	}
+	return nil
+}
+
+// Jobs lists the registered jobs by name.
+func (m *Manager) Jobs() []JobInfo {
+	m.jobsMu.RLock()
+	defer m.jobsMu.RUnlock()
+
+	out := make([]JobInfo, 0, len(m.jobs))
+	for _, st := range m.jobs {
+		info := JobInfo{
+			Name:        st.Name,
+			Description: st.Description,
+			Schedule:    st.Schedule,
+			Concurrency: cap(st.slots),
+			Attempts:    max(st.Retry.Attempts, 1),
+			Running:     len(st.slots),
+		}
+		st.mu.Lock()
+		if !st.nextRun.IsZero() {
+			next := st.nextRun
+			info.NextRun = &next
+		}
+		st.mu.Unlock()
+		out = append(out, info)
+	}
+	slices.SortFunc(out, func(a, b JobInfo) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// TriggerJob starts a run of the named job now, outside its schedule, and
+// returns the run's ID. The run counts against the job's concurrency.
+func (m *Manager) TriggerJob(name string) (string, error) {
+	m.jobsMu.RLock()
+	st, ok := m.jobs[name]
+	ctx := m.runCtx
+	m.jobsMu.RUnlock()
+	if !ok || ctx == nil {
+		return "", ErrUnknownJob
+	}
+	return m.startRun(ctx, st, TriggerManual)
+}
+
+// subscribeJobTriggers runs jobs requested on SubjectJobRun, such as by
+// "amityvox admin run-job".
+func (m *Manager) subscribeJobTriggers() {
+	if m.bus == nil {
+		return
+	}
+	_, err := m.bus.QueueSubscribe(SubjectJobRun, "job-triggers", func(event events.Event) {
+		var req struct {
+			Job string `json:"job"`
+		}
+		if err := json.Unmarshal(event.Data, &req); err != nil || req.Job == "" {
+			return
+		}
+		runID, err := m.TriggerJob(req.Job)
+		if err != nil {
+			m.logger.Warn("manual job trigger refused",
+				slog.String("worker", req.Job), slog.String("error", err.Error()))
+			return
+		}
+		m.logger.Info("job triggered manually",
+			slog.String("worker", req.Job), slog.String("run_id", runID))
+	})
+	if err != nil {
+		m.logger.Error("failed to subscribe to job triggers", slog.String("error", err.Error()))
+	}
+}
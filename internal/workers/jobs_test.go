@@ -0,0 +1,132 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"@every",
+		"@every 10ms",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("parseSchedule(%q) accepted an invalid schedule", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// 2026-03-04 is a Wednesday.
+	from := time.Date(2026, 3, 4, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@every 15m", from.Add(15 * time.Minute)},
+		{"* * * * *", time.Date(2026, 3, 4, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)},
+		{"15 3 * * *", time.Date(2026, 3, 5, 3, 15, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 4 1,15 1 *", time.Date(2027, 1, 1, 4, 30, 0, 0, time.UTC)},
+		// Both day fields restricted: either may match.
+		{"0 0 20 * 5", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := parseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: next(%s) = %s, want %s", tt.spec, from, got, tt.want)
+		}
+	}
+}
+
+func newTestManager() *Manager {
+	m := New(Config{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	m.runCtx = context.Background()
+	return m
+}
+
+func TestRunAttempts_Retries(t *testing.T) {
+	m := newTestManager()
+	var calls int
+	st := &jobState{Job: Job{
+		Name:  "flaky",
+		Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond},
+		Run: func(context.Context) error {
+			calls++
+			if calls < 2 {
+				return errors.New("boom")
+			}
+			return nil
+		},
+	}}
+	attempts, err := m.runAttempts(context.Background(), st)
+	if err != nil || attempts != 2 {
+		t.Errorf("runAttempts = %d, %v; want 2, nil", attempts, err)
+	}
+
+	st.Run = func(context.Context) error { panic("bad job") }
+	attempts, err = m.runAttempts(context.Background(), st)
+	if err == nil || attempts != 3 {
+		t.Errorf("runAttempts of a panicking job = %d, %v; want 3 attempts and an error", attempts, err)
+	}
+}
+
+func TestTriggerJob_Concurrency(t *testing.T) {
+	m := newTestManager()
+	release := make(chan struct{})
+	var runs atomic.Int32
+	m.jobs["slow"] = &jobState{
+		Job: Job{Name: "slow", Run: func(context.Context) error {
+			runs.Add(1)
+			<-release
+			return nil
+		}},
+		slots: make(chan struct{}, 1),
+	}
+
+	if _, err := m.TriggerJob("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("TriggerJob(missing) = %v, want ErrUnknownJob", err)
+	}
+	if id, err := m.TriggerJob("slow"); err != nil || id == "" {
+		t.Fatalf("TriggerJob(slow) = %q, %v", id, err)
+	}
+	if _, err := m.TriggerJob("slow"); !errors.Is(err, ErrJobBusy) {
+		t.Errorf("second TriggerJob(slow) = %v, want ErrJobBusy", err)
+	}
+	if jobs := m.Jobs(); len(jobs) != 1 || jobs[0].Running != 1 {
+		t.Errorf("Jobs() = %+v, want one job with one run in flight", jobs)
+	}
+
+	close(release)
+	m.wg.Wait()
+	if runs.Load() != 1 {
+		t.Errorf("job ran %d times, want 1", runs.Load())
+	}
+	if _, err := m.TriggerJob("slow"); err != nil {
+		t.Errorf("TriggerJob after the run finished = %v", err)
+	}
+	m.wg.Wait()
+}
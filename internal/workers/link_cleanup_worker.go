@@ -0,0 +1,138 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// unattachedFileGrace is how long an upload may wait for the message it was
+// uploaded for before it counts as abandoned.
+const unattachedFileGrace = 24 * time.Hour
+
+// transcriptLinkGrace is how long an expired or revoked transcript link, and
+// its access log, is kept for review before it is deleted.
+const transcriptLinkGrace = 30 * 24 * time.Hour
+
+// Kinds of dead link recorded in cleanup_stats.
+const (
+	cleanupKindInvites     = "invites"
+	cleanupKindFollowers   = "channel_followers"
+	cleanupKindFiles       = "files"
+	cleanupKindTranscripts = "transcript_links"
+)
+
+// cleanDeadLinks removes invites that can no longer be redeemed, channel
+// follows that can no longer deliver, uploads that were never sent and
+// transcript links long past their expiry or revocation, and records how
+// much each pass reclaimed. A failing pass does not stop the others.
+func (m *Manager) cleanDeadLinks(ctx context.Context) error {
+	var errs []error
+
+	if n, err := m.cleanExpiredInvites(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("cleaning invites: %w", err))
+	} else {
+		m.recordCleanup(ctx, cleanupKindInvites, n, 0)
+	}
+
+	if n, err := m.cleanDeadFollowers(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("cleaning channel followers: %w", err))
+	} else {
+		m.recordCleanup(ctx, cleanupKindFollowers, n, 0)
+	}
+
+	if n, err := m.cleanDeadTranscriptLinks(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("cleaning transcript links: %w", err))
+	} else {
+		m.recordCleanup(ctx, cleanupKindTranscripts, n, 0)
+	}
+
+	if m.media != nil {
+		n, size, err := m.media.PruneUnattachedFiles(ctx, time.Now().Add(-unattachedFileGrace))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pruning unattached files: %w", err))
+		} else {
+			if n > 0 {
+				m.logger.Info("pruned unattached files",
+					slog.Int("deleted", n), slog.Int64("bytes", size))
+			}
+			m.recordCleanup(ctx, cleanupKindFiles, int64(n), size)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// cleanExpiredInvites deletes invites past their expiry and invites whose
+// uses have run out.
+func (m *Manager) cleanExpiredInvites(ctx context.Context) (int64, error) {
+	tag, err := m.pool.Exec(ctx,
+		`DELETE FROM invites
+		 WHERE (expires_at IS NOT NULL AND expires_at < NOW())
+		    OR (max_uses > 0 AND uses >= max_uses)`)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned expired invites",
+			slog.Int64("deleted", tag.RowsAffected()))
+	}
+	return tag.RowsAffected(), nil
+}
+
+// cleanDeadFollowers deletes announcement channel follows whose webhook is
+// gone, has moved out of the following guild, or is an outgoing webhook
+// that cannot receive crossposts.
+func (m *Manager) cleanDeadFollowers(ctx context.Context) (int64, error) {
+	tag, err := m.pool.Exec(ctx,
+		`DELETE FROM channel_followers cf
+		 WHERE NOT EXISTS (
+		     SELECT 1 FROM webhooks w
+		     WHERE w.id = cf.webhook_id AND w.guild_id = cf.guild_id
+		       AND COALESCE(w.webhook_type, 'incoming') != 'outgoing'
+		 )`)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned dead channel followers",
+			slog.Int64("deleted", tag.RowsAffected()))
+	}
+	return tag.RowsAffected(), nil
+}
+
+// cleanDeadTranscriptLinks deletes transcript links that expired or were
+// revoked more than transcriptLinkGrace ago, along with their access logs.
+func (m *Manager) cleanDeadTranscriptLinks(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-transcriptLinkGrace)
+	tag, err := m.pool.Exec(ctx,
+		`DELETE FROM channel_transcript_links
+		 WHERE expires_at < $1 OR revoked_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned dead transcript links",
+			slog.Int64("deleted", tag.RowsAffected()))
+	}
+	return tag.RowsAffected(), nil
+}
+
+// recordCleanup adds a pass's results to cleanup_stats. Failures are only
+// logged; the rows are informational.
+func (m *Manager) recordCleanup(ctx context.Context, kind string, removed, bytes int64) {
+	if _, err := m.pool.Exec(ctx,
+		`INSERT INTO cleanup_stats (kind, last_removed, total_removed, bytes_reclaimed, last_run_at)
+		 VALUES ($1, $2, $2, $3, now())
+		 ON CONFLICT (kind) DO UPDATE SET
+		     last_removed = $2,
+		     total_removed = cleanup_stats.total_removed + $2,
+		     bytes_reclaimed = cleanup_stats.bytes_reclaimed + $3,
+		     last_run_at = now()`,
+		kind, removed, bytes); err != nil {
+		m.logger.Warn("failed to record cleanup stats",
+			slog.String("kind", kind), slog.String("error", err.Error()))
+	}
+}
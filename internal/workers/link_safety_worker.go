@@ -0,0 +1,138 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// startLinkSafetyWorker subscribes to message create and edit events and
+// checks the links in each message. Malicious links are rewritten to the
+// warning interstitial and, in guilds, the message is flagged for the
+// moderators with a LINK_FLAG_CREATE event.
+func (m *Manager) startLinkSafetyWorker(ctx context.Context) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		handler := func(event events.Event) {
+			m.processLinkSafety(ctx, event)
+		}
+		for _, subject := range []string{events.SubjectMessageCreate, events.SubjectMessageUpdate} {
+			if _, err := m.bus.QueueSubscribe(subject, "link-safety-workers", handler); err != nil {
+				m.logger.Error("failed to subscribe for link safety",
+					slog.String("subject", subject), slog.String("error", err.Error()))
+				return
+			}
+		}
+
+		m.logger.Info("link safety worker started")
+		<-ctx.Done()
+	}()
+}
+
+// processLinkSafety checks one message's links and rewrites the malicious
+// ones.
+func (m *Manager) processLinkSafety(ctx context.Context, event events.Event) {
+	var msg struct {
+		ID        string  `json:"id"`
+		ChannelID string  `json:"channel_id"`
+		AuthorID  string  `json:"author_id"`
+		Content   *string `json:"content"`
+		Encrypted bool    `json:"encrypted"`
+	}
+	if err := json.Unmarshal(event.Data, &msg); err != nil {
+		return
+	}
+	if msg.ID == "" || msg.Encrypted || msg.Content == nil || *msg.Content == "" {
+		return
+	}
+	urls := m.linkSafety.ExtractURLs(*msg.Content)
+	if len(urls) == 0 {
+		return
+	}
+
+	verdicts, err := m.linkSafety.Check(ctx, urls)
+	if err != nil {
+		m.logger.Warn("link safety check failed",
+			slog.String("message_id", msg.ID), slog.String("error", err.Error()))
+	}
+	if len(verdicts) == 0 {
+		return
+	}
+
+	// Only the content that was checked is rewritten; an edit made in the
+	// meantime gets its own check.
+	content := m.linkSafety.Rewrite(*msg.Content, verdicts)
+	var flags int
+	var guildID *string
+	err = m.pool.QueryRow(ctx,
+		`UPDATE messages m SET content = $2
+		 FROM channels c
+		 WHERE m.id = $1 AND m.content = $3 AND c.id = m.channel_id
+		 RETURNING m.flags, c.guild_id`,
+		msg.ID, content, *msg.Content).Scan(&flags, &guildID)
+	if err == pgx.ErrNoRows {
+		return
+	}
+	if err != nil {
+		m.logger.Error("failed to rewrite malicious links",
+			slog.String("message_id", msg.ID), slog.String("error", err.Error()))
+		return
+	}
+
+	update := map[string]interface{}{
+		"id":         msg.ID,
+		"channel_id": msg.ChannelID,
+		"author_id":  msg.AuthorID,
+		"content":    content,
+	}
+	if flags&models.MessageFlagQuarantined != 0 {
+		m.bus.PublishUserEvent(ctx, events.SubjectQuarantinedMessage, "MESSAGE_UPDATE", msg.AuthorID, update)
+	} else {
+		m.bus.PublishChannelEvent(ctx, events.SubjectMessageUpdate, "MESSAGE_UPDATE", msg.ChannelID, update)
+	}
+
+	m.logger.Info("rewrote malicious links",
+		slog.String("message_id", msg.ID), slog.Int("count", len(verdicts)))
+	if guildID != nil {
+		m.flagMaliciousLinks(ctx, *guildID, msg.ChannelID, msg.ID, msg.AuthorID, verdicts)
+	}
+}
+
+// flagMaliciousLinks queues the message for the guild's moderators. A message
+// flagged again after an edit is reopened with the new verdicts added.
+func (m *Manager) flagMaliciousLinks(ctx context.Context, guildID, channelID, messageID, authorID string, verdicts []models.LinkVerdict) {
+	verdictJSON, err := json.Marshal(verdicts)
+	if err != nil {
+		return
+	}
+	flag := models.MessageLinkFlag{
+		GuildID:   guildID,
+		ChannelID: channelID,
+		MessageID: messageID,
+		AuthorID:  authorID,
+		Status:    "open",
+	}
+	err = m.pool.QueryRow(ctx,
+		`INSERT INTO message_link_flags (id, guild_id, channel_id, message_id, author_id, verdicts, status, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, 'open', now())
+		 ON CONFLICT (message_id) DO UPDATE SET
+		     verdicts = message_link_flags.verdicts || EXCLUDED.verdicts,
+		     status = 'open', resolved_by = NULL, resolved_at = NULL
+		 RETURNING id, verdicts, created_at`,
+		models.NewULID().String(), guildID, channelID, messageID, authorID, verdictJSON,
+	).Scan(&flag.ID, &flag.Verdicts, &flag.CreatedAt)
+	if err != nil {
+		m.logger.Error("failed to flag malicious links",
+			slog.String("message_id", messageID), slog.String("error", err.Error()))
+		return
+	}
+
+	m.bus.PublishGuildEvent(ctx, events.SubjectLinkFlag, "LINK_FLAG_CREATE", guildID, flag)
+}
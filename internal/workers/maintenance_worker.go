@@ -0,0 +1,62 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// maintenanceReturning lists the columns of models.MaintenanceWindow, in
+// the order claimMaintenanceEdges scans them.
+const maintenanceReturning = `RETURNING id, title, message, starts_at, ends_at, read_only, created_by, created_at, cancelled_at`
+
+// announceMaintenance tells clients when scheduled maintenance windows start
+// and end. Each edge is claimed with an UPDATE, so only one node announces
+// it. Read-only enforcement does not depend on this job; it only keeps
+// connected clients informed.
+func (m *Manager) announceMaintenance(ctx context.Context) error {
+	started, err := m.claimMaintenanceEdges(ctx,
+		`UPDATE maintenance_windows SET started_at = now()
+		 WHERE cancelled_at IS NULL AND started_at IS NULL
+		   AND starts_at <= now() AND ends_at > now()
+		 `+maintenanceReturning)
+	if err != nil {
+		return fmt.Errorf("claiming started maintenance windows: %w", err)
+	}
+	for _, mw := range started {
+		m.bus.PublishBroadcastEvent(ctx, events.SubjectMaintenance, "MAINTENANCE_START", mw)
+	}
+
+	ended, err := m.claimMaintenanceEdges(ctx,
+		`UPDATE maintenance_windows SET ended_at = now()
+		 WHERE cancelled_at IS NULL AND started_at IS NOT NULL AND ended_at IS NULL
+		   AND ends_at <= now()
+		 `+maintenanceReturning)
+	if err != nil {
+		return fmt.Errorf("claiming ended maintenance windows: %w", err)
+	}
+	for _, mw := range ended {
+		m.bus.PublishBroadcastEvent(ctx, events.SubjectMaintenance, "MAINTENANCE_END", mw)
+	}
+	return nil
+}
+
+func (m *Manager) claimMaintenanceEdges(ctx context.Context, query string) ([]models.MaintenanceWindow, error) {
+	rows, err := m.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []models.MaintenanceWindow
+	for rows.Next() {
+		var mw models.MaintenanceWindow
+		if err := rows.Scan(&mw.ID, &mw.Title, &mw.Message, &mw.StartsAt, &mw.EndsAt,
+			&mw.ReadOnly, &mw.CreatedBy, &mw.CreatedAt, &mw.CancelledAt); err != nil {
+			return out, err
+		}
+		out = append(out, mw)
+	}
+	return out, rows.Err()
+}
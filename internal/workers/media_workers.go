@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os/exec"
 	"strings"
 	"time"
@@ -189,6 +190,8 @@ type EmbedData struct {
 	Title       string `json:"title,omitempty"`
 	Description string `json:"description,omitempty"`
 	Image       string `json:"image,omitempty"`
+	ImageWidth  int    `json:"image_width,omitempty"`
+	ImageHeight int    `json:"image_height,omitempty"`
 	SiteName    string `json:"site_name,omitempty"`
 	Type        string `json:"type,omitempty"`
 }
@@ -241,6 +244,7 @@ func (m *Manager) processEmbedJob(ctx context.Context, job EmbedJob) {
 			continue
 		}
 		if embed.Title != "" || embed.Description != "" {
+			m.cacheEmbedImage(ctx, embed)
 			embeds = append(embeds, *embed)
 		}
 	}
@@ -280,6 +284,68 @@ func (m *Manager) processEmbedJob(ctx context.Context, job EmbedJob) {
 	)
 }
 
+// cacheEmbedImage replaces an embed's image with a copy re-hosted through
+// the media service, so clients do not hotlink the linked site. An image
+// that cannot be cached is dropped rather than hotlinked.
+func (m *Manager) cacheEmbedImage(ctx context.Context, embed *EmbedData) {
+	if embed.Image == "" || m.media == nil || !m.media.CachesEmbedImages() {
+		return
+	}
+	img, err := m.media.CacheEmbedImage(ctx, resolveEmbedURL(embed.URL, embed.Image))
+	if err != nil {
+		m.logger.Debug("embed image not cached",
+			slog.String("url", embed.Image),
+			slog.String("error", err.Error()),
+		)
+		embed.Image = ""
+		return
+	}
+	embed.Image = img.URL
+	embed.ImageWidth = img.Width
+	embed.ImageHeight = img.Height
+}
+
+// resolveEmbedURL resolves a possibly relative og:image against the page URL.
+func resolveEmbedURL(pageURL, ref string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// cleanExpiredEmbedImages removes cached embed images past their expiry.
+func (m *Manager) cleanExpiredEmbedImages(ctx context.Context) error {
+	n, err := m.media.CleanExpiredEmbedImages(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		m.logger.Info("cleaned expired embed images", slog.Int("deleted", n))
+	}
+	return nil
+}
+
+// imageVariantBatch caps the images the image-variants job renders per run.
+const imageVariantBatch = 50
+
+// generateImageVariants renders the variants of images uploaded since the
+// last run, and backfills images uploaded before variants existed.
+func (m *Manager) generateImageVariants(ctx context.Context) error {
+	n, err := m.media.GenerateImageVariants(ctx, imageVariantBatch)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		m.logger.Debug("rendered image variants", slog.Int("images", n))
+	}
+	return nil
+}
+
 // unfurlURL fetches a URL and extracts OpenGraph metadata for link previews.
 func unfurlURL(ctx context.Context, rawURL string) (*EmbedData, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -0,0 +1,30 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// reconcileMemberCounts corrects guilds whose cached member_count has drifted
+// from their guild_members rows. Triggers keep the count in step as rows are
+// inserted and deleted, but bulk loads and restores can bypass them. Guilds
+// homed on another instance are left alone: their count is the home
+// instance's, while only this instance's members have rows here.
+func (m *Manager) reconcileMemberCounts(ctx context.Context) error {
+	tag, err := m.pool.Exec(ctx,
+		`UPDATE guilds g SET member_count = c.n
+		 FROM (SELECT g2.id, (SELECT count(*) FROM guild_members gm WHERE gm.guild_id = g2.id) AS n
+		       FROM guilds g2
+		       WHERE g2.instance_id IS NULL OR g2.instance_id = $1) c
+		 WHERE g.id = c.id AND g.member_count <> c.n`,
+		m.instanceID)
+	if err != nil {
+		return fmt.Errorf("reconciling member counts: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("corrected drifted guild member counts",
+			slog.Int64("guilds", tag.RowsAffected()))
+	}
+	return nil
+}
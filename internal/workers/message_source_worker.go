@@ -0,0 +1,67 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// rollupMessageSources recomputes today's and yesterday's rows of
+// message_source_daily from the messages table. Recomputing rather than
+// incrementing keeps the job idempotent and picks up messages that arrived
+// around midnight. System messages are not counted.
+//
+// Webhook messages are attributed to their webhook. Messages carrying a
+// bridge_source, or posted by a bot under a masquerade, count as bridged;
+// other bot messages count as the bot's.
+func (m *Manager) rollupMessageSources(ctx context.Context) error {
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	tag, err := m.pool.Exec(ctx,
+		`INSERT INTO message_source_daily (day, guild_id, channel_id, source, source_id, messages)
+		 SELECT day, guild_id, channel_id, source, source_id, count(*)
+		 FROM (
+		     SELECT (m.created_at AT TIME ZONE 'UTC')::date AS day, c.guild_id, m.channel_id,
+		            CASE
+		                WHEN m.message_type = 'webhook' THEN 'webhook'
+		                WHEN m.bridge_source IS NOT NULL THEN 'bridge'
+		                WHEN u.flags & $2 != 0 AND m.masquerade_name IS NOT NULL THEN 'bridge'
+		                WHEN u.flags & $2 != 0 THEN 'bot'
+		                ELSE 'human'
+		            END AS source,
+		            CASE
+		                WHEN m.message_type = 'webhook' THEN COALESCE(m.webhook_id, '')
+		                WHEN m.bridge_source IS NOT NULL THEN m.bridge_source
+		                WHEN u.flags & $2 != 0 THEN m.author_id
+		                ELSE ''
+		            END AS source_id
+		     FROM messages m
+		     JOIN channels c ON c.id = m.channel_id
+		     LEFT JOIN users u ON u.id = m.author_id
+		     WHERE m.created_at >= $1 AND c.guild_id IS NOT NULL
+		       AND m.message_type NOT LIKE 'system%'
+		 ) s
+		 GROUP BY day, guild_id, channel_id, source, source_id
+		 ON CONFLICT (day, guild_id, channel_id, source, source_id)
+		 DO UPDATE SET messages = EXCLUDED.messages`,
+		since, models.UserFlagBot)
+	if err != nil {
+		return err
+	}
+	m.logger.Debug("rolled up message sources", slog.Int64("rows", tag.RowsAffected()))
+	return nil
+}
+
+func (m *Manager) cleanOldMessageSources(ctx context.Context) error {
+	tag, err := m.pool.Exec(ctx,
+		`DELETE FROM message_source_daily WHERE day < CURRENT_DATE - 90`)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned old message source rollups",
+			slog.Int64("deleted", tag.RowsAffected()))
+	}
+	return nil
+}
@@ -9,6 +9,9 @@ import (
 
 	"github.com/amityvox/amityvox/internal/events"
 	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/notifications"
+	"github.com/amityvox/amityvox/internal/presence"
+	"github.com/amityvox/amityvox/internal/spoilers"
 )
 
 // startNotificationWorker subscribes to multiple NATS event subjects and creates
@@ -31,6 +34,7 @@ func (m *Manager) startNotificationWorker(ctx context.Context) {
 			{events.SubjectGuildBanAdd, m.handleBanNotification},
 			{events.SubjectGuildMemberRemove, m.handleMemberRemoveNotification},
 			{events.SubjectAutomodAction, m.handleAutomodNotification},
+			{events.SubjectUserUpdate, m.handleDNDResume},
 		}
 
 		for _, s := range subs {
@@ -94,24 +98,45 @@ func (m *Manager) createNotifForRecipients(ctx context.Context, recipients map[s
 	}
 }
 
+// blockersOf returns the users who have blocked or ignored userID. They get
+// no notifications about what userID does.
+func (m *Manager) blockersOf(ctx context.Context, userID string) map[string]bool {
+	blockers := map[string]bool{}
+	rows, err := m.pool.Query(ctx,
+		`SELECT user_id FROM user_relationships WHERE target_id = $1 AND status = 'blocked'`, userID)
+	if err != nil {
+		return blockers
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uid string
+		if rows.Scan(&uid) == nil {
+			blockers[uid] = true
+		}
+	}
+	return blockers
+}
+
 // strPtr returns a pointer to s.
 func strPtr(s string) *string { return &s }
 
 // handleMessageNotification handles MESSAGE_CREATE — produces mention, reply, dm notifications.
 func (m *Manager) handleMessageNotification(ctx context.Context, event events.Event) {
 	var msg struct {
-		ID             string   `json:"id"`
-		ChannelID      string   `json:"channel_id"`
-		GuildID        string   `json:"guild_id"`
-		AuthorID       string   `json:"author_id"`
-		Content        string   `json:"content"`
-		Flags          int      `json:"flags"`
-		MessageType    string   `json:"message_type"`
-		ReplyToIDs     []string `json:"reply_to_ids"`
-		MentionUserIDs []string `json:"mention_user_ids"`
-		MentionRoleIDs []string `json:"mention_role_ids"`
-		MentionHere    bool     `json:"mention_here"`
-		ThreadID       *string  `json:"thread_id"`
+		ID              string   `json:"id"`
+		ChannelID       string   `json:"channel_id"`
+		GuildID         string   `json:"guild_id"`
+		AuthorID        string   `json:"author_id"`
+		Content         string   `json:"content"`
+		Flags           int      `json:"flags"`
+		MessageType     string   `json:"message_type"`
+		ReplyToIDs      []string `json:"reply_to_ids"`
+		MentionUserIDs  []string `json:"mention_user_ids"`
+		MentionRoleIDs  []string `json:"mention_role_ids"`
+		MentionHere     bool     `json:"mention_here"`
+		MentionEveryone bool     `json:"mention_everyone"`
+		ThreadID        *string  `json:"thread_id"`
+		ContentWarning  *string  `json:"content_warning"`
 	}
 	if err := json.Unmarshal(event.Data, &msg); err != nil {
 		return
@@ -182,8 +207,12 @@ func (m *Manager) handleMessageNotification(ctx context.Context, event events.Ev
 		}
 	}
 
-	// @here mentions.
-	if msg.MentionHere && msg.GuildID != "" {
+	// @here and @everyone mentions. Members reached only through @here are
+	// tracked so Do Not Disturb can drop them below; @everyone reaches them
+	// regardless.
+	hereOnly := map[string]bool{}
+	massMention := msg.MentionHere || msg.MentionEveryone
+	if massMention && msg.GuildID != "" {
 		rows, err := m.pool.Query(ctx,
 			`SELECT user_id FROM guild_members WHERE guild_id = $1 AND user_id <> $2`,
 			msg.GuildID, msg.AuthorID)
@@ -191,6 +220,9 @@ func (m *Manager) handleMessageNotification(ctx context.Context, event events.Ev
 			for rows.Next() {
 				var uid string
 				if rows.Scan(&uid) == nil && !replyRecipients[uid] && !dmRecipients[uid] {
+					if !mentionRecipients[uid] && !msg.MentionEveryone {
+						hereOnly[uid] = true
+					}
 					mentionRecipients[uid] = true
 				}
 			}
@@ -198,9 +230,12 @@ func (m *Manager) handleMessageNotification(ctx context.Context, event events.Ev
 		}
 	}
 
-	// Build context.
-	content := msg.Content
-	if content == "" {
+	// Build context. Previews cannot be revealed, so a content warning
+	// stands in for the content and spoilers are masked.
+	content := spoilers.Mask(msg.Content)
+	if msg.ContentWarning != nil {
+		content = "CW: " + *msg.ContentWarning
+	} else if content == "" {
 		content = "[Attachment]"
 	}
 	if len(content) > 200 {
@@ -229,9 +264,21 @@ func (m *Manager) handleMessageNotification(ctx context.Context, event events.Ev
 		Content:       strPtr(content),
 	}
 
+	// Users who blocked the author hear nothing from them.
+	for uid := range m.blockersOf(ctx, msg.AuthorID) {
+		delete(mentionRecipients, uid)
+		delete(replyRecipients, uid)
+		delete(dmRecipients, uid)
+	}
+
 	// Check notification preferences for each recipient before creating.
 	for uid := range mentionRecipients {
-		if !m.notifications.ShouldNotify(ctx, uid, msg.GuildID, msg.ChannelID, true, false, msg.MentionHere) {
+		if !m.notifications.ShouldNotify(ctx, uid, msg.GuildID, msg.ChannelID, true, false, massMention) {
+			delete(mentionRecipients, uid)
+			continue
+		}
+		if hereOnly[uid] && m.notifications.DoNotDisturb(ctx, uid) {
+			m.notifications.RecordSuppressed(ctx, uid, notifications.SuppressedHere)
 			delete(mentionRecipients, uid)
 		}
 	}
@@ -268,7 +315,8 @@ func (m *Manager) handleMessageNotification(ctx context.Context, event events.Ev
 	}
 }
 
-// handleReactionNotification handles MESSAGE_REACTION_ADD — notifies message author.
+// handleReactionNotification handles MESSAGE_REACTION_ADD — notifies message
+// author, as their reaction setting allows.
 func (m *Manager) handleReactionNotification(ctx context.Context, event events.Event) {
 	var data struct {
 		MessageID string `json:"message_id"`
@@ -288,8 +336,34 @@ func (m *Manager) handleReactionNotification(ctx context.Context, event events.E
 	).Scan(&authorID); err != nil || authorID == data.UserID {
 		return // skip if reactor is the author
 	}
+	var blocked, friends bool
+	m.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM user_relationships
+		               WHERE user_id = $1 AND target_id = $2 AND status = 'blocked'),
+		        EXISTS(SELECT 1 FROM user_relationships
+		               WHERE user_id = $1 AND target_id = $2 AND status = 'friend')`,
+		authorID, data.UserID).Scan(&blocked, &friends)
+	if blocked {
+		return // the author blocked the reactor
+	}
+	switch m.notifications.ReactionSetting(ctx, authorID) {
+	case notifications.ReactionsOff:
+		return
+	case notifications.ReactionsFriends:
+		if !friends {
+			return
+		}
+	}
+
+	// A reaction is addressed to the author like a reply, so it gets
+	// through a mentions-only level; mutes and "none" still apply.
+	if !m.notifications.ShouldNotify(ctx, authorID, data.GuildID, data.ChannelID, true, false, false) {
+		return
+	}
 
-	if !m.notifications.ShouldNotify(ctx, authorID, data.GuildID, data.ChannelID, false, false, false) {
+	// Past the first few reactions to a message, they are batched into
+	// digests sent by the reaction-digests job.
+	if m.notifications.HoldReaction(ctx, authorID, data.MessageID, data.ChannelID, data.GuildID, data.UserID, data.Emoji) {
 		return
 	}
 
@@ -613,6 +687,24 @@ func (m *Manager) handleAutomodNotification(ctx context.Context, event events.Ev
 	}
 }
 
+// handleDNDResume handles USER_UPDATE — once the user's presence is no
+// longer Do Not Disturb, it delivers the summary of what was held back.
+func (m *Manager) handleDNDResume(ctx context.Context, event events.Event) {
+	var user struct {
+		StatusPresence string `json:"status_presence"`
+	}
+	if event.UserID == "" || json.Unmarshal(event.Data, &user) != nil {
+		return
+	}
+	if user.StatusPresence == "" || presence.IsDoNotDisturb(user.StatusPresence) {
+		return
+	}
+	if err := m.notifications.FlushDNDSummary(ctx, m.bus, event.UserID); err != nil {
+		m.logger.Warn("failed to send do not disturb summary",
+			slog.String("user_id", event.UserID), slog.String("error", err.Error()))
+	}
+}
+
 // nilIfEmpty returns a pointer to s if non-empty, nil otherwise.
 func nilIfEmpty(s string) *string {
 	if s == "" {
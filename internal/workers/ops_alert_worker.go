@@ -0,0 +1,276 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+	"github.com/amityvox/amityvox/internal/opsalerts"
+	"github.com/amityvox/amityvox/internal/regions"
+)
+
+const (
+	// registrationSpikeWindow and registrationSpikeThreshold: this many
+	// local accounts created within the window raise an alert, which clears
+	// once the count falls below half the threshold.
+	registrationSpikeWindow    = 10 * time.Minute
+	registrationSpikeThreshold = 50
+
+	// automodSurgeWindow and automodSurgeThreshold: this many AutoMod
+	// actions in one guild within the window raise an alert for the guild.
+	automodSurgeWindow    = 5 * time.Minute
+	automodSurgeThreshold = 100
+)
+
+// opsAlertState carries what the ops-alerts job remembers between runs, so
+// each problem alerts once when it starts and, where it makes sense, once
+// when it ends.
+type opsAlertState struct {
+	peersDown         map[string]bool // federation peer ID
+	storageDown       map[string]bool // storage region name
+	registrationSpike bool
+	automodSurges     map[string]bool // guild ID
+}
+
+// alertAdmins dispatches an ADMIN_ALERT event to every instance admin and
+// sends the alert to the operator alert webhooks that want it.
+func (m *Manager) alertAdmins(ctx context.Context, a opsalerts.Alert) {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC()
+	}
+	m.opsAlerts.Send(ctx, a)
+
+	rows, err := m.pool.Query(ctx,
+		`SELECT id FROM users WHERE flags & $1 <> 0`, models.UserFlagAdmin)
+	if err != nil {
+		m.logger.Error("failed to load admins for alert", slog.String("error", err.Error()))
+		return
+	}
+	defer rows.Close()
+
+	alert := map[string]interface{}{
+		"kind":       a.Kind,
+		"severity":   a.Severity,
+		"summary":    a.Summary,
+		"details":    a.Details,
+		"created_at": a.CreatedAt,
+	}
+	for rows.Next() {
+		var userID string
+		if rows.Scan(&userID) == nil {
+			m.bus.PublishUserEvent(ctx, events.SubjectAdminAlert, "ADMIN_ALERT", userID, alert)
+		}
+	}
+}
+
+// checkOperations looks for federation peers going down, storage regions
+// failing, registration spikes and AutoMod surges, and alerts on each change.
+// A failing check is logged and does not stop the others.
+func (m *Manager) checkOperations(ctx context.Context) error {
+	if m.opsAlert.peersDown == nil {
+		m.opsAlert.peersDown = make(map[string]bool)
+		m.opsAlert.storageDown = make(map[string]bool)
+		m.opsAlert.automodSurges = make(map[string]bool)
+	}
+	for _, c := range []struct {
+		name  string
+		check func(context.Context) error
+	}{
+		{"federation", m.checkFederationPeers},
+		{"storage", m.checkStorage},
+		{"registrations", m.checkRegistrationSpike},
+		{"automod", m.checkAutoModSurges},
+	} {
+		if err := c.check(ctx); err != nil {
+			m.logger.Warn("operations check failed",
+				slog.String("check", c.name), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
+
+// checkFederationPeers alerts when a peer's health turns degraded or
+// unreachable, and again when it is healthy.
+func (m *Manager) checkFederationPeers(ctx context.Context) error {
+	rows, err := m.pool.Query(ctx,
+		`SELECT s.peer_id, COALESCE(i.domain, s.peer_id), s.status
+		 FROM federation_peer_status s
+		 LEFT JOIN instances i ON i.id = s.peer_id
+		 WHERE s.status IN ('healthy', 'degraded', 'unreachable')`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type peer struct{ id, domain, status string }
+	var peers []peer
+	for rows.Next() {
+		var p peer
+		if err := rows.Scan(&p.id, &p.domain, &p.status); err != nil {
+			return err
+		}
+		peers = append(peers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range peers {
+		down := p.status != "healthy"
+		switch {
+		case down && !m.opsAlert.peersDown[p.id]:
+			m.opsAlert.peersDown[p.id] = true
+			m.alertAdmins(ctx, opsalerts.Alert{
+				Kind:     opsalerts.KindFederationPeerDown,
+				Severity: opsalerts.Warning,
+				Summary:  fmt.Sprintf("Federation peer %s is %s", p.domain, p.status),
+				Details:  map[string]interface{}{"peer_id": p.id, "domain": p.domain, "status": p.status},
+			})
+		case !down && m.opsAlert.peersDown[p.id]:
+			delete(m.opsAlert.peersDown, p.id)
+			m.alertAdmins(ctx, opsalerts.Alert{
+				Kind:     opsalerts.KindFederationPeerRecovered,
+				Severity: opsalerts.Info,
+				Summary:  fmt.Sprintf("Federation peer %s is healthy again", p.domain),
+				Details:  map[string]interface{}{"peer_id": p.id, "domain": p.domain},
+			})
+		}
+	}
+	return nil
+}
+
+// checkStorage alerts when a storage region's bucket cannot be reached, and
+// again when it can. With several regions their failover health checks are
+// used; a single bucket is probed here.
+func (m *Manager) checkStorage(ctx context.Context) error {
+	if m.media == nil {
+		return nil
+	}
+	statuses := m.media.RegionStatuses()
+	if len(statuses) < 2 {
+		st := regions.Status{Name: "primary", Primary: true, Healthy: true}
+		if len(statuses) == 1 {
+			st.Name = statuses[0].Name
+		}
+		if err := m.media.HealthCheck(ctx); err != nil {
+			st.Healthy, st.Error = false, err.Error()
+		}
+		statuses = []regions.Status{st}
+	}
+
+	for _, st := range statuses {
+		switch {
+		case !st.Healthy && !m.opsAlert.storageDown[st.Name]:
+			m.opsAlert.storageDown[st.Name] = true
+			m.alertAdmins(ctx, opsalerts.Alert{
+				Kind:     opsalerts.KindStorageError,
+				Severity: opsalerts.Critical,
+				Summary:  fmt.Sprintf("Storage region %s is unreachable", st.Name),
+				Details:  map[string]interface{}{"region": st.Name, "primary": st.Primary, "error": st.Error},
+			})
+		case st.Healthy && m.opsAlert.storageDown[st.Name]:
+			delete(m.opsAlert.storageDown, st.Name)
+			m.alertAdmins(ctx, opsalerts.Alert{
+				Kind:     opsalerts.KindStorageRecovered,
+				Severity: opsalerts.Info,
+				Summary:  fmt.Sprintf("Storage region %s is reachable again", st.Name),
+				Details:  map[string]interface{}{"region": st.Name},
+			})
+		}
+	}
+	return nil
+}
+
+// checkRegistrationSpike alerts when local sign-ups exceed the spike
+// threshold, which often means a spam wave.
+func (m *Manager) checkRegistrationSpike(ctx context.Context) error {
+	var count int
+	if err := m.pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM users
+		 WHERE created_at > now() - make_interval(secs => $1)
+		   AND instance_id = $2 AND flags & $3 = 0`,
+		registrationSpikeWindow.Seconds(), m.instanceID, models.UserFlagBot,
+	).Scan(&count); err != nil {
+		return err
+	}
+
+	switch {
+	case count >= registrationSpikeThreshold && !m.opsAlert.registrationSpike:
+		m.opsAlert.registrationSpike = true
+		m.alertAdmins(ctx, opsalerts.Alert{
+			Kind:     opsalerts.KindRegistrationSpike,
+			Severity: opsalerts.Warning,
+			Summary:  fmt.Sprintf("%d accounts registered in the last %d minutes", count, int(registrationSpikeWindow.Minutes())),
+			Details: map[string]interface{}{
+				"registrations":  count,
+				"window_minutes": int(registrationSpikeWindow.Minutes()),
+				"threshold":      registrationSpikeThreshold,
+			},
+		})
+	case count < registrationSpikeThreshold/2 && m.opsAlert.registrationSpike:
+		m.opsAlert.registrationSpike = false
+	}
+	return nil
+}
+
+// checkAutoModSurges alerts for each guild where AutoMod acted more than
+// the surge threshold, such as during a raid.
+func (m *Manager) checkAutoModSurges(ctx context.Context) error {
+	rows, err := m.pool.Query(ctx,
+		`SELECT a.guild_id, g.name, COUNT(*)
+		 FROM automod_actions a
+		 JOIN guilds g ON g.id = a.guild_id
+		 WHERE a.created_at > now() - make_interval(secs => $1)
+		 GROUP BY a.guild_id, g.name
+		 HAVING COUNT(*) >= $2`,
+		automodSurgeWindow.Seconds(), automodSurgeThreshold)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type surge struct {
+		guildID, name string
+		count         int
+	}
+	var surges []surge
+	for rows.Next() {
+		var s surge
+		if err := rows.Scan(&s.guildID, &s.name, &s.count); err != nil {
+			return err
+		}
+		surges = append(surges, s)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	active := make(map[string]bool, len(surges))
+	for _, s := range surges {
+		active[s.guildID] = true
+		if m.opsAlert.automodSurges[s.guildID] {
+			continue
+		}
+		m.opsAlert.automodSurges[s.guildID] = true
+		m.alertAdmins(ctx, opsalerts.Alert{
+			Kind:     opsalerts.KindAutoModSurge,
+			Severity: opsalerts.Warning,
+			Summary:  fmt.Sprintf("AutoMod acted %d times in %s in the last %d minutes", s.count, s.name, int(automodSurgeWindow.Minutes())),
+			Details: map[string]interface{}{
+				"guild_id":       s.guildID,
+				"guild_name":     s.name,
+				"actions":        s.count,
+				"window_minutes": int(automodSurgeWindow.Minutes()),
+			},
+		})
+	}
+	for guildID := range m.opsAlert.automodSurges {
+		if !active[guildID] {
+			delete(m.opsAlert.automodSurges, guildID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// pinboard is the part of a guild pinboard the worker needs.
+type pinboard struct {
+	ID                string
+	ChannelID         string
+	Emoji             string
+	Threshold         int
+	SelfStar          bool
+	NSFWAllowed       bool
+	SourceChannelIDs  []string
+	IgnoredChannelIDs []string
+}
+
+// watches reports whether reactions in channelID count toward the board.
+// A board never collects from its own channel.
+func (b pinboard) watches(channelID string) bool {
+	if channelID == b.ChannelID || slices.Contains(b.IgnoredChannelIDs, channelID) {
+		return false
+	}
+	return len(b.SourceChannelIDs) == 0 || slices.Contains(b.SourceChannelIDs, channelID)
+}
+
+// pinboardPost is the content of a board's copy of a message.
+func pinboardPost(count int, emoji, channelID string, content *string) string {
+	post := fmt.Sprintf("**%d** %s | <#%s>\n", count, emoji, channelID)
+	if content != nil {
+		post += *content
+	}
+	return post
+}
+
+// startPinboardWorker subscribes to reaction events and keeps the guild's
+// pinboards up to date: a message is posted to a board once it has enough of
+// the board's emoji, and the count on the board's copy follows later
+// reactions.
+func (m *Manager) startPinboardWorker(ctx context.Context) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		handler := func(event events.Event) {
+			m.processPinboards(ctx, event)
+		}
+		for _, subject := range []string{events.SubjectMessageReactionAdd, events.SubjectMessageReactionDel} {
+			if _, err := m.bus.QueueSubscribe(subject, "pinboard-workers", handler); err != nil {
+				m.logger.Error("failed to subscribe for pinboards",
+					slog.String("subject", subject), slog.String("error", err.Error()))
+				return
+			}
+		}
+
+		m.logger.Info("pinboard worker started")
+		<-ctx.Done()
+	}()
+}
+
+// processPinboards re-counts a reaction on a message for every board that
+// uses that emoji and watches the message's channel.
+func (m *Manager) processPinboards(ctx context.Context, event events.Event) {
+	var data struct {
+		MessageID string `json:"message_id"`
+		Emoji     string `json:"emoji"`
+	}
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.MessageID == "" {
+		return
+	}
+
+	var (
+		channelID, authorID string
+		guildID             *string
+		content             *string
+		encrypted, nsfw     bool
+	)
+	err := m.pool.QueryRow(ctx,
+		`SELECT m.channel_id, m.author_id, m.content, m.encrypted, c.guild_id, c.nsfw
+		 FROM messages m JOIN channels c ON c.id = m.channel_id
+		 WHERE m.id = $1`, data.MessageID,
+	).Scan(&channelID, &authorID, &content, &encrypted, &guildID, &nsfw)
+	// Encrypted messages cannot be copied to a board in readable form.
+	if err != nil || guildID == nil || encrypted {
+		return
+	}
+
+	rows, err := m.pool.Query(ctx,
+		`SELECT id, channel_id, emoji, threshold, self_star, nsfw_allowed, source_channel_ids, ignored_channel_ids
+		 FROM pinboards WHERE guild_id = $1 AND enabled AND emoji = $2`,
+		*guildID, data.Emoji)
+	if err != nil {
+		m.logger.Error("failed to load pinboards", slog.String("error", err.Error()))
+		return
+	}
+	boards, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pinboard, error) {
+		var b pinboard
+		err := row.Scan(&b.ID, &b.ChannelID, &b.Emoji, &b.Threshold, &b.SelfStar, &b.NSFWAllowed,
+			&b.SourceChannelIDs, &b.IgnoredChannelIDs)
+		return b, err
+	})
+	if err != nil {
+		m.logger.Error("failed to read pinboards", slog.String("error", err.Error()))
+		return
+	}
+
+	for _, b := range boards {
+		if !b.watches(channelID) || (nsfw && !b.NSFWAllowed) {
+			continue
+		}
+
+		var count int
+		m.pool.QueryRow(ctx,
+			`SELECT COUNT(*) FROM reactions WHERE message_id = $1 AND emoji = $2 AND ($3 OR user_id <> $4)`,
+			data.MessageID, data.Emoji, b.SelfStar, authorID).Scan(&count)
+		m.updatePinboardEntry(ctx, b, *guildID, channelID, data.MessageID, authorID, content, count)
+	}
+}
+
+// updatePinboardEntry records a message's count on one board, posting it to
+// the board the first time it reaches the threshold. Messages already on a
+// board stay there if their count drops.
+func (m *Manager) updatePinboardEntry(ctx context.Context, b pinboard, guildID, channelID, messageID, authorID string, content *string, count int) {
+	post := pinboardPost(count, b.Emoji, channelID, content)
+
+	var boardMessageID *string
+	err := m.pool.QueryRow(ctx,
+		`UPDATE starboard_entries SET star_count = $3
+		 WHERE board_id = $1 AND source_message_id = $2
+		 RETURNING starboard_message_id`,
+		b.ID, messageID, count).Scan(&boardMessageID)
+	if err == nil {
+		if boardMessageID != nil {
+			m.pool.Exec(ctx, `UPDATE messages SET content = $2 WHERE id = $1`, *boardMessageID, post)
+			m.bus.PublishChannelEvent(ctx, events.SubjectMessageUpdate, "MESSAGE_UPDATE", b.ChannelID, map[string]interface{}{
+				"id":         *boardMessageID,
+				"channel_id": b.ChannelID,
+				"author_id":  authorID,
+				"content":    post,
+			})
+		}
+		return
+	}
+	if err != pgx.ErrNoRows || count < b.Threshold {
+		return
+	}
+
+	// The unique (board_id, source_message_id) key makes sure a message that
+	// crosses the threshold on two workers at once is posted only once.
+	entryID := models.NewULID().String()
+	tag, err := m.pool.Exec(ctx,
+		`INSERT INTO starboard_entries (id, guild_id, board_id, source_message_id, source_channel_id,
+		     star_count, author_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		 ON CONFLICT (board_id, source_message_id) DO NOTHING`,
+		entryID, guildID, b.ID, messageID, channelID, count, authorID)
+	if err != nil || tag.RowsAffected() == 0 {
+		return
+	}
+
+	postID := models.NewULID().String()
+	if _, err := m.pool.Exec(ctx,
+		`INSERT INTO messages (id, channel_id, author_id, content, message_type, created_at)
+		 VALUES ($1, $2, $3, $4, 'default', NOW())`,
+		postID, b.ChannelID, authorID, post); err != nil {
+		m.logger.Error("failed to post to pinboard",
+			slog.String("board_id", b.ID), slog.String("error", err.Error()))
+		return
+	}
+	m.pool.Exec(ctx, `UPDATE starboard_entries SET starboard_message_id = $2 WHERE id = $1`, entryID, postID)
+	m.pool.Exec(ctx, `UPDATE channels SET last_message_id = $1 WHERE id = $2`, postID, b.ChannelID)
+
+	m.bus.PublishChannelEvent(ctx, events.SubjectMessageCreate, "MESSAGE_CREATE", b.ChannelID, map[string]interface{}{
+		"id":         postID,
+		"channel_id": b.ChannelID,
+		"author_id":  authorID,
+		"content":    post,
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/events"
 )
 
@@ -60,18 +61,49 @@ func (m *Manager) runRetentionPolicies(ctx context.Context) error {
 	return nil
 }
 
-// executeRetentionPolicy runs a single retention policy: batch-deletes messages
-// older than the cutoff, optionally removes S3 attachments, and publishes bulk
-// delete events.
+// executeRetentionPolicy runs a single retention policy: purges messages
+// older than its maximum age and records the run.
 func (m *Manager) executeRetentionPolicy(ctx context.Context, policyID string, channelID, guildID *string, maxAgeDays int, deleteAttachments, deletePins bool) error {
 	cutoff := time.Now().UTC().Add(-time.Duration(maxAgeDays) * 24 * time.Hour)
+	totalDeleted, err := m.purgeMessagesBefore(ctx, channelID, guildID, cutoff, deleteAttachments, deletePins)
+	if err != nil {
+		return err
+	}
+
+	// Update policy stats.
+	_, err = m.pool.Exec(ctx,
+		`UPDATE data_retention_policies
+		 SET last_run_at = now(),
+		     next_run_at = now() + INTERVAL '24 hours',
+		     messages_deleted = messages_deleted + $1,
+		     updated_at = now()
+		 WHERE id = $2`, totalDeleted, policyID)
+	if err != nil {
+		return fmt.Errorf("updating policy stats: %w", err)
+	}
+
+	if totalDeleted > 0 {
+		m.logger.Info("retention policy executed",
+			slog.String("policy_id", policyID),
+			slog.Int64("messages_deleted", totalDeleted),
+		)
+	}
+
+	return nil
+}
+
+// purgeMessagesBefore batch-deletes the messages of a channel or guild
+// created before cutoff, optionally removes their S3 attachments, and
+// publishes bulk delete events. Messages under legal hold are kept. It
+// returns the number of messages deleted.
+func (m *Manager) purgeMessagesBefore(ctx context.Context, channelID, guildID *string, cutoff time.Time, deleteAttachments, deletePins bool) (int64, error) {
 	var totalDeleted int64
 
 	const batchSize = 1000
 
 	for {
 		if ctx.Err() != nil {
-			return ctx.Err()
+			return totalDeleted, ctx.Err()
 		}
 
 		// Find a batch of message IDs to delete.
@@ -93,14 +125,16 @@ func (m *Manager) executeRetentionPolicy(ctx context.Context, policyID string, c
 				query += ` AND m.id NOT IN (SELECT p.message_id FROM pins p)`
 			}
 		} else {
-			return nil // No scope — skip.
+			return 0, nil // No scope — skip.
 		}
 
+		// Messages under legal hold are never purged.
+		query += ` AND ` + apiutil.NotOnLegalHoldSQL
 		query += fmt.Sprintf(` LIMIT %d`, batchSize)
 
 		rows, err := m.pool.Query(ctx, query, args...)
 		if err != nil {
-			return fmt.Errorf("querying messages for deletion: %w", err)
+			return totalDeleted, fmt.Errorf("querying messages for deletion: %w", err)
 		}
 
 		var messageIDs []string
@@ -108,7 +142,7 @@ func (m *Manager) executeRetentionPolicy(ctx context.Context, policyID string, c
 			var id string
 			if err := rows.Scan(&id); err != nil {
 				rows.Close()
-				return fmt.Errorf("scanning message id: %w", err)
+				return totalDeleted, fmt.Errorf("scanning message id: %w", err)
 			}
 			messageIDs = append(messageIDs, id)
 		}
@@ -162,7 +196,7 @@ func (m *Manager) executeRetentionPolicy(ctx context.Context, policyID string, c
 		tag, err := m.pool.Exec(ctx,
 			`DELETE FROM messages WHERE id = ANY($1)`, messageIDs)
 		if err != nil {
-			return fmt.Errorf("deleting message batch: %w", err)
+			return totalDeleted, fmt.Errorf("deleting message batch: %w", err)
 		}
 		totalDeleted += tag.RowsAffected()
 
@@ -187,9 +221,7 @@ func (m *Manager) executeRetentionPolicy(ctx context.Context, policyID string, c
 
 		// Delete from search index.
 		if m.search != nil {
-			for _, id := range messageIDs {
-				m.search.DeleteMessage(ctx, id)
-			}
+			m.search.DeleteMessages(ctx, messageIDs)
 		}
 
 		// If we got fewer than batchSize, we're done.
@@ -198,24 +230,5 @@ func (m *Manager) executeRetentionPolicy(ctx context.Context, policyID string, c
 		}
 	}
 
-	// Update policy stats.
-	_, err := m.pool.Exec(ctx,
-		`UPDATE data_retention_policies
-		 SET last_run_at = now(),
-		     next_run_at = now() + INTERVAL '24 hours',
-		     messages_deleted = messages_deleted + $1,
-		     updated_at = now()
-		 WHERE id = $2`, totalDeleted, policyID)
-	if err != nil {
-		return fmt.Errorf("updating policy stats: %w", err)
-	}
-
-	if totalDeleted > 0 {
-		m.logger.Info("retention policy executed",
-			slog.String("policy_id", policyID),
-			slog.Int64("messages_deleted", totalDeleted),
-		)
-	}
-
-	return nil
+	return totalDeleted, nil
 }
@@ -0,0 +1,200 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// errPurgeTargetGone fails a scheduled purge whose category or role was
+// deleted before it ran.
+var errPurgeTargetGone = errors.New("target no longer exists")
+
+// runScheduledPurges runs the guild scheduled purges that are due, a few at
+// a time. Each run is recorded in the guild audit log, attributed to whoever
+// scheduled it (or the owner if that account is gone). A repeating purge is
+// moved to its next date instead of being marked completed.
+func (m *Manager) runScheduledPurges(ctx context.Context) error {
+	rows, err := m.pool.Query(ctx,
+		`SELECT p.id, p.guild_id, p.kind, p.target_id, p.run_at, p.repeat_days, COALESCE(p.created_by, g.owner_id)
+		 FROM guild_scheduled_purges p
+		 JOIN guilds g ON g.id = p.guild_id
+		 WHERE p.status = 'pending' AND p.run_at <= now()
+		 ORDER BY p.run_at
+		 LIMIT 20`)
+	if err != nil {
+		return err
+	}
+	type duePurge struct {
+		id, guildID, kind, targetID, actorID string
+		runAt                                time.Time
+		repeatDays                           *int
+	}
+	due, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (duePurge, error) {
+		var p duePurge
+		err := row.Scan(&p.id, &p.guildID, &p.kind, &p.targetID, &p.runAt, &p.repeatDays, &p.actorID)
+		return p, err
+	})
+	if err != nil {
+		return fmt.Errorf("loading due purges: %w", err)
+	}
+
+	for _, p := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		var affected int
+		var runErr error
+		switch p.kind {
+		case models.PurgeKindCategory:
+			affected, runErr = m.purgeCategory(ctx, p.guildID, p.targetID)
+		case models.PurgeKindRole:
+			affected, runErr = m.purgeRole(ctx, p.guildID, p.targetID)
+		}
+
+		status, nextRun := models.PurgeStatusCompleted, p.runAt
+		var errText *string
+		if runErr != nil {
+			status = models.PurgeStatusFailed
+			msg := runErr.Error()
+			errText = &msg
+			m.logger.Warn("scheduled purge failed",
+				slog.String("purge_id", p.id), slog.String("guild_id", p.guildID), slog.String("error", msg))
+		} else if p.repeatDays != nil {
+			status, nextRun = models.PurgeStatusPending, nextPurgeRun(p.runAt, *p.repeatDays, time.Now())
+		}
+		if _, err := m.pool.Exec(ctx,
+			`UPDATE guild_scheduled_purges
+			 SET status = $2, run_at = $3, affected = $4, error = $5, last_run_at = now()
+			 WHERE id = $1`,
+			p.id, status, nextRun, affected, errText); err != nil {
+			m.logger.Error("failed to record scheduled purge run",
+				slog.String("purge_id", p.id), slog.String("error", err.Error()))
+		}
+
+		reason := fmt.Sprintf("Scheduled purge: %d affected", affected)
+		if runErr != nil {
+			reason = "Scheduled purge failed: " + runErr.Error()
+		}
+		if _, err := m.pool.Exec(ctx,
+			`INSERT INTO audit_log (id, guild_id, actor_id, action, target_type, target_id, reason, created_at)
+			 VALUES ($1, $2, $3, 'scheduled_purge_run', $4, $5, $6, now())`,
+			models.NewULID().String(), p.guildID, p.actorID, p.kind, p.targetID, reason); err != nil {
+			m.logger.Warn("failed to audit scheduled purge",
+				slog.String("purge_id", p.id), slog.String("error", err.Error()))
+		}
+	}
+	if len(due) > 0 {
+		m.logger.Info("ran scheduled purges", slog.Int("purges", len(due)))
+	}
+	return nil
+}
+
+// nextPurgeRun returns the first run after now, stepping from runAt by
+// repeatDays, so a purge missed while the worker was down runs once rather
+// than once per missed date.
+func nextPurgeRun(runAt time.Time, repeatDays int, now time.Time) time.Time {
+	next := runAt.AddDate(0, 0, repeatDays)
+	for !next.After(now) {
+		next = next.AddDate(0, 0, repeatDays)
+	}
+	return next
+}
+
+// purgeCategory deletes a category with its channels and their threads,
+// returning how many channels were deleted.
+func (m *Manager) purgeCategory(ctx context.Context, guildID, categoryID string) (int, error) {
+	var channelIDs []string
+	err := pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error {
+		var id string
+		err := tx.QueryRow(ctx,
+			`SELECT id FROM guild_categories WHERE id = $1 AND guild_id = $2 FOR UPDATE`, categoryID, guildID,
+		).Scan(&id)
+		if err == pgx.ErrNoRows {
+			return errPurgeTargetGone
+		}
+		if err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx,
+			`SELECT id FROM channels WHERE guild_id = $1 AND (category_id = $2
+			     OR parent_channel_id IN (SELECT id FROM channels WHERE category_id = $2))`,
+			guildID, categoryID)
+		if err != nil {
+			return err
+		}
+		if channelIDs, err = pgx.CollectRows(rows, pgx.RowTo[string]); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE messages SET thread_id = NULL WHERE thread_id = ANY($1)`, channelIDs); err != nil {
+			return fmt.Errorf("clearing thread references: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE user_reports SET context_channel_id = NULL WHERE context_channel_id = ANY($1)`, channelIDs); err != nil {
+			return fmt.Errorf("clearing user_reports references: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM channels WHERE id = ANY($1)`, channelIDs); err != nil {
+			return fmt.Errorf("deleting channels: %w", err)
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM guild_categories WHERE id = $1`, categoryID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, channelID := range channelIDs {
+		data, _ := json.Marshal(map[string]string{"id": channelID, "guild_id": guildID})
+		m.bus.Publish(ctx, events.SubjectChannelDelete, events.Event{
+			Type:      "CHANNEL_DELETE",
+			GuildID:   guildID,
+			ChannelID: channelID,
+			Data:      data,
+		})
+	}
+	return len(channelIDs), nil
+}
+
+// purgeRole removes a role from every member who has it, returning how many
+// members lost it.
+func (m *Manager) purgeRole(ctx context.Context, guildID, roleID string) (int, error) {
+	var exists bool
+	if err := m.pool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM roles WHERE id = $1 AND guild_id = $2)`, roleID, guildID,
+	).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, errPurgeTargetGone
+	}
+
+	rows, err := m.pool.Query(ctx,
+		`DELETE FROM member_roles WHERE guild_id = $1 AND role_id = $2 RETURNING user_id`, guildID, roleID)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	for _, userID := range removed {
+		var roles []string
+		if r, err := m.pool.Query(ctx,
+			`SELECT role_id FROM member_roles WHERE guild_id = $1 AND user_id = $2`, guildID, userID); err == nil {
+			roles, _ = pgx.CollectRows(r, pgx.RowTo[string])
+		}
+		m.bus.PublishGuildEvent(ctx, events.SubjectGuildMemberUpdate, "GUILD_MEMBER_UPDATE", guildID, map[string]interface{}{
+			"guild_id": guildID, "user_id": userID, "role_id": roleID, "action": "role_remove",
+			"roles": roles,
+		})
+	}
+	return len(removed), nil
+}
@@ -0,0 +1,27 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// liftExpiredSuspensions clears the suspended flag on users whose timed
+// suspension has run out. Instance bans never set suspended_until and are
+// left alone.
+func (m *Manager) liftExpiredSuspensions(ctx context.Context) error {
+	tag, err := m.pool.Exec(ctx,
+		`UPDATE users SET flags = flags & $1, suspended_until = NULL, suspension_reason = NULL
+		 WHERE suspended_until IS NOT NULL AND suspended_until < NOW()
+		   AND NOT EXISTS (SELECT 1 FROM instance_bans ib WHERE ib.user_id = users.id)`,
+		^models.UserFlagSuspended)
+	if err != nil {
+		return err
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		m.logger.Info("lifted expired suspensions",
+			slog.Int64("lifted", n))
+	}
+	return nil
+}
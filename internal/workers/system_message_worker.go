@@ -0,0 +1,125 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// systemReminderLead is how long before an event starts its reminder is
+// posted to the guild's system channel.
+const systemReminderLead = 15 * time.Minute
+
+// systemReminderLogType marks system channel reminders in event_reminder_log,
+// alongside the push reminder types.
+const systemReminderLogType = "system_channel"
+
+// startSystemMessageWorker posts the messages a guild's system channel
+// receives: a join message for each new member and a reminder shortly before
+// each scheduled event. Guilds without a system channel, or with a kind
+// turned off, get nothing.
+func (m *Manager) startSystemMessageWorker(ctx context.Context) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		_, err := m.bus.Subscribe(events.SubjectGuildMemberAdd, func(event events.Event) {
+			m.postJoinMessage(ctx, event)
+		})
+		if err != nil {
+			m.logger.Error("failed to subscribe for system join messages",
+				slog.String("error", err.Error()))
+		}
+		<-ctx.Done()
+	}()
+
+	m.startPeriodic(ctx, "system-event-reminders", time.Minute, m.postEventReminders)
+}
+
+// postJoinMessage posts a join message for a GUILD_MEMBER_ADD. Joins relayed
+// from a federation peer are posted by the guild's home instance.
+func (m *Manager) postJoinMessage(ctx context.Context, event events.Event) {
+	if event.OriginInstanceID != "" {
+		return
+	}
+	var data struct {
+		GuildID string `json:"guild_id"`
+		UserID  string `json:"user_id"`
+	}
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.GuildID == "" || data.UserID == "" {
+		return
+	}
+	if _, err := apiutil.PostSystemMessage(ctx, m.pool, m.bus, data.GuildID,
+		models.MessageTypeSystemJoin, data.UserID, nil); err != nil {
+		m.logger.Warn("failed to post join message",
+			slog.String("guild_id", data.GuildID), slog.String("error", err.Error()))
+	}
+}
+
+// postEventReminders posts a reminder for each scheduled event starting
+// within systemReminderLead, attributed to the event's creator.
+func (m *Manager) postEventReminders(ctx context.Context) error {
+	now := time.Now()
+	rows, err := m.pool.Query(ctx,
+		`SELECT e.id, e.guild_id, e.creator_id, e.name, e.scheduled_start
+		 FROM guild_events e
+		 JOIN guilds g ON g.id = e.guild_id
+		 WHERE e.status = 'scheduled'
+		   AND e.scheduled_start > $1 AND e.scheduled_start <= $2
+		   AND g.system_channel_id IS NOT NULL AND g.system_event_reminders
+		   AND NOT EXISTS (
+		       SELECT 1 FROM event_reminder_log r
+		       WHERE r.event_id = e.id AND r.reminder_type = $3)
+		 ORDER BY e.scheduled_start
+		 LIMIT 50`,
+		now, now.Add(systemReminderLead), systemReminderLogType)
+	if err != nil {
+		return fmt.Errorf("querying events for system reminders: %w", err)
+	}
+	type upcoming struct {
+		ID, GuildID, CreatorID, Name string
+		Start                        time.Time
+	}
+	var due []upcoming
+	for rows.Next() {
+		var e upcoming
+		if err := rows.Scan(&e.ID, &e.GuildID, &e.CreatorID, &e.Name, &e.Start); err != nil {
+			rows.Close()
+			return fmt.Errorf("scanning event for system reminder: %w", err)
+		}
+		due = append(due, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range due {
+		// Claim the reminder first so a slow post is never repeated.
+		tag, err := m.pool.Exec(ctx,
+			`INSERT INTO event_reminder_log (event_id, reminder_type, sent_at)
+			 VALUES ($1, $2, now())
+			 ON CONFLICT (event_id, reminder_type) DO NOTHING`,
+			e.ID, systemReminderLogType)
+		if err != nil {
+			return fmt.Errorf("recording system reminder for event %s: %w", e.ID, err)
+		}
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+
+		content := fmt.Sprintf("%q starts in %d minutes", e.Name, int(time.Until(e.Start).Round(time.Minute).Minutes()))
+		if _, err := apiutil.PostSystemMessage(ctx, m.pool, m.bus, e.GuildID,
+			models.MessageTypeSystemEventReminder, e.CreatorID, &content); err != nil {
+			m.logger.Warn("failed to post event reminder",
+				slog.String("event_id", e.ID), slog.String("error", err.Error()))
+		}
+	}
+	return nil
+}
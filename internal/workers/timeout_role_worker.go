@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/amityvox/amityvox/internal/api/apiutil"
+	"github.com/amityvox/amityvox/internal/events"
+)
+
+// syncTimeoutRoles reconciles every guild that backs timeouts with a role:
+// channels created without the role's override get one, members timed out by
+// automod or a federated peer gain the role, and members whose timeout ran
+// out lose it.
+func (m *Manager) syncTimeoutRoles(ctx context.Context) error {
+	rows, err := m.pool.Query(ctx,
+		`SELECT id, timeout_role_id FROM guilds WHERE timeout_role_id IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	type guildRole struct{ guildID, roleID string }
+	var guilds []guildRole
+	for rows.Next() {
+		var g guildRole
+		if err := rows.Scan(&g.guildID, &g.roleID); err != nil {
+			rows.Close()
+			return err
+		}
+		guilds = append(guilds, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating timeout role guilds: %w", err)
+	}
+
+	var errs []error
+	for _, g := range guilds {
+		channelIDs, err := apiutil.ApplyTimeoutOverrides(ctx, m.pool, g.guildID, g.roleID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("guild %s: %w", g.guildID, err))
+			continue
+		}
+		for _, channelID := range channelIDs {
+			m.bus.PublishChannelEvent(ctx, events.SubjectChannelUpdate, "CHANNEL_UPDATE", channelID, map[string]string{
+				"channel_id": channelID,
+			})
+		}
+		if len(channelIDs) > 0 {
+			m.logger.Info("applied timeout role overrides",
+				slog.String("guild_id", g.guildID), slog.Int("channels", len(channelIDs)))
+		}
+		if err := apiutil.SyncTimeoutRole(ctx, m.pool, m.bus, g.guildID, g.roleID, ""); err != nil {
+			errs = append(errs, fmt.Errorf("guild %s: %w", g.guildID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/translate"
+)
+
+// startTranslationWorker subscribes to message create and edit events and
+// translates messages in auto-translated channels, storing the result and
+// announcing it with a MESSAGE_TRANSLATION event.
+func (m *Manager) startTranslationWorker(ctx context.Context, cfg translate.Config) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		handler := func(event events.Event) {
+			m.processTranslation(ctx, cfg, event)
+		}
+		for _, subject := range []string{events.SubjectMessageCreate, events.SubjectMessageUpdate} {
+			if _, err := m.bus.QueueSubscribe(subject, "translation-workers", handler); err != nil {
+				m.logger.Error("failed to subscribe for auto-translation",
+					slog.String("subject", subject), slog.String("error", err.Error()))
+				return
+			}
+		}
+
+		m.logger.Info("auto-translation worker started")
+		<-ctx.Done()
+	}()
+}
+
+// processTranslation translates one message if its channel has
+// auto-translation on.
+func (m *Manager) processTranslation(ctx context.Context, cfg translate.Config, event events.Event) {
+	var msg struct {
+		ID        string  `json:"id"`
+		ChannelID string  `json:"channel_id"`
+		Content   *string `json:"content"`
+		Encrypted bool    `json:"encrypted"`
+	}
+	if err := json.Unmarshal(event.Data, &msg); err != nil {
+		return
+	}
+	if msg.ID == "" || msg.Encrypted || msg.Content == nil || *msg.Content == "" {
+		return
+	}
+
+	// The channel's own encryption flag is checked too, in case it was
+	// switched on after the setting was made.
+	var guildID, lang, channelLang string
+	err := m.pool.QueryRow(ctx,
+		`SELECT c.guild_id, COALESCE(a.target_lang, g.preferred_locale, 'en'), COALESCE(c.language, '')
+		 FROM channel_auto_translate a
+		 JOIN channels c ON c.id = a.channel_id
+		 JOIN guilds g ON g.id = c.guild_id
+		 WHERE a.channel_id = $1 AND NOT c.encrypted`, msg.ChannelID).Scan(&guildID, &lang, &channelLang)
+	if err != nil {
+		return
+	}
+
+	t, err := translate.TranslateFrom(ctx, cfg, *msg.Content, translate.SourceFor(channelLang, lang), lang)
+	if err != nil {
+		m.logger.Warn("auto-translation failed",
+			slog.String("message_id", msg.ID), slog.String("error", err.Error()))
+		return
+	}
+	// Text already in the target language is left alone.
+	if t.SourceLang == lang {
+		return
+	}
+	if err := translate.Store(ctx, m.pool, msg.ID, t); err != nil {
+		m.logger.Error("failed to store auto-translation",
+			slog.String("message_id", msg.ID), slog.String("error", err.Error()))
+		return
+	}
+
+	m.bus.PublishChannelEvent(ctx, events.SubjectMessageTranslation, "MESSAGE_TRANSLATION", msg.ChannelID, map[string]string{
+		"message_id":  msg.ID,
+		"channel_id":  msg.ChannelID,
+		"guild_id":    guildID,
+		"source_lang": t.SourceLang,
+		"target_lang": t.TargetLang,
+		"text":        t.Text,
+	})
+}
@@ -0,0 +1,120 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/models"
+)
+
+// startVoiceChatWorker subscribes to voice state updates and keeps the
+// participant log that decides who can see a voice channel's chat. When a
+// room whose chat is kept for the session only empties, its chat is cleared.
+func (m *Manager) startVoiceChatWorker(ctx context.Context) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		_, err := m.bus.QueueSubscribe(events.SubjectVoiceStateUpdate, "voice-chat-workers", func(event events.Event) {
+			m.processVoiceChatState(ctx, event)
+		})
+		if err != nil {
+			m.logger.Error("failed to subscribe for voice chat participants", slog.String("error", err.Error()))
+			return
+		}
+
+		m.logger.Info("voice chat worker started")
+		<-ctx.Done()
+	}()
+}
+
+// processVoiceChatState records a join or leave of a voice or stage channel.
+// Joining a room leaves any other room the user was still logged in.
+func (m *Manager) processVoiceChatState(ctx context.Context, event events.Event) {
+	var state struct {
+		UserID    string `json:"user_id"`
+		ChannelID string `json:"channel_id"`
+		Action    string `json:"action"`
+	}
+	if err := json.Unmarshal(event.Data, &state); err != nil || state.UserID == "" || state.ChannelID == "" {
+		return
+	}
+
+	var left []string
+	switch state.Action {
+	case "join":
+		_, err := m.pool.Exec(ctx,
+			`INSERT INTO voice_chat_participants (channel_id, user_id, joined_at)
+			 SELECT id, $2, now() FROM channels WHERE id = $1 AND channel_type IN ('voice', 'stage')
+			 ON CONFLICT (channel_id, user_id) DO UPDATE SET joined_at = now(), left_at = NULL`,
+			state.ChannelID, state.UserID)
+		if err != nil {
+			m.logger.Error("failed to record voice chat participant",
+				slog.String("channel_id", state.ChannelID), slog.String("error", err.Error()))
+			return
+		}
+		left = m.leaveVoiceChats(ctx,
+			`UPDATE voice_chat_participants SET left_at = now()
+			 WHERE user_id = $1 AND channel_id <> $2 AND left_at IS NULL
+			 RETURNING channel_id`, state.UserID, state.ChannelID)
+	case "leave":
+		left = m.leaveVoiceChats(ctx,
+			`UPDATE voice_chat_participants SET left_at = now()
+			 WHERE user_id = $1 AND channel_id = $2 AND left_at IS NULL
+			 RETURNING channel_id`, state.UserID, state.ChannelID)
+	default:
+		return
+	}
+
+	for _, channelID := range left {
+		m.clearEmptyVoiceChat(ctx, channelID)
+	}
+}
+
+// leaveVoiceChats runs an update marking participants as left and returns the
+// channels it touched.
+func (m *Manager) leaveVoiceChats(ctx context.Context, query string, args ...interface{}) []string {
+	rows, err := m.pool.Query(ctx, query, args...)
+	if err != nil {
+		m.logger.Error("failed to record voice chat leave", slog.String("error", err.Error()))
+		return nil
+	}
+	defer rows.Close()
+	var channelIDs []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			channelIDs = append(channelIDs, id)
+		}
+	}
+	return channelIDs
+}
+
+// clearEmptyVoiceChat deletes a session-only voice chat once nobody is left
+// in the room. Pinned messages are kept.
+func (m *Manager) clearEmptyVoiceChat(ctx context.Context, channelID string) {
+	var clear bool
+	m.pool.QueryRow(ctx,
+		`SELECT c.voice_chat_history = $2
+		    AND NOT EXISTS(SELECT 1 FROM voice_chat_participants p
+		                   WHERE p.channel_id = c.id AND p.left_at IS NULL)
+		 FROM channels c WHERE c.id = $1`,
+		channelID, models.VoiceChatHistorySession).Scan(&clear)
+	if !clear {
+		return
+	}
+
+	deleted, err := m.purgeMessagesBefore(ctx, &channelID, nil, time.Now().UTC(), true, false)
+	if err != nil {
+		m.logger.Error("failed to clear voice chat",
+			slog.String("channel_id", channelID), slog.String("error", err.Error()))
+		return
+	}
+	if deleted > 0 {
+		m.logger.Info("cleared voice chat",
+			slog.String("channel_id", channelID), slog.Int64("messages_deleted", deleted))
+	}
+}
@@ -8,16 +8,23 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"os"
 	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/amityvox/amityvox/internal/api/apiutil"
 	"github.com/amityvox/amityvox/internal/automod"
 	"github.com/amityvox/amityvox/internal/events"
+	"github.com/amityvox/amityvox/internal/importer"
+	"github.com/amityvox/amityvox/internal/linksafety"
 	"github.com/amityvox/amityvox/internal/media"
 	"github.com/amityvox/amityvox/internal/notifications"
+	"github.com/amityvox/amityvox/internal/opsalerts"
 	"github.com/amityvox/amityvox/internal/search"
+	"github.com/amityvox/amityvox/internal/translate"
 )
 
 // Manager coordinates background workers and periodic jobs.
@@ -28,10 +35,22 @@ type Manager struct {
 	media              *media.Service
 	automod            *automod.Service
 	notifications      *notifications.Service
+	importer           *importer.Service
+	linkSafety         *linksafety.Checker
+	opsAlerts          *opsalerts.Dispatcher
 	backfillWindowDays int
+	instanceID         string
 	logger             *slog.Logger
+	node               string // host name recorded against job runs
 	cancel             context.CancelFunc
 	wg                 sync.WaitGroup
+
+	jobsMu sync.RWMutex
+	jobs   map[string]*jobState
+	runCtx context.Context // Start's context; manual runs stop with it
+
+	jetStream jetStreamState // touched only by the jetstream-monitor job
+	opsAlert  opsAlertState  // touched only by the ops-alerts job
 }
 
 // Config holds the configuration for the worker manager.
@@ -42,7 +61,10 @@ type Config struct {
 	Media              *media.Service         // nil if media/S3 is disabled
 	AutoMod            *automod.Service       // nil if automod is disabled
 	Notifications      *notifications.Service // nil if push is disabled
+	Importer           *importer.Service      // nil if guild imports are disabled
+	LinkSafety         *linksafety.Checker    // nil if link safety is disabled
 	BackfillWindowDays int                    // federation event retention (default 7)
+	InstanceID         string                 // local instance; its guilds' member counts are reconciled
 	Logger             *slog.Logger
 }
 
@@ -52,6 +74,7 @@ func New(cfg Config) *Manager {
 	if bwd < 1 {
 		bwd = 7
 	}
+	node, _ := os.Hostname()
 	return &Manager{
 		pool:               cfg.Pool,
 		bus:                cfg.Bus,
@@ -59,18 +82,42 @@ func New(cfg Config) *Manager {
 		media:              cfg.Media,
 		automod:            cfg.AutoMod,
 		notifications:      cfg.Notifications,
+		importer:           cfg.Importer,
+		linkSafety:         cfg.LinkSafety,
+		opsAlerts:          &opsalerts.Dispatcher{Pool: cfg.Pool, InstanceID: cfg.InstanceID, Logger: cfg.Logger},
 		backfillWindowDays: bwd,
+		instanceID:         cfg.InstanceID,
+		node:               node,
 		logger:             cfg.Logger,
+		jobs:               make(map[string]*jobState),
 	}
 }
 
 // Start launches all background workers. Call Stop() to shut them down.
 func (m *Manager) Start(ctx context.Context) {
 	ctx, m.cancel = context.WithCancel(ctx)
+	m.jobsMu.Lock()
+	m.runCtx = ctx
+	m.jobsMu.Unlock()
+
+	m.failInterruptedRuns(ctx)
+	m.subscribeJobTriggers()
+	m.startJob(ctx, Job{
+		Name:        "job-history-cleanup",
+		Description: "Prune old job run history",
+		Schedule:    "15 3 * * *",
+		Run:         m.cleanJobHistory,
+	})
 
 	// Start periodic cleanup workers.
 	m.startPeriodic(ctx, "session-cleanup", 1*time.Hour, m.cleanExpiredSessions)
-	m.startPeriodic(ctx, "invite-cleanup", 6*time.Hour, m.cleanExpiredInvites)
+	m.startJob(ctx, Job{
+		Name:        "link-cleanup",
+		Description: "Delete expired invites, dead channel follows and unattached uploads",
+		Schedule:    "0 */6 * * *",
+		Run:         m.cleanDeadLinks,
+	})
+	m.startPeriodic(ctx, "member-count-reconcile", 6*time.Hour, m.reconcileMemberCounts)
 
 	// Start search workers if search is enabled.
 	if m.search != nil {
@@ -84,37 +131,176 @@ func (m *Manager) Start(ctx context.Context) {
 
 		// Periodic catch-up sync (every 15 min, last 20 min window) as a safety net
 		// for any messages missed by the event worker (e.g. during Meilisearch downtime).
-		m.startPeriodic(ctx, "search-sync", 15*time.Minute, m.syncSearchIndex)
+		m.startJob(ctx, Job{
+			Name:        "search-sync",
+			Description: "Re-index recent messages missed by the event worker",
+			Schedule:    "@every 15m",
+			Retry:       RetryPolicy{Attempts: 3, Backoff: 30 * time.Second},
+			Run:         m.syncSearchIndex,
+		})
+		// Periodic audit that compares index and database counts and prunes
+		// documents whose deletes never reached the index.
+		m.startJob(ctx, Job{
+			Name:        "search-audit",
+			Description: "Compare index and database counts and prune orphaned documents",
+			Schedule:    "0 */6 * * *",
+			Retry:       RetryPolicy{Attempts: 3, Backoff: time.Minute},
+			Run:         m.auditSearchIndex,
+		})
 		m.startEventWorker(ctx)
 	}
 
 	// Start media workers (transcode + embed unfurling).
 	m.startTranscodeWorker(ctx)
 	m.startEmbedWorker(ctx)
+	if m.media != nil {
+		m.startJob(ctx, Job{
+			Name:        "image-variants",
+			Description: "Render resized AVIF, WebP and JPEG variants and thumbhashes of uploaded images",
+			Schedule:    "@every 30s",
+			Run:         m.generateImageVariants,
+		})
+	}
+	if m.media != nil && m.media.CachesEmbedImages() {
+		m.startJob(ctx, Job{
+			Name:        "embed-image-cleanup",
+			Description: "Delete cached link preview images past their expiry",
+			Schedule:    "@every 1h",
+			Run:         m.cleanExpiredEmbedImages,
+		})
+	}
 
 	// Start automod worker (message content evaluation).
 	if m.automod != nil {
 		m.startAutomodWorker(ctx)
 	}
 
+	// Start link safety worker (malicious link rewriting).
+	if m.linkSafety != nil {
+		m.startLinkSafetyWorker(ctx)
+	}
+
+	// Start pinboard worker (reaction thresholds).
+	m.startPinboardWorker(ctx)
+
+	// Start system channel worker (join messages, event reminders).
+	m.startSystemMessageWorker(ctx)
+
+	// Start voice chat worker (participant log, session chat clearing).
+	m.startVoiceChatWorker(ctx)
+
+	// Start auto-translation worker if translation is enabled.
+	if cfg, ok := translate.FromEnv(); ok {
+		m.startTranslationWorker(ctx, cfg)
+	}
+
 	// Start push notification worker if enabled.
 	if m.notifications != nil && m.notifications.Enabled() {
 		m.startNotificationWorker(ctx)
 		m.startEventReminderWorker(ctx)
 		m.startBookmarkReminderWorker(ctx)
-		m.startPeriodic(ctx, "push-sub-cleanup", 24*time.Hour, m.cleanStalePushSubscriptions)
-		m.startPeriodic(ctx, "notification-cleanup", 24*time.Hour, m.cleanOldNotifications)
+		m.startJob(ctx, Job{
+			Name:        "push-sub-cleanup",
+			Description: "Remove push subscriptions unused for 90 days",
+			Schedule:    "30 4 * * *",
+			Run:         m.cleanStalePushSubscriptions,
+		})
+		m.startJob(ctx, Job{
+			Name:        "reaction-digests",
+			Description: "Send digests of reactions held back on popular messages",
+			Schedule:    "@every 1m",
+			Run:         m.sendReactionDigests,
+		})
+		m.startJob(ctx, Job{
+			Name:        "notification-cleanup",
+			Description: "Delete old notifications",
+			Schedule:    "45 4 * * *",
+			Run:         m.cleanOldNotifications,
+		})
 	}
 
 	// Periodic data retention cleanup (every 15 minutes).
 	m.startPeriodic(ctx, "retention-cleanup", 15*time.Minute, m.runRetentionPolicies)
 
-	// Federation events retention — prune events older than backfill window.
+	// Federation events, guild replica log and sandbox retention — prune
+	// entries older than the backfill window.
 	m.startPeriodic(ctx, "federation-events-cleanup", 1*time.Hour, m.cleanFederationEvents)
 
+	// Purge soft-deleted messages once their guild's restore window passes.
+	m.startPeriodic(ctx, "deleted-message-purge", 15*time.Minute, m.purgeDeletedMessages)
+
+	// Instance-wide DM and deleted-account retention; legal holds are kept.
+	m.startJob(ctx, Job{
+		Name:        "instance-retention",
+		Description: "Purge DMs and deleted-account data past the instance retention windows",
+		Schedule:    "0 5 * * *",
+		Run:         m.runInstanceRetention,
+	})
+
+	m.startJob(ctx, Job{
+		Name:        "api-usage-cleanup",
+		Description: "Delete API usage rollups older than 90 days",
+		Schedule:    "15 4 * * *",
+		Run:         m.cleanOldAPIUsage,
+	})
+
+	// Message volume per source, for the guild integration dashboards.
+	m.startPeriodic(ctx, "message-source-rollup", 15*time.Minute, m.rollupMessageSources)
+	m.startJob(ctx, Job{
+		Name:        "message-source-cleanup",
+		Description: "Delete message source rollups older than 90 days",
+		Schedule:    "20 4 * * *",
+		Run:         m.cleanOldMessageSources,
+	})
+
+	// Reactions per emoji and week, for guild insights and the emoji picker.
+	m.startPeriodic(ctx, "emoji-reaction-rollup", 15*time.Minute, m.rollupEmojiReactions)
+	m.startJob(ctx, Job{
+		Name:        "emoji-reaction-cleanup",
+		Description: "Delete emoji reaction rollups older than a year",
+		Schedule:    "25 4 * * *",
+		Run:         m.cleanOldEmojiReactions,
+	})
+
+	// Guild-scheduled category deletions and role removals.
+	m.startPeriodic(ctx, "scheduled-purges", 1*time.Minute, m.runScheduledPurges)
+
+	// Guild broadcast DMs, throttled to the instance's delivery rate.
+	m.startPeriodic(ctx, "guild-broadcasts", 1*time.Minute, m.deliverGuildBroadcasts)
+
+	// Burn-after-reading DMs.
+	m.startBurnWorker(ctx)
+
 	// Periodic ban expiry cleanup.
 	m.startPeriodic(ctx, "ban-expiry", 1*time.Minute, m.cleanExpiredBans)
 
+	// Keep guild timeout roles in step with member timeouts and channels.
+	m.startPeriodic(ctx, "timeout-role-sync", 1*time.Minute, m.syncTimeoutRoles)
+
+	// Periodic guild invite pause expiry.
+	m.startPeriodic(ctx, "invite-pause-expiry", 1*time.Minute, m.liftExpiredInvitePauses)
+
+	m.startJob(ctx, Job{
+		Name:        "maintenance-announce",
+		Description: "Announce the start and end of scheduled maintenance",
+		Schedule:    "@every 1m",
+		Run:         m.announceMaintenance,
+	})
+
+	// Periodic timed suspension expiry.
+	m.startPeriodic(ctx, "suspension-expiry", 1*time.Minute, m.liftExpiredSuspensions)
+
+	// JetStream consumer lag monitoring and stream/consumer recovery.
+	m.startPeriodic(ctx, "jetstream-monitor", 1*time.Minute, m.monitorJetStream)
+
+	// Federation, storage, registration and AutoMod checks for operator alerts.
+	m.startPeriodic(ctx, "ops-alerts", 1*time.Minute, m.checkOperations)
+
+	// Guild imports from other platforms.
+	if m.importer != nil {
+		m.startPeriodic(ctx, "guild-imports", 15*time.Second, m.runGuildImports)
+	}
+
 	// Periodic MLS key package cleanup.
 	m.startPeriodic(ctx, "mls-key-cleanup", 6*time.Hour, m.cleanExpiredKeyPackages)
 
@@ -130,38 +316,8 @@ func (m *Manager) Stop() {
 	m.logger.Info("background workers stopped")
 }
 
-// startPeriodic launches a goroutine that runs fn at the given interval.
-func (m *Manager) startPeriodic(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
-	m.wg.Add(1)
-	go func() {
-		defer m.wg.Done()
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		if err := fn(ctx); err != nil {
-			m.logger.Error("worker error",
-				slog.String("worker", name),
-				slog.String("error", err.Error()),
-			)
-		}
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if err := fn(ctx); err != nil {
-					m.logger.Error("worker error",
-						slog.String("worker", name),
-						slog.String("error", err.Error()),
-					)
-				}
-			}
-		}
-	}()
-}
-
-// startEventWorker subscribes to NATS message events and indexes them in Meilisearch.
+// startEventWorker subscribes to NATS message, channel and guild events and
+// keeps the Meilisearch indexes in step with them.
 func (m *Manager) startEventWorker(ctx context.Context) {
 	m.wg.Add(1)
 	go func() {
@@ -175,6 +331,8 @@ func (m *Manager) startEventWorker(ctx context.Context) {
 				m.handleMessageUpdate(ctx, event)
 			case "MESSAGE_DELETE":
 				m.handleMessageDelete(ctx, event)
+			case "MESSAGE_DELETE_BULK":
+				m.handleMessageDeleteBulk(ctx, event)
 			}
 		})
 		if err != nil {
@@ -182,6 +340,18 @@ func (m *Manager) startEventWorker(ctx context.Context) {
 				slog.String("error", err.Error()))
 			return
 		}
+		if _, err := m.bus.Subscribe(events.SubjectChannelDelete, func(event events.Event) {
+			m.handleChannelDelete(ctx, event)
+		}); err != nil {
+			m.logger.Error("failed to subscribe to channel deletes for search indexing",
+				slog.String("error", err.Error()))
+		}
+		if _, err := m.bus.Subscribe(events.SubjectGuildDelete, func(event events.Event) {
+			m.handleGuildDelete(ctx, event)
+		}); err != nil {
+			m.logger.Error("failed to subscribe to guild deletes for search indexing",
+				slog.String("error", err.Error()))
+		}
 
 		<-ctx.Done()
 	}()
@@ -197,6 +367,10 @@ func (m *Manager) cleanOldNotifications(ctx context.Context) error {
 	return m.notifications.CleanupOldNotifications(ctx)
 }
 
+func (m *Manager) sendReactionDigests(ctx context.Context) error {
+	return m.notifications.FlushReactionDigests(ctx, m.bus)
+}
+
 func (m *Manager) cleanExpiredSessions(ctx context.Context) error {
 	tag, err := m.pool.Exec(ctx,
 		`DELETE FROM user_sessions WHERE expires_at < NOW()`)
@@ -207,17 +381,14 @@ func (m *Manager) cleanExpiredSessions(ctx context.Context) error {
 		m.logger.Info("cleaned expired sessions",
 			slog.Int64("deleted", tag.RowsAffected()))
 	}
-	return nil
-}
 
-func (m *Manager) cleanExpiredInvites(ctx context.Context) error {
-	tag, err := m.pool.Exec(ctx,
-		`DELETE FROM invites WHERE expires_at IS NOT NULL AND expires_at < NOW()`)
+	tag, err = m.pool.Exec(ctx,
+		`DELETE FROM admin_action_tokens WHERE expires_at < NOW()`)
 	if err != nil {
 		return err
 	}
 	if tag.RowsAffected() > 0 {
-		m.logger.Info("cleaned expired invites",
+		m.logger.Info("cleaned expired admin action tokens",
 			slog.Int64("deleted", tag.RowsAffected()))
 	}
 	return nil
@@ -236,6 +407,75 @@ func (m *Manager) cleanFederationEvents(ctx context.Context) error {
 			slog.Int64("deleted", tag.RowsAffected()),
 			slog.Int("retention_days", m.backfillWindowDays))
 	}
+
+	// Guild replica log entries are kept for the same window; peers further
+	// behind get a snapshot instead.
+	tag, err = m.pool.Exec(ctx,
+		`DELETE FROM guild_replica_log WHERE created_at < NOW() - make_interval(days => $1)`,
+		m.backfillWindowDays)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned old guild replica log entries",
+			slog.Int64("deleted", tag.RowsAffected()),
+			slog.Int("retention_days", m.backfillWindowDays))
+	}
+
+	tag, err = m.pool.Exec(ctx,
+		`DELETE FROM federation_sandbox_events WHERE created_at < NOW() - make_interval(days => $1)`,
+		m.backfillWindowDays)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned old federation sandbox events",
+			slog.Int64("deleted", tag.RowsAffected()),
+			slog.Int("retention_days", m.backfillWindowDays))
+	}
+
+	tag, err = m.pool.Exec(ctx,
+		`DELETE FROM federation_rejections WHERE created_at < NOW() - make_interval(days => $1)`,
+		m.backfillWindowDays)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned old federation rejections",
+			slog.Int64("deleted", tag.RowsAffected()),
+			slog.Int("retention_days", m.backfillWindowDays))
+	}
+	return nil
+}
+
+func (m *Manager) purgeDeletedMessages(ctx context.Context) error {
+	tag, err := m.pool.Exec(ctx,
+		`DELETE FROM messages m
+		 USING channels c, guilds g
+		 WHERE m.channel_id = c.id AND g.id = c.guild_id
+		   AND m.deleted_at IS NOT NULL
+		   AND m.deleted_at < now() - make_interval(days => g.message_restore_days)
+		   AND `+apiutil.NotOnLegalHoldSQL)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("purged soft-deleted messages",
+			slog.Int64("deleted", tag.RowsAffected()))
+	}
+	return nil
+}
+
+func (m *Manager) cleanOldAPIUsage(ctx context.Context) error {
+	tag, err := m.pool.Exec(ctx,
+		`DELETE FROM api_usage_daily WHERE day < CURRENT_DATE - 90`)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		m.logger.Info("cleaned old API usage",
+			slog.Int64("deleted", tag.RowsAffected()))
+	}
 	return nil
 }
 
@@ -251,6 +491,27 @@ func (m *Manager) syncSearchIndex(ctx context.Context) error {
 	if count > 0 {
 		m.logger.Debug("incremental search sync", slog.Int("indexed", count))
 	}
+	count, err = m.search.SyncAttachments(ctx, since)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		m.logger.Debug("incremental attachment search sync", slog.Int("indexed", count))
+	}
+	return nil
+}
+
+func (m *Manager) auditSearchIndex(ctx context.Context) error {
+	res, err := m.search.AuditMessages(ctx)
+	if err != nil {
+		return err
+	}
+	if res.Indexed != res.Stored || res.Pruned > 0 {
+		m.logger.Warn("search index drift",
+			slog.Int64("indexed", res.Indexed),
+			slog.Int64("stored", res.Stored),
+			slog.Int("pruned", res.Pruned))
+	}
 	return nil
 }
 
@@ -275,8 +536,15 @@ func (m *Manager) handleMessageCreate(ctx context.Context, event events.Event) {
 	guildID, _ := data["guild_id"].(string)
 	authorID, _ := data["author_id"].(string)
 	content, _ := data["content"].(string)
+	attachments, _ := data["attachments"].([]interface{})
 
-	if id == "" || content == "" {
+	if id == "" {
+		return
+	}
+	if len(attachments) > 0 {
+		m.indexMessageAttachments(ctx, id)
+	}
+	if content == "" {
 		return
 	}
 
@@ -286,8 +554,13 @@ func (m *Manager) handleMessageCreate(ctx context.Context, event events.Event) {
 		GuildID:   guildID,
 		AuthorID:  authorID,
 		Content:   content,
+		HasFile:   len(attachments) > 0,
 		CreatedAt: time.Now().Unix(),
 	}
+	if guildID != "" {
+		m.pool.QueryRow(ctx,
+			`SELECT COALESCE(language, '') FROM channels WHERE id = $1`, channelID).Scan(&doc.Language)
+	}
 
 	m.search.EnqueueMessage(doc)
 }
@@ -310,12 +583,18 @@ func (m *Manager) handleMessageUpdate(ctx context.Context, event events.Event) {
 	var msgContent *string
 	var createdAt time.Time
 	err := m.pool.QueryRow(ctx,
-		`SELECT m.id, m.channel_id, c.guild_id, m.author_id, m.content, m.created_at
+		`SELECT m.id, m.channel_id, c.guild_id, m.author_id, m.content, COALESCE(c.language, ''), m.created_at,
+		        EXISTS (SELECT 1 FROM attachments a WHERE a.message_id = m.id)
 		 FROM messages m
 		 LEFT JOIN channels c ON c.id = m.channel_id
 		 WHERE m.id = $1`, id).Scan(
-		&doc.ID, &doc.ChannelID, &guildID, &doc.AuthorID, &msgContent, &createdAt,
+		&doc.ID, &doc.ChannelID, &guildID, &doc.AuthorID, &msgContent, &doc.Language, &createdAt, &doc.HasFile,
 	)
+	if err == pgx.ErrNoRows {
+		// Deleted before the update was processed.
+		m.deleteFromIndex(ctx, []string{id})
+		return
+	}
 	if err != nil {
 		if content != "" {
 			doc.ID = id
@@ -329,9 +608,15 @@ func (m *Manager) handleMessageUpdate(ctx context.Context, event events.Event) {
 	if guildID != nil {
 		doc.GuildID = *guildID
 	}
-	if msgContent != nil {
-		doc.Content = *msgContent
+	if doc.HasFile {
+		m.indexMessageAttachments(ctx, id)
 	}
+	if msgContent == nil || *msgContent == "" {
+		// Edited down to attachments only; nothing left to match.
+		m.deleteFromIndex(ctx, []string{id})
+		return
+	}
+	doc.Content = *msgContent
 	doc.CreatedAt = createdAt.Unix()
 	m.search.EnqueueMessage(doc)
 }
@@ -346,11 +631,55 @@ func (m *Manager) handleMessageDelete(ctx context.Context, event events.Event) {
 	if id == "" {
 		return
 	}
+	m.deleteFromIndex(ctx, []string{id})
+}
+
+// handleMessageDeleteBulk removes bulk-deleted messages from the index. The
+// purge endpoint lists them under "message_ids" and retention under "ids".
+func (m *Manager) handleMessageDeleteBulk(ctx context.Context, event events.Event) {
+	var data struct {
+		MessageIDs []string `json:"message_ids"`
+		IDs        []string `json:"ids"`
+	}
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		return
+	}
+	m.deleteFromIndex(ctx, append(data.MessageIDs, data.IDs...))
+}
 
-	if err := m.search.DeleteMessage(ctx, id); err != nil {
-		m.logger.Error("failed to delete message from index",
+func (m *Manager) handleChannelDelete(ctx context.Context, event events.Event) {
+	data := eventData(event)
+	id, _ := data["id"].(string)
+	if id == "" {
+		return
+	}
+	if err := m.search.DeleteChannel(ctx, id); err != nil {
+		m.logger.Error("failed to delete channel from index",
 			slog.String("id", id),
 			slog.String("error", err.Error()),
 		)
 	}
 }
+
+func (m *Manager) handleGuildDelete(ctx context.Context, event events.Event) {
+	data := eventData(event)
+	id, _ := data["id"].(string)
+	if id == "" {
+		return
+	}
+	if err := m.search.DeleteGuild(ctx, id); err != nil {
+		m.logger.Error("failed to delete guild from index",
+			slog.String("id", id),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func (m *Manager) deleteFromIndex(ctx context.Context, ids []string) {
+	if err := m.search.DeleteMessages(ctx, ids); err != nil {
+		m.logger.Error("failed to delete messages from index",
+			slog.Int("count", len(ids)),
+			slog.String("error", err.Error()),
+		)
+	}
+}
@@ -3,6 +3,7 @@ package workers
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/amityvox/amityvox/internal/events"
 )
@@ -97,3 +98,96 @@ func TestNew(t *testing.T) {
 		t.Error("search should be nil")
 	}
 }
+
+func TestConsumerBacklog(t *testing.T) {
+	c := events.ConsumerStatus{Pending: jetStreamLagThreshold - 10, AckPending: 10}
+	if got := consumerBacklog(c); got != jetStreamLagThreshold {
+		t.Errorf("consumerBacklog = %d, want %d", got, jetStreamLagThreshold)
+	}
+	if jetStreamLagRecovered >= jetStreamLagThreshold {
+		t.Error("recovery level must be below the alert threshold")
+	}
+}
+
+func TestPinboardWatches(t *testing.T) {
+	all := pinboard{ChannelID: "board", IgnoredChannelIDs: []string{"spam"}}
+	if !all.watches("general") {
+		t.Error("board without sources should watch every channel")
+	}
+	if all.watches("board") {
+		t.Error("board should not collect from its own channel")
+	}
+	if all.watches("spam") {
+		t.Error("board should skip ignored channels")
+	}
+
+	art := pinboard{ChannelID: "art-board", SourceChannelIDs: []string{"art"}}
+	if !art.watches("art") || art.watches("memes") {
+		t.Error("board with sources should watch only those channels")
+	}
+}
+
+func TestPinboardPost(t *testing.T) {
+	content := "nice drawing"
+	if got, want := pinboardPost(5, "star", "ch1", &content), "**5** star | <#ch1>\nnice drawing"; got != want {
+		t.Errorf("pinboardPost = %q, want %q", got, want)
+	}
+	if got, want := pinboardPost(3, "fire", "ch1", nil), "**3** fire | <#ch1>\n"; got != want {
+		t.Errorf("pinboardPost = %q, want %q", got, want)
+	}
+}
+
+func TestBroadcastPost(t *testing.T) {
+	if got, want := broadcastPost("Makers", "Meetup on Friday"), "**Announcement from Makers**\nMeetup on Friday"; got != want {
+		t.Errorf("broadcastPost = %q, want %q", got, want)
+	}
+}
+
+func TestResolveEmbedURL(t *testing.T) {
+	tests := []struct {
+		page, ref, want string
+	}{
+		{"https://example.com/post/1", "https://cdn.example.net/a.png", "https://cdn.example.net/a.png"},
+		{"https://example.com/post/1", "/img/a.png", "https://example.com/img/a.png"},
+		{"https://example.com/post/1", "a.png", "https://example.com/post/a.png"},
+		{"https://example.com/post/1", "//cdn.example.net/a.png", "https://cdn.example.net/a.png"},
+	}
+	for _, tt := range tests {
+		if got := resolveEmbedURL(tt.page, tt.ref); got != tt.want {
+			t.Errorf("resolveEmbedURL(%q, %q) = %q, want %q", tt.page, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	tests := []time.Time{
+		monday,
+		time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC),                    // Wednesday
+		time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC),                    // Sunday
+		time.Date(2026, 10, 19, 1, 0, 0, 0, time.FixedZone("UTC+2", 7200)), // Sunday 23:00 UTC
+	}
+	for _, tt := range tests {
+		if got := weekStart(tt); !got.Equal(monday) {
+			t.Errorf("weekStart(%v) = %v, want %v", tt, got, monday)
+		}
+	}
+}
+
+func TestNextPurgeRun(t *testing.T) {
+	runAt := time.Date(2026, 10, 1, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{runAt.Add(time.Minute), time.Date(2026, 10, 8, 18, 0, 0, 0, time.UTC)},
+		// Missed weeks are skipped, not run back to back.
+		{time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 22, 18, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 8, 18, 0, 0, 0, time.UTC), time.Date(2026, 10, 15, 18, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := nextPurgeRun(runAt, 7, tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextPurgeRun(now=%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
@@ -193,6 +193,32 @@ func (c *Client) SendMessage(ctx context.Context, channelID, content string) (*M
 	return &msg, nil
 }
 
+// SendMessageWithNonce sends a message tagged with nonce. The nonce comes
+// back on the message and its MESSAGE_CREATE event, and resending with the
+// same nonce returns the message already sent instead of posting it again,
+// so a send can be retried safely.
+func (c *Client) SendMessageWithNonce(ctx context.Context, channelID, content, nonce string) (*Message, error) {
+	body := map[string]interface{}{
+		"content": content,
+		"nonce":   nonce,
+	}
+	var msg Message
+	if err := c.request(ctx, http.MethodPost, "/channels/"+channelID+"/messages", body, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// MessageByNonce returns the bot's own message sent with nonce, for when a
+// send's response was lost.
+func (c *Client) MessageByNonce(ctx context.Context, channelID, nonce string) (*Message, error) {
+	var msg Message
+	if err := c.request(ctx, http.MethodGet, "/channels/"+channelID+"/messages/nonce/"+url.PathEscape(nonce), nil, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
 // SendMessageWithEmbed sends a message with an embed to a channel.
 func (c *Client) SendMessageWithEmbed(ctx context.Context, channelID, content string, embeds []MessageEmbed) (*Message, error) {
 	body := map[string]interface{}{
@@ -120,6 +120,7 @@ type Message struct {
 	ChannelID       string       `json:"channel_id"`
 	AuthorID        string       `json:"author_id"`
 	Content         *string      `json:"content,omitempty"`
+	Nonce           *string      `json:"nonce,omitempty"`
 	MessageType     string       `json:"message_type"`
 	EditedAt        *string      `json:"edited_at,omitempty"`
 	Flags           int          `json:"flags"`
@@ -0,0 +1,117 @@
+package amityvox
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Requests an instance sends to integrators (outgoing webhooks and
+// interactions delivered to an app's endpoint) are signed with the
+// instance's Ed25519 key over "<timestamp>.<body>". Verify them before
+// acting on them:
+//
+//	key, _ := client.SigningKey(ctx)
+//	http.HandleFunc("/interactions", func(w http.ResponseWriter, r *http.Request) {
+//	    body, err := amityvox.VerifyRequest(key, r)
+//	    if err != nil {
+//	        http.Error(w, "invalid signature", http.StatusUnauthorized)
+//	        return
+//	    }
+//	    // handle body
+//	})
+const (
+	SignatureHeader = "X-AmityVox-Signature-Ed25519"
+	TimestampHeader = "X-AmityVox-Timestamp"
+)
+
+// MaxRequestAge is how old, or how far in the future, a signed request's
+// timestamp may be before VerifyRequest rejects it as a replay.
+const MaxRequestAge = 5 * time.Minute
+
+// maxSignedBody caps how much of a request body VerifyRequest reads.
+const maxSignedBody = 4 << 20
+
+// Errors returned by VerifyRequest.
+var (
+	ErrMissingSignature = errors.New("request is not signed")
+	ErrInvalidSignature = errors.New("request signature is invalid")
+	ErrStaleRequest     = errors.New("request timestamp is too old or in the future")
+)
+
+// ParsePublicKey decodes the hex signing key published by an instance.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid signing key %q", s)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// VerifySignature reports whether signature (hex) is the instance's
+// signature of body sent at timestamp. It does not check the timestamp's age;
+// use VerifyRequest for that.
+func VerifySignature(key ed25519.PublicKey, signature, timestamp string, body []byte) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	msg := make([]byte, 0, len(timestamp)+1+len(body))
+	msg = append(append(append(msg, timestamp...), '.'), body...)
+	return ed25519.Verify(key, msg, sig)
+}
+
+// VerifyRequest checks the signature and timestamp of a request from the
+// instance and returns its body. The body is also left readable on r.
+func VerifyRequest(key ed25519.PublicKey, r *http.Request) ([]byte, error) {
+	signature := r.Header.Get(SignatureHeader)
+	timestamp := r.Header.Get(TimestampHeader)
+	if signature == "" || timestamp == "" {
+		return nil, ErrMissingSignature
+	}
+
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(sent, 0)); age > MaxRequestAge || age < -MaxRequestAge {
+		return nil, ErrStaleRequest
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBody))
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if !VerifySignature(key, signature, timestamp, body) {
+		return nil, ErrInvalidSignature
+	}
+	return body, nil
+}
+
+// SigningKey fetches the key the instance signs its requests with. Fetch it
+// once at start-up rather than per request.
+func (c *Client) SigningKey(ctx context.Context) (ed25519.PublicKey, error) {
+	var inst struct {
+		SigningKey string `json:"signing_key"`
+	}
+	if err := c.request(ctx, http.MethodGet, "/instance", nil, &inst); err != nil {
+		return nil, err
+	}
+	if inst.SigningKey == "" {
+		return nil, errors.New("instance does not publish a signing key")
+	}
+	return ParsePublicKey(inst.SigningKey)
+}
@@ -3,11 +3,17 @@
 
 import type {
 	User,
+	TokenLeakResult,
 	Guild,
+	GuildFolder,
+	GuildLayout,
+	SelfGuild,
 	Channel,
 	Message,
 	GuildMember,
 	Role,
+	PermissionPreset,
+	TimeoutRoleState,
 	Invite,
 	Ban,
 	AuditLogEntry,
@@ -18,21 +24,43 @@ import type {
 	LoginResponse,
 	RegisterResponse,
 	ApiResponse,
+	Page,
+	PageParams,
 	ApiError,
 	AdminStats,
 	InstanceInfo,
+	InstanceBranding,
+	PublicInstance,
+	InstanceStatus,
+	MaintenanceWindow,
+	DisabledSubsystems,
+	FeatureFlag,
+	FeatureFlagState,
+	FeatureFlagTarget,
 	NotificationPreference,
 	ChannelNotificationPreference,
 	Webhook,
 	UserSettings,
 	Category,
+	ChannelPositions,
 	FederationPeer,
 	Poll,
 	MessageBookmark,
+	MentionInboxItem,
+	MemberSuggestion,
+	UserSuggestion,
+	GuildSuggestion,
 	GuildEvent,
 	EventRSVP,
 	MemberWarning,
 	MessageReport,
+	MessageLinkFlag,
+	LinkBlocklistEntry,
+	GuildEditPolicy,
+	GuildJoinOriginPolicy,
+	GuildEmojiStats,
+	OperatorAlertWebhook,
+	AlertWebhookDelivery,
 	RaidConfig,
 	AutoModRule,
 	AutoModAction,
@@ -49,6 +77,23 @@ import type {
 	BanListSubscription,
 	ChannelFollower,
 	BotToken,
+	ApiUsageReport,
+	GuildBroadcast,
+	GuildScheduledPurge,
+	GuildEmojiAlias,
+	Community,
+	CommunityRole,
+	CommunityBan,
+	UserContentSettings,
+	ProfilePrivacy,
+	DirectoryEntry,
+	FederationSandboxStatus,
+	FederationSandboxEvent,
+	FederationRejection,
+	AdminActionToken,
+	RecommendedGuild,
+	FederatedDMRequest,
+	GroupDMInvite,
 	SlashCommand,
 	StickerPack,
 	Sticker,
@@ -57,19 +102,32 @@ import type {
 	ModerationStats,
 	ModerationMessageReport,
 	VoicePreferences,
+	Call,
+	ScreenShareConfig,
+	ChannelInputMode,
+	PushSubscriptionInfo,
 	MutualGuild,
 	UserLink,
 	Attachment,
 	MediaTag,
 	RetentionPolicy,
+	InstanceRetention,
+	LegalHold,
+	TranscriptLink,
+	TranscriptAccess,
 	ForumTag,
 	ForumPost,
 	GalleryTag,
 	GalleryPost,
 	ServerNotification,
 	NotificationTypePreference,
-	KeyAuditEntry
+	KeyAuditEntry,
+	ActivitySummary,
+	MessageSource,
+	MessageSourceStats,
+	ChannelSourceRateLimit
 } from '$lib/types';
+import { CLIENT_VERSION } from '$lib/version';
 
 const API_BASE = '/api/v1';
 
@@ -93,8 +151,16 @@ class ApiClient {
 	}
 
 	private async request<T>(method: string, path: string, body?: unknown): Promise<T> {
+		const json = await this.requestRaw(method, path, body);
+		return (json as ApiResponse<T> | undefined)?.data as T;
+	}
+
+	// requestRaw sends a request and returns the whole response body, for
+	// responses carrying more than data, such as pages.
+	private async requestRaw(method: string, path: string, body?: unknown): Promise<unknown> {
 		const headers: Record<string, string> = {
-			'Content-Type': 'application/json'
+			'Content-Type': 'application/json',
+			'X-Client-Version': CLIENT_VERSION
 		};
 
 		const token = this.getToken();
@@ -109,7 +175,7 @@ class ApiClient {
 		});
 
 		if (res.status === 204) {
-			return undefined as T;
+			return undefined;
 		}
 
 		let json: unknown;
@@ -133,7 +199,7 @@ class ApiClient {
 			);
 		}
 
-		return (json as ApiResponse<T>).data;
+		return json;
 	}
 
 	private get<T>(path: string) {
@@ -152,6 +218,39 @@ class ApiClient {
 		return this.request<T>('DELETE', path, body);
 	}
 
+	private async getPage<T>(path: string, params?: PageParams): Promise<Page<T>> {
+		const query = new URLSearchParams();
+		if (params?.before) query.set('before', params.before);
+		if (params?.after) query.set('after', params.after);
+		if (params?.limit) query.set('limit', String(params.limit));
+		const qs = query.toString();
+		const sep = path.includes('?') ? '&' : '?';
+		return (await this.requestRaw('GET', qs ? path + sep + qs : path)) as Page<T>;
+	}
+
+	// getAllPages follows next_cursor until the list is exhausted.
+	private async getAllPages<T>(path: string): Promise<T[]> {
+		const items: T[] = [];
+		let after: string | undefined;
+		do {
+			const page = await this.getPage<T>(path, { after, limit: 1000 });
+			items.push(...page.data);
+			after = page.next_cursor ?? undefined;
+		} while (after);
+		return items;
+	}
+
+	// --- Instance ---
+
+	// getPublicInstance works before login; the login page uses it for branding.
+	getPublicInstance(): Promise<PublicInstance> {
+		return this.get('/instance');
+	}
+
+	getInstanceStatus(): Promise<InstanceStatus> {
+		return this.get('/instance/status');
+	}
+
 	// --- Auth ---
 
 	async register(username: string, email: string, password: string): Promise<LoginResponse> {
@@ -181,7 +280,7 @@ class ApiClient {
 		return this.get('/users/@me');
 	}
 
-	updateMe(data: Partial<Pick<User, 'username' | 'display_name' | 'bio' | 'status_text' | 'status_emoji' | 'status_presence' | 'pronouns' | 'accent_color' | 'banner_id'>> & { status_expires_at?: string | null; avatar_id?: string | null }): Promise<User> {
+	updateMe(data: Partial<Pick<User, 'username' | 'display_name' | 'bio' | 'status_text' | 'status_emoji' | 'status_presence' | 'pronouns' | 'accent_color' | 'banner_id' | 'read_receipts'>> & { status_expires_at?: string | null; avatar_id?: string | null }): Promise<User> {
 		return this.patch('/users/@me', data);
 	}
 
@@ -213,8 +312,8 @@ class ApiClient {
 		return this.del(`/users/@me/links/${linkId}`);
 	}
 
-	getMyGuilds(): Promise<Guild[]> {
-		return this.get('/users/@me/guilds');
+	getMyGuilds(): Promise<SelfGuild[]> {
+		return this.getAllPages('/users/@me/guilds');
 	}
 
 	getMyDMs(): Promise<Channel[]> {
@@ -229,18 +328,32 @@ class ApiClient {
 		return this.post('/users/@me/group-dms', { user_ids: userIds, name });
 	}
 
-	addGroupDMRecipient(channelId: string, userId: string): Promise<Channel> {
+	// Resolves to an invite instead of the channel when the user has to
+	// accept being added.
+	addGroupDMRecipient(channelId: string, userId: string): Promise<Channel | GroupDMInvite> {
 		return this.put(`/channels/${channelId}/recipients/${userId}`);
 	}
 
+	getGroupDMInvites(): Promise<GroupDMInvite[]> {
+		return this.get('/users/@me/group-dm-invites');
+	}
+
+	acceptGroupDMInvite(inviteId: string): Promise<Channel> {
+		return this.post(`/users/@me/group-dm-invites/${inviteId}/accept`);
+	}
+
+	declineGroupDMInvite(inviteId: string): Promise<void> {
+		return this.del(`/users/@me/group-dm-invites/${inviteId}`);
+	}
+
 	removeGroupDMRecipient(channelId: string, userId: string): Promise<void> {
 		return this.del(`/channels/${channelId}/recipients/${userId}`);
 	}
 
 	// --- Guilds ---
 
-	createGuild(name: string, description?: string): Promise<Guild> {
-		return this.post('/guilds', { name, description });
+	createGuild(name: string, description?: string, templateId?: string): Promise<Guild> {
+		return this.post('/guilds', { name, description, template_id: templateId });
 	}
 
 	getGuild(guildId: string): Promise<Guild> {
@@ -289,6 +402,22 @@ class ApiClient {
 		return this.post(`/guilds/${guildId}/channels/${channelId}/clone`, name ? { name } : {});
 	}
 
+	getArchivedChannels(
+		guildId: string,
+		params?: PageParams & { kind?: 'channels' | 'threads'; parent_id?: string; q?: string }
+	): Promise<Page<Channel>> {
+		const query = new URLSearchParams();
+		if (params?.kind) query.set('kind', params.kind);
+		if (params?.parent_id) query.set('parent_id', params.parent_id);
+		if (params?.q) query.set('q', params.q);
+		const qs = query.toString();
+		return this.getPage(`/guilds/${guildId}/archive${qs ? '?' + qs : ''}`, params);
+	}
+
+	restoreArchivedChannel(guildId: string, channelId: string): Promise<Channel> {
+		return this.post(`/guilds/${guildId}/archive/${channelId}/restore`);
+	}
+
 	// --- Messages ---
 
 	getMessages(channelId: string, params?: { before?: string; after?: string; limit?: number }): Promise<Message[]> {
@@ -335,6 +464,175 @@ class ApiClient {
 		return this.post(`/channels/${channelId}/messages/bulk-delete`, { message_ids: messageIds });
 	}
 
+	getDeletedMessages(channelId: string): Promise<(Message & { deleted_at: string; deleted_by: string | null; purge_at: string })[]> {
+		return this.get(`/channels/${channelId}/messages/deleted`);
+	}
+
+	restoreMessage(channelId: string, messageId: string): Promise<Message> {
+		return this.post(`/channels/${channelId}/messages/${messageId}/restore`);
+	}
+
+	getMessageRestoreWindow(guildId: string): Promise<{ guild_id: string; window_days: number }> {
+		return this.get(`/guilds/${guildId}/message-restore`);
+	}
+
+	setMessageRestoreWindow(guildId: string, windowDays: number): Promise<{ guild_id: string; window_days: number }> {
+		return this.patch(`/guilds/${guildId}/message-restore`, { window_days: windowDays });
+	}
+
+	getEditPolicy(guildId: string): Promise<GuildEditPolicy> {
+		return this.get(`/guilds/${guildId}/edit-policy`);
+	}
+
+	updateEditPolicy(guildId: string, data: Partial<Omit<GuildEditPolicy, 'guild_id' | 'updated_at'>>): Promise<GuildEditPolicy> {
+		return this.patch(`/guilds/${guildId}/edit-policy`, data);
+	}
+
+	getJoinOriginPolicy(guildId: string): Promise<GuildJoinOriginPolicy> {
+		return this.get(`/guilds/${guildId}/join-origin-policy`);
+	}
+
+	updateJoinOriginPolicy(guildId: string, data: Partial<Omit<GuildJoinOriginPolicy, 'guild_id' | 'updated_at'>>): Promise<GuildJoinOriginPolicy> {
+		return this.patch(`/guilds/${guildId}/join-origin-policy`, data);
+	}
+
+	getEmojiStats(guildId: string, weeks?: number): Promise<GuildEmojiStats> {
+		return this.get(`/guilds/${guildId}/emoji-stats${weeks ? `?weeks=${weeks}` : ''}`);
+	}
+
+	getGuildBroadcasts(guildId: string): Promise<GuildBroadcast[]> {
+		return this.get(`/guilds/${guildId}/broadcasts`);
+	}
+
+	createGuildBroadcast(guildId: string, content: string): Promise<GuildBroadcast> {
+		return this.post(`/guilds/${guildId}/broadcasts`, { content });
+	}
+
+	cancelGuildBroadcast(guildId: string, broadcastId: string): Promise<void> {
+		return this.del(`/guilds/${guildId}/broadcasts/${broadcastId}`);
+	}
+
+	getBroadcastSubscription(guildId: string): Promise<{ subscribed: boolean }> {
+		return this.get(`/guilds/${guildId}/broadcasts/subscription`);
+	}
+
+	setBroadcastSubscription(guildId: string, subscribed: boolean): Promise<{ subscribed: boolean }> {
+		return this.put(`/guilds/${guildId}/broadcasts/subscription`, { subscribed });
+	}
+
+	getScheduledPurges(guildId: string): Promise<GuildScheduledPurge[]> {
+		return this.get(`/guilds/${guildId}/scheduled-purges`);
+	}
+
+	createScheduledPurge(
+		guildId: string,
+		data: { kind: 'category' | 'role'; target_id: string; run_at: string; repeat_days?: number }
+	): Promise<GuildScheduledPurge> {
+		return this.post(`/guilds/${guildId}/scheduled-purges`, data);
+	}
+
+	deleteScheduledPurge(guildId: string, purgeId: string): Promise<void> {
+		return this.del(`/guilds/${guildId}/scheduled-purges/${purgeId}`);
+	}
+
+	getEmojiAliases(guildId: string): Promise<GuildEmojiAlias[]> {
+		return this.get(`/guilds/${guildId}/emoji-aliases`);
+	}
+
+	setEmojiAlias(guildId: string, alias: string, emoji: string): Promise<GuildEmojiAlias> {
+		return this.put(`/guilds/${guildId}/emoji-aliases/${encodeURIComponent(alias)}`, { emoji });
+	}
+
+	deleteEmojiAlias(guildId: string, alias: string): Promise<void> {
+		return this.del(`/guilds/${guildId}/emoji-aliases/${encodeURIComponent(alias)}`);
+	}
+
+	// --- Communities ---
+
+	getOwnedCommunities(): Promise<Community[]> {
+		return this.get('/communities');
+	}
+
+	discoverCommunities(): Promise<Community[]> {
+		return this.get('/communities/discover');
+	}
+
+	createCommunity(data: { name: string; description?: string; icon_id?: string; banner_id?: string; accent_color?: string }): Promise<Community> {
+		return this.post('/communities', data);
+	}
+
+	getCommunity(communityId: string): Promise<Community> {
+		return this.get(`/communities/${communityId}`);
+	}
+
+	updateCommunity(communityId: string, data: Partial<Pick<Community, 'name' | 'description' | 'icon_id' | 'banner_id' | 'accent_color' | 'discoverable'>>): Promise<Community> {
+		return this.patch(`/communities/${communityId}`, data);
+	}
+
+	deleteCommunity(communityId: string): Promise<void> {
+		return this.del(`/communities/${communityId}`);
+	}
+
+	addCommunityGuild(communityId: string, guildId: string): Promise<Community> {
+		return this.post(`/communities/${communityId}/guilds`, { guild_id: guildId });
+	}
+
+	removeCommunityGuild(communityId: string, guildId: string): Promise<void> {
+		return this.del(`/communities/${communityId}/guilds/${guildId}`);
+	}
+
+	getGuildCommunity(guildId: string): Promise<Community> {
+		return this.get(`/guilds/${guildId}/community`);
+	}
+
+	acceptCommunityInvite(guildId: string): Promise<Community> {
+		return this.post(`/guilds/${guildId}/community/accept`);
+	}
+
+	leaveCommunity(guildId: string): Promise<void> {
+		return this.del(`/guilds/${guildId}/community`);
+	}
+
+	getCommunityRoles(communityId: string): Promise<CommunityRole[]> {
+		return this.get(`/communities/${communityId}/roles`);
+	}
+
+	createCommunityRole(communityId: string, data: { name: string; color?: string; permissions_allow?: number; permissions_deny?: number }): Promise<CommunityRole> {
+		return this.post(`/communities/${communityId}/roles`, data);
+	}
+
+	updateCommunityRole(communityId: string, roleId: string, data: { name?: string; color?: string; permissions_allow?: number; permissions_deny?: number }): Promise<CommunityRole> {
+		return this.patch(`/communities/${communityId}/roles/${roleId}`, data);
+	}
+
+	deleteCommunityRole(communityId: string, roleId: string): Promise<void> {
+		return this.del(`/communities/${communityId}/roles/${roleId}`);
+	}
+
+	getCommunityRoleMembers(communityId: string, roleId: string): Promise<User[]> {
+		return this.get(`/communities/${communityId}/roles/${roleId}/members`);
+	}
+
+	grantCommunityRole(communityId: string, roleId: string, userId: string): Promise<void> {
+		return this.put(`/communities/${communityId}/roles/${roleId}/members/${userId}`);
+	}
+
+	revokeCommunityRole(communityId: string, roleId: string, userId: string): Promise<void> {
+		return this.del(`/communities/${communityId}/roles/${roleId}/members/${userId}`);
+	}
+
+	getCommunityBans(communityId: string): Promise<CommunityBan[]> {
+		return this.get(`/communities/${communityId}/bans`);
+	}
+
+	banFromCommunity(communityId: string, userId: string, reason?: string): Promise<void> {
+		return this.put(`/communities/${communityId}/bans/${userId}`, reason ? { reason } : undefined);
+	}
+
+	unbanFromCommunity(communityId: string, userId: string): Promise<void> {
+		return this.del(`/communities/${communityId}/bans/${userId}`);
+	}
+
 	// --- Pins ---
 
 	getPins(channelId: string): Promise<Message[]> {
@@ -359,6 +657,18 @@ class ApiClient {
 		return this.post(`/channels/${channelId}/ack`);
 	}
 
+	ackGuild(guildId: string): Promise<void> {
+		return this.post(`/guilds/${guildId}/ack`);
+	}
+
+	ackAll(): Promise<void> {
+		return this.post('/users/@me/ack');
+	}
+
+	getActivitySummary(channelId: string, recap = false): Promise<ActivitySummary> {
+		return this.get(`/channels/${channelId}/summary${recap ? '?recap=true' : ''}`);
+	}
+
 	// --- Friends ---
 
 	getFriends(): Promise<Relationship[]> {
@@ -393,6 +703,12 @@ class ApiClient {
 		return this.get(`/users/resolve?handle=${encodeURIComponent(handle)}`);
 	}
 
+	autocompleteUsers(q: string, limit?: number): Promise<UserSuggestion[]> {
+		const query = new URLSearchParams({ q });
+		if (limit) query.set('limit', String(limit));
+		return this.get(`/users/autocomplete?${query}`);
+	}
+
 	// --- Reactions ---
 
 	addReaction(channelId: string, messageId: string, emoji: string): Promise<void> {
@@ -406,7 +722,7 @@ class ApiClient {
 	// --- Members ---
 
 	getMembers(guildId: string): Promise<GuildMember[]> {
-		return this.get(`/guilds/${guildId}/members`);
+		return this.getAllPages(`/guilds/${guildId}/members`);
 	}
 
 	getMember(guildId: string, memberId: string): Promise<GuildMember> {
@@ -439,14 +755,38 @@ class ApiClient {
 		return this.get(`/guilds/${guildId}/roles`);
 	}
 
-	createRole(guildId: string, name: string, opts?: { color?: string; hoist?: boolean; mentionable?: boolean; permissions_allow?: string; permissions_deny?: string }): Promise<Role> {
+	createRole(guildId: string, name: string, opts?: { color?: string; hoist?: boolean; mentionable?: boolean; permissions_allow?: string; permissions_deny?: string; preset_id?: string }): Promise<Role> {
 		return this.post(`/guilds/${guildId}/roles`, { name, ...opts });
 	}
 
+	getPermissionPresets(guildId: string): Promise<PermissionPreset[]> {
+		return this.get(`/guilds/${guildId}/permission-presets`);
+	}
+
+	createPermissionPreset(guildId: string, data: { name: string; description?: string; permissions_allow: string; permissions_deny: string }): Promise<PermissionPreset> {
+		return this.post(`/guilds/${guildId}/permission-presets`, data);
+	}
+
+	deletePermissionPreset(guildId: string, presetId: string): Promise<void> {
+		return this.del(`/guilds/${guildId}/permission-presets/${presetId}`);
+	}
+
+	getTimeoutRole(guildId: string): Promise<TimeoutRoleState> {
+		return this.get(`/guilds/${guildId}/timeout-role`);
+	}
+
+	enableTimeoutRole(guildId: string): Promise<TimeoutRoleState> {
+		return this.put(`/guilds/${guildId}/timeout-role`);
+	}
+
+	disableTimeoutRole(guildId: string): Promise<void> {
+		return this.del(`/guilds/${guildId}/timeout-role`);
+	}
+
 	// --- Invites ---
 
 	getGuildInvites(guildId: string): Promise<Invite[]> {
-		return this.get(`/guilds/${guildId}/invites`);
+		return this.getAllPages(`/guilds/${guildId}/invites`);
 	}
 
 	createInvite(guildId: string, opts?: { max_uses?: number; max_age_seconds?: number }): Promise<Invite> {
@@ -468,30 +808,48 @@ class ApiClient {
 	// --- Bans ---
 
 	getGuildBans(guildId: string): Promise<Ban[]> {
-		return this.get(`/guilds/${guildId}/bans`);
+		return this.getAllPages(`/guilds/${guildId}/bans`);
 	}
 
 	// --- Audit Log ---
 
-	getAuditLog(guildId: string, params?: { limit?: number; before?: string; action_type?: string }): Promise<AuditLogEntry[]> {
+	getAuditLog(guildId: string, params?: PageParams & { action_type?: string }): Promise<Page<AuditLogEntry>> {
 		const query = new URLSearchParams();
-		if (params?.limit) query.set('limit', String(params.limit));
-		if (params?.before) query.set('before', params.before);
 		if (params?.action_type) query.set('action_type', params.action_type);
 		const qs = query.toString();
-		return this.get(`/guilds/${guildId}/audit-log${qs ? '?' + qs : ''}`);
+		return this.getPage(`/guilds/${guildId}/audit-log${qs ? '?' + qs : ''}`, params);
 	}
 
 	// --- Emoji ---
 
 	getGuildEmoji(guildId: string): Promise<CustomEmoji[]> {
-		return this.get(`/guilds/${guildId}/emoji`);
+		return this.getAllPages(`/guilds/${guildId}/emoji`);
 	}
 
 	deleteGuildEmoji(guildId: string, emojiId: string): Promise<void> {
 		return this.del(`/guilds/${guildId}/emoji/${emojiId}`);
 	}
 
+	getInstanceEmoji(): Promise<CustomEmoji[]> {
+		return this.get('/emoji');
+	}
+
+	getRemoteEmoji(peerId: string): Promise<CustomEmoji[]> {
+		return this.get(`/federation/peers/${peerId}/emoji`);
+	}
+
+	createInstanceEmoji(name: string, fileId: string, animated = false): Promise<CustomEmoji> {
+		return this.post('/admin/emoji', { name, file_id: fileId, animated });
+	}
+
+	renameInstanceEmoji(emojiId: string, name: string): Promise<CustomEmoji> {
+		return this.patch(`/admin/emoji/${emojiId}`, { name });
+	}
+
+	deleteInstanceEmoji(emojiId: string): Promise<void> {
+		return this.del(`/admin/emoji/${emojiId}`);
+	}
+
 	// --- Auth / Security ---
 
 	changePassword(currentPassword: string, newPassword: string): Promise<void> {
@@ -540,6 +898,34 @@ class ApiClient {
 		return this.post(`/voice/${channelId}/leave`);
 	}
 
+	declineCall(channelId: string): Promise<Call> {
+		return this.post(`/voice/${channelId}/call/decline`);
+	}
+
+	getCallLog(channelId: string, params?: { before?: string; limit?: number }): Promise<Call[]> {
+		const query = new URLSearchParams();
+		if (params?.before) query.set('before', params.before);
+		if (params?.limit) query.set('limit', String(params.limit));
+		const qs = query.toString();
+		return this.get(`/voice/${channelId}/calls${qs ? `?${qs}` : ''}`);
+	}
+
+	getScreenShareConfig(channelId: string): Promise<ScreenShareConfig> {
+		return this.get(`/voice/${channelId}/screen-share/config`);
+	}
+
+	updateScreenShareConfig(channelId: string, data: Partial<Pick<ScreenShareConfig, 'max_resolution' | 'max_framerate'>>): Promise<ScreenShareConfig> {
+		return this.patch(`/voice/${channelId}/screen-share/config`, data);
+	}
+
+	getChannelInputMode(channelId: string): Promise<ChannelInputMode> {
+		return this.get(`/voice/${channelId}/input-mode/config`);
+	}
+
+	updateChannelInputMode(channelId: string, inputMode: 'vad' | 'ptt'): Promise<ChannelInputMode> {
+		return this.patch(`/voice/${channelId}/input-mode/config`, { input_mode: inputMode });
+	}
+
 	getVoicePreferences(): Promise<VoicePreferences> {
 		return this.get('/voice/preferences');
 	}
@@ -573,6 +959,24 @@ class ApiClient {
 		return this.get(`/federation/peers/${peerId}/guilds${qs ? `?${qs}` : ''}`);
 	}
 
+	getRecommendedRemoteGuilds(): Promise<RecommendedGuild[]> {
+		return this.get('/federation/discover/recommended');
+	}
+
+	// --- Federated DM Requests ---
+
+	getFederatedDMRequests(): Promise<FederatedDMRequest[]> {
+		return this.get('/federation/dm-requests');
+	}
+
+	acceptFederatedDMRequest(requestId: string): Promise<{ channel_id: string }> {
+		return this.post(`/federation/dm-requests/${requestId}/accept`);
+	}
+
+	declineFederatedDMRequest(requestId: string): Promise<void> {
+		return this.del(`/federation/dm-requests/${requestId}`);
+	}
+
 	// --- Federation Guilds ---
 
 	joinFederatedGuild(instanceDomain: string, guildId?: string, inviteCode?: string): Promise<{
@@ -773,6 +1177,14 @@ class ApiClient {
 		return this.patch('/admin/instance', data);
 	}
 
+	getAdminBranding(): Promise<InstanceBranding> {
+		return this.get('/admin/branding');
+	}
+
+	updateAdminBranding(data: InstanceBranding): Promise<InstanceBranding> {
+		return this.put('/admin/branding', data);
+	}
+
 	// --- Admin Guilds ---
 
 	getAdminGuilds(params?: { limit?: number; offset?: number; query?: string; sort?: string }): Promise<any[]> {
@@ -842,6 +1254,10 @@ class ApiClient {
 		return this.post(`/admin/federation/peers/${peerId}/reject`);
 	}
 
+	updateFederationPeerTrust(peerId: string, trustLevel: 'trusted' | 'normal' | 'restricted', reason?: string): Promise<{ peer_id: string; trust_level: string; status: string }> {
+		return this.put(`/admin/federation/peers/${peerId}/trust`, { trust_level: trustLevel, reason });
+	}
+
 	getKeyAudit(): Promise<KeyAuditEntry[]> {
 		return this.get('/admin/federation/key-audit');
 	}
@@ -850,6 +1266,62 @@ class ApiClient {
 		return this.post(`/admin/federation/key-audit/${auditId}/acknowledge`);
 	}
 
+	getDirectoryListing(): Promise<{ listed: boolean }> {
+		return this.get('/admin/federation/directory-listing');
+	}
+
+	updateDirectoryListing(listed: boolean): Promise<{ listed: boolean }> {
+		return this.put('/admin/federation/directory-listing', { listed });
+	}
+
+	getInstanceDirectory(q?: string): Promise<DirectoryEntry[]> {
+		return this.get(`/admin/federation/directory${q ? `?q=${encodeURIComponent(q)}` : ''}`);
+	}
+
+	refreshInstanceDirectory(): Promise<{ status: string }> {
+		return this.post('/admin/federation/directory/refresh');
+	}
+
+	getFederationSandbox(): Promise<FederationSandboxStatus> {
+		return this.get('/admin/federation/sandbox');
+	}
+
+	updateFederationDryRun(dryRun: boolean, reason?: string): Promise<{ dry_run: boolean }> {
+		return this.put('/admin/federation/sandbox', { dry_run: dryRun, reason });
+	}
+
+	updateFederationPeerSandbox(peerId: string, sandbox: boolean, reason?: string): Promise<{ peer_id: string; sandbox: boolean }> {
+		return this.put(`/admin/federation/peers/${peerId}/sandbox`, { sandbox, reason });
+	}
+
+	getFederationSandboxEvents(params?: { direction?: 'outbound' | 'inbound'; peer_id?: string; limit?: number }): Promise<FederationSandboxEvent[]> {
+		const query = new URLSearchParams();
+		if (params?.direction) query.set('direction', params.direction);
+		if (params?.peer_id) query.set('peer_id', params.peer_id);
+		if (params?.limit) query.set('limit', String(params.limit));
+		const qs = query.toString();
+		return this.get(`/admin/federation/sandbox/events${qs ? `?${qs}` : ''}`);
+	}
+
+	clearFederationSandboxEvents(): Promise<void> {
+		return this.del('/admin/federation/sandbox/events');
+	}
+
+	createAdminActionToken(actions: string[], ttlSeconds?: number, uses?: number): Promise<AdminActionToken> {
+		return this.post('/admin/action-tokens', { actions, ttl_seconds: ttlSeconds, uses });
+	}
+
+	getFederationRejections(params?: { sender_id?: string; reason?: FederationRejection['reason']; endpoint?: string; before?: string; limit?: number }): Promise<FederationRejection[]> {
+		const query = new URLSearchParams();
+		if (params?.sender_id) query.set('sender_id', params.sender_id);
+		if (params?.reason) query.set('reason', params.reason);
+		if (params?.endpoint) query.set('endpoint', params.endpoint);
+		if (params?.before) query.set('before', params.before);
+		if (params?.limit) query.set('limit', String(params.limit));
+		const qs = query.toString();
+		return this.get(`/admin/federation/rejections${qs ? `?${qs}` : ''}`);
+	}
+
 	// --- Admin Instance Bans ---
 
 	instanceBanUser(userId: string, reason: string): Promise<void> {
@@ -904,6 +1376,67 @@ class ApiClient {
 		return this.del(`/admin/announcements/${id}`);
 	}
 
+	// --- Admin Maintenance ---
+
+	getMaintenanceWindows(): Promise<MaintenanceWindow[]> {
+		return this.get('/admin/maintenance');
+	}
+
+	scheduleMaintenance(data: { title: string; message?: string; starts_at?: string; ends_at: string; read_only?: boolean }): Promise<MaintenanceWindow> {
+		return this.post('/admin/maintenance', data);
+	}
+
+	updateMaintenance(id: string, data: { title?: string; message?: string; starts_at?: string; ends_at?: string; read_only?: boolean }): Promise<MaintenanceWindow> {
+		return this.patch(`/admin/maintenance/${id}`, data);
+	}
+
+	cancelMaintenance(id: string): Promise<void> {
+		return this.del(`/admin/maintenance/${id}`);
+	}
+
+	getMOTD(): Promise<{ motd: string }> {
+		return this.get('/admin/motd');
+	}
+
+	updateMOTD(motd: string): Promise<{ motd: string }> {
+		return this.put('/admin/motd', { motd });
+	}
+
+	// --- Admin Subsystem Toggles ---
+
+	getSubsystems(): Promise<DisabledSubsystems> {
+		return this.get('/admin/subsystems');
+	}
+
+	updateSubsystems(data: Partial<DisabledSubsystems>): Promise<DisabledSubsystems> {
+		return this.patch('/admin/subsystems', data);
+	}
+
+	// --- Admin Feature Flags ---
+
+	getAdminFeatureFlags(): Promise<FeatureFlag[]> {
+		return this.get('/admin/feature-flags');
+	}
+
+	updateFeatureFlag(
+		key: string,
+		data: Partial<Pick<FeatureFlag, 'description' | 'enabled' | 'user_percent' | 'guild_percent' | 'instance_percent'>>
+	): Promise<FeatureFlag> {
+		return this.put(`/admin/feature-flags/${encodeURIComponent(key)}`, data);
+	}
+
+	deleteFeatureFlag(key: string): Promise<void> {
+		return this.del(`/admin/feature-flags/${encodeURIComponent(key)}`);
+	}
+
+	setFeatureFlagOverride(key: string, targetType: FeatureFlagTarget, targetId: string, enabled: boolean): Promise<FeatureFlag> {
+		return this.put(`/admin/feature-flags/${encodeURIComponent(key)}/overrides/${targetType}/${targetId}`, { enabled });
+	}
+
+	deleteFeatureFlagOverride(key: string, targetType: FeatureFlagTarget, targetId: string): Promise<FeatureFlag> {
+		return this.del(`/admin/feature-flags/${encodeURIComponent(key)}/overrides/${targetType}/${targetId}`);
+	}
+
 	// --- Active Announcements (all users) ---
 
 	getActiveAnnouncements(): Promise<Announcement[]> {
@@ -996,10 +1529,32 @@ class ApiClient {
 		return this.patch('/users/@me/settings', data);
 	}
 
+	getContentSettings(): Promise<UserContentSettings> {
+		return this.get('/users/@me/content-settings');
+	}
+
+	updateContentSettings(
+		data: Partial<Pick<UserContentSettings, 'show_nsfw' | 'nsfw_media' | 'safe_search'>> & { confirm_age?: boolean }
+	): Promise<UserContentSettings> {
+		return this.patch('/users/@me/content-settings', data);
+	}
+
+	getFeatureFlags(): Promise<FeatureFlagState> {
+		return this.get('/users/@me/feature-flags');
+	}
+
+	getProfilePrivacy(): Promise<ProfilePrivacy> {
+		return this.get('/users/@me/profile-privacy');
+	}
+
+	updateProfilePrivacy(data: Partial<Omit<ProfilePrivacy, 'updated_at'>>): Promise<ProfilePrivacy> {
+		return this.patch('/users/@me/profile-privacy', data);
+	}
+
 	// --- Webhooks ---
 
 	getGuildWebhooks(guildId: string): Promise<Webhook[]> {
-		return this.get(`/guilds/${guildId}/webhooks`);
+		return this.getAllPages(`/guilds/${guildId}/webhooks`);
 	}
 
 	getChannelWebhooks(channelId: string): Promise<Webhook[]> {
@@ -1018,6 +1573,24 @@ class ApiClient {
 		return this.del(`/guilds/${guildId}/webhooks/${webhookId}`);
 	}
 
+	// --- Message Sources ---
+
+	getMessageSourceStats(guildId: string, days = 30): Promise<MessageSourceStats> {
+		return this.get(`/guilds/${guildId}/message-sources?days=${days}`);
+	}
+
+	getSourceRateLimits(channelId: string): Promise<ChannelSourceRateLimit[]> {
+		return this.get(`/channels/${channelId}/source-limits`);
+	}
+
+	setSourceRateLimit(channelId: string, source: MessageSource, data: { max_messages: number; window_seconds: number }): Promise<ChannelSourceRateLimit> {
+		return this.put(`/channels/${channelId}/source-limits/${source}`, data);
+	}
+
+	deleteSourceRateLimit(channelId: string, source: MessageSource): Promise<void> {
+		return this.del(`/channels/${channelId}/source-limits/${source}`);
+	}
+
 	// --- Categories ---
 
 	getCategories(guildId: string): Promise<Category[]> {
@@ -1036,6 +1609,10 @@ class ApiClient {
 		return this.del(`/guilds/${guildId}/categories/${categoryId}`);
 	}
 
+	moveCategory(guildId: string, categoryId: string, position: number): Promise<ChannelPositions> {
+		return this.post(`/guilds/${guildId}/categories/${categoryId}/move`, { position });
+	}
+
 	// --- Message Forwarding ---
 
 	forwardMessage(channelId: string, messageId: string, targetChannelId: string): Promise<Message> {
@@ -1077,8 +1654,10 @@ class ApiClient {
 		return this.get(`/encryption/key-packages/${userId}`);
 	}
 
-	claimKeyPackage(userId: string): Promise<{ id: string; key_package: string }> {
-		return this.post(`/encryption/key-packages/${userId}/claim`);
+	/** Users on other instances need the federated DM the session is for. */
+	claimKeyPackage(userId: string, channelId?: string): Promise<{ id: string; key_package: string }> {
+		const query = channelId ? `?channel_id=${encodeURIComponent(channelId)}` : '';
+		return this.post(`/encryption/key-packages/${userId}/claim${query}`);
 	}
 
 	getWelcomeMessages(): Promise<{ id: string; channel_id: string; sender_id: string; data: string; created_at: string }[]> {
@@ -1111,11 +1690,11 @@ class ApiClient {
 		return this.get('/notifications/vapid-key');
 	}
 
-	subscribePush(subscription: { endpoint: string; keys: { p256dh: string; auth: string } }): Promise<{ id: string }> {
+	subscribePush(subscription: { endpoint: string; keys: { p256dh: string; auth: string }; vapid_public_key?: string }): Promise<{ id: string }> {
 		return this.post('/notifications/subscriptions', subscription);
 	}
 
-	getPushSubscriptions(): Promise<{ id: string; endpoint: string; created_at: string }[]> {
+	getPushSubscriptions(): Promise<PushSubscriptionInfo[]> {
 		return this.get('/notifications/subscriptions');
 	}
 
@@ -1166,6 +1745,22 @@ class ApiClient {
 		return this.get(`/users/@me/bookmarks${qs ? '?' + qs : ''}`);
 	}
 
+	// --- Mentions ---
+
+	getMentions(params?: { limit?: number; before?: string; guild_id?: string; unread?: boolean }): Promise<MentionInboxItem[]> {
+		const query = new URLSearchParams();
+		if (params?.limit) query.set('limit', String(params.limit));
+		if (params?.before) query.set('before', params.before);
+		if (params?.guild_id) query.set('guild_id', params.guild_id);
+		if (params?.unread) query.set('unread', 'true');
+		const qs = query.toString();
+		return this.get(`/users/@me/mentions${qs ? '?' + qs : ''}`);
+	}
+
+	dismissMention(messageId: string): Promise<void> {
+		return this.del(`/users/@me/mentions/${messageId}`);
+	}
+
 	// --- Guild Events ---
 
 	createGuildEvent(guildId: string, data: { name: string; description?: string; location?: string; channel_id?: string; image_id?: string; scheduled_start: string; scheduled_end?: string }): Promise<GuildEvent> {
@@ -1212,6 +1807,18 @@ class ApiClient {
 		return this.get(`/guilds/discover${qs ? '?' + qs : ''}`);
 	}
 
+	autocompleteDiscoverGuilds(q: string, limit?: number): Promise<GuildSuggestion[]> {
+		const query = new URLSearchParams({ q });
+		if (limit) query.set('limit', String(limit));
+		return this.get(`/guilds/discover/autocomplete?${query}`);
+	}
+
+	autocompleteGuildMembers(guildId: string, q: string, limit?: number): Promise<MemberSuggestion[]> {
+		const query = new URLSearchParams({ q });
+		if (limit) query.set('limit', String(limit));
+		return this.get(`/guilds/${guildId}/members/autocomplete?${query}`);
+	}
+
 	getGuildPreview(guildId: string): Promise<Guild & { member_count: number }> {
 		return this.get(`/guilds/${guildId}/preview`);
 	}
@@ -1251,6 +1858,17 @@ class ApiClient {
 		return this.patch(`/guilds/${guildId}/reports/${reportId}`, { status });
 	}
 
+	getLinkFlags(guildId: string, params?: PageParams & { status?: string }): Promise<Page<MessageLinkFlag>> {
+		const query = new URLSearchParams();
+		if (params?.status) query.set('status', params.status);
+		const qs = query.toString();
+		return this.getPage(`/guilds/${guildId}/link-flags${qs ? '?' + qs : ''}`, params);
+	}
+
+	resolveLinkFlag(guildId: string, flagId: string, status: 'resolved' | 'dismissed'): Promise<MessageLinkFlag> {
+		return this.patch(`/guilds/${guildId}/link-flags/${flagId}`, { status });
+	}
+
 	// --- Moderation: Channel Lock ---
 
 	lockChannel(channelId: string): Promise<{ locked: boolean }> {
@@ -1301,6 +1919,64 @@ class ApiClient {
 		return this.post(`/guilds/${guildId}/automod/rules/test`, data);
 	}
 
+	// Instance AutoMod policy (admin only), applied in every guild beneath its own rules.
+
+	getInstanceAutoModRules(): Promise<AutoModRule[]> {
+		return this.get('/admin/automod/rules');
+	}
+
+	createInstanceAutoModRule(data: Partial<AutoModRule>): Promise<AutoModRule> {
+		return this.post('/admin/automod/rules', data);
+	}
+
+	updateInstanceAutoModRule(ruleId: string, data: Partial<AutoModRule>): Promise<AutoModRule> {
+		return this.patch(`/admin/automod/rules/${ruleId}`, data);
+	}
+
+	deleteInstanceAutoModRule(ruleId: string): Promise<void> {
+		return this.del(`/admin/automod/rules/${ruleId}`);
+	}
+
+	// Link safety blocklist (admin only).
+
+	getLinkBlocklist(): Promise<LinkBlocklistEntry[]> {
+		return this.get('/admin/link-blocklist');
+	}
+
+	addLinkBlocklistDomain(domain: string, reason?: string): Promise<LinkBlocklistEntry> {
+		return this.post('/admin/link-blocklist', { domain, reason });
+	}
+
+	removeLinkBlocklistDomain(domain: string): Promise<void> {
+		return this.del(`/admin/link-blocklist/${encodeURIComponent(domain)}`);
+	}
+
+	reportTokenLeak(content: string, url?: string): Promise<TokenLeakResult> {
+		return this.post('/admin/token-leaks', { content, url });
+	}
+
+	// Operational alert webhooks (admin only).
+
+	getAlertWebhooks(): Promise<OperatorAlertWebhook[]> {
+		return this.get('/admin/alert-webhooks');
+	}
+
+	createAlertWebhook(data: Partial<OperatorAlertWebhook> & { name: string; url: string }): Promise<OperatorAlertWebhook> {
+		return this.post('/admin/alert-webhooks', data);
+	}
+
+	updateAlertWebhook(webhookId: string, data: Partial<OperatorAlertWebhook> & { rotate_secret?: boolean }): Promise<OperatorAlertWebhook> {
+		return this.patch(`/admin/alert-webhooks/${webhookId}`, data);
+	}
+
+	deleteAlertWebhook(webhookId: string): Promise<void> {
+		return this.del(`/admin/alert-webhooks/${webhookId}`);
+	}
+
+	testAlertWebhook(webhookId: string): Promise<AlertWebhookDelivery> {
+		return this.post(`/admin/alert-webhooks/${webhookId}/test`);
+	}
+
 	// --- Role Updates ---
 
 	updateRole(guildId: string, roleId: string, data: Partial<Role>): Promise<Role> {
@@ -1327,10 +2003,48 @@ class ApiClient {
 		return this.patch(`/guilds/${guildId}/channels`, positions);
 	}
 
+	moveChannel(guildId: string, channelId: string, categoryId: string | null, position: number): Promise<ChannelPositions> {
+		return this.post(`/guilds/${guildId}/channels/${channelId}/move`, { category_id: categoryId, position });
+	}
+
 	reorderGuilds(positions: { guild_id: string; position: number }[]): Promise<void> {
 		return this.put('/users/@me/guild-positions', positions);
 	}
 
+	// --- Guild layout (folders and favorites) ---
+
+	getGuildLayout(): Promise<GuildLayout> {
+		return this.get('/users/@me/guild-layout');
+	}
+
+	createGuildFolder(name: string, guildIds: string[] = [], color?: string): Promise<GuildFolder> {
+		return this.post('/users/@me/guild-folders', { name, color, guild_ids: guildIds });
+	}
+
+	updateGuildFolder(folderId: string, data: { name?: string; color?: string; position?: number }): Promise<GuildFolder> {
+		return this.patch(`/users/@me/guild-folders/${folderId}`, data);
+	}
+
+	setGuildFolderGuilds(folderId: string, guildIds: string[]): Promise<GuildFolder> {
+		return this.put(`/users/@me/guild-folders/${folderId}/guilds`, { guild_ids: guildIds });
+	}
+
+	deleteGuildFolder(folderId: string): Promise<void> {
+		return this.del(`/users/@me/guild-folders/${folderId}`);
+	}
+
+	setFavorites(channelIds: string[]): Promise<GuildLayout> {
+		return this.put('/users/@me/favorites', { channel_ids: channelIds });
+	}
+
+	addFavorite(channelId: string): Promise<void> {
+		return this.put(`/users/@me/favorites/${channelId}`);
+	}
+
+	removeFavorite(channelId: string): Promise<void> {
+		return this.del(`/users/@me/favorites/${channelId}`);
+	}
+
 	// --- Onboarding ---
 
 	getOnboarding(guildId: string): Promise<OnboardingConfig> {
@@ -1459,6 +2173,14 @@ class ApiClient {
 		return this.del(`/bots/${botId}/tokens/${tokenId}`);
 	}
 
+	getBotUsage(botId: string, days?: number): Promise<ApiUsageReport> {
+		return this.get(`/bots/${botId}/usage${days ? `?days=${days}` : ''}`);
+	}
+
+	getMyApiUsage(days?: number): Promise<ApiUsageReport> {
+		return this.get(`/users/@me/usage${days ? `?days=${days}` : ''}`);
+	}
+
 	getBotCommands(botId: string): Promise<SlashCommand[]> {
 		return this.get(`/bots/${botId}/commands`);
 	}
@@ -1643,6 +2365,28 @@ class ApiClient {
 		return this.del(`/guilds/${guildId}/retention/${policyId}`);
 	}
 
+	// --- Instance Retention & Legal Holds ---
+
+	getInstanceRetention(): Promise<InstanceRetention> {
+		return this.get('/admin/instance-retention');
+	}
+
+	updateInstanceRetention(update: Partial<InstanceRetention>): Promise<InstanceRetention> {
+		return this.patch('/admin/instance-retention', update);
+	}
+
+	getLegalHolds(includeReleased = false): Promise<LegalHold[]> {
+		return this.get(`/admin/legal-holds${includeReleased ? '?include_released=true' : ''}`);
+	}
+
+	createLegalHold(hold: { target_type: LegalHold['target_type']; target_id: string; reason: string }): Promise<LegalHold> {
+		return this.post('/admin/legal-holds', hold);
+	}
+
+	releaseLegalHold(holdId: string): Promise<LegalHold> {
+		return this.del(`/admin/legal-holds/${holdId}`);
+	}
+
 	// --- Forum Tags ---
 
 	getForumTags(channelId: string): Promise<ForumTag[]> {
@@ -1921,6 +2665,26 @@ class ApiClient {
 		return this.get(`/channels/${channelId}/export?format=${format}`);
 	}
 
+	getTranscriptLinks(channelId: string): Promise<TranscriptLink[]> {
+		return this.get(`/channels/${channelId}/transcripts`);
+	}
+
+	createTranscriptLink(channelId: string, opts?: { visibility?: TranscriptLink['visibility']; expires_in_seconds?: number }): Promise<TranscriptLink> {
+		return this.post(`/channels/${channelId}/transcripts`, opts ?? {});
+	}
+
+	revokeTranscriptLink(channelId: string, linkId: string): Promise<void> {
+		return this.del(`/channels/${channelId}/transcripts/${linkId}`);
+	}
+
+	getTranscriptAccesses(channelId: string, linkId: string): Promise<TranscriptAccess[]> {
+		return this.get(`/channels/${channelId}/transcripts/${linkId}/accesses`);
+	}
+
+	getTranscript(code: string): Promise<any> {
+		return this.get(`/transcripts/${code}`);
+	}
+
 	// --- Admin Health ---
 
 	getHealthDashboard(): Promise<any> {
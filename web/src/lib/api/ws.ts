@@ -2,6 +2,7 @@
 // Handles connection, heartbeating, identify, resume, and event dispatch.
 
 import { GatewayOp, type GatewayMessage, type ReadyEvent } from '$lib/types';
+import { CLIENT_VERSION } from '$lib/version';
 
 export type EventHandler = (eventType: string, data: unknown) => void;
 
@@ -83,6 +84,12 @@ export class GatewayClient {
 				return;
 			}
 
+			// This build is too old for the server — reconnecting won't help.
+			if (event.code === 4010) {
+				console.error('[GW] Client version no longer supported, not reconnecting');
+				return;
+			}
+
 			// Notify listeners immediately so UI can show "Reconnecting..."
 			this.emit('GATEWAY_DISCONNECTED', null);
 			this.scheduleReconnect();
@@ -122,6 +129,7 @@ export class GatewayClient {
 
 			case GatewayOp.HeartbeatAck:
 				this.heartbeatAcked = true;
+				if (msg.d) this.emit('HEARTBEAT_ACK', msg.d);
 				break;
 
 			case GatewayOp.Dispatch:
@@ -149,12 +157,17 @@ export class GatewayClient {
 		if (this.sessionId && this.sequence > 0) {
 			this.send({
 				op: GatewayOp.Resume,
-				d: { token: this.token, session_id: this.sessionId, seq: this.sequence }
+				d: {
+					token: this.token,
+					session_id: this.sessionId,
+					seq: this.sequence,
+					client_version: CLIENT_VERSION
+				}
 			});
 		} else {
 			this.send({
 				op: GatewayOp.Identify,
-				d: { token: this.token }
+				d: { token: this.token, client_version: CLIENT_VERSION }
 			});
 		}
 	}
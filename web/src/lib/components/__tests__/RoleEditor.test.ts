@@ -37,12 +37,12 @@ const permissionGroupKeys = [
 	// Members
 	'KickMembers', 'BanMembers', 'TimeoutMembers', 'ManageRoles', 'AssignRoles', 'ManageNicknames', 'RemoveAvatars',
 	// Information
-	'ViewAuditLog', 'ViewGuildInsights', 'MentionHere', 'ManagePermissions',
+	'ViewAuditLog', 'ViewGuildInsights', 'MentionHere', 'MentionEveryone', 'ManagePermissions',
 	// Channel
 	'ViewChannel', 'ReadHistory', 'SendMessages', 'ManageMessages', 'EmbedLinks',
-	'UploadFiles', 'AddReactions', 'UseExternalEmoji', 'Masquerade', 'ManageThreads', 'CreateThreads',
+	'UploadFiles', 'AddReactions', 'UseExternalEmoji', 'Masquerade', 'ManageThreads', 'CreateThreads', 'ExportMessages',
 	// Voice
-	'Connect', 'Speak', 'MuteMembers', 'DeafenMembers', 'MoveMembers', 'UseVAD', 'PrioritySpeaker', 'Stream',
+	'Connect', 'Speak', 'MuteMembers', 'DeafenMembers', 'MoveMembers', 'UseVAD', 'PrioritySpeaker', 'Stream', 'ScreenShare',
 	// Special
 	'ChangeNickname', 'ChangeAvatar', 'Administrator',
 ];
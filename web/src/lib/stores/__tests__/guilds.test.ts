@@ -17,7 +17,11 @@ import {
 	updateGuild,
 	removeGuild,
 	loadGuilds,
-	isGuildFederated
+	isGuildFederated,
+	guildFolders,
+	guildPositions,
+	setGuildLayout,
+	removeGuildFolder
 } from '../guilds';
 import { api } from '$lib/api/client';
 import type { Guild } from '$lib/types';
@@ -36,9 +40,13 @@ function createMockGuild(overrides?: Partial<Guild>): Guild {
 		nsfw: false,
 		discoverable: false,
 		preferred_locale: 'en-US',
+		timezone: 'UTC',
 		max_members: 1000,
 		vanity_url: null,
 		verification_level: 0,
+		system_join_messages: true,
+		system_boost_messages: true,
+		system_event_reminders: true,
 		tags: [],
 		member_count: 1,
 		created_at: new Date().toISOString(),
@@ -50,6 +58,7 @@ describe('guilds store', () => {
 	beforeEach(() => {
 		guilds.set(new Map());
 		currentGuildId.set(null);
+		setGuildLayout(undefined);
 	});
 
 	it('starts with empty guild map', () => {
@@ -179,4 +188,30 @@ describe('guilds store', () => {
 		(undefinedGuild as any).instance_id = undefined;
 		expect(isGuildFederated(undefinedGuild, 'local-inst')).toBe(false);
 	});
+
+	it('guildList follows the user layout, then sorts unplaced guilds by name', () => {
+		updateGuild(createMockGuild({ id: 'g-b', name: 'Beta' }));
+		updateGuild(createMockGuild({ id: 'g-a', name: 'Alpha' }));
+		updateGuild(createMockGuild({ id: 'g-c', name: 'Gamma' }));
+		setGuildLayout({
+			guild_positions: [{ guild_id: 'g-c', position: 0, folder_id: null, folder_position: 0 }],
+			folders: [],
+			favorites: []
+		});
+
+		expect(get(guildList).map((g) => g.id)).toEqual(['g-c', 'g-a', 'g-b']);
+	});
+
+	it('removeGuildFolder drops the folder and moves its guilds to the top level', () => {
+		setGuildLayout({
+			guild_positions: [{ guild_id: 'g1', position: 0, folder_id: 'f1', folder_position: 0 }],
+			folders: [{ id: 'f1', name: 'Games', position: 0, color: null, guild_ids: ['g1'], created_at: '' }],
+			favorites: []
+		});
+
+		removeGuildFolder('f1');
+
+		expect(get(guildFolders)).toEqual([]);
+		expect(get(guildPositions)[0].folder_id).toBeNull();
+	});
 });
@@ -22,6 +22,7 @@ function createMockMessage(overrides?: Partial<Message>): Message {
 		mention_user_ids: [],
 		mention_role_ids: [],
 		mention_here: false,
+		mention_everyone: false,
 		thread_id: null,
 		masquerade_name: null,
 		masquerade_avatar: null,
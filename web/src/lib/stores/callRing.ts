@@ -4,6 +4,7 @@ import { writable, derived } from 'svelte/store';
 
 export interface IncomingCall {
 	channelId: string;
+	callId?: string | null;
 	callerId: string;
 	callerName: string;
 	callerDisplayName?: string | null;
@@ -11,9 +12,10 @@ export interface IncomingCall {
 	callerInstanceId?: string | null;
 	channelType: 'dm' | 'group';
 	timestamp: number;
+	timeoutMs?: number;
 }
 
-const RING_TIMEOUT_MS = 30_000; // Stop ringing after 30 seconds.
+const RING_TIMEOUT_MS = 30_000; // Stop ringing after 30 seconds unless the server says otherwise.
 
 // Map of channelId -> IncomingCall
 const _incomingCalls = writable<Map<string, IncomingCall>>(new Map());
@@ -37,7 +39,7 @@ export const incomingCallCount = derived(_incomingCalls, ($calls) => $calls.size
 
 const timeouts = new Map<string, ReturnType<typeof setTimeout>>();
 
-/** Add an incoming call ring. Auto-expires after 30 seconds. */
+/** Add an incoming call ring. Auto-expires after the ring timeout. */
 export function addIncomingCall(call: IncomingCall) {
 	_incomingCalls.update((map) => {
 		const next = new Map(map);
@@ -52,7 +54,7 @@ export function addIncomingCall(call: IncomingCall) {
 	// Auto-dismiss after timeout.
 	const timeout = setTimeout(() => {
 		dismissIncomingCall(call.channelId);
-	}, RING_TIMEOUT_MS);
+	}, call.timeoutMs ?? RING_TIMEOUT_MS);
 	timeouts.set(call.channelId, timeout);
 }
 
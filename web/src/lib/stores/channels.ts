@@ -1,7 +1,7 @@
 // Channel store — manages channels for the current guild.
 
 import { writable, derived } from 'svelte/store';
-import type { Channel } from '$lib/types';
+import type { Channel, ChannelPositions } from '$lib/types';
 import { api } from '$lib/api/client';
 import { createMapStore } from '$lib/stores/mapHelpers';
 
@@ -175,6 +175,17 @@ export function updateChannel(channel: Channel) {
 	channels.setEntry(channel.id, channel);
 }
 
+/** Apply a CHANNEL_POSITIONS_UPDATE: new category and position for each listed channel. */
+export function applyChannelPositions(positions: NonNullable<ChannelPositions['channels']>) {
+	channels.update(map => {
+		for (const p of positions) {
+			const ch = map.get(p.id);
+			if (ch) map.set(p.id, { ...ch, category_id: p.category_id, position: p.position });
+		}
+		return new Map(map);
+	});
+}
+
 export function removeChannel(channelId: string) {
 	channels.removeEntry(channelId);
 }
@@ -0,0 +1,27 @@
+// Feature flags store -- the staged features switched on for the current user.
+// Sent in READY and fetched again when an operator changes a flag.
+
+import { writable, derived } from 'svelte/store';
+import { api } from '$lib/api/client';
+import type { FeatureFlagState } from '$lib/types';
+
+export const featureFlags = writable<FeatureFlagState>({ flags: [], guilds: {} });
+
+export function setFeatureFlags(state: FeatureFlagState | undefined) {
+	featureFlags.set(state ?? { flags: [], guilds: {} });
+}
+
+export async function loadFeatureFlags() {
+	try {
+		setFeatureFlags(await api.getFeatureFlags());
+	} catch {
+		// Keep the flags from READY; they are refreshed on the next change.
+	}
+}
+
+/** Whether a flag is on for the user, optionally within a guild. */
+export const isFeatureEnabled = derived(
+	featureFlags,
+	($flags) => (key: string, guildId?: string | null) =>
+		$flags.flags.includes(key) || (!!guildId && ($flags.guilds[guildId]?.includes(key) ?? false))
+);
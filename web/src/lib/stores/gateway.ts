@@ -4,16 +4,17 @@ import { writable, get } from 'svelte/store';
 import { goto } from '$app/navigation';
 import { GatewayClient } from '$lib/api/ws';
 import { currentUser } from './auth';
-import { loadGuilds, updateGuild, removeGuild, currentGuildId } from './guilds';
-import { updateChannel, removeChannel, loadChannels, channels as channelsStore, currentChannelId } from './channels';
-import { appendMessage, updateMessage, removeMessage, removeMessages, loadMessages } from './messages';
+import { loadGuilds, updateGuild, removeGuild, currentGuildId, setGuildLayout } from './guilds';
+import { updateChannel, removeChannel, applyChannelPositions, loadChannels, channels as channelsStore, currentChannelId } from './channels';
+import { setFeatureFlags, loadFeatureFlags } from './featureFlags';
+import { appendMessage, updateMessage, removeMessage, removeMessages, loadMessages, applyDMReceipt } from './messages';
 import { updatePresence } from './presence';
 import { addTypingUser, clearTypingUser } from './typing';
 import { loadDMs, addDMChannel, removeDMChannel, updateUserInDMs, updateDMChannel, dmChannels } from './dms';
 import { incrementUnread, loadReadState, loadChannelGuildMap, registerChannelGuild } from './unreads';
 import { handleNotificationCreate, handleNotificationUpdate, handleNotificationDelete, loadNotifications } from './notifications';
 import { initPushNotifications } from '$lib/utils/pushNotifications';
-import { handleVoiceStateUpdate, clearChannelVoiceUsers } from './voice';
+import { handleVoiceStateUpdate, handleScreenShareEvent, clearChannelVoiceUsers } from './voice';
 import { loadRelationships, addOrUpdateRelationship, removeRelationship } from './relationships';
 import { loadPermissions, invalidatePermissions } from './permissions';
 import { loadChannelMutePrefs, isChannelMuted, isGuildMuted } from './muting';
@@ -23,10 +24,12 @@ import { addToast } from './toast';
 import { clearChannelMessages } from './messages';
 import { addAnnouncement, updateAnnouncement, removeAnnouncement } from './announcements';
 import { addIncomingCall, dismissIncomingCall, clearIncomingCalls } from './callRing';
-import { clearChannelUnreads } from './unreads';
-import type { User, Guild, Channel, Message, ReadyEvent, TypingEvent, Relationship, ServerNotification } from '$lib/types';
+import { clearChannelUnreads, clearChannelsUnreads } from './unreads';
+import type { User, Guild, Channel, ChannelPositions, Message, ReadyEvent, GuildLayout, TypingEvent, Relationship, ServerNotification, Call, MaintenanceWindow, HeartbeatAck, DMReceipt } from '$lib/types';
 
 export const gatewayConnected = writable(false);
+// Last heartbeat round trip reported by the gateway, for connection quality.
+export const gatewayLatency = writable<HeartbeatAck | null>(null);
 
 let client: GatewayClient | null = null;
 let hasReceivedReady = false;
@@ -41,6 +44,8 @@ export function connectGateway(token: string) {
 			case 'READY': {
 				const ready = data as ReadyEvent;
 				currentUser.set(ready.user);
+				setFeatureFlags(ready.feature_flags);
+				setGuildLayout(ready.guild_layout);
 				gatewayConnected.set(true);
 				loadGuilds();
 				loadDMs();
@@ -78,6 +83,7 @@ export function connectGateway(token: string) {
 							avatar_id: vs.avatar_id,
 							muted: vs.self_mute,
 							deafened: vs.self_deaf,
+							screen_sharing: vs.screen_sharing,
 							action: 'join'
 						});
 					}
@@ -100,9 +106,15 @@ export function connectGateway(token: string) {
 			}
 
 			// --- Gateway lifecycle ---
+			case 'HEARTBEAT_ACK': {
+				const ack = data as HeartbeatAck;
+				if (ack.rtt_ms !== undefined) gatewayLatency.set(ack);
+				break;
+			}
 			case 'GATEWAY_DISCONNECTED':
 				// Connection dropped — mark disconnected immediately for UI feedback.
 				gatewayConnected.set(false);
+				gatewayLatency.set(null);
 				addToast('Connection lost. Reconnecting...', 'warning', 5000);
 				break;
 			case 'GATEWAY_AUTH_FAILED':
@@ -110,6 +122,20 @@ export function connectGateway(token: string) {
 				disconnectGateway();
 				goto('/login');
 				break;
+			case 'UPGRADE_REQUIRED': {
+				// This build is older than the server supports; the gateway closes
+				// the connection after this event and we stay disconnected.
+				const { upgrade_url } = data as { upgrade_url?: string };
+				gatewayConnected.set(false);
+				addToast(
+					upgrade_url
+						? `This version of AmityVox is no longer supported. Update at ${upgrade_url}`
+						: 'This version of AmityVox is no longer supported. Reload the page to update.',
+					'error',
+					0
+				);
+				break;
+			}
 			case 'GATEWAY_EXHAUSTED':
 				// Too many failed reconnects — mark disconnected.
 				gatewayConnected.set(false);
@@ -150,6 +176,11 @@ export function connectGateway(token: string) {
 				}
 				break;
 			}
+			case 'CHANNEL_POSITIONS_UPDATE': {
+				const layout = data as ChannelPositions;
+				if (layout.channels) applyChannelPositions(layout.channels);
+				break;
+			}
 			case 'CHANNEL_DELETE': {
 				const deleted = data as { id: string };
 				removeChannel(deleted.id);
@@ -266,26 +297,41 @@ export function connectGateway(token: string) {
 			case 'CALL_RING': {
 				const ring = data as {
 					channel_id: string;
+					call_id?: string;
 					caller_id: string;
 					caller_name: string;
 					caller_display_name?: string | null;
 					caller_avatar_id?: string | null;
 					caller_instance_id?: string | null;
 					channel_type: 'dm' | 'group';
+					timeout_seconds?: number;
 				};
 				addIncomingCall({
 					channelId: ring.channel_id,
+					callId: ring.call_id,
 					callerId: ring.caller_id,
 					callerName: ring.caller_name,
 					callerDisplayName: ring.caller_display_name,
 					callerAvatarId: ring.caller_avatar_id,
 					callerInstanceId: ring.caller_instance_id,
 					channelType: ring.channel_type,
-					timestamp: Date.now()
+					timestamp: Date.now(),
+					timeoutMs: ring.timeout_seconds ? ring.timeout_seconds * 1000 : undefined
 				});
 				break;
 			}
 
+			case 'CALL_UPDATE': {
+				// Stop ringing once the call is answered, declined by us, or over.
+				const call = data as Call;
+				let selfId: string | undefined;
+				currentUser.subscribe((u) => (selfId = u?.id))();
+				if (call.status !== 'ringing' || (selfId && call.declined_ids?.includes(selfId))) {
+					dismissIncomingCall(call.channel_id);
+				}
+				break;
+			}
+
 			// --- Relationship events (friend requests) ---
 			// --- Guild member events ---
 			case 'GUILD_MEMBER_UPDATE': {
@@ -411,6 +457,29 @@ export function connectGateway(token: string) {
 				break;
 			}
 
+			// --- Guild order, folders and favorites changed (user-scoped) ---
+			case 'GUILD_LAYOUT_UPDATE':
+				setGuildLayout(data as GuildLayout);
+				break;
+
+			// --- Guild-wide or global ack from another session (user-scoped) ---
+			case 'CHANNELS_ACK': {
+				const ack = data as { guild_id: string | null; channel_ids: string[] };
+				clearChannelsUnreads(ack.channel_ids);
+				break;
+			}
+
+			// --- DM delivery and read receipts (user-scoped, sent to the author) ---
+			case 'DM_RECEIPT': {
+				const receipt = data as DMReceipt;
+				// A group DM's status waits for every recipient, which one
+				// receipt cannot tell; it is brought up to date on the next load.
+				if (get(dmChannels).get(receipt.channel_id)?.channel_type === 'dm') {
+					applyDMReceipt(receipt.channel_id, receipt.message_id, receipt.status);
+				}
+				break;
+			}
+
 			// --- Channel widget events ---
 			case 'CHANNEL_WIDGET_CREATE':
 			case 'CHANNEL_WIDGET_UPDATE':
@@ -503,7 +572,10 @@ export function connectGateway(token: string) {
 			// --- Screen share events ---
 			case 'SCREEN_SHARE_START':
 			case 'SCREEN_SHARE_END':
-				// Screen share lifecycle — handled by voice components.
+				handleScreenShareEvent(
+					data as { channel_id: string; user_id: string },
+					event === 'SCREEN_SHARE_START'
+				);
 				break;
 
 			// --- Location share events ---
@@ -534,6 +606,46 @@ export function connectGateway(token: string) {
 			case 'ANNOUNCEMENT_DELETE':
 				removeAnnouncement((data as { id: string }).id);
 				break;
+
+			// --- Maintenance events (instance-wide) ---
+			case 'MAINTENANCE_SCHEDULE': {
+				const mw = data as MaintenanceWindow;
+				if (mw.cancelled_at) {
+					addToast(`Maintenance "${mw.title}" was cancelled`, 'info');
+				} else if (new Date(mw.starts_at) > new Date()) {
+					addToast(`Maintenance "${mw.title}" is scheduled for ${new Date(mw.starts_at).toLocaleString()}`, 'info', 10000);
+				}
+				break;
+			}
+			case 'MAINTENANCE_START': {
+				const mw = data as MaintenanceWindow;
+				addToast(
+					mw.read_only
+						? `Maintenance started: ${mw.title}. Changes cannot be saved until ${new Date(mw.ends_at).toLocaleTimeString()}.`
+						: `Maintenance started: ${mw.title}`,
+					'warning',
+					mw.read_only ? 0 : 10000
+				);
+				break;
+			}
+			case 'MAINTENANCE_END':
+				addToast(`Maintenance finished: ${(data as MaintenanceWindow).title}`, 'success');
+				break;
+			case 'SUBSYSTEMS_UPDATE': {
+				const { disabled_subsystems, reason } = data as { disabled_subsystems: string[]; reason?: string };
+				if (disabled_subsystems.length > 0) {
+					addToast(
+						`Temporarily unavailable: ${disabled_subsystems.join(', ')}${reason ? ` (${reason})` : ''}`,
+						'warning',
+						10000
+					);
+				}
+				break;
+			}
+
+			case 'FEATURE_FLAGS_UPDATE':
+				loadFeatureFlags();
+				break;
 		}
 	});
 
@@ -1,13 +1,27 @@
 // Guild store — manages guild list and current guild selection.
 
 import { writable, derived } from 'svelte/store';
-import type { Guild } from '$lib/types';
+import type { Guild, GuildFolder, GuildPosition, ChannelFavorite, GuildLayout } from '$lib/types';
 import { api } from '$lib/api/client';
 import { createMapStore } from '$lib/stores/mapHelpers';
 
 export const guilds = createMapStore<string, Guild>();
 export const currentGuildId = writable<string | null>(null);
-export const guildList = derived(guilds, ($guilds) => Array.from($guilds.values()));
+
+// The user's guild layout, synced from the server (READY and GUILD_LAYOUT_UPDATE).
+export const guildPositions = writable<GuildPosition[]>([]);
+export const guildFolders = writable<GuildFolder[]>([]);
+export const channelFavorites = writable<ChannelFavorite[]>([]);
+
+/** Guilds in the user's order; guilds they never placed follow by name. */
+export const guildList = derived([guilds, guildPositions], ([$guilds, $positions]) => {
+	const order = new Map($positions.map(p => [p.guild_id, p.position]));
+	return Array.from($guilds.values()).sort((a, b) => {
+		const pa = order.get(a.id) ?? Infinity;
+		const pb = order.get(b.id) ?? Infinity;
+		return pa !== pb ? pa - pb : a.name.localeCompare(b.name);
+	});
+});
 export const currentGuild = derived(
 	[guilds, currentGuildId],
 	([$guilds, $id]) => ($id ? $guilds.get($id) ?? null : null)
@@ -38,3 +52,18 @@ export function updateGuild(guild: Guild) {
 export function removeGuild(guildId: string) {
 	guilds.removeEntry(guildId);
 }
+
+export function setGuildLayout(layout: GuildLayout | undefined) {
+	guildPositions.set(layout?.guild_positions ?? []);
+	guildFolders.set(layout?.folders ?? []);
+	channelFavorites.set(layout?.favorites ?? []);
+}
+
+export async function loadGuildFolders() {
+	setGuildLayout(await api.getGuildLayout());
+}
+
+export function removeGuildFolder(folderId: string) {
+	guildFolders.update(list => list.filter(f => f.id !== folderId));
+	guildPositions.update(list => list.map(p => (p.folder_id === folderId ? { ...p, folder_id: null, folder_position: 0 } : p)));
+}
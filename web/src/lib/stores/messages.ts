@@ -1,7 +1,7 @@
 // Message store — manages messages for the current channel.
 
 import { writable, derived } from 'svelte/store';
-import type { Message } from '$lib/types';
+import type { Message, MessageStatus } from '$lib/types';
 import { api } from '$lib/api/client';
 
 // Messages keyed by channel ID, each containing a sorted array.
@@ -36,8 +36,28 @@ export async function loadMessages(channelId: string, before?: string) {
 export function appendMessage(msg: Message) {
 	messagesByChannel.update((map) => {
 		const existing = map.get(msg.channel_id) ?? [];
-		// Avoid duplicates (by nonce or ID).
-		if (existing.some((m) => m.id === msg.id)) return map;
+		// Avoid duplicates (by nonce or ID). A message matching a local echo
+		// by nonce replaces the echo.
+		const dup = existing.findIndex((m) => m.id === msg.id);
+		if (dup >= 0) {
+			// The send response can arrive after the gateway copy; keep its status.
+			if (!msg.status || existing[dup].status) return map;
+			map.set(msg.channel_id, existing.map((m, i) => (i === dup ? { ...m, status: msg.status } : m)));
+			return new Map(map);
+		}
+		const echo = msg.nonce ? existing.findIndex((m) => m.nonce === msg.nonce) : -1;
+		if (echo >= 0) {
+			map.set(msg.channel_id, existing.map((m, i) => (i === echo ? { ...msg, status: msg.status ?? m.status } : m)));
+			return new Map(map);
+		}
+		if (msg.inserted_above) {
+			// Out-of-order federated message: place it by ID, not at the end.
+			const at = existing.findIndex((m) => m.id > msg.id);
+			if (at >= 0) {
+				map.set(msg.channel_id, [...existing.slice(0, at), msg, ...existing.slice(at)]);
+				return new Map(map);
+			}
+		}
 		map.set(msg.channel_id, [...existing, msg]);
 		return new Map(map);
 	});
@@ -49,7 +69,27 @@ export function updateMessage(msg: Message) {
 		if (!existing) return map;
 		map.set(
 			msg.channel_id,
-			existing.map((m) => (m.id === msg.id ? msg : m))
+			existing.map((m) => (m.id === msg.id ? { ...msg, status: msg.status ?? m.status } : m))
+		);
+		return new Map(map);
+	});
+}
+
+const statusRank: Record<MessageStatus, number> = { sent: 0, delivered: 1, read: 2 };
+
+// Advances the status of the user's own DM messages up to messageId. Only
+// messages that carry a status are touched, and a status never goes back.
+export function applyDMReceipt(channelId: string, messageId: string, status: MessageStatus) {
+	messagesByChannel.update((map) => {
+		const existing = map.get(channelId);
+		if (!existing) return map;
+		map.set(
+			channelId,
+			existing.map((m) =>
+				m.status && m.id <= messageId && statusRank[m.status] < statusRank[status]
+					? { ...m, status }
+					: m
+			)
 		);
 		return new Map(map);
 	});
@@ -169,13 +169,12 @@ export async function loadChannelGuildMap() {
 	}
 }
 
-// Mark all channels as read.
-export async function markAllRead() {
-	const counts = get(unreadCounts);
-	const channelIds = [...counts.keys()].filter((id) => (counts.get(id) ?? 0) > 0);
-
-	// Clear all locally first.
-	unreadCounts.clear();
+// Clear unread counts and mentions locally for channels acked elsewhere.
+export function clearChannelsUnreads(channelIds: string[]) {
+	unreadCounts.update((map) => {
+		for (const id of channelIds) map.delete(id);
+		return new Map(map);
+	});
 	unreadState.update((map) => {
 		for (const id of channelIds) {
 			const entry = map.get(id);
@@ -183,13 +182,27 @@ export async function markAllRead() {
 		}
 		return new Map(map);
 	});
+}
 
-	// Ack each channel on the server (best-effort).
-	for (const id of channelIds) {
-		try {
-			await api.ackChannel(id);
-		} catch {
-			// Best-effort.
-		}
+// Mark every channel of a guild as read in one request.
+export async function markGuildRead(guildId: string) {
+	const guildMap = get(channelGuildMap);
+	clearChannelsUnreads([...guildMap.keys()].filter((id) => guildMap.get(id) === guildId));
+
+	try {
+		await api.ackGuild(guildId);
+	} catch {
+		// Best-effort.
+	}
+}
+
+// Mark all channels as read in one request.
+export async function markAllRead() {
+	clearChannelsUnreads([...get(unreadCounts).keys(), ...get(unreadState).keys()]);
+
+	try {
+		await api.ackAll();
+	} catch {
+		// Best-effort.
 	}
 }
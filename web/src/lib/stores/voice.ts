@@ -30,6 +30,7 @@ export interface VoiceParticipant {
 	muted: boolean;
 	deafened: boolean;
 	speaking: boolean;
+	streaming: boolean;
 }
 
 export interface VideoTrackInfo {
@@ -267,6 +268,7 @@ export function handleVoiceStateUpdate(data: {
 	instance_id?: string | null;
 	muted?: boolean;
 	deafened?: boolean;
+	screen_sharing?: boolean;
 	action?: 'join' | 'leave' | 'update';
 }) {
 	const currentChannelId = get(voiceChannelId);
@@ -295,7 +297,8 @@ export function handleVoiceStateUpdate(data: {
 			instanceId: data.instance_id ?? null,
 			muted: data.muted ?? false,
 			deafened: data.deafened ?? false,
-			speaking: false
+			speaking: false,
+			streaming: data.screen_sharing ?? false
 		};
 
 		if (data.channel_id === currentChannelId) {
@@ -312,6 +315,30 @@ export function handleVoiceStateUpdate(data: {
 	}
 }
 
+// Mark a participant as live or not when they start or stop sharing their screen.
+export function handleScreenShareEvent(data: { channel_id: string; user_id: string }, streaming: boolean) {
+	const setStreaming = (map: Map<string, VoiceParticipant>) => {
+		const p = map.get(data.user_id);
+		if (!p || p.streaming === streaming) return map;
+		const next = new Map(map);
+		next.set(data.user_id, { ...p, streaming });
+		return next;
+	};
+
+	if (data.channel_id === get(voiceChannelId)) {
+		voiceParticipants.update(setStreaming);
+	}
+	channelVoiceUsers.update((outer) => {
+		const inner = outer.get(data.channel_id);
+		if (!inner) return outer;
+		const nextInner = setStreaming(inner);
+		if (nextInner === inner) return outer;
+		const next = new Map(outer);
+		next.set(data.channel_id, nextInner);
+		return next;
+	});
+}
+
 // --- Channel-level voice participants (for sidebar display) ---
 
 // Map of channelId → Map<userId, VoiceParticipant>
@@ -7,6 +7,7 @@ export interface User {
 	username: string;
 	display_name: string | null;
 	avatar_id: string | null;
+	avatar_url?: string;
 	status_text: string | null;
 	status_emoji: string | null;
 	status_presence: 'online' | 'idle' | 'dnd' | 'busy' | 'invisible' | 'offline';
@@ -15,6 +16,7 @@ export interface User {
 	bot_owner_id: string | null;
 	email: string | null;
 	banner_id: string | null;
+	banner_url?: string;
 	accent_color: string | null;
 	pronouns: string | null;
 	flags: number;
@@ -22,6 +24,9 @@ export interface User {
 	last_online: string | null;
 	created_at: string;
 	instance_domain?: string | null; // Set for federated/remote users
+	// Private settings, only present on the user's own profile.
+	share_federated_presence?: boolean;
+	read_receipts?: boolean;
 }
 
 export interface UserLink {
@@ -42,6 +47,61 @@ export interface MutualGuild {
 	instance_id?: string | null;
 }
 
+// What happens to messages linking to guild invites, here, on federated
+// instances or on Discord.
+export type InviteLinkPolicy = 'allow' | 'strip' | 'require_manage_guild';
+
+// How members may edit their messages in a guild; a window of 0 is no limit.
+export interface GuildEditPolicy {
+	guild_id: string;
+	edit_window_minutes: number;
+	snapshot_edits: boolean;
+	lock_reported: boolean;
+	updated_at?: string;
+}
+
+// Which instances new members of a guild may come from. Blocked domains are
+// refused in every mode; local users are always accepted.
+export interface GuildJoinOriginPolicy {
+	guild_id: string;
+	mode: 'open' | 'local_only' | 'allowlist';
+	allowed_domains: string[];
+	blocked_domains: string[];
+	updated_at?: string;
+}
+
+// Reaction emoji statistics for a guild over the last `weeks` weeks. name
+// and animated are set for the guild's custom emoji.
+export interface EmojiUsage {
+	emoji: string;
+	name?: string;
+	animated?: boolean;
+	reactions: number;
+}
+
+export interface TrendingEmoji {
+	emoji: string;
+	name?: string;
+	animated?: boolean;
+	this_week: number;
+	last_week: number;
+}
+
+export interface ReactedMessage {
+	message_id: string;
+	channel_id: string;
+	author_id: string | null;
+	created_at: string;
+	reactions: number;
+}
+
+export interface GuildEmojiStats {
+	weeks: number;
+	top_emoji: EmojiUsage[];
+	trending: TrendingEmoji[];
+	top_messages: ReactedMessage[];
+}
+
 export interface Guild {
 	id: string;
 	instance_id: string | null;
@@ -51,16 +111,29 @@ export interface Guild {
 	description: string | null;
 	icon_id: string | null;
 	banner_id: string | null;
+	icon_url?: string;
+	banner_url?: string;
 	default_permissions: number;
 	flags: number;
 	nsfw: boolean;
 	discoverable: boolean;
 	preferred_locale: string;
+	timezone: string;
 	max_members: number;
 	vanity_url: string | null;
 	verification_level: number;
+	// Channel the server posts join, boost and event reminder messages to;
+	// send '' to clear it. Each kind can be turned off below.
+	system_channel_id?: string | null;
+	system_join_messages: boolean;
+	system_boost_messages: boolean;
+	system_event_reminders: boolean;
+	invite_link_policy?: InviteLinkPolicy;
 	tags: string[];
 	member_count: number;
+	// Increases with every edit; send it back on PATCH to be refused (409
+	// version_conflict) instead of overwriting someone else's newer edit.
+	version?: number;
 	created_at: string;
 }
 
@@ -86,6 +159,29 @@ export interface Channel {
 	archived: boolean;
 	parent_channel_id: string | null;
 	last_activity_at: string | null;
+	// Keep bots or webhooks from posting, e.g. in human-only channels.
+	disallow_bot_posts?: boolean;
+	disallow_webhook_posts?: boolean;
+	// Lets invite links through whatever the guild's invite link policy.
+	invite_links_exempt?: boolean;
+	// Voice room a text channel is tied to. Members connected to it skip
+	// slowmode and gain voice_participant_allow; send '' to untie.
+	voice_channel_id?: string | null;
+	voice_participant_allow?: number;
+	// Chat of a voice or stage channel: how long after leaving members can
+	// still read it (0 = only while connected), and whether it is cleared
+	// once the room empties.
+	voice_chat_recent_minutes?: number;
+	voice_chat_history?: 'keep' | 'session';
+	// Preview channels stay listed, locked, to members who cannot view them;
+	// preview_locked is set on the channels the caller only previews.
+	preview_enabled?: boolean;
+	preview_role_id?: string | null;
+	preview_locked?: boolean;
+	public_view?: boolean;
+	// ISO 639 code of the channel's primary language; used for search, translation
+	// and AutoMod word lists.
+	language?: string | null;
 	// Forum-specific fields.
 	forum_default_sort?: string;
 	forum_post_guidelines?: string | null;
@@ -96,6 +192,7 @@ export interface Channel {
 	gallery_require_tags?: boolean;
 	pinned?: boolean;
 	reply_count?: number;
+	version?: number;
 	created_at: string;
 	recipients?: User[];
 }
@@ -116,6 +213,7 @@ export interface Message {
 	mention_user_ids: string[];
 	mention_role_ids: string[];
 	mention_here: boolean;
+	mention_everyone: boolean;
 	thread_id: string | null;
 	masquerade_name: string | null;
 	masquerade_avatar: string | null;
@@ -124,14 +222,32 @@ export interface Message {
 	encryption_session_id: string | null;
 	voice_duration_ms?: number | null;
 	voice_waveform?: number[] | null;
+	content_warning?: string | null;
 	attachments: Attachment[];
 	embeds: Embed[];
 	reactions: Reaction[];
 	pinned: boolean;
+	repeat_count?: number;
+	// Set when the author is blocked by the viewer; content is withheld.
+	blocked?: boolean;
+	// Delivery status of the viewer's own messages in DMs and group DMs.
+	status?: MessageStatus;
+	// Set on federated messages that arrived after newer ones in the channel.
+	inserted_above?: boolean;
 	created_at: string;
 	author?: User;
 }
 
+export type MessageStatus = 'sent' | 'delivered' | 'read';
+
+// DM_RECEIPT gateway event: a recipient received or read a DM up to message_id.
+export interface DMReceipt {
+	channel_id: string;
+	user_id: string;
+	message_id: string;
+	status: 'delivered' | 'read';
+}
+
 export type MessageType =
 	| 'default'
 	| 'system_join'
@@ -143,7 +259,11 @@ export type MessageType =
 	| 'thread_created'
 	| 'voice'
 	| 'poll'
-	| 'system_lockdown';
+	| 'system_lockdown'
+	| 'system_missed_call'
+	| 'system_boost'
+	| 'system_event_reminder'
+	| 'system_security_alert';
 
 export interface ScheduledMessage {
 	id: string;
@@ -174,13 +294,27 @@ export interface Attachment {
 	s3_bucket: string;
 	s3_key: string;
 	blurhash: string | null;
+	thumbhash?: string;
+	variants?: AttachmentVariant[];
 	alt_text?: string;
 	nsfw: boolean;
+	spoiler?: boolean;
 	description: string | null;
 	instance_id?: string | null;
+	url?: string;
+	thumbnail_url?: string;
 	created_at: string;
 }
 
+export interface AttachmentVariant {
+	width: number;
+	height: number;
+	format: 'avif' | 'webp' | 'jpeg';
+	content_type: string;
+	size_bytes: number;
+	url: string;
+}
+
 export interface MediaTag {
 	id: string;
 	name: string;
@@ -218,9 +352,31 @@ export interface Role {
 	position: number;
 	permissions_allow: string;
 	permissions_deny: string;
+	version?: number;
+	community_role_id?: string;
 	created_at: string;
 }
 
+// A named permission set offered when creating a role: built in, or saved by
+// the guild.
+export interface PermissionPreset {
+	id: string;
+	guild_id?: string;
+	name: string;
+	description?: string | null;
+	permissions_allow: string;
+	permissions_deny: string;
+	builtin: boolean;
+	created_by?: string | null;
+	created_at?: string;
+}
+
+// Whether a guild backs timeouts with its managed "Timed Out" role.
+export interface TimeoutRoleState {
+	enabled: boolean;
+	role_id: string | null;
+}
+
 export interface GuildMember {
 	guild_id: string;
 	user_id: string;
@@ -254,6 +410,13 @@ export interface Category {
 	position: number;
 }
 
+/** Normalized sidebar layout sent with CHANNEL_POSITIONS_UPDATE. */
+export interface ChannelPositions {
+	guild_id: string;
+	channels?: { id: string; category_id: string | null; position: number }[];
+	categories?: { id: string; position: number }[];
+}
+
 // --- WebSocket Gateway Types ---
 
 export interface GatewayMessage {
@@ -278,6 +441,13 @@ export const GatewayOp = {
 	HeartbeatAck: 11
 } as const;
 
+// HEARTBEAT_ACK payload: the round trip the server last measured for this
+// connection, and the deployment region of the gateway node.
+export interface HeartbeatAck {
+	rtt_ms?: number;
+	region?: string;
+}
+
 export interface ReadyEvent {
 	user: User;
 	guild_ids: string[];
@@ -289,11 +459,81 @@ export interface ReadyEvent {
 		guild_id: string;
 		self_mute: boolean;
 		self_deaf: boolean;
+		screen_sharing?: boolean;
 		username?: string;
 		display_name?: string | null;
 		avatar_id?: string | null;
 	}>;
 	// No more federated_guilds — they come in guild_ids now with instance_id set
+	preferences?: ReadyPreferences;
+	feature_flags?: FeatureFlagState;
+	guild_layout?: GuildLayout;
+}
+
+// The user's own arrangement of their guild list and favorite channels,
+// synced across devices. Guilds without a position sort after the rest by name.
+export interface GuildPosition {
+	guild_id: string;
+	position: number;
+	folder_id: string | null;
+	folder_position: number;
+}
+
+export interface GuildFolder {
+	id: string;
+	name: string;
+	position: number;
+	color: string | null;
+	guild_ids: string[];
+	created_at: string;
+}
+
+export interface ChannelFavorite {
+	channel_id: string;
+	guild_id: string | null;
+	position: number;
+	created_at: string;
+}
+
+export interface GuildLayout {
+	guild_positions: GuildPosition[];
+	folders: GuildFolder[];
+	favorites: ChannelFavorite[];
+}
+
+// SelfGuild is a guild in the current user's guild list, with their roles
+// and permissions in it and what they have not read.
+export interface SelfGuild extends Guild {
+	owner: boolean;
+	role_ids: string[];
+	permissions: number;
+	unread_channels: number;
+	mention_count: number;
+	last_activity_at: string | null;
+}
+
+// Feature flags on for the user; guilds lists those on only within a guild.
+export interface FeatureFlagState {
+	flags: string[];
+	guilds: Record<string, string[]>;
+}
+
+export interface ReadyPreferences {
+	guilds: Array<{
+		guild_id: string | null;
+		level: 'all' | 'mentions' | 'none';
+		suppress_here: boolean;
+		suppress_roles: boolean;
+		muted_until: string | null;
+	}>;
+	channels: Array<{
+		channel_id: string;
+		level: 'all' | 'mentions' | 'none';
+		muted_until: string | null;
+	}>;
+	hidden_thread_ids: string[];
+	muted_guild_ids: string[];
+	muted_channel_ids: string[];
 }
 
 export interface TypingEvent {
@@ -328,6 +568,15 @@ export interface AdminStats {
 	mem_sys_mb: number;
 	num_cpu: number;
 	uptime: string;
+	cleanup: CleanupStat[];
+}
+
+export interface CleanupStat {
+	kind: 'invites' | 'channel_followers' | 'files';
+	last_removed: number;
+	total_removed: number;
+	bytes_reclaimed: number;
+	last_run_at: string;
 }
 
 export interface InstanceInfo {
@@ -343,6 +592,89 @@ export interface InstanceInfo {
 	created_at: string;
 }
 
+export interface BrandingLink {
+	label: string;
+	url: string;
+}
+
+export interface InstanceBranding {
+	product_name?: string;
+	logo_url?: string;
+	icon_url?: string;
+	colors: {
+		primary?: string;
+		accent?: string;
+		background?: string;
+		text?: string;
+	};
+	login_title?: string;
+	login_message?: string;
+	links: BrandingLink[];
+}
+
+export interface PublicInstance {
+	domain: string;
+	name: string;
+	description: string | null;
+	software_version: string;
+	registration_mode: string;
+	registration_message: string;
+	min_client_version?: string;
+	motd?: string;
+	branding: InstanceBranding;
+}
+
+export interface MaintenanceWindow {
+	id: string;
+	title: string;
+	message: string;
+	starts_at: string;
+	ends_at: string;
+	read_only: boolean;
+	created_by?: string;
+	created_at: string;
+	cancelled_at?: string;
+}
+
+export interface InstanceStatus {
+	status: 'ok' | 'maintenance' | 'read_only';
+	motd?: string;
+	maintenance: MaintenanceWindow | null;
+	upcoming: MaintenanceWindow[];
+	disabled_subsystems: Subsystem[];
+}
+
+export type Subsystem = 'registration' | 'messaging' | 'uploads' | 'federation';
+
+export interface DisabledSubsystems {
+	registration: boolean;
+	messaging: boolean;
+	uploads: boolean;
+	federation: boolean;
+	reason?: string;
+}
+
+export type FeatureFlagTarget = 'user' | 'guild' | 'instance';
+
+export interface FeatureFlagOverride {
+	target_type: FeatureFlagTarget;
+	target_id: string;
+	enabled: boolean;
+	created_at: string;
+}
+
+export interface FeatureFlag {
+	key: string;
+	description: string;
+	enabled: boolean;
+	user_percent: number;
+	guild_percent: number;
+	instance_percent: number;
+	overrides: FeatureFlagOverride[];
+	created_at: string;
+	updated_at: string;
+}
+
 // --- Ban ---
 
 export interface Ban {
@@ -372,10 +704,14 @@ export interface AuditLogEntry {
 
 export interface CustomEmoji {
 	id: string;
-	guild_id: string;
+	guild_id?: string;
 	name: string;
 	creator_id: string | null;
 	animated: boolean;
+	origin: 'guild' | 'instance' | 'remote';
+	file_id?: string;
+	instance_id?: string;
+	url?: string;
 	created_at: string;
 }
 
@@ -399,6 +735,20 @@ export interface ReadState {
 	mention_count: number;
 }
 
+export interface ActivitySummary {
+	channel_id: string;
+	since_message_id: string | null;
+	message_count: number;
+	participant_count: number;
+	mention_count: number;
+	first_message_id: string | null;
+	last_message_id: string | null;
+	top_participants: { user_id: string; username: string; display_name: string | null; message_count: number }[];
+	active_threads: { thread_id: string; name: string | null; message_count: number }[];
+	recap_available: boolean;
+	recap?: string;
+}
+
 // --- Relationship (Friend/Block) ---
 
 export interface Relationship {
@@ -448,6 +798,38 @@ export interface MessageBookmark {
 	message?: Message;
 }
 
+export interface MemberSuggestion {
+	user_id: string;
+	username: string;
+	display_name?: string | null;
+	nickname?: string | null;
+	avatar_id?: string | null;
+}
+
+export interface UserSuggestion {
+	id: string;
+	username: string;
+	display_name?: string | null;
+	avatar_id?: string | null;
+	instance_id: string;
+	friend: boolean;
+}
+
+export interface GuildSuggestion {
+	id: string;
+	name: string;
+	icon_id?: string | null;
+	member_count: number;
+}
+
+export interface MentionInboxItem {
+	message: Message;
+	guild_id: string | null;
+	guild_name: string | null;
+	channel_name: string | null;
+	unread: boolean;
+}
+
 // --- Guild Events ---
 
 export interface GuildEvent {
@@ -519,10 +901,11 @@ export interface RaidConfig {
 
 export interface AutoModRule {
 	id: string;
-	guild_id: string;
+	guild_id?: string;
+	instance?: boolean; // part of the instance policy; guild exemptions do not apply
 	name: string;
 	enabled: boolean;
-	rule_type: 'word_filter' | 'regex_filter' | 'invite_filter' | 'mention_spam' | 'caps_filter' | 'spam_filter' | 'link_filter';
+	rule_type: 'word_filter' | 'regex_filter' | 'invite_filter' | 'mention_spam' | 'caps_filter' | 'spam_filter' | 'link_filter' | 'emoji_hash' | 'content_warning' | 'attachment_hash' | 'name_filter';
 	action: 'delete' | 'warn' | 'timeout' | 'log';
 	config: Record<string, unknown>;
 	exempt_roles: string[];
@@ -534,7 +917,8 @@ export interface AutoModRule {
 
 export interface AutoModAction {
 	id: string;
-	rule_id: string;
+	rule_id?: string;
+	instance_rule_id?: string;
 	guild_id: string;
 	channel_id: string;
 	user_id: string;
@@ -545,6 +929,65 @@ export interface AutoModAction {
 	created_at: string;
 }
 
+export interface LinkVerdict {
+	url: string;
+	threat: string;
+	source: 'blocklist' | 'safe_browsing';
+}
+
+// A message whose malicious links were rewritten, queued for moderator review.
+export interface MessageLinkFlag {
+	id: string;
+	guild_id: string;
+	channel_id: string;
+	message_id: string;
+	author_id: string;
+	verdicts: LinkVerdict[];
+	status: 'open' | 'resolved' | 'dismissed';
+	resolved_by?: string;
+	resolved_at?: string;
+	created_at: string;
+}
+
+export interface LinkBlocklistEntry {
+	domain: string;
+	reason?: string;
+	created_by?: string;
+	created_at: string;
+}
+
+// Outbound webhook for operational alerts (admin only). signing_secret is
+// only present when the webhook is created or its secret rotated.
+export interface OperatorAlertWebhook {
+	id: string;
+	name: string;
+	url: string;
+	format: 'json' | 'slack' | 'matrix';
+	min_severity: 'info' | 'warning' | 'critical';
+	kinds: string[];
+	signing_secret?: string;
+	enabled: boolean;
+	created_by?: string;
+	created_at: string;
+	last_delivered_at?: string;
+	last_status?: number;
+	last_error?: string;
+}
+
+export interface AlertWebhookDelivery {
+	success: boolean;
+	status_code: number;
+	response_preview?: string;
+	error?: string;
+}
+
+// Credentials revoked after a token leak report; invalid or already revoked
+// ones are left out.
+export interface TokenLeakResult {
+	webhooks: { id: string; guild_id: string; channel_id: string; name: string }[];
+	bot_tokens: { id: string; bot_id: string; name: string }[];
+}
+
 // --- User Badges ---
 
 export interface UserBadge {
@@ -610,6 +1053,155 @@ export interface BotToken {
 	last_used_at: string | null;
 }
 
+export interface ApiUsageCounts {
+	requests: number;
+	errors: number;
+	rate_limited: number;
+}
+
+export interface ApiUsageReport {
+	user_id: string;
+	days: number;
+	totals: ApiUsageCounts;
+	daily: (ApiUsageCounts & { day: string })[];
+	tokens?: (ApiUsageCounts & { token_id: string; name: string; scope: string; last_used_at: string | null })[];
+}
+
+export interface UserContentSettings {
+	show_nsfw: boolean;
+	nsfw_media: 'show' | 'blur' | 'hide';
+	safe_search: boolean;
+	age_confirmed_at: string | null;
+	updated_at: string | null;
+}
+
+// Which profile fields are shared with remote instances; 'local' keeps a
+// field to members of this instance.
+export type ProfileVisibility = 'everyone' | 'local';
+
+export interface ProfilePrivacy {
+	bio: ProfileVisibility;
+	pronouns: ProfileVisibility;
+	banner: ProfileVisibility;
+	connections: ProfileVisibility;
+	activity: ProfileVisibility;
+	updated_at: string | null;
+}
+
+export interface GuildBroadcast {
+	id: string;
+	guild_id: string;
+	author_id: string;
+	content: string;
+	status: 'pending' | 'sending' | 'completed' | 'cancelled';
+	recipient_count: number;
+	delivered_count: number;
+	skipped_count: number;
+	failed_count: number;
+	created_at: string;
+	started_at: string | null;
+	completed_at: string | null;
+}
+
+export interface GuildScheduledPurge {
+	id: string;
+	guild_id: string;
+	kind: 'category' | 'role';
+	target_id: string;
+	run_at: string;
+	repeat_days: number | null;
+	status: 'pending' | 'completed' | 'failed';
+	affected: number;
+	error?: string;
+	created_by: string | null;
+	created_at: string;
+	last_run_at: string | null;
+}
+
+export interface GuildEmojiAlias {
+	guild_id: string;
+	alias: string;
+	emoji: string;
+	created_by: string | null;
+	created_at: string;
+}
+
+// A group of guilds sharing branding, a ban list and roles.
+export interface Community {
+	id: string;
+	owner_id: string;
+	name: string;
+	description?: string;
+	icon_id?: string;
+	banner_id?: string;
+	accent_color?: string;
+	discoverable: boolean;
+	created_at: string;
+	guilds?: CommunityGuild[];
+}
+
+export interface CommunityGuild {
+	guild_id: string;
+	community_id: string;
+	status: 'pending' | 'active';
+	name: string;
+	icon_id?: string;
+	member_count: number;
+	added_at: string;
+}
+
+export interface CommunityRole {
+	id: string;
+	community_id: string;
+	name: string;
+	color?: string;
+	permissions_allow: number;
+	permissions_deny: number;
+	member_count: number;
+	created_at: string;
+}
+
+export interface CommunityBan {
+	community_id: string;
+	user_id: string;
+	reason?: string;
+	banned_by: string | null;
+	created_at: string;
+	user?: User;
+}
+
+export interface DirectoryGuild {
+	id: string;
+	name: string;
+	description: string | null;
+	icon_id: string | null;
+	member_count: number;
+}
+
+export interface DirectoryEntry {
+	instance_id: string;
+	domain: string;
+	name: string | null;
+	description: string | null;
+	user_count: number;
+	federation_mode: 'open' | 'allowlist' | 'closed';
+	software_version: string | null;
+	featured_guilds: DirectoryGuild[];
+	fetched_at?: string;
+	peer_status?: 'active' | 'pending' | 'blocked' | null;
+}
+
+export interface RecommendedGuild {
+	id: string;
+	name: string;
+	description?: string;
+	icon_id?: string;
+	member_count: number;
+	instance_id: string;
+	instance_domain: string;
+	instance_shorthand?: string;
+}
+
 export interface SlashCommand {
 	id: string;
 	bot_id: string;
@@ -627,6 +1219,19 @@ export interface ApiResponse<T> {
 	data: T;
 }
 
+// Page is one page of a cursor-paginated list. next_cursor continues in the
+// direction the page was fetched and is null at the end of the list.
+export interface Page<T> {
+	data: T[];
+	next_cursor: string | null;
+}
+
+export interface PageParams {
+	before?: string;
+	after?: string;
+	limit?: number;
+}
+
 export interface ApiError {
 	error: {
 		code: string;
@@ -653,6 +1258,8 @@ export interface NotificationPreference {
 	suppress_here: boolean;
 	suppress_roles: boolean;
 	muted_until: string | null;
+	// Whose reactions to your messages notify you; global preferences only.
+	reactions?: 'all' | 'friends' | 'off';
 }
 
 // --- Channel Notification Preferences ---
@@ -667,9 +1274,9 @@ export interface ChannelNotificationPreference {
 // --- Server Notifications (persistent, server-backed) ---
 
 export type ServerNotificationType =
-	| 'mention' | 'reply' | 'dm' | 'thread_reply' | 'message_pinned' | 'reaction_added'
+	| 'mention' | 'reply' | 'dm' | 'thread_reply' | 'message_pinned' | 'reaction_added' | 'reaction_digest'
 	| 'friend_request' | 'friend_accepted' | 'guild_invite' | 'member_joined'
-	| 'warned' | 'muted' | 'kicked' | 'banned' | 'report_resolved'
+	| 'warned' | 'muted' | 'kicked' | 'banned' | 'report_resolved' | 'security_alert'
 	| 'event_starting' | 'announcement';
 
 export type NotificationCategory = 'messages' | 'social' | 'moderation' | 'content';
@@ -718,6 +1325,27 @@ export interface Webhook {
 	created_at: string;
 }
 
+// --- Message Sources ---
+
+export type MessageSource = 'human' | 'bot' | 'webhook' | 'bridge';
+
+export type MessageSourceCounts = Record<MessageSource, number>;
+
+export interface ChannelSourceRateLimit {
+	channel_id: string;
+	source: MessageSource;
+	max_messages: number;
+	window_seconds: number;
+	updated_at: string;
+}
+
+export interface MessageSourceStats {
+	days: ({ day: string } & MessageSourceCounts)[];
+	totals: MessageSourceCounts;
+	integrations: { source: Exclude<MessageSource, 'human'>; source_id: string; name: string; messages: number; channels: number }[];
+	limits: ChannelSourceRateLimit[];
+}
+
 // --- User Settings (Privacy/Notification client prefs) ---
 
 export interface UserSettings {
@@ -726,6 +1354,9 @@ export interface UserSettings {
 	notification_sound_preset?: string; // 'default' | 'chime' | 'bell' | 'pop' | 'none'
 	notification_volume?: number; // 0-100, default 80
 	dm_privacy: 'everyone' | 'friends' | 'nobody';
+	// Who can add you to group DMs directly; everyone else sends an invite
+	// you accept or decline.
+	group_dm_privacy?: 'everyone' | 'friends' | 'nobody';
 	friend_request_privacy: 'everyone' | 'mutual_guilds' | 'nobody';
 	nsfw_content_filter?: 'blur_all' | 'blur_suspicious' | 'show_all';
 	dnd_schedule?: {
@@ -742,6 +1373,7 @@ export interface UserSettings {
 	}>;
 	active_custom_theme?: string | null;
 	custom_css?: string;
+	version?: number;
 	[key: string]: unknown;
 }
 
@@ -755,10 +1387,75 @@ export interface FederationPeer {
 	software: string | null;
 	software_version: string | null;
 	status: string;
+	trust_level?: 'trusted' | 'normal' | 'restricted';
+	sandbox?: boolean;
 	last_seen_at: string | null;
 	created_at: string;
 }
 
+export interface FederationSandboxStatus {
+	dry_run: boolean;
+	sandbox_peers: { peer_id: string; domain: string }[];
+	outbound_events: number;
+	inbound_events: number;
+}
+
+export interface FederationSandboxEvent {
+	id: string;
+	direction: 'outbound' | 'inbound';
+	peer_id?: string;
+	domain: string;
+	event_type: string;
+	guild_id?: string;
+	channel_id?: string;
+	payload: unknown;
+	created_at: string;
+}
+
+// An inbound federation request refused before processing.
+export interface FederationRejection {
+	id: string;
+	sender_id: string;
+	domain?: string;
+	remote_addr: string;
+	endpoint: string;
+	reason: 'bad_signature' | 'unknown_peer' | 'clock_skew';
+	detail?: string;
+	created_at: string;
+}
+
+// A short-lived token letting `amityvox admin` run the listed actions over the
+// API. The raw token is only returned when minted.
+export interface AdminActionToken {
+	id: string;
+	token: string;
+	actions: string[];
+	max_uses: number;
+	expires_at: string;
+}
+
+export interface FederatedDMRequest {
+	id: string;
+	remote_instance_id: string;
+	instance_domain: string;
+	requester_id: string;
+	requester?: User;
+	channel_type: 'dm' | 'group';
+	created_at: string;
+}
+
+// A pending invite to a group DM, sent when the invitee's group_dm_privacy
+// setting keeps the inviter from adding them directly.
+export interface GroupDMInvite {
+	id: string;
+	channel_id: string;
+	channel_name?: string | null;
+	inviter_id: string;
+	inviter?: User;
+	target_id: string;
+	created_at: string;
+}
+
 export interface KeyAuditEntry {
 	id: string;
 	instance_id: string;
@@ -938,6 +1635,51 @@ export interface VoicePreferences {
 	screenshare_audio: boolean;
 }
 
+// --- DM Calls ---
+
+export type CallStatus = 'ringing' | 'active' | 'ended' | 'missed' | 'declined';
+
+export interface Call {
+	id: string;
+	channel_id: string;
+	caller_id: string;
+	status: CallStatus;
+	participant_ids: string[];
+	declined_ids: string[];
+	started_at: string;
+	answered_at?: string;
+	ended_at?: string;
+	duration_seconds?: number;
+}
+
+export type ScreenShareResolution = '720p' | '1080p' | '4k';
+export type ScreenShareFramerate = 15 | 30 | 60;
+
+export interface ScreenShareConfig {
+	guild_id: string;
+	max_resolution: ScreenShareResolution;
+	max_framerate: ScreenShareFramerate;
+}
+
+export interface ChannelInputMode {
+	channel_id: string;
+	guild_id: string;
+	input_mode: 'vad' | 'ptt';
+}
+
+// A push subscription registered on the server, one per browser or device.
+export interface PushSubscriptionInfo {
+	id: string;
+	endpoint: string;
+	user_agent?: string;
+	created_at: string;
+	last_used: string;
+	failure_count: number;
+	last_failure_at?: string;
+	last_error?: string;
+	vapid_public_key?: string;
+}
+
 export interface RetentionPolicy {
 	id: string;
 	channel_id: string | null;
@@ -954,6 +1696,48 @@ export interface RetentionPolicy {
 	updated_at: string;
 }
 
+// A shareable, expiring link to a channel's messages as of snapshot_at.
+export interface TranscriptLink {
+	id: string;
+	code: string;
+	channel_id: string;
+	created_by: string;
+	visibility: 'members' | 'link';
+	snapshot_at: string;
+	expires_at: string;
+	revoked_at?: string;
+	access_count: number;
+	last_accessed_at?: string;
+	created_at: string;
+}
+
+export interface TranscriptAccess {
+	id: string;
+	link_id: string;
+	viewer_id?: string;
+	remote_addr: string;
+	accessed_at: string;
+}
+
+// Instance-wide retention windows in days; 0 keeps data forever.
+export interface InstanceRetention {
+	dm_retention_days: number;
+	deleted_account_retention_days: number;
+}
+
+// Exempts a user or guild from purges and freezes its exports until released.
+export interface LegalHold {
+	id: string;
+	target_type: 'user' | 'guild';
+	target_id: string;
+	target_name?: string;
+	reason: string;
+	created_by: string;
+	created_at: string;
+	released_by?: string;
+	released_at?: string;
+}
+
 export interface ForumTag {
 	id: string;
 	channel_id: string;
@@ -1024,7 +1808,8 @@ export const Permission = {
 	ViewAuditLog:      1n << 14n,
 	ViewGuildInsights: 1n << 15n,
 	MentionHere:       1n << 16n,
-	// Channel-scoped (bits 20-39)
+	MentionEveryone:   1n << 17n,
+	// Channel-scoped (bits 20-40)
 	ViewChannel:       1n << 20n,
 	ReadHistory:       1n << 21n,
 	SendMessages:      1n << 22n,
@@ -1045,6 +1830,8 @@ export const Permission = {
 	CreateInvites:     1n << 37n,
 	ManageThreads:     1n << 38n,
 	CreateThreads:     1n << 39n,
+	ScreenShare:       1n << 40n,
+	ExportMessages:    1n << 41n,
 	// Special
 	Administrator:     1n << 63n,
 } as const;
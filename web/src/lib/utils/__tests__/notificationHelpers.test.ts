@@ -196,6 +196,7 @@ describe('getCategoryForType', () => {
 		expect(getCategoryForType('kicked')).toBe('moderation');
 		expect(getCategoryForType('banned')).toBe('moderation');
 		expect(getCategoryForType('report_resolved')).toBe('moderation');
+		expect(getCategoryForType('security_alert')).toBe('moderation');
 	});
 
 	it('categorizes content types correctly', () => {
@@ -218,8 +219,8 @@ describe('isActionableType', () => {
 });
 
 describe('constants', () => {
-	it('ALL_NOTIFICATION_TYPES has 17 entries', () => {
-		expect(ALL_NOTIFICATION_TYPES).toHaveLength(17);
+	it('ALL_NOTIFICATION_TYPES has 19 entries', () => {
+		expect(ALL_NOTIFICATION_TYPES).toHaveLength(19);
 	});
 
 	it('NOTIFICATION_TYPE_LABELS covers all types', () => {
@@ -230,7 +231,7 @@ describe('constants', () => {
 
 	it('NOTIFICATION_CATEGORIES covers all types', () => {
 		const allTypes = NOTIFICATION_CATEGORIES.flatMap(c => c.types);
-		expect(allTypes).toHaveLength(17);
+		expect(allTypes).toHaveLength(19);
 		for (const type of ALL_NOTIFICATION_TYPES) {
 			expect(allTypes).toContain(type);
 		}
@@ -10,3 +10,9 @@ export function fileUrl(fileId: string, instanceId?: string | null): string {
 	if (instanceId) return `/api/v1/federation/media/${encodeURIComponent(instanceId)}/${encodeURIComponent(fileId)}`;
 	return `/api/v1/files/${fileId}`;
 }
+
+/** Build a thumbnail URL; spoilered images come back blurred. Remote files have no thumbnail route. */
+export function thumbnailUrl(fileId: string, instanceId?: string | null): string {
+	if (instanceId) return fileUrl(fileId, instanceId);
+	return `/api/v1/files/${fileId}/thumbnail`;
+}
@@ -18,6 +18,7 @@ const TYPE_DISPLAY: Record<ServerNotificationType, { icon: string; colorClass: s
 	thread_reply: { icon: '🧵', colorClass: 'text-purple-400', label: 'replied in thread' },
 	message_pinned: { icon: '📌', colorClass: 'text-amber-400', label: 'pinned a message' },
 	reaction_added: { icon: '❤', colorClass: 'text-pink-400', label: 'reacted' },
+	reaction_digest: { icon: '❤', colorClass: 'text-pink-400', label: 'and others reacted' },
 	friend_request: { icon: '👤+', colorClass: 'text-yellow-400', label: 'sent a friend request' },
 	friend_accepted: { icon: '✓', colorClass: 'text-green-400', label: 'accepted your friend request' },
 	guild_invite: { icon: '📨', colorClass: 'text-indigo-400', label: 'invited you' },
@@ -27,6 +28,7 @@ const TYPE_DISPLAY: Record<ServerNotificationType, { icon: string; colorClass: s
 	kicked: { icon: '✕', colorClass: 'text-red-400', label: 'You were kicked' },
 	banned: { icon: '🛡', colorClass: 'text-red-500', label: 'You were banned' },
 	report_resolved: { icon: '✓', colorClass: 'text-green-400', label: 'Your report was resolved' },
+	security_alert: { icon: '🔑', colorClass: 'text-red-400', label: 'Security alert' },
 	event_starting: { icon: '📅', colorClass: 'text-cyan-400', label: 'Event starting' },
 	announcement: { icon: '📢', colorClass: 'text-indigo-400', label: 'New announcement' },
 };
@@ -101,6 +103,7 @@ export function getCategoryForType(type: ServerNotificationType): NotificationCa
 		case 'thread_reply':
 		case 'message_pinned':
 		case 'reaction_added':
+		case 'reaction_digest':
 			return 'messages';
 		case 'friend_request':
 		case 'friend_accepted':
@@ -112,6 +115,7 @@ export function getCategoryForType(type: ServerNotificationType): NotificationCa
 		case 'kicked':
 		case 'banned':
 		case 'report_resolved':
+		case 'security_alert':
 			return 'moderation';
 		case 'event_starting':
 		case 'announcement':
@@ -128,9 +132,9 @@ export function isActionableType(type: ServerNotificationType): boolean {
 
 // All notification types in display order.
 export const ALL_NOTIFICATION_TYPES: ServerNotificationType[] = [
-	'mention', 'reply', 'dm', 'thread_reply', 'message_pinned', 'reaction_added',
+	'mention', 'reply', 'dm', 'thread_reply', 'message_pinned', 'reaction_added', 'reaction_digest',
 	'friend_request', 'friend_accepted', 'guild_invite', 'member_joined',
-	'warned', 'muted', 'kicked', 'banned', 'report_resolved',
+	'warned', 'muted', 'kicked', 'banned', 'report_resolved', 'security_alert',
 	'event_starting', 'announcement',
 ];
 
@@ -142,6 +146,7 @@ export const NOTIFICATION_TYPE_LABELS: Record<ServerNotificationType, string> =
 	thread_reply: 'Thread Replies',
 	message_pinned: 'Pinned Messages',
 	reaction_added: 'Reactions',
+	reaction_digest: 'Reaction Digests',
 	friend_request: 'Friend Requests',
 	friend_accepted: 'Friend Accepted',
 	guild_invite: 'Server Invites',
@@ -151,14 +156,15 @@ export const NOTIFICATION_TYPE_LABELS: Record<ServerNotificationType, string> =
 	kicked: 'Kicked',
 	banned: 'Banned',
 	report_resolved: 'Report Resolved',
+	security_alert: 'Security Alerts',
 	event_starting: 'Event Starting',
 	announcement: 'Announcements',
 };
 
 // Category labels and which types belong to each.
 export const NOTIFICATION_CATEGORIES: { label: string; category: NotificationCategory; types: ServerNotificationType[] }[] = [
-	{ label: 'Messages', category: 'messages', types: ['mention', 'reply', 'dm', 'thread_reply', 'message_pinned', 'reaction_added'] },
+	{ label: 'Messages', category: 'messages', types: ['mention', 'reply', 'dm', 'thread_reply', 'message_pinned', 'reaction_added', 'reaction_digest'] },
 	{ label: 'Social', category: 'social', types: ['friend_request', 'friend_accepted', 'guild_invite', 'member_joined'] },
-	{ label: 'Moderation', category: 'moderation', types: ['warned', 'muted', 'kicked', 'banned', 'report_resolved'] },
+	{ label: 'Moderation', category: 'moderation', types: ['warned', 'muted', 'kicked', 'banned', 'report_resolved', 'security_alert'] },
 	{ label: 'Content', category: 'content', types: ['event_starting', 'announcement'] },
 ];
@@ -15,7 +15,17 @@ export function urlBase64ToUint8Array(base64String: string): Uint8Array {
 	return outputArray;
 }
 
-// Register the service worker and subscribe to push notifications.
+// Whether a subscription was made with the given applicationServerKey.
+function subscribedWithKey(subscription: PushSubscription, key: Uint8Array): boolean {
+	const current = subscription.options.applicationServerKey;
+	if (!current) return false;
+	const bytes = new Uint8Array(current);
+	return bytes.length === key.length && bytes.every((b, i) => b === key[i]);
+}
+
+// Register the service worker and subscribe to push notifications. After
+// the server rotates its VAPID key, an existing subscription is replaced
+// with one under the new key.
 export async function initPushNotifications(): Promise<boolean> {
 	if (typeof navigator === 'undefined' || !('serviceWorker' in navigator)) return false;
 	if (!('PushManager' in window)) return false;
@@ -23,10 +33,6 @@ export async function initPushNotifications(): Promise<boolean> {
 	try {
 		const registration = await navigator.serviceWorker.ready;
 
-		// Check if already subscribed.
-		const existing = await registration.pushManager.getSubscription();
-		if (existing) return true;
-
 		// Get VAPID key from server — may not be configured.
 		let vapid_public_key: string;
 		try {
@@ -39,6 +45,13 @@ export async function initPushNotifications(): Promise<boolean> {
 
 		const applicationServerKey = urlBase64ToUint8Array(vapid_public_key);
 
+		// Keep an existing subscription unless it is bound to a retired key.
+		const existing = await registration.pushManager.getSubscription();
+		if (existing) {
+			if (subscribedWithKey(existing, applicationServerKey)) return true;
+			await existing.unsubscribe();
+		}
+
 		const subscription = await registration.pushManager.subscribe({
 			userVisibleOnly: true,
 			applicationServerKey,
@@ -52,6 +65,7 @@ export async function initPushNotifications(): Promise<boolean> {
 				p256dh: json.keys?.p256dh ?? '',
 				auth: json.keys?.auth ?? '',
 			},
+			vapid_public_key,
 		});
 
 		return true;
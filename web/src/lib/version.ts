@@ -0,0 +1,5 @@
+// The web client's version, reported to the server so operators can retire
+// old builds; see [clients] min_version in the server config.
+import { version } from '../../package.json';
+
+export const CLIENT_VERSION: string = version;